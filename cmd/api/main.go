@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/di"
@@ -23,6 +25,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Replace the bootstrap logger by the configured one
+	configured, err := logger.New(cfg.LoggerConfig())
+	if err != nil {
+		l.Error("Failed to create logger", logger.Field{Key: "error", Value: err.Error()})
+		os.Exit(1)
+	}
+	l = configured
+	cfg.LogDiagnostics(l)
+
 	// Init dependency injection
 	server, err := di.InitializeAPI(ctx, cfg, l)
 	if err != nil {
@@ -30,9 +41,29 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup graceful shutdown
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+
 	// Start server
-	if err := server.Start(ctx); err != nil {
-		l.Error("Server failed", logger.Field{Key: "error", Value: err.Error()})
+	go func() {
+		if err := server.Start(ctx); err != nil {
+			l.Error("Server failed", logger.Field{Key: "error", Value: err.Error()})
+			os.Exit(1)
+		}
+	}()
+
+	// Wait for shutdown signal
+	<-shutdown
+
+	// Drain the in-flight requests, then release the connections
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Stop(shutdownCtx); err != nil {
+		l.Error("Error during server shutdown", logger.Field{Key: "error", Value: err.Error()})
 		os.Exit(1)
 	}
+
+	l.Info("API shutdown complete")
 }
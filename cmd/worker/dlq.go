@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/messaging/rabbitmq"
+)
+
+// runDLQ inspects or requeues the dead-lettered emissions:
+//
+//	worker dlq list [-limit 50]
+//	worker dlq requeue [-request-id ID] [-limit 100]
+func runDLQ(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Missing dlq command: use list or requeue")
+	}
+
+	flags := flag.NewFlagSet("dlq "+args[0], flag.ExitOnError)
+	limit := flags.Int("limit", 0, "Maximum messages listed (default 50) or requeued (default 100)")
+	requestID := flags.String("request-id", "", "Requeue only the messages of this NFC-e")
+	flags.Parse(args[1:])
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	queue := rabbitmq.NewDeadLetterQueue(cfg.RabbitMQURL)
+	switch args[0] {
+	case "list":
+		if *limit <= 0 {
+			*limit = 50
+		}
+		letters, total, err := queue.ListDeadLetters(ctx, *limit)
+		if err != nil {
+			log.Fatalf("Failed to list dead letters: %v", err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(dto.DeadLetterListResponse{DeadLetters: letters, Total: total}); err != nil {
+			log.Fatalf("Failed to print dead letters: %v", err)
+		}
+	case "requeue":
+		if *limit <= 0 {
+			*limit = 100
+		}
+		requeued, err := queue.RequeueDeadLetters(ctx, *requestID, *limit)
+		if err != nil {
+			log.Fatalf("Failed to requeue dead letters (%d requeued): %v", requeued, err)
+		}
+		fmt.Printf("%d dead letters requeued\n", requeued)
+	default:
+		log.Fatalf("Unknown dlq command %q: use list or requeue", args[0])
+	}
+}
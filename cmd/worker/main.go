@@ -5,7 +5,6 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/di"
@@ -13,6 +12,17 @@ import (
 )
 
 func main() {
+	// Dead-letter queue maintenance runs instead of the worker
+	if len(os.Args) > 1 && os.Args[1] == "dlq" {
+		runDLQ(os.Args[2:])
+		return
+	}
+	// Rebuilding the NFC-e from their events also runs instead of the worker
+	if len(os.Args) > 1 && os.Args[1] == "projections" {
+		runProjections(os.Args[2:])
+		return
+	}
+
 	// Inicializar contexto
 	ctx := context.Background()
 	// Inicializar logger
@@ -26,6 +36,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Replace the bootstrap logger by the configured one
+	configured, err := logger.New(cfg.LoggerConfig())
+	if err != nil {
+		l.Error("Failed to create logger", logger.Field{Key: "error", Value: err.Error()})
+		os.Exit(1)
+	}
+	l = configured
+	cfg.LogDiagnostics(l)
+
 	// Init dependency injection
 	worker, err := di.InitializeWorkerManual(ctx, cfg, l)
 	if err != nil {
@@ -50,7 +69,7 @@ func main() {
 	l.Info("Shutting down worker...")
 
 	// Graceful shutdown with timeout
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := worker.Stop(shutdownCtx); err != nil {
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/database/postgres"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/database"
+)
+
+// runProjections checks the NFC-e against the projection of their events and, with -apply,
+// rebuilds the drifted ones from the events:
+//
+//	worker projections [-request-id ID] [-apply]
+func runProjections(args []string) {
+	flags := flag.NewFlagSet("projections", flag.ExitOnError)
+	requestID := flags.String("request-id", "", "Check only this NFC-e")
+	apply := flags.Bool("apply", false, "Rewrite the drifted NFC-e with the projection of their events")
+	flags.Parse(args)
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	if err := database.InitDatabase(ctx, cfg.GetDatabaseDSN(), cfg.Env); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	projections := service.NewNFCeProjectionService(postgres.NewNFCeRepository(database.GetDB()))
+	run, err := projections.Rebuild(ctx, *requestID, *apply, func(check service.ProjectionCheck) {
+		state := "consistent"
+		switch {
+		case check.Rebuilt:
+			state = "rebuilt"
+		case check.Drifted:
+			state = "drifted"
+		}
+		fmt.Printf("%s %s: row %s (version %d), events %s (version %d)\n",
+			check.RequestID, state, check.Status, check.Version, check.ProjectedStatus, check.ProjectedVersion)
+		if len(check.Anomalies) > 0 {
+			fmt.Printf("  %s\n", strings.Join(check.Anomalies, "\n  "))
+		}
+	})
+	fmt.Printf("%d NFC-e checked: %d without events, %d drifted, %d rebuilt, %d with anomalies\n",
+		run.Checked, run.WithoutEvents, run.Drifted, run.Rebuilt, run.Anomalous)
+	if err != nil {
+		log.Fatalf("Failed to rebuild projections: %v", err)
+	}
+	if !*apply && run.Drifted > 0 {
+		fmt.Println("Run again with -apply to rebuild the drifted NFC-e")
+	}
+}
@@ -1,4 +1,68 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
 // This file contains admin-specific DTOs that combine multiple domains
 // Domain-specific DTOs are defined in their respective files
+
+// AdminStatsResponse is the emission dashboard of a period: counts by status, the top
+// rejections, the authorization latency by UF, the daily series and the per-company breakdown
+type AdminStatsResponse struct {
+	From       time.Time               `json:"from"`
+	To         time.Time               `json:"to"`
+	CompanyID  string                  `json:"company_id,omitempty"`
+	ByStatus   map[string]int          `json:"by_status"`
+	Rejections []RejectionStatResponse `json:"rejections"`
+	Latency    []UFLatencyResponse     `json:"latency"`
+	Daily      []DailyStatsResponse    `json:"daily"`
+	Companies  []CompanyStatsResponse  `json:"companies"`
+}
+
+// RejectionStatResponse is the number of NFC-e rejected with a cStat
+type RejectionStatResponse struct {
+	CStat   string `json:"cstat"`
+	XMotivo string `json:"xmotivo"`
+	Count   int    `json:"count"`
+}
+
+// UFLatencyResponse holds the percentiles of the time from intake to authorization in a UF
+type UFLatencyResponse struct {
+	UF    string `json:"uf"`
+	Count int    `json:"count"`
+	P50Ms int64  `json:"p50_ms"`
+	P90Ms int64  `json:"p90_ms"`
+	P99Ms int64  `json:"p99_ms"`
+}
+
+// DailyStatsResponse counts the NFC-e created on a day (Brasília time)
+type DailyStatsResponse struct {
+	Date       string       `json:"date"` // YYYY-MM-DD
+	Total      int          `json:"total"`
+	Authorized int          `json:"authorized"`
+	Rejected   int          `json:"rejected"`
+	Canceled   int          `json:"canceled"`
+	Valor      money.Amount `json:"valor"` // vNF of the authorized NFC-e
+}
+
+// CompanyStatsResponse counts the NFC-e of a company for the billing team
+type CompanyStatsResponse struct {
+	CompanyID   string       `json:"company_id"`
+	CNPJ        string       `json:"cnpj"`
+	RazaoSocial string       `json:"razao_social"`
+	Total       int          `json:"total"`
+	Authorized  int          `json:"authorized"`
+	Rejected    int          `json:"rejected"`
+	Canceled    int          `json:"canceled"`
+	Billable    int          `json:"billable"` // Authorized or canceled in production
+	Valor       money.Amount `json:"valor"`    // vNF of the authorized NFC-e
+}
+
+// ReprocessNFCeRequest represents the admin request to reprocess a stuck NFC-e
+type ReprocessNFCeRequest struct {
+	// Sync queries SEFAZ by the chave de acesso of the NFC-e before emitting it again
+	Sync bool `json:"sync,omitempty"`
+}
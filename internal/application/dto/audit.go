@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// AuditEntryResponse represents a write recorded in the audit log
+type AuditEntryResponse struct {
+	ID           string              `json:"id"`
+	ActorType    string              `json:"actor_type"`
+	ActorID      string              `json:"actor_id"`
+	CompanyID    *string             `json:"company_id,omitempty"`
+	Action       string              `json:"action"`
+	ResourceType string              `json:"resource_type,omitempty"`
+	ResourceID   string              `json:"resource_id,omitempty"`
+	Changes      entity.AuditChanges `json:"changes,omitempty"`
+	Status       int                 `json:"status"`
+	IP           string              `json:"ip,omitempty"`
+	UserAgent    string              `json:"user_agent,omitempty"`
+	RequestID    string              `json:"request_id,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+// AuditLogListResponse represents a paginated list of audit entries
+type AuditLogListResponse struct {
+	Entries []AuditEntryResponse `json:"entries"`
+	Total   int                  `json:"total"`
+}
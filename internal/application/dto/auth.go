@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"time"
+)
+
+// AdminLoginRequest represents the admin credentials
+type AdminLoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// AdminLoginResponse represents the token issued to an admin
+type AdminLoginResponse struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"token_type"` // Bearer
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyRequest represents the request to issue an API key
+type CreateAPIKeyRequest struct {
+	Name string `json:"name,omitempty" binding:"omitempty,max=100"`
+}
+
+// APIKeyResponse represents an API key; Key is only filled when the key is issued
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	CompanyID  string     `json:"company_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Active     bool       `json:"active"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APIKeyListResponse represents the API keys of a company
+type APIKeyListResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
+
+// RotateAPIKeyResponse represents the key issued by a rotation and the key it replaces
+type RotateAPIKeyResponse struct {
+	Key      APIKeyResponse `json:"key"`
+	Replaced APIKeyResponse `json:"replaced"` // Valid until expires_at
+}
@@ -11,6 +11,7 @@ const (
 	CompanyStatusActive   CompanyStatus = "active"
 	CompanyStatusInactive CompanyStatus = "inactive"
 	CompanyStatusBlocked  CompanyStatus = "blocked"
+	CompanyStatusDeleted  CompanyStatus = "deleted"
 )
 
 // TaxRegime represents the tax regime of the company
@@ -26,25 +27,47 @@ const (
 type CertificateType string
 
 const (
-	CertificateTypeA1 CertificateType = "a1"
-	CertificateTypeA3 CertificateType = "a3"
+	CertificateTypeA1  CertificateType = "a1"
+	CertificateTypeA3  CertificateType = "a3"
+	CertificateTypeKMS CertificateType = "kms"
 )
 
 // CompanyDTO represents company data
 type CompanyDTO struct {
-	ID                string         `json:"id"`
-	CNPJ              string         `json:"cnpj"`
-	RazaoSocial       string         `json:"razao_social"`
-	NomeFantasia      string         `json:"nome_fantasia,omitempty"`
-	InscricaoEstadual string         `json:"inscricao_estadual,omitempty"`
-	Email             string         `json:"email"`
-	Endereco          AddressDTO     `json:"endereco"`
-	Certificado       CertificateDTO `json:"certificado"`
-	CSC               CSCDTO         `json:"csc"`
-	RegimeTributario  TaxRegime      `json:"regime_tributario"`
-	Status            CompanyStatus  `json:"status"`
-	CreatedAt         time.Time      `json:"created_at"`
-	UpdatedAt         time.Time      `json:"updated_at"`
+	ID                string                  `json:"id"`
+	CNPJ              string                  `json:"cnpj"`
+	RazaoSocial       string                  `json:"razao_social"`
+	NomeFantasia      string                  `json:"nome_fantasia,omitempty"`
+	InscricaoEstadual string                  `json:"inscricao_estadual,omitempty"`
+	Email             string                  `json:"email"`
+	Endereco          AddressDTO              `json:"endereco"`
+	Certificado       CertificateDTO          `json:"certificado"`
+	CSC               CSCDTO                  `json:"csc"`
+	CSCs              []CSCEntryDTO           `json:"cscs"`
+	DANFE             DANFEConfigDTO          `json:"danfe"`
+	Intermediadores   []IntermediadorDTO      `json:"intermediadores"`
+	FraudRules        map[string]FraudRuleDTO `json:"fraud_rules"`
+	TaxRules          []TaxRuleDTO            `json:"tax_rules"`
+	RespTec           *RespTecDTO             `json:"resp_tec,omitempty"`
+	EmailTemplate     *EmailTemplateDTO       `json:"email_template,omitempty"`
+	EmissionSettings  *EmissionSettingsDTO    `json:"emission_settings,omitempty"`
+	RegimeTributario  TaxRegime               `json:"regime_tributario"`
+	Status            CompanyStatus           `json:"status"`
+	CreatedAt         time.Time               `json:"created_at"`
+	UpdatedAt         time.Time               `json:"updated_at"`
+	DeletedAt         *time.Time              `json:"deleted_at,omitempty"`
+	StorageCleanupAt  *time.Time              `json:"storage_cleanup_at,omitempty"`
+	StorageCleanedAt  *time.Time              `json:"storage_cleaned_at,omitempty"`
+}
+
+// DeleteCompanyResponse summarizes the LGPD offboarding of a company
+type DeleteCompanyResponse struct {
+	CompanyID            string    `json:"company_id"`
+	DeletedAt            time.Time `json:"deleted_at"`
+	APIKeysRevoked       int       `json:"api_keys_revoked"`
+	SubscriptionCanceled bool      `json:"subscription_canceled"`
+	NFCeAnonymized       int       `json:"nfce_anonymized"` // NFC-e past the retention whose destinatário CPF was removed
+	StorageCleanupAt     time.Time `json:"storage_cleanup_at"`
 }
 
 // AddressDTO represents address data
@@ -55,8 +78,8 @@ type AddressDTO struct {
 	Bairro          string `json:"bairro"`
 	CodigoMunicipio string `json:"codigo_municipio"`
 	Municipio       string `json:"municipio"`
-	UF              string `json:"uf"`
-	CEP             string `json:"cep"`
+	UF              string `json:"uf" binding:"omitempty,uf"`
+	CEP             string `json:"cep" binding:"omitempty,cep"`
 }
 
 // CertificateDTO represents certificate data
@@ -67,6 +90,9 @@ type CertificateDTO struct {
 	ExpiresAt time.Time       `json:"expires_at"`
 	Subject   string          `json:"subject,omitempty"`
 	Valid     bool            `json:"valid"`
+
+	KMSEndpoint string `json:"kms_endpoint,omitempty"`
+	KMSKeyID    string `json:"kms_key_id,omitempty"`
 }
 
 // CSCDTO represents CSC data
@@ -77,32 +103,147 @@ type CSCDTO struct {
 	Valid      bool      `json:"valid"`
 }
 
+// CSCEntryDTO represents a CSC of the company registry; the token itself is never returned
+type CSCEntryDTO struct {
+	ID         string     `json:"id"`
+	Ambiente   string     `json:"ambiente"`
+	CSCID      string     `json:"csc_id"`
+	ValidFrom  time.Time  `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	Active     bool       `json:"active"` // In force now and selected for the environment QR Codes
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CSCEntryRequest registers or replaces a CSC of the company registry
+type CSCEntryRequest struct {
+	Ambiente   string     `json:"ambiente" binding:"required,oneof=producao homologacao"`
+	CSCID      string     `json:"csc_id" binding:"required"`
+	CSCToken   string     `json:"csc_token" binding:"required"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"` // Now when omitted
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+}
+
+// DANFEConfigDTO represents DANFE printing preferences
+type DANFEConfigDTO struct {
+	DuasVias                bool   `json:"duas_vias"`
+	CompactoEstabelecimento bool   `json:"compacto_estabelecimento"`
+	Papel                   string `json:"papel,omitempty"` // 80mm (default) or 58mm
+}
+
+// IntermediadorDTO represents a marketplace or delivery app registered by the company
+type IntermediadorDTO struct {
+	Alias        string `json:"alias"`
+	CNPJ         string `json:"cnpj"`
+	Nome         string `json:"nome"`
+	IdCadIntTran string `json:"id_cad_int_tran,omitempty"`
+	Padrao       bool   `json:"padrao,omitempty"`
+}
+
+// FraudRuleDTO configures a pre-emission anti-fraud check (velocity, ticket_value, duplicate)
+type FraudRuleDTO struct {
+	Action        string  `json:"action"` // off, warn or block
+	Limit         float64 `json:"limit,omitempty"`
+	WindowSeconds int     `json:"window_seconds,omitempty"`
+}
+
+// TaxRuleDTO overrides the computed taxes of the items matching NCM (or NCM prefix) and CFOP
+type TaxRuleDTO struct {
+	NCM              string   `json:"ncm,omitempty"`
+	CFOP             string   `json:"cfop,omitempty"`
+	Origem           string   `json:"origem,omitempty"`
+	CSTICMS          string   `json:"cst_icms,omitempty"` // CST or CSOSN (Simples Nacional)
+	AliquotaICMS     *float64 `json:"aliquota_icms,omitempty"`
+	CSTPIS           string   `json:"cst_pis,omitempty"`
+	AliquotaPIS      *float64 `json:"aliquota_pis,omitempty"`
+	CSTCOFINS        string   `json:"cst_cofins,omitempty"`
+	AliquotaCOFINS   *float64 `json:"aliquota_cofins,omitempty"`
+	AliquotaTributos *float64 `json:"aliquota_tributos,omitempty"`
+}
+
 // CreateCompanyRequest represents the request to create a new company
 type CreateCompanyRequest struct {
-	CNPJ              string     `json:"cnpj" validate:"required"`
-	RazaoSocial       string     `json:"razao_social" validate:"required"`
+	CNPJ              string     `json:"cnpj" binding:"required,cnpj"`
+	RazaoSocial       string     `json:"razao_social" binding:"required"`
 	NomeFantasia      string     `json:"nome_fantasia,omitempty"`
 	InscricaoEstadual string     `json:"inscricao_estadual,omitempty"`
-	Email             string     `json:"email" validate:"required,email"`
-	Endereco          AddressDTO `json:"endereco" validate:"required"`
-	RegimeTributario  TaxRegime  `json:"regime_tributario" validate:"required"`
+	Email             string     `json:"email" binding:"required,email"`
+	Endereco          AddressDTO `json:"endereco"`
+	RegimeTributario  TaxRegime  `json:"regime_tributario" binding:"required,oneof=simples_nacional lucro_presumido lucro_real"`
 }
 
 // UpdateCompanyRequest represents the request to update a company
 type UpdateCompanyRequest struct {
-	NomeFantasia      *string        `json:"nome_fantasia,omitempty"`
-	InscricaoEstadual *string        `json:"inscricao_estadual,omitempty"`
-	Email             *string        `json:"email,omitempty"`
-	Endereco          *AddressDTO    `json:"endereco,omitempty"`
-	RegimeTributario  *TaxRegime     `json:"regime_tributario,omitempty"`
-	Status            *CompanyStatus `json:"status,omitempty"`
+	NomeFantasia      *string                  `json:"nome_fantasia,omitempty"`
+	InscricaoEstadual *string                  `json:"inscricao_estadual,omitempty"`
+	Email             *string                  `json:"email,omitempty" binding:"omitempty,email"`
+	Endereco          *AddressDTO              `json:"endereco,omitempty"`
+	RegimeTributario  *TaxRegime               `json:"regime_tributario,omitempty" binding:"omitempty,oneof=simples_nacional lucro_presumido lucro_real"`
+	Status            *CompanyStatus           `json:"status,omitempty" binding:"omitempty,oneof=active inactive blocked"`
+	DANFE             *DANFEConfigDTO          `json:"danfe,omitempty"`
+	Intermediadores   *[]IntermediadorDTO      `json:"intermediadores,omitempty"`
+	FraudRules        *map[string]FraudRuleDTO `json:"fraud_rules,omitempty"`
+	TaxRules          *[]TaxRuleDTO            `json:"tax_rules,omitempty"`
+}
+
+// UpdateCertificateRequest uploads the A1 certificate as JSON instead of multipart/form-data
+type UpdateCertificateRequest struct {
+	PFXBase64 string `json:"cert_pfx_b64" binding:"required"`
+	Password  string `json:"cert_password" binding:"required"`
 }
 
 // UpdateCompanyCSCRequest represents the request to update company CSC
 type UpdateCompanyCSCRequest struct {
-	CSCID      string    `json:"csc_id" validate:"required"`
-	CSCToken   string    `json:"csc_token" validate:"required"`
-	ValidUntil time.Time `json:"valid_until" validate:"required"`
+	CSCID      string    `json:"csc_id" binding:"required"`
+	CSCToken   string    `json:"csc_token" binding:"required"`
+	ValidUntil time.Time `json:"valid_until" binding:"required"`
+}
+
+// ConfigureKMSSignerRequest selects a key kept in an HSM or cloud KMS as the company signer
+type ConfigureKMSSignerRequest struct {
+	Endpoint       string `json:"endpoint" binding:"required,url"`
+	KeyID          string `json:"key_id" binding:"required"`
+	Token          string `json:"token,omitempty"`
+	CertificatePEM string `json:"certificate_pem" binding:"required"` // Public ICP-Brasil certificate of the key
+}
+
+// RespTecDTO represents the company technical responsible; the CSRT itself is never returned
+type RespTecDTO struct {
+	CNPJ            string `json:"cnpj"`
+	Contato         string `json:"contato"`
+	Email           string `json:"email"`
+	Fone            string `json:"fone"`
+	IdCSRT          string `json:"id_csrt,omitempty"`
+	CSRTConfigurado bool   `json:"csrt_configurado"`
+}
+
+// ConfigureRespTecRequest sets the technical responsible (infRespTec) of the company NFC-e
+type ConfigureRespTecRequest struct {
+	CNPJ    string `json:"cnpj" binding:"required,cnpj"`
+	Contato string `json:"contato" binding:"required,max=60"`
+	Email   string `json:"email" binding:"required,email,max=60"`
+	Fone    string `json:"fone" binding:"required"`
+	IdCSRT  string `json:"id_csrt,omitempty"`
+	CSRT    string `json:"csrt,omitempty"`
+}
+
+// EmailTemplateDTO represents the company message e-mailing the XML and DANFE to the consumer.
+// Subject, html and text are Go templates; empty fields use the default message.
+type EmailTemplateDTO struct {
+	Disabled bool   `json:"disabled"` // Do not e-mail the consumer after authorization
+	ReplyTo  string `json:"reply_to,omitempty" binding:"omitempty,email"`
+	Subject  string `json:"subject,omitempty" binding:"max=500"`
+	HTML     string `json:"html,omitempty" binding:"max=65536"`
+	Text     string `json:"text,omitempty" binding:"max=65536"`
+}
+
+// EmissionSettingsDTO represents the ide defaults of the company documents, used when the
+// emission payload does not set them
+type EmissionSettingsDTO struct {
+	NaturezaOperacao string            `json:"natureza_operacao,omitempty" binding:"max=60"`
+	Serie            *int              `json:"serie,omitempty" binding:"omitempty,min=0,max=999"`
+	TpImp            string            `json:"tp_imp,omitempty" binding:"omitempty,oneof=0 1 2 3 4 5"`
+	CFOPPorUF        map[string]string `json:"cfop_por_uf,omitempty" binding:"omitempty,dive,keys,uf,endkeys,cfop"`
+	VerProc          string            `json:"ver_proc,omitempty" binding:"max=20"`
 }
 
 // CompanyListResponse represents a paginated list of companies
@@ -110,3 +251,20 @@ type CompanyListResponse struct {
 	Companies []CompanyDTO `json:"companies"`
 	Total     int          `json:"total"`
 }
+
+// ReadinessCheckDTO represents the outcome of one check of the company verification:
+// passed, failed or skipped
+type ReadinessCheckDTO struct {
+	Name    string         `json:"name"`
+	Status  string         `json:"status"`
+	Message string         `json:"message,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// CompanyVerificationResponse represents the readiness report of a company before its first emission
+type CompanyVerificationResponse struct {
+	CompanyID  string              `json:"company_id"`
+	Ready      bool                `json:"ready"`
+	Checks     []ReadinessCheckDTO `json:"checks"`
+	VerifiedAt time.Time           `json:"verified_at"`
+}
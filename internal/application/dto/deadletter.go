@@ -0,0 +1,18 @@
+package dto
+
+// DeadLetterListResponse represents the dead-lettered emission messages
+type DeadLetterListResponse struct {
+	DeadLetters []DeadLetter `json:"dead_letters"`
+	Total       int          `json:"total"` // Messages in the dead-letter queue
+}
+
+// RequeueDeadLettersRequest represents the request to requeue dead-lettered emissions
+type RequeueDeadLettersRequest struct {
+	RequestID string `json:"request_id,omitempty"` // Only the messages of this NFC-e; all when empty
+	Limit     int    `json:"limit,omitempty" binding:"omitempty,min=1,max=1000"`
+}
+
+// RequeueDeadLettersResponse reports how many dead letters went back to the emission queue
+type RequeueDeadLettersResponse struct {
+	Requeued int `json:"requeued"`
+}
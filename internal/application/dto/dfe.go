@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"time"
+)
+
+// DFeStatusResponse represents the NFeDistribuicaoDFe cursor of a company
+type DFeStatusResponse struct {
+	UltNSU     string     `json:"ult_nsu"`
+	MaxNSU     string     `json:"max_nsu"`
+	CStat      string     `json:"cstat,omitempty"`
+	Motivo     string     `json:"motivo,omitempty"`
+	CaughtUp   bool       `json:"caught_up"`
+	LastSyncAt *time.Time `json:"last_sync_at,omitempty"`
+	NextSyncAt time.Time  `json:"next_sync_at"`
+}
+
+// DFeDocumentResponse represents a document or event distributed to the company
+type DFeDocumentResponse struct {
+	ID           string    `json:"id"`
+	NSU          string    `json:"nsu"`
+	Schema       string    `json:"schema"`
+	Tipo         string    `json:"tipo"`
+	ChaveAcesso  string    `json:"chave_acesso,omitempty"`
+	CNPJEmitente string    `json:"cnpj_emitente,omitempty"`
+	XMLURL       string    `json:"xml_url"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// DFeDocumentListResponse represents a paginated list of distributed documents
+type DFeDocumentListResponse struct {
+	Documents []DFeDocumentResponse `json:"documents"`
+	Total     int                   `json:"total"`
+}
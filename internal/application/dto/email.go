@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"time"
+)
+
+// SendEmailRequest represents the request to e-mail the XML and DANFE of an NFC-e again
+type SendEmailRequest struct {
+	Email string `json:"email,omitempty" binding:"omitempty,email,max=255"` // Defaults to the consumer e-mail of the NFC-e
+}
+
+// EmailDeliveryResponse represents an attempt to e-mail the documents of an NFC-e
+type EmailDeliveryResponse struct {
+	ID           string     `json:"id"`
+	RequestID    string     `json:"request_id"`
+	Recipient    string     `json:"recipient"`
+	Subject      string     `json:"subject"`
+	Trigger      string     `json:"trigger"` // authorization | resend
+	Provider     string     `json:"provider"`
+	MessageID    string     `json:"message_id,omitempty"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	Succeeded    bool       `json:"succeeded"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// EmailDeliveryListResponse represents the send log of an NFC-e
+type EmailDeliveryListResponse struct {
+	Deliveries []EmailDeliveryResponse `json:"deliveries"`
+}
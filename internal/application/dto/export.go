@@ -0,0 +1,62 @@
+package dto
+
+import (
+	"time"
+)
+
+// ExportStatus represents the lifecycle of a bulk export job
+type ExportStatus string
+
+const (
+	ExportStatusPending    ExportStatus = "pending"
+	ExportStatusProcessing ExportStatus = "processing"
+	ExportStatusCompleted  ExportStatus = "completed"
+	ExportStatusFailed     ExportStatus = "failed"
+	ExportStatusExpired    ExportStatus = "expired"
+)
+
+// CreateExportRequest represents the request to create a bulk export job
+type CreateExportRequest struct {
+	PeriodStart time.Time `json:"period_start" binding:"required"`
+	PeriodEnd   time.Time `json:"period_end" binding:"required"`
+	// IncludeIndex adds an index.csv listing the NFC-e of each part
+	IncludeIndex bool `json:"include_index,omitempty"`
+}
+
+// NFCeExport is the outcome of GET /nfce/export: the zip streamed right away for small
+// periods, or the export job created for the larger ones
+type NFCeExport struct {
+	File *NFceFile
+	Job  *ExportJobResponse
+}
+
+// ExportPartResponse represents a downloadable part of an export
+type ExportPartResponse struct {
+	Number int    `json:"number"`
+	Size   int64  `json:"size"`
+	Items  int    `json:"items"`
+	URL    string `json:"url"` // Download link supporting Range requests
+}
+
+// ExportJobResponse represents a bulk export job and its progress
+type ExportJobResponse struct {
+	ID             string               `json:"id"`
+	Status         ExportStatus         `json:"status"`
+	PeriodStart    time.Time            `json:"period_start"`
+	PeriodEnd      time.Time            `json:"period_end"`
+	IncludeIndex   bool                 `json:"include_index"`
+	TotalItems     int                  `json:"total_items"`
+	ProcessedItems int                  `json:"processed_items"`
+	Progress       float64              `json:"progress"`
+	Parts          []ExportPartResponse `json:"parts"`
+	ErrorMessage   string               `json:"error_message,omitempty"`
+	ExpiresAt      time.Time            `json:"expires_at"`
+	CompletedAt    *time.Time           `json:"completed_at,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+}
+
+// ExportJobListResponse represents a paginated list of export jobs
+type ExportJobListResponse struct {
+	Exports []ExportJobResponse `json:"exports"`
+	Total   int                 `json:"total"`
+}
@@ -0,0 +1,10 @@
+package dto
+
+// GraphQLRequest is a GraphQL request, the JSON body of a POST or the query string of a GET.
+// Extensions carries the persistedQuery of the automatic persisted queries.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"time"
+)
+
+// InutilizacaoStatus represents the lifecycle of a number range inutilização
+type InutilizacaoStatus string
+
+const (
+	InutilizacaoStatusPending     InutilizacaoStatus = "pending"
+	InutilizacaoStatusProcessing  InutilizacaoStatus = "processing"
+	InutilizacaoStatusHomologated InutilizacaoStatus = "homologated"
+	InutilizacaoStatusRejected    InutilizacaoStatus = "rejected"
+)
+
+// InutilizacaoRequest represents the request to inutilize a range of NFC-e or NF-e numbers
+type InutilizacaoRequest struct {
+	UF            string `json:"uf,omitempty" binding:"omitempty,uf"` // Defaults to the company UF
+	Ambiente      string `json:"ambiente" binding:"required,oneof=producao homologacao simulado"`
+	Modelo        string `json:"modelo,omitempty" binding:"omitempty,oneof=55 65"` // Defaults to 65 (NFC-e)
+	Serie         int    `json:"serie" binding:"min=0,max=999"`
+	NumeroInicial int64  `json:"numero_inicial" binding:"required,min=1"`
+	NumeroFinal   int64  `json:"numero_final" binding:"required,min=1,max=999999999"`
+	Justificativa string `json:"justificativa" binding:"required,min=15,max=255"`
+}
+
+// InutilizacaoResponse represents an inutilized range and its SEFAZ protocol
+type InutilizacaoResponse struct {
+	ID            string             `json:"id"`
+	UF            string             `json:"uf"`
+	Ambiente      string             `json:"ambiente"`
+	Modelo        string             `json:"modelo"`
+	Serie         int                `json:"serie"`
+	NumeroInicial int64              `json:"numero_inicial"`
+	NumeroFinal   int64              `json:"numero_final"`
+	Justificativa string             `json:"justificativa"`
+	Status        InutilizacaoStatus `json:"status"`
+	Protocolo     string             `json:"protocolo,omitempty"`
+	CStat         string             `json:"cstat,omitempty"`
+	XMotivo       string             `json:"xmotivo,omitempty"`
+	XMLURL        string             `json:"xml_url,omitempty"`
+	HomologatedAt *time.Time         `json:"homologated_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+// InutilizacaoListResponse represents a paginated list of inutilizações
+type InutilizacaoListResponse struct {
+	Inutilizacoes []InutilizacaoResponse `json:"inutilizacoes"`
+	Total         int                    `json:"total"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"time"
+)
+
+// LoteStatus represents the aggregate status of the NFC-e of a lote
+type LoteStatus string
+
+const (
+	LoteStatusProcessing LoteStatus = "processing"
+	LoteStatusAuthorized LoteStatus = "authorized"
+	LoteStatusPartial    LoteStatus = "partial"
+	LoteStatusRejected   LoteStatus = "rejected"
+)
+
+// EmitLoteRequest represents the request to emit up to 50 NFC-e together
+type EmitLoteRequest struct {
+	NFces []EmitNFceRequest `json:"nfces" binding:"required,min=1,max=50,dive"`
+}
+
+// LoteResponse represents a lote of NFC-e with the aggregate status of its NFC-e
+type LoteResponse struct {
+	ID        string                `json:"id"`
+	Status    LoteStatus            `json:"status"`
+	Total     int                   `json:"total"`
+	Counts    map[RequestStatus]int `json:"counts"` // NFC-e of the lote per status
+	NFces     []NFceResponse        `json:"nfces"`
+	CreatedAt time.Time             `json:"created_at"`
+}
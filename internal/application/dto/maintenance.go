@@ -0,0 +1,26 @@
+package dto
+
+import (
+	"time"
+)
+
+// StartMaintenanceRequest represents the request to start a maintenance window
+type StartMaintenanceRequest struct {
+	Message       string     `json:"message" binding:"required"`
+	ExpectedEndAt *time.Time `json:"expected_end_at,omitempty"`
+}
+
+// MaintenanceWindowResponse represents a maintenance window
+type MaintenanceWindowResponse struct {
+	ID            string     `json:"id"`
+	Message       string     `json:"message"`
+	StartedAt     time.Time  `json:"started_at"`
+	ExpectedEndAt *time.Time `json:"expected_end_at,omitempty"`
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+}
+
+// MaintenanceStatusResponse represents the current maintenance mode
+type MaintenanceStatusResponse struct {
+	Active bool                       `json:"active"`
+	Window *MaintenanceWindowResponse `json:"window,omitempty"`
+}
@@ -2,16 +2,27 @@ package dto
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// MessageSchemaVersion is the version of the queue message contract shared by API and worker.
+// Bump it whenever a message field is removed or changes type, and regenerate the golden messages.
+const MessageSchemaVersion = 1
+
+// ErrBrokerUnavailable is returned by publishers while the message broker is unreachable.
+var ErrBrokerUnavailable = errors.New("message broker unavailable")
+
 // EmitMessage is the payload published to the queue for NFC-e emission.
 // Contains only the request ID for efficiency - worker fetches full data from database.
 type EmitMessage struct {
 	RequestID      string    `json:"request_id"`
+	CompanyID      string    `json:"company_id,omitempty"` // Partitions the worker processing; absent in older messages
 	IdempotencyKey string    `json:"idempotency_key"`
 	RetryCount     int       `json:"retry_count,omitempty"`
+	Resync         bool      `json:"resync,omitempty"` // Set by the admin reprocess: query SEFAZ by the chave before emitting again
 	EnqueuedAt     time.Time `json:"enqueued_at"`
+	SchemaVersion  int       `json:"schema_version"`
 }
 
 // CancelMessage is the payload published to the queue for NFC-e cancellation.
@@ -20,16 +31,69 @@ type CancelMessage struct {
 	IdempotencyKey string    `json:"idempotency_key"`
 	Justificativa  string    `json:"justificativa"`
 	EnqueuedAt     time.Time `json:"enqueued_at"`
+	SchemaVersion  int       `json:"schema_version"`
+}
+
+// ExportMessage is the payload published to the queue for bulk export jobs.
+type ExportMessage struct {
+	JobID         string    `json:"job_id"`
+	EnqueuedAt    time.Time `json:"enqueued_at"`
+	SchemaVersion int       `json:"schema_version"`
+}
+
+// InutilizacaoMessage is the payload published to the queue for number range inutilização.
+type InutilizacaoMessage struct {
+	InutilizacaoID string    `json:"inutilizacao_id"`
+	EnqueuedAt     time.Time `json:"enqueued_at"`
+	SchemaVersion  int       `json:"schema_version"`
+}
+
+// LoteMessage is the payload published to the queue for the emission of a lote of NFC-e.
+// The worker fetches the NFC-e of the lote from database.
+type LoteMessage struct {
+	LoteID        string    `json:"lote_id"`
+	CompanyID     string    `json:"company_id"`
+	EnqueuedAt    time.Time `json:"enqueued_at"`
+	SchemaVersion int       `json:"schema_version"`
+}
+
+// DeadLetter is an emission message moved to the dead-letter queue after exhausting its deliveries.
+type DeadLetter struct {
+	Message        EmitMessage `json:"message"`
+	Reason         string      `json:"reason"`
+	Attempts       int         `json:"attempts"`
+	DeadLetteredAt time.Time   `json:"dead_lettered_at"`
+	Body           string      `json:"body,omitempty"` // Raw body of a message that could not be parsed
 }
 
 // Publisher abstracts the message bus used by the API.
 type Publisher interface {
 	PublishEmit(ctx context.Context, msg EmitMessage) error
+	// PublishEmitAfter publishes the emission message to be delivered after the delay,
+	// scheduled by the broker; the message may arrive early and is then delayed again.
+	PublishEmitAfter(ctx context.Context, msg EmitMessage, delay time.Duration) error
 	PublishCancel(ctx context.Context, msg CancelMessage) error
+	PublishExport(ctx context.Context, msg ExportMessage) error
+	PublishInutilizacao(ctx context.Context, msg InutilizacaoMessage) error
+	PublishLote(ctx context.Context, msg LoteMessage) error
+	// IsHealthy reports whether messages can currently be delivered to the broker.
+	IsHealthy() bool
 }
 
 // Consumer abstracts the worker subscription to the emission queue.
 type Consumer interface {
 	ConsumeEmit(ctx context.Context, handler func(context.Context, EmitMessage) error) error
 	ConsumeCancel(ctx context.Context, handler func(context.Context, CancelMessage) error) error
+	ConsumeExport(ctx context.Context, handler func(context.Context, ExportMessage) error) error
+	ConsumeInutilizacao(ctx context.Context, handler func(context.Context, InutilizacaoMessage) error) error
+	ConsumeLote(ctx context.Context, handler func(context.Context, LoteMessage) error) error
+}
+
+// DeadLetterQueue inspects and requeues the emission messages dead-lettered by the worker.
+type DeadLetterQueue interface {
+	// ListDeadLetters returns up to limit dead letters, oldest first, and how many the queue holds
+	ListDeadLetters(ctx context.Context, limit int) ([]DeadLetter, int, error)
+	// RequeueDeadLetters moves up to limit dead letters back to the emission queue, only the
+	// ones of requestID when set, and returns how many were requeued
+	RequeueDeadLetters(ctx context.Context, requestID string, limit int) (int, error)
 }
@@ -1,7 +1,10 @@
 package dto
 
 import (
+	"io"
 	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
 )
 
 // RequestStatus represents the lifecycle state of an NFC-e request
@@ -10,6 +13,8 @@ type RequestStatus string
 const (
 	// RequestStatusPending is persisted right after intake.
 	RequestStatusPending RequestStatus = "pending"
+	// RequestStatusQueuedDeferred is persisted when the broker is down; the request is published once it recovers.
+	RequestStatusQueuedDeferred RequestStatus = "queued_deferred"
 	// RequestStatusProcessing is set when the worker starts handling the job.
 	RequestStatusProcessing RequestStatus = "processing"
 	// RequestStatusAuthorized means SEFAZ authorized the NFC-e.
@@ -22,12 +27,33 @@ const (
 	RequestStatusRetrying RequestStatus = "retrying"
 	// RequestStatusCanceled is for cancellation events.
 	RequestStatusCanceled RequestStatus = "canceled"
+	// RequestStatusPendingTransmission is set for NFC-e issued in offline contingency (tpEmis 9) until SEFAZ receives them.
+	RequestStatusPendingTransmission RequestStatus = "pending_transmission"
 )
 
+// IsSettled reports whether the outcome of the NFC-e is known: authorized, rejected or canceled
+func (s RequestStatus) IsSettled() bool {
+	return s == RequestStatusAuthorized || s == RequestStatusRejected || s == RequestStatusCanceled
+}
+
+// RequestStatuses lists every request status exposed by the API
+var RequestStatuses = []RequestStatus{
+	RequestStatusPending,
+	RequestStatusQueuedDeferred,
+	RequestStatusProcessing,
+	RequestStatusAuthorized,
+	RequestStatusRejected,
+	RequestStatusContingency,
+	RequestStatusRetrying,
+	RequestStatusCanceled,
+	RequestStatusPendingTransmission,
+}
+
 // EmitOptions controls sync/async behavior and contingency flags.
 type EmitOptions struct {
-	Contingencia bool `json:"contingencia"`
-	Sync         bool `json:"sync"`
+	Contingencia  bool `json:"contingencia"`
+	Sync          bool `json:"sync"`
+	DanfeDuasVias bool `json:"danfe_duas_vias"`
 }
 
 // Certificate holds the encrypted PFX and its password.
@@ -38,7 +64,7 @@ type Certificate struct {
 
 // Emitente aggregates issuer data required to build the XML and QR.
 type Emitente struct {
-	CNPJ     string `json:"cnpj"`
+	CNPJ     string `json:"cnpj" binding:"omitempty,cnpj"`
 	IE       string `json:"ie,omitempty"`
 	Regime   string `json:"regime"`
 	CSCID    string `json:"csc_id"`
@@ -47,46 +73,184 @@ type Emitente struct {
 
 // Item is a minimal representation of a product line.
 type Item struct {
-	Descricao  string  `json:"descricao"`
-	NCM        string  `json:"ncm"`
-	CFOP       string  `json:"cfop"`
-	GTIN       string  `json:"gtin,omitempty"`
-	Valor      float64 `json:"valor"`
-	Quantidade float64 `json:"quantidade"`
-	Unidade    string  `json:"unidade"`
+	Descricao  string         `json:"descricao"`
+	NCM        string         `json:"ncm" binding:"required,ncm"`
+	CEST       string         `json:"cest,omitempty"`                          // Required for goods under substituição tributária
+	CFOP       string         `json:"cfop,omitempty" binding:"omitempty,cfop"` // The company default for the destination UF when empty
+	GTIN       string         `json:"gtin,omitempty"`
+	Valor      money.Amount   `json:"valor"`
+	Quantidade money.Quantity `json:"quantidade"`
+	Unidade    string         `json:"unidade"`
+	Desconto   money.Amount   `json:"desconto,omitempty"` // vDesc - discount given on the item
+	Outros     money.Amount   `json:"outros,omitempty"`   // vOutro - other ancillary charges of the item
+	IPI        *ItemIPI       `json:"ipi,omitempty"`      // NF-e only
+	II         *ItemII        `json:"ii,omitempty"`       // NF-e only, imports (CFOP 3xxx)
+}
+
+// ItemIPI is the IPI of an NF-e item
+type ItemIPI struct {
+	CST           string  `json:"cst" binding:"required,len=2"`
+	Enquadramento string  `json:"enquadramento,omitempty" binding:"omitempty,max=3"` // cEnq, 999 when omitted
+	Aliquota      float64 `json:"aliquota,omitempty" binding:"min=0,max=100"`
+}
+
+// ItemII is the import tax of an NF-e item
+type ItemII struct {
+	BaseCalculo        money.Amount `json:"base_calculo"`
+	DespesasAduaneiras money.Amount `json:"despesas_aduaneiras,omitempty"`
+	Valor              money.Amount `json:"valor"`
+	IOF                money.Amount `json:"iof,omitempty"`
 }
 
 // Payment captures the payment mix used in the sale.
 type Payment struct {
-	Forma string  `json:"forma"`
-	Valor float64 `json:"valor"`
-	Troco float64 `json:"troco,omitempty"`
+	Forma string       `json:"forma"`
+	Valor money.Amount `json:"valor"`
+	Troco money.Amount `json:"troco,omitempty"`
+	// Cartao details credit and debit card payments (card)
+	Cartao *CardPayment `json:"cartao,omitempty"`
+}
+
+// CardPayment carries the acquirer data of a card payment
+type CardPayment struct {
+	TpIntegra   string `json:"tp_integra"` // 1 integrated with the PDV (TEF), 2 standalone POS
+	CNPJ        string `json:"cnpj,omitempty" binding:"omitempty,cnpj"`
+	Bandeira    string `json:"bandeira,omitempty"`
+	Autorizacao string `json:"autorizacao,omitempty"`
 }
 
 // EmitNFceRequest represents the request to emit a NFC-e
 type EmitNFceRequest struct {
-	UF         string      `json:"uf" binding:"required"`
-	Ambiente   string      `json:"ambiente" binding:"required,oneof=producao homologacao"`
+	UF         string      `json:"uf" binding:"required,uf"`
+	Ambiente   string      `json:"ambiente" binding:"required,oneof=producao homologacao simulado"`
+	Serie      *int        `json:"serie,omitempty" binding:"omitempty,min=0,max=999"` // Company default series when omitted
 	Emitente   Emitente    `json:"emitente" binding:"required"`
-	Itens      []Item      `json:"itens" binding:"required,min=1"`
-	Pagamentos []Payment   `json:"pagamentos" binding:"required,min=1"`
+	Itens      []Item      `json:"itens" binding:"required,min=1,dive"`
+	Pagamentos []Payment   `json:"pagamentos" binding:"required,min=1,dive"`
 	Options    EmitOptions `json:"options"`
+	// Metadata is echoed back in responses and webhooks for integrator correlation
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Destinatario identifies the consumer by CPF or CNPJ (dest)
+	Destinatario *DestinatarioRequest `json:"destinatario,omitempty"`
+	// Intermediador selects a company registered intermediary by alias or gives its data (infIntermed)
+	Intermediador *IntermediadorRequest `json:"intermediador,omitempty"`
+	// Terminal identifies the point of sale (PDV), used by the anti-fraud velocity check
+	Terminal string `json:"terminal,omitempty"`
+	// Frete is the freight of the sale (vFrete), apportioned among the items by value
+	Frete money.Amount `json:"frete,omitempty"`
+	// Modelo selects the document: 65 (NFC-e, the default) or 55 (NF-e)
+	Modelo string `json:"modelo,omitempty" binding:"omitempty,oneof=55 65"`
+	// NaturezaOperacao is the natOp of the NF-e, VENDA when omitted
+	NaturezaOperacao string `json:"natureza_operacao,omitempty" binding:"omitempty,max=60"`
+	// Transporte and Cobranca are NF-e only
+	Transporte *TransporteRequest `json:"transporte,omitempty"`
+	Cobranca   *CobrancaRequest   `json:"cobranca,omitempty"`
+}
+
+// DestinatarioRequest identifies the consumer of the sale; the NF-e also requires the
+// address and, for taxpayers, the IE
+type DestinatarioRequest struct {
+	CPF      string      `json:"cpf,omitempty" binding:"omitempty,cpf"`
+	CNPJ     string      `json:"cnpj,omitempty" binding:"omitempty,cnpj"`
+	Nome     string      `json:"nome,omitempty"`
+	Email    string      `json:"email,omitempty"`
+	IE       string      `json:"ie,omitempty"`
+	Endereco *AddressDTO `json:"endereco,omitempty"`
+}
+
+// TransporteRequest describes the transport of the goods of an NF-e
+type TransporteRequest struct {
+	// Modalidade is the modFrete: 0 CIF, 1 FOB, 2 third parties, 3 and 4 own, 9 no transport
+	Modalidade     string                 `json:"modalidade" binding:"required,oneof=0 1 2 3 4 9"`
+	Transportadora *TransportadoraRequest `json:"transportadora,omitempty"`
+	Volumes        []VolumeRequest        `json:"volumes,omitempty" binding:"max=5000"`
+}
+
+// TransportadoraRequest identifies the carrier of an NF-e
+type TransportadoraRequest struct {
+	CNPJ      string `json:"cnpj,omitempty" binding:"omitempty,cnpj"`
+	CPF       string `json:"cpf,omitempty" binding:"omitempty,cpf"`
+	Nome      string `json:"nome,omitempty" binding:"max=60"`
+	IE        string `json:"ie,omitempty"`
+	Endereco  string `json:"endereco,omitempty" binding:"max=60"`
+	Municipio string `json:"municipio,omitempty" binding:"max=60"`
+	UF        string `json:"uf,omitempty" binding:"omitempty,uf"`
+}
+
+// VolumeRequest describes the packages carried; weights in kg
+type VolumeRequest struct {
+	Quantidade  int64          `json:"quantidade,omitempty"`
+	Especie     string         `json:"especie,omitempty" binding:"max=60"`
+	Marca       string         `json:"marca,omitempty" binding:"max=60"`
+	Numeracao   string         `json:"numeracao,omitempty" binding:"max=60"`
+	PesoLiquido money.Quantity `json:"peso_liquido,omitempty"`
+	PesoBruto   money.Quantity `json:"peso_bruto,omitempty"`
+}
+
+// CobrancaRequest is the billing of an NF-e sold on credit
+type CobrancaRequest struct {
+	Fatura     *FaturaRequest     `json:"fatura,omitempty"`
+	Duplicatas []DuplicataRequest `json:"duplicatas,omitempty" binding:"max=120"`
+}
+
+// FaturaRequest is the invoice of the billing
+type FaturaRequest struct {
+	Numero        string       `json:"numero" binding:"max=60"`
+	ValorOriginal money.Amount `json:"valor_original"`
+	Desconto      money.Amount `json:"desconto,omitempty"`
+	ValorLiquido  money.Amount `json:"valor_liquido"`
+}
+
+// DuplicataRequest is an installment of the billing; the number defaults to its position
+type DuplicataRequest struct {
+	Numero     string       `json:"numero,omitempty" binding:"max=60"`
+	Vencimento string       `json:"vencimento" binding:"required"` // YYYY-MM-DD
+	Valor      money.Amount `json:"valor"`
+}
+
+// IntermediadorRequest identifies the marketplace or delivery app of the sale
+type IntermediadorRequest struct {
+	Alias        string `json:"alias,omitempty"`
+	CNPJ         string `json:"cnpj,omitempty" binding:"omitempty,cnpj"`
+	Nome         string `json:"nome,omitempty"`
+	IdCadIntTran string `json:"id_cad_int_tran,omitempty"`
 }
 
 // NFceResponse represents the response containing NFC-e data
 type NFceResponse struct {
 	ID             string        `json:"id"`
+	CompanyID      string        `json:"company_id,omitempty"` // Set by the admin listing only
 	IdempotencyKey string        `json:"idempotency_key"`
 	Status         RequestStatus `json:"status"`
+	Modelo         string        `json:"modelo,omitempty"` // 65 (NFC-e) or 55 (NF-e)
 	ChaveAcesso    string        `json:"chave_acesso,omitempty"`
 	Protocolo      string        `json:"protocolo,omitempty"`
+	Numero         string        `json:"numero,omitempty"`
+	Serie          string        `json:"serie,omitempty"`
+	Valor          money.Amount  `json:"valor"` // vNF
 	RejectionCode  string        `json:"rejection_code,omitempty"`
 	RejectionMsg   string        `json:"rejection_msg,omitempty"`
+	SchemaErrors   []SchemaError `json:"schema_errors,omitempty"` // XSD errors of a local rejection
 	RetryCount     int           `json:"retry_count,omitempty"`
 	NextRetryAt    *time.Time    `json:"next_retry_at,omitempty"`
 	CreatedAt      time.Time     `json:"created_at"`
 	UpdatedAt      time.Time     `json:"updated_at"`
 	Links          NFceLinks     `json:"links,omitempty"`
+	// DocumentsPurgedAt is when the XML, DANFE and QR Code were deleted by the plan retention
+	DocumentsPurgedAt *time.Time        `json:"documents_purged_at,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	// DelayedProcessing flags requests accepted in degraded intake mode (broker unavailable)
+	DelayedProcessing bool   `json:"delayed_processing,omitempty"`
+	Notice            string `json:"notice,omitempty"`
+}
+
+// SchemaError locates an XSD error of the generated XML and describes it in Portuguese
+type SchemaError struct {
+	Path    string `json:"path,omitempty"` // e.g. /NFe/infNFe/det[2]/prod/xProd
+	Field   string `json:"field,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"` // Raw validator message
 }
 
 // NFceLinks contains URLs to NFC-e resources
@@ -96,10 +260,34 @@ type NFceLinks struct {
 	QrCode string `json:"qr_code,omitempty"`
 }
 
-// NFceListResponse represents a list of NFC-e requests
+// NFceFile is a stored NFC-e document streamed to the client; Content must be closed
+type NFceFile struct {
+	Content     io.ReadCloser
+	Size        int64 // -1 when unknown
+	ContentType string
+	Filename    string
+}
+
+// NFceListResponse represents a page of NFC-e requests; NextCursor fetches the next page and
+// is empty on the last one
 type NFceListResponse struct {
-	NFces []NFceResponse `json:"nfces"`
-	Total int            `json:"total"`
+	NFces      []NFceResponse `json:"nfces"`
+	Total      int            `json:"total"` // NFC-e matching the filters, across all pages
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// NFceSearchResult is an NFC-e found by the full-text search; Highlight shows the
+// matching item descriptions and consumer name with the terms wrapped in <mark>
+type NFceSearchResult struct {
+	NFceResponse
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+// NFceSearchResponse represents the ranked results of a full-text search
+type NFceSearchResponse struct {
+	Results []NFceSearchResult `json:"results"`
+	Total   int                `json:"total"`
 }
 
 // CancelNFceRequest represents the request to cancel a NFC-e
@@ -115,7 +303,9 @@ type NFceEventResponse struct {
 	StatusTo   RequestStatus `json:"status_to"`
 	CStat      string        `json:"cstat,omitempty"`
 	Message    string        `json:"message,omitempty"`
-	CreatedAt  time.Time     `json:"created_at"`
+	// Metadata carries event details such as the anti-fraud decisions
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
 }
 
 // NFceEventListResponse represents a list of NFC-e events
@@ -123,3 +313,21 @@ type NFceEventListResponse struct {
 	Events []NFceEventResponse `json:"events"`
 	Total  int                 `json:"total"`
 }
+
+// ContentTypeESCPOS is the media type of the DANFE printed directly by ESC/POS thermal printers
+const ContentTypeESCPOS = "application/vnd.escpos"
+
+// DANFEOptions controls on-demand DANFE rendering
+type DANFEOptions struct {
+	Vias     int    // 1 (consumidor) or 2 (consumidor + estabelecimento)
+	Compacto bool   // Suppress item details on the establishment via
+	Papel    string // 80mm or 58mm; the company preference when empty
+}
+
+// NFceEventStream delivers real-time status change events for a company
+type NFceEventStream struct {
+	Replay []NFceEventResponse // Events missed since Last-Event-ID
+	Reset  bool                // Last-Event-ID could not be resumed, client must refetch state
+	Events <-chan NFceEventResponse
+	Close  func()
+}
@@ -49,7 +49,9 @@ type PlanFeatures struct {
 	AllowInutilization bool `json:"allow_inutilization"`
 	WebhookSupport     bool `json:"webhook_support"`
 	PrioritySupport    bool `json:"priority_support"`
-	StorageDays        int  `json:"storage_days"` // Days to keep XML/PDF
+	StorageDays        int  `json:"storage_days"`                   // Days to keep XML/PDF
+	RequestsPerMinute  int  `json:"requests_per_minute,omitempty"`  // API requests, 0 = default of the deployment
+	EmissionsPerMinute int  `json:"emissions_per_minute,omitempty"` // NFC-e emission requests, 0 = default of the deployment
 }
 
 // PlanDTO represents a subscription plan
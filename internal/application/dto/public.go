@@ -0,0 +1,67 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// Situations of an NFC-e in the public consulta
+const (
+	PublicSituacaoAutorizada    = "autorizada"
+	PublicSituacaoCancelada     = "cancelada"
+	PublicSituacaoNaoEncontrada = "nao_encontrada" // Unknown to SEFAZ (cStat 217)
+	PublicSituacaoDesconhecida  = "desconhecida"   // Another answer of SEFAZ
+)
+
+// PublicNFCeResponse is the consumer-facing summary of an NFC-e returned by the public consulta
+type PublicNFCeResponse struct {
+	ChaveAcesso string `json:"chave_acesso"`
+	Numero      string `json:"numero"`
+	Serie       string `json:"serie"`
+	Ambiente    string `json:"ambiente"`
+	// Situacao is the situation answered by SEFAZ, or the one recorded here when SEFAZ did not answer
+	Situacao  string                `json:"situacao"`
+	SEFAZ     *PublicSEFAZSituation `json:"sefaz,omitempty"`
+	EmitidaEm time.Time             `json:"emitida_em"`
+	Emitente  PublicEmitente        `json:"emitente"`
+	Itens     []PublicItem          `json:"itens"`
+	// Pagamentos are the payment forms of the sale, named as on the DANFE
+	Pagamentos          []PublicPayment `json:"pagamentos"`
+	ValorTotal          money.Amount    `json:"valor_total"`
+	Desconto            money.Amount    `json:"desconto,omitempty"`
+	Troco               money.Amount    `json:"troco,omitempty"`
+	TributosAproximados money.Amount    `json:"tributos_aproximados,omitempty"` // Lei 12.741/2012
+	// ConsultaURL is the portal of the UF where the consumer can also look the note up
+	ConsultaURL string `json:"consulta_url,omitempty"`
+}
+
+// PublicSEFAZSituation is the answer of NfeConsultaProtocolo4 for the NFC-e
+type PublicSEFAZSituation struct {
+	CStat        string    `json:"cstat"`
+	XMotivo      string    `json:"xmotivo"`
+	Protocolo    string    `json:"protocolo,omitempty"`
+	ConsultadaEm time.Time `json:"consultada_em"`
+}
+
+// PublicEmitente identifies the issuer of the NFC-e
+type PublicEmitente struct {
+	CNPJ         string `json:"cnpj"`
+	RazaoSocial  string `json:"razao_social"`
+	NomeFantasia string `json:"nome_fantasia,omitempty"`
+}
+
+// PublicItem is a product line of the NFC-e
+type PublicItem struct {
+	Descricao     string         `json:"descricao"`
+	Quantidade    money.Quantity `json:"quantidade"`
+	Unidade       string         `json:"unidade"`
+	ValorUnitario money.Amount   `json:"valor_unitario"`
+	ValorTotal    money.Amount   `json:"valor_total"`
+}
+
+// PublicPayment is a payment form of the sale
+type PublicPayment struct {
+	Forma string       `json:"forma"`
+	Valor money.Amount `json:"valor"`
+}
@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"time"
+)
+
+// CreateNumberingSeriesRequest represents the request to register an NFC-e numbering series
+type CreateNumberingSeriesRequest struct {
+	Serie      *int   `json:"serie" binding:"required,min=0,max=999"`
+	Ambiente   string `json:"ambiente" binding:"required,oneof=producao homologacao simulado"`
+	Modelo     string `json:"modelo,omitempty" binding:"omitempty,oneof=55 65"`              // Defaults to 65 (NFC-e)
+	NextNumber int64  `json:"next_number,omitempty" binding:"omitempty,min=1,max=999999999"` // Defaults to 1
+	Default    bool   `json:"default,omitempty"`                                             // Make it the default of the model in the environment
+}
+
+// UpdateNumberingSeriesRequest represents the request to update a numbering series
+type UpdateNumberingSeriesRequest struct {
+	NextNumber *int64 `json:"next_number,omitempty" binding:"omitempty,min=1,max=999999999"` // Only moves forward
+	Active     *bool  `json:"active,omitempty"`
+}
+
+// NumberingSeriesResponse represents an NFC-e numbering series
+type NumberingSeriesResponse struct {
+	ID         string    `json:"id"`
+	Modelo     string    `json:"modelo"`
+	Serie      int       `json:"serie"`
+	Ambiente   string    `json:"ambiente"`
+	NextNumber int64     `json:"next_number"`
+	Default    bool      `json:"default"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NumberingSeriesListResponse represents the numbering series of a company
+type NumberingSeriesListResponse struct {
+	Series []NumberingSeriesResponse `json:"series"`
+}
+
+// NumberingGapResponse represents a range of skipped numbers that needs inutilização
+type NumberingGapResponse struct {
+	NumeroInicial int64 `json:"numero_inicial"`
+	NumeroFinal   int64 `json:"numero_final"`
+	Quantidade    int64 `json:"quantidade"`
+}
+
+// NumberingGapsResponse represents the skipped numbers of a series
+type NumberingGapsResponse struct {
+	SeriesID   string                 `json:"series_id"`
+	Modelo     string                 `json:"modelo"`
+	Serie      int                    `json:"serie"`
+	Ambiente   string                 `json:"ambiente"`
+	NextNumber int64                  `json:"next_number"`
+	Gaps       []NumberingGapResponse `json:"gaps"`
+}
@@ -31,6 +31,7 @@ type BillingInfo struct {
 	Amount        float64    `json:"amount"`
 	Currency      string     `json:"currency"`
 	PaymentMethod string     `json:"payment_method,omitempty"`
+	CustomerID    string     `json:"customer_id,omitempty"`
 }
 
 // SubscriptionDTO represents a company's subscription to a plan
@@ -76,6 +77,34 @@ type UpdateSubscriptionRequest struct {
 	Status       *SubscriptionStatus `json:"status,omitempty"`
 	AutoRenew    *bool               `json:"auto_renew,omitempty"`
 	CancelReason *string             `json:"cancel_reason,omitempty"`
+	// Payment method and customer charged on the gateway; setting them retries a failed invoice
+	PaymentMethod *string `json:"payment_method,omitempty" binding:"omitempty,max=50"`
+	CustomerID    *string `json:"customer_id,omitempty" binding:"omitempty,max=255"`
+}
+
+// ChangePlanRequest represents the request to switch a subscription to another plan
+type ChangePlanRequest struct {
+	PlanID string `json:"plan_id" binding:"required"`
+}
+
+// SubscriptionEventDTO represents a plan change of a subscription and its proration
+type SubscriptionEventDTO struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	FromPlanID  string    `json:"from_plan_id,omitempty"`
+	ToPlanID    string    `json:"to_plan_id,omitempty"`
+	QuotaBefore int       `json:"quota_before"` // -1 = unlimited
+	QuotaAfter  int       `json:"quota_after"`  // -1 = unlimited
+	Amount      float64   `json:"amount"`       // Charged (positive) or credited (negative) on the next invoice
+	Currency    string    `json:"currency"`
+	InvoiceID   *string   `json:"invoice_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PlanChangeResponse represents the subscription after a plan change and the change recorded
+type PlanChangeResponse struct {
+	Subscription *SubscriptionDTO     `json:"subscription"`
+	Change       SubscriptionEventDTO `json:"change"`
 }
 
 // CancelSubscriptionRequest represents the request to cancel a subscription
@@ -88,3 +117,88 @@ type SubscriptionListResponse struct {
 	Subscriptions []SubscriptionDTO `json:"subscriptions"`
 	Total         int               `json:"total"`
 }
+
+// StatementNFCeDTO summarizes the NFC-e of a usage statement period
+type StatementNFCeDTO struct {
+	Total      int            `json:"total"`
+	ByStatus   map[string]int `json:"by_status"`
+	ByAmbiente map[string]int `json:"by_ambiente"`
+	Emitted    int            `json:"emitted"`
+	Quota      int            `json:"quota"` // -1 = unlimited
+	Overage    int            `json:"overage"`
+}
+
+// StatementWebhooksDTO counts the webhook deliveries of a usage statement period
+type StatementWebhooksDTO struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// StatementStorageDTO is the storage consumed when the usage statement was generated
+type StatementStorageDTO struct {
+	Objects int   `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// StatementPlanChangeDTO is a plan change within a usage statement period
+type StatementPlanChangeDTO struct {
+	ChangedAt    time.Time `json:"changed_at"`
+	FromPlanID   string    `json:"from_plan_id"`
+	FromPlanName string    `json:"from_plan_name,omitempty"`
+	ToPlanID     string    `json:"to_plan_id"`
+	ToPlanName   string    `json:"to_plan_name,omitempty"`
+	Proration    float64   `json:"proration"`
+}
+
+// StatementSummaryDTO is the content of a usage statement
+type StatementSummaryDTO struct {
+	PlanID      string                   `json:"plan_id,omitempty"`
+	PlanName    string                   `json:"plan_name,omitempty"`
+	PlanChanges []StatementPlanChangeDTO `json:"plan_changes,omitempty"`
+	NFCe        StatementNFCeDTO         `json:"nfce"`
+	Webhooks    StatementWebhooksDTO     `json:"webhooks"`
+	Storage     StatementStorageDTO      `json:"storage"`
+}
+
+// UsageStatementDTO represents the end-of-month usage statement of a company
+type UsageStatementDTO struct {
+	ID          string              `json:"id"`
+	Period      string              `json:"period"`
+	PeriodStart time.Time           `json:"period_start"`
+	PeriodEnd   time.Time           `json:"period_end"`
+	Summary     StatementSummaryDTO `json:"summary"`
+	JSONURL     string              `json:"json_url,omitempty"`
+	PDFURL      string              `json:"pdf_url,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// UsageStatementListResponse represents a paginated list of usage statements
+type UsageStatementListResponse struct {
+	Statements []UsageStatementDTO `json:"statements"`
+	Total      int                 `json:"total"`
+}
+
+// InvoiceDTO represents the charge of one billing period of a subscription
+type InvoiceDTO struct {
+	ID             string     `json:"id"`
+	SubscriptionID string     `json:"subscription_id"`
+	Amount         float64    `json:"amount"`
+	Adjustment     float64    `json:"adjustment,omitempty"` // Prorations of plan changes included in Amount
+	Currency       string     `json:"currency"`
+	PeriodStart    time.Time  `json:"period_start"`
+	PeriodEnd      time.Time  `json:"period_end"`
+	Status         string     `json:"status"`
+	Gateway        string     `json:"gateway"`
+	Attempts       int        `json:"attempts"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// InvoiceListResponse represents a paginated list of invoices
+type InvoiceListResponse struct {
+	Invoices []InvoiceDTO `json:"invoices"`
+	Total    int          `json:"total"`
+}
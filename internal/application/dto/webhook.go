@@ -8,12 +8,19 @@ import (
 type WebhookEvent string
 
 const (
-	WebhookEventNFCEAuthorized      WebhookEvent = "nfce.authorized"
-	WebhookEventNFCERejected        WebhookEvent = "nfce.rejected"
-	WebhookEventNFCECanceled        WebhookEvent = "nfce.canceled"
-	WebhookEventNFCEContingency     WebhookEvent = "nfce.contingency"
-	WebhookEventSubscriptionExpired WebhookEvent = "subscription.expired"
-	WebhookEventQuotaExceeded       WebhookEvent = "quota.exceeded"
+	WebhookEventNFCEAuthorized          WebhookEvent = "nfce.authorized"
+	WebhookEventNFCERejected            WebhookEvent = "nfce.rejected"
+	WebhookEventNFCECanceled            WebhookEvent = "nfce.canceled"
+	WebhookEventNFCEContingency         WebhookEvent = "nfce.contingency"
+	WebhookEventSubscriptionExpired     WebhookEvent = "subscription.expired"
+	WebhookEventQuotaExceeded           WebhookEvent = "quota.exceeded"
+	WebhookEventPlanFeatureBlocked      WebhookEvent = "plan.feature_blocked"
+	WebhookEventMaintenanceStarted      WebhookEvent = "maintenance.started"
+	WebhookEventMaintenanceEnded        WebhookEvent = "maintenance.ended"
+	WebhookEventStatementAvailable      WebhookEvent = "statement.available"
+	WebhookEventExportCompleted         WebhookEvent = "export.completed"
+	WebhookEventSubscriptionSuspended   WebhookEvent = "subscription.suspended"
+	WebhookEventSubscriptionReactivated WebhookEvent = "subscription.reactivated"
 )
 
 // WebhookStatus represents the status of a webhook configuration
@@ -44,6 +51,14 @@ type WebhookRetryConfig struct {
 	MaxInterval   time.Duration `json:"max_interval"`   // Maximum interval
 }
 
+// WebhookFilter compares a field of the NFC-e event payload (ambiente, modelo, serie, uf,
+// valor_total or metadata.<key>) with a value using eq, ne, gt, gte, lt, lte or in
+type WebhookFilter struct {
+	Field string `json:"field" binding:"required"`
+	Op    string `json:"op" binding:"required"`
+	Value string `json:"value"` // Comma-separated values for the in operator
+}
+
 // WebhookDelivery represents a webhook delivery attempt
 type WebhookDelivery struct {
 	ID           string                 `json:"id"`
@@ -72,6 +87,9 @@ type WebhookDTO struct {
 	// Events to listen for
 	Events []WebhookEvent `json:"events"`
 
+	// Filters on the NFC-e events, all of which must match for the delivery
+	Filters []WebhookFilter `json:"filters,omitempty"`
+
 	// Authentication and headers
 	Headers WebhookHeaders `json:"headers,omitempty"`
 	Secret  string         `json:"secret,omitempty"` // For HMAC validation
@@ -98,6 +116,7 @@ type CreateWebhookRequest struct {
 	URL         string              `json:"url" validate:"required,url"`
 	Method      HTTPMethod          `json:"method,omitempty"`
 	Events      []WebhookEvent      `json:"events" validate:"required,min=1"`
+	Filters     []WebhookFilter     `json:"filters,omitempty" binding:"omitempty,dive"`
 	Headers     WebhookHeaders      `json:"headers,omitempty"`
 	Secret      string              `json:"secret,omitempty"`
 	RetryConfig *WebhookRetryConfig `json:"retry_config,omitempty"`
@@ -111,6 +130,7 @@ type UpdateWebhookRequest struct {
 	Method      *HTTPMethod         `json:"method,omitempty"`
 	Status      *WebhookStatus      `json:"status,omitempty"`
 	Events      []WebhookEvent      `json:"events,omitempty"`
+	Filters     []WebhookFilter     `json:"filters,omitempty" binding:"omitempty,dive"` // An empty list removes the filters
 	Headers     WebhookHeaders      `json:"headers,omitempty"`
 	Secret      *string             `json:"secret,omitempty"`
 	RetryConfig *WebhookRetryConfig `json:"retry_config,omitempty"`
@@ -0,0 +1,40 @@
+package mapper
+
+import (
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// AuthMapper handles mapping between API key entities and DTOs
+type AuthMapper struct{}
+
+// NewAuthMapper creates a new AuthMapper
+func NewAuthMapper() *AuthMapper {
+	return &AuthMapper{}
+}
+
+// ToAPIKeyResponse converts an APIKey entity to an APIKeyResponse
+func (m *AuthMapper) ToAPIKeyResponse(key *entity.APIKey) *dto.APIKeyResponse {
+	return &dto.APIKeyResponse{
+		ID:         key.ID,
+		CompanyID:  key.CompanyID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Active:     key.IsActive(time.Now()),
+		LastUsedAt: key.LastUsedAt,
+		ExpiresAt:  key.ExpiresAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+// ToAPIKeyListResponse converts API key entities to an APIKeyListResponse
+func (m *AuthMapper) ToAPIKeyListResponse(keys []*entity.APIKey) *dto.APIKeyListResponse {
+	response := &dto.APIKeyListResponse{Keys: make([]dto.APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		response.Keys = append(response.Keys, *m.ToAPIKeyResponse(key))
+	}
+	return response
+}
@@ -1,6 +1,8 @@
 package mapper
 
 import (
+	"time"
+
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 )
@@ -25,10 +27,98 @@ func (m *CompanyMapper) ToCompanyDTO(company *entity.Company) *dto.CompanyDTO {
 		Endereco:          *m.ToAddressDTO(&company.Endereco),
 		Certificado:       *m.ToCertificateDTO(&company.Certificado),
 		CSC:               *m.ToCSCConfigDTO(&company.CSC),
+		CSCs:              m.ToCSCEntryDTOs(company.CSCs),
+		DANFE:             dto.DANFEConfigDTO(company.DANFE),
+		Intermediadores:   m.ToIntermediadorDTOs(company.Intermediadores),
+		FraudRules:        m.ToFraudRuleDTOs(company.FraudRules.Effective()),
+		TaxRules:          m.ToTaxRuleDTOs(company.TaxRules),
+		RespTec:           m.ToRespTecDTO(company.RespTec),
+		EmailTemplate:     (*dto.EmailTemplateDTO)(company.EmailTemplate),
+		EmissionSettings:  (*dto.EmissionSettingsDTO)(company.EmissionSettings),
 		RegimeTributario:  dto.TaxRegime(company.RegimeTributario),
 		Status:            dto.CompanyStatus(company.Status),
 		CreatedAt:         company.CreatedAt,
 		UpdatedAt:         company.UpdatedAt,
+		DeletedAt:         company.DeletedAt,
+		StorageCleanupAt:  company.StorageCleanupAt,
+		StorageCleanedAt:  company.StorageCleanedAt,
+	}
+}
+
+// ToIntermediadorDTOs converts the intermediary registry to IntermediadorDTOs
+func (m *CompanyMapper) ToIntermediadorDTOs(intermediadores entity.Intermediadores) []dto.IntermediadorDTO {
+	dtos := make([]dto.IntermediadorDTO, len(intermediadores))
+	for i, intermediador := range intermediadores {
+		dtos[i] = dto.IntermediadorDTO(intermediador)
+	}
+	return dtos
+}
+
+// ToIntermediadoresEntity converts IntermediadorDTOs to the intermediary registry
+func (m *CompanyMapper) ToIntermediadoresEntity(dtos []dto.IntermediadorDTO) entity.Intermediadores {
+	intermediadores := make(entity.Intermediadores, len(dtos))
+	for i, intermediador := range dtos {
+		intermediadores[i] = entity.Intermediador(intermediador)
+	}
+	return intermediadores
+}
+
+// ToFraudRuleDTOs converts the anti-fraud rules to FraudRuleDTOs
+func (m *CompanyMapper) ToFraudRuleDTOs(rules entity.FraudRules) map[string]dto.FraudRuleDTO {
+	dtos := make(map[string]dto.FraudRuleDTO, len(rules))
+	for name, rule := range rules {
+		dtos[name] = dto.FraudRuleDTO{
+			Action:        string(rule.Action),
+			Limit:         rule.Limit,
+			WindowSeconds: rule.WindowSeconds,
+		}
+	}
+	return dtos
+}
+
+// ToFraudRulesEntity converts FraudRuleDTOs to the anti-fraud rules
+func (m *CompanyMapper) ToFraudRulesEntity(dtos map[string]dto.FraudRuleDTO) entity.FraudRules {
+	rules := make(entity.FraudRules, len(dtos))
+	for name, rule := range dtos {
+		rules[name] = entity.FraudRule{
+			Action:        entity.FraudAction(rule.Action),
+			Limit:         rule.Limit,
+			WindowSeconds: rule.WindowSeconds,
+		}
+	}
+	return rules
+}
+
+// ToTaxRuleDTOs converts the company tax rules to TaxRuleDTOs
+func (m *CompanyMapper) ToTaxRuleDTOs(rules entity.TaxRules) []dto.TaxRuleDTO {
+	dtos := make([]dto.TaxRuleDTO, len(rules))
+	for i, rule := range rules {
+		dtos[i] = dto.TaxRuleDTO(rule)
+	}
+	return dtos
+}
+
+// ToTaxRulesEntity converts TaxRuleDTOs to the company tax rules
+func (m *CompanyMapper) ToTaxRulesEntity(dtos []dto.TaxRuleDTO) entity.TaxRules {
+	rules := make(entity.TaxRules, len(dtos))
+	for i, rule := range dtos {
+		rules[i] = entity.TaxRule(rule)
+	}
+	return rules
+}
+
+// ToRespTecDTO converts the company technical responsible to a RespTecDTO without the CSRT
+func (m *CompanyMapper) ToRespTecDTO(respTec *entity.RespTec) *dto.RespTecDTO {
+	if respTec == nil {
+		return nil
+	}
+	return &dto.RespTecDTO{
+		CNPJ:            respTec.CNPJ,
+		Contato:         respTec.Contato,
+		Email:           respTec.Email,
+		Fone:            respTec.Fone,
+		IdCSRT:          respTec.IdCSRT,
+		CSRTConfigurado: respTec.CSRT != "",
 	}
 }
 
@@ -49,9 +139,11 @@ func (m *CompanyMapper) ToAddressDTO(address *entity.Address) *dto.AddressDTO {
 // ToCertificateDTO converts a DigitalCertificate entity to a CertificateDTO
 func (m *CompanyMapper) ToCertificateDTO(certificate *entity.DigitalCertificate) *dto.CertificateDTO {
 	return &dto.CertificateDTO{
-		Type:      dto.CertificateType(certificate.Type),
-		ExpiresAt: certificate.ExpiresAt,
-		Subject:   certificate.Subject,
+		Type:        dto.CertificateType(certificate.Type),
+		ExpiresAt:   certificate.ExpiresAt,
+		Subject:     certificate.Subject,
+		KMSEndpoint: certificate.KMSEndpoint,
+		KMSKeyID:    certificate.KMSKeyID,
 	}
 }
 
@@ -64,6 +156,25 @@ func (m *CompanyMapper) ToCSCConfigDTO(csc *entity.CSCConfig) *dto.CSCDTO {
 	}
 }
 
+// ToCSCEntryDTOs converts the CSC registry to CSCEntryDTOs, flagging the entries in force
+func (m *CompanyMapper) ToCSCEntryDTOs(cscs entity.CSCEntries) []dto.CSCEntryDTO {
+	now := time.Now()
+	dtos := make([]dto.CSCEntryDTO, len(cscs))
+	for i, csc := range cscs {
+		active, ok := cscs.Active(csc.Ambiente, now)
+		dtos[i] = dto.CSCEntryDTO{
+			ID:         csc.ID,
+			Ambiente:   csc.Ambiente,
+			CSCID:      csc.CSCID,
+			ValidFrom:  csc.ValidFrom,
+			ValidUntil: csc.ValidUntil,
+			Active:     ok && active.ID == csc.ID,
+			CreatedAt:  csc.CreatedAt,
+		}
+	}
+	return dtos
+}
+
 // ToCompanyEntity converts a CompanyDTO to a Company entity
 func (m *CompanyMapper) ToCompanyEntity(company *dto.CompanyDTO) *entity.Company {
 	return &entity.Company{
@@ -76,10 +187,17 @@ func (m *CompanyMapper) ToCompanyEntity(company *dto.CompanyDTO) *entity.Company
 		Endereco:          *m.ToAddressEntity(&company.Endereco),
 		Certificado:       *m.ToCertificateEntity(&company.Certificado),
 		CSC:               *m.ToCSCConfigEntity(&company.CSC),
+		DANFE:             entity.DANFEConfig(company.DANFE),
+		Intermediadores:   m.ToIntermediadoresEntity(company.Intermediadores),
+		FraudRules:        m.ToFraudRulesEntity(company.FraudRules),
+		TaxRules:          m.ToTaxRulesEntity(company.TaxRules),
 		RegimeTributario:  entity.TaxRegime(company.RegimeTributario),
 		Status:            entity.CompanyStatus(company.Status),
 		CreatedAt:         company.CreatedAt,
 		UpdatedAt:         company.UpdatedAt,
+		DeletedAt:         company.DeletedAt,
+		StorageCleanupAt:  company.StorageCleanupAt,
+		StorageCleanedAt:  company.StorageCleanedAt,
 	}
 }
 
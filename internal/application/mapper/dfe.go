@@ -0,0 +1,43 @@
+package mapper
+
+import (
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// DFeMapper handles mapping between DF-e distribution entities and DTOs
+type DFeMapper struct{}
+
+// NewDFeMapper creates a new DFeMapper
+func NewDFeMapper() *DFeMapper {
+	return &DFeMapper{}
+}
+
+// ToDFeStatusResponse converts a DFeCursor entity to a DFeStatusResponse
+func (m *DFeMapper) ToDFeStatusResponse(cursor *entity.DFeCursor) *dto.DFeStatusResponse {
+	return &dto.DFeStatusResponse{
+		UltNSU:     cursor.UltNSU,
+		MaxNSU:     cursor.MaxNSU,
+		CStat:      cursor.CStat,
+		Motivo:     cursor.Motivo,
+		CaughtUp:   cursor.CaughtUp(),
+		LastSyncAt: cursor.LastSyncAt,
+		NextSyncAt: cursor.NextSyncAt,
+	}
+}
+
+// ToDFeDocumentResponse converts a DFeDocument entity to a DFeDocumentResponse
+func (m *DFeMapper) ToDFeDocumentResponse(document *entity.DFeDocument) *dto.DFeDocumentResponse {
+	return &dto.DFeDocumentResponse{
+		ID:           document.ID,
+		NSU:          document.NSU,
+		Schema:       document.Schema,
+		Tipo:         string(document.Tipo),
+		ChaveAcesso:  document.ChaveAcesso,
+		CNPJEmitente: document.CNPJEmitente,
+		XMLURL:       fmt.Sprintf("/api/v1/dfe/documents/%s/xml", document.ID),
+		CreatedAt:    document.CreatedAt,
+	}
+}
@@ -0,0 +1,31 @@
+package mapper
+
+import (
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// EmailMapper handles mapping between e-mail delivery entities and DTOs
+type EmailMapper struct{}
+
+// NewEmailMapper creates a new EmailMapper
+func NewEmailMapper() *EmailMapper {
+	return &EmailMapper{}
+}
+
+// ToDeliveryResponse converts an EmailDelivery entity to an EmailDeliveryResponse
+func (m *EmailMapper) ToDeliveryResponse(delivery *entity.EmailDelivery) *dto.EmailDeliveryResponse {
+	return &dto.EmailDeliveryResponse{
+		ID:           delivery.ID,
+		RequestID:    delivery.RequestID,
+		Recipient:    delivery.Recipient,
+		Subject:      delivery.Subject,
+		Trigger:      string(delivery.Trigger),
+		Provider:     delivery.Provider,
+		MessageID:    delivery.MessageID,
+		ErrorMessage: delivery.ErrorMessage,
+		Succeeded:    delivery.Succeeded,
+		DeliveredAt:  delivery.DeliveredAt,
+		CreatedAt:    delivery.CreatedAt,
+	}
+}
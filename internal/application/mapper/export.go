@@ -0,0 +1,45 @@
+package mapper
+
+import (
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// ExportMapper handles mapping between export job entities and DTOs
+type ExportMapper struct{}
+
+// NewExportMapper creates a new ExportMapper
+func NewExportMapper() *ExportMapper {
+	return &ExportMapper{}
+}
+
+// ToExportJobResponse converts an ExportJob entity to an ExportJobResponse
+func (m *ExportMapper) ToExportJobResponse(job *entity.ExportJob) *dto.ExportJobResponse {
+	parts := make([]dto.ExportPartResponse, len(job.Parts))
+	for i, part := range job.Parts {
+		parts[i] = dto.ExportPartResponse{
+			Number: part.Number,
+			Size:   part.Size,
+			Items:  part.Items,
+			URL:    fmt.Sprintf("/api/v1/exports/%s/parts/%d", job.ID, part.Number),
+		}
+	}
+
+	return &dto.ExportJobResponse{
+		ID:             job.ID,
+		Status:         dto.ExportStatus(job.Status),
+		PeriodStart:    job.PeriodStart,
+		PeriodEnd:      job.PeriodEnd,
+		IncludeIndex:   job.IncludeIndex,
+		TotalItems:     job.TotalItems,
+		ProcessedItems: job.ProcessedItems,
+		Progress:       job.Progress(),
+		Parts:          parts,
+		ErrorMessage:   job.ErrorMessage,
+		ExpiresAt:      job.ExpiresAt,
+		CompletedAt:    job.CompletedAt,
+		CreatedAt:      job.CreatedAt,
+	}
+}
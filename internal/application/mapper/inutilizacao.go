@@ -0,0 +1,35 @@
+package mapper
+
+import (
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// InutilizacaoMapper handles mapping between inutilização entities and DTOs
+type InutilizacaoMapper struct{}
+
+// NewInutilizacaoMapper creates a new InutilizacaoMapper
+func NewInutilizacaoMapper() *InutilizacaoMapper {
+	return &InutilizacaoMapper{}
+}
+
+// ToInutilizacaoResponse converts an Inutilizacao entity to an InutilizacaoResponse
+func (m *InutilizacaoMapper) ToInutilizacaoResponse(inutilizacao *entity.Inutilizacao) *dto.InutilizacaoResponse {
+	return &dto.InutilizacaoResponse{
+		ID:            inutilizacao.ID,
+		UF:            inutilizacao.UF,
+		Ambiente:      inutilizacao.Ambiente,
+		Modelo:        inutilizacao.Modelo,
+		Serie:         inutilizacao.Serie,
+		NumeroInicial: inutilizacao.NumeroInicial,
+		NumeroFinal:   inutilizacao.NumeroFinal,
+		Justificativa: inutilizacao.Justificativa,
+		Status:        dto.InutilizacaoStatus(inutilizacao.Status),
+		Protocolo:     inutilizacao.Protocolo,
+		CStat:         inutilizacao.CStat,
+		XMotivo:       inutilizacao.XMotivo,
+		XMLURL:        inutilizacao.XMLURL,
+		HomologatedAt: inutilizacao.HomologatedAt,
+		CreatedAt:     inutilizacao.CreatedAt,
+	}
+}
@@ -0,0 +1,36 @@
+package mapper
+
+import (
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// MaintenanceMapper handles mapping between maintenance window entities and DTOs
+type MaintenanceMapper struct{}
+
+// NewMaintenanceMapper creates a new MaintenanceMapper
+func NewMaintenanceMapper() *MaintenanceMapper {
+	return &MaintenanceMapper{}
+}
+
+// ToMaintenanceWindowResponse converts a MaintenanceWindow entity to a MaintenanceWindowResponse
+func (m *MaintenanceMapper) ToMaintenanceWindowResponse(window *entity.MaintenanceWindow) *dto.MaintenanceWindowResponse {
+	return &dto.MaintenanceWindowResponse{
+		ID:            window.ID,
+		Message:       window.Message,
+		StartedAt:     window.StartedAt,
+		ExpectedEndAt: window.ExpectedEndAt,
+		EndedAt:       window.EndedAt,
+	}
+}
+
+// ToMaintenanceStatusResponse converts the active window, if any, to a MaintenanceStatusResponse
+func (m *MaintenanceMapper) ToMaintenanceStatusResponse(window *entity.MaintenanceWindow) *dto.MaintenanceStatusResponse {
+	if window == nil {
+		return &dto.MaintenanceStatusResponse{}
+	}
+	return &dto.MaintenanceStatusResponse{
+		Active: true,
+		Window: m.ToMaintenanceWindowResponse(window),
+	}
+}
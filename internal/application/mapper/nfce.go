@@ -21,11 +21,22 @@ func (m *NFceMapper) ToEmitPayload(req dto.EmitNFceRequest) entity.EmitPayload {
 		itens[i] = entity.Item{
 			Descricao:  item.Descricao,
 			NCM:        item.NCM,
+			CEST:       item.CEST,
 			CFOP:       item.CFOP,
 			GTIN:       item.GTIN,
 			Valor:      item.Valor,
 			Quantidade: item.Quantidade,
 			Unidade:    item.Unidade,
+			Desconto:   item.Desconto,
+			Outros:     item.Outros,
+		}
+		if item.IPI != nil {
+			ipi := entity.ItemIPI(*item.IPI)
+			itens[i].IPI = &ipi
+		}
+		if item.II != nil {
+			ii := entity.ItemII(*item.II)
+			itens[i].II = &ii
 		}
 	}
 
@@ -37,11 +48,37 @@ func (m *NFceMapper) ToEmitPayload(req dto.EmitNFceRequest) entity.EmitPayload {
 			Valor: payment.Valor,
 			Troco: payment.Troco,
 		}
+		if payment.Cartao != nil {
+			cartao := entity.CardPayment(*payment.Cartao)
+			pagamentos[i].Cartao = &cartao
+		}
+	}
+
+	var destinatario *entity.Destinatario
+	if req.Destinatario != nil {
+		destinatario = &entity.Destinatario{
+			CPF:   req.Destinatario.CPF,
+			CNPJ:  req.Destinatario.CNPJ,
+			Nome:  req.Destinatario.Nome,
+			Email: req.Destinatario.Email,
+			IE:    req.Destinatario.IE,
+		}
+		if req.Destinatario.Endereco != nil {
+			endereco := entity.Address(*req.Destinatario.Endereco)
+			destinatario.Endereco = &endereco
+		}
+	}
+
+	var intermediador *entity.IntermediadorRef
+	if req.Intermediador != nil {
+		ref := entity.IntermediadorRef(*req.Intermediador)
+		intermediador = &ref
 	}
 
 	return entity.EmitPayload{
 		UF:       req.UF,
 		Ambiente: req.Ambiente,
+		Serie:    req.Serie,
 		Emitente: entity.Emitente{
 			CNPJ:     req.Emitente.CNPJ,
 			IE:       req.Emitente.IE,
@@ -52,39 +89,144 @@ func (m *NFceMapper) ToEmitPayload(req dto.EmitNFceRequest) entity.EmitPayload {
 		Itens:      itens,
 		Pagamentos: pagamentos,
 		Options: entity.EmitOptions{
-			Contingencia: req.Options.Contingencia,
-			Sync:         req.Options.Sync,
+			Contingencia:  req.Options.Contingencia,
+			Sync:          req.Options.Sync,
+			DanfeDuasVias: req.Options.DanfeDuasVias,
 		},
+		Destinatario:     destinatario,
+		Intermediador:    intermediador,
+		Terminal:         req.Terminal,
+		Frete:            req.Frete,
+		Modelo:           req.Modelo,
+		NaturezaOperacao: req.NaturezaOperacao,
+		Transporte:       toTransporte(req.Transporte),
+		Cobranca:         toCobranca(req.Cobranca),
+	}
+}
+
+// toTransporte converts the transport of an NF-e request
+func toTransporte(req *dto.TransporteRequest) *entity.Transporte {
+	if req == nil {
+		return nil
+	}
+
+	transporte := &entity.Transporte{Modalidade: req.Modalidade}
+	if req.Transportadora != nil {
+		transportadora := entity.Transportadora(*req.Transportadora)
+		transporte.Transportadora = &transportadora
+	}
+	for _, volume := range req.Volumes {
+		transporte.Volumes = append(transporte.Volumes, entity.Volume(volume))
+	}
+	return transporte
+}
+
+// toCobranca converts the billing of an NF-e request
+func toCobranca(req *dto.CobrancaRequest) *entity.Cobranca {
+	if req == nil {
+		return nil
+	}
+
+	cobranca := &entity.Cobranca{}
+	if req.Fatura != nil {
+		fatura := entity.Fatura(*req.Fatura)
+		cobranca.Fatura = &fatura
 	}
+	for _, duplicata := range req.Duplicatas {
+		cobranca.Duplicatas = append(cobranca.Duplicatas, entity.Duplicata(duplicata))
+	}
+	return cobranca
 }
 
 // ToResponse converts Request entity to NFceResponse
 func (m *NFceMapper) ToResponse(req *entity.Request) dto.NFceResponse {
-	return dto.NFceResponse{
+	response := dto.NFceResponse{
 		ID:             req.ID,
 		IdempotencyKey: req.IdempotencyKey,
-		Status:         dto.RequestStatus(req.Status),
+		Status:         ToRequestStatusDTO(req.Status),
+		Modelo:         req.Payload.DocumentModel(),
 		ChaveAcesso:    req.ChaveAcesso,
 		Protocolo:      req.Protocolo,
+		Numero:         req.Numero,
+		Serie:          req.Serie,
+		Valor:          req.VNF,
 		RejectionCode:  req.RejectionCode,
 		RejectionMsg:   req.RejectionMsg,
 		RetryCount:     req.RetryCount,
 		NextRetryAt:    req.NextRetryAt,
 		CreatedAt:      req.CreatedAt,
 		UpdatedAt:      req.UpdatedAt,
+		Metadata:       req.Metadata,
+	}
+	response.DocumentsPurgedAt = req.DocumentsPurgedAt
+
+	for _, violation := range req.SchemaErrors {
+		response.SchemaErrors = append(response.SchemaErrors, dto.SchemaError{
+			Path:    violation.Path,
+			Field:   violation.Field,
+			Line:    violation.Line,
+			Message: violation.Message,
+			Detail:  violation.Detail,
+		})
+	}
+
+	if req.Status == entity.RequestStatusQueuedDeferred {
+		response.DelayedProcessing = true
+		response.Notice = "Fila de processamento indisponível: a NFC-e foi recebida e será enviada à SEFAZ assim que o serviço for restabelecido"
 	}
+
+	return response
 }
 
-// ToResponseList converts a slice of Request entities to NFceListResponse
-func (m *NFceMapper) ToResponseList(requests []*entity.Request) dto.NFceListResponse {
+// ToResponseList converts a page of Request entities to NFceListResponse
+func (m *NFceMapper) ToResponseList(requests []*entity.Request, total int, nextCursor string) dto.NFceListResponse {
 	responses := make([]dto.NFceResponse, len(requests))
 	for i, req := range requests {
 		responses[i] = m.ToResponse(req)
 	}
 
 	return dto.NFceListResponse{
-		NFces: responses,
-		Total: len(responses),
+		NFces:      responses,
+		Total:      total,
+		NextCursor: nextCursor,
+	}
+}
+
+// ToLoteResponse converts a lote and its NFC-e to LoteResponse
+func (m *NFceMapper) ToLoteResponse(lote *entity.NFCeLote, requests []*entity.Request) dto.LoteResponse {
+	counts := make(map[entity.RequestStatus]int)
+	responseCounts := make(map[dto.RequestStatus]int)
+	responses := make([]dto.NFceResponse, len(requests))
+	for i, req := range requests {
+		counts[req.Status]++
+		responseCounts[ToRequestStatusDTO(req.Status)]++
+		responses[i] = m.ToResponse(req)
+	}
+
+	return dto.LoteResponse{
+		ID:        lote.ID,
+		Status:    dto.LoteStatus(entity.AggregateLoteStatus(counts)),
+		Total:     len(responses),
+		Counts:    responseCounts,
+		NFces:     responses,
+		CreatedAt: lote.CreatedAt,
+	}
+}
+
+// ToSearchResponse converts the full-text search hits to NFceSearchResponse
+func (m *NFceMapper) ToSearchResponse(hits []*entity.NFCeSearchHit, total int) dto.NFceSearchResponse {
+	results := make([]dto.NFceSearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = dto.NFceSearchResult{
+			NFceResponse: m.ToResponse(hit.NFCe),
+			Rank:         hit.Rank,
+			Highlight:    hit.Highlight,
+		}
+	}
+
+	return dto.NFceSearchResponse{
+		Results: results,
+		Total:   total,
 	}
 }
 
@@ -93,10 +235,11 @@ func (m *NFceMapper) ToEventResponse(event *entity.Event) dto.NFceEventResponse
 	return dto.NFceEventResponse{
 		ID:         event.ID,
 		RequestID:  event.RequestID,
-		StatusFrom: dto.RequestStatus(event.StatusFrom),
-		StatusTo:   dto.RequestStatus(event.StatusTo),
+		StatusFrom: ToRequestStatusDTO(event.StatusFrom),
+		StatusTo:   ToRequestStatusDTO(event.StatusTo),
 		CStat:      event.CStat,
 		Message:    event.Message,
+		Metadata:   event.Metadata,
 		CreatedAt:  event.CreatedAt,
 	}
 }
@@ -36,6 +36,8 @@ func (m *PlanMapper) ToPlanDTO(plan *entity.Plan) *dto.PlanDTO {
 			WebhookSupport:     plan.Features.WebhookSupport,
 			PrioritySupport:    plan.Features.PrioritySupport,
 			StorageDays:        plan.Features.StorageDays,
+			RequestsPerMinute:  plan.Features.RequestsPerMinute,
+			EmissionsPerMinute: plan.Features.EmissionsPerMinute,
 		},
 		IsPopular: plan.IsPopular,
 		SortOrder: plan.SortOrder,
@@ -68,6 +70,8 @@ func (m *PlanMapper) ToPlanEntity(plan *dto.PlanDTO) *entity.Plan {
 			WebhookSupport:     plan.Features.WebhookSupport,
 			PrioritySupport:    plan.Features.PrioritySupport,
 			StorageDays:        plan.Features.StorageDays,
+			RequestsPerMinute:  plan.Features.RequestsPerMinute,
+			EmissionsPerMinute: plan.Features.EmissionsPerMinute,
 		},
 		IsPopular: plan.IsPopular,
 		SortOrder: plan.SortOrder,
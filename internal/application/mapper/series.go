@@ -0,0 +1,50 @@
+package mapper
+
+import (
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// SeriesMapper handles mapping between numbering series entities and DTOs
+type SeriesMapper struct{}
+
+// NewSeriesMapper creates a new SeriesMapper
+func NewSeriesMapper() *SeriesMapper {
+	return &SeriesMapper{}
+}
+
+// ToSeriesResponse converts a NumberingSeries entity to a NumberingSeriesResponse
+func (m *SeriesMapper) ToSeriesResponse(series *entity.NumberingSeries) *dto.NumberingSeriesResponse {
+	return &dto.NumberingSeriesResponse{
+		ID:         series.ID,
+		Modelo:     series.Modelo,
+		Serie:      series.Serie,
+		Ambiente:   series.Ambiente,
+		NextNumber: series.NextNumber,
+		Default:    series.Default,
+		Active:     series.Active,
+		CreatedAt:  series.CreatedAt,
+		UpdatedAt:  series.UpdatedAt,
+	}
+}
+
+// ToGapsResponse converts the gaps of a series to a NumberingGapsResponse
+func (m *SeriesMapper) ToGapsResponse(series *entity.NumberingSeries, gaps []entity.NumberingGap) *dto.NumberingGapsResponse {
+	responses := make([]dto.NumberingGapResponse, len(gaps))
+	for i, gap := range gaps {
+		responses[i] = dto.NumberingGapResponse{
+			NumeroInicial: gap.NumeroInicial,
+			NumeroFinal:   gap.NumeroFinal,
+			Quantidade:    gap.NumeroFinal - gap.NumeroInicial + 1,
+		}
+	}
+
+	return &dto.NumberingGapsResponse{
+		SeriesID:   series.ID,
+		Modelo:     series.Modelo,
+		Serie:      series.Serie,
+		Ambiente:   series.Ambiente,
+		NextNumber: series.NextNumber,
+		Gaps:       responses,
+	}
+}
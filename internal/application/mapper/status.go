@@ -0,0 +1,63 @@
+package mapper
+
+import (
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// requestStatusDTOs maps every domain request status to its API status
+var requestStatusDTOs = map[entity.RequestStatus]dto.RequestStatus{
+	entity.RequestStatusPending:             dto.RequestStatusPending,
+	entity.RequestStatusQueuedDeferred:      dto.RequestStatusQueuedDeferred,
+	entity.RequestStatusProcessing:          dto.RequestStatusProcessing,
+	entity.RequestStatusAuthorized:          dto.RequestStatusAuthorized,
+	entity.RequestStatusRejected:            dto.RequestStatusRejected,
+	entity.RequestStatusContingency:         dto.RequestStatusContingency,
+	entity.RequestStatusRetrying:            dto.RequestStatusRetrying,
+	entity.RequestStatusCanceled:            dto.RequestStatusCanceled,
+	entity.RequestStatusPendingTransmission: dto.RequestStatusPendingTransmission,
+}
+
+// requestStatusEntities is the inverse of requestStatusDTOs
+var requestStatusEntities = make(map[dto.RequestStatus]entity.RequestStatus, len(requestStatusDTOs))
+
+// init checks that the domain and API statuses did not diverge, so a status added
+// on one side only fails at startup instead of leaking unmapped values
+func init() {
+	for _, status := range entity.RequestStatuses {
+		if _, ok := requestStatusDTOs[status]; !ok {
+			panic(fmt.Sprintf("mapper: request status %q has no API status", status))
+		}
+	}
+	for status, apiStatus := range requestStatusDTOs {
+		if !status.IsValid() {
+			panic(fmt.Sprintf("mapper: API status %q maps an unknown request status %q", apiStatus, status))
+		}
+		requestStatusEntities[apiStatus] = status
+	}
+	for _, apiStatus := range dto.RequestStatuses {
+		if _, ok := requestStatusEntities[apiStatus]; !ok {
+			panic(fmt.Sprintf("mapper: API status %q has no request status", apiStatus))
+		}
+	}
+}
+
+// ToRequestStatusDTO converts a domain request status to its API status. Values
+// outside the enum, such as the empty origin of the first event, are kept as is.
+func ToRequestStatusDTO(status entity.RequestStatus) dto.RequestStatus {
+	if apiStatus, ok := requestStatusDTOs[status]; ok {
+		return apiStatus
+	}
+	return dto.RequestStatus(status)
+}
+
+// ToRequestStatusEntity converts an API status, e.g. a list filter, to the domain request status
+func ToRequestStatusEntity(status dto.RequestStatus) (entity.RequestStatus, error) {
+	entityStatus, ok := requestStatusEntities[status]
+	if !ok {
+		return "", fmt.Errorf("status inválido: %s", status)
+	}
+	return entityStatus, nil
+}
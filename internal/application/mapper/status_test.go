@@ -0,0 +1,129 @@
+package mapper
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// declaredRequestStatuses returns the values of the RequestStatus constants declared in the
+// package at dir, so a constant left out of RequestStatuses is caught too
+func declaredRequestStatuses(t *testing.T, dir string) []string {
+	t.Helper()
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []string
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.CONST {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					value := spec.(*ast.ValueSpec)
+					if ident, ok := value.Type.(*ast.Ident); !ok || ident.Name != "RequestStatus" {
+						continue
+					}
+					for _, v := range value.Values {
+						lit, ok := v.(*ast.BasicLit)
+						if !ok || lit.Kind != token.STRING {
+							t.Fatalf("%s: RequestStatus constant is not a string literal", dir)
+						}
+						s, err := strconv.Unquote(lit.Value)
+						if err != nil {
+							t.Fatal(err)
+						}
+						values = append(values, s)
+					}
+				}
+			}
+		}
+	}
+	if len(values) == 0 {
+		t.Fatalf("%s: no RequestStatus constants found", dir)
+	}
+	return values
+}
+
+func TestRequestStatusesListEveryConstant(t *testing.T) {
+	tests := []struct {
+		name   string
+		dir    string
+		listed map[string]bool
+	}{
+		{name: "entity", dir: "../../domain/entity", listed: make(map[string]bool)},
+		{name: "dto", dir: "../dto", listed: make(map[string]bool)},
+	}
+	for _, status := range entity.RequestStatuses {
+		tests[0].listed[string(status)] = true
+	}
+	for _, status := range dto.RequestStatuses {
+		tests[1].listed[string(status)] = true
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, value := range declaredRequestStatuses(t, tt.dir) {
+				if !tt.listed[value] {
+					t.Errorf("%s.RequestStatus %q is missing from %s.RequestStatuses", tt.name, value, tt.name)
+				}
+			}
+		})
+	}
+}
+
+func TestToRequestStatusDTO(t *testing.T) {
+	apiStatuses := make(map[dto.RequestStatus]bool, len(dto.RequestStatuses))
+	for _, apiStatus := range dto.RequestStatuses {
+		apiStatuses[apiStatus] = true
+	}
+
+	for _, status := range entity.RequestStatuses {
+		t.Run(string(status), func(t *testing.T) {
+			apiStatus, ok := requestStatusDTOs[status]
+			if !ok {
+				t.Fatalf("entity status %q has no API status", status)
+			}
+			if got := ToRequestStatusDTO(status); got != apiStatus {
+				t.Fatalf("ToRequestStatusDTO() = %q, want %q", got, apiStatus)
+			}
+			if !apiStatuses[apiStatus] {
+				t.Fatalf("API status %q is missing from dto.RequestStatuses", apiStatus)
+			}
+			back, err := ToRequestStatusEntity(apiStatus)
+			if err != nil || back != status {
+				t.Fatalf("ToRequestStatusEntity(%q) = %q, %v, want %q", apiStatus, back, err, status)
+			}
+		})
+	}
+}
+
+func TestToRequestStatusEntity(t *testing.T) {
+	for _, apiStatus := range dto.RequestStatuses {
+		t.Run(string(apiStatus), func(t *testing.T) {
+			status, err := ToRequestStatusEntity(apiStatus)
+			if err != nil {
+				t.Fatalf("API status %q has no entity status: %v", apiStatus, err)
+			}
+			if !status.IsValid() {
+				t.Fatalf("ToRequestStatusEntity() = %q, not in entity.RequestStatuses", status)
+			}
+			if back := ToRequestStatusDTO(status); back != apiStatus {
+				t.Fatalf("ToRequestStatusDTO(%q) = %q, want %q", status, back, apiStatus)
+			}
+		})
+	}
+
+	if _, err := ToRequestStatusEntity("unknown"); err == nil {
+		t.Fatal("ToRequestStatusEntity(unknown) error = nil, want an error")
+	}
+}
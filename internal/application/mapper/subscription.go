@@ -39,6 +39,7 @@ func (m *SubscriptionMapper) ToSubscriptionDTO(subscription *entity.Subscription
 			Amount:        subscription.BillingInfo.Amount,
 			Currency:      subscription.BillingInfo.Currency,
 			PaymentMethod: subscription.BillingInfo.PaymentMethod,
+			CustomerID:    subscription.BillingInfo.CustomerID,
 		},
 		AutoRenew:    subscription.AutoRenew,
 		CancelReason: subscription.CancelReason,
@@ -86,6 +87,7 @@ func (m *SubscriptionMapper) ToSubscriptionEntity(subscription *dto.Subscription
 			Amount:        subscription.BillingInfo.Amount,
 			Currency:      subscription.BillingInfo.Currency,
 			PaymentMethod: subscription.BillingInfo.PaymentMethod,
+			CustomerID:    subscription.BillingInfo.CustomerID,
 		},
 		AutoRenew:    subscription.AutoRenew,
 		CancelReason: subscription.CancelReason,
@@ -106,3 +108,84 @@ func (m *SubscriptionMapper) ToSubscriptionListDTO(subscriptions []*entity.Subsc
 		Total:         len(dtos),
 	}
 }
+
+// ToUsageStatementDTO converts a UsageStatement entity to UsageStatementDTO
+func (m *SubscriptionMapper) ToUsageStatementDTO(statement *entity.UsageStatement) *dto.UsageStatementDTO {
+	summary := statement.Summary
+	var changes []dto.StatementPlanChangeDTO
+	for _, change := range summary.PlanChanges {
+		changes = append(changes, dto.StatementPlanChangeDTO{
+			ChangedAt:    change.ChangedAt,
+			FromPlanID:   change.FromPlanID,
+			FromPlanName: change.FromPlanName,
+			ToPlanID:     change.ToPlanID,
+			ToPlanName:   change.ToPlanName,
+			Proration:    change.Proration,
+		})
+	}
+	return &dto.UsageStatementDTO{
+		ID:          statement.ID,
+		Period:      statement.Period,
+		PeriodStart: statement.PeriodStart,
+		PeriodEnd:   statement.PeriodEnd,
+		Summary: dto.StatementSummaryDTO{
+			PlanID:      summary.PlanID,
+			PlanName:    summary.PlanName,
+			PlanChanges: changes,
+			NFCe: dto.StatementNFCeDTO{
+				Total:      summary.NFCe.Total,
+				ByStatus:   summary.NFCe.ByStatus,
+				ByAmbiente: summary.NFCe.ByAmbiente,
+				Emitted:    summary.NFCe.Emitted,
+				Quota:      summary.NFCe.Quota,
+				Overage:    summary.NFCe.Overage,
+			},
+			Webhooks: dto.StatementWebhooksDTO{
+				Total:     summary.Webhooks.Total,
+				Succeeded: summary.Webhooks.Succeeded,
+				Failed:    summary.Webhooks.Failed,
+			},
+			Storage: dto.StatementStorageDTO{
+				Objects: summary.Storage.Objects,
+				Bytes:   summary.Storage.Bytes,
+			},
+		},
+		CreatedAt: statement.CreatedAt,
+	}
+}
+
+// ToInvoiceDTO converts an Invoice entity to InvoiceDTO
+func (m *SubscriptionMapper) ToInvoiceDTO(invoice *entity.Invoice) *dto.InvoiceDTO {
+	return &dto.InvoiceDTO{
+		ID:             invoice.ID,
+		SubscriptionID: invoice.SubscriptionID,
+		Amount:         invoice.Amount,
+		Adjustment:     invoice.Adjustment,
+		Currency:       invoice.Currency,
+		PeriodStart:    invoice.PeriodStart,
+		PeriodEnd:      invoice.PeriodEnd,
+		Status:         string(invoice.Status),
+		Gateway:        invoice.Gateway,
+		Attempts:       invoice.Attempts,
+		LastError:      invoice.LastError,
+		NextAttemptAt:  invoice.NextAttemptAt,
+		PaidAt:         invoice.PaidAt,
+		CreatedAt:      invoice.CreatedAt,
+	}
+}
+
+// ToSubscriptionEventDTO converts a SubscriptionEvent entity to SubscriptionEventDTO
+func (m *SubscriptionMapper) ToSubscriptionEventDTO(event *entity.SubscriptionEvent) *dto.SubscriptionEventDTO {
+	return &dto.SubscriptionEventDTO{
+		ID:          event.ID,
+		Type:        string(event.Type),
+		FromPlanID:  event.FromPlanID,
+		ToPlanID:    event.ToPlanID,
+		QuotaBefore: event.QuotaBefore,
+		QuotaAfter:  event.QuotaAfter,
+		Amount:      event.Amount,
+		Currency:    event.Currency,
+		InvoiceID:   event.InvoiceID,
+		CreatedAt:   event.CreatedAt,
+	}
+}
@@ -30,6 +30,7 @@ func (m *WebhookMapper) ToWebhookDTO(webhook *entity.Webhook) *dto.WebhookDTO {
 		Method:      dto.HTTPMethod(webhook.Method),
 		Status:      dto.WebhookStatus(webhook.Status),
 		Events:      events,
+		Filters:     m.ToWebhookFiltersDTO(webhook.Filters),
 		Headers:     dto.WebhookHeaders(webhook.Headers),
 		Secret:      webhook.Secret,
 		RetryConfig: dto.WebhookRetryConfig{
@@ -63,6 +64,7 @@ func (m *WebhookMapper) ToWebhookEntity(webhook *dto.WebhookDTO) *entity.Webhook
 		Method:      entity.HTTPMethod(webhook.Method),
 		Status:      entity.WebhookStatus(webhook.Status),
 		Events:      events,
+		Filters:     m.ToWebhookFiltersEntity(webhook.Filters),
 		Headers:     entity.WebhookHeaders(webhook.Headers),
 		Secret:      webhook.Secret,
 		RetryConfig: entity.WebhookRetryConfig{
@@ -79,6 +81,30 @@ func (m *WebhookMapper) ToWebhookEntity(webhook *dto.WebhookDTO) *entity.Webhook
 	}
 }
 
+// ToWebhookFiltersDTO converts the webhook filters to DTOs
+func (m *WebhookMapper) ToWebhookFiltersDTO(filters entity.WebhookFilters) []dto.WebhookFilter {
+	if len(filters) == 0 {
+		return nil
+	}
+	dtos := make([]dto.WebhookFilter, len(filters))
+	for i, filter := range filters {
+		dtos[i] = dto.WebhookFilter{Field: filter.Field, Op: filter.Op, Value: filter.Value}
+	}
+	return dtos
+}
+
+// ToWebhookFiltersEntity converts the webhook filter DTOs to entities
+func (m *WebhookMapper) ToWebhookFiltersEntity(filters []dto.WebhookFilter) entity.WebhookFilters {
+	if len(filters) == 0 {
+		return nil
+	}
+	entities := make(entity.WebhookFilters, len(filters))
+	for i, filter := range filters {
+		entities[i] = entity.WebhookFilter{Field: filter.Field, Op: filter.Op, Value: filter.Value}
+	}
+	return entities
+}
+
 // ToWebhookListDTO converts a slice of Webhook entities to WebhookListResponse
 func (m *WebhookMapper) ToWebhookListDTO(webhooks []*entity.Webhook) dto.WebhookListResponse {
 	dtos := make([]dto.WebhookDTO, len(webhooks))
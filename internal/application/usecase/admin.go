@@ -1,20 +1,45 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
 )
 
+var (
+	// ErrCompanyAlreadyExists is returned when creating a company with a registered CNPJ
+	ErrCompanyAlreadyExists = errors.New("company with this CNPJ already exists")
+	// ErrInvalidCompany wraps the validation errors of the company data
+	ErrInvalidCompany = errors.New("invalid company")
+	// ErrSEFAZExchangesNotFound is returned when no SEFAZ exchange of the NFC-e is archived
+	ErrSEFAZExchangesNotFound = errors.New("no SEFAZ exchanges archived for this NFC-e")
+	// ErrNFCeAlreadyAuthorized is returned when reprocessing an NFC-e SEFAZ already authorized
+	ErrNFCeAlreadyAuthorized = errors.New("NFC-e already authorized")
+	// ErrNFCeNotReprocessable is returned when reprocessing an NFC-e that is not stuck
+	ErrNFCeNotReprocessable = errors.New("NFC-e can not be reprocessed")
+)
+
+// reprocessMinIdle is how long an NFC-e must be left in processing before it counts as stuck,
+// so the reprocess never races the worker still emitting it
+const reprocessMinIdle = 5 * time.Minute
+
 // AdminUseCase defines the interface for admin operations
 type AdminUseCase interface {
 	CreateCompany(ctx context.Context, req dto.CreateCompanyRequest) (*dto.CompanyDTO, error)
 	GetCompany(ctx context.Context, id string) (*dto.CompanyDTO, error)
 	ListCompanies(ctx context.Context, limit, offset int) (*dto.CompanyListResponse, error)
 	UpdateCompany(ctx context.Context, id string, req dto.UpdateCompanyRequest) error
+	DeleteCompany(ctx context.Context, id string) (*dto.DeleteCompanyResponse, error)
 	CreatePlan(ctx context.Context, req dto.CreatePlanRequest) (*dto.PlanDTO, error)
 	GetPlan(ctx context.Context, id string) (*dto.PlanDTO, error)
 	ListPlans(ctx context.Context, limit, offset int) (*dto.PlanListResponse, error)
@@ -23,6 +48,10 @@ type AdminUseCase interface {
 	GetSubscription(ctx context.Context, id string) (*dto.SubscriptionDTO, error)
 	ListSubscriptions(ctx context.Context, limit, offset int) (*dto.SubscriptionListResponse, error)
 	UpdateSubscription(ctx context.Context, id string, req dto.UpdateSubscriptionRequest) error
+	ListNFCe(ctx context.Context, companyID string, filter ports.NFCeFilter, cursor string, limit int) (*dto.NFceListResponse, error)
+	GetStats(ctx context.Context, filter ports.NFCeStatsFilter) (*dto.AdminStatsResponse, error)
+	ExportSEFAZExchanges(ctx context.Context, requestID string) (*dto.NFceFile, error)
+	ReprocessNFCe(ctx context.Context, requestID string, req dto.ReprocessNFCeRequest) (*dto.NFceResponse, error)
 }
 
 // AdminUseCaseImpl handles admin operations
@@ -31,9 +60,15 @@ type AdminUseCaseImpl struct {
 	planRepo           ports.PlanRepository
 	subscriptionRepo   ports.SubscriptionRepository
 	nfceRepo           ports.NFCeRepository
+	txManager          ports.TxManager
+	outbox             ports.OutboxRepository
 	companyMapper      *mapper.CompanyMapper
 	planMapper         *mapper.PlanMapper
 	subscriptionMapper *mapper.SubscriptionMapper
+	nfceMapper         *mapper.NFceMapper
+	archive            *service.SEFAZArchiveService
+	municipios         *service.MunicipioService
+	offboarding        *service.CompanyOffboardingService
 }
 
 // NewAdminUseCase creates a new AdminUseCase
@@ -41,22 +76,38 @@ func NewAdminUseCase(
 	companyRepo ports.CompanyRepository,
 	planRepo ports.PlanRepository,
 	subscriptionRepo ports.SubscriptionRepository,
+	nfceRepo ports.NFCeRepository,
+	txManager ports.TxManager,
+	outbox ports.OutboxRepository,
+	archive *service.SEFAZArchiveService,
+	municipios *service.MunicipioService,
+	offboarding *service.CompanyOffboardingService,
 ) AdminUseCase {
 	return &AdminUseCaseImpl{
 		companyRepo:        companyRepo,
 		planRepo:           planRepo,
 		subscriptionRepo:   subscriptionRepo,
+		nfceRepo:           nfceRepo,
+		txManager:          txManager,
+		outbox:             outbox,
 		companyMapper:      mapper.NewCompanyMapper(),
 		planMapper:         mapper.NewPlanMapper(),
 		subscriptionMapper: mapper.NewSubscriptionMapper(),
+		nfceMapper:         mapper.NewNFceMapper(),
+		archive:            archive,
+		municipios:         municipios,
+		offboarding:        offboarding,
 	}
 }
 
-// CreateCompany creates a new company
+// CreateCompany creates a new company; the CNPJ must not be registered yet
 func (uc *AdminUseCaseImpl) CreateCompany(ctx context.Context, req dto.CreateCompanyRequest) (*dto.CompanyDTO, error) {
 	company, err := entity.NewCompany(req.CNPJ, req.RazaoSocial)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCompany, invalid(err))
+	}
+	if existing, err := uc.companyRepo.GetByCNPJ(ctx, company.CNPJ); err == nil && existing != nil {
+		return nil, ErrCompanyAlreadyExists
 	}
 
 	// Apply additional fields from request
@@ -65,11 +116,15 @@ func (uc *AdminUseCaseImpl) CreateCompany(ctx context.Context, req dto.CreateCom
 	company.Email = req.Email
 	company.Endereco = *mapper.NewCompanyMapper().ToAddressEntity(&req.Endereco)
 	company.RegimeTributario = entity.TaxRegime(req.RegimeTributario)
+	if err := resolveCompanyAddress(ctx, uc.municipios, &company.Endereco); err != nil {
+		return nil, err
+	}
 
 	err = uc.companyRepo.Create(ctx, company)
 	if err != nil {
 		return nil, err
 	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, nil, company)
 
 	return uc.companyMapper.ToCompanyDTO(company), nil
 }
@@ -78,7 +133,7 @@ func (uc *AdminUseCaseImpl) CreateCompany(ctx context.Context, req dto.CreateCom
 func (uc *AdminUseCaseImpl) GetCompany(ctx context.Context, id string) (*dto.CompanyDTO, error) {
 	company, err := uc.companyRepo.GetByID(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, ErrCompanyNotFound
 	}
 
 	return uc.companyMapper.ToCompanyDTO(company), nil
@@ -106,8 +161,12 @@ func (uc *AdminUseCaseImpl) ListCompanies(ctx context.Context, limit, offset int
 func (uc *AdminUseCaseImpl) UpdateCompany(ctx context.Context, id string, req dto.UpdateCompanyRequest) error {
 	company, err := uc.companyRepo.GetByID(ctx, id)
 	if err != nil {
-		return err
+		return ErrCompanyNotFound
 	}
+	if company.IsDeleted() {
+		return ErrCompanyDeleted
+	}
+	before := entity.NewAuditSnapshot(company)
 
 	// Apply updates from request
 	if req.NomeFantasia != nil {
@@ -121,6 +180,9 @@ func (uc *AdminUseCaseImpl) UpdateCompany(ctx context.Context, id string, req dt
 	}
 	if req.Endereco != nil {
 		company.Endereco = *uc.companyMapper.ToAddressEntity(req.Endereco)
+		if err := resolveCompanyAddress(ctx, uc.municipios, &company.Endereco); err != nil {
+			return err
+		}
 	}
 	if req.RegimeTributario != nil {
 		company.RegimeTributario = entity.TaxRegime(*req.RegimeTributario)
@@ -128,15 +190,67 @@ func (uc *AdminUseCaseImpl) UpdateCompany(ctx context.Context, id string, req dt
 	if req.Status != nil {
 		company.Status = entity.CompanyStatus(*req.Status)
 	}
+	if req.DANFE != nil {
+		company.DANFE = entity.DANFEConfig(*req.DANFE)
+	}
+	if req.Intermediadores != nil {
+		intermediadores := mapper.NewCompanyMapper().ToIntermediadoresEntity(*req.Intermediadores)
+		if err := intermediadores.Validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidCompany, invalid(err))
+		}
+		company.Intermediadores = intermediadores
+	}
+	if req.FraudRules != nil {
+		rules := mapper.NewCompanyMapper().ToFraudRulesEntity(*req.FraudRules)
+		if err := rules.Validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidCompany, invalid(err))
+		}
+		company.FraudRules = rules
+	}
+	if req.TaxRules != nil {
+		company.TaxRules = mapper.NewCompanyMapper().ToTaxRulesEntity(*req.TaxRules)
+	}
+	if err := company.TaxRules.Validate(company.RegimeTributario); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCompany, invalid(err))
+	}
 
-	return uc.companyRepo.Update(ctx, company)
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
+}
+
+// DeleteCompany offboards the company as required by the LGPD: it is soft deleted, its API
+// keys revoked, its subscription canceled, its certificate and CSC secrets purged and the
+// destinatário CPFs of its NFC-e past the retention anonymized. Its documents are deleted
+// from storage by the worker after the grace period.
+func (uc *AdminUseCaseImpl) DeleteCompany(ctx context.Context, id string) (*dto.DeleteCompanyResponse, error) {
+	company, err := uc.companyRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrCompanyNotFound
+	}
+
+	result, err := uc.offboarding.Offboard(ctx, company, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.DeleteCompanyResponse{
+		CompanyID:            result.Company.ID,
+		DeletedAt:            *result.Company.DeletedAt,
+		APIKeysRevoked:       result.APIKeysRevoked,
+		SubscriptionCanceled: result.SubscriptionCanceled,
+		NFCeAnonymized:       result.NFCeAnonymized,
+		StorageCleanupAt:     *result.Company.StorageCleanupAt,
+	}, nil
 }
 
 // CreatePlan creates a new plan
 func (uc *AdminUseCaseImpl) CreatePlan(ctx context.Context, req dto.CreatePlanRequest) (*dto.PlanDTO, error) {
 	plan, err := entity.NewPlan(req.Name, req.Description, entity.PlanType(req.Type), req.Price)
 	if err != nil {
-		return nil, err
+		return nil, invalid(err)
 	}
 
 	err = uc.planRepo.Create(ctx, plan)
@@ -214,6 +328,8 @@ func (uc *AdminUseCaseImpl) UpdatePlan(ctx context.Context, id string, req dto.U
 			WebhookSupport:     req.Features.WebhookSupport,
 			PrioritySupport:    req.Features.PrioritySupport,
 			StorageDays:        req.Features.StorageDays,
+			RequestsPerMinute:  req.Features.RequestsPerMinute,
+			EmissionsPerMinute: req.Features.EmissionsPerMinute,
 		}
 	}
 	if req.IsPopular != nil {
@@ -238,10 +354,10 @@ func (uc *AdminUseCaseImpl) CreateSubscription(ctx context.Context, req dto.Crea
 
 	subscription, err := entity.NewSubscription(req.CompanyID, req.PlanID, plan)
 	if err != nil {
-		return nil, err
+		return nil, invalid(err)
 	}
 
-	err = uc.subscriptionRepo.Create(ctx, subscription)
+	err = replaceActiveSubscription(ctx, uc.txManager, uc.subscriptionRepo, subscription)
 	if err != nil {
 		return nil, err
 	}
@@ -291,3 +407,208 @@ func (uc *AdminUseCaseImpl) UpdateSubscription(ctx context.Context, id string, r
 
 	return uc.subscriptionRepo.Update(ctx, subscription)
 }
+
+// ListNFCe lists a page of the NFC-e of every company, or of one when companyID is given,
+// matching the filter; cursor is the NextCursor of the previous page
+func (uc *AdminUseCaseImpl) ListNFCe(ctx context.Context, companyID string, filter ports.NFCeFilter, cursor string, limit int) (*dto.NFceListResponse, error) {
+	if cursor != "" {
+		after, err := decodeNFCeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = after
+	}
+
+	// One more than the page tells whether there is a next one
+	requests, total, err := uc.nfceRepo.ListByCompany(ctx, companyID, filter, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list NFC-es: %w", err)
+	}
+
+	var nextCursor string
+	if len(requests) > limit {
+		requests = requests[:limit]
+		last := requests[limit-1]
+		nextCursor, err = encodeNFCeCursor(ports.NFCeCursor{CreatedAt: last.CreatedAt, Valor: last.VNF, ID: last.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode cursor: %w", err)
+		}
+	}
+
+	response := uc.nfceMapper.ToResponseList(requests, total, nextCursor)
+	// The listing spans companies, so each NFC-e tells its own
+	for i, request := range requests {
+		response.NFces[i].CompanyID = request.CompanyID
+	}
+	return &response, nil
+}
+
+// ExportSEFAZExchanges returns a zip with the SOAP requests sent to SEFAZ for the NFC-e and
+// the responses received, as archived by the worker
+func (uc *AdminUseCaseImpl) ExportSEFAZExchanges(ctx context.Context, requestID string) (*dto.NFceFile, error) {
+	content, err := uc.archive.Export(ctx, requestID)
+	if errors.Is(err, service.ErrNoSEFAZExchanges) {
+		return nil, ErrSEFAZExchangesNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.NFceFile{
+		Content:     io.NopCloser(bytes.NewReader(content)),
+		Size:        int64(len(content)),
+		ContentType: "application/zip",
+		Filename:    fmt.Sprintf("sefaz-%s.zip", requestID),
+	}, nil
+}
+
+// ReprocessNFCe puts a stuck NFC-e (pending, queued_deferred, processing or retrying) back in
+// the emission queue as a retry due now. With Sync the worker first queries SEFAZ by the chave
+// de acesso of the NFC-e, which also frees one stuck awaiting the receipt of its lote. An NFC-e
+// SEFAZ already decided on is never emitted again.
+func (uc *AdminUseCaseImpl) ReprocessNFCe(ctx context.Context, requestID string, req dto.ReprocessNFCeRequest) (*dto.NFceResponse, error) {
+	nfceRequest, err := uc.nfceRepo.GetByID(ctx, requestID)
+	if err != nil {
+		return nil, ErrNFCeNotFound
+	}
+
+	switch nfceRequest.Status {
+	case entity.RequestStatusAuthorized:
+		return nil, ErrNFCeAlreadyAuthorized
+	case entity.RequestStatusPending, entity.RequestStatusQueuedDeferred, entity.RequestStatusRetrying:
+	case entity.RequestStatusProcessing:
+		if idle := time.Since(nfceRequest.UpdatedAt); idle < reprocessMinIdle {
+			return nil, fmt.Errorf("%w: em processamento há %s, aguarde %s", ErrNFCeNotReprocessable, idle.Round(time.Second), reprocessMinIdle)
+		}
+		if nfceRequest.IsAwaitingReceipt() {
+			if !req.Sync {
+				return nil, fmt.Errorf("%w: aguardando o recibo %s do lote, reprocesse com sync", ErrNFCeNotReprocessable, nfceRequest.Recibo)
+			}
+			nfceRequest.ClearReceipt()
+		}
+	default:
+		return nil, fmt.Errorf("%w: status %s", ErrNFCeNotReprocessable, nfceRequest.Status)
+	}
+
+	from := nfceRequest.Status
+	now := time.Now()
+	nfceRequest.Status = entity.RequestStatusRetrying
+	nfceRequest.NextRetryAt = &now
+	nfceRequest.UpdatedAt = now
+
+	// Save the reset and the emission message together; the worker claims the retry, so the
+	// retry scheduler picking it up as well emits it once
+	err = uc.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		event := &entity.Event{
+			Message:  "Reprocessamento solicitado pelo admin",
+			Metadata: map[string]interface{}{"reprocess": map[string]interface{}{"from": from, "sync": req.Sync}},
+		}
+		if err := uc.nfceRepo.Transition(ctx, nfceRequest, event); err != nil {
+			return err
+		}
+
+		outboxMsg, err := entity.NewOutboxMessage(entity.OutboxTopicEmit, nfceRequest.ID, dto.EmitMessage{
+			RequestID:      nfceRequest.ID,
+			CompanyID:      nfceRequest.CompanyID,
+			IdempotencyKey: nfceRequest.IdempotencyKey,
+			Resync:         req.Sync,
+			EnqueuedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+		if err := uc.outbox.Create(ctx, outboxMsg); err != nil {
+			return fmt.Errorf("failed to create NFC-e emission message: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, entity.ErrStatusConflict) {
+		return nil, fmt.Errorf("%w: o status mudou durante o reprocessamento", ErrNFCeNotReprocessable)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := uc.nfceMapper.ToResponse(nfceRequest)
+	response.CompanyID = nfceRequest.CompanyID
+	return &response, nil
+}
+
+// statsTopRejections is how many cStat the dashboard ranks
+const statsTopRejections = 10
+
+// GetStats builds the emission dashboard of the NFC-e created in the filter period
+func (uc *AdminUseCaseImpl) GetStats(ctx context.Context, filter ports.NFCeStatsFilter) (*dto.AdminStatsResponse, error) {
+	byStatus, err := uc.nfceRepo.GetStats(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count NFC-e: %w", err)
+	}
+	rejections, err := uc.nfceRepo.GetTopRejections(ctx, filter, statsTopRejections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank rejections: %w", err)
+	}
+	latencies, err := uc.nfceRepo.GetLatencyByUF(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute latency: %w", err)
+	}
+	days, err := uc.nfceRepo.GetDailyStats(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build daily series: %w", err)
+	}
+	companies, err := uc.nfceRepo.GetCompanyStats(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count NFC-e by company: %w", err)
+	}
+
+	response := &dto.AdminStatsResponse{
+		From:       filter.From,
+		To:         filter.To,
+		CompanyID:  filter.CompanyID,
+		ByStatus:   byStatus,
+		Rejections: make([]dto.RejectionStatResponse, len(rejections)),
+		Latency:    make([]dto.UFLatencyResponse, len(latencies)),
+		Daily:      make([]dto.DailyStatsResponse, len(days)),
+		Companies:  make([]dto.CompanyStatsResponse, len(companies)),
+	}
+	for i, rejection := range rejections {
+		response.Rejections[i] = dto.RejectionStatResponse{
+			CStat:   rejection.CStat,
+			XMotivo: rejection.XMotivo,
+			Count:   rejection.Count,
+		}
+	}
+	for i, latency := range latencies {
+		response.Latency[i] = dto.UFLatencyResponse{
+			UF:    latency.UF,
+			Count: latency.Count,
+			P50Ms: int64(math.Round(latency.P50Ms)),
+			P90Ms: int64(math.Round(latency.P90Ms)),
+			P99Ms: int64(math.Round(latency.P99Ms)),
+		}
+	}
+	for i, day := range days {
+		response.Daily[i] = dto.DailyStatsResponse{
+			Date:       day.Date.Format("2006-01-02"),
+			Total:      day.Total,
+			Authorized: day.Authorized,
+			Rejected:   day.Rejected,
+			Canceled:   day.Canceled,
+			Valor:      day.Valor,
+		}
+	}
+	for i, company := range companies {
+		response.Companies[i] = dto.CompanyStatsResponse{
+			CompanyID:   company.CompanyID,
+			CNPJ:        company.CNPJ,
+			RazaoSocial: company.RazaoSocial,
+			Total:       company.Total,
+			Authorized:  company.Authorized,
+			Rejected:    company.Rejected,
+			Canceled:    company.Canceled,
+			Billable:    company.Billable,
+			Valor:       company.Valor,
+		}
+	}
+
+	return response, nil
+}
@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+)
+
+// maxAuditPage caps the audit entries listed at once
+const maxAuditPage = 100
+
+// AuditUseCase defines the interface for the admin queries of the audit log
+type AuditUseCase interface {
+	List(ctx context.Context, filter ports.AuditFilter, limit, offset int) (*dto.AuditLogListResponse, error)
+}
+
+// AuditUseCaseImpl reads the audit log of the writes made through the API
+type AuditUseCaseImpl struct {
+	audit *service.AuditService
+}
+
+// NewAuditUseCase creates a new AuditUseCase
+func NewAuditUseCase(audit *service.AuditService) AuditUseCase {
+	return &AuditUseCaseImpl{audit: audit}
+}
+
+// List returns the audit entries matching the filter, newest first
+func (uc *AuditUseCaseImpl) List(ctx context.Context, filter ports.AuditFilter, limit, offset int) (*dto.AuditLogListResponse, error) {
+	if limit > maxAuditPage {
+		limit = maxAuditPage
+	}
+
+	entries, total, err := uc.audit.List(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.AuditEntryResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = dto.AuditEntryResponse{
+			ID:           entry.ID,
+			ActorType:    string(entry.ActorType),
+			ActorID:      entry.ActorID,
+			CompanyID:    entry.CompanyID,
+			Action:       entry.Action,
+			ResourceType: entry.ResourceType,
+			ResourceID:   entry.ResourceID,
+			Changes:      entry.Changes,
+			Status:       entry.Status,
+			IP:           entry.IP,
+			UserAgent:    entry.UserAgent,
+			RequestID:    entry.RequestID,
+			CreatedAt:    entry.CreatedAt,
+		}
+	}
+
+	return &dto.AuditLogListResponse{Entries: responses, Total: total}, nil
+}
@@ -0,0 +1,245 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/auth"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown admin or a wrong password
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidAPIKey is returned for unknown, revoked or expired API keys
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrInvalidToken is returned for admin tokens that are malformed, expired or not admin
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrCompanyInactive is returned when the company of a valid key is inactive or blocked
+	ErrCompanyInactive = errors.New("company is not active")
+	// ErrCompanyNotFound is returned when issuing a key for an unknown company
+	ErrCompanyNotFound = errors.New("company not found")
+	// ErrAPIKeyNotFound is returned when the key does not exist for the company
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyRevoked is returned when rotating a key that no longer authenticates
+	ErrAPIKeyRevoked = errors.New("API key is revoked or expired")
+)
+
+// AuthConfig controls admin tokens and API key rotation
+type AuthConfig struct {
+	JWTSecret     string
+	TokenTTL      time.Duration
+	RotationGrace time.Duration // How long a rotated key keeps working
+}
+
+// AuthUseCase defines the interface for authentication and API key operations
+type AuthUseCase interface {
+	Login(ctx context.Context, req dto.AdminLoginRequest) (*dto.AdminLoginResponse, error)
+	IssueAPIKey(ctx context.Context, companyID string, req dto.CreateAPIKeyRequest) (*dto.APIKeyResponse, error)
+	ListAPIKeys(ctx context.Context, companyID string) (*dto.APIKeyListResponse, error)
+	RotateAPIKey(ctx context.Context, companyID, id string) (*dto.RotateAPIKeyResponse, error)
+	RevokeAPIKey(ctx context.Context, companyID, id string) error
+	// AuthenticateAPIKey resolves a key to the ID of its company
+	AuthenticateAPIKey(ctx context.Context, key string) (string, error)
+	// AuthenticateAdmin resolves an admin token to the ID of the admin
+	AuthenticateAdmin(ctx context.Context, token string) (string, error)
+}
+
+// AuthUseCaseImpl handles authentication and API key operations
+type AuthUseCaseImpl struct {
+	adminRepo   ports.AdminRepository
+	apiKeyRepo  ports.APIKeyRepository
+	companyRepo ports.CompanyRepository
+	txManager   ports.TxManager
+	tokens      *auth.TokenIssuer
+	authMapper  *mapper.AuthMapper
+	config      AuthConfig
+	logger      logger.Logger
+}
+
+// NewAuthUseCase creates a new AuthUseCase
+func NewAuthUseCase(
+	adminRepo ports.AdminRepository,
+	apiKeyRepo ports.APIKeyRepository,
+	companyRepo ports.CompanyRepository,
+	txManager ports.TxManager,
+	config AuthConfig,
+	l logger.Logger,
+) AuthUseCase {
+	return &AuthUseCaseImpl{
+		adminRepo:   adminRepo,
+		apiKeyRepo:  apiKeyRepo,
+		companyRepo: companyRepo,
+		txManager:   txManager,
+		tokens:      auth.NewTokenIssuer(config.JWTSecret, config.TokenTTL),
+		authMapper:  mapper.NewAuthMapper(),
+		config:      config,
+		logger:      l,
+	}
+}
+
+// Login checks the admin credentials and issues a token
+func (uc *AuthUseCaseImpl) Login(ctx context.Context, req dto.AdminLoginRequest) (*dto.AdminLoginResponse, error) {
+	admin, err := uc.adminRepo.GetByUsername(ctx, strings.TrimSpace(req.Username))
+	if err != nil || !admin.CheckPassword(req.Password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	token, expiresAt, err := uc.tokens.Issue(admin.ID, auth.RoleAdmin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue token: %w", err)
+	}
+
+	return &dto.AdminLoginResponse{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// IssueAPIKey creates a key for the company; the response is the only time the key is returned
+func (uc *AuthUseCaseImpl) IssueAPIKey(ctx context.Context, companyID string, req dto.CreateAPIKeyRequest) (*dto.APIKeyResponse, error) {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return nil, ErrCompanyNotFound
+	}
+	if company.IsDeleted() {
+		return nil, ErrCompanyDeleted
+	}
+
+	key, plain, err := entity.NewAPIKey(companyID, req.Name)
+	if err != nil {
+		return nil, invalid(err)
+	}
+
+	if err := uc.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	response := uc.authMapper.ToAPIKeyResponse(key)
+	response.Key = plain
+	return response, nil
+}
+
+// ListAPIKeys lists the keys of the company, including revoked and expired ones
+func (uc *AuthUseCaseImpl) ListAPIKeys(ctx context.Context, companyID string) (*dto.APIKeyListResponse, error) {
+	keys, err := uc.apiKeyRepo.ListByCompanyID(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.authMapper.ToAPIKeyListResponse(keys), nil
+}
+
+// RotateAPIKey issues a key with the same name and expires the old one after the grace period
+func (uc *AuthUseCaseImpl) RotateAPIKey(ctx context.Context, companyID, id string) (*dto.RotateAPIKeyResponse, error) {
+	var (
+		issued *entity.APIKey
+		plain  string
+		old    *entity.APIKey
+	)
+	err := uc.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		old, err = uc.getCompanyKey(ctx, companyID, id)
+		if err != nil {
+			return err
+		}
+		if !old.IsActive(time.Now()) {
+			return ErrAPIKeyRevoked
+		}
+
+		issued, plain, err = entity.NewAPIKey(companyID, old.Name)
+		if err != nil {
+			return err
+		}
+		if err := uc.apiKeyRepo.Create(ctx, issued); err != nil {
+			return fmt.Errorf("failed to save API key: %w", err)
+		}
+
+		old.ExpireAfter(uc.config.RotationGrace)
+		if err := uc.apiKeyRepo.Update(ctx, old); err != nil {
+			return fmt.Errorf("failed to expire API key: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.RotateAPIKeyResponse{
+		Key:      *uc.authMapper.ToAPIKeyResponse(issued),
+		Replaced: *uc.authMapper.ToAPIKeyResponse(old),
+	}
+	response.Key.Key = plain
+	return response, nil
+}
+
+// RevokeAPIKey disables the key immediately
+func (uc *AuthUseCaseImpl) RevokeAPIKey(ctx context.Context, companyID, id string) error {
+	key, err := uc.getCompanyKey(ctx, companyID, id)
+	if err != nil {
+		return err
+	}
+
+	key.Revoke()
+	return uc.apiKeyRepo.Update(ctx, key)
+}
+
+// AuthenticateAPIKey resolves a key to its company, refusing inactive companies
+func (uc *AuthUseCaseImpl) AuthenticateAPIKey(ctx context.Context, plain string) (string, error) {
+	if !strings.HasPrefix(plain, entity.APIKeyPrefix) {
+		return "", ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	key, err := uc.apiKeyRepo.GetByHash(ctx, entity.HashAPIKey(plain))
+	if err != nil || !key.IsActive(now) {
+		return "", ErrInvalidAPIKey
+	}
+
+	company, err := uc.companyRepo.GetByID(ctx, key.CompanyID)
+	if err != nil {
+		return "", ErrInvalidAPIKey
+	}
+	if !company.IsActive() {
+		return "", ErrCompanyInactive
+	}
+
+	if err := uc.apiKeyRepo.TouchLastUsed(ctx, key.ID, now); err != nil {
+		logger.FromContext(ctx, uc.logger).Warn("Failed to record use of API key", logger.Field{Key: "api_key_id", Value: key.ID}, logger.Err(err))
+	}
+
+	return key.CompanyID, nil
+}
+
+// AuthenticateAdmin verifies an admin token and returns the admin ID
+func (uc *AuthUseCaseImpl) AuthenticateAdmin(ctx context.Context, token string) (string, error) {
+	claims, err := uc.tokens.Verify(token)
+	if err != nil || claims.Role != auth.RoleAdmin {
+		return "", ErrInvalidToken
+	}
+
+	// Tokens of deleted admins stop working before they expire
+	if _, err := uc.adminRepo.GetByID(ctx, claims.Subject); err != nil {
+		return "", ErrInvalidToken
+	}
+
+	return claims.Subject, nil
+}
+
+// getCompanyKey loads a key ensuring it belongs to the company
+func (uc *AuthUseCaseImpl) getCompanyKey(ctx context.Context, companyID, id string) (*entity.APIKey, error) {
+	key, err := uc.apiKeyRepo.GetByID(ctx, id)
+	if err != nil || key.CompanyID != companyID {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/payment"
+)
+
+// ErrPaymentGatewayNotFound is returned for the webhooks of a gateway other than the configured one
+var ErrPaymentGatewayNotFound = service.ErrPaymentGatewayNotFound
+
+// ErrInvalidPaymentSignature is returned when a payment webhook is not signed by the gateway
+var ErrInvalidPaymentSignature = payment.ErrInvalidSignature
+
+// BillingUseCase defines the interface for the payment gateway webhooks
+type BillingUseCase interface {
+	HandleWebhook(ctx context.Context, provider string, payload []byte, header http.Header) error
+}
+
+// BillingUseCaseImpl handles the payment gateway webhooks
+type BillingUseCaseImpl struct {
+	billing *service.BillingService
+}
+
+// NewBillingUseCase creates a new BillingUseCase
+func NewBillingUseCase(billing *service.BillingService) BillingUseCase {
+	return &BillingUseCaseImpl{
+		billing: billing,
+	}
+}
+
+// HandleWebhook settles the invoice of a payment confirmed or declined by the gateway
+func (uc *BillingUseCaseImpl) HandleWebhook(ctx context.Context, provider string, payload []byte, header http.Header) error {
+	return uc.billing.HandleWebhook(ctx, provider, payload, header)
+}
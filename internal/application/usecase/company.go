@@ -2,36 +2,81 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/email"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/signer"
 )
 
 // CompanyUseCase defines the interface for company operations
 type CompanyUseCase interface {
 	GetProfile(ctx context.Context, companyID string) (*dto.CompanyDTO, error)
 	UpdateProfile(ctx context.Context, company *dto.CompanyDTO) error
-	UpdateCertificate(ctx context.Context, companyID string, pfxData []byte, password string, expiresAt time.Time) error
+	UpdateCertificate(ctx context.Context, companyID string, pfxData []byte, password string) error
+	ConfigureKMSSigner(ctx context.Context, companyID string, req dto.ConfigureKMSSignerRequest) error
+	ConfigureRespTec(ctx context.Context, companyID string, req dto.ConfigureRespTecRequest) error
+	ClearRespTec(ctx context.Context, companyID string) error
+	ConfigureEmailTemplate(ctx context.Context, companyID string, req dto.EmailTemplateDTO) error
+	ClearEmailTemplate(ctx context.Context, companyID string) error
+	ConfigureEmissionSettings(ctx context.Context, companyID string, req dto.EmissionSettingsDTO) error
+	ClearEmissionSettings(ctx context.Context, companyID string) error
 	UpdateCSC(ctx context.Context, companyID, cscID, cscToken string, validUntil time.Time) error
+	ListCSCs(ctx context.Context, companyID string) ([]dto.CSCEntryDTO, error)
+	CreateCSC(ctx context.Context, companyID string, req dto.CSCEntryRequest) (*dto.CSCEntryDTO, error)
+	ReplaceCSC(ctx context.Context, companyID, cscID string, req dto.CSCEntryRequest) (*dto.CSCEntryDTO, error)
+	DeleteCSC(ctx context.Context, companyID, cscID string) error
+	Verify(ctx context.Context, companyID string) (*dto.CompanyVerificationResponse, error)
 }
 
+var (
+	// ErrCSCNotFound is returned when the company has no CSC with the given ID
+	ErrCSCNotFound = entity.ErrCSCNotFound
+	// ErrCertificatePassword is returned when the password does not open the uploaded PFX
+	ErrCertificatePassword = signer.ErrPFXPassword
+	// ErrCertificateUnsupported is returned when the PFX uses an unsupported encryption or MAC
+	ErrCertificateUnsupported = signer.ErrPFXUnsupported
+	// ErrCertificateInvalid is returned when the upload is not a PFX with an RSA key
+	ErrCertificateInvalid = signer.ErrPFXInvalid
+	// ErrCertificateExpired is returned when the uploaded certificate is past its validity
+	ErrCertificateExpired = entity.ErrCertificateExpired
+	// ErrCertificateCNPJMismatch is returned when the certificate belongs to another CNPJ root
+	ErrCertificateCNPJMismatch = entity.ErrCertificateCNPJMismatch
+	// ErrCompanyDeleted is returned when changing a company that was deleted
+	ErrCompanyDeleted = entity.ErrCompanyDeleted
+)
+
 // CompanyUseCaseImpl handles company operations
 type CompanyUseCaseImpl struct {
 	companyRepo      ports.CompanyRepository
 	subscriptionRepo ports.SubscriptionRepository
+	nfceDomain       *service.NFCeDomainService
+	verifier         *service.CompanyVerifier
+	secrets          ports.SecretCipher
+	municipios       *service.MunicipioService
 }
 
 // NewCompanyUseCase creates a new CompanyUseCase
 func NewCompanyUseCase(
 	companyRepo ports.CompanyRepository,
 	subscriptionRepo ports.SubscriptionRepository,
+	verifier *service.CompanyVerifier,
+	secrets ports.SecretCipher,
+	municipios *service.MunicipioService,
 ) CompanyUseCase {
 	return &CompanyUseCaseImpl{
 		companyRepo:      companyRepo,
 		subscriptionRepo: subscriptionRepo,
+		nfceDomain:       service.NewNFCeDomainService(),
+		verifier:         verifier,
+		secrets:          secrets,
+		municipios:       municipios,
 	}
 }
 
@@ -47,30 +92,400 @@ func (uc *CompanyUseCaseImpl) GetProfile(ctx context.Context, companyID string)
 
 // UpdateProfile updates the company profile
 func (uc *CompanyUseCaseImpl) UpdateProfile(ctx context.Context, company *dto.CompanyDTO) error {
-	return uc.companyRepo.Update(ctx, mapper.NewCompanyMapper().ToCompanyEntity(company))
+	entityCompany := mapper.NewCompanyMapper().ToCompanyEntity(company)
+	if err := entityCompany.Intermediadores.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCompany, invalid(err))
+	}
+	if err := entityCompany.FraudRules.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCompany, invalid(err))
+	}
+	if err := entityCompany.TaxRules.Validate(entityCompany.RegimeTributario); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCompany, invalid(err))
+	}
+	if err := entityCompany.DANFE.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCompany, invalid(err))
+	}
+	if err := resolveCompanyAddress(ctx, uc.municipios, &entityCompany.Endereco); err != nil {
+		return err
+	}
+
+	// The technical responsible is managed by ConfigureRespTec; the DTO never carries the CSRT.
+	// The e-mail template is managed by ConfigureEmailTemplate, the emission defaults by
+	// ConfigureEmissionSettings and the CSC registry by the CSC endpoints.
+	current, err := uc.companyRepo.GetByID(ctx, entityCompany.ID)
+	if err != nil {
+		return err
+	}
+	entityCompany.RespTec = current.RespTec
+	entityCompany.EmailTemplate = current.EmailTemplate
+	entityCompany.EmissionSettings = current.EmissionSettings
+	entityCompany.CSCs = current.CSCs
+
+	if err := uc.companyRepo.Update(ctx, entityCompany); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, entityCompany.ID, entity.NewAuditSnapshot(current), entityCompany)
+	return nil
+}
+
+// UpdateCertificate updates the company certificate. The PFX is opened first, so a wrong
+// password or an unsupported file is refused up front, and its subject, issuer, CNPJ and
+// expiry are read from the certificate. The PFX is stored encrypted.
+func (uc *CompanyUseCaseImpl) UpdateCertificate(ctx context.Context, companyID string, pfxData []byte, password string) error {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return ErrCompanyNotFound
+	}
+	if company.IsDeleted() {
+		return ErrCompanyDeleted
+	}
+	before := entity.NewAuditSnapshot(company)
+
+	bundle, err := signer.DecodePFX(pfxData, password)
+	if err != nil {
+		return err
+	}
+	cert := bundle.Certificate
+
+	sealed, err := uc.secrets.Seal(pfxData)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt certificate: %w", err)
+	}
+
+	err = company.UpdateCertificate(entity.CertificateTypeA1, sealed, password, entity.CertificateIdentity{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		CNPJ:      signer.CertificateCNPJ(cert),
+		ExpiresAt: cert.NotAfter,
+	})
+	if err != nil {
+		return invalid(err)
+	}
+
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
+}
+
+// ConfigureKMSSigner makes the company sign with a key kept in an HSM or cloud KMS. A test
+// digest is signed first so a wrong endpoint, key or certificate is refused up front.
+func (uc *CompanyUseCaseImpl) ConfigureKMSSigner(ctx context.Context, companyID string, req dto.ConfigureKMSSignerRequest) error {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return err
+	}
+	before := entity.NewAuditSnapshot(company)
+
+	certificatePEM := []byte(req.CertificatePEM)
+	cert, err := signer.ProbeKMSKey(ctx, signer.KMSKey{
+		Endpoint:       req.Endpoint,
+		KeyID:          req.KeyID,
+		Token:          req.Token,
+		CertificatePEM: certificatePEM,
+	})
+	if err != nil {
+		return invalid(fmt.Errorf("KMS key check failed: %w", err))
+	}
+
+	err = company.UseKMSSigner(req.Endpoint, req.KeyID, req.Token, certificatePEM, cert.Subject.String(), cert.NotAfter)
+	if err != nil {
+		return invalid(err)
+	}
+
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
+}
+
+// ConfigureRespTec sets the technical responsible injected as infRespTec on the company NFC-e.
+// Omitting the CSRT keeps the stored one when the responsible CNPJ and idCSRT do not change.
+func (uc *CompanyUseCaseImpl) ConfigureRespTec(ctx context.Context, companyID string, req dto.ConfigureRespTecRequest) error {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return err
+	}
+	before := entity.NewAuditSnapshot(company)
+
+	respTec := &entity.RespTec{
+		CNPJ:    req.CNPJ,
+		Contato: req.Contato,
+		Email:   req.Email,
+		Fone:    req.Fone,
+		IdCSRT:  req.IdCSRT,
+		CSRT:    req.CSRT,
+	}
+	if current := company.RespTec; current != nil && respTec.CSRT == "" &&
+		current.CNPJ == respTec.CNPJ && current.IdCSRT == respTec.IdCSRT {
+		respTec.CSRT = current.CSRT
+	}
+	if err := respTec.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	company.RespTec = respTec
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
+}
+
+// ClearRespTec removes the company technical responsible, falling back to the installation one
+func (uc *CompanyUseCaseImpl) ClearRespTec(ctx context.Context, companyID string) error {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return err
+	}
+	before := entity.NewAuditSnapshot(company)
+
+	company.RespTec = nil
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
+}
+
+// ConfigureEmailTemplate sets the message e-mailing the XML and DANFE to the consumer. The
+// templates are rendered once over empty data, so unknown fields are refused up front.
+func (uc *CompanyUseCaseImpl) ConfigureEmailTemplate(ctx context.Context, companyID string, req dto.EmailTemplateDTO) error {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return err
+	}
+	before := entity.NewAuditSnapshot(company)
+
+	template := entity.EmailTemplate(req)
+	if err := template.Validate(); err != nil {
+		return invalid(err)
+	}
+	if _, err := email.Render(&template, email.TemplateData{}); err != nil {
+		return invalid(err)
+	}
+
+	company.EmailTemplate = &template
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
+}
+
+// ClearEmailTemplate removes the company message, falling back to the default one
+func (uc *CompanyUseCaseImpl) ClearEmailTemplate(ctx context.Context, companyID string) error {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return err
+	}
+	before := entity.NewAuditSnapshot(company)
+
+	company.EmailTemplate = nil
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
 }
 
-// UpdateCertificate updates the company certificate
-func (uc *CompanyUseCaseImpl) UpdateCertificate(ctx context.Context, companyID string, pfxData []byte, password string, expiresAt time.Time) error {
+// ConfigureEmissionSettings sets the ide defaults of the company documents: natOp, série,
+// tpImp, the CFOP of the items by destination UF and verProc
+func (uc *CompanyUseCaseImpl) ConfigureEmissionSettings(ctx context.Context, companyID string, req dto.EmissionSettingsDTO) error {
 	company, err := uc.companyRepo.GetByID(ctx, companyID)
 	if err != nil {
 		return err
 	}
+	before := entity.NewAuditSnapshot(company)
+
+	settings := entity.CompanyEmissionSettings(req)
+	if err := settings.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	company.EmissionSettings = &settings
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
+}
 
-	err = company.UpdateCertificate(entity.CertificateTypeA1, pfxData, password, expiresAt)
+// ClearEmissionSettings removes the company emission defaults, falling back to the built-in ones
+func (uc *CompanyUseCaseImpl) ClearEmissionSettings(ctx context.Context, companyID string) error {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
 	if err != nil {
 		return err
 	}
+	before := entity.NewAuditSnapshot(company)
 
-	return uc.companyRepo.Update(ctx, company)
+	company.EmissionSettings = nil
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
 }
 
 // UpdateCSC updates the company CSC configuration
 func (uc *CompanyUseCaseImpl) UpdateCSC(ctx context.Context, companyID, cscID, cscToken string, validUntil time.Time) error {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return ErrCompanyNotFound
+	}
+	if company.IsDeleted() {
+		return ErrCompanyDeleted
+	}
+	before := entity.NewAuditSnapshot(company)
+
+	if err := company.UpdateCSC(cscID, cscToken, validUntil); err != nil {
+		return invalid(err)
+	}
+
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
+}
+
+// ListCSCs lists the company CSC registry; the tokens are never returned
+func (uc *CompanyUseCaseImpl) ListCSCs(ctx context.Context, companyID string) ([]dto.CSCEntryDTO, error) {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapper.NewCompanyMapper().ToCSCEntryDTOs(company.CSCs), nil
+}
+
+// CreateCSC registers a CSC for an environment; its activation window may overlap the current
+// one so a new CSC can be registered ahead of the rotation
+func (uc *CompanyUseCaseImpl) CreateCSC(ctx context.Context, companyID string, req dto.CSCEntryRequest) (*dto.CSCEntryDTO, error) {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	before := entity.NewAuditSnapshot(company)
+
+	entry, err := uc.newCSCEntry(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := company.AddCSC(*entry); err != nil {
+		return nil, invalid(err)
+	}
+
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return nil, err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return findCSCEntryDTO(company, entry.ID), nil
+}
+
+// ReplaceCSC replaces the token, environment or activation window of a registered CSC
+func (uc *CompanyUseCaseImpl) ReplaceCSC(ctx context.Context, companyID, cscID string, req dto.CSCEntryRequest) (*dto.CSCEntryDTO, error) {
+	company, err := uc.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	before := entity.NewAuditSnapshot(company)
+
+	entry, err := uc.newCSCEntry(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := company.ReplaceCSC(cscID, *entry); err != nil {
+		if errors.Is(err, ErrCSCNotFound) {
+			return nil, err
+		}
+		return nil, invalid(err)
+	}
+
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return nil, err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return findCSCEntryDTO(company, cscID), nil
+}
+
+// DeleteCSC removes a CSC from the company registry
+func (uc *CompanyUseCaseImpl) DeleteCSC(ctx context.Context, companyID, cscID string) error {
 	company, err := uc.companyRepo.GetByID(ctx, companyID)
 	if err != nil {
 		return err
 	}
+	before := entity.NewAuditSnapshot(company)
 
-	return company.UpdateCSC(cscID, cscToken, validUntil)
+	if err := company.RemoveCSC(cscID); err != nil {
+		return err
+	}
+	if err := uc.companyRepo.Update(ctx, company); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	return nil
+}
+
+// Verify runs the pre-flight checks of the company and returns its readiness report
+func (uc *CompanyUseCaseImpl) Verify(ctx context.Context, companyID string) (*dto.CompanyVerificationResponse, error) {
+	report, err := uc.verifier.Verify(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.CompanyVerificationResponse{
+		CompanyID:  report.CompanyID,
+		Ready:      report.Ready,
+		Checks:     make([]dto.ReadinessCheckDTO, len(report.Checks)),
+		VerifiedAt: report.VerifiedAt,
+	}
+	for i, check := range report.Checks {
+		response.Checks[i] = dto.ReadinessCheckDTO{
+			Name:    check.Name,
+			Status:  check.Status,
+			Message: check.Message,
+			Details: check.Details,
+		}
+	}
+	return response, nil
+}
+
+// newCSCEntry builds a registry entry from the request, checking the token format
+func (uc *CompanyUseCaseImpl) newCSCEntry(req dto.CSCEntryRequest) (*entity.CSCEntry, error) {
+	if err := uc.nfceDomain.ValidateCSC(req.CSCToken); err != nil {
+		return nil, invalid(err)
+	}
+
+	var validFrom time.Time
+	if req.ValidFrom != nil {
+		validFrom = *req.ValidFrom
+	}
+	entry, err := entity.NewCSCEntry(req.Ambiente, req.CSCID, req.CSCToken, validFrom, req.ValidUntil)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	return entry, nil
+}
+
+// resolveCompanyAddress fills the cMun of the company address; an address the IBGE table
+// contradicts is invalid company data
+func resolveCompanyAddress(ctx context.Context, municipios *service.MunicipioService, address *entity.Address) error {
+	err := municipios.ResolveAddress(ctx, address)
+	if errors.Is(err, service.ErrInvalidMunicipio) {
+		return fmt.Errorf("%w: %w", ErrInvalidCompany, invalid(err))
+	}
+	return err
+}
+
+// findCSCEntryDTO returns the registry entry with id as a DTO
+func findCSCEntryDTO(company *entity.Company, id string) *dto.CSCEntryDTO {
+	for _, entry := range mapper.NewCompanyMapper().ToCSCEntryDTOs(company.CSCs) {
+		if entry.ID == id {
+			return &entry
+		}
+	}
+	return nil
 }
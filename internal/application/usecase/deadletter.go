@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+)
+
+const (
+	// maxDeadLetterPeek caps the dead letters listed at once
+	maxDeadLetterPeek = 500
+	// defaultDeadLetterRequeue is how many dead letters a requeue moves when no limit is given
+	defaultDeadLetterRequeue = 100
+)
+
+// DeadLetterUseCase defines the interface for the admin inspection of the emission dead-letter queue
+type DeadLetterUseCase interface {
+	List(ctx context.Context, limit int) (*dto.DeadLetterListResponse, error)
+	Requeue(ctx context.Context, req dto.RequeueDeadLettersRequest) (*dto.RequeueDeadLettersResponse, error)
+}
+
+// DeadLetterUseCaseImpl handles the emission messages the worker gave up on
+type DeadLetterUseCaseImpl struct {
+	queue dto.DeadLetterQueue
+}
+
+// NewDeadLetterUseCase creates a new DeadLetterUseCase
+func NewDeadLetterUseCase(queue dto.DeadLetterQueue) DeadLetterUseCase {
+	return &DeadLetterUseCaseImpl{queue: queue}
+}
+
+// List returns the oldest dead letters without removing them from the queue
+func (uc *DeadLetterUseCaseImpl) List(ctx context.Context, limit int) (*dto.DeadLetterListResponse, error) {
+	if limit > maxDeadLetterPeek {
+		limit = maxDeadLetterPeek
+	}
+
+	letters, total, err := uc.queue.ListDeadLetters(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.DeadLetterListResponse{DeadLetters: letters, Total: total}, nil
+}
+
+// Requeue moves dead letters back to the emission queue with a fresh delivery count
+func (uc *DeadLetterUseCaseImpl) Requeue(ctx context.Context, req dto.RequeueDeadLettersRequest) (*dto.RequeueDeadLettersResponse, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultDeadLetterRequeue
+	}
+
+	requeued, err := uc.queue.RequeueDeadLetters(ctx, req.RequestID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.RequeueDeadLettersResponse{Requeued: requeued}, nil
+}
@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+)
+
+// ErrDFeDocumentNotFound is returned when the distributed document does not exist for the company
+var ErrDFeDocumentNotFound = errors.New("dfe document not found")
+
+// DFeUseCase defines the interface for NFeDistribuicaoDFe operations
+type DFeUseCase interface {
+	RequestSync(ctx context.Context, companyID string) (*dto.DFeStatusResponse, error)
+	GetStatus(ctx context.Context, companyID string) (*dto.DFeStatusResponse, error)
+	ListDocuments(ctx context.Context, companyID string, filter ports.DFeFilter, limit, offset int) (*dto.DFeDocumentListResponse, error)
+	GetDocument(ctx context.Context, companyID, id string) (*dto.DFeDocumentResponse, error)
+	GetDocumentXML(ctx context.Context, companyID, id string) ([]byte, error)
+}
+
+// DFeUseCaseImpl handles NFeDistribuicaoDFe operations
+type DFeUseCaseImpl struct {
+	dfeRepo   ports.DFeRepository
+	storage   storage.StorageService
+	dfeMapper *mapper.DFeMapper
+}
+
+// NewDFeUseCase creates a new DFeUseCase
+func NewDFeUseCase(dfeRepo ports.DFeRepository, storage storage.StorageService) DFeUseCase {
+	return &DFeUseCaseImpl{
+		dfeRepo:   dfeRepo,
+		storage:   storage,
+		dfeMapper: mapper.NewDFeMapper(),
+	}
+}
+
+// RequestSync makes the company cursor due, so the worker syncs it on its next run.
+// The first request creates the cursor, starting at NSU 0.
+func (uc *DFeUseCaseImpl) RequestSync(ctx context.Context, companyID string) (*dto.DFeStatusResponse, error) {
+	cursor, err := uc.dfeRepo.GetCursor(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dfe cursor: %w", err)
+	}
+
+	if cursor == nil {
+		cursor = entity.NewDFeCursor(companyID)
+	} else if err := cursor.RequestSync(); err != nil {
+		return nil, err
+	}
+
+	if err := uc.dfeRepo.SaveCursor(ctx, cursor); err != nil {
+		return nil, fmt.Errorf("failed to save dfe cursor: %w", err)
+	}
+
+	return uc.dfeMapper.ToDFeStatusResponse(cursor), nil
+}
+
+// GetStatus gets the distribution cursor of the company
+func (uc *DFeUseCaseImpl) GetStatus(ctx context.Context, companyID string) (*dto.DFeStatusResponse, error) {
+	cursor, err := uc.dfeRepo.GetCursor(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dfe cursor: %w", err)
+	}
+
+	if cursor == nil {
+		cursor = entity.NewDFeCursor(companyID)
+	}
+
+	return uc.dfeMapper.ToDFeStatusResponse(cursor), nil
+}
+
+// ListDocuments lists the documents distributed to the company, newest NSU first
+func (uc *DFeUseCaseImpl) ListDocuments(ctx context.Context, companyID string, filter ports.DFeFilter, limit, offset int) (*dto.DFeDocumentListResponse, error) {
+	documents, total, err := uc.dfeRepo.ListDocuments(ctx, companyID, filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.DFeDocumentResponse, len(documents))
+	for i, document := range documents {
+		responses[i] = *uc.dfeMapper.ToDFeDocumentResponse(document)
+	}
+
+	return &dto.DFeDocumentListResponse{
+		Documents: responses,
+		Total:     total,
+	}, nil
+}
+
+// GetDocument gets a distributed document of the company
+func (uc *DFeUseCaseImpl) GetDocument(ctx context.Context, companyID, id string) (*dto.DFeDocumentResponse, error) {
+	document, err := uc.dfeRepo.GetDocument(ctx, companyID, id)
+	if err != nil {
+		return nil, ErrDFeDocumentNotFound
+	}
+
+	return uc.dfeMapper.ToDFeDocumentResponse(document), nil
+}
+
+// GetDocumentXML downloads the XML of a distributed document of the company
+func (uc *DFeUseCaseImpl) GetDocumentXML(ctx context.Context, companyID, id string) ([]byte, error) {
+	document, err := uc.dfeRepo.GetDocument(ctx, companyID, id)
+	if err != nil {
+		return nil, ErrDFeDocumentNotFound
+	}
+
+	content, err := uc.storage.DownloadFile(ctx, "", document.XMLKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download dfe document: %w", err)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+)
+
+// ErrEmailDisabled is returned when no e-mail provider is configured
+var ErrEmailDisabled = service.ErrEmailDisabled
+
+// ErrEmailRecipientMissing is returned when the NFC-e has no consumer e-mail and none was given
+var ErrEmailRecipientMissing = service.ErrEmailRecipientMissing
+
+// EmailUseCase defines the interface for e-mailing the NFC-e documents to the consumer
+type EmailUseCase interface {
+	// SendEmail e-mails the XML and DANFE again; when the provider fails, the failed delivery
+	// is returned along with the error
+	SendEmail(ctx context.Context, companyID, id string, req dto.SendEmailRequest) (*dto.EmailDeliveryResponse, error)
+	ListEmails(ctx context.Context, companyID, id string) (*dto.EmailDeliveryListResponse, error)
+}
+
+// EmailUseCaseImpl handles the e-mails of the NFC-e documents
+type EmailUseCaseImpl struct {
+	nfceRepo    ports.NFCeRepository
+	emails      *service.EmailService
+	emailMapper *mapper.EmailMapper
+}
+
+// NewEmailUseCase creates a new EmailUseCase
+func NewEmailUseCase(nfceRepo ports.NFCeRepository, emails *service.EmailService) EmailUseCase {
+	return &EmailUseCaseImpl{
+		nfceRepo:    nfceRepo,
+		emails:      emails,
+		emailMapper: mapper.NewEmailMapper(),
+	}
+}
+
+// SendEmail e-mails an authorized NFC-e of the company, to the given address or the consumer e-mail
+func (uc *EmailUseCaseImpl) SendEmail(ctx context.Context, companyID, id string, req dto.SendEmailRequest) (*dto.EmailDeliveryResponse, error) {
+	nfce, err := uc.nfceRepo.GetByIDForCompany(ctx, companyID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFC-e: %w", err)
+	}
+	if nfce == nil {
+		return nil, ErrNFCeNotFound
+	}
+	if nfce.Status != entity.RequestStatusAuthorized {
+		return nil, ErrNFCeNotAuthorized
+	}
+	if nfce.DocumentsPurged() {
+		return nil, ErrDocumentsPurged
+	}
+
+	delivery, err := uc.emails.Resend(ctx, nfce, req.Email)
+	if delivery == nil {
+		return nil, err
+	}
+	return uc.emailMapper.ToDeliveryResponse(delivery), err
+}
+
+// ListEmails lists the send log of a company NFC-e, newest first
+func (uc *EmailUseCaseImpl) ListEmails(ctx context.Context, companyID, id string) (*dto.EmailDeliveryListResponse, error) {
+	nfce, err := uc.nfceRepo.GetByIDForCompany(ctx, companyID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFC-e: %w", err)
+	}
+	if nfce == nil {
+		return nil, ErrNFCeNotFound
+	}
+
+	deliveries, err := uc.emails.ListDeliveries(ctx, nfce.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.EmailDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		responses[i] = *uc.emailMapper.ToDeliveryResponse(delivery)
+	}
+	return &dto.EmailDeliveryListResponse{Deliveries: responses}, nil
+}
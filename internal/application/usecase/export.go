@@ -0,0 +1,182 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+)
+
+const (
+	// exportJobTTL is how long the parts of an export remain available for download
+	exportJobTTL = 72 * time.Hour
+	// exportStreamMaxItems is the largest export of GET /nfce/export streamed right away;
+	// larger periods run as an export job
+	exportStreamMaxItems = 500
+)
+
+var (
+	// ErrExportNotFound is returned when the export job does not exist for the company
+	ErrExportNotFound = errors.New("export not found")
+	// ErrExportExpired is returned when the export download window has passed
+	ErrExportExpired = errors.New("export has expired")
+	// ErrExportPartNotFound is returned when the requested part was not written yet
+	ErrExportPartNotFound = errors.New("export part not found")
+)
+
+// ExportUseCase defines the interface for bulk export operations
+type ExportUseCase interface {
+	CreateExport(ctx context.Context, companyID string, req dto.CreateExportRequest) (*dto.ExportJobResponse, error)
+	GetExport(ctx context.Context, companyID, id string) (*dto.ExportJobResponse, error)
+	ListExports(ctx context.Context, companyID string, limit, offset int) (*dto.ExportJobListResponse, error)
+	GetPartURL(ctx context.Context, companyID, id string, number int) (string, error)
+	ExportNFCe(ctx context.Context, companyID string, req dto.CreateExportRequest) (*dto.NFCeExport, error)
+}
+
+// ExportUseCaseImpl handles bulk export operations
+type ExportUseCaseImpl struct {
+	exportRepo    ports.ExportRepository
+	publisher     dto.Publisher
+	storage       storage.StorageService
+	exportService *service.ExportService
+	exportMapper  *mapper.ExportMapper
+}
+
+// NewExportUseCase creates a new ExportUseCase
+func NewExportUseCase(exportRepo ports.ExportRepository, publisher dto.Publisher, storage storage.StorageService, exportService *service.ExportService) ExportUseCase {
+	return &ExportUseCaseImpl{
+		exportRepo:    exportRepo,
+		publisher:     publisher,
+		storage:       storage,
+		exportService: exportService,
+		exportMapper:  mapper.NewExportMapper(),
+	}
+}
+
+// CreateExport creates an export job and enqueues it for processing
+func (uc *ExportUseCaseImpl) CreateExport(ctx context.Context, companyID string, req dto.CreateExportRequest) (*dto.ExportJobResponse, error) {
+	job, err := entity.NewExportJob(companyID, req.PeriodStart, req.PeriodEnd, exportJobTTL)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	job.IncludeIndex = req.IncludeIndex
+
+	if err := uc.exportRepo.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create export job: %w", err)
+	}
+
+	msg := dto.ExportMessage{
+		JobID:      job.ID,
+		EnqueuedAt: time.Now(),
+	}
+	if err := uc.publisher.PublishExport(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to publish export job: %w", err)
+	}
+
+	return uc.exportMapper.ToExportJobResponse(job), nil
+}
+
+// ExportNFCe exports the XMLs of the company NFC-e authorized within the period: up to
+// exportStreamMaxItems are zipped and streamed right away, larger periods create an export
+// job whose completion is announced by the export.completed webhook
+func (uc *ExportUseCaseImpl) ExportNFCe(ctx context.Context, companyID string, req dto.CreateExportRequest) (*dto.NFCeExport, error) {
+	if err := entity.ValidateExportPeriod(req.PeriodStart, req.PeriodEnd); err != nil {
+		return nil, invalid(err)
+	}
+
+	total, err := uc.exportService.Count(ctx, companyID, req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count NFC-e for export: %w", err)
+	}
+
+	if total > exportStreamMaxItems {
+		job, err := uc.CreateExport(ctx, companyID, req)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.NFCeExport{Job: job}, nil
+	}
+
+	// The zip is written while the client reads it; closing the reader stops the writer
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(uc.exportService.WriteZip(ctx, writer, companyID, req.PeriodStart, req.PeriodEnd, req.IncludeIndex))
+	}()
+
+	return &dto.NFCeExport{File: &dto.NFceFile{
+		Content:     reader,
+		Size:        -1,
+		ContentType: "application/zip",
+		Filename:    fmt.Sprintf("nfce-%s-%s.zip", req.PeriodStart.Format("20060102"), req.PeriodEnd.Format("20060102")),
+	}}, nil
+}
+
+// GetExport gets an export job with its progress
+func (uc *ExportUseCaseImpl) GetExport(ctx context.Context, companyID, id string) (*dto.ExportJobResponse, error) {
+	job, err := uc.getCompanyJob(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.exportMapper.ToExportJobResponse(job), nil
+}
+
+// ListExports lists the export jobs of a company
+func (uc *ExportUseCaseImpl) ListExports(ctx context.Context, companyID string, limit, offset int) (*dto.ExportJobListResponse, error) {
+	jobs, total, err := uc.exportRepo.ListByCompanyID(ctx, companyID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	exports := make([]dto.ExportJobResponse, len(jobs))
+	for i, job := range jobs {
+		exports[i] = *uc.exportMapper.ToExportJobResponse(job)
+	}
+
+	return &dto.ExportJobListResponse{
+		Exports: exports,
+		Total:   total,
+	}, nil
+}
+
+// GetPartURL returns the storage URL of an export part, which supports Range requests
+func (uc *ExportUseCaseImpl) GetPartURL(ctx context.Context, companyID, id string, number int) (string, error) {
+	job, err := uc.getCompanyJob(ctx, companyID, id)
+	if err != nil {
+		return "", err
+	}
+
+	if job.IsExpired() {
+		return "", ErrExportExpired
+	}
+
+	part, ok := job.GetPart(number)
+	if !ok {
+		return "", ErrExportPartNotFound
+	}
+
+	url, err := uc.storage.GetFileURL(ctx, "", part.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to get export part URL: %w", err)
+	}
+
+	return url, nil
+}
+
+// getCompanyJob loads an export job ensuring it belongs to the company
+func (uc *ExportUseCaseImpl) getCompanyJob(ctx context.Context, companyID, id string) (*entity.ExportJob, error) {
+	job, err := uc.exportRepo.GetByID(ctx, id)
+	if err != nil || job.CompanyID != companyID {
+		return nil, ErrExportNotFound
+	}
+
+	return job, nil
+}
@@ -0,0 +1,433 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/graphql"
+)
+
+const (
+	// graphQLDefaultPage and graphQLMaxPage bound the first argument of the listings
+	graphQLDefaultPage = 20
+	graphQLMaxPage     = 100
+	// graphQLUsageDefaultDays is the usage period when from is not given
+	graphQLUsageDefaultDays = 30
+	// graphQLUsageMaxRange caps the usage period, as the admin dashboard does
+	graphQLUsageMaxRange = 366 * 24 * time.Hour
+)
+
+var (
+	// ErrGraphQLForbidden is reported for a field the viewer may not read
+	ErrGraphQLForbidden = errors.New("not allowed to read this field")
+	// ErrGraphQLInvalidPage is reported for a first argument out of range
+	ErrGraphQLInvalidPage = fmt.Errorf("first must be between 1 and %d", graphQLMaxPage)
+	// ErrGraphQLInvalidPeriod is reported for a usage period out of range
+	ErrGraphQLInvalidPeriod = errors.New("from must be before to, at most 366 days apart")
+)
+
+// GraphQLConfig controls the GraphQL endpoint
+type GraphQLConfig struct {
+	MaxDepth         int
+	PersistedQueries int // Persisted queries kept in memory, 0 disables them
+}
+
+// GraphQLViewer is who runs a GraphQL query: a company, through its API key, or an admin
+type GraphQLViewer struct {
+	CompanyID string
+	AdminID   string
+}
+
+// IsAdmin reports whether the viewer is an admin, who reads every company
+func (v GraphQLViewer) IsAdmin() bool {
+	return v.AdminID != ""
+}
+
+// GraphQLUseCase runs the read-only GraphQL queries over the NFC-e, their events, the
+// companies and the usage
+type GraphQLUseCase interface {
+	Execute(ctx context.Context, viewer GraphQLViewer, req dto.GraphQLRequest) *graphql.Response
+	// Schema returns the schema in the GraphQL schema definition language
+	Schema() string
+}
+
+// graphQLUseCase resolves the queries with the repositories; the loaders of a request batch
+// the company, event and usage reads of sibling objects
+type graphQLUseCase struct {
+	nfceRepo    ports.NFCeRepository
+	companyRepo ports.CompanyRepository
+	schema      *graphql.Schema
+	persisted   *graphql.PersistedQueries
+	config      GraphQLConfig
+}
+
+// NewGraphQLUseCase creates a new GraphQLUseCase
+func NewGraphQLUseCase(nfceRepo ports.NFCeRepository, companyRepo ports.CompanyRepository, config GraphQLConfig) (GraphQLUseCase, error) {
+	uc := &graphQLUseCase{
+		nfceRepo:    nfceRepo,
+		companyRepo: companyRepo,
+		persisted:   graphql.NewPersistedQueries(config.PersistedQueries),
+		config:      config,
+	}
+
+	schema, err := graphql.NewSchema(uc.queryType())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+	uc.schema = schema
+	return uc, nil
+}
+
+// Execute runs the query of the request for the viewer
+func (uc *graphQLUseCase) Execute(ctx context.Context, viewer GraphQLViewer, req dto.GraphQLRequest) *graphql.Response {
+	query, gqlErr := uc.persisted.Resolve(req.Query, req.Extensions)
+	if gqlErr != nil {
+		return &graphql.Response{Errors: []*graphql.Error{gqlErr}}
+	}
+	if query == "" {
+		return &graphql.Response{Errors: []*graphql.Error{{Message: "query is required"}}}
+	}
+
+	ctx = context.WithValue(ctx, graphQLViewerKey{}, viewer)
+	ctx = context.WithValue(ctx, graphQLLoadersKey{}, uc.newLoaders(ctx))
+	return graphql.Execute(ctx, graphql.Params{
+		Schema:        uc.schema,
+		Query:         query,
+		OperationName: req.OperationName,
+		Variables:     req.Variables,
+		MaxDepth:      uc.config.MaxDepth,
+	})
+}
+
+// Schema returns the schema in the GraphQL schema definition language
+func (uc *graphQLUseCase) Schema() string {
+	return uc.schema.SDL()
+}
+
+type graphQLViewerKey struct{}
+
+type graphQLLoadersKey struct{}
+
+// viewerOf returns the viewer of the query running in ctx
+func viewerOf(ctx context.Context) GraphQLViewer {
+	viewer, _ := ctx.Value(graphQLViewerKey{}).(GraphQLViewer)
+	return viewer
+}
+
+// usageKey is the usage of a company in a period; an empty CompanyID is every company
+type usageKey struct {
+	CompanyID string
+	From      time.Time
+	To        time.Time
+}
+
+// graphQLLoaders batch the reads of one query
+type graphQLLoaders struct {
+	companies *graphql.Loader[string, *entity.Company]
+	events    *graphql.Loader[string, []*entity.Event]
+	usage     *graphql.Loader[usageKey, entity.CompanyStats]
+}
+
+// loadersOf returns the loaders of the query running in ctx
+func loadersOf(ctx context.Context) *graphQLLoaders {
+	return ctx.Value(graphQLLoadersKey{}).(*graphQLLoaders)
+}
+
+func (uc *graphQLUseCase) newLoaders(ctx context.Context) *graphQLLoaders {
+	return &graphQLLoaders{
+		companies: graphql.NewLoader(ctx, uc.loadCompanies),
+		events:    graphql.NewLoader(ctx, uc.loadEvents),
+		usage:     graphql.NewLoader(ctx, uc.loadUsage),
+	}
+}
+
+// loadCompanies reads the companies of the keys in one query
+func (uc *graphQLUseCase) loadCompanies(ctx context.Context, ids []string) (map[string]*entity.Company, error) {
+	companies, err := uc.companyRepo.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load companies: %w", err)
+	}
+	byID := make(map[string]*entity.Company, len(companies))
+	for _, company := range companies {
+		byID[company.ID] = company
+	}
+	return byID, nil
+}
+
+// loadEvents reads the events of the NFC-e of the keys in one query
+func (uc *graphQLUseCase) loadEvents(ctx context.Context, requestIDs []string) (map[string][]*entity.Event, error) {
+	events, err := uc.nfceRepo.ListEventsByRequestIDs(ctx, requestIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events: %w", err)
+	}
+	byRequest := make(map[string][]*entity.Event, len(requestIDs))
+	for _, requestID := range requestIDs {
+		byRequest[requestID] = []*entity.Event{}
+	}
+	for _, event := range events {
+		byRequest[event.RequestID] = append(byRequest[event.RequestID], event)
+	}
+	return byRequest, nil
+}
+
+// loadUsage counts the NFC-e of the keys with one query per period: the counts of a single
+// company are filtered by it, the others are picked from the counts of every company
+func (uc *graphQLUseCase) loadUsage(ctx context.Context, keys []usageKey) (map[usageKey]entity.CompanyStats, error) {
+	type period struct{ from, to time.Time }
+	byPeriod := map[period][]usageKey{}
+	var periods []period
+	for _, key := range keys {
+		p := period{from: key.From, to: key.To}
+		if _, seen := byPeriod[p]; !seen {
+			periods = append(periods, p)
+		}
+		byPeriod[p] = append(byPeriod[p], key)
+	}
+
+	usage := make(map[usageKey]entity.CompanyStats, len(keys))
+	for _, p := range periods {
+		periodKeys := byPeriod[p]
+		filter := ports.NFCeStatsFilter{From: p.from, To: p.to}
+		if len(periodKeys) == 1 {
+			filter.CompanyID = periodKeys[0].CompanyID
+		}
+		stats, err := uc.nfceRepo.GetCompanyStats(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count NFC-e by company: %w", err)
+		}
+
+		var all entity.CompanyStats
+		byCompany := make(map[string]entity.CompanyStats, len(stats))
+		for _, s := range stats {
+			byCompany[s.CompanyID] = s
+			all.Total += s.Total
+			all.Authorized += s.Authorized
+			all.Rejected += s.Rejected
+			all.Canceled += s.Canceled
+			all.Billable += s.Billable
+			all.Valor += s.Valor
+		}
+		for _, key := range periodKeys {
+			if key.CompanyID == "" {
+				usage[key] = all
+				continue
+			}
+			s := byCompany[key.CompanyID]
+			s.CompanyID = key.CompanyID
+			usage[key] = s
+		}
+	}
+	return usage, nil
+}
+
+// adminOnly restricts a field to the admins
+func adminOnly(ctx context.Context, _ interface{}) error {
+	if !viewerOf(ctx).IsAdmin() {
+		return ErrGraphQLForbidden
+	}
+	return nil
+}
+
+// ownCompanyOrAdmin restricts a field of a company to the company itself and the admins
+func ownCompanyOrAdmin(ctx context.Context, source interface{}) error {
+	viewer := viewerOf(ctx)
+	if company, ok := source.(*entity.Company); ok && (viewer.IsAdmin() || company.ID == viewer.CompanyID) {
+		return nil
+	}
+	return ErrGraphQLForbidden
+}
+
+// scopeCompany returns the company whose data the viewer reads: a company reads only its own,
+// an admin the requested one, or every company when none is requested
+func scopeCompany(viewer GraphQLViewer, requested string) (string, error) {
+	if viewer.IsAdmin() {
+		return requested, nil
+	}
+	if requested != "" && requested != viewer.CompanyID {
+		return "", ErrGraphQLForbidden
+	}
+	return viewer.CompanyID, nil
+}
+
+// pageSize returns the first argument, checked against the maximum page
+func pageSize(args map[string]interface{}) (int, error) {
+	first, _ := args["first"].(int)
+	if first < 1 || first > graphQLMaxPage {
+		return 0, ErrGraphQLInvalidPage
+	}
+	return first, nil
+}
+
+// usagePeriod returns the period of the from and to arguments, the last 30 days by default
+func usagePeriod(args map[string]interface{}) (time.Time, time.Time, error) {
+	to := time.Now()
+	if t, ok := args["to"].(time.Time); ok {
+		to = t
+	}
+	from := to.AddDate(0, 0, -graphQLUsageDefaultDays)
+	if t, ok := args["from"].(time.Time); ok {
+		from = t
+	}
+	if !from.Before(to) || to.Sub(from) > graphQLUsageMaxRange {
+		return from, to, ErrGraphQLInvalidPeriod
+	}
+	return from, to, nil
+}
+
+// nfceConnection is a page of NFC-e
+type nfceConnection struct {
+	nodes      []*entity.NFCE
+	total      int
+	nextCursor string
+}
+
+// companyConnection is a page of companies
+type companyConnection struct {
+	nodes []*entity.Company
+	total int
+}
+
+// usageStats is the usage of a company, or of every company, in a period
+type usageStats struct {
+	key   usageKey
+	stats entity.CompanyStats
+}
+
+// filter returns the stats filter of the usage
+func (u *usageStats) filter() ports.NFCeStatsFilter {
+	return ports.NFCeStatsFilter{CompanyID: u.key.CompanyID, From: u.key.From, To: u.key.To}
+}
+
+// resolveNFCe returns the NFC-e of the id argument, nil when the viewer cannot read it
+func (uc *graphQLUseCase) resolveNFCe(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+	viewer := viewerOf(p.Context)
+	if viewer.IsAdmin() {
+		nfce, err := uc.nfceRepo.GetByID(p.Context, id)
+		if err != nil {
+			return nil, nil // Not found, as the admin company lookup reports it
+		}
+		return nfce, nil
+	}
+
+	nfce, err := uc.nfceRepo.GetByIDForCompany(p.Context, viewer.CompanyID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFC-e: %w", err)
+	}
+	return nfce, nil
+}
+
+// resolveNFCes lists a page of the NFC-e, as GET /api/v1/nfce does
+func (uc *graphQLUseCase) resolveNFCes(p graphql.ResolveParams) (interface{}, error) {
+	requested, _ := p.Args["companyId"].(string)
+	companyID, err := scopeCompany(viewerOf(p.Context), requested)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := pageSize(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter ports.NFCeFilter
+	if status, ok := p.Args["status"].(entity.RequestStatus); ok {
+		filter.Status = status
+	}
+	if from, ok := p.Args["from"].(time.Time); ok {
+		filter.From = &from
+	}
+	if to, ok := p.Args["to"].(time.Time); ok {
+		filter.To = &to
+	}
+	filter.ChaveAcesso, _ = p.Args["chaveAcesso"].(string)
+	if after, _ := p.Args["after"].(string); after != "" {
+		if filter.After, err = decodeNFCeCursor(after); err != nil {
+			return nil, err
+		}
+	}
+
+	// One more than the page tells whether there is a next one
+	requests, total, err := uc.nfceRepo.ListByCompany(p.Context, companyID, filter, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list NFC-es: %w", err)
+	}
+
+	connection := &nfceConnection{nodes: requests, total: total}
+	if len(requests) > limit {
+		connection.nodes = requests[:limit]
+		last := requests[limit-1]
+		connection.nextCursor, err = encodeNFCeCursor(ports.NFCeCursor{CreatedAt: last.CreatedAt, Valor: last.VNF, ID: last.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode cursor: %w", err)
+		}
+	}
+	return connection, nil
+}
+
+// resolveCompany returns the company of the id argument, the own company of a company viewer
+// when not given
+func (uc *graphQLUseCase) resolveCompany(p graphql.ResolveParams) (interface{}, error) {
+	requested, _ := p.Args["id"].(string)
+	viewer := viewerOf(p.Context)
+	if viewer.IsAdmin() && requested == "" {
+		return nil, errors.New("id is required")
+	}
+	if !viewer.IsAdmin() && requested != "" && requested != viewer.CompanyID {
+		return nil, nil
+	}
+	if requested == "" {
+		requested = viewer.CompanyID
+	}
+	return loadersOf(p.Context).companies.Load(requested), nil
+}
+
+// resolveCompanies lists a page of the companies, admins only
+func (uc *graphQLUseCase) resolveCompanies(p graphql.ResolveParams) (interface{}, error) {
+	limit, err := pageSize(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	offset, _ := p.Args["offset"].(int)
+	if offset < 0 {
+		return nil, errors.New("offset must be >= 0")
+	}
+
+	companies, total, err := uc.companyRepo.List(p.Context, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list companies: %w", err)
+	}
+	return &companyConnection{nodes: companies, total: total}, nil
+}
+
+// resolveUsage counts the NFC-e of a company, or of every company for an admin, in the period
+func (uc *graphQLUseCase) resolveUsage(p graphql.ResolveParams) (interface{}, error) {
+	requested, _ := p.Args["companyId"].(string)
+	companyID, err := scopeCompany(viewerOf(p.Context), requested)
+	if err != nil {
+		return nil, err
+	}
+	return uc.loadUsageStats(p, companyID)
+}
+
+// loadUsageStats queues the usage of the company in the period of the arguments
+func (uc *graphQLUseCase) loadUsageStats(p graphql.ResolveParams, companyID string) (interface{}, error) {
+	from, to, err := usagePeriod(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	key := usageKey{CompanyID: companyID, From: from, To: to}
+	thunk := loadersOf(p.Context).usage.Load(key)
+	return graphql.Thunk(func() (interface{}, error) {
+		stats, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		return &usageStats{key: key, stats: stats.(entity.CompanyStats)}, nil
+	}), nil
+}
@@ -0,0 +1,425 @@
+package usecase
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/graphql"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// graphQLTime is a date-time in RFC 3339, as the REST API writes them
+var graphQLTime = &graphql.Scalar{
+	Name:        "Time",
+	Description: "An RFC 3339 date-time, such as 2026-01-31T00:00:00Z.",
+	Serialize: func(value interface{}) (interface{}, error) {
+		switch t := value.(type) {
+		case time.Time:
+			return t.Format(time.RFC3339Nano), nil
+		case *time.Time:
+			return t.Format(time.RFC3339Nano), nil
+		}
+		return nil, fmt.Errorf("Time cannot represent %v", value)
+	},
+	Parse: func(value interface{}) (interface{}, error) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("Time cannot represent %v", value)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("Time must be an RFC 3339 date-time, e.g. 2026-01-31T00:00:00Z")
+		}
+		return t, nil
+	},
+}
+
+// graphQLDecimal is a monetary amount, written as a string so clients do not round it
+var graphQLDecimal = &graphql.Scalar{
+	Name:        "Decimal",
+	Description: "A monetary amount in reais with two decimal places, as a string such as \"10.50\".",
+	Serialize: func(value interface{}) (interface{}, error) {
+		amount, ok := value.(money.Amount)
+		if !ok {
+			return nil, fmt.Errorf("Decimal cannot represent %v", value)
+		}
+		return amount.String(), nil
+	},
+	Parse: func(value interface{}) (interface{}, error) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("Decimal cannot represent %v", value)
+		}
+		return money.Parse(s)
+	},
+}
+
+// graphQLStatus lists the NFC-e statuses, named as the REST API names them in upper case
+var graphQLStatus = func() *graphql.Enum {
+	enum := &graphql.Enum{Name: "NFCeStatus", Description: "Status of an NFC-e request."}
+	for _, status := range []entity.RequestStatus{
+		entity.RequestStatusPending,
+		entity.RequestStatusQueuedDeferred,
+		entity.RequestStatusProcessing,
+		entity.RequestStatusAuthorized,
+		entity.RequestStatusRejected,
+		entity.RequestStatusRetrying,
+		entity.RequestStatusContingency,
+		entity.RequestStatusPendingTransmission,
+		entity.RequestStatusCanceled,
+	} {
+		enum.Values = append(enum.Values, &graphql.EnumValue{Name: strings.ToUpper(string(status)), Value: status})
+	}
+	return enum
+}()
+
+// nonNull and listOf shorten the type expressions of the schema
+func nonNull(t graphql.Type) graphql.Type { return &graphql.NonNull{Of: t} }
+
+func listOf(t graphql.Type) graphql.Type { return &graphql.NonNull{Of: &graphql.List{Of: nonNull(t)}} }
+
+// field is a field resolved from the source of type S
+func field[S any](name string, t graphql.Type, resolve func(source S) interface{}) *graphql.FieldDefinition {
+	return &graphql.FieldDefinition{
+		Name: name,
+		Type: t,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return resolve(p.Source.(S)), nil
+		},
+	}
+}
+
+// optional returns nil for the empty string, so absent values read as null
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// usagePeriodArgs are the arguments of the usage fields
+func usagePeriodArgs() []*graphql.ArgumentDefinition {
+	return []*graphql.ArgumentDefinition{
+		{Name: "from", Type: graphQLTime, Description: "Start of the period, 30 days before to by default."},
+		{Name: "to", Type: graphQLTime, Description: "End of the period (exclusive), now by default."},
+	}
+}
+
+// queryType builds the types of the schema, rooted at Query
+func (uc *graphQLUseCase) queryType() *graphql.Object {
+	addressType := &graphql.Object{
+		Name: "Address",
+		Fields: []*graphql.FieldDefinition{
+			field("logradouro", nonNull(graphql.String), func(a entity.Address) interface{} { return a.Logradouro }),
+			field("numero", nonNull(graphql.String), func(a entity.Address) interface{} { return a.Numero }),
+			field("complemento", graphql.String, func(a entity.Address) interface{} { return optional(a.Complemento) }),
+			field("bairro", nonNull(graphql.String), func(a entity.Address) interface{} { return a.Bairro }),
+			field("codigoMunicipio", nonNull(graphql.String), func(a entity.Address) interface{} { return a.CodigoMunicipio }),
+			field("municipio", nonNull(graphql.String), func(a entity.Address) interface{} { return a.Municipio }),
+			field("uf", nonNull(graphql.String), func(a entity.Address) interface{} { return a.UF }),
+			field("cep", nonNull(graphql.String), func(a entity.Address) interface{} { return a.CEP }),
+		},
+	}
+
+	dailyUsageType := &graphql.Object{
+		Name:        "DailyUsage",
+		Description: "NFC-e created on a day, Brasília time.",
+		Fields: []*graphql.FieldDefinition{
+			field("date", nonNull(graphql.String), func(d entity.DailyStats) interface{} { return d.Date.Format("2006-01-02") }),
+			field("total", nonNull(graphql.Int), func(d entity.DailyStats) interface{} { return d.Total }),
+			field("authorized", nonNull(graphql.Int), func(d entity.DailyStats) interface{} { return d.Authorized }),
+			field("rejected", nonNull(graphql.Int), func(d entity.DailyStats) interface{} { return d.Rejected }),
+			field("canceled", nonNull(graphql.Int), func(d entity.DailyStats) interface{} { return d.Canceled }),
+			field("valor", nonNull(graphQLDecimal), func(d entity.DailyStats) interface{} { return d.Valor }),
+		},
+	}
+
+	statusCountType := &graphql.Object{
+		Name: "StatusCount",
+		Fields: []*graphql.FieldDefinition{
+			field("status", nonNull(graphQLStatus), func(c statusCount) interface{} { return c.status }),
+			field("count", nonNull(graphql.Int), func(c statusCount) interface{} { return c.count }),
+		},
+	}
+
+	usageType := &graphql.Object{
+		Name:        "UsageStats",
+		Description: "NFC-e created in a period. Billable are the authorized or canceled NFC-e of production.",
+		Fields: []*graphql.FieldDefinition{
+			field("from", nonNull(graphQLTime), func(u *usageStats) interface{} { return u.key.From }),
+			field("to", nonNull(graphQLTime), func(u *usageStats) interface{} { return u.key.To }),
+			field("total", nonNull(graphql.Int), func(u *usageStats) interface{} { return u.stats.Total }),
+			field("authorized", nonNull(graphql.Int), func(u *usageStats) interface{} { return u.stats.Authorized }),
+			field("rejected", nonNull(graphql.Int), func(u *usageStats) interface{} { return u.stats.Rejected }),
+			field("canceled", nonNull(graphql.Int), func(u *usageStats) interface{} { return u.stats.Canceled }),
+			field("billable", nonNull(graphql.Int), func(u *usageStats) interface{} { return u.stats.Billable }),
+			field("valor", nonNull(graphQLDecimal), func(u *usageStats) interface{} { return u.stats.Valor }),
+			{
+				Name:        "byStatus",
+				Description: "Count of every status, zero ones included.",
+				Type:        listOf(statusCountType),
+				Resolve:     uc.resolveStatusCounts,
+			},
+			{
+				Name:    "daily",
+				Type:    listOf(dailyUsageType),
+				Resolve: uc.resolveDailyUsage,
+			},
+		},
+	}
+
+	companyType := &graphql.Object{
+		Name: "Company",
+		Fields: []*graphql.FieldDefinition{
+			field("id", nonNull(graphql.ID), func(c *entity.Company) interface{} { return c.ID }),
+			field("cnpj", nonNull(graphql.String), func(c *entity.Company) interface{} { return c.CNPJ }),
+			field("razaoSocial", nonNull(graphql.String), func(c *entity.Company) interface{} { return c.RazaoSocial }),
+			field("nomeFantasia", graphql.String, func(c *entity.Company) interface{} { return optional(c.NomeFantasia) }),
+			field("regimeTributario", nonNull(graphql.String), func(c *entity.Company) interface{} { return string(c.RegimeTributario) }),
+			field("status", nonNull(graphql.String), func(c *entity.Company) interface{} { return string(c.Status) }),
+			field("createdAt", nonNull(graphQLTime), func(c *entity.Company) interface{} { return c.CreatedAt }),
+			field("updatedAt", nonNull(graphQLTime), func(c *entity.Company) interface{} { return c.UpdatedAt }),
+		},
+	}
+	// Contact and certificate data, and the usage, are read by the company and the admins only
+	for _, restricted := range []*graphql.FieldDefinition{
+		field("inscricaoEstadual", graphql.String, func(c *entity.Company) interface{} { return optional(c.InscricaoEstadual) }),
+		field("email", nonNull(graphql.String), func(c *entity.Company) interface{} { return c.Email }),
+		field("endereco", nonNull(addressType), func(c *entity.Company) interface{} { return c.Endereco }),
+		field("certificateExpiresAt", graphQLTime, func(c *entity.Company) interface{} {
+			if c.Certificado.ExpiresAt.IsZero() {
+				return nil
+			}
+			return c.Certificado.ExpiresAt
+		}),
+		{
+			Name: "usage",
+			Type: nonNull(usageType),
+			Args: usagePeriodArgs(),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return uc.loadUsageStats(p, p.Source.(*entity.Company).ID)
+			},
+		},
+	} {
+		restricted.Authorize = ownCompanyOrAdmin
+		companyType.Fields = append(companyType.Fields, restricted)
+	}
+
+	metadataEntryType := &graphql.Object{
+		Name:        "MetadataEntry",
+		Description: "A key/value pair of the integrator metadata of an NFC-e.",
+		Fields: []*graphql.FieldDefinition{
+			field("key", nonNull(graphql.String), func(e metadataEntry) interface{} { return e.key }),
+			field("value", nonNull(graphql.String), func(e metadataEntry) interface{} { return e.value }),
+		},
+	}
+
+	eventType := &graphql.Object{
+		Name:        "NFCeEvent",
+		Description: "A status change or note of an NFC-e, in sequence order.",
+		Fields: []*graphql.FieldDefinition{
+			field("id", nonNull(graphql.ID), func(e *entity.Event) interface{} { return e.ID }),
+			field("statusFrom", graphQLStatus, func(e *entity.Event) interface{} {
+				if e.StatusFrom == "" {
+					return nil // Creation of the request
+				}
+				return e.StatusFrom
+			}),
+			field("statusTo", nonNull(graphQLStatus), func(e *entity.Event) interface{} { return e.StatusTo }),
+			field("cstat", graphql.String, func(e *entity.Event) interface{} { return optional(e.CStat) }),
+			field("message", graphql.String, func(e *entity.Event) interface{} { return optional(e.Message) }),
+			field("createdAt", nonNull(graphQLTime), func(e *entity.Event) interface{} { return e.CreatedAt }),
+			{
+				Name:        "sefazExchanges",
+				Description: "SEFAZ exchanges archived with the event, downloaded from GET /api/admin/nfce/{id}/sefaz-exchanges. Admins only.",
+				Type:        listOf(graphql.ID),
+				Authorize:   adminOnly,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return sefazExchangesOf(p.Source.(*entity.Event)), nil
+				},
+			},
+		},
+	}
+
+	nfceType := &graphql.Object{
+		Name: "NFCe",
+		Fields: []*graphql.FieldDefinition{
+			field("id", nonNull(graphql.ID), func(n *entity.NFCE) interface{} { return n.ID }),
+			field("companyId", nonNull(graphql.ID), func(n *entity.NFCE) interface{} { return n.CompanyID }),
+			{
+				Name: "company",
+				Type: companyType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersOf(p.Context).companies.Load(p.Source.(*entity.NFCE).CompanyID), nil
+				},
+			},
+			field("status", nonNull(graphQLStatus), func(n *entity.NFCE) interface{} { return n.Status }),
+			field("idempotencyKey", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.IdempotencyKey) }),
+			field("loteId", graphql.ID, func(n *entity.NFCE) interface{} {
+				if n.LoteID == nil {
+					return nil
+				}
+				return *n.LoteID
+			}),
+			field("ambiente", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.Payload.Ambiente) }),
+			field("chaveAcesso", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.ChaveAcesso) }),
+			field("protocolo", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.Protocolo) }),
+			field("numero", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.Numero) }),
+			field("serie", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.Serie) }),
+			field("valor", nonNull(graphQLDecimal), func(n *entity.NFCE) interface{} { return n.VNF }),
+			field("cstat", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.CStat) }),
+			field("xmotivo", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.XMotivo) }),
+			field("rejectionCode", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.RejectionCode) }),
+			field("rejectionMessage", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.RejectionMsg) }),
+			field("inContingency", nonNull(graphql.Boolean), func(n *entity.NFCE) interface{} { return n.InContingency }),
+			field("contingencyType", graphql.String, func(n *entity.NFCE) interface{} { return optional(n.ContingencyType) }),
+			field("retryCount", nonNull(graphql.Int), func(n *entity.NFCE) interface{} { return n.RetryCount }),
+			field("metadata", listOf(metadataEntryType), func(n *entity.NFCE) interface{} { return metadataEntries(n.Metadata) }),
+			field("processedAt", graphQLTime, func(n *entity.NFCE) interface{} { return n.ProcessedAt }),
+			field("authorizedAt", graphQLTime, func(n *entity.NFCE) interface{} { return n.AuthorizedAt }),
+			field("canceledAt", graphQLTime, func(n *entity.NFCE) interface{} { return n.CanceledAt }),
+			field("documentsPurgedAt", graphQLTime, func(n *entity.NFCE) interface{} { return n.DocumentsPurgedAt }),
+			field("createdAt", nonNull(graphQLTime), func(n *entity.NFCE) interface{} { return n.CreatedAt }),
+			field("updatedAt", nonNull(graphQLTime), func(n *entity.NFCE) interface{} { return n.UpdatedAt }),
+			{
+				Name: "events",
+				Type: listOf(eventType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersOf(p.Context).events.Load(p.Source.(*entity.NFCE).ID), nil
+				},
+			},
+		},
+	}
+
+	nfceConnectionType := &graphql.Object{
+		Name: "NFCeConnection",
+		Fields: []*graphql.FieldDefinition{
+			field("nodes", listOf(nfceType), func(c *nfceConnection) interface{} { return c.nodes }),
+			field("totalCount", nonNull(graphql.Int), func(c *nfceConnection) interface{} { return c.total }),
+			field("nextCursor", graphql.String, func(c *nfceConnection) interface{} { return optional(c.nextCursor) }),
+		},
+	}
+
+	companyConnectionType := &graphql.Object{
+		Name: "CompanyConnection",
+		Fields: []*graphql.FieldDefinition{
+			field("nodes", listOf(companyType), func(c *companyConnection) interface{} { return c.nodes }),
+			field("totalCount", nonNull(graphql.Int), func(c *companyConnection) interface{} { return c.total }),
+		},
+	}
+
+	return &graphql.Object{
+		Name: "Query",
+		Fields: []*graphql.FieldDefinition{
+			{
+				Name:        "nfce",
+				Description: "An NFC-e of the company; admins read any.",
+				Type:        nfceType,
+				Args:        []*graphql.ArgumentDefinition{{Name: "id", Type: nonNull(graphql.ID)}},
+				Resolve:     uc.resolveNFCe,
+			},
+			{
+				Name:        "nfces",
+				Description: "A page of NFC-e, newest first; nextCursor is the after of the next page. companyId is for admins, who list every company without it.",
+				Type:        nonNull(nfceConnectionType),
+				Args: []*graphql.ArgumentDefinition{
+					{Name: "companyId", Type: graphql.ID},
+					{Name: "status", Type: graphQLStatus},
+					{Name: "chaveAcesso", Type: graphql.String},
+					{Name: "from", Type: graphQLTime, Description: "Created at or after."},
+					{Name: "to", Type: graphQLTime, Description: "Created before."},
+					{Name: "first", Type: graphql.Int, Default: graphQLDefaultPage},
+					{Name: "after", Type: graphql.String},
+				},
+				Resolve: uc.resolveNFCes,
+			},
+			{
+				Name:        "company",
+				Description: "A company: the own company by default; admins give the id.",
+				Type:        companyType,
+				Args:        []*graphql.ArgumentDefinition{{Name: "id", Type: graphql.ID}},
+				Resolve:     uc.resolveCompany,
+			},
+			{
+				Name:        "companies",
+				Description: "A page of the companies, newest first. Admins only.",
+				Type:        nonNull(companyConnectionType),
+				Args: []*graphql.ArgumentDefinition{
+					{Name: "first", Type: graphql.Int, Default: graphQLDefaultPage},
+					{Name: "offset", Type: graphql.Int, Default: 0},
+				},
+				Authorize: adminOnly,
+				Resolve:   uc.resolveCompanies,
+			},
+			{
+				Name:        "usage",
+				Description: "NFC-e counts of the company in a period. companyId is for admins, who count every company without it.",
+				Type:        nonNull(usageType),
+				Args:        append([]*graphql.ArgumentDefinition{{Name: "companyId", Type: graphql.ID}}, usagePeriodArgs()...),
+				Resolve:     uc.resolveUsage,
+			},
+		},
+	}
+}
+
+// statusCount is the number of NFC-e in a status
+type statusCount struct {
+	status entity.RequestStatus
+	count  int
+}
+
+// resolveStatusCounts counts the NFC-e of the usage by status
+func (uc *graphQLUseCase) resolveStatusCounts(p graphql.ResolveParams) (interface{}, error) {
+	u := p.Source.(*usageStats)
+	byStatus, err := uc.nfceRepo.GetStats(p.Context, u.filter())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count NFC-e: %w", err)
+	}
+
+	counts := make([]statusCount, 0, len(graphQLStatus.Values))
+	for _, value := range graphQLStatus.Values {
+		status := value.Value.(entity.RequestStatus)
+		counts = append(counts, statusCount{status: status, count: byStatus[string(status)]})
+	}
+	return counts, nil
+}
+
+// resolveDailyUsage counts the NFC-e of the usage by day
+func (uc *graphQLUseCase) resolveDailyUsage(p graphql.ResolveParams) (interface{}, error) {
+	days, err := uc.nfceRepo.GetDailyStats(p.Context, p.Source.(*usageStats).filter())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build daily series: %w", err)
+	}
+	return days, nil
+}
+
+// metadataEntry is a key/value pair of the NFC-e metadata
+type metadataEntry struct {
+	key   string
+	value string
+}
+
+// metadataEntries returns the metadata in key order
+func metadataEntries(metadata entity.Metadata) []metadataEntry {
+	entries := make([]metadataEntry, 0, len(metadata))
+	for key, value := range metadata {
+		entries = append(entries, metadataEntry{key: key, value: value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return entries
+}
+
+// sefazExchangesOf returns the IDs of the SEFAZ exchanges the event metadata links
+func sefazExchangesOf(event *entity.Event) []string {
+	ids := []string{}
+	values, _ := event.Metadata["sefaz_exchanges"].([]interface{})
+	for _, value := range values {
+		if id, ok := value.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
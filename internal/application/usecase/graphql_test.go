@@ -0,0 +1,251 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+func TestScopeCompany(t *testing.T) {
+	company := GraphQLViewer{CompanyID: "company-a"}
+	admin := GraphQLViewer{AdminID: "admin-1"}
+	tests := []struct {
+		name      string
+		viewer    GraphQLViewer
+		requested string
+		want      string
+		wantErr   error
+	}{
+		{name: "company, own by default", viewer: company, want: "company-a"},
+		{name: "company, own requested", viewer: company, requested: "company-a", want: "company-a"},
+		{name: "company, other requested", viewer: company, requested: "company-b", wantErr: ErrGraphQLForbidden},
+		{name: "admin, every company", viewer: admin, want: ""},
+		{name: "admin, one company", viewer: admin, requested: "company-b", want: "company-b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scopeCompany(tt.viewer, tt.requested)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Fatalf("scopeCompany = %q, %v; want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOwnCompanyOrAdmin(t *testing.T) {
+	own := &entity.Company{ID: "company-a"}
+	other := &entity.Company{ID: "company-b"}
+	tests := []struct {
+		name    string
+		viewer  GraphQLViewer
+		source  interface{}
+		wantErr error
+	}{
+		{name: "own company", viewer: GraphQLViewer{CompanyID: "company-a"}, source: own},
+		{name: "other company", viewer: GraphQLViewer{CompanyID: "company-a"}, source: other, wantErr: ErrGraphQLForbidden},
+		{name: "admin", viewer: GraphQLViewer{AdminID: "admin-1"}, source: other},
+		{name: "no viewer", source: own, wantErr: ErrGraphQLForbidden},
+		{name: "not a company", viewer: GraphQLViewer{AdminID: "admin-1"}, source: &entity.NFCE{CompanyID: "company-b"}, wantErr: ErrGraphQLForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), graphQLViewerKey{}, tt.viewer)
+			if err := ownCompanyOrAdmin(ctx, tt.source); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ownCompanyOrAdmin = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeGraphQLNFCeRepo records the company each listing and count was scoped to
+type fakeGraphQLNFCeRepo struct {
+	ports.NFCeRepository
+	nfces           []*entity.NFCE
+	listedCompanies []string
+	statsCompanies  []string
+}
+
+func (r *fakeGraphQLNFCeRepo) ListByCompany(_ context.Context, companyID string, _ ports.NFCeFilter, _ int) ([]*entity.NFCE, int, error) {
+	r.listedCompanies = append(r.listedCompanies, companyID)
+	var nfces []*entity.NFCE
+	for _, nfce := range r.nfces {
+		if companyID == "" || nfce.CompanyID == companyID {
+			nfces = append(nfces, nfce)
+		}
+	}
+	return nfces, len(nfces), nil
+}
+
+func (r *fakeGraphQLNFCeRepo) GetCompanyStats(_ context.Context, filter ports.NFCeStatsFilter) ([]entity.CompanyStats, error) {
+	r.statsCompanies = append(r.statsCompanies, filter.CompanyID)
+	return []entity.CompanyStats{{CompanyID: "company-a", Total: 2}, {CompanyID: "company-b", Total: 3}}, nil
+}
+
+func (r *fakeGraphQLNFCeRepo) ListEventsByRequestIDs(_ context.Context, requestIDs []string) ([]*entity.Event, error) {
+	events := make([]*entity.Event, 0, len(requestIDs))
+	for _, requestID := range requestIDs {
+		events = append(events, &entity.Event{ID: "event-" + requestID, RequestID: requestID, StatusTo: entity.RequestStatusAuthorized})
+	}
+	return events, nil
+}
+
+// fakeGraphQLCompanyRepo returns the companies by ID
+type fakeGraphQLCompanyRepo struct {
+	ports.CompanyRepository
+	companies []*entity.Company
+	listed    bool
+}
+
+func (r *fakeGraphQLCompanyRepo) ListByIDs(_ context.Context, ids []string) ([]*entity.Company, error) {
+	var companies []*entity.Company
+	for _, company := range r.companies {
+		for _, id := range ids {
+			if company.ID == id {
+				companies = append(companies, company)
+			}
+		}
+	}
+	return companies, nil
+}
+
+func (r *fakeGraphQLCompanyRepo) List(_ context.Context, _, _ int) ([]*entity.Company, int, error) {
+	r.listed = true
+	return r.companies, len(r.companies), nil
+}
+
+func TestGraphQLAuthorization(t *testing.T) {
+	company := GraphQLViewer{CompanyID: "company-a"}
+	admin := GraphQLViewer{AdminID: "admin-1"}
+	tests := []struct {
+		name   string
+		viewer GraphQLViewer
+		query  string
+		want   string
+		// wantListed and wantCounted are the companies the repository was asked for
+		wantListed  []string
+		wantCounted []string
+	}{
+		{
+			name:       "company lists its own NFC-e",
+			viewer:     company,
+			query:      `{ nfces { nodes { id companyId } } }`,
+			want:       `{"data":{"nfces":{"nodes":[{"id":"nfce-a","companyId":"company-a"}]}}}`,
+			wantListed: []string{"company-a"},
+		},
+		{
+			name:   "company cannot list another company",
+			viewer: company,
+			query:  `{ nfces(companyId: "company-b") { nodes { id } } }`,
+			want:   `{"data":{"nfces":null},"errors":[{"message":"not allowed to read this field","locations":[{"line":1,"column":3}],"path":["nfces"]}]}`,
+		},
+		{
+			name:       "admin lists every company",
+			viewer:     admin,
+			query:      `{ nfces { nodes { id } } }`,
+			want:       `{"data":{"nfces":{"nodes":[{"id":"nfce-a"},{"id":"nfce-b"}]}}}`,
+			wantListed: []string{""},
+		},
+		{
+			name:       "admin lists one company",
+			viewer:     admin,
+			query:      `{ nfces(companyId: "company-b") { nodes { id } } }`,
+			want:       `{"data":{"nfces":{"nodes":[{"id":"nfce-b"}]}}}`,
+			wantListed: []string{"company-b"},
+		},
+		{
+			name:        "company counts its own usage",
+			viewer:      company,
+			query:       `{ usage { total } }`,
+			want:        `{"data":{"usage":{"total":2}}}`,
+			wantCounted: []string{"company-a"},
+		},
+		{
+			name:   "company cannot count another company",
+			viewer: company,
+			query:  `{ usage(companyId: "company-b") { total } }`,
+			want:   `{"data":{"usage":null},"errors":[{"message":"not allowed to read this field","locations":[{"line":1,"column":3}],"path":["usage"]}]}`,
+		},
+		{
+			name:        "admin counts every company",
+			viewer:      admin,
+			query:       `{ usage { total } }`,
+			want:        `{"data":{"usage":{"total":5}}}`,
+			wantCounted: []string{""},
+		},
+		{
+			name:   "company reads its own contact data",
+			viewer: company,
+			query:  `{ company { id email } }`,
+			want:   `{"data":{"company":{"id":"company-a","email":"a@example.com"}}}`,
+		},
+		{
+			name:   "company cannot read another company",
+			viewer: company,
+			query:  `{ company(id: "company-b") { id email } }`,
+			want:   `{"data":{"company":null}}`,
+		},
+		{
+			name:   "admin reads the contact data of any company",
+			viewer: admin,
+			query:  `{ company(id: "company-b") { email usage { total } } }`,
+			want:   `{"data":{"company":{"email":"b@example.com","usage":{"total":3}}}}`,
+			// The only usage key of the batch is filtered by its company
+			wantCounted: []string{"company-b"},
+		},
+		{
+			name:   "company cannot list the companies",
+			viewer: company,
+			query:  `{ companies { totalCount } }`,
+			want:   `{"data":{"companies":null},"errors":[{"message":"not allowed to read this field","locations":[{"line":1,"column":3}],"path":["companies"]}]}`,
+		},
+		{
+			name:       "company cannot read the SEFAZ exchanges",
+			viewer:     company,
+			query:      `{ nfces { nodes { events { id sefazExchanges } } } }`,
+			want:       `{"data":{"nfces":{"nodes":[{"events":[{"id":"event-nfce-a","sefazExchanges":null}]}]}},"errors":[{"message":"not allowed to read this field","locations":[{"line":1,"column":31}],"path":["nfces","nodes",0,"events",0,"sefazExchanges"]}]}`,
+			wantListed: []string{"company-a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nfceRepo := &fakeGraphQLNFCeRepo{nfces: []*entity.NFCE{
+				{ID: "nfce-a", CompanyID: "company-a", Status: entity.RequestStatusAuthorized},
+				{ID: "nfce-b", CompanyID: "company-b", Status: entity.RequestStatusAuthorized},
+			}}
+			companyRepo := &fakeGraphQLCompanyRepo{companies: []*entity.Company{
+				{ID: "company-a", Email: "a@example.com"},
+				{ID: "company-b", Email: "b@example.com"},
+			}}
+			uc, err := NewGraphQLUseCase(nfceRepo, companyRepo, GraphQLConfig{MaxDepth: 6})
+			if err != nil {
+				t.Fatalf("NewGraphQLUseCase: %v", err)
+			}
+
+			data, err := json.Marshal(uc.Execute(context.Background(), tt.viewer, dto.GraphQLRequest{Query: tt.query}))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Fatalf("response\n got: %s\nwant: %s", data, tt.want)
+			}
+			if !slices.Equal(nfceRepo.listedCompanies, tt.wantListed) {
+				t.Fatalf("listed companies %q, want %q", nfceRepo.listedCompanies, tt.wantListed)
+			}
+			if !slices.Equal(nfceRepo.statsCompanies, tt.wantCounted) {
+				t.Fatalf("counted companies %q, want %q", nfceRepo.statsCompanies, tt.wantCounted)
+			}
+			if companyRepo.listed && !tt.viewer.IsAdmin() {
+				t.Fatalf("companies listed for a company viewer")
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+)
+
+var (
+	// ErrInutilizacaoNotFound is returned when the inutilização does not exist for the company
+	ErrInutilizacaoNotFound = errors.New("inutilizacao not found")
+	// ErrInutilizacaoOverlap is returned when the range intersects a pending or homologated inutilização
+	ErrInutilizacaoOverlap = errors.New("number range overlaps an existing inutilizacao")
+)
+
+// InutilizacaoUseCase defines the interface for number range inutilização operations
+type InutilizacaoUseCase interface {
+	CreateInutilizacao(ctx context.Context, companyID string, req dto.InutilizacaoRequest) (*dto.InutilizacaoResponse, error)
+	GetInutilizacao(ctx context.Context, companyID, id string) (*dto.InutilizacaoResponse, error)
+	ListInutilizacoes(ctx context.Context, companyID string, limit, offset int) (*dto.InutilizacaoListResponse, error)
+}
+
+// InutilizacaoUseCaseImpl handles number range inutilização operations
+type InutilizacaoUseCaseImpl struct {
+	inutilizacaoRepo   ports.InutilizacaoRepository
+	companyRepo        ports.CompanyRepository
+	features           *service.FeatureGate
+	txManager          ports.TxManager
+	publisher          dto.Publisher
+	inutilizacaoMapper *mapper.InutilizacaoMapper
+}
+
+// NewInutilizacaoUseCase creates a new InutilizacaoUseCase
+func NewInutilizacaoUseCase(
+	inutilizacaoRepo ports.InutilizacaoRepository,
+	companyRepo ports.CompanyRepository,
+	featureGate *service.FeatureGate,
+	txManager ports.TxManager,
+	publisher dto.Publisher,
+) InutilizacaoUseCase {
+	return &InutilizacaoUseCaseImpl{
+		inutilizacaoRepo:   inutilizacaoRepo,
+		companyRepo:        companyRepo,
+		features:           featureGate,
+		txManager:          txManager,
+		publisher:          publisher,
+		inutilizacaoMapper: mapper.NewInutilizacaoMapper(),
+	}
+}
+
+// CreateInutilizacao validates the range, records it and enqueues it for SEFAZ
+func (uc *InutilizacaoUseCaseImpl) CreateInutilizacao(ctx context.Context, companyID string, req dto.InutilizacaoRequest) (*dto.InutilizacaoResponse, error) {
+	// Inutilização must be included in the company plan
+	if uc.features != nil {
+		err := uc.features.Require(ctx, companyID, "inutilization", map[string]interface{}{
+			"serie":          req.Serie,
+			"numero_inicial": req.NumeroInicial,
+			"numero_final":   req.NumeroFinal,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	uf := req.UF
+	if uf == "" {
+		company, err := uc.companyRepo.GetByID(ctx, companyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get company: %w", err)
+		}
+		uf = company.Endereco.UF
+	}
+
+	modelo := req.Modelo
+	if modelo == "" {
+		modelo = entity.ModeloNFCe
+	}
+
+	inutilizacao, err := entity.NewInutilizacao(companyID, uf, req.Ambiente, modelo, req.Serie, req.NumeroInicial, req.NumeroFinal, req.Justificativa)
+	if err != nil {
+		return nil, invalid(err)
+	}
+
+	// Check the range and record it atomically
+	create := func(ctx context.Context) error {
+		overlap, err := uc.inutilizacaoRepo.HasOverlap(ctx, companyID, inutilizacao.Modelo, inutilizacao.Serie, inutilizacao.NumeroInicial, inutilizacao.NumeroFinal)
+		if err != nil {
+			return fmt.Errorf("failed to check inutilizacao ranges: %w", err)
+		}
+		if overlap {
+			return ErrInutilizacaoOverlap
+		}
+
+		if err := uc.inutilizacaoRepo.Create(ctx, inutilizacao); err != nil {
+			return fmt.Errorf("failed to create inutilizacao: %w", err)
+		}
+		return nil
+	}
+
+	if uc.txManager != nil {
+		err = uc.txManager.WithinTx(ctx, create)
+	} else {
+		err = create(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dto.InutilizacaoMessage{
+		InutilizacaoID: inutilizacao.ID,
+		EnqueuedAt:     time.Now(),
+	}
+	if err := uc.publisher.PublishInutilizacao(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to publish inutilizacao: %w", err)
+	}
+
+	return uc.inutilizacaoMapper.ToInutilizacaoResponse(inutilizacao), nil
+}
+
+// GetInutilizacao gets an inutilização with its SEFAZ protocol
+func (uc *InutilizacaoUseCaseImpl) GetInutilizacao(ctx context.Context, companyID, id string) (*dto.InutilizacaoResponse, error) {
+	inutilizacao, err := uc.inutilizacaoRepo.GetByID(ctx, id)
+	if err != nil || inutilizacao.CompanyID != companyID {
+		return nil, ErrInutilizacaoNotFound
+	}
+
+	return uc.inutilizacaoMapper.ToInutilizacaoResponse(inutilizacao), nil
+}
+
+// ListInutilizacoes lists the inutilizações of a company
+func (uc *InutilizacaoUseCaseImpl) ListInutilizacoes(ctx context.Context, companyID string, limit, offset int) (*dto.InutilizacaoListResponse, error) {
+	inutilizacoes, total, err := uc.inutilizacaoRepo.ListByCompanyID(ctx, companyID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.InutilizacaoResponse, len(inutilizacoes))
+	for i, inutilizacao := range inutilizacoes {
+		responses[i] = *uc.inutilizacaoMapper.ToInutilizacaoResponse(inutilizacao)
+	}
+
+	return &dto.InutilizacaoListResponse{
+		Inutilizacoes: responses,
+		Total:         total,
+	}, nil
+}
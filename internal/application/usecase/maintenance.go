@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+)
+
+// ErrUnderMaintenance is returned when a new emission arrives during a maintenance window
+var ErrUnderMaintenance = service.ErrUnderMaintenance
+
+// ErrMaintenanceActive is returned when a window is started while another is active
+var ErrMaintenanceActive = service.ErrMaintenanceActive
+
+// ErrMaintenanceNotActive is returned when ending maintenance with no active window
+var ErrMaintenanceNotActive = service.ErrMaintenanceNotActive
+
+// MaintenanceWindowOf extracts the active window carried by a maintenance error, if any
+var MaintenanceWindowOf = service.MaintenanceWindowOf
+
+// MaintenanceUseCase defines the interface for the admin maintenance mode
+type MaintenanceUseCase interface {
+	GetStatus(ctx context.Context) (*dto.MaintenanceStatusResponse, error)
+	Start(ctx context.Context, req dto.StartMaintenanceRequest) (*dto.MaintenanceWindowResponse, error)
+	End(ctx context.Context) (*dto.MaintenanceWindowResponse, error)
+}
+
+// MaintenanceUseCaseImpl handles the admin maintenance mode
+type MaintenanceUseCaseImpl struct {
+	maintenance       *service.MaintenanceService
+	maintenanceMapper *mapper.MaintenanceMapper
+}
+
+// NewMaintenanceUseCase creates a new MaintenanceUseCase
+func NewMaintenanceUseCase(maintenance *service.MaintenanceService) MaintenanceUseCase {
+	return &MaintenanceUseCaseImpl{
+		maintenance:       maintenance,
+		maintenanceMapper: mapper.NewMaintenanceMapper(),
+	}
+}
+
+// GetStatus returns the active maintenance window, if any
+func (uc *MaintenanceUseCaseImpl) GetStatus(ctx context.Context) (*dto.MaintenanceStatusResponse, error) {
+	window, err := uc.maintenance.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return uc.maintenanceMapper.ToMaintenanceStatusResponse(window), nil
+}
+
+// Start begins a maintenance window: new emissions are refused until it ends
+func (uc *MaintenanceUseCaseImpl) Start(ctx context.Context, req dto.StartMaintenanceRequest) (*dto.MaintenanceWindowResponse, error) {
+	window, err := uc.maintenance.Begin(ctx, req.Message, req.ExpectedEndAt)
+	if err != nil {
+		return nil, err
+	}
+	return uc.maintenanceMapper.ToMaintenanceWindowResponse(window), nil
+}
+
+// End closes the active maintenance window
+func (uc *MaintenanceUseCaseImpl) End(ctx context.Context) (*dto.MaintenanceWindowResponse, error) {
+	window, err := uc.maintenance.End(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return uc.maintenanceMapper.ToMaintenanceWindowResponse(window), nil
+}
@@ -1,113 +1,473 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/danfe"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
 )
 
+var (
+	// ErrNFCeNotFound is returned when the NFC-e does not exist or belongs to another company
+	ErrNFCeNotFound = errors.New("NFC-e not found")
+	// ErrNFCeNotAuthorized is returned when the NFC-e has no authorized documents yet
+	ErrNFCeNotAuthorized = errors.New("NFC-e is not authorized")
+	// ErrXMLNotFound is returned when the authorized XML is missing from storage
+	ErrXMLNotFound = errors.New("XML file not found")
+	// ErrPDFNotFound is returned when the DANFE is missing from storage
+	ErrPDFNotFound = errors.New("PDF file not found")
+	// ErrQRCodeNotFound is returned when the QR Code image is missing from storage
+	ErrQRCodeNotFound = errors.New("QR Code file not found")
+	// ErrDocumentsPurged is returned when the documents were deleted by the plan retention
+	ErrDocumentsPurged = errors.New("NFC-e documents were purged by the plan retention")
+	// ErrESCPOSNotAvailable is returned for the NF-e, whose DANFE is printed on A4 only
+	ErrESCPOSNotAvailable = errors.New("the DANFE of the NF-e has no ESC/POS version")
+	// ErrLoteNotFound is returned when the lote does not exist or belongs to another company
+	ErrLoteNotFound = errors.New("lote not found")
+	// ErrInvalidCursor is returned when the listing cursor was not issued by a previous page
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrNFCeAlreadyRejected is returned when the Idempotency-Key belongs to a rejected NFC-e
+	ErrNFCeAlreadyRejected = errors.New("NFC-e already rejected")
+	// ErrNFCeNotCancelable is returned when canceling an NFC-e that is not authorized
+	ErrNFCeNotCancelable = errors.New("only authorized NFC-e can be canceled")
+	// ErrEventStreamUnavailable is returned when no event bus is configured
+	ErrEventStreamUnavailable = errors.New("event streaming is not available")
+)
+
+// ErrFeatureNotInPlan is returned when the company plan does not include the requested feature
+var ErrFeatureNotInPlan = service.ErrFeatureNotInPlan
+
+// QuotaContextOf extracts the plan and usage context carried by a plan block error, if any
+var QuotaContextOf = service.QuotaContextOf
+
+// ErrSubscriptionInactive is returned when the company has no active subscription to emit against
+var ErrSubscriptionInactive = service.ErrSubscriptionInactive
+
+// ErrQuotaExhausted is returned when the company used up the NFC-e quota of the period
+var ErrQuotaExhausted = service.ErrQuotaExhausted
+
+// ErrEmissionBlocked is returned when an anti-fraud rule blocks the NFC-e
+var ErrEmissionBlocked = service.ErrEmissionBlocked
+
+// EmissionBlockedError describes the pre-emission check that blocked the NFC-e
+type EmissionBlockedError = service.EmissionBlockedError
+
+// FeatureNotInPlanError describes a feature blocked by the company plan
+type FeatureNotInPlanError = service.FeatureNotInPlanError
+
+// ErrInvalidFiscalCodes is returned when items carry NCM, CEST or CFOP codes SEFAZ would reject
+var ErrInvalidFiscalCodes = service.ErrInvalidFiscalCodes
+
+// ErrIdempotencyKeyReused is returned when an Idempotency-Key arrives again with a different payload
+var ErrIdempotencyKeyReused = service.ErrIdempotencyKeyReused
+
+// ErrIdempotencyKeyInProgress is returned while the first request with the Idempotency-Key is running
+var ErrIdempotencyKeyInProgress = service.ErrIdempotencyKeyInProgress
+
+// FieldErrorsOf extracts the field-level errors of a catalog validation error, if any
+var FieldErrorsOf = service.FieldErrorsOf
+
 // NFCeUseCase defines the interface for NFC-e business logic
 type NFCeUseCase interface {
-	EmitNFce(ctx context.Context, idempotencyKey string, req dto.EmitNFceRequest) (*dto.NFceResponse, error)
-	GetNFceByID(ctx context.Context, id string) (*dto.NFceResponse, error)
-	ListNFces(ctx context.Context, limit, offset int) (*dto.NFceListResponse, error)
-	CancelNFce(ctx context.Context, id string, req dto.CancelNFceRequest) error
-	GetNFceEvents(ctx context.Context, requestID string, limit, offset int) (*dto.NFceEventListResponse, error)
-	DownloadXML(ctx context.Context, id string) ([]byte, error)
-	DownloadPDF(ctx context.Context, id string) ([]byte, error)
-	DownloadQRCode(ctx context.Context, id string) ([]byte, error)
+	EmitNFce(ctx context.Context, companyID, idempotencyKey string, req dto.EmitNFceRequest) (*dto.NFceResponse, error)
+	EmitLote(ctx context.Context, companyID, idempotencyKey string, req dto.EmitLoteRequest) (*dto.LoteResponse, error)
+	GetLote(ctx context.Context, companyID, id string) (*dto.LoteResponse, error)
+	GetNFceByID(ctx context.Context, companyID, id string) (*dto.NFceResponse, error)
+	ListNFces(ctx context.Context, companyID string, filter ports.NFCeFilter, cursor string, limit int) (*dto.NFceListResponse, error)
+	SearchNFces(ctx context.Context, companyID, query string, limit, offset int) (*dto.NFceSearchResponse, error)
+	CancelNFce(ctx context.Context, companyID, id string, req dto.CancelNFceRequest) error
+	GetNFceEvents(ctx context.Context, companyID, requestID string, limit, offset int) (*dto.NFceEventListResponse, error)
+	StreamNFceEvents(ctx context.Context, companyID, lastEventID string) (*dto.NFceEventStream, error)
+	StreamNFceStatus(ctx context.Context, companyID, id, lastEventID string) (*dto.NFceEventStream, error)
+	DownloadXML(ctx context.Context, companyID, id string) (*dto.NFceFile, error)
+	DownloadPDF(ctx context.Context, companyID, id string, opts *dto.DANFEOptions) (*dto.NFceFile, error)
+	DownloadESCPOS(ctx context.Context, companyID, id string, opts *dto.DANFEOptions) (*dto.NFceFile, error)
+	DownloadQRCode(ctx context.Context, companyID, id string) (*dto.NFceFile, error)
 }
 
 // nfceUseCase implements NFCeUseCase
 type nfceUseCase struct {
-	repo      ports.NFCeRepository
-	publisher dto.Publisher
-	mapper    *mapper.NFceMapper
-	storage   storage.StorageService
+	repo        ports.NFCeRepository
+	companyRepo ports.CompanyRepository
+	publisher   dto.Publisher
+	mapper      *mapper.NFceMapper
+	storage     storage.StorageService
+	eventBus    ports.EventBus
+	danfe       danfe.Renderer
+	features    *service.FeatureGate
+	txManager   ports.TxManager
+	outbox      ports.OutboxRepository
+	lotes       ports.NFCeLoteRepository
+	fraud       *service.FraudGuard
+	catalog     *service.CatalogService
+	maintenance *service.MaintenanceService
+	logger      logger.Logger
 }
 
+// maxReplayEvents caps how many missed events are replayed on stream reconnect
+const maxReplayEvents = 500
+
 // NewNFCeUseCase creates a new NFCeUseCase
-func NewNFCeUseCase(repo ports.NFCeRepository, publisher dto.Publisher, storage storage.StorageService) NFCeUseCase {
+func NewNFCeUseCase(repo ports.NFCeRepository, companyRepo ports.CompanyRepository, publisher dto.Publisher, storage storage.StorageService, eventBus ports.EventBus, danfeRenderer danfe.Renderer, featureGate *service.FeatureGate, txManager ports.TxManager, outbox ports.OutboxRepository, lotes ports.NFCeLoteRepository, fraudGuard *service.FraudGuard, catalog *service.CatalogService, maintenance *service.MaintenanceService, l logger.Logger) NFCeUseCase {
 	return &nfceUseCase{
-		repo:      repo,
-		publisher: publisher,
-		mapper:    mapper.NewNFceMapper(),
-		storage:   storage,
+		repo:        repo,
+		companyRepo: companyRepo,
+		publisher:   publisher,
+		mapper:      mapper.NewNFceMapper(),
+		storage:     storage,
+		eventBus:    eventBus,
+		danfe:       danfeRenderer,
+		features:    featureGate,
+		txManager:   txManager,
+		outbox:      outbox,
+		lotes:       lotes,
+		fraud:       fraudGuard,
+		catalog:     catalog,
+		maintenance: maintenance,
+		logger:      l,
 	}
 }
 
 // EmitNFce handles the NFC-e emission request
-func (uc *nfceUseCase) EmitNFce(ctx context.Context, idempotencyKey string, req dto.EmitNFceRequest) (*dto.NFceResponse, error) {
+func (uc *nfceUseCase) EmitNFce(ctx context.Context, companyID, idempotencyKey string, req dto.EmitNFceRequest) (*dto.NFceResponse, error) {
 	// Check for existing request with same idempotency key
-	existing, err := uc.repo.GetByIdempotencyKey(ctx, idempotencyKey)
+	existing, err := uc.repo.GetByIdempotencyKey(ctx, companyID, idempotencyKey)
 	if err == nil && existing != nil {
 		// Return existing request if already authorized or processing
 		if existing.Status == entity.RequestStatusAuthorized ||
-			existing.Status == entity.RequestStatusProcessing {
+			existing.Status == entity.RequestStatusProcessing ||
+			existing.Status == entity.RequestStatusQueuedDeferred {
 			response := uc.mapper.ToResponse(existing)
 			return &response, nil
 		}
 		// Return error if rejected
 		if existing.Status == entity.RequestStatusRejected {
-			return nil, fmt.Errorf("NFC-e already rejected: %s", existing.RejectionMsg)
+			return nil, fmt.Errorf("%w: %w", ErrNFCeAlreadyRejected, invalid(errors.New(existing.RejectionMsg)))
+		}
+	}
+
+	// New emissions wait for the end of a maintenance window; replays above still answer
+	if uc.maintenance != nil {
+		if err := uc.maintenance.Guard(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// The subscription must be active with quota left; usage is recorded when SEFAZ authorizes
+	if uc.features != nil {
+		if err := uc.features.CheckQuota(ctx, companyID, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	emission, err := uc.newEmission(ctx, companyID, idempotencyKey, req)
+	if err != nil {
+		return nil, err
+	}
+	nfceRequest, decisions, blocked := emission.request, emission.decisions, emission.blocked
+
+	// Degraded intake: the request is relayed once the broker recovers
+	if blocked == nil && !uc.publisher.IsHealthy() {
+		nfceRequest.Status = entity.RequestStatusQueuedDeferred
+	}
+
+	// Persist the request and its emission message in one transaction; the worker relays the
+	// outbox to the queue, so an accepted request is never left unpublished
+	err = uc.withinTx(ctx, func(ctx context.Context) error {
+		if err := uc.repo.Create(ctx, nfceRequest); err != nil {
+			return fmt.Errorf("failed to create NFC-e request: %w", err)
+		}
+		if blocked != nil {
+			return nil
+		}
+
+		outboxMsg, err := entity.NewOutboxMessage(entity.OutboxTopicEmit, nfceRequest.ID, dto.EmitMessage{
+			RequestID:      nfceRequest.ID,
+			CompanyID:      companyID,
+			IdempotencyKey: idempotencyKey,
+			EnqueuedAt:     nfceRequest.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if err := uc.outbox.Create(ctx, outboxMsg); err != nil {
+			return fmt.Errorf("failed to create NFC-e emission message: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decisions) > 0 {
+		uc.recordFraudDecisions(ctx, nfceRequest, decisions)
+	}
+	if blocked != nil {
+		return nil, blocked
+	}
+
+	if nfceRequest.Status == entity.RequestStatusQueuedDeferred {
+		logger.FromContext(ctx, uc.logger).Warn("Message broker unavailable, NFC-e request queued for deferred publishing", logger.RequestID(nfceRequest.ID))
+	}
+
+	response := uc.mapper.ToResponse(nfceRequest)
+	return &response, nil
+}
+
+// emission is a validated emission request with its anti-fraud outcome
+type emission struct {
+	request   *entity.Request
+	decisions entity.FraudDecisions
+	blocked   error // Set when an anti-fraud rule blocked the request, kept as rejected
+}
+
+// newEmission validates an emission payload and builds its request, running the anti-fraud checks
+func (uc *nfceUseCase) newEmission(ctx context.Context, companyID, idempotencyKey string, req dto.EmitNFceRequest) (*emission, error) {
+	metadata := entity.Metadata(req.Metadata)
+	if err := metadata.Validate(); err != nil {
+		return nil, invalid(err)
+	}
+
+	payload := uc.mapper.ToEmitPayload(req)
+	if err := uc.applyEmissionDefaults(ctx, companyID, &payload); err != nil {
+		return nil, err
+	}
+	if err := payload.ValidateAmounts(); err != nil {
+		return nil, invalid(err)
+	}
+	if err := payload.ValidatePayments(); err != nil {
+		return nil, invalid(err)
+	}
+	if err := payload.ValidateModel(); err != nil {
+		return nil, invalid(err)
+	}
+	if payload.Destinatario != nil {
+		if err := payload.Destinatario.Validate(); err != nil {
+			return nil, invalid(err)
+		}
+	}
+	if payload.Intermediador != nil {
+		if err := payload.Intermediador.Validate(); err != nil {
+			return nil, invalid(err)
+		}
+	}
+
+	// Reject unknown NCM/CEST and CFOPs not allowed in the NFC-e before they reach SEFAZ
+	if uc.catalog != nil {
+		if err := uc.catalog.ValidateItems(ctx, payload.DocumentModel(), payload.Itens); err != nil {
+			return nil, err
 		}
 	}
 
 	// Create request entity (this needs to be refactored to use entity constructors)
 	// TODO: This is still a violation - should use entity.NewRequest() or similar
 	nfceRequest := &entity.Request{
+		CompanyID:      companyID,
 		IdempotencyKey: idempotencyKey,
 		Status:         entity.RequestStatusPending,
-		Payload:        uc.mapper.ToEmitPayload(req),
+		Payload:        payload,
+		VNF:            payload.Total(),
+		Metadata:       metadata,
 	}
-	fmt.Printf("DEBUG: Created nfceRequest with initial ID: %s\n", nfceRequest.ID)
 
-	// Persist request
-	if err := uc.repo.Create(ctx, nfceRequest); err != nil {
-		return nil, fmt.Errorf("failed to create NFC-e request: %w", err)
+	// Anti-fraud checks: warnings flag the metadata, a block keeps the request as rejected for auditing
+	var decisions entity.FraudDecisions
+	var blocked error
+	if uc.fraud != nil {
+		decisions, blocked = uc.fraud.Evaluate(ctx, nfceRequest)
+		if warnings := decisions.Warnings(); len(warnings) > 0 {
+			if nfceRequest.Metadata == nil {
+				nfceRequest.Metadata = entity.Metadata{}
+			}
+			nfceRequest.Metadata[entity.FraudMetadataKey] = strings.Join(warnings, ",")
+		}
+
+		var blockedErr *service.EmissionBlockedError
+		if errors.As(blocked, &blockedErr) {
+			nfceRequest.Status = entity.RequestStatusRejected
+			nfceRequest.RejectionCode = string(blockedErr.Code)
+			nfceRequest.RejectionMsg = blockedErr.Error()
+		}
 	}
-	fmt.Printf("DEBUG: Persisted nfceRequest with final ID: %s\n", nfceRequest.ID)
 
-	// Use the ID assigned by database
-	requestID := nfceRequest.ID
+	return &emission{request: nfceRequest, decisions: decisions, blocked: blocked}, nil
+}
 
-	// Publish to queue for async processing
-	emitMsg := dto.EmitMessage{
-		RequestID:      requestID,
-		IdempotencyKey: idempotencyKey,
-		EnqueuedAt:     nfceRequest.CreatedAt,
+// applyEmissionDefaults fills the CFOP of the items sent without one from the company emission
+// settings, so the catalog and SEFAZ validations see the CFOP the NFC-e is issued with
+func (uc *nfceUseCase) applyEmissionDefaults(ctx context.Context, companyID string, payload *entity.EmitPayload) error {
+	var settings *entity.CompanyEmissionSettings
+	for _, item := range payload.Itens {
+		if item.CFOP != "" {
+			continue
+		}
+		company, err := uc.companyRepo.GetByID(ctx, companyID)
+		if err != nil {
+			return fmt.Errorf("failed to get company: %w", err)
+		}
+		settings = company.EmissionSettings
+		break
 	}
-	fmt.Printf("DEBUG: Created emitMsg with RequestID: %s\n", emitMsg.RequestID)
+	if err := payload.ApplyDefaultCFOP(settings); err != nil {
+		return invalid(err)
+	}
+	return nil
+}
 
-	if err := uc.publisher.PublishEmit(ctx, emitMsg); err != nil {
-		// Log error but don't fail the request - it will be retried
-		// TODO: Add proper logging
-		fmt.Printf("Failed to publish NFC-e message: %v\n", err)
-		return nil, fmt.Errorf("failed to publish NFC-e message: %w", err)
+// EmitLote handles the emission request of a lote of NFC-e. Every NFC-e is validated before
+// any is persisted, so an invalid item rejects the whole lote; the NFC-e blocked by an
+// anti-fraud rule are kept as rejected while the others go on.
+func (uc *nfceUseCase) EmitLote(ctx context.Context, companyID, idempotencyKey string, req dto.EmitLoteRequest) (*dto.LoteResponse, error) {
+	if len(req.NFces) == 0 || len(req.NFces) > entity.MaxLoteSize {
+		return nil, &ValidationError{Field: "nfces", Err: fmt.Errorf("lote must have between 1 and %d NFC-e", entity.MaxLoteSize)}
 	}
 
-	response := uc.mapper.ToResponse(nfceRequest)
+	if uc.maintenance != nil {
+		if err := uc.maintenance.Guard(ctx); err != nil {
+			return nil, err
+		}
+	}
+	// The quota must cover every NFC-e of the lote, not only the first one
+	if uc.features != nil {
+		if err := uc.features.CheckQuota(ctx, companyID, len(req.NFces)); err != nil {
+			return nil, err
+		}
+	}
+
+	lote := entity.NewNFCeLote(companyID, idempotencyKey, len(req.NFces))
+	deferred := !uc.publisher.IsHealthy()
+
+	emissions := make([]*emission, len(req.NFces))
+	requests := make([]*entity.Request, len(req.NFces))
+	queued := 0
+	for i, item := range req.NFces {
+		e, err := uc.newEmission(ctx, companyID, lote.ItemIdempotencyKey(i), item)
+		if err != nil {
+			var invalidErr *ValidationError
+			if errors.As(err, &invalidErr) && invalidErr.Field == "" {
+				invalidErr.Field = fmt.Sprintf("nfces[%d]", i)
+			}
+			return nil, fmt.Errorf("nfces[%d]: %w", i, err)
+		}
+		e.request.LoteID = &lote.ID
+		if e.blocked == nil {
+			queued++
+			if deferred {
+				e.request.Status = entity.RequestStatusQueuedDeferred
+			}
+		}
+		emissions[i] = e
+		requests[i] = e.request
+	}
+
+	// Persist the lote, its NFC-e and a single emission message in one transaction
+	err := uc.withinTx(ctx, func(ctx context.Context) error {
+		if err := uc.lotes.Create(ctx, lote); err != nil {
+			return fmt.Errorf("failed to create lote: %w", err)
+		}
+		for _, request := range requests {
+			if err := uc.repo.Create(ctx, request); err != nil {
+				return fmt.Errorf("failed to create NFC-e request: %w", err)
+			}
+		}
+		if queued == 0 {
+			return nil
+		}
+
+		outboxMsg, err := entity.NewOutboxMessage(entity.OutboxTopicLote, lote.ID, dto.LoteMessage{
+			LoteID:     lote.ID,
+			CompanyID:  companyID,
+			EnqueuedAt: lote.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if err := uc.outbox.Create(ctx, outboxMsg); err != nil {
+			return fmt.Errorf("failed to create lote emission message: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range emissions {
+		if len(e.decisions) > 0 {
+			uc.recordFraudDecisions(ctx, e.request, e.decisions)
+		}
+	}
+
+	response := uc.mapper.ToLoteResponse(lote, requests)
 	return &response, nil
 }
 
-// GetNFceByID retrieves a NFC-e by ID
-func (uc *nfceUseCase) GetNFceByID(ctx context.Context, id string) (*dto.NFceResponse, error) {
-	req, err := uc.repo.GetByID(ctx, id)
+// GetLote retrieves a lote of the company with the aggregate status of its NFC-e
+func (uc *nfceUseCase) GetLote(ctx context.Context, companyID, id string) (*dto.LoteResponse, error) {
+	lote, err := uc.lotes.GetByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get NFC-e: %w", err)
+		return nil, fmt.Errorf("failed to get lote: %w", err)
+	}
+	if lote == nil || lote.CompanyID != companyID {
+		return nil, ErrLoteNotFound
+	}
+
+	requests, err := uc.repo.ListByLote(ctx, lote.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lote NFC-e: %w", err)
+	}
+
+	response := uc.mapper.ToLoteResponse(lote, requests)
+	return &response, nil
+}
+
+// recordFraudDecisions appends the anti-fraud decisions to the request events for auditing
+func (uc *nfceUseCase) recordFraudDecisions(ctx context.Context, nfceRequest *entity.NFCE, decisions entity.FraudDecisions) {
+	message := "Verificação antifraude aprovada"
+	if _, ok := decisions.Blocking(); ok {
+		message = "Emissão bloqueada pela verificação antifraude"
+	} else if len(decisions.Warnings()) > 0 {
+		message = "Verificação antifraude aprovada com alertas"
+	}
+
+	event := &entity.Event{
+		RequestID:  nfceRequest.ID,
+		StatusFrom: nfceRequest.Status,
+		StatusTo:   nfceRequest.Status,
+		CStat:      nfceRequest.RejectionCode,
+		Message:    message,
+		Metadata:   map[string]interface{}{"antifraud": decisions},
+	}
+	if err := uc.repo.CreateEvent(ctx, event); err != nil {
+		// Log error but don't fail - the request is already persisted
+		logger.FromContext(ctx, uc.logger).Error("Failed to record anti-fraud decisions", logger.RequestID(nfceRequest.ID), logger.Err(err))
+	}
+}
+
+// GetNFceByID retrieves a NFC-e of the company by ID
+func (uc *nfceUseCase) GetNFceByID(ctx context.Context, companyID, id string) (*dto.NFceResponse, error) {
+	req, err := uc.nfceOf(ctx, companyID, id)
+	if err != nil {
+		return nil, err
 	}
 
 	response := uc.mapper.ToResponse(req)
 
-	// Add links if authorized
-	if dto.RequestStatus(req.Status) == dto.RequestStatusAuthorized && req.ChaveAcesso != "" {
+	// Add links if authorized and the documents are still stored
+	if req.Status == entity.RequestStatusAuthorized && req.ChaveAcesso != "" && !req.DocumentsPurged() {
 		response.Links = dto.NFceLinks{
 			XML:    fmt.Sprintf("/nfce/%s/xml", id),
 			PDF:    fmt.Sprintf("/nfce/%s/pdf", id),
@@ -118,58 +478,135 @@ func (uc *nfceUseCase) GetNFceByID(ctx context.Context, id string) (*dto.NFceRes
 	return &response, nil
 }
 
-// ListNFces lists NFC-e requests with pagination
-func (uc *nfceUseCase) ListNFces(ctx context.Context, limit, offset int) (*dto.NFceListResponse, error) {
-	requests, err := uc.repo.List(ctx, limit, offset)
+// ListNFces lists a page of the company NFC-e matching the filter; cursor is the NextCursor of
+// the previous page, empty for the first one
+func (uc *nfceUseCase) ListNFces(ctx context.Context, companyID string, filter ports.NFCeFilter, cursor string, limit int) (*dto.NFceListResponse, error) {
+	if cursor != "" {
+		after, err := decodeNFCeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = after
+	}
+
+	// One more than the page tells whether there is a next one
+	requests, total, err := uc.repo.ListByCompany(ctx, companyID, filter, limit+1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list NFC-es: %w", err)
 	}
 
-	response := uc.mapper.ToResponseList(requests)
+	var nextCursor string
+	if len(requests) > limit {
+		requests = requests[:limit]
+		last := requests[limit-1]
+		nextCursor, err = encodeNFCeCursor(ports.NFCeCursor{CreatedAt: last.CreatedAt, Valor: last.VNF, ID: last.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode cursor: %w", err)
+		}
+	}
+
+	response := uc.mapper.ToResponseList(requests, total, nextCursor)
 	return &response, nil
 }
 
-// CancelNFce cancels a NFC-e
-func (uc *nfceUseCase) CancelNFce(ctx context.Context, id string, req dto.CancelNFceRequest) error {
-	// Get current request
-	nfceReq, err := uc.repo.GetByID(ctx, id)
+// encodeNFCeCursor makes the opaque cursor of a listing position
+func encodeNFCeCursor(cursor ports.NFCeCursor) (string, error) {
+	data, err := json.Marshal(cursor)
 	if err != nil {
-		return fmt.Errorf("failed to get NFC-e: %w", err)
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
 
-	// Check if can be canceled
-	if dto.RequestStatus(nfceReq.Status) != dto.RequestStatusAuthorized {
-		return errors.New("only authorized NFC-e can be canceled")
+// decodeNFCeCursor reads a cursor made by encodeNFCeCursor
+func decodeNFCeCursor(cursor string) (*ports.NFCeCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
 	}
+	var after ports.NFCeCursor
+	if err := json.Unmarshal(data, &after); err != nil || after.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &after, nil
+}
 
-	// Update status to canceled (temporarily mark as processing for queue)
-	err = uc.repo.UpdateStatus(ctx, id, entity.RequestStatusAuthorized, entity.RequestStatusProcessing, func(r *entity.Request) {
-		// Add cancellation metadata if needed
-		r.XMotivo = req.Justificativa
-	})
+// SearchNFces finds the company NFC-e by item description or consumer name, most relevant first
+func (uc *nfceUseCase) SearchNFces(ctx context.Context, companyID, query string, limit, offset int) (*dto.NFceSearchResponse, error) {
+	hits, total, err := uc.repo.Search(ctx, companyID, query, limit, offset)
 	if err != nil {
-		return fmt.Errorf("failed to update NFC-e status: %w", err)
+		return nil, fmt.Errorf("failed to search NFC-es: %w", err)
 	}
 
-	// Publish cancellation event to queue
-	cancelMsg := dto.CancelMessage{
-		RequestID:      id,
-		IdempotencyKey: nfceReq.IdempotencyKey,
-		Justificativa:  req.Justificativa,
-		EnqueuedAt:     time.Now(),
+	response := uc.mapper.ToSearchResponse(hits, total)
+	return &response, nil
+}
+
+// CancelNFce cancels a NFC-e of the company
+func (uc *nfceUseCase) CancelNFce(ctx context.Context, companyID, id string, req dto.CancelNFceRequest) error {
+	// Get current request
+	nfceReq, err := uc.nfceOf(ctx, companyID, id)
+	if err != nil {
+		return err
 	}
 
-	if err := uc.publisher.PublishCancel(ctx, cancelMsg); err != nil {
-		// Revert status if publish fails
-		uc.repo.UpdateStatus(ctx, id, entity.RequestStatusProcessing, entity.RequestStatusAuthorized, func(r *entity.Request) {})
-		return fmt.Errorf("failed to publish cancellation event: %w", err)
+	// Check if can be canceled
+	if nfceReq.Status != entity.RequestStatusAuthorized {
+		return ErrNFCeNotCancelable
 	}
 
-	return nil
+	// Cancellation must be included in the company plan
+	if uc.features != nil {
+		err := uc.features.Require(ctx, nfceReq.CompanyID, "cancellation", map[string]interface{}{
+			"request_id":   nfceReq.ID,
+			"chave_acesso": nfceReq.ChaveAcesso,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Lock the NFC-e as processing and publish in the same transaction,
+	// so the status is only kept if the cancellation reached the queue
+	return uc.withinTx(ctx, func(ctx context.Context) error {
+		err := uc.repo.UpdateStatus(ctx, id, entity.RequestStatusAuthorized, entity.RequestStatusProcessing, "Cancelamento solicitado", func(r *entity.Request) {
+			// Add cancellation metadata if needed
+			r.XMotivo = req.Justificativa
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update NFC-e status: %w", err)
+		}
+
+		// Publish cancellation event to queue
+		cancelMsg := dto.CancelMessage{
+			RequestID:      id,
+			IdempotencyKey: nfceReq.IdempotencyKey,
+			Justificativa:  req.Justificativa,
+			EnqueuedAt:     time.Now(),
+		}
+
+		if err := uc.publisher.PublishCancel(ctx, cancelMsg); err != nil {
+			return fmt.Errorf("failed to publish cancellation event: %w", err)
+		}
+
+		return nil
+	})
 }
 
-// GetNFceEvents retrieves events for a NFC-e request
-func (uc *nfceUseCase) GetNFceEvents(ctx context.Context, requestID string, limit, offset int) (*dto.NFceEventListResponse, error) {
+// withinTx runs fn in a transaction when a transaction manager is configured
+func (uc *nfceUseCase) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if uc.txManager == nil {
+		return fn(ctx)
+	}
+	return uc.txManager.WithinTx(ctx, fn)
+}
+
+// GetNFceEvents retrieves events for a NFC-e request of the company
+func (uc *nfceUseCase) GetNFceEvents(ctx context.Context, companyID, requestID string, limit, offset int) (*dto.NFceEventListResponse, error) {
+	if _, err := uc.nfceOf(ctx, companyID, requestID); err != nil {
+		return nil, err
+	}
+
 	events, err := uc.repo.GetEventsByRequestID(ctx, requestID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get NFC-e events: %w", err)
@@ -179,93 +616,296 @@ func (uc *nfceUseCase) GetNFceEvents(ctx context.Context, requestID string, limi
 	return &response, nil
 }
 
-// DownloadXML downloads the XML file for an NFC-e
-func (uc *nfceUseCase) DownloadXML(ctx context.Context, id string) ([]byte, error) {
-	// Get NFC-e request
-	nfce, err := uc.repo.GetByID(ctx, id)
+// StreamNFceEvents subscribes to status change events of a company, replaying events missed since lastEventID
+func (uc *nfceUseCase) StreamNFceEvents(ctx context.Context, companyID, lastEventID string) (*dto.NFceEventStream, error) {
+	if uc.eventBus == nil {
+		return nil, ErrEventStreamUnavailable
+	}
+
+	// Subscribe before replaying so no event is lost in between
+	source, unsubscribe := uc.eventBus.Subscribe(companyID)
+
+	stream := &dto.NFceEventStream{Close: unsubscribe}
+	replayed := make(map[string]bool)
+
+	if lastEventID != "" {
+		events, err := uc.repo.GetCompanyEventsAfter(ctx, companyID, lastEventID, maxReplayEvents)
+		if err != nil || len(events) == maxReplayEvents {
+			// Unknown event or too far behind - client must reload its state
+			stream.Reset = true
+		} else {
+			for _, event := range events {
+				stream.Replay = append(stream.Replay, uc.mapper.ToEventResponse(event))
+				replayed[event.ID] = true
+			}
+		}
+	}
+
+	stream.Events = uc.relayEvents(ctx, source, replayed, "")
+	return stream, nil
+}
+
+// StreamNFceStatus subscribes to the status changes of one NFC-e of the company. The events
+// recorded so far are replayed first, or only the ones after lastEventID when it is one of
+// them (Reset is set when it is not). Events is closed once the NFC-e settles, at once when
+// the replay already ends settled.
+func (uc *nfceUseCase) StreamNFceStatus(ctx context.Context, companyID, id, lastEventID string) (*dto.NFceEventStream, error) {
+	if uc.eventBus == nil {
+		return nil, ErrEventStreamUnavailable
+	}
+	if _, err := uc.nfceOf(ctx, companyID, id); err != nil {
+		return nil, err
+	}
+
+	// Subscribe before replaying so no event is lost in between
+	source, unsubscribe := uc.eventBus.Subscribe(companyID)
+
+	events, err := uc.repo.ListEvents(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get NFC-e: %w", err)
+		unsubscribe()
+		return nil, fmt.Errorf("failed to get NFC-e events: %w", err)
 	}
 
-	// Check if authorized
-	if nfce.Status != entity.RequestStatusAuthorized && nfce.Status != entity.RequestStatusContingency {
-		return nil, errors.New("NFC-e is not authorized")
+	stream := &dto.NFceEventStream{Close: unsubscribe}
+	replayed := make(map[string]bool, len(events))
+	for _, event := range events {
+		replayed[event.ID] = true
+	}
+	if lastEventID != "" {
+		stream.Reset = !replayed[lastEventID]
+		for i, event := range events {
+			if event.ID == lastEventID {
+				events = events[i+1:]
+				break
+			}
+		}
+	}
+	for _, event := range events {
+		stream.Replay = append(stream.Replay, uc.mapper.ToEventResponse(event))
 	}
 
-	// Check if XML URL exists
-	if nfce.XMLURL == "" {
-		return nil, errors.New("XML file not found")
+	if len(stream.Replay) > 0 && stream.Replay[len(stream.Replay)-1].StatusTo.IsSettled() {
+		closed := make(chan dto.NFceEventResponse)
+		close(closed)
+		stream.Events = closed
+		return stream, nil
 	}
+	stream.Events = uc.relayEvents(ctx, source, replayed, id)
+	return stream, nil
+}
 
-	// Extract bucket and key from URL (assuming format: http://minio:9000/bucket/key)
-	// For now, construct the key from the known pattern
-	key := fmt.Sprintf("nfce/%s/xml/%s.xml", nfce.CompanyID, nfce.ChaveAcesso)
+// relayEvents forwards the subscribed events not yet replayed until ctx ends. With a
+// requestID only its events are forwarded, up to the one that settles it.
+func (uc *nfceUseCase) relayEvents(ctx context.Context, source <-chan *entity.Event, replayed map[string]bool, requestID string) <-chan dto.NFceEventResponse {
+	events := make(chan dto.NFceEventResponse)
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-source:
+				if !ok {
+					return
+				}
+				if replayed[event.ID] || (requestID != "" && event.RequestID != requestID) {
+					continue
+				}
+				response := uc.mapper.ToEventResponse(event)
+				select {
+				case events <- response:
+				case <-ctx.Done():
+					return
+				}
+				if requestID != "" && response.StatusTo.IsSettled() {
+					return
+				}
+			}
+		}
+	}()
+	return events
+}
 
-	// Download file
-	data, err := uc.storage.DownloadFile(ctx, "", key)
+// DownloadXML opens the authorized XML of a company NFC-e for streaming
+func (uc *nfceUseCase) DownloadXML(ctx context.Context, companyID, id string) (*dto.NFceFile, error) {
+	nfce, err := uc.documentsOf(ctx, companyID, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download XML file: %w", err)
+		return nil, err
+	}
+
+	if nfce.DocumentsPurged() {
+		return nil, ErrDocumentsPurged
+	}
+
+	// Check if XML URL exists
+	if nfce.XMLURL == "" {
+		return nil, ErrXMLNotFound
 	}
 
-	return data, nil
+	key := fmt.Sprintf("nfce/%s/xml/%s.xml", nfce.CompanyID, nfce.ChaveAcesso)
+	return uc.openFile(ctx, key, ErrXMLNotFound, "application/xml", fmt.Sprintf("nfce-%s.xml", nfce.ChaveAcesso))
 }
 
-// DownloadPDF downloads the PDF file for an NFC-e
-func (uc *nfceUseCase) DownloadPDF(ctx context.Context, id string) ([]byte, error) {
-	// Get NFC-e request
-	nfce, err := uc.repo.GetByID(ctx, id)
+// DownloadPDF opens the DANFE of a company NFC-e for streaming, rendering it on demand when DANFE options are given
+func (uc *nfceUseCase) DownloadPDF(ctx context.Context, companyID, id string, opts *dto.DANFEOptions) (*dto.NFceFile, error) {
+	nfce, err := uc.documentsOf(ctx, companyID, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get NFC-e: %w", err)
+		return nil, err
 	}
 
-	// Check if authorized
-	if nfce.Status != entity.RequestStatusAuthorized && nfce.Status != entity.RequestStatusContingency {
-		return nil, errors.New("NFC-e is not authorized")
+	filename := fmt.Sprintf("nfce-%s.pdf", nfce.ChaveAcesso)
+
+	// Render the requested vias instead of serving the stored DANFE
+	if opts != nil {
+		company := uc.danfeCompany(ctx, nfce)
+		pdf := uc.danfe.Render(company, nfce, nfce.ChaveAcesso, danfeOptions(nfce, company, opts))
+		return &dto.NFceFile{
+			Content:     io.NopCloser(bytes.NewReader(pdf)),
+			Size:        int64(len(pdf)),
+			ContentType: "application/pdf",
+			Filename:    filename,
+		}, nil
+	}
+
+	if nfce.DocumentsPurged() {
+		return nil, ErrDocumentsPurged
 	}
 
 	// Check if PDF URL exists
 	if nfce.PDFURL == "" {
-		return nil, errors.New("PDF file not found")
+		return nil, ErrPDFNotFound
 	}
 
-	// Construct the key from the known pattern
 	key := fmt.Sprintf("nfce/%s/pdf/%s.pdf", nfce.CompanyID, nfce.ChaveAcesso)
+	return uc.openFile(ctx, key, ErrPDFNotFound, "application/pdf", filename)
+}
 
-	// Download file
-	data, err := uc.storage.DownloadFile(ctx, "", key)
+// DownloadESCPOS renders the DANFE of a company NFC-e as an ESC/POS byte stream for thermal printers.
+// Without DANFE options it prints the vias and paper the stored DANFE was rendered with.
+func (uc *nfceUseCase) DownloadESCPOS(ctx context.Context, companyID, id string, opts *dto.DANFEOptions) (*dto.NFceFile, error) {
+	nfce, err := uc.documentsOf(ctx, companyID, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download PDF file: %w", err)
+		return nil, err
+	}
+	if nfce.Payload.IsNFe() {
+		return nil, ErrESCPOSNotAvailable
 	}
 
-	return data, nil
+	company := uc.danfeCompany(ctx, nfce)
+
+	var buf bytes.Buffer
+	if err := uc.danfe.RenderESCPOS(&buf, company, nfce, nfce.ChaveAcesso, danfeOptions(nfce, company, opts)); err != nil {
+		return nil, err
+	}
+
+	return &dto.NFceFile{
+		Content:     io.NopCloser(&buf),
+		Size:        int64(buf.Len()),
+		ContentType: dto.ContentTypeESCPOS,
+		Filename:    fmt.Sprintf("nfce-%s.prn", nfce.ChaveAcesso),
+	}, nil
 }
 
-// DownloadQRCode downloads the QR Code image for an NFC-e
-func (uc *nfceUseCase) DownloadQRCode(ctx context.Context, id string) ([]byte, error) {
-	// Get NFC-e request
-	nfce, err := uc.repo.GetByID(ctx, id)
+// danfeCompany loads the issuer printed on the DANFE header; without it the DANFE is still
+// printed, lacking the issuer name and address
+func (uc *nfceUseCase) danfeCompany(ctx context.Context, nfce *entity.NFCE) *entity.Company {
+	company, err := uc.companyRepo.GetByID(ctx, nfce.CompanyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get NFC-e: %w", err)
+		return nil
+	}
+	return company
+}
+
+// danfeOptions resolves the requested DANFE options; without them, the request options and
+// company defaults the stored DANFE was rendered with apply
+func danfeOptions(nfce *entity.NFCE, company *entity.Company, opts *dto.DANFEOptions) danfe.Options {
+	resolved := danfe.Options{DuasVias: nfce.Payload.Options.DanfeDuasVias}
+	if company != nil {
+		resolved.DuasVias = resolved.DuasVias || company.DANFE.DuasVias
+		resolved.CompactoEstabelecimento = company.DANFE.CompactoEstabelecimento
+		resolved.Papel = company.DANFE.Papel
+	}
+	if opts == nil {
+		return resolved
 	}
 
-	// Check if authorized
-	if nfce.Status != entity.RequestStatusAuthorized && nfce.Status != entity.RequestStatusContingency {
-		return nil, errors.New("NFC-e is not authorized")
+	resolved.DuasVias = opts.Vias == 2
+	resolved.CompactoEstabelecimento = opts.Compacto
+	if opts.Papel != "" {
+		resolved.Papel = opts.Papel
+	}
+	return resolved
+}
+
+// DownloadQRCode opens the QR Code image of a company NFC-e for streaming
+func (uc *nfceUseCase) DownloadQRCode(ctx context.Context, companyID, id string) (*dto.NFceFile, error) {
+	nfce, err := uc.documentsOf(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if nfce.DocumentsPurged() {
+		return nil, ErrDocumentsPurged
 	}
 
 	// Check if QR Code URL exists
 	if nfce.QRCodeURL == "" {
-		return nil, errors.New("QR Code file not found")
+		return nil, ErrQRCodeNotFound
 	}
 
-	// Construct the key from the known pattern
 	key := fmt.Sprintf("nfce/%s/qr/%s.png", nfce.CompanyID, nfce.ChaveAcesso)
+	return uc.openFile(ctx, key, ErrQRCodeNotFound, "image/png", fmt.Sprintf("qrcode-%s.png", nfce.ChaveAcesso))
+}
+
+// nfceOf returns the NFC-e of the company. NFC-e of other companies are reported as not
+// found, so their existence does not leak.
+func (uc *nfceUseCase) nfceOf(ctx context.Context, companyID, id string) (*entity.NFCE, error) {
+	nfce, err := uc.repo.GetByIDForCompany(ctx, companyID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFC-e: %w", err)
+	}
+	if nfce == nil {
+		return nil, ErrNFCeNotFound
+	}
+	return nfce, nil
+}
+
+// documentsOf returns the company NFC-e whose documents can be downloaded
+func (uc *nfceUseCase) documentsOf(ctx context.Context, companyID, id string) (*entity.NFCE, error) {
+	nfce, err := uc.nfceOf(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Canceled NFC-e keep the XML and DANFE of their authorization
+	if nfce.Status != entity.RequestStatusAuthorized &&
+		nfce.Status != entity.RequestStatusContingency &&
+		nfce.Status != entity.RequestStatusCanceled {
+		return nil, ErrNFCeNotAuthorized
+	}
+
+	return nfce, nil
+}
+
+// openFile opens a stored NFC-e document, reporting notFound when storage cannot provide it
+func (uc *nfceUseCase) openFile(ctx context.Context, key string, notFound error, contentType, filename string) (*dto.NFceFile, error) {
+	exists, err := uc.storage.FileExists(ctx, "", key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check file: %w", err)
+	}
+	if !exists {
+		return nil, notFound
+	}
 
-	// Download file
-	data, err := uc.storage.DownloadFile(ctx, "", key)
+	content, err := uc.storage.OpenFile(ctx, "", key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download QR Code file: %w", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 
-	return data, nil
+	return &dto.NFceFile{
+		Content:     content,
+		Size:        -1,
+		ContentType: contentType,
+		Filename:    filename,
+	}, nil
 }
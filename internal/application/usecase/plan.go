@@ -7,6 +7,7 @@ import (
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
 )
 
 // PlanUseCase defines the interface for plan operations
@@ -36,13 +37,14 @@ func NewPlanUseCase(planRepo ports.PlanRepository) PlanUseCase {
 func (uc *PlanUseCaseImpl) Create(ctx context.Context, req dto.CreatePlanRequest) (*dto.PlanDTO, error) {
 	plan, err := entity.NewPlan(req.Name, req.Description, entity.PlanType(req.Type), req.Price)
 	if err != nil {
-		return nil, err
+		return nil, invalid(err)
 	}
 
 	err = uc.planRepo.Create(ctx, plan)
 	if err != nil {
 		return nil, err
 	}
+	service.AuditChanged(ctx, entity.AuditResourcePlan, plan.ID, nil, plan)
 
 	return uc.planMapper.ToPlanDTO(plan), nil
 }
@@ -75,6 +77,7 @@ func (uc *PlanUseCaseImpl) Update(ctx context.Context, id string, req dto.Update
 	if err != nil {
 		return err
 	}
+	before := entity.NewAuditSnapshot(plan)
 
 	// Apply updates from request
 	if req.Name != nil {
@@ -114,6 +117,8 @@ func (uc *PlanUseCaseImpl) Update(ctx context.Context, id string, req dto.Update
 			WebhookSupport:     req.Features.WebhookSupport,
 			PrioritySupport:    req.Features.PrioritySupport,
 			StorageDays:        req.Features.StorageDays,
+			RequestsPerMinute:  req.Features.RequestsPerMinute,
+			EmissionsPerMinute: req.Features.EmissionsPerMinute,
 		}
 	}
 	if req.IsPopular != nil {
@@ -126,7 +131,11 @@ func (uc *PlanUseCaseImpl) Update(ctx context.Context, id string, req dto.Update
 		plan.TrialDays = *req.TrialDays
 	}
 
-	return uc.planRepo.Update(ctx, plan)
+	if err := uc.planRepo.Update(ctx, plan); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourcePlan, plan.ID, before, plan)
+	return nil
 }
 
 // Archive archives a plan
@@ -135,7 +144,12 @@ func (uc *PlanUseCaseImpl) Archive(ctx context.Context, id string) error {
 	if err != nil {
 		return err
 	}
+	before := entity.NewAuditSnapshot(plan)
 
 	plan.Archive()
-	return uc.planRepo.Update(ctx, plan)
+	if err := uc.planRepo.Update(ctx, plan); err != nil {
+		return err
+	}
+	service.AuditChanged(ctx, entity.AuditResourcePlan, plan.ID, before, plan)
+	return nil
 }
@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/danfe"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/qr"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// ErrInvalidChaveAcesso is returned when the chave is not the 44 digits of an NFC-e chave de acesso
+var ErrInvalidChaveAcesso = errors.New("invalid chave de acesso")
+
+// publicConsultaTTL is how long the SEFAZ answer for a chave is reused, so the public
+// endpoint does not repeat consultas SEFAZ treats as misuse (cStat 656)
+const publicConsultaTTL = 5 * time.Minute
+
+// PublicUseCase defines the interface of the public consulta of an NFC-e by its chave de acesso
+type PublicUseCase interface {
+	// GetNFCe returns the summary of the NFC-e with its situation at SEFAZ. Only the NFC-e
+	// issued through the API are known; the consulta uses the certificate of their company.
+	GetNFCe(ctx context.Context, chave string) (*dto.PublicNFCeResponse, error)
+}
+
+// PublicUseCaseImpl handles the public consulta of the NFC-e
+type PublicUseCaseImpl struct {
+	nfceRepo    ports.NFCeRepository
+	companyRepo ports.CompanyRepository
+	worker      *service.NFCeWorkerService
+	logger      logger.Logger
+
+	mu        sync.Mutex
+	consultas map[string]publicConsulta
+}
+
+// publicConsulta is a SEFAZ answer kept for publicConsultaTTL
+type publicConsulta struct {
+	situation *dto.PublicSEFAZSituation
+	situacao  string
+}
+
+// NewPublicUseCase creates a new PublicUseCase
+func NewPublicUseCase(nfceRepo ports.NFCeRepository, companyRepo ports.CompanyRepository, worker *service.NFCeWorkerService, l logger.Logger) PublicUseCase {
+	return &PublicUseCaseImpl{
+		nfceRepo:    nfceRepo,
+		companyRepo: companyRepo,
+		worker:      worker,
+		logger:      l,
+		consultas:   make(map[string]publicConsulta),
+	}
+}
+
+// GetNFCe looks up the authorized or canceled NFC-e with the chave and queries
+// NfeConsultaProtocolo4 for its situation. When SEFAZ does not answer, the summary carries
+// the situation recorded here and no sefaz section.
+func (uc *PublicUseCaseImpl) GetNFCe(ctx context.Context, chave string) (*dto.PublicNFCeResponse, error) {
+	if !isNFCeChave(chave) {
+		return nil, ErrInvalidChaveAcesso
+	}
+
+	nfce, err := uc.nfceRepo.GetByChaveAcesso(ctx, chave)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFC-e: %w", err)
+	}
+	// NFC-e SEFAZ never received are not disclosed
+	if nfce == nil || (nfce.Status != entity.RequestStatusAuthorized &&
+		nfce.Status != entity.RequestStatusContingency &&
+		nfce.Status != entity.RequestStatusCanceled) {
+		return nil, ErrNFCeNotFound
+	}
+
+	company, err := uc.companyRepo.GetByID(ctx, nfce.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company: %w", err)
+	}
+
+	response := publicSummary(nfce, company)
+	if consulta, ok := uc.consult(ctx, nfce); ok {
+		response.SEFAZ = consulta.situation
+		response.Situacao = consulta.situacao
+	}
+	return response, nil
+}
+
+// consult returns the SEFAZ answer for the NFC-e, reusing the one of the last publicConsultaTTL
+func (uc *PublicUseCaseImpl) consult(ctx context.Context, nfce *entity.NFCE) (publicConsulta, bool) {
+	now := time.Now()
+	uc.mu.Lock()
+	cached, ok := uc.consultas[nfce.ChaveAcesso]
+	uc.mu.Unlock()
+	if ok && now.Sub(cached.situation.ConsultadaEm) < publicConsultaTTL {
+		return cached, true
+	}
+
+	answer, err := uc.worker.ConsultProtocol(ctx, nfce)
+	if err != nil {
+		logger.FromContext(ctx, uc.logger).Warn("Public consulta failed", logger.Chave(nfce.ChaveAcesso), logger.Err(err))
+		return publicConsulta{}, false
+	}
+
+	consulta := publicConsulta{
+		situation: &dto.PublicSEFAZSituation{
+			CStat:        answer.CStat,
+			XMotivo:      answer.Motivo,
+			Protocolo:    answer.Protocolo,
+			ConsultadaEm: now,
+		},
+		situacao: publicSituacao(answer.CStat),
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	for chave, entry := range uc.consultas {
+		if now.Sub(entry.situation.ConsultadaEm) >= publicConsultaTTL {
+			delete(uc.consultas, chave)
+		}
+	}
+	uc.consultas[nfce.ChaveAcesso] = consulta
+	return consulta, true
+}
+
+// publicSituacao names the situation of the NFC-e answered by SEFAZ with cStat
+func publicSituacao(cStat string) string {
+	switch cStat {
+	case "100", "150": // Autorizado o uso
+		return dto.PublicSituacaoAutorizada
+	case "101", "151", "155": // Cancelamento homologado
+		return dto.PublicSituacaoCancelada
+	case "217": // NF-e não consta na base de dados da SEFAZ
+		return dto.PublicSituacaoNaoEncontrada
+	default:
+		return dto.PublicSituacaoDesconhecida
+	}
+}
+
+// publicSummary builds the consumer-facing summary of the NFC-e. The consumer identification
+// is left out: anyone holding the chave can open the consulta.
+func publicSummary(nfce *entity.NFCE, company *entity.Company) *dto.PublicNFCeResponse {
+	payload := nfce.Payload
+	response := &dto.PublicNFCeResponse{
+		ChaveAcesso: nfce.ChaveAcesso,
+		Numero:      nfce.Numero,
+		Serie:       nfce.Serie,
+		Ambiente:    payload.Ambiente,
+		Situacao:    dto.PublicSituacaoAutorizada,
+		EmitidaEm:   nfce.CreatedAt,
+		Emitente: dto.PublicEmitente{
+			CNPJ:         company.CNPJ,
+			RazaoSocial:  company.RazaoSocial,
+			NomeFantasia: company.NomeFantasia,
+		},
+		Itens:               make([]dto.PublicItem, 0, len(payload.Itens)),
+		Pagamentos:          make([]dto.PublicPayment, 0, len(payload.Pagamentos)),
+		ValorTotal:          payload.Total(),
+		Troco:               payload.Change(),
+		TributosAproximados: nfce.VTotTrib,
+		ConsultaURL:         qr.ConsultaURL(payload.UF, payload.Ambiente),
+	}
+	if nfce.Status == entity.RequestStatusCanceled {
+		response.Situacao = dto.PublicSituacaoCancelada
+	}
+	if nfce.AuthorizedAt != nil {
+		response.EmitidaEm = *nfce.AuthorizedAt
+	}
+
+	for _, item := range payload.Itens {
+		response.Itens = append(response.Itens, dto.PublicItem{
+			Descricao:     item.Descricao,
+			Quantidade:    item.Quantidade,
+			Unidade:       item.Unidade,
+			ValorUnitario: item.Valor,
+			ValorTotal:    item.Gross() - item.Desconto + item.Outros,
+		})
+		response.Desconto += item.Desconto
+	}
+	for _, payment := range payload.Pagamentos {
+		response.Pagamentos = append(response.Pagamentos, dto.PublicPayment{
+			Forma: danfe.PaymentLabel(payment.Forma),
+			Valor: payment.Valor,
+		})
+	}
+	return response
+}
+
+// isNFCeChave reports whether chave has the 44 digits of a chave de acesso of model 65
+func isNFCeChave(chave string) bool {
+	if len(chave) != 44 {
+		return false
+	}
+	for _, char := range chave {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return chave[20:22] == "65"
+}
@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+// maxSeriesGaps bounds the gap report of a series
+const maxSeriesGaps = 500
+
+var (
+	// ErrSeriesNotFound is returned when the series does not exist for the company
+	ErrSeriesNotFound = errors.New("numbering series not found")
+	// ErrSeriesAlreadyExists is returned when the company already has the series of the model in the environment
+	ErrSeriesAlreadyExists = errors.New("numbering series already exists")
+	// ErrSeriesInactive is returned when an inactive series is made the default
+	ErrSeriesInactive = errors.New("numbering series is inactive")
+)
+
+// SeriesUseCase defines the interface for NFC-e numbering series operations
+type SeriesUseCase interface {
+	ListSeries(ctx context.Context, companyID, modelo, ambiente string) (*dto.NumberingSeriesListResponse, error)
+	CreateSeries(ctx context.Context, companyID string, req dto.CreateNumberingSeriesRequest) (*dto.NumberingSeriesResponse, error)
+	UpdateSeries(ctx context.Context, companyID, id string, req dto.UpdateNumberingSeriesRequest) (*dto.NumberingSeriesResponse, error)
+	SetDefaultSeries(ctx context.Context, companyID, id string) (*dto.NumberingSeriesResponse, error)
+	GetGaps(ctx context.Context, companyID, id string) (*dto.NumberingGapsResponse, error)
+}
+
+// SeriesUseCaseImpl handles NFC-e numbering series operations
+type SeriesUseCaseImpl struct {
+	seriesRepo   ports.NumberingSeriesRepository
+	txManager    ports.TxManager
+	seriesMapper *mapper.SeriesMapper
+}
+
+// NewSeriesUseCase creates a new SeriesUseCase
+func NewSeriesUseCase(seriesRepo ports.NumberingSeriesRepository, txManager ports.TxManager) SeriesUseCase {
+	return &SeriesUseCaseImpl{
+		seriesRepo:   seriesRepo,
+		txManager:    txManager,
+		seriesMapper: mapper.NewSeriesMapper(),
+	}
+}
+
+// ListSeries lists the series of a company, of one model and environment when set
+func (uc *SeriesUseCaseImpl) ListSeries(ctx context.Context, companyID, modelo, ambiente string) (*dto.NumberingSeriesListResponse, error) {
+	series, err := uc.seriesRepo.ListByCompanyID(ctx, companyID, modelo, ambiente)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.NumberingSeriesResponse, len(series))
+	for i, s := range series {
+		responses[i] = *uc.seriesMapper.ToSeriesResponse(s)
+	}
+
+	return &dto.NumberingSeriesListResponse{Series: responses}, nil
+}
+
+// CreateSeries registers a series; the first one of a model in an environment becomes its default
+func (uc *SeriesUseCaseImpl) CreateSeries(ctx context.Context, companyID string, req dto.CreateNumberingSeriesRequest) (*dto.NumberingSeriesResponse, error) {
+	if req.Serie == nil {
+		return nil, &ValidationError{Field: "serie", Err: errors.New("série é obrigatória")}
+	}
+
+	modelo := req.Modelo
+	if modelo == "" {
+		modelo = entity.ModeloNFCe
+	}
+
+	series, err := entity.NewNumberingSeries(companyID, modelo, *req.Serie, req.Ambiente, req.NextNumber)
+	if err != nil {
+		return nil, invalid(err)
+	}
+
+	create := func(ctx context.Context) error {
+		existing, err := uc.seriesRepo.ListByCompanyID(ctx, companyID, modelo, req.Ambiente)
+		if err != nil {
+			return fmt.Errorf("failed to list series: %w", err)
+		}
+		for _, s := range existing {
+			if s.Serie == series.Serie {
+				return ErrSeriesAlreadyExists
+			}
+		}
+
+		if err := uc.seriesRepo.Create(ctx, series); err != nil {
+			return fmt.Errorf("failed to create series: %w", err)
+		}
+		if req.Default || len(existing) == 0 {
+			if err := uc.seriesRepo.SetDefault(ctx, series); err != nil {
+				return fmt.Errorf("failed to set default series: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if uc.txManager != nil {
+		err = uc.txManager.WithinTx(ctx, create)
+	} else {
+		err = create(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.seriesMapper.ToSeriesResponse(series), nil
+}
+
+// UpdateSeries advances the next number or (de)activates a series
+func (uc *SeriesUseCaseImpl) UpdateSeries(ctx context.Context, companyID, id string, req dto.UpdateNumberingSeriesRequest) (*dto.NumberingSeriesResponse, error) {
+	series, err := uc.getCompanySeries(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.NextNumber != nil {
+		if err := series.SetNextNumber(*req.NextNumber); err != nil {
+			return nil, invalid(err)
+		}
+	}
+	if req.Active != nil {
+		if *req.Active {
+			series.Activate()
+		} else if err := series.Deactivate(); err != nil {
+			return nil, invalid(err)
+		}
+	}
+
+	if err := uc.seriesRepo.Update(ctx, series); err != nil {
+		return nil, fmt.Errorf("failed to update series: %w", err)
+	}
+
+	return uc.seriesMapper.ToSeriesResponse(series), nil
+}
+
+// SetDefaultSeries makes the series the one used by emissions that do not choose a series
+func (uc *SeriesUseCaseImpl) SetDefaultSeries(ctx context.Context, companyID, id string) (*dto.NumberingSeriesResponse, error) {
+	series, err := uc.getCompanySeries(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !series.Active {
+		return nil, ErrSeriesInactive
+	}
+
+	if err := uc.seriesRepo.SetDefault(ctx, series); err != nil {
+		return nil, fmt.Errorf("failed to set default series: %w", err)
+	}
+
+	return uc.seriesMapper.ToSeriesResponse(series), nil
+}
+
+// GetGaps reports the numbers of the series that were allocated but are held by no NFC-e
+// nor inutilização, so they can be inutilized
+func (uc *SeriesUseCaseImpl) GetGaps(ctx context.Context, companyID, id string) (*dto.NumberingGapsResponse, error) {
+	series, err := uc.getCompanySeries(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	gaps, err := uc.seriesRepo.FindGaps(ctx, series, maxSeriesGaps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find numbering gaps: %w", err)
+	}
+
+	return uc.seriesMapper.ToGapsResponse(series, gaps), nil
+}
+
+// getCompanySeries loads a series ensuring it belongs to the company
+func (uc *SeriesUseCaseImpl) getCompanySeries(ctx context.Context, companyID, id string) (*entity.NumberingSeries, error) {
+	series, err := uc.seriesRepo.GetByID(ctx, id)
+	if err != nil || series.CompanyID != companyID {
+		return nil, ErrSeriesNotFound
+	}
+
+	return series, nil
+}
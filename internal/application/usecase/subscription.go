@@ -2,11 +2,27 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+)
+
+var (
+	// ErrStatementNotFound is returned when the company has no usage statement for the period
+	ErrStatementNotFound = errors.New("statement not found")
+	// ErrSubscriptionNotFound is returned when the subscription does not exist or belongs to another company
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrPlanNotFound is returned when the requested plan does not exist
+	ErrPlanNotFound = errors.New("plan not found")
+	// ErrInvalidPlanChange wraps the reasons a subscription cannot switch to the plan
+	ErrInvalidPlanChange = errors.New("invalid plan change")
 )
 
 // SubscriptionUseCase defines the interface for subscription operations
@@ -17,7 +33,11 @@ type SubscriptionUseCase interface {
 	List(ctx context.Context, limit, offset int) (*dto.SubscriptionListResponse, error)
 	Update(ctx context.Context, id string, req dto.UpdateSubscriptionRequest) error
 	Cancel(ctx context.Context, id string, req dto.CancelSubscriptionRequest) error
+	ChangePlan(ctx context.Context, id, companyID string, req dto.ChangePlanRequest) (*dto.PlanChangeResponse, error)
 	GetUsage(ctx context.Context, companyID string) (*dto.UsageStats, error)
+	ListStatements(ctx context.Context, companyID string, limit, offset int) (*dto.UsageStatementListResponse, error)
+	GetStatement(ctx context.Context, companyID, period string) (*dto.UsageStatementDTO, error)
+	ListInvoices(ctx context.Context, companyID string, limit, offset int) (*dto.InvoiceListResponse, error)
 }
 
 // SubscriptionUseCaseImpl handles subscription operations
@@ -25,6 +45,11 @@ type SubscriptionUseCaseImpl struct {
 	subscriptionRepo   ports.SubscriptionRepository
 	planRepo           ports.PlanRepository
 	companyRepo        ports.CompanyRepository
+	txManager          ports.TxManager
+	statementRepo      ports.StatementRepository
+	invoiceRepo        ports.InvoiceRepository
+	eventRepo          ports.SubscriptionEventRepository
+	storage            storage.StorageService
 	subscriptionMapper *mapper.SubscriptionMapper
 }
 
@@ -33,11 +58,21 @@ func NewSubscriptionUseCase(
 	subscriptionRepo ports.SubscriptionRepository,
 	planRepo ports.PlanRepository,
 	companyRepo ports.CompanyRepository,
+	txManager ports.TxManager,
+	statementRepo ports.StatementRepository,
+	invoiceRepo ports.InvoiceRepository,
+	eventRepo ports.SubscriptionEventRepository,
+	storage storage.StorageService,
 ) SubscriptionUseCase {
 	return &SubscriptionUseCaseImpl{
 		subscriptionRepo:   subscriptionRepo,
 		planRepo:           planRepo,
 		companyRepo:        companyRepo,
+		txManager:          txManager,
+		statementRepo:      statementRepo,
+		invoiceRepo:        invoiceRepo,
+		eventRepo:          eventRepo,
+		storage:            storage,
 		subscriptionMapper: mapper.NewSubscriptionMapper(),
 	}
 }
@@ -51,13 +86,15 @@ func (uc *SubscriptionUseCaseImpl) Create(ctx context.Context, req dto.CreateSub
 
 	subscription, err := entity.NewSubscription(req.CompanyID, req.PlanID, plan)
 	if err != nil {
-		return nil, err
+		return nil, invalid(err)
 	}
 
-	err = uc.subscriptionRepo.Create(ctx, subscription)
+	err = replaceActiveSubscription(ctx, uc.txManager, uc.subscriptionRepo, subscription)
 	if err != nil {
 		return nil, err
 	}
+	service.AuditCompany(ctx, subscription.CompanyID)
+	service.AuditChanged(ctx, entity.AuditResourceSubscription, subscription.ID, nil, subscription)
 
 	return uc.subscriptionMapper.ToSubscriptionDTO(subscription), nil
 }
@@ -100,6 +137,7 @@ func (uc *SubscriptionUseCaseImpl) Update(ctx context.Context, id string, req dt
 	if err != nil {
 		return err
 	}
+	before := entity.NewAuditSnapshot(subscription)
 
 	// Apply updates from request
 	if req.Status != nil {
@@ -111,8 +149,40 @@ func (uc *SubscriptionUseCaseImpl) Update(ctx context.Context, id string, req dt
 	if req.CancelReason != nil {
 		subscription.CancelReason = *req.CancelReason
 	}
+	billingChanged := false
+	if req.PaymentMethod != nil && *req.PaymentMethod != subscription.BillingInfo.PaymentMethod {
+		subscription.BillingInfo.PaymentMethod = *req.PaymentMethod
+		billingChanged = true
+	}
+	if req.CustomerID != nil && *req.CustomerID != subscription.BillingInfo.CustomerID {
+		subscription.BillingInfo.CustomerID = *req.CustomerID
+		billingChanged = true
+	}
 
-	return uc.subscriptionRepo.Update(ctx, subscription)
+	if err := uc.subscriptionRepo.Update(ctx, subscription); err != nil {
+		return err
+	}
+	service.AuditCompany(ctx, subscription.CompanyID)
+	service.AuditChanged(ctx, entity.AuditResourceSubscription, subscription.ID, before, subscription)
+
+	if billingChanged {
+		return uc.retryOpenInvoice(ctx, subscription.ID)
+	}
+	return nil
+}
+
+// retryOpenInvoice schedules the failed invoice of the subscription for a charge on the next
+// billing run, e.g. after the customer replaced a declined card
+func (uc *SubscriptionUseCaseImpl) retryOpenInvoice(ctx context.Context, subscriptionID string) error {
+	invoice, err := uc.invoiceRepo.GetOpenBySubscriptionID(ctx, subscriptionID)
+	if err != nil || invoice == nil || invoice.Status != entity.InvoiceStatusFailed {
+		return err
+	}
+
+	now := time.Now()
+	invoice.NextAttemptAt = &now
+	invoice.UpdatedAt = now
+	return uc.invoiceRepo.Update(ctx, invoice)
 }
 
 // Cancel cancels a subscription
@@ -121,9 +191,64 @@ func (uc *SubscriptionUseCaseImpl) Cancel(ctx context.Context, id string, req dt
 	if err != nil {
 		return err
 	}
+	before := entity.NewAuditSnapshot(subscription)
 
 	subscription.Cancel(req.Reason)
-	return uc.subscriptionRepo.Update(ctx, subscription)
+	if err := uc.subscriptionRepo.Update(ctx, subscription); err != nil {
+		return err
+	}
+	service.AuditCompany(ctx, subscription.CompanyID)
+	service.AuditChanged(ctx, entity.AuditResourceSubscription, subscription.ID, before, subscription)
+	return nil
+}
+
+// ChangePlan switches the subscription to another plan in the middle of the billing cycle,
+// prorating the price difference into the next invoice and carrying the NFC-e issued in the
+// period over to the new quota. A company ID, when given, must own the subscription.
+func (uc *SubscriptionUseCaseImpl) ChangePlan(ctx context.Context, id, companyID string, req dto.ChangePlanRequest) (*dto.PlanChangeResponse, error) {
+	subscription, err := uc.subscriptionRepo.GetByID(ctx, id)
+	if err != nil || (companyID != "" && subscription.CompanyID != companyID) {
+		return nil, ErrSubscriptionNotFound
+	}
+	before := entity.NewAuditSnapshot(subscription)
+
+	plan, err := uc.planRepo.GetByID(ctx, req.PlanID)
+	if err != nil {
+		return nil, ErrPlanNotFound
+	}
+	if current, err := uc.planRepo.GetByID(ctx, subscription.PlanID); err == nil {
+		subscription.Plan = current
+	}
+
+	event, err := subscription.ChangePlan(plan, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPlanChange, err)
+	}
+
+	change := func(ctx context.Context) error {
+		if err := uc.subscriptionRepo.Update(ctx, subscription); err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+		if err := uc.eventRepo.Create(ctx, event); err != nil {
+			return fmt.Errorf("failed to record plan change: %w", err)
+		}
+		return nil
+	}
+	if uc.txManager == nil {
+		err = change(ctx)
+	} else {
+		err = uc.txManager.WithinTx(ctx, change)
+	}
+	if err != nil {
+		return nil, err
+	}
+	service.AuditCompany(ctx, subscription.CompanyID)
+	service.AuditChanged(ctx, entity.AuditResourceSubscription, subscription.ID, before, subscription)
+
+	return &dto.PlanChangeResponse{
+		Subscription: uc.subscriptionMapper.ToSubscriptionDTO(subscription),
+		Change:       *uc.subscriptionMapper.ToSubscriptionEventDTO(event),
+	}, nil
 }
 
 // GetUsage gets the usage statistics for a company's current subscription
@@ -143,3 +268,88 @@ func (uc *SubscriptionUseCaseImpl) GetUsage(ctx context.Context, companyID strin
 
 	return usageStats, nil
 }
+
+// ListStatements lists the monthly usage statements of a company, newest first
+func (uc *SubscriptionUseCaseImpl) ListStatements(ctx context.Context, companyID string, limit, offset int) (*dto.UsageStatementListResponse, error) {
+	statements, total, err := uc.statementRepo.ListByCompanyID(ctx, companyID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]dto.UsageStatementDTO, len(statements))
+	for i, statement := range statements {
+		dtos[i] = *uc.toStatementDTO(ctx, statement)
+	}
+
+	return &dto.UsageStatementListResponse{
+		Statements: dtos,
+		Total:      total,
+	}, nil
+}
+
+// GetStatement gets the usage statement of a company for a period (YYYY-MM)
+func (uc *SubscriptionUseCaseImpl) GetStatement(ctx context.Context, companyID, period string) (*dto.UsageStatementDTO, error) {
+	statement, err := uc.statementRepo.GetByPeriod(ctx, companyID, period)
+	if err != nil {
+		return nil, err
+	}
+	if statement == nil {
+		return nil, ErrStatementNotFound
+	}
+
+	return uc.toStatementDTO(ctx, statement), nil
+}
+
+// ListInvoices lists the invoices of the subscriptions of a company, newest first
+func (uc *SubscriptionUseCaseImpl) ListInvoices(ctx context.Context, companyID string, limit, offset int) (*dto.InvoiceListResponse, error) {
+	invoices, total, err := uc.invoiceRepo.ListByCompanyID(ctx, companyID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]dto.InvoiceDTO, len(invoices))
+	for i, invoice := range invoices {
+		dtos[i] = *uc.subscriptionMapper.ToInvoiceDTO(invoice)
+	}
+
+	return &dto.InvoiceListResponse{
+		Invoices: dtos,
+		Total:    total,
+	}, nil
+}
+
+// toStatementDTO maps a usage statement adding the download URLs of its files
+func (uc *SubscriptionUseCaseImpl) toStatementDTO(ctx context.Context, statement *entity.UsageStatement) *dto.UsageStatementDTO {
+	statementDTO := uc.subscriptionMapper.ToUsageStatementDTO(statement)
+	if url, err := uc.storage.GetFileURL(ctx, "", statement.JSONKey); err == nil {
+		statementDTO.JSONURL = url
+	}
+	if url, err := uc.storage.GetFileURL(ctx, "", statement.PDFKey); err == nil {
+		statementDTO.PDFURL = url
+	}
+	return statementDTO
+}
+
+// replaceActiveSubscription cancels the current active subscription of the company and
+// creates the new one in a single transaction, so a company never ends up with two
+// active subscriptions or none.
+func replaceActiveSubscription(ctx context.Context, txManager ports.TxManager, subscriptionRepo ports.SubscriptionRepository, subscription *entity.Subscription) error {
+	replace := func(ctx context.Context) error {
+		if current, err := subscriptionRepo.GetActiveByCompanyID(ctx, subscription.CompanyID); err == nil && current != nil {
+			current.Cancel("substituída por nova assinatura")
+			if err := subscriptionRepo.Update(ctx, current); err != nil {
+				return fmt.Errorf("failed to cancel current subscription: %w", err)
+			}
+		}
+
+		if err := subscriptionRepo.Create(ctx, subscription); err != nil {
+			return fmt.Errorf("failed to create subscription: %w", err)
+		}
+		return nil
+	}
+
+	if txManager == nil {
+		return replace(ctx)
+	}
+	return txManager.WithinTx(ctx, replace)
+}
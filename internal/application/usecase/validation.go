@@ -0,0 +1,11 @@
+package usecase
+
+import "github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+
+// ValidationError is a rule of the domain broken by the request, answered with its text
+type ValidationError = service.ValidationError
+
+// invalid marks err as a rule broken by the request
+func invalid(err error) error {
+	return &ValidationError{Err: err}
+}
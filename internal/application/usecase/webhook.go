@@ -2,20 +2,26 @@ package usecase
 
 import (
 	"context"
+	"errors"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/mapper"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
 )
 
-// WebhookUseCase defines the interface for webhook operations
+// ErrWebhookNotFound is returned when the webhook does not exist or belongs to another company
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// WebhookUseCase defines the interface for webhook operations. A company ID, when given,
+// must own the webhook.
 type WebhookUseCase interface {
 	Create(ctx context.Context, req dto.CreateWebhookRequest) (*dto.WebhookDTO, error)
-	GetByID(ctx context.Context, id string) (*dto.WebhookDTO, error)
+	GetByID(ctx context.Context, id, companyID string) (*dto.WebhookDTO, error)
 	List(ctx context.Context, companyID string, limit, offset int) (*dto.WebhookListResponse, error)
-	Update(ctx context.Context, id string, req dto.UpdateWebhookRequest) error
-	Delete(ctx context.Context, id string) error
+	Update(ctx context.Context, id, companyID string, req dto.UpdateWebhookRequest) error
+	Delete(ctx context.Context, id, companyID string) error
 }
 
 // WebhookUseCaseImpl handles webhook operations
@@ -42,7 +48,7 @@ func (uc *WebhookUseCaseImpl) Create(ctx context.Context, req dto.CreateWebhookR
 
 	webhook, err := entity.NewWebhook(req.CompanyID, req.Name, req.URL, events)
 	if err != nil {
-		return nil, err
+		return nil, invalid(err)
 	}
 
 	// Apply additional fields
@@ -50,6 +56,9 @@ func (uc *WebhookUseCaseImpl) Create(ctx context.Context, req dto.CreateWebhookR
 	webhook.Method = entity.HTTPMethod(req.Method)
 	webhook.Headers = entity.WebhookHeaders(req.Headers)
 	webhook.Secret = req.Secret
+	if err := webhook.SetFilters(uc.webhookMapper.ToWebhookFiltersEntity(req.Filters)); err != nil {
+		return nil, invalid(err)
+	}
 	if req.RetryConfig != nil {
 		webhook.RetryConfig = entity.WebhookRetryConfig{
 			MaxRetries:    req.RetryConfig.MaxRetries,
@@ -62,13 +71,15 @@ func (uc *WebhookUseCaseImpl) Create(ctx context.Context, req dto.CreateWebhookR
 	if err != nil {
 		return nil, err
 	}
+	service.AuditCompany(ctx, webhook.CompanyID)
+	service.AuditChanged(ctx, entity.AuditResourceWebhook, webhook.ID, nil, webhook)
 
 	return uc.webhookMapper.ToWebhookDTO(webhook), nil
 }
 
 // GetByID gets a webhook by ID
-func (uc *WebhookUseCaseImpl) GetByID(ctx context.Context, id string) (*dto.WebhookDTO, error) {
-	webhook, err := uc.webhookRepo.GetByID(ctx, id)
+func (uc *WebhookUseCaseImpl) GetByID(ctx context.Context, id, companyID string) (*dto.WebhookDTO, error) {
+	webhook, err := uc.get(ctx, id, companyID)
 	if err != nil {
 		return nil, err
 	}
@@ -90,11 +101,12 @@ func (uc *WebhookUseCaseImpl) List(ctx context.Context, companyID string, limit,
 }
 
 // Update updates a webhook
-func (uc *WebhookUseCaseImpl) Update(ctx context.Context, id string, req dto.UpdateWebhookRequest) error {
-	webhook, err := uc.webhookRepo.GetByID(ctx, id)
+func (uc *WebhookUseCaseImpl) Update(ctx context.Context, id, companyID string, req dto.UpdateWebhookRequest) error {
+	webhook, err := uc.get(ctx, id, companyID)
 	if err != nil {
 		return err
 	}
+	before := entity.NewAuditSnapshot(webhook)
 
 	// Apply updates from request
 	if req.Name != nil {
@@ -119,6 +131,11 @@ func (uc *WebhookUseCaseImpl) Update(ctx context.Context, id string, req dto.Upd
 		}
 		webhook.Events = events
 	}
+	if req.Filters != nil {
+		if err := webhook.SetFilters(uc.webhookMapper.ToWebhookFiltersEntity(req.Filters)); err != nil {
+			return invalid(err)
+		}
+	}
 	if req.Headers != nil {
 		webhook.Headers = entity.WebhookHeaders(req.Headers)
 	}
@@ -133,10 +150,35 @@ func (uc *WebhookUseCaseImpl) Update(ctx context.Context, id string, req dto.Upd
 		}
 	}
 
-	return uc.webhookRepo.Update(ctx, webhook)
+	if err := uc.webhookRepo.Update(ctx, webhook); err != nil {
+		return err
+	}
+	service.AuditCompany(ctx, webhook.CompanyID)
+	service.AuditChanged(ctx, entity.AuditResourceWebhook, webhook.ID, before, webhook)
+	return nil
 }
 
 // Delete deletes a webhook
-func (uc *WebhookUseCaseImpl) Delete(ctx context.Context, id string) error {
-	return uc.webhookRepo.Delete(ctx, id)
+func (uc *WebhookUseCaseImpl) Delete(ctx context.Context, id, companyID string) error {
+	webhook, err := uc.get(ctx, id, companyID)
+	if err != nil {
+		return err
+	}
+	before := entity.NewAuditSnapshot(webhook)
+
+	if err := uc.webhookRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	service.AuditCompany(ctx, webhook.CompanyID)
+	service.AuditChanged(ctx, entity.AuditResourceWebhook, id, before, nil)
+	return nil
+}
+
+// get loads a webhook, which must belong to companyID when one is given
+func (uc *WebhookUseCaseImpl) get(ctx context.Context, id, companyID string) (*entity.Webhook, error) {
+	webhook, err := uc.webhookRepo.GetByID(ctx, id)
+	if err != nil || (companyID != "" && webhook.CompanyID != companyID) {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
 }
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// fakeWebhookRepo keeps the webhooks in memory
+type fakeWebhookRepo struct {
+	ports.WebhookRepository
+	webhooks map[string]*entity.Webhook
+}
+
+func (r *fakeWebhookRepo) GetByID(_ context.Context, id string) (*entity.Webhook, error) {
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	clone := *webhook
+	return &clone, nil
+}
+
+func (r *fakeWebhookRepo) Update(_ context.Context, webhook *entity.Webhook) error {
+	r.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+func (r *fakeWebhookRepo) Delete(_ context.Context, id string) error {
+	delete(r.webhooks, id)
+	return nil
+}
+
+func newWebhookFixture() (*fakeWebhookRepo, WebhookUseCase) {
+	repo := &fakeWebhookRepo{webhooks: map[string]*entity.Webhook{
+		"wh-a": {ID: "wh-a", CompanyID: "company-a", Name: "A", URL: "https://a.example.com/hook"},
+	}}
+	return repo, NewWebhookUseCase(repo)
+}
+
+func TestWebhookUseCaseScopesByCompany(t *testing.T) {
+	name := "renamed"
+	tests := []struct {
+		name      string
+		companyID string
+		wantErr   error
+	}{
+		{name: "owner", companyID: "company-a"},
+		{name: "admin without company", companyID: ""},
+		{name: "other company", companyID: "company-b", wantErr: ErrWebhookNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			repo, uc := newWebhookFixture()
+			if _, err := uc.GetByID(ctx, "wh-a", tt.companyID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByID() error = %v, want %v", err, tt.wantErr)
+			}
+
+			err := uc.Update(ctx, "wh-a", tt.companyID, dto.UpdateWebhookRequest{Name: &name})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+			if renamed := repo.webhooks["wh-a"].Name == name; renamed != (tt.wantErr == nil) {
+				t.Fatalf("Update() renamed = %t, want %t", renamed, tt.wantErr == nil)
+			}
+
+			if err := uc.Delete(ctx, "wh-a", tt.companyID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, kept := repo.webhooks["wh-a"]; kept != (tt.wantErr != nil) {
+				t.Fatalf("Delete() kept = %t, want %t", kept, tt.wantErr != nil)
+			}
+		})
+	}
+}
+
+func TestWebhookUseCaseMissingWebhook(t *testing.T) {
+	_, uc := newWebhookFixture()
+	ctx := context.Background()
+
+	if _, err := uc.GetByID(ctx, "missing", "company-a"); !errors.Is(err, ErrWebhookNotFound) {
+		t.Fatalf("GetByID() error = %v, want %v", err, ErrWebhookNotFound)
+	}
+	if err := uc.Delete(ctx, "missing", ""); !errors.Is(err, ErrWebhookNotFound) {
+		t.Fatalf("Delete() error = %v, want %v", err, ErrWebhookNotFound)
+	}
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// defaultJWTSecret is the development placeholder that must never reach production
+const defaultJWTSecret = "your-super-secret-jwt-key-change-this-in-production"
+
+// ValidationError lists every invalid configuration field found at startup
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(e.Problems, "; "))
+}
+
+// Setting is a resolved configuration value as shown in diagnostics
+type Setting struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Secret bool        `json:"secret,omitempty"`
+}
+
+// Validate checks the struct tag rules and the combinations between fields
+func (c *AppConfig) Validate() error {
+	var problems []string
+
+	validate := validator.New()
+	validate.RegisterTagNameFunc(envName)
+
+	if err := validate.Struct(c); err != nil {
+		var fieldErrors validator.ValidationErrors
+		if !errors.As(err, &fieldErrors) {
+			return fmt.Errorf("failed to validate configuration: %w", err)
+		}
+		for _, fe := range fieldErrors {
+			problems = append(problems, describeFieldError(fe))
+		}
+	}
+
+	// Cross-field rules the tags cannot express
+	if u, err := url.Parse(c.RabbitMQURL); err == nil && u.Scheme != "amqp" && u.Scheme != "amqps" {
+		problems = append(problems, "RABBITMQ_URL must use the amqp or amqps scheme")
+	}
+	if c.DBMaxIdleConns > c.DBMaxOpenConns {
+		problems = append(problems, "DB_MAX_IDLE_CONNS must not be greater than DB_MAX_OPEN_CONNS")
+	}
+	if c.RetryBatchMax < c.RetryBatchMin {
+		problems = append(problems, "RETRY_BATCH_MAX must not be lower than RETRY_BATCH_MIN")
+	}
+	if c.RetryIntervalMax < c.RetryIntervalMin {
+		problems = append(problems, "RETRY_INTERVAL_MAX must not be lower than RETRY_INTERVAL_MIN")
+	}
+	if c.RespTecCNPJ != "" && (c.RespTecContato == "" || c.RespTecEmail == "" || c.RespTecFone == "") {
+		problems = append(problems, "RESP_TEC_CONTATO, RESP_TEC_EMAIL and RESP_TEC_FONE are required when RESP_TEC_CNPJ is set")
+	}
+	if _, err := c.RespTecCSRTs(); err != nil {
+		problems = append(problems, err.Error())
+	} else if c.RespTecCSRT != "" && c.RespTecCNPJ == "" {
+		problems = append(problems, "RESP_TEC_CSRT requires RESP_TEC_CNPJ")
+	}
+	if key, err := base64.StdEncoding.DecodeString(c.CertificateEncryptionKey); err == nil && c.CertificateEncryptionKey != "" && len(key) != 32 {
+		problems = append(problems, "CERTIFICATE_ENCRYPTION_KEY must be the base64 of 32 bytes")
+	}
+	if keys, err := c.PreviousEncryptionKeys(); err != nil {
+		problems = append(problems, err.Error())
+	} else if len(keys) > 0 && c.CertificateEncryptionKey == "" {
+		problems = append(problems, "CERTIFICATE_ENCRYPTION_PREVIOUS_KEYS requires CERTIFICATE_ENCRYPTION_KEY")
+	}
+	if c.EmailProvider != "none" && c.EmailFrom == "" {
+		problems = append(problems, "EMAIL_FROM is required when EMAIL_PROVIDER is smtp or ses")
+	}
+	if c.Env == "production" {
+		if c.JWTSecret == defaultJWTSecret {
+			problems = append(problems, "JWT_SECRET must be changed from the default value in production")
+		}
+		if c.CertificateEncryptionKey == "" {
+			problems = append(problems, "CERTIFICATE_ENCRYPTION_KEY is required in production")
+		}
+		if c.DBSSLMode == "disable" {
+			problems = append(problems, "DB_SSL_MODE=disable is not allowed in production")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Diagnostics returns the resolved configuration in declaration order, with secrets masked
+func (c *AppConfig) Diagnostics() []Setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	settings := make([]Setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		setting := Setting{
+			Key:   envName(field),
+			Value: v.Field(i).Interface(),
+		}
+
+		if d, ok := setting.Value.(time.Duration); ok {
+			setting.Value = d.String()
+		}
+
+		if field.Tag.Get("secret") == "true" {
+			setting.Secret = true
+			setting.Value = maskSecret(v.Field(i).String())
+		}
+
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+// LogDiagnostics writes the startup diagnostics report with the resolved configuration
+func (c *AppConfig) LogDiagnostics(l logger.Logger) {
+	settings := c.Diagnostics()
+	fields := make([]logger.Field, 0, len(settings))
+	for _, setting := range settings {
+		fields = append(fields, logger.Field{Key: setting.Key, Value: setting.Value})
+	}
+	l.Info("Configuration loaded", fields...)
+}
+
+// Sanitized returns the resolved configuration keyed by environment variable, with secrets masked
+func (c *AppConfig) Sanitized() map[string]interface{} {
+	sanitized := make(map[string]interface{})
+	for _, setting := range c.Diagnostics() {
+		sanitized[setting.Key] = setting.Value
+	}
+	return sanitized
+}
+
+// envName returns the environment variable name bound to the field
+func envName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("env"), ",", 2)[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// describeFieldError turns a validator error into a readable message
+func describeFieldError(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "required_if":
+		// Param is "<StructField> <value>"
+		param := strings.Fields(fe.Param())
+		if f, ok := reflect.TypeOf(AppConfig{}).FieldByName(param[0]); ok && len(param) == 2 {
+			return fmt.Sprintf("%s is required when %s=%s", fe.Field(), envName(f), param[1])
+		}
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s], got %q", fe.Field(), fe.Param(), fe.Value())
+	case "min", "max":
+		return fmt.Sprintf("%s must satisfy %s=%s, got %v", fe.Field(), fe.Tag(), fe.Param(), fe.Value())
+	default:
+		return fmt.Sprintf("%s is not a valid %s", fe.Field(), fe.Tag())
+	}
+}
+
+// maskSecret keeps the value shape recognizable without revealing it
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	// Keep scheme and host of URLs with credentials
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Redacted()
+	}
+
+	return "****"
+}
@@ -0,0 +1,231 @@
+// Package contract verifies the queue messages shared by the API and the worker
+// against golden serialized messages, so both sides can evolve independently.
+package contract
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+)
+
+//go:embed golden/*.json
+var goldenFS embed.FS
+
+// Golden is the serialized contract of a message at a given schema version
+type Golden struct {
+	Message       string            `json:"message"`
+	SchemaVersion int               `json:"schema_version"`
+	Fields        map[string]string `json:"fields"` // JSON field name -> Go type
+	Sample        json.RawMessage   `json:"sample"`
+}
+
+// message describes a message type exchanged through the broker
+type message struct {
+	name   string
+	sample interface{}
+}
+
+// sampleTime keeps the golden samples stable across generations
+var sampleTime = time.Date(2024, 12, 23, 10, 30, 0, 0, time.UTC)
+
+// messages lists every message published by the API and consumed by the worker
+var messages = []message{
+	{
+		name: "emit_message",
+		sample: dto.EmitMessage{
+			RequestID:      "550e8400-e29b-41d4-a716-446655440000",
+			CompanyID:      "3f2504e0-4f89-41d3-9a0c-0305e82c3301",
+			IdempotencyKey: "pedido-12345",
+			RetryCount:     1,
+			EnqueuedAt:     sampleTime,
+			SchemaVersion:  dto.MessageSchemaVersion,
+		},
+	},
+	{
+		name: "cancel_message",
+		sample: dto.CancelMessage{
+			RequestID:      "550e8400-e29b-41d4-a716-446655440000",
+			IdempotencyKey: "pedido-12345",
+			Justificativa:  "Venda cancelada a pedido do cliente",
+			EnqueuedAt:     sampleTime,
+			SchemaVersion:  dto.MessageSchemaVersion,
+		},
+	},
+	{
+		name: "export_message",
+		sample: dto.ExportMessage{
+			JobID:         "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+			EnqueuedAt:    sampleTime,
+			SchemaVersion: dto.MessageSchemaVersion,
+		},
+	},
+	{
+		name: "inutilizacao_message",
+		sample: dto.InutilizacaoMessage{
+			InutilizacaoID: "9b2c3d4e-5f60-4a7b-8c9d-0e1f2a3b4c5d",
+			EnqueuedAt:     sampleTime,
+			SchemaVersion:  dto.MessageSchemaVersion,
+		},
+	},
+	{
+		name: "lote_message",
+		sample: dto.LoteMessage{
+			LoteID:        "1a2b3c4d-5e6f-4a8b-9c0d-1e2f3a4b5c6d",
+			CompanyID:     "3f2504e0-4f89-41d3-9a0c-0305e82c3301",
+			EnqueuedAt:    sampleTime,
+			SchemaVersion: dto.MessageSchemaVersion,
+		},
+	},
+}
+
+// Check round-trips every golden message through the current types and reports
+// fields that were removed or retyped without bumping dto.MessageSchemaVersion.
+func Check() ([]string, error) {
+	var violations []string
+
+	for _, m := range messages {
+		golden, err := loadGolden(m.name)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, check(m, golden)...)
+	}
+
+	return violations, nil
+}
+
+// check compares a message type with its golden contract
+func check(m message, golden *Golden) []string {
+	var violations []string
+
+	t := reflect.TypeOf(m.sample)
+	current := describe(t)
+
+	// Breaking changes are only allowed together with a schema version bump
+	if golden.SchemaVersion == dto.MessageSchemaVersion {
+		for field, goldenType := range golden.Fields {
+			currentType, ok := current[field]
+			if !ok {
+				violations = append(violations, fmt.Sprintf("%s: field %q was removed without bumping schema_version", m.name, field))
+				continue
+			}
+			if currentType != goldenType {
+				violations = append(violations, fmt.Sprintf("%s: field %q changed from %s to %s without bumping schema_version", m.name, field, goldenType, currentType))
+			}
+		}
+	} else if golden.SchemaVersion < dto.MessageSchemaVersion {
+		return append(violations, fmt.Sprintf("%s: golden message is at schema_version %d, regenerate it for version %d", m.name, golden.SchemaVersion, dto.MessageSchemaVersion))
+	}
+
+	if err := roundTrip(t, golden.Sample); err != nil {
+		violations = append(violations, fmt.Sprintf("%s: %v", m.name, err))
+	}
+	return violations
+}
+
+// Generate writes the golden messages for the current types into dir
+func Generate(dir string) error {
+	for _, m := range messages {
+		sample, err := json.Marshal(m.sample)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s sample: %w", m.name, err)
+		}
+
+		golden := Golden{
+			Message:       m.name,
+			SchemaVersion: dto.MessageSchemaVersion,
+			Fields:        describe(reflect.TypeOf(m.sample)),
+			Sample:        sample,
+		}
+
+		data, err := json.MarshalIndent(golden, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s golden: %w", m.name, err)
+		}
+
+		path := filepath.Join(dir, m.name+".json")
+		if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// loadGolden reads the embedded golden message
+func loadGolden(name string) (*Golden, error) {
+	data, err := goldenFS.ReadFile("golden/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("missing golden message %s: %w", name, err)
+	}
+
+	var golden Golden
+	if err := json.Unmarshal(data, &golden); err != nil {
+		return nil, fmt.Errorf("invalid golden message %s: %w", name, err)
+	}
+	return &golden, nil
+}
+
+// roundTrip decodes the golden sample strictly into t and checks that encoding it again yields the same JSON
+func roundTrip(t reflect.Type, sample json.RawMessage) error {
+	value := reflect.New(t)
+
+	decoder := json.NewDecoder(bytes.NewReader(sample))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value.Interface()); err != nil {
+		return fmt.Errorf("golden sample does not decode: %w", err)
+	}
+
+	encoded, err := json.Marshal(value.Elem().Interface())
+	if err != nil {
+		return fmt.Errorf("failed to encode decoded sample: %w", err)
+	}
+
+	var want, got map[string]interface{}
+	if err := json.Unmarshal(sample, &want); err != nil {
+		return fmt.Errorf("invalid golden sample: %w", err)
+	}
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		return fmt.Errorf("invalid encoded sample: %w", err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("round trip mismatch: golden %s, got %s", sample, encoded)
+	}
+	return nil
+}
+
+// describe maps the JSON field names of a struct to their Go types
+func describe(t reflect.Type) map[string]string {
+	fields := make(map[string]string, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type.String()
+	}
+	return fields
+}
+
+// Names returns the names of the checked messages
+func Names() []string {
+	names := make([]string, 0, len(messages))
+	for _, m := range messages {
+		names = append(names, m.name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,130 @@
+package contract
+
+import (
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+)
+
+var update = flag.Bool("update", false, "regenerate the golden messages after bumping dto.MessageSchemaVersion")
+
+// TestGoldenMessages fails when a message published by the API and consumed by the worker
+// breaks its golden contract. Run with -update to regenerate the golden messages.
+func TestGoldenMessages(t *testing.T) {
+	if *update {
+		if err := Generate("golden"); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		t.Skip("golden messages regenerated; rerun without -update")
+	}
+
+	violations, err := Check()
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	for _, violation := range violations {
+		t.Error(violation)
+	}
+}
+
+// TestEveryMessageHasGolden keeps the golden directory in step with the checked messages
+func TestEveryMessageHasGolden(t *testing.T) {
+	entries, err := goldenFS.ReadDir("golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(messages) {
+		t.Fatalf("golden has %d files, want one per message %v", len(entries), Names())
+	}
+	for _, name := range Names() {
+		golden, err := loadGolden(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if golden.Message != name {
+			t.Errorf("golden %s names the message %q", name, golden.Message)
+		}
+	}
+}
+
+// oldEmitMessage is dto.EmitMessage as a previous release published it
+type oldEmitMessage struct {
+	RequestID     string    `json:"request_id"`
+	RetryCount    int       `json:"retry_count"`
+	EnqueuedAt    time.Time `json:"enqueued_at"`
+	SchemaVersion int       `json:"schema_version"`
+}
+
+func TestCheckReportsBreakingChanges(t *testing.T) {
+	current := message{name: "emit_message", sample: oldEmitMessage{
+		RequestID:     "550e8400-e29b-41d4-a716-446655440000",
+		EnqueuedAt:    sampleTime,
+		SchemaVersion: dto.MessageSchemaVersion,
+	}}
+	sample, err := json.Marshal(current.sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		golden Golden
+		want   string
+	}{
+		{
+			name: "compatible",
+			golden: Golden{SchemaVersion: dto.MessageSchemaVersion, Sample: sample, Fields: map[string]string{
+				"request_id": "string", "retry_count": "int",
+			}},
+		},
+		{
+			name: "field removed",
+			golden: Golden{SchemaVersion: dto.MessageSchemaVersion, Sample: sample, Fields: map[string]string{
+				"request_id": "string", "company_id": "string",
+			}},
+			want: `field "company_id" was removed`,
+		},
+		{
+			name: "field retyped",
+			golden: Golden{SchemaVersion: dto.MessageSchemaVersion, Sample: sample, Fields: map[string]string{
+				"retry_count": "string",
+			}},
+			want: `field "retry_count" changed from string to int`,
+		},
+		{
+			name: "sample with an unknown field",
+			golden: Golden{SchemaVersion: dto.MessageSchemaVersion, Fields: map[string]string{},
+				Sample: json.RawMessage(`{"request_id":"x","lote_id":"y"}`)},
+			want: "golden sample does not decode",
+		},
+		{
+			name:   "stale golden",
+			golden: Golden{SchemaVersion: dto.MessageSchemaVersion - 1, Sample: sample},
+			want:   "regenerate it for version",
+		},
+		{
+			// Golden messages of a newer release are only round-tripped
+			name:   "newer golden",
+			golden: Golden{SchemaVersion: dto.MessageSchemaVersion + 1, Sample: sample, Fields: map[string]string{"gone": "string"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := check(current, &tt.golden)
+			if tt.want == "" {
+				if len(violations) > 0 {
+					t.Fatalf("check() = %v, want no violations", violations)
+				}
+				return
+			}
+			if len(violations) != 1 || !strings.Contains(violations[0], tt.want) {
+				t.Fatalf("check() = %v, want one violation containing %q", violations, tt.want)
+			}
+		})
+	}
+}
@@ -3,42 +3,80 @@ package di
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/cache"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/cep"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/clock"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/database/postgres"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/email"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/grpcserver"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/handler"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/middleware"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/server"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/messaging/rabbitmq"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/outbox"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/payment"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/ratelimit"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/secret"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/danfe"
 	nfceInfra "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/qr"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/signer"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/tables"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/validator"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/statement"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/webhook"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/worker"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/database"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+	"gorm.io/gorm"
 )
 
 // InitializeAPIManual initializes the entire API application manually (alternative to wire)
 func InitializeAPIManual(ctx context.Context, cfg *config.AppConfig, l logger.Logger) (*server.Server, error) {
 	// Initialize database
-	err := database.InitDatabase(ctx, cfg.GetDatabaseDSN(), cfg.Env)
+	err := database.InitDatabaseWithConfig(ctx, cfg.DatabaseConfig())
 	if err != nil {
 		return nil, err
 	}
 	db := database.GetDB()
 
+	secrets, err := newSecretCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Company and certificate reads go through the cache when one is configured
+	cacheMetrics := newCacheMetrics(cfg)
+	companyRepo, err := newCompanyRepository(cfg, db, secrets, cacheMetrics, l)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize repositories
 	nfceRepo := postgres.NewNFCeRepository(db)
-	companyRepo := postgres.NewCompanyRepository(db)
 	planRepo := postgres.NewPlanRepository(db)
 	subscriptionRepo := postgres.NewSubscriptionRepository(db)
 	webhookRepo := postgres.NewWebhookRepository(db)
+	exportRepo := postgres.NewExportRepository(db)
+	inutilizacaoRepo := postgres.NewInutilizacaoRepository(db)
+	seriesRepo := postgres.NewNumberingSeriesRepository(db)
+	dfeRepo := postgres.NewDFeRepository(db)
+	adminRepo := postgres.NewAdminRepository(db)
+	apiKeyRepo := postgres.NewAPIKeyRepository(db)
+	emailDeliveryRepo := postgres.NewEmailDeliveryRepository(db)
+	invoiceRepo := postgres.NewInvoiceRepository(db)
+	subscriptionEventRepo := postgres.NewSubscriptionEventRepository(db)
+	txManager := postgres.NewTxManager(db)
 
 	// Initialize publisher
 	rabbitmqPublisher, err := rabbitmq.NewPublisher(cfg.RabbitMQURL)
@@ -47,6 +85,12 @@ func InitializeAPIManual(ctx context.Context, cfg *config.AppConfig, l logger.Lo
 	}
 	publisher := dto.Publisher(rabbitmqPublisher)
 
+	// Initialize event bus
+	eventBus, err := rabbitmq.NewEventBus(cfg.RabbitMQURL)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize storage service
 	var storageService storage.StorageService
 	switch cfg.StorageType {
@@ -61,6 +105,16 @@ func InitializeAPIManual(ctx context.Context, cfg *config.AppConfig, l logger.Lo
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize MinIO storage: %w", err)
 		}
+	case "s3":
+		storageService, err = storage.NewS3Storage(newS3Config(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize S3 storage: %w", err)
+		}
+	case "gcs":
+		storageService, err = storage.NewGCSStorage(newGCSConfig(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize GCS storage: %w", err)
+		}
 	case "local":
 		storageService, err = storage.NewLocalStorage(
 			cfg.StorageBasePath,
@@ -81,21 +135,144 @@ func InitializeAPIManual(ctx context.Context, cfg *config.AppConfig, l logger.Lo
 		}
 	}
 
+	// Initialize plan feature gate
+	webhookDispatcher := webhook.NewDispatcher(webhookRepo, 10*time.Second)
+	featureGate := service.NewFeatureGate(subscriptionRepo, planRepo, webhookDispatcher, service.FeatureGateConfig{
+		UpgradeURL: cfg.PlanUpgradeURL,
+	}, l)
+
+	// Initialize pre-emission anti-fraud checks
+	fraudGuard := service.NewFraudGuard(nfceRepo, companyRepo, l)
+
+	// Initialize NCM/CEST/CFOP catalog validation and the municipality lookups
+	catalogRepo := postgres.NewCatalogRepository(db)
+	catalogService := service.NewCatalogService(catalogRepo)
+	cepLookup, err := cep.NewLookup(newCEPLookupConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+	municipioService := service.NewMunicipioService(catalogRepo, cepLookup, l)
+
+	// Initialize maintenance mode
+	maintenanceService := service.NewMaintenanceService(postgres.NewMaintenanceRepository(db), companyRepo, webhookDispatcher, l)
+
+	// Initialize consumer e-mails
+	emailService, err := newEmailService(cfg, emailDeliveryRepo, companyRepo, storageService)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize the payment webhooks of the subscription billing
+	billingService, err := newBillingService(cfg, subscriptionRepo, planRepo, invoiceRepo, subscriptionEventRepo, txManager, webhookDispatcher, l)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize Idempotency-Key enforcement of POST /nfce
+	idempotencyService := service.NewIdempotencyService(postgres.NewIdempotencyKeyRepository(db), cfg.IdempotencyKeyTTL)
+
+	// Initialize the audit log of the write endpoints
+	auditService := service.NewAuditService(postgres.NewAuditRepository(db))
+
+	// Initialize the LGPD offboarding of the companies deleted by the admins
+	offboardingService := service.NewCompanyOffboardingService(companyRepo, apiKeyRepo, subscriptionRepo, nfceRepo, txManager, auditService,
+		service.NewDocumentPurgeService(companyRepo, subscriptionRepo, planRepo, nfceRepo, txManager, storageService, newDocumentPurgeConfig(cfg)),
+		newCompanyOffboardingConfig(cfg))
+
+	// Initialize the rate limits per company plan
+	rateLimiter := newRateLimiter(cfg, subscriptionRepo, planRepo)
+
+	// NTP clock skew monitor reported by the health check; dhEmi of the test emission is stamped from it
+	clockMonitor := newClockMonitor(cfg, l)
+
+	// Initialize the SEFAZ components of the company verification test emission
+	xmlValidator, err := newXMLValidator(ctx, cfg, l)
+	if err != nil {
+		return nil, err
+	}
+	sefazTables, err := newSEFAZTables(ctx, cfg, l)
+	if err != nil {
+		return nil, err
+	}
+	soapClient, err := newSOAPClient(cfg, sefazTables, l)
+	if err != nil {
+		return nil, err
+	}
+	taxTables, err := service.LoadTaxTables(cfg.TaxTablesFile)
+	if err != nil {
+		return nil, err
+	}
+	verifierWorker := service.NewNFCeWorkerService(
+		nfceInfra.NewBuilder(companyRepo, nfceRepo, clockMonitor, newRespTecConfig(cfg)),
+		signer.NewSigner(),
+		xmlValidator,
+		soapClient,
+		qr.NewGenerator(),
+		danfe.NewRenderer(),
+		storageService,
+		companyRepo,
+		featureGate,
+		service.AsyncLoteConfig{},          // The test emission is synchronous
+		service.OfflineContingencyConfig{}, // and never issued in contingency
+		service.NewSEFAZMonitor(soapClient, service.SEFAZMonitorConfig{}),
+		service.NewTaxEngine(taxTables),
+		nil, // Contingency periods are entered and probed by the worker
+		service.ContingencyConfig{},
+		nil, // The test emission is not archived
+		l,
+	)
+	companyVerifier := service.NewCompanyVerifier(companyRepo, verifierWorker, soapClient)
+
 	// Initialize use cases
-	nfceUseCase := usecase.NewNFCeUseCase(nfceRepo, publisher, storageService)
-	adminUseCase := usecase.NewAdminUseCase(companyRepo, planRepo, subscriptionRepo)
-	companyUseCase := usecase.NewCompanyUseCase(companyRepo, subscriptionRepo)
+	nfceUseCase := usecase.NewNFCeUseCase(nfceRepo, companyRepo, publisher, storageService, eventBus, danfe.NewRenderer(), featureGate, txManager, postgres.NewOutboxRepository(db), postgres.NewNFCeLoteRepository(db), fraudGuard, catalogService, maintenanceService, l)
+	adminUseCase := usecase.NewAdminUseCase(companyRepo, planRepo, subscriptionRepo, nfceRepo, txManager, postgres.NewOutboxRepository(db), newSEFAZArchiveService(cfg, db, storageService, l), municipioService, offboardingService)
+	companyUseCase := usecase.NewCompanyUseCase(companyRepo, subscriptionRepo, companyVerifier, secrets, municipioService)
 	planUseCase := usecase.NewPlanUseCase(planRepo)
-	subscriptionUseCase := usecase.NewSubscriptionUseCase(subscriptionRepo, planRepo, companyRepo)
+	subscriptionUseCase := usecase.NewSubscriptionUseCase(subscriptionRepo, planRepo, companyRepo, txManager, postgres.NewStatementRepository(db), invoiceRepo, subscriptionEventRepo, storageService)
 	webhookUseCase := usecase.NewWebhookUseCase(webhookRepo)
+	exportUseCase := usecase.NewExportUseCase(exportRepo, publisher, storageService, service.NewExportService(nfceRepo, exportRepo, storageService, webhookDispatcher, l))
+	inutilizacaoUseCase := usecase.NewInutilizacaoUseCase(inutilizacaoRepo, companyRepo, featureGate, txManager, publisher)
+	seriesUseCase := usecase.NewSeriesUseCase(seriesRepo, txManager)
+	dfeUseCase := usecase.NewDFeUseCase(dfeRepo, storageService)
+	maintenanceUseCase := usecase.NewMaintenanceUseCase(maintenanceService)
+	authUseCase := usecase.NewAuthUseCase(adminRepo, apiKeyRepo, companyRepo, txManager, newAuthConfig(cfg), l)
+	emailUseCase := usecase.NewEmailUseCase(nfceRepo, emailService)
+	deadLetterUseCase := usecase.NewDeadLetterUseCase(rabbitmq.NewDeadLetterQueue(cfg.RabbitMQURL))
+	auditUseCase := usecase.NewAuditUseCase(auditService)
+	billingUseCase := usecase.NewBillingUseCase(billingService)
 
 	// Initialize handlers
 	nfceHandler := handler.NewNFCeHandler(nfceUseCase)
-	adminHandler := handler.NewAdminHandler(adminUseCase)
+	adminHandler := handler.NewAdminHandler(adminUseCase, authUseCase)
 	companyHandler := handler.NewCompanyHandler(companyUseCase)
 	planHandler := handler.NewPlanHandler(planUseCase)
 	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionUseCase)
 	webhookHandler := handler.NewWebhookHandler(webhookUseCase)
+	exportHandler := handler.NewExportHandler(exportUseCase)
+	inutilizacaoHandler := handler.NewInutilizacaoHandler(inutilizacaoUseCase)
+	seriesHandler := handler.NewSeriesHandler(seriesUseCase)
+	configHandler := handler.NewConfigHandler(cfg)
+	dfeHandler := handler.NewDFeHandler(dfeUseCase)
+	maintenanceHandler := handler.NewMaintenanceHandler(maintenanceUseCase)
+	apiKeyHandler := handler.NewAPIKeyHandler(authUseCase)
+	emailHandler := handler.NewEmailHandler(emailUseCase)
+	deadLetterHandler := handler.NewDeadLetterHandler(deadLetterUseCase)
+	auditHandler := handler.NewAuditHandler(auditUseCase)
+	billingHandler := handler.NewBillingHandler(billingUseCase)
+	cacheHandler := handler.NewCacheHandler(cfg, cacheMetrics)
+	graphqlHandler, err := newGraphQLHandler(cfg, nfceRepo, companyRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaHandler := handler.NewSchemaHandler(xmlValidator, schemaPackage(cfg), l)
+	sefazTablesHandler := handler.NewSEFAZTablesHandler(sefazTables)
+	publicHandler := handler.NewPublicHandler(usecase.NewPublicUseCase(nfceRepo, companyRepo, verifierWorker, l))
+
+	grpcServer, err := newGRPCServer(cfg, nfceUseCase, authUseCase, rateLimiter, auditService, l)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize server
 	srv := server.NewServer(
@@ -105,6 +282,29 @@ func InitializeAPIManual(ctx context.Context, cfg *config.AppConfig, l logger.Lo
 		planHandler,
 		subscriptionHandler,
 		webhookHandler,
+		exportHandler,
+		inutilizacaoHandler,
+		seriesHandler,
+		configHandler,
+		schemaHandler,
+		sefazTablesHandler,
+		dfeHandler,
+		maintenanceHandler,
+		apiKeyHandler,
+		emailHandler,
+		deadLetterHandler,
+		auditHandler,
+		billingHandler,
+		cacheHandler,
+		graphqlHandler,
+		publicHandler,
+		authUseCase,
+		idempotencyService,
+		auditService,
+		rateLimiter,
+		clockMonitor,
+		grpcServer,
+		newShutdownHooks(db, publisher, eventBus, postgres.NewOutboxRepository(db), nfceRepo, l),
 		l,
 		cfg.Port,
 	)
@@ -115,15 +315,35 @@ func InitializeAPIManual(ctx context.Context, cfg *config.AppConfig, l logger.Lo
 // InitializeWorkerManual initializes the worker manually
 func InitializeWorkerManual(ctx context.Context, cfg *config.AppConfig, l logger.Logger) (*worker.Worker, error) {
 	// Initialize database
-	err := database.InitDatabase(ctx, cfg.GetDatabaseDSN(), cfg.Env)
+	err := database.InitDatabaseWithConfig(ctx, cfg.DatabaseConfig())
 	if err != nil {
 		return nil, err
 	}
 	db := database.GetDB()
 
+	secrets, err := newSecretCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Company and certificate reads go through the cache when one is configured
+	cacheMetrics := newCacheMetrics(cfg)
+	companyRepo, err := newCompanyRepository(cfg, db, secrets, cacheMetrics, l)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize repositories
 	nfceRepo := postgres.NewNFCeRepository(db)
-	companyRepo := postgres.NewCompanyRepository(db)
+	planRepo := postgres.NewPlanRepository(db)
+	subscriptionRepo := postgres.NewSubscriptionRepository(db)
+	webhookRepo := postgres.NewWebhookRepository(db)
+	exportRepo := postgres.NewExportRepository(db)
+	inutilizacaoRepo := postgres.NewInutilizacaoRepository(db)
+	dfeRepo := postgres.NewDFeRepository(db)
+	statementRepo := postgres.NewStatementRepository(db)
+	emailDeliveryRepo := postgres.NewEmailDeliveryRepository(db)
+	txManager := postgres.NewTxManager(db)
 
 	// Initialize messaging
 	rabbitmqPublisher, err := rabbitmq.NewPublisher(cfg.RabbitMQURL)
@@ -132,12 +352,17 @@ func InitializeWorkerManual(ctx context.Context, cfg *config.AppConfig, l logger
 	}
 	publisher := dto.Publisher(rabbitmqPublisher)
 
-	rabbitmqConsumer, err := rabbitmq.NewConsumer(cfg.RabbitMQURL)
+	rabbitmqConsumer, err := rabbitmq.NewConsumer(cfg.RabbitMQURL, cfg.RabbitMQMaxDeliveryAttempts, cfg.WorkerConcurrency)
 	if err != nil {
 		return nil, err
 	}
 	consumer := dto.Consumer(rabbitmqConsumer)
 
+	eventBus, err := rabbitmq.NewEventBus(cfg.RabbitMQURL)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize storage service
 	var storageService storage.StorageService
 	switch cfg.StorageType {
@@ -152,6 +377,16 @@ func InitializeWorkerManual(ctx context.Context, cfg *config.AppConfig, l logger
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize MinIO storage: %w", err)
 		}
+	case "s3":
+		storageService, err = storage.NewS3Storage(newS3Config(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize S3 storage: %w", err)
+		}
+	case "gcs":
+		storageService, err = storage.NewGCSStorage(newGCSConfig(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize GCS storage: %w", err)
+		}
 	case "local":
 		storageService, err = storage.NewLocalStorage(
 			cfg.StorageBasePath,
@@ -172,16 +407,74 @@ func InitializeWorkerManual(ctx context.Context, cfg *config.AppConfig, l logger
 		}
 	}
 
+	// NTP clock skew monitor; dhEmi is stamped from it
+	clockMonitor := newClockMonitor(cfg, l)
+
 	// Initialize SEFAZ components
-	xmlBuilder := nfceInfra.NewBuilder(companyRepo)
+	xmlBuilder := nfceInfra.NewBuilder(companyRepo, nfceRepo, clockMonitor, newRespTecConfig(cfg))
 	xmlSigner := signer.NewSigner()
-	xmlValidator, err := validator.NewXMLValidator("./internal/infrastructure/sefaz/schemas")
+	xmlValidator, err := newXMLValidator(ctx, cfg, l)
+	if err != nil {
+		return nil, err
+	}
+	sefazTables, err := newSEFAZTables(ctx, cfg, l)
+	if err != nil {
+		return nil, err
+	}
+	soapClient, err := newSOAPClient(cfg, sefazTables, l)
 	if err != nil {
 		return nil, err
 	}
-	soapClient := soapclient.NewSOAPClient(30 * time.Second) // 30 second timeout
 	qrGenerator := qr.NewGenerator()
 
+	// Initialize webhook dispatcher and plan feature gate
+	webhookDispatcher := webhook.NewDispatcher(webhookRepo, 10*time.Second)
+	featureGate := service.NewFeatureGate(subscriptionRepo, planRepo, webhookDispatcher, service.FeatureGateConfig{
+		UpgradeURL: cfg.PlanUpgradeURL,
+	}, l)
+
+	// Maintenance mode pauses the schedulers while a window is active
+	maintenanceService := service.NewMaintenanceService(postgres.NewMaintenanceRepository(db), companyRepo, webhookDispatcher, l)
+
+	// Asynchronous lote submission (indSinc=0) settings
+	asyncLote := service.AsyncLoteConfig{
+		Enabled:      cfg.SEFAZAsyncLote,
+		PollInterval: cfg.SEFAZReceiptPollInterval,
+		MaxPolls:     cfg.SEFAZReceiptMaxPolls,
+		Pack:         cfg.SEFAZAsyncLotePack,
+	}
+
+	// Offline contingency (tpEmis 9) settings
+	offline := service.OfflineContingencyConfig{
+		Enabled:          cfg.SEFAZOfflineContingency,
+		TransmitInterval: cfg.SEFAZOfflineTransmitInterval,
+	}
+
+	// Retry scheduler bounds, adapted to the backlog at every run
+	retryScheduler := service.RetrySchedulerConfig{
+		MinBatch:    cfg.RetryBatchMin,
+		MaxBatch:    cfg.RetryBatchMax,
+		MinInterval: cfg.RetryIntervalMin,
+		MaxInterval: cfg.RetryIntervalMax,
+		MaxInFlight: cfg.RetryMaxInFlight,
+	}
+
+	// SEFAZ status monitor and circuit breaker, shared by the worker service and the worker
+	sefazMonitor := service.NewSEFAZMonitor(soapClient, service.SEFAZMonitorConfig{
+		Enabled:          cfg.SEFAZMonitorEnabled,
+		PollInterval:     cfg.SEFAZMonitorInterval,
+		FailureThreshold: cfg.SEFAZMonitorFailureThreshold,
+	})
+
+	// Tax engine with the default aliquot tables and the configured overrides
+	taxTables, err := service.LoadTaxTables(cfg.TaxTablesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Archive of the SEFAZ authorization exchanges
+	archiveService := newSEFAZArchiveService(cfg, db, storageService, l)
+
 	// Initialize domain service
 	workerService := service.NewNFCeWorkerService(
 		xmlBuilder,
@@ -189,19 +482,439 @@ func InitializeWorkerManual(ctx context.Context, cfg *config.AppConfig, l logger
 		xmlValidator,
 		soapClient,
 		qrGenerator,
+		danfe.NewRenderer(),
 		storageService,
 		companyRepo,
+		featureGate,
+		asyncLote,
+		offline,
+		sefazMonitor,
+		service.NewTaxEngine(taxTables),
+		postgres.NewContingencyStateRepository(db),
+		service.ContingencyConfig{ProbeInterval: cfg.SEFAZContingencyProbeInterval},
+		archiveService,
+		l,
+	)
+
+	exportService := service.NewExportService(nfceRepo, exportRepo, storageService, webhookDispatcher, l)
+	inutilizacaoService := service.NewInutilizacaoService(inutilizacaoRepo, companyRepo, xmlSigner, soapClient, storageService, l)
+	dfeService := service.NewDFeService(dfeRepo, companyRepo, soapClient, storageService, service.DFeConfig{
+		Ambiente:     cfg.SEFAZDFeAmbiente,
+		SyncInterval: cfg.SEFAZDFeSyncInterval,
+	})
+	subscriptionEventRepo := postgres.NewSubscriptionEventRepository(db)
+	statementService := service.NewStatementService(
+		statementRepo,
+		companyRepo,
+		subscriptionRepo,
+		planRepo,
+		subscriptionEventRepo,
+		nfceRepo,
+		webhookRepo,
+		storageService,
+		statement.NewRenderer(),
+		webhookDispatcher,
+		service.StatementConfig{Enabled: cfg.StatementsEnabled},
+		l,
 	)
+	retentionService := service.NewStorageRetentionService(companyRepo, subscriptionRepo, planRepo, storageService, service.StorageRetentionConfig{
+		Enabled:  cfg.StorageRetentionEnabled,
+		Interval: cfg.StorageRetentionSyncInterval,
+	})
+	billingService, err := newBillingService(cfg, subscriptionRepo, planRepo, postgres.NewInvoiceRepository(db), subscriptionEventRepo, txManager, webhookDispatcher, l)
+	if err != nil {
+		return nil, err
+	}
+	purgeService := service.NewDocumentPurgeService(companyRepo, subscriptionRepo, planRepo, nfceRepo, txManager, storageService, newDocumentPurgeConfig(cfg))
+	offboardingService := service.NewCompanyOffboardingService(companyRepo, postgres.NewAPIKeyRepository(db), subscriptionRepo, nfceRepo, txManager,
+		service.NewAuditService(postgres.NewAuditRepository(db)), purgeService, newCompanyOffboardingConfig(cfg))
+	lifecycleService := service.NewSubscriptionLifecycleService(subscriptionRepo, planRepo, txManager, webhookDispatcher, newSubscriptionLifecycleConfig(cfg), l)
+
+	emailService, err := newEmailService(cfg, emailDeliveryRepo, companyRepo, storageService)
+	if err != nil {
+		return nil, err
+	}
+
+	// Purge of the expired Idempotency-Key records
+	idempotencyService := service.NewIdempotencyService(postgres.NewIdempotencyKeyRepository(db), cfg.IdempotencyKeyTTL)
 
 	// Initialize worker
 	w := worker.NewWorker(
 		nfceRepo,
+		txManager,
 		publisher,
 		consumer,
+		eventBus,
+		webhookDispatcher,
 		workerService,
+		exportService,
+		inutilizacaoService,
+		dfeService,
+		statementService,
+		retentionService,
+		purgeService,
+		offboardingService,
+		archiveService,
+		billingService,
+		lifecycleService,
+		emailService,
+		idempotencyService,
+		postgres.NewOutboxRepository(db),
+		cfg.OutboxRelayInterval,
+		asyncLote,
+		offline,
+		retryScheduler,
+		sefazMonitor,
+		clockMonitor,
+		maintenanceService,
+		cacheMetrics,
 		l,
 		5, // max retries
 	)
 
 	return w, nil
 }
+
+// newClockMonitor creates the NTP clock skew monitor from the configuration
+func newClockMonitor(cfg *config.AppConfig, l logger.Logger) *clock.Monitor {
+	return clock.NewMonitor(clock.MonitorConfig{
+		Enabled:    cfg.ClockCheckEnabled,
+		Servers:    cfg.ClockNTPServers,
+		Interval:   cfg.ClockCheckInterval,
+		Threshold:  cfg.ClockSkewThreshold,
+		Compensate: cfg.ClockCompensateDhEmi,
+	}, l)
+}
+
+// newSEFAZTables loads the SEFAZ endpoint and QR Code tables shipped with the binary, with
+// the overrides of SEFAZ_TABLES_FILE, and reloads them on SIGHUP; an invalid file stops the
+// startup
+func newSEFAZTables(ctx context.Context, cfg *config.AppConfig, l logger.Logger) (*tables.Reloader, error) {
+	reloader := tables.NewReloader(cfg.SEFAZTablesFile, l)
+	if _, err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	go reloader.WatchSignals(ctx)
+	return reloader, nil
+}
+
+// newSOAPClient creates the SEFAZ client with the endpoint registry, limited per UF, over
+// HTTPS or the cassettes of SEFAZ_RECORD_DIR/SEFAZ_REPLAY_DIR; the simulated ambiente is
+// answered in process. The client follows the tables installed by sefazTables, so it is
+// created once they are loaded.
+func newSOAPClient(cfg *config.AppConfig, sefazTables *tables.Reloader, l logger.Logger) (soapclient.Client, error) {
+	var err error
+	transport := soapclient.NewHTTPTransport(30 * time.Second) // 30 second timeout
+	switch {
+	case cfg.SEFAZReplayDir != "":
+		transport, err = soapclient.NewReplayTransport(cfg.SEFAZReplayDir)
+	case cfg.SEFAZRecordDir != "":
+		transport, err = soapclient.WithRecording(transport, cfg.SEFAZRecordDir, l)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return soapclient.WithSimulator(soapclient.WithUFConcurrency(
+		soapclient.NewSOAPClientWithTransport(transport, nil),
+		cfg.SEFAZMaxConcurrencyPerUF,
+	)), nil
+}
+
+// newXMLValidator creates the XSD validator and installs the pinned schemas offline. Missing
+// or invalid schemas fail the startup, unless SEFAZ_SCHEMAS_DOWNLOAD was set: a failed
+// download is then logged and retried through the admin endpoint, and emissions fail
+// validation until the schemas are installed.
+func newXMLValidator(ctx context.Context, cfg *config.AppConfig, l logger.Logger) (validator.XMLValidator, error) {
+	xmlValidator, err := validator.NewXMLValidator(cfg.SEFAZSchemasDir, l)
+	if err != nil {
+		return nil, err
+	}
+	if err := xmlValidator.EnsureSchemas(ctx, schemaPackage(cfg), cfg.SEFAZSchemasDownload); err != nil {
+		if !cfg.SEFAZSchemasDownload {
+			return nil, fmt.Errorf("SEFAZ schemas not installed: %w", err)
+		}
+		l.Warn("SEFAZ schemas not installed", logger.Err(err))
+	}
+	return xmlValidator, nil
+}
+
+// schemaPackage returns the pinned schema package, or the layout version whose default
+// package is used
+func schemaPackage(cfg *config.AppConfig) string {
+	if cfg.SEFAZSchemaPackage != "" {
+		return cfg.SEFAZSchemaPackage
+	}
+	return cfg.SEFAZSchemaVersion
+}
+
+// newS3Config maps the AWS S3 storage settings
+func newS3Config(cfg *config.AppConfig) storage.S3Config {
+	return storage.S3Config{
+		Bucket:        cfg.StorageBucket,
+		Region:        cfg.StorageRegion,
+		KMSKeyID:      cfg.StorageKMSKeyID,
+		PresignExpiry: cfg.StoragePresignExpiry,
+	}
+}
+
+// newGCSConfig maps the Google Cloud Storage settings; the HMAC key uses the storage access keys
+func newGCSConfig(cfg *config.AppConfig) storage.GCSConfig {
+	return storage.GCSConfig{
+		Bucket:        cfg.StorageBucket,
+		AccessKey:     cfg.StorageAccessKey,
+		SecretKey:     cfg.StorageSecretKey,
+		PresignExpiry: cfg.StoragePresignExpiry,
+	}
+}
+
+// newEmailService creates the consumer e-mail service with the configured provider
+func newEmailService(cfg *config.AppConfig, deliveryRepo ports.EmailDeliveryRepository, companyRepo ports.CompanyRepository, storageService storage.StorageService) (*service.EmailService, error) {
+	sender, err := email.NewSender(newEmailSenderConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return service.NewEmailService(deliveryRepo, companyRepo, storageService, danfe.NewRenderer(), sender, service.EmailConfig{
+		From:     cfg.EmailFrom,
+		FromName: cfg.EmailFromName,
+	}), nil
+}
+
+// newEmailSenderConfig builds the e-mail provider settings from the configuration
+func newEmailSenderConfig(cfg *config.AppConfig) email.Config {
+	return email.Config{
+		Provider:           cfg.EmailProvider,
+		SMTPHost:           cfg.SMTPHost,
+		SMTPPort:           cfg.SMTPPort,
+		SMTPUsername:       cfg.SMTPUsername,
+		SMTPPassword:       cfg.SMTPPassword,
+		SESRegion:          cfg.SESRegion,
+		SESAccessKeyID:     cfg.SESAccessKeyID,
+		SESSecretAccessKey: cfg.SESSecretAccessKey,
+	}
+}
+
+// newBillingService creates the subscription billing service with the configured gateway
+func newBillingService(
+	cfg *config.AppConfig,
+	subscriptionRepo ports.SubscriptionRepository,
+	planRepo ports.PlanRepository,
+	invoiceRepo ports.InvoiceRepository,
+	eventRepo ports.SubscriptionEventRepository,
+	txManager ports.TxManager,
+	webhooks ports.WebhookDispatcher,
+	l logger.Logger,
+) (*service.BillingService, error) {
+	gateway, err := payment.NewGateway(newPaymentGatewayConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return service.NewBillingService(subscriptionRepo, planRepo, invoiceRepo, eventRepo, txManager, gateway, webhooks, newBillingConfig(cfg), l), nil
+}
+
+// newCEPLookupConfig builds the CEP lookup settings from the configuration
+func newCEPLookupConfig(cfg *config.AppConfig) cep.Config {
+	return cep.Config{
+		Provider:  cfg.CEPProvider,
+		ViaCEPURL: cfg.ViaCEPURL,
+	}
+}
+
+// newPaymentGatewayConfig builds the payment gateway settings from the configuration
+func newPaymentGatewayConfig(cfg *config.AppConfig) payment.Config {
+	return payment.Config{
+		Provider:                 cfg.BillingGateway,
+		StripeSecretKey:          cfg.StripeSecretKey,
+		StripeWebhookSecret:      cfg.StripeWebhookSecret,
+		PagarmeSecretKey:         cfg.PagarmeSecretKey,
+		PagarmeWebhookSecret:     cfg.PagarmeWebhookSecret,
+		MercadoPagoAccessToken:   cfg.MercadoPagoAccessToken,
+		MercadoPagoWebhookSecret: cfg.MercadoPagoWebhookSecret,
+	}
+}
+
+// newBillingConfig builds the charge and dunning settings from the configuration
+func newBillingConfig(cfg *config.AppConfig) service.BillingConfig {
+	return service.BillingConfig{
+		Interval:      cfg.BillingInterval,
+		RetryInterval: cfg.BillingRetryInterval,
+		MaxAttempts:   cfg.BillingMaxAttempts,
+	}
+}
+
+// newSubscriptionLifecycleConfig builds the subscription lifecycle settings from the configuration;
+// ended trials are converted only when a payment gateway charges them
+func newSubscriptionLifecycleConfig(cfg *config.AppConfig) service.SubscriptionLifecycleConfig {
+	return service.SubscriptionLifecycleConfig{
+		Enabled:       cfg.SubscriptionLifecycleEnabled,
+		Interval:      cfg.SubscriptionLifecycleInterval,
+		ConvertTrials: cfg.BillingGateway != payment.ProviderNone,
+	}
+}
+
+// newDocumentPurgeConfig builds the settings of the purge of the NFC-e documents from the configuration
+func newDocumentPurgeConfig(cfg *config.AppConfig) service.DocumentPurgeConfig {
+	return service.DocumentPurgeConfig{
+		Enabled:   cfg.DocumentPurgeEnabled,
+		Interval:  cfg.DocumentPurgeInterval,
+		BatchSize: cfg.DocumentPurgeBatchSize,
+	}
+}
+
+// newCompanyOffboardingConfig builds the settings of the LGPD offboarding from the configuration
+func newCompanyOffboardingConfig(cfg *config.AppConfig) service.CompanyOffboardingConfig {
+	return service.CompanyOffboardingConfig{
+		CPFRetentionDays:   cfg.CompanyCPFRetentionDays,
+		StorageCleanupDays: cfg.CompanyStorageCleanupDays,
+		Interval:           cfg.CompanyOffboardingInterval,
+	}
+}
+
+// newSEFAZArchiveService creates the archive of the SEFAZ authorization exchanges
+func newSEFAZArchiveService(cfg *config.AppConfig, db *gorm.DB, storageService storage.StorageService, l logger.Logger) *service.SEFAZArchiveService {
+	return service.NewSEFAZArchiveService(postgres.NewSEFAZExchangeRepository(db), storageService, newSEFAZArchiveConfig(cfg), l)
+}
+
+// newSEFAZArchiveConfig builds the SEFAZ exchange archive settings from the configuration
+func newSEFAZArchiveConfig(cfg *config.AppConfig) service.SEFAZArchiveConfig {
+	return service.SEFAZArchiveConfig{
+		Enabled:       cfg.SEFAZArchiveEnabled,
+		RetentionDays: cfg.SEFAZArchiveRetentionDays,
+		PurgeInterval: cfg.SEFAZArchivePurgeInterval,
+	}
+}
+
+// newRateLimiter builds the rate limits of the API with the buckets in memory, nil when disabled
+func newRateLimiter(cfg *config.AppConfig, subscriptionRepo ports.SubscriptionRepository, planRepo ports.PlanRepository) middleware.RateLimiter {
+	if !cfg.RateLimitEnabled {
+		return nil
+	}
+	return service.NewRateLimitService(ratelimit.NewMemoryStore(), subscriptionRepo, planRepo, service.RateLimitConfig{
+		RequestsPerMinute:  cfg.RateLimitRequestsPerMinute,
+		EmissionsPerMinute: cfg.RateLimitEmissionsPerMinute,
+	})
+}
+
+// newAuthConfig builds the admin token and API key settings from the configuration
+func newAuthConfig(cfg *config.AppConfig) usecase.AuthConfig {
+	return usecase.AuthConfig{
+		JWTSecret:     cfg.JWTSecret,
+		TokenTTL:      time.Duration(cfg.JWTExpiry) * time.Hour,
+		RotationGrace: cfg.APIKeyRotationGrace,
+	}
+}
+
+// newSecretCipher builds the cipher of the secrets stored in the database and registers it
+// as the GORM serializer of the secret columns
+func newSecretCipher(cfg *config.AppConfig) (ports.SecretCipher, error) {
+	// CERTIFICATE_ENCRYPTION_PREVIOUS_KEYS is checked by config.Validate at startup
+	previous, _ := cfg.PreviousEncryptionKeys()
+	cipher, err := secret.NewCipher(cfg.CertificateEncryptionKeyID, cfg.CertificateEncryptionKey, previous)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CERTIFICATE_ENCRYPTION_KEY: %w", err)
+	}
+	postgres.RegisterSecretSerializer(cipher)
+	return cipher, nil
+}
+
+// newCacheMetrics creates the hit ratio counters of the cache, nil when no cache is configured
+func newCacheMetrics(cfg *config.AppConfig) *cache.Metrics {
+	if cfg.CacheProvider == "none" {
+		return nil
+	}
+	return cache.NewMetrics()
+}
+
+// newCompanyRepository builds the company repository, served from Redis when CACHE_PROVIDER is redis
+func newCompanyRepository(cfg *config.AppConfig, db *gorm.DB, secrets ports.SecretCipher, metrics *cache.Metrics, l logger.Logger) (ports.CompanyRepository, error) {
+	repo := postgres.NewCompanyRepository(db, secrets)
+	if cfg.CacheProvider == "none" {
+		return repo, nil
+	}
+
+	redisCache, err := cache.NewRedisCache(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	return cache.NewCompanyRepository(repo, redisCache, secrets, cfg.CacheTTL, metrics, l), nil
+}
+
+// newRespTecConfig builds the installation technical responsible from the configuration
+func newRespTecConfig(cfg *config.AppConfig) nfceInfra.RespTecConfig {
+	// RESP_TEC_CSRT is checked by config.Validate at startup
+	csrts, _ := cfg.RespTecCSRTs()
+	respTec := nfceInfra.RespTecConfig{
+		CNPJ:     cfg.RespTecCNPJ,
+		XContato: cfg.RespTecContato,
+		Email:    cfg.RespTecEmail,
+		Fone:     cfg.RespTecFone,
+		CSRT:     make(map[string]nfceInfra.CSRT, len(csrts)),
+	}
+	for uf, csrt := range csrts {
+		respTec.CSRT[uf] = nfceInfra.CSRT{ID: csrt.ID, Token: csrt.Token}
+	}
+	return respTec
+}
+
+// newGraphQLHandler creates the read-only GraphQL handler, nil when GRAPHQL_ENABLED is off
+func newGraphQLHandler(cfg *config.AppConfig, nfceRepo ports.NFCeRepository, companyRepo ports.CompanyRepository) (*handler.GraphQLHandler, error) {
+	if !cfg.GraphQLEnabled {
+		return nil, nil
+	}
+	graphQLUseCase, err := usecase.NewGraphQLUseCase(nfceRepo, companyRepo, usecase.GraphQLConfig{
+		MaxDepth:         cfg.GraphQLMaxDepth,
+		PersistedQueries: cfg.GraphQLPersistedQueries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return handler.NewGraphQLHandler(graphQLUseCase), nil
+}
+
+// newGRPCServer creates the gRPC emission API over the same use cases, rate limiter and audit
+// log of the REST API, nil when GRPC_ENABLED is off
+func newGRPCServer(cfg *config.AppConfig, nfceUseCase usecase.NFCeUseCase, authUseCase usecase.AuthUseCase, limiter middleware.RateLimiter, audit *service.AuditService, l logger.Logger) (*grpcserver.Server, error) {
+	if !cfg.GRPCEnabled {
+		return nil, nil
+	}
+	return grpcserver.NewServer(nfceUseCase, authUseCase, limiter, audit, grpcserver.Config{
+		Port:        cfg.GRPCPort,
+		TLSCertFile: cfg.GRPCTLSCertFile,
+		TLSKeyFile:  cfg.GRPCTLSKeyFile,
+	}, l)
+}
+
+// newShutdownHooks builds the API shutdown steps: the outbox written by the drained requests
+// is published before the broker and database connections close
+func newShutdownHooks(db *gorm.DB, publisher dto.Publisher, eventBus ports.EventBus, outboxRepo ports.OutboxRepository, nfceRepo ports.NFCeRepository, l logger.Logger) []server.ShutdownHook {
+	relay := outbox.NewRelay(outboxRepo, nfceRepo, publisher, l)
+	return []server.ShutdownHook{
+		{Name: "flush outbox", Run: func(ctx context.Context) error {
+			// The worker relays what is left once the broker is back
+			if !publisher.IsHealthy() {
+				return nil
+			}
+			published, err := relay.Flush(ctx)
+			if published > 0 {
+				l.Info("Outbox messages relayed", logger.Field{Key: "count", Value: published})
+			}
+			return err
+		}},
+		{Name: "close event bus", Run: func(ctx context.Context) error {
+			return eventBus.Close()
+		}},
+		{Name: "close publisher", Run: func(ctx context.Context) error {
+			if closer, ok := publisher.(io.Closer); ok {
+				return closer.Close()
+			}
+			return nil
+		}},
+		{Name: "close database", Run: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.Close()
+		}},
+	}
+}
@@ -12,17 +12,29 @@ import (
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/cache"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/cep"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/clock"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/database/postgres"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/email"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/grpcserver"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/handler"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/middleware"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/server"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/messaging/rabbitmq"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/payment"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/danfe"
 	nfceInfra "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/qr"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/signer"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/tables"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/validator"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/statement"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/webhook"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/worker"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/database"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
@@ -35,22 +47,92 @@ func InitializeAPI(ctx context.Context, cfg *config.AppConfig, l logger.Logger)
 		// Infrastructure
 		provideDatabase,
 		postgres.NewNFCeRepository,
-		postgres.NewCompanyRepository,
+		provideSecretCipher,
+		provideCacheMetrics,
+		provideCompanyRepository,
 		postgres.NewPlanRepository,
 		postgres.NewSubscriptionRepository,
 		postgres.NewWebhookRepository,
+		postgres.NewExportRepository,
+		postgres.NewInutilizacaoRepository,
+		postgres.NewNumberingSeriesRepository,
+		postgres.NewDFeRepository,
+		postgres.NewCatalogRepository,
+		postgres.NewStatementRepository,
+		postgres.NewInvoiceRepository,
+		postgres.NewSubscriptionEventRepository,
+		postgres.NewAdminRepository,
+		postgres.NewAPIKeyRepository,
+		postgres.NewEmailDeliveryRepository,
+		postgres.NewOutboxRepository,
+		postgres.NewNFCeLoteRepository,
+		postgres.NewTxManager,
 		providePublisher,
+		provideEventBus,
+		provideWebhookDispatcher,
+		provideFeatureGateConfig,
+		service.NewFeatureGate,
+		service.NewFraudGuard,
+		service.NewCatalogService,
+		provideCEPLookup,
+		service.NewMunicipioService,
+		postgres.NewMaintenanceRepository,
+		service.NewMaintenanceService,
 		providePort,
+		provideClockMonitor,
 		server.NewServer,
 
 		// Application
 		provideStorage,
+		provideDANFERenderer,
 		usecase.NewNFCeUseCase,
+		postgres.NewSEFAZExchangeRepository,
+		provideSEFAZArchiveConfig,
+		service.NewSEFAZArchiveService,
+		provideDocumentPurgeConfig,
+		service.NewDocumentPurgeService,
+		provideCompanyOffboardingConfig,
+		service.NewCompanyOffboardingService,
 		usecase.NewAdminUseCase,
 		usecase.NewCompanyUseCase,
 		usecase.NewPlanUseCase,
 		usecase.NewSubscriptionUseCase,
 		usecase.NewWebhookUseCase,
+		service.NewExportService,
+		usecase.NewExportUseCase,
+		usecase.NewInutilizacaoUseCase,
+		usecase.NewSeriesUseCase,
+		usecase.NewDFeUseCase,
+		usecase.NewMaintenanceUseCase,
+		provideEmailSender,
+		provideEmailConfig,
+		service.NewEmailService,
+		usecase.NewEmailUseCase,
+		provideDeadLetterQueue,
+		usecase.NewDeadLetterUseCase,
+		postgres.NewAuditRepository,
+		service.NewAuditService,
+		usecase.NewAuditUseCase,
+		providePaymentGateway,
+		provideBillingConfig,
+		service.NewBillingService,
+		usecase.NewBillingUseCase,
+		provideAuthConfig,
+		usecase.NewAuthUseCase,
+		provideXMLBuilder,
+		provideXMLSigner,
+		provideSEFAZTables,
+		provideSOAPClient,
+		provideQRGenerator,
+		provideAsyncLoteConfig,
+		provideOfflineContingencyConfig,
+		postgres.NewContingencyStateRepository,
+		provideContingencyConfig,
+		provideSEFAZMonitor,
+		provideTaxEngine,
+		service.NewNFCeWorkerService,
+		service.NewCompanyVerifier,
+		usecase.NewPublicUseCase,
 
 		// HTTP
 		handler.NewNFCeHandler,
@@ -59,6 +141,31 @@ func InitializeAPI(ctx context.Context, cfg *config.AppConfig, l logger.Logger)
 		handler.NewPlanHandler,
 		handler.NewSubscriptionHandler,
 		handler.NewWebhookHandler,
+		handler.NewExportHandler,
+		handler.NewInutilizacaoHandler,
+		handler.NewSeriesHandler,
+		handler.NewConfigHandler,
+		provideXMLValidator,
+		provideSchemaHandler,
+		handler.NewSEFAZTablesHandler,
+		handler.NewDFeHandler,
+		handler.NewMaintenanceHandler,
+		handler.NewAPIKeyHandler,
+		handler.NewEmailHandler,
+		handler.NewDeadLetterHandler,
+		handler.NewAuditHandler,
+		handler.NewBillingHandler,
+		handler.NewCacheHandler,
+		provideGraphQLHandler,
+		handler.NewPublicHandler,
+		provideAuthenticator,
+		postgres.NewIdempotencyKeyRepository,
+		provideIdempotencyService,
+		provideIdempotencyStore,
+		provideAuditRecorder,
+		provideRateLimiter,
+		provideGRPCServer,
+		provideShutdownHooks,
 	)
 	return &server.Server{}, nil
 }
@@ -69,16 +176,76 @@ func InitializeWorker(ctx context.Context, cfg *config.AppConfig, l logger.Logge
 		// Infrastructure
 		provideDatabase,
 		postgres.NewNFCeRepository,
-		postgres.NewCompanyRepository,
+		provideSecretCipher,
+		provideCacheMetrics,
+		provideCompanyRepository,
+		postgres.NewTxManager,
 		providePublisher,
 		provideConsumer,
+		provideEventBus,
+		postgres.NewWebhookRepository,
+		provideWebhookDispatcher,
+		postgres.NewPlanRepository,
+		postgres.NewSubscriptionRepository,
+		provideFeatureGateConfig,
+		service.NewFeatureGate,
+		provideClockMonitor,
 		provideXMLBuilder,
 		provideXMLSigner,
 		provideXMLValidator,
+		provideSEFAZTables,
 		provideSOAPClient,
 		provideQRGenerator,
+		provideDANFERenderer,
 		provideStorage,
+		provideAsyncLoteConfig,
+		provideOfflineContingencyConfig,
+		postgres.NewContingencyStateRepository,
+		provideContingencyConfig,
+		provideRetrySchedulerConfig,
+		provideSEFAZMonitor,
+		provideTaxEngine,
 		service.NewNFCeWorkerService,
+		postgres.NewExportRepository,
+		service.NewExportService,
+		postgres.NewInutilizacaoRepository,
+		service.NewInutilizacaoService,
+		postgres.NewDFeRepository,
+		provideDFeConfig,
+		service.NewDFeService,
+		postgres.NewStatementRepository,
+		postgres.NewSubscriptionEventRepository,
+		statement.NewRenderer,
+		provideStatementConfig,
+		service.NewStatementService,
+		provideStorageRetentionConfig,
+		service.NewStorageRetentionService,
+		provideDocumentPurgeConfig,
+		service.NewDocumentPurgeService,
+		postgres.NewAPIKeyRepository,
+		postgres.NewAuditRepository,
+		service.NewAuditService,
+		provideCompanyOffboardingConfig,
+		service.NewCompanyOffboardingService,
+		postgres.NewSEFAZExchangeRepository,
+		provideSEFAZArchiveConfig,
+		service.NewSEFAZArchiveService,
+		postgres.NewInvoiceRepository,
+		providePaymentGateway,
+		provideBillingConfig,
+		service.NewBillingService,
+		provideSubscriptionLifecycleConfig,
+		service.NewSubscriptionLifecycleService,
+		postgres.NewEmailDeliveryRepository,
+		provideEmailSender,
+		provideEmailConfig,
+		service.NewEmailService,
+		postgres.NewMaintenanceRepository,
+		service.NewMaintenanceService,
+		postgres.NewIdempotencyKeyRepository,
+		provideIdempotencyService,
+		postgres.NewOutboxRepository,
+		provideOutboxRelayInterval,
 		worker.NewWorker,
 		provideMaxRetries,
 	)
@@ -90,7 +257,7 @@ func provideDatabase(cfg *config.AppConfig) (*gorm.DB, error) {
 	// Initialize database if not already initialized
 	if database.GetDB() == nil {
 		ctx := context.Background()
-		if err := database.InitDatabase(ctx, cfg.GetDatabaseDSN(), cfg.Env); err != nil {
+		if err := database.InitDatabaseWithConfig(ctx, cfg.DatabaseConfig()); err != nil {
 			return nil, fmt.Errorf("failed to initialize database: %w", err)
 		}
 	}
@@ -106,6 +273,16 @@ func providePublisher(cfg *config.AppConfig) (dto.Publisher, error) {
 	return dto.Publisher(publisher), nil
 }
 
+// provideEventBus provides RabbitMQ event bus
+func provideEventBus(cfg *config.AppConfig) (ports.EventBus, error) {
+	return rabbitmq.NewEventBus(cfg.RabbitMQURL)
+}
+
+// provideDeadLetterQueue provides the inspector of the emission dead-letter queue
+func provideDeadLetterQueue(cfg *config.AppConfig) dto.DeadLetterQueue {
+	return rabbitmq.NewDeadLetterQueue(cfg.RabbitMQURL)
+}
+
 // providePort provides the server port
 func providePort(cfg *config.AppConfig) string {
 	return cfg.Port
@@ -113,17 +290,43 @@ func providePort(cfg *config.AppConfig) string {
 
 // provideConsumer provides RabbitMQ consumer
 func provideConsumer(cfg *config.AppConfig) (dto.Consumer, error) {
-	consumer, err := rabbitmq.NewConsumer(cfg.RabbitMQURL)
+	consumer, err := rabbitmq.NewConsumer(cfg.RabbitMQURL, cfg.RabbitMQMaxDeliveryAttempts, cfg.WorkerConcurrency)
 	if err != nil {
 		return nil, err
 	}
 	return dto.Consumer(consumer), nil
 }
 
-// provideXMLBuilder provides XML builder
-func provideXMLBuilder(db *gorm.DB) nfceInfra.Builder {
-	companyRepo := postgres.NewCompanyRepository(db)
-	return nfceInfra.NewBuilder(companyRepo)
+// provideXMLBuilder provides XML builder stamping dhEmi from the clock monitor and checking
+// the chaves de acesso already issued
+func provideXMLBuilder(companyRepo ports.CompanyRepository, nfceRepo ports.NFCeRepository, clockMonitor *clock.Monitor, cfg *config.AppConfig) nfceInfra.Builder {
+	return nfceInfra.NewBuilder(companyRepo, nfceRepo, clockMonitor, newRespTecConfig(cfg))
+}
+
+// provideSecretCipher provides the cipher of the secrets stored in the database
+func provideSecretCipher(cfg *config.AppConfig) (ports.SecretCipher, error) {
+	return newSecretCipher(cfg)
+}
+
+// provideCacheMetrics provides the hit ratio counters of the cache, nil when no cache is configured
+func provideCacheMetrics(cfg *config.AppConfig) *cache.Metrics {
+	return newCacheMetrics(cfg)
+}
+
+// provideCompanyRepository provides the company repository, served from the cache when configured
+func provideCompanyRepository(cfg *config.AppConfig, db *gorm.DB, secrets ports.SecretCipher, metrics *cache.Metrics, l logger.Logger) (ports.CompanyRepository, error) {
+	return newCompanyRepository(cfg, db, secrets, metrics, l)
+}
+
+// provideClockMonitor provides the NTP clock skew monitor
+func provideClockMonitor(cfg *config.AppConfig, l logger.Logger) *clock.Monitor {
+	return clock.NewMonitor(clock.MonitorConfig{
+		Enabled:    cfg.ClockCheckEnabled,
+		Servers:    cfg.ClockNTPServers,
+		Interval:   cfg.ClockCheckInterval,
+		Threshold:  cfg.ClockSkewThreshold,
+		Compensate: cfg.ClockCompensateDhEmi,
+	}, l)
 }
 
 // provideXMLSigner provides XML signer
@@ -131,14 +334,34 @@ func provideXMLSigner() signer.Signer {
 	return signer.NewSigner()
 }
 
-// provideXMLValidator provides XML validator
-func provideXMLValidator() (validator.XMLValidator, error) {
-	return validator.NewXMLValidator("./internal/infrastructure/sefaz/schemas")
+// provideXMLValidator provides XML validator with the pinned schemas installed
+func provideXMLValidator(ctx context.Context, cfg *config.AppConfig, l logger.Logger) (validator.XMLValidator, error) {
+	return newXMLValidator(ctx, cfg, l)
+}
+
+// provideSchemaHandler provides the SEFAZ schema admin handler for the pinned package
+func provideSchemaHandler(xmlValidator validator.XMLValidator, cfg *config.AppConfig, l logger.Logger) *handler.SchemaHandler {
+	return handler.NewSchemaHandler(xmlValidator, schemaPackage(cfg), l)
 }
 
-// provideSOAPClient provides SOAP client
-func provideSOAPClient() soapclient.Client {
-	return soapclient.NewSOAPClient(30 * time.Second)
+// provideSEFAZTables provides the SEFAZ endpoint and QR Code tables, reloaded on SIGHUP
+func provideSEFAZTables(ctx context.Context, cfg *config.AppConfig, l logger.Logger) (*tables.Reloader, error) {
+	return newSEFAZTables(ctx, cfg, l)
+}
+
+// provideSOAPClient provides SOAP client with the SEFAZ endpoint registry, limited per UF; the
+// simulated ambiente is answered in process
+func provideSOAPClient(cfg *config.AppConfig, sefazTables *tables.Reloader, l logger.Logger) (soapclient.Client, error) {
+	return newSOAPClient(cfg, sefazTables, l)
+}
+
+// provideTaxEngine provides the tax engine with the configured aliquot tables
+func provideTaxEngine(cfg *config.AppConfig) (*service.TaxEngine, error) {
+	tables, err := service.LoadTaxTables(cfg.TaxTablesFile)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewTaxEngine(tables), nil
 }
 
 // provideQRGenerator provides QR code generator
@@ -146,16 +369,194 @@ func provideQRGenerator() qr.Generator {
 	return qr.NewGenerator()
 }
 
+// provideDANFERenderer provides DANFE renderer
+func provideDANFERenderer() danfe.Renderer {
+	return danfe.NewRenderer()
+}
+
+// provideWebhookDispatcher provides webhook dispatcher
+func provideWebhookDispatcher(webhookRepo ports.WebhookRepository) ports.WebhookDispatcher {
+	return webhook.NewDispatcher(webhookRepo, 10*time.Second)
+}
+
+// provideFeatureGateConfig provides the plan feature gate settings
+func provideFeatureGateConfig(cfg *config.AppConfig) service.FeatureGateConfig {
+	return service.FeatureGateConfig{
+		UpgradeURL: cfg.PlanUpgradeURL,
+	}
+}
+
+// provideAsyncLoteConfig provides the asynchronous lote submission settings
+func provideAsyncLoteConfig(cfg *config.AppConfig) service.AsyncLoteConfig {
+	return service.AsyncLoteConfig{
+		Enabled:      cfg.SEFAZAsyncLote,
+		PollInterval: cfg.SEFAZReceiptPollInterval,
+		MaxPolls:     cfg.SEFAZReceiptMaxPolls,
+		Pack:         cfg.SEFAZAsyncLotePack,
+	}
+}
+
+// provideOfflineContingencyConfig provides the offline contingency (tpEmis 9) settings
+func provideOfflineContingencyConfig(cfg *config.AppConfig) service.OfflineContingencyConfig {
+	return service.OfflineContingencyConfig{
+		Enabled:          cfg.SEFAZOfflineContingency,
+		TransmitInterval: cfg.SEFAZOfflineTransmitInterval,
+	}
+}
+
+// provideContingencyConfig provides the probe settings of the contingency periods
+func provideContingencyConfig(cfg *config.AppConfig) service.ContingencyConfig {
+	return service.ContingencyConfig{
+		ProbeInterval: cfg.SEFAZContingencyProbeInterval,
+	}
+}
+
+// provideSEFAZMonitor provides the SEFAZ status monitor shared by the worker service and the worker
+func provideSEFAZMonitor(soapClient soapclient.Client, cfg *config.AppConfig) *service.SEFAZMonitor {
+	return service.NewSEFAZMonitor(soapClient, service.SEFAZMonitorConfig{
+		Enabled:          cfg.SEFAZMonitorEnabled,
+		PollInterval:     cfg.SEFAZMonitorInterval,
+		FailureThreshold: cfg.SEFAZMonitorFailureThreshold,
+	})
+}
+
+// provideDFeConfig provides the NFeDistribuicaoDFe sync settings
+func provideDFeConfig(cfg *config.AppConfig) service.DFeConfig {
+	return service.DFeConfig{
+		Ambiente:     cfg.SEFAZDFeAmbiente,
+		SyncInterval: cfg.SEFAZDFeSyncInterval,
+	}
+}
+
+// provideRetrySchedulerConfig provides the bounds of the adaptive retry scheduler
+func provideRetrySchedulerConfig(cfg *config.AppConfig) service.RetrySchedulerConfig {
+	return service.RetrySchedulerConfig{
+		MinBatch:    cfg.RetryBatchMin,
+		MaxBatch:    cfg.RetryBatchMax,
+		MinInterval: cfg.RetryIntervalMin,
+		MaxInterval: cfg.RetryIntervalMax,
+		MaxInFlight: cfg.RetryMaxInFlight,
+	}
+}
+
+// provideStatementConfig provides the monthly usage statement settings
+func provideStatementConfig(cfg *config.AppConfig) service.StatementConfig {
+	return service.StatementConfig{
+		Enabled: cfg.StatementsEnabled,
+	}
+}
+
+// provideStorageRetentionConfig provides the plan retention settings of the stored files
+func provideStorageRetentionConfig(cfg *config.AppConfig) service.StorageRetentionConfig {
+	return service.StorageRetentionConfig{
+		Enabled:  cfg.StorageRetentionEnabled,
+		Interval: cfg.StorageRetentionSyncInterval,
+	}
+}
+
+// provideDocumentPurgeConfig provides the settings of the purge of the NFC-e documents
+func provideDocumentPurgeConfig(cfg *config.AppConfig) service.DocumentPurgeConfig {
+	return newDocumentPurgeConfig(cfg)
+}
+
+// provideCompanyOffboardingConfig provides the settings of the LGPD offboarding of the companies
+func provideCompanyOffboardingConfig(cfg *config.AppConfig) service.CompanyOffboardingConfig {
+	return newCompanyOffboardingConfig(cfg)
+}
+
+// provideSEFAZArchiveConfig provides the settings of the archive of the SEFAZ exchanges
+func provideSEFAZArchiveConfig(cfg *config.AppConfig) service.SEFAZArchiveConfig {
+	return newSEFAZArchiveConfig(cfg)
+}
+
+// provideSubscriptionLifecycleConfig provides the settings of the subscription lifecycle transitions
+func provideSubscriptionLifecycleConfig(cfg *config.AppConfig) service.SubscriptionLifecycleConfig {
+	return newSubscriptionLifecycleConfig(cfg)
+}
+
+// provideEmailSender provides the sender of the consumer e-mails, nil when disabled
+func provideEmailSender(cfg *config.AppConfig) (email.Sender, error) {
+	return email.NewSender(newEmailSenderConfig(cfg))
+}
+
+// provideEmailConfig provides the sender address of the consumer e-mails
+func provideEmailConfig(cfg *config.AppConfig) service.EmailConfig {
+	return service.EmailConfig{
+		From:     cfg.EmailFrom,
+		FromName: cfg.EmailFromName,
+	}
+}
+
+// provideCEPLookup provides the lookup of the company addresses by CEP, nil when disabled
+func provideCEPLookup(cfg *config.AppConfig) (cep.Lookup, error) {
+	return cep.NewLookup(newCEPLookupConfig(cfg))
+}
+
+// providePaymentGateway provides the gateway charging the subscriptions, nil when disabled
+func providePaymentGateway(cfg *config.AppConfig) (payment.Gateway, error) {
+	return payment.NewGateway(newPaymentGatewayConfig(cfg))
+}
+
+// provideBillingConfig provides the charge and dunning settings of the subscription billing
+func provideBillingConfig(cfg *config.AppConfig) service.BillingConfig {
+	return newBillingConfig(cfg)
+}
+
+// provideAuthConfig provides the admin token and API key settings
+func provideAuthConfig(cfg *config.AppConfig) usecase.AuthConfig {
+	return newAuthConfig(cfg)
+}
+
+// provideAuthenticator provides the credentials resolver of the auth middleware
+func provideAuthenticator(authUseCase usecase.AuthUseCase) middleware.Authenticator {
+	return authUseCase
+}
+
+// provideIdempotencyService provides the Idempotency-Key enforcement with the configured TTL
+func provideIdempotencyService(repo ports.IdempotencyKeyRepository, cfg *config.AppConfig) *service.IdempotencyService {
+	return service.NewIdempotencyService(repo, cfg.IdempotencyKeyTTL)
+}
+
+// provideIdempotencyStore provides the Idempotency-Key store of the idempotency middleware
+func provideIdempotencyStore(idempotency *service.IdempotencyService) middleware.IdempotencyStore {
+	return idempotency
+}
+
+// provideAuditRecorder provides the audit log of the audit middleware
+func provideAuditRecorder(audit *service.AuditService) middleware.AuditRecorder {
+	return audit
+}
+
+// provideRateLimiter provides the rate limits per company plan, nil when disabled
+func provideRateLimiter(cfg *config.AppConfig, subscriptionRepo ports.SubscriptionRepository, planRepo ports.PlanRepository) middleware.RateLimiter {
+	return newRateLimiter(cfg, subscriptionRepo, planRepo)
+}
+
+// provideGraphQLHandler provides the read-only GraphQL handler, nil when disabled
+func provideGraphQLHandler(cfg *config.AppConfig, nfceRepo ports.NFCeRepository, companyRepo ports.CompanyRepository) (*handler.GraphQLHandler, error) {
+	return newGraphQLHandler(cfg, nfceRepo, companyRepo)
+}
+
+// provideGRPCServer provides the gRPC emission API, nil when disabled
+func provideGRPCServer(cfg *config.AppConfig, nfceUseCase usecase.NFCeUseCase, authUseCase usecase.AuthUseCase, limiter middleware.RateLimiter, audit *service.AuditService, l logger.Logger) (*grpcserver.Server, error) {
+	return newGRPCServer(cfg, nfceUseCase, authUseCase, limiter, audit, l)
+}
+
+// provideShutdownHooks provides the API shutdown steps run after the in-flight requests
+func provideShutdownHooks(db *gorm.DB, publisher dto.Publisher, eventBus ports.EventBus, outboxRepo ports.OutboxRepository, nfceRepo ports.NFCeRepository, l logger.Logger) []server.ShutdownHook {
+	return newShutdownHooks(db, publisher, eventBus, outboxRepo, nfceRepo, l)
+}
+
+// provideOutboxRelayInterval provides the polling interval of the outbox relay
+func provideOutboxRelayInterval(cfg *config.AppConfig) time.Duration {
+	return cfg.OutboxRelayInterval
+}
+
 // provideMaxRetries provides max retry count
 func provideMaxRetries() int {
 	return 5
 }
 
-// provideWorkerCount provides worker count
-func provideWorkerCount() int {
-	return 3
-}
-
 // provideStorage provides storage service
 func provideStorage(cfg *config.AppConfig) (storage.StorageService, error) {
 	switch cfg.StorageType {
@@ -167,6 +568,10 @@ func provideStorage(cfg *config.AppConfig) (storage.StorageService, error) {
 			cfg.StorageBucket,
 			cfg.StorageUseSSL,
 		)
+	case "s3":
+		return storage.NewS3Storage(newS3Config(cfg))
+	case "gcs":
+		return storage.NewGCSStorage(newGCSConfig(cfg))
 	case "local":
 		return storage.NewLocalStorage(
 			cfg.StorageBasePath,
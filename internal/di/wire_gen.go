@@ -12,17 +12,29 @@ import (
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/cache"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/cep"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/clock"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/database/postgres"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/email"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/grpcserver"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/handler"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/middleware"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/server"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/messaging/rabbitmq"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/payment"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/danfe"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/qr"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/signer"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/tables"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/validator"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/statement"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/webhook"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/worker"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/database"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
@@ -39,6 +51,7 @@ func InitializeAPI(ctx context.Context, cfg *config.AppConfig, l logger.Logger)
 		return nil, err
 	}
 	nfCeRepository := postgres.NewNFCeRepository(db)
+	txManager := postgres.NewTxManager(db)
 	publisher, err := providePublisher(cfg)
 	if err != nil {
 		return nil, err
@@ -47,24 +60,154 @@ func InitializeAPI(ctx context.Context, cfg *config.AppConfig, l logger.Logger)
 	if err != nil {
 		return nil, err
 	}
-	nfCeUseCase := usecase.NewNFCeUseCase(nfCeRepository, publisher, storageService)
-	nfCeHandler := handler.NewNFCeHandler(nfCeUseCase)
-	companyRepository := postgres.NewCompanyRepository(db)
-	planRepository := postgres.NewPlanRepository(db)
+	eventBus, err := provideEventBus(cfg)
+	if err != nil {
+		return nil, err
+	}
+	renderer := provideDANFERenderer()
 	subscriptionRepository := postgres.NewSubscriptionRepository(db)
-	adminUseCase := usecase.NewAdminUseCase(companyRepository, planRepository, subscriptionRepository)
-	adminHandler := handler.NewAdminHandler(adminUseCase)
-	companyUseCase := usecase.NewCompanyUseCase(companyRepository, subscriptionRepository)
+	planRepository := postgres.NewPlanRepository(db)
+	webhookRepository := postgres.NewWebhookRepository(db)
+	webhookDispatcher := provideWebhookDispatcher(webhookRepository)
+	featureGateConfig := provideFeatureGateConfig(cfg)
+	featureGate := service.NewFeatureGate(subscriptionRepository, planRepository, webhookDispatcher, featureGateConfig, l)
+	secretCipher, err := provideSecretCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	metrics := provideCacheMetrics(cfg)
+	companyRepository, err := provideCompanyRepository(cfg, db, secretCipher, metrics, l)
+	if err != nil {
+		return nil, err
+	}
+	fraudGuard := service.NewFraudGuard(nfCeRepository, companyRepository, l)
+	catalogRepository := postgres.NewCatalogRepository(db)
+	catalogService := service.NewCatalogService(catalogRepository)
+	lookup, err := provideCEPLookup(cfg)
+	if err != nil {
+		return nil, err
+	}
+	municipioService := service.NewMunicipioService(catalogRepository, lookup, l)
+	maintenanceRepository := postgres.NewMaintenanceRepository(db)
+	maintenanceService := service.NewMaintenanceService(maintenanceRepository, companyRepository, webhookDispatcher, l)
+	outboxRepository := postgres.NewOutboxRepository(db)
+	nfCeLoteRepository := postgres.NewNFCeLoteRepository(db)
+	nfCeUseCase := usecase.NewNFCeUseCase(nfCeRepository, companyRepository, publisher, storageService, eventBus, renderer, featureGate, txManager, outboxRepository, nfCeLoteRepository, fraudGuard, catalogService, maintenanceService, l)
+	nfCeHandler := handler.NewNFCeHandler(nfCeUseCase)
+	sefazExchangeRepository := postgres.NewSEFAZExchangeRepository(db)
+	sefazArchiveConfig := provideSEFAZArchiveConfig(cfg)
+	sefazArchiveService := service.NewSEFAZArchiveService(sefazExchangeRepository, storageService, sefazArchiveConfig, l)
+	apiKeyRepository := postgres.NewAPIKeyRepository(db)
+	auditRepository := postgres.NewAuditRepository(db)
+	auditService := service.NewAuditService(auditRepository)
+	documentPurgeConfig := provideDocumentPurgeConfig(cfg)
+	documentPurgeService := service.NewDocumentPurgeService(companyRepository, subscriptionRepository, planRepository, nfCeRepository, txManager, storageService, documentPurgeConfig)
+	companyOffboardingConfig := provideCompanyOffboardingConfig(cfg)
+	companyOffboardingService := service.NewCompanyOffboardingService(companyRepository, apiKeyRepository, subscriptionRepository, nfCeRepository, txManager, auditService, documentPurgeService, companyOffboardingConfig)
+	adminUseCase := usecase.NewAdminUseCase(companyRepository, planRepository, subscriptionRepository, nfCeRepository, txManager, outboxRepository, sefazArchiveService, municipioService, companyOffboardingService)
+	adminRepository := postgres.NewAdminRepository(db)
+	authConfig := provideAuthConfig(cfg)
+	authUseCase := usecase.NewAuthUseCase(adminRepository, apiKeyRepository, companyRepository, txManager, authConfig, l)
+	adminHandler := handler.NewAdminHandler(adminUseCase, authUseCase)
+	monitor := provideClockMonitor(cfg, l)
+	builder := provideXMLBuilder(companyRepository, nfCeRepository, monitor, cfg)
+	signer := provideXMLSigner()
+	xmlValidator, err := provideXMLValidator(ctx, cfg, l)
+	if err != nil {
+		return nil, err
+	}
+	reloader, err := provideSEFAZTables(ctx, cfg, l)
+	if err != nil {
+		return nil, err
+	}
+	client, err := provideSOAPClient(cfg, reloader, l)
+	if err != nil {
+		return nil, err
+	}
+	generator := provideQRGenerator()
+	asyncLoteConfig := provideAsyncLoteConfig(cfg)
+	offlineContingencyConfig := provideOfflineContingencyConfig(cfg)
+	contingencyStateRepository := postgres.NewContingencyStateRepository(db)
+	contingencyConfig := provideContingencyConfig(cfg)
+	sefazMonitor := provideSEFAZMonitor(client, cfg)
+	taxEngine, err := provideTaxEngine(cfg)
+	if err != nil {
+		return nil, err
+	}
+	nfCeWorkerService := service.NewNFCeWorkerService(builder, signer, xmlValidator, client, generator, renderer, storageService, companyRepository, featureGate, asyncLoteConfig, offlineContingencyConfig, sefazMonitor, taxEngine, contingencyStateRepository, contingencyConfig, sefazArchiveService, l)
+	companyVerifier := service.NewCompanyVerifier(companyRepository, nfCeWorkerService, client)
+	companyUseCase := usecase.NewCompanyUseCase(companyRepository, subscriptionRepository, companyVerifier, secretCipher, municipioService)
 	companyHandler := handler.NewCompanyHandler(companyUseCase)
 	planUseCase := usecase.NewPlanUseCase(planRepository)
 	planHandler := handler.NewPlanHandler(planUseCase)
-	subscriptionUseCase := usecase.NewSubscriptionUseCase(subscriptionRepository, planRepository, companyRepository)
+	statementRepository := postgres.NewStatementRepository(db)
+	invoiceRepository := postgres.NewInvoiceRepository(db)
+	subscriptionEventRepository := postgres.NewSubscriptionEventRepository(db)
+	subscriptionUseCase := usecase.NewSubscriptionUseCase(subscriptionRepository, planRepository, companyRepository, txManager, statementRepository, invoiceRepository, subscriptionEventRepository, storageService)
 	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionUseCase)
-	webhookRepository := postgres.NewWebhookRepository(db)
 	webhookUseCase := usecase.NewWebhookUseCase(webhookRepository)
 	webhookHandler := handler.NewWebhookHandler(webhookUseCase)
+	exportRepository := postgres.NewExportRepository(db)
+	exportService := service.NewExportService(nfCeRepository, exportRepository, storageService, webhookDispatcher, l)
+	exportUseCase := usecase.NewExportUseCase(exportRepository, publisher, storageService, exportService)
+	exportHandler := handler.NewExportHandler(exportUseCase)
+	inutilizacaoRepository := postgres.NewInutilizacaoRepository(db)
+	inutilizacaoUseCase := usecase.NewInutilizacaoUseCase(inutilizacaoRepository, companyRepository, featureGate, txManager, publisher)
+	inutilizacaoHandler := handler.NewInutilizacaoHandler(inutilizacaoUseCase)
+	numberingSeriesRepository := postgres.NewNumberingSeriesRepository(db)
+	seriesUseCase := usecase.NewSeriesUseCase(numberingSeriesRepository, txManager)
+	seriesHandler := handler.NewSeriesHandler(seriesUseCase)
+	configHandler := handler.NewConfigHandler(cfg)
+	schemaHandler := provideSchemaHandler(xmlValidator, cfg, l)
+	sefazTablesHandler := handler.NewSEFAZTablesHandler(reloader)
+	dFeRepository := postgres.NewDFeRepository(db)
+	dFeUseCase := usecase.NewDFeUseCase(dFeRepository, storageService)
+	dFeHandler := handler.NewDFeHandler(dFeUseCase)
+	maintenanceUseCase := usecase.NewMaintenanceUseCase(maintenanceService)
+	maintenanceHandler := handler.NewMaintenanceHandler(maintenanceUseCase)
+	apiKeyHandler := handler.NewAPIKeyHandler(authUseCase)
+	emailDeliveryRepository := postgres.NewEmailDeliveryRepository(db)
+	sender, err := provideEmailSender(cfg)
+	if err != nil {
+		return nil, err
+	}
+	emailConfig := provideEmailConfig(cfg)
+	emailService := service.NewEmailService(emailDeliveryRepository, companyRepository, storageService, renderer, sender, emailConfig)
+	emailUseCase := usecase.NewEmailUseCase(nfCeRepository, emailService)
+	emailHandler := handler.NewEmailHandler(emailUseCase)
+	deadLetterQueue := provideDeadLetterQueue(cfg)
+	deadLetterUseCase := usecase.NewDeadLetterUseCase(deadLetterQueue)
+	deadLetterHandler := handler.NewDeadLetterHandler(deadLetterUseCase)
+	auditUseCase := usecase.NewAuditUseCase(auditService)
+	auditHandler := handler.NewAuditHandler(auditUseCase)
+	gateway, err := providePaymentGateway(cfg)
+	if err != nil {
+		return nil, err
+	}
+	billingConfig := provideBillingConfig(cfg)
+	billingService := service.NewBillingService(subscriptionRepository, planRepository, invoiceRepository, subscriptionEventRepository, txManager, gateway, webhookDispatcher, billingConfig, l)
+	billingUseCase := usecase.NewBillingUseCase(billingService)
+	billingHandler := handler.NewBillingHandler(billingUseCase)
+	cacheHandler := handler.NewCacheHandler(cfg, metrics)
+	graphQLHandler, err := provideGraphQLHandler(cfg, nfCeRepository, companyRepository)
+	if err != nil {
+		return nil, err
+	}
+	publicUseCase := usecase.NewPublicUseCase(nfCeRepository, companyRepository, nfCeWorkerService, l)
+	publicHandler := handler.NewPublicHandler(publicUseCase)
+	authenticator := provideAuthenticator(authUseCase)
+	idempotencyKeyRepository := postgres.NewIdempotencyKeyRepository(db)
+	idempotencyService := provideIdempotencyService(idempotencyKeyRepository, cfg)
+	idempotencyStore := provideIdempotencyStore(idempotencyService)
+	auditRecorder := provideAuditRecorder(auditService)
+	rateLimiter := provideRateLimiter(cfg, subscriptionRepository, planRepository)
+	grpcserverServer, err := provideGRPCServer(cfg, nfCeUseCase, authUseCase, rateLimiter, auditService, l)
+	if err != nil {
+		return nil, err
+	}
+	v := provideShutdownHooks(db, publisher, eventBus, outboxRepository, nfCeRepository, l)
 	string2 := providePort(cfg)
-	serverServer := server.NewServer(nfCeHandler, adminHandler, companyHandler, planHandler, subscriptionHandler, webhookHandler, l, string2)
+	serverServer := server.NewServer(nfCeHandler, adminHandler, companyHandler, planHandler, subscriptionHandler, webhookHandler, exportHandler, inutilizacaoHandler, seriesHandler, configHandler, schemaHandler, sefazTablesHandler, dFeHandler, maintenanceHandler, apiKeyHandler, emailHandler, deadLetterHandler, auditHandler, billingHandler, cacheHandler, graphQLHandler, publicHandler, authenticator, idempotencyStore, auditRecorder, rateLimiter, monitor, grpcserverServer, v, l, string2)
 	return serverServer, nil
 }
 
@@ -75,6 +218,7 @@ func InitializeWorker(ctx context.Context, cfg *config.AppConfig, l logger.Logge
 		return nil, err
 	}
 	nfCeRepository := postgres.NewNFCeRepository(db)
+	txManager := postgres.NewTxManager(db)
 	publisher, err := providePublisher(cfg)
 	if err != nil {
 		return nil, err
@@ -83,22 +227,105 @@ func InitializeWorker(ctx context.Context, cfg *config.AppConfig, l logger.Logge
 	if err != nil {
 		return nil, err
 	}
-	builder := provideXMLBuilder(db)
+	eventBus, err := provideEventBus(cfg)
+	if err != nil {
+		return nil, err
+	}
+	webhookRepository := postgres.NewWebhookRepository(db)
+	webhookDispatcher := provideWebhookDispatcher(webhookRepository)
+	secretCipher, err := provideSecretCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	metrics := provideCacheMetrics(cfg)
+	companyRepository, err := provideCompanyRepository(cfg, db, secretCipher, metrics, l)
+	if err != nil {
+		return nil, err
+	}
+	monitor := provideClockMonitor(cfg, l)
+	builder := provideXMLBuilder(companyRepository, nfCeRepository, monitor, cfg)
 	signer := provideXMLSigner()
-	xmlValidator, err := provideXMLValidator()
+	xmlValidator, err := provideXMLValidator(ctx, cfg, l)
+	if err != nil {
+		return nil, err
+	}
+	reloader, err := provideSEFAZTables(ctx, cfg, l)
+	if err != nil {
+		return nil, err
+	}
+	client, err := provideSOAPClient(cfg, reloader, l)
 	if err != nil {
 		return nil, err
 	}
-	client := provideSOAPClient()
 	generator := provideQRGenerator()
+	renderer := provideDANFERenderer()
 	storageService, err := provideStorage(cfg)
 	if err != nil {
 		return nil, err
 	}
-	companyRepository := postgres.NewCompanyRepository(db)
-	nfCeWorkerService := service.NewNFCeWorkerService(builder, signer, xmlValidator, client, generator, storageService, companyRepository)
+	subscriptionRepository := postgres.NewSubscriptionRepository(db)
+	planRepository := postgres.NewPlanRepository(db)
+	featureGateConfig := provideFeatureGateConfig(cfg)
+	featureGate := service.NewFeatureGate(subscriptionRepository, planRepository, webhookDispatcher, featureGateConfig, l)
+	asyncLoteConfig := provideAsyncLoteConfig(cfg)
+	offlineContingencyConfig := provideOfflineContingencyConfig(cfg)
+	contingencyStateRepository := postgres.NewContingencyStateRepository(db)
+	contingencyConfig := provideContingencyConfig(cfg)
+	retrySchedulerConfig := provideRetrySchedulerConfig(cfg)
+	sefazMonitor := provideSEFAZMonitor(client, cfg)
+	taxEngine, err := provideTaxEngine(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sefazExchangeRepository := postgres.NewSEFAZExchangeRepository(db)
+	sefazArchiveConfig := provideSEFAZArchiveConfig(cfg)
+	sefazArchiveService := service.NewSEFAZArchiveService(sefazExchangeRepository, storageService, sefazArchiveConfig, l)
+	nfCeWorkerService := service.NewNFCeWorkerService(builder, signer, xmlValidator, client, generator, renderer, storageService, companyRepository, featureGate, asyncLoteConfig, offlineContingencyConfig, sefazMonitor, taxEngine, contingencyStateRepository, contingencyConfig, sefazArchiveService, l)
 	int2 := provideMaxRetries()
-	workerWorker := worker.NewWorker(nfCeRepository, publisher, consumer, nfCeWorkerService, l, int2)
+	exportRepository := postgres.NewExportRepository(db)
+	exportService := service.NewExportService(nfCeRepository, exportRepository, storageService, webhookDispatcher, l)
+	inutilizacaoRepository := postgres.NewInutilizacaoRepository(db)
+	inutilizacaoService := service.NewInutilizacaoService(inutilizacaoRepository, companyRepository, signer, client, storageService, l)
+	dFeRepository := postgres.NewDFeRepository(db)
+	dFeConfig := provideDFeConfig(cfg)
+	dFeService := service.NewDFeService(dFeRepository, companyRepository, client, storageService, dFeConfig)
+	statementRepository := postgres.NewStatementRepository(db)
+	subscriptionEventRepository := postgres.NewSubscriptionEventRepository(db)
+	statementRenderer := statement.NewRenderer()
+	statementConfig := provideStatementConfig(cfg)
+	statementService := service.NewStatementService(statementRepository, companyRepository, subscriptionRepository, planRepository, subscriptionEventRepository, nfCeRepository, webhookRepository, storageService, statementRenderer, webhookDispatcher, statementConfig, l)
+	storageRetentionConfig := provideStorageRetentionConfig(cfg)
+	storageRetentionService := service.NewStorageRetentionService(companyRepository, subscriptionRepository, planRepository, storageService, storageRetentionConfig)
+	documentPurgeConfig := provideDocumentPurgeConfig(cfg)
+	documentPurgeService := service.NewDocumentPurgeService(companyRepository, subscriptionRepository, planRepository, nfCeRepository, txManager, storageService, documentPurgeConfig)
+	apiKeyRepository := postgres.NewAPIKeyRepository(db)
+	auditRepository := postgres.NewAuditRepository(db)
+	auditService := service.NewAuditService(auditRepository)
+	companyOffboardingConfig := provideCompanyOffboardingConfig(cfg)
+	companyOffboardingService := service.NewCompanyOffboardingService(companyRepository, apiKeyRepository, subscriptionRepository, nfCeRepository, txManager, auditService, documentPurgeService, companyOffboardingConfig)
+	invoiceRepository := postgres.NewInvoiceRepository(db)
+	gateway, err := providePaymentGateway(cfg)
+	if err != nil {
+		return nil, err
+	}
+	billingConfig := provideBillingConfig(cfg)
+	billingService := service.NewBillingService(subscriptionRepository, planRepository, invoiceRepository, subscriptionEventRepository, txManager, gateway, webhookDispatcher, billingConfig, l)
+	subscriptionLifecycleConfig := provideSubscriptionLifecycleConfig(cfg)
+	subscriptionLifecycleService := service.NewSubscriptionLifecycleService(subscriptionRepository, planRepository, txManager, webhookDispatcher, subscriptionLifecycleConfig, l)
+	maintenanceRepository := postgres.NewMaintenanceRepository(db)
+	maintenanceService := service.NewMaintenanceService(maintenanceRepository, companyRepository, webhookDispatcher, l)
+	emailDeliveryRepository := postgres.NewEmailDeliveryRepository(db)
+	sender, err := provideEmailSender(cfg)
+	if err != nil {
+		return nil, err
+	}
+	emailConfig := provideEmailConfig(cfg)
+	emailService := service.NewEmailService(emailDeliveryRepository, companyRepository, storageService, renderer, sender, emailConfig)
+	idempotencyKeyRepository := postgres.NewIdempotencyKeyRepository(db)
+	idempotencyService := provideIdempotencyService(idempotencyKeyRepository, cfg)
+	outboxRepository := postgres.NewOutboxRepository(db)
+	duration := provideOutboxRelayInterval(cfg)
+	workerWorker := worker.NewWorker(nfCeRepository, txManager, publisher, consumer, eventBus, webhookDispatcher, nfCeWorkerService, exportService, inutilizacaoService, dFeService, statementService, storageRetentionService, documentPurgeService, companyOffboardingService, sefazArchiveService, billingService, subscriptionLifecycleService, emailService, idempotencyService, outboxRepository, duration, asyncLoteConfig, offlineContingencyConfig, retrySchedulerConfig, sefazMonitor, monitor, maintenanceService, metrics, l, int2)
 	return workerWorker, nil
 }
 
@@ -109,7 +336,7 @@ func provideDatabase(cfg *config.AppConfig) (*gorm.DB, error) {
 
 	if database.GetDB() == nil {
 		ctx := context.Background()
-		if err := database.InitDatabase(ctx, cfg.GetDatabaseDSN(), cfg.Env); err != nil {
+		if err := database.InitDatabaseWithConfig(ctx, cfg.DatabaseConfig()); err != nil {
 			return nil, fmt.Errorf("failed to initialize database: %w", err)
 		}
 	}
@@ -125,6 +352,16 @@ func providePublisher(cfg *config.AppConfig) (dto.Publisher, error) {
 	return dto.Publisher(publisher), nil
 }
 
+// provideEventBus provides RabbitMQ event bus
+func provideEventBus(cfg *config.AppConfig) (ports.EventBus, error) {
+	return rabbitmq.NewEventBus(cfg.RabbitMQURL)
+}
+
+// provideDeadLetterQueue provides the inspector of the emission dead-letter queue
+func provideDeadLetterQueue(cfg *config.AppConfig) dto.DeadLetterQueue {
+	return rabbitmq.NewDeadLetterQueue(cfg.RabbitMQURL)
+}
+
 // providePort provides the server port
 func providePort(cfg *config.AppConfig) string {
 	return cfg.Port
@@ -132,17 +369,43 @@ func providePort(cfg *config.AppConfig) string {
 
 // provideConsumer provides RabbitMQ consumer
 func provideConsumer(cfg *config.AppConfig) (dto.Consumer, error) {
-	consumer, err := rabbitmq.NewConsumer(cfg.RabbitMQURL)
+	consumer, err := rabbitmq.NewConsumer(cfg.RabbitMQURL, cfg.RabbitMQMaxDeliveryAttempts, cfg.WorkerConcurrency)
 	if err != nil {
 		return nil, err
 	}
 	return dto.Consumer(consumer), nil
 }
 
-// provideXMLBuilder provides XML builder
-func provideXMLBuilder(db *gorm.DB) nfe.Builder {
-	companyRepo := postgres.NewCompanyRepository(db)
-	return nfe.NewBuilder(companyRepo)
+// provideXMLBuilder provides XML builder stamping dhEmi from the clock monitor and checking
+// the chaves de acesso already issued
+func provideXMLBuilder(companyRepo ports.CompanyRepository, nfceRepo ports.NFCeRepository, clockMonitor *clock.Monitor, cfg *config.AppConfig) nfe.Builder {
+	return nfe.NewBuilder(companyRepo, nfceRepo, clockMonitor, newRespTecConfig(cfg))
+}
+
+// provideSecretCipher provides the cipher of the secrets stored in the database
+func provideSecretCipher(cfg *config.AppConfig) (ports.SecretCipher, error) {
+	return newSecretCipher(cfg)
+}
+
+// provideCacheMetrics provides the hit ratio counters of the cache, nil when no cache is configured
+func provideCacheMetrics(cfg *config.AppConfig) *cache.Metrics {
+	return newCacheMetrics(cfg)
+}
+
+// provideCompanyRepository provides the company repository, served from the cache when configured
+func provideCompanyRepository(cfg *config.AppConfig, db *gorm.DB, secrets ports.SecretCipher, metrics *cache.Metrics, l logger.Logger) (ports.CompanyRepository, error) {
+	return newCompanyRepository(cfg, db, secrets, metrics, l)
+}
+
+// provideClockMonitor provides the NTP clock skew monitor
+func provideClockMonitor(cfg *config.AppConfig, l logger.Logger) *clock.Monitor {
+	return clock.NewMonitor(clock.MonitorConfig{
+		Enabled:    cfg.ClockCheckEnabled,
+		Servers:    cfg.ClockNTPServers,
+		Interval:   cfg.ClockCheckInterval,
+		Threshold:  cfg.ClockSkewThreshold,
+		Compensate: cfg.ClockCompensateDhEmi,
+	}, l)
 }
 
 // provideXMLSigner provides XML signer
@@ -150,14 +413,34 @@ func provideXMLSigner() signer.Signer {
 	return signer.NewSigner()
 }
 
-// provideXMLValidator provides XML validator
-func provideXMLValidator() (validator.XMLValidator, error) {
-	return validator.NewXMLValidator("./internal/infrastructure/sefaz/schemas")
+// provideXMLValidator provides XML validator with the pinned schemas installed
+func provideXMLValidator(ctx context.Context, cfg *config.AppConfig, l logger.Logger) (validator.XMLValidator, error) {
+	return newXMLValidator(ctx, cfg, l)
+}
+
+// provideSchemaHandler provides the SEFAZ schema admin handler for the pinned package
+func provideSchemaHandler(xmlValidator validator.XMLValidator, cfg *config.AppConfig, l logger.Logger) *handler.SchemaHandler {
+	return handler.NewSchemaHandler(xmlValidator, schemaPackage(cfg), l)
 }
 
-// provideSOAPClient provides SOAP client
-func provideSOAPClient() soapclient.Client {
-	return soapclient.NewSOAPClient(30 * time.Second)
+// provideSEFAZTables provides the SEFAZ endpoint and QR Code tables, reloaded on SIGHUP
+func provideSEFAZTables(ctx context.Context, cfg *config.AppConfig, l logger.Logger) (*tables.Reloader, error) {
+	return newSEFAZTables(ctx, cfg, l)
+}
+
+// provideSOAPClient provides SOAP client with the SEFAZ endpoint registry, limited per UF; the
+// simulated ambiente is answered in process
+func provideSOAPClient(cfg *config.AppConfig, sefazTables *tables.Reloader, l logger.Logger) (soapclient.Client, error) {
+	return newSOAPClient(cfg, sefazTables, l)
+}
+
+// provideTaxEngine provides the tax engine with the configured aliquot tables
+func provideTaxEngine(cfg *config.AppConfig) (*service.TaxEngine, error) {
+	tables, err := service.LoadTaxTables(cfg.TaxTablesFile)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewTaxEngine(tables), nil
 }
 
 // provideQRGenerator provides QR code generator
@@ -165,16 +448,194 @@ func provideQRGenerator() qr.Generator {
 	return qr.NewGenerator()
 }
 
+// provideDANFERenderer provides DANFE renderer
+func provideDANFERenderer() danfe.Renderer {
+	return danfe.NewRenderer()
+}
+
+// provideWebhookDispatcher provides webhook dispatcher
+func provideWebhookDispatcher(webhookRepo ports.WebhookRepository) ports.WebhookDispatcher {
+	return webhook.NewDispatcher(webhookRepo, 10*time.Second)
+}
+
+// provideFeatureGateConfig provides the plan feature gate settings
+func provideFeatureGateConfig(cfg *config.AppConfig) service.FeatureGateConfig {
+	return service.FeatureGateConfig{
+		UpgradeURL: cfg.PlanUpgradeURL,
+	}
+}
+
+// provideAsyncLoteConfig provides the asynchronous lote submission settings
+func provideAsyncLoteConfig(cfg *config.AppConfig) service.AsyncLoteConfig {
+	return service.AsyncLoteConfig{
+		Enabled:      cfg.SEFAZAsyncLote,
+		PollInterval: cfg.SEFAZReceiptPollInterval,
+		MaxPolls:     cfg.SEFAZReceiptMaxPolls,
+		Pack:         cfg.SEFAZAsyncLotePack,
+	}
+}
+
+// provideOfflineContingencyConfig provides the offline contingency (tpEmis 9) settings
+func provideOfflineContingencyConfig(cfg *config.AppConfig) service.OfflineContingencyConfig {
+	return service.OfflineContingencyConfig{
+		Enabled:          cfg.SEFAZOfflineContingency,
+		TransmitInterval: cfg.SEFAZOfflineTransmitInterval,
+	}
+}
+
+// provideContingencyConfig provides the probe settings of the contingency periods
+func provideContingencyConfig(cfg *config.AppConfig) service.ContingencyConfig {
+	return service.ContingencyConfig{
+		ProbeInterval: cfg.SEFAZContingencyProbeInterval,
+	}
+}
+
+// provideSEFAZMonitor provides the SEFAZ status monitor shared by the worker service and the worker
+func provideSEFAZMonitor(soapClient soapclient.Client, cfg *config.AppConfig) *service.SEFAZMonitor {
+	return service.NewSEFAZMonitor(soapClient, service.SEFAZMonitorConfig{
+		Enabled:          cfg.SEFAZMonitorEnabled,
+		PollInterval:     cfg.SEFAZMonitorInterval,
+		FailureThreshold: cfg.SEFAZMonitorFailureThreshold,
+	})
+}
+
+// provideDFeConfig provides the NFeDistribuicaoDFe sync settings
+func provideDFeConfig(cfg *config.AppConfig) service.DFeConfig {
+	return service.DFeConfig{
+		Ambiente:     cfg.SEFAZDFeAmbiente,
+		SyncInterval: cfg.SEFAZDFeSyncInterval,
+	}
+}
+
+// provideRetrySchedulerConfig provides the bounds of the adaptive retry scheduler
+func provideRetrySchedulerConfig(cfg *config.AppConfig) service.RetrySchedulerConfig {
+	return service.RetrySchedulerConfig{
+		MinBatch:    cfg.RetryBatchMin,
+		MaxBatch:    cfg.RetryBatchMax,
+		MinInterval: cfg.RetryIntervalMin,
+		MaxInterval: cfg.RetryIntervalMax,
+		MaxInFlight: cfg.RetryMaxInFlight,
+	}
+}
+
+// provideStatementConfig provides the monthly usage statement settings
+func provideStatementConfig(cfg *config.AppConfig) service.StatementConfig {
+	return service.StatementConfig{
+		Enabled: cfg.StatementsEnabled,
+	}
+}
+
+// provideStorageRetentionConfig provides the plan retention settings of the stored files
+func provideStorageRetentionConfig(cfg *config.AppConfig) service.StorageRetentionConfig {
+	return service.StorageRetentionConfig{
+		Enabled:  cfg.StorageRetentionEnabled,
+		Interval: cfg.StorageRetentionSyncInterval,
+	}
+}
+
+// provideDocumentPurgeConfig provides the settings of the purge of the NFC-e documents
+func provideDocumentPurgeConfig(cfg *config.AppConfig) service.DocumentPurgeConfig {
+	return newDocumentPurgeConfig(cfg)
+}
+
+// provideCompanyOffboardingConfig provides the settings of the LGPD offboarding of the companies
+func provideCompanyOffboardingConfig(cfg *config.AppConfig) service.CompanyOffboardingConfig {
+	return newCompanyOffboardingConfig(cfg)
+}
+
+// provideSEFAZArchiveConfig provides the settings of the archive of the SEFAZ exchanges
+func provideSEFAZArchiveConfig(cfg *config.AppConfig) service.SEFAZArchiveConfig {
+	return newSEFAZArchiveConfig(cfg)
+}
+
+// provideSubscriptionLifecycleConfig provides the settings of the subscription lifecycle transitions
+func provideSubscriptionLifecycleConfig(cfg *config.AppConfig) service.SubscriptionLifecycleConfig {
+	return newSubscriptionLifecycleConfig(cfg)
+}
+
+// provideEmailSender provides the sender of the consumer e-mails, nil when disabled
+func provideEmailSender(cfg *config.AppConfig) (email.Sender, error) {
+	return email.NewSender(newEmailSenderConfig(cfg))
+}
+
+// provideEmailConfig provides the sender address of the consumer e-mails
+func provideEmailConfig(cfg *config.AppConfig) service.EmailConfig {
+	return service.EmailConfig{
+		From:     cfg.EmailFrom,
+		FromName: cfg.EmailFromName,
+	}
+}
+
+// provideCEPLookup provides the lookup of the company addresses by CEP, nil when disabled
+func provideCEPLookup(cfg *config.AppConfig) (cep.Lookup, error) {
+	return cep.NewLookup(newCEPLookupConfig(cfg))
+}
+
+// providePaymentGateway provides the gateway charging the subscriptions, nil when disabled
+func providePaymentGateway(cfg *config.AppConfig) (payment.Gateway, error) {
+	return payment.NewGateway(newPaymentGatewayConfig(cfg))
+}
+
+// provideBillingConfig provides the charge and dunning settings of the subscription billing
+func provideBillingConfig(cfg *config.AppConfig) service.BillingConfig {
+	return newBillingConfig(cfg)
+}
+
+// provideAuthConfig provides the admin token and API key settings
+func provideAuthConfig(cfg *config.AppConfig) usecase.AuthConfig {
+	return newAuthConfig(cfg)
+}
+
+// provideAuthenticator provides the credentials resolver of the auth middleware
+func provideAuthenticator(authUseCase usecase.AuthUseCase) middleware.Authenticator {
+	return authUseCase
+}
+
+// provideIdempotencyService provides the Idempotency-Key enforcement with the configured TTL
+func provideIdempotencyService(repo ports.IdempotencyKeyRepository, cfg *config.AppConfig) *service.IdempotencyService {
+	return service.NewIdempotencyService(repo, cfg.IdempotencyKeyTTL)
+}
+
+// provideIdempotencyStore provides the Idempotency-Key store of the idempotency middleware
+func provideIdempotencyStore(idempotency *service.IdempotencyService) middleware.IdempotencyStore {
+	return idempotency
+}
+
+// provideAuditRecorder provides the audit log of the audit middleware
+func provideAuditRecorder(audit *service.AuditService) middleware.AuditRecorder {
+	return audit
+}
+
+// provideRateLimiter provides the rate limits per company plan, nil when disabled
+func provideRateLimiter(cfg *config.AppConfig, subscriptionRepo ports.SubscriptionRepository, planRepo ports.PlanRepository) middleware.RateLimiter {
+	return newRateLimiter(cfg, subscriptionRepo, planRepo)
+}
+
+// provideGraphQLHandler provides the read-only GraphQL handler, nil when disabled
+func provideGraphQLHandler(cfg *config.AppConfig, nfceRepo ports.NFCeRepository, companyRepo ports.CompanyRepository) (*handler.GraphQLHandler, error) {
+	return newGraphQLHandler(cfg, nfceRepo, companyRepo)
+}
+
+// provideGRPCServer provides the gRPC emission API, nil when disabled
+func provideGRPCServer(cfg *config.AppConfig, nfceUseCase usecase.NFCeUseCase, authUseCase usecase.AuthUseCase, limiter middleware.RateLimiter, audit *service.AuditService, l logger.Logger) (*grpcserver.Server, error) {
+	return newGRPCServer(cfg, nfceUseCase, authUseCase, limiter, audit, l)
+}
+
+// provideShutdownHooks provides the API shutdown steps run after the in-flight requests
+func provideShutdownHooks(db *gorm.DB, publisher dto.Publisher, eventBus ports.EventBus, outboxRepo ports.OutboxRepository, nfceRepo ports.NFCeRepository, l logger.Logger) []server.ShutdownHook {
+	return newShutdownHooks(db, publisher, eventBus, outboxRepo, nfceRepo, l)
+}
+
+// provideOutboxRelayInterval provides the polling interval of the outbox relay
+func provideOutboxRelayInterval(cfg *config.AppConfig) time.Duration {
+	return cfg.OutboxRelayInterval
+}
+
 // provideMaxRetries provides max retry count
 func provideMaxRetries() int {
 	return 5
 }
 
-// provideWorkerCount provides worker count
-func provideWorkerCount() int {
-	return 3
-}
-
 // provideStorage provides storage service
 func provideStorage(cfg *config.AppConfig) (storage.StorageService, error) {
 	switch cfg.StorageType {
@@ -186,6 +647,10 @@ func provideStorage(cfg *config.AppConfig) (storage.StorageService, error) {
 			cfg.StorageBucket,
 			cfg.StorageUseSSL,
 		)
+	case "s3":
+		return storage.NewS3Storage(newS3Config(cfg))
+	case "gcs":
+		return storage.NewGCSStorage(newGCSConfig(cfg))
 	case "local":
 		return storage.NewLocalStorage(
 			cfg.StorageBasePath,
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Admin struct {
@@ -12,7 +13,7 @@ type Admin struct {
 	Name      string    `json:"name"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password"`
+	Password  string    `json:"-"` // bcrypt hash
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -24,17 +25,51 @@ func NewAdmin(username, name, email, password string) (*Admin, error) {
 	if email == "" {
 		return nil, errors.New("email is required")
 	}
-	if password == "" {
-		return nil, errors.New("password is required")
-	}
 
-	return &Admin{
+	admin := &Admin{
 		ID:        uuid.New().String(),
 		Name:      name,
 		Username:  username,
 		Email:     email,
-		Password:  password,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
-	}, nil
+	}
+	if err := admin.SetPassword(password); err != nil {
+		return nil, err
+	}
+
+	return admin, nil
+}
+
+// SetPassword stores the bcrypt hash of the password
+func (a *Admin) SetPassword(password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	a.Password = hash
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// CheckPassword reports whether the password matches the stored hash
+func (a *Admin) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(a.Password), []byte(password)) == nil
+}
+
+// HashPassword returns the bcrypt hash stored in admins.password
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password is required")
+	}
+	if len(password) < 8 {
+		return "", errors.New("password must have at least 8 characters")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
 }
@@ -0,0 +1,141 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FraudAction is what happens when a pre-emission check is violated
+type FraudAction string
+
+const (
+	// FraudActionOff disables the check.
+	FraudActionOff FraudAction = "off"
+	// FraudActionWarn accepts the emission and flags it in the metadata.
+	FraudActionWarn FraudAction = "warn"
+	// FraudActionBlock refuses the emission.
+	FraudActionBlock FraudAction = "block"
+)
+
+// Built-in pre-emission checks
+const (
+	FraudCheckVelocity    = "velocity"     // NFC-e per terminal within the window
+	FraudCheckTicketValue = "ticket_value" // Abnormal NFC-e total
+	FraudCheckDuplicate   = "duplicate"    // Identical NFC-e (items and payments) within the window
+)
+
+// FraudMetadataKey is the metadata key listing the checks that warned on an NFC-e
+const FraudMetadataKey = "antifraud_warning"
+
+// FraudRule configures a pre-emission check
+type FraudRule struct {
+	Action        FraudAction `json:"action"`
+	Limit         float64     `json:"limit,omitempty"`          // Max NFC-e (velocity) or max total in R$ (ticket_value)
+	WindowSeconds int         `json:"window_seconds,omitempty"` // Lookback of velocity and duplicate
+}
+
+// Window returns the lookback of the rule
+func (r FraudRule) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+// FraudRules configures the pre-emission checks of a company by check name.
+// Checks missing from the company rules use DefaultFraudRules.
+type FraudRules map[string]FraudRule
+
+// DefaultFraudRules is the rule set of companies without their own configuration.
+// Every check only warns, blocking is enabled per company.
+func DefaultFraudRules() FraudRules {
+	return FraudRules{
+		FraudCheckVelocity:    {Action: FraudActionWarn, Limit: 20, WindowSeconds: 60},
+		FraudCheckTicketValue: {Action: FraudActionWarn, Limit: 10000},
+		FraudCheckDuplicate:   {Action: FraudActionWarn, WindowSeconds: 120},
+	}
+}
+
+// Validate checks the actions, limits and windows of the rules
+func (r FraudRules) Validate() error {
+	for name, rule := range r {
+		if name == "" {
+			return errors.New("regra antifraude requer nome")
+		}
+		switch rule.Action {
+		case FraudActionOff, FraudActionWarn, FraudActionBlock:
+		default:
+			return fmt.Errorf("ação %q inválida na regra antifraude %s (use off, warn ou block)", rule.Action, name)
+		}
+		if rule.Limit < 0 {
+			return fmt.Errorf("limite da regra antifraude %s não pode ser negativo", name)
+		}
+		if rule.WindowSeconds < 0 {
+			return fmt.Errorf("janela da regra antifraude %s não pode ser negativa", name)
+		}
+	}
+	return nil
+}
+
+// Effective returns the default rules overridden by the company rules
+func (r FraudRules) Effective() FraudRules {
+	rules := DefaultFraudRules()
+	for name, rule := range r {
+		rules[name] = rule
+	}
+	return rules
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (r FraudRules) Value() (driver.Value, error) {
+	if r == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (r *FraudRules) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("FraudRules.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
+
+// FraudDecision is the outcome of a pre-emission check
+type FraudDecision struct {
+	Check    string      `json:"check"`
+	Action   FraudAction `json:"action"`
+	Violated bool        `json:"violated"`
+	Detail   string      `json:"detail,omitempty"`
+}
+
+// FraudDecisions are the outcomes of the pre-emission checks of an NFC-e
+type FraudDecisions []FraudDecision
+
+// Blocking returns the first violated check configured to block
+func (d FraudDecisions) Blocking() (FraudDecision, bool) {
+	for _, decision := range d {
+		if decision.Violated && decision.Action == FraudActionBlock {
+			return decision, true
+		}
+	}
+	return FraudDecision{}, false
+}
+
+// Warnings returns the names of the violated checks configured to warn
+func (d FraudDecisions) Warnings() []string {
+	var warnings []string
+	for _, decision := range d {
+		if decision.Violated && decision.Action == FraudActionWarn {
+			warnings = append(warnings, decision.Check)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,104 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// APIKeyPrefix marks the keys issued by the API, so leaked keys are easy to spot
+	APIKeyPrefix = "pnfce_"
+	// apiKeyDisplayLength is the number of leading characters kept to identify a key
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+)
+
+// APIKey authenticates a company on the /api/v1 routes. Only the SHA-256 of the key is
+// stored; the key itself is shown once, when issued.
+type APIKey struct {
+	ID         string     `json:"id"`
+	CompanyID  string     `json:"company_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // Leading characters of the key, to tell keys apart
+	KeyHash    string     `json:"-"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // Set when the key is rotated out
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// NewAPIKey issues a key for the company, returning the entity and the plain key
+func NewAPIKey(companyID, name string) (*APIKey, string, error) {
+	if companyID == "" {
+		return nil, "", errors.New("company ID é obrigatório")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "default"
+	}
+	if len(name) > 100 {
+		return nil, "", errors.New("nome da chave deve ter no máximo 100 caracteres")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	key := APIKeyPrefix + hex.EncodeToString(secret)
+
+	now := time.Now()
+	return &APIKey{
+		ID:        uuid.New().String(),
+		CompanyID: companyID,
+		Name:      name,
+		Prefix:    key[:apiKeyDisplayLength],
+		KeyHash:   HashAPIKey(key),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, key, nil
+}
+
+// HashAPIKey returns the hash the key is looked up by
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsActive reports whether the key still authenticates at the given time
+func (k *APIKey) IsActive(at time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || at.Before(*k.ExpiresAt)
+}
+
+// ExpireAfter keeps a rotated key valid for the grace period, so clients can switch to the new one
+func (k *APIKey) ExpireAfter(grace time.Duration) {
+	now := time.Now()
+	expiresAt := now.Add(grace)
+	if k.ExpiresAt == nil || expiresAt.Before(*k.ExpiresAt) {
+		k.ExpiresAt = &expiresAt
+	}
+	k.UpdatedAt = now
+}
+
+// Revoke disables the key immediately
+func (k *APIKey) Revoke() {
+	now := time.Now()
+	if k.RevokedAt == nil {
+		k.RevokedAt = &now
+	}
+	k.UpdatedAt = now
+}
+
+// TableName specifies the table name for GORM
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,205 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditActorType identifies who performed an audited action
+type AuditActorType string
+
+const (
+	AuditActorAdmin   AuditActorType = "admin"
+	AuditActorCompany AuditActorType = "company"
+	AuditActorSystem  AuditActorType = "system" // Background jobs of the worker
+)
+
+// Resource types recorded by the use cases on the audit entries
+const (
+	AuditResourceCompany      = "company"
+	AuditResourcePlan         = "plan"
+	AuditResourceSubscription = "subscription"
+	AuditResourceWebhook      = "webhook"
+	AuditResourceAPIKey       = "api_key"
+	AuditResourceNFCe         = "nfce"
+)
+
+// auditMasked replaces the values of sensitive fields in the audit changes
+const auditMasked = "***"
+
+// auditSensitiveFields are the JSON fields whose values never reach the audit log
+var auditSensitiveFields = []string{"password", "pfx", "token", "secret", "csrt", "key_hash", "private_key"}
+
+// AuditChange is the value of a field before and after an audited action
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditChanges maps the changed fields of a resource to their values
+type AuditChanges map[string]AuditChange
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (c *AuditChanges) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("AuditChanges.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, c)
+}
+
+// AuditSnapshot is the JSON representation of a resource at a point in time
+type AuditSnapshot map[string]any
+
+// NewAuditSnapshot captures the state of a resource, e.g. before it is modified
+func NewAuditSnapshot(resource any) AuditSnapshot {
+	if resource == nil {
+		return nil
+	}
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil
+	}
+	var snapshot AuditSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+// Diff returns the top-level fields that differ from the after snapshot; a nil before
+// records the creation and a nil after the removal of the resource
+func (s AuditSnapshot) Diff(after AuditSnapshot) AuditChanges {
+	changes := AuditChanges{}
+	for field, before := range s {
+		if value, ok := after[field]; !ok || !reflect.DeepEqual(before, value) {
+			changes[field] = auditChange(field, before, after[field])
+		}
+	}
+	for field, value := range after {
+		if _, ok := s[field]; !ok {
+			changes[field] = auditChange(field, nil, value)
+		}
+	}
+	return changes
+}
+
+// auditChange builds the change of a field, masking sensitive values
+func auditChange(field string, before, after any) AuditChange {
+	if auditSensitive(field) {
+		return AuditChange{Before: maskAuditValue(before), After: maskAuditValue(after)}
+	}
+	return AuditChange{Before: maskAuditSecrets(before), After: maskAuditSecrets(after)}
+}
+
+// auditSensitive reports whether the field holds a secret
+func auditSensitive(field string) bool {
+	name := strings.ToLower(field)
+	for _, sensitive := range auditSensitiveFields {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskAuditSecrets masks the sensitive fields nested in a value, e.g. the CSRT of resp_tec
+func maskAuditSecrets(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(v))
+		for field, nested := range v {
+			if auditSensitive(field) {
+				masked[field] = maskAuditValue(nested)
+			} else {
+				masked[field] = maskAuditSecrets(nested)
+			}
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(v))
+		for i, nested := range v {
+			masked[i] = maskAuditSecrets(nested)
+		}
+		return masked
+	}
+	return value
+}
+
+// maskAuditValue hides a sensitive value, keeping whether it was set
+func maskAuditValue(value any) any {
+	if value == nil || value == "" {
+		return value
+	}
+	return auditMasked
+}
+
+// AuditEntry records a write made through the API: who made it, on which resource, what
+// changed and the request it came from
+type AuditEntry struct {
+	ID           string         `json:"id" gorm:"primaryKey;type:uuid"`
+	ActorType    AuditActorType `json:"actor_type" gorm:"not null"`
+	ActorID      string         `json:"actor_id" gorm:"not null"`
+	CompanyID    *string        `json:"company_id,omitempty" gorm:"type:uuid"` // Company the action applies to, when known
+	Action       string         `json:"action" gorm:"not null"`                // Method and route, e.g. PUT /api/admin/plans/:id
+	ResourceType string         `json:"resource_type,omitempty"`
+	ResourceID   string         `json:"resource_id,omitempty"`
+	Changes      AuditChanges   `json:"changes,omitempty" gorm:"type:jsonb"`
+	Status       int            `json:"status"` // HTTP status of the response
+	IP           string         `json:"ip,omitempty"`
+	UserAgent    string         `json:"user_agent,omitempty"`
+	RequestID    string         `json:"request_id,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+
+	// Related are the further resources changed by the same action, recorded along with it
+	Related []*AuditEntry `json:"-" gorm:"-"`
+}
+
+// NewAuditEntry starts the audit entry of an action performed by the actor
+func NewAuditEntry(actorType AuditActorType, actorID, action string) *AuditEntry {
+	return &AuditEntry{
+		ID:        uuid.New().String(),
+		ActorType: actorType,
+		ActorID:   actorID,
+		Action:    action,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewRelatedEntry starts the audit entry of a further resource changed by the action, with
+// the same actor and request metadata
+func (e *AuditEntry) NewRelatedEntry(resourceType, resourceID string, changes AuditChanges) *AuditEntry {
+	related := NewAuditEntry(e.ActorType, e.ActorID, e.Action)
+	related.CompanyID = e.CompanyID
+	related.ResourceType = resourceType
+	related.ResourceID = resourceID
+	related.Changes = changes
+	related.IP = e.IP
+	related.UserAgent = e.UserAgent
+	related.RequestID = e.RequestID
+	return related
+}
+
+// TableName specifies the table name for GORM
+func (AuditEntry) TableName() string {
+	return "audit_logs"
+}
@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NCMCatalogEntry is a code of the Nomenclatura Comum do Mercosul (Siscomex table)
+type NCMCatalogEntry struct {
+	Codigo      string     `json:"codigo" gorm:"primaryKey"` // 8 digits, without dots
+	Descricao   string     `json:"descricao"`
+	VigenciaFim *time.Time `json:"vigencia_fim,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (NCMCatalogEntry) TableName() string {
+	return "catalog_ncm"
+}
+
+// ValidAt reports whether the NCM is still in force at t
+func (n NCMCatalogEntry) ValidAt(t time.Time) bool {
+	return n.VigenciaFim == nil || !t.After(*n.VigenciaFim)
+}
+
+// CESTCatalogEntry links a CEST (Convênio ICMS 142/2018) to an NCM or NCM prefix it covers
+type CESTCatalogEntry struct {
+	Codigo    string `json:"codigo" gorm:"primaryKey"` // 7 digits, without dots
+	NCM       string `json:"ncm" gorm:"primaryKey"`    // NCM or NCM prefix
+	Descricao string `json:"descricao"`
+}
+
+// TableName specifies the table name for GORM
+func (CESTCatalogEntry) TableName() string {
+	return "catalog_cest"
+}
+
+// Covers reports whether the CEST entry applies to the NCM
+func (c CESTCatalogEntry) Covers(ncm string) bool {
+	return len(ncm) >= len(c.NCM) && ncm[:len(c.NCM)] == c.NCM
+}
+
+// CFOPCatalogEntry is a Código Fiscal de Operações e Prestações
+type CFOPCatalogEntry struct {
+	Codigo    string `json:"codigo" gorm:"primaryKey"`
+	Descricao string `json:"descricao"`
+	NFCe      bool   `json:"nfce" gorm:"column:nfce"` // Allowed in the NFC-e (modelo 65)
+	ST        bool   `json:"st"`                      // Goods with ICMS retained by substituição tributária, requires CEST
+}
+
+// TableName specifies the table name for GORM
+func (CFOPCatalogEntry) TableName() string {
+	return "catalog_cfop"
+}
+
+// MunicipioCatalogEntry is a municipality of the IBGE table, the cMun of the NF-e addresses
+// and of the place of the taxable event (cMunFG)
+type MunicipioCatalogEntry struct {
+	Codigo string `json:"codigo" gorm:"primaryKey"` // 7 digits, the first two the cUF
+	Nome   string `json:"nome"`
+	UF     string `json:"uf"`
+	// NomeBusca is the name as compared by the lookups, see MunicipioSearchName
+	NomeBusca string `json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (MunicipioCatalogEntry) TableName() string {
+	return "catalog_municipios"
+}
+
+// MunicipioSearchName reduces a municipality name to upper case letters and digits without
+// accents, words separated by one space, so "Santa Bárbara d'Oeste" and
+// "SANTA BARBARA D OESTE" match
+func MunicipioSearchName(nome string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFD.String(nome) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue // Accent of the previous letter
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(unicode.ToUpper(r))
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// FieldError points to the request field that failed a validation
+type FieldError struct {
+	Field   string `json:"field"` // e.g. "itens[0].ncm"
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrChaveAcessoCollision is returned when every cNF drawn for an NFC-e gave a chave de
+// acesso already issued
+var ErrChaveAcessoCollision = errors.New("chave de acesso already issued")
+
+// cnfRange is the count of 8 digit cNF values, 00000001 to 99999999
+var cnfRange = big.NewInt(99999999)
+
+// NewCNF draws the Código Numérico (cNF) of the chave de acesso from crypto/rand. SEFAZ
+// rejects a cNF equal to the nNF of the document, so that value is drawn again.
+func NewCNF(nNF string) (string, error) {
+	numero := fmt.Sprintf("%08s", nNF)
+	for {
+		n, err := rand.Int(rand.Reader, cnfRange)
+		if err != nil {
+			return "", fmt.Errorf("failed to draw cNF: %w", err)
+		}
+		cNF := fmt.Sprintf("%08d", n.Int64()+1)
+		if cNF != numero {
+			return cNF, nil
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package entity
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,8 +19,12 @@ const (
 	CompanyStatusActive   CompanyStatus = "active"
 	CompanyStatusInactive CompanyStatus = "inactive"
 	CompanyStatusBlocked  CompanyStatus = "blocked"
+	CompanyStatusDeleted  CompanyStatus = "deleted" // Offboarded; the row stays for the fiscal and audit records
 )
 
+// ErrCompanyDeleted is returned when changing a company that was deleted
+var ErrCompanyDeleted = errors.New("company was deleted")
+
 // TaxRegime represents the tax regime of the company
 type TaxRegime string
 
@@ -30,24 +38,39 @@ const (
 type CertificateType string
 
 const (
-	CertificateTypeA1 CertificateType = "a1"
+	CertificateTypeA1  CertificateType = "a1"
+	CertificateTypeKMS CertificateType = "kms" // Key kept in an HSM or cloud KMS; only digests are sent to it
 )
 
 // Company represents an NFC-e issuing company
 type Company struct {
-	ID                string             `json:"id"`
-	CNPJ              string             `json:"cnpj"`
-	RazaoSocial       string             `json:"razao_social"`
-	NomeFantasia      string             `json:"nome_fantasia,omitempty"`
-	InscricaoEstadual string             `json:"inscricao_estadual,omitempty"`
-	Email             string             `json:"email"`
-	Endereco          Address            `json:"endereco"`
-	Certificado       DigitalCertificate `json:"certificado"`
-	CSC               CSCConfig          `json:"csc"`
-	RegimeTributario  TaxRegime          `json:"regime_tributario"`
-	Status            CompanyStatus      `json:"status"`
-	CreatedAt         time.Time          `json:"created_at"`
-	UpdatedAt         time.Time          `json:"updated_at"`
+	ID                string                   `json:"id"`
+	CNPJ              string                   `json:"cnpj"`
+	RazaoSocial       string                   `json:"razao_social"`
+	NomeFantasia      string                   `json:"nome_fantasia,omitempty"`
+	InscricaoEstadual string                   `json:"inscricao_estadual,omitempty"`
+	Email             string                   `json:"email"`
+	Endereco          Address                  `json:"endereco"`
+	Certificado       DigitalCertificate       `json:"certificado"`
+	CSC               CSCConfig                `json:"csc"`
+	CSCs              CSCEntries               `json:"cscs,omitempty" gorm:"column:cscs;type:jsonb;serializer:secret"`
+	DANFE             DANFEConfig              `json:"danfe"`
+	Intermediadores   Intermediadores          `json:"intermediadores,omitempty" gorm:"type:jsonb"`
+	FraudRules        FraudRules               `json:"fraud_rules,omitempty" gorm:"type:jsonb"`
+	TaxRules          TaxRules                 `json:"tax_rules,omitempty" gorm:"type:jsonb"`
+	RespTec           *RespTec                 `json:"resp_tec,omitempty" gorm:"type:jsonb"`
+	EmailTemplate     *EmailTemplate           `json:"email_template,omitempty" gorm:"type:jsonb"`
+	EmissionSettings  *CompanyEmissionSettings `json:"emission_settings,omitempty" gorm:"type:jsonb"`
+	RegimeTributario  TaxRegime                `json:"regime_tributario"`
+	Status            CompanyStatus            `json:"status"`
+	CreatedAt         time.Time                `json:"created_at"`
+	UpdatedAt         time.Time                `json:"updated_at"`
+
+	// LGPD offboarding: when the company was deleted and when its documents are (or were)
+	// deleted from storage
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+	StorageCleanupAt *time.Time `json:"storage_cleanup_at,omitempty"`
+	StorageCleanedAt *time.Time `json:"storage_cleaned_at,omitempty"`
 }
 
 // Address represents a company's address
@@ -65,20 +88,189 @@ type Address struct {
 // DigitalCertificate holds the company's digital certificate information
 type DigitalCertificate struct {
 	Type      CertificateType `json:"type"`
-	PFXData   []byte          `json:"pfx_data"` // Encrypted PFX data
-	Password  string          `json:"password"` // Certificate password
+	PFXData   []byte          `json:"pfx_data"`                          // Encrypted PFX data
+	Password  string          `json:"password" gorm:"serializer:secret"` // Certificate password, encrypted at rest
 	ExpiresAt time.Time       `json:"expires_at"`
 	Subject   string          `json:"subject,omitempty"` // Certificate subject
+	Issuer    string          `json:"issuer,omitempty"`  // Issuing AC
+	CNPJ      string          `json:"cnpj,omitempty"`    // CNPJ the certificate was issued to
+
+	// Remote key of the kms type; the PEM holds the public certificate of the key
+	KMSEndpoint    string `json:"kms_endpoint,omitempty"`
+	KMSKeyID       string `json:"kms_key_id,omitempty"`
+	KMSToken       string `json:"-" gorm:"serializer:secret"`
+	CertificatePEM []byte `json:"-"`
 }
 
-// CSCConfig holds CSC (Código de Segurança do Contribuinte) configuration
+// CSCConfig holds the single legacy CSC (Código de Segurança do Contribuinte), used when
+// CSCs has no entry in force for the environment
 type CSCConfig struct {
 	CSCID      string    `json:"csc_id"`
-	CSCToken   string    `json:"csc_token"`
+	CSCToken   string    `json:"csc_token" gorm:"serializer:secret"`
 	ValidFrom  time.Time `json:"valid_from"`
 	ValidUntil time.Time `json:"valid_until"`
 }
 
+// DANFE NFC-e thermal paper widths
+const (
+	DANFEPapel80mm = "80mm"
+	DANFEPapel58mm = "58mm"
+)
+
+// DANFEConfig holds the company's DANFE printing preferences
+type DANFEConfig struct {
+	DuasVias                bool   `json:"duas_vias"`                // Print consumer and establishment vias
+	CompactoEstabelecimento bool   `json:"compacto_estabelecimento"` // Suppress items on the establishment via
+	Papel                   string `json:"papel,omitempty"`          // Thermal paper width, DANFEPapel80mm when empty
+}
+
+// Validate checks the DANFE printing preferences
+func (c DANFEConfig) Validate() error {
+	if c.Papel != "" && c.Papel != DANFEPapel80mm && c.Papel != DANFEPapel58mm {
+		return errors.New("papel do DANFE deve ser 80mm ou 58mm")
+	}
+	return nil
+}
+
+// Intermediador is a marketplace or delivery app (iFood, Rappi, ...) registered by
+// the company to fill the infIntermed group of sales made through it
+type Intermediador struct {
+	Alias        string `json:"alias"` // Name used on the emit payload, e.g. "ifood"
+	CNPJ         string `json:"cnpj"`
+	Nome         string `json:"nome"`
+	IdCadIntTran string `json:"id_cad_int_tran,omitempty"` // Seller identifier at the intermediary
+	Padrao       bool   `json:"padrao,omitempty"`          // Used when the payload has no intermediador
+}
+
+// Intermediadores is the company registry of known intermediaries
+type Intermediadores []Intermediador
+
+// Validate checks the registry entries and the uniqueness of aliases and default
+func (r Intermediadores) Validate() error {
+	aliases := make(map[string]bool, len(r))
+	padrao := false
+	for _, intermediador := range r {
+		alias := strings.ToLower(strings.TrimSpace(intermediador.Alias))
+		if alias == "" {
+			return errors.New("alias do intermediador é obrigatório")
+		}
+		if aliases[alias] {
+			return fmt.Errorf("alias do intermediador %q duplicado", intermediador.Alias)
+		}
+		aliases[alias] = true
+
+		if err := validateCNPJ(intermediador.CNPJ); err != nil {
+			return fmt.Errorf("intermediador %q: %w", intermediador.Alias, err)
+		}
+		if strings.TrimSpace(intermediador.Nome) == "" {
+			return fmt.Errorf("intermediador %q: nome é obrigatório", intermediador.Alias)
+		}
+
+		if intermediador.Padrao {
+			if padrao {
+				return errors.New("apenas um intermediador pode ser o padrão")
+			}
+			padrao = true
+		}
+	}
+	return nil
+}
+
+// Find returns the intermediary registered under alias (case-insensitive)
+func (r Intermediadores) Find(alias string) (Intermediador, bool) {
+	for _, intermediador := range r {
+		if strings.EqualFold(intermediador.Alias, strings.TrimSpace(alias)) {
+			return intermediador, true
+		}
+	}
+	return Intermediador{}, false
+}
+
+// Default returns the intermediary flagged as padrão, if any
+func (r Intermediadores) Default() (Intermediador, bool) {
+	for _, intermediador := range r {
+		if intermediador.Padrao {
+			return intermediador, true
+		}
+	}
+	return Intermediador{}, false
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (r Intermediadores) Value() (driver.Value, error) {
+	if r == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (r *Intermediadores) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("Intermediadores.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
+
+// RespTec is the technical responsible (infRespTec) of the company's NFC-e, overriding the
+// one configured for the installation
+type RespTec struct {
+	CNPJ    string `json:"cnpj"`
+	Contato string `json:"contato"`
+	Email   string `json:"email"`
+	Fone    string `json:"fone"`
+	IdCSRT  string `json:"id_csrt,omitempty"` // CSRT identifier issued by the UF, e.g. "01"
+	CSRT    string `json:"csrt,omitempty"`    // Código de Segurança do Responsável Técnico
+}
+
+// Validate checks the technical responsible contact and CSRT pair
+func (r *RespTec) Validate() error {
+	if err := validateCNPJ(r.CNPJ); err != nil {
+		return fmt.Errorf("responsável técnico: %w", err)
+	}
+	if strings.TrimSpace(r.Contato) == "" {
+		return errors.New("contato do responsável técnico é obrigatório")
+	}
+	if !strings.Contains(r.Email, "@") {
+		return errors.New("email do responsável técnico inválido")
+	}
+	if fone := regexp.MustCompile(`[^\d]`).ReplaceAllString(r.Fone, ""); len(fone) < 6 || len(fone) > 14 {
+		return errors.New("telefone do responsável técnico deve ter de 6 a 14 dígitos")
+	}
+	if (r.IdCSRT == "") != (r.CSRT == "") {
+		return errors.New("id_csrt e csrt devem ser informados juntos")
+	}
+	if r.IdCSRT != "" && !regexp.MustCompile(`^\d{2}$`).MatchString(r.IdCSRT) {
+		return errors.New("id_csrt deve ter 2 dígitos")
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (r RespTec) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (r *RespTec) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("RespTec.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
+
 // NewCompany creates a new company with validation
 func NewCompany(cnpj, razaoSocial string) (*Company, error) {
 	if err := validateCNPJ(cnpj); err != nil {
@@ -100,8 +292,26 @@ func NewCompany(cnpj, razaoSocial string) (*Company, error) {
 	}, nil
 }
 
-// UpdateCertificate updates the company's digital certificate
-func (c *Company) UpdateCertificate(certType CertificateType, pfxData []byte, password string, expiresAt time.Time) error {
+var (
+	// ErrCertificateExpired is returned when uploading a certificate past its validity
+	ErrCertificateExpired = errors.New("certificate has expired")
+	// ErrCertificateCNPJMismatch is returned when the certificate was not issued to the CNPJ root
+	// of the company
+	ErrCertificateCNPJMismatch = errors.New("certificate was not issued to the company CNPJ")
+)
+
+// CertificateIdentity is what the uploaded certificate tells about its holder
+type CertificateIdentity struct {
+	Subject   string
+	Issuer    string
+	CNPJ      string // Empty when the certificate carries no CNPJ, e.g. an e-CPF
+	ExpiresAt time.Time
+}
+
+// UpdateCertificate updates the company's digital certificate. pfxData is the stored form
+// of the PFX; the certificate must be in force and issued to the CNPJ root of the company,
+// since SEFAZ accepts the certificate of any establishment of the same root.
+func (c *Company) UpdateCertificate(certType CertificateType, pfxData []byte, password string, identity CertificateIdentity) error {
 	if len(pfxData) == 0 {
 		return errors.New("dados do certificado são obrigatórios")
 	}
@@ -110,15 +320,53 @@ func (c *Company) UpdateCertificate(certType CertificateType, pfxData []byte, pa
 		return errors.New("senha do certificado é obrigatória")
 	}
 
-	if expiresAt.Before(time.Now()) {
-		return errors.New("certificado já expirou")
+	if identity.ExpiresAt.Before(time.Now()) {
+		return fmt.Errorf("%w: %s", ErrCertificateExpired, identity.ExpiresAt.Format(time.RFC3339))
+	}
+
+	companyCNPJ := regexp.MustCompile(`[^\d]`).ReplaceAllString(c.CNPJ, "")
+	switch {
+	case identity.CNPJ == "":
+		return fmt.Errorf("%w: the certificate carries no CNPJ", ErrCertificateCNPJMismatch)
+	case len(companyCNPJ) != 14 || identity.CNPJ[:8] != companyCNPJ[:8]:
+		return fmt.Errorf("%w: issued to %s", ErrCertificateCNPJMismatch, identity.CNPJ)
 	}
 
 	c.Certificado = DigitalCertificate{
 		Type:      certType,
 		PFXData:   pfxData,
 		Password:  password,
-		ExpiresAt: expiresAt,
+		ExpiresAt: identity.ExpiresAt,
+		Subject:   identity.Subject,
+		Issuer:    identity.Issuer,
+		CNPJ:      identity.CNPJ,
+	}
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// UseKMSSigner makes the company sign with a remote key instead of the uploaded PFX
+func (c *Company) UseKMSSigner(endpoint, keyID, token string, certificatePEM []byte, subject string, expiresAt time.Time) error {
+	if endpoint == "" || keyID == "" {
+		return errors.New("endpoint e key_id do KMS são obrigatórios")
+	}
+
+	if len(certificatePEM) == 0 {
+		return errors.New("certificado público da chave é obrigatório")
+	}
+
+	if expiresAt.Before(time.Now()) {
+		return errors.New("certificado já expirou")
+	}
+
+	c.Certificado = DigitalCertificate{
+		Type:           CertificateTypeKMS,
+		ExpiresAt:      expiresAt,
+		Subject:        subject,
+		KMSEndpoint:    endpoint,
+		KMSKeyID:       keyID,
+		KMSToken:       token,
+		CertificatePEM: certificatePEM,
 	}
 	c.UpdatedAt = time.Now()
 	return nil
@@ -153,6 +401,110 @@ func (c *Company) IsActive() bool {
 	return c.Status == CompanyStatusActive
 }
 
+// IsDeleted returns true if the company was offboarded
+func (c *Company) IsDeleted() bool {
+	return c.Status == CompanyStatusDeleted
+}
+
+// Offboard soft deletes the company: it stops authenticating and emitting, loses its
+// certificate and CSC secrets and has its documents deleted from storage at cleanupAt.
+// The registry data, certificate metadata and CSC IDs are kept for the fiscal records.
+func (c *Company) Offboard(now, cleanupAt time.Time) error {
+	if c.IsDeleted() {
+		return ErrCompanyDeleted
+	}
+
+	c.Status = CompanyStatusDeleted
+	c.DeletedAt = &now
+	c.StorageCleanupAt = &cleanupAt
+
+	c.Certificado.PFXData = nil
+	c.Certificado.Password = ""
+	c.Certificado.KMSEndpoint = ""
+	c.Certificado.KMSKeyID = ""
+	c.Certificado.KMSToken = ""
+	c.Certificado.CertificatePEM = nil
+	c.CSC.CSCToken = ""
+	for i := range c.CSCs {
+		c.CSCs[i].CSCToken = ""
+	}
+
+	c.UpdatedAt = now
+	return nil
+}
+
+// MissingEmitenteFields returns the registry fields required on the NFC-e emitente that are not filled
+func (c *Company) MissingEmitenteFields() []string {
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"cnpj", c.CNPJ},
+		{"razao_social", c.RazaoSocial},
+		{"inscricao_estadual", c.InscricaoEstadual},
+		{"regime_tributario", string(c.RegimeTributario)},
+		{"endereco.logradouro", c.Endereco.Logradouro},
+		{"endereco.numero", c.Endereco.Numero},
+		{"endereco.bairro", c.Endereco.Bairro},
+		{"endereco.codigo_municipio", c.Endereco.CodigoMunicipio},
+		{"endereco.municipio", c.Endereco.Municipio},
+		{"endereco.uf", c.Endereco.UF},
+		{"endereco.cep", c.Endereco.CEP},
+	}
+
+	var missing []string
+	for _, field := range required {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	return missing
+}
+
+// ResolveIntermediador returns the infIntermed data of a sale: the registry entry
+// selected by alias (explicit payload fields override it), the explicit payload data,
+// or the company default when the payload has none. Returns nil for direct sales.
+func (c *Company) ResolveIntermediador(ref *IntermediadorRef) (*Intermediador, error) {
+	if ref == nil {
+		if intermediador, ok := c.Intermediadores.Default(); ok {
+			return &intermediador, nil
+		}
+		return nil, nil
+	}
+
+	var intermediador Intermediador
+	if ref.Alias != "" {
+		registered, ok := c.Intermediadores.Find(ref.Alias)
+		if !ok {
+			return nil, fmt.Errorf("intermediador %q não cadastrado na empresa", ref.Alias)
+		}
+		intermediador = registered
+	}
+
+	if ref.CNPJ != "" {
+		intermediador.CNPJ = ref.CNPJ
+	}
+	if ref.Nome != "" {
+		intermediador.Nome = ref.Nome
+	}
+	if ref.IdCadIntTran != "" {
+		intermediador.IdCadIntTran = ref.IdCadIntTran
+	}
+
+	if err := validateCNPJ(intermediador.CNPJ); err != nil {
+		return nil, fmt.Errorf("intermediador: %w", err)
+	}
+	return &intermediador, nil
+}
+
+// CRT returns the NFC-e Código de Regime Tributário of the company
+func (c *Company) CRT() string {
+	if c.RegimeTributario == TaxRegimeSimplesNacional {
+		return "1" // Simples Nacional
+	}
+	return "3" // Regime Normal
+}
+
 // IsCertificateValid returns true if the certificate is still valid
 func (c *Company) IsCertificateValid() bool {
 	return c.Certificado.ExpiresAt.After(time.Now())
@@ -183,6 +535,32 @@ func validateCNPJ(cnpj string) error {
 	return nil
 }
 
+// validateCPF validates the length and check digits of a consumer CPF
+func validateCPF(cpf string) error {
+	re := regexp.MustCompile(`[^\d]`)
+	cleanCPF := re.ReplaceAllString(cpf, "")
+
+	if len(cleanCPF) != 11 {
+		return errors.New("CPF deve ter 11 dígitos")
+	}
+	if strings.Count(cleanCPF, cleanCPF[:1]) == 11 {
+		return errors.New("CPF inválido")
+	}
+
+	for length := 9; length <= 10; length++ {
+		total := 0
+		for i := 0; i < length; i++ {
+			total += int(cleanCPF[i]-'0') * (length + 1 - i)
+		}
+		digit := total * 10 % 11 % 10
+		if int(cleanCPF[length]-'0') != digit {
+			return errors.New("CPF inválido")
+		}
+	}
+
+	return nil
+}
+
 // generateID generates a unique UUID for the company
 func generateID() string {
 	return uuid.New().String()
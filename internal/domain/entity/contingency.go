@@ -0,0 +1,90 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// contingencyReasonMaxLength is the largest xJust accepted by the NF-e layout
+const contingencyReasonMaxLength = 256
+
+// ContingencyState is a period in which a company emits in contingency in a UF and
+// ambiente. While it is active, new NFC-e skip the regular authorizer and carry its
+// entry time (dhCont) and reason (xJust); it ends when the status service reports the
+// authorizer in operation again.
+type ContingencyState struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:uuid"`
+	CompanyID string     `json:"company_id" gorm:"type:uuid;not null"`
+	UF        string     `json:"uf" gorm:"column:uf"`
+	Ambiente  string     `json:"ambiente"`
+	Type      string     `json:"type"`   // SVC-AN, SVC-RS or ContingencyTypeOffline
+	Reason    string     `json:"reason"` // xJust
+	CStat     string     `json:"cstat,omitempty" gorm:"column:cstat"`
+	EnteredAt time.Time  `json:"entered_at"` // dhCont
+	ProbedAt  *time.Time `json:"probed_at,omitempty"`
+	ExitedAt  *time.Time `json:"exited_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (ContingencyState) TableName() string {
+	return "contingency_states"
+}
+
+// NewContingencyState enters contingency now for the company in the UF and ambiente
+func NewContingencyState(companyID, uf, ambiente, contingencyType, cstat, motivo string) *ContingencyState {
+	now := time.Now()
+	return &ContingencyState{
+		ID:        uuid.New().String(),
+		CompanyID: companyID,
+		UF:        uf,
+		Ambiente:  ambiente,
+		Type:      contingencyType,
+		Reason:    contingencyReason(cstat, motivo),
+		CStat:     cstat,
+		EnteredAt: now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// contingencyReason builds the xJust from the SEFAZ answer that caused the contingency
+func contingencyReason(cstat, motivo string) string {
+	reason := "SEFAZ autorizadora indisponivel para autorizacao em tempo real"
+	switch {
+	case cstat != "" && motivo != "":
+		reason = fmt.Sprintf("%s (cStat %s: %s)", reason, cstat, motivo)
+	case cstat != "":
+		reason = fmt.Sprintf("%s (%s)", reason, cstat)
+	}
+
+	for utf8.RuneCountInString(reason) > contingencyReasonMaxLength {
+		_, size := utf8.DecodeLastRuneInString(reason)
+		reason = reason[:len(reason)-size]
+	}
+	return reason
+}
+
+// IsActive reports whether the contingency has not ended yet
+func (c *ContingencyState) IsActive() bool {
+	return c.ExitedAt == nil
+}
+
+// RecordProbe records a status service query that still found the authorizer down
+func (c *ContingencyState) RecordProbe() {
+	now := time.Now()
+	c.ProbedAt = &now
+	c.UpdatedAt = now
+}
+
+// Exit ends the contingency after the authorizer was reported in operation
+func (c *ContingencyState) Exit() {
+	now := time.Now()
+	c.ProbedAt = &now
+	c.ExitedAt = &now
+	c.UpdatedAt = now
+}
@@ -0,0 +1,202 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrCSCNotFound is returned when the company has no CSC with the given ID
+var ErrCSCNotFound = errors.New("CSC not found")
+
+// cscIDPattern matches the cIdToken, the identifier of the CSC issued by the UF
+var cscIDPattern = regexp.MustCompile(`^\d{1,6}$`)
+
+// CSCEntry is a CSC issued by the SEFAZ for one environment. Companies keep several to
+// rotate them: the entry is used from ValidFrom until ValidUntil.
+type CSCEntry struct {
+	ID         string     `json:"id"`
+	Ambiente   string     `json:"ambiente"` // producao | homologacao
+	CSCID      string     `json:"csc_id"`   // cIdToken, e.g. "000001"
+	CSCToken   string     `json:"csc_token"`
+	ValidFrom  time.Time  `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"` // No end when nil
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewCSCEntry creates a CSC entry valid from validFrom (now when zero)
+func NewCSCEntry(ambiente, cscID, cscToken string, validFrom time.Time, validUntil *time.Time) (*CSCEntry, error) {
+	now := time.Now()
+	if validFrom.IsZero() {
+		validFrom = now
+	}
+
+	entry := &CSCEntry{
+		ID:         uuid.New().String(),
+		Ambiente:   ambiente,
+		CSCID:      cscID,
+		CSCToken:   cscToken,
+		ValidFrom:  validFrom,
+		ValidUntil: validUntil,
+		CreatedAt:  now,
+	}
+	if err := entry.Validate(); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Validate checks the environment, the identifier and the activation window of the entry;
+// the token format is checked by NFCeDomainService.ValidateCSC
+func (e CSCEntry) Validate() error {
+	if e.Ambiente != "producao" && e.Ambiente != "homologacao" {
+		return errors.New("ambiente do CSC deve ser producao ou homologacao")
+	}
+	if !cscIDPattern.MatchString(e.CSCID) {
+		return errors.New("ID do CSC deve ter de 1 a 6 dígitos")
+	}
+	if e.CSCToken == "" {
+		return errors.New("CSC Token é obrigatório")
+	}
+	if e.ValidUntil != nil && !e.ValidUntil.After(e.ValidFrom) {
+		return errors.New("fim da vigência do CSC deve ser posterior ao início")
+	}
+	return nil
+}
+
+// ActiveAt reports whether the entry is in force at t
+func (e CSCEntry) ActiveAt(t time.Time) bool {
+	if t.Before(e.ValidFrom) {
+		return false
+	}
+	return e.ValidUntil == nil || t.Before(*e.ValidUntil)
+}
+
+// CSCEntries is the company registry of CSCs per environment
+type CSCEntries []CSCEntry
+
+// Validate checks the entries and refuses the same identifier twice in an environment
+func (r CSCEntries) Validate() error {
+	ids := make(map[string]bool, len(r))
+	for _, entry := range r {
+		if err := entry.Validate(); err != nil {
+			return err
+		}
+		key := entry.Ambiente + "/" + entry.CSCID
+		if ids[key] {
+			return fmt.Errorf("CSC %s já cadastrado em %s", entry.CSCID, entry.Ambiente)
+		}
+		ids[key] = true
+	}
+	return nil
+}
+
+// Find returns the index of the entry with id, or -1
+func (r CSCEntries) Find(id string) int {
+	for i, entry := range r {
+		if entry.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Active returns the CSC of the environment in force at t. When windows overlap during a
+// rotation the most recent one wins.
+func (r CSCEntries) Active(ambiente string, t time.Time) (CSCEntry, bool) {
+	var active CSCEntry
+	found := false
+	for _, entry := range r {
+		if entry.Ambiente != ambiente || !entry.ActiveAt(t) {
+			continue
+		}
+		if !found || !entry.ValidFrom.Before(active.ValidFrom) {
+			active = entry
+			found = true
+		}
+	}
+	return active, found
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (r CSCEntries) Value() (driver.Value, error) {
+	if r == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (r *CSCEntries) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("CSCEntries.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
+
+// AddCSC registers a CSC entry on the company
+func (c *Company) AddCSC(entry CSCEntry) error {
+	cscs := append(append(CSCEntries{}, c.CSCs...), entry)
+	if err := cscs.Validate(); err != nil {
+		return err
+	}
+
+	c.CSCs = cscs
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// ReplaceCSC replaces the CSC entry with id, keeping its ID and creation time
+func (c *Company) ReplaceCSC(id string, entry CSCEntry) error {
+	i := c.CSCs.Find(id)
+	if i < 0 {
+		return ErrCSCNotFound
+	}
+
+	cscs := append(CSCEntries{}, c.CSCs...)
+	entry.ID = id
+	entry.CreatedAt = cscs[i].CreatedAt
+	cscs[i] = entry
+	if err := cscs.Validate(); err != nil {
+		return err
+	}
+
+	c.CSCs = cscs
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// RemoveCSC removes the CSC entry with id
+func (c *Company) RemoveCSC(id string) error {
+	i := c.CSCs.Find(id)
+	if i < 0 {
+		return ErrCSCNotFound
+	}
+
+	c.CSCs = append(append(CSCEntries{}, c.CSCs[:i]...), c.CSCs[i+1:]...)
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// ResolveCSC returns the CSC that signs the QR Code of an NFC-e emitted at t in the
+// environment: the registry entry in force, or the single legacy CSC while it is valid
+func (c *Company) ResolveCSC(ambiente string, t time.Time) (cscID, cscToken string, ok bool) {
+	if entry, found := c.CSCs.Active(ambiente, t); found {
+		return entry.CSCID, entry.CSCToken, true
+	}
+	if c.CSC.CSCID != "" && c.CSC.CSCToken != "" && c.CSC.ValidUntil.After(t) {
+		return c.CSC.CSCID, c.CSC.CSCToken, true
+	}
+	return "", "", false
+}
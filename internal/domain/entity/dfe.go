@@ -0,0 +1,143 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DFeSyncWait is how long SEFAZ requires before querying again after having no new documents (cStat 137)
+// or after consuming the service too often (cStat 656)
+const DFeSyncWait = time.Hour
+
+// ErrDFeSyncTooSoon is returned when a distribution sync is requested before SEFAZ allows it
+var ErrDFeSyncTooSoon = errors.New("dfe sync too soon")
+
+// DFeDocumentType classifies a document received from NFeDistribuicaoDFe
+type DFeDocumentType string
+
+const (
+	DFeDocumentResumoNFe    DFeDocumentType = "resumo_nfe"    // resNFe - summary of an NF-e issued against the CNPJ
+	DFeDocumentNFe          DFeDocumentType = "nfe"           // procNFe - full authorized document
+	DFeDocumentResumoEvento DFeDocumentType = "resumo_evento" // resEvento - summary of an event
+	DFeDocumentEvento       DFeDocumentType = "evento"        // procEventoNFe - full event
+	DFeDocumentOutro        DFeDocumentType = "outro"
+)
+
+// DFeDocumentTypeFromSchema derives the document type from the docZip schema attribute
+func DFeDocumentTypeFromSchema(schema string) DFeDocumentType {
+	switch {
+	case strings.HasPrefix(schema, "resNFe"):
+		return DFeDocumentResumoNFe
+	case strings.HasPrefix(schema, "procNFe"):
+		return DFeDocumentNFe
+	case strings.HasPrefix(schema, "resEvento"):
+		return DFeDocumentResumoEvento
+	case strings.HasPrefix(schema, "procEventoNFe"):
+		return DFeDocumentEvento
+	default:
+		return DFeDocumentOutro
+	}
+}
+
+// DFeDocument is a document or event distributed to the company by the Ambiente Nacional
+type DFeDocument struct {
+	ID           string          `json:"id"`
+	CompanyID    string          `json:"company_id"`
+	NSU          string          `json:"nsu"`
+	Schema       string          `json:"schema"`
+	Tipo         DFeDocumentType `json:"tipo"`
+	ChaveAcesso  string          `json:"chave_acesso,omitempty"`
+	CNPJEmitente string          `json:"cnpj_emitente,omitempty"`
+	XMLKey       string          `json:"xml_key"` // Storage key of the decompressed XML
+	XMLURL       string          `json:"xml_url,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// NewDFeDocument creates a distributed document of the company
+func NewDFeDocument(companyID, nsu, schema string) *DFeDocument {
+	return &DFeDocument{
+		ID:        uuid.New().String(),
+		CompanyID: companyID,
+		NSU:       nsu,
+		Schema:    schema,
+		Tipo:      DFeDocumentTypeFromSchema(schema),
+		CreatedAt: time.Now(),
+	}
+}
+
+// TableName specifies the table name for GORM
+func (DFeDocument) TableName() string {
+	return "dfe_documents"
+}
+
+// DFeCursor tracks the last NSU consumed from NFeDistribuicaoDFe by a company
+type DFeCursor struct {
+	CompanyID  string     `json:"company_id" gorm:"primaryKey"`
+	UltNSU     string     `json:"ult_nsu"`
+	MaxNSU     string     `json:"max_nsu"`
+	CStat      string     `json:"cstat,omitempty"`
+	Motivo     string     `json:"motivo,omitempty"`
+	LastSyncAt *time.Time `json:"last_sync_at,omitempty"`
+	NextSyncAt time.Time  `json:"next_sync_at"` // SEFAZ must not be queried again before it
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// NewDFeCursor creates a cursor starting at the first NSU, due immediately
+func NewDFeCursor(companyID string) *DFeCursor {
+	now := time.Now()
+	return &DFeCursor{
+		CompanyID:  companyID,
+		UltNSU:     "0",
+		MaxNSU:     "0",
+		NextSyncAt: now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// RequestSync makes the cursor due now, unless SEFAZ still requires the company to wait
+func (c *DFeCursor) RequestSync() error {
+	now := time.Now()
+	if c.NextSyncAt.After(now) && c.LastSyncAt != nil {
+		return ErrDFeSyncTooSoon
+	}
+	c.NextSyncAt = now
+	c.UpdatedAt = now
+	return nil
+}
+
+// Advance records a distribution reply. The cursor stays due while documents remain
+// (cStat 138 with ultNSU < maxNSU) and waits DFeSyncWait otherwise.
+func (c *DFeCursor) Advance(ultNSU, maxNSU, cstat, motivo string) {
+	now := time.Now()
+	if ultNSU != "" {
+		c.UltNSU = ultNSU
+	}
+	if maxNSU != "" {
+		c.MaxNSU = maxNSU
+	}
+	c.CStat = cstat
+	c.Motivo = motivo
+	c.LastSyncAt = &now
+	c.UpdatedAt = now
+
+	if cstat == "138" && !c.CaughtUp() {
+		c.NextSyncAt = now
+		return
+	}
+	c.NextSyncAt = now.Add(DFeSyncWait)
+}
+
+// CaughtUp reports whether every NSU available at SEFAZ was consumed
+func (c *DFeCursor) CaughtUp() bool {
+	return strings.TrimLeft(c.UltNSU, "0") == strings.TrimLeft(c.MaxNSU, "0")
+}
+
+// TableName specifies the table name for GORM
+func (DFeCursor) TableName() string {
+	return "dfe_cursors"
+}
@@ -0,0 +1,112 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailTrigger identifies what sent the NFC-e documents to the consumer
+type EmailTrigger string
+
+const (
+	EmailTriggerAuthorization EmailTrigger = "authorization" // Sent by the worker once the NFC-e is authorized
+	EmailTriggerResend        EmailTrigger = "resend"        // Requested through the API
+)
+
+// EmailTemplate is the company's message sending the XML and the DANFE to the consumer.
+// Subject and bodies are Go templates; empty fields use the default message.
+type EmailTemplate struct {
+	Disabled bool   `json:"disabled,omitempty"` // Do not e-mail the consumer after authorization
+	ReplyTo  string `json:"reply_to,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	HTML     string `json:"html,omitempty"` // html/template, escaped
+	Text     string `json:"text,omitempty"` // text/template, plain text alternative
+}
+
+// Validate checks the reply-to address and that the templates parse
+func (t *EmailTemplate) Validate() error {
+	if t.ReplyTo != "" && !strings.Contains(t.ReplyTo, "@") {
+		return errors.New("email de resposta inválido")
+	}
+	if _, err := template.New("subject").Parse(t.Subject); err != nil {
+		return fmt.Errorf("assunto do email inválido: %w", err)
+	}
+	if _, err := htmltemplate.New("html").Parse(t.HTML); err != nil {
+		return fmt.Errorf("corpo HTML do email inválido: %w", err)
+	}
+	if _, err := template.New("text").Parse(t.Text); err != nil {
+		return fmt.Errorf("corpo texto do email inválido: %w", err)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (t EmailTemplate) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (t *EmailTemplate) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("EmailTemplate.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, t)
+}
+
+// EmailDelivery records an attempt to e-mail the XML and the DANFE of an NFC-e
+type EmailDelivery struct {
+	ID           string       `json:"id"`
+	CompanyID    string       `json:"company_id"`
+	RequestID    string       `json:"request_id"`
+	Recipient    string       `json:"recipient"`
+	Subject      string       `json:"subject"`
+	Trigger      EmailTrigger `json:"trigger"`
+	Provider     string       `json:"provider"`             // Sender that handled the message: smtp | ses
+	MessageID    string       `json:"message_id,omitempty"` // Identifier returned by the provider
+	ErrorMessage string       `json:"error_message,omitempty"`
+	Succeeded    bool         `json:"succeeded"`
+	DeliveredAt  *time.Time   `json:"delivered_at,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// NewEmailDelivery records the outcome of e-mailing the documents of an NFC-e to recipient
+func NewEmailDelivery(nfceRequest *NFCE, recipient, subject string, trigger EmailTrigger, provider, messageID string, sendErr error) *EmailDelivery {
+	now := time.Now()
+	delivery := &EmailDelivery{
+		ID:        uuid.New().String(),
+		CompanyID: nfceRequest.CompanyID,
+		RequestID: nfceRequest.ID,
+		Recipient: recipient,
+		Subject:   subject,
+		Trigger:   trigger,
+		Provider:  provider,
+		MessageID: messageID,
+		Succeeded: sendErr == nil,
+		CreatedAt: now,
+	}
+	if sendErr != nil {
+		delivery.ErrorMessage = sendErr.Error()
+	} else {
+		delivery.DeliveredAt = &now
+	}
+	return delivery
+}
+
+// TableName specifies the table name for GORM
+func (EmailDelivery) TableName() string {
+	return "nfce_email_deliveries"
+}
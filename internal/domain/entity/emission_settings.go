@@ -0,0 +1,117 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/validation"
+)
+
+// DANFE print types (tpImp) of the NFC-e; the others (0 to 3) are those of the NF-e
+const (
+	TpImpDANFENFCe         = "4"
+	TpImpDANFENFCeMensagem = "5" // DANFE NFC-e sent in an electronic message only
+)
+
+// CompanyEmissionSettings are the ide defaults of the company documents, used when the
+// emission payload does not set them
+type CompanyEmissionSettings struct {
+	NaturezaOperacao string `json:"natureza_operacao,omitempty"` // natOp, VENDA when empty
+	// Serie is used when the emission chooses none, before the default numbering series
+	Serie *int `json:"serie,omitempty"`
+	// TpImp is the DANFE print type, applied to the documents of its model: 4 or 5 to the
+	// NFC-e, 0 to 3 to the NF-e
+	TpImp string `json:"tp_imp,omitempty"`
+	// CFOPPorUF is the CFOP of the items sent without one, by destination UF
+	CFOPPorUF map[string]string `json:"cfop_por_uf,omitempty"`
+	VerProc   string            `json:"ver_proc,omitempty"` // Version of the issuing application, 1.0.0 when empty
+}
+
+// Validate checks the defaults against the limits of the NF-e layout
+func (s *CompanyEmissionSettings) Validate() error {
+	if len([]rune(s.NaturezaOperacao)) > 60 {
+		return errors.New("natureza_operacao deve ter até 60 caracteres")
+	}
+	if s.Serie != nil && (*s.Serie < 0 || *s.Serie > 999) {
+		return errors.New("série deve estar entre 0 e 999")
+	}
+	if s.TpImp != "" && (len(s.TpImp) != 1 || s.TpImp[0] < '0' || s.TpImp[0] > '5') {
+		return errors.New("tp_imp deve ser de 0 a 5")
+	}
+	ufs := make([]string, 0, len(s.CFOPPorUF))
+	for uf := range s.CFOPPorUF {
+		ufs = append(ufs, uf)
+	}
+	sort.Strings(ufs)
+	for _, uf := range ufs {
+		if !validation.UF(uf) {
+			return fmt.Errorf("cfop_por_uf: UF %s inválida", uf)
+		}
+		if !validation.CFOP(s.CFOPPorUF[uf]) {
+			return fmt.Errorf("cfop_por_uf: CFOP %s da UF %s inválido", s.CFOPPorUF[uf], uf)
+		}
+	}
+	if len([]rune(s.VerProc)) > 20 {
+		return errors.New("ver_proc deve ter até 20 caracteres")
+	}
+	return nil
+}
+
+// TpImpFor returns the configured DANFE print type when it belongs to the model, or empty
+func (s *CompanyEmissionSettings) TpImpFor(modelo string) string {
+	if s == nil || s.TpImp == "" {
+		return ""
+	}
+	nfce := s.TpImp == TpImpDANFENFCe || s.TpImp == TpImpDANFENFCeMensagem
+	if nfce == (modelo == ModeloNFCe) {
+		return s.TpImp
+	}
+	return ""
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (s CompanyEmissionSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (s *CompanyEmissionSettings) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("CompanyEmissionSettings.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, s)
+}
+
+// DestinationUF returns the UF the goods go to: the recipient address of the NF-e, the
+// issuing UF otherwise
+func (p EmitPayload) DestinationUF() string {
+	if p.IsNFe() && p.Destinatario != nil && p.Destinatario.Endereco != nil && p.Destinatario.Endereco.UF != "" {
+		return p.Destinatario.Endereco.UF
+	}
+	return p.UF
+}
+
+// ApplyDefaultCFOP fills the CFOP of the items sent without one with the company default
+// for the destination UF
+func (p *EmitPayload) ApplyDefaultCFOP(settings *CompanyEmissionSettings) error {
+	uf := p.DestinationUF()
+	for i := range p.Itens {
+		if p.Itens[i].CFOP != "" {
+			continue
+		}
+		if settings == nil || settings.CFOPPorUF[uf] == "" {
+			return fmt.Errorf("item %d: cfop é obrigatório, a empresa não tem CFOP padrão para a UF %s", i+1, uf)
+		}
+		p.Itens[i].CFOP = settings.CFOPPorUF[uf]
+	}
+	return nil
+}
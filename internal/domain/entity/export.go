@@ -0,0 +1,188 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportStatus represents the lifecycle of a bulk export job
+type ExportStatus string
+
+const (
+	ExportStatusPending    ExportStatus = "pending"
+	ExportStatusProcessing ExportStatus = "processing"
+	ExportStatusCompleted  ExportStatus = "completed"
+	ExportStatusFailed     ExportStatus = "failed"
+	ExportStatusExpired    ExportStatus = "expired"
+)
+
+// ExportPart represents a chunk of the export written to storage
+type ExportPart struct {
+	Number    int       `json:"number"`
+	Key       string    `json:"key"`   // Storage key of the part
+	Size      int64     `json:"size"`  // Size in bytes
+	Items     int       `json:"items"` // Number of NFC-e in the part
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportParts is the list of parts stored as JSONB
+type ExportParts []ExportPart
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (p ExportParts) Value() (driver.Value, error) {
+	if p == nil {
+		return json.Marshal([]ExportPart{})
+	}
+	return json.Marshal(p)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (p *ExportParts) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("ExportParts.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, p)
+}
+
+// ExportJob represents an asynchronous bulk export of NFC-e XMLs
+type ExportJob struct {
+	ID          string       `json:"id"`
+	CompanyID   string       `json:"company_id"`
+	Status      ExportStatus `json:"status"`
+	PeriodStart time.Time    `json:"period_start"`
+	PeriodEnd   time.Time    `json:"period_end"`
+	// IncludeIndex adds an index.csv listing the NFC-e of each part
+	IncludeIndex bool `json:"include_index"`
+
+	// Progress
+	TotalItems     int         `json:"total_items"`
+	ProcessedItems int         `json:"processed_items"`
+	Parts          ExportParts `json:"parts" gorm:"type:jsonb"`
+
+	Attempts     int        `json:"attempts"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// NewExportJob creates a new export job for the given period
+func NewExportJob(companyID string, periodStart, periodEnd time.Time, ttl time.Duration) (*ExportJob, error) {
+	if companyID == "" {
+		return nil, errors.New("company ID é obrigatório")
+	}
+
+	if err := ValidateExportPeriod(periodStart, periodEnd); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &ExportJob{
+		ID:          uuid.New().String(),
+		CompanyID:   companyID,
+		Status:      ExportStatusPending,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Parts:       ExportParts{},
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// ValidateExportPeriod checks the period of an export, whether run as a job or streamed
+func ValidateExportPeriod(periodStart, periodEnd time.Time) error {
+	if periodStart.IsZero() || periodEnd.IsZero() {
+		return errors.New("período da exportação é obrigatório")
+	}
+
+	if !periodEnd.After(periodStart) {
+		return errors.New("data final deve ser posterior à data inicial")
+	}
+
+	return nil
+}
+
+// MarkAsProcessing marks the job as processing with the total of items to export
+func (j *ExportJob) MarkAsProcessing(totalItems int) {
+	j.Status = ExportStatusProcessing
+	j.TotalItems = totalItems
+	j.UpdatedAt = time.Now()
+}
+
+// IncrementAttempts records a new processing attempt
+func (j *ExportJob) IncrementAttempts() {
+	j.Attempts++
+	j.UpdatedAt = time.Now()
+}
+
+// AddPart records a part written to storage and advances the progress
+func (j *ExportJob) AddPart(part ExportPart) {
+	j.Parts = append(j.Parts, part)
+	j.ProcessedItems += part.Items
+	j.UpdatedAt = time.Now()
+}
+
+// MarkAsCompleted marks the job as completed
+func (j *ExportJob) MarkAsCompleted() {
+	now := time.Now()
+	j.Status = ExportStatusCompleted
+	j.CompletedAt = &now
+	j.UpdatedAt = now
+}
+
+// MarkAsFailed marks the job as failed
+func (j *ExportJob) MarkAsFailed(message string) {
+	j.Status = ExportStatusFailed
+	j.ErrorMessage = message
+	j.UpdatedAt = time.Now()
+}
+
+// MarkAsExpired marks the job as expired after its parts are removed
+func (j *ExportJob) MarkAsExpired() {
+	j.Status = ExportStatusExpired
+	j.Parts = ExportParts{}
+	j.UpdatedAt = time.Now()
+}
+
+// IsExpired checks if the job download window has passed
+func (j *ExportJob) IsExpired() bool {
+	return j.Status == ExportStatusExpired || time.Now().After(j.ExpiresAt)
+}
+
+// Progress returns the completion percentage of the job
+func (j *ExportJob) Progress() float64 {
+	if j.Status == ExportStatusCompleted {
+		return 100
+	}
+	if j.TotalItems == 0 {
+		return 0
+	}
+	return float64(j.ProcessedItems) / float64(j.TotalItems) * 100
+}
+
+// GetPart returns the part with the given number
+func (j *ExportJob) GetPart(number int) (*ExportPart, bool) {
+	for i := range j.Parts {
+		if j.Parts[i].Number == number {
+			return &j.Parts[i], true
+		}
+	}
+	return nil, false
+}
+
+// TableName specifies the table name for GORM
+func (ExportJob) TableName() string {
+	return "export_jobs"
+}
@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey records a request made with an Idempotency-Key header, unique per company.
+// The key is reserved when the request starts and holds the response once it completes, so
+// a retry with the same payload replays it.
+type IdempotencyKey struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:uuid"`
+	CompanyID      string     `json:"company_id" gorm:"type:uuid;not null"`
+	Key            string     `json:"key" gorm:"column:idempotency_key;not null"`
+	RequestHash    string     `json:"request_hash" gorm:"not null"` // SHA-256 of method, path and body
+	ResponseStatus int        `json:"response_status,omitempty"`
+	ResponseBody   []byte     `json:"-" gorm:"type:bytea"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+}
+
+// NewIdempotencyKey reserves key for a request of the company, kept for ttl
+func NewIdempotencyKey(companyID, key, requestHash string, ttl time.Duration) *IdempotencyKey {
+	now := time.Now()
+	return &IdempotencyKey{
+		ID:          uuid.New().String(),
+		CompanyID:   companyID,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+}
+
+// Completed reports whether the response of the request was stored
+func (k *IdempotencyKey) Completed() bool {
+	return k.CompletedAt != nil
+}
+
+// ExpiredAt reports whether the key can be reused at t
+func (k *IdempotencyKey) ExpiredAt(t time.Time) bool {
+	return !t.Before(k.ExpiresAt)
+}
+
+// TableName specifies the table name for GORM
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
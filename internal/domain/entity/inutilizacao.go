@@ -0,0 +1,124 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InutilizacaoStatus represents the lifecycle of a number range inutilização
+type InutilizacaoStatus string
+
+const (
+	InutilizacaoStatusPending     InutilizacaoStatus = "pending"
+	InutilizacaoStatusProcessing  InutilizacaoStatus = "processing"
+	InutilizacaoStatusHomologated InutilizacaoStatus = "homologated"
+	InutilizacaoStatusRejected    InutilizacaoStatus = "rejected"
+)
+
+// Inutilizacao represents a range of NFC-e numbers inutilized at SEFAZ for a company/series
+type Inutilizacao struct {
+	ID            string             `json:"id"`
+	CompanyID     string             `json:"company_id"`
+	UF            string             `json:"uf"`
+	Ambiente      string             `json:"ambiente"`
+	Modelo        string             `json:"modelo"` // 65 (NFC-e) or 55 (NF-e)
+	Serie         int                `json:"serie"`
+	NumeroInicial int64              `json:"numero_inicial"`
+	NumeroFinal   int64              `json:"numero_final"`
+	Justificativa string             `json:"justificativa"`
+	Status        InutilizacaoStatus `json:"status"`
+
+	// SEFAZ response
+	Protocolo     string     `json:"protocolo,omitempty"`
+	CStat         string     `json:"cstat,omitempty" gorm:"column:cstat"`
+	XMotivo       string     `json:"xmotivo,omitempty" gorm:"column:xmotivo"`
+	XMLURL        string     `json:"xml_url,omitempty"` // procInutNFe
+	HomologatedAt *time.Time `json:"homologated_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewInutilizacao creates a new inutilização request for a range of numbers of a series
+func NewInutilizacao(companyID, uf, ambiente, modelo string, serie int, numeroInicial, numeroFinal int64, justificativa string) (*Inutilizacao, error) {
+	if companyID == "" {
+		return nil, errors.New("company ID é obrigatório")
+	}
+
+	if uf == "" {
+		return nil, errors.New("UF é obrigatória")
+	}
+
+	if !IsValidModelo(modelo) {
+		return nil, errors.New("modelo deve ser 65 (NFC-e) ou 55 (NF-e)")
+	}
+
+	if serie < 0 || serie > 999 {
+		return nil, errors.New("série deve estar entre 0 e 999")
+	}
+
+	if numeroInicial <= 0 || numeroFinal > 999999999 {
+		return nil, errors.New("numeração deve estar entre 1 e 999999999")
+	}
+
+	if numeroFinal < numeroInicial {
+		return nil, errors.New("número final deve ser maior ou igual ao número inicial")
+	}
+
+	if len(justificativa) < 15 || len(justificativa) > 255 {
+		return nil, errors.New("justificativa deve ter entre 15 e 255 caracteres")
+	}
+
+	now := time.Now()
+	return &Inutilizacao{
+		ID:            uuid.New().String(),
+		CompanyID:     companyID,
+		UF:            uf,
+		Ambiente:      ambiente,
+		Modelo:        modelo,
+		Serie:         serie,
+		NumeroInicial: numeroInicial,
+		NumeroFinal:   numeroFinal,
+		Justificativa: justificativa,
+		Status:        InutilizacaoStatusPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}, nil
+}
+
+// MarkAsProcessing marks the inutilização as being sent to SEFAZ
+func (i *Inutilizacao) MarkAsProcessing() {
+	i.Status = InutilizacaoStatusProcessing
+	i.UpdatedAt = time.Now()
+}
+
+// MarkAsHomologated records the protocol returned by SEFAZ
+func (i *Inutilizacao) MarkAsHomologated(protocolo, cStat, xMotivo string) {
+	now := time.Now()
+	i.Status = InutilizacaoStatusHomologated
+	i.Protocolo = protocolo
+	i.CStat = cStat
+	i.XMotivo = xMotivo
+	i.HomologatedAt = &now
+	i.UpdatedAt = now
+}
+
+// MarkAsRejected records the SEFAZ rejection
+func (i *Inutilizacao) MarkAsRejected(cStat, xMotivo string) {
+	i.Status = InutilizacaoStatusRejected
+	i.CStat = cStat
+	i.XMotivo = xMotivo
+	i.UpdatedAt = time.Now()
+}
+
+// IsFinal checks if the inutilização reached a final state
+func (i *Inutilizacao) IsFinal() bool {
+	return i.Status == InutilizacaoStatusHomologated || i.Status == InutilizacaoStatusRejected
+}
+
+// TableName specifies the table name for GORM
+func (Inutilizacao) TableName() string {
+	return "nfce_inutilizacoes"
+}
@@ -0,0 +1,128 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// InvoiceStatus represents the payment status of an invoice
+type InvoiceStatus string
+
+const (
+	InvoiceStatusPending InvoiceStatus = "pending" // Not charged yet, or awaiting the gateway confirmation
+	InvoiceStatusPaid    InvoiceStatus = "paid"
+	InvoiceStatusFailed  InvoiceStatus = "failed" // Last charge failed, retried at NextAttemptAt
+	InvoiceStatusVoid    InvoiceStatus = "void"   // Subscription canceled before the invoice was paid
+)
+
+// Invoice is the charge of one billing period of a subscription through the payment gateway
+type Invoice struct {
+	ID             string        `json:"id"`
+	CompanyID      string        `json:"company_id"`
+	SubscriptionID string        `json:"subscription_id"`
+	Amount         float64       `json:"amount"`
+	Adjustment     float64       `json:"adjustment,omitempty"` // Prorations of plan changes included in Amount
+	Currency       string        `json:"currency"`
+	PeriodStart    time.Time     `json:"period_start"`
+	PeriodEnd      time.Time     `json:"period_end"`
+	Status         InvoiceStatus `json:"status"`
+
+	// Gateway
+	Gateway       string     `json:"gateway"`
+	ChargeID      string     `json:"charge_id,omitempty"` // Charge of the last attempt on the gateway
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewInvoice creates the pending invoice of the subscription for the billing period
+func NewInvoice(subscription *Subscription, gateway string, periodStart, periodEnd time.Time) (*Invoice, error) {
+	if subscription == nil {
+		return nil, errors.New("assinatura é obrigatória")
+	}
+	if gateway == "" {
+		return nil, errors.New("gateway de pagamento é obrigatório")
+	}
+	if !periodEnd.After(periodStart) {
+		return nil, errors.New("período da fatura inválido")
+	}
+
+	now := time.Now()
+	return &Invoice{
+		ID:             uuid.New().String(),
+		CompanyID:      subscription.CompanyID,
+		SubscriptionID: subscription.ID,
+		Amount:         subscription.BillingInfo.Amount,
+		Currency:       subscription.BillingInfo.Currency,
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+		Status:         InvoiceStatusPending,
+		Gateway:        gateway,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}, nil
+}
+
+// IsOpen returns true while the invoice is neither paid nor void
+func (i *Invoice) IsOpen() bool {
+	return i.Status == InvoiceStatusPending || i.Status == InvoiceStatusFailed
+}
+
+// MarkPaid settles the invoice with the gateway charge that paid it
+func (i *Invoice) MarkPaid(chargeID string, now time.Time) {
+	if chargeID != "" {
+		i.ChargeID = chargeID
+	}
+	i.Status = InvoiceStatusPaid
+	i.LastError = ""
+	i.NextAttemptAt = nil
+	i.PaidAt = &now
+	i.UpdatedAt = now
+}
+
+// MarkAwaiting keeps the invoice pending while the gateway confirms the charge, e.g. Pix or
+// a charge under review; the payment webhook settles it
+func (i *Invoice) MarkAwaiting(chargeID string, now time.Time) {
+	i.ChargeID = chargeID
+	i.Status = InvoiceStatusPending
+	i.NextAttemptAt = nil
+	i.UpdatedAt = now
+}
+
+// MarkFailed records a failed charge; retryAt schedules the next attempt, nil stops the retries
+func (i *Invoice) MarkFailed(chargeID, reason string, retryAt *time.Time, now time.Time) {
+	if chargeID != "" {
+		i.ChargeID = chargeID
+	}
+	i.Status = InvoiceStatusFailed
+	i.LastError = reason
+	i.NextAttemptAt = retryAt
+	i.UpdatedAt = now
+}
+
+// Void closes the unpaid invoice of a canceled or expired subscription
+func (i *Invoice) Void(now time.Time) {
+	i.Status = InvoiceStatusVoid
+	i.NextAttemptAt = nil
+	i.UpdatedAt = now
+}
+
+// ApplyAdjustment adds the prorations of the plan changes to the amount of the invoice. A credit
+// larger than the amount leaves the invoice free and returns the rest, carried to the next one.
+func (i *Invoice) ApplyAdjustment(adjustment float64) (carried float64) {
+	i.Adjustment = adjustment
+	total := money.FromFloat(i.Amount) + money.FromFloat(adjustment)
+	if total < 0 {
+		i.Amount = 0
+		return total.Float64()
+	}
+	i.Amount = total.Float64()
+	return 0
+}
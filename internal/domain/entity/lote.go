@@ -0,0 +1,78 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxLoteSize caps the NFC-e of a lote, the limit of NF-e in a SEFAZ enviNFe
+const MaxLoteSize = 50
+
+// NFCeLote groups the NFC-e requested together through POST /nfce/lote. Each NFC-e is a
+// regular request; the lote only links them for the aggregate status.
+type NFCeLote struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:uuid"`
+	CompanyID      string    `json:"company_id" gorm:"type:uuid;not null"`
+	IdempotencyKey string    `json:"idempotency_key" gorm:"not null"`
+	Size           int       `json:"size"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// NewNFCeLote creates a lote of size NFC-e requested with the Idempotency-Key
+func NewNFCeLote(companyID, idempotencyKey string, size int) *NFCeLote {
+	return &NFCeLote{
+		ID:             uuid.New().String(),
+		CompanyID:      companyID,
+		IdempotencyKey: idempotencyKey,
+		Size:           size,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// ItemIdempotencyKey returns the idempotency key of the NFC-e at index of the lote
+func (l *NFCeLote) ItemIdempotencyKey(index int) string {
+	return fmt.Sprintf("lote:%s:%d", l.ID, index+1)
+}
+
+// TableName specifies the table name for GORM
+func (NFCeLote) TableName() string {
+	return "nfce_lotes"
+}
+
+// LoteStatus is the aggregate status of the NFC-e of a lote
+type LoteStatus string
+
+const (
+	// LoteStatusProcessing means some NFC-e have not reached a final status yet.
+	LoteStatusProcessing LoteStatus = "processing"
+	// LoteStatusAuthorized means every NFC-e was authorized (canceled ones included).
+	LoteStatusAuthorized LoteStatus = "authorized"
+	// LoteStatusPartial means some NFC-e were authorized and others rejected.
+	LoteStatusPartial LoteStatus = "partial"
+	// LoteStatusRejected means every NFC-e was rejected.
+	LoteStatusRejected LoteStatus = "rejected"
+)
+
+// AggregateLoteStatus derives the lote status from the count of its NFC-e per status
+func AggregateLoteStatus(counts map[RequestStatus]int) LoteStatus {
+	authorized := counts[RequestStatusAuthorized] + counts[RequestStatusCanceled]
+	rejected := counts[RequestStatusRejected]
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	switch {
+	case authorized+rejected < total:
+		return LoteStatusProcessing
+	case rejected == 0:
+		return LoteStatusAuthorized
+	case authorized == 0:
+		return LoteStatusRejected
+	default:
+		return LoteStatusPartial
+	}
+}
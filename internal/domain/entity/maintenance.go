@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaintenanceWindow is a period in which new emissions are refused while the
+// operators work on the platform; processing already accepted goes on
+type MaintenanceWindow struct {
+	ID            string     `json:"id"`
+	Message       string     `json:"message"` // Shown to the clients while the window lasts
+	StartedAt     time.Time  `json:"started_at"`
+	ExpectedEndAt *time.Time `json:"expected_end_at,omitempty"`
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (MaintenanceWindow) TableName() string {
+	return "maintenance_windows"
+}
+
+// NewMaintenanceWindow starts a maintenance window now
+func NewMaintenanceWindow(message string, expectedEndAt *time.Time) (*MaintenanceWindow, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return nil, errors.New("mensagem da manutenção é obrigatória")
+	}
+
+	now := time.Now()
+	if expectedEndAt != nil && !expectedEndAt.After(now) {
+		return nil, errors.New("previsão de término da manutenção deve ser futura")
+	}
+
+	return &MaintenanceWindow{
+		ID:            uuid.New().String(),
+		Message:       message,
+		StartedAt:     now,
+		ExpectedEndAt: expectedEndAt,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}, nil
+}
+
+// IsActive reports whether the window has not ended yet
+func (m *MaintenanceWindow) IsActive() bool {
+	return m.EndedAt == nil
+}
+
+// End closes the window
+func (m *MaintenanceWindow) End() {
+	now := time.Now()
+	m.EndedAt = &now
+	m.UpdatedAt = now
+}
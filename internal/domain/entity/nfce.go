@@ -4,9 +4,13 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
 )
 
 // RequestStatus represents the lifecycle state of an NFC-e request.
@@ -15,6 +19,8 @@ type RequestStatus string
 const (
 	// RequestStatusPending is persisted right after intake.
 	RequestStatusPending RequestStatus = "pending"
+	// RequestStatusQueuedDeferred is persisted when the broker is down; the request is published once it recovers.
+	RequestStatusQueuedDeferred RequestStatus = "queued_deferred"
 	// RequestStatusProcessing is set when the worker starts handling the job.
 	RequestStatusProcessing RequestStatus = "processing"
 	// RequestStatusAuthorized means SEFAZ authorized the NFC-e.
@@ -27,18 +33,58 @@ const (
 	RequestStatusRetrying RequestStatus = "retrying"
 	// RequestStatusCanceled is for cancellation events.
 	RequestStatusCanceled RequestStatus = "canceled"
+	// RequestStatusPendingTransmission is set for NFC-e issued in offline contingency (tpEmis 9) until SEFAZ receives them.
+	RequestStatusPendingTransmission RequestStatus = "pending_transmission"
 )
 
+// RequestStatuses lists every request status; new statuses must be added here
+// and to the API status mapping (mapper.ToRequestStatusDTO).
+var RequestStatuses = []RequestStatus{
+	RequestStatusPending,
+	RequestStatusQueuedDeferred,
+	RequestStatusProcessing,
+	RequestStatusAuthorized,
+	RequestStatusRejected,
+	RequestStatusContingency,
+	RequestStatusRetrying,
+	RequestStatusCanceled,
+	RequestStatusPendingTransmission,
+}
+
+// IsValid reports whether s is a known request status
+func (s RequestStatus) IsValid() bool {
+	for _, status := range RequestStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ContingencyTypeOffline is the NFC-e offline contingency (tpEmis 9).
+const ContingencyTypeOffline = "OFFLINE"
+
 // EmitOptions controls sync/async behavior and contingency flags.
 type EmitOptions struct {
-	Contingencia bool `json:"contingencia"`
-	Sync         bool `json:"sync"`
+	Contingencia  bool `json:"contingencia"`
+	Sync          bool `json:"sync"`
+	DanfeDuasVias bool `json:"danfe_duas_vias"`
 }
 
-// Certificate holds the encrypted PFX and its password.
+// Certificate holds the encrypted PFX and its password, or the remote key of
+// companies that sign through an HSM or cloud KMS.
 type Certificate struct {
-	PFXBase64 string `json:"cert_pfx_b64"`
-	Password  string `json:"cert_password"`
+	PFXBase64 string     `json:"cert_pfx_b64"`
+	Password  string     `json:"cert_password"`
+	KMS       *KMSKeyRef `json:"-"`
+}
+
+// KMSKeyRef identifies the remote signing key of a company and its public certificate
+type KMSKeyRef struct {
+	Endpoint       string
+	KeyID          string
+	Token          string
+	CertificatePEM []byte
 }
 
 // Emitente aggregates issuer data required to build the XML and QR.
@@ -52,30 +98,260 @@ type Emitente struct {
 
 // Item is a minimal representation of a product line.
 type Item struct {
-	Descricao  string  `json:"descricao"`
-	NCM        string  `json:"ncm"`
-	CFOP       string  `json:"cfop"`
-	GTIN       string  `json:"gtin,omitempty"`
-	Valor      float64 `json:"valor"`
-	Quantidade float64 `json:"quantidade"`
-	Unidade    string  `json:"unidade"`
+	Descricao  string         `json:"descricao"`
+	NCM        string         `json:"ncm"`
+	CEST       string         `json:"cest,omitempty"` // Required for goods under substituição tributária
+	CFOP       string         `json:"cfop"`
+	GTIN       string         `json:"gtin,omitempty"`
+	Valor      money.Amount   `json:"valor"`
+	Quantidade money.Quantity `json:"quantidade"`
+	Unidade    string         `json:"unidade"`
+	Desconto   money.Amount   `json:"desconto,omitempty"` // vDesc - discount given on the item
+	Outros     money.Amount   `json:"outros,omitempty"`   // vOutro - other ancillary charges of the item
+	IPI        *ItemIPI       `json:"ipi,omitempty"`      // NF-e only
+	II         *ItemII        `json:"ii,omitempty"`       // NF-e only, imports (CFOP 3xxx)
+}
+
+// Gross returns the item value before discounts and charges (vProd)
+func (i Item) Gross() money.Amount {
+	return i.Quantidade.Times(i.Valor)
 }
 
 // Payment captures the payment mix used in the sale.
 type Payment struct {
-	Forma string  `json:"forma"`
-	Valor float64 `json:"valor"`
-	Troco float64 `json:"troco,omitempty"`
+	Forma  string       `json:"forma"`
+	Valor  money.Amount `json:"valor"`
+	Troco  money.Amount `json:"troco,omitempty"`
+	Cartao *CardPayment `json:"cartao,omitempty"`
+}
+
+// Card payment forms (tPag) and integration types (tpIntegra)
+const (
+	PaymentFormaCredito = "03"
+	PaymentFormaDebito  = "04"
+
+	CardIntegrated = "1" // TEF integrated with the PDV
+	CardStandalone = "2" // POS not integrated with the PDV
+)
+
+// IsCard reports whether the payment was made with a credit or debit card
+func (p Payment) IsCard() bool {
+	return p.Forma == PaymentFormaCredito || p.Forma == PaymentFormaDebito
+}
+
+// CardPayment carries the acquirer data of a card payment (card)
+type CardPayment struct {
+	TpIntegra   string `json:"tp_integra"`
+	CNPJ        string `json:"cnpj,omitempty"`
+	Bandeira    string `json:"bandeira,omitempty"`
+	Autorizacao string `json:"autorizacao,omitempty"`
+}
+
+// Validate checks the card group; with integrated TEF the acquirer CNPJ, the
+// bandeira (tBand) and the authorization code (cAut) are mandatory
+func (c *CardPayment) Validate() error {
+	switch c.TpIntegra {
+	case CardIntegrated:
+		if c.CNPJ == "" || c.Bandeira == "" || c.Autorizacao == "" {
+			return errors.New("pagamento com TEF integrado requer cnpj, bandeira e autorizacao do cartão")
+		}
+	case CardStandalone:
+	default:
+		return errors.New("tp_integra do cartão deve ser 1 (TEF integrado) ou 2 (POS)")
+	}
+
+	if c.CNPJ != "" {
+		if err := validateCNPJ(c.CNPJ); err != nil {
+			return fmt.Errorf("cnpj da credenciadora: %w", err)
+		}
+	}
+	if c.Bandeira != "" && !cardBandeira.MatchString(c.Bandeira) {
+		return errors.New("bandeira do cartão deve ser o código de 2 dígitos da tabela tBand")
+	}
+	if len(c.Autorizacao) > 20 {
+		return errors.New("autorizacao do cartão deve ter até 20 caracteres")
+	}
+	return nil
+}
+
+// cardBandeira matches the two digit tBand codes (01 Visa, 02 Mastercard, ..., 99 Outros)
+var cardBandeira = regexp.MustCompile(`^\d{2}$`)
+
+// ValidatePayments checks the card group of the payments; it is only allowed for card forms
+func (p EmitPayload) ValidatePayments() error {
+	for i, payment := range p.Pagamentos {
+		if payment.Cartao == nil {
+			continue
+		}
+		if !payment.IsCard() {
+			return fmt.Errorf("pagamento %d: cartao só é permitido nas formas 03 (crédito) e 04 (débito)", i+1)
+		}
+		if err := payment.Cartao.Validate(); err != nil {
+			return fmt.Errorf("pagamento %d: %w", i+1, err)
+		}
+	}
+	return nil
 }
 
 // EmitPayload is the normalized payload used to generate the NFC-e XML.
 type EmitPayload struct {
 	UF         string      `json:"uf"`
 	Ambiente   string      `json:"ambiente"`
+	Serie      *int        `json:"serie,omitempty"` // Numbering series; the company default when nil
 	Emitente   Emitente    `json:"emitente"`
 	Itens      []Item      `json:"itens"`
 	Pagamentos []Payment   `json:"pagamentos"`
 	Options    EmitOptions `json:"options"`
+	// Destinatario identifies the consumer (dest); optional in the NFC-e
+	Destinatario *Destinatario `json:"destinatario,omitempty"`
+	// Intermediador identifies the marketplace of the sale (infIntermed)
+	Intermediador *IntermediadorRef `json:"intermediador,omitempty"`
+	// Terminal identifies the point of sale (PDV) that issued the NFC-e
+	Terminal string `json:"terminal,omitempty"`
+	// Frete is the freight of the sale (vFrete), apportioned among the items by value
+	Frete money.Amount `json:"frete,omitempty"`
+	// Modelo is the document model, 65 (NFC-e, the default) or 55 (NF-e)
+	Modelo string `json:"modelo,omitempty"`
+	// NaturezaOperacao is the natOp of the NF-e; VENDA when empty
+	NaturezaOperacao string `json:"natureza_operacao,omitempty"`
+	// Transporte and Cobranca are the transp and cobr groups, NF-e only
+	Transporte *Transporte `json:"transporte,omitempty"`
+	Cobranca   *Cobranca   `json:"cobranca,omitempty"`
+}
+
+// Total returns the document total (vNF): the items less their discounts plus freight and
+// other charges, and the IPI and II of the NF-e
+func (p EmitPayload) Total() money.Amount {
+	total := p.Frete
+	for _, item := range p.Itens {
+		total += item.Gross() - item.Desconto + item.Outros
+	}
+	return total + p.importTaxes()
+}
+
+// FreightShares apportions the freight among the items proportionally to their value.
+// The last item takes the rounding difference so the shares add up to the freight.
+func (p EmitPayload) FreightShares() []money.Amount {
+	weights := make([]money.Amount, len(p.Itens))
+	var gross money.Amount
+	for i, item := range p.Itens {
+		weights[i] = item.Gross()
+		gross += weights[i]
+	}
+	if gross == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	return p.Frete.Allocate(weights)
+}
+
+// Change returns the troco of the sale (vTroco): the troco informed in the payments or,
+// when none is informed, what the payments exceed the total
+func (p EmitPayload) Change() money.Amount {
+	var paid, troco money.Amount
+	for _, payment := range p.Pagamentos {
+		paid += payment.Valor
+		troco += payment.Troco
+	}
+	if troco > 0 {
+		return troco
+	}
+	if excess := paid - p.Total(); excess > 0 {
+		return excess
+	}
+	return 0
+}
+
+// ValidateAmounts checks the discounts, charges and payments against the NFC-e total,
+// as SEFAZ rejects notes whose payments do not cover vNF
+func (p EmitPayload) ValidateAmounts() error {
+	if p.Frete < 0 {
+		return errors.New("frete não pode ser negativo")
+	}
+
+	for i, item := range p.Itens {
+		if item.Desconto < 0 || item.Outros < 0 {
+			return fmt.Errorf("item %d: desconto e outros não podem ser negativos", i+1)
+		}
+		if item.Desconto >= item.Gross() {
+			return fmt.Errorf("item %d: desconto deve ser menor que o valor do item", i+1)
+		}
+	}
+
+	var paid, troco money.Amount
+	for _, payment := range p.Pagamentos {
+		if payment.Valor < 0 || payment.Troco < 0 {
+			return errors.New("valor e troco dos pagamentos não podem ser negativos")
+		}
+		paid += payment.Valor
+		troco += payment.Troco
+	}
+
+	total := p.Total()
+	if paid-troco < total {
+		return fmt.Errorf("pagamentos (%s) menos o troco (%s) não cobrem o total da NFC-e (%s)", paid, troco, total)
+	}
+	if troco > 0 && paid-troco != total {
+		return fmt.Errorf("troco (%s) deve ser a diferença entre os pagamentos (%s) e o total da NFC-e (%s)", troco, paid, total)
+	}
+	return nil
+}
+
+// Destinatario identifies the consumer of the sale by CPF or CNPJ
+type Destinatario struct {
+	CPF   string `json:"cpf,omitempty"`
+	CNPJ  string `json:"cnpj,omitempty"`
+	Nome  string `json:"nome,omitempty"`
+	Email string `json:"email,omitempty"`
+	// IE and Endereco identify the recipient of an NF-e
+	IE       string   `json:"ie,omitempty"`
+	Endereco *Address `json:"endereco,omitempty"`
+}
+
+// Validate checks the consumer document and the xNome and email limits of the layout
+func (d *Destinatario) Validate() error {
+	switch {
+	case d.CPF != "" && d.CNPJ != "":
+		return errors.New("destinatário deve ter cpf ou cnpj, não ambos")
+	case d.CPF != "":
+		if err := validateCPF(d.CPF); err != nil {
+			return err
+		}
+	case d.CNPJ != "":
+		if err := validateCNPJ(d.CNPJ); err != nil {
+			return err
+		}
+	default:
+		return errors.New("destinatário requer cpf ou cnpj")
+	}
+
+	if nome := strings.TrimSpace(d.Nome); nome != "" && (len([]rune(nome)) < 2 || len([]rune(nome)) > 60) {
+		return errors.New("nome do destinatário deve ter de 2 a 60 caracteres")
+	}
+	if d.Email != "" && (len(d.Email) > 60 || !strings.Contains(d.Email, "@")) {
+		return errors.New("email do destinatário inválido")
+	}
+	return nil
+}
+
+// IntermediadorRef selects the sale intermediary by company registry alias and/or explicit data.
+type IntermediadorRef struct {
+	Alias        string `json:"alias,omitempty"`
+	CNPJ         string `json:"cnpj,omitempty"`
+	Nome         string `json:"nome,omitempty"`
+	IdCadIntTran string `json:"id_cad_int_tran,omitempty"`
+}
+
+// Validate checks that the reference selects an alias or carries the intermediary CNPJ
+func (r *IntermediadorRef) Validate() error {
+	if r.Alias == "" && r.CNPJ == "" {
+		return errors.New("intermediador requer alias ou cnpj")
+	}
+	if r.CNPJ != "" {
+		return validateCNPJ(r.CNPJ)
+	}
+	return nil
 }
 
 // Value implements the driver.Valuer interface for GORM JSONB serialization
@@ -97,15 +373,107 @@ func (e *EmitPayload) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, e)
 }
 
+// Metadata limits keep integrator correlation data small
+const (
+	MaxMetadataKeys        = 20
+	MaxMetadataKeyLength   = 40
+	MaxMetadataValueLength = 500
+)
+
+// Metadata holds integrator key/value pairs (order_id, store_id...) echoed back in responses and webhooks
+type Metadata map[string]string
+
+// Validate checks the metadata size limits
+func (m Metadata) Validate() error {
+	if len(m) > MaxMetadataKeys {
+		return fmt.Errorf("metadata aceita no máximo %d chaves", MaxMetadataKeys)
+	}
+	for key, value := range m {
+		if key == "" {
+			return errors.New("metadata não aceita chave vazia")
+		}
+		if len(key) > MaxMetadataKeyLength {
+			return fmt.Errorf("chave de metadata %q excede %d caracteres", key, MaxMetadataKeyLength)
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("valor de metadata %q excede %d caracteres", key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (m *Metadata) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("Metadata.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, m)
+}
+
+// SchemaViolation is an XSD error of the generated XML, located so the integrator can fix
+// the payload
+type SchemaViolation struct {
+	Path    string `json:"path,omitempty"`  // Element location, e.g. /NFe/infNFe/det[2]/prod/xProd
+	Field   string `json:"field,omitempty"` // Element or attribute the error refers to
+	Line    int    `json:"line,omitempty"`  // Line of the generated XML
+	Message string `json:"message"`         // Description in Portuguese
+	Detail  string `json:"detail"`          // Raw validator message
+}
+
+// SchemaViolations are the XSD errors that rejected an NFC-e before transmission
+type SchemaViolations []SchemaViolation
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (v SchemaViolations) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (v *SchemaViolations) Scan(value interface{}) error {
+	if value == nil {
+		*v = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("SchemaViolations.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, v)
+}
+
 // NFCE represents an NFC-e document and its processing state
 type NFCE struct {
 	ID             string        `json:"id"`
 	CompanyID      string        `json:"company_id"` // Reference to issuing company
 	IdempotencyKey string        `json:"idempotency_key"`
 	Status         RequestStatus `json:"status"`
+	LoteID         *string       `json:"lote_id,omitempty" gorm:"type:uuid"` // Lote the NFC-e was requested in, if any
 
 	// NFC-e data
-	Payload EmitPayload `json:"payload" gorm:"type:jsonb"`
+	Payload EmitPayload  `json:"payload" gorm:"type:jsonb"`
+	VNF     money.Amount `json:"v_nf,omitempty" gorm:"column:v_nf"` // Payload total, a column for the listing filters
+
+	// Integrator correlation data
+	Metadata Metadata `json:"metadata,omitempty" gorm:"type:jsonb"`
 
 	// SEFAZ response data
 	ChaveAcesso string `json:"chave_acesso,omitempty"`
@@ -113,11 +481,16 @@ type NFCE struct {
 	Numero      string `json:"numero,omitempty"` // NFC-e number
 	Serie       string `json:"serie,omitempty"`  // NFC-e series
 
+	// Asynchronous lote (indSinc=0)
+	Recibo      string `json:"recibo,omitempty" gorm:"column:recibo"`             // nRec returned by NFeAutorizacao4
+	ReciboPolls int    `json:"recibo_polls,omitempty" gorm:"column:recibo_polls"` // NFeRetAutorizacao4 queries made
+
 	// Error handling
-	RejectionCode string `json:"rejection_code,omitempty" gorm:"column:rejection_code"`
-	RejectionMsg  string `json:"rejection_msg,omitempty" gorm:"column:rejection_msg"`
-	CStat         string `json:"cstat,omitempty" gorm:"column:cstat"`     // SEFAZ status code
-	XMotivo       string `json:"xmotivo,omitempty" gorm:"column:xmotivo"` // SEFAZ status message
+	RejectionCode string           `json:"rejection_code,omitempty" gorm:"column:rejection_code"`
+	RejectionMsg  string           `json:"rejection_msg,omitempty" gorm:"column:rejection_msg"`
+	SchemaErrors  SchemaViolations `json:"schema_errors,omitempty" gorm:"type:jsonb;column:schema_errors"` // XSD errors of a local rejection
+	CStat         string           `json:"cstat,omitempty" gorm:"column:cstat"`                            // SEFAZ status code
+	XMotivo       string           `json:"xmotivo,omitempty" gorm:"column:xmotivo"`                        // SEFAZ status message
 
 	// Processing metadata
 	RetryCount   int        `json:"retry_count,omitempty"`
@@ -125,14 +498,36 @@ type NFCE struct {
 	ProcessedAt  *time.Time `json:"processed_at,omitempty"`
 	AuthorizedAt *time.Time `json:"authorized_at,omitempty"`
 
+	// Cancellation (evento 110111)
+	CancelProtocolo string     `json:"cancel_protocolo,omitempty"`
+	CanceledAt      *time.Time `json:"canceled_at,omitempty"`
+	CancelXMLURL    string     `json:"cancel_xml_url,omitempty" gorm:"column:cancel_xml_url"` // procEventoNFe URL
+
 	// Contingency
 	InContingency   bool   `json:"in_contingency,omitempty"`
-	ContingencyType string `json:"contingency_type,omitempty"` // SVC-AN, SVC-RS
+	ContingencyType string `json:"contingency_type,omitempty"` // SVC-AN, SVC-RS, OFFLINE
 
 	// Storage references
 	XMLURL    string `json:"xml_url,omitempty" gorm:"column:xml_url"`       // S3 URL for XML
 	PDFURL    string `json:"pdf_url,omitempty" gorm:"column:pdf_url"`       // S3 URL for DANFE
 	QRCodeURL string `json:"qrcode_url,omitempty" gorm:"column:qrcode_url"` // QR Code image URL
+	// DocumentsPurgedAt is when the stored files were deleted by the plan retention; the
+	// request data and the chave are kept
+	DocumentsPurgedAt *time.Time `json:"documents_purged_at,omitempty" gorm:"column:documents_purged_at"`
+
+	// DANFE data fixed at authorization
+	QRCode   string       `json:"qrcode,omitempty" gorm:"column:qrcode"`         // Content of the QR Code, the SEFAZ consultation URL with its hash
+	VTotTrib money.Amount `json:"v_tot_trib,omitempty" gorm:"column:v_tot_trib"` // Approximate taxes of the sale (Lei 12.741/2012)
+	// Totais are the tax totals printed by the DANFE A4 of the NF-e
+	Totais *DocumentTotals `json:"totais,omitempty" gorm:"column:totais;type:jsonb"`
+
+	// Version is the sequence of the last event of the request, checked by the status
+	// changes so a concurrent change is not overwritten
+	Version int `json:"-" gorm:"column:version"`
+
+	// SEFAZExchanges holds the IDs of the SEFAZ exchanges archived while the request is
+	// processed, linked from the event recording the outcome (not persisted)
+	SEFAZExchanges []string `json:"-" gorm:"-"`
 
 	// Relationships (not serialized to JSON)
 	Events []Event `json:"-" gorm:"foreignKey:RequestID;references:ID"`
@@ -159,6 +554,7 @@ func NewNFCE(companyID, idempotencyKey string, payload EmitPayload) (*NFCE, erro
 		IdempotencyKey: idempotencyKey,
 		Status:         RequestStatusPending,
 		Payload:        payload,
+		VNF:            payload.Total(),
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}, nil
@@ -191,19 +587,61 @@ func (n *NFCE) MarkAsRejected(cstat, xmotivo string) {
 	n.XMotivo = xmotivo
 	n.RejectionCode = cstat
 	n.RejectionMsg = xmotivo
+	n.SchemaErrors = nil
 	n.ProcessedAt = &now
 	n.UpdatedAt = now
 }
 
-// MarkAsCanceled marks the NFC-e as canceled
-func (n *NFCE) MarkAsCanceled(justificativa string) {
+// MarkAsSchemaRejected rejects the NFC-e whose XML does not comply with the SEFAZ schema,
+// keeping the violations for the integrator
+func (n *NFCE) MarkAsSchemaRejected(summary string, violations SchemaViolations) {
+	n.MarkAsRejected("999", "Falha no schema XML: "+summary)
+	n.SchemaErrors = violations
+}
+
+// MarkAsCanceled marks the NFC-e as canceled by the SEFAZ cancellation event
+func (n *NFCE) MarkAsCanceled(justificativa, protocolo string) {
 	now := time.Now()
 	n.Status = RequestStatusCanceled
 	n.XMotivo = justificativa
+	n.CancelProtocolo = protocolo
+	n.CanceledAt = &now
 	n.ProcessedAt = &now
 	n.UpdatedAt = now
 }
 
+// MarkCancellationRejected keeps the NFC-e authorized after SEFAZ refused the cancellation event
+func (n *NFCE) MarkCancellationRejected(cstat, xmotivo string) {
+	n.Status = RequestStatusAuthorized
+	n.CStat = cstat
+	n.XMotivo = xmotivo
+	n.UpdatedAt = time.Now()
+}
+
+// MarkAsAwaitingReceipt records the receipt of an asynchronous lote; the
+// protocol is fetched by polling NFeRetAutorizacao4 from nextPoll on
+func (n *NFCE) MarkAsAwaitingReceipt(chaveAcesso, recibo string, nextPoll time.Time) {
+	n.Status = RequestStatusProcessing
+	n.ChaveAcesso = chaveAcesso
+	n.Recibo = recibo
+	n.ReciboPolls = 0
+	n.NextRetryAt = &nextPoll
+	n.UpdatedAt = time.Now()
+}
+
+// IsAwaitingReceipt checks if the NFC-e waits for the result of an asynchronous lote
+func (n *NFCE) IsAwaitingReceipt() bool {
+	return n.Status == RequestStatusProcessing && n.Recibo != ""
+}
+
+// ClearReceipt drops the receipt so the NFC-e can be submitted again
+func (n *NFCE) ClearReceipt() {
+	n.Recibo = ""
+	n.ReciboPolls = 0
+	n.NextRetryAt = nil
+	n.UpdatedAt = time.Now()
+}
+
 // MarkAsContingency marks the NFC-e as using contingency
 func (n *NFCE) MarkAsContingency(contingencyType string) {
 	n.Status = RequestStatusContingency
@@ -212,6 +650,22 @@ func (n *NFCE) MarkAsContingency(contingencyType string) {
 	n.UpdatedAt = time.Now()
 }
 
+// MarkAsPendingTransmission marks the NFC-e as issued in offline contingency
+// (tpEmis 9); the signed XML is transmitted once SEFAZ is back
+func (n *NFCE) MarkAsPendingTransmission(chaveAcesso string) {
+	n.Status = RequestStatusPendingTransmission
+	n.ChaveAcesso = chaveAcesso
+	n.InContingency = true
+	n.ContingencyType = ContingencyTypeOffline
+	n.NextRetryAt = nil
+	n.UpdatedAt = time.Now()
+}
+
+// IsPendingTransmission checks if the NFC-e was issued offline and not yet transmitted to SEFAZ
+func (n *NFCE) IsPendingTransmission() bool {
+	return n.Status == RequestStatusPendingTransmission
+}
+
 // IncrementRetry increments the retry count
 func (n *NFCE) IncrementRetry() {
 	n.RetryCount++
@@ -232,14 +686,37 @@ func (n *NFCE) CanRetry(maxRetries int) bool {
 	}
 
 	// Don't retry if more than 48 hours have passed since creation
-	maxAge := 48 * time.Hour
-	if time.Since(n.CreatedAt) > maxAge {
+	if time.Now().After(n.RetryDeadline()) {
 		return false
 	}
 
 	return true
 }
 
+// RetryMaxAge is how long after creation an NFC-e may still be retried
+const RetryMaxAge = 48 * time.Hour
+
+// RetryDeadline returns the last moment the NFC-e may be retried
+func (n *NFCE) RetryDeadline() time.Time {
+	return n.CreatedAt.Add(RetryMaxAge)
+}
+
+// NFCeSearchHit is an NFC-e matched by the full-text search, with its relevance and
+// the matching excerpt of the item descriptions and consumer name
+type NFCeSearchHit struct {
+	NFCe      *NFCE
+	Rank      float64
+	Highlight string
+}
+
+// RetryBacklog summarizes the retries due and the requests already in flight,
+// used by the retry scheduler to size its batches
+type RetryBacklog struct {
+	Due         int        // Retrying requests whose next_retry_at has passed
+	InFlight    int        // Requests pending or processing (queued or being emitted)
+	OldestDueAt *time.Time // Earliest next_retry_at among the due retries
+}
+
 // SetStorageURLs sets the URLs for stored documents
 func (n *NFCE) SetStorageURLs(xmlURL, pdfURL, qrCodeURL string) {
 	n.XMLURL = xmlURL
@@ -248,15 +725,40 @@ func (n *NFCE) SetStorageURLs(xmlURL, pdfURL, qrCodeURL string) {
 	n.UpdatedAt = time.Now()
 }
 
-// Event captures status transitions for auditability and observability.
+// DocumentKeys returns the storage keys of the files kept for the NFC-e: the authorized XML,
+// the cancellation event, the DANFE and the QR Code
+func (n *NFCE) DocumentKeys() []string {
+	if n.ChaveAcesso == "" {
+		return nil
+	}
+	prefix := fmt.Sprintf("nfce/%s/", n.CompanyID)
+	return []string{
+		prefix + "xml/" + n.ChaveAcesso + ".xml",
+		prefix + "xml/" + n.ChaveAcesso + "-cancelamento.xml",
+		prefix + "pdf/" + n.ChaveAcesso + ".pdf",
+		prefix + "qr/" + n.ChaveAcesso + ".png",
+		prefix + "qr/" + n.ChaveAcesso + ".txt",
+	}
+}
+
+// DocumentsPurged reports whether the stored files were deleted by the plan retention
+func (n *NFCE) DocumentsPurged() bool {
+	return n.DocumentsPurgedAt != nil
+}
+
+// Event captures status transitions for auditability and observability. The events of a
+// request are numbered by Sequence and are the source of its status: replaying them
+// rebuilds the request (see ProjectEvents).
 type Event struct {
 	ID         string                 `json:"id" gorm:"type:varchar(36);primaryKey"`
 	RequestID  string                 `json:"request_id" gorm:"type:varchar(36);index"`
+	Sequence   int                    `json:"-" gorm:"column:sequence"`
 	StatusFrom RequestStatus          `json:"status_from" gorm:"type:varchar(20)"`
 	StatusTo   RequestStatus          `json:"status_to" gorm:"type:varchar(20)"`
-	CStat      string                 `json:"cstat,omitempty" gorm:"type:varchar(10)"`
+	CStat      string                 `json:"cstat,omitempty" gorm:"column:cstat;type:varchar(10)"`
 	Message    string                 `json:"message,omitempty" gorm:"type:text"`
-	Metadata   map[string]interface{} `json:"metadata,omitempty" gorm:"type:jsonb"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty" gorm:"type:jsonb;serializer:json"`
+	State      *NFCeState             `json:"-" gorm:"type:jsonb;serializer:json"` // State the request was left in
 	CreatedAt  time.Time              `json:"created_at" gorm:"autoCreateTime"`
 }
 
@@ -265,6 +767,11 @@ func (NFCE) TableName() string {
 	return "nfce_requests"
 }
 
+// TableName specifies the table name for GORM
+func (Event) TableName() string {
+	return "nfce_events"
+}
+
 // Request represents an NFC-e emission request (alias for NFCE for backward compatibility)
 type Request = NFCE
 
@@ -0,0 +1,258 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+var (
+	// ErrInvalidStatusTransition is returned for a status change the NFC-e state machine does not allow
+	ErrInvalidStatusTransition = errors.New("invalid NFC-e status transition")
+	// ErrStatusConflict is returned when the NFC-e changed since it was read, so the status
+	// change was not applied
+	ErrStatusConflict = errors.New("NFC-e status changed concurrently")
+)
+
+// statusTransitions lists the statuses each status may move to. The empty status is the
+// creation of the request. An event that keeps the status is always allowed.
+var statusTransitions = map[RequestStatus][]RequestStatus{
+	"": {RequestStatusPending, RequestStatusQueuedDeferred, RequestStatusRejected},
+	RequestStatusPending: {
+		RequestStatusQueuedDeferred, RequestStatusProcessing, RequestStatusAuthorized, RequestStatusRejected,
+		RequestStatusRetrying, RequestStatusContingency, RequestStatusPendingTransmission,
+	},
+	RequestStatusQueuedDeferred: {
+		RequestStatusPending, RequestStatusProcessing, RequestStatusAuthorized, RequestStatusRejected,
+		RequestStatusRetrying, RequestStatusContingency, RequestStatusPendingTransmission,
+	},
+	RequestStatusProcessing: {
+		RequestStatusAuthorized, RequestStatusRejected, RequestStatusRetrying, RequestStatusContingency,
+		RequestStatusPendingTransmission, RequestStatusCanceled,
+	},
+	RequestStatusRetrying: {RequestStatusProcessing, RequestStatusRejected},
+	RequestStatusContingency: {
+		RequestStatusProcessing, RequestStatusAuthorized, RequestStatusRejected, RequestStatusRetrying,
+		RequestStatusPendingTransmission,
+	},
+	RequestStatusPendingTransmission: {RequestStatusAuthorized, RequestStatusRejected},
+	// Processing locks an authorized NFC-e while its cancellation is sent
+	RequestStatusAuthorized: {RequestStatusProcessing, RequestStatusCanceled},
+	RequestStatusRejected:   {},
+	RequestStatusCanceled:   {},
+}
+
+// CanTransitionTo reports whether the state machine allows the status to move to next
+func (s RequestStatus) CanTransitionTo(next RequestStatus) bool {
+	if s == next {
+		return s != ""
+	}
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition returns ErrInvalidStatusTransition when the status may not move to next
+func (s RequestStatus) ValidateTransition(next RequestStatus) error {
+	if !s.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %q to %q", ErrInvalidStatusTransition, s, next)
+	}
+	return nil
+}
+
+// StatusTransition is the metadata every status-changing event carries under
+// EventMetadataTransition, so the audit trail shows each step of the state machine
+type StatusTransition struct {
+	From     RequestStatus `json:"from"`
+	To       RequestStatus `json:"to"`
+	Sequence int           `json:"sequence"` // Sequence of the event, the version the NFC-e was left at
+}
+
+// EventMetadataTransition is the event metadata key of the StatusTransition
+const EventMetadataTransition = "transition"
+
+// IsTerminal reports whether the status allows no further transition
+func (s RequestStatus) IsTerminal() bool {
+	allowed, known := statusTransitions[s]
+	return known && len(allowed) == 0
+}
+
+// NFCeState holds the fields of an NFC-e set along its status transitions. Each event
+// records the state the request was left in, so the request can be rebuilt from its events.
+// The JSON names are the columns of nfce_requests.
+type NFCeState struct {
+	ChaveAcesso       string           `json:"chave_acesso,omitempty"`
+	Protocolo         string           `json:"protocolo,omitempty"`
+	Numero            string           `json:"numero,omitempty"`
+	Serie             string           `json:"serie,omitempty"`
+	Recibo            string           `json:"recibo,omitempty"`
+	ReciboPolls       int              `json:"recibo_polls,omitempty"`
+	RejectionCode     string           `json:"rejection_code,omitempty"`
+	RejectionMsg      string           `json:"rejection_msg,omitempty"`
+	SchemaErrors      SchemaViolations `json:"schema_errors,omitempty"`
+	CStat             string           `json:"cstat,omitempty"`
+	XMotivo           string           `json:"xmotivo,omitempty"`
+	RetryCount        int              `json:"retry_count,omitempty"`
+	NextRetryAt       *time.Time       `json:"next_retry_at,omitempty"`
+	ProcessedAt       *time.Time       `json:"processed_at,omitempty"`
+	AuthorizedAt      *time.Time       `json:"authorized_at,omitempty"`
+	CancelProtocolo   string           `json:"cancel_protocolo,omitempty"`
+	CanceledAt        *time.Time       `json:"canceled_at,omitempty"`
+	CancelXMLURL      string           `json:"cancel_xml_url,omitempty"`
+	InContingency     bool             `json:"in_contingency,omitempty"`
+	ContingencyType   string           `json:"contingency_type,omitempty"`
+	XMLURL            string           `json:"xml_url,omitempty"`
+	PDFURL            string           `json:"pdf_url,omitempty"`
+	QRCodeURL         string           `json:"qrcode_url,omitempty"`
+	QRCode            string           `json:"qrcode,omitempty"`
+	VTotTrib          money.Amount     `json:"v_tot_trib,omitempty"`
+	Totais            *DocumentTotals  `json:"totais,omitempty"`
+	DocumentsPurgedAt *time.Time       `json:"documents_purged_at,omitempty"`
+}
+
+// State returns the current state of the NFC-e
+func (n *NFCE) State() *NFCeState {
+	return &NFCeState{
+		ChaveAcesso:       n.ChaveAcesso,
+		Protocolo:         n.Protocolo,
+		Numero:            n.Numero,
+		Serie:             n.Serie,
+		Recibo:            n.Recibo,
+		ReciboPolls:       n.ReciboPolls,
+		RejectionCode:     n.RejectionCode,
+		RejectionMsg:      n.RejectionMsg,
+		SchemaErrors:      n.SchemaErrors,
+		CStat:             n.CStat,
+		XMotivo:           n.XMotivo,
+		RetryCount:        n.RetryCount,
+		NextRetryAt:       n.NextRetryAt,
+		ProcessedAt:       n.ProcessedAt,
+		AuthorizedAt:      n.AuthorizedAt,
+		CancelProtocolo:   n.CancelProtocolo,
+		CanceledAt:        n.CanceledAt,
+		CancelXMLURL:      n.CancelXMLURL,
+		InContingency:     n.InContingency,
+		ContingencyType:   n.ContingencyType,
+		XMLURL:            n.XMLURL,
+		PDFURL:            n.PDFURL,
+		QRCodeURL:         n.QRCodeURL,
+		QRCode:            n.QRCode,
+		VTotTrib:          n.VTotTrib,
+		Totais:            n.Totais,
+		DocumentsPurgedAt: n.DocumentsPurgedAt,
+	}
+}
+
+// applyState overwrites the fields of the NFC-e with the state
+func (n *NFCE) applyState(s *NFCeState) {
+	n.ChaveAcesso = s.ChaveAcesso
+	n.Protocolo = s.Protocolo
+	n.Numero = s.Numero
+	n.Serie = s.Serie
+	n.Recibo = s.Recibo
+	n.ReciboPolls = s.ReciboPolls
+	n.RejectionCode = s.RejectionCode
+	n.RejectionMsg = s.RejectionMsg
+	n.SchemaErrors = s.SchemaErrors
+	n.CStat = s.CStat
+	n.XMotivo = s.XMotivo
+	n.RetryCount = s.RetryCount
+	n.NextRetryAt = s.NextRetryAt
+	n.ProcessedAt = s.ProcessedAt
+	n.AuthorizedAt = s.AuthorizedAt
+	n.CancelProtocolo = s.CancelProtocolo
+	n.CanceledAt = s.CanceledAt
+	n.CancelXMLURL = s.CancelXMLURL
+	n.InContingency = s.InContingency
+	n.ContingencyType = s.ContingencyType
+	n.XMLURL = s.XMLURL
+	n.PDFURL = s.PDFURL
+	n.QRCodeURL = s.QRCodeURL
+	n.QRCode = s.QRCode
+	n.VTotTrib = s.VTotTrib
+	n.Totais = s.Totais
+	n.DocumentsPurgedAt = s.DocumentsPurgedAt
+}
+
+// Equal reports whether both states hold the same values. Times are compared at the
+// microsecond precision of the database.
+func (s *NFCeState) Equal(other *NFCeState) bool {
+	a, errA := json.Marshal(s.normalized())
+	b, errB := json.Marshal(other.normalized())
+	return errA == nil && errB == nil && string(a) == string(b)
+}
+
+// normalized returns a copy with the times in UTC truncated to microseconds
+func (s *NFCeState) normalized() NFCeState {
+	out := *s
+	for _, t := range []**time.Time{&out.NextRetryAt, &out.ProcessedAt, &out.AuthorizedAt, &out.CanceledAt, &out.DocumentsPurgedAt} {
+		if *t != nil {
+			normalized := (*t).UTC().Truncate(time.Microsecond)
+			*t = &normalized
+		}
+	}
+	return out
+}
+
+// StatusProjection is the status and state of an NFC-e rebuilt by replaying its events
+type StatusProjection struct {
+	Status  RequestStatus
+	Version int        // Sequence of the last event
+	State   *NFCeState // State recorded by the last event that carries one, nil for older events
+	// Anomalies describe the events that do not start from the status left by the previous
+	// one or that the state machine does not allow; the replay follows them anyway
+	Anomalies []string
+}
+
+// ProjectEvents replays the events of an NFC-e, in sequence order, into its projection.
+// It reports false when there are no events to replay.
+func ProjectEvents(events []*Event) (StatusProjection, bool) {
+	var projection StatusProjection
+	if len(events) == 0 {
+		return projection, false
+	}
+
+	current := events[0].StatusFrom
+	for _, event := range events {
+		switch {
+		case event.StatusFrom != current:
+			projection.Anomalies = append(projection.Anomalies,
+				fmt.Sprintf("event %d starts from %q, the previous event left %q", event.Sequence, event.StatusFrom, current))
+		case !current.CanTransitionTo(event.StatusTo):
+			projection.Anomalies = append(projection.Anomalies,
+				fmt.Sprintf("event %d moves %q to %q, which the state machine does not allow", event.Sequence, current, event.StatusTo))
+		}
+
+		current = event.StatusTo
+		projection.Version = event.Sequence
+		if event.State != nil {
+			projection.State = event.State
+		}
+	}
+	projection.Status = current
+	return projection, true
+}
+
+// Drifted reports whether the NFC-e differs from the projection of its events
+func (n *NFCE) Drifted(projection StatusProjection) bool {
+	if n.Status != projection.Status || n.Version != projection.Version {
+		return true
+	}
+	return projection.State != nil && !n.State().Equal(projection.State)
+}
+
+// ApplyProjection sets the status, the version and, when the events recorded it, the state
+// of the projection on the NFC-e
+func (n *NFCE) ApplyProjection(projection StatusProjection) {
+	n.Status = projection.Status
+	n.Version = projection.Version
+	if projection.State != nil {
+		n.applyState(projection.State)
+	}
+}
@@ -0,0 +1,398 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// Document models issued by the API: the NFC-e of the retail sales and the NF-e
+const (
+	ModeloNFCe = "65"
+	ModeloNFe  = "55"
+)
+
+// IsValidModelo reports whether modelo is a document model issued by the API
+func IsValidModelo(modelo string) bool {
+	return modelo == ModeloNFCe || modelo == ModeloNFe
+}
+
+// Limits of the transp and cobr groups of the NF-e layout
+const (
+	MaxNFeVolumes    = 5000
+	MaxNFeDuplicatas = 120
+)
+
+// Transporte is the transport of the goods of an NF-e (transp)
+type Transporte struct {
+	// Modalidade is the modFrete: 0 hired by the issuer (CIF), 1 by the recipient (FOB),
+	// 2 by third parties, 3 own by the issuer, 4 own by the recipient, 9 no transport
+	Modalidade     string          `json:"modalidade"`
+	Transportadora *Transportadora `json:"transportadora,omitempty"`
+	Volumes        []Volume        `json:"volumes,omitempty"`
+}
+
+// Transportadora identifies the carrier of an NF-e (transporta)
+type Transportadora struct {
+	CNPJ      string `json:"cnpj,omitempty"`
+	CPF       string `json:"cpf,omitempty"`
+	Nome      string `json:"nome,omitempty"`
+	IE        string `json:"ie,omitempty"`
+	Endereco  string `json:"endereco,omitempty"` // xEnder, the full address in one line
+	Municipio string `json:"municipio,omitempty"`
+	UF        string `json:"uf,omitempty"`
+}
+
+// Volume describes the packages carried (vol); weights in kg
+type Volume struct {
+	Quantidade  int64          `json:"quantidade,omitempty"`
+	Especie     string         `json:"especie,omitempty"`
+	Marca       string         `json:"marca,omitempty"`
+	Numeracao   string         `json:"numeracao,omitempty"`
+	PesoLiquido money.Quantity `json:"peso_liquido,omitempty"`
+	PesoBruto   money.Quantity `json:"peso_bruto,omitempty"`
+}
+
+// Cobranca is the billing of an NF-e sold on credit (cobr)
+type Cobranca struct {
+	Fatura     *Fatura     `json:"fatura,omitempty"`
+	Duplicatas []Duplicata `json:"duplicatas,omitempty"`
+}
+
+// Fatura is the invoice of the billing (fat)
+type Fatura struct {
+	Numero        string       `json:"numero"`
+	ValorOriginal money.Amount `json:"valor_original"`
+	Desconto      money.Amount `json:"desconto,omitempty"`
+	ValorLiquido  money.Amount `json:"valor_liquido"`
+}
+
+// Duplicata is an installment of the billing (dup)
+type Duplicata struct {
+	Numero     string       `json:"numero,omitempty"` // nDup; 001, 002... by position when empty
+	Vencimento string       `json:"vencimento"`       // YYYY-MM-DD
+	Valor      money.Amount `json:"valor"`
+}
+
+// NumeroOf returns the nDup of the installment at position i, the informed one or its
+// three-digit sequence
+func (d Duplicata) NumeroOf(i int) string {
+	if d.Numero != "" {
+		return d.Numero
+	}
+	return fmt.Sprintf("%03d", i+1)
+}
+
+// ItemIPI is the IPI of an NF-e item, levied on the value of the operation of the item
+type ItemIPI struct {
+	CST           string  `json:"cst"`
+	Enquadramento string  `json:"enquadramento,omitempty"` // cEnq, 999 (tributação normal) when empty
+	Aliquota      float64 `json:"aliquota,omitempty"`      // Percentage of the taxed CSTs
+}
+
+// Taxed reports whether the CST levies the IPI (IPITrib) rather than leaving it untaxed (IPINT)
+func (i *ItemIPI) Taxed() bool {
+	switch i.CST {
+	case "00", "49", "50", "99":
+		return true
+	}
+	return false
+}
+
+// Value returns the IPI of the item over the value of its operation; zero when untaxed
+func (i *ItemIPI) Value(base money.Amount) money.Amount {
+	if i == nil || !i.Taxed() {
+		return 0
+	}
+	return base.Percent(i.Aliquota)
+}
+
+// ItemII is the import tax of an NF-e item, with the values of the import declaration
+type ItemII struct {
+	BaseCalculo        money.Amount `json:"base_calculo"`
+	DespesasAduaneiras money.Amount `json:"despesas_aduaneiras,omitempty"`
+	Valor              money.Amount `json:"valor"`
+	IOF                money.Amount `json:"iof,omitempty"`
+}
+
+// DocumentTotals are the tax totals of the ICMSTot fixed when the XML is built, printed by
+// the DANFE of the NF-e
+type DocumentTotals struct {
+	VBC   money.Amount `json:"v_bc"`
+	VICMS money.Amount `json:"v_icms"`
+	VBCST money.Amount `json:"v_bc_st"`
+	VST   money.Amount `json:"v_st"`
+	VIPI  money.Amount `json:"v_ipi"`
+	VII   money.Amount `json:"v_ii"`
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (t DocumentTotals) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (t *DocumentTotals) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("DocumentTotals.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, t)
+}
+
+// DocumentModel returns the model of the document, the NFC-e when none was chosen
+func (p EmitPayload) DocumentModel() string {
+	if p.Modelo == "" {
+		return ModeloNFCe
+	}
+	return p.Modelo
+}
+
+// IsNFe reports whether the payload is an NF-e (modelo 55)
+func (p EmitPayload) IsNFe() bool {
+	return p.DocumentModel() == ModeloNFe
+}
+
+// OperationValues returns the value of the operation of each item (vProd - vDesc + vFrete +
+// vOutro), the base the taxes of the item are levied on
+func (p EmitPayload) OperationValues() []money.Amount {
+	fretes := p.FreightShares()
+	values := make([]money.Amount, len(p.Itens))
+	for i, item := range p.Itens {
+		values[i] = item.Gross() - item.Desconto + fretes[i] + item.Outros
+	}
+	return values
+}
+
+// importTaxes returns the IPI and II of the items, which the NF-e adds to vNF
+func (p EmitPayload) importTaxes() money.Amount {
+	var total money.Amount
+	var values []money.Amount
+	for i, item := range p.Itens {
+		if item.II != nil {
+			total += item.II.Valor
+		}
+		if item.IPI == nil {
+			continue
+		}
+		if values == nil {
+			values = p.OperationValues()
+		}
+		total += item.IPI.Value(values[i])
+	}
+	return total
+}
+
+var (
+	nfeCFOPPattern = regexp.MustCompile(`^[123567]\d{3}$`)
+	ufPattern      = regexp.MustCompile(`^[A-Z]{2}$`)
+	cepPattern     = regexp.MustCompile(`^\d{8}$`)
+	cMunPattern    = regexp.MustCompile(`^\d{7}$`)
+	ieDigitsOnly   = regexp.MustCompile(`^\d{2,14}$`)
+)
+
+// ValidateModel checks the groups of the document model: the NFC-e carries none of the NF-e
+// groups, and the NF-e identifies the recipient with its address and keeps the direction
+// and destination of the operation consistent across the items
+func (p EmitPayload) ValidateModel() error {
+	if !IsValidModelo(p.DocumentModel()) {
+		return fmt.Errorf("modelo deve ser %s (NFC-e) ou %s (NF-e)", ModeloNFCe, ModeloNFe)
+	}
+	if len([]rune(p.NaturezaOperacao)) > 60 {
+		return errors.New("natureza_operacao deve ter até 60 caracteres")
+	}
+
+	if !p.IsNFe() {
+		if p.Transporte != nil || p.Cobranca != nil {
+			return errors.New("transporte e cobranca só são permitidos na NF-e (modelo 55)")
+		}
+		for i, item := range p.Itens {
+			if item.IPI != nil || item.II != nil {
+				return fmt.Errorf("item %d: ipi e ii só são permitidos na NF-e (modelo 55)", i+1)
+			}
+		}
+		return nil
+	}
+
+	return p.validateNFe()
+}
+
+// validateNFe checks the recipient, items, transport and billing of an NF-e
+func (p EmitPayload) validateNFe() error {
+	dest := p.Destinatario
+	if dest == nil || strings.TrimSpace(dest.Nome) == "" || dest.Endereco == nil {
+		return errors.New("NF-e requer destinatário com nome e endereço")
+	}
+	if err := dest.validateAddress(); err != nil {
+		return err
+	}
+	if dest.IE != "" && dest.IE != IsentoIE && !ieDigitsOnly.MatchString(dest.IE) {
+		return errors.New("IE do destinatário deve ter apenas dígitos ou ser ISENTO")
+	}
+
+	// Every item must share the direction (entrada/saída) and the destination of the operation
+	var direction byte
+	for i, item := range p.Itens {
+		if !nfeCFOPPattern.MatchString(item.CFOP) {
+			return fmt.Errorf("item %d: CFOP %s inválido na NF-e", i+1, item.CFOP)
+		}
+		group := item.CFOP[0]
+		if direction == 0 {
+			direction = group
+		} else if group != direction {
+			return fmt.Errorf("item %d: CFOP %s difere da operação dos demais itens (%cxxx)", i+1, item.CFOP, direction)
+		}
+
+		if item.II != nil && group != '3' {
+			return fmt.Errorf("item %d: ii só é permitido em entrada de importação (CFOP 3xxx)", i+1)
+		}
+		if item.IPI != nil {
+			if len(item.IPI.CST) != 2 {
+				return fmt.Errorf("item %d: CST do IPI deve ter 2 dígitos", i+1)
+			}
+			if item.IPI.Aliquota < 0 || item.IPI.Aliquota > 100 {
+				return fmt.Errorf("item %d: alíquota do IPI deve estar entre 0 e 100", i+1)
+			}
+		}
+		if ii := item.II; ii != nil && (ii.BaseCalculo < 0 || ii.DespesasAduaneiras < 0 || ii.Valor < 0 || ii.IOF < 0) {
+			return fmt.Errorf("item %d: valores do ii não podem ser negativos", i+1)
+		}
+	}
+
+	switch direction {
+	case '1', '5':
+		if dest.Endereco.UF != p.UF {
+			return fmt.Errorf("CFOP %cxxx é de operação interna, mas o destinatário está em %s", direction, dest.Endereco.UF)
+		}
+	case '2', '6':
+		if dest.Endereco.UF == p.UF || dest.Endereco.UF == ExteriorUF {
+			return fmt.Errorf("CFOP %cxxx é de operação interestadual, mas o destinatário está em %s", direction, dest.Endereco.UF)
+		}
+	case '3', '7':
+		if dest.Endereco.UF != ExteriorUF {
+			return fmt.Errorf("CFOP %cxxx é de operação com o exterior, o destinatário deve ter UF %s", direction, ExteriorUF)
+		}
+	}
+
+	if err := p.Transporte.validate(); err != nil {
+		return err
+	}
+	return p.Cobranca.validate(time.Now())
+}
+
+// ExteriorUF is the UF of addresses abroad, with the municipality ExteriorCMun
+const (
+	ExteriorUF   = "EX"
+	ExteriorCMun = "9999999"
+)
+
+// IsentoIE is the IE of recipients exempt from the state registration
+const IsentoIE = "ISENTO"
+
+// validateAddress checks the address of an NF-e recipient
+func (d *Destinatario) validateAddress() error {
+	address := d.Endereco
+	if address.Logradouro == "" || address.Numero == "" || address.Bairro == "" || address.Municipio == "" {
+		return errors.New("endereço do destinatário requer logradouro, numero, bairro e municipio")
+	}
+	if !ufPattern.MatchString(address.UF) {
+		return errors.New("UF do destinatário inválida")
+	}
+	if address.UF == ExteriorUF {
+		return nil
+	}
+	if !cMunPattern.MatchString(address.CodigoMunicipio) {
+		return errors.New("codigo_municipio do destinatário deve ter 7 dígitos (IBGE)")
+	}
+	if !cepPattern.MatchString(address.CEP) {
+		return errors.New("CEP do destinatário deve ter 8 dígitos, sem traço")
+	}
+	return nil
+}
+
+// validate checks the modality, carrier and volumes of the transport; nil has no transport
+func (t *Transporte) validate() error {
+	if t == nil {
+		return nil
+	}
+
+	switch t.Modalidade {
+	case "0", "1", "2", "3", "4", "9":
+	default:
+		return errors.New("transporte.modalidade deve ser 0, 1, 2, 3, 4 ou 9")
+	}
+
+	if carrier := t.Transportadora; carrier != nil {
+		switch {
+		case carrier.CNPJ != "" && carrier.CPF != "":
+			return errors.New("transportadora deve ter cnpj ou cpf, não ambos")
+		case carrier.CNPJ != "":
+			if err := validateCNPJ(carrier.CNPJ); err != nil {
+				return fmt.Errorf("transportadora: %w", err)
+			}
+		case carrier.CPF != "":
+			if err := validateCPF(carrier.CPF); err != nil {
+				return fmt.Errorf("transportadora: %w", err)
+			}
+		}
+		if carrier.UF != "" && !ufPattern.MatchString(carrier.UF) {
+			return errors.New("UF da transportadora inválida")
+		}
+	}
+
+	if len(t.Volumes) > MaxNFeVolumes {
+		return fmt.Errorf("transporte permite até %d volumes", MaxNFeVolumes)
+	}
+	for i, volume := range t.Volumes {
+		if volume.Quantidade < 0 || volume.PesoLiquido < 0 || volume.PesoBruto < 0 {
+			return fmt.Errorf("volume %d: quantidade e pesos não podem ser negativos", i+1)
+		}
+	}
+	return nil
+}
+
+// validate checks the invoice and the installments of the billing against the issue date:
+// SEFAZ rejects installments due before the issue or before the previous one
+func (c *Cobranca) validate(now time.Time) error {
+	if c == nil {
+		return nil
+	}
+
+	if fatura := c.Fatura; fatura != nil {
+		if fatura.ValorOriginal < 0 || fatura.Desconto < 0 || fatura.ValorLiquido < 0 {
+			return errors.New("valores da fatura não podem ser negativos")
+		}
+		if fatura.ValorOriginal-fatura.Desconto != fatura.ValorLiquido {
+			return errors.New("valor líquido da fatura deve ser o valor original menos o desconto")
+		}
+	}
+
+	if len(c.Duplicatas) > MaxNFeDuplicatas {
+		return fmt.Errorf("cobrança permite até %d duplicatas", MaxNFeDuplicatas)
+	}
+	previous := now.Format("2006-01-02")
+	for i, duplicata := range c.Duplicatas {
+		if _, err := time.Parse("2006-01-02", duplicata.Vencimento); err != nil {
+			return fmt.Errorf("duplicata %d: vencimento deve estar no formato AAAA-MM-DD", i+1)
+		}
+		if duplicata.Vencimento < previous {
+			return fmt.Errorf("duplicata %d: vencimento anterior à emissão ou à duplicata anterior", i+1)
+		}
+		if duplicata.Valor <= 0 {
+			return fmt.Errorf("duplicata %d: valor deve ser positivo", i+1)
+		}
+		previous = duplicata.Vencimento
+	}
+	return nil
+}
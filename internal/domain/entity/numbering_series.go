@@ -0,0 +1,125 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultNFCeSerie is the series a company emits on until it registers its own
+	DefaultNFCeSerie = 1
+	// MaxNFCeNumber is the highest nNF allowed by the NFC-e layout
+	MaxNFCeNumber = 999999999
+)
+
+var (
+	// ErrNumberingSeriesNotFound is returned when the series is not registered for the company and environment
+	ErrNumberingSeriesNotFound = errors.New("numbering series not found")
+	// ErrNumberingSeriesInactive is returned when allocating a number from a deactivated series
+	ErrNumberingSeriesInactive = errors.New("numbering series is inactive")
+	// ErrNumberingSeriesExhausted is returned when the series reached MaxNFCeNumber
+	ErrNumberingSeriesExhausted = errors.New("numbering series exhausted")
+)
+
+// NumberingSeries is a series of a company for one document model and environment and the next
+// number it hands out
+type NumberingSeries struct {
+	ID         string    `json:"id"`
+	CompanyID  string    `json:"company_id"`
+	Modelo     string    `json:"modelo"` // 65 (NFC-e) or 55 (NF-e), numbered apart
+	Serie      int       `json:"serie"`
+	Ambiente   string    `json:"ambiente"` // producao | homologacao | simulado
+	NextNumber int64     `json:"next_number"`
+	Default    bool      `json:"default" gorm:"column:is_default"` // Used when the emission does not choose a series
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NumberingGap is a range of allocated numbers of a series held by no NFC-e nor inutilização,
+// which must be inutilized at SEFAZ
+type NumberingGap struct {
+	Modelo        string `json:"modelo"`
+	Serie         int    `json:"serie"`
+	Ambiente      string `json:"ambiente"`
+	NumeroInicial int64  `json:"numero_inicial"`
+	NumeroFinal   int64  `json:"numero_final"`
+}
+
+// NewNumberingSeries creates an active series starting at nextNumber (1 when zero)
+func NewNumberingSeries(companyID, modelo string, serie int, ambiente string, nextNumber int64) (*NumberingSeries, error) {
+	if companyID == "" {
+		return nil, errors.New("company ID é obrigatório")
+	}
+
+	if !IsValidModelo(modelo) {
+		return nil, errors.New("modelo deve ser 65 (NFC-e) ou 55 (NF-e)")
+	}
+
+	if serie < 0 || serie > 999 {
+		return nil, errors.New("série deve estar entre 0 e 999")
+	}
+
+	if ambiente != "producao" && ambiente != "homologacao" && ambiente != "simulado" {
+		return nil, errors.New("ambiente deve ser producao, homologacao ou simulado")
+	}
+
+	if nextNumber == 0 {
+		nextNumber = 1
+	}
+	if nextNumber < 1 || nextNumber > MaxNFCeNumber {
+		return nil, errors.New("próximo número deve estar entre 1 e 999999999")
+	}
+
+	now := time.Now()
+	return &NumberingSeries{
+		ID:         uuid.New().String(),
+		CompanyID:  companyID,
+		Modelo:     modelo,
+		Serie:      serie,
+		Ambiente:   ambiente,
+		NextNumber: nextNumber,
+		Active:     true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, nil
+}
+
+// SetNextNumber advances the series; numbers are never reused, so it cannot go back.
+// The skipped numbers show up as gaps to be inutilized.
+func (s *NumberingSeries) SetNextNumber(nextNumber int64) error {
+	if nextNumber < s.NextNumber {
+		return errors.New("próximo número não pode ser menor que o atual")
+	}
+	if nextNumber > MaxNFCeNumber {
+		return errors.New("próximo número deve estar entre 1 e 999999999")
+	}
+
+	s.NextNumber = nextNumber
+	s.UpdatedAt = time.Now()
+	return nil
+}
+
+// Deactivate stops the series from handing out numbers; the default series cannot be deactivated
+func (s *NumberingSeries) Deactivate() error {
+	if s.Default {
+		return errors.New("defina outra série padrão antes de desativar esta")
+	}
+
+	s.Active = false
+	s.UpdatedAt = time.Now()
+	return nil
+}
+
+// Activate lets the series hand out numbers again
+func (s *NumberingSeries) Activate() {
+	s.Active = true
+	s.UpdatedAt = time.Now()
+}
+
+// TableName specifies the table name for GORM
+func (NumberingSeries) TableName() string {
+	return "nfce_series"
+}
@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxTopicEmit is the topic of the NFC-e emission messages, published to the nfce.emit queue
+const OutboxTopicEmit = "nfce.emit"
+
+// OutboxTopicLote is the topic of the lote emission messages, published to the nfce.lote queue
+const OutboxTopicLote = "nfce.lote"
+
+const (
+	// outboxRetryMinDelay is the wait before publishing a message again after the first failure
+	outboxRetryMinDelay = time.Second
+	// outboxRetryMaxDelay caps the exponential backoff between publish attempts
+	outboxRetryMaxDelay = 5 * time.Minute
+)
+
+// OutboxMessage is a message to the queue written in the same transaction as the change it
+// announces. The worker relays the pending messages to the broker and marks them sent, so a
+// committed change is published even when the broker was down at the time.
+type OutboxMessage struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:uuid"`
+	Topic       string     `json:"topic" gorm:"not null"`
+	AggregateID string     `json:"aggregate_id" gorm:"type:uuid;not null"` // Record the message is about, e.g. the NFC-e request
+	Payload     string     `json:"payload" gorm:"type:jsonb;not null"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	AvailableAt time.Time  `json:"available_at"` // Not relayed before this time
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// NewOutboxMessage creates a pending message of topic carrying payload as JSON
+func NewOutboxMessage(topic, aggregateID string, payload any) (*OutboxMessage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	now := time.Now()
+	return &OutboxMessage{
+		ID:          uuid.New().String(),
+		Topic:       topic,
+		AggregateID: aggregateID,
+		Payload:     string(body),
+		AvailableAt: now,
+		CreatedAt:   now,
+	}, nil
+}
+
+// NextAttemptAt returns when a message that just failed to publish is relayed again
+func (m *OutboxMessage) NextAttemptAt(now time.Time) time.Time {
+	delay := outboxRetryMinDelay
+	for i := 0; i < m.Attempts && delay < outboxRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return now.Add(min(delay, outboxRetryMaxDelay))
+}
+
+// TableName specifies the table name for GORM
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
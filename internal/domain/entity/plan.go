@@ -45,14 +45,16 @@ const (
 
 // PlanFeatures represents the features included in a plan
 type PlanFeatures struct {
-	MaxNFCePerMonth    int  `json:"max_nfce_per_month,omitempty"` // 0 = unlimited
-	MaxNFCeTotal       int  `json:"max_nfce_total,omitempty"`     // 0 = unlimited
+	MaxNFCePerMonth    int  `json:"max_nfce_per_month,omitempty" gorm:"column:max_nfce_per_month"` // 0 = unlimited
+	MaxNFCeTotal       int  `json:"max_nfce_total,omitempty" gorm:"column:max_nfce_total"`         // 0 = unlimited
 	AllowContingency   bool `json:"allow_contingency"`
 	AllowCancellation  bool `json:"allow_cancellation"`
 	AllowInutilization bool `json:"allow_inutilization"`
 	WebhookSupport     bool `json:"webhook_support"`
 	PrioritySupport    bool `json:"priority_support"`
-	StorageDays        int  `json:"storage_days"` // Days to keep XML/PDF
+	StorageDays        int  `json:"storage_days"`                   // Days to keep XML/PDF
+	RequestsPerMinute  int  `json:"requests_per_minute,omitempty"`  // API requests, 0 = default of the deployment
+	EmissionsPerMinute int  `json:"emissions_per_minute,omitempty"` // NFC-e emission requests, 0 = default of the deployment
 }
 
 // Plan represents a subscription plan
@@ -70,11 +72,11 @@ type Plan struct {
 
 	// Quotas
 	QuotaType       QuotaType `json:"quota_type"`
-	MaxNFCePerMonth int       `json:"max_nfce_per_month,omitempty"` // For monthly quotas
-	MaxNFCeTotal    int       `json:"max_nfce_total,omitempty"`     // For package quotas
+	MaxNFCePerMonth int       `json:"max_nfce_per_month,omitempty" gorm:"column:max_nfce_per_month"` // For monthly quotas
+	MaxNFCeTotal    int       `json:"max_nfce_total,omitempty" gorm:"column:max_nfce_total"`         // For package quotas
 
 	// Features
-	Features PlanFeatures `json:"features"`
+	Features PlanFeatures `json:"features" gorm:"embedded;embeddedPrefix:features_"`
 
 	// Metadata
 	IsPopular bool      `json:"is_popular,omitempty"` // Highlight in UI
@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SEFAZ services whose exchanges are archived
+const (
+	SEFAZServiceAutorizacao     = "autorizacao"      // NFeAutorizacao4 with a single NFC-e
+	SEFAZServiceAutorizacaoLote = "autorizacao_lote" // NFeAutorizacao4 with a packed lote
+)
+
+// SEFAZExchange is a SOAP request sent to SEFAZ for an NFC-e and the response it got, kept
+// gzip-compressed in the storage for troubleshooting and disputes with SEFAZ. The NFC-e of a
+// lote each have an exchange pointing to the same files.
+type SEFAZExchange struct {
+	ID        string `json:"id"`
+	RequestID string `json:"request_id"`
+	CompanyID string `json:"company_id"`
+	Service   string `json:"service"`
+	UF        string `json:"uf" gorm:"column:uf"`
+	Ambiente  string `json:"ambiente"`
+	CStat     string `json:"cstat,omitempty" gorm:"column:cstat"`
+	Error     string `json:"error,omitempty"` // Failure of the call when SEFAZ did not answer
+
+	RequestKey   string `json:"request_key"`
+	ResponseKey  string `json:"response_key,omitempty"` // Empty when SEFAZ did not answer
+	RequestSize  int64  `json:"request_size"`           // Uncompressed bytes
+	ResponseSize int64  `json:"response_size"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewSEFAZExchange creates the exchange of a call to the SEFAZ service for the NFC-e
+func NewSEFAZExchange(nfce *NFCE, service string, now time.Time) *SEFAZExchange {
+	return &SEFAZExchange{
+		ID:        uuid.New().String(),
+		RequestID: nfce.ID,
+		CompanyID: nfce.CompanyID,
+		Service:   service,
+		UF:        nfce.Payload.UF,
+		Ambiente:  nfce.Payload.Ambiente,
+		CreatedAt: now,
+	}
+}
+
+// TableName specifies the table name for GORM
+func (SEFAZExchange) TableName() string {
+	return "sefaz_exchanges"
+}
+
+// TakeSEFAZExchanges returns the IDs of the exchanges archived since the last call and clears
+// them, so each one is linked from a single event
+func (n *NFCE) TakeSEFAZExchanges() []string {
+	ids := n.SEFAZExchanges
+	n.SEFAZExchanges = nil
+	return ids
+}
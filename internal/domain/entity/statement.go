@@ -0,0 +1,150 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StatementPeriodLayout formats the month covered by a usage statement, e.g. "2026-09"
+const StatementPeriodLayout = "2006-01"
+
+// NFCeUsageCount is the number of NFC-e of a company in one status and environment
+type NFCeUsageCount struct {
+	Status   RequestStatus `json:"status"`
+	Ambiente string        `json:"ambiente"`
+	Count    int           `json:"count"`
+}
+
+// StatementNFCe summarizes the NFC-e of the period and the plan quota they were billed against
+type StatementNFCe struct {
+	Total      int            `json:"total"`
+	ByStatus   map[string]int `json:"by_status"`
+	ByAmbiente map[string]int `json:"by_ambiente"`
+	Emitted    int            `json:"emitted"` // Authorized or canceled in produção, the billable notes
+	Quota      int            `json:"quota"`   // -1 = unlimited
+	Overage    int            `json:"overage"` // Emitted beyond the monthly quota
+}
+
+// WebhookDeliveryStats counts the webhook deliveries of a company in a period
+type WebhookDeliveryStats struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// StorageUsage is the storage consumed by a company when the statement was generated
+type StorageUsage struct {
+	Objects int   `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// StatementPlanChange is a plan change of the subscription within the period
+type StatementPlanChange struct {
+	ChangedAt    time.Time `json:"changed_at"`
+	FromPlanID   string    `json:"from_plan_id"`
+	FromPlanName string    `json:"from_plan_name,omitempty"`
+	ToPlanID     string    `json:"to_plan_id"`
+	ToPlanName   string    `json:"to_plan_name,omitempty"`
+	Proration    float64   `json:"proration"` // Charged (positive) or credited (negative) on the next invoice
+}
+
+// StatementSummary is the content of a usage statement
+type StatementSummary struct {
+	PlanID      string                `json:"plan_id,omitempty"` // Plan at the end of the period
+	PlanName    string                `json:"plan_name,omitempty"`
+	PlanChanges []StatementPlanChange `json:"plan_changes,omitempty"`
+	NFCe        StatementNFCe         `json:"nfce"`
+	Webhooks    WebhookDeliveryStats  `json:"webhooks"`
+	Storage     StorageUsage          `json:"storage"`
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (s StatementSummary) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (s *StatementSummary) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("StatementSummary.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, s)
+}
+
+// UsageStatement is the end-of-month usage statement of a company, stored as PDF and JSON
+type UsageStatement struct {
+	ID          string           `json:"id"`
+	CompanyID   string           `json:"company_id"`
+	Period      string           `json:"period"` // StatementPeriodLayout
+	PeriodStart time.Time        `json:"period_start"`
+	PeriodEnd   time.Time        `json:"period_end"`
+	Summary     StatementSummary `json:"summary" gorm:"type:jsonb"`
+	JSONKey     string           `json:"json_key"` // Storage key of the JSON statement
+	PDFKey      string           `json:"pdf_key"`  // Storage key of the PDF statement
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// NewUsageStatement creates the statement of the month starting at periodStart
+func NewUsageStatement(companyID string, periodStart time.Time) (*UsageStatement, error) {
+	if companyID == "" {
+		return nil, errors.New("company ID é obrigatório")
+	}
+	if periodStart.IsZero() {
+		return nil, errors.New("período do extrato é obrigatório")
+	}
+
+	return &UsageStatement{
+		ID:          uuid.New().String(),
+		CompanyID:   companyID,
+		Period:      periodStart.Format(StatementPeriodLayout),
+		PeriodStart: periodStart,
+		PeriodEnd:   periodStart.AddDate(0, 1, 0),
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// SummarizeNFCe fills the NFC-e summary from the period counts, computing the overage
+// of the billable notes over a monthly plan quota
+func (s *UsageStatement) SummarizeNFCe(counts []NFCeUsageCount, plan *Plan) {
+	nfce := StatementNFCe{
+		ByStatus:   make(map[string]int),
+		ByAmbiente: make(map[string]int),
+		Quota:      -1,
+	}
+	for _, count := range counts {
+		nfce.Total += count.Count
+		nfce.ByStatus[string(count.Status)] += count.Count
+		nfce.ByAmbiente[count.Ambiente] += count.Count
+		if count.Ambiente == "producao" && (count.Status == RequestStatusAuthorized || count.Status == RequestStatusCanceled) {
+			nfce.Emitted += count.Count
+		}
+	}
+
+	if plan != nil {
+		s.Summary.PlanID = plan.ID
+		s.Summary.PlanName = plan.Name
+		if plan.QuotaType == QuotaTypeMonthly {
+			if limit, limited := plan.GetMaxNFCe(); limited {
+				nfce.Quota = limit
+				nfce.Overage = max(nfce.Emitted-limit, 0)
+			}
+		}
+	}
+
+	s.Summary.NFCe = nfce
+}
+
+// TableName specifies the table name for GORM
+func (UsageStatement) TableName() string {
+	return "usage_statements"
+}
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// RejectionStat is the number of NFC-e rejected with one cStat, with its most frequent xMotivo
+type RejectionStat struct {
+	CStat   string `json:"cstat"`
+	XMotivo string `json:"xmotivo"`
+	Count   int    `json:"count"`
+}
+
+// UFLatency is the time SEFAZ of a UF took to authorize the NFC-e, from intake to authorization
+type UFLatency struct {
+	UF    string  `json:"uf"`
+	Count int     `json:"count"`
+	P50Ms float64 `json:"p50_ms"`
+	P90Ms float64 `json:"p90_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// DailyStats counts the NFC-e created on one day (Brasília time)
+type DailyStats struct {
+	Date       time.Time    `json:"date"`
+	Total      int          `json:"total"`
+	Authorized int          `json:"authorized"`
+	Rejected   int          `json:"rejected"`
+	Canceled   int          `json:"canceled"`
+	Valor      money.Amount `json:"valor"` // vNF of the authorized NFC-e
+}
+
+// CompanyStats counts the NFC-e of one company; Billable are the authorized or canceled notes
+// of the production environment, as the usage statements bill them
+type CompanyStats struct {
+	CompanyID   string       `json:"company_id"`
+	CNPJ        string       `json:"cnpj"`
+	RazaoSocial string       `json:"razao_social"`
+	Total       int          `json:"total"`
+	Authorized  int          `json:"authorized"`
+	Rejected    int          `json:"rejected"`
+	Canceled    int          `json:"canceled"`
+	Billable    int          `json:"billable"`
+	Valor       money.Amount `json:"valor"` // vNF of the authorized NFC-e
+}
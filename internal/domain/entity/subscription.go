@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
 )
 
 // SubscriptionStatus represents the status of a subscription
@@ -20,20 +21,21 @@ const (
 
 // UsageStats tracks the usage of NFC-e within a billing period
 type UsageStats struct {
-	PeriodStart   time.Time  `json:"period_start"`
-	PeriodEnd     time.Time  `json:"period_end"`
-	NFCeIssued    int        `json:"nfce_issued"`
-	NFCeRemaining int        `json:"nfce_remaining"` // -1 = unlimited
-	LastNFCeAt    *time.Time `json:"last_nfce_at,omitempty"`
+	PeriodStart   time.Time  `json:"period_start" gorm:"column:period_start"`
+	PeriodEnd     time.Time  `json:"period_end" gorm:"column:period_end"`
+	NFCeIssued    int        `json:"nfce_issued" gorm:"column:nfce_issued"`
+	NFCeRemaining int        `json:"nfce_remaining" gorm:"column:nfce_remaining"` // -1 = unlimited
+	LastNFCeAt    *time.Time `json:"last_nfce_at,omitempty" gorm:"column:last_nfce_at"`
 }
 
 // BillingInfo contains billing-related information
 type BillingInfo struct {
-	NextBillingAt time.Time  `json:"next_billing_at"`
-	LastBilledAt  *time.Time `json:"last_billed_at,omitempty"`
-	Amount        float64    `json:"amount"`
-	Currency      string     `json:"currency"`
-	PaymentMethod string     `json:"payment_method,omitempty"`
+	NextBillingAt time.Time  `json:"next_billing_at" gorm:"column:next_billing_at"`
+	LastBilledAt  *time.Time `json:"last_billed_at,omitempty" gorm:"column:last_billed_at"`
+	Amount        float64    `json:"amount" gorm:"column:amount"`
+	Currency      string     `json:"currency" gorm:"column:currency"`
+	PaymentMethod string     `json:"payment_method,omitempty" gorm:"column:payment_method"` // Card or payment method ID on the gateway
+	CustomerID    string     `json:"customer_id,omitempty" gorm:"column:customer_id"`       // Customer ID on the gateway
 }
 
 // Subscription represents a company's subscription to a plan
@@ -54,8 +56,8 @@ type Subscription struct {
 	TrialEndsAt *time.Time `json:"trial_ends_at,omitempty"`
 
 	// Usage and quotas
-	CurrentUsage UsageStats  `json:"current_usage"`
-	BillingInfo  BillingInfo `json:"billing_info"`
+	CurrentUsage UsageStats  `json:"current_usage" gorm:"embedded;embeddedPrefix:usage_"`
+	BillingInfo  BillingInfo `json:"billing_info" gorm:"embedded;embeddedPrefix:billing_"`
 
 	// Metadata
 	AutoRenew    bool      `json:"auto_renew"`
@@ -64,8 +66,8 @@ type Subscription struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 
 	// References (populated when needed)
-	Company *Company `json:"company,omitempty"`
-	Plan    *Plan    `json:"plan,omitempty"`
+	Company *Company `json:"company,omitempty" gorm:"-"`
+	Plan    *Plan    `json:"plan,omitempty" gorm:"-"`
 }
 
 // NewSubscription creates a new subscription
@@ -111,6 +113,7 @@ func NewSubscription(companyID, planID string, plan *Plan) (*Subscription, error
 	// Handle trial period if applicable
 	if plan.TrialDays > 0 {
 		subscription.Status = SubscriptionStatusTrial
+		subscription.IsTrial = true
 		trialEnd := now.AddDate(0, 0, plan.TrialDays)
 		subscription.TrialEndsAt = &trialEnd
 		subscription.BillingInfo.NextBillingAt = trialEnd
@@ -121,47 +124,76 @@ func NewSubscription(companyID, planID string, plan *Plan) (*Subscription, error
 
 // CanIssueNFCe checks if the subscription allows issuing another NFC-e
 func (s *Subscription) CanIssueNFCe() (bool, string) {
+	return s.CanIssueNFCes(1)
+}
+
+// CanIssueNFCes checks if the subscription allows issuing count more NFC-e, e.g. the
+// NFC-e of a lote. Plan must be loaded to check an ended period against the plan quota.
+func (s *Subscription) CanIssueNFCes(count int) (bool, string) {
 	// Check subscription status
 	if s.Status == SubscriptionStatusSuspended || s.Status == SubscriptionStatusCanceled || s.Status == SubscriptionStatusExpired {
 		return false, "assinatura não está ativa"
 	}
 
 	// Check trial expiration
-	if s.IsTrial && s.TrialEndsAt != nil && time.Now().After(*s.TrialEndsAt) {
+	if s.TrialExpired() {
 		return false, "período de teste expirou"
 	}
 
 	// Check quota
-	if s.CurrentUsage.NFCeRemaining == 0 {
+	if !s.QuotaCovers(count) {
 		return false, "cota de NFC-e esgotada para o período"
 	}
 
 	return true, ""
 }
 
-// RecordNFCeUsage records the usage of one NFC-e
-func (s *Subscription) RecordNFCeUsage() error {
+// TrialExpired returns true if the subscription is a trial past its end
+func (s *Subscription) TrialExpired() bool {
+	return (s.IsTrial || s.Status == SubscriptionStatusTrial) && s.TrialEndsAt != nil && time.Now().After(*s.TrialEndsAt)
+}
+
+// QuotaExhausted returns true if no NFC-e is left in the current period; an ended
+// period is renewed on the next recorded NFC-e
+func (s *Subscription) QuotaExhausted() bool {
+	return s.CurrentUsage.NFCeRemaining == 0 && !s.needsPeriodReset(time.Now())
+}
+
+// QuotaCovers reports whether count more NFC-e fit in the quota of the period. An ended
+// period is renewed on the next recorded NFC-e, so the plan quota applies when Plan is loaded.
+func (s *Subscription) QuotaCovers(count int) bool {
+	if s.needsPeriodReset(time.Now()) {
+		if s.Plan == nil {
+			return true
+		}
+		limit, limited := s.Plan.GetMaxNFCe()
+		return !limited || limit >= count
+	}
+	remaining := s.CurrentUsage.NFCeRemaining
+	return remaining < 0 || remaining >= count // -1 = unlimited
+}
+
+// RecordNFCeUsage records the usage of one authorized NFC-e and reports whether it
+// exhausted the quota. The note is already authorized at SEFAZ, so it is counted even
+// when concurrent emissions overran the quota. Plan must be loaded to renew the period.
+func (s *Subscription) RecordNFCeUsage() (exhausted bool) {
 	now := time.Now()
 
 	// Check if period has changed (for monthly plans)
-	if s.needsPeriodReset(now) {
+	if s.needsPeriodReset(now) && s.Plan != nil {
 		s.resetUsagePeriod(now)
 	}
 
-	// Check quota
-	if s.CurrentUsage.NFCeRemaining == 0 {
-		return errors.New("cota de NFC-e esgotada para o período")
-	}
-
 	// Record usage
 	s.CurrentUsage.NFCeIssued++
 	if s.CurrentUsage.NFCeRemaining > 0 {
 		s.CurrentUsage.NFCeRemaining--
+		exhausted = s.CurrentUsage.NFCeRemaining == 0
 	}
 	s.CurrentUsage.LastNFCeAt = &now
 	s.UpdatedAt = now
 
-	return nil
+	return exhausted
 }
 
 // GetUsagePercentage returns the usage percentage (0-100)
@@ -208,6 +240,178 @@ func (s *Subscription) Reactivate() error {
 	return nil
 }
 
+// Reasons of the subscription.expired webhook
+const (
+	ExpirationReasonTrialEnded  = "trial_ended"
+	ExpirationReasonPeriodEnded = "period_ended"
+)
+
+// TrialEnded returns true if the subscription is still a trial when its trial period is over
+func (s *Subscription) TrialEnded(now time.Time) bool {
+	return s.Status == SubscriptionStatusTrial && s.TrialEndsAt != nil && !now.Before(*s.TrialEndsAt)
+}
+
+// FixedPeriodEnded returns true if a subscription with a fixed period reached its end
+func (s *Subscription) FixedPeriodEnded(now time.Time) bool {
+	return s.EndsAt != nil && !now.Before(*s.EndsAt) &&
+		(s.IsActive() || s.Status == SubscriptionStatusSuspended)
+}
+
+// Expire ends the subscription at the end of its trial or fixed period; the company can no
+// longer emit until it subscribes again
+func (s *Subscription) Expire(now time.Time) {
+	s.Status = SubscriptionStatusExpired
+	s.AutoRenew = false
+	s.UpdatedAt = now
+}
+
+// ConvertTrial turns an ended trial into an active subscription, charged from then on
+func (s *Subscription) ConvertTrial(now time.Time) {
+	s.Status = SubscriptionStatusActive
+	s.IsTrial = false
+	s.UpdatedAt = now
+}
+
+// RenewUsagePeriod starts a new usage period when the current one has ended, instead of
+// waiting for the next recorded NFC-e. Plan must be loaded.
+func (s *Subscription) RenewUsagePeriod(now time.Time) bool {
+	if !s.IsActive() || s.Plan == nil || !s.needsPeriodReset(now) {
+		return false
+	}
+	s.resetUsagePeriod(now)
+	s.UpdatedAt = now
+	return true
+}
+
+// BillingDue returns true if the subscription renews and its next billing date has come
+func (s *Subscription) BillingDue(now time.Time) bool {
+	return s.IsActive() && s.AutoRenew && s.BillingInfo.Amount > 0 &&
+		!s.BillingInfo.NextBillingAt.IsZero() && !now.Before(s.BillingInfo.NextBillingAt)
+}
+
+// AdvanceBilling moves the next billing date one cycle ahead and returns the period billed
+func (s *Subscription) AdvanceBilling(plan *Plan) (periodStart, periodEnd time.Time) {
+	periodStart = s.BillingInfo.NextBillingAt
+	periodEnd = s.calculateNextBilling(periodStart, plan)
+	s.BillingInfo.NextBillingAt = periodEnd
+	s.UpdatedAt = time.Now()
+	return periodStart, periodEnd
+}
+
+// RecordPayment records a paid invoice: a trial becomes active and a subscription suspended
+// for lack of payment is reactivated, which it reports
+func (s *Subscription) RecordPayment(now time.Time) (reactivated bool) {
+	s.BillingInfo.LastBilledAt = &now
+	switch s.Status {
+	case SubscriptionStatusTrial:
+		s.Status = SubscriptionStatusActive
+		s.IsTrial = false
+	case SubscriptionStatusSuspended:
+		s.Status = SubscriptionStatusActive
+		s.SuspendedAt = nil
+		reactivated = true
+	}
+	s.UpdatedAt = now
+	return reactivated
+}
+
+// ChangePlan switches the subscription to another plan in the middle of the billing cycle.
+// The price difference over the rest of the cycle is prorated into the returned event, billed
+// on the next invoice; a trial is not prorated. The NFC-e already issued in the period count
+// against the quota of the new plan. The current plan must be loaded to find the cycle start.
+func (s *Subscription) ChangePlan(plan *Plan, now time.Time) (*SubscriptionEvent, error) {
+	if !s.IsActive() {
+		return nil, errors.New("apenas assinaturas ativas podem trocar de plano")
+	}
+	if plan == nil || !plan.IsActive() {
+		return nil, errors.New("o novo plano não está ativo")
+	}
+	if plan.ID == s.PlanID {
+		return nil, errors.New("a assinatura já está neste plano")
+	}
+	if s.BillingInfo.Currency != "" && plan.Currency != s.BillingInfo.Currency {
+		return nil, errors.New("a moeda do novo plano difere da assinatura")
+	}
+
+	var proration float64
+	if s.Status != SubscriptionStatusTrial {
+		proration = money.FromFloat((plan.Price - s.BillingInfo.Amount) * s.remainingCycle(now)).Float64()
+	}
+
+	event := newSubscriptionEvent(s, SubscriptionEventPlanChanged, proration, now)
+	event.FromPlanID = s.PlanID
+	event.ToPlanID = plan.ID
+	event.QuotaBefore = s.CurrentUsage.NFCeRemaining
+
+	s.PlanID = plan.ID
+	s.Plan = plan
+	s.BillingInfo.Amount = plan.Price
+	s.BillingInfo.Currency = plan.Currency
+	if quota := s.calculateInitialQuota(plan); quota < 0 {
+		s.CurrentUsage.NFCeRemaining = -1
+	} else {
+		s.CurrentUsage.NFCeRemaining = max(quota-s.CurrentUsage.NFCeIssued, 0)
+	}
+	s.UpdatedAt = now
+
+	event.QuotaAfter = s.CurrentUsage.NFCeRemaining
+	return event, nil
+}
+
+// remainingCycle returns the fraction (0-1) of the current billing cycle left at now
+func (s *Subscription) remainingCycle(now time.Time) float64 {
+	cycleEnd := s.BillingInfo.NextBillingAt
+	cycleStart := s.CurrentUsage.PeriodStart
+	if s.Plan != nil {
+		cycleStart = s.calculatePreviousBilling(cycleEnd, s.Plan)
+	}
+	if !cycleEnd.After(cycleStart) || !now.Before(cycleEnd) {
+		return 0
+	}
+	if now.Before(cycleStart) {
+		return 1
+	}
+	return float64(cycleEnd.Sub(now)) / float64(cycleEnd.Sub(cycleStart))
+}
+
+// QuotaContext summarizes the plan and usage of a company whose operation was blocked,
+// so POS UIs can tell the operator why and how to upgrade
+type QuotaContext struct {
+	PlanID             string             `json:"plan_id,omitempty"`
+	PlanName           string             `json:"plan_name,omitempty"`
+	QuotaType          QuotaType          `json:"quota_type,omitempty"`
+	SubscriptionStatus SubscriptionStatus `json:"subscription_status,omitempty"`
+	Used               int                `json:"used"`
+	Limit              int                `json:"limit"`               // -1 = unlimited
+	Remaining          int                `json:"remaining"`           // -1 = unlimited
+	Requested          int                `json:"requested,omitempty"` // NFC-e of the refused lote
+	PeriodEnd          *time.Time         `json:"period_end,omitempty"`
+	UpgradeURL         string             `json:"upgrade_url,omitempty"`
+}
+
+// QuotaContext returns the current usage of the subscription against the plan quota
+func (s *Subscription) QuotaContext(plan *Plan) *QuotaContext {
+	periodEnd := s.CurrentUsage.PeriodEnd
+	quota := &QuotaContext{
+		SubscriptionStatus: s.Status,
+		Used:               s.CurrentUsage.NFCeIssued,
+		Limit:              -1,
+		Remaining:          s.CurrentUsage.NFCeRemaining,
+		PeriodEnd:          &periodEnd,
+	}
+
+	if plan != nil {
+		quota.PlanID = plan.ID
+		quota.PlanName = plan.Name
+		quota.QuotaType = plan.QuotaType
+		if limit, limited := plan.GetMaxNFCe(); limited {
+			quota.Limit = limit
+		}
+	}
+
+	return quota
+}
+
 // IsActive returns true if the subscription is active
 func (s *Subscription) IsActive() bool {
 	return s.Status == SubscriptionStatusActive || s.Status == SubscriptionStatusTrial
@@ -237,6 +441,16 @@ func (s *Subscription) calculateNextBilling(start time.Time, plan *Plan) time.Ti
 	}
 }
 
+// calculatePreviousBilling calculates the start of the billing cycle ending at end
+func (s *Subscription) calculatePreviousBilling(end time.Time, plan *Plan) time.Time {
+	switch plan.BillingCycle {
+	case BillingCycleYearly:
+		return end.AddDate(-1, 0, 0)
+	default:
+		return end.AddDate(0, -1, 0)
+	}
+}
+
 // calculateInitialQuota calculates the initial quota based on the plan
 func (s *Subscription) calculateInitialQuota(plan *Plan) int {
 	switch plan.QuotaType {
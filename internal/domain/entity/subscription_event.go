@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionEventType identifies a change recorded on a subscription
+type SubscriptionEventType string
+
+const (
+	SubscriptionEventPlanChanged   SubscriptionEventType = "plan_changed"
+	SubscriptionEventCreditCarried SubscriptionEventType = "credit_carried" // Credit left over after an invoice, billed on the next one
+)
+
+// SubscriptionEvent records a change of a subscription in the middle of a billing cycle. The
+// events carry the proration billed on the next invoice of the subscription.
+type SubscriptionEvent struct {
+	ID             string                `json:"id"`
+	SubscriptionID string                `json:"subscription_id"`
+	CompanyID      string                `json:"company_id"`
+	Type           SubscriptionEventType `json:"type"`
+
+	// Plan change
+	FromPlanID  string `json:"from_plan_id,omitempty"`
+	ToPlanID    string `json:"to_plan_id,omitempty"`
+	QuotaBefore int    `json:"quota_before"` // NFC-e remaining before the change, -1 = unlimited
+	QuotaAfter  int    `json:"quota_after"`  // NFC-e remaining after the change, -1 = unlimited
+
+	// Proration
+	Amount    float64 `json:"amount"` // Charged (positive) or credited (negative) on the next invoice
+	Currency  string  `json:"currency"`
+	InvoiceID *string `json:"invoice_id,omitempty"` // Invoice the amount was billed on, nil until then
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// newSubscriptionEvent creates an unbilled event of the subscription
+func newSubscriptionEvent(subscription *Subscription, eventType SubscriptionEventType, amount float64, now time.Time) *SubscriptionEvent {
+	return &SubscriptionEvent{
+		ID:             uuid.New().String(),
+		SubscriptionID: subscription.ID,
+		CompanyID:      subscription.CompanyID,
+		Type:           eventType,
+		Amount:         amount,
+		Currency:       subscription.BillingInfo.Currency,
+		CreatedAt:      now,
+	}
+}
+
+// NewCreditCarriedEvent carries to the next invoice the credit an invoice could not absorb
+func NewCreditCarriedEvent(subscription *Subscription, credit float64, now time.Time) *SubscriptionEvent {
+	return newSubscriptionEvent(subscription, SubscriptionEventCreditCarried, credit, now)
+}
+
+// TableName specifies the table name for GORM
+func (SubscriptionEvent) TableName() string {
+	return "subscription_events"
+}
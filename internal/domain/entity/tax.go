@@ -0,0 +1,147 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	ncmPrefixPattern = regexp.MustCompile(`^\d{2,8}$`)
+	cfopPattern      = regexp.MustCompile(`^\d{4}$`)
+)
+
+// ICMS situations (CST for Regime Normal, CSOSN for Simples Nacional) computed by the tax engine
+var (
+	supportedCSTICMS   = map[string]bool{"00": true, "40": true, "41": true, "60": true}
+	supportedCSOSN     = map[string]bool{"102": true, "103": true, "300": true, "400": true, "500": true}
+	supportedCSTPISCOF = map[string]bool{
+		"01": true, "02": true, "04": true, "05": true, "06": true, "07": true, "08": true, "09": true,
+		"49": true, "99": true,
+	}
+)
+
+// TaxRule overrides the computed taxes of the items matching its NCM and CFOP.
+// Fields left empty keep the value of the regime default tables.
+type TaxRule struct {
+	NCM            string   `json:"ncm,omitempty"`  // NCM or NCM prefix (e.g. "2202" for every beverage)
+	CFOP           string   `json:"cfop,omitempty"` // Empty matches any CFOP
+	Origem         string   `json:"origem,omitempty"`
+	CSTICMS        string   `json:"cst_icms,omitempty"` // CST (Regime Normal) or CSOSN (Simples Nacional)
+	AliquotaICMS   *float64 `json:"aliquota_icms,omitempty"`
+	CSTPIS         string   `json:"cst_pis,omitempty"`
+	AliquotaPIS    *float64 `json:"aliquota_pis,omitempty"`
+	CSTCOFINS      string   `json:"cst_cofins,omitempty"`
+	AliquotaCOFINS *float64 `json:"aliquota_cofins,omitempty"`
+	// AliquotaTributos is the approximate tax burden (Lei 12.741/2012) used for vTotTrib
+	AliquotaTributos *float64 `json:"aliquota_tributos,omitempty"`
+}
+
+// specificity ranks rules matching the same item: longer NCM prefixes win, then an explicit CFOP
+func (r TaxRule) specificity() int {
+	score := len(r.NCM) * 2
+	if r.CFOP != "" {
+		score++
+	}
+	return score
+}
+
+// matches reports whether the rule applies to an item with the given NCM and CFOP
+func (r TaxRule) matches(ncm, cfop string) bool {
+	if r.NCM != "" && (len(ncm) < len(r.NCM) || ncm[:len(r.NCM)] != r.NCM) {
+		return false
+	}
+	return r.CFOP == "" || r.CFOP == cfop
+}
+
+// TaxRules are the tax overrides of a company
+type TaxRules []TaxRule
+
+// Match returns the most specific rule for the item NCM and CFOP
+func (r TaxRules) Match(ncm, cfop string) (TaxRule, bool) {
+	best, found := TaxRule{}, false
+	for _, rule := range r {
+		if !rule.matches(ncm, cfop) {
+			continue
+		}
+		if !found || rule.specificity() > best.specificity() {
+			best, found = rule, true
+		}
+	}
+	return best, found
+}
+
+// Validate checks the rules against the company tax regime
+func (r TaxRules) Validate(regime TaxRegime) error {
+	seen := make(map[string]bool, len(r))
+	for i, rule := range r {
+		name := fmt.Sprintf("regra tributária %d", i+1)
+		if rule.NCM == "" && rule.CFOP == "" {
+			return fmt.Errorf("%s requer ncm ou cfop", name)
+		}
+		if rule.NCM != "" && !ncmPrefixPattern.MatchString(rule.NCM) {
+			return fmt.Errorf("%s: ncm deve ter de 2 a 8 dígitos", name)
+		}
+		if rule.CFOP != "" && !cfopPattern.MatchString(rule.CFOP) {
+			return fmt.Errorf("%s: cfop deve ter 4 dígitos", name)
+		}
+		key := rule.NCM + "|" + rule.CFOP
+		if seen[key] {
+			return fmt.Errorf("%s repete ncm %q e cfop %q", name, rule.NCM, rule.CFOP)
+		}
+		seen[key] = true
+
+		if rule.Origem != "" && (len(rule.Origem) != 1 || rule.Origem[0] < '0' || rule.Origem[0] > '8') {
+			return fmt.Errorf("%s: origem deve ser de 0 a 8", name)
+		}
+		if rule.CSTICMS != "" {
+			if regime == TaxRegimeSimplesNacional && !supportedCSOSN[rule.CSTICMS] {
+				return fmt.Errorf("%s: CSOSN %s não suportado (use 102, 103, 300, 400 ou 500)", name, rule.CSTICMS)
+			}
+			if regime != TaxRegimeSimplesNacional && !supportedCSTICMS[rule.CSTICMS] {
+				return fmt.Errorf("%s: CST de ICMS %s não suportado (use 00, 40, 41 ou 60)", name, rule.CSTICMS)
+			}
+		}
+		if rule.CSTPIS != "" && !supportedCSTPISCOF[rule.CSTPIS] {
+			return fmt.Errorf("%s: CST de PIS %s não suportado", name, rule.CSTPIS)
+		}
+		if rule.CSTCOFINS != "" && !supportedCSTPISCOF[rule.CSTCOFINS] {
+			return fmt.Errorf("%s: CST de COFINS %s não suportado", name, rule.CSTCOFINS)
+		}
+		for field, aliquota := range map[string]*float64{
+			"aliquota_icms":     rule.AliquotaICMS,
+			"aliquota_pis":      rule.AliquotaPIS,
+			"aliquota_cofins":   rule.AliquotaCOFINS,
+			"aliquota_tributos": rule.AliquotaTributos,
+		} {
+			if aliquota != nil && (*aliquota < 0 || *aliquota > 100) {
+				return fmt.Errorf("%s: %s deve estar entre 0 e 100", name, field)
+			}
+		}
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (r TaxRules) Value() (driver.Value, error) {
+	if r == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (r *TaxRules) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("TaxRules.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
@@ -1,8 +1,13 @@
 package entity
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,12 +17,19 @@ import (
 type WebhookEvent string
 
 const (
-	WebhookEventNFCEAuthorized      WebhookEvent = "nfce.authorized"
-	WebhookEventNFCERejected        WebhookEvent = "nfce.rejected"
-	WebhookEventNFCECanceled        WebhookEvent = "nfce.canceled"
-	WebhookEventNFCEContingency     WebhookEvent = "nfce.contingency"
-	WebhookEventSubscriptionExpired WebhookEvent = "subscription.expired"
-	WebhookEventQuotaExceeded       WebhookEvent = "quota.exceeded"
+	WebhookEventNFCEAuthorized          WebhookEvent = "nfce.authorized"
+	WebhookEventNFCERejected            WebhookEvent = "nfce.rejected"
+	WebhookEventNFCECanceled            WebhookEvent = "nfce.canceled"
+	WebhookEventNFCEContingency         WebhookEvent = "nfce.contingency"
+	WebhookEventSubscriptionExpired     WebhookEvent = "subscription.expired"
+	WebhookEventQuotaExceeded           WebhookEvent = "quota.exceeded"
+	WebhookEventPlanFeatureBlocked      WebhookEvent = "plan.feature_blocked"
+	WebhookEventMaintenanceStarted      WebhookEvent = "maintenance.started"
+	WebhookEventMaintenanceEnded        WebhookEvent = "maintenance.ended"
+	WebhookEventStatementAvailable      WebhookEvent = "statement.available"
+	WebhookEventExportCompleted         WebhookEvent = "export.completed"
+	WebhookEventSubscriptionSuspended   WebhookEvent = "subscription.suspended"
+	WebhookEventSubscriptionReactivated WebhookEvent = "subscription.reactivated"
 )
 
 // WebhookStatus represents the status of a webhook configuration
@@ -53,7 +65,7 @@ type WebhookDelivery struct {
 	ID           string                 `json:"id"`
 	WebhookID    string                 `json:"webhook_id"`
 	Event        WebhookEvent           `json:"event"`
-	Payload      map[string]interface{} `json:"payload"`
+	Payload      map[string]interface{} `json:"payload" gorm:"serializer:json"`
 	Attempt      int                    `json:"attempt"`
 	StatusCode   int                    `json:"status_code,omitempty"`
 	ResponseBody string                 `json:"response_body,omitempty"`
@@ -63,6 +75,31 @@ type WebhookDelivery struct {
 	CreatedAt    time.Time              `json:"created_at"`
 }
 
+// NewWebhookDelivery records the outcome of delivering an event to a webhook after attempts tries
+func NewWebhookDelivery(webhookID string, event WebhookEvent, payload map[string]interface{}, attempts int, deliveryErr error) *WebhookDelivery {
+	now := time.Now()
+	delivery := &WebhookDelivery{
+		ID:        uuid.New().String(),
+		WebhookID: webhookID,
+		Event:     event,
+		Payload:   payload,
+		Attempt:   attempts,
+		Succeeded: deliveryErr == nil,
+		CreatedAt: now,
+	}
+	if deliveryErr != nil {
+		delivery.ErrorMessage = deliveryErr.Error()
+	} else {
+		delivery.DeliveredAt = &now
+	}
+	return delivery
+}
+
+// TableName specifies the table name for GORM
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
 // Webhook represents a webhook configuration for notifications
 type Webhook struct {
 	ID          string        `json:"id"`
@@ -76,9 +113,12 @@ type Webhook struct {
 	// Events to listen for
 	Events []WebhookEvent `json:"events"`
 
+	// Filters on the NFC-e events, all of which must match for the delivery
+	Filters WebhookFilters `json:"filters,omitempty" gorm:"type:jsonb"`
+
 	// Authentication and headers
 	Headers WebhookHeaders `json:"headers,omitempty"`
-	Secret  string         `json:"secret,omitempty"` // For HMAC validation
+	Secret  string         `json:"secret,omitempty" gorm:"serializer:secret"` // For HMAC validation, encrypted at rest
 
 	// Retry configuration
 	RetryConfig WebhookRetryConfig `json:"retry_config"`
@@ -172,6 +212,30 @@ func (w *Webhook) RemoveEvent(event WebhookEvent) {
 	}
 }
 
+// SetFilters validates and sets the filters of the NFC-e events
+func (w *Webhook) SetFilters(filters WebhookFilters) error {
+	if err := filters.Validate(); err != nil {
+		return err
+	}
+	w.Filters = filters
+	w.UpdatedAt = time.Now()
+	return nil
+}
+
+// Accepts reports whether the event passes the webhook filters. Filters only apply to the
+// NFC-e events; the events of the company account are always delivered.
+func (w *Webhook) Accepts(event WebhookEvent, payload map[string]interface{}) bool {
+	if !strings.HasPrefix(string(event), "nfce.") {
+		return true
+	}
+	for _, filter := range w.Filters {
+		if !filter.Matches(payload) {
+			return false
+		}
+	}
+	return true
+}
+
 // SetHeaders sets custom headers for the webhook
 func (w *Webhook) SetHeaders(headers WebhookHeaders) {
 	w.Headers = headers
@@ -256,3 +320,159 @@ func validateWebhookURL(webhookURL string) error {
 func generateWebhookID() string {
 	return uuid.New().String()
 }
+
+// ErrInvalidWebhookFilter is returned when a webhook filter has an unknown field or operator
+var ErrInvalidWebhookFilter = errors.New("invalid webhook filter")
+
+// Operators of the webhook filters
+const (
+	WebhookFilterEq  = "eq"
+	WebhookFilterNe  = "ne"
+	WebhookFilterGt  = "gt"
+	WebhookFilterGte = "gte"
+	WebhookFilterLt  = "lt"
+	WebhookFilterLte = "lte"
+	WebhookFilterIn  = "in"
+)
+
+// webhookFilterFields are the fields of the NFC-e event payloads the filters compare; the
+// metadata keys are filtered as metadata.<key>
+var webhookFilterFields = map[string]bool{
+	"ambiente":    true,
+	"modelo":      true,
+	"serie":       true,
+	"uf":          true,
+	"valor_total": true,
+}
+
+// MaxWebhookFilters caps the filters of a webhook
+const MaxWebhookFilters = 10
+
+// WebhookFilter compares a field of the NFC-e event payload with a value, e.g. ambiente eq
+// producao, valor_total gt 100.00 or serie in 1,2
+type WebhookFilter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"` // Comma-separated values for the in operator
+}
+
+// Validate checks the field, the operator and, for the ordering operators, the numeric value
+func (f WebhookFilter) Validate() error {
+	if !webhookFilterFields[f.Field] && !(strings.HasPrefix(f.Field, "metadata.") && len(f.Field) > len("metadata.")) {
+		return fmt.Errorf("%w: campo %q não suportado", ErrInvalidWebhookFilter, f.Field)
+	}
+
+	switch f.Op {
+	case WebhookFilterEq, WebhookFilterNe, WebhookFilterIn:
+	case WebhookFilterGt, WebhookFilterGte, WebhookFilterLt, WebhookFilterLte:
+		if _, err := strconv.ParseFloat(f.Value, 64); err != nil {
+			return fmt.Errorf("%w: operador %s de %s exige valor numérico", ErrInvalidWebhookFilter, f.Op, f.Field)
+		}
+	default:
+		return fmt.Errorf("%w: operador %q não suportado (eq, ne, gt, gte, lt, lte, in)", ErrInvalidWebhookFilter, f.Op)
+	}
+	return nil
+}
+
+// Matches reports whether the payload satisfies the filter; a payload without the field does not
+func (f WebhookFilter) Matches(payload map[string]interface{}) bool {
+	actual, ok := webhookPayloadField(payload, f.Field)
+	if !ok {
+		return false
+	}
+
+	switch f.Op {
+	case WebhookFilterEq:
+		return actual == f.Value
+	case WebhookFilterNe:
+		return actual != f.Value
+	case WebhookFilterIn:
+		for _, value := range strings.Split(f.Value, ",") {
+			if strings.TrimSpace(value) == actual {
+				return true
+			}
+		}
+		return false
+	}
+
+	number, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return false
+	}
+	limit, _ := strconv.ParseFloat(f.Value, 64)
+	switch f.Op {
+	case WebhookFilterGt:
+		return number > limit
+	case WebhookFilterGte:
+		return number >= limit
+	case WebhookFilterLt:
+		return number < limit
+	case WebhookFilterLte:
+		return number <= limit
+	}
+	return false
+}
+
+// webhookPayloadField returns a field of the payload formatted as text, looking the
+// metadata.<key> fields up in the metadata of the NFC-e
+func webhookPayloadField(payload map[string]interface{}, field string) (string, bool) {
+	if key, ok := strings.CutPrefix(field, "metadata."); ok {
+		switch metadata := payload["metadata"].(type) {
+		case Metadata:
+			value, found := metadata[key]
+			return value, found
+		case map[string]string:
+			value, found := metadata[key]
+			return value, found
+		case map[string]interface{}:
+			value, found := metadata[key]
+			return fmt.Sprint(value), found
+		}
+		return "", false
+	}
+
+	value, ok := payload[field]
+	if !ok || value == nil {
+		return "", false
+	}
+	return fmt.Sprint(value), true
+}
+
+// WebhookFilters are the filters of a webhook, all of which must match
+type WebhookFilters []WebhookFilter
+
+// Validate checks every filter
+func (f WebhookFilters) Validate() error {
+	if len(f) > MaxWebhookFilters {
+		return fmt.Errorf("%w: até %d filtros por webhook", ErrInvalidWebhookFilter, MaxWebhookFilters)
+	}
+	for _, filter := range f {
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface for GORM JSONB serialization
+func (f WebhookFilters) Value() (driver.Value, error) {
+	if f == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(f)
+}
+
+// Scan implements the sql.Scanner interface for GORM JSONB deserialization
+func (f *WebhookFilters) Scan(value interface{}) error {
+	if value == nil {
+		*f = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("WebhookFilters.Scan: value must be []byte")
+	}
+
+	return json.Unmarshal(bytes, f)
+}
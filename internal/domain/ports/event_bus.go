@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// EventBus distributes NFC-e status change events between processes.
+type EventBus interface {
+	// Publish broadcasts a status change event for the given company
+	Publish(ctx context.Context, companyID string, event *entity.Event) error
+	// Subscribe returns a channel of events for the given company and a function to unsubscribe
+	Subscribe(companyID string) (<-chan *entity.Event, func())
+	Close() error
+}
@@ -0,0 +1,14 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimitStore keeps the token buckets of the API rate limits, so several API instances
+// may share them. A bucket holds up to limit tokens and refills limit tokens per minute.
+type RateLimitStore interface {
+	// Take removes a token from the bucket of key and returns the tokens left; when the bucket
+	// is empty, allowed is false and retryAfter is the wait for the next token
+	Take(ctx context.Context, key string, limit int, now time.Time) (allowed bool, remaining int, retryAfter time.Duration, err error)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
 )
 
 // CompanyRepository defines the persistence boundary for companies.
@@ -14,14 +15,22 @@ type CompanyRepository interface {
 	GetByCNPJ(ctx context.Context, cnpj string) (*entity.Company, error)
 	Update(ctx context.Context, company *entity.Company) error
 	List(ctx context.Context, limit, offset int) ([]*entity.Company, int, error)
+	// ListByIDs returns the companies with the IDs, in no particular order
+	ListByIDs(ctx context.Context, ids []string) ([]*entity.Company, error)
 	Count(ctx context.Context) (int, error)
 	CountByStatus(ctx context.Context, status entity.CompanyStatus) (int, error)
+	// ListDeleted lists the offboarded companies, oldest deletion first
+	ListDeleted(ctx context.Context, limit, offset int) ([]*entity.Company, int, error)
 
 	// Certificate methods
 	GetCertificateByCompanyID(ctx context.Context, companyID string) (*entity.Certificate, error)
 
 	// NFC-e sequencing methods
-	GetNextNFCeNumber(ctx context.Context, companyID string) (int64, error)
+	// GetNextNFCeNumber allocates the next number of the series of the model, creating
+	// DefaultNFCeSerie on the first emission of a company without series in the environment
+	GetNextNFCeNumber(ctx context.Context, companyID, modelo, ambiente string, serie int) (int64, error)
+	// GetDefaultNFCeSerie returns the default series of the model and environment, DefaultNFCeSerie without one
+	GetDefaultNFCeSerie(ctx context.Context, companyID, modelo, ambiente string) (int, error)
 }
 
 // PlanRepository defines the persistence boundary for plans.
@@ -38,10 +47,18 @@ type SubscriptionRepository interface {
 	Create(ctx context.Context, subscription *entity.Subscription) error
 	GetByID(ctx context.Context, id string) (*entity.Subscription, error)
 	GetActiveByCompanyID(ctx context.Context, companyID string) (*entity.Subscription, error)
+	// LockActiveByCompanyID loads the active subscription locking its row until the transaction in ctx ends
+	LockActiveByCompanyID(ctx context.Context, companyID string) (*entity.Subscription, error)
 	Update(ctx context.Context, subscription *entity.Subscription) error
 	List(ctx context.Context, limit, offset int) ([]*entity.Subscription, int, error)
 	Count(ctx context.Context) (int, error)
 	CountByStatus(ctx context.Context, status entity.SubscriptionStatus) (int, error)
+	// ListBillingDue lists the active subscriptions that renew and whose next billing date has come
+	ListBillingDue(ctx context.Context, now time.Time, limit int) ([]*entity.Subscription, error)
+	// LockByID loads the subscription locking its row until the transaction in ctx ends
+	LockByID(ctx context.Context, id string) (*entity.Subscription, error)
+	// ListLifecycleDue lists the subscriptions whose trial, fixed period or usage period has ended
+	ListLifecycleDue(ctx context.Context, now time.Time, limit int) ([]*entity.Subscription, error)
 }
 
 // WebhookRepository defines the persistence boundary for webhooks.
@@ -53,25 +70,335 @@ type WebhookRepository interface {
 	List(ctx context.Context, limit, offset int) ([]*entity.Webhook, int, error)
 	ListByCompanyID(ctx context.Context, companyID string, limit, offset int) ([]*entity.Webhook, int, error)
 	Count(ctx context.Context) (int, error)
+	CreateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error
+	GetDeliveryStats(ctx context.Context, companyID string, from, to time.Time) (*entity.WebhookDeliveryStats, error)
+}
+
+// Sort orders of the NFC-e listing
+const (
+	NFCeSortCreatedAt = "created_at"
+	NFCeSortValor     = "valor"
+)
+
+// NFCeFilter narrows the listing of a company NFC-e; empty fields match everything
+type NFCeFilter struct {
+	From         *time.Time // Created at or after
+	To           *time.Time // Created before
+	ChaveAcesso  string
+	Numero       string
+	Serie        string
+	Destinatario string // CPF or CNPJ of the consumer, digits only
+	CStat        string
+	Status       entity.RequestStatus
+	MinValor     *money.Amount // vNF range, inclusive
+	MaxValor     *money.Amount
+	Contingency  *bool
+	Query        string            // Full-text search on the item descriptions and consumer name (web search syntax)
+	Metadata     map[string]string // Exact metadata key/value pairs, all of them
+	Sort         string            // NFCeSortCreatedAt (default) or NFCeSortValor
+	Desc         bool
+	After        *NFCeCursor // Keyset of the last NFC-e of the previous page
+}
+
+// NFCeCursor is the position of an NFC-e in the listing order: its sort value and ID
+type NFCeCursor struct {
+	CreatedAt time.Time    `json:"created_at,omitempty"`
+	Valor     money.Amount `json:"valor,omitempty"`
+	ID        string       `json:"id"`
+}
+
+// NFCeStatsFilter selects the NFC-e of the admin statistics: created in [From, To), of one
+// company when CompanyID is set
+type NFCeStatsFilter struct {
+	CompanyID string
+	From      time.Time
+	To        time.Time
 }
 
 // NFCeRepository defines the persistence boundary for NFC-e requests.
 type NFCeRepository interface {
+	// Create stores the request with its creation event
 	Create(ctx context.Context, req *entity.NFCE) error
+	// Update saves the fields of the request that keep its status; status changes go through
+	// Transition or UpdateStatus
 	Update(ctx context.Context, nfce *entity.NFCE) error
 	UpdateFields(ctx context.Context, id string, updates map[string]interface{}) error
-	UpdateStatus(ctx context.Context, id string, from entity.RequestStatus, to entity.RequestStatus, mutate func(*entity.NFCE)) error
+	// Transition saves the status of the request read at nfce.Version and appends the event
+	// of the transition. It fails with entity.ErrStatusConflict when the request changed
+	// since, and with entity.ErrInvalidStatusTransition for a transition the state machine
+	// does not allow.
+	Transition(ctx context.Context, nfce *entity.NFCE, event *entity.Event) error
+	// UpdateStatus moves the request from one status to another, applying mutate, and appends
+	// the event with the message; nothing changes when the request is no longer in from
+	UpdateStatus(ctx context.Context, id string, from entity.RequestStatus, to entity.RequestStatus, message string, mutate func(*entity.NFCE)) error
 	GetByID(ctx context.Context, id string) (*entity.NFCE, error)
-	GetByIdempotencyKey(ctx context.Context, key string) (*entity.NFCE, error)
-	List(ctx context.Context, limit, offset int) ([]*entity.NFCE, error)
-	ListWithFilters(ctx context.Context, limit, offset int, companyID, status string) ([]*entity.NFCE, int, error)
-	GetStats(ctx context.Context, companyID string, since time.Time) (map[string]int, error)
+	GetByIDForCompany(ctx context.Context, companyID, id string) (*entity.NFCE, error)
+	GetByIdempotencyKey(ctx context.Context, companyID, key string) (*entity.NFCE, error)
+	// GetByChaveAcesso returns the NFC-e of any company with the chave de acesso, or nil
+	GetByChaveAcesso(ctx context.Context, chave string) (*entity.NFCE, error)
+	// ChaveAcessoExists reports whether an NFC-e was already given the chave de acesso
+	ChaveAcessoExists(ctx context.Context, chave string) (bool, error)
+	ListByLote(ctx context.Context, loteID string) ([]*entity.NFCE, error)
+	// ListByCompany lists a page of the company NFC-e after filter.After in the filter sort order,
+	// with the total matching the filter; an empty companyID lists every company
+	ListByCompany(ctx context.Context, companyID string, filter NFCeFilter, limit int) ([]*entity.NFCE, int, error)
+	// GetStats counts the NFC-e matching the filter by status, with the total
+	GetStats(ctx context.Context, filter NFCeStatsFilter) (map[string]int, error)
+	// GetTopRejections returns the limit cStat that rejected most NFC-e, most frequent first
+	GetTopRejections(ctx context.Context, filter NFCeStatsFilter, limit int) ([]entity.RejectionStat, error)
+	// GetLatencyByUF returns the authorization latency percentiles of each UF
+	GetLatencyByUF(ctx context.Context, filter NFCeStatsFilter) ([]entity.UFLatency, error)
+	// GetDailyStats returns the NFC-e counts of each day with emissions, oldest first
+	GetDailyStats(ctx context.Context, filter NFCeStatsFilter) ([]entity.DailyStats, error)
+	// GetCompanyStats returns the NFC-e counts of each company with emissions, busiest first
+	GetCompanyStats(ctx context.Context, filter NFCeStatsFilter) ([]entity.CompanyStats, error)
 	Count(ctx context.Context) (int, error)
 	CountByStatus(ctx context.Context, status entity.RequestStatus) (int, error)
+	// AppendEvent records an event that keeps the status of the request, with its current state
 	AppendEvent(ctx context.Context, evt *entity.Event) error
 	CreateEvent(ctx context.Context, event *entity.Event) error
 	GetEventsByRequestID(ctx context.Context, requestID string, limit, offset int) ([]*entity.Event, error)
-	GetPendingRetries(ctx context.Context, beforeTime time.Time, limit int) ([]*entity.NFCE, error)
+	// ListEvents returns every event of the request in sequence order
+	ListEvents(ctx context.Context, requestID string) ([]*entity.Event, error)
+	// ListEventsByRequestIDs returns the events of the requests, each in sequence order
+	ListEventsByRequestIDs(ctx context.Context, requestIDs []string) ([]*entity.Event, error)
+	// ListAfter lists up to limit requests of any company after the given ID, in ID order
+	ListAfter(ctx context.Context, afterID string, limit int) ([]*entity.NFCE, error)
+	// SaveProjection saves the status, version and state rebuilt from the events of the
+	// request, failing with entity.ErrStatusConflict when it is no longer at version
+	SaveProjection(ctx context.Context, nfce *entity.NFCE, version int) error
+	GetCompanyEventsAfter(ctx context.Context, companyID, afterEventID string, limit int) ([]*entity.Event, error)
+	// ClaimDueRetries moves up to limit retries due before beforeTime to processing, skipping
+	// the rows locked by another worker
+	ClaimDueRetries(ctx context.Context, beforeTime time.Time, limit int) ([]*entity.NFCE, error)
+	// ClaimRetry moves the retry to processing when it is due before beforeTime
+	ClaimRetry(ctx context.Context, nfce *entity.NFCE, beforeTime time.Time) (bool, error)
+	GetRetryBacklog(ctx context.Context, beforeTime time.Time) (*entity.RetryBacklog, error)
+	Search(ctx context.Context, companyID, query string, limit, offset int) ([]*entity.NFCeSearchHit, int, error)
+	GetAwaitingReceipt(ctx context.Context, beforeTime time.Time, limit int) ([]*entity.NFCE, error)
+	GetPendingTransmission(ctx context.Context, limit int) ([]*entity.NFCE, error)
+	// ListPurgeable lists up to limit authorized or canceled NFC-e of the company created before
+	// the given time whose documents were not purged yet, oldest first
+	ListPurgeable(ctx context.Context, companyID string, before time.Time, limit int) ([]*entity.NFCE, error)
+	// AnonymizeDestinatarios removes the CPF, name and e-mail of the consumers identified by CPF
+	// from the payload of the NFC-e of the company created before the given time, returning how
+	// many NFC-e were anonymized
+	AnonymizeDestinatarios(ctx context.Context, companyID string, before time.Time) (int, error)
+	CountByTerminalSince(ctx context.Context, cnpj, terminal string, since time.Time) (int, error)
+	CountIdenticalSince(ctx context.Context, payload entity.EmitPayload, since time.Time) (int, error)
+	CountForExport(ctx context.Context, companyID string, from, to time.Time) (int, error)
+	ListForExport(ctx context.Context, companyID string, from, to time.Time, limit, offset int) ([]*entity.NFCE, error)
+	GetUsageCounts(ctx context.Context, companyID string, from, to time.Time) ([]entity.NFCeUsageCount, error)
+}
+
+// ExportRepository defines the persistence boundary for bulk export jobs.
+type ExportRepository interface {
+	Create(ctx context.Context, job *entity.ExportJob) error
+	GetByID(ctx context.Context, id string) (*entity.ExportJob, error)
+	Update(ctx context.Context, job *entity.ExportJob) error
+	ListByCompanyID(ctx context.Context, companyID string, limit, offset int) ([]*entity.ExportJob, int, error)
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]*entity.ExportJob, error)
+}
+
+// StatementRepository defines the persistence boundary for monthly usage statements.
+type StatementRepository interface {
+	// CreateIfAbsent stores the statement unless the company already has one for the period
+	CreateIfAbsent(ctx context.Context, statement *entity.UsageStatement) (bool, error)
+	GetByPeriod(ctx context.Context, companyID, period string) (*entity.UsageStatement, error)
+	ListByCompanyID(ctx context.Context, companyID string, limit, offset int) ([]*entity.UsageStatement, int, error)
+}
+
+// InvoiceRepository defines the persistence boundary for subscription invoices.
+type InvoiceRepository interface {
+	Create(ctx context.Context, invoice *entity.Invoice) error
+	GetByID(ctx context.Context, id string) (*entity.Invoice, error)
+	// GetByChargeID returns the invoice of the gateway charge, nil when none
+	GetByChargeID(ctx context.Context, gateway, chargeID string) (*entity.Invoice, error)
+	// GetOpenBySubscriptionID returns the pending or failed invoice of the subscription, nil when none
+	GetOpenBySubscriptionID(ctx context.Context, subscriptionID string) (*entity.Invoice, error)
+	Update(ctx context.Context, invoice *entity.Invoice) error
+	// ListRetryDue lists the failed invoices whose next charge attempt has come
+	ListRetryDue(ctx context.Context, now time.Time, limit int) ([]*entity.Invoice, error)
+	ListByCompanyID(ctx context.Context, companyID string, limit, offset int) ([]*entity.Invoice, int, error)
+}
+
+// SubscriptionEventRepository defines the persistence boundary for the plan changes and
+// prorations of the subscriptions.
+type SubscriptionEventRepository interface {
+	Create(ctx context.Context, event *entity.SubscriptionEvent) error
+	// ListUnbilled lists the events of the subscription whose amount no invoice has billed yet
+	ListUnbilled(ctx context.Context, subscriptionID string) ([]*entity.SubscriptionEvent, error)
+	// MarkBilled records the invoice that billed the events
+	MarkBilled(ctx context.Context, ids []string, invoiceID string) error
+	// ListByCompanyID lists the events of the company created in [from, to), oldest first
+	ListByCompanyID(ctx context.Context, companyID string, from, to time.Time) ([]*entity.SubscriptionEvent, error)
+}
+
+// SEFAZExchangeRepository defines the persistence boundary for the archived SEFAZ exchanges.
+type SEFAZExchangeRepository interface {
+	Create(ctx context.Context, exchanges []*entity.SEFAZExchange) error
+	// ListByRequestID lists the exchanges of an NFC-e, oldest first
+	ListByRequestID(ctx context.Context, requestID string) ([]*entity.SEFAZExchange, error)
+	// ListCreatedBefore lists up to limit exchanges created before the time, oldest first
+	ListCreatedBefore(ctx context.Context, before time.Time, limit int) ([]*entity.SEFAZExchange, error)
+	Delete(ctx context.Context, ids []string) error
+}
+
+// NumberingSeriesRepository defines the persistence boundary for NFC-e numbering series.
+type NumberingSeriesRepository interface {
+	Create(ctx context.Context, series *entity.NumberingSeries) error
+	GetByID(ctx context.Context, id string) (*entity.NumberingSeries, error)
+	Update(ctx context.Context, series *entity.NumberingSeries) error
+	// ListByCompanyID lists the series of the company, of one model and environment when set
+	ListByCompanyID(ctx context.Context, companyID, modelo, ambiente string) ([]*entity.NumberingSeries, error)
+	// SetDefault makes the series the default of its model and environment, unsetting the previous one
+	SetDefault(ctx context.Context, series *entity.NumberingSeries) error
+	// FindGaps lists the allocated numbers of the series held by no NFC-e nor non-rejected inutilização
+	FindGaps(ctx context.Context, series *entity.NumberingSeries, limit int) ([]entity.NumberingGap, error)
+}
+
+// InutilizacaoRepository defines the persistence boundary for inutilized number ranges.
+type InutilizacaoRepository interface {
+	Create(ctx context.Context, inutilizacao *entity.Inutilizacao) error
+	GetByID(ctx context.Context, id string) (*entity.Inutilizacao, error)
+	Update(ctx context.Context, inutilizacao *entity.Inutilizacao) error
+	ListByCompanyID(ctx context.Context, companyID string, limit, offset int) ([]*entity.Inutilizacao, int, error)
+	// HasOverlap reports whether a non-rejected inutilização of the model and series intersects the range
+	HasOverlap(ctx context.Context, companyID, modelo string, serie int, numeroInicial, numeroFinal int64) (bool, error)
+}
+
+// DFeFilter narrows the listing of distributed documents
+type DFeFilter struct {
+	Tipo        entity.DFeDocumentType
+	ChaveAcesso string
+}
+
+// DFeRepository defines the persistence boundary for NFeDistribuicaoDFe cursors and documents.
+type DFeRepository interface {
+	// GetCursor returns nil without error when the company never synced
+	GetCursor(ctx context.Context, companyID string) (*entity.DFeCursor, error)
+	SaveCursor(ctx context.Context, cursor *entity.DFeCursor) error
+	// ListDueCursors lists the cursors whose next sync is due at the given time
+	ListDueCursors(ctx context.Context, before time.Time, limit int) ([]*entity.DFeCursor, error)
+	// SaveDocuments stores the documents, ignoring NSUs already stored for the company
+	SaveDocuments(ctx context.Context, documents []*entity.DFeDocument) error
+	ListDocuments(ctx context.Context, companyID string, filter DFeFilter, limit, offset int) ([]*entity.DFeDocument, int, error)
+	GetDocument(ctx context.Context, companyID, id string) (*entity.DFeDocument, error)
+}
+
+// CatalogRepository defines the lookups of the NCM, CEST, CFOP and IBGE municipality catalog tables.
+type CatalogRepository interface {
+	// HasNCMs reports whether the NCM table was imported
+	HasNCMs(ctx context.Context) (bool, error)
+	FindNCMs(ctx context.Context, codes []string) (map[string]entity.NCMCatalogEntry, error)
+	// HasCESTs reports whether the CEST table was imported
+	HasCESTs(ctx context.Context) (bool, error)
+	// FindCESTs returns the NCM entries of each CEST code found
+	FindCESTs(ctx context.Context, codes []string) (map[string][]entity.CESTCatalogEntry, error)
+	FindCFOPs(ctx context.Context, codes []string) (map[string]entity.CFOPCatalogEntry, error)
+	// HasMunicipios reports whether the IBGE municipality table was imported
+	HasMunicipios(ctx context.Context) (bool, error)
+	// GetMunicipio returns nil without error when no municipality has the code
+	GetMunicipio(ctx context.Context, codigo string) (*entity.MunicipioCatalogEntry, error)
+	// FindMunicipio looks a municipality up by UF and MunicipioSearchName, nil without error when unknown
+	FindMunicipio(ctx context.Context, uf, nomeBusca string) (*entity.MunicipioCatalogEntry, error)
+	// ReplaceNCMs, ReplaceCESTs and ReplaceMunicipios swap the whole table, used by the catalog import
+	ReplaceNCMs(ctx context.Context, entries []entity.NCMCatalogEntry) error
+	ReplaceCESTs(ctx context.Context, entries []entity.CESTCatalogEntry) error
+	ReplaceMunicipios(ctx context.Context, entries []entity.MunicipioCatalogEntry) error
+}
+
+// MaintenanceRepository defines the persistence boundary for maintenance windows.
+type MaintenanceRepository interface {
+	Create(ctx context.Context, window *entity.MaintenanceWindow) error
+	Update(ctx context.Context, window *entity.MaintenanceWindow) error
+	// GetActive returns nil without error when no window is active
+	GetActive(ctx context.Context) (*entity.MaintenanceWindow, error)
+}
+
+// ContingencyStateRepository defines the persistence boundary for contingency periods.
+type ContingencyStateRepository interface {
+	// Enter stores the period unless the company is already in contingency in the UF and
+	// ambiente, and returns the active period
+	Enter(ctx context.Context, state *entity.ContingencyState) (*entity.ContingencyState, error)
+	Update(ctx context.Context, state *entity.ContingencyState) error
+	// GetActive returns nil without error when the company is not in contingency
+	GetActive(ctx context.Context, companyID, uf, ambiente string) (*entity.ContingencyState, error)
+	ListActive(ctx context.Context) ([]*entity.ContingencyState, error)
+}
+
+// AdminRepository defines the persistence boundary for admin accounts.
+type AdminRepository interface {
+	Create(ctx context.Context, admin *entity.Admin) error
+	Update(ctx context.Context, admin *entity.Admin) error
+	GetByID(ctx context.Context, id string) (*entity.Admin, error)
+	GetByUsername(ctx context.Context, username string) (*entity.Admin, error)
+}
+
+// APIKeyRepository defines the persistence boundary for company API keys.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *entity.APIKey) error
+	Update(ctx context.Context, key *entity.APIKey) error
+	GetByID(ctx context.Context, id string) (*entity.APIKey, error)
+	GetByHash(ctx context.Context, keyHash string) (*entity.APIKey, error)
+	ListByCompanyID(ctx context.Context, companyID string) ([]*entity.APIKey, error)
+	// TouchLastUsed records the use of the key, at most once per minute
+	TouchLastUsed(ctx context.Context, id string, at time.Time) error
+}
+
+// EmailDeliveryRepository defines the persistence boundary for the NFC-e e-mail send log.
+type EmailDeliveryRepository interface {
+	Create(ctx context.Context, delivery *entity.EmailDelivery) error
+	// ListByRequestID lists the deliveries of an NFC-e, newest first
+	ListByRequestID(ctx context.Context, requestID string) ([]*entity.EmailDelivery, error)
+}
+
+// IdempotencyKeyRepository defines the persistence boundary for the Idempotency-Key records.
+type IdempotencyKeyRepository interface {
+	// CreateIfAbsent reserves the key, reporting false when the company already holds it
+	CreateIfAbsent(ctx context.Context, key *entity.IdempotencyKey) (bool, error)
+	GetByKey(ctx context.Context, companyID, key string) (*entity.IdempotencyKey, error)
+	Complete(ctx context.Context, id string, status int, body []byte, completedAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	// DeleteExpired removes up to limit keys expired before the time, returning how many
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// AuditFilter narrows the listing of audit entries; empty fields match everything
+type AuditFilter struct {
+	ActorType    entity.AuditActorType
+	ActorID      string
+	CompanyID    string
+	Action       string // Matches the actions containing it
+	ResourceType string
+	ResourceID   string
+	From         *time.Time
+	To           *time.Time
+}
+
+// AuditRepository defines the persistence boundary for the audit log.
+type AuditRepository interface {
+	Create(ctx context.Context, entry *entity.AuditEntry) error
+	// List returns the entries matching the filter, newest first, and how many match
+	List(ctx context.Context, filter AuditFilter, limit, offset int) ([]*entity.AuditEntry, int, error)
+}
+
+// NFCeLoteRepository defines the persistence boundary for the lotes of NFC-e.
+type NFCeLoteRepository interface {
+	Create(ctx context.Context, lote *entity.NFCeLote) error
+	GetByID(ctx context.Context, id string) (*entity.NFCeLote, error)
+}
+
+// OutboxRepository defines the persistence boundary for the transactional outbox.
+type OutboxRepository interface {
+	Create(ctx context.Context, msg *entity.OutboxMessage) error
+	// ClaimPending locks up to limit available messages for lease, so concurrent relays skip them
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*entity.OutboxMessage, error)
+	MarkSent(ctx context.Context, id string, sentAt time.Time) error
+	// MarkFailed records a failed publish and postpones the message until availableAt
+	MarkFailed(ctx context.Context, id string, reason string, availableAt time.Time) error
+	// DeleteSentBefore removes up to limit messages sent before the time, returning how many
+	DeleteSentBefore(ctx context.Context, before time.Time, limit int) (int64, error)
 }
 
 // Tx defines the minimal transaction contract used by the service layer.
@@ -79,3 +406,11 @@ type Tx interface {
 	Commit(ctx context.Context) error
 	Rollback(ctx context.Context) error
 }
+
+// TxManager runs repository calls atomically. The transaction travels in the
+// context: repositories called with the context passed to fn (or returned by
+// Begin) take part in it, and nested calls join the outer transaction.
+type TxManager interface {
+	Begin(ctx context.Context) (context.Context, Tx, error)
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
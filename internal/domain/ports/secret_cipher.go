@@ -0,0 +1,14 @@
+package ports
+
+// SecretCipher protects the secrets kept in the database, e.g. the company PFX. Seal returns
+// the stored form of a secret and Open reverses it. SealString and OpenString do the same
+// for the text columns, which hold the plaintext when written before encryption.
+type SecretCipher interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(stored []byte) ([]byte, error)
+	SealString(plaintext string) (string, error)
+	OpenString(stored string) (string, error)
+
+	// NeedsReencryption reports whether a stored secret is not sealed under the current key
+	NeedsReencryption(stored []byte) bool
+}
@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// WebhookDispatcher delivers events to the webhooks registered by a company.
+type WebhookDispatcher interface {
+	Dispatch(ctx context.Context, companyID string, event entity.WebhookEvent, payload map[string]interface{}) error
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// ErrEmissionBlocked is returned when a pre-emission check blocks the NFC-e
+var ErrEmissionBlocked = errors.New("emission blocked")
+
+// EmissionBlockedError describes the pre-emission check that blocked the NFC-e
+type EmissionBlockedError struct {
+	Check  string
+	Code   i18n.Code
+	Detail string
+}
+
+// Error implements the error interface
+func (e *EmissionBlockedError) Error() string {
+	message := i18n.Message(e.Code, i18n.English)
+	if e.Detail == "" {
+		return message
+	}
+	return fmt.Sprintf("%s: %s", message, e.Detail)
+}
+
+// Is makes errors.Is(err, ErrEmissionBlocked) match
+func (e *EmissionBlockedError) Is(target error) bool {
+	return target == ErrEmissionBlocked
+}
+
+// EmissionCheck is a pre-emission check evaluated against the rule configured under its name
+type EmissionCheck interface {
+	Name() string
+	Evaluate(ctx context.Context, nfceRequest *entity.NFCE, rule entity.FraudRule) (violated bool, detail string, err error)
+}
+
+// fraudCheckCodes are the error codes of the built-in checks; other checks use CodeEmissionBlocked
+var fraudCheckCodes = map[string]i18n.Code{
+	entity.FraudCheckVelocity:    i18n.CodeFraudVelocity,
+	entity.FraudCheckTicketValue: i18n.CodeFraudTicketValue,
+	entity.FraudCheckDuplicate:   i18n.CodeFraudDuplicate,
+}
+
+// FraudGuard runs the anti-fraud checks before an NFC-e is accepted for emission
+type FraudGuard struct {
+	companyRepo ports.CompanyRepository
+	checks      []EmissionCheck
+	logger      logger.Logger
+}
+
+// NewFraudGuard creates a fraud guard with the built-in velocity, ticket value and duplicate checks
+func NewFraudGuard(nfceRepo ports.NFCeRepository, companyRepo ports.CompanyRepository, l logger.Logger) *FraudGuard {
+	return &FraudGuard{
+		companyRepo: companyRepo,
+		checks: []EmissionCheck{
+			&velocityCheck{repo: nfceRepo},
+			&ticketValueCheck{},
+			&duplicateCheck{repo: nfceRepo},
+		},
+		logger: l,
+	}
+}
+
+// Register adds a check, configured by the company rule with the same name
+func (g *FraudGuard) Register(check EmissionCheck) {
+	g.checks = append(g.checks, check)
+}
+
+// Evaluate runs the checks enabled for the issuer and returns every decision. When a
+// violated check is configured to block, an EmissionBlockedError is returned too.
+func (g *FraudGuard) Evaluate(ctx context.Context, nfceRequest *entity.NFCE) (entity.FraudDecisions, error) {
+	rules := g.rules(ctx, nfceRequest.Payload.Emitente.CNPJ)
+
+	var decisions entity.FraudDecisions
+	for _, check := range g.checks {
+		rule, ok := rules[check.Name()]
+		if !ok || rule.Action == entity.FraudActionOff {
+			continue
+		}
+
+		violated, detail, err := check.Evaluate(ctx, nfceRequest, rule)
+		if err != nil {
+			// Log error but don't fail - a check failure must not stop the sale
+			logger.FromContext(ctx, g.logger).Warn("Failed to evaluate anti-fraud check", logger.Field{Key: "check", Value: check.Name()}, logger.RequestID(nfceRequest.ID), logger.Err(err))
+			continue
+		}
+
+		decisions = append(decisions, entity.FraudDecision{
+			Check:    check.Name(),
+			Action:   rule.Action,
+			Violated: violated,
+			Detail:   detail,
+		})
+	}
+
+	if blocking, ok := decisions.Blocking(); ok {
+		code, ok := fraudCheckCodes[blocking.Check]
+		if !ok {
+			code = i18n.CodeEmissionBlocked
+		}
+		return decisions, &EmissionBlockedError{Check: blocking.Check, Code: code, Detail: blocking.Detail}
+	}
+
+	return decisions, nil
+}
+
+// rules returns the effective rules of the issuer, or the defaults when it is not a registered company
+func (g *FraudGuard) rules(ctx context.Context, cnpj string) entity.FraudRules {
+	if g.companyRepo == nil || cnpj == "" {
+		return entity.DefaultFraudRules()
+	}
+
+	company, err := g.companyRepo.GetByCNPJ(ctx, cnpj)
+	if err != nil || company == nil {
+		return entity.DefaultFraudRules()
+	}
+	return company.FraudRules.Effective()
+}
+
+// velocityCheck limits how many NFC-e a terminal issues within the window
+type velocityCheck struct {
+	repo ports.NFCeRepository
+}
+
+func (c *velocityCheck) Name() string { return entity.FraudCheckVelocity }
+
+func (c *velocityCheck) Evaluate(ctx context.Context, nfceRequest *entity.NFCE, rule entity.FraudRule) (bool, string, error) {
+	if rule.Limit <= 0 || rule.WindowSeconds <= 0 {
+		return false, "", nil
+	}
+
+	payload := nfceRequest.Payload
+	count, err := c.repo.CountByTerminalSince(ctx, payload.Emitente.CNPJ, payload.Terminal, time.Now().Add(-rule.Window()))
+	if err != nil {
+		return false, "", fmt.Errorf("failed to count terminal NFC-e: %w", err)
+	}
+
+	// The NFC-e being checked is not persisted yet
+	if float64(count+1) > rule.Limit {
+		return true, fmt.Sprintf("%d NFC-e do terminal %q em %ds (limite %.0f)", count+1, payload.Terminal, rule.WindowSeconds, rule.Limit), nil
+	}
+	return false, "", nil
+}
+
+// ticketValueCheck flags NFC-e whose total is above the limit
+type ticketValueCheck struct{}
+
+func (c *ticketValueCheck) Name() string { return entity.FraudCheckTicketValue }
+
+func (c *ticketValueCheck) Evaluate(_ context.Context, nfceRequest *entity.NFCE, rule entity.FraudRule) (bool, string, error) {
+	if rule.Limit <= 0 {
+		return false, "", nil
+	}
+
+	total, limit := nfceRequest.Payload.Total(), money.FromFloat(rule.Limit)
+	if total > limit {
+		return true, fmt.Sprintf("total R$ %s acima do limite R$ %s", total, limit), nil
+	}
+	return false, "", nil
+}
+
+// duplicateCheck flags NFC-e with the same items and payments as a recent one of the issuer
+type duplicateCheck struct {
+	repo ports.NFCeRepository
+}
+
+func (c *duplicateCheck) Name() string { return entity.FraudCheckDuplicate }
+
+func (c *duplicateCheck) Evaluate(ctx context.Context, nfceRequest *entity.NFCE, rule entity.FraudRule) (bool, string, error) {
+	if rule.WindowSeconds <= 0 {
+		return false, "", nil
+	}
+
+	count, err := c.repo.CountIdenticalSince(ctx, nfceRequest.Payload, time.Now().Add(-rule.Window()))
+	if err != nil {
+		return false, "", fmt.Errorf("failed to count identical NFC-e: %w", err)
+	}
+
+	if count > 0 {
+		return true, fmt.Sprintf("%d NFC-e idêntica(s) em %ds", count, rule.WindowSeconds), nil
+	}
+	return false, "", nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+// auditEntryKey is the context key of the audit entry of the current request
+type auditEntryKey struct{}
+
+// WithAuditEntry attaches the audit entry of a request to ctx, so the use cases can
+// describe the resource they changed
+func WithAuditEntry(ctx context.Context, entry *entity.AuditEntry) context.Context {
+	return context.WithValue(ctx, auditEntryKey{}, entry)
+}
+
+// AuditChanged records on the audit entry of ctx the resource changed by the request and
+// the diff between its before snapshot and after; nil before means it was created and nil
+// after that it was removed. Without an entry in ctx, e.g. in the worker, it does nothing.
+func AuditChanged(ctx context.Context, resourceType, resourceID string, before entity.AuditSnapshot, after any) {
+	entry, ok := ctx.Value(auditEntryKey{}).(*entity.AuditEntry)
+	if !ok || entry == nil {
+		return
+	}
+	entry.ResourceType = resourceType
+	entry.ResourceID = resourceID
+	entry.Changes = before.Diff(entity.NewAuditSnapshot(after))
+}
+
+// AuditRelated records, along with the audit entry of ctx, a further resource changed by the
+// request, e.g. the API keys revoked when a company is deleted. Without an entry in ctx it
+// does nothing.
+func AuditRelated(ctx context.Context, resourceType, resourceID string, changes entity.AuditChanges) {
+	entry, ok := ctx.Value(auditEntryKey{}).(*entity.AuditEntry)
+	if !ok || entry == nil {
+		return
+	}
+	entry.Related = append(entry.Related, entry.NewRelatedEntry(resourceType, resourceID, changes))
+}
+
+// AuditCompany records on the audit entry of ctx the company the request applies to
+func AuditCompany(ctx context.Context, companyID string) {
+	entry, ok := ctx.Value(auditEntryKey{}).(*entity.AuditEntry)
+	if !ok || entry == nil || companyID == "" {
+		return
+	}
+	entry.CompanyID = &companyID
+}
+
+// AuditService keeps the audit log of the writes made through the API
+type AuditService struct {
+	repo ports.AuditRepository
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(repo ports.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record stores a completed audit entry and its related entries, which share its status
+func (s *AuditService) Record(ctx context.Context, entry *entity.AuditEntry) error {
+	if err := s.repo.Create(ctx, entry); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	for _, related := range entry.Related {
+		related.Status = entry.Status
+		if err := s.repo.Create(ctx, related); err != nil {
+			return fmt.Errorf("failed to record related audit entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// List returns the audit entries matching the filter, newest first, and how many match
+func (s *AuditService) List(ctx context.Context, filter ports.AuditFilter, limit, offset int) ([]*entity.AuditEntry, int, error) {
+	return s.repo.List(ctx, filter, limit, offset)
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/payment"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// billingBatchSize is the number of subscriptions or invoices charged per page of a run
+const billingBatchSize = 100
+
+// ErrPaymentGatewayNotFound is returned for the webhooks of a gateway other than the configured one
+var ErrPaymentGatewayNotFound = errors.New("payment gateway not configured")
+
+// BillingConfig controls the charges of the subscriptions
+type BillingConfig struct {
+	Interval      time.Duration // How often the due subscriptions are charged
+	RetryInterval time.Duration // Wait before charging a failed invoice again
+	MaxAttempts   int           // Failed charges of an invoice before the subscription is suspended
+}
+
+// BillingService charges the subscriptions through the payment gateway: every billing period
+// becomes an invoice, a failed charge is retried until MaxAttempts and then suspends the
+// subscription, and a payment confirmed by the gateway webhook reactivates it
+type BillingService struct {
+	subscriptionRepo ports.SubscriptionRepository
+	planRepo         ports.PlanRepository
+	invoiceRepo      ports.InvoiceRepository
+	eventRepo        ports.SubscriptionEventRepository
+	txManager        ports.TxManager
+	gateway          payment.Gateway
+	webhooks         ports.WebhookDispatcher
+	config           BillingConfig
+	logger           logger.Logger
+}
+
+// NewBillingService creates a new billing service; a nil gateway disables the billing
+func NewBillingService(
+	subscriptionRepo ports.SubscriptionRepository,
+	planRepo ports.PlanRepository,
+	invoiceRepo ports.InvoiceRepository,
+	eventRepo ports.SubscriptionEventRepository,
+	txManager ports.TxManager,
+	gateway payment.Gateway,
+	webhooks ports.WebhookDispatcher,
+	config BillingConfig,
+	l logger.Logger,
+) *BillingService {
+	return &BillingService{
+		subscriptionRepo: subscriptionRepo,
+		planRepo:         planRepo,
+		invoiceRepo:      invoiceRepo,
+		eventRepo:        eventRepo,
+		txManager:        txManager,
+		gateway:          gateway,
+		webhooks:         webhooks,
+		config:           config,
+		logger:           l,
+	}
+}
+
+// Enabled reports whether a payment gateway is configured
+func (s *BillingService) Enabled() bool {
+	return s.gateway != nil
+}
+
+// Interval returns how often the due subscriptions are charged
+func (s *BillingService) Interval() time.Duration {
+	return s.config.Interval
+}
+
+// BillDue invoices and charges the subscriptions whose billing date has come, then charges
+// again the failed invoices due for a retry. A subscription with an open invoice is billed
+// for the next period only once that invoice is settled. It returns the invoices paid.
+func (s *BillingService) BillDue(ctx context.Context, now time.Time) (int, error) {
+	paid := 0
+	var errs []error
+
+	subscriptions, err := s.subscriptionRepo.ListBillingDue(ctx, now, billingBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list subscriptions due for billing: %w", err)
+	}
+	for _, subscription := range subscriptions {
+		invoice, err := s.invoice(ctx, subscription)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+			continue
+		}
+		if invoice == nil {
+			continue
+		}
+		if invoice.Amount == 0 {
+			// Fully covered by the credit of a downgrade, nothing to charge
+			if err := s.settle(ctx, invoice, subscription, "", now); err != nil {
+				errs = append(errs, fmt.Errorf("invoice %s: %w", invoice.ID, err))
+				continue
+			}
+			paid++
+			continue
+		}
+		ok, err := s.charge(ctx, invoice, subscription, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invoice %s: %w", invoice.ID, err))
+		}
+		if ok {
+			paid++
+		}
+	}
+
+	invoices, err := s.invoiceRepo.ListRetryDue(ctx, now, billingBatchSize)
+	if err != nil {
+		return paid, errors.Join(append(errs, fmt.Errorf("failed to list invoices due for retry: %w", err))...)
+	}
+	for _, invoice := range invoices {
+		ok, err := s.retry(ctx, invoice, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invoice %s: %w", invoice.ID, err))
+		}
+		if ok {
+			paid++
+		}
+	}
+
+	return paid, errors.Join(errs...)
+}
+
+// invoice creates the invoice of the next billing period of the subscription, moving its
+// billing date one cycle ahead; nil while an earlier invoice is open
+func (s *BillingService) invoice(ctx context.Context, subscription *entity.Subscription) (*entity.Invoice, error) {
+	open, err := s.invoiceRepo.GetOpenBySubscriptionID(ctx, subscription.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load open invoice: %w", err)
+	}
+	if open != nil {
+		return nil, nil
+	}
+
+	plan, err := s.planRepo.GetByID(ctx, subscription.PlanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plan: %w", err)
+	}
+
+	periodStart, periodEnd := subscription.AdvanceBilling(plan)
+	invoice, err := entity.NewInvoice(subscription, s.gateway.Provider(), periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// The prorations of the plan changes since the last invoice are billed on this one
+	events, err := s.eventRepo.ListUnbilled(ctx, subscription.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plan changes: %w", err)
+	}
+	ids := make([]string, len(events))
+	adjustments := make([]money.Amount, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+		adjustments[i] = money.FromFloat(event.Amount)
+	}
+	carried := invoice.ApplyAdjustment(money.Sum(adjustments...).Float64())
+
+	create := func(ctx context.Context) error {
+		if err := s.invoiceRepo.Create(ctx, invoice); err != nil {
+			return fmt.Errorf("failed to create invoice: %w", err)
+		}
+		if err := s.eventRepo.MarkBilled(ctx, ids, invoice.ID); err != nil {
+			return fmt.Errorf("failed to bill plan changes: %w", err)
+		}
+		if carried != 0 {
+			if err := s.eventRepo.Create(ctx, entity.NewCreditCarriedEvent(subscription, carried, invoice.CreatedAt)); err != nil {
+				return fmt.Errorf("failed to carry credit: %w", err)
+			}
+		}
+		if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+			return fmt.Errorf("failed to update billing date: %w", err)
+		}
+		return nil
+	}
+	if err := s.withinTx(ctx, create); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// retry charges a failed invoice again, voiding it when the subscription no longer renews
+func (s *BillingService) retry(ctx context.Context, invoice *entity.Invoice, now time.Time) (bool, error) {
+	subscription, err := s.subscriptionRepo.GetByID(ctx, invoice.SubscriptionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load subscription: %w", err)
+	}
+
+	if subscription.Status == entity.SubscriptionStatusCanceled || subscription.Status == entity.SubscriptionStatusExpired {
+		invoice.Void(now)
+		return false, s.invoiceRepo.Update(ctx, invoice)
+	}
+	return s.charge(ctx, invoice, subscription, now)
+}
+
+// charge charges the invoice on the gateway and records the outcome, reporting whether it was paid
+func (s *BillingService) charge(ctx context.Context, invoice *entity.Invoice, subscription *entity.Subscription, now time.Time) (bool, error) {
+	invoice.Attempts++
+	result, err := s.gateway.Charge(ctx, &payment.ChargeRequest{
+		InvoiceID:      invoice.ID,
+		IdempotencyKey: fmt.Sprintf("%s-%d", invoice.ID, invoice.Attempts),
+		CustomerID:     subscription.BillingInfo.CustomerID,
+		PaymentMethod:  subscription.BillingInfo.PaymentMethod,
+		Amount:         money.FromFloat(invoice.Amount),
+		Currency:       invoice.Currency,
+		Description: fmt.Sprintf("Assinatura PlugNFC-e de %s a %s",
+			invoice.PeriodStart.Format("02/01/2006"), invoice.PeriodEnd.Format("02/01/2006")),
+	})
+	if err != nil {
+		// The gateway could not be reached or refused the request: counted as a failed attempt
+		return false, errors.Join(err, s.fail(ctx, invoice, subscription, "", err.Error(), now))
+	}
+
+	switch result.Status {
+	case payment.ChargeStatusPaid:
+		return true, s.settle(ctx, invoice, subscription, result.ID, now)
+	case payment.ChargeStatusPending:
+		invoice.MarkAwaiting(result.ID, now)
+		if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
+			return false, fmt.Errorf("failed to update invoice: %w", err)
+		}
+		return false, nil
+	default:
+		return false, s.fail(ctx, invoice, subscription, result.ID, result.FailureReason, now)
+	}
+}
+
+// settle marks the invoice paid and records the payment on the subscription, reactivating it
+// when it was suspended for lack of payment
+func (s *BillingService) settle(ctx context.Context, invoice *entity.Invoice, subscription *entity.Subscription, chargeID string, now time.Time) error {
+	invoice.MarkPaid(chargeID, now)
+
+	reactivated := false
+	persist := func(ctx context.Context) error {
+		if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
+			return fmt.Errorf("failed to update invoice: %w", err)
+		}
+
+		// A company that subscribed to another plan meanwhile keeps it
+		if subscription.Status == entity.SubscriptionStatusSuspended {
+			if current, err := s.subscriptionRepo.GetActiveByCompanyID(ctx, subscription.CompanyID); err == nil && current != nil {
+				return nil
+			}
+		}
+		reactivated = subscription.RecordPayment(now)
+		if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+		return nil
+	}
+	if err := s.withinTx(ctx, persist); err != nil {
+		return err
+	}
+
+	if reactivated {
+		s.notify(ctx, subscription, entity.WebhookEventSubscriptionReactivated, invoice, map[string]interface{}{
+			"reactivated_at": now,
+		})
+	}
+	return nil
+}
+
+// fail records a failed charge, scheduling a retry until MaxAttempts and suspending the
+// subscription after the last one
+func (s *BillingService) fail(ctx context.Context, invoice *entity.Invoice, subscription *entity.Subscription, chargeID, reason string, now time.Time) error {
+	suspend := invoice.Attempts >= s.config.MaxAttempts && subscription.IsActive()
+
+	var retryAt *time.Time
+	if invoice.Attempts < s.config.MaxAttempts {
+		next := now.Add(s.config.RetryInterval)
+		retryAt = &next
+	}
+	invoice.MarkFailed(chargeID, reason, retryAt, now)
+
+	persist := func(ctx context.Context) error {
+		if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
+			return fmt.Errorf("failed to update invoice: %w", err)
+		}
+		if suspend {
+			subscription.Suspend()
+			if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+				return fmt.Errorf("failed to suspend subscription: %w", err)
+			}
+		}
+		return nil
+	}
+	if err := s.withinTx(ctx, persist); err != nil {
+		return err
+	}
+
+	if suspend {
+		s.notify(ctx, subscription, entity.WebhookEventSubscriptionSuspended, invoice, map[string]interface{}{
+			"reason":       reason,
+			"attempts":     invoice.Attempts,
+			"suspended_at": subscription.SuspendedAt,
+		})
+	}
+	return nil
+}
+
+// HandleWebhook settles the invoice of a payment confirmed or declined by the gateway. The
+// events are idempotent: a paid invoice ignores them, and events of unknown charges are dropped.
+func (s *BillingService) HandleWebhook(ctx context.Context, provider string, payload []byte, header http.Header) error {
+	if !s.Enabled() || provider != s.gateway.Provider() {
+		return ErrPaymentGatewayNotFound
+	}
+
+	event, err := s.gateway.ParseWebhook(ctx, payload, header)
+	if err != nil || event == nil {
+		return err
+	}
+
+	invoice, err := s.eventInvoice(ctx, event)
+	if err != nil || invoice == nil || !invoice.IsOpen() {
+		return err
+	}
+
+	subscription, err := s.subscriptionRepo.GetByID(ctx, invoice.SubscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to load subscription: %w", err)
+	}
+
+	now := time.Now()
+	switch event.Type {
+	case payment.EventPaymentSucceeded:
+		return s.settle(ctx, invoice, subscription, event.ChargeID, now)
+	case payment.EventPaymentFailed:
+		// Only the outcome of the charge awaiting confirmation counts as an attempt
+		if invoice.Status != entity.InvoiceStatusPending || invoice.ChargeID != event.ChargeID {
+			return nil
+		}
+		return s.fail(ctx, invoice, subscription, event.ChargeID, event.FailureReason, now)
+	}
+	return nil
+}
+
+// eventInvoice finds the invoice of a webhook by the invoice ID echoed in the charge metadata
+// or by the charge ID
+func (s *BillingService) eventInvoice(ctx context.Context, event *payment.Event) (*entity.Invoice, error) {
+	if event.InvoiceID != "" {
+		invoice, err := s.invoiceRepo.GetByID(ctx, event.InvoiceID)
+		if err == nil {
+			return invoice, nil
+		}
+	}
+	if event.ChargeID == "" {
+		return nil, nil
+	}
+	return s.invoiceRepo.GetByChargeID(ctx, s.gateway.Provider(), event.ChargeID)
+}
+
+// notify dispatches a subscription billing webhook to the company
+func (s *BillingService) notify(ctx context.Context, subscription *entity.Subscription, event entity.WebhookEvent, invoice *entity.Invoice, data map[string]interface{}) {
+	if s.webhooks == nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"plan_id":         subscription.PlanID,
+		"invoice_id":      invoice.ID,
+		"amount":          invoice.Amount,
+		"currency":        invoice.Currency,
+	}
+	for key, value := range data {
+		payload[key] = value
+	}
+
+	if err := s.webhooks.Dispatch(ctx, subscription.CompanyID, event, payload); err != nil {
+		logger.FromContext(ctx, s.logger).Warn("Failed to dispatch billing webhook", logger.Field{Key: "event", Value: string(event)}, logger.CompanyID(subscription.CompanyID), logger.Err(err))
+	}
+}
+
+// withinTx runs fn in a transaction when a transaction manager is configured
+func (s *BillingService) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.txManager == nil {
+		return fn(ctx)
+	}
+	return s.txManager.WithinTx(ctx, fn)
+}
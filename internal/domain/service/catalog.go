@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+// ErrInvalidFiscalCodes is returned when items carry NCM, CEST or CFOP codes SEFAZ would reject
+var ErrInvalidFiscalCodes = errors.New("invalid fiscal codes")
+
+// Field error codes of the catalog validation
+const (
+	FieldCodeNCMInvalid      = "ncm_invalid"
+	FieldCodeNCMNotFound     = "ncm_not_found"
+	FieldCodeNCMExpired      = "ncm_expired"
+	FieldCodeCFOPNotAllowed  = "cfop_not_allowed"
+	FieldCodeCESTRequired    = "cest_required"
+	FieldCodeCESTInvalid     = "cest_invalid"
+	FieldCodeCESTNotFound    = "cest_not_found"
+	FieldCodeCESTNCMMismatch = "cest_ncm_mismatch"
+)
+
+var (
+	ncmPattern  = regexp.MustCompile(`^\d{8}$`)
+	cestPattern = regexp.MustCompile(`^\d{7}$`)
+)
+
+// FiscalCodesError lists the item fields that failed the catalog validation
+type FiscalCodesError struct {
+	Fields []entity.FieldError
+}
+
+// Error implements the error interface
+func (e *FiscalCodesError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = fmt.Sprintf("%s: %s", field.Field, field.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidFiscalCodes.Error(), strings.Join(messages, "; "))
+}
+
+// Is allows errors.Is(err, ErrInvalidFiscalCodes)
+func (e *FiscalCodesError) Is(target error) bool {
+	return target == ErrInvalidFiscalCodes
+}
+
+// FieldErrorsOf returns the field errors carried by err, if any
+func FieldErrorsOf(err error) []entity.FieldError {
+	var codesErr *FiscalCodesError
+	if errors.As(err, &codesErr) {
+		return codesErr.Fields
+	}
+	return nil
+}
+
+// CatalogService validates the item NCM, CEST and CFOP against the catalog tables
+// before the XML is built, instead of waiting for SEFAZ rejections 778 and 693
+type CatalogService struct {
+	repo ports.CatalogRepository
+}
+
+// NewCatalogService creates a new catalog service
+func NewCatalogService(repo ports.CatalogRepository) *CatalogService {
+	return &CatalogService{repo: repo}
+}
+
+// ValidateItems checks the fiscal codes of the items of a document model, returning a
+// *FiscalCodesError with one entry per invalid field. The CFOP catalog lists the operations
+// of the NFC-e; the NF-e takes any CFOP, its format checked with the payload.
+func (s *CatalogService) ValidateItems(ctx context.Context, modelo string, itens []entity.Item) error {
+	var fields []entity.FieldError
+	addField := func(i int, name, code, message string) {
+		fields = append(fields, entity.FieldError{
+			Field:   fmt.Sprintf("itens[%d].%s", i, name),
+			Code:    code,
+			Message: message,
+		})
+	}
+
+	ncms, cests, cfops := uniqueCodes(itens)
+
+	cfopEntries, err := s.repo.FindCFOPs(ctx, cfops)
+	if err != nil {
+		return fmt.Errorf("failed to look up CFOP catalog: %w", err)
+	}
+	// The NCM and CEST tables are imported separately; until then only the format is checked
+	var cestEntries map[string][]entity.CESTCatalogEntry
+	hasCESTs, err := s.repo.HasCESTs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up CEST catalog: %w", err)
+	}
+	if hasCESTs {
+		if cestEntries, err = s.repo.FindCESTs(ctx, cests); err != nil {
+			return fmt.Errorf("failed to look up CEST catalog: %w", err)
+		}
+	}
+
+	var ncmEntries map[string]entity.NCMCatalogEntry
+	hasNCMs, err := s.repo.HasNCMs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up NCM catalog: %w", err)
+	}
+	if hasNCMs {
+		if ncmEntries, err = s.repo.FindNCMs(ctx, ncms); err != nil {
+			return fmt.Errorf("failed to look up NCM catalog: %w", err)
+		}
+	}
+
+	now := time.Now()
+	for i, item := range itens {
+		ncmValid := ncmPattern.MatchString(item.NCM)
+		switch {
+		case !ncmValid:
+			addField(i, "ncm", FieldCodeNCMInvalid, "NCM deve ter 8 dígitos, sem pontos")
+		case hasNCMs:
+			entry, ok := ncmEntries[item.NCM]
+			if !ok {
+				addField(i, "ncm", FieldCodeNCMNotFound, fmt.Sprintf("NCM %s inexistente na tabela vigente", item.NCM))
+			} else if !entry.ValidAt(now) {
+				addField(i, "ncm", FieldCodeNCMExpired, fmt.Sprintf("NCM %s fora de vigência", item.NCM))
+			}
+		}
+
+		cfop, ok := cfopEntries[item.CFOP]
+		if modelo != entity.ModeloNFe && (!ok || !cfop.NFCe) {
+			addField(i, "cfop", FieldCodeCFOPNotAllowed, fmt.Sprintf("CFOP %s não permitido na NFC-e (venda a consumidor final dentro da UF)", item.CFOP))
+		}
+
+		switch {
+		case item.CEST == "":
+			if ok && cfop.ST {
+				addField(i, "cest", FieldCodeCESTRequired, fmt.Sprintf("CEST obrigatório para mercadoria sujeita a substituição tributária (CFOP %s)", item.CFOP))
+			}
+		case !cestPattern.MatchString(item.CEST):
+			addField(i, "cest", FieldCodeCESTInvalid, "CEST deve ter 7 dígitos, sem pontos")
+		case hasCESTs:
+			entries, found := cestEntries[item.CEST]
+			if !found {
+				addField(i, "cest", FieldCodeCESTNotFound, fmt.Sprintf("CEST %s inexistente", item.CEST))
+			} else if ncmValid && !cestCovers(entries, item.NCM) {
+				addField(i, "cest", FieldCodeCESTNCMMismatch, fmt.Sprintf("CEST %s não corresponde ao NCM %s", item.CEST, item.NCM))
+			}
+		}
+	}
+
+	if len(fields) > 0 {
+		return &FiscalCodesError{Fields: fields}
+	}
+	return nil
+}
+
+// uniqueCodes collects the distinct NCM, CEST and CFOP codes of the items
+func uniqueCodes(itens []entity.Item) (ncms, cests, cfops []string) {
+	seen := map[string]bool{}
+	add := func(list []string, kind, code string) []string {
+		if code == "" || seen[kind+code] {
+			return list
+		}
+		seen[kind+code] = true
+		return append(list, code)
+	}
+
+	for _, item := range itens {
+		ncms = add(ncms, "ncm", item.NCM)
+		cests = add(cests, "cest", item.CEST)
+		cfops = add(cfops, "cfop", item.CFOP)
+	}
+	return ncms, cests, cfops
+}
+
+// cestCovers reports whether any NCM entry of the CEST applies to the NCM
+func cestCovers(entries []entity.CESTCatalogEntry, ncm string) bool {
+	for _, entry := range entries {
+		if entry.Covers(ncm) {
+			return true
+		}
+	}
+	return false
+}
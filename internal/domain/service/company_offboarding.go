@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+const (
+	// offboardingActor and offboardingAction identify on the audit log the changes made by the
+	// worker to the deleted companies
+	offboardingActor  = "worker"
+	offboardingAction = "company offboarding"
+
+	// offboardingCancelReason is the cancel reason of the subscription of a deleted company
+	offboardingCancelReason = "empresa excluída"
+)
+
+// CompanyOffboardingConfig controls the LGPD offboarding of the deleted companies
+type CompanyOffboardingConfig struct {
+	CPFRetentionDays   int           // Age past which the destinatário CPFs of the NFC-e are anonymized
+	StorageCleanupDays int           // Days between the deletion and the deletion of the documents
+	Interval           time.Duration // How often the worker processes the deleted companies
+}
+
+// OffboardingResult summarizes the deletion of a company
+type OffboardingResult struct {
+	Company              *entity.Company
+	APIKeysRevoked       int
+	SubscriptionCanceled bool
+	NFCeAnonymized       int
+}
+
+// CompanyOffboardingService deletes companies as required by the LGPD: the company is soft
+// deleted, its API keys revoked, its subscription canceled, its certificate and CSC secrets
+// purged and the destinatário CPFs of its NFC-e past the retention anonymized; its documents
+// are deleted from storage after a grace period. Every change is recorded on the audit log.
+type CompanyOffboardingService struct {
+	companyRepo      ports.CompanyRepository
+	apiKeyRepo       ports.APIKeyRepository
+	subscriptionRepo ports.SubscriptionRepository
+	nfceRepo         ports.NFCeRepository
+	txManager        ports.TxManager
+	audit            *AuditService
+	purge            *DocumentPurgeService
+	config           CompanyOffboardingConfig
+}
+
+// NewCompanyOffboardingService creates a new company offboarding service
+func NewCompanyOffboardingService(
+	companyRepo ports.CompanyRepository,
+	apiKeyRepo ports.APIKeyRepository,
+	subscriptionRepo ports.SubscriptionRepository,
+	nfceRepo ports.NFCeRepository,
+	txManager ports.TxManager,
+	audit *AuditService,
+	purge *DocumentPurgeService,
+	config CompanyOffboardingConfig,
+) *CompanyOffboardingService {
+	return &CompanyOffboardingService{
+		companyRepo:      companyRepo,
+		apiKeyRepo:       apiKeyRepo,
+		subscriptionRepo: subscriptionRepo,
+		nfceRepo:         nfceRepo,
+		txManager:        txManager,
+		audit:            audit,
+		purge:            purge,
+		config:           config,
+	}
+}
+
+// Interval returns how often the deleted companies are processed
+func (s *CompanyOffboardingService) Interval() time.Duration {
+	return s.config.Interval
+}
+
+// Offboard deletes the company in a single transaction and schedules the deletion of its
+// documents. The changes are recorded on the audit entry of the request in ctx.
+func (s *CompanyOffboardingService) Offboard(ctx context.Context, company *entity.Company, now time.Time) (*OffboardingResult, error) {
+	before := entity.NewAuditSnapshot(company)
+	if err := company.Offboard(now, now.AddDate(0, 0, s.config.StorageCleanupDays)); err != nil {
+		return nil, err
+	}
+
+	result := &OffboardingResult{Company: company}
+	var related []*entity.AuditEntry
+	persist := func(ctx context.Context) error {
+		related = nil
+		if err := s.companyRepo.Update(ctx, company); err != nil {
+			return fmt.Errorf("failed to delete company: %w", err)
+		}
+
+		keys, err := s.apiKeyRepo.ListByCompanyID(ctx, company.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list API keys: %w", err)
+		}
+		result.APIKeysRevoked = 0
+		for _, key := range keys {
+			if key.RevokedAt != nil {
+				continue
+			}
+			keyBefore := entity.NewAuditSnapshot(key)
+			key.Revoke()
+			if err := s.apiKeyRepo.Update(ctx, key); err != nil {
+				return fmt.Errorf("failed to revoke API key %s: %w", key.ID, err)
+			}
+			result.APIKeysRevoked++
+			related = append(related, relatedChange(entity.AuditResourceAPIKey, key.ID, keyBefore.Diff(entity.NewAuditSnapshot(key))))
+		}
+
+		result.SubscriptionCanceled = false
+		if subscription, err := s.subscriptionRepo.GetActiveByCompanyID(ctx, company.ID); err == nil {
+			subscriptionBefore := entity.NewAuditSnapshot(subscription)
+			subscription.Cancel(offboardingCancelReason)
+			if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+				return fmt.Errorf("failed to cancel subscription: %w", err)
+			}
+			result.SubscriptionCanceled = true
+			related = append(related, relatedChange(entity.AuditResourceSubscription, subscription.ID, subscriptionBefore.Diff(entity.NewAuditSnapshot(subscription))))
+		}
+
+		cutoff := s.cpfCutoff(now)
+		anonymized, err := s.nfceRepo.AnonymizeDestinatarios(ctx, company.ID, cutoff)
+		if err != nil {
+			return fmt.Errorf("failed to anonymize destinatários: %w", err)
+		}
+		result.NFCeAnonymized = anonymized
+		if anonymized > 0 {
+			related = append(related, relatedChange(entity.AuditResourceNFCe, company.ID, anonymizedChanges(anonymized, cutoff)))
+		}
+		return nil
+	}
+
+	var err error
+	if s.txManager == nil {
+		err = persist(ctx)
+	} else {
+		err = s.txManager.WithinTx(ctx, persist)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	AuditCompany(ctx, company.ID)
+	AuditChanged(ctx, entity.AuditResourceCompany, company.ID, before, company)
+	for _, entry := range related {
+		AuditRelated(ctx, entry.ResourceType, entry.ResourceID, entry.Changes)
+	}
+	return result, nil
+}
+
+// ProcessDeleted anonymizes the destinatário CPFs of the deleted companies that went past the
+// retention since the deletion and deletes the documents of those whose grace period ended.
+// It returns the number of NFC-e whose documents were deleted.
+func (s *CompanyOffboardingService) ProcessDeleted(ctx context.Context, now time.Time) (int, error) {
+	const pageSize = 100
+
+	purged := 0
+	var errs []error
+	for offset := 0; ; offset += pageSize {
+		companies, total, err := s.companyRepo.ListDeleted(ctx, pageSize, offset)
+		if err != nil {
+			return purged, fmt.Errorf("failed to list deleted companies: %w", err)
+		}
+
+		for _, company := range companies {
+			count, err := s.processDeleted(ctx, company, now)
+			purged += count
+			if err != nil {
+				errs = append(errs, fmt.Errorf("company %s: %w", company.ID, err))
+			}
+		}
+
+		if len(companies) < pageSize || offset+pageSize >= total {
+			break
+		}
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+// processDeleted anonymizes and cleans up a deleted company, recording what it changed on
+// the audit log as the system
+func (s *CompanyOffboardingService) processDeleted(ctx context.Context, company *entity.Company, now time.Time) (int, error) {
+	cutoff := s.cpfCutoff(now)
+	anonymized, err := s.nfceRepo.AnonymizeDestinatarios(ctx, company.ID, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to anonymize destinatários: %w", err)
+	}
+	if anonymized > 0 {
+		entry := s.systemEntry(company.ID, entity.AuditResourceNFCe, company.ID, anonymizedChanges(anonymized, cutoff))
+		if err := s.audit.Record(ctx, entry); err != nil {
+			return 0, err
+		}
+	}
+
+	if company.StorageCleanedAt != nil || company.StorageCleanupAt == nil || now.Before(*company.StorageCleanupAt) {
+		return 0, nil
+	}
+
+	// A company is cleaned up only once every document was deleted; failures are retried
+	purged, err := s.purge.PurgeDeletedCompany(ctx, company.ID, now)
+	if err != nil {
+		return purged, err
+	}
+
+	before := entity.NewAuditSnapshot(company)
+	company.StorageCleanedAt = &now
+	company.UpdatedAt = now
+	if err := s.companyRepo.Update(ctx, company); err != nil {
+		return purged, fmt.Errorf("failed to mark storage as cleaned: %w", err)
+	}
+
+	changes := before.Diff(entity.NewAuditSnapshot(company))
+	changes["documents_purged"] = entity.AuditChange{After: purged}
+	if err := s.audit.Record(ctx, s.systemEntry(company.ID, entity.AuditResourceCompany, company.ID, changes)); err != nil {
+		return purged, err
+	}
+	return purged, nil
+}
+
+// cpfCutoff returns the creation time before which the NFC-e are past the CPF retention
+func (s *CompanyOffboardingService) cpfCutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -s.config.CPFRetentionDays)
+}
+
+// systemEntry builds an audit entry of a change made by the worker to a deleted company
+func (s *CompanyOffboardingService) systemEntry(companyID, resourceType, resourceID string, changes entity.AuditChanges) *entity.AuditEntry {
+	entry := entity.NewAuditEntry(entity.AuditActorSystem, offboardingActor, offboardingAction)
+	entry.CompanyID = &companyID
+	entry.ResourceType = resourceType
+	entry.ResourceID = resourceID
+	entry.Changes = changes
+	return entry
+}
+
+// relatedChange holds a change to record along with the audit entry of the request
+func relatedChange(resourceType, resourceID string, changes entity.AuditChanges) *entity.AuditEntry {
+	return &entity.AuditEntry{ResourceType: resourceType, ResourceID: resourceID, Changes: changes}
+}
+
+// anonymizedChanges describes the anonymization of the destinatários of a company NFC-e
+func anonymizedChanges(count int, cutoff time.Time) entity.AuditChanges {
+	return entity.AuditChanges{
+		"anonymized_destinatarios": {After: count},
+		"created_before":           {After: cutoff},
+	}
+}
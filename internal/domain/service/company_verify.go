@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/signer"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// Checks run by the company verification, in order
+const (
+	ReadinessCheckProfile      = "profile"
+	ReadinessCheckCertificate  = "certificate"
+	ReadinessCheckCSC          = "csc"
+	ReadinessCheckSEFAZStatus  = "sefaz_status"
+	ReadinessCheckTestEmission = "test_emission"
+)
+
+// Outcomes of a readiness check
+const (
+	ReadinessPassed  = "passed"
+	ReadinessFailed  = "failed"
+	ReadinessSkipped = "skipped"
+)
+
+// testEmissionDescription is the xProd SEFAZ requires on the first item of NFC-e issued in homologação
+const testEmissionDescription = "NOTA FISCAL EMITIDA EM AMBIENTE DE HOMOLOGACAO - SEM VALOR FISCAL"
+
+// ReadinessCheck is the outcome of one verification check
+type ReadinessCheck struct {
+	Name    string         `json:"name"`
+	Status  string         `json:"status"`
+	Message string         `json:"message,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// CompanyReadiness is the report of a company verification: the company is ready to emit
+// when every check passed
+type CompanyReadiness struct {
+	CompanyID  string           `json:"company_id"`
+	Ready      bool             `json:"ready"`
+	Checks     []ReadinessCheck `json:"checks"`
+	VerifiedAt time.Time        `json:"verified_at"`
+}
+
+// passed reports whether the named check passed
+func (r *CompanyReadiness) passed(name string) bool {
+	for _, check := range r.Checks {
+		if check.Name == name {
+			return check.Status == ReadinessPassed
+		}
+	}
+	return false
+}
+
+// CompanyVerifier runs the pre-flight checks of a company before its first emission: the
+// registry, the certificate, the CSC, the SEFAZ status service of its UF and a test
+// emission in homologação
+type CompanyVerifier struct {
+	companyRepo ports.CompanyRepository
+	worker      *NFCeWorkerService
+	soapClient  soapclient.Client
+	nfceDomain  *NFCeDomainService
+}
+
+// NewCompanyVerifier creates a new company verifier
+func NewCompanyVerifier(companyRepo ports.CompanyRepository, worker *NFCeWorkerService, soapClient soapclient.Client) *CompanyVerifier {
+	return &CompanyVerifier{
+		companyRepo: companyRepo,
+		worker:      worker,
+		soapClient:  soapClient,
+		nfceDomain:  NewNFCeDomainService(),
+	}
+}
+
+// Verify runs every check and returns the readiness report. Failed checks are part of the
+// report; an error is only returned when the company can not be loaded.
+func (v *CompanyVerifier) Verify(ctx context.Context, companyID string) (*CompanyReadiness, error) {
+	company, err := v.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CompanyReadiness{CompanyID: company.ID, VerifiedAt: time.Now()}
+	report.Checks = append(report.Checks, v.checkProfile(company))
+
+	certificate, check := v.checkCertificate(ctx, company)
+	report.Checks = append(report.Checks, check)
+	report.Checks = append(report.Checks, v.checkCSC(company, report.VerifiedAt))
+	report.Checks = append(report.Checks, v.checkSEFAZStatus(ctx, company))
+
+	// The test emission needs the emitente data and a working signing key
+	if report.passed(ReadinessCheckProfile) && report.passed(ReadinessCheckCertificate) {
+		report.Checks = append(report.Checks, v.checkTestEmission(ctx, company, certificate))
+	} else {
+		report.Checks = append(report.Checks, ReadinessCheck{
+			Name:    ReadinessCheckTestEmission,
+			Status:  ReadinessSkipped,
+			Message: "requires the profile and certificate checks to pass",
+		})
+	}
+
+	report.Ready = true
+	for _, check := range report.Checks {
+		if check.Status != ReadinessPassed {
+			report.Ready = false
+		}
+	}
+	return report, nil
+}
+
+// checkProfile verifies the registry holds every emitente field of the NFC-e
+func (v *CompanyVerifier) checkProfile(company *entity.Company) ReadinessCheck {
+	if missing := company.MissingEmitenteFields(); len(missing) > 0 {
+		return ReadinessCheck{
+			Name:    ReadinessCheckProfile,
+			Status:  ReadinessFailed,
+			Message: fmt.Sprintf("company profile is missing %s", strings.Join(missing, ", ")),
+			Details: map[string]any{"missing_fields": missing},
+		}
+	}
+	return ReadinessCheck{Name: ReadinessCheckProfile, Status: ReadinessPassed}
+}
+
+// checkCertificate opens the certificate, or probes the KMS key, and verifies it is in force
+// and was issued to the CNPJ root of the company
+func (v *CompanyVerifier) checkCertificate(ctx context.Context, company *entity.Company) (*entity.Certificate, ReadinessCheck) {
+	check := ReadinessCheck{Name: ReadinessCheckCertificate, Status: ReadinessFailed}
+
+	certificate, err := v.companyRepo.GetCertificateByCompanyID(ctx, company.ID)
+	if err != nil {
+		check.Message = fmt.Sprintf("no certificate configured: %v", err)
+		return nil, check
+	}
+
+	var cert *x509.Certificate
+	if key := keyMaterial(certificate); key.KMS != nil {
+		cert, err = signer.ProbeKMSKey(ctx, *key.KMS)
+	} else {
+		var bundle *signer.PFXBundle
+		if bundle, err = signer.LoadPFX(key); err == nil {
+			cert = bundle.Certificate
+		}
+	}
+	if err != nil {
+		check.Message = fmt.Sprintf("certificate can not be used: %v", err)
+		return nil, check
+	}
+
+	certCNPJ := signer.CertificateCNPJ(cert)
+	check.Details = map[string]any{
+		"subject":     cert.Subject.CommonName,
+		"cnpj":        certCNPJ,
+		"not_before":  cert.NotBefore,
+		"not_after":   cert.NotAfter,
+		"remote_sign": certificate.KMS != nil,
+	}
+
+	now := time.Now()
+	companyCNPJ := onlyDigits(company.CNPJ)
+	switch {
+	case now.Before(cert.NotBefore):
+		check.Message = fmt.Sprintf("certificate is only valid from %s", cert.NotBefore.Format(time.RFC3339))
+	case now.After(cert.NotAfter):
+		check.Message = fmt.Sprintf("certificate expired at %s", cert.NotAfter.Format(time.RFC3339))
+	case certCNPJ == "":
+		check.Message = "certificate does not carry a CNPJ"
+	case len(companyCNPJ) != 14 || certCNPJ[:8] != companyCNPJ[:8]:
+		// SEFAZ accepts the certificate of any establishment of the same CNPJ root
+		check.Message = fmt.Sprintf("certificate was issued to CNPJ %s, not to the company CNPJ %s", certCNPJ, companyCNPJ)
+	default:
+		check.Status = ReadinessPassed
+		return certificate, check
+	}
+	return nil, check
+}
+
+// checkCSC verifies the CSC in force for producao, which signs the QR Code of the NFC-e
+func (v *CompanyVerifier) checkCSC(company *entity.Company, now time.Time) ReadinessCheck {
+	check := ReadinessCheck{Name: ReadinessCheckCSC, Status: ReadinessFailed}
+
+	cscID, cscToken, ok := company.ResolveCSC("producao", now)
+	if !ok {
+		check.Message = "no CSC in force for producao"
+		return check
+	}
+	check.Details = map[string]any{"csc_id": cscID, "ambiente": "producao"}
+	if err := v.nfceDomain.ValidateCSC(cscToken); err != nil {
+		check.Message = err.Error()
+		return check
+	}
+
+	check.Status = ReadinessPassed
+	return check
+}
+
+// checkSEFAZStatus queries NfeStatusServico4 of the company UF in both environments
+func (v *CompanyVerifier) checkSEFAZStatus(ctx context.Context, company *entity.Company) ReadinessCheck {
+	check := ReadinessCheck{Name: ReadinessCheckSEFAZStatus, Status: ReadinessPassed}
+
+	uf := company.Endereco.UF
+	if uf == "" {
+		check.Status = ReadinessFailed
+		check.Message = "company has no UF"
+		return check
+	}
+
+	check.Details = map[string]any{"uf": uf}
+	var failures []string
+	for _, ambiente := range []string{"homologacao", "producao"} {
+		response, err := v.soapClient.QueryStatus(ctx, uf, ambiente)
+		if err != nil {
+			check.Details[ambiente] = map[string]any{"error": err.Error()}
+			failures = append(failures, fmt.Sprintf("%s: %v", ambiente, err))
+			continue
+		}
+		check.Details[ambiente] = map[string]any{"cstat": response.CStat, "motivo": response.Motivo}
+		// 107 - Serviço em Operação
+		if response.CStat != "107" {
+			failures = append(failures, fmt.Sprintf("%s: %s - %s", ambiente, response.CStat, response.Motivo))
+		}
+	}
+	if len(failures) > 0 {
+		check.Status = ReadinessFailed
+		check.Message = "SEFAZ status service is not in operation for " + strings.Join(failures, "; ")
+	}
+	return check
+}
+
+// checkTestEmission authorizes a test NFC-e in homologação with the company data and certificate
+func (v *CompanyVerifier) checkTestEmission(ctx context.Context, company *entity.Company, certificate *entity.Certificate) ReadinessCheck {
+	check := ReadinessCheck{
+		Name:    ReadinessCheckTestEmission,
+		Status:  ReadinessFailed,
+		Details: map[string]any{"ambiente": "homologacao"},
+	}
+
+	response, chaveAcesso, err := v.worker.EmitTestNFCe(ctx, company, certificate)
+	if chaveAcesso != "" {
+		check.Details["chave_acesso"] = chaveAcesso
+	}
+	if err != nil {
+		check.Message = err.Error()
+		return check
+	}
+
+	check.Details["cstat"] = response.CStat
+	check.Details["motivo"] = response.Motivo
+	if response.Status != "authorized" {
+		check.Message = fmt.Sprintf("SEFAZ did not authorize the test NFC-e: %s - %s", response.CStat, response.Motivo)
+		return check
+	}
+	check.Details["protocolo"] = response.Protocolo
+	check.Status = ReadinessPassed
+	return check
+}
+
+// EmitTestNFCe builds, signs and synchronously authorizes a one item NFC-e in homologação
+// for the company, returning the SEFAZ answer and the chave de acesso. The number is spent
+// from the homologação series and the NFC-e is not stored.
+func (s *NFCeWorkerService) EmitTestNFCe(ctx context.Context, company *entity.Company, certificate *entity.Certificate) (soapclient.AuthorizationResponse, string, error) {
+	payload := entity.EmitPayload{
+		UF:       company.Endereco.UF,
+		Ambiente: "homologacao",
+		Itens: []entity.Item{{
+			Descricao:  testEmissionDescription,
+			NCM:        "21069090",
+			CFOP:       "5102",
+			GTIN:       "SEM GTIN",
+			Valor:      money.FromFloat(1),
+			Quantidade: money.Units(1),
+			Unidade:    "UN",
+		}},
+		Pagamentos: []entity.Payment{{Forma: "01", Valor: money.FromFloat(1)}},
+	}
+
+	nfceData, err := s.xmlBuilder.BuildNFCe(s.convertToNFCeInput(payload, company, nil, false, ""), company.ID)
+	if err != nil {
+		return soapclient.AuthorizationResponse{}, "", fmt.Errorf("failed to build test NFC-e: %w", err)
+	}
+	chaveAcesso, err := s.extractChaveAcesso(nfceData)
+	if err != nil {
+		return soapclient.AuthorizationResponse{}, "", fmt.Errorf("failed to extract chave acesso: %w", err)
+	}
+
+	xmlBytes, err := s.convertNFCeToXML(nfceData)
+	if err != nil {
+		return soapclient.AuthorizationResponse{}, chaveAcesso, fmt.Errorf("failed to convert NFC-e to XML: %w", err)
+	}
+	if err := s.xmlValidator.ValidateNFCe(ctx, xmlBytes, "4.00"); err != nil {
+		return soapclient.AuthorizationResponse{}, chaveAcesso, fmt.Errorf("XSD validation failed: %w", err)
+	}
+
+	infNFeID, err := s.findInfNFeID(xmlBytes)
+	if err != nil {
+		return soapclient.AuthorizationResponse{}, chaveAcesso, fmt.Errorf("failed to find infNFe ID: %w", err)
+	}
+	key := keyMaterial(certificate)
+	signedXML, err := s.xmlSigner.SignEnveloped(ctx, xmlBytes, key, infNFeID)
+	if err != nil {
+		return soapclient.AuthorizationResponse{}, chaveAcesso, fmt.Errorf("failed to sign XML: %w", err)
+	}
+	if err := s.xmlValidator.ValidateNFCe(ctx, signedXML, "4.00"); err != nil {
+		return soapclient.AuthorizationResponse{}, chaveAcesso, fmt.Errorf("signed XML validation failed: %w", err)
+	}
+
+	response, err := s.soapClient.Authorize(ctx, soapclient.AuthorizationRequest{
+		UF:          payload.UF,
+		Ambiente:    payload.Ambiente,
+		XML:         signedXML,
+		Certificate: &soapclient.ClientCertificate{CompanyID: company.ID, Key: key},
+	})
+	if err != nil {
+		return soapclient.AuthorizationResponse{}, chaveAcesso, fmt.Errorf("SEFAZ authorization failed: %w", err)
+	}
+	return response, chaveAcesso, nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// ContingencyConfig controls the status service probe that ends the contingency periods
+type ContingencyConfig struct {
+	ProbeInterval time.Duration
+}
+
+// ContingencyProbeEnabled reports whether the contingency periods are persisted and probed
+func (s *NFCeWorkerService) ContingencyProbeEnabled() bool {
+	return s.contingencies != nil && s.contingency.ProbeInterval > 0
+}
+
+// ContingencyProbeInterval returns how often the authorizers of the active contingency
+// periods are queried
+func (s *NFCeWorkerService) ContingencyProbeInterval() time.Duration {
+	return s.contingency.ProbeInterval
+}
+
+// svcContingencyType returns the SEFAZ Virtual de Contingência that takes over the
+// authorizer of the UF
+func svcContingencyType(uf string) string {
+	if uf == "RS" {
+		return "SVC-RS" // Use SVC-RS for Rio Grande do Sul
+	}
+	return "SVC-AN" // Default to SVC-AN
+}
+
+// activeContingency returns the contingency period of the company in the UF and ambiente
+// of the NFC-e, nil when it emits normally
+func (s *NFCeWorkerService) activeContingency(ctx context.Context, nfceRequest *entity.NFCE) *entity.ContingencyState {
+	if s.contingencies == nil {
+		return nil
+	}
+
+	state, err := s.contingencies.GetActive(ctx, nfceRequest.CompanyID, nfceRequest.Payload.UF, nfceRequest.Payload.Ambiente)
+	if err != nil {
+		// Log error but don't fail - the regular authorizer is tried and contingency entered again if needed
+		s.log(ctx).Error("Failed to load contingency state", logger.Field{Key: "uf", Value: nfceRequest.Payload.UF}, logger.Err(err))
+		return nil
+	}
+	return state
+}
+
+// enterContingency persists that the company emits in contingency in the UF and ambiente of
+// the NFC-e, unless it already does
+func (s *NFCeWorkerService) enterContingency(ctx context.Context, nfceRequest *entity.NFCE, contingencyType, cstat, motivo string) {
+	if s.contingencies == nil {
+		return
+	}
+
+	state := entity.NewContingencyState(nfceRequest.CompanyID, nfceRequest.Payload.UF, nfceRequest.Payload.Ambiente, contingencyType, cstat, motivo)
+	if _, err := s.contingencies.Enter(ctx, state); err != nil {
+		// Log error but don't fail - the NFC-e itself is still issued in contingency
+		s.log(ctx).Error("Failed to record contingency", logger.Field{Key: "uf", Value: nfceRequest.Payload.UF}, logger.Err(err))
+	}
+}
+
+// emitInContingency issues the NFC-e in the contingency the company is in, without trying
+// the regular authorizer first
+func (s *NFCeWorkerService) emitInContingency(ctx context.Context, nfceRequest *entity.NFCE, state *entity.ContingencyState) error {
+	contingencyType := state.Type
+	if contingencyType == entity.ContingencyTypeOffline && !s.offlineFor(nfceRequest) {
+		contingencyType = svcContingencyType(nfceRequest.Payload.UF)
+	}
+
+	// Contingency must be included in the company plan
+	if s.featureGate != nil {
+		err := s.featureGate.Require(ctx, nfceRequest.CompanyID, "contingency", map[string]interface{}{
+			"request_id":       nfceRequest.ID,
+			"contingency_type": contingencyType,
+			"cstat":            state.CStat,
+		})
+		if err != nil {
+			return fmt.Errorf("company in contingency since %s, contingency not used: %w", state.EnteredAt.Format("2006-01-02 15:04:05"), err)
+		}
+	}
+
+	nfceRequest.MarkAsContingency(contingencyType)
+	return s.processNFceEmissionWithContingency(ctx, nfceRequest, true, contingencyType)
+}
+
+// ProbeContingencies queries NfeStatusServico4 for the authorizers of the active
+// contingency periods and ends those whose authorizer is in operation again (cStat 107).
+// It returns the periods that ended.
+func (s *NFCeWorkerService) ProbeContingencies(ctx context.Context) ([]*entity.ContingencyState, error) {
+	if s.contingencies == nil {
+		return nil, nil
+	}
+
+	states, err := s.contingencies.ListActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active contingencies: %w", err)
+	}
+
+	// One query per authorizer, shared by the companies in contingency in it
+	available := make(map[string]bool)
+	var exited []*entity.ContingencyState
+	for _, state := range states {
+		key := monitorKey(state.UF, state.Ambiente)
+		up, probed := available[key]
+		if !probed {
+			response, err := s.soapClient.QueryStatus(ctx, state.UF, state.Ambiente)
+			up = err == nil && response.CStat == "107" // Serviço em operação
+			available[key] = up
+		}
+
+		if up {
+			state.Exit()
+		} else {
+			state.RecordProbe()
+		}
+		if err := s.contingencies.Update(ctx, state); err != nil {
+			return exited, fmt.Errorf("failed to update contingency %s: %w", state.ID, err)
+		}
+		if up {
+			exited = append(exited, state)
+		}
+	}
+	return exited, nil
+}
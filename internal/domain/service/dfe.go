@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+)
+
+// maxDFeCallsPerSync caps the NFeDistribuicaoDFe calls of a company in one sync; each call returns up to 50 documents
+const maxDFeCallsPerSync = 20
+
+// DFeConfig controls the NFeDistribuicaoDFe sync
+type DFeConfig struct {
+	Ambiente     string
+	SyncInterval time.Duration // How often the worker looks for due cursors
+}
+
+// DFeService fetches the documents and events distributed to the companies by the Ambiente Nacional
+type DFeService struct {
+	dfeRepo     ports.DFeRepository
+	companyRepo ports.CompanyRepository
+	soapClient  soapclient.Client
+	storage     storage.StorageService
+	config      DFeConfig
+}
+
+// NewDFeService creates a new DF-e distribution service
+func NewDFeService(
+	dfeRepo ports.DFeRepository,
+	companyRepo ports.CompanyRepository,
+	soapClient soapclient.Client,
+	storage storage.StorageService,
+	config DFeConfig,
+) *DFeService {
+	return &DFeService{
+		dfeRepo:     dfeRepo,
+		companyRepo: companyRepo,
+		soapClient:  soapClient,
+		storage:     storage,
+		config:      config,
+	}
+}
+
+// SyncInterval returns how often due cursors are looked for
+func (s *DFeService) SyncInterval() time.Duration {
+	return s.config.SyncInterval
+}
+
+// ListDue lists the companies whose cursor is due for a sync
+func (s *DFeService) ListDue(ctx context.Context, limit int) ([]*entity.DFeCursor, error) {
+	return s.dfeRepo.ListDueCursors(ctx, time.Now(), limit)
+}
+
+// Sync consumes the NSUs available for the company after its cursor, storing each
+// document and advancing the cursor after every call so an interrupted sync resumes
+func (s *DFeService) Sync(ctx context.Context, companyID string) (*entity.DFeCursor, error) {
+	company, err := s.companyRepo.GetByID(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company %s: %w", companyID, err)
+	}
+
+	cursor, err := s.dfeRepo.GetCursor(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dfe cursor: %w", err)
+	}
+	if cursor == nil {
+		cursor = entity.NewDFeCursor(companyID)
+	}
+
+	certificate, err := s.companyRepo.GetCertificateByCompanyID(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate for company %s: %w", companyID, err)
+	}
+	clientCert := &soapclient.ClientCertificate{
+		CompanyID: companyID,
+		Key:       keyMaterial(certificate),
+	}
+
+	for call := 0; call < maxDFeCallsPerSync; call++ {
+		response, err := s.soapClient.DistributeDFe(ctx, soapclient.DistributionRequest{
+			Ambiente:    s.config.Ambiente,
+			UF:          company.Endereco.UF,
+			CNPJ:        company.CNPJ,
+			UltNSU:      cursor.UltNSU,
+			Certificate: clientCert,
+		})
+		if err != nil {
+			return cursor, fmt.Errorf("SEFAZ distribution failed: %w", err)
+		}
+
+		if response.CStat == "138" { // Documento(s) localizado(s)
+			if err := s.storeDocuments(ctx, companyID, response.Documents); err != nil {
+				return cursor, err
+			}
+		}
+
+		cursor.Advance(response.UltNSU, response.MaxNSU, response.CStat, response.Motivo)
+		if err := s.dfeRepo.SaveCursor(ctx, cursor); err != nil {
+			return cursor, fmt.Errorf("failed to save dfe cursor: %w", err)
+		}
+
+		if cursor.NextSyncAt.After(time.Now()) {
+			break
+		}
+	}
+
+	return cursor, nil
+}
+
+// storeDocuments uploads the XML of each document and records them
+func (s *DFeService) storeDocuments(ctx context.Context, companyID string, distributed []soapclient.DistributedDocument) error {
+	documents := make([]*entity.DFeDocument, 0, len(distributed))
+	for _, doc := range distributed {
+		document := entity.NewDFeDocument(companyID, doc.NSU, doc.Schema)
+		document.ChaveAcesso = doc.ChaveAcesso
+		document.CNPJEmitente = doc.CNPJEmitente
+		document.XMLKey = fmt.Sprintf("nfce/%s/dfe/%s.xml", companyID, doc.NSU)
+
+		url, err := s.storage.UploadFile(ctx, "", document.XMLKey, bytes.NewReader(doc.XML), "application/xml")
+		if err != nil {
+			return fmt.Errorf("failed to upload dfe document NSU %s: %w", doc.NSU, err)
+		}
+		document.XMLURL = url
+
+		documents = append(documents, document)
+	}
+
+	if err := s.dfeRepo.SaveDocuments(ctx, documents); err != nil {
+		return fmt.Errorf("failed to save dfe documents: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+)
+
+// DocumentPurgeConfig controls the deletion of the NFC-e documents past the plan retention
+type DocumentPurgeConfig struct {
+	Enabled   bool
+	Interval  time.Duration
+	BatchSize int
+}
+
+// DocumentPurgeService enforces the StorageDays of each company plan: it deletes the XML,
+// DANFE and QR Code of the NFC-e older than the retention window, keeping the request data
+// and the chave, and records a purge event on each NFC-e for auditability
+type DocumentPurgeService struct {
+	companyRepo      ports.CompanyRepository
+	subscriptionRepo ports.SubscriptionRepository
+	planRepo         ports.PlanRepository
+	nfceRepo         ports.NFCeRepository
+	txManager        ports.TxManager
+	storage          storage.StorageService
+	config           DocumentPurgeConfig
+}
+
+// NewDocumentPurgeService creates a new document purge service
+func NewDocumentPurgeService(
+	companyRepo ports.CompanyRepository,
+	subscriptionRepo ports.SubscriptionRepository,
+	planRepo ports.PlanRepository,
+	nfceRepo ports.NFCeRepository,
+	txManager ports.TxManager,
+	storage storage.StorageService,
+	config DocumentPurgeConfig,
+) *DocumentPurgeService {
+	return &DocumentPurgeService{
+		companyRepo:      companyRepo,
+		subscriptionRepo: subscriptionRepo,
+		planRepo:         planRepo,
+		nfceRepo:         nfceRepo,
+		txManager:        txManager,
+		storage:          storage,
+		config:           config,
+	}
+}
+
+// Enabled reports whether the documents are purged
+func (s *DocumentPurgeService) Enabled() bool {
+	return s.config.Enabled
+}
+
+// Interval returns how often the purge runs
+func (s *DocumentPurgeService) Interval() time.Duration {
+	return s.config.Interval
+}
+
+// PurgeDue purges the documents past the retention of every company whose active plan limits
+// StorageDays. Companies without an active subscription or with an unlimited plan keep their
+// documents. It returns the number of NFC-e purged.
+func (s *DocumentPurgeService) PurgeDue(ctx context.Context, now time.Time) (int, error) {
+	const pageSize = 100
+
+	purged := 0
+	var errs []error
+	for offset := 0; ; offset += pageSize {
+		companies, total, err := s.companyRepo.List(ctx, pageSize, offset)
+		if err != nil {
+			return purged, fmt.Errorf("failed to list companies: %w", err)
+		}
+
+		for _, company := range companies {
+			days := planStorageDays(ctx, s.subscriptionRepo, s.planRepo, company.ID)
+			if days <= 0 {
+				continue
+			}
+
+			count, err := s.PurgeCompany(ctx, company.ID, days, now)
+			purged += count
+			if err != nil {
+				errs = append(errs, fmt.Errorf("company %s: %w", company.ID, err))
+			}
+		}
+
+		if len(companies) < pageSize || offset+pageSize >= total {
+			break
+		}
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+// PurgeCompany purges the documents of the company NFC-e created more than days before now,
+// in batches. An NFC-e whose files cannot be deleted keeps its documents and is retried on the
+// next run.
+func (s *DocumentPurgeService) PurgeCompany(ctx context.Context, companyID string, days int, now time.Time) (int, error) {
+	reason := purgeReason{
+		message:  fmt.Sprintf("Documentos removidos após %d dias de retenção do plano", days),
+		metadata: map[string]interface{}{"storage_days": days},
+	}
+	return s.purgeBefore(ctx, companyID, now.AddDate(0, 0, -days), now, reason)
+}
+
+// PurgeDeletedCompany purges the documents of every NFC-e of an offboarded company, whatever
+// the plan retention
+func (s *DocumentPurgeService) PurgeDeletedCompany(ctx context.Context, companyID string, now time.Time) (int, error) {
+	reason := purgeReason{
+		message:  "Documentos removidos na exclusão da empresa",
+		metadata: map[string]interface{}{"company_deleted": true},
+	}
+	return s.purgeBefore(ctx, companyID, now, now, reason)
+}
+
+// purgeReason describes on the purge event why the documents were deleted
+type purgeReason struct {
+	message  string
+	metadata map[string]interface{}
+}
+
+// purgeBefore purges the documents of the company NFC-e created before the given time, in
+// batches
+func (s *DocumentPurgeService) purgeBefore(ctx context.Context, companyID string, before, now time.Time, reason purgeReason) (int, error) {
+	purged := 0
+	var errs []error
+	for {
+		requests, err := s.nfceRepo.ListPurgeable(ctx, companyID, before, s.config.BatchSize)
+		if err != nil {
+			return purged, fmt.Errorf("failed to list NFC-e to purge: %w", err)
+		}
+
+		batch := 0
+		for _, nfce := range requests {
+			if err := s.purge(ctx, nfce, reason, now); err != nil {
+				errs = append(errs, fmt.Errorf("NFC-e %s: %w", nfce.ID, err))
+				continue
+			}
+			batch++
+		}
+		purged += batch
+
+		// A batch without progress only holds failures, which would be listed again
+		if len(requests) < s.config.BatchSize || batch == 0 {
+			break
+		}
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+// purge deletes the files of the NFC-e, then clears their URLs and records the purge event
+func (s *DocumentPurgeService) purge(ctx context.Context, nfce *entity.NFCE, reason purgeReason, now time.Time) error {
+	keys := nfce.DocumentKeys()
+	for _, key := range keys {
+		if err := s.storage.DeleteFile(ctx, "", key); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+	}
+
+	purge := map[string]interface{}{"keys": keys}
+	for key, value := range reason.metadata {
+		purge[key] = value
+	}
+	event := &entity.Event{
+		RequestID:  nfce.ID,
+		StatusFrom: nfce.Status,
+		StatusTo:   nfce.Status,
+		CStat:      nfce.CStat,
+		Message:    reason.message,
+		Metadata:   map[string]interface{}{"purge": purge},
+	}
+
+	persist := func(ctx context.Context) error {
+		err := s.nfceRepo.UpdateFields(ctx, nfce.ID, map[string]interface{}{
+			"xml_url":             "",
+			"pdf_url":             "",
+			"qrcode_url":          "",
+			"cancel_xml_url":      "",
+			"documents_purged_at": now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to mark NFC-e as purged: %w", err)
+		}
+		if err := s.nfceRepo.CreateEvent(ctx, event); err != nil {
+			return fmt.Errorf("failed to create purge event: %w", err)
+		}
+		return nil
+	}
+
+	if s.txManager == nil {
+		return persist(ctx)
+	}
+	return s.txManager.WithinTx(ctx, persist)
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/email"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/danfe"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/qr"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+)
+
+var (
+	// ErrEmailDisabled is returned when no e-mail provider is configured
+	ErrEmailDisabled = errors.New("e-mail delivery is not configured")
+	// ErrEmailRecipientMissing is returned when the NFC-e has no consumer e-mail and none was given
+	ErrEmailRecipientMissing = errors.New("NFC-e has no consumer e-mail")
+)
+
+// EmailConfig holds the sender address of the e-mails
+type EmailConfig struct {
+	From     string
+	FromName string // Display name, the company name when empty
+}
+
+// EmailService e-mails the XML and the DANFE of authorized NFC-e to the consumer, with the
+// company message, and keeps the send log
+type EmailService struct {
+	deliveryRepo  ports.EmailDeliveryRepository
+	companyRepo   ports.CompanyRepository
+	storage       storage.StorageService
+	danfeRenderer danfe.Renderer
+	sender        email.Sender
+	config        EmailConfig
+}
+
+// NewEmailService creates a new e-mail service; a nil sender disables the e-mails
+func NewEmailService(
+	deliveryRepo ports.EmailDeliveryRepository,
+	companyRepo ports.CompanyRepository,
+	storage storage.StorageService,
+	danfeRenderer danfe.Renderer,
+	sender email.Sender,
+	config EmailConfig,
+) *EmailService {
+	return &EmailService{
+		deliveryRepo:  deliveryRepo,
+		companyRepo:   companyRepo,
+		storage:       storage,
+		danfeRenderer: danfeRenderer,
+		sender:        sender,
+		config:        config,
+	}
+}
+
+// Enabled reports whether an e-mail provider is configured
+func (s *EmailService) Enabled() bool {
+	return s.sender != nil
+}
+
+// SendAuthorized e-mails a newly authorized NFC-e to the consumer e-mail of the payload.
+// It returns nil without sending when the consumer gave no e-mail or the company disabled it.
+func (s *EmailService) SendAuthorized(ctx context.Context, nfceRequest *entity.NFCE) (*entity.EmailDelivery, error) {
+	dest := nfceRequest.Payload.Destinatario
+	if !s.Enabled() || dest == nil || dest.Email == "" {
+		return nil, nil
+	}
+
+	company, err := s.companyRepo.GetByID(ctx, nfceRequest.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load company: %w", err)
+	}
+	if company.EmailTemplate != nil && company.EmailTemplate.Disabled {
+		return nil, nil
+	}
+
+	return s.deliver(ctx, company, nfceRequest, dest.Email, entity.EmailTriggerAuthorization)
+}
+
+// Resend e-mails the NFC-e again, to recipient or to the consumer e-mail of the payload
+func (s *EmailService) Resend(ctx context.Context, nfceRequest *entity.NFCE, recipient string) (*entity.EmailDelivery, error) {
+	if !s.Enabled() {
+		return nil, ErrEmailDisabled
+	}
+	if recipient == "" && nfceRequest.Payload.Destinatario != nil {
+		recipient = nfceRequest.Payload.Destinatario.Email
+	}
+	if recipient == "" {
+		return nil, ErrEmailRecipientMissing
+	}
+
+	company, err := s.companyRepo.GetByID(ctx, nfceRequest.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load company: %w", err)
+	}
+
+	return s.deliver(ctx, company, nfceRequest, recipient, entity.EmailTriggerResend)
+}
+
+// ListDeliveries lists the send log of an NFC-e, newest first
+func (s *EmailService) ListDeliveries(ctx context.Context, requestID string) ([]*entity.EmailDelivery, error) {
+	return s.deliveryRepo.ListByRequestID(ctx, requestID)
+}
+
+// deliver sends the message and records the attempt; when sending fails the failed delivery
+// is returned with the error
+func (s *EmailService) deliver(ctx context.Context, company *entity.Company, nfceRequest *entity.NFCE, recipient string, trigger entity.EmailTrigger) (*entity.EmailDelivery, error) {
+	var subject, messageID string
+	msg, err := s.buildMessage(ctx, company, nfceRequest, recipient)
+	if err == nil {
+		subject = msg.Subject
+		messageID, err = s.sender.Send(ctx, msg)
+	}
+
+	delivery := entity.NewEmailDelivery(nfceRequest, recipient, subject, trigger, s.sender.Provider(), messageID, err)
+	if createErr := s.deliveryRepo.Create(ctx, delivery); createErr != nil {
+		return nil, fmt.Errorf("failed to record e-mail delivery: %w", createErr)
+	}
+
+	if err != nil {
+		return delivery, fmt.Errorf("failed to send e-mail: %w", err)
+	}
+	return delivery, nil
+}
+
+// buildMessage renders the company message and attaches the authorized XML and the DANFE
+func (s *EmailService) buildMessage(ctx context.Context, company *entity.Company, nfceRequest *entity.NFCE, recipient string) (*email.Message, error) {
+	content, err := email.Render(company.EmailTemplate, templateData(company, nfceRequest))
+	if err != nil {
+		return nil, err
+	}
+
+	xmlKey := fmt.Sprintf("nfce/%s/xml/%s.xml", nfceRequest.CompanyID, nfceRequest.ChaveAcesso)
+	xmlContent, err := s.storage.DownloadFile(ctx, "", xmlKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load NFC-e XML: %w", err)
+	}
+
+	// The DANFE is rendered again when it was not stored
+	pdfKey := fmt.Sprintf("nfce/%s/pdf/%s.pdf", nfceRequest.CompanyID, nfceRequest.ChaveAcesso)
+	pdfContent, err := s.storage.DownloadFile(ctx, "", pdfKey)
+	if err != nil {
+		pdfContent = s.danfeRenderer.Render(company, nfceRequest, nfceRequest.ChaveAcesso, danfeOptions(nfceRequest, company))
+	}
+
+	fromName := s.config.FromName
+	if fromName == "" {
+		fromName = company.RazaoSocial
+		if company.NomeFantasia != "" {
+			fromName = company.NomeFantasia
+		}
+	}
+
+	replyTo := company.Email
+	if company.EmailTemplate != nil && company.EmailTemplate.ReplyTo != "" {
+		replyTo = company.EmailTemplate.ReplyTo
+	}
+
+	return &email.Message{
+		From:    (&mail.Address{Name: fromName, Address: s.config.From}).String(),
+		To:      []string{recipient},
+		ReplyTo: replyTo,
+		Subject: content.Subject,
+		HTML:    content.HTML,
+		Text:    content.Text,
+		Attachments: []email.Attachment{
+			{Filename: fmt.Sprintf("nfce-%s.xml", nfceRequest.ChaveAcesso), ContentType: "application/xml", Content: xmlContent},
+			{Filename: fmt.Sprintf("nfce-%s.pdf", nfceRequest.ChaveAcesso), ContentType: "application/pdf", Content: pdfContent},
+		},
+	}, nil
+}
+
+// templateData collects the NFC-e data available to the company templates
+func templateData(company *entity.Company, nfceRequest *entity.NFCE) email.TemplateData {
+	payload := nfceRequest.Payload
+	data := email.TemplateData{
+		RazaoSocial:  company.RazaoSocial,
+		NomeFantasia: company.NomeFantasia,
+		CNPJ:         company.CNPJ,
+		Numero:       nfceRequest.Numero,
+		Serie:        nfceRequest.Serie,
+		ChaveAcesso:  nfceRequest.ChaveAcesso,
+		Protocolo:    nfceRequest.Protocolo,
+		Valor:        payload.Total().String(),
+		EmitidaEm:    nfceRequest.CreatedAt.Format("02/01/2006 15:04"),
+		ConsultaURL:  qr.ConsultaURL(payload.UF, payload.Ambiente),
+		QRCode:       nfceRequest.QRCode,
+	}
+	if payload.Destinatario != nil {
+		data.Consumidor = payload.Destinatario.Nome
+	}
+	return data
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+const (
+	// exportPartSize is the number of NFC-e written to each part of the export
+	exportPartSize = 500
+	// exportIndexName is the file listing the NFC-e of a part, when the job asks for it
+	exportIndexName = "index.csv"
+	// maxExportAttempts caps how many times a job is resumed before it is marked as failed
+	maxExportAttempts = 5
+)
+
+// exportIndexHeader are the columns of the index.csv of an export
+var exportIndexHeader = []string{"chave_acesso", "numero", "serie", "status", "autorizada_em", "protocolo", "valor", "arquivo"}
+
+// ExportService writes bulk exports of NFC-e XMLs to storage in chunked parts
+type ExportService struct {
+	nfceRepo   ports.NFCeRepository
+	exportRepo ports.ExportRepository
+	storage    storage.StorageService
+	webhooks   ports.WebhookDispatcher
+	logger     logger.Logger
+}
+
+// NewExportService creates a new export service
+func NewExportService(
+	nfceRepo ports.NFCeRepository,
+	exportRepo ports.ExportRepository,
+	storage storage.StorageService,
+	webhooks ports.WebhookDispatcher,
+	l logger.Logger,
+) *ExportService {
+	return &ExportService{
+		nfceRepo:   nfceRepo,
+		exportRepo: exportRepo,
+		storage:    storage,
+		webhooks:   webhooks,
+		logger:     l,
+	}
+}
+
+// Count counts the exportable NFC-e of a company authorized within the period
+func (s *ExportService) Count(ctx context.Context, companyID string, from, to time.Time) (int, error) {
+	return s.nfceRepo.CountForExport(ctx, companyID, from, to)
+}
+
+// WriteZip writes a single zip with the XMLs of the company NFC-e authorized within the
+// period, for exports small enough to be downloaded right away instead of run as a job
+func (s *ExportService) WriteZip(ctx context.Context, w io.Writer, companyID string, from, to time.Time, includeIndex bool) error {
+	zw := zip.NewWriter(w)
+	ref := fmt.Sprintf("%s %s-%s", companyID, from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	var index [][]string
+	for offset := 0; ; offset += exportPartSize {
+		requests, err := s.nfceRepo.ListForExport(ctx, companyID, from, to, exportPartSize, offset)
+		if err != nil {
+			return fmt.Errorf("failed to list NFC-e for export: %w", err)
+		}
+
+		rows, err := s.writeXMLs(ctx, zw, ref, requests)
+		if err != nil {
+			return err
+		}
+		index = append(index, rows...)
+
+		if len(requests) < exportPartSize {
+			break
+		}
+	}
+
+	if includeIndex {
+		if err := writeExportIndex(zw, index); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize export: %w", err)
+	}
+	return nil
+}
+
+// ProcessExport writes the remaining parts of an export job, resuming from the last checkpoint
+func (s *ExportService) ProcessExport(ctx context.Context, jobID string) error {
+	job, err := s.exportRepo.GetByID(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to get export job: %w", err)
+	}
+
+	// Nothing to do for finished jobs
+	if job.Status == entity.ExportStatusCompleted || job.Status == entity.ExportStatusFailed || job.Status == entity.ExportStatusExpired {
+		return nil
+	}
+
+	if job.IsExpired() {
+		job.MarkAsFailed("export job expired before completion")
+		return s.exportRepo.Update(ctx, job)
+	}
+
+	job.IncrementAttempts()
+	if job.Attempts > maxExportAttempts {
+		job.MarkAsFailed(fmt.Sprintf("export job failed after %d attempts", maxExportAttempts))
+		return s.exportRepo.Update(ctx, job)
+	}
+
+	if job.Status == entity.ExportStatusPending {
+		total, err := s.nfceRepo.CountForExport(ctx, job.CompanyID, job.PeriodStart, job.PeriodEnd)
+		if err != nil {
+			return fmt.Errorf("failed to count NFC-e for export: %w", err)
+		}
+		job.MarkAsProcessing(total)
+	}
+	if err := s.exportRepo.Update(ctx, job); err != nil {
+		return fmt.Errorf("failed to update export job: %w", err)
+	}
+
+	// Resume after the items already written in previous parts
+	for {
+		requests, err := s.nfceRepo.ListForExport(ctx, job.CompanyID, job.PeriodStart, job.PeriodEnd, exportPartSize, job.ProcessedItems)
+		if err != nil {
+			return fmt.Errorf("failed to list NFC-e for export: %w", err)
+		}
+		if len(requests) == 0 {
+			break
+		}
+
+		part, err := s.writePart(ctx, job, len(job.Parts)+1, requests)
+		if err != nil {
+			return err
+		}
+
+		// Checkpoint the part so a restarted job continues from here
+		job.AddPart(*part)
+		if err := s.exportRepo.Update(ctx, job); err != nil {
+			return fmt.Errorf("failed to update export job: %w", err)
+		}
+
+		if len(requests) < exportPartSize {
+			break
+		}
+	}
+
+	job.MarkAsCompleted()
+	if err := s.exportRepo.Update(ctx, job); err != nil {
+		return fmt.Errorf("failed to update export job: %w", err)
+	}
+
+	s.notifyCompleted(ctx, job)
+	return nil
+}
+
+// notifyCompleted dispatches the export.completed webhook with the download links of the parts
+func (s *ExportService) notifyCompleted(ctx context.Context, job *entity.ExportJob) {
+	if s.webhooks == nil {
+		return
+	}
+
+	parts := make([]map[string]interface{}, len(job.Parts))
+	for i, part := range job.Parts {
+		parts[i] = map[string]interface{}{
+			"number": part.Number,
+			"size":   part.Size,
+			"items":  part.Items,
+			"url":    fmt.Sprintf("/api/v1/exports/%s/parts/%d", job.ID, part.Number),
+		}
+	}
+	payload := map[string]interface{}{
+		"export_id":    job.ID,
+		"period_start": job.PeriodStart,
+		"period_end":   job.PeriodEnd,
+		"total_items":  job.ProcessedItems,
+		"parts":        parts,
+		"expires_at":   job.ExpiresAt,
+	}
+
+	if err := s.webhooks.Dispatch(ctx, job.CompanyID, entity.WebhookEventExportCompleted, payload); err != nil {
+		logger.FromContext(ctx, s.logger).Warn("Failed to dispatch export webhook", logger.CompanyID(job.CompanyID), logger.Err(err))
+	}
+}
+
+// writePart zips the XMLs of a chunk of NFC-e and streams the zip to storage. Each XML is
+// copied in chunks from storage into the zip entry, so neither the XMLs nor the zip are held in memory.
+func (s *ExportService) writePart(ctx context.Context, job *entity.ExportJob, number int, requests []*entity.NFCE) (*entity.ExportPart, error) {
+	key := fmt.Sprintf("exports/%s/%s/part-%04d.zip", job.CompanyID, job.ID, number)
+
+	reader, writer := io.Pipe()
+	counter := &countingWriter{w: writer}
+	zipped := make(chan error, 1)
+	go func() {
+		err := s.zipPart(ctx, zip.NewWriter(counter), job, requests)
+		writer.CloseWithError(err)
+		zipped <- err
+	}()
+
+	_, uploadErr := s.storage.UploadFile(ctx, "", key, reader, "application/zip")
+	// Unblock the zip writer when the upload stopped reading early
+	reader.Close()
+	if err := <-zipped; err != nil && uploadErr == nil {
+		uploadErr = err
+	}
+	if uploadErr != nil {
+		return nil, fmt.Errorf("failed to upload export part: %w", uploadErr)
+	}
+
+	return &entity.ExportPart{
+		Number:    number,
+		Key:       key,
+		Size:      counter.n,
+		Items:     len(requests),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// zipPart writes the XMLs of the chunk, and its index when the job asks for it, to the zip
+// writer and finalizes it
+func (s *ExportService) zipPart(ctx context.Context, zw *zip.Writer, job *entity.ExportJob, requests []*entity.NFCE) error {
+	index, err := s.writeXMLs(ctx, zw, job.ID, requests)
+	if err != nil {
+		return err
+	}
+
+	if job.IncludeIndex {
+		if err := writeExportIndex(zw, index); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize export part: %w", err)
+	}
+	return nil
+}
+
+// writeXMLs copies the XMLs of the NFC-e to the zip writer and returns their index rows;
+// ref identifies the export in the logs
+func (s *ExportService) writeXMLs(ctx context.Context, zw *zip.Writer, ref string, requests []*entity.NFCE) ([][]string, error) {
+	var index [][]string
+	for _, req := range requests {
+		files := []string{fmt.Sprintf("%s.xml", req.ChaveAcesso)}
+		if req.Status == entity.RequestStatusCanceled && req.CancelXMLURL != "" {
+			files = append(files, fmt.Sprintf("%s-cancelamento.xml", req.ChaveAcesso))
+		}
+
+		for _, name := range files {
+			key := fmt.Sprintf("nfce/%s/xml/%s", req.CompanyID, name)
+			file, err := s.storage.OpenFile(ctx, "", key)
+			if err != nil {
+				// Log error but don't fail the export - the XML may have been purged
+				logger.FromContext(ctx, s.logger).Warn("Failed to read XML for export", logger.Field{Key: "key", Value: key}, logger.Field{Key: "export", Value: ref}, logger.Err(err))
+				continue
+			}
+
+			w, err := zw.Create(name)
+			if err == nil {
+				_, err = io.Copy(w, file)
+			}
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to write %s to export: %w", name, err)
+			}
+			index = append(index, exportIndexRow(req, name))
+		}
+	}
+	return index, nil
+}
+
+// exportIndexRow describes an XML of the export in the index.csv
+func exportIndexRow(req *entity.NFCE, file string) []string {
+	var authorizedAt string
+	if req.AuthorizedAt != nil {
+		authorizedAt = req.AuthorizedAt.Format(time.RFC3339)
+	}
+	return []string{req.ChaveAcesso, req.Numero, req.Serie, string(req.Status), authorizedAt, req.Protocolo, req.VNF.String(), file}
+}
+
+// writeExportIndex writes the index.csv, semicolon separated as spreadsheets in pt-BR expect
+func writeExportIndex(zw *zip.Writer, rows [][]string) error {
+	w, err := zw.Create(exportIndexName)
+	if err != nil {
+		return fmt.Errorf("failed to write %s to export: %w", exportIndexName, err)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	if err := cw.Write(exportIndexHeader); err != nil {
+		return fmt.Errorf("failed to write %s to export: %w", exportIndexName, err)
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write %s to export: %w", exportIndexName, err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// CleanupExpired removes the parts of expired jobs from storage and marks them as expired
+func (s *ExportService) CleanupExpired(ctx context.Context, limit int) (int, error) {
+	jobs, err := s.exportRepo.ListExpired(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list expired export jobs: %w", err)
+	}
+
+	cleaned := 0
+	for _, job := range jobs {
+		for _, part := range job.Parts {
+			if err := s.storage.DeleteFile(ctx, "", part.Key); err != nil {
+				logger.FromContext(ctx, s.logger).Warn("Failed to delete export part", logger.Field{Key: "key", Value: part.Key}, logger.Err(err))
+			}
+		}
+
+		job.MarkAsExpired()
+		if err := s.exportRepo.Update(ctx, job); err != nil {
+			return cleaned, fmt.Errorf("failed to update export job %s: %w", job.ID, err)
+		}
+		cleaned++
+	}
+
+	return cleaned, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+var (
+	// ErrIdempotencyKeyReused is returned when an Idempotency-Key arrives again with a different payload
+	ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used with a different payload")
+	// ErrIdempotencyKeyInProgress is returned while the first request with the key is still running
+	ErrIdempotencyKeyInProgress = errors.New("a request with this Idempotency-Key is still in progress")
+)
+
+// idempotencyLockTimeout is how long a key stays reserved by a request that never completed,
+// e.g. when the API process died, before a retry may take it over
+const idempotencyLockTimeout = 2 * time.Minute
+
+// idempotencyPurgeBatch caps the expired keys removed per statement
+const idempotencyPurgeBatch = 1000
+
+// IdempotencyService enforces the Idempotency-Key of the API requests per company: the first
+// request reserves the key, its response is replayed to retries with the same payload and
+// a different payload is refused, until the key expires.
+type IdempotencyService struct {
+	repo ports.IdempotencyKeyRepository
+	ttl  time.Duration
+}
+
+// NewIdempotencyService creates a new idempotency service keeping the keys for ttl
+func NewIdempotencyService(repo ports.IdempotencyKeyRepository, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{repo: repo, ttl: ttl}
+}
+
+// Begin reserves the key for a request with requestHash. A new reservation is returned not
+// completed and the request must run; a completed record carries the response to replay.
+// When the key holds another payload or is still running, the record is returned with
+// ErrIdempotencyKeyReused or ErrIdempotencyKeyInProgress.
+func (s *IdempotencyService) Begin(ctx context.Context, companyID, key, requestHash string) (*entity.IdempotencyKey, error) {
+	// A second attempt follows the removal of an expired or abandoned reservation
+	for attempt := 0; attempt < 2; attempt++ {
+		record := entity.NewIdempotencyKey(companyID, key, requestHash, s.ttl)
+		created, err := s.repo.CreateIfAbsent(ctx, record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if created {
+			return record, nil
+		}
+
+		existing, err := s.repo.GetByKey(ctx, companyID, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load idempotency key: %w", err)
+		}
+		if existing == nil {
+			continue // Released meanwhile
+		}
+
+		now := time.Now()
+		abandoned := !existing.Completed() && now.Sub(existing.CreatedAt) > idempotencyLockTimeout
+		if existing.ExpiredAt(now) || abandoned {
+			if err := s.repo.Delete(ctx, existing.ID); err != nil {
+				return nil, fmt.Errorf("failed to release idempotency key: %w", err)
+			}
+			continue
+		}
+
+		if existing.RequestHash != requestHash {
+			return existing, ErrIdempotencyKeyReused
+		}
+		if !existing.Completed() {
+			return existing, ErrIdempotencyKeyInProgress
+		}
+		return existing, nil
+	}
+	return nil, ErrIdempotencyKeyInProgress
+}
+
+// Complete stores the response of the request that reserved the key
+func (s *IdempotencyService) Complete(ctx context.Context, record *entity.IdempotencyKey, status int, body []byte) error {
+	if err := s.repo.Complete(ctx, record.ID, status, body, time.Now()); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release frees the key of a request that failed, so it can be retried with a corrected payload
+func (s *IdempotencyService) Release(ctx context.Context, record *entity.IdempotencyKey) error {
+	if err := s.repo.Delete(ctx, record.ID); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired removes the expired keys, returning how many
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		deleted, err := s.repo.DeleteExpired(ctx, time.Now(), idempotencyPurgeBatch)
+		total += deleted
+		if err != nil {
+			return total, fmt.Errorf("failed to purge idempotency keys: %w", err)
+		}
+		if deleted < idempotencyPurgeBatch {
+			return total, nil
+		}
+	}
+}
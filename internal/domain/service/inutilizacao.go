@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	nfceInfra "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/signer"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// InutilizacaoService sends number range inutilizações to SEFAZ NfeInutilizacao4
+type InutilizacaoService struct {
+	inutilizacaoRepo ports.InutilizacaoRepository
+	companyRepo      ports.CompanyRepository
+	xmlSigner        signer.Signer
+	soapClient       soapclient.Client
+	storage          storage.StorageService
+	logger           logger.Logger
+}
+
+// NewInutilizacaoService creates a new inutilização service
+func NewInutilizacaoService(
+	inutilizacaoRepo ports.InutilizacaoRepository,
+	companyRepo ports.CompanyRepository,
+	xmlSigner signer.Signer,
+	soapClient soapclient.Client,
+	storage storage.StorageService,
+	l logger.Logger,
+) *InutilizacaoService {
+	return &InutilizacaoService{
+		inutilizacaoRepo: inutilizacaoRepo,
+		companyRepo:      companyRepo,
+		xmlSigner:        xmlSigner,
+		soapClient:       soapClient,
+		storage:          storage,
+		logger:           l,
+	}
+}
+
+// ProcessInutilizacao builds, signs and sends the inutNFe, persisting the SEFAZ protocol.
+// SEFAZ rejections are persisted and return nil; transient failures return an error so the message is retried.
+func (s *InutilizacaoService) ProcessInutilizacao(ctx context.Context, id string) (*entity.Inutilizacao, error) {
+	inutilizacao, err := s.inutilizacaoRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inutilizacao: %w", err)
+	}
+
+	// Idempotency - already answered by SEFAZ
+	if inutilizacao.IsFinal() {
+		return inutilizacao, nil
+	}
+
+	company, err := s.companyRepo.GetByID(ctx, inutilizacao.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company %s: %w", inutilizacao.CompanyID, err)
+	}
+
+	inutilizacao.MarkAsProcessing()
+	if err := s.inutilizacaoRepo.Update(ctx, inutilizacao); err != nil {
+		return nil, fmt.Errorf("failed to update inutilizacao: %w", err)
+	}
+
+	// Step 1: Build inutNFe
+	inutNFe, err := nfceInfra.BuildInutilizacao(nfceInfra.InutilizacaoInput{
+		UF:            inutilizacao.UF,
+		Ambiente:      inutilizacao.Ambiente,
+		Ano:           inutilizacao.CreatedAt.Year(),
+		CNPJ:          company.CNPJ,
+		Modelo:        inutilizacao.Modelo,
+		Serie:         inutilizacao.Serie,
+		NNFIni:        inutilizacao.NumeroInicial,
+		NNFFin:        inutilizacao.NumeroFinal,
+		Justificativa: inutilizacao.Justificativa,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build inutilizacao: %w", err)
+	}
+
+	inutXML, err := xml.Marshal(inutNFe)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal inutilizacao: %w", err)
+	}
+
+	// Step 2: Sign with company certificate
+	certificate, err := s.companyRepo.GetCertificateByCompanyID(ctx, inutilizacao.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate for company %s: %w", inutilizacao.CompanyID, err)
+	}
+
+	keyMaterial := keyMaterial(certificate)
+
+	signedInut, err := s.xmlSigner.SignEnveloped(ctx, inutXML, keyMaterial, inutNFe.InfInut.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign inutilizacao: %w", err)
+	}
+
+	// Step 3: Send to SEFAZ NfeInutilizacao4
+	response, err := s.soapClient.Inutilize(ctx, soapclient.InutilizacaoRequest{
+		UF:          inutilizacao.UF,
+		Ambiente:    inutilizacao.Ambiente,
+		Modelo:      inutilizacao.Modelo,
+		CUF:         inutNFe.InfInut.CUF,
+		XML:         signedInut,
+		Certificate: &soapclient.ClientCertificate{CompanyID: inutilizacao.CompanyID, Key: keyMaterial},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SEFAZ inutilizacao failed: %w", err)
+	}
+
+	// Step 4: Process SEFAZ response
+	switch response.Status {
+	case "homologated":
+		inutilizacao.MarkAsHomologated(response.Protocolo, response.CStat, response.Motivo)
+
+		// Store procInutNFe
+		procInut := nfceInfra.BuildProcInutNFe(signedInut, response.RetInutNFe)
+		xmlURL, err := s.storeInutXMLFile(ctx, procInut, inutilizacao)
+		if err != nil {
+			// Log error but don't fail the process - the range is already inutilized at SEFAZ
+			logger.FromContext(ctx, s.logger).Error("Failed to store inutilizacao XML file", logger.Err(err))
+		}
+		inutilizacao.XMLURL = xmlURL
+	case "rejected":
+		inutilizacao.MarkAsRejected(response.CStat, response.Motivo)
+	default:
+		return nil, fmt.Errorf("SEFAZ error (retryable): cStat=%s, motivo=%s", response.CStat, response.Motivo)
+	}
+
+	if err := s.inutilizacaoRepo.Update(ctx, inutilizacao); err != nil {
+		return nil, fmt.Errorf("failed to update inutilizacao: %w", err)
+	}
+
+	return inutilizacao, nil
+}
+
+// storeInutXMLFile uploads the procInutNFe XML to storage
+func (s *InutilizacaoService) storeInutXMLFile(ctx context.Context, xmlContent []byte, inutilizacao *entity.Inutilizacao) (string, error) {
+	// The NF-e ranges are told apart from the NFC-e ones of the same series by the model
+	prefix := "inut"
+	if inutilizacao.Modelo == entity.ModeloNFe {
+		prefix = "inut-nfe"
+	}
+	key := fmt.Sprintf("nfce/%s/xml/%s-%03d-%09d-%09d.xml",
+		inutilizacao.CompanyID, prefix, inutilizacao.Serie, inutilizacao.NumeroInicial, inutilizacao.NumeroFinal)
+	reader := bytes.NewReader(xmlContent)
+
+	url, err := s.storage.UploadFile(ctx, "", key, reader, "application/xml")
+	if err != nil {
+		return "", fmt.Errorf("failed to upload inutilizacao XML: %w", err)
+	}
+
+	return url, nil
+}
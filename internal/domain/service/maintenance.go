@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+var (
+	// ErrUnderMaintenance is returned when a new emission arrives during a maintenance window
+	ErrUnderMaintenance = errors.New("service under maintenance")
+	// ErrMaintenanceActive is returned when a window is started while another is active
+	ErrMaintenanceActive = errors.New("maintenance already active")
+	// ErrMaintenanceNotActive is returned when ending maintenance with no active window
+	ErrMaintenanceNotActive = errors.New("maintenance not active")
+)
+
+// maintenanceCacheTTL is how long the active window is cached between lookups;
+// API and worker processes see a new window within this delay
+const maintenanceCacheTTL = 5 * time.Second
+
+// MaintenanceError carries the active window so clients can show its message and expected end
+type MaintenanceError struct {
+	Window *entity.MaintenanceWindow
+}
+
+// Error implements the error interface
+func (e *MaintenanceError) Error() string {
+	return ErrUnderMaintenance.Error()
+}
+
+// Is allows errors.Is(err, ErrUnderMaintenance)
+func (e *MaintenanceError) Is(target error) bool {
+	return target == ErrUnderMaintenance
+}
+
+// MaintenanceWindowOf returns the window carried by a maintenance error, if any
+func MaintenanceWindowOf(err error) *entity.MaintenanceWindow {
+	var maintenanceErr *MaintenanceError
+	if errors.As(err, &maintenanceErr) {
+		return maintenanceErr.Window
+	}
+	return nil
+}
+
+// MaintenanceService switches the maintenance mode: while a window is active new
+// emissions are refused and the worker schedulers pause. Companies are notified by
+// webhook when a window begins and ends.
+type MaintenanceService struct {
+	repo        ports.MaintenanceRepository
+	companyRepo ports.CompanyRepository
+	webhooks    ports.WebhookDispatcher
+	logger      logger.Logger
+
+	mu       sync.Mutex
+	active   *entity.MaintenanceWindow
+	cachedAt time.Time
+}
+
+// NewMaintenanceService creates a new maintenance service
+func NewMaintenanceService(repo ports.MaintenanceRepository, companyRepo ports.CompanyRepository, webhooks ports.WebhookDispatcher, l logger.Logger) *MaintenanceService {
+	return &MaintenanceService{
+		repo:        repo,
+		companyRepo: companyRepo,
+		webhooks:    webhooks,
+		logger:      l,
+	}
+}
+
+// Current returns the active window, or nil when the platform is not under maintenance
+func (s *MaintenanceService) Current(ctx context.Context) (*entity.MaintenanceWindow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.cachedAt.IsZero() && time.Since(s.cachedAt) < maintenanceCacheTTL {
+		return s.active, nil
+	}
+
+	active, err := s.repo.GetActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active maintenance window: %w", err)
+	}
+	s.active, s.cachedAt = active, time.Now()
+	return active, nil
+}
+
+// Guard returns a *MaintenanceError while a window is active
+func (s *MaintenanceService) Guard(ctx context.Context) error {
+	window, err := s.Current(ctx)
+	if err != nil {
+		return err
+	}
+	if window != nil {
+		return &MaintenanceError{Window: window}
+	}
+	return nil
+}
+
+// Begin starts a maintenance window and notifies the companies
+func (s *MaintenanceService) Begin(ctx context.Context, message string, expectedEndAt *time.Time) (*entity.MaintenanceWindow, error) {
+	active, err := s.repo.GetActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active maintenance window: %w", err)
+	}
+	if active != nil {
+		return nil, ErrMaintenanceActive
+	}
+
+	window, err := entity.NewMaintenanceWindow(message, expectedEndAt)
+	if err != nil {
+		return nil, &ValidationError{Err: err}
+	}
+	if err := s.repo.Create(ctx, window); err != nil {
+		return nil, fmt.Errorf("failed to create maintenance window: %w", err)
+	}
+
+	s.setActive(window)
+	go s.notifyCompanies(context.Background(), entity.WebhookEventMaintenanceStarted, window)
+	return window, nil
+}
+
+// End closes the active maintenance window and notifies the companies
+func (s *MaintenanceService) End(ctx context.Context) (*entity.MaintenanceWindow, error) {
+	window, err := s.repo.GetActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active maintenance window: %w", err)
+	}
+	if window == nil {
+		return nil, ErrMaintenanceNotActive
+	}
+
+	window.End()
+	if err := s.repo.Update(ctx, window); err != nil {
+		return nil, fmt.Errorf("failed to end maintenance window: %w", err)
+	}
+
+	s.setActive(nil)
+	go s.notifyCompanies(context.Background(), entity.WebhookEventMaintenanceEnded, window)
+	return window, nil
+}
+
+// setActive refreshes the cached window after a change made by this process
+func (s *MaintenanceService) setActive(window *entity.MaintenanceWindow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active, s.cachedAt = window, time.Now()
+}
+
+// notifyCompanies dispatches the maintenance webhook to every company
+func (s *MaintenanceService) notifyCompanies(ctx context.Context, event entity.WebhookEvent, window *entity.MaintenanceWindow) {
+	if s.webhooks == nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"maintenance_id":  window.ID,
+		"message":         window.Message,
+		"started_at":      window.StartedAt,
+		"expected_end_at": window.ExpectedEndAt,
+	}
+	if event == entity.WebhookEventMaintenanceStarted {
+		payload["code"] = i18n.CodeUnderMaintenance
+		payload["messages"] = i18n.Messages(i18n.CodeUnderMaintenance)
+	} else {
+		payload["ended_at"] = window.EndedAt
+	}
+
+	const pageSize = 100
+	for offset := 0; ; offset += pageSize {
+		companies, total, err := s.companyRepo.List(ctx, pageSize, offset)
+		if err != nil {
+			logger.FromContext(ctx, s.logger).Error("Failed to list companies for maintenance webhook", logger.Err(err))
+			return
+		}
+		for _, company := range companies {
+			if err := s.webhooks.Dispatch(ctx, company.ID, event, payload); err != nil {
+				logger.FromContext(ctx, s.logger).Warn("Failed to dispatch maintenance webhook", logger.CompanyID(company.ID), logger.Err(err))
+			}
+		}
+		if len(companies) < pageSize || offset+pageSize >= total {
+			return
+		}
+	}
+}
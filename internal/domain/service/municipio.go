@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/cep"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// ErrInvalidMunicipio is returned when the municipality of an address is not in the IBGE
+// table or belongs to another UF
+var ErrInvalidMunicipio = errors.New("invalid municipality")
+
+// MunicipioService resolves the IBGE code (cMun) of the addresses from the municipality
+// table imported with scripts/catalog, or from the CEP through the configured lookup
+type MunicipioService struct {
+	repo   ports.CatalogRepository
+	cep    cep.Lookup // nil disables the lookups by CEP
+	logger logger.Logger
+}
+
+// NewMunicipioService creates a new municipality service
+func NewMunicipioService(repo ports.CatalogRepository, lookup cep.Lookup, l logger.Logger) *MunicipioService {
+	return &MunicipioService{repo: repo, cep: lookup, logger: l}
+}
+
+// ByName returns the municipality of the UF with the name, accents and case ignored;
+// nil when the table does not have it
+func (s *MunicipioService) ByName(ctx context.Context, uf, nome string) (*entity.MunicipioCatalogEntry, error) {
+	return s.repo.FindMunicipio(ctx, uf, entity.MunicipioSearchName(nome))
+}
+
+// ByCEP returns the municipality the CEP belongs to; nil when the CEP lookup is disabled or
+// the CEP is unknown
+func (s *MunicipioService) ByCEP(ctx context.Context, code string) (*entity.MunicipioCatalogEntry, error) {
+	if s.cep == nil {
+		return nil, nil
+	}
+	address, err := s.cep.Lookup(ctx, code)
+	if errors.Is(err, cep.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if address.CodigoMunicipio == "" {
+		return nil, nil
+	}
+	return &entity.MunicipioCatalogEntry{
+		Codigo:    address.CodigoMunicipio,
+		Nome:      address.Municipio,
+		UF:        address.UF,
+		NomeBusca: entity.MunicipioSearchName(address.Municipio),
+	}, nil
+}
+
+// ResolveAddress fills the cMun of an address sent without one, from its CEP or else from
+// its municipality name and UF, and checks a given cMun belongs to the UF. Until the table
+// is imported and without a CEP lookup the address is kept as sent.
+func (s *MunicipioService) ResolveAddress(ctx context.Context, address *entity.Address) error {
+	imported, err := s.repo.HasMunicipios(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check the municipality table: %w", err)
+	}
+
+	if address.CodigoMunicipio != "" {
+		if !imported {
+			return nil
+		}
+		municipio, err := s.repo.GetMunicipio(ctx, address.CodigoMunicipio)
+		if err != nil {
+			return fmt.Errorf("failed to get municipality: %w", err)
+		}
+		if municipio == nil {
+			return fmt.Errorf("%w: codigo_municipio %s não consta na tabela do IBGE", ErrInvalidMunicipio, address.CodigoMunicipio)
+		}
+		if municipio.UF != address.UF {
+			return fmt.Errorf("%w: codigo_municipio %s é de %s/%s, não da UF %s", ErrInvalidMunicipio, municipio.Codigo, municipio.Nome, municipio.UF, address.UF)
+		}
+		if address.Municipio == "" {
+			address.Municipio = municipio.Nome
+		}
+		return nil
+	}
+
+	// The CEP names the municipality even when the name was spelled differently
+	if address.CEP != "" {
+		municipio, err := s.ByCEP(ctx, address.CEP)
+		if err != nil {
+			// The name is still tried below; a lookup failure does not block the address
+			logger.FromContext(ctx, s.logger).Warn("Failed to look up CEP", logger.Field{Key: "cep", Value: address.CEP}, logger.Err(err))
+		} else if municipio != nil {
+			if municipio.UF != address.UF {
+				return fmt.Errorf("%w: CEP %s é de %s/%s, não da UF %s", ErrInvalidMunicipio, address.CEP, municipio.Nome, municipio.UF, address.UF)
+			}
+			address.CodigoMunicipio = municipio.Codigo
+			if address.Municipio == "" {
+				address.Municipio = municipio.Nome
+			}
+			return nil
+		}
+	}
+
+	if !imported {
+		return nil
+	}
+	if address.Municipio != "" {
+		municipio, err := s.ByName(ctx, address.UF, address.Municipio)
+		if err != nil {
+			return fmt.Errorf("failed to find municipality: %w", err)
+		}
+		if municipio != nil {
+			address.CodigoMunicipio = municipio.Codigo
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: município %q não encontrado na UF %s", ErrInvalidMunicipio, address.Municipio, address.UF)
+}
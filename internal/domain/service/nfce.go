@@ -3,10 +3,10 @@ package service
 import (
 	"errors"
 	"fmt"
-	"math/rand"
 	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
 )
 
 // NFCeDomainService contém regras de negócio puras da NFC-e
@@ -48,12 +48,12 @@ func (s *NFCeDomainService) ValidateCSC(csc string) error {
 
 // CalculateTotal calcula o valor total da NFC-e
 // Inclui produtos + frete - descontos
-func (s *NFCeDomainService) CalculateTotal(items []entity.Item, freight, discount float64) (float64, error) {
+func (s *NFCeDomainService) CalculateTotal(items []entity.Item, freight, discount money.Amount) (money.Amount, error) {
 	if len(items) == 0 {
 		return 0, errors.New("NFC-e deve ter pelo menos um item")
 	}
 
-	var totalProducts float64
+	var totalProducts money.Amount
 	for _, item := range items {
 		if item.Quantidade <= 0 {
 			return 0, fmt.Errorf("quantidade do item %s deve ser maior que zero", item.Descricao)
@@ -61,7 +61,7 @@ func (s *NFCeDomainService) CalculateTotal(items []entity.Item, freight, discoun
 		if item.Valor <= 0 {
 			return 0, fmt.Errorf("preço unitário do item %s deve ser maior que zero", item.Descricao)
 		}
-		totalProducts += item.Quantidade * item.Valor
+		totalProducts += item.Gross()
 	}
 
 	total := totalProducts + freight - discount
@@ -167,10 +167,7 @@ func (s *NFCeDomainService) GenerateSequentialNumber(lastNumber int) int {
 	return lastNumber + 1
 }
 
-// GenerateCNF gera o Código Numérico (cNF) de 8 dígitos
-// O cNF é um número aleatório único por NFC-e
-func (s *NFCeDomainService) GenerateCNF() string {
-	// Generate random 8-digit number (00000001 to 99999999)
-	// In production, ensure uniqueness within the company
-	return fmt.Sprintf("%08d", rand.Intn(99999999)+1)
+// GenerateCNF gera o Código Numérico (cNF) de 8 dígitos da NFC-e de número nNF
+func (s *NFCeDomainService) GenerateCNF(nNF string) (string, error) {
+	return entity.NewCNF(nNF)
 }
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+// projectionBatchSize is the number of NFC-e read per page by the rebuild
+const projectionBatchSize = 200
+
+// ProjectionCheck is an NFC-e whose row differs from the projection of its events, or whose
+// events do not follow the state machine
+type ProjectionCheck struct {
+	RequestID        string
+	Status           entity.RequestStatus // Status of the row
+	Version          int                  // Version of the row
+	ProjectedStatus  entity.RequestStatus
+	ProjectedVersion int
+	Drifted          bool
+	Rebuilt          bool
+	Anomalies        []string
+}
+
+// ProjectionRun counts the NFC-e checked by a rebuild
+type ProjectionRun struct {
+	Checked       int
+	WithoutEvents int // Requests created before the events were recorded, left as they are
+	Drifted       int
+	Rebuilt       int
+	Anomalous     int
+}
+
+// NFCeProjectionService rebuilds the NFC-e rows from their events. The events are the source
+// of the status: a row that drifted from them, after a manual change or a failed write, is
+// restored to the state the events describe.
+type NFCeProjectionService struct {
+	repo ports.NFCeRepository
+}
+
+// NewNFCeProjectionService creates a new NFC-e projection service
+func NewNFCeProjectionService(repo ports.NFCeRepository) *NFCeProjectionService {
+	return &NFCeProjectionService{repo: repo}
+}
+
+// Rebuild replays the events of the NFC-e, of every one when requestID is empty, and reports
+// the drifted or anomalous ones to report. With apply the drifted rows are rewritten with
+// their projection; a row changed by the worker meanwhile is skipped with an error.
+func (s *NFCeProjectionService) Rebuild(ctx context.Context, requestID string, apply bool, report func(ProjectionCheck)) (ProjectionRun, error) {
+	var run ProjectionRun
+
+	if requestID != "" {
+		nfce, err := s.repo.GetByID(ctx, requestID)
+		if err != nil {
+			return run, fmt.Errorf("failed to get NFC-e request: %w", err)
+		}
+		return run, s.rebuild(ctx, nfce, apply, report, &run)
+	}
+
+	var errs []error
+	afterID := ""
+	for {
+		requests, err := s.repo.ListAfter(ctx, afterID, projectionBatchSize)
+		if err != nil {
+			return run, errors.Join(append(errs, fmt.Errorf("failed to list NFC-e requests: %w", err))...)
+		}
+		for _, nfce := range requests {
+			if err := s.rebuild(ctx, nfce, apply, report, &run); err != nil {
+				errs = append(errs, fmt.Errorf("request %s: %w", nfce.ID, err))
+			}
+		}
+		if len(requests) < projectionBatchSize {
+			return run, errors.Join(errs...)
+		}
+		afterID = requests[len(requests)-1].ID
+	}
+}
+
+// rebuild checks the NFC-e against the projection of its events
+func (s *NFCeProjectionService) rebuild(ctx context.Context, nfce *entity.NFCE, apply bool, report func(ProjectionCheck), run *ProjectionRun) error {
+	run.Checked++
+
+	events, err := s.repo.ListEvents(ctx, nfce.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list events: %w", err)
+	}
+	projection, ok := entity.ProjectEvents(events)
+	if !ok {
+		run.WithoutEvents++
+		return nil
+	}
+
+	check := ProjectionCheck{
+		RequestID:        nfce.ID,
+		Status:           nfce.Status,
+		Version:          nfce.Version,
+		ProjectedStatus:  projection.Status,
+		ProjectedVersion: projection.Version,
+		Drifted:          nfce.Drifted(projection),
+		Anomalies:        projection.Anomalies,
+	}
+	if len(check.Anomalies) > 0 {
+		run.Anomalous++
+	}
+	if !check.Drifted {
+		if len(check.Anomalies) > 0 {
+			report(check)
+		}
+		return nil
+	}
+	run.Drifted++
+
+	if apply {
+		nfce.ApplyProjection(projection)
+		if err := s.repo.SaveProjection(ctx, nfce, check.Version); err != nil {
+			report(check)
+			return fmt.Errorf("failed to save projection: %w", err)
+		}
+		check.Rebuilt = true
+		run.Rebuilt++
+	}
+	report(check)
+	return nil
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// ErrFeatureNotInPlan is returned when the company plan does not include a feature
+var ErrFeatureNotInPlan = errors.New("feature not in plan")
+
+var (
+	// ErrSubscriptionInactive is returned when the company has no active subscription or its trial expired
+	ErrSubscriptionInactive = errors.New("no active subscription")
+	// ErrQuotaExhausted is returned when the company used up the NFC-e quota of the period
+	ErrQuotaExhausted = errors.New("NFC-e quota exhausted for the period")
+)
+
+// QuotaContextError is implemented by the errors of operations blocked by the company
+// plan or quota; handlers and webhooks expose the context as "quota_context"
+type QuotaContextError interface {
+	error
+	QuotaContext() *entity.QuotaContext
+}
+
+// QuotaContextOf returns the quota context carried by err, if any
+func QuotaContextOf(err error) *entity.QuotaContext {
+	var quotaErr QuotaContextError
+	if errors.As(err, &quotaErr) {
+		return quotaErr.QuotaContext()
+	}
+	return nil
+}
+
+// FeatureGateConfig holds the settings of the plan feature gate
+type FeatureGateConfig struct {
+	// UpgradeURL is where the company upgrades its plan; "{company_id}" is replaced
+	UpgradeURL string
+}
+
+// FeatureNotInPlanError describes a feature blocked by the company plan
+type FeatureNotInPlanError struct {
+	Feature  string
+	PlanID   string
+	PlanName string
+	Quota    *entity.QuotaContext
+}
+
+// Error implements the error interface
+func (e *FeatureNotInPlanError) Error() string {
+	if e.PlanName == "" {
+		return fmt.Sprintf("feature not in plan: %s", e.Feature)
+	}
+	return fmt.Sprintf("feature not in plan: %s is not available in plan %s", e.Feature, e.PlanName)
+}
+
+// Is makes errors.Is(err, ErrFeatureNotInPlan) match
+func (e *FeatureNotInPlanError) Is(target error) bool {
+	return target == ErrFeatureNotInPlan
+}
+
+// QuotaContext returns the plan and usage of the blocked company
+func (e *FeatureNotInPlanError) QuotaContext() *entity.QuotaContext {
+	return e.Quota
+}
+
+// QuotaExceededError describes an emission refused by the company subscription
+type QuotaExceededError struct {
+	Err    error  // ErrSubscriptionInactive or ErrQuotaExhausted
+	Reason string // Why the subscription is not active, e.g. the trial expired
+	Quota  *entity.QuotaContext
+}
+
+// Error implements the error interface
+func (e *QuotaExceededError) Error() string {
+	if e.Reason == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Err, e.Reason)
+}
+
+// Unwrap makes errors.Is match ErrSubscriptionInactive or ErrQuotaExhausted
+func (e *QuotaExceededError) Unwrap() error {
+	return e.Err
+}
+
+// QuotaContext returns the plan and usage of the blocked company
+func (e *QuotaExceededError) QuotaContext() *entity.QuotaContext {
+	return e.Quota
+}
+
+// FeatureGate enforces the plan features of the company active subscription
+type FeatureGate struct {
+	subscriptionRepo ports.SubscriptionRepository
+	planRepo         ports.PlanRepository
+	webhooks         ports.WebhookDispatcher
+	config           FeatureGateConfig
+	logger           logger.Logger
+}
+
+// NewFeatureGate creates a new plan feature gate
+func NewFeatureGate(
+	subscriptionRepo ports.SubscriptionRepository,
+	planRepo ports.PlanRepository,
+	webhooks ports.WebhookDispatcher,
+	config FeatureGateConfig,
+	l logger.Logger,
+) *FeatureGate {
+	return &FeatureGate{
+		subscriptionRepo: subscriptionRepo,
+		planRepo:         planRepo,
+		webhooks:         webhooks,
+		config:           config,
+		logger:           l,
+	}
+}
+
+// Require returns a FeatureNotInPlanError when the company plan does not allow the feature.
+// Blocked attempts emit the plan.feature_blocked webhook with the given details so the
+// company can be offered an upgrade.
+func (g *FeatureGate) Require(ctx context.Context, companyID, feature string, details map[string]interface{}) error {
+	blocked := &FeatureNotInPlanError{
+		Feature: feature,
+		Quota:   &entity.QuotaContext{UpgradeURL: g.UpgradeURL(companyID)},
+	}
+
+	subscription, err := g.subscriptionRepo.GetActiveByCompanyID(ctx, companyID)
+	if err != nil {
+		// No active subscription means no plan features
+		g.notifyBlocked(ctx, companyID, blocked, details)
+		return blocked
+	}
+
+	plan, err := g.planRepo.GetByID(ctx, subscription.PlanID)
+	if err != nil {
+		return fmt.Errorf("failed to get plan: %w", err)
+	}
+
+	if plan.AllowsFeature(feature) {
+		return nil
+	}
+
+	blocked.PlanID = plan.ID
+	blocked.PlanName = plan.Name
+	blocked.Quota = subscription.QuotaContext(plan)
+	blocked.Quota.UpgradeURL = g.UpgradeURL(companyID)
+	g.notifyBlocked(ctx, companyID, blocked, details)
+	return blocked
+}
+
+// CheckQuota returns a QuotaExceededError when the company active subscription does not
+// allow issuing count more NFC-e: one for an emission, the NFC-e of a lote for a lote. Usage
+// is only recorded at authorization, so the NFC-e still in flight are not counted.
+func (g *FeatureGate) CheckQuota(ctx context.Context, companyID string, count int) error {
+	subscription, err := g.subscriptionRepo.GetActiveByCompanyID(ctx, companyID)
+	if err != nil {
+		return &QuotaExceededError{
+			Err:   ErrSubscriptionInactive,
+			Quota: &entity.QuotaContext{Limit: -1, Remaining: -1, UpgradeURL: g.UpgradeURL(companyID)},
+		}
+	}
+
+	if count > 1 {
+		// An ended period is renewed with the plan quota, which must cover the whole lote
+		if subscription.Plan, err = g.planRepo.GetByID(ctx, subscription.PlanID); err != nil {
+			return fmt.Errorf("failed to get plan: %w", err)
+		}
+	}
+
+	allowed, reason := subscription.CanIssueNFCes(count)
+	if allowed {
+		return nil
+	}
+
+	plan := subscription.Plan
+	if plan == nil {
+		if plan, err = g.planRepo.GetByID(ctx, subscription.PlanID); err != nil {
+			return fmt.Errorf("failed to get plan: %w", err)
+		}
+	}
+
+	blocked := &QuotaExceededError{
+		Err:    ErrSubscriptionInactive,
+		Reason: reason,
+		Quota:  subscription.QuotaContext(plan),
+	}
+	if subscription.IsActive() && !subscription.TrialExpired() && !subscription.QuotaCovers(count) {
+		blocked.Err = ErrQuotaExhausted
+		blocked.Reason = "" // Same as the catalog message
+		if count > 1 {
+			blocked.Quota.Requested = count
+		}
+	}
+	blocked.Quota.UpgradeURL = g.UpgradeURL(companyID)
+	return blocked
+}
+
+// RecordUsage counts an authorized NFC-e against the company active subscription. It must
+// run in the transaction that persists the authorization: the subscription row stays locked
+// until it commits. It returns the quota context when this NFC-e exhausted the quota.
+func (g *FeatureGate) RecordUsage(ctx context.Context, companyID string) (*entity.QuotaContext, error) {
+	subscription, err := g.subscriptionRepo.LockActiveByCompanyID(ctx, companyID)
+	if err != nil {
+		// No active subscription means nothing to bill against
+		return nil, nil
+	}
+
+	plan, err := g.planRepo.GetByID(ctx, subscription.PlanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+	subscription.Plan = plan
+
+	exhausted := subscription.RecordNFCeUsage()
+	if err := g.subscriptionRepo.Update(ctx, subscription); err != nil {
+		return nil, fmt.Errorf("failed to update subscription usage: %w", err)
+	}
+
+	if !exhausted {
+		return nil, nil
+	}
+	quota := subscription.QuotaContext(plan)
+	quota.UpgradeURL = g.UpgradeURL(companyID)
+	return quota, nil
+}
+
+// NotifyQuotaExceeded dispatches the quota.exceeded webhook once the quota of the period is used up
+func (g *FeatureGate) NotifyQuotaExceeded(ctx context.Context, companyID string, quota *entity.QuotaContext, details map[string]interface{}) {
+	if g.webhooks == nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"code":          i18n.CodeQuotaExhausted,
+		"message":       i18n.Message(i18n.CodeQuotaExhausted, i18n.DefaultLang),
+		"messages":      i18n.Messages(i18n.CodeQuotaExhausted),
+		"quota_context": quota,
+	}
+	for k, v := range details {
+		payload[k] = v
+	}
+
+	if err := g.webhooks.Dispatch(ctx, companyID, entity.WebhookEventQuotaExceeded, payload); err != nil {
+		logger.FromContext(ctx, g.logger).Warn("Failed to dispatch quota exceeded webhook", logger.CompanyID(companyID), logger.Err(err))
+	}
+}
+
+// UpgradeURL returns the plan upgrade link of the company, empty when not configured
+func (g *FeatureGate) UpgradeURL(companyID string) string {
+	return strings.ReplaceAll(g.config.UpgradeURL, "{company_id}", companyID)
+}
+
+// notifyBlocked dispatches the plan.feature_blocked webhook
+func (g *FeatureGate) notifyBlocked(ctx context.Context, companyID string, blocked *FeatureNotInPlanError, details map[string]interface{}) {
+	if g.webhooks == nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"feature":   blocked.Feature,
+		"plan_id":   blocked.PlanID,
+		"plan_name": blocked.PlanName,
+		"code":      i18n.CodeFeatureNotInPlan,
+		"message":   i18n.Message(i18n.CodeFeatureNotInPlan, i18n.DefaultLang, blocked.Feature),
+		"messages":  i18n.Messages(i18n.CodeFeatureNotInPlan, blocked.Feature),
+	}
+	if blocked.Quota != nil {
+		payload["quota_context"] = blocked.Quota
+	}
+	for k, v := range details {
+		payload[k] = v
+	}
+
+	if err := g.webhooks.Dispatch(ctx, companyID, entity.WebhookEventPlanFeatureBlocked, payload); err != nil {
+		// Log error but don't fail - the block itself is already decided
+		logger.FromContext(ctx, g.logger).Warn("Failed to dispatch feature blocked webhook", logger.CompanyID(companyID), logger.Err(err))
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// fakeSubscriptionRepo returns the active subscription, if any
+type fakeSubscriptionRepo struct {
+	ports.SubscriptionRepository
+	active *entity.Subscription
+}
+
+func (r *fakeSubscriptionRepo) GetActiveByCompanyID(_ context.Context, _ string) (*entity.Subscription, error) {
+	if r.active == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	clone := *r.active
+	return &clone, nil
+}
+
+// fakePlanRepo returns the one plan
+type fakePlanRepo struct {
+	ports.PlanRepository
+	plan *entity.Plan
+}
+
+func (r *fakePlanRepo) GetByID(_ context.Context, _ string) (*entity.Plan, error) {
+	return r.plan, nil
+}
+
+func TestFeatureGateCheckQuota(t *testing.T) {
+	monthly := &entity.Plan{ID: "basic", Name: "Básico", QuotaType: entity.QuotaTypeMonthly, MaxNFCePerMonth: 30}
+	subscription := func(remaining int, periodEnd time.Time) *entity.Subscription {
+		return &entity.Subscription{
+			PlanID:       monthly.ID,
+			Status:       entity.SubscriptionStatusActive,
+			CurrentUsage: entity.UsageStats{NFCeRemaining: remaining, PeriodEnd: periodEnd},
+		}
+	}
+	current := time.Now().Add(24 * time.Hour)
+	ended := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		active        *entity.Subscription
+		count         int
+		wantErr       error
+		wantRequested int
+	}{
+		{name: "emission with quota left", active: subscription(2, current), count: 1},
+		{name: "lote within the quota", active: subscription(2, current), count: 2},
+		{name: "lote over the quota", active: subscription(2, current), count: 3, wantErr: ErrQuotaExhausted, wantRequested: 3},
+		{name: "emission with the quota used up", active: subscription(0, current), count: 1, wantErr: ErrQuotaExhausted},
+		{name: "unlimited", active: subscription(-1, current), count: entity.MaxLoteSize},
+		{name: "ended period within the plan quota", active: subscription(0, ended), count: 30},
+		{name: "ended period over the plan quota", active: subscription(0, ended), count: 31, wantErr: ErrQuotaExhausted, wantRequested: 31},
+		{name: "no subscription", count: 1, wantErr: ErrSubscriptionInactive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := NewFeatureGate(&fakeSubscriptionRepo{active: tt.active}, &fakePlanRepo{plan: monthly}, nil, FeatureGateConfig{}, logger.NewNopLogger())
+
+			err := gate.CheckQuota(context.Background(), "company-a", tt.count)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckQuota() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			quota := QuotaContextOf(err)
+			if quota == nil {
+				t.Fatal("CheckQuota() error carries no quota context")
+			}
+			if quota.Requested != tt.wantRequested {
+				t.Fatalf("quota_context.requested = %d, want %d", quota.Requested, tt.wantRequested)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+// RateLimitScope names a limit enforced per company
+type RateLimitScope string
+
+const (
+	RateLimitScopeRequests  RateLimitScope = "requests"  // Every API request of the company
+	RateLimitScopeEmissions RateLimitScope = "emissions" // NFC-e emission requests, single or lote
+)
+
+// rateLimitPlanTTL is how long the limits of a company are cached; a plan change applies
+// within this delay
+const rateLimitPlanTTL = time.Minute
+
+// RateLimitConfig holds the limits per minute of the companies whose plan sets none
+type RateLimitConfig struct {
+	RequestsPerMinute  int
+	EmissionsPerMinute int
+}
+
+// RateLimitDecision is the outcome of a request against a limit
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// companyLimits caches the limits of the plan of a company
+type companyLimits struct {
+	requests  int
+	emissions int
+	expiresAt time.Time
+}
+
+// RateLimitService enforces the limits per minute of the companies with token buckets,
+// taking the limits from the plan of the active subscription
+type RateLimitService struct {
+	store            ports.RateLimitStore
+	subscriptionRepo ports.SubscriptionRepository
+	planRepo         ports.PlanRepository
+	config           RateLimitConfig
+
+	mu     sync.Mutex
+	limits map[string]companyLimits
+}
+
+// NewRateLimitService creates a new rate limit service
+func NewRateLimitService(
+	store ports.RateLimitStore,
+	subscriptionRepo ports.SubscriptionRepository,
+	planRepo ports.PlanRepository,
+	config RateLimitConfig,
+) *RateLimitService {
+	return &RateLimitService{
+		store:            store,
+		subscriptionRepo: subscriptionRepo,
+		planRepo:         planRepo,
+		config:           config,
+		limits:           make(map[string]companyLimits),
+	}
+}
+
+// Allow takes a token of the company bucket of scope
+func (s *RateLimitService) Allow(ctx context.Context, companyID string, scope RateLimitScope) (RateLimitDecision, error) {
+	now := time.Now()
+	limits := s.companyLimits(ctx, companyID, now)
+
+	limit := limits.requests
+	if scope == RateLimitScopeEmissions {
+		limit = limits.emissions
+	}
+
+	allowed, remaining, retryAfter, err := s.store.Take(ctx, string(scope)+":"+companyID, limit, now)
+	if err != nil {
+		return RateLimitDecision{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return RateLimitDecision{Allowed: allowed, Limit: limit, Remaining: remaining, RetryAfter: retryAfter}, nil
+}
+
+// companyLimits returns the limits of the company plan, the configured ones for what the plan
+// leaves unset or when the company has no active subscription
+func (s *RateLimitService) companyLimits(ctx context.Context, companyID string, now time.Time) companyLimits {
+	s.mu.Lock()
+	cached, ok := s.limits[companyID]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached
+	}
+
+	limits := companyLimits{
+		requests:  s.config.RequestsPerMinute,
+		emissions: s.config.EmissionsPerMinute,
+		expiresAt: now.Add(rateLimitPlanTTL),
+	}
+	if subscription, err := s.subscriptionRepo.GetActiveByCompanyID(ctx, companyID); err == nil {
+		if plan, err := s.planRepo.GetByID(ctx, subscription.PlanID); err == nil {
+			if plan.Features.RequestsPerMinute > 0 {
+				limits.requests = plan.Features.RequestsPerMinute
+			}
+			if plan.Features.EmissionsPerMinute > 0 {
+				limits.emissions = plan.Features.EmissionsPerMinute
+			}
+		}
+	}
+
+	s.mu.Lock()
+	for id, entry := range s.limits {
+		if !now.Before(entry.expiresAt) {
+			delete(s.limits, id)
+		}
+	}
+	s.limits[companyID] = limits
+	s.mu.Unlock()
+	return limits
+}
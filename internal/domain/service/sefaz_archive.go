@@ -0,0 +1,237 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// sefazArchivePurgeBatchSize is the number of exchanges deleted per batch by the purge
+const sefazArchivePurgeBatchSize = 500
+
+// ErrNoSEFAZExchanges is returned when no exchange of the NFC-e is archived
+var ErrNoSEFAZExchanges = errors.New("no SEFAZ exchanges archived")
+
+// SEFAZArchiveConfig controls the archive of the SEFAZ exchanges
+type SEFAZArchiveConfig struct {
+	Enabled       bool
+	RetentionDays int
+	PurgeInterval time.Duration
+}
+
+// SEFAZArchiveService keeps the exact SOAP request sent to NFeAutorizacao4 and the response
+// of SEFAZ, gzip-compressed in the storage, so a dispute over an NFC-e can be settled with
+// the XML exchanged. The exchanges are deleted after the retention.
+type SEFAZArchiveService struct {
+	repo    ports.SEFAZExchangeRepository
+	storage storage.StorageService
+	config  SEFAZArchiveConfig
+	logger  logger.Logger
+}
+
+// NewSEFAZArchiveService creates a new SEFAZ archive service
+func NewSEFAZArchiveService(repo ports.SEFAZExchangeRepository, storage storage.StorageService, config SEFAZArchiveConfig, l logger.Logger) *SEFAZArchiveService {
+	return &SEFAZArchiveService{
+		repo:    repo,
+		storage: storage,
+		config:  config,
+		logger:  l,
+	}
+}
+
+// Enabled reports whether the exchanges are archived
+func (s *SEFAZArchiveService) Enabled() bool {
+	return s != nil && s.config.Enabled
+}
+
+// Interval returns how often the exchanges past the retention are deleted
+func (s *SEFAZArchiveService) Interval() time.Duration {
+	return s.config.PurgeInterval
+}
+
+// Record archives the exchange of an authorization call for the NFC-e it carried and adds
+// its ID to their SEFAZExchanges. A call that failed before the request was sent has nothing
+// to archive. An archive failure is logged and does not affect the emission.
+func (s *SEFAZArchiveService) Record(ctx context.Context, service string, requests []*entity.NFCE, response soapclient.AuthorizationResponse, callErr error) {
+	if !s.Enabled() || len(requests) == 0 || len(response.RawRequest) == 0 {
+		return
+	}
+	if err := s.record(ctx, service, requests, response, callErr); err != nil {
+		logger.FromContext(ctx, s.logger).Error("Failed to archive the SEFAZ exchange", logger.Field{Key: "service", Value: service}, logger.Field{Key: "count", Value: len(requests)}, logger.Err(err))
+	}
+}
+
+// record stores the request and response files once and an exchange per NFC-e, named
+// after the exchange of the first one
+func (s *SEFAZArchiveService) record(ctx context.Context, service string, requests []*entity.NFCE, response soapclient.AuthorizationResponse, callErr error) error {
+	now := time.Now()
+	exchanges := make([]*entity.SEFAZExchange, len(requests))
+	for i, nfceRequest := range requests {
+		exchanges[i] = entity.NewSEFAZExchange(nfceRequest, service, now)
+	}
+	prefix := fmt.Sprintf("nfce/%s/sefaz/%s/%s", requests[0].CompanyID, now.Format("2006-01-02"), exchanges[0].ID)
+
+	requestKey := prefix + "-request.xml.gz"
+	if err := s.upload(ctx, requestKey, response.RawRequest); err != nil {
+		return fmt.Errorf("failed to store request: %w", err)
+	}
+	responseKey := ""
+	if len(response.RawResponse) > 0 {
+		responseKey = prefix + "-response.xml.gz"
+		if err := s.upload(ctx, responseKey, response.RawResponse); err != nil {
+			return fmt.Errorf("failed to store response: %w", err)
+		}
+	}
+
+	for _, exchange := range exchanges {
+		exchange.CStat = response.CStat
+		exchange.RequestKey = requestKey
+		exchange.RequestSize = int64(len(response.RawRequest))
+		exchange.ResponseKey = responseKey
+		exchange.ResponseSize = int64(len(response.RawResponse))
+		if callErr != nil {
+			exchange.Error = callErr.Error()
+		}
+	}
+	if err := s.repo.Create(ctx, exchanges); err != nil {
+		return fmt.Errorf("failed to save exchanges: %w", err)
+	}
+
+	for i, nfceRequest := range requests {
+		nfceRequest.SEFAZExchanges = append(nfceRequest.SEFAZExchanges, exchanges[i].ID)
+	}
+	return nil
+}
+
+// upload stores the content gzip-compressed
+func (s *SEFAZArchiveService) upload(ctx context.Context, key string, content []byte) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	_, err := s.storage.UploadFile(ctx, "", key, &buf, "application/gzip")
+	return err
+}
+
+// Export packs the archived exchanges of the NFC-e in a zip: the request and response XML
+// of each exchange, decompressed and numbered in the order they were made, and an
+// exchanges.json index with their outcome
+func (s *SEFAZArchiveService) Export(ctx context.Context, requestID string) ([]byte, error) {
+	exchanges, err := s.repo.ListByRequestID(ctx, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SEFAZ exchanges: %w", err)
+	}
+	if len(exchanges) == 0 {
+		return nil, ErrNoSEFAZExchanges
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, exchange := range exchanges {
+		name := fmt.Sprintf("%02d-%s-%s", i+1, exchange.Service, exchange.CreatedAt.UTC().Format("20060102T150405Z"))
+		if err := s.addFile(ctx, zw, name+"-request.xml", exchange.RequestKey); err != nil {
+			return nil, err
+		}
+		if exchange.ResponseKey != "" {
+			if err := s.addFile(ctx, zw, name+"-response.xml", exchange.ResponseKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	index, err := json.MarshalIndent(exchanges, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode exchanges index: %w", err)
+	}
+	w, err := zw.Create("exchanges.json")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(index); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// addFile writes the decompressed content of the stored file to the zip
+func (s *SEFAZArchiveService) addFile(ctx context.Context, zw *zip.Writer, name, key string) error {
+	content, err := s.storage.DownloadFile(ctx, "", key)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", key, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s: %w", key, err)
+	}
+	defer zr.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, zr); err != nil {
+		return fmt.Errorf("failed to decompress %s: %w", key, err)
+	}
+	return nil
+}
+
+// PurgeExpired deletes the files and the records of the exchanges archived more than
+// RetentionDays before now, in batches. It runs even when archiving is disabled, so the
+// exchanges archived before expire. It returns the number of exchanges deleted.
+func (s *SEFAZArchiveService) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	before := now.AddDate(0, 0, -s.config.RetentionDays)
+
+	purged := 0
+	for {
+		exchanges, err := s.repo.ListCreatedBefore(ctx, before, sefazArchivePurgeBatchSize)
+		if err != nil {
+			return purged, fmt.Errorf("failed to list expired SEFAZ exchanges: %w", err)
+		}
+
+		// The NFC-e of a lote share the files, deleting them again is a no-op
+		deleted := make(map[string]bool)
+		ids := make([]string, len(exchanges))
+		for i, exchange := range exchanges {
+			ids[i] = exchange.ID
+			for _, key := range []string{exchange.RequestKey, exchange.ResponseKey} {
+				if key == "" || deleted[key] {
+					continue
+				}
+				if err := s.storage.DeleteFile(ctx, "", key); err != nil {
+					return purged, fmt.Errorf("failed to delete %s: %w", key, err)
+				}
+				deleted[key] = true
+			}
+		}
+
+		if err := s.repo.Delete(ctx, ids); err != nil {
+			return purged, fmt.Errorf("failed to delete expired SEFAZ exchanges: %w", err)
+		}
+		purged += len(exchanges)
+
+		if len(exchanges) < sefazArchivePurgeBatchSize {
+			return purged, nil
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
+)
+
+// ErrSEFAZUnavailable is returned when the SEFAZ authorizer of the UF is known to be
+// down and the NFC-e can not be issued in contingency; dispatch is delayed until it is back
+var ErrSEFAZUnavailable = errors.New("SEFAZ unavailable")
+
+// SEFAZMonitorConfig controls the NfeStatusServico4 poller and the circuit breaker
+type SEFAZMonitorConfig struct {
+	Enabled          bool
+	PollInterval     time.Duration
+	FailureThreshold int // Consecutive authorization failures that open the circuit
+}
+
+// SEFAZAvailability is the last known situation of the authorizer of a UF and ambiente
+type SEFAZAvailability struct {
+	UF        string     `json:"uf"`
+	Ambiente  string     `json:"ambiente"`
+	Available bool       `json:"available"`
+	CStat     string     `json:"cstat,omitempty"`
+	Motivo    string     `json:"motivo,omitempty"`
+	Failures  int        `json:"failures"`
+	CheckedAt time.Time  `json:"checked_at"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"`
+}
+
+// SEFAZMonitor keeps the availability of the SEFAZ authorizers the worker emits to.
+// The circuit of a UF opens when NfeStatusServico4 reports the service down or after
+// consecutive authorization failures, and closes once the status service reports it
+// in operation again.
+type SEFAZMonitor struct {
+	soapClient soapclient.Client
+	config     SEFAZMonitorConfig
+
+	mu     sync.RWMutex
+	states map[string]*SEFAZAvailability
+}
+
+// NewSEFAZMonitor creates a new SEFAZ status monitor
+func NewSEFAZMonitor(soapClient soapclient.Client, config SEFAZMonitorConfig) *SEFAZMonitor {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 3
+	}
+	return &SEFAZMonitor{
+		soapClient: soapClient,
+		config:     config,
+		states:     make(map[string]*SEFAZAvailability),
+	}
+}
+
+// Enabled reports whether the monitor is enabled
+func (m *SEFAZMonitor) Enabled() bool {
+	return m != nil && m.config.Enabled
+}
+
+// PollInterval returns how often the status service is queried
+func (m *SEFAZMonitor) PollInterval() time.Duration {
+	return m.config.PollInterval
+}
+
+// Track adds the UF and ambiente to the polled authorizers; they start as available
+func (m *SEFAZMonitor) Track(uf, ambiente string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := monitorKey(uf, ambiente)
+	if _, ok := m.states[key]; !ok {
+		m.states[key] = &SEFAZAvailability{UF: uf, Ambiente: ambiente, Available: true}
+	}
+}
+
+// Open reports whether the circuit of the UF is open, i.e. its authorizer is down
+func (m *SEFAZMonitor) Open(uf, ambiente string) (SEFAZAvailability, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.states[monitorKey(uf, ambiente)]
+	if !ok {
+		return SEFAZAvailability{}, false
+	}
+	return *state, !state.Available
+}
+
+// RecordSuccess resets the consecutive failures after SEFAZ answered an authorization
+func (m *SEFAZMonitor) RecordSuccess(uf, ambiente string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if state, ok := m.states[monitorKey(uf, ambiente)]; ok {
+		state.Failures = 0
+	}
+}
+
+// RecordFailure counts an authorization that failed because SEFAZ was unreachable or
+// paralyzed, opening the circuit at the failure threshold
+func (m *SEFAZMonitor) RecordFailure(uf, ambiente, cstat string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := monitorKey(uf, ambiente)
+	state, ok := m.states[key]
+	if !ok {
+		state = &SEFAZAvailability{UF: uf, Ambiente: ambiente, Available: true}
+		m.states[key] = state
+	}
+
+	state.Failures++
+	if state.Available && state.Failures >= m.config.FailureThreshold {
+		now := time.Now()
+		state.Available = false
+		state.CStat = cstat
+		state.Motivo = "Falhas consecutivas na autorização"
+		state.OpenedAt = &now
+	}
+}
+
+// Refresh queries NfeStatusServico4 for every tracked authorizer and returns the ones
+// whose availability changed
+func (m *SEFAZMonitor) Refresh(ctx context.Context) []SEFAZAvailability {
+	var changed []SEFAZAvailability
+	for _, state := range m.Snapshot() {
+		available, cstat, motivo := true, "", ""
+		response, err := m.soapClient.QueryStatus(ctx, state.UF, state.Ambiente)
+		if err != nil {
+			available, motivo = false, err.Error()
+		} else {
+			available = response.CStat == "107" // Serviço em operação
+			cstat, motivo = response.CStat, response.Motivo
+		}
+
+		m.mu.Lock()
+		current := m.states[monitorKey(state.UF, state.Ambiente)]
+		now := time.Now()
+		wasAvailable := current.Available
+		current.Available = available
+		current.CStat = cstat
+		current.Motivo = motivo
+		current.CheckedAt = now
+		if available {
+			current.Failures = 0
+			current.OpenedAt = nil
+		} else if wasAvailable {
+			current.OpenedAt = &now
+		}
+		if wasAvailable != available {
+			changed = append(changed, *current)
+		}
+		m.mu.Unlock()
+	}
+	return changed
+}
+
+// Snapshot returns the availability of every tracked authorizer
+func (m *SEFAZMonitor) Snapshot() []SEFAZAvailability {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	states := make([]SEFAZAvailability, 0, len(m.states))
+	for _, state := range m.states {
+		states = append(states, *state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return monitorKey(states[i].UF, states[i].Ambiente) < monitorKey(states[j].UF, states[j].Ambiente)
+	})
+	return states
+}
+
+// monitorKey identifies the authorizer of a UF in an ambiente
+func monitorKey(uf, ambiente string) string {
+	return uf + "/" + ambiente
+}
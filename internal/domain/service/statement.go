@@ -0,0 +1,294 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/clock"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/statement"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// StatementConfig controls the end-of-month usage statements
+type StatementConfig struct {
+	Enabled bool
+}
+
+// statementDocument is the JSON statement stored next to the PDF
+type statementDocument struct {
+	ID          string                  `json:"id"`
+	CompanyID   string                  `json:"company_id"`
+	CNPJ        string                  `json:"cnpj"`
+	RazaoSocial string                  `json:"razao_social"`
+	Period      string                  `json:"period"`
+	PeriodStart time.Time               `json:"period_start"`
+	PeriodEnd   time.Time               `json:"period_end"`
+	Summary     entity.StatementSummary `json:"summary"`
+	GeneratedAt time.Time               `json:"generated_at"`
+}
+
+// StatementService generates the monthly usage statement of each company, feeding billing
+// and the customer with the notes, webhook deliveries and storage of the month
+type StatementService struct {
+	statementRepo    ports.StatementRepository
+	companyRepo      ports.CompanyRepository
+	subscriptionRepo ports.SubscriptionRepository
+	planRepo         ports.PlanRepository
+	eventRepo        ports.SubscriptionEventRepository
+	nfceRepo         ports.NFCeRepository
+	webhookRepo      ports.WebhookRepository
+	storage          storage.StorageService
+	renderer         statement.Renderer
+	webhooks         ports.WebhookDispatcher
+	config           StatementConfig
+	logger           logger.Logger
+}
+
+// NewStatementService creates a new usage statement service
+func NewStatementService(
+	statementRepo ports.StatementRepository,
+	companyRepo ports.CompanyRepository,
+	subscriptionRepo ports.SubscriptionRepository,
+	planRepo ports.PlanRepository,
+	eventRepo ports.SubscriptionEventRepository,
+	nfceRepo ports.NFCeRepository,
+	webhookRepo ports.WebhookRepository,
+	storage storage.StorageService,
+	renderer statement.Renderer,
+	webhooks ports.WebhookDispatcher,
+	config StatementConfig,
+	l logger.Logger,
+) *StatementService {
+	return &StatementService{
+		statementRepo:    statementRepo,
+		companyRepo:      companyRepo,
+		subscriptionRepo: subscriptionRepo,
+		planRepo:         planRepo,
+		eventRepo:        eventRepo,
+		nfceRepo:         nfceRepo,
+		webhookRepo:      webhookRepo,
+		storage:          storage,
+		renderer:         renderer,
+		webhooks:         webhooks,
+		config:           config,
+		logger:           l,
+	}
+}
+
+// Enabled reports whether the statements are generated
+func (s *StatementService) Enabled() bool {
+	return s.config.Enabled
+}
+
+// GenerateDue generates the statement of the month before now for every company that has
+// none yet. Months follow the time zone of the company UF.
+func (s *StatementService) GenerateDue(ctx context.Context, now time.Time) (int, error) {
+	const pageSize = 100
+
+	generated := 0
+	var errs []error
+	for offset := 0; ; offset += pageSize {
+		companies, total, err := s.companyRepo.List(ctx, pageSize, offset)
+		if err != nil {
+			return generated, fmt.Errorf("failed to list companies: %w", err)
+		}
+
+		for _, company := range companies {
+			local := now.In(clock.Location(company.Endereco.UF))
+			periodStart := time.Date(local.Year(), local.Month()-1, 1, 0, 0, 0, 0, local.Location())
+			if !company.CreatedAt.Before(periodStart.AddDate(0, 1, 0)) {
+				continue // Not registered yet in that month
+			}
+
+			existing, err := s.statementRepo.GetByPeriod(ctx, company.ID, periodStart.Format(entity.StatementPeriodLayout))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("company %s: failed to get statement: %w", company.ID, err))
+				continue
+			}
+			if existing != nil {
+				continue
+			}
+
+			created, err := s.Generate(ctx, company, periodStart)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("company %s: %w", company.ID, err))
+				continue
+			}
+			if created != nil {
+				generated++
+			}
+		}
+
+		if len(companies) < pageSize || offset+pageSize >= total {
+			break
+		}
+	}
+
+	return generated, errors.Join(errs...)
+}
+
+// Generate builds and stores the statement of the month starting at periodStart and announces
+// it with the statement.available webhook. It returns nil when another worker stored it first.
+func (s *StatementService) Generate(ctx context.Context, company *entity.Company, periodStart time.Time) (*entity.UsageStatement, error) {
+	usage, err := entity.NewUsageStatement(company.ID, periodStart)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.nfceRepo.GetUsageCounts(ctx, company.ID, usage.PeriodStart, usage.PeriodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count NFC-e: %w", err)
+	}
+	plan, changes, err := s.planChanges(ctx, company.ID, usage)
+	if err != nil {
+		return nil, err
+	}
+	usage.SummarizeNFCe(counts, plan)
+	usage.Summary.PlanChanges = changes
+
+	deliveries, err := s.webhookRepo.GetDeliveryStats(ctx, company.ID, usage.PeriodStart, usage.PeriodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+	usage.Summary.Webhooks = *deliveries
+
+	prefix := fmt.Sprintf("nfce/%s/", company.ID)
+	objects, size, err := s.storage.Usage(ctx, "", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute storage usage: %w", err)
+	}
+	usage.Summary.Storage = entity.StorageUsage{Objects: objects, Bytes: size}
+
+	// Upload before claiming the period; keys are per period, so a concurrent run overwrites the same files
+	usage.JSONKey = fmt.Sprintf("%sstatements/%s.json", prefix, usage.Period)
+	usage.PDFKey = fmt.Sprintf("%sstatements/%s.pdf", prefix, usage.Period)
+
+	document, err := json.MarshalIndent(statementDocument{
+		ID:          usage.ID,
+		CompanyID:   company.ID,
+		CNPJ:        company.CNPJ,
+		RazaoSocial: company.RazaoSocial,
+		Period:      usage.Period,
+		PeriodStart: usage.PeriodStart,
+		PeriodEnd:   usage.PeriodEnd,
+		Summary:     usage.Summary,
+		GeneratedAt: usage.CreatedAt,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal statement: %w", err)
+	}
+	if _, err := s.storage.UploadFile(ctx, "", usage.JSONKey, bytes.NewReader(document), "application/json"); err != nil {
+		return nil, fmt.Errorf("failed to upload statement JSON: %w", err)
+	}
+
+	var pdf bytes.Buffer
+	if err := s.renderer.RenderTo(&pdf, company, usage); err != nil {
+		return nil, err
+	}
+	if _, err := s.storage.UploadFile(ctx, "", usage.PDFKey, &pdf, "application/pdf"); err != nil {
+		return nil, fmt.Errorf("failed to upload statement PDF: %w", err)
+	}
+
+	created, err := s.statementRepo.CreateIfAbsent(ctx, usage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save statement: %w", err)
+	}
+	if !created {
+		return nil, nil
+	}
+
+	s.notifyAvailable(ctx, usage)
+	return usage, nil
+}
+
+// planChanges lists the plan changes of the company within the statement period and returns
+// the plan in effect at its end: the plan left by the first change after the period, or the
+// current one when there was none
+func (s *StatementService) planChanges(ctx context.Context, companyID string, usage *entity.UsageStatement) (*entity.Plan, []entity.StatementPlanChange, error) {
+	events, err := s.eventRepo.ListByCompanyID(ctx, companyID, usage.PeriodStart, time.Now())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list plan changes: %w", err)
+	}
+
+	plans := make(map[string]*entity.Plan)
+	planByID := func(id string) *entity.Plan {
+		plan, ok := plans[id]
+		if !ok {
+			if plan, err = s.planRepo.GetByID(ctx, id); err != nil {
+				plan = nil
+			}
+			plans[id] = plan
+		}
+		return plan
+	}
+	planName := func(id string) string {
+		if plan := planByID(id); plan != nil {
+			return plan.Name
+		}
+		return ""
+	}
+
+	var changes []entity.StatementPlanChange
+	for _, event := range events {
+		if event.Type != entity.SubscriptionEventPlanChanged {
+			continue
+		}
+		if !event.CreatedAt.Before(usage.PeriodEnd) {
+			return planByID(event.FromPlanID), changes, nil
+		}
+		changes = append(changes, entity.StatementPlanChange{
+			ChangedAt:    event.CreatedAt,
+			FromPlanID:   event.FromPlanID,
+			FromPlanName: planName(event.FromPlanID),
+			ToPlanID:     event.ToPlanID,
+			ToPlanName:   planName(event.ToPlanID),
+			Proration:    event.Amount,
+		})
+	}
+	return s.currentPlan(ctx, companyID), changes, nil
+}
+
+// currentPlan returns the plan of the company active subscription, nil without one
+func (s *StatementService) currentPlan(ctx context.Context, companyID string) *entity.Plan {
+	subscription, err := s.subscriptionRepo.GetActiveByCompanyID(ctx, companyID)
+	if err != nil {
+		return nil
+	}
+	plan, err := s.planRepo.GetByID(ctx, subscription.PlanID)
+	if err != nil {
+		return nil
+	}
+	return plan
+}
+
+// notifyAvailable dispatches the statement.available webhook with links to both files
+func (s *StatementService) notifyAvailable(ctx context.Context, usage *entity.UsageStatement) {
+	if s.webhooks == nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"statement_id": usage.ID,
+		"period":       usage.Period,
+		"period_start": usage.PeriodStart,
+		"period_end":   usage.PeriodEnd,
+		"summary":      usage.Summary,
+	}
+	if url, err := s.storage.GetFileURL(ctx, "", usage.JSONKey); err == nil {
+		payload["json_url"] = url
+	}
+	if url, err := s.storage.GetFileURL(ctx, "", usage.PDFKey); err == nil {
+		payload["pdf_url"] = url
+	}
+
+	if err := s.webhooks.Dispatch(ctx, usage.CompanyID, entity.WebhookEventStatementAvailable, payload); err != nil {
+		logger.FromContext(ctx, s.logger).Warn("Failed to dispatch statement webhook", logger.CompanyID(usage.CompanyID), logger.Err(err))
+	}
+}
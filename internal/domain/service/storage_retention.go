@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+)
+
+// storageRetentionRoot is the key prefix of the company files, nfce/{company_id}/...
+const storageRetentionRoot = "nfce/"
+
+// StorageRetentionConfig controls the expiration of the stored files by the plan retention
+type StorageRetentionConfig struct {
+	Enabled  bool
+	Interval time.Duration
+}
+
+// StorageRetentionService keeps the bucket lifecycle rules in line with the StorageDays of
+// each company plan, so the backend expires the XML, DANFE and other files of the company
+// without the application deleting them
+type StorageRetentionService struct {
+	companyRepo      ports.CompanyRepository
+	subscriptionRepo ports.SubscriptionRepository
+	planRepo         ports.PlanRepository
+	storage          storage.StorageService
+	config           StorageRetentionConfig
+}
+
+// NewStorageRetentionService creates a new storage retention service
+func NewStorageRetentionService(
+	companyRepo ports.CompanyRepository,
+	subscriptionRepo ports.SubscriptionRepository,
+	planRepo ports.PlanRepository,
+	storage storage.StorageService,
+	config StorageRetentionConfig,
+) *StorageRetentionService {
+	return &StorageRetentionService{
+		companyRepo:      companyRepo,
+		subscriptionRepo: subscriptionRepo,
+		planRepo:         planRepo,
+		storage:          storage,
+		config:           config,
+	}
+}
+
+// Enabled reports whether the retention is applied, which needs a backend with lifecycle rules
+func (s *StorageRetentionService) Enabled() bool {
+	_, ok := s.storage.(storage.LifecycleManager)
+	return s.config.Enabled && ok
+}
+
+// Interval returns how often the lifecycle rules are synced
+func (s *StorageRetentionService) Interval() time.Duration {
+	return s.config.Interval
+}
+
+// Sync replaces the retention rules of the bucket with one rule per company whose active
+// plan limits StorageDays. Companies without an active subscription or with an unlimited
+// plan keep their files. It returns the number of rules applied.
+func (s *StorageRetentionService) Sync(ctx context.Context) (int, error) {
+	const pageSize = 100
+
+	lifecycle, ok := s.storage.(storage.LifecycleManager)
+	if !ok {
+		return 0, errors.New("storage backend does not support lifecycle rules")
+	}
+
+	var rules []storage.RetentionRule
+	for offset := 0; ; offset += pageSize {
+		companies, total, err := s.companyRepo.List(ctx, pageSize, offset)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list companies: %w", err)
+		}
+
+		for _, company := range companies {
+			if days := planStorageDays(ctx, s.subscriptionRepo, s.planRepo, company.ID); days > 0 {
+				rules = append(rules, storage.RetentionRule{
+					Prefix: fmt.Sprintf("%s%s/", storageRetentionRoot, company.ID),
+					Days:   days,
+				})
+			}
+		}
+
+		if len(companies) < pageSize || offset+pageSize >= total {
+			break
+		}
+	}
+
+	if err := lifecycle.ApplyRetention(ctx, "", storageRetentionRoot, rules); err != nil {
+		return 0, err
+	}
+	return len(rules), nil
+}
+
+// planStorageDays returns the retention of the company plan, zero when unlimited or unknown
+func planStorageDays(ctx context.Context, subscriptionRepo ports.SubscriptionRepository, planRepo ports.PlanRepository, companyID string) int {
+	subscription, err := subscriptionRepo.GetActiveByCompanyID(ctx, companyID)
+	if err != nil || subscription == nil {
+		return 0
+	}
+	plan, err := planRepo.GetByID(ctx, subscription.PlanID)
+	if err != nil || plan == nil {
+		return 0
+	}
+	return plan.Features.StorageDays
+}
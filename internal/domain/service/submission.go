@@ -0,0 +1,177 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	nfceInfra "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// duplicateChaveRegex finds the chave de acesso of the NFC-e already authorized in the
+// xMotivo of cStat 539, e.g. "... diferença na Chave de Acesso [chNFe: 3519...]"
+var duplicateChaveRegex = regexp.MustCompile(`chNFe:\s*(\d{44})`)
+
+// submissionKey is where the signed XML of the last submission of the request is kept
+// until the NFC-e reaches a final status
+func submissionKey(nfceRequest *entity.NFCE) string {
+	return fmt.Sprintf("nfce/%s/submissions/%s.xml", nfceRequest.CompanyID, nfceRequest.ID)
+}
+
+// recordSubmission keeps the signed XML before it is sent to SEFAZ, so a retry after a
+// crash or timeout finds the chave that may already be authorized
+func (s *NFCeWorkerService) recordSubmission(ctx context.Context, nfceRequest *entity.NFCE, signedXML []byte) error {
+	if _, err := s.storage.UploadFile(ctx, "", submissionKey(nfceRequest), bytes.NewReader(signedXML), "application/xml"); err != nil {
+		return fmt.Errorf("failed to record submission: %w", err)
+	}
+	return nil
+}
+
+// clearSubmission drops the record of the last submission once the NFC-e reached a final status
+func (s *NFCeWorkerService) clearSubmission(ctx context.Context, nfceRequest *entity.NFCE) {
+	if err := s.storage.DeleteFile(ctx, "", submissionKey(nfceRequest)); err != nil {
+		// Log error but don't fail - a stale record only costs a consulta on the next retry
+		s.log(ctx).Warn("Failed to clear submission record", logger.Err(err))
+	}
+}
+
+// resolvePreviousSubmission queries NfeConsultaProtocolo4 for the chave of the last submission
+// of the request, when there was one. It reports true when SEFAZ already decided on that NFC-e,
+// which then takes its protocol instead of being sent again under a new chave. An unanswered
+// consulta fails, so the retry never risks a second authorized NFC-e for the sale.
+func (s *NFCeWorkerService) resolvePreviousSubmission(ctx context.Context, nfceRequest *entity.NFCE) (bool, error) {
+	key := submissionKey(nfceRequest)
+	exists, err := s.storage.FileExists(ctx, "", key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check previous submission: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	signedXML, err := s.storage.DownloadFile(ctx, "", key)
+	if err != nil {
+		return false, fmt.Errorf("failed to load previous submission: %w", err)
+	}
+	var previous nfceInfra.NFCe
+	if err := xml.Unmarshal(signedXML, &previous); err != nil || len(previous.InfNFe.Id) != 47 {
+		// Not a signed NFC-e: nothing SEFAZ could have authorized
+		s.clearSubmission(ctx, nfceRequest)
+		return false, nil
+	}
+	chaveAcesso := previous.InfNFe.Id[3:]
+
+	response, err := s.queryProtocol(ctx, nfceRequest, chaveAcesso)
+	if err != nil {
+		return false, fmt.Errorf("failed to query previous submission %s: %w", chaveAcesso, err)
+	}
+
+	switch response.Status {
+	case "authorized":
+		nfceRequest.Numero = previous.InfNFe.Ide.NNF
+		nfceRequest.Serie = previous.InfNFe.Ide.Serie
+		return true, s.handleAuthorized(ctx, nfceRequest, chaveAcesso, signedXML, response)
+	case "denied":
+		return true, s.handleRejected(ctx, nfceRequest, response)
+	}
+
+	// Not in the SEFAZ base (e.g. cStat 217): the NFC-e is built again
+	s.log(ctx).Info("Previous submission not found at SEFAZ, emitting again", logger.Chave(chaveAcesso), logger.Field{Key: "cstat", Value: response.CStat})
+	return false, nil
+}
+
+// SyncWithSEFAZ queries NfeConsultaProtocolo4 for the chave de acesso recorded on the request,
+// when there is one, as asked by the admin reprocess of a stuck NFC-e. It reports true when
+// SEFAZ already decided on that NFC-e; one SEFAZ does not know is emitted again as usual.
+func (s *NFCeWorkerService) SyncWithSEFAZ(ctx context.Context, nfceRequest *entity.NFCE) (bool, error) {
+	chaveAcesso := nfceRequest.ChaveAcesso
+	if chaveAcesso == "" {
+		return false, nil
+	}
+
+	response, err := s.queryProtocol(ctx, nfceRequest, chaveAcesso)
+	if err != nil {
+		return false, fmt.Errorf("failed to query NFC-e %s: %w", chaveAcesso, err)
+	}
+
+	switch response.Status {
+	case "authorized":
+		signedXML, err := s.submissionXML(ctx, nfceRequest, chaveAcesso)
+		if err != nil {
+			return false, fmt.Errorf("NFC-e %s authorized by SEFAZ, whose XML was not found: %w", chaveAcesso, err)
+		}
+		var authorized nfceInfra.NFCe
+		if err := xml.Unmarshal(signedXML, &authorized); err == nil {
+			nfceRequest.Numero = authorized.InfNFe.Ide.NNF
+			nfceRequest.Serie = authorized.InfNFe.Ide.Serie
+		}
+		return true, s.handleAuthorized(ctx, nfceRequest, chaveAcesso, signedXML, response)
+	case "denied":
+		return true, s.handleRejected(ctx, nfceRequest, response)
+	}
+
+	s.log(ctx).Info("NFC-e not found at SEFAZ, emitting again", logger.Chave(chaveAcesso), logger.Field{Key: "cstat", Value: response.CStat})
+	return false, nil
+}
+
+// ConsultProtocol queries NfeConsultaProtocolo4 for the current situation of the NFC-e at
+// SEFAZ, with the certificate of its company, as shown by the public consulta
+func (s *NFCeWorkerService) ConsultProtocol(ctx context.Context, nfceRequest *entity.NFCE) (soapclient.AuthorizationResponse, error) {
+	return s.queryProtocol(ctx, nfceRequest, nfceRequest.ChaveAcesso)
+}
+
+// resolveDuplicate handles cStat 204 (duplicidade) and 539 (duplicidade com diferença na
+// chave): the NFC-e already authorized takes the place of the one just sent. It reports
+// false when the authorized NFC-e or its XML can not be found.
+func (s *NFCeWorkerService) resolveDuplicate(ctx context.Context, nfceRequest *entity.NFCE, chaveAcesso string, signedXML []byte, response soapclient.AuthorizationResponse) (bool, error) {
+	duplicate := chaveAcesso
+	if response.CStat == "539" {
+		if match := duplicateChaveRegex.FindStringSubmatch(response.Motivo); match != nil {
+			duplicate = match[1]
+		}
+	}
+
+	confirmed, ok := s.confirmAuthorization(ctx, nfceRequest, duplicate)
+	if !ok {
+		return false, nil
+	}
+	if duplicate == chaveAcesso {
+		return true, s.handleAuthorized(ctx, nfceRequest, chaveAcesso, signedXML, confirmed)
+	}
+
+	// The NFC-e authorized under another chave is the one of an earlier submission
+	duplicateXML, err := s.submissionXML(ctx, nfceRequest, duplicate)
+	if err != nil {
+		s.log(ctx).Warn("NFC-e authorized under another chave, whose XML was not found", logger.Chave(duplicate), logger.Err(err))
+		return false, nil
+	}
+	var authorized nfceInfra.NFCe
+	if err := xml.Unmarshal(duplicateXML, &authorized); err == nil {
+		nfceRequest.Numero = authorized.InfNFe.Ide.NNF
+		nfceRequest.Serie = authorized.InfNFe.Ide.Serie
+	}
+	return true, s.handleAuthorized(ctx, nfceRequest, duplicate, duplicateXML, confirmed)
+}
+
+// submissionXML looks for the signed XML of the chave in the submission record and in the
+// stored XMLs of the company
+func (s *NFCeWorkerService) submissionXML(ctx context.Context, nfceRequest *entity.NFCE, chaveAcesso string) ([]byte, error) {
+	for _, key := range []string{
+		submissionKey(nfceRequest),
+		fmt.Sprintf("nfce/%s/xml/%s.xml", nfceRequest.CompanyID, chaveAcesso),
+	} {
+		content, err := s.storage.DownloadFile(ctx, "", key)
+		if err != nil {
+			continue
+		}
+		if bytes.Contains(content, []byte(`Id="NFe`+chaveAcesso+`"`)) {
+			return content, nil
+		}
+	}
+	return nil, fmt.Errorf("no signed XML of chave %s", chaveAcesso)
+}
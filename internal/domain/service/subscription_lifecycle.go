@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// lifecycleBatchSize is the number of subscriptions handled per run; the rest waits for the next one
+const lifecycleBatchSize = 500
+
+// SubscriptionLifecycleConfig controls the scheduled transitions of the subscriptions
+type SubscriptionLifecycleConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	// ConvertTrials makes an ended trial with a payment method active, to be charged by the
+	// billing; set when a payment gateway is configured. Other ended trials expire.
+	ConvertTrials bool
+}
+
+// LifecycleRun counts the transitions of one lifecycle run
+type LifecycleRun struct {
+	Expired   int
+	Converted int
+	Renewed   int
+}
+
+// SubscriptionLifecycleService moves the subscriptions through their lifecycle on a schedule:
+// it ends the trials at TrialEndsAt, expires the fixed-period subscriptions at EndsAt and
+// starts the new usage period of the active ones when the current period ends, so the quota
+// is renewed before the next emission instead of on it
+type SubscriptionLifecycleService struct {
+	subscriptionRepo ports.SubscriptionRepository
+	planRepo         ports.PlanRepository
+	txManager        ports.TxManager
+	webhooks         ports.WebhookDispatcher
+	config           SubscriptionLifecycleConfig
+	logger           logger.Logger
+}
+
+// NewSubscriptionLifecycleService creates a new subscription lifecycle service
+func NewSubscriptionLifecycleService(
+	subscriptionRepo ports.SubscriptionRepository,
+	planRepo ports.PlanRepository,
+	txManager ports.TxManager,
+	webhooks ports.WebhookDispatcher,
+	config SubscriptionLifecycleConfig,
+	l logger.Logger,
+) *SubscriptionLifecycleService {
+	return &SubscriptionLifecycleService{
+		subscriptionRepo: subscriptionRepo,
+		planRepo:         planRepo,
+		txManager:        txManager,
+		webhooks:         webhooks,
+		config:           config,
+		logger:           l,
+	}
+}
+
+// Enabled reports whether the lifecycle transitions run
+func (s *SubscriptionLifecycleService) Enabled() bool {
+	return s.config.Enabled
+}
+
+// Interval returns how often the lifecycle transitions run
+func (s *SubscriptionLifecycleService) Interval() time.Duration {
+	return s.config.Interval
+}
+
+// RunDue applies the transitions due at now and announces the expired subscriptions with the
+// subscription.expired webhook. An expired subscription is no longer active, which blocks
+// the emissions of the company.
+func (s *SubscriptionLifecycleService) RunDue(ctx context.Context, now time.Time) (LifecycleRun, error) {
+	var run LifecycleRun
+
+	subscriptions, err := s.subscriptionRepo.ListLifecycleDue(ctx, now, lifecycleBatchSize)
+	if err != nil {
+		return run, fmt.Errorf("failed to list subscriptions due for lifecycle: %w", err)
+	}
+
+	var errs []error
+	for _, due := range subscriptions {
+		if err := s.advance(ctx, due.ID, now, &run); err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", due.ID, err))
+		}
+	}
+	return run, errors.Join(errs...)
+}
+
+// advance applies the due transitions to the subscription. Its row is locked, so the usage
+// recorded by a concurrent authorization is not overwritten.
+func (s *SubscriptionLifecycleService) advance(ctx context.Context, id string, now time.Time, run *LifecycleRun) error {
+	var subscription *entity.Subscription
+	reason := ""
+	converted, renewed := false, false
+
+	transition := func(ctx context.Context) error {
+		locked, err := s.subscriptionRepo.LockByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to lock subscription: %w", err)
+		}
+		subscription = locked
+
+		switch {
+		case locked.FixedPeriodEnded(now):
+			locked.Expire(now)
+			reason = entity.ExpirationReasonPeriodEnded
+		case locked.TrialEnded(now):
+			if s.config.ConvertTrials && locked.BillingInfo.PaymentMethod != "" {
+				locked.ConvertTrial(now)
+				converted = true
+			} else {
+				locked.Expire(now)
+				reason = entity.ExpirationReasonTrialEnded
+			}
+		}
+
+		if locked.IsActive() {
+			plan, err := s.planRepo.GetByID(ctx, locked.PlanID)
+			if err != nil {
+				return fmt.Errorf("failed to load plan: %w", err)
+			}
+			locked.Plan = plan
+			renewed = locked.RenewUsagePeriod(now)
+		}
+
+		if reason == "" && !converted && !renewed {
+			return nil
+		}
+		if err := s.subscriptionRepo.Update(ctx, locked); err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+		return nil
+	}
+
+	var err error
+	if s.txManager == nil {
+		err = transition(ctx)
+	} else {
+		err = s.txManager.WithinTx(ctx, transition)
+	}
+	if err != nil {
+		return err
+	}
+
+	if converted {
+		run.Converted++
+	}
+	if renewed {
+		run.Renewed++
+	}
+	if reason != "" {
+		run.Expired++
+		s.notifyExpired(ctx, subscription, reason, now)
+	}
+	return nil
+}
+
+// notifyExpired dispatches the subscription.expired webhook to the company
+func (s *SubscriptionLifecycleService) notifyExpired(ctx context.Context, subscription *entity.Subscription, reason string, now time.Time) {
+	if s.webhooks == nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"plan_id":         subscription.PlanID,
+		"reason":          reason,
+		"trial_ends_at":   subscription.TrialEndsAt,
+		"ends_at":         subscription.EndsAt,
+		"expired_at":      now,
+	}
+	if err := s.webhooks.Dispatch(ctx, subscription.CompanyID, entity.WebhookEventSubscriptionExpired, payload); err != nil {
+		logger.FromContext(ctx, s.logger).Warn("Failed to dispatch subscription.expired webhook", logger.CompanyID(subscription.CompanyID), logger.Err(err))
+	}
+}
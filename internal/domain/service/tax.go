@@ -0,0 +1,347 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	nfceInfra "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// modBCValorOperacao is the ICMS base calculation mode "valor da operação"
+const modBCValorOperacao = "3"
+
+// TaxTables are the aliquot tables used when no company tax rule applies
+type TaxTables struct {
+	// ICMS is the internal ICMS aliquot by UF
+	ICMS map[string]float64 `json:"icms"`
+	// PIS and COFINS are the aliquots by tax regime (Simples Nacional pays them in the DAS)
+	PIS    map[entity.TaxRegime]float64 `json:"pis"`
+	COFINS map[entity.TaxRegime]float64 `json:"cofins"`
+	// Tributos is the approximate tax burden (Lei 12.741/2012) by NCM or NCM prefix.
+	// Items without an entry report the sum of the computed taxes in vTotTrib.
+	Tributos map[string]float64 `json:"tributos"`
+	// CFOPST lists the CFOPs of goods with ICMS already retained by substituição tributária
+	CFOPST []string `json:"cfop_st"`
+}
+
+// DefaultTaxTables returns the modal internal ICMS aliquots and the PIS/COFINS
+// aliquots of the cumulative (Lucro Presumido) and non-cumulative (Lucro Real) regimes
+func DefaultTaxTables() TaxTables {
+	return TaxTables{
+		ICMS: map[string]float64{
+			"AC": 19, "AL": 19, "AM": 20, "AP": 18, "BA": 20.5, "CE": 20, "DF": 20,
+			"ES": 17, "GO": 19, "MA": 23, "MG": 18, "MS": 17, "MT": 17, "PA": 19,
+			"PB": 20, "PE": 20.5, "PI": 22.5, "PR": 19.5, "RJ": 22, "RN": 20, "RO": 19.5,
+			"RR": 20, "RS": 17, "SC": 17, "SE": 20, "SP": 18, "TO": 20,
+		},
+		PIS: map[entity.TaxRegime]float64{
+			entity.TaxRegimeLucroPresumido: 0.65,
+			entity.TaxRegimeLucroReal:      1.65,
+		},
+		COFINS: map[entity.TaxRegime]float64{
+			entity.TaxRegimeLucroPresumido: 3.00,
+			entity.TaxRegimeLucroReal:      7.60,
+		},
+		Tributos: map[string]float64{},
+		CFOPST:   []string{"5405", "5656", "5667"},
+	}
+}
+
+// LoadTaxTables returns the default tax tables overridden by the entries of the
+// JSON file at path, if any
+func LoadTaxTables(path string) (TaxTables, error) {
+	tables := DefaultTaxTables()
+	if path == "" {
+		return tables, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TaxTables{}, fmt.Errorf("failed to read tax tables file: %w", err)
+	}
+
+	var overrides TaxTables
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return TaxTables{}, fmt.Errorf("failed to parse tax tables file: %w", err)
+	}
+
+	for uf, aliquota := range overrides.ICMS {
+		tables.ICMS[strings.ToUpper(uf)] = aliquota
+	}
+	for regime, aliquota := range overrides.PIS {
+		tables.PIS[regime] = aliquota
+	}
+	for regime, aliquota := range overrides.COFINS {
+		tables.COFINS[regime] = aliquota
+	}
+	for ncm, aliquota := range overrides.Tributos {
+		tables.Tributos[ncm] = aliquota
+	}
+	if overrides.CFOPST != nil {
+		tables.CFOPST = overrides.CFOPST
+	}
+
+	return tables, nil
+}
+
+// TaxEngine fills the ICMS, PIS and COFINS groups, the IPI and II of the NF-e items and
+// vTotTrib from the company regime, the item NCM/CFOP and the company tax rules
+type TaxEngine struct {
+	tables TaxTables
+}
+
+// NewTaxEngine creates a new tax engine
+func NewTaxEngine(tables TaxTables) *TaxEngine {
+	return &TaxEngine{tables: tables}
+}
+
+// itemTax is the resolved tax situation of an item
+type itemTax struct {
+	origem         string
+	cstICMS        string
+	aliquotaICMS   float64
+	cstPIS         string
+	aliquotaPIS    float64
+	cstCOFINS      string
+	aliquotaCOFINS float64
+	tributos       *float64
+}
+
+// Compute returns the taxes of an item sold by the company in the UF; vProd is the
+// value of the operation (vProd - vDesc + vFrete + vOutro) the taxes are levied on
+func (e *TaxEngine) Compute(company *entity.Company, uf string, item entity.Item, vProd money.Amount) nfceInfra.ImpostoInput {
+	tax := e.defaults(company.RegimeTributario, uf, item)
+	if rule, ok := company.TaxRules.Match(item.NCM, item.CFOP); ok {
+		tax = applyTaxRule(tax, rule)
+	}
+
+	icms, vICMS := buildICMSInput(company.RegimeTributario, tax, vProd)
+	pis, vPIS := buildPISInput(tax.cstPIS, tax.aliquotaPIS, vProd)
+	cofins, vCOFINS := buildCOFINSInput(tax.cstCOFINS, tax.aliquotaCOFINS, vProd)
+	ipi, vIPI := buildIPIInput(item.IPI, vProd)
+	ii, vII := buildIIInput(item.II)
+
+	vTotTrib := vICMS + vPIS + vCOFINS + vIPI + vII
+	if tax.tributos != nil {
+		vTotTrib = vProd.Percent(*tax.tributos)
+	}
+
+	return nfceInfra.ImpostoInput{
+		VTotTrib: stringPtr(vTotTrib.String()),
+		ICMS:     icms,
+		IPI:      ipi,
+		II:       ii,
+		PIS:      pis,
+		COFINS:   cofins,
+	}
+}
+
+// defaults resolves the item taxes from the regime tables
+func (e *TaxEngine) defaults(regime entity.TaxRegime, uf string, item entity.Item) itemTax {
+	st := e.isST(item.CFOP)
+	tax := itemTax{origem: "0"}
+
+	if regime == entity.TaxRegimeSimplesNacional {
+		tax.cstICMS = "102"
+		if st {
+			tax.cstICMS = "500"
+		}
+		tax.cstPIS, tax.cstCOFINS = "49", "49"
+	} else {
+		tax.cstICMS = "00"
+		tax.aliquotaICMS = e.tables.ICMS[strings.ToUpper(uf)]
+		if st {
+			tax.cstICMS = "60"
+		}
+		tax.cstPIS, tax.aliquotaPIS = "01", e.tables.PIS[regime]
+		tax.cstCOFINS, tax.aliquotaCOFINS = "01", e.tables.COFINS[regime]
+	}
+
+	if aliquota, ok := e.tributos(item.NCM); ok {
+		tax.tributos = &aliquota
+	}
+	return tax
+}
+
+// isST reports whether the CFOP sells goods with ICMS retained by substituição tributária
+func (e *TaxEngine) isST(cfop string) bool {
+	for _, st := range e.tables.CFOPST {
+		if st == cfop {
+			return true
+		}
+	}
+	return false
+}
+
+// tributos returns the approximate tax burden of the longest NCM prefix in the table
+func (e *TaxEngine) tributos(ncm string) (float64, bool) {
+	for prefix := ncm; prefix != ""; prefix = prefix[:len(prefix)-1] {
+		if aliquota, ok := e.tables.Tributos[prefix]; ok {
+			return aliquota, true
+		}
+	}
+	return 0, false
+}
+
+// applyTaxRule overrides the defaults with the fields set in the company rule
+func applyTaxRule(tax itemTax, rule entity.TaxRule) itemTax {
+	if rule.Origem != "" {
+		tax.origem = rule.Origem
+	}
+	if rule.CSTICMS != "" {
+		tax.cstICMS = rule.CSTICMS
+	}
+	if rule.AliquotaICMS != nil {
+		tax.aliquotaICMS = *rule.AliquotaICMS
+	}
+	if rule.CSTPIS != "" {
+		tax.cstPIS = rule.CSTPIS
+	}
+	if rule.AliquotaPIS != nil {
+		tax.aliquotaPIS = *rule.AliquotaPIS
+	}
+	if rule.CSTCOFINS != "" {
+		tax.cstCOFINS = rule.CSTCOFINS
+	}
+	if rule.AliquotaCOFINS != nil {
+		tax.aliquotaCOFINS = *rule.AliquotaCOFINS
+	}
+	if rule.AliquotaTributos != nil {
+		tax.tributos = rule.AliquotaTributos
+	}
+	return tax
+}
+
+// buildICMSInput builds the ICMS (CST) or ICMSSN (CSOSN) group and returns the ICMS value
+func buildICMSInput(regime entity.TaxRegime, tax itemTax, vProd money.Amount) (nfceInfra.ICMSInput, money.Amount) {
+	if regime == entity.TaxRegimeSimplesNacional {
+		icms := nfceInfra.ICMSInput{Tipo: "ICMSSN" + tax.cstICMS, Orig: tax.origem, CST: tax.cstICMS}
+		if tax.cstICMS == "500" {
+			icms.VBCST = stringPtr(zeroMoney)
+			icms.VICMSST = stringPtr(zeroMoney)
+		}
+		return icms, 0
+	}
+
+	icms := nfceInfra.ICMSInput{Tipo: "ICMS" + tax.cstICMS, Orig: tax.origem, CST: tax.cstICMS}
+	switch tax.cstICMS {
+	case "00":
+		vICMS := vProd.Percent(tax.aliquotaICMS)
+		icms.ModBC = stringPtr(modBCValorOperacao)
+		icms.VBC = stringPtr(vProd.String())
+		icms.PICMS = stringPtr(formatRate(tax.aliquotaICMS))
+		icms.VICMS = stringPtr(vICMS.String())
+		return icms, vICMS
+	case "60":
+		// The ICMS was retained by the supplier; the retained values are not known here
+		icms.VBCST = stringPtr(zeroMoney)
+		icms.VICMSST = stringPtr(zeroMoney)
+	}
+	return icms, 0
+}
+
+// buildPISInput builds the PIS group for the CST and returns the PIS value
+func buildPISInput(cst string, aliquota float64, vProd money.Amount) (nfceInfra.PISInput, money.Amount) {
+	switch cst {
+	case "01", "02":
+		vPIS := vProd.Percent(aliquota)
+		return nfceInfra.PISInput{
+			Tipo: "PISAliq",
+			CST:  cst,
+			VBC:  stringPtr(vProd.String()),
+			PPIS: stringPtr(formatRate(aliquota)),
+			VPIS: stringPtr(vPIS.String()),
+		}, vPIS
+	case "04", "05", "06", "07", "08", "09":
+		return nfceInfra.PISInput{Tipo: "PISNT", CST: cst}, 0
+	}
+
+	// Other operations: taxed only when an aliquot is set (e.g. CST 49 of the Simples Nacional)
+	var vBC money.Amount
+	if aliquota > 0 {
+		vBC = vProd
+	}
+	vPIS := vBC.Percent(aliquota)
+	return nfceInfra.PISInput{
+		Tipo: "PISOutr",
+		CST:  cst,
+		VBC:  stringPtr(vBC.String()),
+		PPIS: stringPtr(formatRate(aliquota)),
+		VPIS: stringPtr(vPIS.String()),
+	}, vPIS
+}
+
+// buildCOFINSInput builds the COFINS group for the CST and returns the COFINS value
+func buildCOFINSInput(cst string, aliquota float64, vProd money.Amount) (nfceInfra.COFINSInput, money.Amount) {
+	switch cst {
+	case "01", "02":
+		vCOFINS := vProd.Percent(aliquota)
+		return nfceInfra.COFINSInput{
+			Tipo:    "COFINSAliq",
+			CST:     cst,
+			VBC:     stringPtr(vProd.String()),
+			PCOFINS: stringPtr(formatRate(aliquota)),
+			VCOFINS: stringPtr(vCOFINS.String()),
+		}, vCOFINS
+	case "04", "05", "06", "07", "08", "09":
+		return nfceInfra.COFINSInput{Tipo: "COFINSNT", CST: cst}, 0
+	}
+
+	var vBC money.Amount
+	if aliquota > 0 {
+		vBC = vProd
+	}
+	vCOFINS := vBC.Percent(aliquota)
+	return nfceInfra.COFINSInput{
+		Tipo:    "COFINSOutr",
+		CST:     cst,
+		VBC:     stringPtr(vBC.String()),
+		PCOFINS: stringPtr(formatRate(aliquota)),
+		VCOFINS: stringPtr(vCOFINS.String()),
+	}, vCOFINS
+}
+
+// buildIPIInput builds the IPI group of an NF-e item and returns the IPI value; nil for
+// items without IPI, which include every NFC-e item
+func buildIPIInput(ipi *entity.ItemIPI, vProd money.Amount) (*nfceInfra.IPIInput, money.Amount) {
+	if ipi == nil {
+		return nil, 0
+	}
+
+	input := &nfceInfra.IPIInput{CEnq: ipi.Enquadramento, CST: ipi.CST}
+	if !ipi.Taxed() {
+		return input, 0
+	}
+	vIPI := ipi.Value(vProd)
+	input.VBC = stringPtr(vProd.String())
+	input.PIPI = stringPtr(formatRate(ipi.Aliquota))
+	input.VIPI = stringPtr(vIPI.String())
+	return input, vIPI
+}
+
+// buildIIInput builds the import tax group of an NF-e item from the import declaration
+// values and returns the II value
+func buildIIInput(ii *entity.ItemII) (*nfceInfra.IIInput, money.Amount) {
+	if ii == nil {
+		return nil, 0
+	}
+	return &nfceInfra.IIInput{
+		VBC:      ii.BaseCalculo.String(),
+		VDespAdu: ii.DespesasAduaneiras.String(),
+		VII:      ii.Valor.String(),
+		VIOF:     ii.IOF.String(),
+	}, ii.Valor
+}
+
+// zeroMoney is the zero value of the monetary tags, formatted for the XML
+var zeroMoney = money.Amount(0).String()
+
+// formatRate formats a percentage with the four decimals allowed by the NFC-e layout
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', 4, 64)
+}
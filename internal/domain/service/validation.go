@@ -0,0 +1,19 @@
+package service
+
+// ValidationError is a rule of the domain broken by the request, e.g. payments that do not
+// cover the NFC-e total. Its text is written for the client, which gets it as the detail of
+// the error.
+type ValidationError struct {
+	Field string // Part of the request breaking the rule, e.g. "nfces[2]"; empty when unknown
+	Err   error
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the broken rule, which may wrap a domain sentinel
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -4,30 +4,100 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/danfe"
 	nfceInfra "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/qr"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/signer"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/soap/soapclient"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/validator"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
-	"github.com/jung-kurt/gofpdf"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/bufpool"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
 )
 
+// ErrCancellationRejected is returned when SEFAZ refuses the cancellation event
+var ErrCancellationRejected = errors.New("SEFAZ rejected cancellation event")
+
+// ErrIncompleteCompanyProfile is returned when the company registry lacks data required on the emitente
+var ErrIncompleteCompanyProfile = errors.New("incomplete company profile")
+
+// ErrInvalidIntermediador is returned when the payload intermediary cannot be resolved against the company registry
+var ErrInvalidIntermediador = errors.New("invalid intermediador")
+
+// AsyncLoteConfig controls asynchronous lote submission (indSinc=0) and the
+// NFeRetAutorizacao4 polling that fetches the final protocol
+type AsyncLoteConfig struct {
+	Enabled      bool
+	PollInterval time.Duration
+	MaxPolls     int
+	Pack         bool // Pack the NFC-e of an API lote into a single enviNFe per UF and environment
+}
+
+// OfflineContingencyConfig controls the offline contingency (tpEmis 9): NFC-e are
+// issued without SEFAZ when it is unreachable and transmitted once it is back
+type OfflineContingencyConfig struct {
+	Enabled          bool
+	TransmitInterval time.Duration
+}
+
+// RetrySchedulerConfig bounds the retry scheduler: the batch grows and the interval
+// shrinks with the backlog of due retries, and no batch is published while
+// MaxInFlight requests are already queued or being emitted
+type RetrySchedulerConfig struct {
+	MinBatch    int
+	MaxBatch    int
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	MaxInFlight int
+}
+
+// Plan sizes the next retry batch and the wait until the following run. The batch
+// follows the due backlog within MinBatch and MaxBatch, limited by the room left under
+// MaxInFlight; the interval shrinks in proportion to the backlog a batch cannot absorb.
+func (c RetrySchedulerConfig) Plan(backlog *entity.RetryBacklog) (int, time.Duration) {
+	capacity := c.MaxInFlight - backlog.InFlight
+	if capacity <= 0 {
+		return 0, c.MaxInterval
+	}
+
+	batch := min(max(backlog.Due, c.MinBatch), c.MaxBatch, capacity)
+	if backlog.Due <= batch {
+		return batch, c.MaxInterval
+	}
+
+	interval := time.Duration(float64(c.MaxInterval) * float64(batch) / float64(backlog.Due))
+	return batch, max(interval, c.MinInterval)
+}
+
 // NFCeWorkerService handles the complete NFC-e emission process
 type NFCeWorkerService struct {
-	xmlBuilder   nfceInfra.Builder
-	xmlSigner    signer.Signer
-	xmlValidator validator.XMLValidator
-	soapClient   soapclient.Client
-	qrGenerator  qr.Generator
-	storage      storage.StorageService
-	companyRepo  ports.CompanyRepository
+	xmlBuilder    nfceInfra.Builder
+	xmlSigner     signer.Signer
+	xmlValidator  validator.XMLValidator
+	soapClient    soapclient.Client
+	qrGenerator   qr.Generator
+	danfeRenderer danfe.Renderer
+	storage       storage.StorageService
+	companyRepo   ports.CompanyRepository
+	featureGate   *FeatureGate
+	asyncLote     AsyncLoteConfig
+	offline       OfflineContingencyConfig
+	monitor       *SEFAZMonitor
+	taxEngine     *TaxEngine
+	contingencies ports.ContingencyStateRepository
+	contingency   ContingencyConfig
+	archive       *SEFAZArchiveService
+	logger        logger.Logger
 }
 
 // NewNFCeWorkerService creates a new NFC-e worker service
@@ -37,131 +107,463 @@ func NewNFCeWorkerService(
 	xmlValidator validator.XMLValidator,
 	soapClient soapclient.Client,
 	qrGenerator qr.Generator,
+	danfeRenderer danfe.Renderer,
 	storage storage.StorageService,
 	companyRepo ports.CompanyRepository,
+	featureGate *FeatureGate,
+	asyncLote AsyncLoteConfig,
+	offline OfflineContingencyConfig,
+	monitor *SEFAZMonitor,
+	taxEngine *TaxEngine,
+	contingencies ports.ContingencyStateRepository,
+	contingency ContingencyConfig,
+	archive *SEFAZArchiveService,
+	l logger.Logger,
 ) *NFCeWorkerService {
 	return &NFCeWorkerService{
-		xmlBuilder:   xmlBuilder,
-		xmlSigner:    xmlSigner,
-		xmlValidator: xmlValidator,
-		soapClient:   soapClient,
-		qrGenerator:  qrGenerator,
-		storage:      storage,
-		companyRepo:  companyRepo,
+		xmlBuilder:    xmlBuilder,
+		xmlSigner:     xmlSigner,
+		xmlValidator:  xmlValidator,
+		soapClient:    soapClient,
+		qrGenerator:   qrGenerator,
+		danfeRenderer: danfeRenderer,
+		storage:       storage,
+		companyRepo:   companyRepo,
+		featureGate:   featureGate,
+		asyncLote:     asyncLote,
+		offline:       offline,
+		monitor:       monitor,
+		taxEngine:     taxEngine,
+		contingencies: contingencies,
+		contingency:   contingency,
+		archive:       archive,
+		logger:        l,
 	}
 }
 
-// ProcessNFceEmission handles the complete NFC-e emission workflow
+// log returns the logger of the NFC-e being processed, carried by ctx, or the service logger
+func (s *NFCeWorkerService) log(ctx context.Context) logger.Logger {
+	return logger.FromContext(ctx, s.logger)
+}
+
+// ProcessNFceEmission handles the complete NFC-e emission workflow. A request sent to SEFAZ
+// before is first looked up by the chave of that submission, so it is never authorized twice.
 func (s *NFCeWorkerService) ProcessNFceEmission(ctx context.Context, nfceRequest *entity.NFCE) error {
+	if resolved, err := s.resolvePreviousSubmission(ctx, nfceRequest); resolved || err != nil {
+		return err
+	}
 	return s.processNFceEmissionWithContingency(ctx, nfceRequest, false, "")
 }
 
-// ProcessNFceCancellation handles the complete NFC-e cancellation workflow
+// ProcessLoteEmission handles the emission of the NFC-e of a lote and returns the error of
+// each one, in order. With packing enabled the NFC-e of the same company, UF and environment
+// go to SEFAZ in a single asynchronous enviNFe; when SEFAZ does not receive the lote they are
+// sent one by one.
+func (s *NFCeWorkerService) ProcessLoteEmission(ctx context.Context, requests []*entity.NFCE) []error {
+	errs := make([]error, len(requests))
+	if !s.asyncLote.Enabled || !s.asyncLote.Pack {
+		for i, nfceRequest := range requests {
+			errs[i] = s.ProcessNFceEmission(ctx, nfceRequest)
+		}
+		return errs
+	}
+
+	var keys []string
+	groups := make(map[string][]*preparedEmission)
+	indexes := make(map[*entity.NFCE]int, len(requests))
+	for i, nfceRequest := range requests {
+		indexes[nfceRequest] = i
+		if resolved, err := s.resolvePreviousSubmission(ctx, nfceRequest); resolved || err != nil {
+			errs[i] = err
+			continue
+		}
+		prepared, err := s.prepareEmission(ctx, nfceRequest, false, "")
+		if err != nil || prepared == nil {
+			errs[i] = err
+			continue
+		}
+
+		key := nfceRequest.CompanyID + "|" + nfceRequest.Payload.UF + "|" + nfceRequest.Payload.Ambiente + "|" + nfceRequest.Payload.DocumentModel()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], prepared)
+	}
+
+	for _, key := range keys {
+		for _, result := range s.submitLote(ctx, groups[key]) {
+			errs[indexes[result.request]] = result.err
+		}
+	}
+	return errs
+}
+
+// loteResult is the outcome of an NFC-e submitted in a packed lote
+type loteResult struct {
+	request *entity.NFCE
+	err     error
+}
+
+// submitLote sends the prepared NFC-e in a single enviNFe, falling back to one submission
+// per NFC-e when SEFAZ does not receive the lote
+func (s *NFCeWorkerService) submitLote(ctx context.Context, lote []*preparedEmission) []loteResult {
+	results := make([]loteResult, len(lote))
+	submitEach := func() []loteResult {
+		for i, prepared := range lote {
+			results[i] = loteResult{request: prepared.request, err: s.submitEmission(ctx, prepared)}
+		}
+		return results
+	}
+	if len(lote) == 1 {
+		return submitEach()
+	}
+
+	first := lote[0].request
+	xmls := make([][]byte, len(lote))
+	for i, prepared := range lote {
+		xmls[i] = prepared.signedXML
+		if err := s.recordSubmission(ctx, prepared.request, prepared.signedXML); err != nil {
+			s.log(ctx).Warn("Failed to record the lote submission, sending the NFC-e one by one", logger.RequestID(prepared.request.ID), logger.Err(err))
+			return submitEach()
+		}
+	}
+
+	response, err := s.soapClient.AuthorizeLote(ctx, soapclient.LoteAuthorizationRequest{
+		UF:          first.Payload.UF,
+		Ambiente:    first.Payload.Ambiente,
+		Modelo:      first.Payload.DocumentModel(),
+		XMLs:        xmls,
+		Certificate: &soapclient.ClientCertificate{CompanyID: first.CompanyID, Key: lote[0].keyMaterial},
+	})
+	requests := make([]*entity.NFCE, len(lote))
+	for i, prepared := range lote {
+		requests[i] = prepared.request
+	}
+	s.archive.Record(ctx, entity.SEFAZServiceAutorizacaoLote, requests, response, err)
+	if err != nil || response.Status != "received" {
+		if err == nil {
+			err = fmt.Errorf("cStat=%s, motivo=%s", response.CStat, response.Motivo)
+		}
+		s.log(ctx).Warn("SEFAZ did not receive the lote, sending the NFC-e one by one", logger.Field{Key: "count", Value: len(lote)}, logger.Err(err))
+		return submitEach()
+	}
+	s.recordAvailability(first, false, true, response.CStat)
+
+	for i, prepared := range lote {
+		results[i] = loteResult{
+			request: prepared.request,
+			err:     s.handleReceived(ctx, prepared.request, prepared.chaveAcesso, prepared.signedXML, response),
+		}
+	}
+	return results
+}
+
+// ProcessNFceCancellation handles the complete NFC-e cancellation workflow (evento 110111)
 func (s *NFCeWorkerService) ProcessNFceCancellation(ctx context.Context, nfceRequest *entity.NFCE, justificativa string) error {
-	// Update status to canceled
-	nfceRequest.MarkAsCanceled(justificativa)
+	// Step 1: Build cancellation event
+	evento, err := nfceInfra.BuildCancelamento(nfceInfra.CancelamentoInput{
+		ChaveAcesso:   nfceRequest.ChaveAcesso,
+		CNPJ:          nfceRequest.Payload.Emitente.CNPJ,
+		Protocolo:     nfceRequest.Protocolo,
+		Justificativa: justificativa,
+		Ambiente:      nfceRequest.Payload.Ambiente,
+		DhEvento:      time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build cancellation event: %w", err)
+	}
+
+	eventoXML, err := xml.Marshal(evento)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cancellation event: %w", err)
+	}
+
+	// Step 2: Sign with company certificate
+	certificate, err := s.companyRepo.GetCertificateByCompanyID(ctx, nfceRequest.CompanyID)
+	if err != nil {
+		return fmt.Errorf("failed to get certificate for company %s: %w", nfceRequest.CompanyID, err)
+	}
 
-	// TODO: Implement actual SEFAZ cancellation logic
-	// This would involve:
-	// 1. Building cancellation XML
-	// 2. Signing with certificate
-	// 3. Sending to SEFAZ cancellation endpoint
-	// 4. Processing response
-	// 5. Updating status based on result
+	keyMaterial := keyMaterial(certificate)
 
-	// For now, just mark as canceled
-	// In production, this would call SEFAZ cancellation API
-	fmt.Printf("NFC-e cancellation requested for %s: %s\n", nfceRequest.ID, justificativa)
+	signedEvento, err := s.xmlSigner.SignEnveloped(ctx, eventoXML, keyMaterial, evento.InfEvento.Id)
+	if err != nil {
+		return fmt.Errorf("failed to sign cancellation event: %w", err)
+	}
 
-	return nil
+	// Step 3: Send to SEFAZ RecepcaoEvento
+	response, err := s.soapClient.SendEvent(ctx, soapclient.EventRequest{
+		UF:          nfceRequest.Payload.UF,
+		Ambiente:    nfceRequest.Payload.Ambiente,
+		ChaveAcesso: nfceRequest.ChaveAcesso,
+		XML:         signedEvento,
+		Certificate: &soapclient.ClientCertificate{CompanyID: nfceRequest.CompanyID, Key: keyMaterial},
+	})
+	if err != nil {
+		return fmt.Errorf("SEFAZ cancellation event failed: %w", err)
+	}
+
+	// Step 4: Process SEFAZ response
+	switch response.Status {
+	case "registered":
+		nfceRequest.MarkAsCanceled(justificativa, response.Protocolo)
+
+		// Store procEventoNFe
+		procEvento := nfceInfra.BuildProcEventoNFe(signedEvento, response.RetEvento)
+		cancelXMLURL, err := s.storeCancelXMLFile(ctx, procEvento, nfceRequest.ChaveAcesso, nfceRequest.CompanyID)
+		if err != nil {
+			// Log error but don't fail the process - the event is already registered at SEFAZ
+			s.log(ctx).Error("Failed to store cancellation XML file", logger.Err(err))
+		}
+		nfceRequest.CancelXMLURL = cancelXMLURL
+		return nil
+	case "denied":
+		nfceRequest.MarkCancellationRejected(response.CStat, response.Motivo)
+		return fmt.Errorf("%w: cStat=%s, motivo=%s", ErrCancellationRejected, response.CStat, response.Motivo)
+	default:
+		return fmt.Errorf("SEFAZ error (retryable): cStat=%s, motivo=%s", response.CStat, response.Motivo)
+	}
 }
 
 // processNFceEmissionWithContingency handles NFC-e emission with optional contingency
 func (s *NFCeWorkerService) processNFceEmissionWithContingency(ctx context.Context, nfceRequest *entity.NFCE, contingency bool, contingencyType string) error {
+	prepared, err := s.prepareEmission(ctx, nfceRequest, contingency, contingencyType)
+	if err != nil || prepared == nil {
+		return err
+	}
+	return s.submitEmission(ctx, prepared)
+}
+
+// preparedEmission is a signed NFC-e ready to be sent to SEFAZ
+type preparedEmission struct {
+	request         *entity.NFCE
+	chaveAcesso     string
+	signedXML       []byte
+	keyMaterial     signer.KeyMaterial
+	contingency     bool
+	contingencyType string
+}
+
+// prepareEmission builds, validates and signs the NFC-e. It returns nil when the NFC-e was
+// already handled without SEFAZ: issued in offline contingency or held by a known outage.
+func (s *NFCeWorkerService) prepareEmission(ctx context.Context, nfceRequest *entity.NFCE, contingency bool, contingencyType string) (*preparedEmission, error) {
 	// Update status to processing
 	nfceRequest.MarkAsProcessing()
 
 	// Step 1: Check idempotency - if already authorized, skip processing
 	if nfceRequest.Status == entity.RequestStatusAuthorized {
-		return nil
+		return nil, nil
+	}
+
+	// Load emitente data from the company registry
+	company, err := s.companyRepo.GetByID(ctx, nfceRequest.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company %s: %w", nfceRequest.CompanyID, err)
+	}
+	if missing := company.MissingEmitenteFields(); len(missing) > 0 {
+		// Retrying will not help until the company profile is fixed
+		nfceRequest.MarkAsRejected("999", fmt.Sprintf("Cadastro da empresa incompleto: %s", strings.Join(missing, ", ")))
+		return nil, fmt.Errorf("%w: company %s is missing %s", ErrIncompleteCompanyProfile, company.ID, strings.Join(missing, ", "))
+	}
+
+	intermediador, err := company.ResolveIntermediador(nfceRequest.Payload.Intermediador)
+	if err != nil {
+		nfceRequest.MarkAsRejected("999", err.Error())
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIntermediador, err)
+	}
+
+	// Company already in contingency in the UF: skip the regular authorizer until it is back
+	if !contingency {
+		if state := s.activeContingency(ctx, nfceRequest); state != nil {
+			err := s.emitInContingency(ctx, nfceRequest, state)
+			if !errors.Is(err, ErrFeatureNotInPlan) {
+				return nil, err
+			}
+			// Contingency left the plan: try the regular authorizer
+		}
+	}
+
+	// Known SEFAZ outage: go straight to contingency instead of waiting for the timeout
+	if s.monitor.Enabled() && !contingency {
+		s.monitor.Track(nfceRequest.Payload.UF, nfceRequest.Payload.Ambiente)
+		if availability, open := s.monitor.Open(nfceRequest.Payload.UF, nfceRequest.Payload.Ambiente); open {
+			return nil, s.emitWhileUnavailable(ctx, nfceRequest, availability)
+		}
 	}
 
 	// Step 2: Generate chave de acesso
-	nfceInput := s.convertToNFCeInput(nfceRequest.Payload, contingency, contingencyType)
+	nfceInput := s.convertToNFCeInput(nfceRequest.Payload, company, intermediador, contingency, contingencyType)
+	if contingency {
+		// dhCont and xJust are those of the contingency period the NFC-e is issued in
+		if state := s.activeContingency(ctx, nfceRequest); state != nil {
+			nfceInput.ContingencyAt = &state.EnteredAt
+			nfceInput.ContingencyJust = state.Reason
+		}
+	}
 	nfceData, err := s.xmlBuilder.BuildNFCe(nfceInput, nfceRequest.CompanyID)
 	if err != nil {
-		return fmt.Errorf("failed to build NFC-e XML: %w", err)
+		if errors.Is(err, entity.ErrNumberingSeriesNotFound) || errors.Is(err, entity.ErrNumberingSeriesInactive) ||
+			errors.Is(err, entity.ErrNumberingSeriesExhausted) {
+			// Retrying will not help until the series is registered, reactivated or replaced
+			nfceRequest.MarkAsRejected("999", fmt.Sprintf("Série de numeração indisponível: %v", err))
+		}
+		return nil, fmt.Errorf("failed to build NFC-e XML: %w", err)
+	}
+
+	// The number is spent from here on; keep it so unused ones show up as gaps
+	nfceRequest.Numero = nfceData.InfNFe.Ide.NNF
+	nfceRequest.Serie = nfceData.InfNFe.Ide.Serie
+	nfceRequest.VTotTrib = 0
+	if vTotTrib := nfceData.InfNFe.Total.ICMSTot.VTotTrib; vTotTrib != nil {
+		if amount, err := money.Parse(*vTotTrib); err == nil {
+			nfceRequest.VTotTrib = amount
+		}
+	}
+	nfceRequest.Totais = nil
+	if nfceRequest.Payload.IsNFe() {
+		nfceRequest.Totais = documentTotals(nfceData.InfNFe.Total.ICMSTot)
 	}
 
 	// The chave de acesso is generated inside BuildNFCe and set in the XML
 	// Extract it from the built XML
 	chaveAcesso, err := s.extractChaveAcesso(nfceData)
 	if err != nil {
-		return fmt.Errorf("failed to extract chave acesso: %w", err)
+		return nil, fmt.Errorf("failed to extract chave acesso: %w", err)
 	}
 
 	// Step 3: Convert to XML bytes for signing
 	xmlBytes, err := s.convertNFCeToXML(nfceData)
 	if err != nil {
-		return fmt.Errorf("failed to convert NFC-e to XML: %w", err)
+		return nil, fmt.Errorf("failed to convert NFC-e to XML: %w", err)
 	}
 
 	// Step 4: Validate XML against XSD schema before signing
 	if err := s.xmlValidator.ValidateNFCe(ctx, xmlBytes, "4.00"); err != nil {
-		return fmt.Errorf("XSD validation failed: %w", err)
+		rejectSchemaViolations(nfceRequest, err)
+		return nil, fmt.Errorf("XSD validation failed: %w", err)
 	}
 
 	// Step 5: Get certificate from company
 	certificate, err := s.companyRepo.GetCertificateByCompanyID(ctx, nfceRequest.CompanyID)
 	if err != nil {
-		return fmt.Errorf("failed to get certificate for company %s: %w", nfceRequest.CompanyID, err)
+		return nil, fmt.Errorf("failed to get certificate for company %s: %w", nfceRequest.CompanyID, err)
 	}
 
-	keyMaterial := signer.KeyMaterial{
-		PFXBase64: certificate.PFXBase64,
-		Password:  certificate.Password,
-	}
+	keyMaterial := keyMaterial(certificate)
 
 	// Find the ID of the infNFe element for signing
 	infNFeID, err := s.findInfNFeID(xmlBytes)
 	if err != nil {
-		return fmt.Errorf("failed to find infNFe ID: %w", err)
+		return nil, fmt.Errorf("failed to find infNFe ID: %w", err)
 	}
 
 	signedXML, err := s.xmlSigner.SignEnveloped(ctx, xmlBytes, keyMaterial, infNFeID)
 	if err != nil {
-		return fmt.Errorf("failed to sign XML: %w", err)
+		if errors.Is(err, signer.ErrPFXPassword) || errors.Is(err, signer.ErrPFXUnsupported) || errors.Is(err, signer.ErrPFXInvalid) {
+			// Retrying will not help until a new certificate is uploaded
+			nfceRequest.MarkAsRejected("999", fmt.Sprintf("Certificado digital inválido: %v", err))
+		}
+		return nil, fmt.Errorf("failed to sign XML: %w", err)
 	}
 
 	// Step 6: Validate signed XML against XSD schema
 	if err := s.xmlValidator.ValidateNFCe(ctx, signedXML, "4.00"); err != nil {
-		return fmt.Errorf("signed XML validation failed: %w", err)
+		rejectSchemaViolations(nfceRequest, err)
+		return nil, fmt.Errorf("signed XML validation failed: %w", err)
+	}
+
+	// Offline contingency: the signed NFC-e is valid for the sale and transmitted later
+	if contingencyType == entity.ContingencyTypeOffline {
+		return nil, s.handleIssuedOffline(ctx, nfceRequest, chaveAcesso, signedXML)
+	}
+
+	return &preparedEmission{
+		request:         nfceRequest,
+		chaveAcesso:     chaveAcesso,
+		signedXML:       signedXML,
+		keyMaterial:     keyMaterial,
+		contingency:     contingency,
+		contingencyType: contingencyType,
+	}, nil
+}
+
+// rejectSchemaViolations rejects the NFC-e whose XML does not comply with the schema, since
+// retrying the same payload fails again; other validation errors, e.g. missing schemas, are
+// left to the retry
+func rejectSchemaViolations(nfceRequest *entity.NFCE, err error) {
+	var schemaErr *validator.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return
 	}
 
+	violations := make(entity.SchemaViolations, len(schemaErr.Violations))
+	for i, violation := range schemaErr.Violations {
+		violations[i] = entity.SchemaViolation(violation)
+	}
+	nfceRequest.MarkAsSchemaRejected(schemaErr.Summary(), violations)
+}
+
+// submitEmission sends a prepared NFC-e to SEFAZ and applies the answer
+func (s *NFCeWorkerService) submitEmission(ctx context.Context, prepared *preparedEmission) error {
+	nfceRequest, chaveAcesso, signedXML := prepared.request, prepared.chaveAcesso, prepared.signedXML
+	contingency, contingencyType := prepared.contingency, prepared.contingencyType
+
 	// Step 7: Send to SEFAZ
 	authReq := soapclient.AuthorizationRequest{
 		UF:              nfceRequest.Payload.UF,
 		Ambiente:        nfceRequest.Payload.Ambiente,
+		Modelo:          nfceRequest.Payload.DocumentModel(),
 		XML:             signedXML,
 		Contingency:     contingency,
 		ContingencyType: contingencyType,
+		Async:           s.asyncLote.Enabled,
+		Certificate:     &soapclient.ClientCertificate{CompanyID: nfceRequest.CompanyID, Key: prepared.keyMaterial},
+	}
+
+	if err := s.recordSubmission(ctx, nfceRequest, signedXML); err != nil {
+		return err
 	}
 
 	response, err := s.soapClient.Authorize(ctx, authReq)
+	s.archive.Record(ctx, entity.SEFAZServiceAutorizacao, []*entity.NFCE{nfceRequest}, response, err)
 	if err != nil {
+		// The request may have reached SEFAZ before the failure (e.g. timeout),
+		// so confirm the real situation before scheduling a retry
+		if confirmed, ok := s.confirmAuthorization(ctx, nfceRequest, chaveAcesso); ok {
+			return s.handleAuthorized(ctx, nfceRequest, chaveAcesso, signedXML, confirmed)
+		}
+		s.recordAvailability(nfceRequest, contingency, false, "")
+		// SEFAZ unreachable - issue offline instead of holding the sale
+		if s.offlineFor(nfceRequest) && !contingency {
+			return s.tryOfflineContingency(ctx, nfceRequest, "unreachable")
+		}
 		return fmt.Errorf("SEFAZ authorization failed: %w", err)
 	}
 
+	s.recordAvailability(nfceRequest, contingency, !s.shouldUseContingency(response.CStat), response.CStat)
+
+	// Duplicidade means a previous submission was authorized
+	if response.CStat == "204" || response.CStat == "539" {
+		if resolved, err := s.resolveDuplicate(ctx, nfceRequest, chaveAcesso, signedXML, response); resolved {
+			return err
+		}
+	}
+
 	// Step 8: Process SEFAZ response
 	switch response.Status {
 	case "authorized":
 		return s.handleAuthorized(ctx, nfceRequest, chaveAcesso, signedXML, response)
 	case "denied":
 		return s.handleRejected(ctx, nfceRequest, response)
+	case "received":
+		return s.handleReceived(ctx, nfceRequest, chaveAcesso, signedXML, response)
 	default:
 		// Check if we should try contingency for service unavailable errors
 		if s.shouldUseContingency(response.CStat) && !contingency {
-			return s.tryContingency(ctx, nfceRequest)
+			if s.offlineFor(nfceRequest) {
+				return s.tryOfflineContingency(ctx, nfceRequest, response.CStat)
+			}
+			return s.tryContingency(ctx, nfceRequest, response)
 		}
 
 		// Check if it's a retryable error
@@ -170,10 +572,108 @@ func (s *NFCeWorkerService) processNFceEmissionWithContingency(ctx context.Conte
 		}
 		// Non-retryable error
 		nfceRequest.MarkAsRejected(response.CStat, response.Motivo)
+		s.clearSubmission(ctx, nfceRequest)
 		return fmt.Errorf("SEFAZ error (non-retryable): cStat=%s, motivo=%s", response.CStat, response.Motivo)
 	}
 }
 
+// PollReceipt queries NFeRetAutorizacao4 for the result of an asynchronous lote.
+// It reports whether the NFC-e reached a final status (authorized or rejected).
+func (s *NFCeWorkerService) PollReceipt(ctx context.Context, nfceRequest *entity.NFCE) (bool, error) {
+	clientCert, err := s.clientCertificate(ctx, nfceRequest.CompanyID)
+	if err != nil {
+		return false, err
+	}
+
+	response, err := s.soapClient.QueryReceipt(ctx, soapclient.ReceiptQueryRequest{
+		UF:              nfceRequest.Payload.UF,
+		Ambiente:        nfceRequest.Payload.Ambiente,
+		Modelo:          nfceRequest.Payload.DocumentModel(),
+		Recibo:          nfceRequest.Recibo,
+		ChaveAcesso:     nfceRequest.ChaveAcesso,
+		Contingency:     nfceRequest.InContingency,
+		ContingencyType: nfceRequest.ContingencyType,
+		Certificate:     clientCert,
+	})
+	if err != nil {
+		return false, fmt.Errorf("SEFAZ receipt query failed: %w", err)
+	}
+
+	switch response.Status {
+	case "processing":
+		return false, nil
+	case "authorized":
+		// The signed XML was stored when the lote was received
+		key := fmt.Sprintf("nfce/%s/xml/%s.xml", nfceRequest.CompanyID, nfceRequest.ChaveAcesso)
+		signedXML, err := s.storage.DownloadFile(ctx, "", key)
+		if err != nil {
+			return false, fmt.Errorf("failed to load signed XML: %w", err)
+		}
+		return true, s.handleAuthorized(ctx, nfceRequest, nfceRequest.ChaveAcesso, signedXML, response)
+	case "denied":
+		return true, s.handleRejected(ctx, nfceRequest, response)
+	default:
+		return false, fmt.Errorf("SEFAZ receipt error: cStat=%s, motivo=%s", response.CStat, response.Motivo)
+	}
+}
+
+// confirmAuthorization queries NfeConsultaProtocolo4 to check whether the NFC-e was authorized
+func (s *NFCeWorkerService) confirmAuthorization(ctx context.Context, nfceRequest *entity.NFCE, chaveAcesso string) (soapclient.AuthorizationResponse, bool) {
+	response, err := s.queryProtocol(ctx, nfceRequest, chaveAcesso)
+	if err != nil {
+		s.log(ctx).Warn("Failed to query NFC-e protocol", logger.Chave(chaveAcesso), logger.Err(err))
+		return soapclient.AuthorizationResponse{}, false
+	}
+
+	return response, response.Status == "authorized"
+}
+
+// queryProtocol queries NfeConsultaProtocolo4 for the situation of the NFC-e
+func (s *NFCeWorkerService) queryProtocol(ctx context.Context, nfceRequest *entity.NFCE, chaveAcesso string) (soapclient.AuthorizationResponse, error) {
+	clientCert, err := s.clientCertificate(ctx, nfceRequest.CompanyID)
+	if err != nil {
+		return soapclient.AuthorizationResponse{}, err
+	}
+
+	return s.soapClient.QueryProtocol(ctx, soapclient.ProtocolQueryRequest{
+		UF:          nfceRequest.Payload.UF,
+		Ambiente:    nfceRequest.Payload.Ambiente,
+		ChaveAcesso: chaveAcesso,
+		Certificate: clientCert,
+	})
+}
+
+// clientCertificate loads the company A1 certificate presented to SEFAZ on mutual TLS calls
+func (s *NFCeWorkerService) clientCertificate(ctx context.Context, companyID string) (*soapclient.ClientCertificate, error) {
+	certificate, err := s.companyRepo.GetCertificateByCompanyID(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate for company %s: %w", companyID, err)
+	}
+
+	return &soapclient.ClientCertificate{
+		CompanyID: companyID,
+		Key:       keyMaterial(certificate),
+	}, nil
+}
+
+// keyMaterial selects the signing key of the company: its PFX or its remote KMS key
+func keyMaterial(certificate *entity.Certificate) signer.KeyMaterial {
+	if kms := certificate.KMS; kms != nil {
+		return signer.KeyMaterial{
+			KMS: &signer.KMSKey{
+				Endpoint:       kms.Endpoint,
+				KeyID:          kms.KeyID,
+				Token:          kms.Token,
+				CertificatePEM: kms.CertificatePEM,
+			},
+		}
+	}
+	return signer.KeyMaterial{
+		PFXBase64: certificate.PFXBase64,
+		Password:  certificate.Password,
+	}
+}
+
 // extractChaveAcesso extracts the access key from the NFC-e XML
 func (s *NFCeWorkerService) extractChaveAcesso(nfceData *nfceInfra.NFCe) (string, error) {
 	// The chave acesso is in the Id field of infNFe, format: "NFe{CHAVE}"
@@ -191,16 +691,20 @@ func (s *NFCeWorkerService) extractChaveAcesso(nfceData *nfceInfra.NFCe) (string
 
 // convertNFCeToXML converts NFC-e struct to XML bytes
 func (s *NFCeWorkerService) convertNFCeToXML(nfceData *nfceInfra.NFCe) ([]byte, error) {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	// Add XML declaration
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+
 	// Marshal to XML
-	xmlBytes, err := xml.MarshalIndent(nfceData, "", "  ")
-	if err != nil {
+	encoder := xml.NewEncoder(buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(nfceData); err != nil {
 		return nil, err
 	}
 
-	// Add XML declaration
-	xmlWithDeclaration := []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + string(xmlBytes))
-
-	return xmlWithDeclaration, nil
+	return bufpool.Bytes(buf), nil
 }
 
 // findInfNFeID finds the ID attribute of the infNFe element
@@ -234,25 +738,34 @@ func findInString(s, substr string) int {
 }
 
 // convertToNFCeInput converts entity payload to NFC-e builder input
-func (s *NFCeWorkerService) convertToNFCeInput(payload entity.EmitPayload, contingency bool, contingencyType string) nfceInfra.NFCeInput {
+func (s *NFCeWorkerService) convertToNFCeInput(payload entity.EmitPayload, company *entity.Company, intermediador *entity.Intermediador, contingency bool, contingencyType string) nfceInfra.NFCeInput {
 	// Convert entity types to infrastructure types
 	itens := make([]nfceInfra.ItemInput, len(payload.Itens))
+	fretes := payload.FreightShares()
 	for i, item := range payload.Itens {
+		vProd := item.Gross()
+		// Taxes are levied on the value of the operation: discounts reduce it, freight and charges add to it
+		base := vProd - item.Desconto + fretes[i] + item.Outros
 		itens[i] = nfceInfra.ItemInput{
 			CProd:    item.GTIN, // Using GTIN as product code
 			CEAN:     &item.GTIN,
 			XProd:    item.Descricao,
 			NCM:      item.NCM,
+			CEST:     optionalString(item.CEST),
 			CFOP:     item.CFOP,
 			UCom:     item.Unidade,
-			QCom:     fmt.Sprintf("%.4f", item.Quantidade),
-			VUnCom:   fmt.Sprintf("%.10f", item.Valor),
-			VProd:    fmt.Sprintf("%.2f", item.Quantidade*item.Valor),
+			QCom:     item.Quantidade.String(),
+			VUnCom:   item.Valor.String(),
+			VProd:    vProd.String(),
 			CEANTrib: &item.GTIN,
 			UTrib:    item.Unidade,
-			QTrib:    fmt.Sprintf("%.4f", item.Quantidade),
-			VUnTrib:  fmt.Sprintf("%.10f", item.Valor),
+			QTrib:    item.Quantidade.String(),
+			VUnTrib:  item.Valor.String(),
+			VFrete:   optionalMoney(fretes[i]),
+			VDesc:    optionalMoney(item.Desconto),
+			VOutro:   optionalMoney(item.Outros),
 			IndTot:   "1", // Always totalize
+			Imposto:  s.taxEngine.Compute(company, payload.UF, item, base),
 		}
 	}
 
@@ -260,77 +773,253 @@ func (s *NFCeWorkerService) convertToNFCeInput(payload entity.EmitPayload, conti
 	for i, pag := range payload.Pagamentos {
 		pagamentos[i] = nfceInfra.PagamentoInput{
 			TPag: pag.Forma,
-			VPag: fmt.Sprintf("%.2f", pag.Valor),
+			VPag: pag.Valor.String(),
+			Card: cardInput(pag),
 		}
 	}
 
+	// Sales with freight are delivered by the seller (modFrete 0); counter sales have no transport
+	transp := nfceInfra.TranspInput{ModFrete: "9"}
+	if payload.Frete > 0 {
+		transp.ModFrete = "0"
+	}
+	if payload.Transporte != nil {
+		transp = transpInput(payload.Transporte)
+	}
+
+	var destinatario *nfceInfra.DestinatarioInput
+	if dest := payload.Destinatario; dest != nil {
+		destinatario = &nfceInfra.DestinatarioInput{
+			CNPJ:      optionalString(onlyDigits(dest.CNPJ)),
+			CPF:       optionalString(onlyDigits(dest.CPF)),
+			XNome:     optionalString(dest.Nome),
+			IndIEDest: "9", // Consumers of the NFC-e are never ICMS taxpayers
+			Email:     optionalString(dest.Email),
+		}
+		if payload.IsNFe() {
+			destinatario.IndIEDest, destinatario.IE = indIEDest(dest.IE)
+			destinatario.EnderDest = enderDestInput(dest.Endereco)
+		}
+	}
+
+	var infIntermed *nfceInfra.InfIntermedInput
+	if intermediador != nil {
+		infIntermed = &nfceInfra.InfIntermedInput{
+			CNPJ:         onlyDigits(intermediador.CNPJ),
+			XNome:        intermediador.Nome,
+			IdCadIntTran: optionalString(intermediador.IdCadIntTran),
+		}
+	}
+
+	// Without a company technical responsible the builder injects the installation one
+	var infRespTec *nfceInfra.InfRespTecInput
+	if respTec := company.RespTec; respTec != nil {
+		infRespTec = &nfceInfra.InfRespTecInput{
+			CNPJ:     onlyDigits(respTec.CNPJ),
+			XContato: respTec.Contato,
+			Email:    respTec.Email,
+			Fone:     onlyDigits(respTec.Fone),
+			IdCSRT:   respTec.IdCSRT,
+			CSRT:     respTec.CSRT,
+		}
+	}
+
+	// The company emission settings fill the ide fields the payload leaves empty
+	settings := company.EmissionSettings
+	natOp, serie, verProc := payload.NaturezaOperacao, payload.Serie, ""
+	if settings != nil {
+		if natOp == "" {
+			natOp = settings.NaturezaOperacao
+		}
+		if serie == nil {
+			serie = settings.Serie
+		}
+		verProc = settings.VerProc
+	}
+
 	return nfceInfra.NFCeInput{
 		UF:              payload.UF,
 		Ambiente:        payload.Ambiente,
+		Modelo:          payload.DocumentModel(),
+		NatOp:           natOp,
+		Serie:           serie,
+		TpImp:           settings.TpImpFor(payload.DocumentModel()),
+		VerProc:         verProc,
 		Contingency:     contingency,
 		ContingencyType: contingencyType,
 		Emitente: nfceInfra.EmitenteInput{
-			CNPJ:  payload.Emitente.CNPJ,
-			XNome: "EMPRESA EXEMPLO", // Should come from payload
-			XFant: stringPtr("EXEMPLO"),
+			CNPJ:  onlyDigits(company.CNPJ),
+			XNome: company.RazaoSocial,
+			XFant: optionalString(company.NomeFantasia),
 			EnderEmit: nfceInfra.EnderEmitInput{
-				XLgr:    "RUA EXEMPLO",
-				Nro:     "123",
-				XBairro: "CENTRO",
-				CMun:    "3550308", // São Paulo
-				XMun:    "SAO PAULO",
-				UF:      payload.UF,
-				CEP:     "01234567",
+				XLgr:    company.Endereco.Logradouro,
+				Nro:     company.Endereco.Numero,
+				XCpl:    optionalString(company.Endereco.Complemento),
+				XBairro: company.Endereco.Bairro,
+				CMun:    company.Endereco.CodigoMunicipio,
+				XMun:    company.Endereco.Municipio,
+				UF:      company.Endereco.UF,
+				CEP:     onlyDigits(company.Endereco.CEP),
 				CPais:   stringPtr("1058"),
 				XPais:   stringPtr("BRASIL"),
-				Fone:    stringPtr("11999999999"),
 			},
-			IE:  payload.Emitente.IE,
-			CRT: payload.Emitente.Regime, // Simples Nacional
-		},
-		Itens:      itens,
-		Pagamentos: pagamentos,
-		Transp: nfceInfra.TranspInput{
-			ModFrete: "9", // Sem frete
+			IE:  onlyDigits(company.InscricaoEstadual),
+			CRT: company.CRT(),
 		},
+		Destinatario: destinatario,
+		Itens:        itens,
+		Pagamentos:   pagamentos,
+		VTroco:       optionalMoney(payload.Change()),
+		Transp:       transp,
+		Cobr:         cobrInput(payload.Cobranca),
+		InfIntermed:  infIntermed,
+		InfRespTec:   infRespTec,
 	}
 }
 
+// indIEDest returns the indIEDest and IE of an NF-e recipient: 1 for ICMS taxpayers with
+// their IE, 2 for taxpayers exempt from the registration and 9 for non taxpayers
+func indIEDest(ie string) (string, *string) {
+	switch {
+	case ie == entity.IsentoIE:
+		return "2", nil
+	case onlyDigits(ie) != "":
+		return "1", stringPtr(onlyDigits(ie))
+	default:
+		return "9", nil
+	}
+}
+
+// enderDestInput converts the address of an NF-e recipient; addresses abroad take the
+// municipality code and name of the exterior
+func enderDestInput(address *entity.Address) *nfceInfra.EnderDestInput {
+	if address == nil {
+		return nil
+	}
+
+	input := &nfceInfra.EnderDestInput{
+		XLgr:    address.Logradouro,
+		Nro:     address.Numero,
+		XCpl:    optionalString(address.Complemento),
+		XBairro: address.Bairro,
+		CMun:    address.CodigoMunicipio,
+		XMun:    address.Municipio,
+		UF:      address.UF,
+		CEP:     onlyDigits(address.CEP),
+		CPais:   stringPtr("1058"),
+		XPais:   stringPtr("BRASIL"),
+	}
+	if address.UF == entity.ExteriorUF {
+		input.CMun, input.XMun, input.CEP = entity.ExteriorCMun, "EXTERIOR", ""
+		input.CPais, input.XPais = nil, nil
+	}
+	return input
+}
+
+// transpInput converts the transport of an NF-e
+func transpInput(transporte *entity.Transporte) nfceInfra.TranspInput {
+	input := nfceInfra.TranspInput{ModFrete: transporte.Modalidade}
+	if carrier := transporte.Transportadora; carrier != nil {
+		input.Transporta = &nfceInfra.TransportaInput{
+			CNPJ:   optionalString(onlyDigits(carrier.CNPJ)),
+			CPF:    optionalString(onlyDigits(carrier.CPF)),
+			XNome:  optionalString(carrier.Nome),
+			IE:     optionalString(carrier.IE),
+			XEnder: optionalString(carrier.Endereco),
+			XMun:   optionalString(carrier.Municipio),
+			UF:     optionalString(carrier.UF),
+		}
+	}
+
+	for _, volume := range transporte.Volumes {
+		vol := nfceInfra.VolInput{
+			Esp:   optionalString(volume.Especie),
+			Marca: optionalString(volume.Marca),
+			NVol:  optionalString(volume.Numeracao),
+			PesoL: optionalWeight(volume.PesoLiquido),
+			PesoB: optionalWeight(volume.PesoBruto),
+		}
+		if volume.Quantidade > 0 {
+			vol.QVol = stringPtr(strconv.FormatInt(volume.Quantidade, 10))
+		}
+		input.Vol = append(input.Vol, vol)
+	}
+	return input
+}
+
+// optionalWeight formats a weight with the three decimals of the vol group; nil for zero
+func optionalWeight(weight money.Quantity) *string {
+	if weight == 0 {
+		return nil
+	}
+	return stringPtr(strconv.FormatFloat(weight.Float64(), 'f', 3, 64))
+}
+
+// cobrInput converts the billing of an NF-e; nil without billing
+func cobrInput(cobranca *entity.Cobranca) *nfceInfra.CobrInput {
+	if cobranca == nil {
+		return nil
+	}
+
+	input := &nfceInfra.CobrInput{}
+	if fatura := cobranca.Fatura; fatura != nil {
+		input.Fat = &nfceInfra.FatInput{
+			NFat:  fatura.Numero,
+			VOrig: fatura.ValorOriginal.String(),
+			VDesc: optionalMoney(fatura.Desconto),
+			VLiq:  fatura.ValorLiquido.String(),
+		}
+	}
+	for i, duplicata := range cobranca.Duplicatas {
+		input.Dup = append(input.Dup, nfceInfra.DupInput{
+			NDup:  duplicata.NumeroOf(i),
+			DVenc: duplicata.Vencimento,
+			VDup:  duplicata.Valor.String(),
+		})
+	}
+	return input
+}
+
 // handleAuthorized processes successful SEFAZ authorization
 func (s *NFCeWorkerService) handleAuthorized(ctx context.Context, nfceRequest *entity.NFCE, chaveAcesso string, signedXML []byte, response soapclient.AuthorizationResponse) error {
 	// Extract protocol and other data from response
 	protocolo := response.Protocolo
-	numero := "1" // Should be extracted from response or generated
-	serie := "1"  // Should be extracted from response or configured
-
-	// Mark as authorized
-	nfceRequest.MarkAsAuthorized(chaveAcesso, protocolo, numero, serie)
 
-	// Generate QR Code
-	qrParams := qr.Params{
-		ChaveAcesso: chaveAcesso,
-		TpAmb:       nfceRequest.Payload.Ambiente,
-		DhEmi:       time.Now().Format("2006-01-02T15:04:05-07:00"),
-		VNF:         "100.00",       // Should calculate from items
-		VICMS:       "0.00",         // Should calculate from taxes
-		DigVal:      "dummy_digest", // Should extract from signed XML
-		CSCID:       nfceRequest.Payload.Emitente.CSCID,
-		CSCToken:    nfceRequest.Payload.Emitente.CSCToken,
-		UF:          nfceRequest.Payload.UF,
-		Contingency: nfceRequest.InContingency,
-	}
+	// Mark as authorized with the number allocated when the XML was built
+	nfceRequest.MarkAsAuthorized(chaveAcesso, protocolo, nfceRequest.Numero, nfceRequest.Serie)
+
+	// Generate QR Code; the NF-e has none, its DANFE carries the barcode of the chave
+	var qrURL string
+	if !nfceRequest.Payload.IsNFe() {
+		cscID, cscToken := s.qrCSC(ctx, nfceRequest)
+		qrParams := qr.Params{
+			ChaveAcesso: chaveAcesso,
+			TpAmb:       nfceRequest.Payload.Ambiente,
+			DhEmi:       time.Now().Format("2006-01-02T15:04:05-07:00"),
+			VNF:         nfceRequest.Payload.Total().String(),
+			VICMS:       "0.00",         // Should calculate from taxes
+			DigVal:      "dummy_digest", // Should extract from signed XML
+			CSCID:       cscID,
+			CSCToken:    cscToken,
+			UF:          nfceRequest.Payload.UF,
+			Contingency: nfceRequest.InContingency,
+			TpEmis:      qrTpEmis(nfceRequest.ContingencyType),
+		}
 
-	qrURL, err := s.qrGenerator.BuildURL(ctx, qrParams)
-	if err != nil {
-		// Log error but don't fail the process
-		fmt.Printf("Failed to generate QR code: %v\n", err)
+		var err error
+		qrURL, err = s.qrGenerator.BuildURL(ctx, qrParams)
+		if err != nil {
+			// Log error but don't fail the process
+			s.log(ctx).Error("Failed to generate QR code", logger.Err(err))
+		}
 	}
+	nfceRequest.QRCode = qrURL
 
 	// Store XML file
 	xmlURL, err := s.storeXMLFile(ctx, signedXML, chaveAcesso, nfceRequest.CompanyID)
 	if err != nil {
 		// Log error but don't fail the process - use fallback URL
-		fmt.Printf("Failed to store XML file: %v\n", err)
+		s.log(ctx).Error("Failed to store XML file", logger.Err(err))
 		xmlURL = fmt.Sprintf("http://localhost:9000/plugnfce/nfce/%s/xml/%s.xml", nfceRequest.CompanyID, chaveAcesso)
 	}
 
@@ -338,26 +1027,209 @@ func (s *NFCeWorkerService) handleAuthorized(ctx context.Context, nfceRequest *e
 	pdfURL, err := s.generateAndStorePDFFile(ctx, nfceRequest, chaveAcesso)
 	if err != nil {
 		// Log error but don't fail the process - use fallback URL
-		fmt.Printf("Failed to generate/store PDF file: %v\n", err)
+		s.log(ctx).Error("Failed to generate/store PDF file", logger.Err(err))
 		pdfURL = fmt.Sprintf("http://localhost:9000/plugnfce/nfce/%s/pdf/%s.pdf", nfceRequest.CompanyID, chaveAcesso)
 	}
 
 	// Store QR Code as image
-	qrCodeURL, err := s.storeQRCodeImage(ctx, qrURL, chaveAcesso, nfceRequest.CompanyID, nfceRequest.InContingency)
-	if err != nil {
-		// Log error but don't fail the process - use fallback URL
-		fmt.Printf("Failed to store QR code image: %v\n", err)
-		qrCodeURL = fmt.Sprintf("http://localhost:9000/plugnfce/nfce/%s/qr/%s.png", nfceRequest.CompanyID, chaveAcesso)
+	var qrCodeURL string
+	if !nfceRequest.Payload.IsNFe() {
+		qrCodeURL, err = s.storeQRCodeImage(ctx, qrURL, chaveAcesso, nfceRequest.CompanyID)
+		if err != nil {
+			// Log error but don't fail the process - use fallback URL
+			s.log(ctx).Error("Failed to store QR code image", logger.Err(err))
+			qrCodeURL = fmt.Sprintf("http://localhost:9000/plugnfce/nfce/%s/qr/%s.png", nfceRequest.CompanyID, chaveAcesso)
+		}
 	}
 
 	nfceRequest.SetStorageURLs(xmlURL, pdfURL, qrCodeURL)
+	s.clearSubmission(ctx, nfceRequest)
+
+	return nil
+}
+
+// qrCSC selects the CSC signing the QR Code: the one given on the payload, else the company
+// CSC in force for the environment. Empty values make the QR Code generation fail.
+func (s *NFCeWorkerService) qrCSC(ctx context.Context, nfceRequest *entity.NFCE) (string, string) {
+	emitente := nfceRequest.Payload.Emitente
+	if emitente.CSCID != "" && emitente.CSCToken != "" {
+		return emitente.CSCID, emitente.CSCToken
+	}
+
+	// The simulated ambiente is issued as homologação and needs no CSC of its own
+	ambiente := nfceRequest.Payload.Ambiente
+	if ambiente == "simulado" {
+		ambiente = "homologacao"
+	}
+
+	company, err := s.companyRepo.GetByID(ctx, nfceRequest.CompanyID)
+	if err != nil {
+		s.log(ctx).Error("Failed to load company CSC", logger.Err(err))
+		return "", ""
+	}
+	cscID, cscToken, ok := company.ResolveCSC(ambiente, time.Now())
+	if !ok {
+		if nfceRequest.Payload.Ambiente == "simulado" {
+			return simulatedCSCID, simulatedCSCToken
+		}
+		s.log(ctx).Warn("Company has no CSC in force", logger.Field{Key: "ambiente", Value: ambiente})
+	}
+	return cscID, cscToken
+}
+
+// CSC signing the QR Code of the simulated NFC-e of companies without a homologação CSC
+const (
+	simulatedCSCID    = "000001"
+	simulatedCSCToken = "00000000000000000000000000000000SIMU"
+)
+
+// handleReceived keeps the signed XML and the receipt of an asynchronous lote
+// until the protocol is fetched by PollReceipt
+func (s *NFCeWorkerService) handleReceived(ctx context.Context, nfceRequest *entity.NFCE, chaveAcesso string, signedXML []byte, response soapclient.AuthorizationResponse) error {
+	if response.Recibo == "" {
+		return fmt.Errorf("SEFAZ received the lote without a receipt: cStat=%s, motivo=%s", response.CStat, response.Motivo)
+	}
+
+	if _, err := s.storeXMLFile(ctx, signedXML, chaveAcesso, nfceRequest.CompanyID); err != nil {
+		return fmt.Errorf("failed to store signed XML: %w", err)
+	}
+
+	nfceRequest.MarkAsAwaitingReceipt(chaveAcesso, response.Recibo, time.Now().Add(s.asyncLote.PollInterval))
+	return nil
+}
+
+// handleIssuedOffline stores the signed NFC-e issued in offline contingency and
+// its DANFE, leaving it pending transmission to SEFAZ
+func (s *NFCeWorkerService) handleIssuedOffline(ctx context.Context, nfceRequest *entity.NFCE, chaveAcesso string, signedXML []byte) error {
+	// The stored XML is the one transmitted later, so it must not be lost
+	xmlURL, err := s.storeXMLFile(ctx, signedXML, chaveAcesso, nfceRequest.CompanyID)
+	if err != nil {
+		return fmt.Errorf("failed to store offline NFC-e XML: %w", err)
+	}
+
+	nfceRequest.MarkAsPendingTransmission(chaveAcesso)
+
+	cscID, cscToken := s.qrCSC(ctx, nfceRequest)
+	qrURL, err := s.qrGenerator.BuildURL(ctx, qr.Params{
+		ChaveAcesso: chaveAcesso,
+		TpAmb:       nfceRequest.Payload.Ambiente,
+		DhEmi:       time.Now().Format("2006-01-02T15:04:05-07:00"),
+		VNF:         nfceRequest.Payload.Total().String(),
+		VICMS:       "0.00",         // Should calculate from taxes
+		DigVal:      "dummy_digest", // Should extract from signed XML
+		CSCID:       cscID,
+		CSCToken:    cscToken,
+		UF:          nfceRequest.Payload.UF,
+		Contingency: true,
+		TpEmis:      qr.TpEmisOffline,
+	})
+	if err != nil {
+		// Log error but don't fail the process
+		s.log(ctx).Error("Failed to generate offline QR code", logger.Err(err))
+	}
+	nfceRequest.QRCode = qrURL
+
+	// The DANFE carries the contingency notice until the NFC-e is authorized
+	pdfURL, err := s.generateAndStorePDFFile(ctx, nfceRequest, chaveAcesso)
+	if err != nil {
+		s.log(ctx).Error("Failed to generate/store offline PDF file", logger.Err(err))
+	}
+
+	qrCodeURL, err := s.storeQRCodeImage(ctx, qrURL, chaveAcesso, nfceRequest.CompanyID)
+	if err != nil {
+		s.log(ctx).Error("Failed to store offline QR code image", logger.Err(err))
+	}
 
+	nfceRequest.SetStorageURLs(xmlURL, pdfURL, qrCodeURL)
 	return nil
 }
 
+// IsSEFAZAvailable queries NFeStatusServico4 and reports whether the authorizer of the UF is in operation
+func (s *NFCeWorkerService) IsSEFAZAvailable(ctx context.Context, uf, ambiente string) bool {
+	response, err := s.soapClient.QueryStatus(ctx, uf, ambiente)
+	if err != nil {
+		return false
+	}
+	return response.CStat == "107" // Serviço em operação
+}
+
+// TransmitOffline sends a NFC-e issued in offline contingency to SEFAZ. It reports
+// whether the NFC-e reached a final status (authorized or rejected); otherwise it
+// stays pending transmission.
+func (s *NFCeWorkerService) TransmitOffline(ctx context.Context, nfceRequest *entity.NFCE) (bool, error) {
+	clientCert, err := s.clientCertificate(ctx, nfceRequest.CompanyID)
+	if err != nil {
+		return false, err
+	}
+
+	key := fmt.Sprintf("nfce/%s/xml/%s.xml", nfceRequest.CompanyID, nfceRequest.ChaveAcesso)
+	signedXML, err := s.storage.DownloadFile(ctx, "", key)
+	if err != nil {
+		return false, fmt.Errorf("failed to load offline NFC-e XML: %w", err)
+	}
+
+	// Offline NFC-e are transmitted to the regular authorizer, synchronously
+	response, err := s.soapClient.Authorize(ctx, soapclient.AuthorizationRequest{
+		UF:          nfceRequest.Payload.UF,
+		Ambiente:    nfceRequest.Payload.Ambiente,
+		Modelo:      nfceRequest.Payload.DocumentModel(),
+		XML:         signedXML,
+		Certificate: clientCert,
+	})
+	s.archive.Record(ctx, entity.SEFAZServiceAutorizacao, []*entity.NFCE{nfceRequest}, response, err)
+	if err != nil {
+		if confirmed, ok := s.confirmAuthorization(ctx, nfceRequest, nfceRequest.ChaveAcesso); ok {
+			return true, s.handleAuthorized(ctx, nfceRequest, nfceRequest.ChaveAcesso, signedXML, confirmed)
+		}
+		return false, fmt.Errorf("SEFAZ transmission failed: %w", err)
+	}
+
+	// Duplicidade means a previous transmission was authorized
+	if response.CStat == "204" || response.CStat == "539" {
+		if resolved, err := s.resolveDuplicate(ctx, nfceRequest, nfceRequest.ChaveAcesso, signedXML, response); resolved {
+			return true, err
+		}
+	}
+
+	switch response.Status {
+	case "authorized":
+		return true, s.handleAuthorized(ctx, nfceRequest, nfceRequest.ChaveAcesso, signedXML, response)
+	case "denied":
+		return true, s.handleRejected(ctx, nfceRequest, response)
+	default:
+		if soapclient.IsRetryableError(response.CStat) || s.shouldUseContingency(response.CStat) {
+			return false, fmt.Errorf("SEFAZ error (retryable): cStat=%s, motivo=%s", response.CStat, response.Motivo)
+		}
+		nfceRequest.MarkAsRejected(response.CStat, response.Motivo)
+		return true, nil
+	}
+}
+
+// RecordUsage counts the authorized NFC-e against the company subscription quota. Call it within
+// the transaction persisting the authorization; it returns the quota context when the quota ran out.
+func (s *NFCeWorkerService) RecordUsage(ctx context.Context, nfceRequest *entity.NFCE) (*entity.QuotaContext, error) {
+	// Simulated NFC-e never reach SEFAZ, so they are free
+	if s.featureGate == nil || nfceRequest.Payload.Ambiente == "simulado" {
+		return nil, nil
+	}
+	return s.featureGate.RecordUsage(ctx, nfceRequest.CompanyID)
+}
+
+// NotifyQuotaExceeded dispatches the quota.exceeded webhook for the NFC-e that used up the quota
+func (s *NFCeWorkerService) NotifyQuotaExceeded(ctx context.Context, nfceRequest *entity.NFCE, quota *entity.QuotaContext) {
+	if s.featureGate == nil {
+		return
+	}
+	s.featureGate.NotifyQuotaExceeded(ctx, nfceRequest.CompanyID, quota, map[string]interface{}{
+		"request_id":   nfceRequest.ID,
+		"chave_acesso": nfceRequest.ChaveAcesso,
+	})
+}
+
 // handleRejected processes SEFAZ rejection
 func (s *NFCeWorkerService) handleRejected(ctx context.Context, nfceRequest *entity.NFCE, response soapclient.AuthorizationResponse) error {
 	nfceRequest.MarkAsRejected(response.CStat, response.Motivo)
+	s.clearSubmission(ctx, nfceRequest)
 	return nil
 }
 
@@ -384,219 +1256,63 @@ func (s *NFCeWorkerService) storeXMLFile(ctx context.Context, xmlContent []byte,
 	return url, nil
 }
 
-// generateAndStorePDFFile generates DANFE PDF and uploads it
-func (s *NFCeWorkerService) generateAndStorePDFFile(ctx context.Context, nfceRequest *entity.NFCE, chaveAcesso string) (string, error) {
-	// Generate real DANFE PDF
-	pdfContent := s.generateDANFE(nfceRequest, chaveAcesso)
-	key := fmt.Sprintf("nfce/%s/pdf/%s.pdf", nfceRequest.CompanyID, chaveAcesso)
-	reader := bytes.NewReader(pdfContent)
+// storeCancelXMLFile uploads the procEventoNFe of the cancellation to storage
+func (s *NFCeWorkerService) storeCancelXMLFile(ctx context.Context, xmlContent []byte, chaveAcesso string, companyID string) (string, error) {
+	key := fmt.Sprintf("nfce/%s/xml/%s-cancelamento.xml", companyID, chaveAcesso)
+	reader := bytes.NewReader(xmlContent)
 
-	url, err := s.storage.UploadFile(ctx, "", key, reader, "application/pdf")
+	url, err := s.storage.UploadFile(ctx, "", key, reader, "application/xml")
 	if err != nil {
-		return "", fmt.Errorf("failed to upload PDF: %w", err)
+		return "", fmt.Errorf("failed to upload cancellation XML: %w", err)
 	}
 
 	return url, nil
 }
 
-// generateDANFE generates a real DANFE NFC-e PDF
-func (s *NFCeWorkerService) generateDANFE(nfceRequest *entity.NFCE, chaveAcesso string) []byte {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-
-	// Set margins
-	pdf.SetMargins(10, 10, 10)
-	pdf.SetAutoPageBreak(true, 10)
-
-	// Title
-	pdf.SetFont("Arial", "B", 14)
-	pdf.Cell(190, 10, "DOCUMENTO AUXILIAR DA NOTA FISCAL DE CONSUMIDOR ELETRÔNICA")
-	pdf.Ln(15)
-
-	// NFC-e Info
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(190, 6, "NFC-e")
-	pdf.Ln(8)
-
-	pdf.SetFont("Arial", "", 8)
-
-	// Chave de Acesso
-	pdf.Cell(30, 5, "Chave de Acesso:")
-	pdf.SetFont("Courier", "", 7)
-	pdf.MultiCell(160, 3, chaveAcesso, "", "L", false)
-	pdf.Ln(2)
-
-	// Emitente
-	pdf.SetFont("Arial", "B", 8)
-	pdf.Cell(190, 5, "EMITENTE")
-	pdf.Ln(6)
-
-	pdf.SetFont("Arial", "", 8)
-	pdf.Cell(20, 4, "CNPJ:")
-	pdf.Cell(50, 4, nfceRequest.Payload.Emitente.CNPJ)
-	pdf.Cell(20, 4, "IE:")
-	pdf.Cell(40, 4, nfceRequest.Payload.Emitente.IE)
-	pdf.Cell(15, 4, "UF:")
-	pdf.Cell(15, 4, nfceRequest.Payload.UF)
-	pdf.Ln(5)
-
-	// Ambiente
-	env := "PRODUÇÃO"
-	if nfceRequest.Payload.Ambiente == "2" || nfceRequest.Payload.Ambiente == "homologacao" {
-		env = "HOMOLOGAÇÃO"
-	}
-	pdf.Cell(25, 4, "Ambiente:")
-	pdf.Cell(40, 4, env)
-	pdf.Cell(20, 4, "Número:")
-	pdf.Cell(30, 4, nfceRequest.Numero)
-	pdf.Cell(20, 4, "Série:")
-	pdf.Cell(30, 4, nfceRequest.Serie)
-	pdf.Ln(8)
-
-	// Items Table Header
-	pdf.SetFont("Arial", "B", 7)
-	pdf.SetFillColor(240, 240, 240)
-
-	// Simple table without borders for now
-	pdf.Cell(15, 6, "Cód.")
-	pdf.Cell(60, 6, "Descrição")
-	pdf.Cell(15, 6, "Qtde")
-	pdf.Cell(15, 6, "UN")
-	pdf.Cell(20, 6, "V. Unit.")
-	pdf.Cell(20, 6, "V. Total")
-	pdf.Ln(6)
-
-	// Items
-	pdf.SetFont("Arial", "", 7)
-	totalValue := 0.0
-
-	for i, item := range nfceRequest.Payload.Itens {
-		pdf.Cell(15, 5, item.GTIN)
-		pdf.Cell(60, 5, truncateString(item.Descricao, 35))
-		pdf.Cell(15, 5, fmt.Sprintf("%.2f", item.Quantidade))
-		pdf.Cell(15, 5, item.Unidade)
-		pdf.Cell(20, 5, fmt.Sprintf("R$ %.2f", item.Valor))
-
-		itemTotal := item.Valor * item.Quantidade
-		totalValue += itemTotal
-		pdf.Cell(20, 5, fmt.Sprintf("R$ %.2f", itemTotal))
-		pdf.Ln(5)
-
-		// Add page break if needed
-		if i > 0 && i%20 == 0 && i < len(nfceRequest.Payload.Itens)-1 {
-			pdf.AddPage()
-		}
-	}
-
-	// Totals
-	pdf.Ln(5)
-	pdf.SetFont("Arial", "B", 8)
-	pdf.Cell(130, 6, "")
-	pdf.Cell(30, 6, "TOTAL R$:")
-	pdf.Cell(30, 6, fmt.Sprintf("%.2f", totalValue))
-	pdf.Ln(10)
-
-	// Payment Info
-	if len(nfceRequest.Payload.Pagamentos) > 0 {
-		pdf.SetFont("Arial", "B", 8)
-		pdf.Cell(190, 5, "FORMA DE PAGAMENTO")
-		pdf.Ln(6)
-
-		pdf.SetFont("Arial", "", 8)
-		for _, payment := range nfceRequest.Payload.Pagamentos {
-			pdf.Cell(40, 4, payment.Forma)
-			pdf.Cell(30, 4, fmt.Sprintf("R$ %.2f", payment.Valor))
-			if payment.Troco > 0 {
-				pdf.Cell(30, 4, fmt.Sprintf("Troco: R$ %.2f", payment.Troco))
-			}
-			pdf.Ln(5)
-		}
-		pdf.Ln(5)
+// generateAndStorePDFFile generates DANFE PDF and streams it to storage, without
+// holding a second copy of the document in memory
+func (s *NFCeWorkerService) generateAndStorePDFFile(ctx context.Context, nfceRequest *entity.NFCE, chaveAcesso string) (string, error) {
+	// Without the company the DANFE is still printed, lacking the issuer name and address
+	company, err := s.companyRepo.GetByID(ctx, nfceRequest.CompanyID)
+	if err != nil {
+		company = nil
 	}
 
-	// Protocol Info
-	pdf.SetFont("Arial", "B", 8)
-	pdf.Cell(190, 5, "PROTOCOLO DE AUTORIZAÇÃO")
-	pdf.Ln(6)
-
-	pdf.SetFont("Courier", "", 8)
-	pdf.Cell(190, 4, fmt.Sprintf("Protocolo: %s", nfceRequest.Protocolo))
-	pdf.Ln(5)
-	if nfceRequest.AuthorizedAt != nil {
-		pdf.Cell(190, 4, fmt.Sprintf("Data: %s", nfceRequest.AuthorizedAt.Format("02/01/2006 15:04:05")))
-	}
-	pdf.Ln(10)
+	opts := danfeOptions(nfceRequest, company)
+	key := fmt.Sprintf("nfce/%s/pdf/%s.pdf", nfceRequest.CompanyID, chaveAcesso)
 
-	// Footer
-	pdf.SetFont("Arial", "I", 6)
-	pdf.MultiCell(190, 3, "Esta NFC-e foi emitida por ME ou EPP optante pelo Simples Nacional. Não gera direito a crédito fiscal de IPI ou ICMS.", "", "L", false)
-	pdf.Ln(2)
-	pdf.Cell(190, 3, "Emitida em contingência: Não")
+	// Generate real DANFE PDF into the pipe read by the upload
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(s.danfeRenderer.RenderTo(writer, company, nfceRequest, chaveAcesso, opts))
+	}()
 
-	// Generate PDF bytes
-	var buf bytes.Buffer
-	err := pdf.Output(&buf)
+	url, err := s.storage.UploadFile(ctx, "", key, reader, "application/pdf")
+	// Unblock the renderer when the upload stopped reading early
+	reader.Close()
 	if err != nil {
-		// Fallback to simple PDF if gofpdf fails
-		return s.generateSimpleFallbackPDF(nfceRequest, chaveAcesso)
+		return "", fmt.Errorf("failed to upload PDF: %w", err)
 	}
 
-	return buf.Bytes()
+	return url, nil
 }
 
-// generateSimpleFallbackPDF creates a minimal PDF if gofpdf fails
-func (s *NFCeWorkerService) generateSimpleFallbackPDF(nfceRequest *entity.NFCE, chaveAcesso string) []byte {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(190, 20, "DANFE NFC-e")
-	pdf.Ln(20)
-
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(190, 8, fmt.Sprintf("Chave de Acesso: %s", chaveAcesso))
-	pdf.Ln(10)
-	pdf.Cell(190, 8, fmt.Sprintf("Emitente: %s", nfceRequest.Payload.Emitente.CNPJ))
-	pdf.Ln(10)
-
-	totalValue := 0.0
-	for _, item := range nfceRequest.Payload.Itens {
-		totalValue += item.Valor * item.Quantidade
+// danfeOptions resolves the DANFE vias and paper from the request options or the company defaults
+func danfeOptions(nfceRequest *entity.NFCE, company *entity.Company) danfe.Options {
+	opts := danfe.Options{DuasVias: nfceRequest.Payload.Options.DanfeDuasVias}
+	if company == nil {
+		return opts
 	}
-	pdf.Cell(190, 8, fmt.Sprintf("Valor Total: R$ %.2f", totalValue))
-
-	var buf bytes.Buffer
-	pdf.Output(&buf)
-	return buf.Bytes()
-}
 
-// Helper function to truncate strings
-func truncateString(str string, maxLen int) string {
-	if len(str) <= maxLen {
-		return str
-	}
-	return str[:maxLen-3] + "..."
+	opts.DuasVias = opts.DuasVias || company.DANFE.DuasVias
+	opts.CompactoEstabelecimento = company.DANFE.CompactoEstabelecimento
+	opts.Papel = company.DANFE.Papel
+	return opts
 }
 
-// storeQRCodeImage generates QR code image and uploads to storage
-func (s *NFCeWorkerService) storeQRCodeImage(ctx context.Context, qrURL, chaveAcesso, companyID string, contingency bool) (string, error) {
-	// Extract parameters from the NFC-e request to regenerate QR code
-	// For now, we'll use placeholder values - in production, these should come from the request
-	qrParams := qr.Params{
-		ChaveAcesso: chaveAcesso,
-		TpAmb:       "2", // Assume homologation for now
-		DhEmi:       time.Now().Format("2006-01-02T15:04:05-07:00"),
-		VNF:         "100.00",       // Should be calculated from items
-		VICMS:       "0.00",         // Should be calculated from taxes
-		DigVal:      "dummy_digest", // Should be extracted from signed XML
-		CSCID:       "001",          // Should come from company config
-		CSCToken:    "dummy_token",  // Should come from company config
-		UF:          "SP",           // Should come from request
-		Contingency: contingency,
-	}
-
-	// Generate QR code image
-	qrImage, err := s.qrGenerator.BuildImage(ctx, qrParams, 256)
+// storeQRCodeImage encodes the QR code URL built for the NFC-e, the same printed on the DANFE, and uploads the image to storage
+func (s *NFCeWorkerService) storeQRCodeImage(ctx context.Context, qrURL, chaveAcesso, companyID string) (string, error) {
+	qrImage, err := qr.Image(qrURL, 256)
 	if err != nil {
 		// Fallback to storing URL as text if image generation fails
 		content := fmt.Sprintf("QR Code URL: %s\nGenerated at: %s", qrURL, time.Now().Format(time.RFC3339))
@@ -642,13 +1358,24 @@ func (s *NFCeWorkerService) shouldUseContingency(cstat string) bool {
 }
 
 // tryContingency attempts to process the NFC-e using contingency mode
-func (s *NFCeWorkerService) tryContingency(ctx context.Context, nfceRequest *entity.NFCE) error {
-	// Determine which contingency to use based on UF
-	contingencyType := "SVC-AN" // Default to SVC-AN
-	if nfceRequest.Payload.UF == "RS" {
-		contingencyType = "SVC-RS" // Use SVC-RS for Rio Grande do Sul
+func (s *NFCeWorkerService) tryContingency(ctx context.Context, nfceRequest *entity.NFCE, response soapclient.AuthorizationResponse) error {
+	contingencyType := svcContingencyType(nfceRequest.Payload.UF)
+
+	// Contingency must be included in the company plan, otherwise keep retrying the normal endpoint
+	if s.featureGate != nil {
+		err := s.featureGate.Require(ctx, nfceRequest.CompanyID, "contingency", map[string]interface{}{
+			"request_id":       nfceRequest.ID,
+			"contingency_type": contingencyType,
+			"cstat":            response.CStat,
+		})
+		if err != nil {
+			return fmt.Errorf("SEFAZ unavailable (cStat=%s), contingency not used: %w", response.CStat, err)
+		}
 	}
 
+	// The next NFC-e of the company in the UF go straight to contingency
+	s.enterContingency(ctx, nfceRequest, contingencyType, response.CStat, response.Motivo)
+
 	// Mark as contingency
 	nfceRequest.MarkAsContingency(contingencyType)
 
@@ -656,7 +1383,141 @@ func (s *NFCeWorkerService) tryContingency(ctx context.Context, nfceRequest *ent
 	return s.processNFceEmissionWithContingency(ctx, nfceRequest, true, contingencyType)
 }
 
+// offlineFor reports whether the request may be issued in offline contingency, which
+// exists for the NFC-e only; the NF-e waits for SVC or for SEFAZ to be back
+func (s *NFCeWorkerService) offlineFor(nfceRequest *entity.NFCE) bool {
+	return s.offline.Enabled && !nfceRequest.Payload.IsNFe()
+}
+
+// tryOfflineContingency issues the NFC-e in offline contingency (tpEmis 9)
+func (s *NFCeWorkerService) tryOfflineContingency(ctx context.Context, nfceRequest *entity.NFCE, cstat string) error {
+	// Contingency must be included in the company plan, otherwise keep retrying the normal endpoint
+	if s.featureGate != nil {
+		err := s.featureGate.Require(ctx, nfceRequest.CompanyID, "contingency", map[string]interface{}{
+			"request_id":       nfceRequest.ID,
+			"contingency_type": entity.ContingencyTypeOffline,
+			"cstat":            cstat,
+		})
+		if err != nil {
+			return fmt.Errorf("SEFAZ unavailable (cStat=%s), contingency not used: %w", cstat, err)
+		}
+	}
+
+	s.enterContingency(ctx, nfceRequest, entity.ContingencyTypeOffline, cstat, "")
+
+	nfceRequest.MarkAsContingency(entity.ContingencyTypeOffline)
+
+	// The chave de acesso carries tpEmis, so the NFC-e is built and signed again
+	return s.processNFceEmissionWithContingency(ctx, nfceRequest, true, entity.ContingencyTypeOffline)
+}
+
+// emitWhileUnavailable issues the NFC-e in contingency while the circuit of its UF is
+// open. Without contingency in the plan the dispatch is delayed until SEFAZ is back.
+func (s *NFCeWorkerService) emitWhileUnavailable(ctx context.Context, nfceRequest *entity.NFCE, availability SEFAZAvailability) error {
+	cstat := availability.CStat
+	if cstat == "" {
+		cstat = "unreachable"
+	}
+
+	var err error
+	if s.offlineFor(nfceRequest) {
+		err = s.tryOfflineContingency(ctx, nfceRequest, cstat)
+	} else {
+		err = s.tryContingency(ctx, nfceRequest, soapclient.AuthorizationResponse{CStat: cstat, Motivo: availability.Motivo})
+	}
+	if errors.Is(err, ErrFeatureNotInPlan) {
+		return fmt.Errorf("%w: %s/%s: %w", ErrSEFAZUnavailable, availability.UF, availability.Ambiente, err)
+	}
+	return err
+}
+
+// recordAvailability feeds the circuit breaker with the outcome of an authorization
+// sent to the regular authorizer of the UF
+func (s *NFCeWorkerService) recordAvailability(nfceRequest *entity.NFCE, contingency, answered bool, cstat string) {
+	if !s.monitor.Enabled() || contingency {
+		return
+	}
+	if answered {
+		s.monitor.RecordSuccess(nfceRequest.Payload.UF, nfceRequest.Payload.Ambiente)
+		return
+	}
+	s.monitor.RecordFailure(nfceRequest.Payload.UF, nfceRequest.Payload.Ambiente, cstat)
+}
+
+// qrTpEmis returns the tpEmis used to select the QR Code format for the contingency type
+func qrTpEmis(contingencyType string) string {
+	if contingencyType == entity.ContingencyTypeOffline {
+		return qr.TpEmisOffline
+	}
+	return ""
+}
+
 // stringPtr returns a pointer to the given string
 func stringPtr(s string) *string {
 	return &s
 }
+
+// cardInput builds the card group of a payment; card payments sent without it are
+// declared as made on a POS not integrated with the PDV (tpIntegra 2)
+func cardInput(pag entity.Payment) *nfceInfra.CardInput {
+	if !pag.IsCard() {
+		return nil
+	}
+	if pag.Cartao == nil {
+		return &nfceInfra.CardInput{TpIntegra: entity.CardStandalone}
+	}
+	return &nfceInfra.CardInput{
+		TpIntegra: pag.Cartao.TpIntegra,
+		CNPJ:      optionalString(onlyDigits(pag.Cartao.CNPJ)),
+		TBand:     optionalString(pag.Cartao.Bandeira),
+		CAut:      optionalString(pag.Cartao.Autorizacao),
+	}
+}
+
+// optionalString returns nil for empty strings so optional XML tags are omitted
+func optionalString(s string) *string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return &s
+}
+
+// optionalMoney returns nil for zero values so optional XML tags are omitted
+func optionalMoney(value money.Amount) *string {
+	if value == 0 {
+		return nil
+	}
+	return stringPtr(value.String())
+}
+
+// onlyDigits strips formatting characters from documents such as CNPJ, IE and CEP
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// documentTotals keeps the tax totals of the built NF-e for its DANFE
+func documentTotals(tot nfceInfra.ICMSTot) *entity.DocumentTotals {
+	parse := func(value string) money.Amount {
+		amount, _ := money.Parse(value)
+		return amount
+	}
+	parseOptional := func(value *string) money.Amount {
+		if value == nil {
+			return 0
+		}
+		return parse(*value)
+	}
+	return &entity.DocumentTotals{
+		VBC:   parse(tot.VBC),
+		VICMS: parse(tot.VICMS),
+		VBCST: parse(tot.VBCST),
+		VST:   parse(tot.VST),
+		VIPI:  parseOptional(tot.VIPI),
+		VII:   parseOptional(tot.VII),
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// keyPrefix namespaces the entries of the API in a shared Redis
+const keyPrefix = "plugnfce:"
+
+// companyRepository serves the company and certificate reads of the emissions from the cache,
+// falling back to the repository on a miss or a cache failure. The entries hold the PFX, its
+// password and the CSC tokens, so they are sealed with the secret cipher. Update invalidates
+// the entries of the company; changes made outside the repository show up after the TTL.
+type companyRepository struct {
+	ports.CompanyRepository
+	cache   ports.Cache
+	secrets ports.SecretCipher
+	ttl     time.Duration
+	metrics *Metrics
+	logger  logger.Logger
+}
+
+// NewCompanyRepository wraps repo caching its entries for ttl
+func NewCompanyRepository(repo ports.CompanyRepository, cache ports.Cache, secrets ports.SecretCipher, ttl time.Duration, metrics *Metrics, l logger.Logger) ports.CompanyRepository {
+	return &companyRepository{
+		CompanyRepository: repo,
+		cache:             cache,
+		secrets:           secrets,
+		ttl:               ttl,
+		metrics:           metrics,
+		logger:            l,
+	}
+}
+
+// GetByID returns the company, with its CSCs
+func (r *companyRepository) GetByID(ctx context.Context, id string) (*entity.Company, error) {
+	var company entity.Company
+	key := companyKey(id)
+	if r.load(ctx, KindCompany, key, &company) {
+		return &company, nil
+	}
+
+	loaded, err := r.CompanyRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.store(ctx, key, loaded)
+	return loaded, nil
+}
+
+// GetCertificateByCompanyID returns the certificate of an active company
+func (r *companyRepository) GetCertificateByCompanyID(ctx context.Context, companyID string) (*entity.Certificate, error) {
+	var certificate entity.Certificate
+	key := certificateKey(companyID)
+	if r.load(ctx, KindCertificate, key, &certificate) {
+		return &certificate, nil
+	}
+
+	loaded, err := r.CompanyRepository.GetCertificateByCompanyID(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	r.store(ctx, key, loaded)
+	return loaded, nil
+}
+
+// Update saves the company and invalidates its entries
+func (r *companyRepository) Update(ctx context.Context, company *entity.Company) error {
+	if err := r.CompanyRepository.Update(ctx, company); err != nil {
+		return err
+	}
+	if err := r.cache.Delete(ctx, companyKey(company.ID), certificateKey(company.ID)); err != nil {
+		logger.FromContext(ctx, r.logger).Warn("Failed to invalidate cache of company", logger.CompanyID(company.ID), logger.Err(err))
+	}
+	return nil
+}
+
+// load decodes the entry of key into value, counting the lookup
+func (r *companyRepository) load(ctx context.Context, kind, key string, value interface{}) bool {
+	sealed, found, err := r.cache.Get(ctx, key)
+	if err != nil {
+		r.metrics.failure(kind)
+		return false
+	}
+	if !found {
+		r.metrics.miss(kind)
+		return false
+	}
+
+	data, err := r.secrets.Open(sealed)
+	if err == nil {
+		err = gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+	}
+	if err != nil {
+		// Sealed with another key or from an older layout; replaced by the next store
+		r.metrics.miss(kind)
+		return false
+	}
+	r.metrics.hit(kind)
+	return true
+}
+
+// store seals and keeps value under key; a failure only costs the next lookup a miss
+func (r *companyRepository) store(ctx context.Context, key string, value interface{}) {
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(value); err != nil {
+		return
+	}
+	sealed, err := r.secrets.Seal(data.Bytes())
+	if err != nil {
+		return
+	}
+	_ = r.cache.Set(ctx, key, sealed, r.ttl)
+}
+
+func companyKey(id string) string {
+	return keyPrefix + "company:" + id
+}
+
+func certificateKey(companyID string) string {
+	return keyPrefix + "certificate:" + companyID
+}
@@ -0,0 +1,66 @@
+package cache
+
+import "sync/atomic"
+
+// Kinds of the cached reads
+const (
+	KindCompany     = "company"     // Company with its CSCs
+	KindCertificate = "certificate" // Certificate used to sign
+)
+
+// counters are the lookups of a kind since the process started
+type counters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+	errors atomic.Int64
+}
+
+// KindStats summarizes the lookups of a kind. Errors are lookups that fell back to the
+// database because the cache failed; they count as misses in the hit ratio.
+type KindStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	Errors   int64   `json:"errors"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// Metrics counts the cache lookups of this process per kind
+type Metrics struct {
+	kinds map[string]*counters
+}
+
+// NewMetrics creates the counters of the cached kinds
+func NewMetrics() *Metrics {
+	return &Metrics{kinds: map[string]*counters{
+		KindCompany:     {},
+		KindCertificate: {},
+	}}
+}
+
+func (m *Metrics) hit(kind string) {
+	m.kinds[kind].hits.Add(1)
+}
+
+func (m *Metrics) miss(kind string) {
+	m.kinds[kind].misses.Add(1)
+}
+
+func (m *Metrics) failure(kind string) {
+	m.kinds[kind].errors.Add(1)
+}
+
+// Snapshot returns the stats of each kind, none when the cache is disabled (nil metrics)
+func (m *Metrics) Snapshot() map[string]KindStats {
+	if m == nil {
+		return map[string]KindStats{}
+	}
+	stats := make(map[string]KindStats, len(m.kinds))
+	for kind, c := range m.kinds {
+		s := KindStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Errors: c.errors.Load()}
+		if lookups := s.Hits + s.Misses + s.Errors; lookups > 0 {
+			s.HitRatio = float64(s.Hits) / float64(lookups)
+		}
+		stats[kind] = s
+	}
+	return stats
+}
@@ -0,0 +1,54 @@
+package cep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Providers that look the CEPs up
+const (
+	ProviderNone   = "none"
+	ProviderViaCEP = "viacep"
+)
+
+// lookupTimeout bounds a call to the CEP provider
+const lookupTimeout = 5 * time.Second
+
+// ErrNotFound is returned when the provider does not know the CEP
+var ErrNotFound = errors.New("CEP not found")
+
+// Address is the address a CEP belongs to
+type Address struct {
+	CEP             string
+	Logradouro      string
+	Bairro          string
+	Municipio       string
+	UF              string
+	CodigoMunicipio string // IBGE code, 7 digits
+}
+
+// Lookup finds the address of a CEP
+type Lookup interface {
+	// Lookup returns ErrNotFound when the CEP does not exist
+	Lookup(ctx context.Context, cep string) (*Address, error)
+}
+
+// Config selects and configures the provider
+type Config struct {
+	Provider  string // none | viacep
+	ViaCEPURL string // Base URL of the ViaCEP web service, e.g. https://viacep.com.br/ws
+}
+
+// NewLookup creates the lookup of the configured provider; nil disables the CEP lookups
+func NewLookup(config Config) (Lookup, error) {
+	switch config.Provider {
+	case "", ProviderNone:
+		return nil, nil
+	case ProviderViaCEP:
+		return NewViaCEPLookup(config.ViaCEPURL), nil
+	default:
+		return nil, fmt.Errorf("unknown CEP provider %q", config.Provider)
+	}
+}
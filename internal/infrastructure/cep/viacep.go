@@ -0,0 +1,82 @@
+package cep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// viaCEPLookup queries the ViaCEP web service, which answers the IBGE code of the
+// municipality with the address
+type viaCEPLookup struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewViaCEPLookup creates a lookup for the ViaCEP web service at endpoint
+func NewViaCEPLookup(endpoint string) Lookup {
+	return &viaCEPLookup{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{Timeout: lookupTimeout},
+	}
+}
+
+type viaCEPAddress struct {
+	CEP        string `json:"cep"`
+	Logradouro string `json:"logradouro"`
+	Bairro     string `json:"bairro"`
+	Localidade string `json:"localidade"`
+	UF         string `json:"uf"`
+	IBGE       string `json:"ibge"`
+	// Erro is true, or "true" in the current version of the service, for unknown CEPs
+	Erro interface{} `json:"erro"`
+}
+
+// Lookup queries the CEP, given with or without the hyphen
+func (l *viaCEPLookup) Lookup(ctx context.Context, cep string) (*Address, error) {
+	digits := strings.ReplaceAll(cep, "-", "")
+	if len(digits) != 8 {
+		return nil, ErrNotFound
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.endpoint+"/"+digits+"/json/", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ViaCEP request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ViaCEP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	// An invalid CEP is answered with 400, an unknown one with the erro field
+	if resp.StatusCode == http.StatusBadRequest {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ViaCEP returned status %d", resp.StatusCode)
+	}
+
+	var address viaCEPAddress
+	if err := json.Unmarshal(body, &address); err != nil {
+		return nil, fmt.Errorf("failed to decode ViaCEP response: %w", err)
+	}
+	if address.Erro == true || address.Erro == "true" {
+		return nil, ErrNotFound
+	}
+
+	return &Address{
+		CEP:             digits,
+		Logradouro:      address.Logradouro,
+		Bairro:          address.Bairro,
+		Municipio:       address.Localidade,
+		UF:              address.UF,
+		CodigoMunicipio: address.IBGE,
+	}, nil
+}
@@ -0,0 +1,33 @@
+package clock
+
+import (
+	"strings"
+	"time"
+)
+
+// dateTimeLayout is the UTC-offset date time format of dhEmi, dhCont and dhEvento (AAAA-MM-DDThh:mm:ssTZD)
+const dateTimeLayout = "2006-01-02T15:04:05-07:00"
+
+// Brazilian legal time zones; there is no daylight saving time since 2019
+var (
+	brasilia = time.FixedZone("BRT", -3*60*60)
+	amazonas = time.FixedZone("AMT", -4*60*60)
+	acre     = time.FixedZone("ACT", -5*60*60)
+)
+
+// Location returns the legal time zone of the UF capital, used for the dhEmi of the notes it authorizes
+func Location(uf string) *time.Location {
+	switch strings.ToUpper(uf) {
+	case "AC":
+		return acre
+	case "AM", "MS", "MT", "RO", "RR":
+		return amazonas
+	default:
+		return brasilia
+	}
+}
+
+// FormatDateTime formats t in the UF time zone with an explicit UTC offset, as the NFC-e layout requires
+func FormatDateTime(t time.Time, uf string) string {
+	return t.In(Location(uf)).Format(dateTimeLayout)
+}
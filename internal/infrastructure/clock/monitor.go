@@ -0,0 +1,196 @@
+package clock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// MaxCompensation is the dhEmi drift SEFAZ tolerates before rejecting the note
+// (703 - data-hora de emissão posterior ao horário de recebimento, 228 - data de
+// emissão muito atrasada). Larger offsets are never compensated.
+const MaxCompensation = 5 * time.Minute
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the host clock
+type SystemClock struct{}
+
+// Now returns the host time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// MonitorConfig controls the NTP clock skew checker
+type MonitorConfig struct {
+	Enabled    bool
+	Servers    []string      // Queried in order until one answers
+	Interval   time.Duration // How often the offset is measured
+	Threshold  time.Duration // Offset above which operators are warned
+	Compensate bool          // Shift dhEmi by the measured offset while it is within MaxCompensation
+}
+
+// Status is the last measured skew of the host clock
+type Status struct {
+	Enabled      bool      `json:"enabled"`
+	Server       string    `json:"server,omitempty"`
+	OffsetMs     int64     `json:"offset_ms"`
+	ThresholdMs  int64     `json:"threshold_ms"`
+	Exceeded     bool      `json:"exceeded"`
+	Compensating bool      `json:"compensating"`
+	CheckedAt    time.Time `json:"checked_at,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Monitor periodically measures the host clock against NTP servers. SEFAZ rejects
+// notes whose dhEmi drifts from its own clock, so a skewed host is reported in the
+// health check and logs, and optionally compensated within the legal tolerance.
+type Monitor struct {
+	config MonitorConfig
+	logger logger.Logger
+
+	mu     sync.RWMutex
+	offset time.Duration
+	status Status
+}
+
+// NewMonitor creates a new clock skew monitor
+func NewMonitor(config MonitorConfig, l logger.Logger) *Monitor {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Minute
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = 2 * time.Second
+	}
+	return &Monitor{
+		config: config,
+		logger: l,
+		status: Status{
+			Enabled:     config.Enabled,
+			ThresholdMs: config.Threshold.Milliseconds(),
+		},
+	}
+}
+
+// Enabled reports whether the monitor is enabled
+func (m *Monitor) Enabled() bool {
+	return m != nil && m.config.Enabled && len(m.config.Servers) > 0
+}
+
+// Interval returns how often the offset is measured
+func (m *Monitor) Interval() time.Duration {
+	return m.config.Interval
+}
+
+// Run measures the offset right away and then at every interval until ctx is done
+func (m *Monitor) Run(ctx context.Context) {
+	if !m.Enabled() {
+		return
+	}
+
+	m.Check(ctx)
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check measures the offset against the first NTP server that answers and
+// warns when it is beyond the threshold
+func (m *Monitor) Check(ctx context.Context) Status {
+	var (
+		offset  time.Duration
+		server  string
+		lastErr error
+	)
+	for _, candidate := range m.config.Servers {
+		queryCtx, cancel := context.WithTimeout(ctx, defaultQueryTimeout)
+		offset, lastErr = QueryOffset(queryCtx, candidate)
+		cancel()
+		if lastErr == nil {
+			server = candidate
+			break
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status.CheckedAt = time.Now()
+	if lastErr != nil {
+		// Keep the last known offset; an unreachable NTP server says nothing about the host clock
+		m.status.Error = fmt.Sprintf("no NTP server answered: %v", lastErr)
+		m.logger.Warn("Clock skew check failed", logger.Field{Key: "error", Value: lastErr.Error()})
+		return m.status
+	}
+
+	m.offset = offset
+	m.status.Server = server
+	m.status.OffsetMs = offset.Milliseconds()
+	m.status.Exceeded = abs(offset) > m.config.Threshold
+	m.status.Compensating = m.config.Compensate && offset != 0 && abs(offset) <= MaxCompensation
+	m.status.Error = ""
+
+	switch {
+	case abs(offset) > MaxCompensation:
+		m.logger.Error("Host clock skew beyond the SEFAZ tolerance, notes will be rejected; fix the host clock",
+			logger.Field{Key: "offset", Value: offset.String()},
+			logger.Field{Key: "server", Value: server})
+	case m.status.Exceeded:
+		m.logger.Warn("Host clock skew beyond threshold; fix the host clock",
+			logger.Field{Key: "offset", Value: offset.String()},
+			logger.Field{Key: "threshold", Value: m.config.Threshold.String()},
+			logger.Field{Key: "compensating", Value: m.status.Compensating},
+			logger.Field{Key: "server", Value: server})
+	}
+
+	return m.status
+}
+
+// Status returns the last measured skew
+func (m *Monitor) Status() Status {
+	if m == nil {
+		return Status{}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Now returns the host time, shifted by the measured offset when compensation is
+// enabled and the offset is within MaxCompensation
+func (m *Monitor) Now() time.Time {
+	now := time.Now()
+	if m == nil {
+		return now
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.status.Compensating {
+		return now.Add(m.offset)
+	}
+	return now
+}
+
+// abs returns the absolute value of d
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
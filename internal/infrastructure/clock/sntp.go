@@ -0,0 +1,90 @@
+package clock
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP (1900) and Unix (1970) epochs
+const ntpEpochOffset = 2208988800
+
+// sntpPacketSize is the size of an NTP v4 packet without extensions
+const sntpPacketSize = 48
+
+// defaultQueryTimeout bounds a single SNTP round trip
+const defaultQueryTimeout = 5 * time.Second
+
+// QueryOffset asks the NTP server how far the local clock is from it (RFC 4330).
+// A positive offset means the local clock is behind the server.
+func QueryOffset(ctx context.Context, server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, fmt.Errorf("failed to dial NTP server %s: %w", server, err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultQueryTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, fmt.Errorf("failed to set NTP deadline: %w", err)
+	}
+
+	request := make([]byte, sntpPacketSize)
+	request[0] = 0x23 // LI 0, version 4, mode 3 (client)
+	t1 := time.Now()
+	putTimestamp(request[40:], t1) // Transmit timestamp, echoed back as originate
+
+	if _, err := conn.Write(request); err != nil {
+		return 0, fmt.Errorf("failed to send NTP request to %s: %w", server, err)
+	}
+
+	response := make([]byte, sntpPacketSize)
+	n, err := conn.Read(response)
+	t4 := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read NTP response from %s: %w", server, err)
+	}
+	if n < sntpPacketSize {
+		return 0, fmt.Errorf("short NTP response from %s: %d bytes", server, n)
+	}
+
+	mode := response[0] & 0x07
+	stratum := response[1]
+	if mode != 4 {
+		return 0, fmt.Errorf("unexpected NTP mode %d from %s", mode, server)
+	}
+	if stratum == 0 || stratum > 15 {
+		return 0, fmt.Errorf("NTP server %s is unsynchronized (stratum %d)", server, stratum)
+	}
+
+	t2 := readTimestamp(response[32:]) // Receive timestamp
+	t3 := readTimestamp(response[40:]) // Transmit timestamp
+
+	return (t2.Sub(t1) + t3.Sub(t4)) / 2, nil
+}
+
+// putTimestamp writes t as a 64-bit NTP timestamp
+func putTimestamp(b []byte, t time.Time) {
+	seconds := uint64(t.Unix()) + ntpEpochOffset
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	binary.BigEndian.PutUint32(b[0:], uint32(seconds))
+	binary.BigEndian.PutUint32(b[4:], uint32(fraction))
+}
+
+// readTimestamp reads a 64-bit NTP timestamp
+func readTimestamp(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[0:])) - ntpEpochOffset
+	fraction := uint64(binary.BigEndian.Uint32(b[4:]))
+	nanos := int64(fraction * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanos)
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// Admin repository implementation
+type adminRepository struct {
+	db *gorm.DB
+}
+
+func NewAdminRepository(db *gorm.DB) ports.AdminRepository {
+	return &adminRepository{db: db}
+}
+
+func (r *adminRepository) Create(ctx context.Context, admin *entity.Admin) error {
+	return dbFromContext(ctx, r.db).Create(admin).Error
+}
+
+func (r *adminRepository) Update(ctx context.Context, admin *entity.Admin) error {
+	return dbFromContext(ctx, r.db).Save(admin).Error
+}
+
+func (r *adminRepository) GetByID(ctx context.Context, id string) (*entity.Admin, error) {
+	var admin entity.Admin
+	err := dbFromContext(ctx, r.db).First(&admin, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+func (r *adminRepository) GetByUsername(ctx context.Context, username string) (*entity.Admin, error) {
+	var admin entity.Admin
+	err := dbFromContext(ctx, r.db).First(&admin, "username = ?", username).Error
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// API key repository implementation
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) ports.APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *entity.APIKey) error {
+	return dbFromContext(ctx, r.db).Create(key).Error
+}
+
+func (r *apiKeyRepository) Update(ctx context.Context, key *entity.APIKey) error {
+	return dbFromContext(ctx, r.db).Model(key).Updates(map[string]interface{}{
+		"name":       key.Name,
+		"expires_at": key.ExpiresAt,
+		"revoked_at": key.RevokedAt,
+		"updated_at": key.UpdatedAt,
+	}).Error
+}
+
+func (r *apiKeyRepository) GetByID(ctx context.Context, id string) (*entity.APIKey, error) {
+	var key entity.APIKey
+	err := dbFromContext(ctx, r.db).First(&key, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	var key entity.APIKey
+	err := dbFromContext(ctx, r.db).First(&key, "key_hash = ?", keyHash).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) ListByCompanyID(ctx context.Context, companyID string) ([]*entity.APIKey, error) {
+	var keys []*entity.APIKey
+	err := dbFromContext(ctx, r.db).
+		Where("company_id = ?", companyID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// TouchLastUsed skips the write when the key was used in the last minute, keeping
+// authentication from updating the row on every request
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	return dbFromContext(ctx, r.db).Model(&entity.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, at.Add(-time.Minute)).
+		Update("last_used_at", at).Error
+}
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// Audit log repository implementation
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) ports.AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(ctx context.Context, entry *entity.AuditEntry) error {
+	return dbFromContext(ctx, r.db).Create(entry).Error
+}
+
+func (r *auditRepository) List(ctx context.Context, filter ports.AuditFilter, limit, offset int) ([]*entity.AuditEntry, int, error) {
+	var entries []*entity.AuditEntry
+	var total int64
+
+	query := replicaFromContext(ctx, r.db).Model(&entity.AuditEntry{})
+	if filter.ActorType != "" {
+		query = query.Where("actor_type = ?", filter.ActorType)
+	}
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.CompanyID != "" {
+		query = query.Where("company_id = ?", filter.CompanyID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action ILIKE ?", "%"+filter.Action+"%")
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Limit(limit).Offset(offset).Order("created_at DESC").Find(&entries).Error
+	return entries, int(total), err
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// catalogImportBatchSize bounds the rows inserted per statement on import
+const catalogImportBatchSize = 1000
+
+// NCM/CEST/CFOP/municipality catalog repository implementation
+type catalogRepository struct {
+	db *gorm.DB
+}
+
+func NewCatalogRepository(db *gorm.DB) ports.CatalogRepository {
+	return &catalogRepository{db: db}
+}
+
+func (r *catalogRepository) HasNCMs(ctx context.Context) (bool, error) {
+	var exists bool
+	err := dbFromContext(ctx, r.db).Raw("SELECT EXISTS (SELECT 1 FROM catalog_ncm)").Scan(&exists).Error
+	return exists, err
+}
+
+func (r *catalogRepository) HasCESTs(ctx context.Context) (bool, error) {
+	var exists bool
+	err := dbFromContext(ctx, r.db).Raw("SELECT EXISTS (SELECT 1 FROM catalog_cest)").Scan(&exists).Error
+	return exists, err
+}
+
+func (r *catalogRepository) FindNCMs(ctx context.Context, codes []string) (map[string]entity.NCMCatalogEntry, error) {
+	var entries []entity.NCMCatalogEntry
+	if err := dbFromContext(ctx, r.db).Where("codigo IN ?", codes).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]entity.NCMCatalogEntry, len(entries))
+	for _, entry := range entries {
+		found[entry.Codigo] = entry
+	}
+	return found, nil
+}
+
+func (r *catalogRepository) FindCESTs(ctx context.Context, codes []string) (map[string][]entity.CESTCatalogEntry, error) {
+	var entries []entity.CESTCatalogEntry
+	if err := dbFromContext(ctx, r.db).Where("codigo IN ?", codes).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string][]entity.CESTCatalogEntry, len(entries))
+	for _, entry := range entries {
+		found[entry.Codigo] = append(found[entry.Codigo], entry)
+	}
+	return found, nil
+}
+
+func (r *catalogRepository) FindCFOPs(ctx context.Context, codes []string) (map[string]entity.CFOPCatalogEntry, error) {
+	var entries []entity.CFOPCatalogEntry
+	if err := dbFromContext(ctx, r.db).Where("codigo IN ?", codes).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]entity.CFOPCatalogEntry, len(entries))
+	for _, entry := range entries {
+		found[entry.Codigo] = entry
+	}
+	return found, nil
+}
+
+func (r *catalogRepository) HasMunicipios(ctx context.Context) (bool, error) {
+	var exists bool
+	err := dbFromContext(ctx, r.db).Raw("SELECT EXISTS (SELECT 1 FROM catalog_municipios)").Scan(&exists).Error
+	return exists, err
+}
+
+func (r *catalogRepository) GetMunicipio(ctx context.Context, codigo string) (*entity.MunicipioCatalogEntry, error) {
+	var entry entity.MunicipioCatalogEntry
+	err := dbFromContext(ctx, r.db).Where("codigo = ?", codigo).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *catalogRepository) FindMunicipio(ctx context.Context, uf, nomeBusca string) (*entity.MunicipioCatalogEntry, error) {
+	var entry entity.MunicipioCatalogEntry
+	err := dbFromContext(ctx, r.db).Where("uf = ? AND nome_busca = ?", uf, nomeBusca).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ReplaceNCMs swaps the NCM table in a single transaction
+func (r *catalogRepository) ReplaceNCMs(ctx context.Context, entries []entity.NCMCatalogEntry) error {
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM catalog_ncm").Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(entries, catalogImportBatchSize).Error
+	})
+}
+
+// ReplaceCESTs swaps the CEST table in a single transaction
+func (r *catalogRepository) ReplaceCESTs(ctx context.Context, entries []entity.CESTCatalogEntry) error {
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM catalog_cest").Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(entries, catalogImportBatchSize).Error
+	})
+}
+
+// ReplaceMunicipios swaps the IBGE municipality table in a single transaction
+func (r *catalogRepository) ReplaceMunicipios(ctx context.Context, entries []entity.MunicipioCatalogEntry) error {
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM catalog_municipios").Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(entries, catalogImportBatchSize).Error
+	})
+}
@@ -2,28 +2,34 @@ package postgres
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Company repository implementation
 type companyRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	secrets ports.SecretCipher // Opens the stored PFX
 }
 
-func NewCompanyRepository(db *gorm.DB) ports.CompanyRepository {
-	return &companyRepository{db: db}
+func NewCompanyRepository(db *gorm.DB, secrets ports.SecretCipher) ports.CompanyRepository {
+	return &companyRepository{db: db, secrets: secrets}
 }
 
 func (r *companyRepository) Create(ctx context.Context, company *entity.Company) error {
-	return r.db.WithContext(ctx).Create(company).Error
+	return dbFromContext(ctx, r.db).Create(company).Error
 }
 
 func (r *companyRepository) GetByID(ctx context.Context, id string) (*entity.Company, error) {
 	var company entity.Company
-	err := r.db.WithContext(ctx).First(&company, "id = ?", id).Error
+	err := dbFromContext(ctx, r.db).First(&company, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +38,7 @@ func (r *companyRepository) GetByID(ctx context.Context, id string) (*entity.Com
 
 func (r *companyRepository) GetByCNPJ(ctx context.Context, cnpj string) (*entity.Company, error) {
 	var company entity.Company
-	err := r.db.WithContext(ctx).Where("cnpj = ?", cnpj).First(&company).Error
+	err := dbFromContext(ctx, r.db).Where("cnpj = ?", cnpj).First(&company).Error
 	if err != nil {
 		return nil, err
 	}
@@ -40,14 +46,14 @@ func (r *companyRepository) GetByCNPJ(ctx context.Context, cnpj string) (*entity
 }
 
 func (r *companyRepository) Update(ctx context.Context, company *entity.Company) error {
-	return r.db.WithContext(ctx).Save(company).Error
+	return dbFromContext(ctx, r.db).Save(company).Error
 }
 
 func (r *companyRepository) List(ctx context.Context, limit, offset int) ([]*entity.Company, int, error) {
 	var companies []*entity.Company
 	var total int64
 
-	query := r.db.WithContext(ctx).Model(&entity.Company{})
+	query := replicaFromContext(ctx, r.db).Model(&entity.Company{})
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -56,50 +62,164 @@ func (r *companyRepository) List(ctx context.Context, limit, offset int) ([]*ent
 	return companies, int(total), err
 }
 
+func (r *companyRepository) ListByIDs(ctx context.Context, ids []string) ([]*entity.Company, error) {
+	var companies []*entity.Company
+	err := dbFromContext(ctx, r.db).Where("id IN ?", ids).Find(&companies).Error
+	return companies, err
+}
+
 func (r *companyRepository) Count(ctx context.Context) (int, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&entity.Company{}).Count(&count).Error
+	err := replicaFromContext(ctx, r.db).Model(&entity.Company{}).Count(&count).Error
 	return int(count), err
 }
 
 func (r *companyRepository) CountByStatus(ctx context.Context, status entity.CompanyStatus) (int, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&entity.Company{}).Where("status = ?", status).Count(&count).Error
+	err := replicaFromContext(ctx, r.db).Model(&entity.Company{}).Where("status = ?", status).Count(&count).Error
 	return int(count), err
 }
 
-// Default NFC-e series used by the system
-const defaultNFCeSerie = "1"
+// ListDeleted lists the offboarded companies, oldest deletion first
+func (r *companyRepository) ListDeleted(ctx context.Context, limit, offset int) ([]*entity.Company, int, error) {
+	var companies []*entity.Company
+	var total int64
+
+	query := dbFromContext(ctx, r.db).Model(&entity.Company{}).Where("status = ?", entity.CompanyStatusDeleted)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Limit(limit).Offset(offset).Order("deleted_at ASC, id ASC").Find(&companies).Error
+	return companies, int(total), err
+}
+
+// GetNextNFCeNumber allocates the next number of a series of the company. The series row is locked
+// until the transaction ends, so concurrent emissions never get the same number.
+func (r *companyRepository) GetNextNFCeNumber(ctx context.Context, companyID, modelo, ambiente string, serie int) (int64, error) {
+	var number int64
+
+	err := dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		series, err := lockSeries(tx, companyID, modelo, ambiente, serie)
+		if errors.Is(err, gorm.ErrRecordNotFound) && serie == entity.DefaultNFCeSerie {
+			// Companies without series start on the default one at their first emission
+			series, err = createDefaultSeries(tx, companyID, modelo, ambiente)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: modelo %s serie %d (%s)", entity.ErrNumberingSeriesNotFound, modelo, serie, ambiente)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !series.Active {
+			return fmt.Errorf("%w: modelo %s serie %d (%s)", entity.ErrNumberingSeriesInactive, modelo, serie, ambiente)
+		}
+		if series.NextNumber > entity.MaxNFCeNumber {
+			return fmt.Errorf("%w: modelo %s serie %d (%s)", entity.ErrNumberingSeriesExhausted, modelo, serie, ambiente)
+		}
+
+		number = series.NextNumber
+		return tx.Model(series).Updates(map[string]interface{}{
+			"next_number": number + 1,
+			"updated_at":  time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
 
-// GetNextNFCeNumber atomically gets and increments the next NFC-e number for a company
-func (r *companyRepository) GetNextNFCeNumber(ctx context.Context, companyID string) (int64, error) {
-	var nextNumber int64
+	return number, nil
+}
 
-	// Use PostgreSQL function for atomic sequence generation with default series
-	err := r.db.WithContext(ctx).Raw("SELECT get_next_nfce_number(?::uuid, ?)", companyID, defaultNFCeSerie).Scan(&nextNumber).Error
+// GetDefaultNFCeSerie returns the default series of the company for the model in the environment
+func (r *companyRepository) GetDefaultNFCeSerie(ctx context.Context, companyID, modelo, ambiente string) (int, error) {
+	var series entity.NumberingSeries
+	err := dbFromContext(ctx, r.db).
+		First(&series, "company_id = ? AND modelo = ? AND ambiente = ? AND is_default", companyID, modelo, ambiente).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.DefaultNFCeSerie, nil
+	}
 	if err != nil {
 		return 0, err
 	}
+	return series.Serie, nil
+}
+
+// lockSeries loads a series locking its row for update
+func lockSeries(tx *gorm.DB, companyID, modelo, ambiente string, serie int) (*entity.NumberingSeries, error) {
+	var series entity.NumberingSeries
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&series, "company_id = ? AND modelo = ? AND ambiente = ? AND serie = ?", companyID, modelo, ambiente, serie).Error
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// createDefaultSeries registers DefaultNFCeSerie as the default series of a company that has
+// none for the model in the environment, and returns it locked
+func createDefaultSeries(tx *gorm.DB, companyID, modelo, ambiente string) (*entity.NumberingSeries, error) {
+	var count int64
+	if err := tx.Model(&entity.NumberingSeries{}).
+		Where("company_id = ? AND modelo = ? AND ambiente = ?", companyID, modelo, ambiente).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
 
-	return nextNumber, nil
+	series, err := entity.NewNumberingSeries(companyID, modelo, entity.DefaultNFCeSerie, ambiente, 1)
+	if err != nil {
+		return nil, err
+	}
+	series.Default = true
+
+	// A concurrent first emission may have created it; both end up locking the same row
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(series).Error; err != nil {
+		return nil, err
+	}
+	return lockSeries(tx, companyID, modelo, ambiente, entity.DefaultNFCeSerie)
 }
 
 // GetCertificateByCompanyID retrieves the certificate for a company
 func (r *companyRepository) GetCertificateByCompanyID(ctx context.Context, companyID string) (*entity.Certificate, error) {
 	var company entity.Company
-	err := r.db.WithContext(ctx).Select("certificado_pfx_data, certificado_password").First(&company, "id = ? AND status = ?", companyID, entity.CompanyStatusActive).Error
+	err := dbFromContext(ctx, r.db).
+		Select("certificado_type, certificado_pfx_data, certificado_password, certificado_pem, certificado_kms_endpoint, certificado_kms_key_id, certificado_kms_token").
+		First(&company, "id = ? AND status = ?", companyID, entity.CompanyStatusActive).Error
 	if err != nil {
 		return nil, err
 	}
 
+	// Companies signing through a KMS have no PFX, only the remote key and its public certificate
+	if company.Certificado.Type == entity.CertificateTypeKMS {
+		if company.Certificado.KMSEndpoint == "" || len(company.Certificado.CertificatePEM) == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return &entity.Certificate{
+			KMS: &entity.KMSKeyRef{
+				Endpoint:       company.Certificado.KMSEndpoint,
+				KeyID:          company.Certificado.KMSKeyID,
+				Token:          company.Certificado.KMSToken,
+				CertificatePEM: company.Certificado.CertificatePEM,
+			},
+		}, nil
+	}
+
 	// Check if certificate exists
 	if company.Certificado.PFXData == nil || company.Certificado.Password == "" {
 		return nil, gorm.ErrRecordNotFound
 	}
 
-	// Convert bytea to base64 string (assuming it's stored as base64 in the DB)
+	// The PFX is stored encrypted, or base64 encoded before encryption was configured
+	pfxData, err := r.secrets.Open(company.Certificado.PFXData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open certificate: %w", err)
+	}
 	certData := &entity.Certificate{
-		PFXBase64: string(company.Certificado.PFXData),
+		PFXBase64: base64.StdEncoding.EncodeToString(pfxData),
 		Password:  company.Certificado.Password,
 	}
 
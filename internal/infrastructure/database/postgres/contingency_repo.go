@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// contingencyStateRepository implements ports.ContingencyStateRepository
+type contingencyStateRepository struct {
+	db *gorm.DB
+}
+
+// NewContingencyStateRepository creates a new contingency state repository
+func NewContingencyStateRepository(db *gorm.DB) ports.ContingencyStateRepository {
+	return &contingencyStateRepository{db: db}
+}
+
+// Enter stores the period; when another worker entered contingency first, the unique
+// index idx_contingency_states_active keeps its period, which is returned instead
+func (r *contingencyStateRepository) Enter(ctx context.Context, state *entity.ContingencyState) (*entity.ContingencyState, error) {
+	result := dbFromContext(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(state)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return state, nil
+	}
+	return r.GetActive(ctx, state.CompanyID, state.UF, state.Ambiente)
+}
+
+func (r *contingencyStateRepository) Update(ctx context.Context, state *entity.ContingencyState) error {
+	return dbFromContext(ctx, r.db).Save(state).Error
+}
+
+func (r *contingencyStateRepository) GetActive(ctx context.Context, companyID, uf, ambiente string) (*entity.ContingencyState, error) {
+	var state entity.ContingencyState
+	err := dbFromContext(ctx, r.db).
+		Where("company_id = ? AND uf = ? AND ambiente = ? AND exited_at IS NULL", companyID, uf, ambiente).
+		First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ListActive lists the periods not ended yet, oldest first
+func (r *contingencyStateRepository) ListActive(ctx context.Context) ([]*entity.ContingencyState, error) {
+	var states []*entity.ContingencyState
+	err := dbFromContext(ctx, r.db).
+		Where("exited_at IS NULL").
+		Order("entered_at ASC").
+		Find(&states).Error
+	return states, err
+}
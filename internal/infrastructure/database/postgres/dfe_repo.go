@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DF-e distribution repository implementation
+type dfeRepository struct {
+	db *gorm.DB
+}
+
+func NewDFeRepository(db *gorm.DB) ports.DFeRepository {
+	return &dfeRepository{db: db}
+}
+
+func (r *dfeRepository) GetCursor(ctx context.Context, companyID string) (*entity.DFeCursor, error) {
+	var cursor entity.DFeCursor
+	err := dbFromContext(ctx, r.db).First(&cursor, "company_id = ?", companyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+func (r *dfeRepository) SaveCursor(ctx context.Context, cursor *entity.DFeCursor) error {
+	return dbFromContext(ctx, r.db).Save(cursor).Error
+}
+
+// ListDueCursors lists the cursors whose next sync is due, oldest first
+func (r *dfeRepository) ListDueCursors(ctx context.Context, before time.Time, limit int) ([]*entity.DFeCursor, error) {
+	var cursors []*entity.DFeCursor
+	err := dbFromContext(ctx, r.db).
+		Where("next_sync_at <= ?", before).
+		Order("next_sync_at ASC").
+		Limit(limit).
+		Find(&cursors).Error
+	return cursors, err
+}
+
+// SaveDocuments inserts the documents, skipping NSUs already stored for the company
+func (r *dfeRepository) SaveDocuments(ctx context.Context, documents []*entity.DFeDocument) error {
+	if len(documents) == 0 {
+		return nil
+	}
+	return dbFromContext(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "company_id"}, {Name: "nsu"}}, DoNothing: true}).
+		Create(&documents).Error
+}
+
+func (r *dfeRepository) ListDocuments(ctx context.Context, companyID string, filter ports.DFeFilter, limit, offset int) ([]*entity.DFeDocument, int, error) {
+	var documents []*entity.DFeDocument
+	var total int64
+
+	query := dbFromContext(ctx, r.db).Model(&entity.DFeDocument{}).Where("company_id = ?", companyID)
+	if filter.Tipo != "" {
+		query = query.Where("tipo = ?", filter.Tipo)
+	}
+	if filter.ChaveAcesso != "" {
+		query = query.Where("chave_acesso = ?", filter.ChaveAcesso)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Limit(limit).Offset(offset).Order("nsu DESC").Find(&documents).Error
+	return documents, int(total), err
+}
+
+func (r *dfeRepository) GetDocument(ctx context.Context, companyID, id string) (*entity.DFeDocument, error) {
+	var document entity.DFeDocument
+	err := dbFromContext(ctx, r.db).First(&document, "id = ? AND company_id = ?", id, companyID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// E-mail delivery repository implementation
+type emailDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailDeliveryRepository(db *gorm.DB) ports.EmailDeliveryRepository {
+	return &emailDeliveryRepository{db: db}
+}
+
+func (r *emailDeliveryRepository) Create(ctx context.Context, delivery *entity.EmailDelivery) error {
+	return dbFromContext(ctx, r.db).Create(delivery).Error
+}
+
+func (r *emailDeliveryRepository) ListByRequestID(ctx context.Context, requestID string) ([]*entity.EmailDelivery, error) {
+	var deliveries []*entity.EmailDelivery
+	err := dbFromContext(ctx, r.db).Where("request_id = ?", requestID).Order("created_at DESC").Find(&deliveries).Error
+	return deliveries, err
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// Export repository implementation
+type exportRepository struct {
+	db *gorm.DB
+}
+
+func NewExportRepository(db *gorm.DB) ports.ExportRepository {
+	return &exportRepository{db: db}
+}
+
+func (r *exportRepository) Create(ctx context.Context, job *entity.ExportJob) error {
+	return dbFromContext(ctx, r.db).Create(job).Error
+}
+
+func (r *exportRepository) GetByID(ctx context.Context, id string) (*entity.ExportJob, error) {
+	var job entity.ExportJob
+	err := dbFromContext(ctx, r.db).First(&job, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *exportRepository) Update(ctx context.Context, job *entity.ExportJob) error {
+	return dbFromContext(ctx, r.db).Save(job).Error
+}
+
+func (r *exportRepository) ListByCompanyID(ctx context.Context, companyID string, limit, offset int) ([]*entity.ExportJob, int, error) {
+	var jobs []*entity.ExportJob
+	var total int64
+
+	query := dbFromContext(ctx, r.db).Model(&entity.ExportJob{}).Where("company_id = ?", companyID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Limit(limit).Offset(offset).Order("created_at DESC").Find(&jobs).Error
+	return jobs, int(total), err
+}
+
+// ListExpired lists jobs past their expiry that still hold parts in storage
+func (r *exportRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]*entity.ExportJob, error) {
+	var jobs []*entity.ExportJob
+	err := dbFromContext(ctx, r.db).
+		Where("status <> ? AND expires_at <= ?", entity.ExportStatusExpired, before).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&jobs).Error
+	return jobs, err
+}
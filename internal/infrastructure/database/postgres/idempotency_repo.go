@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Idempotency key repository implementation
+type idempotencyKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyRepository(db *gorm.DB) ports.IdempotencyKeyRepository {
+	return &idempotencyKeyRepository{db: db}
+}
+
+// CreateIfAbsent inserts the key, reporting false when the company already holds it
+func (r *idempotencyKeyRepository) CreateIfAbsent(ctx context.Context, key *entity.IdempotencyKey) (bool, error) {
+	result := dbFromContext(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "company_id"}, {Name: "idempotency_key"}}, DoNothing: true}).
+		Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *idempotencyKeyRepository) GetByKey(ctx context.Context, companyID, key string) (*entity.IdempotencyKey, error) {
+	var record entity.IdempotencyKey
+	err := dbFromContext(ctx, r.db).First(&record, "company_id = ? AND idempotency_key = ?", companyID, key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete stores the response of the request made with the key
+func (r *idempotencyKeyRepository) Complete(ctx context.Context, id string, status int, body []byte, completedAt time.Time) error {
+	return dbFromContext(ctx, r.db).Model(&entity.IdempotencyKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"response_status": status,
+			"response_body":   body,
+			"completed_at":    completedAt,
+		}).Error
+}
+
+func (r *idempotencyKeyRepository) Delete(ctx context.Context, id string) error {
+	return dbFromContext(ctx, r.db).Delete(&entity.IdempotencyKey{}, "id = ?", id).Error
+}
+
+// DeleteExpired removes a batch of expired keys, oldest first
+func (r *idempotencyKeyRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result := dbFromContext(ctx, r.db).Exec(
+		`DELETE FROM idempotency_keys WHERE id IN (SELECT id FROM idempotency_keys WHERE expires_at <= ? ORDER BY expires_at LIMIT ?)`,
+		before, limit)
+	return result.RowsAffected, result.Error
+}
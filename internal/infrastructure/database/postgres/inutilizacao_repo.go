@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// Inutilizacao repository implementation
+type inutilizacaoRepository struct {
+	db *gorm.DB
+}
+
+func NewInutilizacaoRepository(db *gorm.DB) ports.InutilizacaoRepository {
+	return &inutilizacaoRepository{db: db}
+}
+
+func (r *inutilizacaoRepository) Create(ctx context.Context, inutilizacao *entity.Inutilizacao) error {
+	return dbFromContext(ctx, r.db).Create(inutilizacao).Error
+}
+
+func (r *inutilizacaoRepository) GetByID(ctx context.Context, id string) (*entity.Inutilizacao, error) {
+	var inutilizacao entity.Inutilizacao
+	err := dbFromContext(ctx, r.db).First(&inutilizacao, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &inutilizacao, nil
+}
+
+func (r *inutilizacaoRepository) Update(ctx context.Context, inutilizacao *entity.Inutilizacao) error {
+	return dbFromContext(ctx, r.db).Save(inutilizacao).Error
+}
+
+func (r *inutilizacaoRepository) ListByCompanyID(ctx context.Context, companyID string, limit, offset int) ([]*entity.Inutilizacao, int, error) {
+	var inutilizacoes []*entity.Inutilizacao
+	var total int64
+
+	query := dbFromContext(ctx, r.db).Model(&entity.Inutilizacao{}).Where("company_id = ?", companyID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Limit(limit).Offset(offset).Order("created_at DESC").Find(&inutilizacoes).Error
+	return inutilizacoes, int(total), err
+}
+
+// HasOverlap checks for pending or homologated ranges of the model and series intersecting [numeroInicial, numeroFinal]
+func (r *inutilizacaoRepository) HasOverlap(ctx context.Context, companyID, modelo string, serie int, numeroInicial, numeroFinal int64) (bool, error) {
+	var count int64
+	err := dbFromContext(ctx, r.db).Model(&entity.Inutilizacao{}).
+		Where("company_id = ? AND modelo = ? AND serie = ? AND status <> ?", companyID, modelo, serie, entity.InutilizacaoStatusRejected).
+		Where("numero_inicial <= ? AND numero_final >= ?", numeroFinal, numeroInicial).
+		Count(&count).Error
+	return count > 0, err
+}
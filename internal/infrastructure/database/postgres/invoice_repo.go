@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// Invoice repository implementation
+type invoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) ports.InvoiceRepository {
+	return &invoiceRepository{db: db}
+}
+
+func (r *invoiceRepository) Create(ctx context.Context, invoice *entity.Invoice) error {
+	return dbFromContext(ctx, r.db).Create(invoice).Error
+}
+
+func (r *invoiceRepository) GetByID(ctx context.Context, id string) (*entity.Invoice, error) {
+	var invoice entity.Invoice
+	err := dbFromContext(ctx, r.db).First(&invoice, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *invoiceRepository) GetByChargeID(ctx context.Context, gateway, chargeID string) (*entity.Invoice, error) {
+	var invoice entity.Invoice
+	err := dbFromContext(ctx, r.db).First(&invoice, "gateway = ? AND charge_id = ?", gateway, chargeID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *invoiceRepository) GetOpenBySubscriptionID(ctx context.Context, subscriptionID string) (*entity.Invoice, error) {
+	var invoice entity.Invoice
+	err := dbFromContext(ctx, r.db).
+		Where("subscription_id = ? AND status IN ('pending', 'failed')", subscriptionID).
+		Order("period_start").First(&invoice).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *invoiceRepository) Update(ctx context.Context, invoice *entity.Invoice) error {
+	return dbFromContext(ctx, r.db).Save(invoice).Error
+}
+
+func (r *invoiceRepository) ListRetryDue(ctx context.Context, now time.Time, limit int) ([]*entity.Invoice, error) {
+	var invoices []*entity.Invoice
+	err := dbFromContext(ctx, r.db).
+		Where("status = 'failed' AND next_attempt_at <= ?", now).
+		Order("next_attempt_at").Limit(limit).Find(&invoices).Error
+	return invoices, err
+}
+
+func (r *invoiceRepository) ListByCompanyID(ctx context.Context, companyID string, limit, offset int) ([]*entity.Invoice, int, error) {
+	var invoices []*entity.Invoice
+	var total int64
+
+	query := dbFromContext(ctx, r.db).Model(&entity.Invoice{}).Where("company_id = ?", companyID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Limit(limit).Offset(offset).Order("period_start DESC").Find(&invoices).Error
+	return invoices, int(total), err
+}
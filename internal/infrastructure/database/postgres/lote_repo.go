@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// NFC-e lote repository implementation
+type nfceLoteRepository struct {
+	db *gorm.DB
+}
+
+func NewNFCeLoteRepository(db *gorm.DB) ports.NFCeLoteRepository {
+	return &nfceLoteRepository{db: db}
+}
+
+func (r *nfceLoteRepository) Create(ctx context.Context, lote *entity.NFCeLote) error {
+	return dbFromContext(ctx, r.db).Create(lote).Error
+}
+
+func (r *nfceLoteRepository) GetByID(ctx context.Context, id string) (*entity.NFCeLote, error) {
+	var lote entity.NFCeLote
+	err := dbFromContext(ctx, r.db).First(&lote, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lote, nil
+}
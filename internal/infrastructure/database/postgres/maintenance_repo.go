@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// Maintenance window repository implementation
+type maintenanceRepository struct {
+	db *gorm.DB
+}
+
+func NewMaintenanceRepository(db *gorm.DB) ports.MaintenanceRepository {
+	return &maintenanceRepository{db: db}
+}
+
+func (r *maintenanceRepository) Create(ctx context.Context, window *entity.MaintenanceWindow) error {
+	return dbFromContext(ctx, r.db).Create(window).Error
+}
+
+func (r *maintenanceRepository) Update(ctx context.Context, window *entity.MaintenanceWindow) error {
+	return dbFromContext(ctx, r.db).Save(window).Error
+}
+
+func (r *maintenanceRepository) GetActive(ctx context.Context) (*entity.MaintenanceWindow, error) {
+	var window entity.MaintenanceWindow
+	err := dbFromContext(ctx, r.db).
+		Where("ended_at IS NULL").
+		Order("started_at DESC").
+		First(&window).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &window, nil
+}
@@ -2,6 +2,9 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,67 +23,137 @@ func NewNFCeRepository(db *gorm.DB) ports.NFCeRepository {
 	return &nfceRepository{db: db}
 }
 
-// Create creates a new NFC-e request
+// Create creates a new NFC-e request with its creation event, the first of its events
 func (r *nfceRepository) Create(ctx context.Context, req *entity.NFCE) error {
 	req.ID = uuid.New().String()
 	// Set default company ID if not provided (temporary until company management is implemented)
 	if req.CompanyID == "" {
 		req.CompanyID = "550e8400-e29b-41d4-a716-446655440000" // Default company UUID
 	}
+	if err := entity.RequestStatus("").ValidateTransition(req.Status); err != nil {
+		return err
+	}
+	req.Version = 1
 	req.CreatedAt = time.Now()
-	req.UpdatedAt = time.Now()
-	// Omit associations to prevent GORM from trying to resolve Events relationship
-	return r.db.WithContext(ctx).Omit("Events").Create(req).Error
-}
-
-// UpdateStatus updates the status of an NFC-e request
-func (r *nfceRepository) UpdateStatus(ctx context.Context, id string, from entity.RequestStatus, to entity.RequestStatus, mutate func(*entity.NFCE)) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Optimized: Use direct UPDATE with WHERE clause to avoid SELECT + UPDATE
-		result := tx.Model(&entity.NFCE{}).
-			Where("id = ? AND status = ?", id, from).
-			Update("status", to).
-			Update("updated_at", time.Now())
-
-		if result.Error != nil {
-			return result.Error
+	req.UpdatedAt = req.CreatedAt
+
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		// Omit associations to prevent GORM from trying to resolve Events relationship
+		if err := tx.Omit("Events").Create(req).Error; err != nil {
+			return err
 		}
+		return recordEvent(tx, req, "", &entity.Event{Message: "Solicitação de emissão recebida"})
+	})
+}
+
+// Transition saves the status of the NFC-e read at nfce.Version and appends its event
+func (r *nfceRepository) Transition(ctx context.Context, nfce *entity.NFCE, event *entity.Event) error {
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return transition(tx, nfce, event)
+	})
+}
 
-		// If no rows were affected, status was already changed
-		if result.RowsAffected == 0 {
+// UpdateStatus moves the NFC-e from one status to another and appends the event of the
+// transition; a pair the state machine does not allow fails before the NFC-e is read
+func (r *nfceRepository) UpdateStatus(ctx context.Context, id string, from entity.RequestStatus, to entity.RequestStatus, message string, mutate func(*entity.NFCE)) error {
+	if err := from.ValidateTransition(to); err != nil {
+		return err
+	}
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var req entity.NFCE
+		err := tx.Where("id = ? AND status = ?", id, from).Take(&req).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil // Status already changed or record not found
 		}
+		if err != nil {
+			return err
+		}
 
-		// If we need to mutate other fields, we still need to fetch
 		if mutate != nil {
-			var req entity.NFCE
-			if err := tx.First(&req, "id = ?", id).Error; err != nil {
-				return err
-			}
 			mutate(&req)
-			return tx.Save(&req).Error
 		}
+		req.Status = to
 
-		return nil
+		err = transition(tx, &req, &entity.Event{Message: message})
+		if errors.Is(err, entity.ErrStatusConflict) {
+			return nil // Changed since it was read
+		}
+		return err
 	})
 }
 
+// transition saves the status of the NFC-e and appends the event of the transition in tx. The
+// row is only updated while it keeps the version and status the NFC-e was read with, so a
+// concurrent change fails with entity.ErrStatusConflict instead of being overwritten.
+func transition(tx *gorm.DB, nfce *entity.NFCE, event *entity.Event) error {
+	var current struct {
+		Status entity.RequestStatus
+	}
+	err := tx.Model(&entity.NFCE{}).Select("status").Where("id = ? AND version = ?", nfce.ID, nfce.Version).Take(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.ErrStatusConflict
+	}
+	if err != nil {
+		return err
+	}
+	if err := current.Status.ValidateTransition(nfce.Status); err != nil {
+		return err
+	}
+
+	next := *nfce
+	next.Version++
+	next.UpdatedAt = time.Now()
+	result := tx.Model(&next).
+		Where("version = ? AND status = ?", nfce.Version, current.Status).
+		Select("*").Omit("id", "created_at", "Events").
+		Updates(&next)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrStatusConflict
+	}
+
+	nfce.Version, nfce.UpdatedAt = next.Version, next.UpdatedAt
+	return recordEvent(tx, nfce, current.Status, event)
+}
+
+// recordEvent stores the event of the NFC-e moving from a status to its current one, numbered
+// with the version of the NFC-e and carrying its state; a status change also goes in the
+// metadata as an entity.StatusTransition
+func recordEvent(tx *gorm.DB, nfce *entity.NFCE, from entity.RequestStatus, event *entity.Event) error {
+	event.ID = uuid.New().String()
+	event.RequestID = nfce.ID
+	event.Sequence = nfce.Version
+	event.StatusFrom = from
+	event.StatusTo = nfce.Status
+	event.State = nfce.State()
+	event.CreatedAt = time.Now()
+	if from != nfce.Status {
+		if event.Metadata == nil {
+			event.Metadata = map[string]interface{}{}
+		}
+		event.Metadata[entity.EventMetadataTransition] = entity.StatusTransition{From: from, To: nfce.Status, Sequence: nfce.Version}
+	}
+	return tx.Create(event).Error
+}
+
 // GetByID gets an NFC-e request by ID
 func (r *nfceRepository) GetByID(ctx context.Context, id string) (*entity.NFCE, error) {
 	var req entity.NFCE
-	err := r.db.WithContext(ctx).First(&req, "id = ?", id).Error
+	err := dbFromContext(ctx, r.db).First(&req, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &req, nil
 }
 
-// GetByIdempotencyKey gets an NFC-e request by idempotency key
-func (r *nfceRepository) GetByIdempotencyKey(ctx context.Context, key string) (*entity.NFCE, error) {
+// GetByIdempotencyKey gets an NFC-e request of the company by idempotency key
+func (r *nfceRepository) GetByIdempotencyKey(ctx context.Context, companyID, key string) (*entity.NFCE, error) {
 	var req entity.NFCE
-	err := r.db.WithContext(ctx).
+	err := dbFromContext(ctx, r.db).
 		Omit("Events"). // Prevent GORM from trying to load Events association
-		Where("idempotency_key = ?", key).
+		Where("company_id = ? AND idempotency_key = ?", companyID, key).
 		Order("created_at DESC"). // Get the most recent if duplicates (though UNIQUE constraint prevents this)
 		First(&req).Error
 	if err != nil {
@@ -89,102 +162,275 @@ func (r *nfceRepository) GetByIdempotencyKey(ctx context.Context, key string) (*
 	return &req, nil
 }
 
-// List lists NFC-e requests with pagination
-func (r *nfceRepository) List(ctx context.Context, limit, offset int) ([]*entity.NFCE, error) {
-	var requests []*entity.NFCE
-	err := r.db.WithContext(ctx).Limit(limit).Offset(offset).Order("created_at DESC").Find(&requests).Error
-	return requests, err
+// ChaveAcessoExists reports whether an NFC-e was already given the chave de acesso
+func (r *nfceRepository) GetByChaveAcesso(ctx context.Context, chave string) (*entity.NFCE, error) {
+	var req entity.NFCE
+	err := dbFromContext(ctx, r.db).Omit("Events").First(&req, "chave_acesso = ?", chave).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
 }
 
-// AppendEvent appends an event to the NFC-e request
-func (r *nfceRepository) AppendEvent(ctx context.Context, evt *entity.Event) error {
-	evt.ID = uuid.New().String()
-	evt.CreatedAt = time.Now()
-	return r.db.WithContext(ctx).Create(evt).Error
+func (r *nfceRepository) ChaveAcessoExists(ctx context.Context, chave string) (bool, error) {
+	var count int64
+	err := dbFromContext(ctx, r.db).Model(&entity.NFCE{}).Where("chave_acesso = ?", chave).Limit(1).Count(&count).Error
+	return count > 0, err
 }
 
-// ListWithFilters lists NFC-e requests with filters and pagination
-func (r *nfceRepository) ListWithFilters(ctx context.Context, limit, offset int, companyID, status string) ([]*entity.NFCE, int, error) {
-	var requests []*entity.NFCE
-	var total int64
+// GetByIDForCompany gets an NFC-e request of the company; it returns nil when the request does
+// not exist or belongs to another company
+func (r *nfceRepository) GetByIDForCompany(ctx context.Context, companyID, id string) (*entity.NFCE, error) {
+	var req entity.NFCE
+	err := dbFromContext(ctx, r.db).First(&req, "id = ? AND company_id = ?", id, companyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
 
-	query := r.db.WithContext(ctx).Model(&entity.NFCE{})
+// nfceSortColumns maps the listing sort orders to their column; each is paired with id for a
+// stable keyset, backed by the (company_id, column, id) indexes
+var nfceSortColumns = map[string]string{
+	ports.NFCeSortCreatedAt: "created_at",
+	ports.NFCeSortValor:     "v_nf",
+}
 
-	// Apply filters (order matters for index usage)
-	if companyID != "" && status != "" {
-		// Use composite index: idx_nfce_requests_company_status_created
-		query = query.Where("company_id = ? AND status = ?", companyID, status)
-	} else if companyID != "" {
+// ListByCompany lists a page of the company NFC-e matching the filter, after the filter
+// cursor in the filter sort order, with the total matching the filter. An empty companyID
+// lists the NFC-e of every company.
+func (r *nfceRepository) ListByCompany(ctx context.Context, companyID string, filter ports.NFCeFilter, limit int) ([]*entity.NFCE, int, error) {
+	query := replicaFromContext(ctx, r.db).Model(&entity.NFCE{})
+	if companyID != "" {
 		query = query.Where("company_id = ?", companyID)
-	} else if status != "" {
-		query = query.Where("status = ?", status)
 	}
 
-	// Get total count efficiently
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if filter.ChaveAcesso != "" {
+		query = query.Where("chave_acesso = ?", filter.ChaveAcesso)
+	}
+	if filter.Serie != "" {
+		query = query.Where("serie = ?", filter.Serie)
+	}
+	if filter.Numero != "" {
+		query = query.Where("numero = ?", filter.Numero)
+	}
+	if filter.Destinatario != "" {
+		query = query.Where("dest_documento = ?", filter.Destinatario)
+	}
+	if filter.CStat != "" {
+		query = query.Where("cstat = ?", filter.CStat)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.MinValor != nil {
+		query = query.Where("v_nf >= ?", *filter.MinValor)
+	}
+	if filter.MaxValor != nil {
+		query = query.Where("v_nf <= ?", *filter.MaxValor)
+	}
+	if filter.Contingency != nil {
+		query = query.Where("COALESCE(in_contingency, FALSE) = ?", *filter.Contingency)
+	}
+	if filter.Query != "" {
+		query = query.Where("search_vector @@ websearch_to_tsquery('portuguese', ?)", filter.Query)
+	}
+	if len(filter.Metadata) > 0 {
+		metadata, err := json.Marshal(filter.Metadata)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode metadata filter: %w", err)
+		}
+		query = query.Where("metadata @> ?::jsonb", string(metadata)) // Uses GIN index idx_nfce_requests_metadata
+	}
+
+	var total int64
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	// Get paginated results with optimized ordering
-	err := query.Limit(limit).Offset(offset).Order("created_at DESC").Find(&requests).Error
+	column, ok := nfceSortColumns[filter.Sort]
+	if !ok {
+		column = nfceSortColumns[ports.NFCeSortCreatedAt]
+	}
+	direction, compare := "ASC", ">"
+	if filter.Desc {
+		direction, compare = "DESC", "<"
+	}
+	if after := filter.After; after != nil {
+		var value any = after.CreatedAt
+		if column == "v_nf" {
+			value = after.Valor
+		}
+		// Row comparison keeps the keyset on the (company_id, column, id) index
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, compare), value, after.ID)
+	}
+
+	var requests []*entity.NFCE
+	err := query.Omit("Events").
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(limit).
+		Find(&requests).Error
 	return requests, int(total), err
 }
 
+// searchHeadlineOptions marks the matched words with <mark> in up to three excerpts
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=3, FragmentDelimiter=\" ... \""
+
+// Search ranks the NFC-e of the company whose item descriptions or consumer name match
+// the query (web search syntax: words, "phrases", OR, -exclusion), using the
+// search_vector GIN index
+func (r *nfceRepository) Search(ctx context.Context, companyID, query string, limit, offset int) ([]*entity.NFCeSearchHit, int, error) {
+	db := replicaFromContext(ctx, r.db)
+
+	var total int64
+	err := db.Model(&entity.NFCE{}).
+		Where("company_id = ? AND search_vector @@ websearch_to_tsquery('portuguese', ?)", companyID, query).
+		Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	var matches []struct {
+		ID        string
+		Rank      float64
+		Highlight string
+	}
+	err = db.Raw(`
+		SELECT r.id,
+			ts_rank(r.search_vector, q.query) AS rank,
+			ts_headline('portuguese',
+				concat_ws(' | ',
+					(SELECT string_agg(item->>'descricao', ' | ') FROM jsonb_array_elements(r.payload->'itens') AS item),
+					r.payload->'destinatario'->>'nome'),
+				q.query, ?) AS highlight
+		FROM nfce_requests r, websearch_to_tsquery('portuguese', ?) AS q(query)
+		WHERE r.company_id = ? AND r.search_vector @@ q.query
+		ORDER BY rank DESC, r.created_at DESC
+		LIMIT ? OFFSET ?`,
+		searchHeadlineOptions, query, companyID, limit, offset).
+		Scan(&matches).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	var requests []*entity.NFCE
+	if err := db.Omit("Events").Where("id IN ?", ids).Find(&requests).Error; err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[string]*entity.NFCE, len(requests))
+	for _, req := range requests {
+		byID[req.ID] = req
+	}
+
+	// Keep the ranking order of the matches
+	hits := make([]*entity.NFCeSearchHit, 0, len(matches))
+	for _, match := range matches {
+		if req, ok := byID[match.ID]; ok {
+			hits = append(hits, &entity.NFCeSearchHit{NFCe: req, Rank: match.Rank, Highlight: match.Highlight})
+		}
+	}
+	return hits, int(total), nil
+}
+
+// AppendEvent appends an event that keeps the status of the NFC-e request, numbered after
+// the last one and carrying the current state of the request
+func (r *nfceRepository) AppendEvent(ctx context.Context, evt *entity.Event) error {
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var req entity.NFCE
+		err := tx.Raw(`UPDATE nfce_requests SET version = version + 1 WHERE id = ? RETURNING *`, evt.RequestID).
+			Scan(&req).Error
+		if err != nil {
+			return err
+		}
+		if req.ID == "" {
+			return gorm.ErrRecordNotFound
+		}
+		if evt.StatusTo != "" && evt.StatusTo != req.Status {
+			return fmt.Errorf("%w: %q to %q must be recorded by a status transition", entity.ErrInvalidStatusTransition, req.Status, evt.StatusTo)
+		}
+		return recordEvent(tx, &req, req.Status, evt)
+	})
+}
+
 // Count counts total NFC-e requests
 func (r *nfceRepository) Count(ctx context.Context) (int, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&entity.NFCE{}).Count(&count).Error
+	err := replicaFromContext(ctx, r.db).Model(&entity.NFCE{}).Count(&count).Error
 	return int(count), err
 }
 
 // CountByStatus counts NFC-e requests by status
 func (r *nfceRepository) CountByStatus(ctx context.Context, status entity.RequestStatus) (int, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&entity.NFCE{}).Where("status = ?", status).Count(&count).Error
+	err := replicaFromContext(ctx, r.db).Model(&entity.NFCE{}).Where("status = ?", status).Count(&count).Error
 	return int(count), err
 }
 
-// Update updates an NFC-e request
+// Update updates an NFC-e request, keeping the status and version of the row: they only
+// change along with an event
 func (r *nfceRepository) Update(ctx context.Context, nfce *entity.NFCE) error {
 	nfce.UpdatedAt = time.Now()
-	return r.db.WithContext(ctx).Save(nfce).Error
+	return dbFromContext(ctx, r.db).Omit("status", "version").Save(nfce).Error
 }
 
-// UpdateFields updates specific fields of an NFC-e request efficiently
+// UpdateFields updates specific fields of an NFC-e request efficiently. The status and version
+// only change along with an event, so updates naming them are refused.
 func (r *nfceRepository) UpdateFields(ctx context.Context, id string, updates map[string]interface{}) error {
+	for _, column := range []string{"status", "version"} {
+		if _, ok := updates[column]; ok {
+			return fmt.Errorf("%w: %s must be changed by a status transition", entity.ErrInvalidStatusTransition, column)
+		}
+	}
 	updates["updated_at"] = time.Now()
-	return r.db.WithContext(ctx).
+	return dbFromContext(ctx, r.db).
 		Model(&entity.NFCE{}).
 		Where("id = ?", id).
 		Updates(updates).Error
 }
 
 // GetStats returns optimized statistics for dashboard
-func (r *nfceRepository) GetStats(ctx context.Context, companyID string, since time.Time) (map[string]int, error) {
+func (r *nfceRepository) GetStats(ctx context.Context, filter ports.NFCeStatsFilter) (map[string]int, error) {
 	var stats struct {
-		Pending    int `json:"pending"`
-		Processing int `json:"processing"`
-		Authorized int `json:"authorized"`
-		Rejected   int `json:"rejected"`
-		Retrying   int `json:"retrying"`
-		Canceled   int `json:"canceled"`
-		Total      int `json:"total"`
-	}
-
-	query := r.db.WithContext(ctx).Model(&entity.NFCE{}).Where("created_at >= ?", since)
-
-	if companyID != "" {
-		query = query.Where("company_id = ?", companyID)
+		Pending             int `json:"pending"`
+		QueuedDeferred      int `json:"queued_deferred"`
+		Processing          int `json:"processing"`
+		Authorized          int `json:"authorized"`
+		Rejected            int `json:"rejected"`
+		Retrying            int `json:"retrying"`
+		Canceled            int `json:"canceled"`
+		PendingTransmission int `json:"pending_transmission"`
+		Total               int `json:"total"`
 	}
 
 	// Use raw SQL for better performance on stats queries
-	err := query.Select(`
+	err := r.statsQuery(ctx, filter).Select(`
 		COUNT(*) FILTER (WHERE status = 'pending') as pending,
+		COUNT(*) FILTER (WHERE status = 'queued_deferred') as queued_deferred,
 		COUNT(*) FILTER (WHERE status = 'processing') as processing,
 		COUNT(*) FILTER (WHERE status = 'authorized') as authorized,
 		COUNT(*) FILTER (WHERE status = 'rejected') as rejected,
 		COUNT(*) FILTER (WHERE status = 'retrying') as retrying,
 		COUNT(*) FILTER (WHERE status = 'canceled') as canceled,
+		COUNT(*) FILTER (WHERE status = 'pending_transmission') as pending_transmission,
 		COUNT(*) as total
 	`).Scan(&stats).Error
 
@@ -193,37 +439,380 @@ func (r *nfceRepository) GetStats(ctx context.Context, companyID string, since t
 	}
 
 	return map[string]int{
-		"pending":    stats.Pending,
-		"processing": stats.Processing,
-		"authorized": stats.Authorized,
-		"rejected":   stats.Rejected,
-		"retrying":   stats.Retrying,
-		"canceled":   stats.Canceled,
-		"total":      stats.Total,
+		"pending":              stats.Pending,
+		"queued_deferred":      stats.QueuedDeferred,
+		"processing":           stats.Processing,
+		"authorized":           stats.Authorized,
+		"rejected":             stats.Rejected,
+		"retrying":             stats.Retrying,
+		"canceled":             stats.Canceled,
+		"pending_transmission": stats.PendingTransmission,
+		"total":                stats.Total,
 	}, nil
 }
 
+// statsQuery selects, on the read replicas, the NFC-e created within the filter period, of the filter company if set
+func (r *nfceRepository) statsQuery(ctx context.Context, filter ports.NFCeStatsFilter) *gorm.DB {
+	query := replicaFromContext(ctx, r.db).Model(&entity.NFCE{}).
+		Where("nfce_requests.created_at >= ? AND nfce_requests.created_at < ?", filter.From, filter.To)
+	if filter.CompanyID != "" {
+		query = query.Where("nfce_requests.company_id = ?", filter.CompanyID)
+	}
+	return query
+}
+
+// GetTopRejections groups the rejected NFC-e by cStat, reporting the most frequent xMotivo of each
+func (r *nfceRepository) GetTopRejections(ctx context.Context, filter ports.NFCeStatsFilter, limit int) ([]entity.RejectionStat, error) {
+	var rejections []entity.RejectionStat
+	err := r.statsQuery(ctx, filter).
+		Select("cstat, MODE() WITHIN GROUP (ORDER BY xmotivo) AS xmotivo, COUNT(*) AS count").
+		Where("status = ? AND cstat IS NOT NULL AND cstat <> ''", entity.RequestStatusRejected).
+		Group("cstat").
+		Order("count DESC, cstat ASC").
+		Limit(limit).
+		Scan(&rejections).Error
+	return rejections, err
+}
+
+// GetLatencyByUF computes the percentiles of the time from intake to authorization by UF. NFC-e
+// issued in contingency are left out, as they wait for SEFAZ on purpose.
+func (r *nfceRepository) GetLatencyByUF(ctx context.Context, filter ports.NFCeStatsFilter) ([]entity.UFLatency, error) {
+	var latencies []entity.UFLatency
+	err := r.statsQuery(ctx, filter).
+		Select(`
+			payload->>'uf' AS uf,
+			COUNT(*) AS count,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM authorized_at - created_at) * 1000) AS p50_ms,
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM authorized_at - created_at) * 1000) AS p90_ms,
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM authorized_at - created_at) * 1000) AS p99_ms
+		`).
+		Where("authorized_at IS NOT NULL AND NOT COALESCE(in_contingency, FALSE)").
+		Group("payload->>'uf'").
+		Order("uf ASC").
+		Scan(&latencies).Error
+	return latencies, err
+}
+
+// GetDailyStats counts the NFC-e by day of creation in Brasília time
+func (r *nfceRepository) GetDailyStats(ctx context.Context, filter ports.NFCeStatsFilter) ([]entity.DailyStats, error) {
+	var days []entity.DailyStats
+	err := r.statsQuery(ctx, filter).
+		Select(`
+			(created_at AT TIME ZONE 'America/Sao_Paulo')::date AS date,
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE status = 'authorized') AS authorized,
+			COUNT(*) FILTER (WHERE status = 'rejected') AS rejected,
+			COUNT(*) FILTER (WHERE status = 'canceled') AS canceled,
+			COALESCE(SUM(v_nf) FILTER (WHERE status = 'authorized'), 0) AS valor
+		`).
+		Group("date").
+		Order("date ASC").
+		Scan(&days).Error
+	return days, err
+}
+
+// GetCompanyStats counts the NFC-e of each company, with the billable notes of the usage statements
+func (r *nfceRepository) GetCompanyStats(ctx context.Context, filter ports.NFCeStatsFilter) ([]entity.CompanyStats, error) {
+	var companies []entity.CompanyStats
+	err := r.statsQuery(ctx, filter).
+		Select(`
+			nfce_requests.company_id AS company_id,
+			COALESCE(MAX(companies.cnpj), '') AS cnpj,
+			COALESCE(MAX(companies.razao_social), '') AS razao_social,
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE status = 'authorized') AS authorized,
+			COUNT(*) FILTER (WHERE status = 'rejected') AS rejected,
+			COUNT(*) FILTER (WHERE status = 'canceled') AS canceled,
+			COUNT(*) FILTER (WHERE status IN ('authorized', 'canceled') AND payload->>'ambiente' = 'producao') AS billable,
+			COALESCE(SUM(v_nf) FILTER (WHERE status = 'authorized'), 0) AS valor
+		`).
+		Joins("LEFT JOIN companies ON companies.id = nfce_requests.company_id").
+		Where("nfce_requests.company_id IS NOT NULL").
+		Group("nfce_requests.company_id").
+		Order("total DESC, company_id ASC").
+		Scan(&companies).Error
+	return companies, err
+}
+
 // CreateEvent creates an event for NFC-e tracking (alias for AppendEvent)
 func (r *nfceRepository) CreateEvent(ctx context.Context, event *entity.Event) error {
 	return r.AppendEvent(ctx, event)
 }
 
-// GetPendingRetries gets NFC-e requests that are due for retry
-func (r *nfceRepository) GetPendingRetries(ctx context.Context, beforeTime time.Time, limit int) ([]*entity.NFCE, error) {
+// ClaimDueRetries moves the retries due before beforeTime, nearest to the 48h retry
+// cutoff (oldest) first, to processing and returns them. Rows locked by another worker
+// are skipped, so concurrent workers never claim the same retry.
+func (r *nfceRepository) ClaimDueRetries(ctx context.Context, beforeTime time.Time, limit int) ([]*entity.NFCE, error) {
 	var requests []*entity.NFCE
-	err := r.db.WithContext(ctx).
-		Omit("Events"). // Prevent GORM from trying to load Events association
-		Where("status = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?",
+	err := dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(`
+			SELECT * FROM nfce_requests
+			WHERE status = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?
+			ORDER BY created_at ASC, next_retry_at ASC
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED`, entity.RequestStatusRetrying, beforeTime, limit).
+			Scan(&requests).Error
+		if err != nil {
+			return err
+		}
+
+		for _, req := range requests {
+			req.Status = entity.RequestStatusProcessing
+			req.NextRetryAt = nil
+			if err := transition(tx, req, &entity.Event{Message: retryClaimedMessage}); err != nil {
+				return fmt.Errorf("failed to claim retry %s: %w", req.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// retryClaimedMessage is the message of the event of a retry moved to processing
+const retryClaimedMessage = "Retentativa iniciada"
+
+// ClaimRetry moves the retry to processing when it is due before beforeTime. It reports
+// false when the retry is not due or another worker claimed it first.
+func (r *nfceRepository) ClaimRetry(ctx context.Context, nfce *entity.NFCE, beforeTime time.Time) (bool, error) {
+	if nfce.Status != entity.RequestStatusRetrying || (nfce.NextRetryAt != nil && nfce.NextRetryAt.After(beforeTime)) {
+		return false, nil
+	}
+
+	claimed := *nfce
+	claimed.Status = entity.RequestStatusProcessing
+	claimed.NextRetryAt = nil
+	err := r.Transition(ctx, &claimed, &entity.Event{Message: retryClaimedMessage})
+	if errors.Is(err, entity.ErrStatusConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	*nfce = claimed
+	return true, nil
+}
+
+// GetRetryBacklog counts the retries due and the requests in flight in a single query
+func (r *nfceRepository) GetRetryBacklog(ctx context.Context, beforeTime time.Time) (*entity.RetryBacklog, error) {
+	var row struct {
+		Due         int
+		InFlight    int
+		OldestDueAt *time.Time
+	}
+	err := dbFromContext(ctx, r.db).Model(&entity.NFCE{}).
+		Select(`
+		COUNT(*) FILTER (WHERE status = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?) as due,
+		COUNT(*) FILTER (WHERE status IN (?, ?)) as in_flight,
+		MIN(next_retry_at) FILTER (WHERE status = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?) as oldest_due_at
+	`, entity.RequestStatusRetrying, beforeTime,
+			entity.RequestStatusPending, entity.RequestStatusProcessing,
 			entity.RequestStatusRetrying, beforeTime).
+		Where("status IN (?, ?, ?)", entity.RequestStatusRetrying, entity.RequestStatusPending, entity.RequestStatusProcessing).
+		Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.RetryBacklog{
+		Due:         row.Due,
+		InFlight:    row.InFlight,
+		OldestDueAt: row.OldestDueAt,
+	}, nil
+}
+
+// ListByLote gets the NFC-e requests of a lote in the order they were requested
+func (r *nfceRepository) ListByLote(ctx context.Context, loteID string) ([]*entity.NFCE, error) {
+	var requests []*entity.NFCE
+	err := dbFromContext(ctx, r.db).
+		Omit("Events").
+		Where("lote_id = ?", loteID).
+		Order("created_at ASC").
+		Find(&requests).Error
+	return requests, err
+}
+
+// GetAwaitingReceipt gets asynchronous lotes whose next receipt query is due
+func (r *nfceRepository) GetAwaitingReceipt(ctx context.Context, beforeTime time.Time, limit int) ([]*entity.NFCE, error) {
+	var requests []*entity.NFCE
+	err := dbFromContext(ctx, r.db).
+		Omit("Events").
+		Where("status = ? AND recibo IS NOT NULL AND recibo <> '' AND next_retry_at IS NOT NULL AND next_retry_at <= ?",
+			entity.RequestStatusProcessing, beforeTime).
+		Limit(limit).
+		Order("next_retry_at ASC").
+		Find(&requests).Error
+	return requests, err
+}
+
+// GetPendingTransmission gets NFC-e issued in offline contingency that SEFAZ has not received yet, oldest first
+func (r *nfceRepository) GetPendingTransmission(ctx context.Context, limit int) ([]*entity.NFCE, error) {
+	var requests []*entity.NFCE
+	err := dbFromContext(ctx, r.db).
+		Omit("Events").
+		Where("status = ?", entity.RequestStatusPendingTransmission).
 		Limit(limit).
-		Order("next_retry_at ASC"). // Order by next_retry_at for priority (oldest first)
+		Order("created_at ASC").
 		Find(&requests).Error
 	return requests, err
 }
 
+// ListPurgeable gets the authorized or canceled NFC-e of the company created before the given
+// time that still have their documents in storage, oldest first
+func (r *nfceRepository) ListPurgeable(ctx context.Context, companyID string, before time.Time, limit int) ([]*entity.NFCE, error) {
+	var requests []*entity.NFCE
+	err := dbFromContext(ctx, r.db).
+		Omit("Events").
+		Where("company_id = ? AND status IN ? AND documents_purged_at IS NULL AND created_at < ?",
+			companyID, []entity.RequestStatus{entity.RequestStatusAuthorized, entity.RequestStatusCanceled}, before).
+		Limit(limit).
+		Order("created_at ASC").
+		Find(&requests).Error
+	return requests, err
+}
+
+// AnonymizeDestinatarios removes the CPF, name and e-mail of the consumers identified by CPF
+// from the payload of the NFC-e of the company created before the given time; consumers
+// identified by CNPJ are companies and are kept
+func (r *nfceRepository) AnonymizeDestinatarios(ctx context.Context, companyID string, before time.Time) (int, error) {
+	result := dbFromContext(ctx, r.db).Model(&entity.NFCE{}).
+		Where("company_id = ? AND created_at < ? AND payload->'destinatario'->>'cpf' IS NOT NULL", companyID, before).
+		Update("payload", gorm.Expr("payload #- '{destinatario,cpf}' #- '{destinatario,nome}' #- '{destinatario,email}'"))
+	return int(result.RowsAffected), result.Error
+}
+
+// CountByTerminalSince counts the NFC-e requests of an issuer terminal created since the given time
+func (r *nfceRepository) CountByTerminalSince(ctx context.Context, cnpj, terminal string, since time.Time) (int, error) {
+	var count int64
+	err := dbFromContext(ctx, r.db).Model(&entity.NFCE{}).
+		Where("payload->'emitente'->>'cnpj' = ? AND COALESCE(payload->>'terminal', '') = ? AND created_at >= ?",
+			cnpj, terminal, since).
+		Count(&count).Error
+	return int(count), err
+}
+
+// CountIdenticalSince counts the NFC-e requests of the issuer with the same items and payments created since the given time
+func (r *nfceRepository) CountIdenticalSince(ctx context.Context, payload entity.EmitPayload, since time.Time) (int, error) {
+	itens, err := json.Marshal(payload.Itens)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal items: %w", err)
+	}
+	pagamentos, err := json.Marshal(payload.Pagamentos)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal payments: %w", err)
+	}
+
+	// jsonb equality ignores key order and formatting
+	var count int64
+	err = dbFromContext(ctx, r.db).Model(&entity.NFCE{}).
+		Where("payload->'emitente'->>'cnpj' = ? AND payload->'itens' = ?::jsonb AND payload->'pagamentos' = ?::jsonb AND created_at >= ?",
+			payload.Emitente.CNPJ, string(itens), string(pagamentos), since).
+		Count(&count).Error
+	return int(count), err
+}
+
 // GetEventsByRequestID gets events for a specific NFC-e request
 func (r *nfceRepository) GetEventsByRequestID(ctx context.Context, requestID string, limit, offset int) ([]*entity.Event, error) {
 	var events []*entity.Event
-	err := r.db.WithContext(ctx).Where("request_id = ?", requestID).Limit(limit).Offset(offset).Order("created_at DESC").Find(&events).Error
+	err := dbFromContext(ctx, r.db).Where("request_id = ?", requestID).Limit(limit).Offset(offset).Order("created_at DESC").Find(&events).Error
+	return events, err
+}
+
+// ListEvents returns every event of the NFC-e request in sequence order
+func (r *nfceRepository) ListEvents(ctx context.Context, requestID string) ([]*entity.Event, error) {
+	var events []*entity.Event
+	err := dbFromContext(ctx, r.db).Where("request_id = ?", requestID).Order("sequence ASC, created_at ASC").Find(&events).Error
+	return events, err
+}
+
+// ListEventsByRequestIDs returns the events of the requests, each in sequence order
+func (r *nfceRepository) ListEventsByRequestIDs(ctx context.Context, requestIDs []string) ([]*entity.Event, error) {
+	var events []*entity.Event
+	err := dbFromContext(ctx, r.db).Where("request_id IN ?", requestIDs).Order("request_id, sequence ASC, created_at ASC").Find(&events).Error
 	return events, err
 }
+
+// ListAfter lists up to limit NFC-e requests of any company after the given ID, in ID order
+func (r *nfceRepository) ListAfter(ctx context.Context, afterID string, limit int) ([]*entity.NFCE, error) {
+	var requests []*entity.NFCE
+	err := dbFromContext(ctx, r.db).Where("id::text > ?", afterID).Order("id::text ASC").Limit(limit).Find(&requests).Error
+	return requests, err
+}
+
+// SaveProjection saves the NFC-e rebuilt from its events while the row is still at version
+func (r *nfceRepository) SaveProjection(ctx context.Context, nfce *entity.NFCE, version int) error {
+	nfce.UpdatedAt = time.Now()
+	result := dbFromContext(ctx, r.db).Model(nfce).
+		Where("version = ?", version).
+		Select("*").Omit("id", "created_at", "Events").
+		Updates(nfce)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrStatusConflict
+	}
+	return nil
+}
+
+// GetCompanyEventsAfter gets the events of a company created after the given event, oldest first
+func (r *nfceRepository) GetCompanyEventsAfter(ctx context.Context, companyID, afterEventID string, limit int) ([]*entity.Event, error) {
+	var anchor entity.Event
+	if err := dbFromContext(ctx, r.db).First(&anchor, "id = ?", afterEventID).Error; err != nil {
+		return nil, err
+	}
+
+	var events []*entity.Event
+	err := dbFromContext(ctx, r.db).
+		Where("request_id IN (?)", r.db.Model(&entity.NFCE{}).Select("id").Where("company_id = ?", companyID)).
+		Where("created_at > ?", anchor.CreatedAt).
+		Limit(limit).
+		Order("created_at ASC").
+		Find(&events).Error
+	return events, err
+}
+
+// exportableStatuses are the NFC-e statuses that have an XML to export
+var exportableStatuses = []entity.RequestStatus{
+	entity.RequestStatusAuthorized,
+	entity.RequestStatusContingency,
+	entity.RequestStatusCanceled,
+}
+
+// CountForExport counts the exportable NFC-e of a company authorized within the period
+func (r *nfceRepository) CountForExport(ctx context.Context, companyID string, from, to time.Time) (int, error) {
+	var count int64
+	err := replicaFromContext(ctx, r.db).Model(&entity.NFCE{}).
+		Where("company_id = ? AND status IN ? AND authorized_at >= ? AND authorized_at < ?",
+			companyID, exportableStatuses, from, to).
+		Count(&count).Error
+	return int(count), err
+}
+
+// GetUsageCounts counts the NFC-e of a company created within the period by current status and environment
+func (r *nfceRepository) GetUsageCounts(ctx context.Context, companyID string, from, to time.Time) ([]entity.NFCeUsageCount, error) {
+	var counts []entity.NFCeUsageCount
+	err := dbFromContext(ctx, r.db).Model(&entity.NFCE{}).
+		Select("status, COALESCE(payload->>'ambiente', '') AS ambiente, COUNT(*) AS count").
+		Where("company_id = ? AND created_at >= ? AND created_at < ?", companyID, from, to).
+		Group("status, payload->>'ambiente'").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// ListForExport lists the exportable NFC-e of a company authorized within the period, in a stable order
+func (r *nfceRepository) ListForExport(ctx context.Context, companyID string, from, to time.Time, limit, offset int) ([]*entity.NFCE, error) {
+	var requests []*entity.NFCE
+	err := replicaFromContext(ctx, r.db).
+		Omit("Events").
+		Where("company_id = ? AND status IN ? AND authorized_at >= ? AND authorized_at < ?",
+			companyID, exportableStatuses, from, to).
+		Order("authorized_at ASC, id ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&requests).Error
+	return requests, err
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// Outbox repository implementation
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) ports.OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// Create writes the message, within the caller's transaction when there is one
+func (r *outboxRepository) Create(ctx context.Context, msg *entity.OutboxMessage) error {
+	return dbFromContext(ctx, r.db).Create(msg).Error
+}
+
+// ClaimPending pushes the availability of the oldest pending messages past the lease and
+// returns them, oldest first. Rows locked by another relay are skipped.
+func (r *outboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*entity.OutboxMessage, error) {
+	now := time.Now()
+	var messages []*entity.OutboxMessage
+	err := dbFromContext(ctx, r.db).Raw(`
+		UPDATE outbox_messages SET available_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox_messages
+			WHERE sent_at IS NULL AND available_at <= ?
+			ORDER BY created_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, now.Add(lease), now, limit).
+		Scan(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	return messages, nil
+}
+
+func (r *outboxRepository) MarkSent(ctx context.Context, id string, sentAt time.Time) error {
+	return dbFromContext(ctx, r.db).Model(&entity.OutboxMessage{}).
+		Where("id = ?", id).
+		Update("sent_at", sentAt).Error
+}
+
+// MarkFailed counts the attempt and keeps the message pending until availableAt
+func (r *outboxRepository) MarkFailed(ctx context.Context, id string, reason string, availableAt time.Time) error {
+	return dbFromContext(ctx, r.db).Model(&entity.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":     gorm.Expr("attempts + 1"),
+			"last_error":   reason,
+			"available_at": availableAt,
+		}).Error
+}
+
+// DeleteSentBefore removes a batch of sent messages, oldest first
+func (r *outboxRepository) DeleteSentBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result := dbFromContext(ctx, r.db).Exec(
+		`DELETE FROM outbox_messages WHERE id IN (SELECT id FROM outbox_messages WHERE sent_at <= ? ORDER BY sent_at LIMIT ?)`,
+		before, limit)
+	return result.RowsAffected, result.Error
+}
@@ -18,12 +18,12 @@ func NewPlanRepository(db *gorm.DB) ports.PlanRepository {
 }
 
 func (r *planRepository) Create(ctx context.Context, plan *entity.Plan) error {
-	return r.db.WithContext(ctx).Create(plan).Error
+	return dbFromContext(ctx, r.db).Create(plan).Error
 }
 
 func (r *planRepository) GetByID(ctx context.Context, id string) (*entity.Plan, error) {
 	var plan entity.Plan
-	err := r.db.WithContext(ctx).First(&plan, "id = ?", id).Error
+	err := dbFromContext(ctx, r.db).First(&plan, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -31,14 +31,14 @@ func (r *planRepository) GetByID(ctx context.Context, id string) (*entity.Plan,
 }
 
 func (r *planRepository) Update(ctx context.Context, plan *entity.Plan) error {
-	return r.db.WithContext(ctx).Save(plan).Error
+	return dbFromContext(ctx, r.db).Save(plan).Error
 }
 
 func (r *planRepository) List(ctx context.Context, limit, offset int) ([]*entity.Plan, int, error) {
 	var plans []*entity.Plan
 	var total int64
 
-	query := r.db.WithContext(ctx).Model(&entity.Plan{})
+	query := dbFromContext(ctx, r.db).Model(&entity.Plan{})
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -49,6 +49,6 @@ func (r *planRepository) List(ctx context.Context, limit, offset int) ([]*entity
 
 func (r *planRepository) Count(ctx context.Context) (int, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&entity.Plan{}).Count(&count).Error
+	err := dbFromContext(ctx, r.db).Model(&entity.Plan{}).Count(&count).Error
 	return int(count), err
 }
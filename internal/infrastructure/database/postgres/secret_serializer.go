@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm/schema"
+)
+
+// secretSerializerName is the serializer of the entity fields tagged gorm:"serializer:secret"
+const secretSerializerName = "secret"
+
+func init() {
+	// Until a cipher is registered the secret columns are read and written as they are
+	RegisterSecretSerializer(nil)
+}
+
+// RegisterSecretSerializer makes GORM seal the secret columns with the cipher on every write
+// and open them on every read, so the entities only ever hold the plaintext. The columns
+// written before encryption are still read as they are.
+func RegisterSecretSerializer(secrets ports.SecretCipher) {
+	schema.RegisterSerializer(secretSerializerName, secretSerializer{secrets: secrets})
+}
+
+// secretSerializer seals the text secret columns and the CSC tokens inside the cscs JSONB,
+// whose IDs and validity stay readable
+type secretSerializer struct {
+	secrets ports.SecretCipher
+}
+
+// Scan implements the schema.SerializerInterface
+func (s secretSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored []byte
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		stored = v
+	case string:
+		stored = []byte(v)
+	default:
+		return fmt.Errorf("secret column %s: unsupported value %T", field.DBName, dbValue)
+	}
+
+	fieldValue := reflect.New(field.FieldType)
+	switch target := fieldValue.Interface().(type) {
+	case *string:
+		plaintext, err := s.open(string(stored))
+		if err != nil {
+			return fmt.Errorf("secret column %s: %w", field.DBName, err)
+		}
+		*target = plaintext
+	case *entity.CSCEntries:
+		if len(stored) > 0 {
+			if err := target.Scan(stored); err != nil {
+				return err
+			}
+		}
+		for i := range *target {
+			token, err := s.open((*target)[i].CSCToken)
+			if err != nil {
+				return fmt.Errorf("secret column %s: CSC %s: %w", field.DBName, (*target)[i].ID, err)
+			}
+			(*target)[i].CSCToken = token
+		}
+	default:
+		return fmt.Errorf("secret column %s: unsupported type %s", field.DBName, field.FieldType)
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// Value implements the schema.SerializerValuerInterface
+func (s secretSerializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	switch v := fieldValue.(type) {
+	case string:
+		stored, err := s.seal(v)
+		if err != nil {
+			return nil, fmt.Errorf("secret column %s: %w", field.DBName, err)
+		}
+		return stored, nil
+	case entity.CSCEntries:
+		// Sealed on a copy: the caller keeps the plaintext tokens
+		sealed := append(entity.CSCEntries(nil), v...)
+		for i := range sealed {
+			token, err := s.seal(sealed[i].CSCToken)
+			if err != nil {
+				return nil, fmt.Errorf("secret column %s: CSC %s: %w", field.DBName, sealed[i].ID, err)
+			}
+			sealed[i].CSCToken = token
+		}
+		return sealed.Value()
+	}
+	return nil, fmt.Errorf("secret column %s: unsupported type %T", field.DBName, fieldValue)
+}
+
+// seal returns the stored form of a secret
+func (s secretSerializer) seal(plaintext string) (string, error) {
+	if s.secrets == nil {
+		return plaintext, nil
+	}
+	return s.secrets.SealString(plaintext)
+}
+
+// open returns the plaintext of a stored secret
+func (s secretSerializer) open(stored string) (string, error) {
+	if s.secrets == nil {
+		return stored, nil
+	}
+	return s.secrets.OpenString(stored)
+}
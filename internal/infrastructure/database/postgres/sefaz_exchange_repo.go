@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// SEFAZ exchange repository implementation
+type sefazExchangeRepository struct {
+	db *gorm.DB
+}
+
+func NewSEFAZExchangeRepository(db *gorm.DB) ports.SEFAZExchangeRepository {
+	return &sefazExchangeRepository{db: db}
+}
+
+func (r *sefazExchangeRepository) Create(ctx context.Context, exchanges []*entity.SEFAZExchange) error {
+	if len(exchanges) == 0 {
+		return nil
+	}
+	return dbFromContext(ctx, r.db).Create(exchanges).Error
+}
+
+func (r *sefazExchangeRepository) ListByRequestID(ctx context.Context, requestID string) ([]*entity.SEFAZExchange, error) {
+	var exchanges []*entity.SEFAZExchange
+	err := dbFromContext(ctx, r.db).
+		Where("request_id = ?", requestID).
+		Order("created_at").Find(&exchanges).Error
+	return exchanges, err
+}
+
+func (r *sefazExchangeRepository) ListCreatedBefore(ctx context.Context, before time.Time, limit int) ([]*entity.SEFAZExchange, error) {
+	var exchanges []*entity.SEFAZExchange
+	err := dbFromContext(ctx, r.db).
+		Where("created_at < ?", before).
+		Order("created_at").Limit(limit).Find(&exchanges).Error
+	return exchanges, err
+}
+
+func (r *sefazExchangeRepository) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return dbFromContext(ctx, r.db).Delete(&entity.SEFAZExchange{}, "id IN ?", ids).Error
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// Numbering series repository implementation
+type seriesRepository struct {
+	db *gorm.DB
+}
+
+func NewNumberingSeriesRepository(db *gorm.DB) ports.NumberingSeriesRepository {
+	return &seriesRepository{db: db}
+}
+
+func (r *seriesRepository) Create(ctx context.Context, series *entity.NumberingSeries) error {
+	return dbFromContext(ctx, r.db).Create(series).Error
+}
+
+func (r *seriesRepository) GetByID(ctx context.Context, id string) (*entity.NumberingSeries, error) {
+	var series entity.NumberingSeries
+	err := dbFromContext(ctx, r.db).First(&series, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// Update saves the series settings; next_number only moves forward, so a concurrent
+// allocation is never rolled back
+func (r *seriesRepository) Update(ctx context.Context, series *entity.NumberingSeries) error {
+	return dbFromContext(ctx, r.db).Model(series).Updates(map[string]interface{}{
+		"next_number": gorm.Expr("GREATEST(next_number, ?)", series.NextNumber),
+		"active":      series.Active,
+		"updated_at":  series.UpdatedAt,
+	}).Error
+}
+
+func (r *seriesRepository) ListByCompanyID(ctx context.Context, companyID, modelo, ambiente string) ([]*entity.NumberingSeries, error) {
+	var series []*entity.NumberingSeries
+
+	query := dbFromContext(ctx, r.db).Where("company_id = ?", companyID)
+	if modelo != "" {
+		query = query.Where("modelo = ?", modelo)
+	}
+	if ambiente != "" {
+		query = query.Where("ambiente = ?", ambiente)
+	}
+
+	err := query.Order("modelo DESC, ambiente, serie").Find(&series).Error
+	return series, err
+}
+
+func (r *seriesRepository) SetDefault(ctx context.Context, series *entity.NumberingSeries) error {
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&entity.NumberingSeries{}).
+			Where("company_id = ? AND modelo = ? AND ambiente = ? AND is_default AND id <> ?", series.CompanyID, series.Modelo, series.Ambiente, series.ID).
+			Updates(map[string]interface{}{"is_default": false, "updated_at": now}).Error; err != nil {
+			return err
+		}
+
+		series.Default = true
+		series.UpdatedAt = now
+		return tx.Model(series).Updates(map[string]interface{}{"is_default": true, "updated_at": now}).Error
+	})
+}
+
+// FindGaps walks the numbers covered by NFC-e and inutilizações in order, reporting the holes
+// below next_number. Rejected NFC-e do not hold their number, except when the use was denied.
+// Requests without a model in the payload are NFC-e, the only model before the NF-e.
+func (r *seriesRepository) FindGaps(ctx context.Context, series *entity.NumberingSeries, limit int) ([]entity.NumberingGap, error) {
+	var gaps []entity.NumberingGap
+
+	err := dbFromContext(ctx, r.db).Raw(`
+		WITH covered AS (
+			SELECT 0::bigint AS ini, 0::bigint AS fin
+			UNION ALL
+			SELECT numero::bigint, numero::bigint
+			FROM nfce_requests
+			WHERE company_id = @company AND serie = @serie_text AND payload->>'ambiente' = @ambiente
+				AND COALESCE(payload->>'modelo', '65') = @modelo
+				AND numero ~ '^[0-9]+$'
+				AND (status <> @rejected OR cstat IN ('110', '301', '302', '303'))
+			UNION ALL
+			SELECT numero_inicial, numero_final
+			FROM nfce_inutilizacoes
+			WHERE company_id = @company AND modelo = @modelo AND serie = @serie AND ambiente = @ambiente
+				AND status <> @inut_rejected
+			UNION ALL
+			SELECT @next::bigint, @next::bigint
+		), ordered AS (
+			SELECT ini, MAX(fin) OVER (ORDER BY ini, fin ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING) AS prev_fin
+			FROM covered
+		)
+		SELECT @modelo AS modelo, @serie AS serie, @ambiente AS ambiente, prev_fin + 1 AS numero_inicial, ini - 1 AS numero_final
+		FROM ordered
+		WHERE ini > prev_fin + 1
+		ORDER BY ini
+		LIMIT @limit`,
+		map[string]interface{}{
+			"company":       series.CompanyID,
+			"modelo":        series.Modelo,
+			"serie":         series.Serie,
+			"serie_text":    strconv.Itoa(series.Serie),
+			"ambiente":      series.Ambiente,
+			"rejected":      entity.RequestStatusRejected,
+			"inut_rejected": entity.InutilizacaoStatusRejected,
+			"next":          series.NextNumber,
+			"limit":         limit,
+		}).Scan(&gaps).Error
+
+	return gaps, err
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Usage statement repository implementation
+type statementRepository struct {
+	db *gorm.DB
+}
+
+func NewStatementRepository(db *gorm.DB) ports.StatementRepository {
+	return &statementRepository{db: db}
+}
+
+// CreateIfAbsent inserts the statement, reporting false when the period was already generated
+func (r *statementRepository) CreateIfAbsent(ctx context.Context, statement *entity.UsageStatement) (bool, error) {
+	result := dbFromContext(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "company_id"}, {Name: "period"}}, DoNothing: true}).
+		Create(statement)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *statementRepository) GetByPeriod(ctx context.Context, companyID, period string) (*entity.UsageStatement, error) {
+	var statement entity.UsageStatement
+	err := dbFromContext(ctx, r.db).First(&statement, "company_id = ? AND period = ?", companyID, period).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+func (r *statementRepository) ListByCompanyID(ctx context.Context, companyID string, limit, offset int) ([]*entity.UsageStatement, int, error) {
+	var statements []*entity.UsageStatement
+	var total int64
+
+	query := dbFromContext(ctx, r.db).Model(&entity.UsageStatement{}).Where("company_id = ?", companyID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Limit(limit).Offset(offset).Order("period_start DESC").Find(&statements).Error
+	return statements, int(total), err
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"gorm.io/gorm"
+)
+
+// Subscription event repository implementation
+type subscriptionEventRepository struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionEventRepository(db *gorm.DB) ports.SubscriptionEventRepository {
+	return &subscriptionEventRepository{db: db}
+}
+
+func (r *subscriptionEventRepository) Create(ctx context.Context, event *entity.SubscriptionEvent) error {
+	return dbFromContext(ctx, r.db).Create(event).Error
+}
+
+func (r *subscriptionEventRepository) ListUnbilled(ctx context.Context, subscriptionID string) ([]*entity.SubscriptionEvent, error) {
+	var events []*entity.SubscriptionEvent
+	err := dbFromContext(ctx, r.db).
+		Where("subscription_id = ? AND invoice_id IS NULL AND amount <> 0", subscriptionID).
+		Order("created_at").Find(&events).Error
+	return events, err
+}
+
+func (r *subscriptionEventRepository) MarkBilled(ctx context.Context, ids []string, invoiceID string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return dbFromContext(ctx, r.db).Model(&entity.SubscriptionEvent{}).
+		Where("id IN ?", ids).Update("invoice_id", invoiceID).Error
+}
+
+func (r *subscriptionEventRepository) ListByCompanyID(ctx context.Context, companyID string, from, to time.Time) ([]*entity.SubscriptionEvent, error) {
+	var events []*entity.SubscriptionEvent
+	err := dbFromContext(ctx, r.db).
+		Where("company_id = ? AND created_at >= ? AND created_at < ?", companyID, from, to).
+		Order("created_at").Find(&events).Error
+	return events, err
+}
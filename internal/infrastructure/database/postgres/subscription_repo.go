@@ -2,10 +2,12 @@ package postgres
 
 import (
 	"context"
+	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Subscription repository implementation
@@ -18,12 +20,12 @@ func NewSubscriptionRepository(db *gorm.DB) ports.SubscriptionRepository {
 }
 
 func (r *subscriptionRepository) Create(ctx context.Context, subscription *entity.Subscription) error {
-	return r.db.WithContext(ctx).Create(subscription).Error
+	return dbFromContext(ctx, r.db).Create(subscription).Error
 }
 
 func (r *subscriptionRepository) GetByID(ctx context.Context, id string) (*entity.Subscription, error) {
 	var subscription entity.Subscription
-	err := r.db.WithContext(ctx).First(&subscription, "id = ?", id).Error
+	err := dbFromContext(ctx, r.db).First(&subscription, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +34,17 @@ func (r *subscriptionRepository) GetByID(ctx context.Context, id string) (*entit
 
 func (r *subscriptionRepository) GetActiveByCompanyID(ctx context.Context, companyID string) (*entity.Subscription, error) {
 	var subscription entity.Subscription
-	err := r.db.WithContext(ctx).Where("company_id = ? AND status IN ('active', 'trial')", companyID).Order("created_at DESC").First(&subscription).Error
+	err := dbFromContext(ctx, r.db).Where("company_id = ? AND status IN ('active', 'trial')", companyID).Order("created_at DESC").First(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *subscriptionRepository) LockActiveByCompanyID(ctx context.Context, companyID string) (*entity.Subscription, error) {
+	var subscription entity.Subscription
+	err := dbFromContext(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("company_id = ? AND status IN ('active', 'trial')", companyID).Order("created_at DESC").First(&subscription).Error
 	if err != nil {
 		return nil, err
 	}
@@ -40,14 +52,14 @@ func (r *subscriptionRepository) GetActiveByCompanyID(ctx context.Context, compa
 }
 
 func (r *subscriptionRepository) Update(ctx context.Context, subscription *entity.Subscription) error {
-	return r.db.WithContext(ctx).Save(subscription).Error
+	return dbFromContext(ctx, r.db).Save(subscription).Error
 }
 
 func (r *subscriptionRepository) List(ctx context.Context, limit, offset int) ([]*entity.Subscription, int, error) {
 	var subscriptions []*entity.Subscription
 	var total int64
 
-	query := r.db.WithContext(ctx).Model(&entity.Subscription{})
+	query := dbFromContext(ctx, r.db).Model(&entity.Subscription{})
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -58,12 +70,39 @@ func (r *subscriptionRepository) List(ctx context.Context, limit, offset int) ([
 
 func (r *subscriptionRepository) Count(ctx context.Context) (int, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&entity.Subscription{}).Count(&count).Error
+	err := replicaFromContext(ctx, r.db).Model(&entity.Subscription{}).Count(&count).Error
 	return int(count), err
 }
 
 func (r *subscriptionRepository) CountByStatus(ctx context.Context, status entity.SubscriptionStatus) (int, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&entity.Subscription{}).Where("status = ?", status).Count(&count).Error
+	err := replicaFromContext(ctx, r.db).Model(&entity.Subscription{}).Where("status = ?", status).Count(&count).Error
 	return int(count), err
 }
+
+func (r *subscriptionRepository) ListBillingDue(ctx context.Context, now time.Time, limit int) ([]*entity.Subscription, error) {
+	var subscriptions []*entity.Subscription
+	err := dbFromContext(ctx, r.db).
+		Where("status IN ('active', 'trial') AND auto_renew AND billing_amount > 0 AND billing_next_billing_at <= ?", now).
+		Order("billing_next_billing_at").Limit(limit).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *subscriptionRepository) LockByID(ctx context.Context, id string) (*entity.Subscription, error) {
+	var subscription entity.Subscription
+	err := dbFromContext(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).First(&subscription, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *subscriptionRepository) ListLifecycleDue(ctx context.Context, now time.Time, limit int) ([]*entity.Subscription, error) {
+	var subscriptions []*entity.Subscription
+	err := dbFromContext(ctx, r.db).
+		Where("(status = 'trial' AND trial_ends_at <= ?)"+
+			" OR (status IN ('active', 'trial', 'suspended') AND ends_at <= ?)"+
+			" OR (status IN ('active', 'trial') AND usage_period_end <= ?)", now, now, now).
+		Order("updated_at").Limit(limit).Find(&subscriptions).Error
+	return subscriptions, err
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/database"
+	"gorm.io/gorm"
+)
+
+// txKey is the context key holding the active GORM transaction
+type txKey struct{}
+
+// gormTx implements ports.Tx over a GORM transaction
+type gormTx struct {
+	tx *gorm.DB
+}
+
+func (t *gormTx) Commit(ctx context.Context) error {
+	return t.tx.Commit().Error
+}
+
+func (t *gormTx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback().Error
+}
+
+// txManager implements ports.TxManager over GORM
+type txManager struct {
+	db *gorm.DB
+}
+
+// NewTxManager creates a new GORM transaction manager
+func NewTxManager(db *gorm.DB) ports.TxManager {
+	return &txManager{db: db}
+}
+
+// Begin starts a transaction and returns a context carrying it.
+// Repositories called with the returned context take part in the transaction.
+func (m *txManager) Begin(ctx context.Context) (context.Context, ports.Tx, error) {
+	// Nested calls join the outer transaction
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return ctx, &nestedTx{}, nil
+	}
+
+	tx := m.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return ctx, nil, fmt.Errorf("failed to begin transaction: %w", tx.Error)
+	}
+
+	return context.WithValue(ctx, txKey{}, tx), &gormTx{tx: tx}, nil
+}
+
+// WithinTx runs fn inside a transaction, committing on success and rolling back on error or panic
+func (m *txManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	txCtx, tx, err := m.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(txCtx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(txCtx); err != nil {
+		if rbErr := tx.Rollback(txCtx); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(txCtx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// nestedTx is returned when a transaction is already active; the outer owner commits or rolls back
+type nestedTx struct{}
+
+func (t *nestedTx) Commit(ctx context.Context) error {
+	return nil
+}
+
+func (t *nestedTx) Rollback(ctx context.Context) error {
+	return nil
+}
+
+// dbFromContext returns the transaction carried by ctx, or the base connection
+func dbFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// replicaFromContext returns the transaction carried by ctx, or the base connection with its
+// reads routed to the read replicas, for the listing, statistics and export queries that can
+// lag behind the primary
+func replicaFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return database.ReadReplica(db.WithContext(ctx))
+}
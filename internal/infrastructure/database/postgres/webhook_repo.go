@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
@@ -18,12 +19,12 @@ func NewWebhookRepository(db *gorm.DB) ports.WebhookRepository {
 }
 
 func (r *webhookRepository) Create(ctx context.Context, webhook *entity.Webhook) error {
-	return r.db.WithContext(ctx).Create(webhook).Error
+	return dbFromContext(ctx, r.db).Create(webhook).Error
 }
 
 func (r *webhookRepository) GetByID(ctx context.Context, id string) (*entity.Webhook, error) {
 	var webhook entity.Webhook
-	err := r.db.WithContext(ctx).First(&webhook, "id = ?", id).Error
+	err := dbFromContext(ctx, r.db).First(&webhook, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -31,18 +32,18 @@ func (r *webhookRepository) GetByID(ctx context.Context, id string) (*entity.Web
 }
 
 func (r *webhookRepository) Update(ctx context.Context, webhook *entity.Webhook) error {
-	return r.db.WithContext(ctx).Save(webhook).Error
+	return dbFromContext(ctx, r.db).Save(webhook).Error
 }
 
 func (r *webhookRepository) Delete(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Delete(&entity.Webhook{}, "id = ?", id).Error
+	return dbFromContext(ctx, r.db).Delete(&entity.Webhook{}, "id = ?", id).Error
 }
 
 func (r *webhookRepository) List(ctx context.Context, limit, offset int) ([]*entity.Webhook, int, error) {
 	var webhooks []*entity.Webhook
 	var total int64
 
-	query := r.db.WithContext(ctx).Model(&entity.Webhook{})
+	query := dbFromContext(ctx, r.db).Model(&entity.Webhook{})
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -55,7 +56,7 @@ func (r *webhookRepository) ListByCompanyID(ctx context.Context, companyID strin
 	var webhooks []*entity.Webhook
 	var total int64
 
-	query := r.db.WithContext(ctx).Model(&entity.Webhook{}).Where("company_id = ?", companyID)
+	query := dbFromContext(ctx, r.db).Model(&entity.Webhook{}).Where("company_id = ?", companyID)
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -66,6 +67,26 @@ func (r *webhookRepository) ListByCompanyID(ctx context.Context, companyID strin
 
 func (r *webhookRepository) Count(ctx context.Context) (int, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&entity.Webhook{}).Count(&count).Error
+	err := dbFromContext(ctx, r.db).Model(&entity.Webhook{}).Count(&count).Error
 	return int(count), err
 }
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	return dbFromContext(ctx, r.db).Create(delivery).Error
+}
+
+// GetDeliveryStats counts the deliveries to the company webhooks made within the period
+func (r *webhookRepository) GetDeliveryStats(ctx context.Context, companyID string, from, to time.Time) (*entity.WebhookDeliveryStats, error) {
+	var stats entity.WebhookDeliveryStats
+	err := replicaFromContext(ctx, r.db).Model(&entity.WebhookDelivery{}).
+		Select(`COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE webhook_deliveries.succeeded) AS succeeded,
+			COUNT(*) FILTER (WHERE NOT webhook_deliveries.succeeded) AS failed`).
+		Joins("JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id").
+		Where("webhooks.company_id = ? AND webhook_deliveries.created_at >= ? AND webhook_deliveries.created_at < ?", companyID, from, to).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
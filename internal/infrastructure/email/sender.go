@@ -0,0 +1,67 @@
+package email
+
+import (
+	"context"
+	"fmt"
+)
+
+// Providers that deliver the e-mails
+const (
+	ProviderNone = "none"
+	ProviderSMTP = "smtp"
+	ProviderSES  = "ses"
+)
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// Message is an e-mail with an HTML body, its plain text alternative and attachments
+type Message struct {
+	From        string // Address, or "Name <address>"
+	To          []string
+	ReplyTo     string
+	Subject     string
+	HTML        string
+	Text        string
+	Attachments []Attachment
+}
+
+// Sender delivers e-mails through a provider
+type Sender interface {
+	// Send delivers the message and returns the identifier given by the provider, when any
+	Send(ctx context.Context, msg *Message) (string, error)
+	// Provider names the provider, recorded on the send log
+	Provider() string
+}
+
+// Config selects and configures the provider
+type Config struct {
+	Provider string // none | smtp | ses
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	SESRegion          string
+	SESAccessKeyID     string
+	SESSecretAccessKey string
+}
+
+// NewSender creates the sender of the configured provider; nil disables the e-mails
+func NewSender(config Config) (Sender, error) {
+	switch config.Provider {
+	case "", ProviderNone:
+		return nil, nil
+	case ProviderSMTP:
+		return NewSMTPSender(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword), nil
+	case ProviderSES:
+		return NewSESSender(config.SESRegion, config.SESAccessKeyID, config.SESSecretAccessKey), nil
+	default:
+		return nil, fmt.Errorf("unknown e-mail provider %q", config.Provider)
+	}
+}
@@ -0,0 +1,148 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// sesTimeout bounds a SendEmail call
+const sesTimeout = 30 * time.Second
+
+// sesSendPath is the SES v2 SendEmail operation
+const sesSendPath = "/v2/email/outbound-emails"
+
+// sesSender delivers the e-mails through the Amazon SES v2 API, signing the calls with
+// AWS Signature Version 4
+type sesSender struct {
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	endpoint        string
+	client          *http.Client
+}
+
+// NewSESSender creates a sender for Amazon SES in the region; the sender address must be
+// verified on SES
+func NewSESSender(region, accessKeyID, secretAccessKey string) Sender {
+	return &sesSender{
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		endpoint:        fmt.Sprintf("https://email.%s.amazonaws.com", region),
+		client:          &http.Client{Timeout: sesTimeout},
+	}
+}
+
+// Provider names the provider
+func (s *sesSender) Provider() string {
+	return ProviderSES
+}
+
+type sesSendRequest struct {
+	FromEmailAddress string `json:"FromEmailAddress"`
+	Destination      struct {
+		ToAddresses []string `json:"ToAddresses"`
+	} `json:"Destination"`
+	ReplyToAddresses []string `json:"ReplyToAddresses,omitempty"`
+	Content          struct {
+		Raw struct {
+			Data []byte `json:"Data"` // Base64 encoded by encoding/json
+		} `json:"Raw"`
+	} `json:"Content"`
+}
+
+type sesSendResponse struct {
+	MessageID string `json:"MessageId"`
+	Message   string `json:"message"`
+}
+
+// Send delivers the raw MIME message, which carries the attachments
+func (s *sesSender) Send(ctx context.Context, msg *Message) (string, error) {
+	raw, err := msg.Build()
+	if err != nil {
+		return "", err
+	}
+
+	var payload sesSendRequest
+	payload.FromEmailAddress = msg.From
+	payload.Destination.ToAddresses = msg.To
+	if msg.ReplyTo != "" {
+		payload.ReplyToAddresses = []string{msg.ReplyTo}
+	}
+	payload.Content.Raw.Data = raw
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode SES request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+sesSendPath, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create SES request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("SES request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result sesSendResponse
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	json.Unmarshal(respBody, &result)
+
+	if resp.StatusCode != http.StatusOK {
+		if result.Message != "" {
+			return "", fmt.Errorf("SES returned status %d: %s", resp.StatusCode, result.Message)
+		}
+		return "", fmt.Errorf("SES returned status %d", resp.StatusCode)
+	}
+	return result.MessageID, nil
+}
+
+// sign adds the AWS Signature Version 4 headers for the ses service
+func (s *sesSender) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/ses/aws4_request", date, s.region)
+
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := fmt.Sprintf("%s\n%s\n\ncontent-type:%s\nhost:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n\n%s\n%s",
+		req.Method, req.URL.EscapedPath(), req.Header.Get("Content-Type"), req.URL.Host, payloadHash, amzDate, signedHeaders, payloadHash)
+
+	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", amzDate, scope, sha256Hex([]byte(canonicalRequest)))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "ses")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,109 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// smtpTimeout bounds a whole SMTP conversation
+const smtpTimeout = 30 * time.Second
+
+// smtpImplicitTLSPort is the submission port that starts with TLS instead of STARTTLS
+const smtpImplicitTLSPort = 465
+
+// smtpSender delivers the e-mails through an SMTP relay
+type smtpSender struct {
+	host     string
+	port     int
+	username string
+	password string
+}
+
+// NewSMTPSender creates a sender for the SMTP relay. Port 465 uses implicit TLS; other ports
+// upgrade with STARTTLS when the server offers it. Credentials are optional.
+func NewSMTPSender(host string, port int, username, password string) Sender {
+	return &smtpSender{host: host, port: port, username: username, password: password}
+}
+
+// Provider names the provider
+func (s *smtpSender) Provider() string {
+	return ProviderSMTP
+}
+
+// Send delivers the message to the relay
+func (s *smtpSender) Send(ctx context.Context, msg *Message) (string, error) {
+	raw, err := msg.Build()
+	if err != nil {
+		return "", err
+	}
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: s.host}
+	if s.port == smtpImplicitTLSPort {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && s.port != smtpImplicitTLSPort {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return "", fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return "", fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return "", fmt.Errorf("SMTP server refused the sender: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return "", fmt.Errorf("SMTP server refused recipient %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return "", fmt.Errorf("SMTP server refused the message: %w", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return "", fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("SMTP server refused the message: %w", err)
+	}
+
+	// The relay accepted the message once DATA ended; a failed QUIT does not undo it
+	client.Quit()
+	return "", nil
+}
@@ -0,0 +1,104 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"text/template"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// TemplateData is the NFC-e data available to the templates, e.g. {{.Numero}}
+type TemplateData struct {
+	RazaoSocial  string
+	NomeFantasia string
+	CNPJ         string
+	Consumidor   string // Consumer name, empty when not identified
+	Numero       string
+	Serie        string
+	ChaveAcesso  string
+	Protocolo    string
+	Valor        string // Total of the NFC-e, e.g. "10.50"
+	EmitidaEm    string // dd/mm/yyyy hh:mm
+	ConsultaURL  string // Consultation by key of the UF
+	QRCode       string // QR Code URL of the NFC-e
+}
+
+// Default message, used for every template field the company leaves empty
+const (
+	defaultSubject = `NFC-e nº {{.Numero}} - {{if .NomeFantasia}}{{.NomeFantasia}}{{else}}{{.RazaoSocial}}{{end}}`
+
+	defaultText = `Olá{{if .Consumidor}}, {{.Consumidor}}{{end}}!
+
+Segue em anexo o XML e o DANFE da sua NFC-e nº {{.Numero}}, série {{.Serie}}, emitida por {{.RazaoSocial}} em {{.EmitidaEm}} no valor de R$ {{.Valor}}.
+
+Chave de acesso: {{.ChaveAcesso}}
+{{if .QRCode}}Consulte a nota em: {{.QRCode}}
+{{else if .ConsultaURL}}Consulte a nota em {{.ConsultaURL}} pela chave de acesso.
+{{end}}`
+
+	defaultHTML = `<p>Olá{{if .Consumidor}}, {{.Consumidor}}{{end}}!</p>
+<p>Segue em anexo o XML e o DANFE da sua NFC-e nº {{.Numero}}, série {{.Serie}}, emitida por <strong>{{.RazaoSocial}}</strong> em {{.EmitidaEm}} no valor de <strong>R$ {{.Valor}}</strong>.</p>
+<p>Chave de acesso: {{.ChaveAcesso}}</p>
+{{if .QRCode}}<p><a href="{{.QRCode}}">Consulte a nota no site da SEFAZ</a></p>
+{{else if .ConsultaURL}}<p>Consulte a nota em <a href="{{.ConsultaURL}}">{{.ConsultaURL}}</a> pela chave de acesso.</p>
+{{end}}`
+)
+
+// Content is a rendered message
+type Content struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Render renders the company templates over the NFC-e data; tmpl may be nil
+func Render(tmpl *entity.EmailTemplate, data TemplateData) (*Content, error) {
+	subject, html, text := defaultSubject, defaultHTML, defaultText
+	if tmpl != nil {
+		if tmpl.Subject != "" {
+			subject = tmpl.Subject
+		}
+		if tmpl.HTML != "" {
+			html = tmpl.HTML
+		}
+		if tmpl.Text != "" {
+			text = tmpl.Text
+		}
+	}
+
+	content := &Content{}
+	var err error
+	if content.Subject, err = renderText("subject", subject, data); err != nil {
+		return nil, err
+	}
+	if content.Text, err = renderText("text", text, data); err != nil {
+		return nil, err
+	}
+
+	t, err := htmltemplate.New("html").Parse(html)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse e-mail template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render e-mail template: %w", err)
+	}
+	content.HTML = buf.String()
+
+	return content, nil
+}
+
+// renderText renders a plain text template
+func renderText(name, text string, data TemplateData) (string, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse e-mail template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render e-mail template: %w", err)
+	}
+	return buf.String(), nil
+}
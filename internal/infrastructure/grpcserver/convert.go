@@ -0,0 +1,196 @@
+package grpcserver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+	nfcev1 "github.com/joaopaulo-bertoncini/plugnfce-api/proto/nfce/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// statusValues maps the request statuses to the Status enum of the contract
+var statusValues = map[dto.RequestStatus]nfcev1.Status{
+	dto.RequestStatusPending:             nfcev1.Status_STATUS_PENDING,
+	dto.RequestStatusQueuedDeferred:      nfcev1.Status_STATUS_QUEUED_DEFERRED,
+	dto.RequestStatusProcessing:          nfcev1.Status_STATUS_PROCESSING,
+	dto.RequestStatusAuthorized:          nfcev1.Status_STATUS_AUTHORIZED,
+	dto.RequestStatusRejected:            nfcev1.Status_STATUS_REJECTED,
+	dto.RequestStatusContingency:         nfcev1.Status_STATUS_CONTINGENCY,
+	dto.RequestStatusRetrying:            nfcev1.Status_STATUS_RETRYING,
+	dto.RequestStatusCanceled:            nfcev1.Status_STATUS_CANCELED,
+	dto.RequestStatusPendingTransmission: nfcev1.Status_STATUS_PENDING_TRANSMISSION,
+}
+
+// toEmitRequest converts an EmitNFCeRequest to the request of the use case, failing on an
+// amount or quantity that is not a decimal number
+func toEmitRequest(in *nfcev1.EmitNFCeRequest) (dto.EmitNFceRequest, error) {
+	p := amountParser{}
+	req := dto.EmitNFceRequest{
+		UF:       in.GetUf(),
+		Ambiente: in.GetAmbiente(),
+		Emitente: dto.Emitente{
+			CNPJ:     in.GetEmitente().GetCnpj(),
+			IE:       in.GetEmitente().GetIe(),
+			Regime:   in.GetEmitente().GetRegime(),
+			CSCID:    in.GetEmitente().GetCscId(),
+			CSCToken: in.GetEmitente().GetCscToken(),
+		},
+		Options: dto.EmitOptions{
+			Contingencia:  in.GetOptions().GetContingencia(),
+			Sync:          in.GetOptions().GetSync(),
+			DanfeDuasVias: in.GetOptions().GetDanfeDuasVias(),
+		},
+		Metadata: in.GetMetadata(),
+		Terminal: in.GetTerminal(),
+		Frete:    p.amount("frete", in.GetFrete()),
+	}
+	if in.Serie != nil {
+		serie := int(in.GetSerie())
+		req.Serie = &serie
+	}
+
+	for i, item := range in.GetItens() {
+		field := fmt.Sprintf("itens[%d]", i)
+		req.Itens = append(req.Itens, dto.Item{
+			Descricao:  item.GetDescricao(),
+			NCM:        item.GetNcm(),
+			CEST:       item.GetCest(),
+			CFOP:       item.GetCfop(),
+			GTIN:       item.GetGtin(),
+			Valor:      p.amount(field+".valor", item.GetValor()),
+			Quantidade: p.quantity(field+".quantidade", item.GetQuantidade()),
+			Unidade:    item.GetUnidade(),
+			Desconto:   p.amount(field+".desconto", item.GetDesconto()),
+			Outros:     p.amount(field+".outros", item.GetOutros()),
+		})
+	}
+
+	for i, payment := range in.GetPagamentos() {
+		field := fmt.Sprintf("pagamentos[%d]", i)
+		converted := dto.Payment{
+			Forma: payment.GetForma(),
+			Valor: p.amount(field+".valor", payment.GetValor()),
+			Troco: p.amount(field+".troco", payment.GetTroco()),
+		}
+		if cartao := payment.GetCartao(); cartao != nil {
+			converted.Cartao = &dto.CardPayment{
+				TpIntegra:   cartao.GetTpIntegra(),
+				CNPJ:        cartao.GetCnpj(),
+				Bandeira:    cartao.GetBandeira(),
+				Autorizacao: cartao.GetAutorizacao(),
+			}
+		}
+		req.Pagamentos = append(req.Pagamentos, converted)
+	}
+
+	if dest := in.GetDestinatario(); dest != nil {
+		req.Destinatario = &dto.DestinatarioRequest{
+			CPF:   dest.GetCpf(),
+			CNPJ:  dest.GetCnpj(),
+			Nome:  dest.GetNome(),
+			Email: dest.GetEmail(),
+		}
+	}
+	if intermed := in.GetIntermediador(); intermed != nil {
+		req.Intermediador = &dto.IntermediadorRequest{
+			Alias:        intermed.GetAlias(),
+			CNPJ:         intermed.GetCnpj(),
+			Nome:         intermed.GetNome(),
+			IdCadIntTran: intermed.GetIdCadIntTran(),
+		}
+	}
+
+	return req, p.err
+}
+
+// amountParser parses the decimal strings of a request, keeping the first error
+type amountParser struct {
+	err error
+}
+
+// amount parses an amount with two decimal places, zero when empty
+func (p *amountParser) amount(field, value string) money.Amount {
+	value = strings.TrimSpace(value)
+	if value == "" || p.err != nil {
+		return 0
+	}
+	amount, err := money.Parse(value)
+	if err != nil {
+		p.err = fmt.Errorf("%s: %w", field, err)
+	}
+	return amount
+}
+
+// quantity parses a quantity with up to four decimal places, zero when empty
+func (p *amountParser) quantity(field, value string) money.Quantity {
+	value = strings.TrimSpace(value)
+	if value == "" || p.err != nil {
+		return 0
+	}
+	quantity, err := money.ParseQuantity(value)
+	if err != nil {
+		p.err = fmt.Errorf("%s: %w", field, err)
+	}
+	return quantity
+}
+
+// toNFCe converts an NFC-e of the use case to the contract
+func toNFCe(in *dto.NFceResponse) *nfcev1.NFCe {
+	out := &nfcev1.NFCe{
+		Id:                in.ID,
+		IdempotencyKey:    in.IdempotencyKey,
+		Status:            statusValues[in.Status],
+		ChaveAcesso:       in.ChaveAcesso,
+		Protocolo:         in.Protocolo,
+		Numero:            in.Numero,
+		Serie:             in.Serie,
+		Valor:             in.Valor.String(),
+		RejectionCode:     in.RejectionCode,
+		RejectionMessage:  in.RejectionMsg,
+		RetryCount:        int32(in.RetryCount),
+		NextRetryAt:       timestampOf(in.NextRetryAt),
+		CreatedAt:         timestamppb.New(in.CreatedAt),
+		UpdatedAt:         timestamppb.New(in.UpdatedAt),
+		DocumentsPurgedAt: timestampOf(in.DocumentsPurgedAt),
+		Metadata:          in.Metadata,
+		DelayedProcessing: in.DelayedProcessing,
+		Notice:            in.Notice,
+	}
+	if in.Links != (dto.NFceLinks{}) {
+		out.Links = &nfcev1.Links{Xml: in.Links.XML, Pdf: in.Links.PDF, QrCode: in.Links.QrCode}
+	}
+	for _, schemaErr := range in.SchemaErrors {
+		out.SchemaErrors = append(out.SchemaErrors, &nfcev1.SchemaError{
+			Path:    schemaErr.Path,
+			Field:   schemaErr.Field,
+			Line:    int32(schemaErr.Line),
+			Message: schemaErr.Message,
+			Detail:  schemaErr.Detail,
+		})
+	}
+	return out
+}
+
+// toStatusEvent converts a status change of the event stream to the contract
+func toStatusEvent(in dto.NFceEventResponse) *nfcev1.WatchStatusResponse {
+	return &nfcev1.WatchStatusResponse{Event: &nfcev1.WatchStatusResponse_Status{Status: &nfcev1.StatusEvent{
+		Id:         in.ID,
+		RequestId:  in.RequestID,
+		StatusFrom: statusValues[in.StatusFrom],
+		StatusTo:   statusValues[in.StatusTo],
+		Cstat:      in.CStat,
+		Message:    in.Message,
+		CreatedAt:  timestamppb.New(in.CreatedAt),
+	}}}
+}
+
+// timestampOf converts an optional time, nil when unset
+func timestampOf(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,201 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	nfcev1 "github.com/joaopaulo-bertoncini/plugnfce-api/proto/nfce/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// apiKeyMetadata carries the company API key; authorization: Bearer is accepted too
+	apiKeyMetadata = "x-api-key"
+	// requestIDMetadata carries the client correlation ID recorded in the audit log
+	requestIDMetadata = "x-request-id"
+)
+
+// companyIDKey is the context key holding the company of the API key
+type companyIDKey struct{}
+
+// companyIDFrom returns the company authenticated by the interceptors
+func companyIDFrom(ctx context.Context) string {
+	companyID, _ := ctx.Value(companyIDKey{}).(string)
+	return companyID
+}
+
+// unaryInterceptor authenticates the call, takes its rate limit tokens and records the
+// writes in the audit log, like the middlewares of the REST API
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []service.RateLimitScope{service.RateLimitScopeRequests}
+	if info.FullMethod == nfcev1.NFCeService_EmitNFCe_FullMethodName {
+		scopes = append(scopes, service.RateLimitScopeEmissions)
+	}
+	if err := s.rateLimit(ctx, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }, scopes...); err != nil {
+		return nil, err
+	}
+
+	if info.FullMethod != nfcev1.NFCeService_EmitNFCe_FullMethodName || s.audit == nil {
+		return handler(ctx, req)
+	}
+	entry := s.auditEntry(ctx, info.FullMethod)
+	resp, err := handler(service.WithAuditEntry(ctx, entry), req)
+	entry.Status = httpStatusOf(status.Code(err))
+	_ = s.audit.Record(context.WithoutCancel(ctx), entry)
+	return resp, err
+}
+
+// streamInterceptor authenticates the stream and takes a request token when it opens
+func (s *Server) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	if err := s.rateLimit(ctx, ss.SetHeader, service.RateLimitScopeRequests); err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream is a server stream carrying the authenticated company in its context
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context with the authenticated company
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate resolves the company API key of the call metadata into the context
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := firstValue(md, apiKeyMetadata)
+	if key == "" {
+		scheme, token, found := strings.Cut(firstValue(md, "authorization"), " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			key = strings.TrimSpace(token)
+		}
+	}
+	if key == "" {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	companyID, err := s.authenticator.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		if errors.Is(err, usecase.ErrCompanyInactive) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return context.WithValue(ctx, companyIDKey{}, companyID), nil
+}
+
+// rateLimit takes a token of each scope from the company buckets, sending the limit and the
+// remaining tokens of the last one as header metadata. Over a limit the call fails with
+// ResourceExhausted and a RetryInfo detail; a nil limiter or a store failure lets it through.
+func (s *Server) rateLimit(ctx context.Context, setHeader func(metadata.MD) error, scopes ...service.RateLimitScope) error {
+	if s.limiter == nil {
+		return nil
+	}
+
+	companyID := companyIDFrom(ctx)
+	header := metadata.MD{}
+	defer func() {
+		if header.Len() > 0 {
+			_ = setHeader(header)
+		}
+	}()
+	for _, scope := range scopes {
+		decision, err := s.limiter.Allow(ctx, companyID, scope)
+		if err != nil {
+			continue
+		}
+
+		header.Set("x-ratelimit-limit", strconv.Itoa(decision.Limit))
+		header.Set("x-ratelimit-remaining", strconv.Itoa(decision.Remaining))
+		if !decision.Allowed {
+			header.Set("retry-after", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+			return withDetails(status.New(codes.ResourceExhausted, "rate limit exceeded"),
+				&errdetails.RetryInfo{RetryDelay: durationpb.New(decision.RetryAfter)})
+		}
+	}
+	return nil
+}
+
+// auditEntry starts the audit entry of a call made with a company API key
+func (s *Server) auditEntry(ctx context.Context, method string) *entity.AuditEntry {
+	companyID := companyIDFrom(ctx)
+	entry := entity.NewAuditEntry(entity.AuditActorCompany, companyID, "gRPC "+method)
+	entry.CompanyID = &companyID
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	entry.UserAgent = firstValue(md, "user-agent")
+	entry.RequestID = firstValue(md, requestIDMetadata)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		entry.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(entry.IP); err == nil {
+			entry.IP = host
+		}
+	}
+	return entry
+}
+
+// firstValue returns the first value of a metadata key, empty when absent
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// httpStatusOf maps a gRPC code to the HTTP status recorded in the audit log, so the entries
+// of both APIs are filtered alike
+func httpStatusOf(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusUnprocessableEntity
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499 // Client closed request
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
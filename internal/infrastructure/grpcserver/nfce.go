@@ -0,0 +1,202 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/validation"
+	nfcev1 "github.com/joaopaulo-bertoncini/plugnfce-api/proto/nfce/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func init() {
+	// The emissions are validated with the binding rules of the REST API, custom tags included
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		if err := validation.Register(engine); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// nfceService implements the NFCeService of the contract over the NFC-e use case
+type nfceService struct {
+	nfcev1.UnimplementedNFCeServiceServer
+
+	nfceUseCase usecase.NFCeUseCase
+	draining    <-chan struct{} // Closed when the server shuts down, ending the status streams
+}
+
+// EmitNFCe emits an NFC-e, validated with the same rules of POST /api/v1/nfce
+func (s *nfceService) EmitNFCe(ctx context.Context, in *nfcev1.EmitNFCeRequest) (*nfcev1.NFCe, error) {
+	if in.GetIdempotencyKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "idempotency_key is required")
+	}
+	if len(in.GetIdempotencyKey()) > 255 {
+		return nil, status.Error(codes.InvalidArgument, "idempotency_key must have up to 255 characters")
+	}
+
+	req, err := toEmitRequest(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return nil, validationError(err)
+	}
+
+	response, err := s.nfceUseCase.EmitNFce(ctx, companyIDFrom(ctx), in.GetIdempotencyKey(), req)
+	if err != nil {
+		return nil, emitError(err)
+	}
+	return toNFCe(response), nil
+}
+
+// validationError answers a request that failed the payload rules with one field violation,
+// described in Portuguese, per invalid field
+func validationError(err error) error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	violations := &errdetails.BadRequest{}
+	for _, fe := range validationErrors {
+		field := fe.Namespace()
+		if idx := strings.Index(field, "."); idx != -1 {
+			field = field[idx+1:]
+		}
+		violations.FieldViolations = append(violations.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: validation.Message(fe),
+			Reason:      fe.Tag(),
+		})
+	}
+	return withDetails(status.New(codes.InvalidArgument, err.Error()), violations)
+}
+
+// emitError maps the errors of an emission to the status of the call, with the same
+// conditions as the REST API
+func emitError(err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrUnderMaintenance):
+		st := status.New(codes.Unavailable, err.Error())
+		if window := usecase.MaintenanceWindowOf(err); window != nil && window.ExpectedEndAt != nil {
+			if wait := time.Until(*window.ExpectedEndAt); wait > 0 {
+				return withDetails(st, &errdetails.RetryInfo{RetryDelay: durationpb.New(wait)})
+			}
+		}
+		return st.Err()
+	case errors.Is(err, usecase.ErrEmissionBlocked):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrInvalidFiscalCodes):
+		violations := &errdetails.BadRequest{}
+		for _, field := range usecase.FieldErrorsOf(err) {
+			violations.FieldViolations = append(violations.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Message,
+				Reason:      field.Code,
+			})
+		}
+		return withDetails(status.New(codes.InvalidArgument, err.Error()), violations)
+	case errors.Is(err, usecase.ErrFeatureNotInPlan):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, usecase.ErrSubscriptionInactive):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrQuotaExhausted):
+		st := status.New(codes.ResourceExhausted, err.Error())
+		if quota := usecase.QuotaContextOf(err); quota != nil {
+			return withDetails(st, &errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+				Subject:     string(quota.QuotaType),
+				Description: err.Error(),
+			}}})
+		}
+		return st.Err()
+	default:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+// GetStatus returns an NFC-e of the company
+func (s *nfceService) GetStatus(ctx context.Context, in *nfcev1.GetStatusRequest) (*nfcev1.NFCe, error) {
+	if in.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	response, err := s.nfceUseCase.GetNFceByID(ctx, companyIDFrom(ctx), in.GetId())
+	if err != nil {
+		if errors.Is(err, usecase.ErrNFCeNotFound) {
+			return nil, status.Error(codes.NotFound, "NFC-e not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to get NFC-e")
+	}
+	return toNFCe(response), nil
+}
+
+// WatchStatus streams the status changes of the company NFC-e, replaying the ones missed since
+// last_event_id, until the client cancels or the server shuts down
+func (s *nfceService) WatchStatus(in *nfcev1.WatchStatusRequest, stream grpc.ServerStreamingServer[nfcev1.WatchStatusResponse]) error {
+	ctx := stream.Context()
+	events, err := s.nfceUseCase.StreamNFceEvents(ctx, companyIDFrom(ctx), in.GetLastEventId())
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer events.Close()
+
+	watched := make(map[string]bool, len(in.GetRequestIds()))
+	for _, id := range in.GetRequestIds() {
+		watched[id] = true
+	}
+
+	if events.Reset {
+		resync := &nfcev1.WatchStatusResponse{Event: &nfcev1.WatchStatusResponse_Resync{Resync: &nfcev1.Resync{
+			Message: "last event not found, reload current state",
+		}}}
+		if err := stream.Send(resync); err != nil {
+			return err
+		}
+	}
+	for _, event := range events.Replay {
+		if len(watched) > 0 && !watched[event.RequestID] {
+			continue
+		}
+		if err := stream.Send(toStatusEvent(event)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.draining:
+			return status.Error(codes.Unavailable, "server shutting down, resume with last_event_id")
+		case event, ok := <-events.Events:
+			if !ok {
+				return nil
+			}
+			if len(watched) > 0 && !watched[event.RequestID] {
+				continue
+			}
+			if err := stream.Send(toStatusEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// withDetails attaches error details to a status, falling back to the bare status
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
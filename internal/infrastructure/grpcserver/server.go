@@ -0,0 +1,126 @@
+// Package grpcserver serves the NFC-e emission over gRPC for the POS gateways, alongside the
+// REST API and over the same use cases. The contract is proto/nfce/v1/nfce.proto.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+	nfcev1 "github.com/joaopaulo-bertoncini/plugnfce-api/proto/nfce/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Authenticator resolves the company of an API key
+type Authenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (string, error)
+}
+
+// RateLimiter takes a token of the company bucket of a scope
+type RateLimiter interface {
+	Allow(ctx context.Context, companyID string, scope service.RateLimitScope) (service.RateLimitDecision, error)
+}
+
+// AuditRecorder stores the audit entries of the emissions
+type AuditRecorder interface {
+	Record(ctx context.Context, entry *entity.AuditEntry) error
+}
+
+// Config holds the listener settings of the gRPC server
+type Config struct {
+	Port string
+	// TLS certificate and key files; plaintext when empty, e.g. behind a TLS terminating proxy
+	TLSCertFile string
+	TLSKeyFile  string
+}
+
+// Server serves the NFCeService
+type Server struct {
+	server        *grpc.Server
+	config        Config
+	logger        logger.Logger
+	authenticator Authenticator
+	limiter       RateLimiter
+	audit         AuditRecorder
+	draining      chan struct{}
+	drainOnce     sync.Once
+}
+
+// NewServer creates the gRPC server of the NFC-e use case. A nil limiter disables the rate
+// limiting and a nil recorder the audit log.
+func NewServer(nfceUseCase usecase.NFCeUseCase, authenticator Authenticator, limiter RateLimiter, audit AuditRecorder, config Config, l logger.Logger) (*Server, error) {
+	s := &Server{
+		config:        config,
+		logger:        l,
+		authenticator: authenticator,
+		limiter:       limiter,
+		audit:         audit,
+		draining:      make(chan struct{}),
+	}
+
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+		// Pings keep the status streams open through the NATs and proxies of the stores
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: 30 * time.Second, Timeout: 10 * time.Second}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: 10 * time.Second, PermitWithoutStream: true}),
+	}
+	if config.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load gRPC TLS certificate: %w", err)
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+
+	s.server = grpc.NewServer(options...)
+	nfcev1.RegisterNFCeServiceServer(s.server, &nfceService{nfceUseCase: nfceUseCase, draining: s.draining})
+	return s, nil
+}
+
+// Start listens on the port and serves in the background, so a busy port fails the startup
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", ":"+s.config.Port)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC: %w", err)
+	}
+
+	s.logger.Info("Starting gRPC server",
+		logger.Field{Key: "port", Value: s.config.Port},
+		logger.Field{Key: "tls", Value: s.config.TLSCertFile != ""})
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			s.logger.Error("gRPC server failed", logger.Field{Key: "error", Value: err.Error()})
+		}
+	}()
+	return nil
+}
+
+// Stop ends the status streams and waits for the in-flight calls until ctx ends, then closes
+// the remaining connections
+func (s *Server) Stop(ctx context.Context) error {
+	s.drainOnce.Do(func() { close(s.draining) })
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
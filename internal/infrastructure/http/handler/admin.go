@@ -1,15 +1,30 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
 )
 
-// AdminHandler manages HTTP requests related to admin operations
+const (
+	// statsDefaultDays is the period of the dashboard when from is not given
+	statsDefaultDays = 30
+	// statsMaxRange caps the period of the dashboard queries
+	statsMaxRange = 366 * 24 * time.Hour
+)
+
+// AdminHandler manages HTTP requests related to admin operations; plans, subscriptions and
+// webhooks have their own handlers
 type AdminHandler struct {
-	// TODO: Add admin use cases
+	adminUseCase usecase.AdminUseCase
+	authUseCase  usecase.AuthUseCase
 }
 
 // AdminHandlerInterface defines admin handler methods
@@ -18,138 +33,210 @@ type AdminHandlerInterface interface {
 	ListCompanies(c *gin.Context)
 	GetCompany(c *gin.Context)
 	UpdateCompany(c *gin.Context)
-	UpdateCompanyCertificate(c *gin.Context)
-	UpdateCompanyCSC(c *gin.Context)
-	CreatePlan(c *gin.Context)
-	ListPlans(c *gin.Context)
-	GetPlan(c *gin.Context)
-	UpdatePlan(c *gin.Context)
-	ArchivePlan(c *gin.Context)
-	CreateSubscription(c *gin.Context)
-	ListSubscriptions(c *gin.Context)
-	GetSubscription(c *gin.Context)
-	UpdateSubscription(c *gin.Context)
-	CancelSubscription(c *gin.Context)
-	CreateWebhook(c *gin.Context)
-	ListWebhooks(c *gin.Context)
-	GetWebhook(c *gin.Context)
-	UpdateWebhook(c *gin.Context)
-	DeleteWebhook(c *gin.Context)
+	DeleteCompany(c *gin.Context)
 	ListNFCE(c *gin.Context)
+	DownloadSEFAZExchanges(c *gin.Context)
+	ReprocessNFCe(c *gin.Context)
 	GetStats(c *gin.Context)
 }
 
 // NewAdminHandler creates a new AdminHandler
-func NewAdminHandler(adminUseCase usecase.AdminUseCase) *AdminHandler {
-	return &AdminHandler{}
+func NewAdminHandler(adminUseCase usecase.AdminUseCase, authUseCase usecase.AuthUseCase) *AdminHandler {
+	return &AdminHandler{
+		adminUseCase: adminUseCase,
+		authUseCase:  authUseCase,
+	}
 }
 
-// TODO: Implement all admin handler methods
+// CreateCompany registers a company; its certificate and CSC are uploaded afterwards
 func (h *AdminHandler) CreateCompany(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+	var req dto.CreateCompanyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
 
-func (h *AdminHandler) ListCompanies(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+	company, err := h.adminUseCase.CreateCompany(c.Request.Context(), req)
+	if err != nil {
+		h.respondCompanyError(c, err)
+		return
+	}
 
-func (h *AdminHandler) GetCompany(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	c.JSON(http.StatusCreated, company)
 }
 
-func (h *AdminHandler) UpdateCompany(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+// ListCompanies lists the companies with pagination
+func (h *AdminHandler) ListCompanies(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 100 {
+		respondError(c, http.StatusBadRequest, errInvalidLimit)
+		return
+	}
 
-func (h *AdminHandler) UpdateCompanyCertificate(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		respondError(c, http.StatusBadRequest, errInvalidOffset)
+		return
+	}
 
-func (h *AdminHandler) UpdateCompanyCSC(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+	response, err := h.adminUseCase.ListCompanies(c.Request.Context(), limit, offset)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
 
-func (h *AdminHandler) CreatePlan(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	c.JSON(http.StatusOK, gin.H{
+		"data":   response.Companies,
+		"total":  response.Total,
+		"limit":  limit,
+		"offset": offset,
+	})
 }
 
-func (h *AdminHandler) ListPlans(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+// GetCompany gets a company by ID
+func (h *AdminHandler) GetCompany(c *gin.Context) {
+	company, err := h.adminUseCase.GetCompany(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondCompanyError(c, err)
+		return
+	}
 
-func (h *AdminHandler) GetPlan(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	c.JSON(http.StatusOK, company)
 }
 
-func (h *AdminHandler) UpdatePlan(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+// UpdateCompany updates the given fields of a company, e.g. to block it
+func (h *AdminHandler) UpdateCompany(c *gin.Context) {
+	var req dto.UpdateCompanyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
 
-func (h *AdminHandler) ArchivePlan(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+	if err := h.adminUseCase.UpdateCompany(c.Request.Context(), c.Param("id"), req); err != nil {
+		h.respondCompanyError(c, err)
+		return
+	}
 
-func (h *AdminHandler) CreateSubscription(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	c.JSON(http.StatusOK, gin.H{"message": "company updated successfully"})
 }
 
-func (h *AdminHandler) ListSubscriptions(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+// DeleteCompany offboards a company (LGPD), answering with what was revoked, anonymized and
+// scheduled for deletion
+func (h *AdminHandler) DeleteCompany(c *gin.Context) {
+	response, err := h.adminUseCase.DeleteCompany(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondCompanyError(c, err)
+		return
+	}
 
-func (h *AdminHandler) GetSubscription(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	c.JSON(http.StatusOK, response)
 }
 
-func (h *AdminHandler) UpdateSubscription(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+// ListNFCE lists the NFC-e of every company, or of one when company_id is given, with the
+// filters, sort orders and keyset pagination of the company listing
+func (h *AdminHandler) ListNFCE(c *gin.Context) {
+	filter, limit, ok := bindNFCeListQuery(c)
+	if !ok {
+		return
+	}
 
-func (h *AdminHandler) CancelSubscription(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+	response, err := h.adminUseCase.ListNFCe(c.Request.Context(), c.Query("company_id"), filter, c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidCursor) {
+			respondError(c, http.StatusBadRequest, usecase.ErrInvalidCursor)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, fmt.Errorf("%w: %v", errListNFCes, err))
+		return
+	}
 
-func (h *AdminHandler) CreateWebhook(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	c.JSON(http.StatusOK, response)
 }
 
-func (h *AdminHandler) ListWebhooks(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+// DownloadSEFAZExchanges returns a zip with the SOAP exchanges with SEFAZ archived for the NFC-e
+func (h *AdminHandler) DownloadSEFAZExchanges(c *gin.Context) {
+	file, err := h.adminUseCase.ExportSEFAZExchanges(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
 
-func (h *AdminHandler) GetWebhook(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	streamFile(c, file)
 }
 
-func (h *AdminHandler) UpdateWebhook(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
+// ReprocessNFCe puts a stuck NFC-e back in the emission queue, optionally syncing it with SEFAZ first
+func (h *AdminHandler) ReprocessNFCe(c *gin.Context) {
+	var req dto.ReprocessNFCeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
 
-func (h *AdminHandler) DeleteWebhook(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	response, err := h.adminUseCase.ReprocessNFCe(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response)
 }
 
-func (h *AdminHandler) ListNFCE(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+// respondCompanyError maps the company errors to HTTP status codes
+func (h *AdminHandler) respondCompanyError(c *gin.Context, err error) {
+	respondError(c, http.StatusInternalServerError, err)
 }
 
+// GetStats returns the emission dashboard of the NFC-e created between from and to (RFC 3339,
+// the last 30 days by default), of one company when company_id is given
 func (h *AdminHandler) GetStats(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	filter := ports.NFCeStatsFilter{
+		CompanyID: c.Query("company_id"),
+		To:        time.Now(),
+	}
+	for param, bound := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidDateRange)
+			return
+		}
+		*bound = t
+	}
+	if filter.From.IsZero() {
+		filter.From = filter.To.AddDate(0, 0, -statsDefaultDays)
+	}
+	if !filter.From.Before(filter.To) || filter.To.Sub(filter.From) > statsMaxRange {
+		respondError(c, http.StatusBadRequest, errInvalidStatsRange)
+		return
+	}
+
+	response, err := h.adminUseCase.GetStats(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
-// Login handles admin authentication
+// Login handles admin authentication, returning the token for the admin routes
 func (h *AdminHandler) Login(c *gin.Context) {
-	var req struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
+	var req dto.AdminLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	response, err := h.authUseCase.Login(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	// TODO: Implement admin authentication logic
-	// For now, just return not implemented
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Admin authentication not implemented"})
+	c.JSON(http.StatusOK, response)
 }
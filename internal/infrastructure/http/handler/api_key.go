@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+)
+
+// APIKeyHandler manages HTTP requests related to company API keys, both self-service
+// (/api/v1/api-keys) and by the admins (/api/admin/companies/:id/api-keys)
+type APIKeyHandler struct {
+	authUseCase usecase.AuthUseCase
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler
+func NewAPIKeyHandler(authUseCase usecase.AuthUseCase) *APIKeyHandler {
+	return &APIKeyHandler{
+		authUseCase: authUseCase,
+	}
+}
+
+// List lists the API keys of the authenticated company
+func (h *APIKeyHandler) List(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	h.list(c, companyID)
+}
+
+// Create issues an API key for the authenticated company
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	h.create(c, companyID)
+}
+
+// Rotate replaces an API key of the authenticated company
+func (h *APIKeyHandler) Rotate(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	h.rotate(c, companyID, c.Param("id"))
+}
+
+// Revoke disables an API key of the authenticated company
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	h.revoke(c, companyID, c.Param("id"))
+}
+
+// AdminList lists the API keys of a company
+func (h *APIKeyHandler) AdminList(c *gin.Context) {
+	h.list(c, c.Param("id"))
+}
+
+// AdminCreate issues an API key for a company, typically its first one
+func (h *APIKeyHandler) AdminCreate(c *gin.Context) {
+	h.create(c, c.Param("id"))
+}
+
+// AdminRotate replaces an API key of a company
+func (h *APIKeyHandler) AdminRotate(c *gin.Context) {
+	h.rotate(c, c.Param("id"), c.Param("key_id"))
+}
+
+// AdminRevoke disables an API key of a company
+func (h *APIKeyHandler) AdminRevoke(c *gin.Context) {
+	h.revoke(c, c.Param("id"), c.Param("key_id"))
+}
+
+func (h *APIKeyHandler) list(c *gin.Context, companyID string) {
+	response, err := h.authUseCase.ListAPIKeys(c.Request.Context(), companyID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": response.Keys})
+}
+
+func (h *APIKeyHandler) create(c *gin.Context, companyID string) {
+	// The body is optional: without a name the key is called "default"
+	var req dto.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+
+	key, err := h.authUseCase.IssueAPIKey(c.Request.Context(), companyID, req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *APIKeyHandler) rotate(c *gin.Context, companyID, id string) {
+	response, err := h.authUseCase.RotateAPIKey(c.Request.Context(), companyID, id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *APIKeyHandler) revoke(c *gin.Context, companyID, id string) {
+	if err := h.authUseCase.RevokeAPIKey(c.Request.Context(), companyID, id); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondError maps the API key use case errors to HTTP status codes
+func (h *APIKeyHandler) respondError(c *gin.Context, err error) {
+	respondError(c, http.StatusUnprocessableEntity, err)
+}
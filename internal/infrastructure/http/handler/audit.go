@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+// AuditHandler lets administrators query the audit log of the writes made through the API
+type AuditHandler struct {
+	auditUseCase usecase.AuditUseCase
+}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler(auditUseCase usecase.AuditUseCase) *AuditHandler {
+	return &AuditHandler{
+		auditUseCase: auditUseCase,
+	}
+}
+
+// List returns the audit entries matching the query filters, newest first
+func (h *AuditHandler) List(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	filter := ports.AuditFilter{
+		ActorType:    entity.AuditActorType(c.Query("actor_type")),
+		ActorID:      c.Query("actor_id"),
+		CompanyID:    c.Query("company_id"),
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+	}
+	for param, bound := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidDateRange)
+			return
+		}
+		*bound = &t
+	}
+
+	response, err := h.auditUseCase.List(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   response.Entries,
+		"total":  response.Total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+)
+
+// maxPaymentWebhookSize caps the body of a payment gateway webhook
+const maxPaymentWebhookSize = 1 << 20
+
+// BillingHandler receives the payment webhooks of the gateway charging the subscriptions
+type BillingHandler struct {
+	billingUseCase usecase.BillingUseCase
+}
+
+// NewBillingHandler creates a new BillingHandler
+func NewBillingHandler(billingUseCase usecase.BillingUseCase) *BillingHandler {
+	return &BillingHandler{
+		billingUseCase: billingUseCase,
+	}
+}
+
+// Webhook settles the invoice of a payment confirmed or declined by the gateway. The gateway
+// authenticates with the signature of the body, so the route takes no token; any answer other
+// than 200 makes the gateway deliver the event again.
+func (h *BillingHandler) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPaymentWebhookSize))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errPaymentWebhookRead)
+		return
+	}
+
+	err = h.billingUseCase.HandleWebhook(c.Request.Context(), c.Param("provider"), payload, c.Request.Header)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/cache"
+)
+
+// CacheHandler exposes the hit ratio of the company and certificate cache to administrators
+type CacheHandler struct {
+	cfg     *config.AppConfig
+	metrics *cache.Metrics
+}
+
+// NewCacheHandler creates a new CacheHandler; metrics is nil when no cache is configured
+func NewCacheHandler(cfg *config.AppConfig, metrics *cache.Metrics) *CacheHandler {
+	return &CacheHandler{
+		cfg:     cfg,
+		metrics: metrics,
+	}
+}
+
+// Stats returns the lookups of each cached kind since this API instance started
+func (h *CacheHandler) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"provider": h.cfg.CacheProvider,
+		"ttl":      h.cfg.CacheTTL.String(),
+		"kinds":    h.metrics.Snapshot(),
+	})
+}
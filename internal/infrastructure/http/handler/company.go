@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +14,9 @@ import (
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
 )
 
+// verifyTimeout bounds the company verification, which makes up to three SEFAZ calls
+const verifyTimeout = 2 * time.Minute
+
 // CompanyHandler manages HTTP requests related to company operations
 type CompanyHandler struct {
 	companyUseCase usecase.CompanyUseCase
@@ -21,7 +27,18 @@ type CompanyHandlerInterface interface {
 	UpdateProfile(c *gin.Context)
 	UpdateCertificate(c *gin.Context)
 	UpdateCertificateByID(c *gin.Context)
+	AdminUpdateCertificate(c *gin.Context)
+	ConfigureKMSSigner(c *gin.Context)
+	ConfigureRespTec(c *gin.Context)
+	ClearRespTec(c *gin.Context)
 	UpdateCSC(c *gin.Context)
+	AdminUpdateCSC(c *gin.Context)
+	ListCSCs(c *gin.Context)
+	CreateCSC(c *gin.Context)
+	ReplaceCSC(c *gin.Context)
+	DeleteCSC(c *gin.Context)
+	Verify(c *gin.Context)
+	AdminVerify(c *gin.Context)
 }
 
 // NewCompanyHandler creates a new CompanyHandler
@@ -35,13 +52,13 @@ func NewCompanyHandler(companyUseCase usecase.CompanyUseCase) *CompanyHandler {
 func (h *CompanyHandler) GetProfile(c *gin.Context) {
 	companyID := c.GetString("company_id") // From auth middleware
 	if companyID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
 
 	company, err := h.companyUseCase.GetProfile(c.Request.Context(), companyID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -52,20 +69,20 @@ func (h *CompanyHandler) GetProfile(c *gin.Context) {
 func (h *CompanyHandler) UpdateProfile(c *gin.Context) {
 	companyID := c.GetString("company_id")
 	if companyID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
 
 	var req dto.UpdateCompanyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Get current profile first
 	currentProfile, err := h.companyUseCase.GetProfile(c.Request.Context(), companyID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -88,123 +105,416 @@ func (h *CompanyHandler) UpdateProfile(c *gin.Context) {
 	if req.Status != nil {
 		currentProfile.Status = *req.Status
 	}
+	if req.DANFE != nil {
+		currentProfile.DANFE = *req.DANFE
+	}
+	if req.Intermediadores != nil {
+		currentProfile.Intermediadores = *req.Intermediadores
+	}
+	if req.FraudRules != nil {
+		currentProfile.FraudRules = *req.FraudRules
+	}
+	if req.TaxRules != nil {
+		currentProfile.TaxRules = *req.TaxRules
+	}
 
 	err = h.companyUseCase.UpdateProfile(c.Request.Context(), currentProfile)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "profile updated successfully"})
 }
 
-// UpdateCertificate updates the authenticated company's certificate via file upload
+// UpdateCertificate updates the authenticated company's certificate via upload
 func (h *CompanyHandler) UpdateCertificate(c *gin.Context) {
 	companyID := c.GetString("company_id")
 	if companyID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
 
-	// Process multipart/form-data file upload
-	pfxData, password, expiresAt, err := h.processMultipartUpload(c)
+	h.updateCertificate(c, companyID)
+}
+
+// UpdateCertificateByID updates a company's certificate by ID (admin endpoint) via file upload
+func (h *CompanyHandler) UpdateCertificateByID(c *gin.Context) {
+	companyID := c.Param("id")
+	if companyID == "" {
+		respondError(c, http.StatusBadRequest, errCompanyIDRequired)
+		return
+	}
+
+	// The API key only grants access to its own company
+	if companyID != c.GetString("company_id") {
+		respondError(c, http.StatusForbidden, errForbidden)
+		return
+	}
+
+	h.updateCertificate(c, companyID)
+}
+
+// AdminUpdateCertificate updates the certificate of any company via upload
+func (h *CompanyHandler) AdminUpdateCertificate(c *gin.Context) {
+	h.updateCertificate(c, c.Param("id"))
+}
+
+// updateCertificate replaces the company certificate with the uploaded PFX
+func (h *CompanyHandler) updateCertificate(c *gin.Context, companyID string) {
+	pfxData, password, err := h.readCertificateUpload(c)
+	var invalid *usecase.ValidationError
+	if errors.As(err, &invalid) {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	err = h.companyUseCase.UpdateCertificate(c.Request.Context(), companyID, pfxData, password, expiresAt)
+	err = h.companyUseCase.UpdateCertificate(c.Request.Context(), companyID, pfxData, password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondCertificateError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "certificate updated successfully"})
 }
 
-// UpdateCertificateByID updates a company's certificate by ID (admin endpoint) via file upload
-func (h *CompanyHandler) UpdateCertificateByID(c *gin.Context) {
-	companyID := c.Param("id")
+// ConfigureKMSSigner switches the authenticated company to a key kept in an HSM or cloud KMS
+func (h *CompanyHandler) ConfigureKMSSigner(c *gin.Context) {
+	companyID := c.GetString("company_id")
 	if companyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "company ID is required"})
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.ConfigureKMSSignerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
-	// Process multipart/form-data file upload
-	pfxData, password, expiresAt, err := h.processMultipartUpload(c)
+	err := h.companyUseCase.ConfigureKMSSigner(c.Request.Context(), companyID, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	err = h.companyUseCase.UpdateCertificate(c.Request.Context(), companyID, pfxData, password, expiresAt)
+	c.JSON(http.StatusOK, gin.H{"message": "KMS signer configured successfully"})
+}
+
+// ConfigureRespTec sets the technical responsible of the authenticated company NFC-e
+func (h *CompanyHandler) ConfigureRespTec(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.ConfigureRespTecRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err := h.companyUseCase.ConfigureRespTec(c.Request.Context(), companyID, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "certificate updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "technical responsible configured successfully"})
+}
+
+// ClearRespTec removes the company technical responsible so the installation one is used
+func (h *CompanyHandler) ClearRespTec(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	if err := h.companyUseCase.ClearRespTec(c.Request.Context(), companyID); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "technical responsible removed successfully"})
+}
+
+// ConfigureEmailTemplate sets the message e-mailing the XML and DANFE to the consumer
+func (h *CompanyHandler) ConfigureEmailTemplate(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.EmailTemplateDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err := h.companyUseCase.ConfigureEmailTemplate(c.Request.Context(), companyID, req)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "e-mail template configured successfully"})
+}
+
+// ClearEmailTemplate removes the company message so the default one is used
+func (h *CompanyHandler) ClearEmailTemplate(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	if err := h.companyUseCase.ClearEmailTemplate(c.Request.Context(), companyID); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "e-mail template removed successfully"})
+}
+
+// ConfigureEmissionSettings sets the ide defaults of the authenticated company documents
+func (h *CompanyHandler) ConfigureEmissionSettings(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.EmissionSettingsDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err := h.companyUseCase.ConfigureEmissionSettings(c.Request.Context(), companyID, req)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "emission settings configured successfully"})
+}
+
+// ClearEmissionSettings removes the company emission defaults so the built-in ones are used
+func (h *CompanyHandler) ClearEmissionSettings(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	if err := h.companyUseCase.ClearEmissionSettings(c.Request.Context(), companyID); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "emission settings removed successfully"})
 }
 
-// processMultipartUpload handles file upload via multipart/form-data
-func (h *CompanyHandler) processMultipartUpload(c *gin.Context) ([]byte, string, time.Time, error) {
+// readCertificateUpload reads the PFX and its password, either uploaded as multipart/form-data
+// (pfx_file and password) or sent as JSON with the base64 PFX (cert_pfx_b64 and cert_password)
+func (h *CompanyHandler) readCertificateUpload(c *gin.Context) ([]byte, string, error) {
+	if c.ContentType() == gin.MIMEJSON {
+		var req dto.UpdateCertificateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, "", err
+		}
+		pfxData, err := base64.StdEncoding.DecodeString(req.PFXBase64)
+		if err != nil {
+			return nil, "", &usecase.ValidationError{Field: "cert_pfx_b64", Err: errors.New("must be base64")}
+		}
+		return pfxData, req.Password, nil
+	}
+
 	// Get PFX file
 	file, _, err := c.Request.FormFile("pfx_file")
 	if err != nil {
-		return nil, "", time.Time{}, fmt.Errorf("pfx_file is required")
+		return nil, "", &usecase.ValidationError{Field: "pfx_file", Err: errors.New("is required")}
 	}
 	defer file.Close()
 
 	// Read file data
 	pfxData, err := io.ReadAll(file)
 	if err != nil {
-		return nil, "", time.Time{}, fmt.Errorf("failed to read PFX file: %w", err)
+		return nil, "", fmt.Errorf("failed to read PFX file: %w", err)
 	}
 
 	// Get password
 	password := c.PostForm("password")
 	if password == "" {
-		return nil, "", time.Time{}, fmt.Errorf("password is required")
+		return nil, "", &usecase.ValidationError{Field: "password", Err: errors.New("is required")}
 	}
 
-	// Get expiration date
-	expiresAtStr := c.PostForm("expires_at")
-	if expiresAtStr == "" {
-		return nil, "", time.Time{}, fmt.Errorf("expires_at is required")
+	return pfxData, password, nil
+}
+
+// UpdateCSC updates the company CSC configuration
+func (h *CompanyHandler) UpdateCSC(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	h.updateCSC(c, companyID)
+}
+
+// AdminUpdateCSC updates the CSC configuration of any company
+func (h *CompanyHandler) AdminUpdateCSC(c *gin.Context) {
+	h.updateCSC(c, c.Param("id"))
+}
+
+// updateCSC replaces the company CSC configuration
+func (h *CompanyHandler) updateCSC(c *gin.Context, companyID string) {
+	var req dto.UpdateCompanyCSCRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
 	}
 
-	expiresAt, err := time.Parse(time.RFC3339, expiresAtStr)
+	err := h.companyUseCase.UpdateCSC(c.Request.Context(), companyID, req.CSCID, req.CSCToken, req.ValidUntil)
 	if err != nil {
-		return nil, "", time.Time{}, fmt.Errorf("invalid expires_at format (use RFC3339): %w", err)
+		h.respondCSCError(c, err)
+		return
 	}
 
-	return pfxData, password, expiresAt, nil
+	c.JSON(http.StatusOK, gin.H{"message": "CSC updated successfully"})
 }
 
-// UpdateCSC updates the company CSC configuration
-func (h *CompanyHandler) UpdateCSC(c *gin.Context) {
+// ListCSCs lists the CSC registry of the authenticated company
+func (h *CompanyHandler) ListCSCs(c *gin.Context) {
 	companyID := c.GetString("company_id")
 	if companyID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
 
-	var req struct {
-		CSCID      string    `json:"csc_id"`
-		CSCToken   string    `json:"csc_token"`
-		ValidUntil time.Time `json:"valid_until"`
+	cscs, err := h.companyUseCase.ListCSCs(c.Request.Context(), companyID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"data": cscs})
+}
+
+// CreateCSC registers a CSC for an environment
+func (h *CompanyHandler) CreateCSC(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.CSCEntryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	err := h.companyUseCase.UpdateCSC(c.Request.Context(), companyID, req.CSCID, req.CSCToken, req.ValidUntil)
+	csc, err := h.companyUseCase.CreateCSC(c.Request.Context(), companyID, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondCSCError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "CSC updated successfully"})
+	c.JSON(http.StatusCreated, csc)
+}
+
+// ReplaceCSC replaces a registered CSC
+func (h *CompanyHandler) ReplaceCSC(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.CSCEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	csc, err := h.companyUseCase.ReplaceCSC(c.Request.Context(), companyID, c.Param("id"), req)
+	if err != nil {
+		h.respondCSCError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, csc)
+}
+
+// DeleteCSC removes a CSC from the registry
+func (h *CompanyHandler) DeleteCSC(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	if err := h.companyUseCase.DeleteCSC(c.Request.Context(), companyID, c.Param("id")); err != nil {
+		h.respondCSCError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "CSC removed successfully"})
+}
+
+// Verify runs the pre-flight checks of the authenticated company before its first emission
+func (h *CompanyHandler) Verify(c *gin.Context) {
+	companyID := c.Param("id")
+	if companyID == "" {
+		respondError(c, http.StatusBadRequest, errCompanyIDRequired)
+		return
+	}
+
+	// The API key only grants access to its own company
+	if companyID != c.GetString("company_id") {
+		respondError(c, http.StatusForbidden, errForbidden)
+		return
+	}
+
+	h.verify(c, companyID)
+}
+
+// AdminVerify runs the pre-flight checks of any company
+func (h *CompanyHandler) AdminVerify(c *gin.Context) {
+	h.verify(c, c.Param("id"))
+}
+
+// verify responds with the readiness report of the company
+func (h *CompanyHandler) verify(c *gin.Context, companyID string) {
+	// The SEFAZ calls may outlast the server write timeout
+	setWriteDeadline(c, time.Now().Add(verifyTimeout+10*time.Second))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), verifyTimeout)
+	defer cancel()
+
+	report, err := h.companyUseCase.Verify(ctx, companyID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// respondCertificateError maps the certificate upload errors to HTTP status codes
+func (h *CompanyHandler) respondCertificateError(c *gin.Context, err error) {
+	respondError(c, http.StatusInternalServerError, err)
+}
+
+// respondCSCError maps the CSC registry errors to HTTP status codes
+func (h *CompanyHandler) respondCSCError(c *gin.Context, err error) {
+	respondError(c, http.StatusUnprocessableEntity, err)
 }
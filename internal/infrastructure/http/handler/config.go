@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+)
+
+// ConfigHandler exposes the resolved runtime configuration to administrators
+type ConfigHandler struct {
+	cfg *config.AppConfig
+}
+
+// NewConfigHandler creates a new ConfigHandler
+func NewConfigHandler(cfg *config.AppConfig) *ConfigHandler {
+	return &ConfigHandler{
+		cfg: cfg,
+	}
+}
+
+// Get returns the sanitized configuration, with secrets masked
+func (h *ConfigHandler) Get(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"config": h.cfg.Sanitized()})
+}
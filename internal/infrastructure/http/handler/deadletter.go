@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+)
+
+// DeadLetterHandler lets administrators inspect and requeue the dead-lettered emissions
+type DeadLetterHandler struct {
+	deadLetterUseCase usecase.DeadLetterUseCase
+}
+
+// NewDeadLetterHandler creates a new DeadLetterHandler
+func NewDeadLetterHandler(deadLetterUseCase usecase.DeadLetterUseCase) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		deadLetterUseCase: deadLetterUseCase,
+	}
+}
+
+// List returns the oldest dead-lettered emission messages, which stay in the queue
+func (h *DeadLetterHandler) List(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	response, err := h.deadLetterUseCase.List(c.Request.Context(), limit)
+	if err != nil {
+		respondError(c, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  response.DeadLetters,
+		"total": response.Total,
+	})
+}
+
+// Requeue moves dead-lettered emission messages back to the emission queue
+func (h *DeadLetterHandler) Requeue(c *gin.Context) {
+	var req dto.RequeueDeadLettersRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	response, err := h.deadLetterUseCase.Requeue(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// setWriteDeadline moves the write deadline of the response past the server WriteTimeout,
+// zero for none, for the responses that stream or wait on SEFAZ. A failure is logged: the
+// response is then cut at the WriteTimeout.
+func setWriteDeadline(c *gin.Context, deadline time.Time) {
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(deadline); err != nil {
+		logger.FromContext(c.Request.Context(), nil).Warn("Failed to set the write deadline of the response",
+			logger.Field{Key: "path", Value: c.FullPath()}, logger.Err(err))
+	}
+}
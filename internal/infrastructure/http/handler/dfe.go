@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+// DFeHandler manages HTTP requests related to the NFeDistribuicaoDFe documents
+type DFeHandler struct {
+	dfeUseCase usecase.DFeUseCase
+}
+
+// NewDFeHandler creates a new DFeHandler
+func NewDFeHandler(dfeUseCase usecase.DFeUseCase) *DFeHandler {
+	return &DFeHandler{
+		dfeUseCase: dfeUseCase,
+	}
+}
+
+// Sync requests a distribution sync of the authenticated company
+func (h *DFeHandler) Sync(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	status, err := h.dfeUseCase.RequestSync(c.Request.Context(), companyID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, status)
+}
+
+// Status gets the distribution cursor of the authenticated company
+func (h *DFeHandler) Status(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	status, err := h.dfeUseCase.GetStatus(c.Request.Context(), companyID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// ListDocuments lists the documents distributed to the authenticated company
+func (h *DFeHandler) ListDocuments(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	filter := ports.DFeFilter{
+		Tipo:        entity.DFeDocumentType(c.Query("tipo")),
+		ChaveAcesso: c.Query("chave_acesso"),
+	}
+
+	response, err := h.dfeUseCase.ListDocuments(c.Request.Context(), companyID, filter, limit, offset)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   response.Documents,
+		"total":  response.Total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetDocument gets a document distributed to the authenticated company
+func (h *DFeHandler) GetDocument(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	document, err := h.dfeUseCase.GetDocument(c.Request.Context(), companyID, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, document)
+}
+
+// DownloadXML returns the XML of a document distributed to the authenticated company
+func (h *DFeHandler) DownloadXML(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	content, err := h.dfeUseCase.GetDocumentXML(c.Request.Context(), companyID, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/xml", content)
+}
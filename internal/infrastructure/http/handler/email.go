@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/apierror"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+)
+
+// EmailHandler manages HTTP requests related to the e-mails of the NFC-e documents
+type EmailHandler struct {
+	emailUseCase usecase.EmailUseCase
+}
+
+// NewEmailHandler creates a new EmailHandler
+func NewEmailHandler(emailUseCase usecase.EmailUseCase) *EmailHandler {
+	return &EmailHandler{
+		emailUseCase: emailUseCase,
+	}
+}
+
+// Send e-mails the XML and DANFE of an authorized NFC-e again, to the address of the body
+// or the consumer e-mail of the NFC-e
+func (h *EmailHandler) Send(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	// The body is optional
+	var req dto.SendEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+
+	delivery, err := h.emailUseCase.SendEmail(c.Request.Context(), companyID, c.Param("id"), req)
+	if err != nil {
+		if delivery != nil {
+			// The provider refused the message; the failed attempt is in the send log
+			respondError(c, http.StatusBadGateway, apierror.New(http.StatusBadGateway, i18n.CodeEmailSendFailed, err).With("delivery", delivery))
+			return
+		}
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// List lists the send log of an NFC-e
+func (h *EmailHandler) List(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	response, err := h.emailUseCase.ListEmails(c.Request.Context(), companyID, c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": response.Deliveries})
+}
+
+// respondError maps the e-mail use case errors to HTTP status codes
+func (h *EmailHandler) respondError(c *gin.Context, err error) {
+	respondError(c, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/tables"
+	schemavalidator "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/validator"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/apierror"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/validation"
+)
+
+// Errors of the requests the handlers refuse before reaching a use case
+var (
+	errUnauthorized           = errors.New("unauthorized")
+	errForbidden              = errors.New("forbidden")
+	errIdempotencyKeyRequired = errors.New("Idempotency-Key header is required")
+	errInvalidLimit           = errors.New("limit is out of the allowed range")
+	errInvalidOffset          = errors.New("offset must be >= 0")
+	errInvalidDateRange       = errors.New("from and to must be RFC 3339 date-times")
+	errInvalidStatsRange      = errors.New("from must be before to, at most 366 days apart")
+	errInvalidValorRange      = errors.New("valor_min and valor_max must be amounts in reais")
+	errInvalidDestinatario    = errors.New("destinatario must be a CPF or CNPJ")
+	errInvalidContingency     = errors.New("contingency must be a boolean")
+	errInvalidSort            = errors.New("sort must be created_at or valor")
+	errInvalidSearchQuery     = errors.New("q must have between 1 and 200 characters")
+	errInvalidVias            = errors.New("vias must be 1 or 2")
+	errInvalidCompacto        = errors.New("compacto must be a boolean")
+	errInvalidPapel           = errors.New("papel must be 80mm or 58mm")
+	errInvalidDANFEFormat     = errors.New("format must be pdf or escpos")
+	errInvalidExportFormat    = errors.New("format must be zip")
+	errInvalidExportIndex     = errors.New("index must be a boolean")
+	errInvalidPartNumber      = errors.New("invalid part number")
+	errInvalidSchemaSource    = errors.New("source must be download or bundle")
+	errInvalidGraphQLRequest  = errors.New("invalid GraphQL request")
+	errCompanyIDRequired      = errors.New("company ID is required")
+	errPlanIDRequired         = errors.New("plan ID is required")
+	errSubscriptionIDRequired = errors.New("subscription ID is required")
+	errWebhookIDRequired      = errors.New("webhook ID is required")
+	errPaymentWebhookRead     = errors.New("failed to read payment webhook")
+	errSchemaNotBundled       = errors.New("schema package is not bundled")
+	errGetNFCe                = errors.New("failed to get NFC-e")
+	errListNFCes              = errors.New("failed to list NFC-es")
+	errSearchNFCes            = errors.New("failed to search NFC-es")
+	errGetNFCeEvents          = errors.New("failed to get NFC-e events")
+	errGetLote                = errors.New("failed to get lote")
+)
+
+// errorMappings answers the errors with the same status and code in every handler
+var errorMappings = []apierror.Mapping{
+	{Target: errUnauthorized, Status: http.StatusUnauthorized, Code: i18n.CodeUnauthorized},
+	{Target: errForbidden, Status: http.StatusForbidden, Code: i18n.CodeForbidden},
+	{Target: errIdempotencyKeyRequired, Status: http.StatusBadRequest, Code: i18n.CodeIdempotencyKeyRequired},
+	{Target: errInvalidLimit, Status: http.StatusBadRequest, Code: i18n.CodeInvalidLimit},
+	{Target: errInvalidOffset, Status: http.StatusBadRequest, Code: i18n.CodeInvalidOffset},
+	{Target: errInvalidDateRange, Status: http.StatusBadRequest, Code: i18n.CodeInvalidDateRange},
+	{Target: errInvalidStatsRange, Status: http.StatusBadRequest, Code: i18n.CodeInvalidStatsRange},
+	{Target: errInvalidValorRange, Status: http.StatusBadRequest, Code: i18n.CodeInvalidValorRange},
+	{Target: errInvalidDestinatario, Status: http.StatusBadRequest, Code: i18n.CodeInvalidDestinatario},
+	{Target: errInvalidContingency, Status: http.StatusBadRequest, Code: i18n.CodeInvalidContingency},
+	{Target: errInvalidSort, Status: http.StatusBadRequest, Code: i18n.CodeInvalidSort},
+	{Target: errInvalidSearchQuery, Status: http.StatusBadRequest, Code: i18n.CodeInvalidSearchQuery},
+	{Target: errInvalidVias, Status: http.StatusBadRequest, Code: i18n.CodeInvalidVias},
+	{Target: errInvalidCompacto, Status: http.StatusBadRequest, Code: i18n.CodeInvalidCompacto},
+	{Target: errInvalidPapel, Status: http.StatusBadRequest, Code: i18n.CodeInvalidPapel},
+	{Target: errInvalidDANFEFormat, Status: http.StatusBadRequest, Code: i18n.CodeInvalidDANFEFormat},
+	{Target: errInvalidExportFormat, Status: http.StatusBadRequest, Code: i18n.CodeInvalidExportFormat},
+	{Target: errInvalidExportIndex, Status: http.StatusBadRequest, Code: i18n.CodeInvalidExportIndex},
+	{Target: errInvalidPartNumber, Status: http.StatusBadRequest, Code: i18n.CodeInvalidPartNumber},
+	{Target: errInvalidSchemaSource, Status: http.StatusBadRequest, Code: i18n.CodeInvalidSchemaSource},
+	{Target: errInvalidGraphQLRequest, Status: http.StatusBadRequest, Code: i18n.CodeInvalidGraphQLRequest},
+	{Target: errCompanyIDRequired, Status: http.StatusBadRequest, Code: i18n.CodeCompanyIDRequired},
+	{Target: errPlanIDRequired, Status: http.StatusBadRequest, Code: i18n.CodePlanIDRequired},
+	{Target: errSubscriptionIDRequired, Status: http.StatusBadRequest, Code: i18n.CodeSubscriptionIDRequired},
+	{Target: errWebhookIDRequired, Status: http.StatusBadRequest, Code: i18n.CodeWebhookIDRequired},
+	{Target: errPaymentWebhookRead, Status: http.StatusBadRequest, Code: i18n.CodePaymentWebhookRead},
+	{Target: errSchemaNotBundled, Status: http.StatusConflict, Code: i18n.CodeSchemaNotBundled},
+	{Target: errGetNFCe, Status: http.StatusInternalServerError, Code: i18n.CodeNFCeGetFailed},
+	{Target: errListNFCes, Status: http.StatusInternalServerError, Code: i18n.CodeNFCeListFailed},
+	{Target: errSearchNFCes, Status: http.StatusInternalServerError, Code: i18n.CodeNFCeSearchFailed},
+	{Target: errGetNFCeEvents, Status: http.StatusInternalServerError, Code: i18n.CodeNFCeEventsFailed},
+	{Target: errGetLote, Status: http.StatusInternalServerError, Code: i18n.CodeLoteGetFailed},
+	{Target: usecase.ErrInvalidCursor, Status: http.StatusBadRequest, Code: i18n.CodeInvalidCursor},
+	{Target: usecase.ErrNFCeAlreadyRejected, Status: http.StatusBadRequest, Code: i18n.CodeNFCeAlreadyRejected},
+	{Target: usecase.ErrNFCeNotCancelable, Status: http.StatusBadRequest, Code: i18n.CodeNFCeNotCancelable},
+	{Target: usecase.ErrInvalidPlanChange, Status: http.StatusBadRequest, Code: i18n.CodeInvalidPlanChange},
+	{Target: usecase.ErrInvalidChaveAcesso, Status: http.StatusBadRequest, Code: i18n.CodeInvalidChaveAcesso},
+	{Target: entity.ErrInvalidWebhookFilter, Status: http.StatusBadRequest, Code: i18n.CodeInvalidWebhookFilter},
+	{Target: usecase.ErrInvalidCompany, Status: http.StatusBadRequest, Code: i18n.CodeInvalidCompany},
+	{Target: usecase.ErrInvalidCredentials, Status: http.StatusUnauthorized, Code: i18n.CodeInvalidCredentials},
+	{Target: usecase.ErrInvalidPaymentSignature, Status: http.StatusUnauthorized, Code: i18n.CodePaymentSignature},
+	{Target: usecase.ErrSubscriptionInactive, Status: http.StatusPaymentRequired, Code: i18n.CodeSubscriptionInactive},
+	{Target: usecase.ErrFeatureNotInPlan, Status: http.StatusForbidden, Code: i18n.CodeFeatureNotInPlan},
+	{Target: usecase.ErrNFCeNotFound, Status: http.StatusNotFound, Code: i18n.CodeNFCeNotFound},
+	{Target: usecase.ErrXMLNotFound, Status: http.StatusNotFound, Code: i18n.CodeXMLNotFound},
+	{Target: usecase.ErrPDFNotFound, Status: http.StatusNotFound, Code: i18n.CodePDFNotFound},
+	{Target: usecase.ErrQRCodeNotFound, Status: http.StatusNotFound, Code: i18n.CodeQRCodeNotFound},
+	{Target: usecase.ErrLoteNotFound, Status: http.StatusNotFound, Code: i18n.CodeLoteNotFound},
+	{Target: usecase.ErrCompanyNotFound, Status: http.StatusNotFound, Code: i18n.CodeCompanyNotFound},
+	{Target: usecase.ErrCSCNotFound, Status: http.StatusNotFound, Code: i18n.CodeCSCNotFound},
+	{Target: usecase.ErrAPIKeyNotFound, Status: http.StatusNotFound, Code: i18n.CodeAPIKeyNotFound},
+	{Target: usecase.ErrSubscriptionNotFound, Status: http.StatusNotFound, Code: i18n.CodeSubscriptionNotFound},
+	{Target: usecase.ErrPlanNotFound, Status: http.StatusNotFound, Code: i18n.CodePlanNotFound},
+	{Target: usecase.ErrStatementNotFound, Status: http.StatusNotFound, Code: i18n.CodeStatementNotFound},
+	{Target: usecase.ErrSeriesNotFound, Status: http.StatusNotFound, Code: i18n.CodeSeriesNotFound},
+	{Target: usecase.ErrInutilizacaoNotFound, Status: http.StatusNotFound, Code: i18n.CodeInutilizacaoNotFound},
+	{Target: usecase.ErrExportNotFound, Status: http.StatusNotFound, Code: i18n.CodeExportNotFound},
+	{Target: usecase.ErrExportPartNotFound, Status: http.StatusNotFound, Code: i18n.CodeExportPartNotFound},
+	{Target: usecase.ErrDFeDocumentNotFound, Status: http.StatusNotFound, Code: i18n.CodeDFeDocumentNotFound},
+	{Target: usecase.ErrMaintenanceNotActive, Status: http.StatusNotFound, Code: i18n.CodeMaintenanceNotActive},
+	{Target: usecase.ErrPaymentGatewayNotFound, Status: http.StatusNotFound, Code: i18n.CodePaymentGatewayNotFound},
+	{Target: usecase.ErrWebhookNotFound, Status: http.StatusNotFound, Code: i18n.CodeWebhookNotFound},
+	{Target: usecase.ErrSEFAZExchangesNotFound, Status: http.StatusNotFound, Code: i18n.CodeSEFAZExchangesNotFound},
+	{Target: usecase.ErrNFCeNotAuthorized, Status: http.StatusConflict, Code: i18n.CodeNFCeNotAuthorized},
+	{Target: usecase.ErrNFCeAlreadyAuthorized, Status: http.StatusConflict, Code: i18n.CodeNFCeAlreadyAuthorized},
+	{Target: usecase.ErrNFCeNotReprocessable, Status: http.StatusConflict, Code: i18n.CodeNFCeNotReprocessable},
+	{Target: usecase.ErrCompanyAlreadyExists, Status: http.StatusConflict, Code: i18n.CodeCompanyAlreadyExists},
+	{Target: usecase.ErrCompanyDeleted, Status: http.StatusConflict, Code: i18n.CodeCompanyDeleted},
+	{Target: usecase.ErrAPIKeyRevoked, Status: http.StatusConflict, Code: i18n.CodeAPIKeyRevoked},
+	{Target: usecase.ErrSeriesAlreadyExists, Status: http.StatusConflict, Code: i18n.CodeSeriesAlreadyExists},
+	{Target: usecase.ErrSeriesInactive, Status: http.StatusConflict, Code: i18n.CodeSeriesInactive},
+	{Target: usecase.ErrInutilizacaoOverlap, Status: http.StatusConflict, Code: i18n.CodeInutilizacaoOverlap},
+	{Target: usecase.ErrMaintenanceActive, Status: http.StatusConflict, Code: i18n.CodeMaintenanceActive},
+	{Target: schemavalidator.ErrSchemaUpdateInProgress, Status: http.StatusConflict, Code: i18n.CodeSchemaUpdateInProgress},
+	{Target: usecase.ErrDocumentsPurged, Status: http.StatusGone, Code: i18n.CodeDocumentsPurged},
+	{Target: usecase.ErrExportExpired, Status: http.StatusGone, Code: i18n.CodeExportExpired},
+	{Target: usecase.ErrEmissionBlocked, Status: http.StatusUnprocessableEntity, Code: i18n.CodeEmissionBlocked},
+	{Target: tables.ErrInvalidTables, Status: http.StatusUnprocessableEntity, Code: i18n.CodeInvalidSEFAZTables},
+	{Target: usecase.ErrInvalidFiscalCodes, Status: http.StatusUnprocessableEntity, Code: i18n.CodeInvalidFiscalCodes},
+	{Target: usecase.ErrESCPOSNotAvailable, Status: http.StatusUnprocessableEntity, Code: i18n.CodeESCPOSNotAvailable},
+	{Target: usecase.ErrEmailRecipientMissing, Status: http.StatusUnprocessableEntity, Code: i18n.CodeEmailRecipientMissing},
+	{Target: usecase.ErrCertificateExpired, Status: http.StatusUnprocessableEntity, Code: i18n.CodeCertificateExpired},
+	{Target: usecase.ErrCertificatePassword, Status: http.StatusUnprocessableEntity, Code: i18n.CodeCertificatePassword},
+	{Target: usecase.ErrCertificateUnsupported, Status: http.StatusUnprocessableEntity, Code: i18n.CodeCertificateUnsupported},
+	{Target: usecase.ErrCertificateInvalid, Status: http.StatusUnprocessableEntity, Code: i18n.CodeCertificateInvalid},
+	{Target: usecase.ErrCertificateCNPJMismatch, Status: http.StatusUnprocessableEntity, Code: i18n.CodeCertificateCNPJ},
+	{Target: entity.ErrDFeSyncTooSoon, Status: http.StatusTooManyRequests, Code: i18n.CodeDFeSyncTooSoon},
+	{Target: usecase.ErrQuotaExhausted, Status: http.StatusTooManyRequests, Code: i18n.CodeQuotaExhausted},
+	{Target: usecase.ErrUnderMaintenance, Status: http.StatusServiceUnavailable, Code: i18n.CodeUnderMaintenance},
+	{Target: usecase.ErrEmailDisabled, Status: http.StatusServiceUnavailable, Code: i18n.CodeEmailDisabled},
+	{Target: usecase.ErrEventStreamUnavailable, Status: http.StatusServiceUnavailable, Code: i18n.CodeEventStreamUnavailable},
+	{Target: dto.ErrBrokerUnavailable, Status: http.StatusServiceUnavailable, Code: i18n.CodeBrokerUnavailable},
+}
+
+func init() {
+	// Report the fields of the validation errors by their JSON name, as the client sent them,
+	// and check the Brazilian documents and fiscal codes of the payloads
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(jsonName)
+		if err := validation.Register(engine); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// respondError answers err with the status and code of its mapping, or with fallback when
+// the error is not a domain one. The error is recorded in the gin context, from which the
+// error-handling middleware writes the apierror.Envelope.
+func respondError(c *gin.Context, fallback int, err error) {
+	apiErr := apierror.Classify(err, errorMappings, fallback)
+
+	var blocked *usecase.EmissionBlockedError
+	if errors.As(err, &blocked) {
+		apiErr.Code = blocked.Code
+		if blocked.Detail != "" {
+			apiErr.WithDetails(apierror.Detail{Code: blocked.Check, Message: blocked.Detail})
+		}
+	}
+	var notInPlan *usecase.FeatureNotInPlanError
+	if errors.As(err, &notInPlan) {
+		apiErr.WithArgs(notInPlan.Feature)
+	}
+	// A broken domain rule is a problem of the request, detailed with the rule text
+	var invalid *usecase.ValidationError
+	if errors.As(err, &invalid) {
+		if apiErr.Code == "" {
+			if apiErr.Status >= http.StatusInternalServerError {
+				apiErr.Status = http.StatusUnprocessableEntity
+			}
+			apiErr.Code = i18n.ForStatus(apiErr.Status)
+		}
+		apiErr.WithDetails(apierror.Detail{Field: invalid.Field, Message: invalid.Err.Error()})
+	}
+	for _, field := range usecase.FieldErrorsOf(err) {
+		apiErr.WithDetails(apierror.Detail{Field: field.Field, Code: field.Code, Message: field.Message})
+	}
+	for _, problem := range tables.ProblemsOf(err) {
+		apiErr.WithDetails(apierror.Detail{Message: problem})
+	}
+
+	// The plan quota context lets clients offer an upgrade
+	if quota := usecase.QuotaContextOf(err); quota != nil {
+		apiErr.With("quota_context", quota)
+	}
+	if window := usecase.MaintenanceWindowOf(err); window != nil {
+		maintenance := gin.H{"message": window.Message, "started_at": window.StartedAt}
+		if window.ExpectedEndAt != nil {
+			maintenance["expected_end_at"] = window.ExpectedEndAt
+			if wait := time.Until(*window.ExpectedEndAt); wait > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			}
+		}
+		apiErr.With("maintenance", maintenance)
+	}
+
+	_ = c.Error(apiErr)
+	c.Status(apiErr.Status)
+	c.Abort()
+}
+
+// respondBindError answers a request body that could not be bound with 400, listing the
+// fields that failed validation as details described in Portuguese
+func respondBindError(c *gin.Context, err error) {
+	apiErr := apierror.BadRequest(i18n.CodeInvalidRequest, err)
+
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrors):
+		for _, fe := range validationErrors {
+			apiErr.WithDetails(apierror.Detail{
+				Field:   fieldPath(fe.Namespace()),
+				Code:    fe.Tag(),
+				Message: validation.Message(fe),
+			})
+		}
+	case errors.As(err, &typeError):
+		apiErr.WithDetails(apierror.Detail{
+			Field:   typeError.Field,
+			Code:    "type",
+			Message: fmt.Sprintf("expected %s, got %s", typeError.Type, typeError.Value),
+		})
+	}
+
+	respondError(c, http.StatusBadRequest, apiErr)
+}
+
+// jsonName names a struct field by its json tag, falling back to the Go name
+func jsonName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// fieldPath drops the request struct name from a validator namespace, e.g.
+// "EmitNFceRequest.itens[0].ncm" becomes "itens[0].ncm"
+func fieldPath(namespace string) string {
+	if idx := strings.Index(namespace, "."); idx != -1 {
+		return namespace[idx+1:]
+	}
+	return namespace
+}
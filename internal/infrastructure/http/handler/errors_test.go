@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/middleware"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/apierror"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// TestRespondError answers the typed errors of the use cases through the envelope
+func TestRespondError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		fallback    int
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+		wantDetails []apierror.Detail
+	}{
+		{
+			name:        "mapped sentinel",
+			fallback:    http.StatusInternalServerError,
+			err:         fmt.Errorf("get nfce: %w", usecase.ErrNFCeNotFound),
+			wantStatus:  http.StatusNotFound,
+			wantCode:    "nfce_not_found",
+			wantMessage: "NFC-e not found",
+			wantDetails: []apierror.Detail{},
+		},
+		{
+			name:        "broken rule of a mapped sentinel",
+			fallback:    http.StatusInternalServerError,
+			err:         fmt.Errorf("%w: %w", usecase.ErrInvalidCompany, &usecase.ValidationError{Field: "cnpj", Err: errors.New("invalid CNPJ")}),
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_company",
+			wantMessage: "invalid company",
+			wantDetails: []apierror.Detail{{Field: "cnpj", Message: "invalid CNPJ"}},
+		},
+		{
+			name:        "broken rule without a sentinel",
+			fallback:    http.StatusInternalServerError,
+			err:         &usecase.ValidationError{Err: errors.New("payments do not cover the total")},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    "unprocessable_entity",
+			wantMessage: "Unprocessable request",
+			wantDetails: []apierror.Detail{{Message: "payments do not cover the total"}},
+		},
+		{
+			name:        "emission blocked by a check",
+			fallback:    http.StatusInternalServerError,
+			err:         &usecase.EmissionBlockedError{Check: "velocity", Code: i18n.CodeFraudVelocity, Detail: "10 NFC-e in 1 minute"},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    "fraud_velocity",
+			wantMessage: i18n.Message(i18n.CodeFraudVelocity, i18n.English),
+			wantDetails: []apierror.Detail{{Code: "velocity", Message: "10 NFC-e in 1 minute"}},
+		},
+		{
+			name:        "feature not in plan",
+			fallback:    http.StatusInternalServerError,
+			err:         &usecase.FeatureNotInPlanError{Feature: "graphql"},
+			wantStatus:  http.StatusForbidden,
+			wantCode:    "feature_not_in_plan",
+			wantMessage: "The graphql feature is not included in the current plan. Upgrade the plan to enable it.",
+			wantDetails: []apierror.Detail{},
+		},
+		{
+			name:        "unknown error",
+			fallback:    http.StatusInternalServerError,
+			err:         errors.New("pq: connection refused"),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    "internal_error",
+			wantMessage: "Internal error",
+			wantDetails: []apierror.Detail{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(middleware.HandleErrors(logger.NewNopLogger()))
+			r.GET("/fail", func(c *gin.Context) { respondError(c, tt.fallback, tt.err) })
+
+			req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+			req.Header.Set("Accept-Language", "en")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			var body struct {
+				apierror.Envelope
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q is not JSON: %v", w.Body.String(), err)
+			}
+			if string(body.Code) != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+			}
+			if body.Message != tt.wantMessage || body.Error != tt.wantMessage {
+				t.Errorf("message = %q, error = %q, want %q", body.Message, body.Error, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(body.Details, tt.wantDetails) {
+				t.Errorf("details = %+v, want %+v", body.Details, tt.wantDetails)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+)
+
+// ExportHandler manages HTTP requests related to bulk exports
+type ExportHandler struct {
+	exportUseCase usecase.ExportUseCase
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(exportUseCase usecase.ExportUseCase) *ExportHandler {
+	return &ExportHandler{
+		exportUseCase: exportUseCase,
+	}
+}
+
+// Create creates a new bulk export job
+func (h *ExportHandler) Create(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.CreateExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	export, err := h.exportUseCase.CreateExport(c.Request.Context(), companyID, req)
+	if err != nil {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, export)
+}
+
+// ExportNFCe downloads the XMLs of the company NFC-e authorized within from and to as a zip,
+// with an index.csv when index=true. Larger periods respond 202 with the export job that
+// builds the zip parts instead.
+func (h *ExportHandler) ExportNFCe(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	if format := c.DefaultQuery("format", "zip"); format != "zip" {
+		respondError(c, http.StatusBadRequest, errInvalidExportFormat)
+		return
+	}
+
+	var req dto.CreateExportRequest
+	for param, bound := range map[string]*time.Time{"from": &req.PeriodStart, "to": &req.PeriodEnd} {
+		t, err := time.Parse(time.RFC3339, c.Query(param))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidDateRange)
+			return
+		}
+		*bound = t
+	}
+
+	if index := c.Query("index"); index != "" {
+		b, err := strconv.ParseBool(index)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidExportIndex)
+			return
+		}
+		req.IncludeIndex = b
+	}
+
+	// Streaming the zip may outlast the server write timeout
+	setWriteDeadline(c, time.Time{})
+
+	export, err := h.exportUseCase.ExportNFCe(c.Request.Context(), companyID, req)
+	if err != nil {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if export.Job != nil {
+		c.Header("Location", "/api/v1/exports/"+export.Job.ID)
+		c.JSON(http.StatusAccepted, export.Job)
+		return
+	}
+
+	streamFile(c, export.File)
+}
+
+// GetByID gets an export job and its progress
+func (h *ExportHandler) GetByID(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	export, err := h.exportUseCase.GetExport(c.Request.Context(), companyID, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
+// List lists the export jobs of the authenticated company
+func (h *ExportHandler) List(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	response, err := h.exportUseCase.ListExports(c.Request.Context(), companyID, limit, offset)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   response.Exports,
+		"total":  response.Total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// DownloadPart redirects to the storage URL of an export part, which supports Range requests
+func (h *ExportHandler) DownloadPart(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("part"))
+	if err != nil || number <= 0 {
+		respondError(c, http.StatusBadRequest, errInvalidPartNumber)
+		return
+	}
+
+	url, err := h.exportUseCase.GetPartURL(c.Request.Context(), companyID, c.Param("id"), number)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, url)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/apierror"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+)
+
+// graphQLMaxBody caps the size of a GraphQL request body
+const graphQLMaxBody = 1 << 20 // 1 MiB
+
+// GraphQLHandler serves the read-only GraphQL endpoint to the companies and the admins
+type GraphQLHandler struct {
+	graphQLUseCase usecase.GraphQLUseCase
+}
+
+// NewGraphQLHandler creates a new GraphQLHandler
+func NewGraphQLHandler(graphQLUseCase usecase.GraphQLUseCase) *GraphQLHandler {
+	return &GraphQLHandler{graphQLUseCase: graphQLUseCase}
+}
+
+// Query runs a GraphQL query, from the JSON body of a POST or the query string of a GET
+// (query, operationName, and variables and extensions as JSON). The response is always 200
+// with the data and errors of GraphQL, unless the request cannot be read.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req dto.GraphQLRequest
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		for param, target := range map[string]*map[string]interface{}{"variables": &req.Variables, "extensions": &req.Extensions} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			if err := decodeGraphQLJSON(value, target); err != nil {
+				respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %w", errInvalidGraphQLRequest, &usecase.ValidationError{Field: param, Err: errors.New("must be a JSON object")}))
+				return
+			}
+		}
+	} else {
+		decoder := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, graphQLMaxBody))
+		decoder.UseNumber() // Large Int and ID variables keep their digits
+		if err := decoder.Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondError(c, http.StatusRequestEntityTooLarge, apierror.New(http.StatusRequestEntityTooLarge, i18n.CodeRequestTooLarge, err).WithArgs(tooLarge.Limit))
+				return
+			}
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %w", errInvalidGraphQLRequest, &usecase.ValidationError{Err: err}))
+			return
+		}
+	}
+
+	viewer := usecase.GraphQLViewer{
+		CompanyID: c.GetString("company_id"),
+		AdminID:   c.GetString("admin_id"),
+	}
+	c.JSON(http.StatusOK, h.graphQLUseCase.Execute(c.Request.Context(), viewer, req))
+}
+
+// Schema returns the schema in the GraphQL schema definition language
+func (h *GraphQLHandler) Schema(c *gin.Context) {
+	c.String(http.StatusOK, h.graphQLUseCase.Schema())
+}
+
+// decodeGraphQLJSON decodes a JSON object of the query string, keeping numbers as json.Number
+func decodeGraphQLJSON(data string, target *map[string]interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/clock"
+)
+
+// HealthHandler reports the API health, including the host clock skew
+type HealthHandler struct {
+	clock *clock.Monitor
+}
+
+// NewHealthHandler creates a new HealthHandler
+func NewHealthHandler(clockMonitor *clock.Monitor) *HealthHandler {
+	return &HealthHandler{clock: clockMonitor}
+}
+
+// Check answers the health check; a skewed clock degrades the status without failing
+// the check, since the API keeps accepting requests while operators fix the host
+func (h *HealthHandler) Check(c *gin.Context) {
+	status := "ok"
+	skew := h.clock.Status()
+	if skew.Exceeded {
+		status = "degraded"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": status,
+		"clock":  skew,
+	})
+}
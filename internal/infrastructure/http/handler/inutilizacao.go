@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+)
+
+// InutilizacaoHandler manages HTTP requests related to number range inutilização
+type InutilizacaoHandler struct {
+	inutilizacaoUseCase usecase.InutilizacaoUseCase
+}
+
+// NewInutilizacaoHandler creates a new InutilizacaoHandler
+func NewInutilizacaoHandler(inutilizacaoUseCase usecase.InutilizacaoUseCase) *InutilizacaoHandler {
+	return &InutilizacaoHandler{
+		inutilizacaoUseCase: inutilizacaoUseCase,
+	}
+}
+
+// Create requests the inutilização of a range of NFC-e numbers
+func (h *InutilizacaoHandler) Create(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.InutilizacaoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	inutilizacao, err := h.inutilizacaoUseCase.CreateInutilizacao(c.Request.Context(), companyID, req)
+	if err != nil {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, inutilizacao)
+}
+
+// GetByID gets an inutilização and its SEFAZ protocol
+func (h *InutilizacaoHandler) GetByID(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	inutilizacao, err := h.inutilizacaoUseCase.GetInutilizacao(c.Request.Context(), companyID, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, inutilizacao)
+}
+
+// List lists the inutilizações of the authenticated company
+func (h *InutilizacaoHandler) List(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	response, err := h.inutilizacaoUseCase.ListInutilizacoes(c.Request.Context(), companyID, limit, offset)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   response.Inutilizacoes,
+		"total":  response.Total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+)
+
+// MaintenanceHandler lets administrators switch the maintenance mode
+type MaintenanceHandler struct {
+	maintenanceUseCase usecase.MaintenanceUseCase
+}
+
+// NewMaintenanceHandler creates a new MaintenanceHandler
+func NewMaintenanceHandler(maintenanceUseCase usecase.MaintenanceUseCase) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenanceUseCase: maintenanceUseCase,
+	}
+}
+
+// Status returns the active maintenance window, if any
+func (h *MaintenanceHandler) Status(c *gin.Context) {
+	status, err := h.maintenanceUseCase.GetStatus(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Start begins a maintenance window
+func (h *MaintenanceHandler) Start(c *gin.Context) {
+	var req dto.StartMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	window, err := h.maintenanceUseCase.Start(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, window)
+}
+
+// End closes the active maintenance window
+func (h *MaintenanceHandler) End(c *gin.Context) {
+	window, err := h.maintenanceUseCase.End(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, window)
+}
@@ -1,26 +1,42 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
 )
 
 // NFCeHandler manages HTTP requests related to NFC-e
 type NFCeHandler struct {
 	nfceUseCase usecase.NFCeUseCase
+	draining    chan struct{} // Closed on shutdown to end the open event streams
+	drainOnce   sync.Once
 }
 
 type NFCeHandlerInterface interface {
 	EmitNFce(c *gin.Context)
+	EmitLote(c *gin.Context)
+	GetLote(c *gin.Context)
 	GetNFceByID(c *gin.Context)
 	ListNFces(c *gin.Context)
+	SearchNFces(c *gin.Context)
 	CancelNFce(c *gin.Context)
 	GetNFceEvents(c *gin.Context)
+	StreamNFceEvents(c *gin.Context)
+	StreamNFceStatus(c *gin.Context)
 	DownloadXML(c *gin.Context)
 	DownloadPDF(c *gin.Context)
 	DownloadQRCode(c *gin.Context)
@@ -30,9 +46,16 @@ type NFCeHandlerInterface interface {
 func NewNFCeHandler(nfceUseCase usecase.NFCeUseCase) *NFCeHandler {
 	return &NFCeHandler{
 		nfceUseCase: nfceUseCase,
+		draining:    make(chan struct{}),
 	}
 }
 
+// CloseStreams ends the open event streams, so the server shutdown does not wait for them;
+// the clients reconnect with Last-Event-ID and miss nothing
+func (h *NFCeHandler) CloseStreams() {
+	h.drainOnce.Do(func() { close(h.draining) })
+}
+
 // EmitNFce emits a new NFC-e
 func (h *NFCeHandler) EmitNFce(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -41,61 +64,218 @@ func (h *NFCeHandler) EmitNFce(c *gin.Context) {
 	// Get idempotency key from header
 	idempotencyKey := c.GetHeader("Idempotency-Key")
 	if idempotencyKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key header is required"})
+		respondError(c, http.StatusBadRequest, errIdempotencyKeyRequired)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	response, err := h.nfceUseCase.EmitNFce(ctx, c.GetString("company_id"), idempotencyKey, req)
+	if err != nil {
+		writeEmitError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// writeEmitError maps the errors of an emission request to the response; the errors that
+// are not domain ones are taken as a problem of the request itself
+func writeEmitError(c *gin.Context, err error) {
+	respondError(c, http.StatusBadRequest, err)
+}
+
+// EmitLote emits a lote of up to 50 NFC-e; the lote is polled for the aggregate status
+func (h *NFCeHandler) EmitLote(c *gin.Context) {
+	var req dto.EmitLoteRequest
+
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey == "" {
+		respondError(c, http.StatusBadRequest, errIdempotencyKeyRequired)
 		return
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	response, err := h.nfceUseCase.EmitNFce(ctx, idempotencyKey, req)
+	response, err := h.nfceUseCase.EmitLote(c.Request.Context(), c.GetString("company_id"), idempotencyKey, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeEmitError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusAccepted, response)
 }
 
-// GetNFceByID gets a NFC-e by ID
+// GetLote gets a lote with the aggregate status of its NFC-e
+func (h *NFCeHandler) GetLote(c *gin.Context) {
+	response, err := h.nfceUseCase.GetLote(c.Request.Context(), c.GetString("company_id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, usecase.ErrLoteNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, fmt.Errorf("%w: %v", errGetLote, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetNFceByID gets a NFC-e of the authenticated company by ID
 func (h *NFCeHandler) GetNFceByID(c *gin.Context) {
 	ctx := c.Request.Context()
 	id := c.Param("id")
 
-	response, err := h.nfceUseCase.GetNFceByID(ctx, id)
+	response, err := h.nfceUseCase.GetNFceByID(ctx, c.GetString("company_id"), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "NFC-e not found"})
+		if errors.Is(err, usecase.ErrNFCeNotFound) {
+			respondError(c, http.StatusNotFound, usecase.ErrNFCeNotFound)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, fmt.Errorf("%w: %v", errGetNFCe, err))
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// ListNFces lists NFC-e requests with pagination
+// ListNFces lists the authenticated company NFC-e requests with keyset pagination: the
+// next_cursor of a page is passed as cursor to get the following one. The NFC-e can be
+// filtered by creation period, chave de acesso, número/série, destinatário, cStat, status,
+// valor range, contingency, full-text q and metadata[key]=value, and sorted by created_at or
+// valor (prefixed with - for descending order, the default being -created_at).
 func (h *NFCeHandler) ListNFces(c *gin.Context) {
-	ctx := c.Request.Context()
+	filter, limit, ok := bindNFCeListQuery(c)
+	if !ok {
+		return
+	}
 
-	// Parse pagination parameters
-	limitStr := c.DefaultQuery("limit", "10")
-	offsetStr := c.DefaultQuery("offset", "0")
+	response, err := h.nfceUseCase.ListNFces(c.Request.Context(), c.GetString("company_id"), filter, c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidCursor) {
+			respondError(c, http.StatusBadRequest, usecase.ErrInvalidCursor)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, fmt.Errorf("%w: %v", errListNFCes, err))
+		return
+	}
 
-	limit, err := strconv.Atoi(limitStr)
+	c.JSON(http.StatusOK, response)
+}
+
+// bindNFCeListQuery reads the limit and the filters of an NFC-e listing, responding 400 and
+// reporting false when one is invalid
+func bindNFCeListQuery(c *gin.Context) (ports.NFCeFilter, int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	if err != nil || limit <= 0 || limit > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		respondError(c, http.StatusBadRequest, errInvalidLimit)
+		return ports.NFCeFilter{}, 0, false
+	}
+
+	filter := ports.NFCeFilter{
+		ChaveAcesso: c.Query("chave_acesso"),
+		Numero:      c.Query("numero"),
+		Serie:       c.Query("serie"),
+		CStat:       c.Query("cstat"),
+		Status:      entity.RequestStatus(c.Query("status")),
+		Query:       strings.TrimSpace(c.Query("q")),
+		Metadata:    c.QueryMap("metadata"),
+	}
+
+	for param, bound := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidDateRange)
+			return ports.NFCeFilter{}, 0, false
+		}
+		*bound = &t
+	}
+
+	for param, bound := range map[string]**money.Amount{"valor_min": &filter.MinValor, "valor_max": &filter.MaxValor} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		amount, err := money.Parse(value)
+		if err != nil || amount < 0 {
+			respondError(c, http.StatusBadRequest, errInvalidValorRange)
+			return ports.NFCeFilter{}, 0, false
+		}
+		*bound = &amount
+	}
+
+	if destinatario := c.Query("destinatario"); destinatario != "" {
+		filter.Destinatario = strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, destinatario)
+		if len(filter.Destinatario) != 11 && len(filter.Destinatario) != 14 {
+			respondError(c, http.StatusBadRequest, errInvalidDestinatario)
+			return ports.NFCeFilter{}, 0, false
+		}
+	}
+
+	if contingency := c.Query("contingency"); contingency != "" {
+		b, err := strconv.ParseBool(contingency)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidContingency)
+			return ports.NFCeFilter{}, 0, false
+		}
+		filter.Contingency = &b
+	}
+
+	sort := c.DefaultQuery("sort", "-"+ports.NFCeSortCreatedAt)
+	filter.Desc = strings.HasPrefix(sort, "-")
+	filter.Sort = strings.TrimPrefix(sort, "-")
+	if filter.Sort != ports.NFCeSortCreatedAt && filter.Sort != ports.NFCeSortValor {
+		respondError(c, http.StatusBadRequest, errInvalidSort)
+		return ports.NFCeFilter{}, 0, false
+	}
+
+	return filter, limit, true
+}
+
+// SearchNFces searches the authenticated company NFC-e by item description and consumer name
+func (h *NFCeHandler) SearchNFces(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
 
-	offset, err := strconv.Atoi(offsetStr)
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" || len(query) > 200 {
+		respondError(c, http.StatusBadRequest, errInvalidSearchQuery)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 100 {
+		respondError(c, http.StatusBadRequest, errInvalidLimit)
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	if err != nil || offset < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be >= 0"})
+		respondError(c, http.StatusBadRequest, errInvalidOffset)
 		return
 	}
 
-	response, err := h.nfceUseCase.ListNFces(ctx, limit, offset)
+	response, err := h.nfceUseCase.SearchNFces(c.Request.Context(), companyID, query, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list NFC-es"})
+		respondError(c, http.StatusInternalServerError, fmt.Errorf("%w: %v", errSearchNFCes, err))
 		return
 	}
 
@@ -109,13 +289,13 @@ func (h *NFCeHandler) CancelNFce(c *gin.Context) {
 
 	var req dto.CancelNFceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	err := h.nfceUseCase.CancelNFce(ctx, id, req)
+	err := h.nfceUseCase.CancelNFce(ctx, c.GetString("company_id"), id, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -133,69 +313,238 @@ func (h *NFCeHandler) GetNFceEvents(c *gin.Context) {
 
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit <= 0 || limit > 200 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		respondError(c, http.StatusBadRequest, errInvalidLimit)
 		return
 	}
 
 	offset, err := strconv.Atoi(offsetStr)
 	if err != nil || offset < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be >= 0"})
+		respondError(c, http.StatusBadRequest, errInvalidOffset)
 		return
 	}
 
-	response, err := h.nfceUseCase.GetNFceEvents(ctx, requestID, limit, offset)
+	response, err := h.nfceUseCase.GetNFceEvents(ctx, c.GetString("company_id"), requestID, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get NFC-e events"})
+		if errors.Is(err, usecase.ErrNFCeNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, fmt.Errorf("%w: %v", errGetNFCeEvents, err))
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// DownloadXML downloads the XML file for an NFC-e
-func (h *NFCeHandler) DownloadXML(c *gin.Context) {
+// StreamNFceEvents streams status change events of the company as Server-Sent Events
+func (h *NFCeHandler) StreamNFceEvents(c *gin.Context) {
 	ctx := c.Request.Context()
-	id := c.Param("id")
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
 
-	data, err := h.nfceUseCase.DownloadXML(ctx, id)
+	stream, err := h.nfceUseCase.StreamNFceEvents(ctx, companyID, lastEventIDOf(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, http.StatusServiceUnavailable, err)
 		return
 	}
+	defer stream.Close()
 
-	c.Header("Content-Type", "application/xml")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"nfce-%s.xml\"", id))
-	c.Data(http.StatusOK, "application/xml", data)
+	h.writeEventStream(c, stream)
 }
 
-// DownloadPDF downloads the PDF file for an NFC-e
-func (h *NFCeHandler) DownloadPDF(c *gin.Context) {
+// StreamNFceStatus streams the status changes of one NFC-e as Server-Sent Events, replacing
+// the polling of GET /nfce/{id}: the events so far are sent first, and a done event closes
+// the stream once the NFC-e is authorized, rejected or canceled
+func (h *NFCeHandler) StreamNFceStatus(c *gin.Context) {
 	ctx := c.Request.Context()
-	id := c.Param("id")
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
 
-	data, err := h.nfceUseCase.DownloadPDF(ctx, id)
+	stream, err := h.nfceUseCase.StreamNFceStatus(ctx, companyID, c.Param("id"), lastEventIDOf(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, http.StatusServiceUnavailable, err)
 		return
 	}
+	defer stream.Close()
 
-	c.Header("Content-Type", "application/pdf")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"nfce-%s.pdf\"", id))
-	c.Data(http.StatusOK, "application/pdf", data)
+	if last := h.writeEventStream(c, stream); last != nil && last.StatusTo.IsSettled() {
+		c.Render(-1, sse.Event{Event: "done", Data: gin.H{"request_id": last.RequestID, "status": last.StatusTo}})
+		c.Writer.Flush()
+	}
 }
 
-// DownloadQRCode downloads the QR Code image for an NFC-e
-func (h *NFCeHandler) DownloadQRCode(c *gin.Context) {
+// lastEventIDOf returns the event to resume a stream after: browsers send Last-Event-ID on
+// reconnect, and the last_event_id query param allows manual resumes
+func lastEventIDOf(c *gin.Context) string {
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		return lastEventID
+	}
+	return c.Query("last_event_id")
+}
+
+// writeEventStream sends the replay and then the live events of a stream as Server-Sent
+// Events, with a ping every 15 seconds, until the client leaves, the server drains or the
+// events end. It returns the last event sent, nil when none was.
+func (h *NFCeHandler) writeEventStream(c *gin.Context, stream *dto.NFceEventStream) *dto.NFceEventResponse {
 	ctx := c.Request.Context()
-	id := c.Param("id")
 
-	data, err := h.nfceUseCase.DownloadQRCode(ctx, id)
+	// The stream must outlive the server write timeout
+	setWriteDeadline(c, time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	var last *dto.NFceEventResponse
+	if stream.Reset {
+		c.Render(-1, sse.Event{Event: "reset", Data: gin.H{"message": "last event not found, reload current state"}})
+	}
+	for i := range stream.Replay {
+		last = &stream.Replay[i]
+		c.Render(-1, sse.Event{Id: last.ID, Event: "status", Data: last})
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-h.draining:
+			return false
+		case event, ok := <-stream.Events:
+			if !ok {
+				return false
+			}
+			last = &event
+			c.Render(-1, sse.Event{Id: event.ID, Event: "status", Data: event})
+			return true
+		case <-heartbeat.C:
+			// Comment line keeps proxies from closing an idle connection
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		}
+	})
+	return last
+}
+
+// DownloadXML streams the authorized XML of the company NFC-e
+func (h *NFCeHandler) DownloadXML(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	file, err := h.nfceUseCase.DownloadXML(c.Request.Context(), companyID, c.Param("id"))
+	if err != nil {
+		downloadError(c, err)
+		return
+	}
+
+	streamFile(c, file)
+}
+
+// DownloadPDF streams the DANFE of the company NFC-e, as PDF or as ESC/POS when
+// requested with ?format=escpos or Accept: application/vnd.escpos
+func (h *NFCeHandler) DownloadPDF(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	// Optional DANFE rendering options (?vias=2&compacto=true&papel=58mm)
+	var opts *dto.DANFEOptions
+	if vias, compacto, papel := c.Query("vias"), c.Query("compacto"), c.Query("papel"); vias != "" || compacto != "" || papel != "" {
+		opts = &dto.DANFEOptions{Vias: 1}
+		if vias != "" {
+			n, err := strconv.Atoi(vias)
+			if err != nil || (n != 1 && n != 2) {
+				respondError(c, http.StatusBadRequest, errInvalidVias)
+				return
+			}
+			opts.Vias = n
+		}
+		if compacto != "" {
+			b, err := strconv.ParseBool(compacto)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, errInvalidCompacto)
+				return
+			}
+			opts.Compacto = b
+		}
+		if papel != "" {
+			if papel != entity.DANFEPapel80mm && papel != entity.DANFEPapel58mm {
+				respondError(c, http.StatusBadRequest, errInvalidPapel)
+				return
+			}
+			opts.Papel = papel
+		}
+	}
+
+	var escpos bool
+	switch format := c.Query("format"); format {
+	case "":
+		escpos = strings.Contains(c.GetHeader("Accept"), dto.ContentTypeESCPOS)
+	case "pdf", "escpos":
+		escpos = format == "escpos"
+	default:
+		respondError(c, http.StatusBadRequest, errInvalidDANFEFormat)
+		return
+	}
+
+	download := h.nfceUseCase.DownloadPDF
+	if escpos {
+		download = h.nfceUseCase.DownloadESCPOS
+	}
+
+	file, err := download(c.Request.Context(), companyID, c.Param("id"), opts)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		downloadError(c, err)
 		return
 	}
 
-	c.Header("Content-Type", "image/png")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"qrcode-%s.png\"", id))
-	c.Data(http.StatusOK, "image/png", data)
+	streamFile(c, file)
+}
+
+// DownloadQRCode streams the QR Code image of the company NFC-e
+func (h *NFCeHandler) DownloadQRCode(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	file, err := h.nfceUseCase.DownloadQRCode(c.Request.Context(), companyID, c.Param("id"))
+	if err != nil {
+		downloadError(c, err)
+		return
+	}
+
+	streamFile(c, file)
+}
+
+// downloadError maps the errors of the NFC-e document downloads
+func downloadError(c *gin.Context, err error) {
+	respondError(c, http.StatusInternalServerError, err)
+}
+
+// streamFile writes a stored NFC-e document as an attachment without buffering it
+func streamFile(c *gin.Context, file *dto.NFceFile) {
+	defer file.Content.Close()
+
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, file.Content, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=\"%s\"", file.Filename),
+	})
 }
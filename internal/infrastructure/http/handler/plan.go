@@ -25,13 +25,13 @@ func NewPlanHandler(planUseCase usecase.PlanUseCase) *PlanHandler {
 func (h *PlanHandler) Create(c *gin.Context) {
 	var req dto.CreatePlanRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	plan, err := h.planUseCase.Create(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -42,13 +42,13 @@ func (h *PlanHandler) Create(c *gin.Context) {
 func (h *PlanHandler) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan ID is required"})
+		respondError(c, http.StatusBadRequest, errPlanIDRequired)
 		return
 	}
 
 	plan, err := h.planUseCase.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -72,7 +72,7 @@ func (h *PlanHandler) List(c *gin.Context) {
 
 	response, err := h.planUseCase.List(c.Request.Context(), limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -88,19 +88,19 @@ func (h *PlanHandler) List(c *gin.Context) {
 func (h *PlanHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan ID is required"})
+		respondError(c, http.StatusBadRequest, errPlanIDRequired)
 		return
 	}
 
 	var req dto.UpdatePlanRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	err := h.planUseCase.Update(c.Request.Context(), id, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -111,13 +111,13 @@ func (h *PlanHandler) Update(c *gin.Context) {
 func (h *PlanHandler) Archive(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan ID is required"})
+		respondError(c, http.StatusBadRequest, errPlanIDRequired)
 		return
 	}
 
 	err := h.planUseCase.Archive(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
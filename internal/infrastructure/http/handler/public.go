@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/danfe"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// publicNFCePage renders the public consulta for browsers, in the layout of the UF portals
+var publicNFCePage = template.Must(template.New("nfce").Funcs(template.FuncMap{
+	"reais":      func(a money.Amount) string { return "R$ " + strings.Replace(a.String(), ".", ",", 1) },
+	"quantidade": publicQuantity,
+	"cnpj":       danfe.FormatCNPJ,
+	"situacao":   publicSituacaoLabel,
+}).Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>NFC-e {{.Numero}} - {{.Emitente.RazaoSocial}}</title>
+<style>
+body{font-family:sans-serif;max-width:640px;margin:0 auto;padding:16px;color:#222}
+h1{font-size:1.2em;margin-bottom:0}
+table{width:100%;border-collapse:collapse;margin:12px 0}
+td,th{padding:4px;border-bottom:1px solid #ddd;text-align:left}
+.valor{text-align:right}
+.situacao{font-weight:bold}
+.chave{font-family:monospace;word-break:break-all}
+</style>
+</head>
+<body>
+<h1>{{if .Emitente.NomeFantasia}}{{.Emitente.NomeFantasia}}{{else}}{{.Emitente.RazaoSocial}}{{end}}</h1>
+<p>{{.Emitente.RazaoSocial}} - CNPJ {{cnpj .Emitente.CNPJ}}</p>
+<p class="situacao">{{situacao .Situacao}}{{if .SEFAZ}} - {{.SEFAZ.XMotivo}}{{end}}</p>
+<table>
+<tr><th>Descrição</th><th class="valor">Qtde</th><th>Un</th><th class="valor">Vl. unit.</th><th class="valor">Vl. total</th></tr>
+{{range .Itens}}<tr><td>{{.Descricao}}</td><td class="valor">{{quantidade .Quantidade}}</td><td>{{.Unidade}}</td><td class="valor">{{reais .ValorUnitario}}</td><td class="valor">{{reais .ValorTotal}}</td></tr>
+{{end}}</table>
+<table>
+{{if .Desconto}}<tr><td>Descontos</td><td class="valor">{{reais .Desconto}}</td></tr>{{end}}
+<tr><th>Valor a pagar</th><th class="valor">{{reais .ValorTotal}}</th></tr>
+{{range .Pagamentos}}<tr><td>{{.Forma}}</td><td class="valor">{{reais .Valor}}</td></tr>
+{{end}}{{if .Troco}}<tr><td>Troco</td><td class="valor">{{reais .Troco}}</td></tr>{{end}}
+{{if .TributosAproximados}}<tr><td>Tributos totais incidentes (Lei 12.741/2012)</td><td class="valor">{{reais .TributosAproximados}}</td></tr>{{end}}
+</table>
+<p>NFC-e nº {{.Numero}} série {{.Serie}} - emitida em {{.EmitidaEm.Format "02/01/2006 15:04:05"}}{{if .SEFAZ}}{{if .SEFAZ.Protocolo}}<br>Protocolo de autorização {{.SEFAZ.Protocolo}}{{end}}{{end}}</p>
+<p>Chave de acesso<br><span class="chave">{{.ChaveAcesso}}</span></p>
+{{if .ConsultaURL}}<p>Consulte também em {{.ConsultaURL}}</p>{{end}}
+</body>
+</html>
+`))
+
+// PublicHandler manages the unauthenticated consulta of the NFC-e by the chave of their QR Code
+type PublicHandler struct {
+	publicUseCase usecase.PublicUseCase
+}
+
+// NewPublicHandler creates a new PublicHandler
+func NewPublicHandler(publicUseCase usecase.PublicUseCase) *PublicHandler {
+	return &PublicHandler{
+		publicUseCase: publicUseCase,
+	}
+}
+
+// GetNFCe answers the summary of the NFC-e with the chave, as JSON or, for browsers and
+// ?format=html, as an HTML page
+func (h *PublicHandler) GetNFCe(c *gin.Context) {
+	summary, err := h.publicUseCase.GetNFCe(c.Request.Context(), c.Param("chave"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" && c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		format = "html"
+	}
+	if format != "html" {
+		c.JSON(http.StatusOK, summary)
+		return
+	}
+
+	var page strings.Builder
+	if err := publicNFCePage.Execute(&page, summary); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page.String()))
+}
+
+// publicSituacaoLabel describes the situation of the NFC-e on the HTML page
+func publicSituacaoLabel(situacao string) string {
+	switch situacao {
+	case dto.PublicSituacaoAutorizada:
+		return "NFC-e autorizada"
+	case dto.PublicSituacaoCancelada:
+		return "NFC-e cancelada"
+	case dto.PublicSituacaoNaoEncontrada:
+		return "NFC-e não encontrada na SEFAZ"
+	default:
+		return "Situação da NFC-e indisponível"
+	}
+}
+
+// publicQuantity formats the quantity without trailing zeros, e.g. 1,5
+func publicQuantity(q money.Quantity) string {
+	return strings.Replace(strings.TrimRight(strings.TrimRight(q.String(), "0"), "."), ".", ",", 1)
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/validator"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// schemaUpdateTimeout bounds a background schema update
+const schemaUpdateTimeout = 5 * time.Minute
+
+// SchemaHandler lets administrators inspect and update the SEFAZ XSD schemas
+type SchemaHandler struct {
+	validator validator.XMLValidator
+	version   string // Schema package, or the layout version of the default package
+	logger    logger.Logger
+}
+
+// NewSchemaHandler creates a new SchemaHandler for the pinned schema package
+func NewSchemaHandler(xmlValidator validator.XMLValidator, version string, l logger.Logger) *SchemaHandler {
+	return &SchemaHandler{
+		validator: xmlValidator,
+		version:   version,
+		logger:    l,
+	}
+}
+
+// Status verifies the local schemas against the pinned manifest
+func (h *SchemaHandler) Status(c *gin.Context) {
+	status, err := h.validator.SchemaStatus(h.version)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Update starts a background install of the pinned schemas, downloaded from the SEFAZ portal
+// or with source=bundle copied from the binary; the result is reported in last_update of the
+// status endpoint
+func (h *SchemaHandler) Update(c *gin.Context) {
+	source := c.DefaultQuery("source", validator.SchemaSourceDownload)
+	if source != validator.SchemaSourceDownload && source != validator.SchemaSourceBundle {
+		respondError(c, http.StatusBadRequest, errInvalidSchemaSource)
+		return
+	}
+
+	status, err := h.validator.SchemaStatus(h.version)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if status.Updating {
+		respondError(c, http.StatusConflict, validator.ErrSchemaUpdateInProgress)
+		return
+	}
+	if source == validator.SchemaSourceBundle && !status.Bundled {
+		respondError(c, http.StatusConflict, errSchemaNotBundled)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), schemaUpdateTimeout)
+		defer cancel()
+
+		var err error
+		if source == validator.SchemaSourceBundle {
+			err = h.validator.InstallBundledSchemas(h.version)
+		} else {
+			err = h.validator.DownloadSEFAZSchemas(ctx, h.version)
+		}
+		if err != nil && !errors.Is(err, validator.ErrSchemaUpdateInProgress) {
+			h.logger.Error("SEFAZ schema update failed",
+				logger.Field{Key: "version", Value: h.version},
+				logger.Field{Key: "source", Value: source},
+				logger.Err(err))
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "schema update started",
+		"version": h.version,
+		"source":  source,
+	})
+}
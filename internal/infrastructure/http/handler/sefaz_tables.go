@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/tables"
+)
+
+// SEFAZTablesHandler lets administrators inspect and reload the SEFAZ endpoint and QR Code
+// tables of this instance
+type SEFAZTablesHandler struct {
+	reloader *tables.Reloader
+}
+
+// NewSEFAZTablesHandler creates a new SEFAZTablesHandler
+func NewSEFAZTablesHandler(reloader *tables.Reloader) *SEFAZTablesHandler {
+	return &SEFAZTablesHandler{
+		reloader: reloader,
+	}
+}
+
+// Status returns the versions of the tables in use
+func (h *SEFAZTablesHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, sefazTablesStatus(tables.Active()))
+}
+
+// Reload validates SEFAZ_TABLES_FILE again and installs it; an invalid file keeps the tables
+// in use and answers 422 with the problems found
+func (h *SEFAZTablesHandler) Reload(c *gin.Context) {
+	reloaded, err := h.reloader.Reload()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sefazTablesStatus(reloaded))
+}
+
+// sefazTablesStatus describes a table set
+func sefazTablesStatus(active *tables.Tables) gin.H {
+	return gin.H{
+		"version":      active.Version,
+		"file":         active.Path,
+		"file_version": active.FileVersion,
+		"loaded_at":    active.LoadedAt,
+	}
+}
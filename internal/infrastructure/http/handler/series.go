@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+)
+
+// SeriesHandler manages HTTP requests related to NFC-e numbering series
+type SeriesHandler struct {
+	seriesUseCase usecase.SeriesUseCase
+}
+
+// NewSeriesHandler creates a new SeriesHandler
+func NewSeriesHandler(seriesUseCase usecase.SeriesUseCase) *SeriesHandler {
+	return &SeriesHandler{
+		seriesUseCase: seriesUseCase,
+	}
+}
+
+// List lists the numbering series of the authenticated company
+func (h *SeriesHandler) List(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	response, err := h.seriesUseCase.ListSeries(c.Request.Context(), companyID, c.Query("modelo"), c.Query("ambiente"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": response.Series})
+}
+
+// Create registers a numbering series
+func (h *SeriesHandler) Create(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.CreateNumberingSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	series, err := h.seriesUseCase.CreateSeries(c.Request.Context(), companyID, req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, series)
+}
+
+// Update advances the next number or (de)activates a numbering series
+func (h *SeriesHandler) Update(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var req dto.UpdateNumberingSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	series, err := h.seriesUseCase.UpdateSeries(c.Request.Context(), companyID, c.Param("id"), req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// SetDefault makes a numbering series the default of its environment
+func (h *SeriesHandler) SetDefault(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	series, err := h.seriesUseCase.SetDefaultSeries(c.Request.Context(), companyID, c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// GetGaps reports the skipped numbers of a series that need inutilização
+func (h *SeriesHandler) GetGaps(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	gaps, err := h.seriesUseCase.GetGaps(c.Request.Context(), companyID, c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gaps)
+}
+
+// respondError maps the series use case errors to HTTP status codes
+func (h *SeriesHandler) respondError(c *gin.Context, err error) {
+	respondError(c, http.StatusUnprocessableEntity, err)
+}
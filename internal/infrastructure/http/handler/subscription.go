@@ -25,13 +25,13 @@ func NewSubscriptionHandler(subscriptionUseCase usecase.SubscriptionUseCase) *Su
 func (h *SubscriptionHandler) Create(c *gin.Context) {
 	var req dto.CreateSubscriptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	subscription, err := h.subscriptionUseCase.Create(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -42,13 +42,13 @@ func (h *SubscriptionHandler) Create(c *gin.Context) {
 func (h *SubscriptionHandler) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription ID is required"})
+		respondError(c, http.StatusBadRequest, errSubscriptionIDRequired)
 		return
 	}
 
 	subscription, err := h.subscriptionUseCase.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -59,13 +59,13 @@ func (h *SubscriptionHandler) GetByID(c *gin.Context) {
 func (h *SubscriptionHandler) GetCurrent(c *gin.Context) {
 	companyID := c.GetString("company_id")
 	if companyID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
 
 	subscription, err := h.subscriptionUseCase.GetCurrent(c.Request.Context(), companyID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -89,7 +89,7 @@ func (h *SubscriptionHandler) List(c *gin.Context) {
 
 	response, err := h.subscriptionUseCase.List(c.Request.Context(), limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -105,19 +105,19 @@ func (h *SubscriptionHandler) List(c *gin.Context) {
 func (h *SubscriptionHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription ID is required"})
+		respondError(c, http.StatusBadRequest, errSubscriptionIDRequired)
 		return
 	}
 
 	var req dto.UpdateSubscriptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	err := h.subscriptionUseCase.Update(c.Request.Context(), id, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -128,38 +128,143 @@ func (h *SubscriptionHandler) Update(c *gin.Context) {
 func (h *SubscriptionHandler) Cancel(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription ID is required"})
+		respondError(c, http.StatusBadRequest, errSubscriptionIDRequired)
 		return
 	}
 
 	var req dto.CancelSubscriptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	err := h.subscriptionUseCase.Cancel(c.Request.Context(), id, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "subscription canceled successfully"})
 }
 
+// ChangePlan switches a subscription to another plan mid-cycle. Companies may only change
+// their own subscriptions; the admin token changes any.
+func (h *SubscriptionHandler) ChangePlan(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		respondError(c, http.StatusBadRequest, errSubscriptionIDRequired)
+		return
+	}
+
+	var req dto.ChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	response, err := h.subscriptionUseCase.ChangePlan(c.Request.Context(), id, c.GetString("company_id"), req)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // GetUsage gets the usage statistics for a company's current subscription
 func (h *SubscriptionHandler) GetUsage(c *gin.Context) {
 	companyID := c.GetString("company_id")
 	if companyID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
 
 	usage, err := h.subscriptionUseCase.GetUsage(c.Request.Context(), companyID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, usage)
 }
+
+// ListStatements lists the monthly usage statements of the authenticated company
+func (h *SubscriptionHandler) ListStatements(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "12"))
+	if err != nil || limit <= 0 {
+		limit = 12
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	response, err := h.subscriptionUseCase.ListStatements(c.Request.Context(), companyID, limit, offset)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   response.Statements,
+		"total":  response.Total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ListInvoices lists the subscription invoices of the authenticated company
+func (h *SubscriptionHandler) ListInvoices(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "12"))
+	if err != nil || limit <= 0 {
+		limit = 12
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	response, err := h.subscriptionUseCase.ListInvoices(c.Request.Context(), companyID, limit, offset)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   response.Invoices,
+		"total":  response.Total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetStatement gets the usage statement of the authenticated company for a month (YYYY-MM)
+func (h *SubscriptionHandler) GetStatement(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	statement, err := h.subscriptionUseCase.GetStatement(c.Request.Context(), companyID, c.Param("period"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
@@ -21,57 +21,121 @@ func NewWebhookHandler(webhookUseCase usecase.WebhookUseCase) *WebhookHandler {
 	}
 }
 
-// Create creates a new webhook
+// Create creates a webhook for the authenticated company
 func (h *WebhookHandler) Create(c *gin.Context) {
 	companyID := c.GetString("company_id")
 	if companyID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
 
+	h.create(c, companyID)
+}
+
+// GetByID gets a webhook of the authenticated company
+func (h *WebhookHandler) GetByID(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	h.getByID(c, companyID, c.Param("id"))
+}
+
+// List lists webhooks for the authenticated company
+func (h *WebhookHandler) List(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	h.list(c, companyID)
+}
+
+// Update updates a webhook of the authenticated company
+func (h *WebhookHandler) Update(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	h.update(c, companyID, c.Param("id"))
+}
+
+// Delete deletes a webhook of the authenticated company
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	companyID := c.GetString("company_id")
+	if companyID == "" {
+		respondError(c, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	h.delete(c, companyID, c.Param("id"))
+}
+
+// AdminCreate creates a webhook for a company
+func (h *WebhookHandler) AdminCreate(c *gin.Context) {
+	h.create(c, c.Param("id"))
+}
+
+// AdminGetByID gets a webhook of a company
+func (h *WebhookHandler) AdminGetByID(c *gin.Context) {
+	h.getByID(c, c.Param("id"), c.Param("webhook_id"))
+}
+
+// AdminList lists the webhooks of a company
+func (h *WebhookHandler) AdminList(c *gin.Context) {
+	h.list(c, c.Param("id"))
+}
+
+// AdminUpdate updates a webhook of a company
+func (h *WebhookHandler) AdminUpdate(c *gin.Context) {
+	h.update(c, c.Param("id"), c.Param("webhook_id"))
+}
+
+// AdminDelete deletes a webhook of a company
+func (h *WebhookHandler) AdminDelete(c *gin.Context) {
+	h.delete(c, c.Param("id"), c.Param("webhook_id"))
+}
+
+func (h *WebhookHandler) create(c *gin.Context, companyID string) {
 	var req dto.CreateWebhookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	// Set the company ID from the context
+	// The company comes from the token or the path, never from the body
 	req.CompanyID = companyID
 
 	webhook, err := h.webhookUseCase.Create(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, webhook)
 }
 
-// GetByID gets a webhook by ID
-func (h *WebhookHandler) GetByID(c *gin.Context) {
-	id := c.Param("id")
+func (h *WebhookHandler) getByID(c *gin.Context, companyID, id string) {
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "webhook ID is required"})
+		respondError(c, http.StatusBadRequest, errWebhookIDRequired)
 		return
 	}
 
-	webhook, err := h.webhookUseCase.GetByID(c.Request.Context(), id)
+	webhook, err := h.webhookUseCase.GetByID(c.Request.Context(), id, companyID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, webhook)
 }
 
-// List lists webhooks for the authenticated company
-func (h *WebhookHandler) List(c *gin.Context) {
-	companyID := c.GetString("company_id")
-	if companyID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return
-	}
-
+func (h *WebhookHandler) list(c *gin.Context, companyID string) {
 	limitStr := c.DefaultQuery("limit", "10")
 	offsetStr := c.DefaultQuery("offset", "0")
 
@@ -87,7 +151,7 @@ func (h *WebhookHandler) List(c *gin.Context) {
 
 	response, err := h.webhookUseCase.List(c.Request.Context(), companyID, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -99,40 +163,36 @@ func (h *WebhookHandler) List(c *gin.Context) {
 	})
 }
 
-// Update updates a webhook
-func (h *WebhookHandler) Update(c *gin.Context) {
-	id := c.Param("id")
+func (h *WebhookHandler) update(c *gin.Context, companyID, id string) {
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "webhook ID is required"})
+		respondError(c, http.StatusBadRequest, errWebhookIDRequired)
 		return
 	}
 
 	var req dto.UpdateWebhookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	err := h.webhookUseCase.Update(c.Request.Context(), id, req)
+	err := h.webhookUseCase.Update(c.Request.Context(), id, companyID, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "webhook updated successfully"})
 }
 
-// Delete deletes a webhook
-func (h *WebhookHandler) Delete(c *gin.Context) {
-	id := c.Param("id")
+func (h *WebhookHandler) delete(c *gin.Context, companyID, id string) {
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "webhook ID is required"})
+		respondError(c, http.StatusBadRequest, errWebhookIDRequired)
 		return
 	}
 
-	err := h.webhookUseCase.Delete(c.Request.Context(), id)
+	err := h.webhookUseCase.Delete(c.Request.Context(), id, companyID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+)
+
+// recordingWebhookUseCase records the company each call was scoped to
+type recordingWebhookUseCase struct {
+	usecase.WebhookUseCase
+	companyIDs []string
+}
+
+func (uc *recordingWebhookUseCase) Create(_ context.Context, req dto.CreateWebhookRequest) (*dto.WebhookDTO, error) {
+	uc.companyIDs = append(uc.companyIDs, req.CompanyID)
+	return &dto.WebhookDTO{CompanyID: req.CompanyID}, nil
+}
+
+func (uc *recordingWebhookUseCase) GetByID(_ context.Context, id, companyID string) (*dto.WebhookDTO, error) {
+	uc.companyIDs = append(uc.companyIDs, companyID)
+	if companyID != "company-a" {
+		return nil, usecase.ErrWebhookNotFound
+	}
+	return &dto.WebhookDTO{ID: id, CompanyID: companyID}, nil
+}
+
+func (uc *recordingWebhookUseCase) List(_ context.Context, companyID string, _, _ int) (*dto.WebhookListResponse, error) {
+	uc.companyIDs = append(uc.companyIDs, companyID)
+	return &dto.WebhookListResponse{}, nil
+}
+
+func (uc *recordingWebhookUseCase) Update(_ context.Context, _, companyID string, _ dto.UpdateWebhookRequest) error {
+	uc.companyIDs = append(uc.companyIDs, companyID)
+	return nil
+}
+
+func (uc *recordingWebhookUseCase) Delete(_ context.Context, _, companyID string) error {
+	uc.companyIDs = append(uc.companyIDs, companyID)
+	return nil
+}
+
+// newWebhookRouter mounts the handler as the router does, with the admin token setting only
+// admin_id and the company API key setting company_id
+func newWebhookRouter(uc usecase.WebhookUseCase) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewWebhookHandler(uc)
+	r := gin.New()
+
+	companies := r.Group("/api/admin/companies", func(c *gin.Context) { c.Set("admin_id", "admin-1") })
+	companies.GET("/:id/webhooks", h.AdminList)
+	companies.POST("/:id/webhooks", h.AdminCreate)
+	companies.GET("/:id/webhooks/:webhook_id", h.AdminGetByID)
+	companies.PUT("/:id/webhooks/:webhook_id", h.AdminUpdate)
+	companies.DELETE("/:id/webhooks/:webhook_id", h.AdminDelete)
+
+	webhooks := r.Group("/api/v1/webhooks", func(c *gin.Context) {
+		if companyID := c.GetHeader("X-Test-Company"); companyID != "" {
+			c.Set("company_id", companyID)
+		}
+	})
+	webhooks.GET("/:id", h.GetByID)
+	webhooks.DELETE("/:id", h.Delete)
+	return r
+}
+
+func TestWebhookHandlerAdminRoutesUseCompanyFromPath(t *testing.T) {
+	createBody := `{"name":"ERP","url":"https://erp.example.com/hook","events":["nfce.authorized"]}`
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/api/admin/companies/company-a/webhooks", "", http.StatusOK},
+		{http.MethodPost, "/api/admin/companies/company-a/webhooks", createBody, http.StatusCreated},
+		{http.MethodGet, "/api/admin/companies/company-a/webhooks/wh-1", "", http.StatusOK},
+		{http.MethodPut, "/api/admin/companies/company-a/webhooks/wh-1", `{"name":"ERP 2"}`, http.StatusOK},
+		{http.MethodDelete, "/api/admin/companies/company-a/webhooks/wh-1", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			uc := &recordingWebhookUseCase{}
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			newWebhookRouter(uc).ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if len(uc.companyIDs) != 1 || uc.companyIDs[0] != "company-a" {
+				t.Fatalf("use case called for companies %v, want [company-a]", uc.companyIDs)
+			}
+		})
+	}
+}
+
+func TestWebhookHandlerCompanyRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		company string
+		want    int
+	}{
+		{"owner", http.MethodGet, "company-a", http.StatusOK},
+		{"other company", http.MethodGet, "company-b", http.StatusNotFound},
+		{"without company", http.MethodGet, "", http.StatusUnauthorized},
+		{"delete without company", http.MethodDelete, "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := &recordingWebhookUseCase{}
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/api/v1/webhooks/wh-1", nil)
+			req.Header.Set("X-Test-Company", tt.company)
+			newWebhookRouter(uc).ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.company == "" && len(uc.companyIDs) > 0 {
+				t.Fatalf("use case called without a company: %v", uc.companyIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+)
+
+// requestIDHeader carries the client or proxy correlation ID recorded in the audit log
+const requestIDHeader = "X-Request-ID"
+
+// AuditRecorder stores the audit entries of the write requests
+type AuditRecorder interface {
+	Record(ctx context.Context, entry *entity.AuditEntry) error
+}
+
+// Audit records every POST, PUT, PATCH and DELETE request, successful or not: the actor, the
+// route, the response status and the request metadata. The use cases add the resource they
+// changed with its before/after diff through the request context. Must run after
+// CompanyAuth or AdminAuth.
+func Audit(recorder AuditRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		var entry *entity.AuditEntry
+		action := c.Request.Method + " " + c.FullPath()
+		if adminID := c.GetString(AdminIDKey); adminID != "" {
+			entry = entity.NewAuditEntry(entity.AuditActorAdmin, adminID, action)
+		} else if companyID := c.GetString(CompanyIDKey); companyID != "" {
+			entry = entity.NewAuditEntry(entity.AuditActorCompany, companyID, action)
+			entry.CompanyID = &companyID
+		} else {
+			c.Next()
+			return
+		}
+		entry.IP = c.ClientIP()
+		entry.UserAgent = c.Request.UserAgent()
+		entry.RequestID = c.GetHeader(requestIDHeader)
+
+		c.Request = c.Request.WithContext(service.WithAuditEntry(c.Request.Context(), entry))
+		c.Next()
+
+		// The request context may be canceled once the response is written
+		entry.Status = c.Writer.Status()
+		_ = recorder.Record(context.WithoutCancel(c.Request.Context()), entry)
+	}
+}
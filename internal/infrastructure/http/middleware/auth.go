@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/apierror"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+)
+
+const (
+	// CompanyIDKey is the gin context key holding the company of the API key
+	CompanyIDKey = "company_id"
+	// AdminIDKey is the gin context key holding the admin of the token
+	AdminIDKey = "admin_id"
+
+	// apiKeyHeader carries the company API key; Authorization: Bearer is accepted too
+	apiKeyHeader = "X-API-Key"
+)
+
+// errMissingCredentials is the error of a request without an API key or token
+var errMissingCredentials = errors.New("unauthorized")
+
+// authErrorMappings answers the errors of the credentials presented
+var authErrorMappings = []apierror.Mapping{
+	{Target: usecase.ErrCompanyInactive, Status: http.StatusForbidden, Code: i18n.CodeCompanyInactive},
+	{Target: usecase.ErrInvalidAPIKey, Status: http.StatusUnauthorized, Code: i18n.CodeInvalidAPIKey},
+	{Target: usecase.ErrInvalidToken, Status: http.StatusUnauthorized, Code: i18n.CodeInvalidToken},
+}
+
+// Authenticator resolves the credentials presented to the API
+type Authenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (string, error)
+	AuthenticateAdmin(ctx context.Context, token string) (string, error)
+}
+
+// CompanyAuth requires a company API key and sets company_id for the handlers
+func CompanyAuth(authenticator Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(apiKeyHeader)
+		if key == "" {
+			key = bearerToken(c)
+		}
+		if key == "" {
+			abort(c, apierror.New(http.StatusUnauthorized, i18n.CodeUnauthorized, errMissingCredentials))
+			return
+		}
+
+		companyID, err := authenticator.AuthenticateAPIKey(c.Request.Context(), key)
+		if err != nil {
+			abort(c, apierror.Classify(err, authErrorMappings, http.StatusUnauthorized))
+			return
+		}
+
+		c.Set(CompanyIDKey, companyID)
+		c.Next()
+	}
+}
+
+// AdminAuth requires an admin token issued by POST /api/admin/login and sets admin_id
+func AdminAuth(authenticator Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			abort(c, apierror.New(http.StatusUnauthorized, i18n.CodeUnauthorized, errMissingCredentials))
+			return
+		}
+
+		adminID, err := authenticator.AuthenticateAdmin(c.Request.Context(), token)
+		if err != nil {
+			abort(c, apierror.Classify(err, authErrorMappings, http.StatusUnauthorized))
+			return
+		}
+
+		c.Set(AdminIDKey, adminID)
+		c.Next()
+	}
+}
+
+// bearerToken returns the credential of an "Authorization: Bearer" header
+func bearerToken(c *gin.Context) string {
+	scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/apierror"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+const (
+	// LangKey is the gin context key holding the negotiated i18n.Lang
+	LangKey = "lang"
+	// TraceIDKey is the gin context key holding the trace ID of the request
+	TraceIDKey = "trace_id"
+)
+
+// HandleErrors answers every failed request with the apierror.Envelope:
+//
+//	{"code": "nfce_not_found", "message": "NFC-e não encontrada", "details": [], "trace_id": "..."}
+//
+// The handlers and middlewares report the failure with c.Error of an *apierror.Error and the
+// status, writing no body; the envelope gets the code of the error, or the generic code of
+// the status when it has none, with the catalog message in the language negotiated from
+// Accept-Language. The text of the error is never returned: 5xx errors, and 4xx errors
+// without a code, are logged with the trace ID. The trace ID is the X-Request-ID sent, or a
+// new one, and goes back in the X-Request-ID header of every response; the request context
+// carries a logger with it.
+func HandleErrors(l logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set(LangKey, lang)
+		c.Header("Content-Language", string(lang))
+
+		traceID := c.GetHeader(requestIDHeader)
+		if traceID == "" || len(traceID) > 128 {
+			traceID = uuid.New().String()
+			c.Request.Header.Set(requestIDHeader, traceID)
+		}
+		c.Set(TraceIDKey, traceID)
+		c.Header(requestIDHeader, traceID)
+		// Handlers log through the request context with the trace ID
+		requestLogger := logger.FromContext(c.Request.Context(), l).With(logger.RequestID(traceID))
+		c.Request = c.Request.WithContext(logger.NewContext(c.Request.Context(), requestLogger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 400 || c.Writer.Written() {
+			return
+		}
+
+		var apiErr *apierror.Error
+		if last := c.Errors.Last(); last != nil {
+			apiErr, _ = apierror.As(last.Err)
+		}
+		c.JSON(status, envelope(apiErr, status, lang, traceID, requestLogger))
+	}
+}
+
+// abort stops the request with apiErr, answered by HandleErrors
+func abort(c *gin.Context, apiErr *apierror.Error) {
+	_ = c.Error(apiErr)
+	c.Status(apiErr.Status)
+	c.Abort()
+}
+
+// envelope builds the error envelope of apiErr, which is nil when the request failed without
+// an error, e.g. on an unknown route. The keys of Extra, such as quota_context or maintenance,
+// are answered next to the envelope; error repeats the message for the clients written before
+// the envelope.
+func envelope(apiErr *apierror.Error, status int, lang i18n.Lang, traceID string, l logger.Logger) map[string]interface{} {
+	payload := map[string]interface{}{}
+	code := i18n.ForStatus(status)
+	var args []interface{}
+	details := []apierror.Detail{}
+	if apiErr != nil {
+		for key, value := range apiErr.Extra {
+			payload[key] = value
+		}
+		if apiErr.Code != "" {
+			code, args = apiErr.Code, apiErr.Args
+		}
+		details = append(details, apiErr.Details...)
+
+		// The text of an error without a code stays in the log, so internals do not leak
+		switch {
+		case status >= 500:
+			l.Error("Request failed", logger.Field{Key: "status", Value: status}, logger.Field{Key: "error", Value: apiErr.Error()})
+		case apiErr.Code == "":
+			l.Warn("Request failed", logger.Field{Key: "status", Value: status}, logger.Field{Key: "error", Value: apiErr.Error()})
+		}
+	}
+
+	message := i18n.Message(code, lang, args...)
+	payload["code"] = code
+	payload["message"] = message
+	payload["error"] = message
+	payload["details"] = details
+	payload["trace_id"] = traceID
+	return payload
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/apierror"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// TestHandleErrorsEnvelope answers the errors reported with c.Error from their code, in the
+// negotiated language, without the text of the error
+func TestHandleErrorsEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		path     string
+		lang     string
+		err      *apierror.Error
+		status   int
+		want     map[string]interface{}
+		wantKeys map[string]interface{}
+	}{
+		{
+			name:   "code in Portuguese",
+			lang:   "pt-BR",
+			err:    apierror.New(http.StatusNotFound, i18n.CodeNFCeNotFound, errors.New("NFC-e not found")),
+			status: http.StatusNotFound,
+			want:   map[string]interface{}{"code": "nfce_not_found", "message": "NFC-e não encontrada"},
+		},
+		{
+			name:   "code in English",
+			lang:   "en",
+			err:    apierror.New(http.StatusNotFound, i18n.CodeNFCeNotFound, errors.New("NFC-e not found")),
+			status: http.StatusNotFound,
+			want:   map[string]interface{}{"code": "nfce_not_found", "message": "NFC-e not found"},
+		},
+		{
+			name:   "args of the message",
+			lang:   "en",
+			err:    apierror.New(http.StatusRequestEntityTooLarge, i18n.CodeRequestTooLarge, errors.New("http: request body too large")).WithArgs(int64(10)),
+			status: http.StatusRequestEntityTooLarge,
+			want:   map[string]interface{}{"code": "request_too_large", "message": "request body exceeds 10 bytes"},
+		},
+		{
+			name:     "extra keys next to the envelope",
+			lang:     "en",
+			err:      apierror.New(http.StatusConflict, i18n.CodeIdempotencyKeyReused, errors.New("reused")).With("resource", map[string]interface{}{"id": "1"}),
+			status:   http.StatusConflict,
+			want:     map[string]interface{}{"code": "idempotency_key_reused"},
+			wantKeys: map[string]interface{}{"resource": map[string]interface{}{"id": "1"}},
+		},
+		{
+			name:   "4xx without a code does not leak its text",
+			lang:   "en",
+			err:    apierror.New(http.StatusUnprocessableEntity, "", errors.New("pq: relation nfces does not exist")),
+			status: http.StatusUnprocessableEntity,
+			want:   map[string]interface{}{"code": "unprocessable_entity", "message": "Unprocessable request"},
+		},
+		{
+			name:   "5xx does not leak its text",
+			lang:   "en",
+			err:    apierror.New(http.StatusInternalServerError, "", errors.New("dial tcp 10.0.0.1:5432: connection refused")),
+			status: http.StatusInternalServerError,
+			want:   map[string]interface{}{"code": "internal_error", "message": "Internal error"},
+		},
+		{
+			name:   "unknown route",
+			path:   "/missing",
+			lang:   "en",
+			status: http.StatusNotFound,
+			want:   map[string]interface{}{"code": "not_found", "message": "Resource not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(HandleErrors(logger.NewNopLogger()))
+			r.GET("/fail", func(c *gin.Context) { abort(c, tt.err) })
+
+			path := tt.path
+			if path == "" {
+				path = "/fail"
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Accept-Language", tt.lang)
+			req.Header.Set(requestIDHeader, "trace-1")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q is not JSON: %v", w.Body.String(), err)
+			}
+			for key, want := range tt.want {
+				if body[key] != want {
+					t.Errorf("%s = %v, want %v", key, body[key], want)
+				}
+			}
+			if body["error"] != body["message"] {
+				t.Errorf("error = %v, want the message %v", body["error"], body["message"])
+			}
+			if body["trace_id"] != "trace-1" {
+				t.Errorf("trace_id = %v, want trace-1", body["trace_id"])
+			}
+			if details, ok := body["details"].([]interface{}); !ok || len(details) != 0 {
+				t.Errorf("details = %v, want []", body["details"])
+			}
+			for key, want := range tt.wantKeys {
+				if !reflect.DeepEqual(body[key], want) {
+					t.Errorf("%s = %v, want %v", key, body[key], want)
+				}
+			}
+		})
+	}
+}
+
+// TestWrappedWritersReachTheConnection lifts the write deadline through the writers the
+// middlewares wrap around the gin writer, as the event stream and the export do
+func TestWrappedWritersReachTheConnection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorded := func(c *gin.Context) {
+		c.Writer = &responseRecorder{ResponseWriter: c.Writer}
+		c.Next()
+	}
+
+	r := gin.New()
+	r.GET("/stream", HandleErrors(logger.NewNopLogger()), recorded, func(c *gin.Context) {
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("SetWriteDeadline() through the wrapped writers failed: status %d", resp.StatusCode)
+	}
+}
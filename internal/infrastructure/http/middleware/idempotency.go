@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/usecase"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/apierror"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+)
+
+const (
+	// idempotencyKeyHeader identifies a request across client retries
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks a response replayed from a previous request
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength matches the idempotency_key columns
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize caps the body read to fingerprint a request, with room for a lote
+	// of 50 NFC-e
+	maxIdempotentBodySize = 10 << 20 // 10 MiB
+)
+
+// IdempotencyStore reserves the Idempotency-Key of a company and keeps the response
+type IdempotencyStore interface {
+	Begin(ctx context.Context, companyID, key, requestHash string) (*entity.IdempotencyKey, error)
+	Complete(ctx context.Context, record *entity.IdempotencyKey, status int, body []byte) error
+	Release(ctx context.Context, record *entity.IdempotencyKey) error
+}
+
+// responseRecorder keeps a copy of the body written by the handler
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write copies the body and passes it through
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString copies the body and passes it through
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to lift the write deadline
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Idempotency requires the Idempotency-Key header, unique per company until it expires.
+// A retry with the same key and payload replays the stored response with
+// Idempotent-Replayed: true; a different payload gets 409 with the existing resource:
+//
+//	{"code": "idempotency_key_reused", "message": "...", "details": [], "trace_id": "...", "resource": {...}}
+//
+// Only successful responses are kept: the key of a failed request, or of a handler that
+// panicked, is released so the client can retry it. Bodies over 10 MiB get 413. Must run
+// after CompanyAuth.
+func Idempotency(store IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			abort(c, apierror.BadRequest(i18n.CodeIdempotencyKeyRequired, errors.New("Idempotency-Key header is required")))
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abort(c, apierror.BadRequest(i18n.CodeIdempotencyKeyTooLong, errors.New("Idempotency-Key header is too long")))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				abort(c, apierror.New(http.StatusRequestEntityTooLarge, i18n.CodeRequestTooLarge, err).WithArgs(tooLarge.Limit))
+				return
+			}
+			abort(c, apierror.BadRequest(i18n.CodeInvalidRequest, err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		record, err := store.Begin(ctx, c.GetString(CompanyIDKey), key, requestHash(c.Request.Method, c.Request.URL.Path, body))
+		switch {
+		case errors.Is(err, usecase.ErrIdempotencyKeyReused):
+			conflict := apierror.New(http.StatusConflict, i18n.CodeIdempotencyKeyReused, err)
+			if record != nil && record.Completed() && json.Valid(record.ResponseBody) {
+				conflict.With("resource", json.RawMessage(record.ResponseBody))
+			}
+			abort(c, conflict)
+			return
+		case errors.Is(err, usecase.ErrIdempotencyKeyInProgress):
+			abort(c, apierror.New(http.StatusConflict, i18n.CodeIdempotencyInProgress, err))
+			return
+		case err != nil:
+			abort(c, apierror.New(http.StatusInternalServerError, "", err))
+			return
+		}
+
+		if record.Completed() {
+			c.Header(idempotentReplayedHeader, "true")
+			c.Data(record.ResponseStatus, "application/json; charset=utf-8", record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		handled := false
+		defer func() {
+			if !handled {
+				// The handler panicked: the key is released so the client can retry it, and the
+				// panic goes on to the recovery middleware
+				_ = store.Release(context.WithoutCancel(ctx), record)
+			}
+		}()
+		c.Next()
+		handled = true
+		c.Writer = recorder.ResponseWriter
+
+		// The request context may be canceled once the response is written
+		ctx = context.WithoutCancel(ctx)
+		status := recorder.Status()
+		if status >= 200 && status < 300 {
+			_ = store.Complete(ctx, record, status, recorder.body.Bytes())
+		} else {
+			_ = store.Release(ctx, record)
+		}
+	}
+}
+
+// requestHash fingerprints a request by method, path and body
+func requestHash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+)
+
+// fakeIdempotencyStore counts the reservations and their outcome
+type fakeIdempotencyStore struct {
+	begun, completed, released int
+}
+
+func (s *fakeIdempotencyStore) Begin(_ context.Context, _, key, _ string) (*entity.IdempotencyKey, error) {
+	s.begun++
+	return &entity.IdempotencyKey{Key: key}, nil
+}
+
+func (s *fakeIdempotencyStore) Complete(_ context.Context, _ *entity.IdempotencyKey, _ int, _ []byte) error {
+	s.completed++
+	return nil
+}
+
+func (s *fakeIdempotencyStore) Release(_ context.Context, _ *entity.IdempotencyKey) error {
+	s.released++
+	return nil
+}
+
+func newIdempotencyRouter(store IdempotencyStore, h gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.CustomRecovery(func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	r.POST("/nfce", Idempotency(store), h)
+	return r
+}
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		handler       gin.HandlerFunc
+		wantStatus    int
+		wantBegun     int
+		wantCompleted int
+		wantReleased  int
+	}{
+		{
+			name:       "success is kept",
+			body:       `{"items":[]}`,
+			handler:    func(c *gin.Context) { c.JSON(http.StatusCreated, gin.H{"id": "1"}) },
+			wantStatus: http.StatusCreated, wantBegun: 1, wantCompleted: 1,
+		},
+		{
+			name:       "failure is released",
+			body:       `{"items":[]}`,
+			handler:    func(c *gin.Context) { c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid"}) },
+			wantStatus: http.StatusUnprocessableEntity, wantBegun: 1, wantReleased: 1,
+		},
+		{
+			name:       "panic is released",
+			body:       `{"items":[]}`,
+			handler:    func(*gin.Context) { panic("handler bug") },
+			wantStatus: http.StatusInternalServerError, wantBegun: 1, wantReleased: 1,
+		},
+		{
+			name:       "body over the limit",
+			body:       strings.Repeat("a", maxIdempotentBodySize+1),
+			handler:    func(c *gin.Context) { c.Status(http.StatusCreated) },
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeIdempotencyStore{}
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/nfce", strings.NewReader(tt.body))
+			req.Header.Set(idempotencyKeyHeader, "key-1")
+			newIdempotencyRouter(store, tt.handler).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if store.begun != tt.wantBegun || store.completed != tt.wantCompleted || store.released != tt.wantReleased {
+				t.Fatalf("begun %d, completed %d, released %d; want %d, %d, %d",
+					store.begun, store.completed, store.released, tt.wantBegun, tt.wantCompleted, tt.wantReleased)
+			}
+		})
+	}
+}
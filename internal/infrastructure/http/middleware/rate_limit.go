@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/apierror"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+)
+
+// errRateLimitExceeded is the error of a request over the limit of the plan
+var errRateLimitExceeded = errors.New("rate limit exceeded")
+
+// RateLimiter takes a token of the company bucket of a scope
+type RateLimiter interface {
+	Allow(ctx context.Context, companyID string, scope service.RateLimitScope) (service.RateLimitDecision, error)
+}
+
+// RateLimit limits the requests per minute of the company to the limit of its plan in scope,
+// sending X-RateLimit-Limit and X-RateLimit-Remaining. Over the limit the request gets 429
+// with Retry-After in seconds:
+//
+//	{"code": "rate_limit_exceeded", "message": "...", "details": [], "trace_id": "..."}
+//
+// A nil limiter disables it, and a store failure lets the request through. Must run after
+// CompanyAuth.
+func RateLimit(limiter RateLimiter, scope service.RateLimitScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		companyID := c.GetString(CompanyIDKey)
+		if limiter == nil || companyID == "" {
+			c.Next()
+			return
+		}
+
+		decision, err := limiter.Allow(c.Request.Context(), companyID, scope)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		if !decision.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+			abort(c, apierror.New(http.StatusTooManyRequests, i18n.CodeRateLimitExceeded, errRateLimitExceeded))
+			return
+		}
+		c.Next()
+	}
+}
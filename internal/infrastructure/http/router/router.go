@@ -2,7 +2,10 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/handler"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/middleware"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
 )
 
 // SetupRoutes configures all API routes
@@ -13,24 +16,90 @@ func SetupRoutes(
 	planHandler *handler.PlanHandler,
 	subscriptionHandler *handler.SubscriptionHandler,
 	webhookHandler *handler.WebhookHandler,
+	exportHandler *handler.ExportHandler,
+	inutilizacaoHandler *handler.InutilizacaoHandler,
+	seriesHandler *handler.SeriesHandler,
+	configHandler *handler.ConfigHandler,
+	schemaHandler *handler.SchemaHandler,
+	sefazTablesHandler *handler.SEFAZTablesHandler,
+	dfeHandler *handler.DFeHandler,
+	maintenanceHandler *handler.MaintenanceHandler,
+	apiKeyHandler *handler.APIKeyHandler,
+	emailHandler *handler.EmailHandler,
+	deadLetterHandler *handler.DeadLetterHandler,
+	auditHandler *handler.AuditHandler,
+	billingHandler *handler.BillingHandler,
+	cacheHandler *handler.CacheHandler,
+	graphqlHandler *handler.GraphQLHandler,
+	publicHandler *handler.PublicHandler,
+	healthHandler *handler.HealthHandler,
+	authenticator middleware.Authenticator,
+	idempotency middleware.IdempotencyStore,
+	audit middleware.AuditRecorder,
+	limiter middleware.RateLimiter,
+	l logger.Logger,
 ) *gin.Engine {
 	r := gin.Default()
 
-	// Health check
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Error messages in pt-BR or en, selected via Accept-Language
+	r.Use(middleware.HandleErrors(l))
 
-	// API v1 routes
-	v1 := r.Group("/api/v1")
+	// Health check, not rate limited
+	r.GET("/health", healthHandler.Check)
+
+	// Payment webhooks of the gateway charging the subscriptions, authenticated by their signature
+	if billingHandler != nil {
+		r.POST("/billing/webhooks/:provider", billingHandler.Webhook)
+	}
+
+	// Consulta of the NFC-e by the chave of its QR Code, for the apps of the consumers
+	if publicHandler != nil {
+		r.GET("/public/nfce/:chave", publicHandler.GetNFCe)
+	}
+
+	// API v1 routes, authenticated by the company API key and rate limited per company plan
+	v1 := r.Group("/api/v1",
+		middleware.CompanyAuth(authenticator),
+		middleware.RateLimit(limiter, service.RateLimitScopeRequests),
+		middleware.Audit(audit),
+	)
 	{
-		// Public NFC-e endpoints
+		// NFC-e endpoints
 		nfce := v1.Group("/nfce")
 		{
-			nfce.POST("", nfceHandler.EmitNFce)
+			emissionLimit := middleware.RateLimit(limiter, service.RateLimitScopeEmissions)
+			nfce.POST("", emissionLimit, middleware.Idempotency(idempotency), nfceHandler.EmitNFce)
+			nfce.POST("/lote", emissionLimit, middleware.Idempotency(idempotency), nfceHandler.EmitLote)
+			nfce.GET("/lote/:id", nfceHandler.GetLote)
+			nfce.GET("/stream", nfceHandler.StreamNFceEvents)
+			nfce.GET("", nfceHandler.ListNFces)
+			nfce.GET("/search", nfceHandler.SearchNFces)
+			if exportHandler != nil {
+				nfce.GET("/export", exportHandler.ExportNFCe)
+			}
+			if inutilizacaoHandler != nil {
+				nfce.POST("/inutilizacao", inutilizacaoHandler.Create)
+				nfce.GET("/inutilizacao", inutilizacaoHandler.List)
+				nfce.GET("/inutilizacao/:id", inutilizacaoHandler.GetByID)
+			}
+			if seriesHandler != nil {
+				nfce.GET("/series", seriesHandler.List)
+				nfce.POST("/series", seriesHandler.Create)
+				nfce.PUT("/series/:id", seriesHandler.Update)
+				nfce.PUT("/series/:id/default", seriesHandler.SetDefault)
+				nfce.GET("/series/:id/gaps", seriesHandler.GetGaps)
+			}
 			nfce.GET("/:id", nfceHandler.GetNFceByID)
 			nfce.POST("/:id/cancel", nfceHandler.CancelNFce)
 			nfce.GET("/:id/events", nfceHandler.GetNFceEvents)
+			nfce.GET("/:id/stream", nfceHandler.StreamNFceStatus)
+			nfce.GET("/:id/xml", nfceHandler.DownloadXML)
+			nfce.GET("/:id/pdf", nfceHandler.DownloadPDF)
+			nfce.GET("/:id/qrcode", nfceHandler.DownloadQRCode)
+			if emailHandler != nil {
+				nfce.POST("/:id/email", emailHandler.Send)
+				nfce.GET("/:id/emails", emailHandler.List)
+			}
 		}
 
 		// Company endpoints (for authenticated companies)
@@ -40,7 +109,19 @@ func SetupRoutes(
 			companies.PUT("/profile", companyHandler.UpdateProfile)
 			companies.PUT("/:id/certificate", companyHandler.UpdateCertificateByID)
 			companies.PUT("/certificate", companyHandler.UpdateCertificate)
+			companies.PUT("/certificate/kms", companyHandler.ConfigureKMSSigner)
+			companies.PUT("/resp-tec", companyHandler.ConfigureRespTec)
+			companies.DELETE("/resp-tec", companyHandler.ClearRespTec)
+			companies.PUT("/email-template", companyHandler.ConfigureEmailTemplate)
+			companies.DELETE("/email-template", companyHandler.ClearEmailTemplate)
+			companies.PUT("/emission-settings", companyHandler.ConfigureEmissionSettings)
+			companies.DELETE("/emission-settings", companyHandler.ClearEmissionSettings)
 			companies.PUT("/csc", companyHandler.UpdateCSC)
+			companies.GET("/cscs", companyHandler.ListCSCs)
+			companies.POST("/cscs", companyHandler.CreateCSC)
+			companies.PUT("/cscs/:id", companyHandler.ReplaceCSC)
+			companies.DELETE("/cscs/:id", companyHandler.DeleteCSC)
+			companies.POST("/:id/verify", companyHandler.Verify)
 		}
 
 		// Subscription endpoints (for authenticated companies)
@@ -48,6 +129,10 @@ func SetupRoutes(
 		if subscriptionHandler != nil {
 			subscriptions.GET("/current", subscriptionHandler.GetCurrent)
 			subscriptions.GET("/usage", subscriptionHandler.GetUsage)
+			subscriptions.GET("/statements", subscriptionHandler.ListStatements)
+			subscriptions.GET("/statements/:period", subscriptionHandler.GetStatement)
+			subscriptions.GET("/invoices", subscriptionHandler.ListInvoices)
+			subscriptions.POST("/:id/change-plan", subscriptionHandler.ChangePlan)
 		}
 
 		// Webhook endpoints (for authenticated companies)
@@ -59,16 +144,53 @@ func SetupRoutes(
 			webhooks.PUT("/:id", webhookHandler.Update)
 			webhooks.DELETE("/:id", webhookHandler.Delete)
 		}
+
+		// Bulk export endpoints (for authenticated companies)
+		exports := v1.Group("/exports")
+		if exportHandler != nil {
+			exports.POST("", exportHandler.Create)
+			exports.GET("", exportHandler.List)
+			exports.GET("/:id", exportHandler.GetByID)
+			exports.GET("/:id/parts/:part", exportHandler.DownloadPart)
+		}
+
+		// NFeDistribuicaoDFe endpoints (for authenticated companies)
+		dfe := v1.Group("/dfe")
+		if dfeHandler != nil {
+			dfe.POST("/sync", dfeHandler.Sync)
+			dfe.GET("/status", dfeHandler.Status)
+			dfe.GET("/documents", dfeHandler.ListDocuments)
+			dfe.GET("/documents/:id", dfeHandler.GetDocument)
+			dfe.GET("/documents/:id/xml", dfeHandler.DownloadXML)
+		}
+
+		// API key endpoints (for authenticated companies)
+		apiKeys := v1.Group("/api-keys")
+		if apiKeyHandler != nil {
+			apiKeys.GET("", apiKeyHandler.List)
+			apiKeys.POST("", apiKeyHandler.Create)
+			apiKeys.POST("/:id/rotate", apiKeyHandler.Rotate)
+			apiKeys.DELETE("/:id", apiKeyHandler.Revoke)
+		}
+
+		// Read-only GraphQL over the NFC-e, scoped to the authenticated company
+		if graphqlHandler != nil {
+			v1.GET("/graphql", graphqlHandler.Query)
+			v1.POST("/graphql", graphqlHandler.Query)
+			v1.GET("/graphql/schema", graphqlHandler.Schema)
+		}
 	}
 
 	// Admin API routes
-	admin := r.Group("/api/admin")
-	{
-		// Admin authentication (if admin handler exists)
-		if adminHandler != nil {
-			admin.POST("/login", adminHandler.Login)
-		}
+	adminAPI := r.Group("/api/admin")
+
+	// Admin authentication (if admin handler exists), the only route without a token
+	if adminHandler != nil {
+		adminAPI.POST("/login", adminHandler.Login)
+	}
 
+	admin := adminAPI.Group("", middleware.AdminAuth(authenticator), middleware.Audit(audit))
+	{
 		// Company management
 		companies := admin.Group("/companies")
 		if adminHandler != nil {
@@ -76,8 +198,25 @@ func SetupRoutes(
 			companies.GET("", adminHandler.ListCompanies)
 			companies.GET("/:id", adminHandler.GetCompany)
 			companies.PUT("/:id", adminHandler.UpdateCompany)
-			companies.PUT("/:id/certificate", adminHandler.UpdateCompanyCertificate)
-			companies.PUT("/:id/csc", adminHandler.UpdateCompanyCSC)
+			companies.DELETE("/:id", adminHandler.DeleteCompany)
+		}
+		if companyHandler != nil {
+			companies.PUT("/:id/certificate", companyHandler.AdminUpdateCertificate)
+			companies.PUT("/:id/csc", companyHandler.AdminUpdateCSC)
+			companies.POST("/:id/verify", companyHandler.AdminVerify)
+		}
+		if apiKeyHandler != nil {
+			companies.GET("/:id/api-keys", apiKeyHandler.AdminList)
+			companies.POST("/:id/api-keys", apiKeyHandler.AdminCreate)
+			companies.POST("/:id/api-keys/:key_id/rotate", apiKeyHandler.AdminRotate)
+			companies.DELETE("/:id/api-keys/:key_id", apiKeyHandler.AdminRevoke)
+		}
+		if webhookHandler != nil {
+			companies.GET("/:id/webhooks", webhookHandler.AdminList)
+			companies.POST("/:id/webhooks", webhookHandler.AdminCreate)
+			companies.GET("/:id/webhooks/:webhook_id", webhookHandler.AdminGetByID)
+			companies.PUT("/:id/webhooks/:webhook_id", webhookHandler.AdminUpdate)
+			companies.DELETE("/:id/webhooks/:webhook_id", webhookHandler.AdminDelete)
 		}
 
 		// Plan management
@@ -98,28 +237,68 @@ func SetupRoutes(
 			subscriptions.GET("/:id", subscriptionHandler.GetByID)
 			subscriptions.PUT("/:id", subscriptionHandler.Update)
 			subscriptions.DELETE("/:id", subscriptionHandler.Cancel)
-		}
-
-		// Webhook management
-		webhooks := admin.Group("/webhooks")
-		if webhookHandler != nil {
-			webhooks.POST("", webhookHandler.Create)
-			webhooks.GET("", webhookHandler.List)
-			webhooks.GET("/:id", webhookHandler.GetByID)
-			webhooks.PUT("/:id", webhookHandler.Update)
-			webhooks.DELETE("/:id", webhookHandler.Delete)
+			subscriptions.POST("/:id/change-plan", subscriptionHandler.ChangePlan)
 		}
 
 		// NFC-e management
 		nfceAdmin := admin.Group("/nfce")
 		if adminHandler != nil {
 			nfceAdmin.GET("", adminHandler.ListNFCE)
+			nfceAdmin.GET("/:id/sefaz-exchanges", adminHandler.DownloadSEFAZExchanges)
+			nfceAdmin.POST("/:id/reprocess", adminHandler.ReprocessNFCe)
 		}
 
 		// Statistics
 		if adminHandler != nil {
 			admin.GET("/stats", adminHandler.GetStats)
 		}
+
+		// Runtime configuration (secrets masked)
+		if configHandler != nil {
+			admin.GET("/config", configHandler.Get)
+		}
+
+		// SEFAZ XSD schemas
+		if schemaHandler != nil {
+			admin.GET("/schemas", schemaHandler.Status)
+			admin.POST("/schemas/update", schemaHandler.Update)
+		}
+
+		// SEFAZ endpoint and QR Code tables of this instance
+		if sefazTablesHandler != nil {
+			admin.GET("/sefaz-tables", sefazTablesHandler.Status)
+			admin.POST("/sefaz-tables/reload", sefazTablesHandler.Reload)
+		}
+
+		// Maintenance mode: new emissions are refused while a window is active
+		if maintenanceHandler != nil {
+			admin.GET("/maintenance", maintenanceHandler.Status)
+			admin.POST("/maintenance", maintenanceHandler.Start)
+			admin.DELETE("/maintenance", maintenanceHandler.End)
+		}
+
+		// Emission messages moved to the dead-letter queue after exhausting their deliveries
+		if deadLetterHandler != nil {
+			admin.GET("/dead-letters", deadLetterHandler.List)
+			admin.POST("/dead-letters/requeue", deadLetterHandler.Requeue)
+		}
+
+		// Audit log of the writes made by admins and companies
+		if auditHandler != nil {
+			admin.GET("/audit", auditHandler.List)
+		}
+
+		// Hit ratio of the company and certificate cache of this instance
+		if cacheHandler != nil {
+			admin.GET("/cache", cacheHandler.Stats)
+		}
+
+		// Read-only GraphQL over the NFC-e of every company
+		if graphqlHandler != nil {
+			admin.GET("/graphql", graphqlHandler.Query)
+			admin.POST("/graphql", graphqlHandler.Query)
+			admin.GET("/graphql/schema", graphqlHandler.Schema)
+		}
 	}
 
 	return r
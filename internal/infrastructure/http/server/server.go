@@ -2,25 +2,36 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/clock"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/grpcserver"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/handler"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/middleware"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/http/router"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
 )
 
+// ShutdownHook releases a resource of the API once the in-flight requests finished
+type ShutdownHook struct {
+	Name string // Step shown in the logs
+	Run  func(ctx context.Context) error
+}
+
 // Server represents the HTTP server
 type Server struct {
-	engine *gin.Engine
-	port   string
-	logger logger.Logger
-	server *http.Server
+	engine        *gin.Engine
+	port          string
+	logger        logger.Logger
+	server        *http.Server
+	clock         *clock.Monitor
+	stopClock     context.CancelFunc
+	shutdownHooks []ShutdownHook
+	grpc          *grpcserver.Server // nil when the gRPC API is disabled
 }
 
 // NewServer creates a new HTTP server
@@ -31,6 +42,29 @@ func NewServer(
 	planHandler *handler.PlanHandler,
 	subscriptionHandler *handler.SubscriptionHandler,
 	webhookHandler *handler.WebhookHandler,
+	exportHandler *handler.ExportHandler,
+	inutilizacaoHandler *handler.InutilizacaoHandler,
+	seriesHandler *handler.SeriesHandler,
+	configHandler *handler.ConfigHandler,
+	schemaHandler *handler.SchemaHandler,
+	sefazTablesHandler *handler.SEFAZTablesHandler,
+	dfeHandler *handler.DFeHandler,
+	maintenanceHandler *handler.MaintenanceHandler,
+	apiKeyHandler *handler.APIKeyHandler,
+	emailHandler *handler.EmailHandler,
+	deadLetterHandler *handler.DeadLetterHandler,
+	auditHandler *handler.AuditHandler,
+	billingHandler *handler.BillingHandler,
+	cacheHandler *handler.CacheHandler,
+	graphqlHandler *handler.GraphQLHandler,
+	publicHandler *handler.PublicHandler,
+	authenticator middleware.Authenticator,
+	idempotency middleware.IdempotencyStore,
+	audit middleware.AuditRecorder,
+	limiter middleware.RateLimiter,
+	clockMonitor *clock.Monitor,
+	grpcServer *grpcserver.Server,
+	shutdownHooks []ShutdownHook,
 	logger logger.Logger,
 	port string,
 ) *Server {
@@ -45,59 +79,105 @@ func NewServer(
 		planHandler,
 		subscriptionHandler,
 		webhookHandler,
+		exportHandler,
+		inutilizacaoHandler,
+		seriesHandler,
+		configHandler,
+		schemaHandler,
+		sefazTablesHandler,
+		dfeHandler,
+		maintenanceHandler,
+		apiKeyHandler,
+		emailHandler,
+		deadLetterHandler,
+		auditHandler,
+		billingHandler,
+		cacheHandler,
+		graphqlHandler,
+		publicHandler,
+		handler.NewHealthHandler(clockMonitor),
+		authenticator,
+		idempotency,
+		audit,
+		limiter,
+		logger,
 	)
 
-	return &Server{
-		engine: engine,
-		port:   port,
-		logger: logger,
-	}
-}
-
-// Start starts the HTTP server
-func (s *Server) Start(ctx context.Context) error {
-	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%s", s.port),
-		Handler:      s.engine,
+	httpServer := &http.Server{
+		Addr:         fmt.Sprintf(":%s", port),
+		Handler:      engine,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	// The event streams never go idle, so they are ended when the shutdown starts
+	if nfceHandler != nil {
+		httpServer.RegisterOnShutdown(nfceHandler.CloseStreams)
+	}
 
-	// Channel to listen for interrupt signal
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-
-	// Start server in a goroutine
-	go func() {
-		s.logger.Info("Starting HTTP server", logger.Field{Key: "port", Value: s.port})
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.logger.Error("Failed to start server", logger.Field{Key: "error", Value: err.Error()})
-			os.Exit(1)
-		}
-	}()
+	return &Server{
+		engine:        engine,
+		port:          port,
+		logger:        logger,
+		server:        httpServer,
+		clock:         clockMonitor,
+		shutdownHooks: shutdownHooks,
+		grpc:          grpcServer,
+	}
+}
 
-	// Wait for interrupt signal
-	<-done
-	s.logger.Info("Shutting down server...")
+// Start serves HTTP, and gRPC when enabled, until Stop is called
+func (s *Server) Start(ctx context.Context) error {
+	// Measure the host clock skew reported by the health check
+	clockCtx, stopClock := context.WithCancel(ctx)
+	s.stopClock = stopClock
+	go s.clock.Run(clockCtx)
 
-	// Graceful shutdown with timeout
-	shutdownCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+	// The gRPC API listens first, so a busy port fails the startup too
+	if s.grpc != nil {
+		if err := s.grpc.Start(); err != nil {
+			return err
+		}
+	}
 
-	if err := s.server.Shutdown(shutdownCtx); err != nil {
-		s.logger.Error("Server forced to shutdown", logger.Field{Key: "error", Value: err.Error()})
+	s.logger.Info("Starting HTTP server", logger.Field{Key: "port", Value: s.port})
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
-
-	s.logger.Info("Server exited")
 	return nil
 }
 
-// Stop stops the HTTP server
+// Stop drains the server: it stops accepting connections, waits for the in-flight requests
+// and gRPC calls until ctx ends and then runs the shutdown hooks in order, e.g. flushing the
+// outbox before the broker and database connections close. A failed hook does not stop the
+// next ones.
 func (s *Server) Stop(ctx context.Context) error {
-	if s.server != nil {
-		return s.server.Shutdown(ctx)
+	s.logger.Info("Shutting down server...")
+
+	var errs []error
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.Error("Server forced to shutdown", logger.Field{Key: "error", Value: err.Error()})
+		errs = append(errs, err)
 	}
-	return nil
+	if s.grpc != nil {
+		if err := s.grpc.Stop(ctx); err != nil {
+			s.logger.Error("gRPC server forced to shutdown", logger.Field{Key: "error", Value: err.Error()})
+			errs = append(errs, err)
+		}
+	}
+	if s.stopClock != nil {
+		s.stopClock()
+	}
+
+	for _, hook := range s.shutdownHooks {
+		if err := hook.Run(ctx); err != nil {
+			s.logger.Error("Shutdown step failed",
+				logger.Field{Key: "step", Value: hook.Name},
+				logger.Field{Key: "error", Value: err.Error()})
+			errs = append(errs, fmt.Errorf("%s: %w", hook.Name, err))
+		}
+	}
+
+	s.logger.Info("Server exited")
+	return errors.Join(errs...)
 }
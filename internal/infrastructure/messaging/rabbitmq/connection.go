@@ -0,0 +1,201 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// reconnectMinDelay is the first wait before dialing again after the broker went away
+	reconnectMinDelay = time.Second
+	// reconnectMaxDelay caps the exponential backoff between dial attempts
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// workQueues are the queues of the nfce.exchange, each bound with its own name as routing key
+var workQueues = []string{"nfce.emit", "nfce.cancel", "nfce.export", "nfce.inutilizacao", "nfce.lote"}
+
+// declareTopology declares the exchange, the work queues with their bindings, the retry
+// delay queues and the dead-letter topology, shared by publisher and consumer
+func declareTopology(channel *amqp.Channel) error {
+	err := channel.ExchangeDeclare(
+		"nfce.exchange", // name
+		"direct",        // type
+		true,            // durable
+		false,           // auto-deleted
+		false,           // internal
+		false,           // no-wait
+		nil,             // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	for _, queue := range workQueues {
+		_, err := channel.QueueDeclare(
+			queue, // name
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			nil,   // arguments
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare %s queue: %w", queue, err)
+		}
+
+		if err := channel.QueueBind(queue, queue, "nfce.exchange", false, nil); err != nil {
+			return fmt.Errorf("failed to bind %s queue: %w", queue, err)
+		}
+	}
+
+	if err := declareDelayQueues(channel); err != nil {
+		return err
+	}
+
+	return declareDeadLetter(channel)
+}
+
+// dialConfirmed connects to RabbitMQ, declares the topology and opens a channel in confirm
+// mode, so every publish is acknowledged by the broker
+func dialConfirmed(url string) (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := declareTopology(channel); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	return conn, channel, nil
+}
+
+// connection keeps a confirmed channel to RabbitMQ. When the connection or the channel
+// closes, e.g. on a broker restart, it dials again in the background with exponential backoff.
+type connection struct {
+	name    string // Owner shown in the logs
+	url     string
+	mu      sync.RWMutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	closed  chan struct{}
+}
+
+// newConnection dials the broker; the first dial must succeed
+func newConnection(name, url string) (*connection, error) {
+	conn, channel, err := dialConfirmed(url)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &connection{
+		name:    name,
+		url:     url,
+		conn:    conn,
+		channel: channel,
+		closed:  make(chan struct{}),
+	}
+	go c.watch(conn, channel)
+
+	return c, nil
+}
+
+// watch waits for the connection or the channel to close and reconnects
+func (c *connection) watch(conn *amqp.Connection, channel *amqp.Channel) {
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+
+	var amqpErr *amqp.Error
+	select {
+	case <-c.closed:
+		return
+	case amqpErr = <-connClosed:
+	case amqpErr = <-channelClosed:
+	}
+	if amqpErr != nil {
+		log.Printf("RabbitMQ %s connection lost: %v", c.name, amqpErr)
+	}
+
+	c.mu.Lock()
+	c.conn = nil
+	c.channel = nil
+	c.mu.Unlock()
+	conn.Close() // A closed channel leaves the connection open
+
+	delay := reconnectMinDelay
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-time.After(delay):
+		}
+
+		conn, channel, err := dialConfirmed(c.url)
+		if err != nil {
+			log.Printf("RabbitMQ %s reconnect failed: %v", c.name, err)
+			delay = min(delay*2, reconnectMaxDelay)
+			continue
+		}
+
+		c.mu.Lock()
+		c.conn = conn
+		c.channel = channel
+		c.mu.Unlock()
+
+		log.Printf("RabbitMQ %s reconnected", c.name)
+		go c.watch(conn, channel)
+		return
+	}
+}
+
+// healthy reports whether the channel is currently usable
+func (c *connection) healthy() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn != nil && !c.conn.IsClosed() && c.channel != nil && !c.channel.IsClosed()
+}
+
+// currentChannel returns the open channel or ErrBrokerUnavailable while reconnecting
+func (c *connection) currentChannel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.channel == nil || c.channel.IsClosed() {
+		return nil, dto.ErrBrokerUnavailable
+	}
+	return c.channel, nil
+}
+
+// close stops the reconnection and closes the connection
+func (c *connection) close() error {
+	close(c.closed)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.channel != nil {
+		c.channel.Close()
+	}
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
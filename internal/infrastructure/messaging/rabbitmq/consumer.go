@@ -5,219 +5,348 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+const (
+	// consumeRetryDelay is the wait before registering a consumer again on a new channel
+	consumeRetryDelay = time.Second
+	// prefetchPerLane is how many unacknowledged deliveries each processing lane may hold
+	prefetchPerLane = 4
+)
+
 // consumer implements Consumer interface
 type consumer struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
+	conn        *connection
+	maxAttempts int
+	concurrency int
 }
 
-// NewConsumer creates a new RabbitMQ consumer
-func NewConsumer(url string) (dto.Consumer, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
-	}
-
-	channel, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
-	}
-
-	// Declare exchange
-	err = channel.ExchangeDeclare(
-		"nfce.exchange", // name
-		"direct",        // type
-		true,            // durable
-		false,           // auto-deleted
-		false,           // internal
-		false,           // no-wait
-		nil,             // arguments
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare exchange: %w", err)
-	}
-
-	// Declare emit queue
-	queue, err := channel.QueueDeclare(
-		"nfce.emit", // name
-		true,        // durable
-		false,       // delete when unused
-		false,       // exclusive
-		false,       // no-wait
-		nil,         // arguments
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare emit queue: %w", err)
-	}
-
-	// Bind emit queue to exchange
-	err = channel.QueueBind(
-		queue.Name,      // queue name
-		"nfce.emit",     // routing key
-		"nfce.exchange", // exchange
-		false,
-		nil,
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to bind emit queue: %w", err)
-	}
-
-	// Declare cancel queue
-	cancelQueue, err := channel.QueueDeclare(
-		"nfce.cancel", // name
-		true,          // durable
-		false,         // delete when unused
-		false,         // exclusive
-		false,         // no-wait
-		nil,           // arguments
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare cancel queue: %w", err)
-	}
-
-	// Bind cancel queue to exchange
-	err = channel.QueueBind(
-		cancelQueue.Name, // queue name
-		"nfce.cancel",    // routing key
-		"nfce.exchange",  // exchange
-		false,
-		nil,
-	)
+// NewConsumer creates a new RabbitMQ consumer. Emission messages are processed on
+// concurrency lanes partitioned by company, and a message failing maxAttempts deliveries is
+// moved to the dead-letter queue. The connection is re-established in the background when
+// the broker goes away and the consume loops resume on the new channel.
+func NewConsumer(url string, maxAttempts, concurrency int) (dto.Consumer, error) {
+	conn, err := newConnection("consumer", url)
 	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to bind cancel queue: %w", err)
+		return nil, err
 	}
 
 	return &consumer{
-		conn:    conn,
-		channel: channel,
+		conn:        conn,
+		maxAttempts: maxAttempts,
+		concurrency: concurrency,
 	}, nil
 }
 
-// ConsumeEmit consumes NFC-e emission messages
-func (c *consumer) ConsumeEmit(ctx context.Context, handler func(context.Context, dto.EmitMessage) error) error {
-	msgs, err := c.channel.Consume(
-		"nfce.emit", // queue
-		"",          // consumer
-		false,       // auto-ack
-		false,       // exclusive
-		false,       // no-local
-		false,       // no-wait
-		nil,         // args
-	)
-	if err != nil {
-		return fmt.Errorf("failed to register consumer: %w", err)
+// consume hands the deliveries of queue to handle until ctx is done. When the channel
+// closes, the unacknowledged deliveries go back to the queue and the consumer is registered
+// again once the connection is re-established.
+func (c *consumer) consume(ctx context.Context, queue string, handle func(amqp.Delivery)) error {
+	for {
+		channel, err := c.conn.currentChannel()
+		if err == nil {
+			// Bound the unacknowledged deliveries, so a busy worker leaves messages to the others
+			err = channel.Qos(c.concurrency*prefetchPerLane, 0, false)
+		}
+		if err == nil {
+			var msgs <-chan amqp.Delivery
+			msgs, err = channel.Consume(
+				queue, // queue
+				"",    // consumer
+				false, // auto-ack
+				false, // exclusive
+				false, // no-local
+				false, // no-wait
+				nil,   // args
+			)
+			if err == nil {
+				if done := c.drain(ctx, msgs, handle); done {
+					return ctx.Err()
+				}
+				log.Printf("Consumer of %s lost its channel, waiting for reconnection", queue)
+				continue
+			}
+			log.Printf("Failed to register consumer of %s: %v", queue, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(consumeRetryDelay):
+		}
 	}
+}
 
+// drain handles the deliveries until the channel closes; it reports whether ctx is done
+func (c *consumer) drain(ctx context.Context, msgs <-chan amqp.Delivery, handle func(amqp.Delivery)) bool {
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return true
 		case d, ok := <-msgs:
 			if !ok {
-				return fmt.Errorf("message channel closed")
+				return false
 			}
+			handle(d)
+		}
+	}
+}
 
-			// Parse message
-			var msg dto.EmitMessage
-			if err := json.Unmarshal(d.Body, &msg); err != nil {
-				log.Printf("Failed to unmarshal message: %v", err)
-				d.Nack(false, false) // Don't requeue invalid messages
-				continue
-			}
+// ConsumeEmit consumes NFC-e emission messages. The messages of a company are handled one
+// at a time, so they do not race on the numbering series, while different companies are
+// handled in parallel.
+func (c *consumer) ConsumeEmit(ctx context.Context, handler func(context.Context, dto.EmitMessage) error) error {
+	lanes := newDispatcher(c.concurrency, c.concurrency*prefetchPerLane)
+	defer lanes.close()
 
-			// Handle message
-			if err := handler(ctx, msg); err != nil {
-				log.Printf("Handler error for message %s: %v", msg.RequestID, err)
-				// Check if it's a retryable error
-				if shouldRetry(err) {
-					d.Nack(false, true) // Requeue
-				} else {
-					d.Nack(false, false) // Don't requeue
-				}
-				continue
-			}
+	return c.consume(ctx, "nfce.emit", func(d amqp.Delivery) {
+		// Parse message
+		var msg dto.EmitMessage
+		if err := json.Unmarshal(d.Body, &msg); err != nil {
+			log.Printf("Failed to unmarshal message: %v", err)
+			c.deadLetter(ctx, d, deliveryAttempts(d.Headers), fmt.Sprintf("invalid message: %v", err))
+			return
+		}
 
-			// Acknowledge successful processing
-			if err := d.Ack(false); err != nil {
-				log.Printf("Failed to acknowledge message %s: %v", msg.RequestID, err)
-			}
+		if msg.SchemaVersion > dto.MessageSchemaVersion {
+			c.parkUnsupported(ctx, d, msg.SchemaVersion)
+			return
+		}
+
+		// Messages from older releases carry no company and are spread by request
+		key := msg.CompanyID
+		if key == "" {
+			key = msg.RequestID
+		}
+		lanes.dispatch(key, func() { c.handleEmit(ctx, d, msg, handler) })
+	})
+}
+
+// handleEmit runs the handler of an emission message. A failed delivery goes to the end of
+// the queue until the attempts run out, then to the dead-letter queue.
+func (c *consumer) handleEmit(ctx context.Context, d amqp.Delivery, msg dto.EmitMessage, handler func(context.Context, dto.EmitMessage) error) {
+	if err := safeHandle(ctx, handler, msg); err != nil {
+		log.Printf("Handler error for message %s: %v", msg.RequestID, err)
+		attempts := deliveryAttempts(d.Headers) + 1
+		if attempts < c.maxAttempts {
+			c.redeliver(ctx, d, attempts)
+		} else {
+			log.Printf("Dead-lettering message %s after %d attempts", msg.RequestID, attempts)
+			c.deadLetter(ctx, d, attempts, err.Error())
 		}
+		return
+	}
+
+	// Acknowledge successful processing
+	if err := d.Ack(false); err != nil {
+		log.Printf("Failed to acknowledge message %s: %v", msg.RequestID, err)
 	}
 }
 
 // ConsumeCancel consumes NFC-e cancellation messages
 func (c *consumer) ConsumeCancel(ctx context.Context, handler func(context.Context, dto.CancelMessage) error) error {
-	msgs, err := c.channel.Consume(
-		"nfce.cancel", // queue
-		"",            // consumer
-		false,         // auto-ack
-		false,         // exclusive
-		false,         // no-local
-		false,         // no-wait
-		nil,           // args
-	)
-	if err != nil {
-		return fmt.Errorf("failed to register cancel consumer: %w", err)
-	}
+	return c.consume(ctx, "nfce.cancel", func(d amqp.Delivery) {
+		// Parse message
+		var msg dto.CancelMessage
+		if err := json.Unmarshal(d.Body, &msg); err != nil {
+			log.Printf("Failed to unmarshal cancel message: %v", err)
+			d.Nack(false, false) // Don't requeue invalid messages
+			return
+		}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case d, ok := <-msgs:
-			if !ok {
-				return fmt.Errorf("cancel message channel closed")
-			}
+		if msg.SchemaVersion > dto.MessageSchemaVersion {
+			c.parkUnsupported(ctx, d, msg.SchemaVersion)
+			return
+		}
 
-			// Parse message
-			var msg dto.CancelMessage
-			if err := json.Unmarshal(d.Body, &msg); err != nil {
-				log.Printf("Failed to unmarshal cancel message: %v", err)
-				d.Nack(false, false) // Don't requeue invalid messages
-				continue
+		// Handle message
+		if err := handler(ctx, msg); err != nil {
+			log.Printf("Cancel handler error for message %s: %v", msg.RequestID, err)
+			// For cancellation, we might not want to retry as much
+			if shouldRetryCancel(err) {
+				d.Nack(false, true) // Requeue
+			} else {
+				d.Nack(false, false) // Don't requeue
 			}
+			return
+		}
 
-			// Handle message
-			if err := handler(ctx, msg); err != nil {
-				log.Printf("Cancel handler error for message %s: %v", msg.RequestID, err)
-				// For cancellation, we might not want to retry as much
-				if shouldRetryCancel(err) {
-					d.Nack(false, true) // Requeue
-				} else {
-					d.Nack(false, false) // Don't requeue
-				}
-				continue
-			}
+		// Acknowledge successful processing
+		if err := d.Ack(false); err != nil {
+			log.Printf("Failed to acknowledge cancel message %s: %v", msg.RequestID, err)
+		}
+	})
+}
 
-			// Acknowledge successful processing
-			if err := d.Ack(false); err != nil {
-				log.Printf("Failed to acknowledge cancel message %s: %v", msg.RequestID, err)
-			}
+// ConsumeExport consumes bulk export job messages
+func (c *consumer) ConsumeExport(ctx context.Context, handler func(context.Context, dto.ExportMessage) error) error {
+	return c.consume(ctx, "nfce.export", func(d amqp.Delivery) {
+		// Parse message
+		var msg dto.ExportMessage
+		if err := json.Unmarshal(d.Body, &msg); err != nil {
+			log.Printf("Failed to unmarshal export message: %v", err)
+			d.Nack(false, false) // Don't requeue invalid messages
+			return
+		}
+
+		if msg.SchemaVersion > dto.MessageSchemaVersion {
+			c.parkUnsupported(ctx, d, msg.SchemaVersion)
+			return
+		}
+
+		// Handle message - jobs checkpoint their parts, so a requeue resumes where it stopped
+		if err := handler(ctx, msg); err != nil {
+			log.Printf("Export handler error for job %s: %v", msg.JobID, err)
+			d.Nack(false, true) // Requeue
+			return
+		}
+
+		// Acknowledge successful processing
+		if err := d.Ack(false); err != nil {
+			log.Printf("Failed to acknowledge export message %s: %v", msg.JobID, err)
+		}
+	})
+}
+
+// ConsumeInutilizacao consumes number range inutilização messages
+func (c *consumer) ConsumeInutilizacao(ctx context.Context, handler func(context.Context, dto.InutilizacaoMessage) error) error {
+	return c.consume(ctx, "nfce.inutilizacao", func(d amqp.Delivery) {
+		// Parse message
+		var msg dto.InutilizacaoMessage
+		if err := json.Unmarshal(d.Body, &msg); err != nil {
+			log.Printf("Failed to unmarshal inutilizacao message: %v", err)
+			d.Nack(false, false) // Don't requeue invalid messages
+			return
+		}
+
+		if msg.SchemaVersion > dto.MessageSchemaVersion {
+			c.parkUnsupported(ctx, d, msg.SchemaVersion)
+			return
+		}
+
+		// Handle message - SEFAZ rejections are persisted by the handler, errors are transient
+		if err := handler(ctx, msg); err != nil {
+			log.Printf("Inutilizacao handler error for %s: %v", msg.InutilizacaoID, err)
+			d.Nack(false, true) // Requeue
+			return
+		}
+
+		// Acknowledge successful processing
+		if err := d.Ack(false); err != nil {
+			log.Printf("Failed to acknowledge inutilizacao message %s: %v", msg.InutilizacaoID, err)
+		}
+	})
+}
+
+// ConsumeLote consumes the emission messages of lotes of NFC-e
+func (c *consumer) ConsumeLote(ctx context.Context, handler func(context.Context, dto.LoteMessage) error) error {
+	return c.consume(ctx, "nfce.lote", func(d amqp.Delivery) {
+		// Parse message
+		var msg dto.LoteMessage
+		if err := json.Unmarshal(d.Body, &msg); err != nil {
+			log.Printf("Failed to unmarshal lote message: %v", err)
+			d.Nack(false, false) // Don't requeue invalid messages
+			return
 		}
+
+		if msg.SchemaVersion > dto.MessageSchemaVersion {
+			c.parkUnsupported(ctx, d, msg.SchemaVersion)
+			return
+		}
+
+		// Handle message - the NFC-e already processed are skipped, so a requeue resumes the lote
+		if err := safeHandle(ctx, handler, msg); err != nil {
+			log.Printf("Lote handler error for %s: %v", msg.LoteID, err)
+			d.Nack(false, true) // Requeue
+			return
+		}
+
+		// Acknowledge successful processing
+		if err := d.Ack(false); err != nil {
+			log.Printf("Failed to acknowledge lote message %s: %v", msg.LoteID, err)
+		}
+	})
+}
+
+// safeHandle runs the handler, turning a panic on a poison message into an error
+func safeHandle[T any](ctx context.Context, handler func(context.Context, T) error, msg T) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+	return handler(ctx, msg)
+}
+
+// redeliver republishes a failed message at the end of its queue with the attempts made
+func (c *consumer) redeliver(ctx context.Context, d amqp.Delivery, attempts int) {
+	headers := amqp.Table{headerAttempts: int32(attempts)}
+	if err := c.republish(ctx, d, d.Exchange, headers); err != nil {
+		log.Printf("Failed to redeliver message: %v", err)
+		d.Nack(false, true) // Requeue in place
+		return
 	}
+	d.Ack(false)
 }
 
-// shouldRetry determines if an error should trigger message requeue
-func shouldRetry(err error) bool {
-	// For now, retry all errors. In production, you might want to classify errors
-	// as retryable (temporary failures) vs non-retryable (permanent failures)
-	return true
+// parkUnsupported moves a message produced by a newer release to the dead-letter queue of its
+// work queue, where it waits for an upgraded worker. Requeueing it would hand it back to this
+// worker at once, forever.
+func (c *consumer) parkUnsupported(ctx context.Context, d amqp.Delivery, schemaVersion int) {
+	reason := fmt.Sprintf("unsupported schema_version %d (supported: %d)", schemaVersion, dto.MessageSchemaVersion)
+	log.Printf("Parking %s message: %s", d.RoutingKey, reason)
+	c.deadLetter(ctx, d, deliveryAttempts(d.Headers), reason)
+}
+
+// deadLetter moves a message to the dead-letter queue with the reason it was given up on
+func (c *consumer) deadLetter(ctx context.Context, d amqp.Delivery, attempts int, reason string) {
+	headers := amqp.Table{
+		headerAttempts:       int32(attempts),
+		headerDeadLetterWhy:  reason,
+		headerDeadLetteredAt: time.Now().UTC(),
+	}
+	if err := c.republish(ctx, d, deadLetterExchange, headers); err != nil {
+		log.Printf("Failed to dead-letter message: %v", err)
+		d.Nack(false, true) // Requeue in place
+		return
+	}
+	d.Ack(false)
+}
+
+// republish publishes the delivery body to the exchange with its routing key and waits
+// for the broker confirmation
+func (c *consumer) republish(ctx context.Context, d amqp.Delivery, exchange string, headers amqp.Table) error {
+	channel, err := c.conn.currentChannel()
+	if err != nil {
+		return err
+	}
+
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx,
+		exchange,     // exchange
+		d.RoutingKey, // routing key
+		false,        // mandatory
+		false,        // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Headers:      headers,
+			Body:         d.Body,
+			DeliveryMode: amqp.Persistent,
+		})
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("broker rejected the message")
+	}
+	return nil
 }
 
 // shouldRetryCancel determines if a cancel error should trigger message requeue
@@ -229,11 +358,5 @@ func shouldRetryCancel(err error) bool {
 
 // Close closes the consumer connections
 func (c *consumer) Close() error {
-	if c.channel != nil {
-		c.channel.Close()
-	}
-	if c.conn != nil {
-		return c.conn.Close()
-	}
-	return nil
+	return c.conn.close()
 }
@@ -0,0 +1,191 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// deadLetterExchange receives the messages the worker gave up on, routed by their original routing key
+	deadLetterExchange = "nfce.dlx"
+	// emitDeadLetterQueue holds the dead-lettered emission messages, inspected by the admin
+	emitDeadLetterQueue = "nfce.emit.dlq"
+
+	// Headers carried by the messages between deliveries and into the dead-letter queue
+	headerAttempts       = "x-delivery-attempts"
+	headerDeadLetterWhy  = "x-dead-letter-reason"
+	headerDeadLetteredAt = "x-dead-lettered-at"
+)
+
+// declareDeadLetter declares the dead-letter exchange and a dead-letter queue for each work
+// queue, e.g. nfce.emit.dlq, bound with the routing key of the work queue
+func declareDeadLetter(channel *amqp.Channel) error {
+	err := channel.ExchangeDeclare(
+		deadLetterExchange, // name
+		"direct",           // type
+		true,               // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+
+	for _, queue := range workQueues {
+		_, err = channel.QueueDeclare(
+			deadLetterQueueOf(queue), // name
+			true,                     // durable
+			false,                    // delete when unused
+			false,                    // exclusive
+			false,                    // no-wait
+			nil,                      // arguments
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare %s dead-letter queue: %w", queue, err)
+		}
+
+		err = channel.QueueBind(
+			deadLetterQueueOf(queue), // queue name
+			queue,                    // routing key
+			deadLetterExchange,       // exchange
+			false,
+			nil,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to bind %s dead-letter queue: %w", queue, err)
+		}
+	}
+	return nil
+}
+
+// deadLetterQueueOf returns the dead-letter queue of a work queue
+func deadLetterQueueOf(queue string) string {
+	return queue + ".dlq"
+}
+
+// deliveryAttempts returns how many times the message was already handled
+func deliveryAttempts(headers amqp.Table) int {
+	switch v := headers[headerAttempts].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// deadLetterQueue implements DeadLetterQueue. Each call dials its own connection, as
+// inspecting the queue is an occasional admin operation.
+type deadLetterQueue struct {
+	url string
+}
+
+// NewDeadLetterQueue creates the inspector of the emission dead-letter queue
+func NewDeadLetterQueue(url string) dto.DeadLetterQueue {
+	return &deadLetterQueue{url: url}
+}
+
+// ListDeadLetters peeks the queue: the messages are fetched without acknowledgement and go
+// back to the queue, in order, when the channel closes
+func (q *deadLetterQueue) ListDeadLetters(ctx context.Context, limit int) ([]dto.DeadLetter, int, error) {
+	conn, channel, err := dialConfirmed(q.url)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	defer channel.Close()
+
+	queue, err := channel.QueueDeclarePassive(emitDeadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to inspect emit dead-letter queue: %w", err)
+	}
+
+	letters := []dto.DeadLetter{}
+	for len(letters) < limit && ctx.Err() == nil {
+		d, ok, err := channel.Get(emitDeadLetterQueue, false)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read emit dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, toDeadLetter(d))
+	}
+	return letters, queue.Messages, ctx.Err()
+}
+
+// RequeueDeadLetters republishes the dead letters to the emission queue with a fresh
+// delivery count; the others stay in the dead-letter queue
+func (q *deadLetterQueue) RequeueDeadLetters(ctx context.Context, requestID string, limit int) (int, error) {
+	conn, channel, err := dialConfirmed(q.url)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	defer channel.Close()
+
+	requeued := 0
+	for requeued < limit && ctx.Err() == nil {
+		d, ok, err := channel.Get(emitDeadLetterQueue, false)
+		if err != nil {
+			return requeued, fmt.Errorf("failed to read emit dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		// Skipped letters stay unacknowledged, so they are not fetched again, and return
+		// to the queue when the channel closes
+		if requestID != "" && toDeadLetter(d).Message.RequestID != requestID {
+			continue
+		}
+
+		confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx,
+			"nfce.exchange", // exchange
+			"nfce.emit",     // routing key
+			false,           // mandatory
+			false,           // immediate
+			amqp.Publishing{
+				ContentType:  "application/json",
+				Body:         d.Body,
+				DeliveryMode: amqp.Persistent,
+			})
+		if err != nil {
+			return requeued, fmt.Errorf("failed to requeue dead letter: %w", err)
+		}
+		if acked, err := confirmation.WaitContext(ctx); err != nil || !acked {
+			return requeued, fmt.Errorf("broker did not confirm the requeued dead letter")
+		}
+
+		if err := d.Ack(false); err != nil {
+			return requeued, fmt.Errorf("failed to remove requeued dead letter: %w", err)
+		}
+		requeued++
+	}
+	return requeued, ctx.Err()
+}
+
+// toDeadLetter decodes a dead-lettered delivery; a body that is not an emission message is
+// returned as is
+func toDeadLetter(d amqp.Delivery) dto.DeadLetter {
+	letter := dto.DeadLetter{Attempts: deliveryAttempts(d.Headers)}
+	if reason, ok := d.Headers[headerDeadLetterWhy].(string); ok {
+		letter.Reason = reason
+	}
+	if at, ok := d.Headers[headerDeadLetteredAt].(time.Time); ok {
+		letter.DeadLetteredAt = at
+	}
+	if err := json.Unmarshal(d.Body, &letter.Message); err != nil {
+		letter.Body = string(d.Body)
+	}
+	return letter
+}
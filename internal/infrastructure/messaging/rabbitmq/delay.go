@@ -0,0 +1,71 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// retryDelayTier is a queue holding emission messages for a fixed time. Its messages
+// expire after the delay and are dead-lettered back to nfce.emit, so the broker schedules
+// the retries instead of a database poller.
+type retryDelayTier struct {
+	delay time.Duration
+	queue string
+}
+
+// retryDelayTiers are the delay queues, shortest first. Each queue has a single TTL, so its
+// messages expire in the order they were published; a longer delay takes several hops.
+var retryDelayTiers = []retryDelayTier{
+	{delay: time.Second, queue: "nfce.emit.delay.1s"},
+	{delay: 5 * time.Second, queue: "nfce.emit.delay.5s"},
+	{delay: 30 * time.Second, queue: "nfce.emit.delay.30s"},
+	{delay: time.Minute, queue: "nfce.emit.delay.1m"},
+	{delay: 5 * time.Minute, queue: "nfce.emit.delay.5m"},
+	{delay: 15 * time.Minute, queue: "nfce.emit.delay.15m"},
+	{delay: time.Hour, queue: "nfce.emit.delay.1h"},
+	{delay: 4 * time.Hour, queue: "nfce.emit.delay.4h"},
+	{delay: 12 * time.Hour, queue: "nfce.emit.delay.12h"},
+}
+
+// declareDelayQueues declares the delay queues, bound to nfce.exchange with their own name
+// as routing key and dead-lettering to nfce.emit when the messages expire
+func declareDelayQueues(channel *amqp.Channel) error {
+	for _, tier := range retryDelayTiers {
+		_, err := channel.QueueDeclare(
+			tier.queue, // name
+			true,       // durable
+			false,      // delete when unused
+			false,      // exclusive
+			false,      // no-wait
+			amqp.Table{
+				"x-message-ttl":             tier.delay.Milliseconds(),
+				"x-dead-letter-exchange":    "nfce.exchange",
+				"x-dead-letter-routing-key": "nfce.emit",
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare %s queue: %w", tier.queue, err)
+		}
+
+		if err := channel.QueueBind(tier.queue, tier.queue, "nfce.exchange", false, nil); err != nil {
+			return fmt.Errorf("failed to bind %s queue: %w", tier.queue, err)
+		}
+	}
+	return nil
+}
+
+// delayRoutingKey returns the routing key of the longest delay queue not past the delay, or
+// nfce.emit when the delay is shorter than every tier. The worker delays the message again
+// when it arrives before the retry is due.
+func delayRoutingKey(delay time.Duration) string {
+	routingKey := "nfce.emit"
+	for _, tier := range retryDelayTiers {
+		if tier.delay > delay {
+			break
+		}
+		routingKey = tier.queue
+	}
+	return routingKey
+}
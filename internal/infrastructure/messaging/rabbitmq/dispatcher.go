@@ -0,0 +1,47 @@
+package rabbitmq
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// dispatcher runs tasks on a fixed pool of lanes. Tasks with the same key always land on
+// the same lane, so they run one at a time and in arrival order, while different keys run
+// in parallel.
+type dispatcher struct {
+	lanes []chan func()
+	wg    sync.WaitGroup
+}
+
+// newDispatcher starts size lanes, each holding up to buffer waiting tasks
+func newDispatcher(size, buffer int) *dispatcher {
+	d := &dispatcher{lanes: make([]chan func(), size)}
+	for i := range d.lanes {
+		lane := make(chan func(), buffer)
+		d.lanes[i] = lane
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for task := range lane {
+				task()
+			}
+		}()
+	}
+	return d
+}
+
+// dispatch queues the task on the lane of key, blocking while that lane is full
+func (d *dispatcher) dispatch(key string, task func()) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	d.lanes[h.Sum32()%uint32(len(d.lanes))] <- task
+}
+
+// close waits for the queued tasks to finish and stops the lanes
+func (d *dispatcher) close() {
+	for _, lane := range d.lanes {
+		close(lane)
+	}
+	d.wg.Wait()
+}
@@ -0,0 +1,206 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// statusEventMessage is the payload broadcast on the events exchange
+type statusEventMessage struct {
+	CompanyID string        `json:"company_id"`
+	Event     *entity.Event `json:"event"`
+}
+
+// eventBus implements ports.EventBus over a RabbitMQ fanout exchange
+type eventBus struct {
+	conn        *amqp.Connection
+	channel     *amqp.Channel
+	mu          sync.RWMutex
+	subscribers map[string]map[chan *entity.Event]struct{}
+	consumeOnce sync.Once
+}
+
+// NewEventBus creates a new RabbitMQ event bus
+func NewEventBus(url string) (ports.EventBus, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	// Declare events exchange
+	err = channel.ExchangeDeclare(
+		"nfce.events", // name
+		"fanout",      // type
+		true,          // durable
+		false,         // auto-deleted
+		false,         // internal
+		false,         // no-wait
+		nil,           // arguments
+	)
+	if err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare events exchange: %w", err)
+	}
+
+	return &eventBus{
+		conn:        conn,
+		channel:     channel,
+		subscribers: make(map[string]map[chan *entity.Event]struct{}),
+	}, nil
+}
+
+// Publish broadcasts a status change event to every subscribed process
+func (b *eventBus) Publish(ctx context.Context, companyID string, event *entity.Event) error {
+	body, err := json.Marshal(statusEventMessage{CompanyID: companyID, Event: event})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	err = b.channel.PublishWithContext(ctx,
+		"nfce.events", // exchange
+		"",            // routing key
+		false,         // mandatory
+		false,         // immediate
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe registers a local subscriber for the events of a company
+func (b *eventBus) Subscribe(companyID string) (<-chan *entity.Event, func()) {
+	// Only processes that subscribe need a queue bound to the exchange
+	b.consumeOnce.Do(b.startConsuming)
+
+	ch := make(chan *entity.Event, 16)
+
+	b.mu.Lock()
+	if b.subscribers[companyID] == nil {
+		b.subscribers[companyID] = make(map[chan *entity.Event]struct{})
+	}
+	b.subscribers[companyID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[companyID][ch]; ok {
+				delete(b.subscribers[companyID], ch)
+				close(ch)
+			}
+			if len(b.subscribers[companyID]) == 0 {
+				delete(b.subscribers, companyID)
+			}
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// startConsuming binds an exclusive queue to the events exchange and dispatches deliveries
+func (b *eventBus) startConsuming() {
+	queue, err := b.channel.QueueDeclare(
+		"",    // name (server generated)
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		log.Printf("Failed to declare events queue: %v", err)
+		return
+	}
+
+	err = b.channel.QueueBind(
+		queue.Name,    // queue name
+		"",            // routing key
+		"nfce.events", // exchange
+		false,
+		nil,
+	)
+	if err != nil {
+		log.Printf("Failed to bind events queue: %v", err)
+		return
+	}
+
+	msgs, err := b.channel.Consume(
+		queue.Name, // queue
+		"",         // consumer
+		true,       // auto-ack
+		true,       // exclusive
+		false,      // no-local
+		false,      // no-wait
+		nil,        // args
+	)
+	if err != nil {
+		log.Printf("Failed to register events consumer: %v", err)
+		return
+	}
+
+	go func() {
+		for d := range msgs {
+			var msg statusEventMessage
+			if err := json.Unmarshal(d.Body, &msg); err != nil || msg.Event == nil {
+				log.Printf("Failed to unmarshal event message: %v", err)
+				continue
+			}
+			b.dispatch(msg.CompanyID, msg.Event)
+		}
+	}()
+}
+
+// dispatch delivers an event to the local subscribers of a company
+func (b *eventBus) dispatch(companyID string, event *entity.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[companyID] {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber, drop the event - clients recover via Last-Event-ID
+		}
+	}
+}
+
+// Close closes the event bus connections and all subscriber channels
+func (b *eventBus) Close() error {
+	b.mu.Lock()
+	for companyID, subs := range b.subscribers {
+		for ch := range subs {
+			close(ch)
+		}
+		delete(b.subscribers, companyID)
+	}
+	b.mu.Unlock()
+
+	if b.channel != nil {
+		b.channel.Close()
+	}
+	if b.conn != nil {
+		return b.conn.Close()
+	}
+	return nil
+}
@@ -4,179 +4,195 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 // publisher implements Publisher interface
 type publisher struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
+	conn *connection
+
+	// Publishes are serialized so the confirmation and a return of the broker always
+	// belong to the message just sent
+	publishMu   sync.Mutex
+	returns     chan amqp.Return
+	returnsChan *amqp.Channel // Channel the returns are registered on
 }
 
 // NewPublisher creates a new RabbitMQ publisher
+// The connection is re-established in the background when the broker goes away.
 func NewPublisher(url string) (dto.Publisher, error) {
-	conn, err := amqp.Dial(url)
+	conn, err := newConnection("publisher", url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+		return nil, err
 	}
+	return &publisher{conn: conn}, nil
+}
 
-	channel, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
-	}
+// IsHealthy reports whether the broker connection is currently usable
+func (p *publisher) IsHealthy() bool {
+	return p.conn.healthy()
+}
 
-	// Declare exchange
-	err = channel.ExchangeDeclare(
-		"nfce.exchange", // name
-		"direct",        // type
-		true,            // durable
-		false,           // auto-deleted
-		false,           // internal
-		false,           // no-wait
-		nil,             // arguments
-	)
+// publish sends a persistent message with the mandatory flag and waits for the broker
+// confirmation. A message the broker nacks or cannot route to a queue fails with
+// ErrBrokerUnavailable, like one published while reconnecting.
+func (p *publisher) publish(ctx context.Context, routingKey string, body []byte) error {
+	p.publishMu.Lock()
+	defer p.publishMu.Unlock()
+
+	channel, err := p.conn.currentChannel()
 	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare exchange: %w", err)
+		return err
+	}
+	if p.returnsChan != channel {
+		p.returns = channel.NotifyReturn(make(chan amqp.Return, 16))
+		p.returnsChan = channel
 	}
 
-	// Declare emit queue
-	_, err = channel.QueueDeclare(
-		"nfce.emit", // name
-		true,        // durable
-		false,       // delete when unused
-		false,       // exclusive
-		false,       // no-wait
-		nil,         // arguments
-	)
+	messageID := uuid.New().String()
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx,
+		"nfce.exchange", // exchange
+		routingKey,      // routing key
+		true,            // mandatory
+		false,           // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			MessageId:    messageID,
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		})
 	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare emit queue: %w", err)
+		return err
 	}
 
-	// Bind emit queue to exchange
-	err = channel.QueueBind(
-		"nfce.emit",     // queue name
-		"nfce.emit",     // routing key
-		"nfce.exchange", // exchange
-		false,
-		nil,
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to bind emit queue: %w", err)
-	}
-
-	// Declare cancel queue
-	_, err = channel.QueueDeclare(
-		"nfce.cancel", // name
-		true,          // durable
-		false,         // delete when unused
-		false,         // exclusive
-		false,         // no-wait
-		nil,           // arguments
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare cancel queue: %w", err)
-	}
-
-	// Bind cancel queue to exchange
-	err = channel.QueueBind(
-		"nfce.cancel",   // queue name
-		"nfce.cancel",   // routing key
-		"nfce.exchange", // exchange
-		false,
-		nil,
-	)
+	acked, err := confirmation.WaitContext(ctx)
 	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to bind cancel queue: %w", err)
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("%w: message not confirmed by the broker", dto.ErrBrokerUnavailable)
 	}
 
-	return &publisher{
-		conn:    conn,
-		channel: channel,
-	}, nil
+	// The broker sends the return before the confirmation of an unroutable message
+	for {
+		select {
+		case ret, ok := <-p.returns:
+			if !ok {
+				return nil // Channel closed after confirming the message
+			}
+			if ret.MessageId == messageID {
+				return fmt.Errorf("%w: message returned as unroutable: %s", dto.ErrBrokerUnavailable, ret.ReplyText)
+			}
+			continue // Return of an earlier publish that timed out
+		default:
+		}
+		return nil
+	}
 }
 
 // PublishEmit publishes an NFC-e emission message
 func (p *publisher) PublishEmit(ctx context.Context, msg dto.EmitMessage) error {
-	fmt.Printf("DEBUG: Publishing message to nfce.exchange with routing key nfce.emit: %+v\n", msg)
-
+	msg.SchemaVersion = dto.MessageSchemaVersion
 	body, err := json.Marshal(msg)
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to marshal message: %v\n", err)
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	fmt.Printf("DEBUG: Publishing to exchange nfce.exchange, routing key nfce.emit\n")
-
-	err = p.channel.PublishWithContext(ctx,
-		"nfce.exchange", // exchange
-		"nfce.emit",     // routing key
-		false,           // mandatory
-		false,           // immediate
-		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp.Persistent,
-		})
+	err = p.publish(ctx, "nfce.emit", body)
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to publish message: %v\n", err)
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
-	fmt.Printf("DEBUG: Message published successfully\n")
+	return nil
+}
+
+// PublishEmitAfter publishes an NFC-e emission message that reaches nfce.emit once the
+// delay expires in the retry delay queues
+func (p *publisher) PublishEmitAfter(ctx context.Context, msg dto.EmitMessage, delay time.Duration) error {
+	msg.SchemaVersion = dto.MessageSchemaVersion
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	err = p.publish(ctx, delayRoutingKey(delay), body)
+	if err != nil {
+		return fmt.Errorf("failed to publish delayed message: %w", err)
+	}
+
 	return nil
 }
 
 // PublishCancel publishes an NFC-e cancellation message
 func (p *publisher) PublishCancel(ctx context.Context, msg dto.CancelMessage) error {
-	fmt.Printf("DEBUG: Publishing cancel message to nfce.exchange with routing key nfce.cancel: %+v\n", msg)
-
+	msg.SchemaVersion = dto.MessageSchemaVersion
 	body, err := json.Marshal(msg)
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to marshal cancel message: %v\n", err)
 		return fmt.Errorf("failed to marshal cancel message: %w", err)
 	}
 
-	fmt.Printf("DEBUG: Publishing cancel to exchange nfce.exchange, routing key nfce.cancel\n")
-
-	err = p.channel.PublishWithContext(ctx,
-		"nfce.exchange", // exchange
-		"nfce.cancel",   // routing key
-		false,           // mandatory
-		false,           // immediate
-		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp.Persistent,
-		})
+	err = p.publish(ctx, "nfce.cancel", body)
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to publish cancel message: %v\n", err)
 		return fmt.Errorf("failed to publish cancel message: %w", err)
 	}
 
-	fmt.Printf("DEBUG: Cancel message published successfully\n")
 	return nil
 }
 
-// Close closes the publisher connections
-func (p *publisher) Close() error {
-	if p.channel != nil {
-		p.channel.Close()
+// PublishExport publishes a bulk export job message
+func (p *publisher) PublishExport(ctx context.Context, msg dto.ExportMessage) error {
+	msg.SchemaVersion = dto.MessageSchemaVersion
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal export message: %w", err)
 	}
-	if p.conn != nil {
-		return p.conn.Close()
+
+	err = p.publish(ctx, "nfce.export", body)
+	if err != nil {
+		return fmt.Errorf("failed to publish export message: %w", err)
 	}
+
 	return nil
 }
+
+// PublishInutilizacao publishes a number range inutilização message
+func (p *publisher) PublishInutilizacao(ctx context.Context, msg dto.InutilizacaoMessage) error {
+	msg.SchemaVersion = dto.MessageSchemaVersion
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inutilizacao message: %w", err)
+	}
+
+	err = p.publish(ctx, "nfce.inutilizacao", body)
+	if err != nil {
+		return fmt.Errorf("failed to publish inutilizacao message: %w", err)
+	}
+
+	return nil
+}
+
+// PublishLote publishes the emission message of a lote of NFC-e
+func (p *publisher) PublishLote(ctx context.Context, msg dto.LoteMessage) error {
+	msg.SchemaVersion = dto.MessageSchemaVersion
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lote message: %w", err)
+	}
+
+	err = p.publish(ctx, "nfce.lote", body)
+	if err != nil {
+		return fmt.Errorf("failed to publish lote message: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the publisher connections
+func (p *publisher) Close() error {
+	return p.conn.close()
+}
@@ -0,0 +1,153 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/application/dto"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+const (
+	// relayBatch caps the messages claimed by a relay run
+	relayBatch = 100
+	// claimLease keeps a claimed message away from the other relays while it is published
+	claimLease = time.Minute
+)
+
+// Relay publishes the outbox messages written with the requests to their queues. The worker
+// runs it periodically and the API flushes it on shutdown; the claims let both run at once.
+type Relay struct {
+	outbox    ports.OutboxRepository
+	repo      ports.NFCeRepository
+	publisher dto.Publisher
+	logger    logger.Logger
+}
+
+// NewRelay creates a new outbox relay
+func NewRelay(outbox ports.OutboxRepository, repo ports.NFCeRepository, publisher dto.Publisher, logger logger.Logger) *Relay {
+	return &Relay{
+		outbox:    outbox,
+		repo:      repo,
+		publisher: publisher,
+		logger:    logger,
+	}
+}
+
+// Flush relays batches until no pending message is left, the broker goes away or ctx ends
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		published, err := r.RelayBatch(ctx)
+		total += published
+		if err != nil || published == 0 {
+			return total, err
+		}
+	}
+	return total, ctx.Err()
+}
+
+// RelayBatch publishes a batch of pending outbox messages and marks them sent. A message
+// that fails is retried with backoff; the run stops when the broker goes away.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	messages, err := r.outbox.ClaimPending(ctx, relayBatch, claimLease)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+
+	published := 0
+	for _, msg := range messages {
+		if err := r.publishMessage(ctx, msg); err != nil {
+			if markErr := r.outbox.MarkFailed(ctx, msg.ID, err.Error(), msg.NextAttemptAt(time.Now())); markErr != nil {
+				r.logger.Error("Failed to record outbox failure",
+					logger.Field{Key: "outbox_id", Value: msg.ID},
+					logger.Field{Key: "error", Value: markErr.Error()})
+			}
+			if errors.Is(err, dto.ErrBrokerUnavailable) {
+				// The claimed messages left are relayed once their lease runs out
+				return published, fmt.Errorf("failed to publish outbox message %s: %w", msg.ID, err)
+			}
+			r.logger.Error("Failed to publish outbox message",
+				logger.Field{Key: "outbox_id", Value: msg.ID},
+				logger.Field{Key: "topic", Value: msg.Topic},
+				logger.Field{Key: "error", Value: err.Error()})
+			continue
+		}
+
+		// A message published but not marked sent is published again; the emit handler skips processed requests
+		if err := r.outbox.MarkSent(ctx, msg.ID, time.Now()); err != nil {
+			r.logger.Error("Failed to mark outbox message sent",
+				logger.Field{Key: "outbox_id", Value: msg.ID},
+				logger.Field{Key: "error", Value: err.Error()})
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// deferredReleasedMessage is the message of the event of a deferred request published to the queue
+const deferredReleasedMessage = "Emissão publicada na fila após a recuperação do broker"
+
+// publishOutboxMessage publishes the message to the queue of its topic
+func (r *Relay) publishMessage(ctx context.Context, msg *entity.OutboxMessage) error {
+	switch msg.Topic {
+	case entity.OutboxTopicEmit:
+		var emitMsg dto.EmitMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &emitMsg); err != nil {
+			return fmt.Errorf("invalid emit payload: %w", err)
+		}
+
+		// Requests accepted while the broker was down are processed once published
+		if err := r.repo.UpdateStatus(ctx, emitMsg.RequestID, entity.RequestStatusQueuedDeferred, entity.RequestStatusPending, deferredReleasedMessage, nil); err != nil {
+			return fmt.Errorf("failed to update deferred request: %w", err)
+		}
+
+		if err := r.publisher.PublishEmit(ctx, emitMsg); err != nil {
+			// Keep the request deferred until the message is published
+			if restoreErr := r.repo.UpdateStatus(ctx, emitMsg.RequestID, entity.RequestStatusPending, entity.RequestStatusQueuedDeferred, "Falha ao publicar a emissão na fila", nil); restoreErr != nil {
+				r.logger.Error("Failed to restore deferred request",
+					logger.Field{Key: "request_id", Value: emitMsg.RequestID},
+					logger.Field{Key: "error", Value: restoreErr.Error()})
+			}
+			return err
+		}
+		return nil
+	case entity.OutboxTopicLote:
+		var loteMsg dto.LoteMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &loteMsg); err != nil {
+			return fmt.Errorf("invalid lote payload: %w", err)
+		}
+
+		if err := r.publisher.PublishLote(ctx, loteMsg); err != nil {
+			return err
+		}
+
+		// The lote handler also takes the deferred requests, so they are released after publishing
+		requests, err := r.repo.ListByLote(ctx, loteMsg.LoteID)
+		if err != nil {
+			r.logger.Error("Failed to list deferred lote requests",
+				logger.Field{Key: "lote_id", Value: loteMsg.LoteID},
+				logger.Field{Key: "error", Value: err.Error()})
+			return nil
+		}
+		for _, request := range requests {
+			if request.Status != entity.RequestStatusQueuedDeferred {
+				continue
+			}
+			if err := r.repo.UpdateStatus(ctx, request.ID, entity.RequestStatusQueuedDeferred, entity.RequestStatusPending, deferredReleasedMessage, nil); err != nil {
+				r.logger.Error("Failed to update deferred lote request",
+					logger.Field{Key: "request_id", Value: request.ID},
+					logger.Field{Key: "error", Value: err.Error()})
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown outbox topic %q", msg.Topic)
+	}
+}
@@ -0,0 +1,120 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// Gateways that charge the subscriptions
+const (
+	ProviderNone        = "none"
+	ProviderStripe      = "stripe"
+	ProviderPagarme     = "pagarme"
+	ProviderMercadoPago = "mercadopago"
+)
+
+// gatewayTimeout bounds a call to the gateway API
+const gatewayTimeout = 30 * time.Second
+
+// ErrInvalidSignature is returned when a webhook is not signed with the configured secret
+var ErrInvalidSignature = errors.New("invalid payment webhook signature")
+
+// ChargeStatus is the outcome of a charge
+type ChargeStatus string
+
+const (
+	ChargeStatusPaid    ChargeStatus = "paid"
+	ChargeStatusPending ChargeStatus = "pending" // Confirmed later by a webhook
+	ChargeStatusFailed  ChargeStatus = "failed"
+)
+
+// ChargeRequest charges the stored payment method of a customer for an invoice
+type ChargeRequest struct {
+	InvoiceID      string // Sent as metadata, so the webhooks point back to the invoice
+	IdempotencyKey string // Repeating the key never charges twice
+	CustomerID     string
+	PaymentMethod  string
+	Amount         money.Amount
+	Currency       string
+	Description    string
+}
+
+// Charge is the charge created on the gateway
+type Charge struct {
+	ID            string
+	Status        ChargeStatus
+	FailureReason string // Decline reason given by the gateway when failed
+}
+
+// EventType is the kind of a payment webhook
+type EventType string
+
+const (
+	EventPaymentSucceeded EventType = "payment.succeeded"
+	EventPaymentFailed    EventType = "payment.failed"
+)
+
+// Event is a payment webhook of the gateway
+type Event struct {
+	ID            string
+	Type          EventType
+	ChargeID      string
+	InvoiceID     string // From the charge metadata, empty when the gateway does not echo it
+	FailureReason string
+}
+
+// Gateway charges the subscriptions and reads the payment webhooks of a provider
+type Gateway interface {
+	// Charge charges the customer; a declined payment is a failed Charge, not an error
+	Charge(ctx context.Context, req *ChargeRequest) (*Charge, error)
+	// ParseWebhook checks the signature and reads the event; nil for events that are not payments
+	ParseWebhook(ctx context.Context, payload []byte, header http.Header) (*Event, error)
+	// Provider names the provider, recorded on the invoices
+	Provider() string
+}
+
+// Config selects and configures the gateway
+type Config struct {
+	Provider string // none | stripe | pagarme | mercadopago
+
+	StripeSecretKey     string
+	StripeWebhookSecret string
+
+	PagarmeSecretKey     string
+	PagarmeWebhookSecret string
+
+	MercadoPagoAccessToken   string
+	MercadoPagoWebhookSecret string
+}
+
+// NewGateway creates the gateway of the configured provider; nil disables the billing
+func NewGateway(config Config) (Gateway, error) {
+	switch config.Provider {
+	case "", ProviderNone:
+		return nil, nil
+	case ProviderStripe:
+		return NewStripeGateway(config.StripeSecretKey, config.StripeWebhookSecret), nil
+	case ProviderPagarme:
+		return NewPagarmeGateway(config.PagarmeSecretKey, config.PagarmeWebhookSecret), nil
+	case ProviderMercadoPago:
+		return NewMercadoPagoGateway(config.MercadoPagoAccessToken, config.MercadoPagoWebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment gateway %q", config.Provider)
+	}
+}
+
+// validHMAC reports whether the hex signature is the HMAC-SHA256 of data with the secret
+func validHMAC(secret, data, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	expected, err := hex.DecodeString(signature)
+	return err == nil && hmac.Equal(mac.Sum(nil), expected)
+}
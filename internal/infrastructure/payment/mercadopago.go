@@ -0,0 +1,195 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const mercadoPagoEndpoint = "https://api.mercadopago.com"
+
+// mercadoPagoGateway charges the saved card of a Mercado Pago customer with the Payments API
+type mercadoPagoGateway struct {
+	accessToken   string
+	webhookSecret string
+	endpoint      string
+	client        *http.Client
+}
+
+// NewMercadoPagoGateway creates a gateway for Mercado Pago; the webhook secret is the
+// signature key of the notifications configured on the Mercado Pago panel
+func NewMercadoPagoGateway(accessToken, webhookSecret string) Gateway {
+	return &mercadoPagoGateway{
+		accessToken:   accessToken,
+		webhookSecret: webhookSecret,
+		endpoint:      mercadoPagoEndpoint,
+		client:        &http.Client{Timeout: gatewayTimeout},
+	}
+}
+
+// Provider names the provider
+func (g *mercadoPagoGateway) Provider() string {
+	return ProviderMercadoPago
+}
+
+type mercadoPagoPaymentRequest struct {
+	TransactionAmount json.Number       `json:"transaction_amount"`
+	Description       string            `json:"description"`
+	ExternalReference string            `json:"external_reference"`
+	Token             string            `json:"token"`
+	Installments      int               `json:"installments"`
+	Payer             mercadoPagoPayer  `json:"payer"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+type mercadoPagoPayer struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type mercadoPagoPayment struct {
+	ID                int64  `json:"id"`
+	Status            string `json:"status"`
+	StatusDetail      string `json:"status_detail"`
+	ExternalReference string `json:"external_reference"`
+	Message           string `json:"message"` // Set on the error responses
+}
+
+// Charge creates the payment of the invoice
+func (g *mercadoPagoGateway) Charge(ctx context.Context, charge *ChargeRequest) (*Charge, error) {
+	body, err := json.Marshal(mercadoPagoPaymentRequest{
+		TransactionAmount: json.Number(charge.Amount.String()),
+		Description:       charge.Description,
+		ExternalReference: charge.InvoiceID,
+		Token:             charge.PaymentMethod,
+		Installments:      1,
+		Payer:             mercadoPagoPayer{Type: "customer", ID: charge.CustomerID},
+		Metadata:          map[string]string{"invoice_id": charge.InvoiceID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Mercado Pago request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint+"/v1/payments", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Mercado Pago request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Idempotency-Key", charge.IdempotencyKey)
+
+	payment, err := g.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Charge{ID: strconv.FormatInt(payment.ID, 10), Status: mercadoPagoStatus(payment.Status)}
+	if result.Status == ChargeStatusFailed {
+		result.FailureReason = "pagamento recusado: " + payment.StatusDetail
+	}
+	return result, nil
+}
+
+// mercadoPagoStatus maps the status of a payment
+func mercadoPagoStatus(status string) ChargeStatus {
+	switch status {
+	case "approved":
+		return ChargeStatusPaid
+	case "pending", "in_process", "authorized":
+		return ChargeStatusPending
+	default: // rejected, cancelled, refunded, charged_back
+		return ChargeStatusFailed
+	}
+}
+
+type mercadoPagoNotification struct {
+	ID   json.Number `json:"id"`
+	Type string      `json:"type"`
+	Data struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// ParseWebhook checks the x-signature header and reads the payment notifications. They only
+// carry the payment ID, so the payment is fetched for its status.
+func (g *mercadoPagoGateway) ParseWebhook(ctx context.Context, payload []byte, header http.Header) (*Event, error) {
+	var notification mercadoPagoNotification
+	if err := json.Unmarshal(payload, &notification); err != nil {
+		return nil, fmt.Errorf("failed to decode Mercado Pago notification: %w", err)
+	}
+	if !g.verify(notification.Data.ID, header.Get("X-Request-Id"), header.Get("X-Signature")) {
+		return nil, ErrInvalidSignature
+	}
+	if notification.Type != "payment" {
+		return nil, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.endpoint+"/v1/payments/"+notification.Data.ID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Mercado Pago request: %w", err)
+	}
+	payment, err := g.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Event{ID: notification.ID.String(), ChargeID: notification.Data.ID, InvoiceID: payment.ExternalReference}
+	switch mercadoPagoStatus(payment.Status) {
+	case ChargeStatusPaid:
+		result.Type = EventPaymentSucceeded
+	case ChargeStatusFailed:
+		result.Type = EventPaymentFailed
+		result.FailureReason = "pagamento recusado: " + payment.StatusDetail
+	default:
+		return nil, nil
+	}
+	return result, nil
+}
+
+// do sends the authenticated request and decodes the payment of the response
+func (g *mercadoPagoGateway) do(req *http.Request) (*mercadoPagoPayment, error) {
+	req.Header.Set("Authorization", "Bearer "+g.accessToken)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Mercado Pago request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var payment mercadoPagoPayment
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+	json.Unmarshal(body, &payment)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		if payment.Message != "" {
+			return nil, fmt.Errorf("Mercado Pago returned status %d: %s", resp.StatusCode, payment.Message)
+		}
+		return nil, fmt.Errorf("Mercado Pago returned status %d", resp.StatusCode)
+	}
+	return &payment, nil
+}
+
+// verify checks the "ts=timestamp,v1=signature" header: v1 is the HMAC-SHA256 of the
+// manifest "id:{data.id};request-id:{x-request-id};ts:{ts};"
+func (g *mercadoPagoGateway) verify(dataID, requestID, header string) bool {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "ts":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == "" || signature == "" {
+		return false
+	}
+
+	manifest := fmt.Sprintf("id:%s;request-id:%s;ts:%s;", strings.ToLower(dataID), requestID, timestamp)
+	return validHMAC(g.webhookSecret, manifest, signature)
+}
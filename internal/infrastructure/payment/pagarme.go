@@ -0,0 +1,211 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const pagarmeEndpoint = "https://api.pagar.me/core/v5"
+
+// pagarmeGateway charges the saved card of a Pagar.me customer with a closed order of the
+// Pagar.me v5 API
+type pagarmeGateway struct {
+	secretKey     string
+	webhookSecret string
+	endpoint      string
+	client        *http.Client
+}
+
+// NewPagarmeGateway creates a gateway for Pagar.me; the webhook secret signs the
+// X-Hub-Signature header of the webhooks
+func NewPagarmeGateway(secretKey, webhookSecret string) Gateway {
+	return &pagarmeGateway{
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		endpoint:      pagarmeEndpoint,
+		client:        &http.Client{Timeout: gatewayTimeout},
+	}
+}
+
+// Provider names the provider
+func (g *pagarmeGateway) Provider() string {
+	return ProviderPagarme
+}
+
+type pagarmeItem struct {
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	Code        string `json:"code"`
+}
+
+type pagarmePayment struct {
+	PaymentMethod string `json:"payment_method"`
+	CreditCard    struct {
+		CardID          string `json:"card_id"`
+		OperationType   string `json:"operation_type"`
+		RecurrenceCycle string `json:"recurrence_cycle"`
+	} `json:"credit_card"`
+}
+
+type pagarmeOrderRequest struct {
+	Code       string            `json:"code"`
+	CustomerID string            `json:"customer_id"`
+	Items      []pagarmeItem     `json:"items"`
+	Payments   []pagarmePayment  `json:"payments"`
+	Metadata   map[string]string `json:"metadata"`
+	Closed     bool              `json:"closed"`
+}
+
+type pagarmeOrder struct {
+	ID       string            `json:"id"`
+	Code     string            `json:"code"`
+	Status   string            `json:"status"`
+	Metadata map[string]string `json:"metadata"`
+	Charges  []struct {
+		LastTransaction struct {
+			AcquirerMessage string `json:"acquirer_message"`
+		} `json:"last_transaction"`
+	} `json:"charges"`
+	Message string `json:"message"` // Set on the error responses
+}
+
+// Charge creates the paid order of the invoice
+func (g *pagarmeGateway) Charge(ctx context.Context, charge *ChargeRequest) (*Charge, error) {
+	order := pagarmeOrderRequest{
+		Code:       charge.InvoiceID,
+		CustomerID: charge.CustomerID,
+		Items: []pagarmeItem{{
+			Amount:      int64(charge.Amount),
+			Description: charge.Description,
+			Quantity:    1,
+			Code:        charge.InvoiceID,
+		}},
+		Metadata: map[string]string{"invoice_id": charge.InvoiceID},
+		Closed:   true,
+	}
+	var payment pagarmePayment
+	payment.PaymentMethod = "credit_card"
+	payment.CreditCard.CardID = charge.PaymentMethod
+	payment.CreditCard.OperationType = "auth_and_capture"
+	payment.CreditCard.RecurrenceCycle = "subsequent"
+	order.Payments = []pagarmePayment{payment}
+
+	body, err := json.Marshal(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Pagar.me request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint+"/orders", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Pagar.me request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", charge.IdempotencyKey)
+	req.SetBasicAuth(g.secretKey, "")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Pagar.me request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result pagarmeOrder
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+	json.Unmarshal(respBody, &result)
+
+	if resp.StatusCode != http.StatusOK {
+		if result.Message != "" {
+			return nil, fmt.Errorf("Pagar.me returned status %d: %s", resp.StatusCode, result.Message)
+		}
+		return nil, fmt.Errorf("Pagar.me returned status %d", resp.StatusCode)
+	}
+
+	return &Charge{
+		ID:            result.ID,
+		Status:        pagarmeStatus(result.Status),
+		FailureReason: result.failureReason(),
+	}, nil
+}
+
+// pagarmeStatus maps the status of an order
+func pagarmeStatus(status string) ChargeStatus {
+	switch status {
+	case "paid":
+		return ChargeStatusPaid
+	case "pending":
+		return ChargeStatusPending
+	default: // failed, canceled
+		return ChargeStatusFailed
+	}
+}
+
+// failureReason returns the acquirer message of a failed order
+func (o *pagarmeOrder) failureReason() string {
+	if pagarmeStatus(o.Status) != ChargeStatusFailed {
+		return ""
+	}
+	for _, charge := range o.Charges {
+		if charge.LastTransaction.AcquirerMessage != "" {
+			return charge.LastTransaction.AcquirerMessage
+		}
+	}
+	return "pagamento não aprovado: " + o.Status
+}
+
+type pagarmeEvent struct {
+	ID   string       `json:"id"`
+	Type string       `json:"type"`
+	Data pagarmeOrder `json:"data"`
+}
+
+// ParseWebhook checks the X-Hub-Signature header and reads the order events
+func (g *pagarmeGateway) ParseWebhook(ctx context.Context, payload []byte, header http.Header) (*Event, error) {
+	if !g.verify(payload, header.Get("X-Hub-Signature")) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event pagarmeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode Pagar.me event: %w", err)
+	}
+
+	order := event.Data
+	result := &Event{ID: event.ID, ChargeID: order.ID, InvoiceID: order.Metadata["invoice_id"]}
+	if result.InvoiceID == "" {
+		result.InvoiceID = order.Code
+	}
+	switch event.Type {
+	case "order.paid":
+		result.Type = EventPaymentSucceeded
+	case "order.payment_failed":
+		result.Type = EventPaymentFailed
+		result.FailureReason = order.failureReason()
+	default:
+		return nil, nil
+	}
+	return result, nil
+}
+
+// verify checks the "sha1=signature" header, the HMAC-SHA1 of the payload
+func (g *pagarmeGateway) verify(payload []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha1=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, []byte(g.webhookSecret))
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,192 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	stripeEndpoint = "https://api.stripe.com"
+	// stripeSignatureTolerance rejects replayed webhooks signed longer ago
+	stripeSignatureTolerance = 5 * time.Minute
+)
+
+// stripeGateway charges the saved card of a Stripe customer with an off-session
+// PaymentIntent, confirmed on creation
+type stripeGateway struct {
+	secretKey     string
+	webhookSecret string
+	endpoint      string
+	client        *http.Client
+}
+
+// NewStripeGateway creates a gateway for Stripe; the webhook secret is the signing secret
+// of the endpoint registered on the Stripe dashboard
+func NewStripeGateway(secretKey, webhookSecret string) Gateway {
+	return &stripeGateway{
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		endpoint:      stripeEndpoint,
+		client:        &http.Client{Timeout: gatewayTimeout},
+	}
+}
+
+// Provider names the provider
+func (g *stripeGateway) Provider() string {
+	return ProviderStripe
+}
+
+type stripePaymentIntent struct {
+	ID               string            `json:"id"`
+	Status           string            `json:"status"`
+	Metadata         map[string]string `json:"metadata"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+type stripeErrorResponse struct {
+	Error struct {
+		Type          string               `json:"type"`
+		Message       string               `json:"message"`
+		PaymentIntent *stripePaymentIntent `json:"payment_intent"`
+	} `json:"error"`
+}
+
+// Charge creates and confirms the PaymentIntent of the invoice
+func (g *stripeGateway) Charge(ctx context.Context, charge *ChargeRequest) (*Charge, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(int64(charge.Amount), 10))
+	form.Set("currency", strings.ToLower(charge.Currency))
+	form.Set("customer", charge.CustomerID)
+	form.Set("payment_method", charge.PaymentMethod)
+	form.Set("confirm", "true")
+	form.Set("off_session", "true")
+	form.Set("description", charge.Description)
+	form.Set("metadata[invoice_id]", charge.InvoiceID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint+"/v1/payment_intents", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Stripe request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+g.secretKey)
+	req.Header.Set("Idempotency-Key", charge.IdempotencyKey)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+
+	if resp.StatusCode != http.StatusOK {
+		var failure stripeErrorResponse
+		json.Unmarshal(body, &failure)
+		// A declined card is reported as a card_error carrying the PaymentIntent
+		if failure.Error.Type == "card_error" {
+			result := &Charge{Status: ChargeStatusFailed, FailureReason: failure.Error.Message}
+			if failure.Error.PaymentIntent != nil {
+				result.ID = failure.Error.PaymentIntent.ID
+			}
+			return result, nil
+		}
+		if failure.Error.Message != "" {
+			return nil, fmt.Errorf("Stripe returned status %d: %s", resp.StatusCode, failure.Error.Message)
+		}
+		return nil, fmt.Errorf("Stripe returned status %d", resp.StatusCode)
+	}
+
+	var intent stripePaymentIntent
+	if err := json.Unmarshal(body, &intent); err != nil {
+		return nil, fmt.Errorf("failed to decode Stripe response: %w", err)
+	}
+
+	result := &Charge{ID: intent.ID}
+	switch intent.Status {
+	case "succeeded":
+		result.Status = ChargeStatusPaid
+	case "processing":
+		result.Status = ChargeStatusPending
+	default: // requires_payment_method, requires_action (3DS is not possible off-session), canceled
+		result.Status = ChargeStatusFailed
+		result.FailureReason = "pagamento não confirmado: " + intent.Status
+		if intent.LastPaymentError != nil && intent.LastPaymentError.Message != "" {
+			result.FailureReason = intent.LastPaymentError.Message
+		}
+	}
+	return result, nil
+}
+
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object stripePaymentIntent `json:"object"`
+	} `json:"data"`
+}
+
+// ParseWebhook checks the Stripe-Signature header and reads the PaymentIntent events
+func (g *stripeGateway) ParseWebhook(ctx context.Context, payload []byte, header http.Header) (*Event, error) {
+	if err := g.verify(payload, header.Get("Stripe-Signature"), time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event stripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode Stripe event: %w", err)
+	}
+
+	intent := event.Data.Object
+	result := &Event{ID: event.ID, ChargeID: intent.ID, InvoiceID: intent.Metadata["invoice_id"]}
+	switch event.Type {
+	case "payment_intent.succeeded":
+		result.Type = EventPaymentSucceeded
+	case "payment_intent.payment_failed":
+		result.Type = EventPaymentFailed
+		if intent.LastPaymentError != nil {
+			result.FailureReason = intent.LastPaymentError.Message
+		}
+	default:
+		return nil, nil
+	}
+	return result, nil
+}
+
+// verify checks the "t=timestamp,v1=signature" header: v1 is the HMAC-SHA256 of
+// "timestamp.payload" with the webhook secret
+func (g *stripeGateway) verify(payload []byte, header string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	for _, signature := range signatures {
+		if validHMAC(g.webhookSecret, timestamp+"."+string(payload), signature) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
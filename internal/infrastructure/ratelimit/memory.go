@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+// sweepInterval is how often the buckets refilled to their limit are dropped
+const sweepInterval = 5 * time.Minute
+
+// bucket is a token bucket of a key
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// memoryStore keeps the token buckets in the process: each API instance enforces the limits
+// on its own, so behind a load balancer a company gets up to the limit per instance
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates a rate limit store in memory
+func NewMemoryStore() ports.RateLimitStore {
+	return &memoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take refills the bucket for the time since its last take and removes a token
+func (s *memoryStore) Take(_ context.Context, key string, limit int, now time.Time) (bool, int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	capacity := float64(limit)
+	perSecond := capacity / 60
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	} else if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*perSecond)
+		b.updated = now
+	}
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+		return false, 0, wait, nil
+	}
+	b.tokens--
+	return true, int(b.tokens), 0, nil
+}
+
+// sweep drops the buckets idle for a minute or more, already refilled to their limit
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) >= time.Minute {
+			delete(s.buckets, key)
+		}
+	}
+}
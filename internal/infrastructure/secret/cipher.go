@@ -0,0 +1,268 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// dataKeySize is the size of the AES-256 key generated for every sealed value
+const dataKeySize = 32
+
+// Stored forms of the secrets:
+//
+//	enc:v2:<key id>:<base64 of the sealed data key>:<base64 of the sealed value>
+//	enc:v1:<base64 of the nonce and the ciphertext>, sealed with the key itself
+//
+// Anything else was stored before encryption: the base64 of the PFX or the plaintext column.
+var (
+	envelopePrefix = []byte("enc:v2:")
+	sealedPrefix   = []byte("enc:v1:")
+)
+
+// ErrKeyMissing is returned when opening an encrypted secret without an encryption key
+var ErrKeyMissing = errors.New("secret is encrypted but no encryption key is configured")
+
+// ErrUnknownKey is returned when opening a secret sealed under a key that is not configured
+var ErrUnknownKey = errors.New("secret is encrypted with a key that is not configured")
+
+// Cipher encrypts the secrets stored in the database with envelope encryption: each value is
+// sealed with AES-256-GCM under a random data key, which is sealed under the primary key and
+// stored along with the value and the ID of that key. After a rotation the previous keys
+// still open the values sealed under them, until they are re-encrypted. Without a key the
+// secrets are stored as before encryption, which Open still reads.
+type Cipher struct {
+	primaryID string
+	keys      map[string]cipher.AEAD
+}
+
+// NewCipher creates a cipher sealing under the base64 encoded 32-byte key, recorded as keyID,
+// and opening also with the previous keys (base64 by ID); an empty key disables the encryption
+func NewCipher(keyID, key string, previous map[string]string) (*Cipher, error) {
+	if key == "" {
+		if len(previous) > 0 {
+			return nil, errors.New("previous encryption keys require an encryption key")
+		}
+		return &Cipher{}, nil
+	}
+	if keyID == "" {
+		return nil, errors.New("encryption key ID is required")
+	}
+
+	c := &Cipher{primaryID: keyID, keys: make(map[string]cipher.AEAD, len(previous)+1)}
+	for id, encoded := range previous {
+		if id == keyID {
+			return nil, fmt.Errorf("previous encryption key %q has the ID of the current key", id)
+		}
+		aead, err := newKeyAEAD(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("previous encryption key %q: %w", id, err)
+		}
+		c.keys[id] = aead
+	}
+
+	aead, err := newKeyAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	c.keys[keyID] = aead
+	return c, nil
+}
+
+// newKeyAEAD decodes a base64 encoded 32-byte key
+func newKeyAEAD(encoded string) (cipher.AEAD, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be base64: %w", err)
+	}
+	if len(raw) != dataKeySize {
+		return nil, fmt.Errorf("encryption key must have %d bytes, got %d", dataKeySize, len(raw))
+	}
+	return newAEAD(raw)
+}
+
+// newAEAD creates the AES-256-GCM of a raw key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal returns the stored form of plaintext
+func (c *Cipher) Seal(plaintext []byte) ([]byte, error) {
+	if len(c.keys) == 0 {
+		return []byte(base64.StdEncoding.EncodeToString(plaintext)), nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key ID is authenticated with the data key, so it cannot be swapped for another one
+	wrapped, err := seal(c.keys[c.primaryID], dataKey, []byte(c.primaryID))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(dataAEAD, plaintext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := append([]byte{}, envelopePrefix...)
+	stored = append(stored, c.primaryID...)
+	stored = append(stored, ':')
+	stored = base64.StdEncoding.AppendEncode(stored, wrapped)
+	stored = append(stored, ':')
+	return base64.StdEncoding.AppendEncode(stored, sealed), nil
+}
+
+// Open returns the plaintext of a stored secret, encrypted or only base64 encoded
+func (c *Cipher) Open(stored []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(stored, envelopePrefix):
+		return c.openEnvelope(stored[len(envelopePrefix):])
+	case bytes.HasPrefix(stored, sealedPrefix):
+		return c.openSealed(stored[len(sealedPrefix):])
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(string(stored))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret: %w", err)
+	}
+	return plaintext, nil
+}
+
+// openEnvelope opens the data key with the key it names, then the value with the data key
+func (c *Cipher) openEnvelope(envelope []byte) ([]byte, error) {
+	parts := bytes.SplitN(envelope, []byte(":"), 3)
+	if len(parts) != 3 {
+		return nil, errors.New("encrypted secret is truncated")
+	}
+	keyID := string(parts[0])
+
+	if len(c.keys) == 0 {
+		return nil, ErrKeyMissing
+	}
+	keyAEAD, ok := c.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+	dataKey, err := open(keyAEAD, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key with key %s: %w", keyID, err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(parts[2]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret: %w", err)
+	}
+	plaintext, err := open(dataAEAD, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return plaintext, nil
+}
+
+// openSealed opens a secret sealed with the key itself, which carries no key ID: the current
+// key is tried first, then the previous ones
+func (c *Cipher) openSealed(encoded []byte) ([]byte, error) {
+	if len(c.keys) == 0 {
+		return nil, ErrKeyMissing
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret: %w", err)
+	}
+
+	if plaintext, err := open(c.keys[c.primaryID], sealed, nil); err == nil {
+		return plaintext, nil
+	}
+	for id, keyAEAD := range c.keys {
+		if id == c.primaryID {
+			continue
+		}
+		if plaintext, err := open(keyAEAD, sealed, nil); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, errors.New("failed to decrypt secret, wrong encryption key?")
+}
+
+// SealString returns the stored form of a secret column; without a key the column keeps the
+// plaintext, as before encryption
+func (c *Cipher) SealString(plaintext string) (string, error) {
+	if plaintext == "" || len(c.keys) == 0 {
+		return plaintext, nil
+	}
+	stored, err := c.Seal([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return string(stored), nil
+}
+
+// OpenString returns the plaintext of a secret column, which holds the plaintext itself when
+// it was written before encryption
+func (c *Cipher) OpenString(stored string) (string, error) {
+	if !isEncrypted([]byte(stored)) {
+		return stored, nil
+	}
+	plaintext, err := c.Open([]byte(stored))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencryption reports whether a stored secret is not sealed under the current key:
+// written before encryption, sealed with the key itself or under a previous key
+func (c *Cipher) NeedsReencryption(stored []byte) bool {
+	if len(c.keys) == 0 || len(stored) == 0 {
+		return false
+	}
+	current := append(append([]byte{}, envelopePrefix...), c.primaryID+":"...)
+	return !bytes.HasPrefix(stored, current)
+}
+
+// isEncrypted reports whether a stored secret was sealed by a key
+func isEncrypted(stored []byte) bool {
+	return bytes.HasPrefix(stored, envelopePrefix) || bytes.HasPrefix(stored, sealedPrefix)
+}
+
+// seal encrypts plaintext with a random nonce, returned in front of the ciphertext
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the output of seal
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted secret is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
@@ -0,0 +1,549 @@
+package danfe
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Geometry of the A4 DANFE of the NF-e, in mm
+const (
+	a4Width       = 210.0
+	a4Height      = 297.0
+	a4Margin      = 5.0
+	a4FieldHeight = 7.0
+	a4ItemHeight  = 4.0
+	a4Additional  = 30.0 // Room kept for the additional data
+)
+
+// a4Column is a column of the products table
+type a4Column struct {
+	title string
+	width float64
+	align string
+}
+
+// a4Columns are the columns of the products table; the widths add up to the printable width
+var a4Columns = []a4Column{
+	{"CÓDIGO", 20, "L"},
+	{"DESCRIÇÃO DO PRODUTO / SERVIÇO", 58, "L"},
+	{"NCM", 14, "C"},
+	{"CFOP", 9, "C"},
+	{"UN", 8, "C"},
+	{"QUANT.", 15, "R"},
+	{"V. UNIT.", 15, "R"},
+	{"V. DESC.", 13, "R"},
+	{"V. TOTAL", 16, "R"},
+	{"V. IPI", 14, "R"},
+	{"ALÍQ. IPI", 18, "R"},
+}
+
+// a4Document draws the DANFE of an NF-e on A4 pages
+type a4Document struct {
+	pdf         *gofpdf.Fpdf
+	tr          func(string) string
+	company     *entity.Company
+	nfceRequest *entity.NFCE
+	chave       string
+}
+
+// renderA4 writes the DANFE of the NF-e to w: the receipt stub, the issuer and the barcode of
+// the chave on every page, the recipient, billing, taxes and transport on the first page and
+// the products spread over as many pages as needed
+func (r *renderer) renderA4(w io.Writer, company *entity.Company, nfceRequest *entity.NFCE, chaveAcesso string) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(a4Margin, a4Margin, a4Margin)
+	pdf.SetAutoPageBreak(false, 0)
+
+	d := &a4Document{
+		pdf:         pdf,
+		tr:          pdf.UnicodeTranslatorFromDescriptor(""),
+		company:     company,
+		nfceRequest: nfceRequest,
+		chave:       chaveAcesso,
+	}
+
+	itens := nfceRequest.Payload.Itens
+	pages := d.paginate(len(itens))
+	start := 0
+	for i, count := range pages {
+		pdf.AddPage()
+		d.watermark()
+		y := a4Margin
+		if i == 0 {
+			y = d.stub(y)
+		}
+		y = d.header(y, i+1, len(pages))
+		if i == 0 {
+			y = d.recipient(y)
+			y = d.billing(y)
+			y = d.taxes(y)
+			y = d.transport(y)
+		}
+		y = d.products(y, start, start+count)
+		if i == len(pages)-1 {
+			d.additional(y)
+		}
+		start += count
+	}
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to render DANFE: %w", err)
+	}
+	return nil
+}
+
+// paginate returns how many items fit on each page: the first page holds the groups of the
+// document, the next ones only the header and the products, and every page keeps room for
+// the additional data printed on the last one
+func (d *a4Document) paginate(items int) []int {
+	table := a4Height - 2*a4Margin - d.headerHeight() - 2*a4ItemHeight - a4Additional
+	first := int((table - d.firstPageGroups()) / a4ItemHeight)
+	next := int(table / a4ItemHeight)
+
+	pages := []int{min(items, first)}
+	for remaining := items - pages[0]; remaining > 0; remaining -= next {
+		pages = append(pages, min(remaining, next))
+	}
+	return pages
+}
+
+// firstPageGroups returns the height of the stub and the groups printed only on the first page
+func (d *a4Document) firstPageGroups() float64 {
+	height := 22.0 + 3*a4FieldHeight + 4 + 2*a4FieldHeight + 4
+	if d.nfceRequest.Payload.Cobranca != nil {
+		height += a4FieldHeight + 4
+	}
+	if d.nfceRequest.Payload.Transporte != nil {
+		height += 3*a4FieldHeight + 4
+	}
+	return height
+}
+
+// headerHeight returns the height of the issuer, DANFE and barcode boxes and the rows below them
+func (d *a4Document) headerHeight() float64 {
+	return 34 + 2*a4FieldHeight
+}
+
+// watermark marks the NF-e without fiscal value or issued in contingency across the page
+func (d *a4Document) watermark() {
+	var text string
+	switch ambiente := d.nfceRequest.Payload.Ambiente; {
+	case ambiente == "simulado", isHomologacao(ambiente):
+		text = "SEM VALOR FISCAL"
+	case d.nfceRequest.InContingency:
+		text = "CONTINGÊNCIA"
+	default:
+		return
+	}
+
+	d.pdf.SetFont("Arial", "B", 60)
+	d.pdf.SetTextColor(225, 225, 225)
+	cx, cy := a4Width/2, a4Height/2
+	d.pdf.TransformBegin()
+	d.pdf.TransformRotate(55, cx, cy)
+	d.pdf.Text(cx-d.pdf.GetStringWidth(d.tr(text))/2, cy, d.tr(text))
+	d.pdf.TransformEnd()
+	d.pdf.SetTextColor(0, 0, 0)
+}
+
+// stub draws the receipt stub detached by the recipient on delivery
+func (d *a4Document) stub(y float64) float64 {
+	width := a4Width - 2*a4Margin
+	razao := d.nfceRequest.Payload.Emitente.CNPJ
+	if d.company != nil {
+		razao = d.company.RazaoSocial
+	}
+
+	d.field(a4Margin, y, width-40, 8, "", fmt.Sprintf("RECEBEMOS DE %s OS PRODUTOS E/OU SERVIÇOS CONSTANTES DA NOTA FISCAL ELETRÔNICA INDICADA AO LADO", strings.ToUpper(razao)), "L")
+	d.field(a4Margin, y+8, 40, 9, "DATA DE RECEBIMENTO", "", "L")
+	d.field(a4Margin+40, y+8, width-80, 9, "IDENTIFICAÇÃO E ASSINATURA DO RECEBEDOR", "", "L")
+
+	d.pdf.Rect(a4Width-a4Margin-40, y, 40, 17, "D")
+	d.pdf.SetFont("Arial", "B", 10)
+	d.pdf.SetXY(a4Width-a4Margin-40, y+2)
+	d.pdf.CellFormat(40, 5, "NF-e", "", 2, "C", false, 0, "")
+	d.pdf.SetFont("Arial", "", 8)
+	d.pdf.CellFormat(40, 4, d.tr("Nº "+d.numero()), "", 2, "C", false, 0, "")
+	d.pdf.CellFormat(40, 4, d.tr("SÉRIE "+d.nfceRequest.Serie), "", 2, "C", false, 0, "")
+
+	// Detach line
+	y += 19
+	d.pdf.SetDashPattern([]float64{1, 1}, 0)
+	d.pdf.Line(a4Margin, y, a4Width-a4Margin, y)
+	d.pdf.SetDashPattern([]float64{}, 0)
+	return y + 3
+}
+
+// header draws the issuer, the DANFE identification with the page count and the barcode of the
+// chave, followed by the nature of the operation and the issuer registrations
+func (d *a4Document) header(y float64, page, pages int) float64 {
+	payload := d.nfceRequest.Payload
+	width := a4Width - 2*a4Margin
+
+	// Issuer
+	d.pdf.Rect(a4Margin, y, 80, 34, "D")
+	d.pdf.SetXY(a4Margin+1, y+2)
+	d.pdf.SetFont("Arial", "B", 9)
+	if d.company != nil {
+		d.pdf.MultiCell(78, 4, d.tr(d.company.RazaoSocial), "", "C", false)
+		address := d.company.Endereco
+		d.pdf.SetFont("Arial", "", 7)
+		d.pdf.SetX(a4Margin + 1)
+		street := address.Logradouro + ", " + address.Numero
+		if address.Complemento != "" {
+			street += " " + address.Complemento
+		}
+		d.pdf.MultiCell(78, 3.5, d.tr(fmt.Sprintf("%s\n%s - CEP %s\n%s/%s", street, address.Bairro, address.CEP, address.Municipio, address.UF)), "", "C", false)
+	}
+
+	// DANFE identification
+	x := a4Margin + 80
+	d.pdf.Rect(x, y, 35, 34, "D")
+	d.pdf.SetXY(x, y+2)
+	d.pdf.SetFont("Arial", "B", 12)
+	d.pdf.CellFormat(35, 5, "DANFE", "", 2, "C", false, 0, "")
+	d.pdf.SetFont("Arial", "", 6)
+	d.pdf.MultiCell(35, 2.8, d.tr("Documento Auxiliar da Nota Fiscal Eletrônica"), "", "C", false)
+	d.pdf.SetX(x + 3)
+	d.pdf.CellFormat(20, 3, d.tr("0 - ENTRADA"), "", 2, "L", false, 0, "")
+	d.pdf.CellFormat(20, 3, d.tr("1 - SAÍDA"), "", 0, "L", false, 0, "")
+	d.pdf.Rect(x+25, y+13.5, 6, 5, "D")
+	d.pdf.SetFont("Arial", "B", 10)
+	d.pdf.SetXY(x+25, y+13.5)
+	d.pdf.CellFormat(6, 5, d.tpNF(), "", 0, "C", false, 0, "")
+	d.pdf.SetXY(x, y+21)
+	d.pdf.SetFont("Arial", "B", 8)
+	d.pdf.CellFormat(35, 4, d.tr("Nº "+d.numero()), "", 2, "C", false, 0, "")
+	d.pdf.CellFormat(35, 4, d.tr("SÉRIE "+d.nfceRequest.Serie), "", 2, "C", false, 0, "")
+	d.pdf.SetFont("Arial", "", 7)
+	d.pdf.CellFormat(35, 4, fmt.Sprintf("FOLHA %d/%d", page, pages), "", 0, "C", false, 0, "")
+
+	// Barcode and chave de acesso
+	x += 35
+	barcodeWidth := width - 115
+	d.pdf.Rect(x, y, barcodeWidth, 34, "D")
+	if err := drawBarcode(d.pdf, d.chave, x+3, y+2, barcodeWidth-6, 12); err != nil {
+		d.pdf.SetError(err)
+	}
+	d.field(x, y+16, barcodeWidth, a4FieldHeight, "CHAVE DE ACESSO", formatChave(d.chave), "C")
+	d.pdf.SetXY(x+1, y+24)
+	d.pdf.SetFont("Arial", "", 7)
+	d.pdf.MultiCell(barcodeWidth-2, 3.5, d.tr("Consulta de autenticidade no portal nacional da NF-e www.nfe.fazenda.gov.br/portal ou no site da Sefaz Autorizadora"), "", "C", false)
+
+	y += 34
+	natOp := payload.NaturezaOperacao
+	if natOp == "" {
+		natOp = "VENDA"
+	}
+	protocolo := d.nfceRequest.Protocolo
+	if d.nfceRequest.AuthorizedAt != nil {
+		protocolo += " - " + d.nfceRequest.AuthorizedAt.Format("02/01/2006 15:04:05")
+	}
+	d.field(a4Margin, y, 115, a4FieldHeight, "NATUREZA DA OPERAÇÃO", natOp, "L")
+	d.field(a4Margin+115, y, width-115, a4FieldHeight, "PROTOCOLO DE AUTORIZAÇÃO DE USO", protocolo, "C")
+
+	y += a4FieldHeight
+	third := width / 3
+	d.field(a4Margin, y, third, a4FieldHeight, "INSCRIÇÃO ESTADUAL", payload.Emitente.IE, "L")
+	d.field(a4Margin+third, y, third, a4FieldHeight, "INSC. ESTADUAL DO SUBST. TRIBUT.", "", "L")
+	d.field(a4Margin+2*third, y, third, a4FieldHeight, "CNPJ", FormatCNPJ(payload.Emitente.CNPJ), "L")
+	return y + a4FieldHeight
+}
+
+// recipient draws the recipient identification and address
+func (d *a4Document) recipient(y float64) float64 {
+	y = d.title(y, "DESTINATÁRIO / REMETENTE")
+
+	dest := d.nfceRequest.Payload.Destinatario
+	if dest == nil {
+		dest = &entity.Destinatario{}
+	}
+	address := dest.Endereco
+	if address == nil {
+		address = &entity.Address{}
+	}
+	document := dest.CPF
+	if dest.CNPJ != "" {
+		document = FormatCNPJ(dest.CNPJ)
+	}
+	emissao := ""
+	if !d.nfceRequest.CreatedAt.IsZero() {
+		emissao = d.nfceRequest.CreatedAt.Format("02/01/2006")
+	}
+	street := address.Logradouro
+	if address.Numero != "" {
+		street += ", " + address.Numero
+	}
+	if address.Complemento != "" {
+		street += " " + address.Complemento
+	}
+
+	d.field(a4Margin, y, 125, a4FieldHeight, "NOME / RAZÃO SOCIAL", dest.Nome, "L")
+	d.field(a4Margin+125, y, 45, a4FieldHeight, "CNPJ / CPF", document, "L")
+	d.field(a4Margin+170, y, 30, a4FieldHeight, "DATA DA EMISSÃO", emissao, "C")
+	y += a4FieldHeight
+	d.field(a4Margin, y, 105, a4FieldHeight, "ENDEREÇO", street, "L")
+	d.field(a4Margin+105, y, 50, a4FieldHeight, "BAIRRO / DISTRITO", address.Bairro, "L")
+	d.field(a4Margin+155, y, 20, a4FieldHeight, "CEP", address.CEP, "L")
+	d.field(a4Margin+175, y, 25, a4FieldHeight, "DATA DA SAÍDA", emissao, "C")
+	y += a4FieldHeight
+	d.field(a4Margin, y, 105, a4FieldHeight, "MUNICÍPIO", address.Municipio, "L")
+	d.field(a4Margin+105, y, 15, a4FieldHeight, "UF", address.UF, "C")
+	d.field(a4Margin+120, y, 50, a4FieldHeight, "E-MAIL", dest.Email, "L")
+	d.field(a4Margin+170, y, 30, a4FieldHeight, "INSCRIÇÃO ESTADUAL", dest.IE, "L")
+	return y + a4FieldHeight
+}
+
+// billing draws the invoice and the installments, when the NF-e was sold on credit
+func (d *a4Document) billing(y float64) float64 {
+	cobranca := d.nfceRequest.Payload.Cobranca
+	if cobranca == nil {
+		return y
+	}
+	y = d.title(y, "FATURA / DUPLICATAS")
+
+	var parts []string
+	if fatura := cobranca.Fatura; fatura != nil {
+		parts = append(parts, fmt.Sprintf("Fatura %s: original R$ %s, desconto R$ %s, líquido R$ %s",
+			fatura.Numero, fatura.ValorOriginal.String(), fatura.Desconto.String(), fatura.ValorLiquido.String()))
+	}
+	for i, duplicata := range cobranca.Duplicatas {
+		vencimento := duplicata.Vencimento
+		if len(vencimento) == 10 {
+			vencimento = vencimento[8:] + "/" + vencimento[5:7] + "/" + vencimento[:4]
+		}
+		parts = append(parts, fmt.Sprintf("%s %s R$ %s", duplicata.NumeroOf(i), vencimento, duplicata.Valor.String()))
+	}
+
+	d.pdf.Rect(a4Margin, y, a4Width-2*a4Margin, a4FieldHeight, "D")
+	d.pdf.SetXY(a4Margin+1, y+0.5)
+	d.pdf.SetFont("Arial", "", 6)
+	d.pdf.MultiCell(a4Width-2*a4Margin-2, 2.5, d.tr(strings.Join(parts, "  |  ")), "", "L", false)
+	return y + a4FieldHeight
+}
+
+// taxes draws the tax totals fixed when the XML was built and the values of the operation
+func (d *a4Document) taxes(y float64) float64 {
+	y = d.title(y, "CÁLCULO DO IMPOSTO")
+
+	payload := d.nfceRequest.Payload
+	totais := entity.DocumentTotals{}
+	if d.nfceRequest.Totais != nil {
+		totais = *d.nfceRequest.Totais
+	}
+	var produtos, desconto, outros money.Amount
+	for _, item := range payload.Itens {
+		produtos += item.Gross()
+		desconto += item.Desconto
+		outros += item.Outros
+	}
+
+	width := (a4Width - 2*a4Margin) / 6
+	row := func(y float64, fields [6][2]string) {
+		for i, field := range fields {
+			d.field(a4Margin+float64(i)*width, y, width, a4FieldHeight, field[0], field[1], "R")
+		}
+	}
+	row(y, [6][2]string{
+		{"BASE DE CÁLC. DO ICMS", totais.VBC.String()},
+		{"VALOR DO ICMS", totais.VICMS.String()},
+		{"BASE DE CÁLC. ICMS S.T.", totais.VBCST.String()},
+		{"VALOR DO ICMS SUBST.", totais.VST.String()},
+		{"V. IMP. IMPORTAÇÃO", totais.VII.String()},
+		{"V. TOTAL PRODUTOS", produtos.String()},
+	})
+	row(y+a4FieldHeight, [6][2]string{
+		{"VALOR DO FRETE", payload.Frete.String()},
+		{"DESCONTO", desconto.String()},
+		{"OUTRAS DESPESAS", outros.String()},
+		{"VALOR TOTAL IPI", totais.VIPI.String()},
+		{"V. TOT. TRIB.", d.nfceRequest.VTotTrib.String()},
+		{"V. TOTAL DA NOTA", payload.Total().String()},
+	})
+	return y + 2*a4FieldHeight
+}
+
+// transport draws the carrier and the volumes, when the NF-e has a transport
+func (d *a4Document) transport(y float64) float64 {
+	transporte := d.nfceRequest.Payload.Transporte
+	if transporte == nil {
+		return y
+	}
+	y = d.title(y, "TRANSPORTADOR / VOLUMES TRANSPORTADOS")
+
+	carrier := transporte.Transportadora
+	if carrier == nil {
+		carrier = &entity.Transportadora{}
+	}
+	document := carrier.CPF
+	if carrier.CNPJ != "" {
+		document = FormatCNPJ(carrier.CNPJ)
+	}
+	d.field(a4Margin, y, 95, a4FieldHeight, "NOME / RAZÃO SOCIAL", carrier.Nome, "L")
+	d.field(a4Margin+95, y, 55, a4FieldHeight, "FRETE POR CONTA", freightLabel(transporte.Modalidade), "L")
+	d.field(a4Margin+150, y, 50, a4FieldHeight, "CNPJ / CPF", document, "L")
+	y += a4FieldHeight
+	d.field(a4Margin, y, 105, a4FieldHeight, "ENDEREÇO", carrier.Endereco, "L")
+	d.field(a4Margin+105, y, 50, a4FieldHeight, "MUNICÍPIO", carrier.Municipio, "L")
+	d.field(a4Margin+155, y, 10, a4FieldHeight, "UF", carrier.UF, "C")
+	d.field(a4Margin+165, y, 35, a4FieldHeight, "INSCRIÇÃO ESTADUAL", carrier.IE, "L")
+	y += a4FieldHeight
+
+	// The volumes are summed in one line, as the layout has room for a single row
+	var quantidade int64
+	var especie, marca, numeracao string
+	var pesoBruto, pesoLiquido money.Quantity
+	for _, volume := range transporte.Volumes {
+		quantidade += volume.Quantidade
+		pesoBruto += volume.PesoBruto
+		pesoLiquido += volume.PesoLiquido
+		if especie == "" {
+			especie, marca, numeracao = volume.Especie, volume.Marca, volume.Numeracao
+		}
+	}
+	d.field(a4Margin, y, 25, a4FieldHeight, "QUANTIDADE", fmt.Sprint(quantidade), "R")
+	d.field(a4Margin+25, y, 45, a4FieldHeight, "ESPÉCIE", especie, "L")
+	d.field(a4Margin+70, y, 40, a4FieldHeight, "MARCA", marca, "L")
+	d.field(a4Margin+110, y, 30, a4FieldHeight, "NUMERAÇÃO", numeracao, "L")
+	d.field(a4Margin+140, y, 30, a4FieldHeight, "PESO BRUTO", fmt.Sprintf("%.3f", pesoBruto.Float64()), "R")
+	d.field(a4Margin+170, y, 30, a4FieldHeight, "PESO LÍQUIDO", fmt.Sprintf("%.3f", pesoLiquido.Float64()), "R")
+	return y + a4FieldHeight
+}
+
+// products draws the items from start to end (exclusive) in the products table
+func (d *a4Document) products(y float64, start, end int) float64 {
+	y = d.title(y, "DADOS DOS PRODUTOS / SERVIÇOS")
+
+	d.pdf.SetFont("Arial", "B", 5.5)
+	x := a4Margin
+	for _, column := range a4Columns {
+		d.pdf.SetXY(x, y)
+		d.pdf.CellFormat(column.width, a4ItemHeight, d.tr(column.title), "1", 0, "C", false, 0, "")
+		x += column.width
+	}
+	y += a4ItemHeight
+
+	payload := d.nfceRequest.Payload
+	values := payload.OperationValues()
+	d.pdf.SetFont("Arial", "", 6)
+	for i := start; i < end; i++ {
+		item := payload.Itens[i]
+		code := item.GTIN
+		if code == "" {
+			code = fmt.Sprintf("%03d", i+1)
+		}
+		vIPI, aliquota := "", ""
+		if item.IPI != nil {
+			vIPI = item.IPI.Value(values[i]).String()
+			aliquota = fmt.Sprintf("%.2f", item.IPI.Aliquota)
+		}
+
+		cells := []string{code, item.Descricao, item.NCM, item.CFOP, item.Unidade, item.Quantidade.String(),
+			item.Valor.String(), item.Desconto.String(), item.Gross().String(), vIPI, aliquota}
+		x := a4Margin
+		for j, column := range a4Columns {
+			d.pdf.SetXY(x, y)
+			d.pdf.CellFormat(column.width, a4ItemHeight, d.fit(cells[j], column.width), "LR", 0, column.align, false, 0, "")
+			x += column.width
+		}
+		y += a4ItemHeight
+	}
+	d.pdf.Line(a4Margin, y, a4Width-a4Margin, y)
+	return y
+}
+
+// additional draws the complementary information of the NF-e
+func (d *a4Document) additional(y float64) {
+	y = d.title(y+1, "DADOS ADICIONAIS")
+
+	var notes []string
+	switch ambiente := d.nfceRequest.Payload.Ambiente; {
+	case ambiente == "simulado":
+		notes = append(notes, "EMITIDA EM AMBIENTE SIMULADO - SEM VALOR FISCAL")
+	case isHomologacao(ambiente):
+		notes = append(notes, "EMITIDA EM AMBIENTE DE HOMOLOGAÇÃO - SEM VALOR FISCAL")
+	}
+	if d.nfceRequest.InContingency {
+		notes = append(notes, "EMITIDA EM CONTINGÊNCIA")
+	}
+	notes = append(notes, "Tributos Totais Incidentes (Lei Federal 12.741/2012): R$ "+d.nfceRequest.VTotTrib.String())
+
+	height := a4Height - a4Margin - y
+	d.pdf.Rect(a4Margin, y, a4Width-2*a4Margin, height, "D")
+	d.pdf.SetXY(a4Margin+1, y+1)
+	d.pdf.SetFont("Arial", "", 7)
+	d.pdf.MultiCell(a4Width-2*a4Margin-2, 3.5, d.tr(strings.Join(notes, "\n")), "", "L", false)
+}
+
+// title draws the caption of a group and returns the top of its fields
+func (d *a4Document) title(y float64, text string) float64 {
+	d.pdf.SetXY(a4Margin, y+0.5)
+	d.pdf.SetFont("Arial", "B", 7)
+	d.pdf.CellFormat(a4Width-2*a4Margin, 3, d.tr(text), "", 0, "L", false, 0, "")
+	return y + 4
+}
+
+// field draws a framed box with the label on top and the value below it
+func (d *a4Document) field(x, y, width, height float64, label, value, align string) {
+	d.pdf.Rect(x, y, width, height, "D")
+	if label != "" {
+		d.pdf.SetXY(x+0.5, y+0.3)
+		d.pdf.SetFont("Arial", "", 5)
+		d.pdf.CellFormat(width-1, 2.5, d.tr(label), "", 0, "L", false, 0, "")
+	}
+
+	d.pdf.SetFont("Arial", "", 8)
+	if label == "" {
+		d.pdf.SetXY(x+0.5, y+0.5)
+		d.pdf.SetFont("Arial", "", 6)
+		d.pdf.MultiCell(width-1, 2.5, d.tr(value), "", align, false)
+		return
+	}
+	d.pdf.SetXY(x+0.5, y+height-4)
+	d.pdf.CellFormat(width-1, 3.5, d.fit(value, width-1), "", 0, align, false, 0, "")
+}
+
+// fit translates the text and cuts it to the width of the cell in the current font
+func (d *a4Document) fit(text string, width float64) string {
+	translated := d.tr(text)
+	for len(translated) > 0 && d.pdf.GetStringWidth(translated) > width-1 {
+		translated = translated[:len(translated)-1]
+	}
+	return translated
+}
+
+// numero formats the number of the NF-e in groups of three digits, as printed on the DANFE
+func (d *a4Document) numero() string {
+	numero := fmt.Sprintf("%09s", d.nfceRequest.Numero)
+	return numero[:3] + "." + numero[3:6] + "." + numero[6:]
+}
+
+// tpNF returns 0 for an entrada and 1 for a saída, from the CFOP of the items
+func (d *a4Document) tpNF() string {
+	itens := d.nfceRequest.Payload.Itens
+	if len(itens) > 0 && itens[0].CFOP != "" && itens[0].CFOP[0] <= '3' {
+		return "0"
+	}
+	return "1"
+}
+
+// freightLabel names the freight modality (modFrete) on the DANFE
+func freightLabel(modalidade string) string {
+	labels := map[string]string{
+		"0": "0 - Remetente (CIF)",
+		"1": "1 - Destinatário (FOB)",
+		"2": "2 - Terceiros",
+		"3": "3 - Próprio Remetente",
+		"4": "4 - Próprio Destinatário",
+		"9": "9 - Sem Transporte",
+	}
+	if label, ok := labels[modalidade]; ok {
+		return label
+	}
+	return modalidade
+}
@@ -0,0 +1,79 @@
+package danfe
+
+import (
+	"fmt"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// code128Patterns are the bar and space widths, in modules, of the Code 128 symbols by
+// value; 105 is the start of code set C and 106 the stop
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// code128C encodes an even number of digits, such as the chave de acesso, in Code 128 code
+// set C and returns the widths of the alternating bars and spaces, starting with a bar
+func code128C(digits string) ([]int, error) {
+	if len(digits) == 0 || len(digits)%2 != 0 {
+		return nil, fmt.Errorf("code 128 C requires an even number of digits, got %d", len(digits))
+	}
+
+	symbols := []int{code128StartC}
+	checksum := code128StartC
+	for i := 0; i < len(digits); i += 2 {
+		if digits[i] < '0' || digits[i] > '9' || digits[i+1] < '0' || digits[i+1] > '9' {
+			return nil, fmt.Errorf("code 128 C encodes digits only, got %q", digits)
+		}
+		value := int(digits[i]-'0')*10 + int(digits[i+1]-'0')
+		symbols = append(symbols, value)
+		checksum += value * (i/2 + 1)
+	}
+	symbols = append(symbols, checksum%103, code128Stop)
+
+	var widths []int
+	for _, symbol := range symbols {
+		for _, width := range code128Patterns[symbol] {
+			widths = append(widths, int(width-'0'))
+		}
+	}
+	return widths, nil
+}
+
+// drawBarcode draws the Code 128 C barcode of digits in the box at x, y, stretching the
+// modules to the box width
+func drawBarcode(pdf *gofpdf.Fpdf, digits string, x, y, width, height float64) error {
+	widths, err := code128C(digits)
+	if err != nil {
+		return err
+	}
+
+	modules := 0
+	for _, w := range widths {
+		modules += w
+	}
+	module := width / float64(modules)
+
+	for i, w := range widths {
+		if i%2 == 0 {
+			pdf.Rect(x, y, float64(w)*module, height, "F")
+		}
+		x += float64(w) * module
+	}
+	return nil
+}
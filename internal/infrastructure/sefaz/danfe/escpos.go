@@ -0,0 +1,194 @@
+package danfe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ESC/POS commands used by the DANFE
+var (
+	escInit          = []byte{0x1b, 0x40}             // ESC @: reset the printer
+	escCodePage860   = []byte{0x1b, 0x74, 0x03}       // ESC t 3: PC860 (Portuguese)
+	escBoldOn        = []byte{0x1b, 0x45, 0x01}       // ESC E 1
+	escBoldOff       = []byte{0x1b, 0x45, 0x00}       // ESC E 0
+	escAlignLeft     = []byte{0x1b, 0x61, 0x00}       // ESC a 0
+	escAlignCenter   = []byte{0x1b, 0x61, 0x01}       // ESC a 1
+	escDoubleSize    = []byte{0x1d, 0x21, 0x11}       // GS ! 0x11: double width and height
+	escNormalSize    = []byte{0x1d, 0x21, 0x00}       // GS ! 0x00
+	escReverseOn     = []byte{0x1d, 0x42, 0x01}       // GS B 1: white on black
+	escReverseOff    = []byte{0x1d, 0x42, 0x00}       // GS B 0
+	escFeedAndCut    = []byte{0x1d, 0x56, 0x42, 0x03} // GS V 66 3: feed and partial cut
+	escQRModel2      = []byte{0x1d, 0x28, 0x6b, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00}
+	escQRCorrectionM = []byte{0x1d, 0x28, 0x6b, 0x03, 0x00, 0x31, 0x45, 0x31}
+	escQRPrint       = []byte{0x1d, 0x28, 0x6b, 0x03, 0x00, 0x31, 0x51, 0x30}
+)
+
+// RenderESCPOS writes the DANFE with one or two vias as an ESC/POS byte stream for thermal
+// printers, cutting the paper after each via. The NF-e has no ESC/POS DANFE.
+func (r *renderer) RenderESCPOS(w io.Writer, company *entity.Company, nfceRequest *entity.NFCE, chaveAcesso string, opts Options) error {
+	if nfceRequest.Payload.IsNFe() {
+		return fmt.Errorf("the DANFE of the NF-e is printed on A4 only")
+	}
+
+	size, ok := papers[opts.Papel]
+	if !ok {
+		size = papers[Papel80mm]
+	}
+
+	content := buildLayout(company, nfceRequest, chaveAcesso, opts)
+
+	ticket := &escposTicket{size: size}
+	ticket.write(escInit, escCodePage860)
+	for _, blocks := range content.vias {
+		if content.contingency {
+			ticket.contingencyMark()
+		}
+		ticket.draw(blocks, content.qrCode)
+		ticket.write(escFeedAndCut)
+	}
+
+	if _, err := w.Write(ticket.buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to render DANFE ESC/POS: %w", err)
+	}
+	return nil
+}
+
+// escposTicket prints the DANFE on the character grid of a thermal printer
+type escposTicket struct {
+	buf  bytes.Buffer
+	size paper
+}
+
+// draw prints the blocks of the via in order
+func (t *escposTicket) draw(blocks []block, qrCode string) {
+	for _, b := range blocks {
+		switch b.kind {
+		case blockText:
+			t.text(b.bold, b.text, b.align)
+		case blockRow:
+			t.row(b.bold, b.text, b.value)
+		case blockSeparator:
+			t.line(strings.Repeat("-", t.size.columns))
+		case blockQRCode:
+			t.qrCode(qrCode)
+		}
+	}
+}
+
+// contingencyMark prints the contingency notice in large reversed letters, standing in
+// for the watermark of the PDF
+func (t *escposTicket) contingencyMark() {
+	t.write(escAlignCenter, escDoubleSize, escReverseOn)
+	t.line(" CONTINGÊNCIA ")
+	t.write(escReverseOff, escNormalSize, escAlignLeft)
+}
+
+// text prints text wrapped at the paper columns
+func (t *escposTicket) text(bold bool, value, align string) {
+	if align == alignCenter {
+		t.write(escAlignCenter)
+	}
+	if bold {
+		t.write(escBoldOn)
+	}
+	for _, line := range wrap(value, t.size.columns) {
+		t.line(line)
+	}
+	if bold {
+		t.write(escBoldOff)
+	}
+	if align == alignCenter {
+		t.write(escAlignLeft)
+	}
+}
+
+// row prints the label and the value at both ends of a line, or the value on its own
+// line aligned to the right when both do not fit
+func (t *escposTicket) row(bold bool, label, value string) {
+	if bold {
+		t.write(escBoldOn)
+	}
+
+	columns := t.size.columns
+	labelWidth, valueWidth := len([]rune(label)), len([]rune(value))
+	if labelWidth+valueWidth+1 > columns {
+		if label != "" {
+			for _, line := range wrap(label, columns) {
+				t.line(line)
+			}
+		}
+		label, labelWidth = "", 0
+	}
+	padding := max(columns-labelWidth-valueWidth, 0)
+	t.line(label + strings.Repeat(" ", padding) + value)
+
+	if bold {
+		t.write(escBoldOff)
+	}
+}
+
+// qrCode prints the QR Code centered with the printer's own QR Code commands (GS ( k)
+func (t *escposTicket) qrCode(content string) {
+	if content == "" {
+		return
+	}
+
+	t.write(escAlignCenter, escQRModel2)
+	t.write([]byte{0x1d, 0x28, 0x6b, 0x03, 0x00, 0x31, 0x43, t.size.qrModule}, escQRCorrectionM)
+
+	// Store the data in the symbol area: pL pH count the data plus the cn, fn and m bytes
+	length := len(content) + 3
+	t.write([]byte{0x1d, 0x28, 0x6b, byte(length % 256), byte(length / 256), 0x31, 0x50, 0x30}, []byte(content))
+	t.write(escQRPrint)
+	t.buf.WriteByte('\n')
+	t.write(escAlignLeft)
+}
+
+// line prints a line of text in the printer code page
+func (t *escposTicket) line(value string) {
+	for _, r := range value {
+		b, ok := charmap.CodePage860.EncodeRune(r)
+		if !ok {
+			b = '?'
+		}
+		t.buf.WriteByte(b)
+	}
+	t.buf.WriteByte('\n')
+}
+
+// write appends raw commands
+func (t *escposTicket) write(commands ...[]byte) {
+	for _, command := range commands {
+		t.buf.Write(command)
+	}
+}
+
+// wrap breaks text into lines of at most width characters, at spaces when possible
+func wrap(text string, width int) []string {
+	var lines []string
+	current := []rune{}
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		if len(current) > 0 && len(current)+1+len(runes) > width {
+			lines = append(lines, string(current))
+			current = current[:0]
+		}
+		if len(current) > 0 {
+			current = append(current, ' ')
+		}
+		current = append(current, runes...)
+		for len(current) > width {
+			lines = append(lines, string(current[:width]))
+			current = append([]rune{}, current[width:]...)
+		}
+	}
+	if len(current) > 0 || len(lines) == 0 {
+		lines = append(lines, string(current))
+	}
+	return lines
+}
@@ -0,0 +1,309 @@
+package danfe
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/qr"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// blockKind identifies how a block of the layout is printed
+type blockKind int
+
+const (
+	blockText      blockKind = iota // Wrapped text across the paper width
+	blockRow                        // Label on the left, value on the right
+	blockSeparator                  // Dashed line between divisions
+	blockQRCode                     // The QR Code of the NFC-e, centered
+)
+
+// Text alignment of a text block
+const (
+	alignLeft   = "L"
+	alignCenter = "C"
+)
+
+// block is one printable element of a via
+type block struct {
+	kind  blockKind
+	bold  bool
+	align string
+	text  string // Text, or the label of a row
+	value string // Right side of a row
+}
+
+// layout is the content of the DANFE NFC-e shared by the PDF and ESC/POS outputs,
+// following the divisions of the official layout
+type layout struct {
+	vias        [][]block
+	contingency bool   // Print the contingency mark
+	qrCode      string // Content of the QR Code, empty when it was not built
+}
+
+// buildLayout lays out one or two vias of the DANFE
+func buildLayout(company *entity.Company, nfceRequest *entity.NFCE, chaveAcesso string, opts Options) layout {
+	type via struct {
+		name    Via
+		compact bool
+	}
+	vias := []via{{"", false}}
+	if opts.DuasVias {
+		vias = []via{{ViaConsumidor, false}, {ViaEstabelecimento, opts.CompactoEstabelecimento}}
+	}
+
+	l := layout{contingency: nfceRequest.InContingency, qrCode: nfceRequest.QRCode}
+	for _, v := range vias {
+		b := &viaBuilder{}
+		b.render(company, nfceRequest, chaveAcesso, v.name, v.compact)
+		l.vias = append(l.vias, b.blocks)
+	}
+	return l
+}
+
+// viaBuilder collects the blocks of one via
+type viaBuilder struct {
+	blocks []block
+}
+
+// render lays out the divisions of the DANFE in order
+func (b *viaBuilder) render(company *entity.Company, nfceRequest *entity.NFCE, chaveAcesso string, via Via, compact bool) {
+	b.header(company, nfceRequest)
+	if compact {
+		// Compact mode only prints the item count
+		b.text(false, fmt.Sprintf("Qtde. total de itens: %d", len(nfceRequest.Payload.Itens)), alignLeft)
+		b.separator()
+	} else {
+		b.items(nfceRequest)
+	}
+	b.totals(nfceRequest)
+	b.fiscalMessages(nfceRequest, chaveAcesso)
+	b.consumer(nfceRequest)
+	b.identification(nfceRequest)
+	b.qrCode()
+	b.taxes(nfceRequest)
+
+	switch via {
+	case ViaConsumidor:
+		b.text(true, "VIA CONSUMIDOR", alignCenter)
+	case ViaEstabelecimento:
+		b.text(true, "VIA ESTABELECIMENTO", alignCenter)
+	}
+}
+
+// header lays out the issuer identification and the document title (divisão I)
+func (b *viaBuilder) header(company *entity.Company, nfceRequest *entity.NFCE) {
+	emitente := nfceRequest.Payload.Emitente
+	if company != nil {
+		b.text(true, company.RazaoSocial, alignCenter)
+	}
+
+	line := "CNPJ: " + FormatCNPJ(emitente.CNPJ)
+	if emitente.IE != "" {
+		line += "  IE: " + emitente.IE
+	}
+	b.text(false, line, alignCenter)
+
+	if company != nil && company.Endereco.Logradouro != "" {
+		address := company.Endereco
+		street := address.Logradouro + ", " + address.Numero
+		if address.Complemento != "" {
+			street += " " + address.Complemento
+		}
+		b.text(false, fmt.Sprintf("%s - %s - %s/%s", street, address.Bairro, address.Municipio, address.UF), alignCenter)
+	}
+	b.separator()
+
+	b.text(true, "Documento Auxiliar da Nota Fiscal de Consumidor Eletrônica", alignCenter)
+	b.separator()
+}
+
+// items lays out the products (divisão II); each item takes a description line and a value line
+func (b *viaBuilder) items(nfceRequest *entity.NFCE) {
+	b.row(true, "Código  Descrição", "Qtde UN x Vl Unit = Vl Total")
+
+	for i, item := range nfceRequest.Payload.Itens {
+		code := item.GTIN
+		if code == "" {
+			code = fmt.Sprintf("%03d", i+1)
+		}
+		b.text(false, code+"  "+item.Descricao, alignLeft)
+		b.row(false, "", fmt.Sprintf("%s %s x %s = %s", item.Quantidade.String(), item.Unidade, item.Valor.String(), item.Gross().String()))
+	}
+	b.separator()
+}
+
+// totals lays out the values of the sale and its payments (divisão III)
+func (b *viaBuilder) totals(nfceRequest *entity.NFCE) {
+	payload := nfceRequest.Payload
+
+	var bruto, desconto, outros money.Amount
+	for _, item := range payload.Itens {
+		bruto += item.Gross()
+		desconto += item.Desconto
+		outros += item.Outros
+	}
+
+	b.row(false, "Qtde. total de itens", fmt.Sprint(len(payload.Itens)))
+	b.row(false, "Valor total R$", bruto.String())
+	if desconto > 0 {
+		b.row(false, "Descontos R$", desconto.String())
+	}
+	if acrescimos := payload.Frete + outros; acrescimos > 0 {
+		b.row(false, "Acréscimos R$", acrescimos.String())
+	}
+	b.row(true, "Valor a Pagar R$", payload.Total().String())
+
+	if len(payload.Pagamentos) > 0 {
+		b.row(true, "FORMA PAGAMENTO", "VALOR PAGO R$")
+		for _, payment := range payload.Pagamentos {
+			b.row(false, PaymentLabel(payment.Forma), payment.Valor.String())
+		}
+		if troco := payload.Change(); troco > 0 {
+			b.row(false, "Troco R$", troco.String())
+		}
+	}
+	b.separator()
+}
+
+// fiscalMessages lays out the environment and contingency notices and the consultation by key (divisão IV)
+func (b *viaBuilder) fiscalMessages(nfceRequest *entity.NFCE, chaveAcesso string) {
+	payload := nfceRequest.Payload
+
+	switch {
+	case payload.Ambiente == "simulado":
+		b.text(true, "EMITIDA EM AMBIENTE SIMULADO - SEM VALOR FISCAL", alignCenter)
+	case isHomologacao(payload.Ambiente):
+		b.text(true, "EMITIDA EM AMBIENTE DE HOMOLOGAÇÃO - SEM VALOR FISCAL", alignCenter)
+	}
+
+	// Contingency notice, mandatory until the NFC-e is authorized by SEFAZ
+	if nfceRequest.InContingency {
+		b.text(true, "EMITIDA EM CONTINGÊNCIA", alignCenter)
+		if nfceRequest.IsPendingTransmission() {
+			b.text(false, "Pendente de autorização", alignCenter)
+		}
+	}
+
+	b.text(true, "Consulte pela Chave de Acesso em", alignCenter)
+	if url := qr.ConsultaURL(payload.UF, payload.Ambiente); url != "" {
+		b.text(false, url, alignCenter)
+	}
+	b.text(false, formatChave(chaveAcesso), alignCenter)
+	b.separator()
+}
+
+// consumer identifies the consumer (divisão V)
+func (b *viaBuilder) consumer(nfceRequest *entity.NFCE) {
+	b.text(true, consumerLine(nfceRequest.Payload.Destinatario), alignCenter)
+	b.separator()
+}
+
+// identification lays out the number, series, emission date and authorization protocol (divisão VI)
+func (b *viaBuilder) identification(nfceRequest *entity.NFCE) {
+	line := fmt.Sprintf("NFC-e nº %s  Série %s", nfceRequest.Numero, nfceRequest.Serie)
+	if !nfceRequest.CreatedAt.IsZero() {
+		line += "  " + nfceRequest.CreatedAt.Format("02/01/2006 15:04:05")
+	}
+	b.text(true, line, alignCenter)
+
+	if nfceRequest.Protocolo != "" {
+		b.text(false, "Protocolo de autorização: "+nfceRequest.Protocolo, alignCenter)
+	}
+	if nfceRequest.AuthorizedAt != nil {
+		b.text(false, "Data de autorização: "+nfceRequest.AuthorizedAt.Format("02/01/2006 15:04:05"), alignCenter)
+	}
+}
+
+// qrCode places the QR Code (divisão VII)
+func (b *viaBuilder) qrCode() {
+	b.blocks = append(b.blocks, block{kind: blockQRCode})
+	b.separator()
+}
+
+// taxes lays out the approximate taxes of the sale required by Lei 12.741/2012 (divisão VIII)
+func (b *viaBuilder) taxes(nfceRequest *entity.NFCE) {
+	b.text(false, "Tributos Totais Incidentes (Lei Federal 12.741/2012): R$ "+nfceRequest.VTotTrib.String(), alignCenter)
+	b.separator()
+}
+
+// text appends a wrapped line of text
+func (b *viaBuilder) text(bold bool, value, align string) {
+	b.blocks = append(b.blocks, block{kind: blockText, bold: bold, align: align, text: value})
+}
+
+// row appends a label and a value printed on the same line
+func (b *viaBuilder) row(bold bool, label, value string) {
+	b.blocks = append(b.blocks, block{kind: blockRow, bold: bold, text: label, value: value})
+}
+
+// separator appends a dashed line
+func (b *viaBuilder) separator() {
+	b.blocks = append(b.blocks, block{kind: blockSeparator})
+}
+
+// isHomologacao reports whether the NFC-e was issued in the test environment
+func isHomologacao(ambiente string) bool {
+	return ambiente == "2" || ambiente == "homologacao"
+}
+
+// formatChave groups the chave de acesso in blocks of four digits, as printed on the DANFE
+func formatChave(chave string) string {
+	var blocks []string
+	for len(chave) > 4 {
+		blocks = append(blocks, chave[:4])
+		chave = chave[4:]
+	}
+	return strings.Join(append(blocks, chave), " ")
+}
+
+// FormatCNPJ formats a 14 digit CNPJ as 00.000.000/0000-00, returning other values as given
+func FormatCNPJ(cnpj string) string {
+	if len(cnpj) != 14 {
+		return cnpj
+	}
+	return fmt.Sprintf("%s.%s.%s/%s-%s", cnpj[:2], cnpj[2:5], cnpj[5:8], cnpj[8:12], cnpj[12:])
+}
+
+// PaymentLabel names the payment form (tPag) on the DANFE and the public consulta
+func PaymentLabel(forma string) string {
+	labels := map[string]string{
+		"01": "Dinheiro",
+		"02": "Cheque",
+		"03": "Cartão de Crédito",
+		"04": "Cartão de Débito",
+		"05": "Crédito Loja",
+		"10": "Vale Alimentação",
+		"11": "Vale Refeição",
+		"12": "Vale Presente",
+		"13": "Vale Combustível",
+		"15": "Boleto Bancário",
+		"16": "Depósito Bancário",
+		"17": "PIX",
+		"18": "Transferência bancária, Carteira Digital",
+		"19": "Programa de fidelidade, Cashback, Crédito Virtual",
+		"90": "Sem pagamento",
+		"99": "Outros",
+	}
+	if label, ok := labels[forma]; ok {
+		return label
+	}
+	return forma
+}
+
+// consumerLine identifies the consumer as required on the DANFE NFC-e
+func consumerLine(dest *entity.Destinatario) string {
+	if dest == nil {
+		return "CONSUMIDOR NÃO IDENTIFICADO"
+	}
+
+	line := "CONSUMIDOR - CNPJ " + dest.CNPJ
+	if dest.CPF != "" {
+		line = "CONSUMIDOR - CPF " + dest.CPF
+	}
+	if dest.Nome != "" {
+		line += " - " + dest.Nome
+	}
+	return line
+}
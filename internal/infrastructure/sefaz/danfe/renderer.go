@@ -0,0 +1,271 @@
+package danfe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/qr"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Via identifies which copy of the DANFE is being printed
+type Via string
+
+const (
+	ViaConsumidor      Via = "consumidor"
+	ViaEstabelecimento Via = "estabelecimento"
+)
+
+// Thermal paper widths supported by the DANFE NFC-e
+const (
+	Papel80mm = entity.DANFEPapel80mm
+	Papel58mm = entity.DANFEPapel58mm
+)
+
+// Options controls how the DANFE NFC-e is rendered
+type Options struct {
+	// DuasVias prints the consumer and establishment copies in the same document
+	DuasVias bool
+	// CompactoEstabelecimento suppresses item details on the establishment copy
+	CompactoEstabelecimento bool
+	// Papel is the thermal paper width, Papel80mm when empty
+	Papel string
+}
+
+// Renderer generates the DANFE NFC-e as PDF or ESC/POS from the same layout, and the A4
+// DANFE of the NF-e as PDF. The company fills the issuer header and may be nil.
+type Renderer interface {
+	Render(company *entity.Company, nfceRequest *entity.NFCE, chaveAcesso string, opts Options) []byte
+	// RenderTo writes the PDF to w, e.g. the writer of an io.Pipe read by the storage upload
+	RenderTo(w io.Writer, company *entity.Company, nfceRequest *entity.NFCE, chaveAcesso string, opts Options) error
+	// RenderESCPOS writes the byte stream printed directly by ESC/POS thermal printers
+	RenderESCPOS(w io.Writer, company *entity.Company, nfceRequest *entity.NFCE, chaveAcesso string, opts Options) error
+}
+
+// renderer implements Renderer using gofpdf and raw ESC/POS commands
+type renderer struct{}
+
+// NewRenderer creates a new DANFE renderer
+func NewRenderer() Renderer {
+	return &renderer{}
+}
+
+// paper holds the geometry of a thermal paper width, in mm for the PDF
+type paper struct {
+	width    float64
+	margin   float64
+	qrSize   float64 // The layout requires at least 25mm
+	fontSize float64
+	columns  int  // Characters per line of the printer font A
+	qrModule byte // Dots per QR Code module on the printer
+}
+
+// printable returns the width available for the content
+func (p paper) printable() float64 {
+	return p.width - 2*p.margin
+}
+
+var papers = map[string]paper{
+	Papel80mm: {width: 80, margin: 4, qrSize: 30, fontSize: 7, columns: 48, qrModule: 5},
+	Papel58mm: {width: 58, margin: 2, qrSize: 25, fontSize: 6, columns: 32, qrModule: 4},
+}
+
+// measureHeight is the page height of the pass that measures a via; thermal paper is a roll
+const measureHeight = 5000
+
+// qrImageName is the name the QR Code PNG is registered under in the PDF
+const qrImageName = "qrcode"
+
+// Render generates the DANFE PDF with one or two vias
+func (r *renderer) Render(company *entity.Company, nfceRequest *entity.NFCE, chaveAcesso string, opts Options) []byte {
+	var buf bytes.Buffer
+	if err := r.RenderTo(&buf, company, nfceRequest, chaveAcesso, opts); err != nil {
+		// Fallback to simple PDF if gofpdf fails
+		return r.renderSimpleFallback(nfceRequest, chaveAcesso)
+	}
+
+	return buf.Bytes()
+}
+
+// RenderTo writes the DANFE PDF with one or two vias to w, each via on a page as tall as its content.
+// The DANFE of the NF-e is printed on A4 and ignores the options.
+func (r *renderer) RenderTo(w io.Writer, company *entity.Company, nfceRequest *entity.NFCE, chaveAcesso string, opts Options) error {
+	if nfceRequest.Payload.IsNFe() {
+		return r.renderA4(w, company, nfceRequest, chaveAcesso)
+	}
+
+	size, ok := papers[opts.Papel]
+	if !ok {
+		size = papers[Papel80mm]
+	}
+
+	content := buildLayout(company, nfceRequest, chaveAcesso, opts)
+
+	var qrImage []byte
+	if content.qrCode != "" {
+		image, err := qr.Image(content.qrCode, 512)
+		if err != nil {
+			return err
+		}
+		qrImage = image
+	}
+
+	pdf := newDocument(size, qrImage)
+	for _, blocks := range content.vias {
+		// Draw the via once on a tall scratch page to learn the height of its page
+		measure := newDocument(size, qrImage)
+		measure.AddPageFormat("P", gofpdf.SizeType{Wd: size.width, Ht: measureHeight})
+		(&page{pdf: measure, size: size}).draw(blocks)
+		if err := measure.Error(); err != nil {
+			return fmt.Errorf("failed to render DANFE: %w", err)
+		}
+		height := measure.GetY() + size.margin
+
+		pdf.AddPageFormat("P", gofpdf.SizeType{Wd: size.width, Ht: height})
+		current := &page{pdf: pdf, size: size}
+		if content.contingency {
+			current.watermark(height)
+		}
+		current.draw(blocks)
+	}
+
+	// Write PDF
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to render DANFE: %w", err)
+	}
+
+	return nil
+}
+
+// newDocument creates a PDF for the paper width with the QR Code image registered
+func newDocument(size paper, qrImage []byte) *gofpdf.Fpdf {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    gofpdf.SizeType{Wd: size.width, Ht: measureHeight},
+	})
+	pdf.SetMargins(size.margin, size.margin, size.margin)
+	pdf.SetAutoPageBreak(false, 0)
+
+	if qrImage != nil {
+		pdf.RegisterImageOptionsReader(qrImageName, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(qrImage))
+	}
+	return pdf
+}
+
+// page draws one via of the DANFE NFC-e on a PDF page
+type page struct {
+	pdf  *gofpdf.Fpdf
+	size paper
+	tr   func(string) string
+}
+
+// draw prints the blocks of the via in order
+func (p *page) draw(blocks []block) {
+	// Core fonts are cp1252; translate the accented texts
+	p.tr = p.pdf.UnicodeTranslatorFromDescriptor("")
+
+	for _, b := range blocks {
+		switch b.kind {
+		case blockText:
+			p.text(b.bold, b.text, b.align)
+		case blockRow:
+			p.row(b.bold, b.text, b.value)
+		case blockSeparator:
+			p.separator()
+		case blockQRCode:
+			p.qrCode()
+		}
+	}
+}
+
+// qrCode draws the QR Code image centered
+func (p *page) qrCode() {
+	if p.pdf.GetImageInfo(qrImageName) == nil {
+		return
+	}
+
+	y := p.pdf.GetY() + 1
+	x := (p.size.width - p.size.qrSize) / 2
+	p.pdf.ImageOptions(qrImageName, x, y, p.size.qrSize, p.size.qrSize, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	p.pdf.SetY(y + p.size.qrSize + 1)
+}
+
+// watermark draws the contingency mark across the page, below the content
+func (p *page) watermark(height float64) {
+	tr := p.pdf.UnicodeTranslatorFromDescriptor("")
+	text := tr("CONTINGÊNCIA")
+
+	p.pdf.SetFont("Arial", "B", p.size.fontSize*2.6)
+	p.pdf.SetTextColor(215, 215, 215)
+
+	cx, cy := p.size.width/2, height/2
+	p.pdf.TransformBegin()
+	p.pdf.TransformRotate(60, cx, cy)
+	p.pdf.Text(cx-p.pdf.GetStringWidth(text)/2, cy, text)
+	p.pdf.TransformEnd()
+
+	p.pdf.SetTextColor(0, 0, 0)
+}
+
+// text draws a wrapped line of text across the printable width
+func (p *page) text(bold bool, value, align string) {
+	p.setFont(bold)
+	p.pdf.MultiCell(p.size.printable(), p.lineHeight(), p.tr(value), "", align, false)
+}
+
+// row draws a label on the left and a value on the right of the same line
+func (p *page) row(bold bool, label, value string) {
+	p.setFont(bold)
+	width := p.size.printable()
+	valueWidth := p.pdf.GetStringWidth(p.tr(value)) + 1
+
+	p.pdf.CellFormat(width-valueWidth, p.lineHeight(), p.tr(label), "", 0, "L", false, 0, "")
+	p.pdf.CellFormat(valueWidth, p.lineHeight(), p.tr(value), "", 1, "R", false, 0, "")
+}
+
+// separator draws a dashed line between the divisions
+func (p *page) separator() {
+	y := p.pdf.GetY() + 1
+	p.pdf.SetDashPattern([]float64{0.8, 0.5}, 0)
+	p.pdf.Line(p.size.margin, y, p.size.width-p.size.margin, y)
+	p.pdf.SetDashPattern([]float64{}, 0)
+	p.pdf.SetY(y + 1)
+}
+
+// setFont selects the regular or bold font at the paper font size
+func (p *page) setFont(bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	p.pdf.SetFont("Arial", style, p.size.fontSize)
+}
+
+// lineHeight returns the height of a text line for the paper font size
+func (p *page) lineHeight() float64 {
+	return p.size.fontSize * 0.45
+}
+
+// renderSimpleFallback creates a minimal PDF if gofpdf fails
+func (r *renderer) renderSimpleFallback(nfceRequest *entity.NFCE, chaveAcesso string) []byte {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(190, 20, "DANFE NFC-e")
+	pdf.Ln(20)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(190, 8, fmt.Sprintf("Chave de Acesso: %s", chaveAcesso))
+	pdf.Ln(10)
+	pdf.Cell(190, 8, fmt.Sprintf("Emitente: %s", nfceRequest.Payload.Emitente.CNPJ))
+	pdf.Ln(10)
+
+	pdf.Cell(190, 8, "Valor Total: R$ "+nfceRequest.Payload.Total().String())
+
+	var buf bytes.Buffer
+	pdf.Output(&buf)
+	return buf.Bytes()
+}
@@ -2,56 +2,112 @@ package nfe
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/base64"
 	"fmt"
 	"strconv"
 	"time"
 
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/clock"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
 )
 
 // Builder handles NFC-e XML construction
 type Builder interface {
 	BuildNFCe(input NFCeInput, companyID string) (*NFCe, error)
-	GenerateChaveAcesso(uf, cnpj, serie, nNF, tpEmis, cNF string, dhEmi time.Time) (string, error)
+	GenerateChaveAcesso(uf, cnpj, mod, serie, nNF, tpEmis, cNF string, dhEmi time.Time) (string, error)
 	CalculateDV(chave string) string
 }
 
+// ChaveAcessoRegistry tells whether a chave de acesso was already issued
+type ChaveAcessoRegistry interface {
+	ChaveAcessoExists(ctx context.Context, chave string) (bool, error)
+}
+
+// maxCNFDraws bounds the cNF drawn for one NFC-e when the chaves keep colliding
+const maxCNFDraws = 5
+
+// RespTecConfig is the installation-wide technical responsible (infRespTec),
+// used when the input carries none
+type RespTecConfig struct {
+	CNPJ     string
+	XContato string
+	Email    string
+	Fone     string
+	CSRT     map[string]CSRT // Keyed by UF
+}
+
+// CSRT is the Código de Segurança do Responsável Técnico issued by a UF
+type CSRT struct {
+	ID    string
+	Token string
+}
+
+// Enabled reports whether the installation has a technical responsible configured
+func (c RespTecConfig) Enabled() bool {
+	return c.CNPJ != ""
+}
+
 // builder implements Builder interface
 type builder struct {
 	companyRepo ports.CompanyRepository
+	chaves      ChaveAcessoRegistry
+	clock       clock.Clock
+	respTec     RespTecConfig
 }
 
-// NewBuilder creates a new NFC-e builder
-func NewBuilder(companyRepo ports.CompanyRepository) Builder {
+// NewBuilder creates a new NFC-e builder; dhEmi is taken from the given clock, the cNF is
+// drawn again while chaves already has the chave de acesso, and infRespTec defaults to
+// respTec when the input has none
+func NewBuilder(companyRepo ports.CompanyRepository, chaves ChaveAcessoRegistry, c clock.Clock, respTec RespTecConfig) Builder {
+	if c == nil {
+		c = clock.SystemClock{}
+	}
 	return &builder{
 		companyRepo: companyRepo,
+		chaves:      chaves,
+		clock:       c,
+		respTec:     respTec,
 	}
 }
 
-// BuildNFCe builds a complete NFC-e XML from input data
+// BuildNFCe builds a complete NFC-e XML from input data, or the NF-e when the input is modelo 55
 func (b *builder) BuildNFCe(input NFCeInput, companyID string) (*NFCe, error) {
-	// Get next sequential number (NNF) from database
-	nextNumber, err := b.companyRepo.GetNextNFCeNumber(context.Background(), companyID)
+	ctx := context.Background()
+
+	mod := modelo(input)
+	if mod != modNFCe && mod != modNFe {
+		return nil, fmt.Errorf("invalid modelo: %s", mod)
+	}
+	// The issuer municipality is also the place of the taxable event (cMunFG); checked before
+	// a number of the series is taken
+	if cMun := input.Emitente.EnderEmit.CMun; len(cMun) != 7 || cMun[:2] != b.getCUF(input.UF) {
+		return nil, fmt.Errorf("invalid emitente cMun %q: the company address needs the IBGE code of a municipality of %s", cMun, input.UF)
+	}
+
+	// Series chosen by the emission or the company default for the model and environment
+	serie, err := b.serieFor(ctx, input, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get default NFC-e series: %w", err)
+	}
+
+	// Get next sequential number (NNF) of the series from database
+	nextNumber, err := b.companyRepo.GetNextNFCeNumber(ctx, companyID, mod, input.Ambiente, serie)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get next NFC-e number: %w", err)
 	}
 	nNF := strconv.FormatInt(nextNumber, 10)
+	serieStr := strconv.Itoa(serie)
 
-	// Generate random number for CNF (8 digits)
-	cNF := b.generateCNF()
+	// dhEmi, dhCont and the chave AAMM share one instant in the UF time zone
+	dhEmi := b.clock.Now().In(clock.Location(input.UF))
 
 	// Generate chave de acesso
-	chave, err := b.GenerateChaveAcesso(
-		input.UF,
-		input.Emitente.CNPJ,
-		"1", // serie - should be configurable
-		nNF,
-		"1", // tpEmis - normal
-		cNF,
-		time.Now(), // dhEmi
-	)
+	cNF, chave, err := b.newChave(ctx, input, mod, serieStr, nNF, dhEmi)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate chave acesso: %w", err)
+		return nil, err
 	}
 
 	// Build NFC-e structure
@@ -59,18 +115,19 @@ func (b *builder) BuildNFCe(input NFCeInput, companyID string) (*NFCe, error) {
 		InfNFe: InfNFe{
 			Versao: "4.00",
 			Id:     "NFe" + chave,
-			Ide:    b.buildIde(input, nNF, cNF, chave),
+			Ide:    b.buildIde(input, serieStr, nNF, cNF, chave, dhEmi),
 			Emit:   b.buildEmit(input.Emitente),
 			Det:    b.buildDet(input.Itens),
 			Total:  b.buildTotal(input.Itens),
 			Transp: b.buildTransp(input.Transp),
-			Pag:    b.buildPag(input.Pagamentos),
+			Cobr:   b.buildCobr(input.Cobr),
+			Pag:    b.buildPag(input.Pagamentos, input.VTroco),
 		},
 	}
 
 	// Add optional fields
 	if input.Destinatario != nil {
-		dest := b.buildDest(*input.Destinatario)
+		dest := b.buildDest(*input.Destinatario, input.Ambiente)
 		nfce.InfNFe.Dest = &dest
 	}
 
@@ -79,47 +136,131 @@ func (b *builder) BuildNFCe(input NFCeInput, companyID string) (*NFCe, error) {
 		nfce.InfNFe.InfIntermed = &infIntermed
 	}
 
-	if input.InfRespTec != nil {
-		infRespTec := b.buildInfRespTec(*input.InfRespTec)
+	if respTec, ok := b.respTecFor(input); ok {
+		infRespTec := b.buildInfRespTec(respTec, chave)
 		nfce.InfNFe.InfRespTec = &infRespTec
 	}
 
 	return nfce, nil
 }
 
+// serieFor returns the series of the emission, the company default when none was chosen
+func (b *builder) serieFor(ctx context.Context, input NFCeInput, companyID string) (int, error) {
+	if input.Serie != nil {
+		return *input.Serie, nil
+	}
+	return b.companyRepo.GetDefaultNFCeSerie(ctx, companyID, modelo(input), input.Ambiente)
+}
+
+// modelo returns the document model of the input, the NFC-e when none was chosen
+func modelo(input NFCeInput) string {
+	if input.Modelo == "" {
+		return modNFCe
+	}
+	return input.Modelo
+}
+
 // buildIde builds identification block
-func (b *builder) buildIde(input NFCeInput, nNF, cNF, chave string) Ide {
-	// Get municipality code based on UF
-	cMunFG := b.getMunicipioFG(input.UF)
-
-	// Determine emission type based on contingency
-	tpEmis := "1" // Normal emission
-	if input.Contingency {
-		if input.ContingencyType == "SVC-AN" {
-			tpEmis = "6" // SVC-AN contingency
-		} else if input.ContingencyType == "SVC-RS" {
-			tpEmis = "7" // SVC-RS contingency
-		}
-	}
-
-	return Ide{
-		CUF:     b.getCUF(input.UF),
-		CNF:     cNF,
-		NatOp:   "VENDA",
-		Mod:     "65", // NFC-e
-		Serie:   "1",
-		NNF:     nNF,
-		DhEmi:   time.Now().Format(time.RFC3339),
-		TpNF:    "1", // Saída
-		IdDest:  "1", // Interna
-		CmunFG:  cMunFG,
-		TpImp:   "4",                       // DANFE NFC-e
-		TpEmis:  tpEmis,                    // Normal or contingency
-		Cdv:     b.CalculateDV(chave[:43]), // Last digit of chave
-		TpAmb:   input.Ambiente,
-		ProcEmi: "0", // Emissão própria
-		VerProc: "1.0.0",
+func (b *builder) buildIde(input NFCeInput, serie, nNF, cNF, chave string, dhEmi time.Time) Ide {
+	natOp := input.NatOp
+	if natOp == "" {
+		natOp = "VENDA"
+	}
+
+	ide := Ide{
+		CUF:      b.getCUF(input.UF),
+		CNF:      cNF,
+		NatOp:    natOp,
+		Mod:      modelo(input),
+		Serie:    serie,
+		NNF:      nNF,
+		DhEmi:    clock.FormatDateTime(dhEmi, input.UF),
+		TpNF:     "1", // Saída
+		IdDest:   "1", // Interna
+		CmunFG:   input.Emitente.EnderEmit.CMun,
+		TpImp:    "4",                       // DANFE NFC-e
+		TpEmis:   b.tpEmis(input),           // Normal or contingency
+		Cdv:      b.CalculateDV(chave[:43]), // Last digit of chave
+		TpAmb:    tpAmb(input.Ambiente),
+		FinNFe:   "1", // Normal
+		IndFinal: "1", // Consumidor final
+		IndPres:  "1", // Presencial
+		ProcEmi:  "0", // Emissão própria
+		VerProc:  defaultVerProc,
+	}
+	if input.VerProc != "" {
+		ide.VerProc = input.VerProc
+	}
+	ide.IndIntermed = "0" // Own site or establishment
+	if input.InfIntermed != nil {
+		ide.IndIntermed = "1" // Third party platform
 	}
+
+	// The NF-e prints the DANFE A4 and takes its direction and destination from the CFOP
+	// of the items, validated as consistent: 1xxx-3xxx entrada, 5xxx-7xxx saída
+	if ide.Mod == modNFe {
+		ide.TpImp = "1"   // DANFE retrato
+		ide.IndPres = "9" // Não presencial, outros
+		if input.Destinatario != nil && input.Destinatario.IndIEDest == "1" {
+			ide.IndFinal = "0" // Contribuinte, not a final consumer
+		}
+		if len(input.Itens) > 0 && input.Itens[0].CFOP != "" {
+			group := input.Itens[0].CFOP[0]
+			if group <= '3' {
+				ide.TpNF = "0" // Entrada
+			}
+			switch group {
+			case '2', '6':
+				ide.IdDest = "2" // Interestadual
+			case '3', '7':
+				ide.IdDest = "3" // Exterior
+			}
+		}
+	}
+
+	if input.TpImp != "" {
+		ide.TpImp = input.TpImp
+	}
+
+	// Contingency emissions must inform when and why the contingency was entered
+	if ide.TpEmis != "1" {
+		just := input.ContingencyJust
+		if just == "" {
+			just = defaultContingencyJust
+		}
+		enteredAt := dhEmi
+		if input.ContingencyAt != nil && input.ContingencyAt.Before(dhEmi) {
+			enteredAt = *input.ContingencyAt
+		}
+		dhCont := clock.FormatDateTime(enteredAt, input.UF)
+		ide.DhCont = &dhCont
+		ide.XJust = &just
+	}
+
+	return ide
+}
+
+// defaultVerProc is the verProc of the documents whose company configured none
+const defaultVerProc = "1.0.0"
+
+// defaultContingencyJust is the xJust used when the caller does not give one
+const defaultContingencyJust = "SEFAZ autorizadora indisponivel para autorizacao em tempo real"
+
+// tpEmis determines the emission type based on contingency
+func (b *builder) tpEmis(input NFCeInput) string {
+	if !input.Contingency {
+		return "1" // Normal emission
+	}
+
+	switch input.ContingencyType {
+	case "SVC-AN":
+		return "6" // SVC-AN contingency
+	case "SVC-RS":
+		return "7" // SVC-RS contingency
+	case "OFFLINE":
+		return "9" // Offline contingency (NFC-e)
+	}
+	return "1"
 }
 
 // buildEmit builds issuer block
@@ -148,13 +289,31 @@ func (b *builder) buildEmit(emit EmitenteInput) Emit {
 	}
 }
 
-// buildDest builds destination block
-func (b *builder) buildDest(dest DestinatarioInput) Dest {
+// tpAmb returns the tpAmb code of an ambiente; the simulated one is issued as homologação
+func tpAmb(ambiente string) string {
+	if ambiente == "1" || ambiente == "producao" {
+		return "1"
+	}
+	return "2"
+}
+
+// homologacaoXNome is the only consumer name SEFAZ accepts in homologação (rejection 598)
+const homologacaoXNome = "NF-E EMITIDA EM AMBIENTE DE HOMOLOGACAO - SEM VALOR FISCAL"
+
+// buildDest builds destination block; in homologação the consumer name is masked
+func (b *builder) buildDest(dest DestinatarioInput, ambiente string) Dest {
+	xNome := dest.XNome
+	if tpAmb(ambiente) == "2" {
+		masked := homologacaoXNome
+		xNome = &masked
+	}
+
 	return Dest{
 		CNPJ:      dest.CNPJ,
 		CPF:       dest.CPF,
-		XNome:     dest.XNome,
+		XNome:     xNome,
 		IndIEDest: dest.IndIEDest,
+		IE:        dest.IE,
 		Email:     dest.Email,
 		EnderDest: func() *EnderDest {
 			if dest.EnderDest == nil {
@@ -188,6 +347,7 @@ func (b *builder) buildDet(itens []ItemInput) []Det {
 				CEAN:     item.CEAN,
 				XProd:    item.XProd,
 				NCM:      item.NCM,
+				CEST:     item.CEST,
 				CFOP:     item.CFOP,
 				UCom:     item.UCom,
 				QCom:     item.QCom,
@@ -197,6 +357,9 @@ func (b *builder) buildDet(itens []ItemInput) []Det {
 				UTrib:    item.UTrib,
 				QTrib:    item.QTrib,
 				VUnTrib:  item.VUnTrib,
+				VFrete:   item.VFrete,
+				VDesc:    item.VDesc,
+				VOutro:   item.VOutro,
 				IndTot:   item.IndTot,
 				XPed:     item.XPed,
 				NItemPed: item.NItemPed,
@@ -216,6 +379,19 @@ func (b *builder) buildImposto(imposto ImpostoInput) Imposto {
 	// Build ICMS
 	imp.ICMS = b.buildICMS(imposto.ICMS)
 
+	// Build IPI and II (NF-e)
+	if imposto.IPI != nil {
+		imp.IPI = b.buildIPI(*imposto.IPI)
+	}
+	if imposto.II != nil {
+		imp.II = &II{
+			VBC:      imposto.II.VBC,
+			VDespAdu: imposto.II.VDespAdu,
+			VII:      imposto.II.VII,
+			VIOF:     imposto.II.VIOF,
+		}
+	}
+
 	// Build PIS
 	imp.PIS = b.buildPIS(imposto.PIS)
 
@@ -361,6 +537,26 @@ func (b *builder) buildICMS(icms ICMSInput) ICMS {
 	return result
 }
 
+// buildIPI builds IPI tax block, IPITrib for the taxed CSTs and IPINT for the others
+func (b *builder) buildIPI(ipi IPIInput) *IPI {
+	result := &IPI{CEnq: ipi.CEnq}
+	if result.CEnq == "" {
+		result.CEnq = "999" // Tributação normal
+	}
+
+	if ipi.VIPI == nil {
+		result.IPINT = &IPINT{CST: ipi.CST}
+		return result
+	}
+	result.IPITrib = &IPITrib{
+		CST:  ipi.CST,
+		VBC:  *ipi.VBC,
+		PIPI: *ipi.PIPI,
+		VIPI: *ipi.VIPI,
+	}
+	return result
+}
+
 // buildPIS builds PIS tax block
 func (b *builder) buildPIS(pis PISInput) PIS {
 	var result PIS
@@ -431,68 +627,147 @@ func (b *builder) buildCOFINS(cofins COFINSInput) COFINS {
 	return result
 }
 
-// buildTotal builds total block
+// buildTotal builds total block; the totals are the exact sums of the item tags,
+// as SEFAZ rejects an ICMSTot that differs from them by a centavo (e.g. 629 and 610)
 func (b *builder) buildTotal(itens []ItemInput) Total {
-	var vBC, vICMS, vBCST, vST, vProd, vPIS, vCOFINS float64
+	var vBC, vICMS, vBCST, vST, vProd, vFrete, vDesc, vOutro, vII, vIPI, vPIS, vCOFINS, vTotTrib money.Amount
+	hasTotTrib := false
 
 	for _, item := range itens {
-		vProdItem, _ := strconv.ParseFloat(item.VProd, 64)
-		vProd += vProdItem
+		vProd += parseAmount(&item.VProd)
+
+		// Discounts and charges of the item
+		vFrete += parseAmount(item.VFrete)
+		vDesc += parseAmount(item.VDesc)
+		vOutro += parseAmount(item.VOutro)
 
 		// Calculate tax values based on ICMS
-		if item.Imposto.ICMS.VBC != nil {
-			vbc, _ := strconv.ParseFloat(*item.Imposto.ICMS.VBC, 64)
-			vBC += vbc
-		}
-		if item.Imposto.ICMS.VICMS != nil {
-			vicms, _ := strconv.ParseFloat(*item.Imposto.ICMS.VICMS, 64)
-			vICMS += vicms
-		}
-		if item.Imposto.ICMS.VBCST != nil {
-			vbcst, _ := strconv.ParseFloat(*item.Imposto.ICMS.VBCST, 64)
-			vBCST += vbcst
+		vBC += parseAmount(item.Imposto.ICMS.VBC)
+		vICMS += parseAmount(item.Imposto.ICMS.VICMS)
+		vBCST += parseAmount(item.Imposto.ICMS.VBCST)
+		vST += parseAmount(item.Imposto.ICMS.VICMSST)
+
+		// IPI and II of the NF-e
+		if item.Imposto.IPI != nil {
+			vIPI += parseAmount(item.Imposto.IPI.VIPI)
 		}
-		if item.Imposto.ICMS.VICMSST != nil {
-			vicmsst, _ := strconv.ParseFloat(*item.Imposto.ICMS.VICMSST, 64)
-			vST += vicmsst
+		if item.Imposto.II != nil {
+			vII += parseAmount(&item.Imposto.II.VII)
 		}
 
 		// PIS and COFINS
-		if item.Imposto.PIS.VPIS != nil {
-			vpis, _ := strconv.ParseFloat(*item.Imposto.PIS.VPIS, 64)
-			vPIS += vpis
-		}
-		if item.Imposto.COFINS.VCOFINS != nil {
-			vcofins, _ := strconv.ParseFloat(*item.Imposto.COFINS.VCOFINS, 64)
-			vCOFINS += vcofins
+		vPIS += parseAmount(item.Imposto.PIS.VPIS)
+		vCOFINS += parseAmount(item.Imposto.COFINS.VCOFINS)
+
+		// Approximate tax burden (Lei 12.741/2012)
+		if item.Imposto.VTotTrib != nil {
+			vTotTrib += parseAmount(item.Imposto.VTotTrib)
+			hasTotTrib = true
 		}
 	}
 
-	vNF := vProd + vST // Total value
+	vNF := vProd - vDesc + vST + vFrete + vOutro + vII + vIPI // Total value
+
+	var vTotTribTotal *string
+	if hasTotTrib {
+		vTotTribTotal = moneyPtr(vTotTrib)
+	}
 
 	return Total{
 		ICMSTot: ICMSTot{
-			VBC:     fmt.Sprintf("%.2f", vBC),
-			VICMS:   fmt.Sprintf("%.2f", vICMS),
-			VBCST:   fmt.Sprintf("%.2f", vBCST),
-			VST:     fmt.Sprintf("%.2f", vST),
-			VProd:   fmt.Sprintf("%.2f", vProd),
-			VPIS:    fmt.Sprintf("%.2f", vPIS),
-			VCOFINS: fmt.Sprintf("%.2f", vCOFINS),
-			VNF:     fmt.Sprintf("%.2f", vNF),
+			VBC:       vBC.String(),
+			VICMS:     vICMS.String(),
+			VBCST:     vBCST.String(),
+			VST:       vST.String(),
+			VProd:     vProd.String(),
+			VFrete:    moneyPtr(vFrete),
+			VSeg:      moneyPtr(0),
+			VDesc:     moneyPtr(vDesc),
+			VII:       moneyPtr(vII),
+			VIPI:      moneyPtr(vIPI),
+			VIPIDevol: moneyPtr(0),
+			VPIS:      vPIS.String(),
+			VCOFINS:   vCOFINS.String(),
+			VOutro:    moneyPtr(vOutro),
+			VNF:       vNF.String(),
+			VTotTrib:  vTotTribTotal,
 		},
 	}
 }
 
-// buildTransp builds transport block
+// parseAmount reads an optional monetary tag of an item; absent tags count as zero
+func parseAmount(value *string) money.Amount {
+	if value == nil {
+		return 0
+	}
+	amount, _ := money.Parse(*value)
+	return amount
+}
+
+// buildTransp builds transport block; the carrier and volumes are NF-e only
 func (b *builder) buildTransp(transp TranspInput) Transp {
-	return Transp{
+	result := Transp{
 		ModFrete: transp.ModFrete,
 	}
+
+	if carrier := transp.Transporta; carrier != nil {
+		result.Transporta = &Transporta{
+			CNPJ:   carrier.CNPJ,
+			CPF:    carrier.CPF,
+			XNome:  carrier.XNome,
+			IE:     carrier.IE,
+			XEnder: carrier.XEnder,
+			XMun:   carrier.XMun,
+			UF:     carrier.UF,
+		}
+	}
+	for _, vol := range transp.Vol {
+		result.Vol = append(result.Vol, Vol{
+			QVol:  vol.QVol,
+			Esp:   vol.Esp,
+			Marca: vol.Marca,
+			NVol:  vol.NVol,
+			PesoL: vol.PesoL,
+			PesoB: vol.PesoB,
+		})
+	}
+
+	return result
+}
+
+// buildCobr builds billing block of the NF-e; nil without billing
+func (b *builder) buildCobr(cobr *CobrInput) *Cobr {
+	if cobr == nil || (cobr.Fat == nil && len(cobr.Dup) == 0) {
+		return nil
+	}
+
+	result := &Cobr{}
+	if cobr.Fat != nil {
+		result.Fat = &Fat{
+			NFat:  cobr.Fat.NFat,
+			VOrig: cobr.Fat.VOrig,
+			VDesc: cobr.Fat.VDesc,
+			VLiq:  cobr.Fat.VLiq,
+		}
+	}
+	for _, dup := range cobr.Dup {
+		result.Dup = append(result.Dup, Dup{
+			NDup:  dup.NDup,
+			DVenc: dup.DVenc,
+			VDup:  dup.VDup,
+		})
+	}
+	return result
+}
+
+// moneyPtr formats a value with the two decimals of the NFC-e layout
+func moneyPtr(value money.Amount) *string {
+	formatted := value.String()
+	return &formatted
 }
 
 // buildPag builds payment block
-func (b *builder) buildPag(pagamentos []PagamentoInput) Pag {
+func (b *builder) buildPag(pagamentos []PagamentoInput, vTroco *string) Pag {
 	detPag := make([]DetPag, len(pagamentos))
 	for i, pag := range pagamentos {
 		detPag[i] = DetPag{
@@ -514,7 +789,7 @@ func (b *builder) buildPag(pagamentos []PagamentoInput) Pag {
 
 	return Pag{
 		DetPag: detPag,
-		VTroco: nil, // Calculate if needed
+		VTroco: vTroco,
 	}
 }
 
@@ -527,18 +802,52 @@ func (b *builder) buildInfIntermed(inf InfIntermedInput) InfIntermed {
 	}
 }
 
+// respTecFor returns the technical responsible of the input, falling back to the installation one
+func (b *builder) respTecFor(input NFCeInput) (InfRespTecInput, bool) {
+	if input.InfRespTec != nil {
+		return *input.InfRespTec, true
+	}
+	if !b.respTec.Enabled() {
+		return InfRespTecInput{}, false
+	}
+
+	respTec := InfRespTecInput{
+		CNPJ:     b.respTec.CNPJ,
+		XContato: b.respTec.XContato,
+		Email:    b.respTec.Email,
+		Fone:     b.respTec.Fone,
+	}
+	if csrt, ok := b.respTec.CSRT[input.UF]; ok {
+		respTec.IdCSRT = csrt.ID
+		respTec.CSRT = csrt.Token
+	}
+	return respTec, true
+}
+
 // buildInfRespTec builds technical responsible information block
-func (b *builder) buildInfRespTec(inf InfRespTecInput) InfRespTec {
-	return InfRespTec{
+func (b *builder) buildInfRespTec(inf InfRespTecInput, chave string) InfRespTec {
+	infRespTec := InfRespTec{
 		CNPJ:     inf.CNPJ,
 		XContato: inf.XContato,
 		Email:    inf.Email,
 		Fone:     inf.Fone,
 	}
+	if inf.IdCSRT != "" && inf.CSRT != "" {
+		hash := HashCSRT(inf.CSRT, chave)
+		infRespTec.IdCSRT = &inf.IdCSRT
+		infRespTec.HashCSRT = &hash
+	}
+	return infRespTec
+}
+
+// HashCSRT computes hashCSRT: the base64 SHA-1 of the CSRT concatenated with the chave de acesso
+func HashCSRT(csrt, chave string) string {
+	sum := sha1.Sum([]byte(csrt + chave))
+	return base64.StdEncoding.EncodeToString(sum[:])
 }
 
-// GenerateChaveAcesso generates the access key for NFC-e
-func (b *builder) GenerateChaveAcesso(uf, cnpj, serie, nNF, tpEmis, cNF string, dhEmi time.Time) (string, error) {
+// GenerateChaveAcesso generates the access key of an NFC-e (mod 65) or NF-e (mod 55)
+func (b *builder) GenerateChaveAcesso(uf, cnpj, mod, serie, nNF, tpEmis, cNF string, dhEmi time.Time) (string, error) {
 	cUF := b.getCUF(uf)
 	aamm := dhEmi.Format("0601") // YYMM
 
@@ -549,8 +858,8 @@ func (b *builder) GenerateChaveAcesso(uf, cnpj, serie, nNF, tpEmis, cNF string,
 	}
 
 	// Format: CUF + AAMM + CNPJ + MOD + SERIE + NNF + TPEMIS + CNF + DV
-	chave := fmt.Sprintf("%02s%04s%014s65%03s%09s%01s%08s",
-		cUF, aamm, cleanCNPJ, serie, nNF, tpEmis, cNF)
+	chave := fmt.Sprintf("%02s%04s%014s%02s%03s%09s%01s%08s",
+		cUF, aamm, cleanCNPJ, mod, serie, nNF, tpEmis, cNF)
 
 	dv := b.CalculateDV(chave)
 	return chave + dv, nil
@@ -583,39 +892,12 @@ func (b *builder) CalculateDV(chave string) string {
 
 // getCUF returns the federal unit code
 func (b *builder) getCUF(uf string) string {
-	ufCodes := map[string]string{
-		"AC": "12", "AL": "27", "AP": "16", "AM": "13", "BA": "29",
-		"CE": "23", "DF": "53", "ES": "32", "GO": "52", "MA": "21",
-		"MT": "51", "MS": "50", "MG": "31", "PA": "15", "PB": "25",
-		"PR": "41", "PE": "26", "PI": "22", "RJ": "33", "RN": "24",
-		"RS": "43", "RO": "11", "RR": "14", "SC": "42", "SP": "35",
-		"SE": "28", "TO": "17",
-	}
-
-	if code, exists := ufCodes[uf]; exists {
+	if code, exists := GetCUF(uf); exists {
 		return code
 	}
 	return "35" // Default to SP
 }
 
-// getMunicipioFG returns the municipality code for the federal unit
-func (b *builder) getMunicipioFG(uf string) string {
-	// Default municipalities for each UF (capital cities)
-	municipios := map[string]string{
-		"AC": "1200401", "AL": "2704302", "AP": "1600303", "AM": "1302603", "BA": "2927408",
-		"CE": "2304400", "DF": "5300108", "ES": "3205309", "GO": "5208707", "MA": "2111300",
-		"MT": "5103403", "MS": "5002704", "MG": "3106200", "PA": "1501402", "PB": "2507507",
-		"PR": "4106902", "PE": "2611606", "PI": "2211001", "RJ": "3304557", "RN": "2408102",
-		"RS": "4314902", "RO": "1100205", "RR": "1400100", "SC": "4205407", "SP": "3550308",
-		"SE": "2800308", "TO": "1721000",
-	}
-
-	if code, exists := municipios[uf]; exists {
-		return code
-	}
-	return "3550308" // Default to São Paulo
-}
-
 // cleanNumericOnly removes all non-numeric characters
 func (b *builder) cleanNumericOnly(s string) string {
 	var result []rune
@@ -627,9 +909,28 @@ func (b *builder) cleanNumericOnly(s string) string {
 	return string(result)
 }
 
-// generateCNF generates a random 8-digit CNF (Código Numérico)
-func (b *builder) generateCNF() string {
-	// Generate random 8-digit number (00000001 to 99999999)
-	// In production, ensure uniqueness within the company for the day
-	return fmt.Sprintf("%08d", time.Now().UnixNano()%99999999+1)
+// newChave draws the cNF and returns it with the chave de acesso it gives, drawing again
+// while the chave was already issued to another NFC-e
+func (b *builder) newChave(ctx context.Context, input NFCeInput, mod, serie, nNF string, dhEmi time.Time) (string, string, error) {
+	for draw := 0; draw < maxCNFDraws; draw++ {
+		cNF, err := entity.NewCNF(nNF)
+		if err != nil {
+			return "", "", err
+		}
+		chave, err := b.GenerateChaveAcesso(input.UF, input.Emitente.CNPJ, mod, serie, nNF, b.tpEmis(input), cNF, dhEmi)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to generate chave acesso: %w", err)
+		}
+		if b.chaves == nil {
+			return cNF, chave, nil
+		}
+		exists, err := b.chaves.ChaveAcessoExists(ctx, chave)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to check chave acesso: %w", err)
+		}
+		if !exists {
+			return cNF, chave, nil
+		}
+	}
+	return "", "", fmt.Errorf("%w: %d cNF drawn for número %s", entity.ErrChaveAcessoCollision, maxCNFDraws, nNF)
 }
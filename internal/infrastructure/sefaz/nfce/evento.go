@@ -0,0 +1,109 @@
+package nfe
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+const (
+	// TpEventoCancelamento is the event type for NFC-e cancellation
+	TpEventoCancelamento = "110111"
+
+	eventoVersao = "1.00"
+	nfeNamespace = "http://www.portalfiscal.inf.br/nfe"
+)
+
+// Evento represents a signed NF-e/NFC-e event (evento)
+type Evento struct {
+	XMLName   xml.Name  `xml:"evento"`
+	Xmlns     string    `xml:"xmlns,attr"`
+	Versao    string    `xml:"versao,attr"`
+	InfEvento InfEvento `xml:"infEvento"`
+}
+
+// InfEvento represents the event information block
+type InfEvento struct {
+	Id         string    `xml:"Id,attr"`
+	COrgao     string    `xml:"cOrgao"`
+	TpAmb      string    `xml:"tpAmb"`
+	CNPJ       string    `xml:"CNPJ"`
+	ChNFe      string    `xml:"chNFe"`
+	DhEvento   string    `xml:"dhEvento"`
+	TpEvento   string    `xml:"tpEvento"`
+	NSeqEvento string    `xml:"nSeqEvento"`
+	VerEvento  string    `xml:"verEvento"`
+	DetEvento  DetEvento `xml:"detEvento"`
+}
+
+// DetEvento represents the event details for cancellation
+type DetEvento struct {
+	Versao     string `xml:"versao,attr"`
+	DescEvento string `xml:"descEvento"`
+	NProt      string `xml:"nProt"`
+	XJust      string `xml:"xJust"`
+}
+
+// CancelamentoInput represents input data for the cancellation event
+type CancelamentoInput struct {
+	ChaveAcesso   string
+	CNPJ          string
+	Protocolo     string
+	Justificativa string
+	Ambiente      string
+	DhEvento      time.Time
+}
+
+// BuildCancelamento builds the cancellation event (tpEvento 110111) for an authorized NFC-e
+func BuildCancelamento(input CancelamentoInput) (*Evento, error) {
+	if len(input.ChaveAcesso) != 44 {
+		return nil, fmt.Errorf("invalid chave de acesso: %s", input.ChaveAcesso)
+	}
+	if input.Protocolo == "" {
+		return nil, fmt.Errorf("authorization protocol is required for cancellation")
+	}
+	if len(input.Justificativa) < 15 || len(input.Justificativa) > 255 {
+		return nil, fmt.Errorf("justificativa must have between 15 and 255 characters")
+	}
+
+	return &Evento{
+		Xmlns:  nfeNamespace,
+		Versao: eventoVersao,
+		InfEvento: InfEvento{
+			Id:         fmt.Sprintf("ID%s%s01", TpEventoCancelamento, input.ChaveAcesso),
+			COrgao:     input.ChaveAcesso[:2], // cUF of the emitter
+			TpAmb:      tpAmb(input.Ambiente),
+			CNPJ:       input.CNPJ,
+			ChNFe:      input.ChaveAcesso,
+			DhEvento:   input.DhEvento.Format("2006-01-02T15:04:05-07:00"),
+			TpEvento:   TpEventoCancelamento,
+			NSeqEvento: "1", // NFC-e accepts a single cancellation event
+			VerEvento:  eventoVersao,
+			DetEvento: DetEvento{
+				Versao:     eventoVersao,
+				DescEvento: "Cancelamento",
+				NProt:      input.Protocolo,
+				XJust:      input.Justificativa,
+			},
+		},
+	}, nil
+}
+
+// BuildProcEventoNFe wraps the signed event and SEFAZ retEvento into the procEventoNFe distribution XML
+func BuildProcEventoNFe(signedEvento, retEvento []byte) []byte {
+	// Drop the XML declaration of the signed event, if any
+	if bytes.HasPrefix(signedEvento, []byte("<?xml")) {
+		if idx := bytes.Index(signedEvento, []byte("?>")); idx != -1 {
+			signedEvento = signedEvento[idx+2:]
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	buf.WriteString(fmt.Sprintf(`<procEventoNFe versao="%s" xmlns="%s">`, eventoVersao, nfeNamespace))
+	buf.Write(bytes.TrimSpace(signedEvento))
+	buf.Write(retEvento)
+	buf.WriteString(`</procEventoNFe>`)
+	return buf.Bytes()
+}
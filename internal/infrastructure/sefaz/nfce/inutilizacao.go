@@ -0,0 +1,138 @@
+package nfe
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+const inutilizacaoVersao = "4.00"
+
+// ufCodes maps the federal unit abbreviation to its IBGE code (cUF)
+var ufCodes = map[string]string{
+	"AC": "12", "AL": "27", "AP": "16", "AM": "13", "BA": "29",
+	"CE": "23", "DF": "53", "ES": "32", "GO": "52", "MA": "21",
+	"MT": "51", "MS": "50", "MG": "31", "PA": "15", "PB": "25",
+	"PR": "41", "PE": "26", "PI": "22", "RJ": "33", "RN": "24",
+	"RS": "43", "RO": "11", "RR": "14", "SC": "42", "SP": "35",
+	"SE": "28", "TO": "17",
+}
+
+// GetCUF returns the IBGE code of the federal unit
+func GetCUF(uf string) (string, bool) {
+	code, exists := ufCodes[uf]
+	return code, exists
+}
+
+// GetUF returns the federal unit of the IBGE code (cUF)
+func GetUF(cUF string) (string, bool) {
+	for uf, code := range ufCodes {
+		if code == cUF {
+			return uf, true
+		}
+	}
+	return "", false
+}
+
+// InutNFe represents the inutilização de numeração request (inutNFe)
+type InutNFe struct {
+	XMLName xml.Name `xml:"inutNFe"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Versao  string   `xml:"versao,attr"`
+	InfInut InfInut  `xml:"infInut"`
+}
+
+// InfInut represents the inutilização information block
+type InfInut struct {
+	Id     string `xml:"Id,attr"`
+	TpAmb  string `xml:"tpAmb"`
+	XServ  string `xml:"xServ"`
+	CUF    string `xml:"cUF"`
+	Ano    string `xml:"ano"`
+	CNPJ   string `xml:"CNPJ"`
+	Mod    string `xml:"mod"`
+	Serie  int    `xml:"serie"`
+	NNFIni int64  `xml:"nNFIni"`
+	NNFFin int64  `xml:"nNFFin"`
+	XJust  string `xml:"xJust"`
+}
+
+// InutilizacaoInput represents input data for the inutilização request
+type InutilizacaoInput struct {
+	UF            string
+	Ambiente      string
+	Ano           int // Year of the inutilização, only the last two digits are used
+	CNPJ          string
+	Modelo        string // 65 (NFC-e) or 55 (NF-e); NFC-e when empty
+	Serie         int
+	NNFIni        int64
+	NNFFin        int64
+	Justificativa string
+}
+
+// BuildInutilizacao builds the inutNFe request for a range of NFC-e or NF-e numbers
+func BuildInutilizacao(input InutilizacaoInput) (*InutNFe, error) {
+	cUF, ok := GetCUF(input.UF)
+	if !ok {
+		return nil, fmt.Errorf("invalid UF: %s", input.UF)
+	}
+	if len(input.CNPJ) != 14 {
+		return nil, fmt.Errorf("invalid CNPJ: %s", input.CNPJ)
+	}
+	if input.Serie < 0 || input.Serie > 999 {
+		return nil, fmt.Errorf("invalid serie: %d", input.Serie)
+	}
+	if input.NNFIni <= 0 || input.NNFFin > 999999999 || input.NNFIni > input.NNFFin {
+		return nil, fmt.Errorf("invalid number range: %d-%d", input.NNFIni, input.NNFFin)
+	}
+	if len(input.Justificativa) < 15 || len(input.Justificativa) > 255 {
+		return nil, fmt.Errorf("justificativa must have between 15 and 255 characters")
+	}
+
+	mod := input.Modelo
+	if mod == "" {
+		mod = modNFCe
+	}
+	if mod != modNFCe && mod != modNFe {
+		return nil, fmt.Errorf("invalid modelo: %s", mod)
+	}
+
+	ano := fmt.Sprintf("%02d", input.Ano%100)
+
+	return &InutNFe{
+		Xmlns:  nfeNamespace,
+		Versao: inutilizacaoVersao,
+		InfInut: InfInut{
+			// ID + cUF + ano + CNPJ + mod + serie + nNFIni + nNFFin
+			Id:     fmt.Sprintf("ID%s%s%s%s%03d%09d%09d", cUF, ano, input.CNPJ, mod, input.Serie, input.NNFIni, input.NNFFin),
+			TpAmb:  tpAmb(input.Ambiente),
+			XServ:  "INUTILIZAR",
+			CUF:    cUF,
+			Ano:    ano,
+			CNPJ:   input.CNPJ,
+			Mod:    mod,
+			Serie:  input.Serie,
+			NNFIni: input.NNFIni,
+			NNFFin: input.NNFFin,
+			XJust:  input.Justificativa,
+		},
+	}, nil
+}
+
+// BuildProcInutNFe wraps the signed inutNFe and SEFAZ retInutNFe into the procInutNFe distribution XML
+func BuildProcInutNFe(signedInut, retInut []byte) []byte {
+	// Drop the XML declaration of the signed request, if any
+	if bytes.HasPrefix(signedInut, []byte("<?xml")) {
+		if idx := bytes.Index(signedInut, []byte("?>")); idx != -1 {
+			signedInut = signedInut[idx+2:]
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	buf.WriteString(fmt.Sprintf(`<procInutNFe versao="%s" xmlns="%s">`, inutilizacaoVersao, nfeNamespace))
+	buf.Write(bytes.TrimSpace(signedInut))
+	buf.Write(retInut)
+	buf.WriteString(`</procInutNFe>`)
+	return buf.Bytes()
+}
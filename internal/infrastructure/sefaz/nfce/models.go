@@ -2,9 +2,16 @@ package nfe
 
 import (
 	"encoding/xml"
+	"time"
 )
 
-// NFCe represents the complete NFC-e structure
+// Document models of the layout 4.00 built here
+const (
+	modNFCe = "65"
+	modNFe  = "55"
+)
+
+// NFCe represents the complete NFC-e structure, also used for the NF-e of the same layout
 type NFCe struct {
 	XMLName xml.Name `xml:"NFe"`
 	InfNFe  InfNFe   `xml:"infNFe"`
@@ -29,23 +36,29 @@ type InfNFe struct {
 
 // Ide represents identification information
 type Ide struct {
-	CUF      string  `xml:"cUF"`
-	CNF      string  `xml:"cNF"`
-	NatOp    string  `xml:"natOp"`
-	Mod      string  `xml:"mod"`
-	Serie    string  `xml:"serie"`
-	NNF      string  `xml:"nNF"`
-	DhEmi    string  `xml:"dhEmi"`
-	DhSaiEnt *string `xml:"dhSaiEnt,omitempty"`
-	TpNF     string  `xml:"tpNF"`
-	IdDest   string  `xml:"idDest"`
-	CmunFG   string  `xml:"cMunFG"`
-	TpImp    string  `xml:"tpImp"`
-	TpEmis   string  `xml:"tpEmis"`
-	Cdv      string  `xml:"cDV"`
-	TpAmb    string  `xml:"tpAmb"`
-	ProcEmi  string  `xml:"procEmi"`
-	VerProc  string  `xml:"verProc"`
+	CUF         string  `xml:"cUF"`
+	CNF         string  `xml:"cNF"`
+	NatOp       string  `xml:"natOp"`
+	Mod         string  `xml:"mod"`
+	Serie       string  `xml:"serie"`
+	NNF         string  `xml:"nNF"`
+	DhEmi       string  `xml:"dhEmi"`
+	DhSaiEnt    *string `xml:"dhSaiEnt,omitempty"`
+	TpNF        string  `xml:"tpNF"`
+	IdDest      string  `xml:"idDest"`
+	CmunFG      string  `xml:"cMunFG"`
+	TpImp       string  `xml:"tpImp"`
+	TpEmis      string  `xml:"tpEmis"`
+	Cdv         string  `xml:"cDV"`
+	TpAmb       string  `xml:"tpAmb"`
+	FinNFe      string  `xml:"finNFe,omitempty"`
+	IndFinal    string  `xml:"indFinal,omitempty"`
+	IndPres     string  `xml:"indPres,omitempty"`
+	IndIntermed string  `xml:"indIntermed,omitempty"`
+	ProcEmi     string  `xml:"procEmi"`
+	VerProc     string  `xml:"verProc"`
+	DhCont      *string `xml:"dhCont,omitempty"` // Contingency entry time (tpEmis != 1)
+	XJust       *string `xml:"xJust,omitempty"`  // Contingency justification, 15 to 256 characters
 }
 
 // Emit represents issuer information
@@ -75,14 +88,15 @@ type EnderEmit struct {
 	Fone    *string `xml:"fone,omitempty"`
 }
 
-// Dest represents destination information (optional for NFC-e)
+// Dest represents destination information (optional for NFC-e, required for NF-e)
 type Dest struct {
 	CNPJ      *string    `xml:"CNPJ,omitempty"`
 	CPF       *string    `xml:"CPF,omitempty"`
 	XNome     *string    `xml:"xNome,omitempty"`
+	EnderDest *EnderDest `xml:"enderDest,omitempty"`
 	IndIEDest string     `xml:"indIEDest"`
+	IE        *string    `xml:"IE,omitempty"`
 	Email     *string    `xml:"email,omitempty"`
-	EnderDest *EnderDest `xml:"enderDest,omitempty"`
 }
 
 // EnderDest represents destination address
@@ -94,7 +108,7 @@ type EnderDest struct {
 	CMun    string  `xml:"cMun"`
 	XMun    string  `xml:"xMun"`
 	UF      string  `xml:"UF"`
-	CEP     string  `xml:"CEP"`
+	CEP     string  `xml:"CEP,omitempty"` // Absent for addresses abroad
 	CPais   *string `xml:"cPais,omitempty"`
 	XPais   *string `xml:"xPais,omitempty"`
 	Fone    *string `xml:"fone,omitempty"`
@@ -113,6 +127,7 @@ type Prod struct {
 	CEAN     *string `xml:"cEAN,omitempty"`
 	XProd    string  `xml:"xProd"`
 	NCM      string  `xml:"NCM"`
+	CEST     *string `xml:"CEST,omitempty"`
 	CFOP     string  `xml:"CFOP"`
 	UCom     string  `xml:"uCom"`
 	QCom     string  `xml:"qCom"`
@@ -122,6 +137,10 @@ type Prod struct {
 	UTrib    string  `xml:"uTrib"`
 	QTrib    string  `xml:"qTrib"`
 	VUnTrib  string  `xml:"vUnTrib"`
+	VFrete   *string `xml:"vFrete,omitempty"`
+	VSeg     *string `xml:"vSeg,omitempty"`
+	VDesc    *string `xml:"vDesc,omitempty"`
+	VOutro   *string `xml:"vOutro,omitempty"`
 	IndTot   string  `xml:"indTot"`
 	XPed     *string `xml:"xPed,omitempty"`
 	NItemPed *string `xml:"nItemPed,omitempty"`
@@ -131,10 +150,40 @@ type Prod struct {
 type Imposto struct {
 	VTotTrib *string `xml:"vTotTrib,omitempty"`
 	ICMS     ICMS    `xml:"ICMS"`
+	IPI      *IPI    `xml:"IPI,omitempty"` // NF-e only
+	II       *II     `xml:"II,omitempty"`  // NF-e only, imports
 	PIS      PIS     `xml:"PIS"`
 	COFINS   COFINS  `xml:"COFINS"`
 }
 
+// IPI represents the IPI tax of an NF-e item
+type IPI struct {
+	CEnq    string   `xml:"cEnq"`
+	IPITrib *IPITrib `xml:"IPITrib,omitempty"`
+	IPINT   *IPINT   `xml:"IPINT,omitempty"`
+}
+
+// IPITrib represents the taxed IPI (CST 00, 49, 50 and 99)
+type IPITrib struct {
+	CST  string `xml:"CST"`
+	VBC  string `xml:"vBC"`
+	PIPI string `xml:"pIPI"`
+	VIPI string `xml:"vIPI"`
+}
+
+// IPINT represents the untaxed IPI (CST 01 to 05 and 51 to 55)
+type IPINT struct {
+	CST string `xml:"CST"`
+}
+
+// II represents the import tax of an NF-e item
+type II struct {
+	VBC      string `xml:"vBC"`
+	VDespAdu string `xml:"vDespAdu"`
+	VII      string `xml:"vII"`
+	VIOF     string `xml:"vIOF"`
+}
+
 // ICMS represents ICMS tax
 type ICMS struct {
 	ICMS00    *ICMS00    `xml:"ICMS00,omitempty"`
@@ -406,7 +455,30 @@ type ICMSTot struct {
 
 // Transp represents transport information
 type Transp struct {
-	ModFrete string `xml:"modFrete"`
+	ModFrete   string      `xml:"modFrete"`
+	Transporta *Transporta `xml:"transporta,omitempty"`
+	Vol        []Vol       `xml:"vol,omitempty"`
+}
+
+// Transporta represents the carrier of an NF-e
+type Transporta struct {
+	CNPJ   *string `xml:"CNPJ,omitempty"`
+	CPF    *string `xml:"CPF,omitempty"`
+	XNome  *string `xml:"xNome,omitempty"`
+	IE     *string `xml:"IE,omitempty"`
+	XEnder *string `xml:"xEnder,omitempty"`
+	XMun   *string `xml:"xMun,omitempty"`
+	UF     *string `xml:"UF,omitempty"`
+}
+
+// Vol represents the packages carried by an NF-e
+type Vol struct {
+	QVol  *string `xml:"qVol,omitempty"`
+	Esp   *string `xml:"esp,omitempty"`
+	Marca *string `xml:"marca,omitempty"`
+	NVol  *string `xml:"nVol,omitempty"`
+	PesoL *string `xml:"pesoL,omitempty"`
+	PesoB *string `xml:"pesoB,omitempty"`
 }
 
 // Cobr represents billing information (optional)
@@ -460,23 +532,34 @@ type InfIntermed struct {
 
 // InfRespTec represents technical responsible information
 type InfRespTec struct {
-	CNPJ     string `xml:"CNPJ"`
-	XContato string `xml:"xContato"`
-	Email    string `xml:"email"`
-	Fone     string `xml:"fone"`
+	CNPJ     string  `xml:"CNPJ"`
+	XContato string  `xml:"xContato"`
+	Email    string  `xml:"email"`
+	Fone     string  `xml:"fone"`
+	IdCSRT   *string `xml:"idCSRT,omitempty"`
+	HashCSRT *string `xml:"hashCSRT,omitempty"`
 }
 
 // NFCeInput represents the input data for NFC-e generation
 type NFCeInput struct {
 	UF              string
 	Ambiente        string
-	Contingency     bool   // Whether to use contingency mode
-	ContingencyType string // "SVC-AN" or "SVC-RS"
+	Modelo          string     // 65 (NFC-e) or 55 (NF-e); NFC-e when empty
+	NatOp           string     // VENDA when empty
+	Serie           *int       // Numbering series; the company default when nil
+	TpImp           string     // DANFE print type; 4 for the NFC-e and 1 for the NF-e when empty
+	VerProc         string     // Version of the issuing application; defaultVerProc when empty
+	Contingency     bool       // Whether to use contingency mode
+	ContingencyType string     // "SVC-AN", "SVC-RS" or "OFFLINE"
+	ContingencyJust string     // xJust of the contingency; defaultContingencyJust when empty
+	ContingencyAt   *time.Time // dhCont, when the contingency was entered; dhEmi when nil
 	Emitente        EmitenteInput
 	Destinatario    *DestinatarioInput
 	Itens           []ItemInput
 	Pagamentos      []PagamentoInput
+	VTroco          *string
 	Transp          TranspInput
+	Cobr            *CobrInput // NF-e only
 	InfIntermed     *InfIntermedInput
 	InfRespTec      *InfRespTecInput
 }
@@ -514,6 +597,7 @@ type DestinatarioInput struct {
 	CPF       *string
 	XNome     *string
 	IndIEDest string
+	IE        *string
 	Email     *string
 	EnderDest *EnderDestInput
 }
@@ -539,6 +623,7 @@ type ItemInput struct {
 	CEAN     *string
 	XProd    string
 	NCM      string
+	CEST     *string
 	CFOP     string
 	UCom     string
 	QCom     string
@@ -548,6 +633,9 @@ type ItemInput struct {
 	UTrib    string
 	QTrib    string
 	VUnTrib  string
+	VFrete   *string // Freight share of the item
+	VDesc    *string
+	VOutro   *string
 	IndTot   string
 	XPed     *string
 	NItemPed *string
@@ -558,10 +646,29 @@ type ItemInput struct {
 type ImpostoInput struct {
 	VTotTrib *string
 	ICMS     ICMSInput
+	IPI      *IPIInput // NF-e only
+	II       *IIInput  // NF-e only
 	PIS      PISInput
 	COFINS   COFINSInput
 }
 
+// IPIInput represents IPI input; VBC, PIPI and VIPI are set for the taxed CSTs
+type IPIInput struct {
+	CEnq string
+	CST  string
+	VBC  *string
+	PIPI *string
+	VIPI *string
+}
+
+// IIInput represents import tax input
+type IIInput struct {
+	VBC      string
+	VDespAdu string
+	VII      string
+	VIOF     string
+}
+
 // ICMSInput represents ICMS input
 type ICMSInput struct {
 	Tipo    string // "ICMS00", "ICMS10", etc.
@@ -616,7 +723,51 @@ type CardInput struct {
 
 // TranspInput represents transport input
 type TranspInput struct {
-	ModFrete string
+	ModFrete   string
+	Transporta *TransportaInput
+	Vol        []VolInput
+}
+
+// TransportaInput represents carrier input
+type TransportaInput struct {
+	CNPJ   *string
+	CPF    *string
+	XNome  *string
+	IE     *string
+	XEnder *string
+	XMun   *string
+	UF     *string
+}
+
+// VolInput represents transported volume input
+type VolInput struct {
+	QVol  *string
+	Esp   *string
+	Marca *string
+	NVol  *string
+	PesoL *string
+	PesoB *string
+}
+
+// CobrInput represents billing input
+type CobrInput struct {
+	Fat *FatInput
+	Dup []DupInput
+}
+
+// FatInput represents the invoice of the billing
+type FatInput struct {
+	NFat  string
+	VOrig string
+	VDesc *string
+	VLiq  string
+}
+
+// DupInput represents an installment of the billing
+type DupInput struct {
+	NDup  string
+	DVenc string
+	VDup  string
 }
 
 // InfIntermedInput represents intermediary input
@@ -632,4 +783,6 @@ type InfRespTecInput struct {
 	XContato string
 	Email    string
 	Fone     string
+	IdCSRT   string // CSRT identifier issued by the UF, e.g. "01"
+	CSRT     string // Código de Segurança do Responsável Técnico; only its hash goes to the XML
 }
@@ -3,11 +3,14 @@ package qr
 import (
 	"context"
 	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"net/url"
-	"strconv"
 	"strings"
+	"time"
 
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/tables"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
 	"github.com/skip2/go-qrcode"
 )
 
@@ -23,9 +26,13 @@ type Params struct {
 	CSCID        string
 	CSCToken     string
 	UF           string
-	Contingency  bool // Whether this is a contingency NFC-e
+	Contingency  bool   // Whether this is a contingency NFC-e
+	TpEmis       string // Emission type of the NFC-e; TpEmisOffline selects the offline payload
 }
 
+// TpEmisOffline is the tpEmis of NFC-e issued in offline contingency
+const TpEmisOffline = "9"
+
 // Generator builds the URL (and optionally image) for NFC-e QR Code v3.
 type Generator interface {
 	BuildURL(ctx context.Context, params Params) (string, error)
@@ -47,6 +54,11 @@ func (g *generator) BuildURL(ctx context.Context, params Params) (string, error)
 		return "", fmt.Errorf("invalid parameters: %w", err)
 	}
 
+	// Offline contingency NFC-e carry their own hash payload, scannable without SEFAZ authorization
+	if params.IsOffline() {
+		return g.buildOfflineURL(params)
+	}
+
 	// Build the payload string according to NT 2025.001
 	payload := g.buildPayload(params)
 
@@ -141,6 +153,54 @@ func (g *generator) buildPayload(params Params) string {
 	return strings.Join(parts, "|")
 }
 
+// IsOffline reports whether the QR Code must use the offline contingency format (tpEmis 9)
+func (p Params) IsOffline() bool {
+	return p.Contingency && p.TpEmis == TpEmisOffline
+}
+
+// buildOfflinePayload builds the payload string for offline contingency hash generation
+func (g *generator) buildOfflinePayload(params Params) (string, error) {
+	// Format for offline emission (tpEmis=9)
+	// chNFe|nVersao|tpAmb|diaEmi|vNF|digVal|cIdToken
+	dhEmi, err := time.Parse(time.RFC3339, params.DhEmi)
+	if err != nil {
+		return "", fmt.Errorf("data de emissão inválida: %w", err)
+	}
+
+	parts := []string{
+		params.ChaveAcesso,        // chNFe
+		"3",                       // nVersao
+		params.TpAmb,              // tpAmb (1=produção, 2=homologação)
+		dhEmi.Format("02"),        // diaEmi - day of the month of dhEmi
+		formatDecimal(params.VNF), // vNF
+		hex.EncodeToString([]byte(params.DigVal)), // digVal in hexadecimal
+		params.CSCID, // cIdToken
+	}
+
+	return strings.Join(parts, "|"), nil
+}
+
+// buildOfflineURL builds the QR Code URL for offline contingency NFC-e
+func (g *generator) buildOfflineURL(params Params) (string, error) {
+	payload, err := g.buildOfflinePayload(params)
+	if err != nil {
+		return "", fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	hash := g.generateHash(payload, params.CSCToken)
+
+	baseURL := g.getBaseURL(params.UF, params.TpAmb)
+	if contingencyURL := g.getContingencyBaseURL(params.UF, params.TpAmb); contingencyURL != "" {
+		baseURL = contingencyURL
+	}
+	if baseURL == "" {
+		baseURL = "https://www.nfce.fazenda.sp.gov.br/qrcode"
+	}
+
+	// The whole payload goes in the p parameter followed by the hash
+	return fmt.Sprintf("%s?p=%s|%s", baseURL, payload, hash), nil
+}
+
 // generateHash generates SHA-1 hash of payload + CSC token
 func (g *generator) generateHash(payload, cscToken string) string {
 	// Concatenate payload with CSC token
@@ -194,54 +254,16 @@ func (g *generator) buildQRURL(params Params, hash string) string {
 	return fullURL
 }
 
-// getBaseURL returns the base URL for QR Code according to UF and environment
+// getBaseURL returns the base URL for QR Code according to UF and environment, from the
+// SEFAZ tables in use
 func (g *generator) getBaseURL(uf, tpAmb string) string {
 	// Environment: 1=produção, 2=homologação
-	isProduction := tpAmb == "1"
-
-	// URLs by UF for production and homologation
-	ufURLs := map[string]map[string]string{
-		"AC": {"prod": "https://www.sefaznet.ac.gov.br/nfce/qrcode", "hom": "https://www.sefaznet.ac.gov.br/nfce/qrcode"},
-		"AL": {"prod": "https://nfce.sefaz.al.gov.br/QRCode/consultarNFCe.jsp", "hom": "https://nfce.sefaz.al.gov.br/QRCode/consultarNFCe.jsp"},
-		"AP": {"prod": "https://www.sefaz.ap.gov.br/nfce/nfcep.php", "hom": "https://www.sefaz.ap.gov.br/nfce/nfcep.php"},
-		"AM": {"prod": "https://www.sefaz.am.gov.br/nfce/qrcode", "hom": "https://www.sefaz.am.gov.br/nfce/qrcode"},
-		"BA": {"prod": "https://nfce.sefaz.ba.gov.br/servicos/nfce/default.aspx", "hom": "https://nfce.sefaz.ba.gov.br/servicos/nfce/default.aspx"},
-		"CE": {"prod": "https://nfce.sefaz.ce.gov.br/pages/ShowNFCe.html", "hom": "https://nfce.sefaz.ce.gov.br/pages/ShowNFCe.html"},
-		"DF": {"prod": "https://www.fazenda.df.gov.br/nfce/qrcode", "hom": "https://www.fazenda.df.gov.br/nfce/qrcode"},
-		"ES": {"prod": "https://www.sefaz.es.gov.br/nfce/qrcode", "hom": "https://www.sefaz.es.gov.br/nfce/qrcode"},
-		"GO": {"prod": "https://nfce.sefaz.go.gov.br/nfce/qrcode", "hom": "https://nfce.sefaz.go.gov.br/nfce/qrcode"},
-		"MA": {"prod": "https://www.sefaz.ma.gov.br/nfce/qrcode", "hom": "https://www.sefaz.ma.gov.br/nfce/qrcode"},
-		"MT": {"prod": "https://www.sefaz.mt.gov.br/nfce/qrcode", "hom": "https://www.sefaz.mt.gov.br/nfce/qrcode"},
-		"MS": {"prod": "https://www.dfe.ms.gov.br/nfce/qrcode", "hom": "https://www.dfe.ms.gov.br/nfce/qrcode"},
-		"MG": {"prod": "https://nfce.fazenda.mg.gov.br/portalnfce/sistema/qrcode.xhtml", "hom": "https://nfce.fazenda.mg.gov.br/portalnfce/sistema/qrcode.xhtml"},
-		"PA": {"prod": "https://www.sefa.pa.gov.br/nfce/qrcode", "hom": "https://www.sefa.pa.gov.br/nfce/qrcode"},
-		"PB": {"prod": "https://www.sefaz.pb.gov.br/nfce/qrcode", "hom": "https://www.sefaz.pb.gov.br/nfce/qrcode"},
-		"PR": {"prod": "https://www.fazenda.pr.gov.br/nfce/qrcode", "hom": "https://www.fazenda.pr.gov.br/nfce/qrcode"},
-		"PE": {"prod": "https://nfce.sefaz.pe.gov.br/nfce/consulta", "hom": "https://nfce.sefaz.pe.gov.br/nfce/consulta"},
-		"PI": {"prod": "https://www.sefaz.pi.gov.br/nfce/qrcode", "hom": "https://www.sefaz.pi.gov.br/nfce/qrcode"},
-		"RJ": {"prod": "https://www.fazenda.rj.gov.br/nfce/qrcode", "hom": "https://www.fazenda.rj.gov.br/nfce/qrcode"},
-		"RN": {"prod": "https://www.sefaz.rn.gov.br/nfce/qrcode", "hom": "https://www.sefaz.rn.gov.br/nfce/qrcode"},
-		"RS": {"prod": "https://www.sefaz.rs.gov.br/nfce/qrcode", "hom": "https://www.sefaz.rs.gov.br/nfce/qrcode"},
-		"RO": {"prod": "https://www.sefaz.ro.gov.br/nfce/qrcode", "hom": "https://www.sefaz.ro.gov.br/nfce/qrcode"},
-		"RR": {"prod": "https://www.sefaz.rr.gov.br/nfce/qrcode", "hom": "https://www.sefaz.rr.gov.br/nfce/qrcode"},
-		"SC": {"prod": "https://sat.sef.sc.gov.br/nfce/qrcode", "hom": "https://sat.sef.sc.gov.br/nfce/qrcode"},
-		"SP": {"prod": "https://www.nfce.fazenda.sp.gov.br/qrcode", "hom": "https://www.nfce.fazenda.sp.gov.br/qrcode"},
-		"SE": {"prod": "https://www.sefaz.se.gov.br/nfce/qrcode", "hom": "https://www.sefaz.se.gov.br/nfce/qrcode"},
-		"TO": {"prod": "https://www.sefaz.to.gov.br/nfce/qrcode", "hom": "https://www.sefaz.to.gov.br/nfce/qrcode"},
-	}
-
-	env := "hom"
-	if isProduction {
-		env = "prod"
-	}
-
-	if ufData, exists := ufURLs[uf]; exists {
-		if url, exists := ufData[env]; exists {
-			return url
-		}
+	env := tables.EnvHomologacao
+	if tpAmb == "1" {
+		env = tables.EnvProducao
 	}
 
-	return ""
+	return tables.Active().QRCodeURL(uf, env)
 }
 
 // getContingencyBaseURL returns contingency-specific base URL for QR codes
@@ -255,8 +277,34 @@ func (g *generator) getContingencyBaseURL(uf, tpAmb string) string {
 // Helper function to convert string to proper format if needed
 func formatDecimal(value string) string {
 	// Ensure proper decimal formatting (2 decimal places)
-	if f, err := strconv.ParseFloat(value, 64); err == nil {
-		return fmt.Sprintf("%.2f", f)
+	if amount, err := money.Parse(value); err == nil {
+		return amount.String()
 	}
 	return value
 }
+
+// Image encodes the content of an NFC-e QR Code, as returned by BuildURL, into a PNG
+func Image(content string, size int) ([]byte, error) {
+	if size <= 0 {
+		size = 256 // Default size
+	}
+
+	qrCode, err := qrcode.Encode(content, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code image: %w", err)
+	}
+
+	return qrCode, nil
+}
+
+// ConsultaURL returns the urlChave printed on the DANFE NFC-e, where the consumer looks up
+// the note by its chave de acesso, according to UF and environment, from the SEFAZ tables in use
+func ConsultaURL(uf, tpAmb string) string {
+	// Environment: 1=produção, 2=homologação
+	env := tables.EnvHomologacao
+	if tpAmb == "1" || tpAmb == "producao" {
+		env = tables.EnvProducao
+	}
+
+	return tables.Active().ConsultaURL(uf, env)
+}
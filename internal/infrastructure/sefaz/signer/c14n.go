@@ -0,0 +1,179 @@
+package signer
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/beevik/etree"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/bufpool"
+)
+
+// AlgorithmC14N is Canonical XML 1.0 (without comments), required by the NF-e 4.00 signature profile
+const AlgorithmC14N = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+
+// xmlNamespace is the namespace bound to the reserved xml prefix
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", "\"", "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+// Canonicalize serializes the subtree of element with Canonical XML 1.0 as a document
+// subset: the namespace declarations and xml: attributes in scope from its ancestors are
+// rendered on it, so the digest of infNFe carries the NF-e namespace of the NFe element.
+// The exclude element and its subtree are left out, as the enveloped-signature transform does.
+func Canonicalize(element *etree.Element, exclude *etree.Element) []byte {
+	// Namespaces and xml: attributes inherited from the ancestors, outermost first
+	var ancestors []*etree.Element
+	for parent := element.Parent(); parent != nil; parent = parent.Parent() {
+		ancestors = append(ancestors, parent)
+	}
+	inScope := map[string]string{}
+	inherited := map[string]etree.Attr{}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		for _, attr := range ancestors[i].Attr {
+			if prefix, ok := namespaceDeclaration(attr); ok {
+				inScope[prefix] = attr.Value
+			} else if attr.Space == "xml" {
+				inherited[attr.Key] = attr
+			}
+		}
+	}
+
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	c := canonicalizer{buf: buf, exclude: exclude}
+	c.writeElement(element, inScope, map[string]string{"": ""}, inherited)
+	return bufpool.Bytes(buf)
+}
+
+// canonicalizer writes the canonical form of a subtree
+type canonicalizer struct {
+	buf     *bytes.Buffer
+	exclude *etree.Element
+}
+
+// writeElement writes element with the namespace declarations not yet rendered by its output
+// ancestors, and inherited xml: attributes on the apex element
+func (c *canonicalizer) writeElement(element *etree.Element, parentScope, rendered map[string]string, inherited map[string]etree.Attr) {
+	scope := make(map[string]string, len(parentScope))
+	for prefix, uri := range parentScope {
+		scope[prefix] = uri
+	}
+
+	type attribute struct {
+		namespace string
+		local     string
+		qualified string
+		value     string
+	}
+	var attrs []attribute
+	for _, attr := range element.Attr {
+		if prefix, ok := namespaceDeclaration(attr); ok {
+			scope[prefix] = attr.Value
+		}
+	}
+	for _, attr := range element.Attr {
+		if _, ok := namespaceDeclaration(attr); ok {
+			continue
+		}
+		if attr.Space == "xml" {
+			delete(inherited, attr.Key)
+		}
+		attrs = append(attrs, attribute{
+			namespace: attributeNamespace(attr, scope),
+			local:     attr.Key,
+			qualified: attr.FullKey(),
+			value:     attr.Value,
+		})
+	}
+	for _, attr := range inherited {
+		attrs = append(attrs, attribute{namespace: xmlNamespace, local: attr.Key, qualified: attr.FullKey(), value: attr.Value})
+	}
+
+	// Namespace declarations sorted by prefix (the default one first), then attributes
+	// sorted by namespace URI and local name
+	var prefixes []string
+	childRendered := make(map[string]string, len(rendered))
+	for prefix, uri := range rendered {
+		childRendered[prefix] = uri
+	}
+	for prefix, uri := range scope {
+		if current, ok := rendered[prefix]; ok && current == uri {
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+		childRendered[prefix] = uri
+	}
+	sort.Strings(prefixes)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].namespace != attrs[j].namespace {
+			return attrs[i].namespace < attrs[j].namespace
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	c.buf.WriteByte('<')
+	c.buf.WriteString(element.FullTag())
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			c.buf.WriteString(` xmlns="`)
+		} else {
+			c.buf.WriteString(" xmlns:" + prefix + `="`)
+		}
+		c.buf.WriteString(attrEscaper.Replace(scope[prefix]))
+		c.buf.WriteByte('"')
+	}
+	for _, attr := range attrs {
+		c.buf.WriteString(" " + attr.qualified + `="`)
+		c.buf.WriteString(attrEscaper.Replace(attr.value))
+		c.buf.WriteByte('"')
+	}
+	c.buf.WriteByte('>')
+
+	for _, token := range element.Child {
+		switch t := token.(type) {
+		case *etree.Element:
+			if t != c.exclude {
+				c.writeElement(t, scope, childRendered, map[string]etree.Attr{})
+			}
+		case *etree.CharData:
+			c.buf.WriteString(textEscaper.Replace(t.Data))
+		case *etree.ProcInst:
+			c.buf.WriteString("<?" + t.Target)
+			if t.Inst != "" {
+				c.buf.WriteString(" " + t.Inst)
+			}
+			c.buf.WriteString("?>")
+		}
+		// Comments and directives are not part of the canonical form
+	}
+
+	c.buf.WriteString("</" + element.FullTag() + ">")
+}
+
+// namespaceDeclaration reports whether attr declares a namespace and the prefix it binds
+// ("" for the default namespace)
+func namespaceDeclaration(attr etree.Attr) (string, bool) {
+	switch {
+	case attr.Space == "" && attr.Key == "xmlns":
+		return "", true
+	case attr.Space == "xmlns":
+		return attr.Key, true
+	}
+	return "", false
+}
+
+// attributeNamespace resolves the namespace URI of an attribute; unprefixed attributes have none
+func attributeNamespace(attr etree.Attr, scope map[string]string) string {
+	switch attr.Space {
+	case "":
+		return ""
+	case "xml":
+		return xmlNamespace
+	}
+	return scope[attr.Space]
+}
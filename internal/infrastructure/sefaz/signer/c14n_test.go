@@ -0,0 +1,135 @@
+package signer
+
+import (
+	"testing"
+
+	"github.com/beevik/etree"
+)
+
+// TestCanonicalizeW3CExamples runs the examples of section 3 of the Canonical XML 1.0
+// recommendation (https://www.w3.org/TR/2001/REC-xml-c14n-20010315#Examples). Canonicalize
+// works on the element, so the parts that need the document (the prolog and the comments
+// outside the root of 3.1) or a DTD (the default attribute of 3.3, the entities and the
+// normalized attributes of 3.4, 3.5) are left out of the inputs.
+func TestCanonicalizeW3CExamples(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name: "3.2 whitespace in document content",
+			input: `<doc>
+   <clean>   </clean>
+   <dirty>   A   B   </dirty>
+   <mixed>
+      A
+      <clean>   </clean>
+      B
+      <dirty>   A   B   </dirty>
+      C
+   </mixed>
+</doc>`,
+			want: `<doc>
+   <clean>   </clean>
+   <dirty>   A   B   </dirty>
+   <mixed>
+      A
+      <clean>   </clean>
+      B
+      <dirty>   A   B   </dirty>
+      C
+   </mixed>
+</doc>`,
+		},
+		{
+			name: "3.3 start and end tags",
+			input: `<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`,
+			want: `<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   <e4 id="elem4" name="elem4"></e4>
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6 xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9 xmlns:a="http://www.ietf.org"></e9>
+         </e8>
+      </e7>
+   </e6>
+</doc>`,
+		},
+		{
+			name: "3.4 character modifications and character references",
+			input: `<doc>
+   <text>First line&#x0d;&#10;Second line</text>
+   <value>&#x32;</value>
+   <compute><![CDATA[value>"0" && value<"10" ?"valid":"error"]]></compute>
+   <compute expr='value>"0" &amp;&amp; value&lt;"10" ?"valid":"error"'>valid</compute>
+   <norm attr=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+</doc>`,
+			want: `<doc>
+   <text>First line&#xD;
+Second line</text>
+   <value>2</value>
+   <compute>value&gt;"0" &amp;&amp; value&lt;"10" ?"valid":"error"</compute>
+   <compute expr="value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;">valid</compute>
+   <norm attr=" '    &#xD;&#xA;&#x9;   ' "></norm>
+</doc>`,
+		},
+		{
+			name:  "3.6 UTF-8 encoding",
+			input: `<doc>&#169;</doc>`,
+			want:  "<doc>©</doc>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := etree.NewDocument()
+			if err := doc.ReadFromString(tt.input); err != nil {
+				t.Fatalf("ReadFromString() error = %v", err)
+			}
+			if got := string(Canonicalize(doc.Root(), nil)); got != tt.want {
+				t.Fatalf("Canonicalize() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCanonicalizeSubset checks the document subset rules the signature relies on: the
+// namespaces and the xml: attributes in scope are rendered on the apex element and the
+// enveloped Signature is left out
+func TestCanonicalizeSubset(t *testing.T) {
+	input := `<doc xmlns="http://example.org" xmlns:a="http://www.w3.org" xml:lang="pt-BR">` +
+		`<wrap><inf a:k="1" Id="x"><b>1</b>` +
+		`<Signature xmlns="http://www.w3.org/2000/09/xmldsig#"><SignedInfo/></Signature>` +
+		`</inf></wrap></doc>`
+	want := `<inf xmlns="http://example.org" xmlns:a="http://www.w3.org" Id="x" a:k="1" xml:lang="pt-BR"><b>1</b></inf>`
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(input); err != nil {
+		t.Fatalf("ReadFromString() error = %v", err)
+	}
+	inf := doc.FindElement("//inf")
+	if got := string(Canonicalize(inf, inf.SelectElement("Signature"))); got != want {
+		t.Fatalf("Canonicalize() =\n%s\nwant\n%s", got, want)
+	}
+}
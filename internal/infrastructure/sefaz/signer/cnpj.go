@@ -0,0 +1,85 @@
+package signer
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"strings"
+)
+
+var (
+	// oidSubjectAltName is the subjectAltName extension of X.509 certificates
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	// oidICPBrasilCNPJ is the ICP-Brasil otherName carrying the CNPJ of an e-CNPJ certificate
+	oidICPBrasilCNPJ = asn1.ObjectIdentifier{2, 16, 76, 1, 3, 3}
+)
+
+// icpOtherName is an otherName entry of the subjectAltName
+type icpOtherName struct {
+	TypeID asn1.ObjectIdentifier
+	Value  asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+// CertificateCNPJ returns the CNPJ an ICP-Brasil e-CNPJ certificate was issued to, read from
+// the subjectAltName otherName 2.16.76.1.3.3 or else from the "NAME:CNPJ" common name. It
+// returns an empty string when the certificate carries no CNPJ, e.g. an e-CPF.
+func CertificateCNPJ(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		if cnpj := otherNameCNPJ(ext.Value); cnpj != "" {
+			return cnpj
+		}
+	}
+
+	if i := strings.LastIndex(cert.Subject.CommonName, ":"); i != -1 {
+		if cnpj := digits(cert.Subject.CommonName[i+1:]); len(cnpj) == 14 {
+			return cnpj
+		}
+	}
+	return ""
+}
+
+// otherNameCNPJ looks for the ICP-Brasil CNPJ otherName in the DER GeneralNames
+func otherNameCNPJ(der []byte) string {
+	var names asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &names); err != nil {
+		return ""
+	}
+
+	rest := names.Bytes
+	for len(rest) > 0 {
+		var name asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &name); err != nil {
+			return ""
+		}
+		// otherName is the [0] choice of GeneralName
+		if name.Class != asn1.ClassContextSpecific || name.Tag != 0 {
+			continue
+		}
+
+		var other icpOtherName
+		if _, err := asn1.UnmarshalWithParams(name.FullBytes, &other, "tag:0"); err != nil {
+			continue
+		}
+		if !other.TypeID.Equal(oidICPBrasilCNPJ) {
+			continue
+		}
+		if cnpj := digits(string(other.Value.Bytes)); len(cnpj) == 14 {
+			return cnpj
+		}
+	}
+	return ""
+}
+
+// digits keeps only the digits of s
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
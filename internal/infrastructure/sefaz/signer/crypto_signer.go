@@ -0,0 +1,58 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+)
+
+// CryptoSigner is the signing primitive behind the XML signatures and the mutual TLS
+// handshake. It receives only the digest, so the private key may live in the process
+// (PFX), in an HSM or in a cloud KMS.
+type CryptoSigner interface {
+	crypto.Signer
+	// Certificate returns the X.509 certificate of the key, sent in KeyInfo and on TLS
+	Certificate() *x509.Certificate
+	// Chain returns the intermediate CA certificates sent after Certificate on TLS, if known
+	Chain() []*x509.Certificate
+}
+
+// OpenCryptoSigner returns the signer selected by the key material: the remote KMS key
+// when configured, otherwise the PFX bundle
+func OpenCryptoSigner(key KeyMaterial) (CryptoSigner, error) {
+	if key.KMS != nil {
+		return newKMSSigner(*key.KMS)
+	}
+
+	bundle, err := LoadPFX(key)
+	if err != nil {
+		return nil, err
+	}
+	return &pfxSigner{PrivateKey: bundle.PrivateKey, cert: bundle.Certificate, chain: bundle.Chain}, nil
+}
+
+// pfxSigner signs in-process with the RSA key decoded from the PFX
+type pfxSigner struct {
+	*rsa.PrivateKey
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+}
+
+// Certificate returns the certificate of the PFX bundle
+func (s *pfxSigner) Certificate() *x509.Certificate {
+	return s.cert
+}
+
+// Chain returns the CA certificates exported with the PFX
+func (s *pfxSigner) Chain() []*x509.Certificate {
+	return s.chain
+}
+
+// certificateChain returns the DER certificates sent on the TLS handshake, leaf first
+func certificateChain(leaf *x509.Certificate, chain []*x509.Certificate) [][]byte {
+	certificates := [][]byte{leaf.Raw}
+	for _, cert := range chain {
+		certificates = append(certificates, cert.Raw)
+	}
+	return certificates
+}
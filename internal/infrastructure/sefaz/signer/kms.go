@@ -0,0 +1,217 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Signing algorithms requested from the KMS; XMLDSig uses PKCS#1 v1.5 over SHA-1 (NF-e 4.00
+// profile) and the TLS handshake PKCS#1 v1.5 or PSS over SHA-256
+const (
+	KMSAlgorithmPKCS1v15SHA1 = "RSASSA_PKCS1_V1_5_SHA_1"
+	KMSAlgorithmPKCS1v15     = "RSASSA_PKCS1_V1_5_SHA_256"
+	KMSAlgorithmPSS          = "RSASSA_PSS_SHA_256"
+)
+
+// kmsTimeout bounds a remote signature; the handshake and the emission wait on it
+const kmsTimeout = 10 * time.Second
+
+// kmsHTTPClient is shared by the KMS signers of every company
+var kmsHTTPClient = &http.Client{Timeout: kmsTimeout}
+
+// KMSKey identifies a private key kept in an HSM (through a PKCS#11 gateway) or a cloud
+// KMS. Only the digest (SHA-1 or SHA-256) is sent to Endpoint, which answers with the signature:
+//
+//	POST Endpoint  {"key_id": "...", "algorithm": "RSASSA_PKCS1_V1_5_SHA_256", "digest": "<base64>"}
+//	200            {"signature": "<base64>"}
+type KMSKey struct {
+	Endpoint       string
+	KeyID          string
+	Token          string // Sent as a Bearer token, when set
+	CertificatePEM []byte // Public certificate of the key, issued by ICP-Brasil
+}
+
+type kmsSignRequest struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	Digest    string `json:"digest"`
+}
+
+type kmsSignResponse struct {
+	Signature string `json:"signature"`
+	Error     string `json:"error,omitempty"`
+}
+
+// kmsSigner delegates the signature of digests to the remote key
+type kmsSigner struct {
+	key   KMSKey
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+}
+
+func newKMSSigner(key KMSKey) (*kmsSigner, error) {
+	if key.Endpoint == "" || key.KeyID == "" {
+		return nil, errors.New("KMS endpoint and key ID are required")
+	}
+	cert, err := ParseCertificatePEM(key.CertificatePEM)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cert.PublicKey.(*rsa.PublicKey); !ok {
+		return nil, errors.New("KMS certificate key is not RSA")
+	}
+	return &kmsSigner{key: key, cert: cert, chain: parseChainPEM(key.CertificatePEM)}, nil
+}
+
+// ParseCertificatePEM decodes the first certificate of a PEM bundle
+func ParseCertificatePEM(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("certificate PEM not found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// parseChainPEM decodes the certificates after the first one of a PEM bundle, skipping the
+// blocks that are not certificates
+func parseChainPEM(data []byte) []*x509.Certificate {
+	_, rest := pem.Decode(data)
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return chain
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			chain = append(chain, cert)
+		}
+	}
+}
+
+// Certificate returns the public certificate of the remote key
+func (s *kmsSigner) Certificate() *x509.Certificate {
+	return s.cert
+}
+
+// Chain returns the CA certificates following the key certificate in the PEM bundle
+func (s *kmsSigner) Chain() []*x509.Certificate {
+	return s.chain
+}
+
+// Public returns the public key of the certificate
+func (s *kmsSigner) Public() crypto.PublicKey {
+	return s.cert.PublicKey
+}
+
+// Sign sends the digest to the KMS; SHA-256 digests and SHA-1 PKCS#1 v1.5 ones are supported
+func (s *kmsSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	_, pss := opts.(*rsa.PSSOptions)
+	var algorithm string
+	switch {
+	case opts.HashFunc() == crypto.SHA256 && pss:
+		algorithm = KMSAlgorithmPSS
+	case opts.HashFunc() == crypto.SHA256:
+		algorithm = KMSAlgorithmPKCS1v15
+	case opts.HashFunc() == crypto.SHA1 && !pss:
+		algorithm = KMSAlgorithmPKCS1v15SHA1
+	default:
+		return nil, fmt.Errorf("KMS signer does not support %v", opts.HashFunc())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kmsTimeout)
+	defer cancel()
+	return s.sign(ctx, algorithm, digest)
+}
+
+func (s *kmsSigner) sign(ctx context.Context, algorithm string, digest []byte) ([]byte, error) {
+	body, err := json.Marshal(kmsSignRequest{
+		KeyID:     s.key.KeyID,
+		Algorithm: algorithm,
+		Digest:    base64.StdEncoding.EncodeToString(digest),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.key.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMS endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.key.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.key.Token)
+	}
+
+	resp, err := kmsHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("KMS request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result kmsSignResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("invalid KMS response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("KMS returned status %d: %s", resp.StatusCode, result.Error)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(result.Signature)
+	if err != nil || len(signature) == 0 {
+		return nil, errors.New("KMS returned an invalid signature")
+	}
+	return signature, nil
+}
+
+// ProbeKMSKey signs test digests with the remote key, as the XML signatures (SHA-1) and the
+// TLS handshake (SHA-256) do, and verifies them against the certificate, so a misconfigured
+// key is refused before the first emission
+func ProbeKMSKey(ctx context.Context, key KMSKey) (*x509.Certificate, error) {
+	signer, err := newKMSSigner(key)
+	if err != nil {
+		return nil, err
+	}
+
+	message := []byte("plugnfce kms probe " + key.KeyID)
+	sha1Digest := sha1.Sum(message)
+	sha256Digest := sha256.Sum256(message)
+	probes := []struct {
+		algorithm string
+		hash      crypto.Hash
+		digest    []byte
+	}{
+		{KMSAlgorithmPKCS1v15SHA1, crypto.SHA1, sha1Digest[:]},
+		{KMSAlgorithmPKCS1v15, crypto.SHA256, sha256Digest[:]},
+	}
+	for _, probe := range probes {
+		signature, err := signer.sign(ctx, probe.algorithm, probe.digest)
+		if err != nil {
+			return nil, err
+		}
+		if err := rsa.VerifyPKCS1v15(signer.cert.PublicKey.(*rsa.PublicKey), probe.hash, probe.digest, signature); err != nil {
+			return nil, fmt.Errorf("KMS %s signature does not match the certificate", probe.algorithm)
+		}
+	}
+	return signer.cert, nil
+}
@@ -0,0 +1,50 @@
+package signer
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	// ErrPFXPassword is returned when the PFX password does not open the bundle
+	ErrPFXPassword = errors.New("certificate password is incorrect")
+	// ErrPFXUnsupported is returned when the PFX uses an encryption or MAC algorithm that is not supported
+	ErrPFXUnsupported = errors.New("certificate file encoding is not supported")
+	// ErrPFXInvalid is returned when the file is not a PFX bundle with an RSA key and its certificate
+	ErrPFXInvalid = errors.New("certificate file is not a valid PFX")
+)
+
+// PFXBundle is the content of an A1 certificate PFX
+type PFXBundle struct {
+	Certificate *x509.Certificate
+	PrivateKey  *rsa.PrivateKey
+	Chain       []*x509.Certificate // Intermediate CA certificates exported with the PFX
+}
+
+// DecodePFX opens a PKCS#12 bundle, either legacy (3DES/RC2 with SHA-1 MAC) or modern
+// (PBES2 with AES and SHA-256 MAC) as exported by newer ACs. The errors tell a wrong
+// password (ErrPFXPassword) apart from an unsupported encoding (ErrPFXUnsupported).
+func DecodePFX(pfxData []byte, password string) (*PFXBundle, error) {
+	privateKey, cert, caCerts, err := pkcs12.DecodeChain(pfxData, password)
+	if err != nil {
+		var notImplemented pkcs12.NotImplementedError
+		switch {
+		case errors.Is(err, pkcs12.ErrIncorrectPassword):
+			return nil, ErrPFXPassword
+		case errors.As(err, &notImplemented):
+			return nil, fmt.Errorf("%w: %s", ErrPFXUnsupported, string(notImplemented))
+		}
+		return nil, fmt.Errorf("%w: %v", ErrPFXInvalid, err)
+	}
+
+	rsaKey, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: private key is not RSA", ErrPFXInvalid)
+	}
+
+	return &PFXBundle{Certificate: cert, PrivateKey: rsaKey, Chain: caCerts}, nil
+}
@@ -4,22 +4,22 @@ import (
 	"context"
 	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
+	"crypto/sha1"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
-	"regexp"
-	"strings"
 
 	"github.com/beevik/etree"
-	"golang.org/x/crypto/pkcs12"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/bufpool"
 )
 
-// KeyMaterial represents the PFX bundle and its password.
+// KeyMaterial represents the PFX bundle and its password, or the remote KMS key
+// of companies that do not hand over their PFX.
 type KeyMaterial struct {
 	PFXBase64 string
 	Password  string
+	KMS       *KMSKey
 }
 
 // Signer encapsulates XMLDSig enveloped signature logic.
@@ -35,7 +35,9 @@ func NewSigner() Signer {
 	return &signer{}
 }
 
-// SignEnveloped signs XML with enveloped signature
+// SignEnveloped signs the element with Id referenceID with an enveloped signature following
+// the NF-e 4.00 profile: Canonical XML 1.0, SHA-1 digest and RSA-SHA1. The Signature is
+// appended to the parent of the signed element (NFe, evento, inutNFe).
 func (s *signer) SignEnveloped(ctx context.Context, unsignedXML []byte, key KeyMaterial, referenceID string) ([]byte, error) {
 	// Parse XML
 	doc := etree.NewDocument()
@@ -49,29 +51,30 @@ func (s *signer) SignEnveloped(ctx context.Context, unsignedXML []byte, key KeyM
 	if elementToSign == nil {
 		return nil, fmt.Errorf("element with ID %s not found", referenceID)
 	}
+	parent := elementToSign.Parent()
+	if parent == nil {
+		parent = doc.Root()
+	}
 
-	// Load certificate and private key
-	cert, privateKey, err := s.loadCertificateAndKey(key)
+	// Open the signing key (PFX or KMS)
+	cryptoSigner, err := OpenCryptoSigner(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load certificate: %w", err)
 	}
 
-	// Create signature
-	signature, err := s.createSignature(elementToSign, cert, privateKey)
-	if err != nil {
+	// Create the signature in place, so SignedInfo is canonicalized with its namespace
+	if err := s.createSignature(parent, elementToSign, cryptoSigner); err != nil {
 		return nil, fmt.Errorf("failed to create signature: %w", err)
 	}
 
-	// Add signature to document
-	doc.Root().AddChild(signature)
-
-	// Return signed XML
-	signedXML, err := doc.WriteToBytes()
-	if err != nil {
+	// Return signed XML, serialized through a pooled buffer
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	if _, err := doc.WriteTo(buf); err != nil {
 		return nil, fmt.Errorf("failed to serialize signed XML: %w", err)
 	}
 
-	return signedXML, nil
+	return bufpool.Bytes(buf), nil
 }
 
 // findElementByID finds an element by its ID attribute
@@ -89,88 +92,52 @@ func (s *signer) findElementByID(element *etree.Element, id string) *etree.Eleme
 	return nil
 }
 
-// loadCertificateAndKey loads certificate and private key from PFX
-func (s *signer) loadCertificateAndKey(key KeyMaterial) (*x509.Certificate, *rsa.PrivateKey, error) {
-	// Decode base64 PFX
-	pfxData, err := base64.StdEncoding.DecodeString(key.PFXBase64)
+// TLSCertificate builds the client certificate used for mutual TLS with SEFAZ; with a
+// KMS key the handshake signature is also computed remotely
+func TLSCertificate(key KeyMaterial) (tls.Certificate, error) {
+	cryptoSigner, err := OpenCryptoSigner(key)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to decode PFX base64: %w", err)
+		return tls.Certificate{}, err
 	}
 
-	// Parse PFX/P12
-	privateKey, cert, err := pkcs12.Decode(pfxData, key.Password)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse PFX: %w", err)
-	}
-
-	rsaKey, ok := privateKey.(*rsa.PrivateKey)
-	if !ok {
-		return nil, nil, fmt.Errorf("private key is not RSA")
-	}
-
-	return cert, rsaKey, nil
+	cert := cryptoSigner.Certificate()
+	return tls.Certificate{
+		Certificate: certificateChain(cert, cryptoSigner.Chain()),
+		PrivateKey:  cryptoSigner,
+		Leaf:        cert,
+	}, nil
 }
 
-// createSignature creates the XMLDSig signature element
-func (s *signer) createSignature(elementToSign *etree.Element, cert *x509.Certificate, privateKey *rsa.PrivateKey) (*etree.Element, error) {
-	// Canonicalize the element
-	canonicalized, err := s.canonicalize(elementToSign)
+// LoadPFX decodes the A1 certificate, its RSA private key and the CA chain from the base64
+// PFX bundle of the key material
+func LoadPFX(key KeyMaterial) (*PFXBundle, error) {
+	pfxData, err := base64.StdEncoding.DecodeString(key.PFXBase64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to canonicalize: %w", err)
+		return nil, fmt.Errorf("failed to decode PFX base64: %w", err)
 	}
+	return DecodePFX(pfxData, key.Password)
+}
 
-	// Calculate digest (SHA-256)
-	digest := sha256.Sum256(canonicalized)
-	digestBase64 := base64.StdEncoding.EncodeToString(digest[:])
-
-	// Create SignedInfo
-	signedInfo := s.createSignedInfo(elementToSign, digestBase64)
+// createSignature appends the XMLDSig Signature of elementToSign to parent
+func (s *signer) createSignature(parent, elementToSign *etree.Element, cryptoSigner CryptoSigner) error {
+	signature := parent.CreateElement("Signature")
+	signature.CreateAttr("xmlns", "http://www.w3.org/2000/09/xmldsig#")
 
-	// Canonicalize SignedInfo
-	signedInfoCanonicalized, err := s.canonicalizeElement(signedInfo)
-	if err != nil {
-		return nil, fmt.Errorf("failed to canonicalize SignedInfo: %w", err)
-	}
+	// Digest of the referenced element, without the enveloped Signature
+	digest := sha1.Sum(Canonicalize(elementToSign, signature))
+	signedInfo := s.createSignedInfo(elementToSign, base64.StdEncoding.EncodeToString(digest[:]))
+	signature.AddChild(signedInfo)
 
-	// Sign SignedInfo
-	signatureValue, err := s.signData(signedInfoCanonicalized, privateKey)
+	// Sign the canonical SignedInfo, which inherits the xmldsig namespace of Signature
+	signatureValue, err := s.signData(Canonicalize(signedInfo, nil), cryptoSigner)
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign data: %w", err)
+		parent.RemoveChild(signature)
+		return fmt.Errorf("failed to sign data: %w", err)
 	}
 
-	// Create signature element
-	signature := etree.NewElement("Signature")
-	signature.CreateAttr("xmlns", "http://www.w3.org/2000/09/xmldsig#")
-
-	signature.AddChild(signedInfo)
 	signature.AddChild(s.createSignatureValue(signatureValue))
-	signature.AddChild(s.createKeyInfo(cert))
-
-	return signature, nil
-}
-
-// canonicalize performs C14N canonicalization
-func (s *signer) canonicalize(element *etree.Element) ([]byte, error) {
-	// For simplicity, we'll use a basic canonicalization
-	// In production, you should use a proper C14N implementation
-	var buf strings.Builder
-	element.WriteTo(&buf, &etree.WriteSettings{
-		CanonicalText:    true,
-		CanonicalAttrVal: true,
-	})
-
-	xmlStr := buf.String()
-	// Remove extra whitespace between tags
-	re := regexp.MustCompile(`>\s+<`)
-	xmlStr = re.ReplaceAllString(xmlStr, "><")
-	// Trim spaces
-	xmlStr = strings.TrimSpace(xmlStr)
-	return []byte(xmlStr), nil
-}
-
-// canonicalizeElement converts element to canonicalized bytes
-func (s *signer) canonicalizeElement(element *etree.Element) ([]byte, error) {
-	return s.canonicalize(element)
+	signature.AddChild(s.createKeyInfo(cryptoSigner.Certificate()))
+	return nil
 }
 
 // createSignedInfo creates the SignedInfo element
@@ -179,12 +146,12 @@ func (s *signer) createSignedInfo(elementToSign *etree.Element, digestBase64 str
 
 	// CanonicalizationMethod
 	canonicalizationMethod := etree.NewElement("CanonicalizationMethod")
-	canonicalizationMethod.CreateAttr("Algorithm", "http://www.w3.org/TR/2001/REC-xml-c14n-20010315")
+	canonicalizationMethod.CreateAttr("Algorithm", AlgorithmC14N)
 	signedInfo.AddChild(canonicalizationMethod)
 
 	// SignatureMethod
 	signatureMethod := etree.NewElement("SignatureMethod")
-	signatureMethod.CreateAttr("Algorithm", "http://www.w3.org/2000/09/xmldsig#rsa-sha256")
+	signatureMethod.CreateAttr("Algorithm", "http://www.w3.org/2000/09/xmldsig#rsa-sha1")
 	signedInfo.AddChild(signatureMethod)
 
 	// Reference
@@ -198,14 +165,14 @@ func (s *signer) createSignedInfo(elementToSign *etree.Element, digestBase64 str
 	transforms.AddChild(envelopedTransform)
 
 	canonicalTransform := etree.NewElement("Transform")
-	canonicalTransform.CreateAttr("Algorithm", "http://www.w3.org/TR/2001/REC-xml-c14n-20010315")
+	canonicalTransform.CreateAttr("Algorithm", AlgorithmC14N)
 	transforms.AddChild(canonicalTransform)
 
 	reference.AddChild(transforms)
 
 	// DigestMethod
 	digestMethod := etree.NewElement("DigestMethod")
-	digestMethod.CreateAttr("Algorithm", "http://www.w3.org/2001/04/xmlenc#sha256")
+	digestMethod.CreateAttr("Algorithm", "http://www.w3.org/2000/09/xmldsig#sha1")
 	reference.AddChild(digestMethod)
 
 	// DigestValue
@@ -218,10 +185,10 @@ func (s *signer) createSignedInfo(elementToSign *etree.Element, digestBase64 str
 	return signedInfo
 }
 
-// signData signs the data with RSA-SHA256
-func (s *signer) signData(data []byte, privateKey *rsa.PrivateKey) (string, error) {
-	hashed := sha256.Sum256(data)
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
+// signData signs the data with RSA-SHA1; only the digest reaches the CryptoSigner
+func (s *signer) signData(data []byte, cryptoSigner CryptoSigner) (string, error) {
+	hashed := sha1.Sum(data)
+	signature, err := cryptoSigner.Sign(rand.Reader, hashed[:], crypto.SHA1)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,116 @@
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/beevik/etree"
+)
+
+// nfceID is the Id of the infNFe of testdata/nfce.xml
+const nfceID = "NFe35241211222333000181650010000001011482135763"
+
+// testKeyMaterial is the self-signed test certificate of testdata/a1-teste.pfx
+func testKeyMaterial(t *testing.T) KeyMaterial {
+	t.Helper()
+	pfx, err := os.ReadFile(filepath.Join("testdata", "a1-teste.pfx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return KeyMaterial{PFXBase64: base64.StdEncoding.EncodeToString(pfx), Password: "teste123"}
+}
+
+// TestSignEnvelopedNFCe signs testdata/nfce.xml and compares the result with
+// testdata/nfce-signed.xml, whose DigestValue and SignatureValue were computed with
+// xmllint --c14n and openssl (see testdata/README.md)
+func TestSignEnvelopedNFCe(t *testing.T) {
+	unsigned, err := os.ReadFile(filepath.Join("testdata", "nfce.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "nfce-signed.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewSigner().SignEnveloped(context.Background(), unsigned, testKeyMaterial(t), nfceID)
+	if err != nil {
+		t.Fatalf("SignEnveloped() error = %v", err)
+	}
+
+	gotDoc, wantDoc := etree.NewDocument(), etree.NewDocument()
+	if err := gotDoc.ReadFromBytes(got); err != nil {
+		t.Fatalf("signed XML does not parse: %v", err)
+	}
+	if err := wantDoc.ReadFromBytes(want); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		"//Signature/SignedInfo/Reference/DigestValue",
+		"//Signature/SignatureValue",
+		"//Signature/KeyInfo/X509Data/X509Certificate",
+	} {
+		if got, want := gotDoc.FindElement(path).Text(), wantDoc.FindElement(path).Text(); got != want {
+			t.Errorf("%s = %s, want %s", path, got, want)
+		}
+	}
+	if string(got) != string(want) {
+		t.Errorf("SignEnveloped() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestSignEnvelopedVerifies checks the signature with the public key of the certificate, as
+// SEFAZ does: the digest over the canonical infNFe and RSA-SHA1 over the canonical SignedInfo
+func TestSignEnvelopedVerifies(t *testing.T) {
+	unsigned, err := os.ReadFile(filepath.Join("testdata", "nfce.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := testKeyMaterial(t)
+	signed, err := NewSigner().SignEnveloped(context.Background(), unsigned, key, nfceID)
+	if err != nil {
+		t.Fatalf("SignEnveloped() error = %v", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(signed); err != nil {
+		t.Fatal(err)
+	}
+	signature := doc.FindElement("//Signature")
+	signedInfo := signature.SelectElement("SignedInfo")
+
+	digest := sha1.Sum(Canonicalize(doc.FindElement("//infNFe"), signature))
+	if got, want := signedInfo.FindElement("Reference/DigestValue").Text(), base64.StdEncoding.EncodeToString(digest[:]); got != want {
+		t.Fatalf("DigestValue = %s, want %s", got, want)
+	}
+
+	bundle, err := LoadPFX(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureValue, err := base64.StdEncoding.DecodeString(signature.SelectElement("SignatureValue").Text())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := sha1.Sum(Canonicalize(signedInfo, nil))
+	publicKey := bundle.Certificate.PublicKey.(*rsa.PublicKey)
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA1, hashed[:], signatureValue); err != nil {
+		t.Fatalf("SignatureValue does not verify: %v", err)
+	}
+}
+
+func TestSignEnvelopedUnknownReference(t *testing.T) {
+	unsigned, err := os.ReadFile(filepath.Join("testdata", "nfce.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner().SignEnveloped(context.Background(), unsigned, testKeyMaterial(t), "NFe0"); err == nil {
+		t.Fatal("SignEnveloped() error = nil, want the missing element error")
+	}
+}
@@ -0,0 +1,201 @@
+package soapclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// ErrCassetteNotFound is returned by the replay transport for an exchange never recorded
+var ErrCassetteNotFound = errors.New("no recorded SEFAZ exchange")
+
+// cassette is a SEFAZ exchange recorded as a fixture, one JSON file per call
+type cassette struct {
+	Service    string    `json:"service"`  // NFeAutorizacao4, NFeStatusServico4, ...
+	Key        string    `json:"key"`      // Identity of the call the replay matches on
+	Endpoint   string    `json:"endpoint"` // Informative, the replay matches any endpoint
+	Request    string    `json:"request"`
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"` // Transport failure, replayed as an error
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+var (
+	servicePattern = regexp.MustCompile(`portalfiscal\.inf\.br/nfe/wsdl/([A-Za-z0-9]+)`)
+	chavePattern   = regexp.MustCompile(`Id="NFe(\d{44})"`)
+	inutIDPattern  = regexp.MustCompile(`Id="(ID\d+)"`)
+)
+
+// exchangeKey identifies a call by its service and the document it is about, leaving out the
+// timestamps, signatures and random codes that change on every run: the NFC-e of an
+// authorization, the NFC-e and event type of an event or query, the receipt, the inutilização
+// range, the NSU or the UF of a status query
+func exchangeKey(envelope []byte) (string, string) {
+	service := ""
+	if match := servicePattern.FindSubmatch(envelope); match != nil {
+		service = string(match[1])
+	}
+
+	var key string
+	if matches := chavePattern.FindAllSubmatch(envelope, -1); len(matches) > 0 {
+		chaves := make([]string, len(matches))
+		for i, match := range matches {
+			chaves[i] = documentKey(string(match[1]))
+		}
+		key = strings.Join(chaves, ",")
+	} else if chNFe := extractTag(envelope, "chNFe"); chNFe != "" {
+		key = documentKey(chNFe)
+		if tpEvento := extractTag(envelope, "tpEvento"); tpEvento != "" {
+			key += "/" + tpEvento
+		}
+	} else if nRec := extractTag(envelope, "nRec"); nRec != "" {
+		key = nRec
+	} else if match := inutIDPattern.FindSubmatch(envelope); match != nil {
+		key = string(match[1])
+	} else if cnpj := extractTag(envelope, "CNPJ"); cnpj != "" {
+		key = cnpj + "/" + extractTag(envelope, "ultNSU") + extractTag(envelope, "NSU")
+	} else {
+		key = extractTag(envelope, "cUF") + "/" + extractTag(envelope, "tpAmb")
+	}
+	return service, key
+}
+
+// documentKey reduces a chave de acesso to the NFC-e it numbers: cUF, CNPJ, model, series,
+// number and tpEmis, without the month, the random cNF and the check digit
+func documentKey(chave string) string {
+	if len(chave) != 44 {
+		return chave
+	}
+	return chave[:2] + chave[6:35]
+}
+
+// recordingTransport saves every exchange of the transport it wraps as a cassette
+type recordingTransport struct {
+	transport Transport
+	dir       string
+	sequence  atomic.Int64
+	logger    logger.Logger
+}
+
+// WithRecording wraps transport saving its exchanges with SEFAZ as cassettes in dir, to be
+// answered later by NewReplayTransport. The envelopes carry the NFC-e as sent, with the CNPJ
+// and the consumer data, so review the cassettes before sharing them.
+func WithRecording(transport Transport, dir string, l logger.Logger) (Transport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the SEFAZ cassette directory: %w", err)
+	}
+	return &recordingTransport{transport: transport, dir: dir, logger: l}, nil
+}
+
+func (t *recordingTransport) Send(ctx context.Context, endpoint string, envelope []byte, cert *ClientCertificate) ([]byte, error) {
+	response, err := t.transport.Send(ctx, endpoint, envelope, cert)
+
+	service, key := exchangeKey(envelope)
+	recorded := cassette{
+		Service:    service,
+		Key:        key,
+		Endpoint:   endpoint,
+		Request:    string(envelope),
+		Response:   string(response),
+		RecordedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		recorded.Error = err.Error()
+	}
+	if saveErr := t.save(recorded); saveErr != nil {
+		// The exchange already happened; losing its fixture must not fail the emission
+		t.logger.Warn("Failed to record SEFAZ exchange",
+			logger.Field{Key: "service", Value: service}, logger.Field{Key: "key", Value: key}, logger.Err(saveErr))
+	}
+
+	return response, err
+}
+
+// save writes the cassette named by its recording order, service and key, so the replay
+// loads the exchanges of a key in the order they happened
+func (t *recordingTransport) save(recorded cassette) error {
+	content, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(recorded.Key))
+	name := fmt.Sprintf("%s-%06d-%s-%s.json", recorded.RecordedAt.Format("20060102T150405.000000000"),
+		t.sequence.Add(1), recorded.Service, hex.EncodeToString(hash[:6]))
+	return os.WriteFile(filepath.Join(t.dir, name), content, 0o644)
+}
+
+// replayTransport answers from recorded cassettes instead of SEFAZ
+type replayTransport struct {
+	mu        sync.Mutex
+	cassettes map[string][]cassette // service|key -> exchanges in recording order
+	played    map[string]int
+}
+
+// NewReplayTransport answers the calls with the cassettes recorded in dir, matched by service
+// and key. The exchanges of a key are replayed in the order they were recorded, e.g. a
+// rejection then the authorization of the retry, and the last one answers any further call.
+func NewReplayTransport(dir string) (Transport, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no SEFAZ cassettes in %s", dir)
+	}
+	sort.Strings(paths)
+
+	t := &replayTransport{cassettes: make(map[string][]cassette), played: make(map[string]int)}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SEFAZ cassette %s: %w", path, err)
+		}
+		var recorded cassette
+		if err := json.Unmarshal(content, &recorded); err != nil {
+			return nil, fmt.Errorf("invalid SEFAZ cassette %s: %w", path, err)
+		}
+		id := recorded.Service + "|" + recorded.Key
+		t.cassettes[id] = append(t.cassettes[id], recorded)
+	}
+	return t, nil
+}
+
+func (t *replayTransport) Send(ctx context.Context, endpoint string, envelope []byte, cert *ClientCertificate) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	service, key := exchangeKey(envelope)
+	id := service + "|" + key
+
+	t.mu.Lock()
+	recorded, found := t.cassettes[id]
+	var next cassette
+	if found {
+		played := t.played[id]
+		next = recorded[min(played, len(recorded)-1)]
+		t.played[id] = played + 1
+	}
+	t.mu.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s %s", ErrCassetteNotFound, service, key)
+	}
+	if next.Error != "" {
+		return nil, errors.New(next.Error)
+	}
+	return []byte(next.Response), nil
+}
@@ -0,0 +1,172 @@
+package soapclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// NFC-e of the sanitized cassettes in testdata/cassettes
+const (
+	cassetteChave     = "35241211222333000181650010000001011482135763"
+	cassetteLoteChave = "35241211222333000181650010000001021739201841"
+	cassetteRecibo    = "351000012345678"
+)
+
+// cassetteNFe is an NFC-e numbered by chave, as far as the cassette key goes
+func cassetteNFe(chave string) []byte {
+	return []byte(`<NFe xmlns="http://www.portalfiscal.inf.br/nfe"><infNFe Id="NFe` + chave + `" versao="4.00"></infNFe></NFe>`)
+}
+
+func newReplayClient(t *testing.T) Client {
+	t.Helper()
+	transport, err := NewReplayTransport("testdata/cassettes")
+	if err != nil {
+		t.Fatalf("NewReplayTransport() error = %v", err)
+	}
+	return NewSOAPClientWithTransport(transport, nil)
+}
+
+func TestReplayAuthorize(t *testing.T) {
+	client := newReplayClient(t)
+
+	// The cassette matches the NFC-e, not the random cNF and check digit of a new emission
+	chave := cassetteChave[:35] + "99999999" + "0"
+	response, err := client.Authorize(context.Background(), AuthorizationRequest{
+		UF: "SP", Ambiente: "2", XML: cassetteNFe(chave),
+	})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if response.CStat != "104" || response.Status != "authorized" || response.Protocolo != "135240000012345" {
+		t.Fatalf("Authorize() = cStat %s, status %s, protocolo %s; want 104, authorized, 135240000012345",
+			response.CStat, response.Status, response.Protocolo)
+	}
+	if len(response.RawRequest) == 0 || len(response.RawResponse) == 0 {
+		t.Fatal("Authorize() did not keep the raw exchange")
+	}
+}
+
+func TestReplayLoteAndReceipt(t *testing.T) {
+	client := newReplayClient(t)
+	ctx := context.Background()
+
+	lote, err := client.AuthorizeLote(ctx, LoteAuthorizationRequest{
+		UF: "SP", Ambiente: "2", XMLs: [][]byte{cassetteNFe(cassetteChave), cassetteNFe(cassetteLoteChave)},
+	})
+	if err != nil {
+		t.Fatalf("AuthorizeLote() error = %v", err)
+	}
+	if lote.CStat != "103" || lote.Status != "received" || lote.Recibo != cassetteRecibo {
+		t.Fatalf("AuthorizeLote() = cStat %s, status %s, recibo %s; want 103, received, %s",
+			lote.CStat, lote.Status, lote.Recibo, cassetteRecibo)
+	}
+
+	// The receipt was recorded twice: still processing, then processed
+	tests := []struct {
+		chave     string
+		cStat     string
+		status    string
+		protocolo string
+	}{
+		{cassetteChave, "105", "processing", ""},
+		{cassetteChave, "100", "authorized", "135240000012346"},
+		{cassetteLoteChave, "778", "denied", ""},
+	}
+	for _, tt := range tests {
+		response, err := client.QueryReceipt(ctx, ReceiptQueryRequest{
+			UF: "SP", Ambiente: "2", Recibo: lote.Recibo, ChaveAcesso: tt.chave,
+		})
+		if err != nil {
+			t.Fatalf("QueryReceipt(%s) error = %v", tt.chave, err)
+		}
+		if response.CStat != tt.cStat || response.Status != tt.status || response.Protocolo != tt.protocolo {
+			t.Fatalf("QueryReceipt(%s) = cStat %s, status %s, protocolo %q; want %s, %s, %q",
+				tt.chave, response.CStat, response.Status, response.Protocolo, tt.cStat, tt.status, tt.protocolo)
+		}
+	}
+}
+
+func TestReplayQueryProtocol(t *testing.T) {
+	client := newReplayClient(t)
+
+	response, err := client.QueryProtocol(context.Background(), ProtocolQueryRequest{
+		UF: "SP", Ambiente: "2", ChaveAcesso: cassetteChave,
+	})
+	if err != nil {
+		t.Fatalf("QueryProtocol() error = %v", err)
+	}
+	if response.CStat != "100" || response.Status != "authorized" || response.Protocolo != "135240000012345" {
+		t.Fatalf("QueryProtocol() = cStat %s, status %s, protocolo %s; want 100, authorized, 135240000012345",
+			response.CStat, response.Status, response.Protocolo)
+	}
+}
+
+func TestReplaySendEvent(t *testing.T) {
+	client := newReplayClient(t)
+
+	evento := `<evento versao="1.00" xmlns="http://www.portalfiscal.inf.br/nfe"><infEvento Id="ID110111` + cassetteChave +
+		`01"><chNFe>` + cassetteChave + `</chNFe><tpEvento>110111</tpEvento></infEvento></evento>`
+	response, err := client.SendEvent(context.Background(), EventRequest{
+		UF: "SP", Ambiente: "2", ChaveAcesso: cassetteChave, XML: []byte(evento),
+	})
+	if err != nil {
+		t.Fatalf("SendEvent() error = %v", err)
+	}
+	if response.CStat != "135" || response.Status != "registered" || response.Protocolo != "135240000012400" {
+		t.Fatalf("SendEvent() = cStat %s, status %s, protocolo %s; want 135, registered, 135240000012400",
+			response.CStat, response.Status, response.Protocolo)
+	}
+	if extractTag(response.RetEvento, "tpEvento") != "110111" {
+		t.Fatalf("SendEvent() retEvento = %s", response.RetEvento)
+	}
+}
+
+func TestReplayWithoutCassette(t *testing.T) {
+	client := newReplayClient(t)
+
+	// Another event type of the same NFC-e was never recorded
+	evento := `<evento><infEvento><chNFe>` + cassetteChave + `</chNFe><tpEvento>110110</tpEvento></infEvento></evento>`
+	_, err := client.SendEvent(context.Background(), EventRequest{
+		UF: "SP", Ambiente: "2", ChaveAcesso: cassetteChave, XML: []byte(evento),
+	})
+	if !errors.Is(err, ErrCassetteNotFound) {
+		t.Fatalf("SendEvent() error = %v, want %v", err, ErrCassetteNotFound)
+	}
+}
+
+// stubTransport answers every call with the same response
+type stubTransport struct {
+	response string
+}
+
+func (t stubTransport) Send(context.Context, string, []byte, *ClientCertificate) ([]byte, error) {
+	return []byte(t.response), nil
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	response := `<retConsStatServ><cStat>107</cStat><xMotivo>Servico em Operacao</xMotivo></retConsStatServ>`
+
+	recording, err := WithRecording(stubTransport{response: response}, dir, logger.NewNopLogger())
+	if err != nil {
+		t.Fatalf("WithRecording() error = %v", err)
+	}
+	if _, err := NewSOAPClientWithTransport(recording, nil).QueryStatus(context.Background(), "SP", "2"); err != nil {
+		t.Fatalf("QueryStatus() recording error = %v", err)
+	}
+
+	replay, err := NewReplayTransport(dir)
+	if err != nil {
+		t.Fatalf("NewReplayTransport() error = %v", err)
+	}
+	status, err := NewSOAPClientWithTransport(replay, nil).QueryStatus(context.Background(), "SP", "2")
+	if err != nil {
+		t.Fatalf("QueryStatus() replay error = %v", err)
+	}
+	if status.CStat != "107" || string(status.RawResponse) != response {
+		t.Fatalf("QueryStatus() replay = cStat %s, response %s", status.CStat, status.RawResponse)
+	}
+}
@@ -4,19 +4,35 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
 	"time"
+
+	nfceInfra "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
 )
 
 // AuthorizationRequest is the input for SEFAZ authorization.
 type AuthorizationRequest struct {
 	UF              string
 	Ambiente        string
+	Modelo          string // 65 (NFC-e) or 55 (NF-e), which select different authorizers; NFC-e when empty
 	XML             []byte
 	Contingency     bool   // Whether to use contingency mode
 	ContingencyType string // "SVC-AN" or "SVC-RS"
+	Async           bool   // Send the lote with indSinc=0; SEFAZ answers with a receipt (nRec) to poll
+	Certificate     *ClientCertificate
+}
+
+// maxLoteNFe is the limit of NF-e in an enviNFe lote
+const maxLoteNFe = 50
+
+// LoteAuthorizationRequest is the input for the asynchronous authorization of several NFC-e
+// packed in a single enviNFe lote. They must share the UF, the environment and the certificate.
+type LoteAuthorizationRequest struct {
+	UF          string
+	Ambiente    string
+	Modelo      string   // The lote carries documents of a single model
+	XMLs        [][]byte // Signed NFC-e, up to 50
+	Certificate *ClientCertificate
 }
 
 // AuthorizationResponse captures the SEFAZ reply.
@@ -25,30 +41,100 @@ type AuthorizationResponse struct {
 	CStat       string
 	Motivo      string
 	Protocolo   string
+	Recibo      string // nRec of asynchronous lotes
+	RawRequest  []byte // SOAP envelope sent by Authorize and AuthorizeLote, also on a failed call
+	RawResponse []byte
+}
+
+// ReceiptQueryRequest is the input for the NFeRetAutorizacao4 service.
+type ReceiptQueryRequest struct {
+	UF              string
+	Ambiente        string
+	Modelo          string
+	Recibo          string // nRec returned when the lote was received
+	ChaveAcesso     string // Selects the protNFe of the NFC-e in a lote of several
+	Contingency     bool
+	ContingencyType string
+	Certificate     *ClientCertificate
+}
+
+// ProtocolQueryRequest is the input for the NfeConsultaProtocolo4 service.
+type ProtocolQueryRequest struct {
+	UF          string
+	Ambiente    string
+	ChaveAcesso string
+	Certificate *ClientCertificate
+}
+
+// EventRequest is the input for the NFeRecepcaoEvento4 service.
+type EventRequest struct {
+	UF          string
+	Ambiente    string
+	ChaveAcesso string // NFC-e the event refers to
+	XML         []byte // Signed evento XML
+	Certificate *ClientCertificate
+}
+
+// EventResponse captures the SEFAZ reply for an event.
+type EventResponse struct {
+	Status      string
+	CStat       string
+	Motivo      string
+	Protocolo   string
+	RetEvento   []byte // retEvento element, used to build procEventoNFe
+	RawResponse []byte
+}
+
+// InutilizacaoRequest is the input for the NfeInutilizacao4 service.
+type InutilizacaoRequest struct {
+	UF          string
+	Ambiente    string
+	Modelo      string
+	CUF         string // cUF of the emitter, sent in nfeCabecMsg
+	XML         []byte // Signed inutNFe XML
+	Certificate *ClientCertificate
+}
+
+// InutilizacaoResponse captures the SEFAZ reply for a number range inutilização.
+type InutilizacaoResponse struct {
+	Status      string
+	CStat       string
+	Motivo      string
+	Protocolo   string
+	RetInutNFe  []byte // retInutNFe element, used to build procInutNFe
 	RawResponse []byte
 }
 
 // Client abstracts SOAP communication with SEFAZ.
 type Client interface {
 	Authorize(ctx context.Context, req AuthorizationRequest) (AuthorizationResponse, error)
+	AuthorizeLote(ctx context.Context, req LoteAuthorizationRequest) (AuthorizationResponse, error)
+	QueryReceipt(ctx context.Context, req ReceiptQueryRequest) (AuthorizationResponse, error)
 	QueryStatus(ctx context.Context, uf, ambiente string) (AuthorizationResponse, error)
+	QueryProtocol(ctx context.Context, req ProtocolQueryRequest) (AuthorizationResponse, error)
+	SendEvent(ctx context.Context, req EventRequest) (EventResponse, error)
+	Inutilize(ctx context.Context, req InutilizacaoRequest) (InutilizacaoResponse, error)
+	DistributeDFe(ctx context.Context, req DistributionRequest) (DistributionResponse, error)
 }
 
 // soapClient implements Client interface
 type soapClient struct {
-	httpClient *http.Client
-	endpoints  map[string]map[string]string // UF -> Ambiente -> URL
-	timeout    time.Duration
+	endpoints Endpoints // UF -> environment -> service -> URL; nil follows ActiveEndpoints
+	transport Transport
+}
+
+// NewSOAPClient creates a new SOAP client for SEFAZ communication over HTTPS; nil endpoints
+// follow the SEFAZ tables in use, so a reload applies to the next request
+func NewSOAPClient(timeout time.Duration, endpoints Endpoints) Client {
+	return NewSOAPClientWithTransport(NewHTTPTransport(timeout), endpoints)
 }
 
-// NewSOAPClient creates a new SOAP client for SEFAZ communication
-func NewSOAPClient(timeout time.Duration) Client {
+// NewSOAPClientWithTransport creates a SOAP client sending its envelopes through transport,
+// e.g. one recording or replaying the exchanges
+func NewSOAPClientWithTransport(transport Transport, endpoints Endpoints) Client {
 	return &soapClient{
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
-		endpoints: getSEFAZEndpoints(),
-		timeout:   timeout,
+		endpoints: endpoints,
+		transport: transport,
 	}
 }
 
@@ -58,42 +144,143 @@ func (c *soapClient) Authorize(ctx context.Context, req AuthorizationRequest) (A
 	var err error
 
 	if req.Contingency {
-		endpoint, err = c.getContingencyEndpoint(req.ContingencyType, req.Ambiente)
+		endpoint, err = c.getContingencyEndpoint(req.ContingencyType, req.Ambiente, ServiceAutorizacao)
 		if err != nil {
 			return AuthorizationResponse{}, fmt.Errorf("failed to get contingency endpoint: %w", err)
 		}
 	} else {
-		endpoint, err = c.getEndpoint(req.UF, req.Ambiente)
+		endpoint, err = c.getEndpoint(authorizer(req.UF, req.Modelo), req.Ambiente, ServiceAutorizacao)
 		if err != nil {
 			return AuthorizationResponse{}, fmt.Errorf("failed to get endpoint: %w", err)
 		}
 	}
 
+	cUF, err := ufCode(req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+
+	indSinc := "1"
+	if req.Async {
+		indSinc = "0"
+	}
+
 	// Build SOAP envelope
-	soapEnvelope := c.buildAuthorizationEnvelope(req.XML)
+	soapEnvelope := c.buildAuthorizationEnvelope([][]byte{req.XML}, cUF, indSinc)
 
 	// Send SOAP request
-	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope)
+	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope, req.Certificate)
+	if err != nil {
+		return AuthorizationResponse{RawRequest: []byte(soapEnvelope)}, fmt.Errorf("SOAP request failed: %w", err)
+	}
+
+	// Parse response
+	response, err := c.parseAuthorizationResponse(resp)
+	response.RawRequest = []byte(soapEnvelope)
+	if err != nil {
+		return response, err
+	}
+
+	// Lote recebido com sucesso - the protocol must be fetched with the receipt
+	if req.Async && response.CStat == "103" {
+		response.Status = "received"
+		response.Recibo = extractTag(resp, "nRec")
+	}
+
+	return response, nil
+}
+
+// AuthorizeLote sends several NFC-e in one enviNFe lote. SEFAZ only processes lotes of more
+// than one NF-e asynchronously, so a received lote (cStat 103) carries the receipt to poll.
+func (c *soapClient) AuthorizeLote(ctx context.Context, req LoteAuthorizationRequest) (AuthorizationResponse, error) {
+	if len(req.XMLs) == 0 || len(req.XMLs) > maxLoteNFe {
+		return AuthorizationResponse{}, fmt.Errorf("lote must have between 1 and %d NF-e", maxLoteNFe)
+	}
+
+	endpoint, err := c.getEndpoint(authorizer(req.UF, req.Modelo), req.Ambiente, ServiceAutorizacao)
+	if err != nil {
+		return AuthorizationResponse{}, fmt.Errorf("failed to get endpoint: %w", err)
+	}
+
+	cUF, err := ufCode(req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+
+	soapEnvelope := c.buildAuthorizationEnvelope(req.XMLs, cUF, "0")
+
+	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope, req.Certificate)
+	if err != nil {
+		return AuthorizationResponse{RawRequest: []byte(soapEnvelope)}, fmt.Errorf("SOAP request failed: %w", err)
+	}
+
+	response, err := c.parseAuthorizationResponse(resp)
+	response.RawRequest = []byte(soapEnvelope)
+	if err != nil {
+		return response, err
+	}
+
+	if response.CStat == "103" {
+		response.Status = "received"
+		response.Recibo = extractTag(resp, "nRec")
+	}
+
+	return response, nil
+}
+
+// QueryReceipt fetches the result of an asynchronous lote from SEFAZ NFeRetAutorizacao4
+func (c *soapClient) QueryReceipt(ctx context.Context, req ReceiptQueryRequest) (AuthorizationResponse, error) {
+	if req.Recibo == "" {
+		return AuthorizationResponse{}, fmt.Errorf("recibo is required")
+	}
+
+	var endpoint string
+	var err error
+
+	if req.Contingency {
+		endpoint, err = c.getContingencyEndpoint(req.ContingencyType, req.Ambiente, ServiceRetAutorizacao)
+	} else {
+		endpoint, err = c.getEndpoint(authorizer(req.UF, req.Modelo), req.Ambiente, ServiceRetAutorizacao)
+	}
+	if err != nil {
+		return AuthorizationResponse{}, fmt.Errorf("failed to get endpoint: %w", err)
+	}
+
+	cUF, err := ufCode(req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+
+	// Build receipt query SOAP envelope
+	soapEnvelope := c.buildReceiptQueryEnvelope(req.Recibo, cUF, req.Ambiente)
+
+	// Send SOAP request
+	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope, req.Certificate)
 	if err != nil {
 		return AuthorizationResponse{}, fmt.Errorf("SOAP request failed: %w", err)
 	}
 
 	// Parse response
-	return c.parseAuthorizationResponse(resp)
+	return c.parseReceiptResponse(resp, req.ChaveAcesso)
 }
 
 // QueryStatus queries SEFAZ service status
 func (c *soapClient) QueryStatus(ctx context.Context, uf, ambiente string) (AuthorizationResponse, error) {
-	endpoint, err := c.getEndpoint(uf, ambiente)
+	endpoint, err := c.getEndpoint(uf, ambiente, ServiceStatusServico)
 	if err != nil {
 		return AuthorizationResponse{}, fmt.Errorf("failed to get endpoint: %w", err)
 	}
 
+	cUF, err := ufCode(uf)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+
 	// Build status query SOAP envelope
-	soapEnvelope := c.buildStatusQueryEnvelope()
+	soapEnvelope := c.buildStatusQueryEnvelope(cUF, ambiente)
 
 	// Send SOAP request
-	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope)
+	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope, nil)
 	if err != nil {
 		return AuthorizationResponse{}, fmt.Errorf("SOAP request failed: %w", err)
 	}
@@ -102,41 +289,94 @@ func (c *soapClient) QueryStatus(ctx context.Context, uf, ambiente string) (Auth
 	return c.parseStatusResponse(resp)
 }
 
-// sendSOAPRequest sends a SOAP request to the specified endpoint
-func (c *soapClient) sendSOAPRequest(ctx context.Context, endpoint, soapEnvelope string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(soapEnvelope))
+// QueryProtocol queries the current situation of an NFC-e or NF-e by its chave de acesso,
+// at the authorizer of the model in the chave
+func (c *soapClient) QueryProtocol(ctx context.Context, req ProtocolQueryRequest) (AuthorizationResponse, error) {
+	if len(req.ChaveAcesso) != 44 {
+		return AuthorizationResponse{}, fmt.Errorf("invalid chave de acesso: %s", req.ChaveAcesso)
+	}
+
+	endpoint, err := c.getEndpoint(authorizer(req.UF, modeloOf(req.ChaveAcesso)), req.Ambiente, ServiceConsultaProtocolo)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		return AuthorizationResponse{}, fmt.Errorf("failed to get endpoint: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
-	req.Header.Set("SOAPAction", "")
+	// Build protocol query SOAP envelope
+	soapEnvelope := c.buildProtocolQueryEnvelope(req.ChaveAcesso, req.Ambiente)
 
-	resp, err := c.httpClient.Do(req)
+	// Send SOAP request
+	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope, req.Certificate)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return AuthorizationResponse{}, fmt.Errorf("SOAP request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP request failed with status: %d", resp.StatusCode)
+	// Parse response
+	return c.parseProtocolQueryResponse(resp)
+}
+
+// SendEvent sends an NFC-e event (e.g. cancellation) to SEFAZ RecepcaoEvento
+func (c *soapClient) SendEvent(ctx context.Context, req EventRequest) (EventResponse, error) {
+	if len(req.ChaveAcesso) != 44 {
+		return EventResponse{}, fmt.Errorf("invalid chave de acesso: %s", req.ChaveAcesso)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	endpoint, err := c.getEndpoint(authorizer(req.UF, modeloOf(req.ChaveAcesso)), req.Ambiente, ServiceRecepcaoEvento)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return EventResponse{}, fmt.Errorf("failed to get endpoint: %w", err)
 	}
 
-	return body, nil
+	// Build event SOAP envelope
+	soapEnvelope := c.buildEventEnvelope(req.XML, req.ChaveAcesso[:2])
+
+	// Send SOAP request
+	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope, req.Certificate)
+	if err != nil {
+		return EventResponse{}, fmt.Errorf("SOAP request failed: %w", err)
+	}
+
+	// Parse response
+	return c.parseEventResponse(resp)
 }
 
-// buildAuthorizationEnvelope builds SOAP envelope for NFC-e authorization
-func (c *soapClient) buildAuthorizationEnvelope(xmlContent []byte) string {
+// Inutilize sends an inutilização de numeração request to SEFAZ NfeInutilizacao4
+func (c *soapClient) Inutilize(ctx context.Context, req InutilizacaoRequest) (InutilizacaoResponse, error) {
+	endpoint, err := c.getEndpoint(authorizer(req.UF, req.Modelo), req.Ambiente, ServiceInutilizacao)
+	if err != nil {
+		return InutilizacaoResponse{}, fmt.Errorf("failed to get endpoint: %w", err)
+	}
+
+	cUF := req.CUF
+	if cUF == "" {
+		if cUF, err = ufCode(req.UF); err != nil {
+			return InutilizacaoResponse{}, err
+		}
+	}
+
+	// Build inutilização SOAP envelope
+	soapEnvelope := c.buildInutilizacaoEnvelope(req.XML, cUF)
+
+	// Send SOAP request
+	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope, req.Certificate)
+	if err != nil {
+		return InutilizacaoResponse{}, fmt.Errorf("SOAP request failed: %w", err)
+	}
+
+	// Parse response
+	return c.parseInutilizacaoResponse(resp)
+}
+
+// sendSOAPRequest sends a SOAP request to the specified endpoint through the transport
+func (c *soapClient) sendSOAPRequest(ctx context.Context, endpoint, soapEnvelope string, cert *ClientCertificate) ([]byte, error) {
+	return c.transport.Send(ctx, endpoint, []byte(soapEnvelope), cert)
+}
+
+// buildAuthorizationEnvelope builds SOAP envelope for NFC-e authorization with the NF-e of the lote
+func (c *soapClient) buildAuthorizationEnvelope(xmlContents [][]byte, cUF, indSinc string) string {
 	envelope := `<?xml version="1.0" encoding="UTF-8"?>
 <soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
 	<soap12:Header>
 		<nfeCabecMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeAutorizacao4">
-			<cUF>35</cUF>
+			<cUF>{{cUF}}</cUF>
 			<versaoDados>4.00</versaoDados>
 		</nfeCabecMsg>
 	</soap12:Header>
@@ -144,13 +384,8 @@ func (c *soapClient) buildAuthorizationEnvelope(xmlContent []byte) string {
 		<nfeDadosMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeAutorizacao4">
 			<NFeAutorizacaoLote xmlns="http://www.portalfiscal.inf.br/nfe">
 				<idLote>1</idLote>
-				<indSinc>1</indSinc>
-				<NFes>
-					<NFe>
-						<infNFe versao="4.00">
-							<!-- NFC-e content will be inserted here -->
-						</infNFe>
-					</NFe>
+				<indSinc>{{indSinc}}</indSinc>
+				<NFes>{{NFes}}
 				</NFes>
 			</NFeAutorizacaoLote>
 		</nfeDadosMsg>
@@ -159,31 +394,143 @@ func (c *soapClient) buildAuthorizationEnvelope(xmlContent []byte) string {
 
 	// Insert the XML content into the envelope
 	// This is a simplified approach - in production, proper XML manipulation should be used
-	xmlStr := string(xmlContent)
-	envelope = strings.Replace(envelope, "<!-- NFC-e content will be inserted here -->", xmlStr, 1)
+	var nfes strings.Builder
+	for _, xmlContent := range xmlContents {
+		nfes.WriteString(`
+					<NFe>
+						<infNFe versao="4.00">
+							`)
+		nfes.Write(xmlContent)
+		nfes.WriteString(`
+						</infNFe>
+					</NFe>`)
+	}
+	envelope = strings.Replace(envelope, "{{NFes}}", nfes.String(), 1)
+	envelope = strings.Replace(envelope, "{{cUF}}", cUF, 1)
+	envelope = strings.Replace(envelope, "{{indSinc}}", indSinc, 1)
 
 	return envelope
 }
 
 // buildStatusQueryEnvelope builds SOAP envelope for status query
-func (c *soapClient) buildStatusQueryEnvelope() string {
-	return `<?xml version="1.0" encoding="UTF-8"?>
+func (c *soapClient) buildStatusQueryEnvelope(cUF, ambiente string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
 	<soap12:Header>
 		<nfeCabecMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeStatusServico4">
-			<cUF>35</cUF>
+			<cUF>%[1]s</cUF>
 			<versaoDados>4.00</versaoDados>
 		</nfeCabecMsg>
 	</soap12:Header>
 	<soap12:Body>
 		<nfeDadosMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeStatusServico4">
 			<consStatServ versao="4.00" xmlns="http://www.portalfiscal.inf.br/nfe">
-				<tpAmb>2</tpAmb>
-				<cServ>SP</cServ>
+				<tpAmb>%[2]s</tpAmb>
+				<cUF>%[1]s</cUF>
+				<xServ>STATUS</xServ>
 			</consStatServ>
 		</nfeDadosMsg>
 	</soap12:Body>
-</soap12:Envelope>`
+</soap12:Envelope>`, cUF, tpAmb(ambiente))
+}
+
+// buildProtocolQueryEnvelope builds SOAP envelope for NFC-e protocol query
+func (c *soapClient) buildProtocolQueryEnvelope(chaveAcesso, ambiente string) string {
+	// cUF is the first two digits of the chave de acesso
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+	<soap12:Header>
+		<nfeCabecMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeConsultaProtocolo4">
+			<cUF>%s</cUF>
+			<versaoDados>4.00</versaoDados>
+		</nfeCabecMsg>
+	</soap12:Header>
+	<soap12:Body>
+		<nfeDadosMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeConsultaProtocolo4">
+			<consSitNFe versao="4.00" xmlns="http://www.portalfiscal.inf.br/nfe">
+				<tpAmb>%s</tpAmb>
+				<xServ>CONSULTAR</xServ>
+				<chNFe>%s</chNFe>
+			</consSitNFe>
+		</nfeDadosMsg>
+	</soap12:Body>
+</soap12:Envelope>`, chaveAcesso[:2], tpAmb(ambiente), chaveAcesso)
+}
+
+// buildReceiptQueryEnvelope builds SOAP envelope for the asynchronous lote receipt query
+func (c *soapClient) buildReceiptQueryEnvelope(recibo, cUF, ambiente string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+	<soap12:Header>
+		<nfeCabecMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeRetAutorizacao4">
+			<cUF>%s</cUF>
+			<versaoDados>4.00</versaoDados>
+		</nfeCabecMsg>
+	</soap12:Header>
+	<soap12:Body>
+		<nfeDadosMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeRetAutorizacao4">
+			<consReciNFe versao="4.00" xmlns="http://www.portalfiscal.inf.br/nfe">
+				<tpAmb>%s</tpAmb>
+				<nRec>%s</nRec>
+			</consReciNFe>
+		</nfeDadosMsg>
+	</soap12:Body>
+</soap12:Envelope>`, cUF, tpAmb(ambiente), recibo)
+}
+
+// buildEventEnvelope builds SOAP envelope for NFC-e events
+func (c *soapClient) buildEventEnvelope(eventoXML []byte, cUF string) string {
+	// Strip XML declaration from the signed evento
+	xmlStr := strings.TrimSpace(string(eventoXML))
+	if strings.HasPrefix(xmlStr, "<?xml") {
+		if idx := strings.Index(xmlStr, "?>"); idx != -1 {
+			xmlStr = strings.TrimSpace(xmlStr[idx+2:])
+		}
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+	<soap12:Header>
+		<nfeCabecMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeRecepcaoEvento4">
+			<cUF>%s</cUF>
+			<versaoDados>1.00</versaoDados>
+		</nfeCabecMsg>
+	</soap12:Header>
+	<soap12:Body>
+		<nfeDadosMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeRecepcaoEvento4">
+			<envEvento versao="1.00" xmlns="http://www.portalfiscal.inf.br/nfe">
+				<idLote>%d</idLote>
+				%s
+			</envEvento>
+		</nfeDadosMsg>
+	</soap12:Body>
+</soap12:Envelope>`, cUF, time.Now().UnixNano()%1000000000000000, xmlStr)
+}
+
+// buildInutilizacaoEnvelope builds SOAP envelope for number range inutilização
+func (c *soapClient) buildInutilizacaoEnvelope(inutXML []byte, cUF string) string {
+	// Strip XML declaration from the signed inutNFe
+	xmlStr := strings.TrimSpace(string(inutXML))
+	if strings.HasPrefix(xmlStr, "<?xml") {
+		if idx := strings.Index(xmlStr, "?>"); idx != -1 {
+			xmlStr = strings.TrimSpace(xmlStr[idx+2:])
+		}
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+	<soap12:Header>
+		<nfeCabecMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeInutilizacao4">
+			<cUF>%s</cUF>
+			<versaoDados>4.00</versaoDados>
+		</nfeCabecMsg>
+	</soap12:Header>
+	<soap12:Body>
+		<nfeDadosMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeInutilizacao4">
+			%s
+		</nfeDadosMsg>
+	</soap12:Body>
+</soap12:Envelope>`, cUF, xmlStr)
 }
 
 // parseAuthorizationResponse parses the SOAP response for authorization
@@ -228,6 +575,177 @@ func (c *soapClient) parseStatusResponse(soapResponse []byte) (AuthorizationResp
 	return c.parseAuthorizationResponse(soapResponse)
 }
 
+// parseProtocolQueryResponse parses the SOAP response for protocol query.
+// The first cStat in retConsSitNFe reflects the current situation of the NFC-e
+// (100 authorized, 101 canceled, 217 not found in SEFAZ database).
+func (c *soapClient) parseProtocolQueryResponse(soapResponse []byte) (AuthorizationResponse, error) {
+	response, err := c.parseAuthorizationResponse(soapResponse)
+	if err != nil {
+		return response, err
+	}
+
+	if response.CStat == "101" {
+		response.Status = "canceled"
+	}
+
+	return response, nil
+}
+
+// parseReceiptResponse parses the SOAP response for the receipt query.
+// The batch cStat 105 means the lote is still being processed; with 104 the
+// result of the NFC-e comes from protNFe/infProt, the one of chaveAcesso when set.
+func (c *soapClient) parseReceiptResponse(soapResponse []byte, chaveAcesso string) (AuthorizationResponse, error) {
+	batch, err := c.parseAuthorizationResponse(soapResponse)
+	if err != nil {
+		return batch, err
+	}
+
+	start, end := findProtNFe(soapResponse, chaveAcesso)
+	if start == -1 {
+		if batch.CStat == "105" { // Lote em processamento
+			batch.Status = "processing"
+		} else {
+			batch.Status = "error"
+		}
+		return batch, nil
+	}
+
+	response, err := c.parseAuthorizationResponse(soapResponse[start:end])
+	if err != nil {
+		return response, err
+	}
+	response.RawResponse = soapResponse
+
+	switch {
+	case response.CStat == "100" || response.CStat == "150": // Autorizado o uso da NF-e
+		response.Status = "authorized"
+	case IsRetryableError(response.CStat):
+		response.Status = "error"
+	default:
+		response.Status = "denied"
+	}
+
+	return response, nil
+}
+
+// findProtNFe locates the protNFe element of the NFC-e with chaveAcesso, the first one when
+// empty; it returns -1 when there is none
+func findProtNFe(content []byte, chaveAcesso string) (int, int) {
+	offset := 0
+	for {
+		start := bytes.Index(content[offset:], []byte("<protNFe"))
+		if start == -1 {
+			return -1, -1
+		}
+		start += offset
+		end := bytes.Index(content[start:], []byte("</protNFe>"))
+		if end == -1 {
+			return -1, -1
+		}
+		end += start + len("</protNFe>")
+
+		if chaveAcesso == "" || extractTag(content[start:end], "chNFe") == chaveAcesso {
+			return start, end
+		}
+		offset = end
+	}
+}
+
+// extractTag returns the text of the first occurrence of the given element
+func extractTag(content []byte, tag string) string {
+	open := []byte("<" + tag + ">")
+	idx := bytes.Index(content, open)
+	if idx == -1 {
+		return ""
+	}
+	start := idx + len(open)
+	end := bytes.Index(content[start:], []byte("</"+tag+">"))
+	if end == -1 {
+		return ""
+	}
+	return string(content[start : start+end])
+}
+
+// parseEventResponse parses the SOAP response for events.
+// The batch cStat (128) is ignored; the result comes from retEvento/infEvento.
+func (c *soapClient) parseEventResponse(soapResponse []byte) (EventResponse, error) {
+	response := EventResponse{
+		RawResponse: soapResponse,
+	}
+
+	start := bytes.Index(soapResponse, []byte("<retEvento"))
+	end := bytes.Index(soapResponse, []byte("</retEvento>"))
+	if start == -1 || end == -1 || end < start {
+		// Batch rejected before event processing
+		batch, err := c.parseAuthorizationResponse(soapResponse)
+		if err != nil {
+			return response, err
+		}
+		response.CStat = batch.CStat
+		response.Motivo = batch.Motivo
+		response.Status = c.determineEventStatus(batch.CStat)
+		return response, nil
+	}
+
+	response.RetEvento = soapResponse[start : end+len("</retEvento>")]
+
+	parsed, err := c.parseAuthorizationResponse(response.RetEvento)
+	if err != nil {
+		return response, err
+	}
+	response.CStat = parsed.CStat
+	response.Motivo = parsed.Motivo
+	response.Protocolo = parsed.Protocolo
+	response.Status = c.determineEventStatus(parsed.CStat)
+
+	return response, nil
+}
+
+// parseInutilizacaoResponse parses the SOAP response for inutilização
+func (c *soapClient) parseInutilizacaoResponse(soapResponse []byte) (InutilizacaoResponse, error) {
+	response := InutilizacaoResponse{
+		RawResponse: soapResponse,
+	}
+
+	parsed, err := c.parseAuthorizationResponse(soapResponse)
+	if err != nil {
+		return response, err
+	}
+	response.CStat = parsed.CStat
+	response.Motivo = parsed.Motivo
+	response.Protocolo = parsed.Protocolo
+
+	start := bytes.Index(soapResponse, []byte("<retInutNFe"))
+	end := bytes.Index(soapResponse, []byte("</retInutNFe>"))
+	if start != -1 && end != -1 && end > start {
+		response.RetInutNFe = soapResponse[start : end+len("</retInutNFe>")]
+	}
+
+	switch {
+	case response.CStat == "102": // Inutilização de número homologado
+		response.Status = "homologated"
+	case IsRetryableError(response.CStat):
+		response.Status = "error"
+	default:
+		response.Status = "rejected"
+	}
+
+	return response, nil
+}
+
+// determineEventStatus determines the event status based on cStat
+func (c *soapClient) determineEventStatus(cstat string) string {
+	switch cstat {
+	case "135", "136", "155": // Evento registrado (155: cancelamento fora de prazo homologado)
+		return "registered"
+	default:
+		if IsRetryableError(cstat) {
+			return "error"
+		}
+		return "denied"
+	}
+}
+
 // determineStatus determines the status based on cStat
 func (c *soapClient) determineStatus(cstat string) string {
 	switch cstat {
@@ -298,163 +816,39 @@ func GetErrorCategory(cstat string) string {
 	}
 }
 
-// getEndpoint returns the SEFAZ endpoint for the given UF and environment
-func (c *soapClient) getEndpoint(uf, ambiente string) (string, error) {
-	ufMap, exists := c.endpoints[uf]
-	if !exists {
-		return "", fmt.Errorf("UF %s not supported", uf)
+// getEndpoint returns the URL of a SEFAZ service for the given UF and environment
+func (c *soapClient) getEndpoint(uf, ambiente, service string) (string, error) {
+	endpoints := c.endpoints
+	if endpoints == nil {
+		endpoints = ActiveEndpoints()
 	}
 
-	env := "prod"
-	if ambiente == "2" || ambiente == "homologacao" {
-		env = "hom"
+	envs, exists := endpoints[uf]
+	if !exists {
+		return "", fmt.Errorf("UF %s not supported", uf)
 	}
 
-	endpoint, exists := ufMap[env]
-	if !exists {
-		return "", fmt.Errorf("environment %s not supported for UF %s", ambiente, uf)
+	endpoint, exists := envs[environment(ambiente)][service]
+	if !exists || endpoint == "" {
+		return "", fmt.Errorf("service %s not available in environment %s for UF %s", service, ambiente, uf)
 	}
 
 	return endpoint, nil
 }
 
-// getContingencyEndpoint returns the contingency endpoint for SVC-AN or SVC-RS
-func (c *soapClient) getContingencyEndpoint(contingencyType, ambiente string) (string, error) {
-	env := "prod"
-	if ambiente == "2" || ambiente == "homologacao" {
-		env = "hom"
-	}
-
-	switch contingencyType {
-	case "SVC-AN":
-		// SVC-AN (Sistema Virtual de Contingência - Ambiente Nacional)
-		if env == "prod" {
-			return "https://www.svc.fazenda.gov.br/NFeAutorizacao4/NFeAutorizacao4.asmx", nil
-		}
-		return "https://hom.svc.fazenda.gov.br/NFeAutorizacao4/NFeAutorizacao4.asmx", nil
-
-	case "SVC-RS":
-		// SVC-RS (Sistema Virtual de Contingência - Rio Grande do Sul)
-		if env == "prod" {
-			return "https://www.svrs.rs.gov.br/NFeAutorizacao4/NFeAutorizacao4.asmx", nil
-		}
-		return "https://hom.svrs.rs.gov.br/NFeAutorizacao4/NFeAutorizacao4.asmx", nil
-
-	default:
+// getContingencyEndpoint returns the URL of a SEFAZ service on SVC-AN or SVC-RS
+func (c *soapClient) getContingencyEndpoint(contingencyType, ambiente, service string) (string, error) {
+	if contingencyType != "SVC-AN" && contingencyType != "SVC-RS" {
 		return "", fmt.Errorf("unsupported contingency type: %s", contingencyType)
 	}
+	return c.getEndpoint(contingencyType, ambiente, service)
 }
 
-// getSEFAZEndpoints returns the SEFAZ endpoints for each UF and environment
-func getSEFAZEndpoints() map[string]map[string]string {
-	return map[string]map[string]string{
-		"AC": {
-			"prod": "https://www.sefaznet.ac.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://www.sefaznet.ac.gov.br/nfce/NFeAutorizacao4",
-		},
-		"AL": {
-			"prod": "https://nfce.sefaz.al.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.al.gov.br/nfce/NFeAutorizacao4",
-		},
-		"AP": {
-			"prod": "https://nfce.sefaz.ap.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.ap.gov.br/nfce/NFeAutorizacao4",
-		},
-		"AM": {
-			"prod": "https://nfce.sefaz.am.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.am.gov.br/nfce/NFeAutorizacao4",
-		},
-		"BA": {
-			"prod": "https://nfce.sefaz.ba.gov.br/webservices/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.ba.gov.br/webservices/NFeAutorizacao4",
-		},
-		"CE": {
-			"prod": "https://nfce.sefaz.ce.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.ce.gov.br/nfce/NFeAutorizacao4",
-		},
-		"DF": {
-			"prod": "https://www.nfce.fazenda.df.gov.br/NFeAutorizacao4",
-			"hom":  "https://www.nfce.fazenda.df.gov.br/NFeAutorizacao4",
-		},
-		"ES": {
-			"prod": "https://nfce.sefaz.es.gov.br/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.es.gov.br/NFeAutorizacao4",
-		},
-		"GO": {
-			"prod": "https://nfce.sefaz.go.gov.br/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.go.gov.br/NFeAutorizacao4",
-		},
-		"MA": {
-			"prod": "https://nfce.sefaz.ma.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.ma.gov.br/nfce/NFeAutorizacao4",
-		},
-		"MT": {
-			"prod": "https://nfce.sefaz.mt.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.mt.gov.br/nfce/NFeAutorizacao4",
-		},
-		"MS": {
-			"prod": "https://nfce.sefaz.ms.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.ms.gov.br/nfce/NFeAutorizacao4",
-		},
-		"MG": {
-			"prod": "https://nfce.fazenda.mg.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.fazenda.mg.gov.br/nfce/NFeAutorizacao4",
-		},
-		"PA": {
-			"prod": "https://nfce.sefa.pa.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefa.pa.gov.br/nfce/NFeAutorizacao4",
-		},
-		"PB": {
-			"prod": "https://nfce.sefaz.pb.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.pb.gov.br/nfce/NFeAutorizacao4",
-		},
-		"PR": {
-			"prod": "https://nfce.sefaz.pr.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.pr.gov.br/nfce/NFeAutorizacao4",
-		},
-		"PE": {
-			"prod": "https://nfce.sefaz.pe.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.pe.gov.br/nfce/NFeAutorizacao4",
-		},
-		"PI": {
-			"prod": "https://nfce.sefaz.pi.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.pi.gov.br/nfce/NFeAutorizacao4",
-		},
-		"RJ": {
-			"prod": "https://nfce.sefaz.rj.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.rj.gov.br/nfce/NFeAutorizacao4",
-		},
-		"RN": {
-			"prod": "https://nfce.sefaz.rn.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.rn.gov.br/nfce/NFeAutorizacao4",
-		},
-		"RS": {
-			"prod": "https://nfce.sefaz.rs.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.rs.gov.br/nfce/NFeAutorizacao4",
-		},
-		"RO": {
-			"prod": "https://nfce.sefaz.ro.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.ro.gov.br/nfce/NFeAutorizacao4",
-		},
-		"RR": {
-			"prod": "https://nfce.sefaz.rr.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.rr.gov.br/nfce/NFeAutorizacao4",
-		},
-		"SC": {
-			"prod": "https://nfce.sefaz.sc.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.sc.gov.br/nfce/NFeAutorizacao4",
-		},
-		"SP": {
-			"prod": "https://nfce.fazenda.sp.gov.br/NFeAutorizacao4",
-			"hom":  "https://nfce.fazenda.sp.gov.br/NFeAutorizacao4",
-		},
-		"SE": {
-			"prod": "https://nfce.sefaz.se.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.se.gov.br/nfce/NFeAutorizacao4",
-		},
-		"TO": {
-			"prod": "https://nfce.sefaz.to.gov.br/nfce/NFeAutorizacao4",
-			"hom":  "https://nfce.sefaz.to.gov.br/nfce/NFeAutorizacao4",
-		},
+// ufCode returns the cUF sent in nfeCabecMsg for the given UF
+func ufCode(uf string) (string, error) {
+	cUF, exists := nfceInfra.GetCUF(uf)
+	if !exists {
+		return "", fmt.Errorf("UF %s not supported", uf)
 	}
+	return cUF, nil
 }
@@ -0,0 +1,192 @@
+package soapclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DistributionRequest is the input for the Ambiente Nacional NFeDistribuicaoDFe service.
+// Documents are fetched after UltNSU, or the single document NSU when it is set.
+type DistributionRequest struct {
+	Ambiente    string
+	UF          string // UF of the interested party (cUFAutor)
+	CNPJ        string // Interested party whose documents are distributed
+	UltNSU      string // Last NSU already received (distNSU)
+	NSU         string // Specific NSU to fetch again (consNSU)
+	Certificate *ClientCertificate
+}
+
+// DistributedDocument is a docZip of the distribution lote, already decompressed
+type DistributedDocument struct {
+	NSU          string
+	Schema       string // e.g. resNFe_v1.01.xsd, procNFe_v4.00.xsd, resEvento_v1.01.xsd
+	ChaveAcesso  string // chNFe of the document or of the event
+	CNPJEmitente string // First CNPJ of the document: the issuer, or the event author
+	XML          []byte
+}
+
+// DistributionResponse captures the retDistDFeInt reply
+type DistributionResponse struct {
+	CStat       string // 137 nenhum documento, 138 documentos localizados
+	Motivo      string
+	UltNSU      string
+	MaxNSU      string
+	Documents   []DistributedDocument
+	RawResponse []byte
+}
+
+// retDistDFeInt is the distribution reply inside the SOAP body
+type retDistDFeInt struct {
+	CStat   string `xml:"cStat"`
+	XMotivo string `xml:"xMotivo"`
+	UltNSU  string `xml:"ultNSU"`
+	MaxNSU  string `xml:"maxNSU"`
+	DocZips []struct {
+		NSU     string `xml:"NSU,attr"`
+		Schema  string `xml:"schema,attr"`
+		Content string `xml:",chardata"`
+	} `xml:"loteDistDFeInt>docZip"`
+}
+
+// DistributeDFe fetches the NSU-sequenced documents and events of interest of a CNPJ
+func (c *soapClient) DistributeDFe(ctx context.Context, req DistributionRequest) (DistributionResponse, error) {
+	if len(req.CNPJ) != 14 {
+		return DistributionResponse{}, fmt.Errorf("invalid CNPJ: %s", req.CNPJ)
+	}
+
+	cUFAutor, err := ufCode(req.UF)
+	if err != nil {
+		return DistributionResponse{}, err
+	}
+
+	endpoint, err := c.getEndpoint(ambienteNacional, req.Ambiente, ServiceDistribuicaoDFe)
+	if err != nil {
+		return DistributionResponse{}, fmt.Errorf("failed to get endpoint: %w", err)
+	}
+
+	// Build distribution SOAP envelope
+	soapEnvelope := c.buildDistributionEnvelope(req, cUFAutor)
+
+	// Send SOAP request
+	resp, err := c.sendSOAPRequest(ctx, endpoint, soapEnvelope, req.Certificate)
+	if err != nil {
+		return DistributionResponse{}, fmt.Errorf("SOAP request failed: %w", err)
+	}
+
+	// Parse response
+	return c.parseDistributionResponse(resp)
+}
+
+// buildDistributionEnvelope builds SOAP envelope for the DF-e distribution
+func (c *soapClient) buildDistributionEnvelope(req DistributionRequest, cUFAutor string) string {
+	query := fmt.Sprintf("<distNSU><ultNSU>%s</ultNSU></distNSU>", padNSU(req.UltNSU))
+	if req.NSU != "" {
+		query = fmt.Sprintf("<consNSU><NSU>%s</NSU></consNSU>", padNSU(req.NSU))
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+	<soap12:Body>
+		<nfeDistDFeInteresse xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeDistribuicaoDFe">
+			<nfeDadosMsg>
+				<distDFeInt versao="1.01" xmlns="http://www.portalfiscal.inf.br/nfe">
+					<tpAmb>%s</tpAmb>
+					<cUFAutor>%s</cUFAutor>
+					<CNPJ>%s</CNPJ>
+					%s
+				</distDFeInt>
+			</nfeDadosMsg>
+		</nfeDistDFeInteresse>
+	</soap12:Body>
+</soap12:Envelope>`, tpAmb(req.Ambiente), cUFAutor, req.CNPJ, query)
+}
+
+// parseDistributionResponse parses retDistDFeInt and decompresses the docZip documents
+func (c *soapClient) parseDistributionResponse(soapResponse []byte) (DistributionResponse, error) {
+	ret, err := decodeRetDistDFeInt(soapResponse)
+	if err != nil {
+		return DistributionResponse{}, err
+	}
+
+	response := DistributionResponse{
+		CStat:       ret.CStat,
+		Motivo:      ret.XMotivo,
+		UltNSU:      ret.UltNSU,
+		MaxNSU:      ret.MaxNSU,
+		RawResponse: soapResponse,
+	}
+
+	for _, docZip := range ret.DocZips {
+		content, err := unzipDocument(docZip.Content)
+		if err != nil {
+			return DistributionResponse{}, fmt.Errorf("failed to decompress document NSU %s: %w", docZip.NSU, err)
+		}
+		response.Documents = append(response.Documents, DistributedDocument{
+			NSU:          docZip.NSU,
+			Schema:       docZip.Schema,
+			ChaveAcesso:  extractTag(content, "chNFe"),
+			CNPJEmitente: extractTag(content, "CNPJ"),
+			XML:          content,
+		})
+	}
+
+	return response, nil
+}
+
+// decodeRetDistDFeInt finds retDistDFeInt inside the SOAP envelope
+func decodeRetDistDFeInt(soapResponse []byte) (retDistDFeInt, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(soapResponse))
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return retDistDFeInt{}, errors.New("retDistDFeInt not found in SEFAZ response")
+		}
+		if err != nil {
+			return retDistDFeInt{}, fmt.Errorf("failed to parse SEFAZ response: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "retDistDFeInt" {
+			continue
+		}
+
+		var ret retDistDFeInt
+		if err := decoder.DecodeElement(&ret, &start); err != nil {
+			return retDistDFeInt{}, fmt.Errorf("failed to parse retDistDFeInt: %w", err)
+		}
+		return ret, nil
+	}
+}
+
+// unzipDocument decodes a base64 GZip docZip
+func unzipDocument(content string) ([]byte, error) {
+	compressed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace([]byte(content))))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// padNSU left-pads an NSU to the 15 digits of the layout
+func padNSU(nsu string) string {
+	if nsu == "" {
+		nsu = "0"
+	}
+	for len(nsu) < 15 {
+		nsu = "0" + nsu
+	}
+	return nsu
+}
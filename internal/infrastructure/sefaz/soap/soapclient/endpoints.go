@@ -0,0 +1,73 @@
+package soapclient
+
+import (
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/tables"
+)
+
+// SEFAZ web services of the NF-e/NFC-e 4.00 layout
+const (
+	ServiceAutorizacao       = tables.ServiceAutorizacao
+	ServiceRetAutorizacao    = tables.ServiceRetAutorizacao
+	ServiceConsultaProtocolo = tables.ServiceConsultaProtocolo
+	ServiceStatusServico     = tables.ServiceStatusServico
+	ServiceRecepcaoEvento    = tables.ServiceRecepcaoEvento
+	ServiceInutilizacao      = tables.ServiceInutilizacao
+	ServiceDistribuicaoDFe   = tables.ServiceDistribuicaoDFe // Ambiente Nacional only
+)
+
+// ambienteNacional is the registry entry of the Ambiente Nacional services
+const ambienteNacional = tables.AuthorizerAmbienteNacional
+
+// Environments of the endpoint registry
+const (
+	envProducao    = tables.EnvProducao
+	envHomologacao = tables.EnvHomologacao
+)
+
+// Endpoints maps UF (or contingency type) -> environment ("prod"/"hom") -> service -> URL
+type Endpoints map[string]map[string]map[string]string
+
+// Models of the documents authorized; the NF-e has its own authorizers
+const (
+	modeloNFCe = "65"
+	modeloNFe  = "55"
+)
+
+// authorizer returns the registry entry of the authorizer of a UF for a document model: the
+// UF itself for the NFC-e and "<UF>/55" for the NF-e, e.g. "BA/55"
+func authorizer(uf, modelo string) string {
+	if modelo == modeloNFe {
+		return uf + "/" + modeloNFe
+	}
+	return uf
+}
+
+// modeloOf returns the model of the document numbered by a chave de acesso
+func modeloOf(chave string) string {
+	if len(chave) != 44 {
+		return modeloNFCe
+	}
+	return chave[20:22]
+}
+
+// ActiveEndpoints returns the registry of the SEFAZ tables in use, reloaded from
+// SEFAZ_TABLES_FILE on SIGHUP or by the admin
+func ActiveEndpoints() Endpoints {
+	return tables.Active().Endpoints()
+}
+
+// environment returns the registry environment of an ambiente
+func environment(ambiente string) string {
+	if ambiente == "2" || ambiente == "homologacao" {
+		return envHomologacao
+	}
+	return envProducao
+}
+
+// tpAmb returns the tpAmb code of an ambiente
+func tpAmb(ambiente string) string {
+	if environment(ambiente) == envHomologacao {
+		return "2"
+	}
+	return "1"
+}
@@ -0,0 +1,117 @@
+package soapclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ufLimitedClient caps the requests in flight to the SEFAZ of each UF, so a burst of
+// emissions does not trip the authorizer rate limits (e.g. cStat 656 - consumo indevido)
+type ufLimitedClient struct {
+	client Client
+	limit  int
+	mu     sync.Mutex
+	slots  map[string]chan struct{} // UF -> semaphore
+}
+
+// WithUFConcurrency wraps client allowing at most limit concurrent requests per UF;
+// callers wait for a free slot until their context is done
+func WithUFConcurrency(client Client, limit int) Client {
+	return &ufLimitedClient{
+		client: client,
+		limit:  limit,
+		slots:  make(map[string]chan struct{}),
+	}
+}
+
+// acquire waits for a slot of the UF and returns its release
+func (c *ufLimitedClient) acquire(ctx context.Context, uf string) (func(), error) {
+	c.mu.Lock()
+	slots, ok := c.slots[uf]
+	if !ok {
+		slots = make(chan struct{}, c.limit)
+		c.slots[uf] = slots
+	}
+	c.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a SEFAZ %s request slot: %w", uf, ctx.Err())
+	}
+}
+
+func (c *ufLimitedClient) Authorize(ctx context.Context, req AuthorizationRequest) (AuthorizationResponse, error) {
+	release, err := c.acquire(ctx, req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	defer release()
+	return c.client.Authorize(ctx, req)
+}
+
+func (c *ufLimitedClient) AuthorizeLote(ctx context.Context, req LoteAuthorizationRequest) (AuthorizationResponse, error) {
+	release, err := c.acquire(ctx, req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	defer release()
+	return c.client.AuthorizeLote(ctx, req)
+}
+
+func (c *ufLimitedClient) QueryReceipt(ctx context.Context, req ReceiptQueryRequest) (AuthorizationResponse, error) {
+	release, err := c.acquire(ctx, req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	defer release()
+	return c.client.QueryReceipt(ctx, req)
+}
+
+func (c *ufLimitedClient) QueryStatus(ctx context.Context, uf, ambiente string) (AuthorizationResponse, error) {
+	release, err := c.acquire(ctx, uf)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	defer release()
+	return c.client.QueryStatus(ctx, uf, ambiente)
+}
+
+func (c *ufLimitedClient) QueryProtocol(ctx context.Context, req ProtocolQueryRequest) (AuthorizationResponse, error) {
+	release, err := c.acquire(ctx, req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	defer release()
+	return c.client.QueryProtocol(ctx, req)
+}
+
+func (c *ufLimitedClient) SendEvent(ctx context.Context, req EventRequest) (EventResponse, error) {
+	release, err := c.acquire(ctx, req.UF)
+	if err != nil {
+		return EventResponse{}, err
+	}
+	defer release()
+	return c.client.SendEvent(ctx, req)
+}
+
+func (c *ufLimitedClient) Inutilize(ctx context.Context, req InutilizacaoRequest) (InutilizacaoResponse, error) {
+	release, err := c.acquire(ctx, req.UF)
+	if err != nil {
+		return InutilizacaoResponse{}, err
+	}
+	defer release()
+	return c.client.Inutilize(ctx, req)
+}
+
+// DistributeDFe is served by the Ambiente Nacional for every UF
+func (c *ufLimitedClient) DistributeDFe(ctx context.Context, req DistributionRequest) (DistributionResponse, error) {
+	release, err := c.acquire(ctx, ambienteNacional)
+	if err != nil {
+		return DistributionResponse{}, err
+	}
+	defer release()
+	return c.client.DistributeDFe(ctx, req)
+}
@@ -0,0 +1,577 @@
+package soapclient
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// ambienteSimulado is the ambiente answered by the in-process simulator instead of SEFAZ. Its
+// NFC-e are issued as homologação (tpAmb 2), without fiscal value.
+const ambienteSimulado = "simulado"
+
+// maxSimulatedEntries bounds the protocols and receipts the simulator remembers
+const maxSimulatedEntries = 10000
+
+// ErrSimulatedUnavailable is the transport failure of the simulated timeouts and outages
+var ErrSimulatedUnavailable = errors.New("simulated SEFAZ did not answer")
+
+// Magic values of the simulated ambiente. An item description containing "SIMULAR CSTAT nnn"
+// answers with that cStat, and the NFC-e totals below with their fixed outcome. "SIMULAR
+// TIMEOUT" authorizes the NFC-e but fails the call, as a timeout after SEFAZ received it, and
+// "SIMULAR INDISPONIVEL" fails the call without authorizing it. The cancellation and
+// inutilização justifications accept "SIMULAR CSTAT nnn" too.
+var (
+	simulatedCStatPattern = regexp.MustCompile(`SIMULAR CSTAT (\d{3})`)
+	simulatedTotals       = map[string]string{
+		"999.00": "610", // Total da NF difere do somatório
+		"998.00": "108", // Serviço paralisado, exercises the contingency
+		"997.00": "301", // Uso denegado
+		"996.00": "656", // Consumo indevido
+	}
+)
+
+const (
+	simulatedTimeout     = "SIMULAR TIMEOUT"
+	simulatedUnavailable = "SIMULAR INDISPONIVEL"
+)
+
+// simulatedMotivos are the xMotivo of the cStat the simulator answers with
+var simulatedMotivos = map[string]string{
+	"100": "Autorizado o uso da NF-e",
+	"101": "Cancelamento de NF-e homologado",
+	"102": "Inutilização de número homologado",
+	"103": "Lote recebido com sucesso",
+	"104": "Lote processado",
+	"106": "Lote não localizado",
+	"107": "Serviço em Operação",
+	"108": "Serviço Paralisado Momentaneamente (curto prazo)",
+	"109": "Serviço Paralisado sem Previsão",
+	"128": "Lote de Evento Processado",
+	"135": "Evento registrado e vinculado a NF-e",
+	"137": "Nenhum documento localizado para o Destinatário",
+	"204": "Rejeição: Duplicidade de NF-e",
+	"215": "Rejeição: Falha no schema XML",
+	"217": "Rejeição: NF-e não consta na base de dados da SEFAZ",
+	"225": "Rejeição: Falha no Schema XML do lote de NFe",
+	"226": "Rejeição: Código da UF do Emitente diverge da UF autorizadora",
+	"252": "Rejeição: Ambiente informado diverge do Ambiente de recebimento",
+	"301": "Uso Denegado: Irregularidade fiscal do emitente",
+	"502": "Rejeição: Erro na Chave de Acesso - Campo Id não corresponde à concatenação dos campos correspondentes",
+	"539": "Rejeição: Duplicidade de NF-e com diferença na Chave de Acesso",
+	"573": "Rejeição: Duplicidade de Evento",
+	"610": "Rejeição: Total da NF difere do somatório dos Valores compõe o valor Total da NF",
+	"656": "Rejeição: Consumo Indevido",
+}
+
+// loteCStats are answered for the whole lote, without a protNFe, as SEFAZ does
+var loteCStats = map[string]bool{"108": true, "109": true, "225": true, "226": true, "252": true, "656": true}
+
+// simulatedClient sends the requests of the simulated ambiente to the simulator
+type simulatedClient struct {
+	client    Client
+	simulator Client
+}
+
+// WithSimulator wraps client answering the requests of the "simulado" ambiente with the
+// in-process fake SEFAZ, so integrators test their error handling without SEFAZ credentials
+func WithSimulator(client Client) Client {
+	return &simulatedClient{client: client, simulator: NewSimulator()}
+}
+
+func (c *simulatedClient) Authorize(ctx context.Context, req AuthorizationRequest) (AuthorizationResponse, error) {
+	if req.Ambiente == ambienteSimulado {
+		return c.simulator.Authorize(ctx, req)
+	}
+	return c.client.Authorize(ctx, req)
+}
+
+func (c *simulatedClient) AuthorizeLote(ctx context.Context, req LoteAuthorizationRequest) (AuthorizationResponse, error) {
+	if req.Ambiente == ambienteSimulado {
+		return c.simulator.AuthorizeLote(ctx, req)
+	}
+	return c.client.AuthorizeLote(ctx, req)
+}
+
+func (c *simulatedClient) QueryReceipt(ctx context.Context, req ReceiptQueryRequest) (AuthorizationResponse, error) {
+	if req.Ambiente == ambienteSimulado {
+		return c.simulator.QueryReceipt(ctx, req)
+	}
+	return c.client.QueryReceipt(ctx, req)
+}
+
+func (c *simulatedClient) QueryStatus(ctx context.Context, uf, ambiente string) (AuthorizationResponse, error) {
+	if ambiente == ambienteSimulado {
+		return c.simulator.QueryStatus(ctx, uf, ambiente)
+	}
+	return c.client.QueryStatus(ctx, uf, ambiente)
+}
+
+func (c *simulatedClient) QueryProtocol(ctx context.Context, req ProtocolQueryRequest) (AuthorizationResponse, error) {
+	if req.Ambiente == ambienteSimulado {
+		return c.simulator.QueryProtocol(ctx, req)
+	}
+	return c.client.QueryProtocol(ctx, req)
+}
+
+func (c *simulatedClient) SendEvent(ctx context.Context, req EventRequest) (EventResponse, error) {
+	if req.Ambiente == ambienteSimulado {
+		return c.simulator.SendEvent(ctx, req)
+	}
+	return c.client.SendEvent(ctx, req)
+}
+
+func (c *simulatedClient) Inutilize(ctx context.Context, req InutilizacaoRequest) (InutilizacaoResponse, error) {
+	if req.Ambiente == ambienteSimulado {
+		return c.simulator.Inutilize(ctx, req)
+	}
+	return c.client.Inutilize(ctx, req)
+}
+
+func (c *simulatedClient) DistributeDFe(ctx context.Context, req DistributionRequest) (DistributionResponse, error) {
+	if req.Ambiente == ambienteSimulado {
+		return c.simulator.DistributeDFe(ctx, req)
+	}
+	return c.client.DistributeDFe(ctx, req)
+}
+
+// simulatedProtocol is an authorization issued by the simulator
+type simulatedProtocol struct {
+	protNFe  string
+	canceled bool
+}
+
+// simulator is a fake SEFAZ answering in process. It checks the NFC-e as the authorizer would,
+// answers with deterministic cStat and protocols, and keeps the protocols and receipts in
+// memory, so they are only known to the process that issued them.
+type simulator struct {
+	parser *soapClient // Parses the answers like those of SEFAZ
+
+	mu        sync.Mutex
+	protocols map[string]*simulatedProtocol // chave de acesso -> authorization
+	chaves    []string                      // Authorization order, to forget the oldest
+	lotes     map[string][]string           // nRec -> protNFe of the lote
+	recibos   []string
+}
+
+// NewSimulator creates the fake SEFAZ of the simulated ambiente
+func NewSimulator() Client {
+	return &simulator{
+		parser:    &soapClient{},
+		protocols: make(map[string]*simulatedProtocol),
+		lotes:     make(map[string][]string),
+	}
+}
+
+// simulatedNFe holds the fields of a signed NFC-e checked by the simulator
+type simulatedNFe struct {
+	XMLName xml.Name `xml:"NFe"`
+	InfNFe  struct {
+		ID  string `xml:"Id,attr"`
+		Ide struct {
+			CUF   string `xml:"cUF"`
+			TpAmb string `xml:"tpAmb"`
+		} `xml:"ide"`
+		Det []struct {
+			XProd string `xml:"prod>xProd"`
+		} `xml:"det"`
+		VNF string `xml:"total>ICMSTot>vNF"`
+	} `xml:"infNFe"`
+	DigestValue string `xml:"Signature>SignedInfo>Reference>DigestValue"`
+}
+
+// simulatedOutcome is the answer of the simulator to one NFC-e
+type simulatedOutcome struct {
+	chave  string
+	cStat  string
+	digVal string
+	fail   bool // The call fails without an answer
+}
+
+func (s *simulator) Authorize(ctx context.Context, req AuthorizationRequest) (AuthorizationResponse, error) {
+	cUF, err := ufCode(req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	indSinc := "1"
+	if req.Async {
+		indSinc = "0"
+	}
+	rawRequest := []byte(s.parser.buildAuthorizationEnvelope([][]byte{req.XML}, cUF, indSinc))
+
+	outcome := s.evaluate(req.XML, cUF)
+	if outcome.fail {
+		return AuthorizationResponse{RawRequest: rawRequest}, fmt.Errorf("SOAP request failed: %w", ErrSimulatedUnavailable)
+	}
+	if loteCStats[outcome.cStat] {
+		response, err := s.parser.parseReceiptResponse(s.retEnviNFe(cUF, outcome.cStat, "", nil), "")
+		response.RawRequest = rawRequest
+		return response, err
+	}
+
+	protNFe := s.protNFe(outcome)
+	if req.Async {
+		recibo := s.rememberLote(cUF, []string{protNFe}, outcome.chave)
+		raw := s.retEnviNFe(cUF, "103", recibo, nil)
+		response, err := s.parser.parseAuthorizationResponse(raw)
+		response.RawRequest = rawRequest
+		response.Status = "received"
+		response.Recibo = recibo
+		return response, err
+	}
+
+	response, err := s.parser.parseReceiptResponse(s.retEnviNFe(cUF, "104", "", []string{protNFe}), outcome.chave)
+	response.RawRequest = rawRequest
+	return response, err
+}
+
+func (s *simulator) AuthorizeLote(ctx context.Context, req LoteAuthorizationRequest) (AuthorizationResponse, error) {
+	if len(req.XMLs) == 0 || len(req.XMLs) > maxLoteNFe {
+		return AuthorizationResponse{}, fmt.Errorf("lote must have between 1 and %d NF-e", maxLoteNFe)
+	}
+	cUF, err := ufCode(req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	rawRequest := []byte(s.parser.buildAuthorizationEnvelope(req.XMLs, cUF, "0"))
+
+	protNFes := make([]string, 0, len(req.XMLs))
+	chaves := make([]string, 0, len(req.XMLs))
+	for _, content := range req.XMLs {
+		outcome := s.evaluate(content, cUF)
+		if outcome.fail {
+			return AuthorizationResponse{RawRequest: rawRequest}, fmt.Errorf("SOAP request failed: %w", ErrSimulatedUnavailable)
+		}
+		if loteCStats[outcome.cStat] {
+			response, err := s.parser.parseReceiptResponse(s.retEnviNFe(cUF, outcome.cStat, "", nil), "")
+			response.RawRequest = rawRequest
+			return response, err
+		}
+		protNFes = append(protNFes, s.protNFe(outcome))
+		chaves = append(chaves, outcome.chave)
+	}
+
+	recibo := s.rememberLote(cUF, protNFes, chaves...)
+	response, err := s.parser.parseAuthorizationResponse(s.retEnviNFe(cUF, "103", recibo, nil))
+	response.RawRequest = rawRequest
+	response.Status = "received"
+	response.Recibo = recibo
+	return response, err
+}
+
+func (s *simulator) QueryReceipt(ctx context.Context, req ReceiptQueryRequest) (AuthorizationResponse, error) {
+	cUF, err := ufCode(req.UF)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+
+	s.mu.Lock()
+	protNFes, found := s.lotes[req.Recibo]
+	s.mu.Unlock()
+
+	cStat := "104"
+	if !found {
+		cStat = "106"
+	}
+	raw := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope"><soap12:Body><nfeResultMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeRetAutorizacao4"><retConsReciNFe versao="4.00" xmlns="http://www.portalfiscal.inf.br/nfe"><tpAmb>2</tpAmb><verAplic>SIMULADOR</verAplic><nRec>%s</nRec><cStat>%s</cStat><xMotivo>%s</xMotivo><cUF>%s</cUF><dhRecbto>%s</dhRecbto>%s</retConsReciNFe></nfeResultMsg></soap12:Body></soap12:Envelope>`,
+		req.Recibo, cStat, simulatedMotivo(cStat), cUF, simulatedNow(), strings.Join(protNFes, ""))
+	return s.parser.parseReceiptResponse([]byte(raw), req.ChaveAcesso)
+}
+
+func (s *simulator) QueryStatus(ctx context.Context, uf, ambiente string) (AuthorizationResponse, error) {
+	cUF, err := ufCode(uf)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	raw := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope"><soap12:Body><nfeResultMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeStatusServico4"><retConsStatServ versao="4.00" xmlns="http://www.portalfiscal.inf.br/nfe"><tpAmb>2</tpAmb><verAplic>SIMULADOR</verAplic><cStat>107</cStat><xMotivo>%s</xMotivo><cUF>%s</cUF><dhRecbto>%s</dhRecbto><tMed>1</tMed></retConsStatServ></nfeResultMsg></soap12:Body></soap12:Envelope>`,
+		simulatedMotivo("107"), cUF, simulatedNow())
+	return s.parser.parseStatusResponse([]byte(raw))
+}
+
+func (s *simulator) QueryProtocol(ctx context.Context, req ProtocolQueryRequest) (AuthorizationResponse, error) {
+	s.mu.Lock()
+	protocol, found := s.protocols[req.ChaveAcesso]
+	var protNFe string
+	var canceled bool
+	if found {
+		protNFe, canceled = protocol.protNFe, protocol.canceled
+	}
+	s.mu.Unlock()
+
+	cStat := "217"
+	switch {
+	case canceled:
+		cStat = "101"
+	case found:
+		cStat = "100"
+	}
+	raw := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope"><soap12:Body><nfeResultMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeConsultaProtocolo4"><retConsSitNFe versao="4.00" xmlns="http://www.portalfiscal.inf.br/nfe"><tpAmb>2</tpAmb><verAplic>SIMULADOR</verAplic><cStat>%s</cStat><xMotivo>%s</xMotivo><cUF>%s</cUF><dhRecbto>%s</dhRecbto><chNFe>%s</chNFe>%s</retConsSitNFe></nfeResultMsg></soap12:Body></soap12:Envelope>`,
+		cStat, simulatedMotivo(cStat), cUFOf(req.ChaveAcesso), simulatedNow(), req.ChaveAcesso, protNFe)
+	return s.parser.parseProtocolQueryResponse([]byte(raw))
+}
+
+func (s *simulator) SendEvent(ctx context.Context, req EventRequest) (EventResponse, error) {
+	var evento struct {
+		InfEvento struct {
+			TpAmb      string `xml:"tpAmb"`
+			ChNFe      string `xml:"chNFe"`
+			TpEvento   string `xml:"tpEvento"`
+			NSeqEvento string `xml:"nSeqEvento"`
+			XJust      string `xml:"detEvento>xJust"`
+		} `xml:"infEvento"`
+	}
+	cStat := "135"
+	err := xml.Unmarshal(req.XML, &evento)
+	info := evento.InfEvento
+	switch {
+	case err != nil:
+		cStat = "215"
+	case info.TpAmb != "2":
+		cStat = "252"
+	default:
+		if magic := simulatedCStat(info.XJust); magic != "" {
+			cStat = magic
+		}
+	}
+
+	s.mu.Lock()
+	if protocol, found := s.protocols[info.ChNFe]; found && cStat == "135" && info.TpEvento == "110111" {
+		if protocol.canceled {
+			cStat = "573"
+		}
+		protocol.canceled = true
+	}
+	s.mu.Unlock()
+
+	nProt := ""
+	if cStat == "135" {
+		nProt = "<nProt>" + simulatedNumber("9"+cUFOf(info.ChNFe)+time.Now().Format("06"), info.ChNFe+info.TpEvento+info.NSeqEvento) + "</nProt>"
+	}
+	raw := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope"><soap12:Body><nfeResultMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeRecepcaoEvento4"><retEnvEvento versao="1.00" xmlns="http://www.portalfiscal.inf.br/nfe"><idLote>1</idLote><tpAmb>2</tpAmb><verAplic>SIMULADOR</verAplic><cOrgao>%[1]s</cOrgao><cStat>128</cStat><xMotivo>%[2]s</xMotivo><retEvento versao="1.00"><infEvento><tpAmb>2</tpAmb><verAplic>SIMULADOR</verAplic><cOrgao>%[1]s</cOrgao><cStat>%[3]s</cStat><xMotivo>%[4]s</xMotivo><chNFe>%[5]s</chNFe><tpEvento>%[6]s</tpEvento><nSeqEvento>%[7]s</nSeqEvento><dhRegEvento>%[8]s</dhRegEvento>%[9]s</infEvento></retEvento></retEnvEvento></nfeResultMsg></soap12:Body></soap12:Envelope>`,
+		cUFOf(info.ChNFe), simulatedMotivo("128"), cStat, simulatedMotivo(cStat), info.ChNFe, info.TpEvento, info.NSeqEvento, simulatedNow(), nProt)
+	return s.parser.parseEventResponse([]byte(raw))
+}
+
+func (s *simulator) Inutilize(ctx context.Context, req InutilizacaoRequest) (InutilizacaoResponse, error) {
+	var inut struct {
+		InfInut struct {
+			ID     string `xml:"Id,attr"`
+			TpAmb  string `xml:"tpAmb"`
+			CUF    string `xml:"cUF"`
+			Ano    string `xml:"ano"`
+			CNPJ   string `xml:"CNPJ"`
+			Serie  string `xml:"serie"`
+			NNFIni string `xml:"nNFIni"`
+			NNFFin string `xml:"nNFFin"`
+			XJust  string `xml:"xJust"`
+		} `xml:"infInut"`
+	}
+	cStat := "102"
+	err := xml.Unmarshal(req.XML, &inut)
+	info := inut.InfInut
+	switch {
+	case err != nil:
+		cStat = "215"
+	case info.TpAmb != "2":
+		cStat = "252"
+	default:
+		if magic := simulatedCStat(info.XJust); magic != "" {
+			cStat = magic
+		}
+	}
+
+	nProt := ""
+	if cStat == "102" {
+		nProt = "<nProt>" + simulatedNumber("9"+info.CUF+info.Ano, info.ID) + "</nProt>"
+	}
+	raw := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope"><soap12:Body><nfeResultMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeInutilizacao4"><retInutNFe versao="4.00" xmlns="http://www.portalfiscal.inf.br/nfe"><infInut><tpAmb>2</tpAmb><verAplic>SIMULADOR</verAplic><cStat>%s</cStat><xMotivo>%s</xMotivo><cUF>%s</cUF><ano>%s</ano><CNPJ>%s</CNPJ><mod>65</mod><serie>%s</serie><nNFIni>%s</nNFIni><nNFFin>%s</nNFFin><dhRecbto>%s</dhRecbto>%s</infInut></retInutNFe></nfeResultMsg></soap12:Body></soap12:Envelope>`,
+		cStat, simulatedMotivo(cStat), info.CUF, info.Ano, info.CNPJ, info.Serie, info.NNFIni, info.NNFFin, simulatedNow(), nProt)
+	return s.parser.parseInutilizacaoResponse([]byte(raw))
+}
+
+// DistributeDFe has no documents to distribute in the simulated ambiente
+func (s *simulator) DistributeDFe(ctx context.Context, req DistributionRequest) (DistributionResponse, error) {
+	raw := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope"><soap12:Body><nfeDistDFeInteresseResponse xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeDistribuicaoDFe"><nfeDistDFeInteresseResult><retDistDFeInt versao="1.01" xmlns="http://www.portalfiscal.inf.br/nfe"><tpAmb>2</tpAmb><verAplic>SIMULADOR</verAplic><cStat>137</cStat><xMotivo>%s</xMotivo><dhResp>%s</dhResp><ultNSU>%[3]s</ultNSU><maxNSU>%[3]s</maxNSU></retDistDFeInt></nfeDistDFeInteresseResult></nfeDistDFeInteresseResponse></soap12:Body></soap12:Envelope>`,
+		simulatedMotivo("137"), simulatedNow(), padNSU(req.UltNSU))
+	return s.parser.parseDistributionResponse([]byte(raw))
+}
+
+// evaluate checks a signed NFC-e as the authorizer would and picks its cStat: the validation
+// rejections first, then the duplicates and the magic values, else the authorization
+func (s *simulator) evaluate(content []byte, cUF string) simulatedOutcome {
+	var nfe simulatedNFe
+	if err := xml.Unmarshal(content, &nfe); err != nil {
+		return simulatedOutcome{cStat: "225"}
+	}
+	outcome := simulatedOutcome{chave: strings.TrimPrefix(nfe.InfNFe.ID, "NFe"), digVal: nfe.DigestValue}
+
+	switch {
+	case nfe.DigestValue == "":
+		outcome.cStat = "225" // Signature is mandatory in the schema
+	case nfe.InfNFe.Ide.TpAmb != "2":
+		outcome.cStat = "252"
+	case nfe.InfNFe.Ide.CUF != cUF:
+		outcome.cStat = "226"
+	case !isChaveAcesso(outcome.chave) || outcome.chave[:2] != cUF || outcome.chave[20:22] != "65":
+		outcome.cStat = "502"
+	}
+	if outcome.cStat != "" {
+		return outcome
+	}
+
+	s.mu.Lock()
+	_, duplicate := s.protocols[outcome.chave]
+	s.mu.Unlock()
+	if duplicate {
+		outcome.cStat = "204"
+		return outcome
+	}
+
+	outcome.cStat = "100"
+	for _, det := range nfe.InfNFe.Det {
+		description := strings.ToUpper(det.XProd)
+		switch {
+		case strings.Contains(description, simulatedUnavailable):
+			outcome.fail = true
+			return outcome
+		case strings.Contains(description, simulatedTimeout):
+			// SEFAZ authorized the NFC-e, but the answer never arrived
+			s.protNFe(outcome)
+			outcome.fail = true
+			return outcome
+		}
+		if magic := simulatedCStat(description); magic != "" {
+			outcome.cStat = magic
+			return outcome
+		}
+	}
+	if vNF, err := money.Parse(nfe.InfNFe.VNF); err == nil {
+		if magic, found := simulatedTotals[vNF.String()]; found {
+			outcome.cStat = magic
+		}
+	}
+	return outcome
+}
+
+// protNFe builds the protNFe of an outcome; authorizations and denials get a protocol,
+// remembered for the protocol queries and duplicates
+func (s *simulator) protNFe(outcome simulatedOutcome) string {
+	nProt := ""
+	if outcome.cStat == "100" || outcome.cStat == "301" {
+		nProt = "<nProt>" + simulatedNumber("9"+outcome.chave[:4], outcome.chave) + "</nProt>"
+	}
+	protNFe := fmt.Sprintf(`<protNFe versao="4.00"><infProt><tpAmb>2</tpAmb><verAplic>SIMULADOR</verAplic><chNFe>%s</chNFe><dhRecbto>%s</dhRecbto>%s<digVal>%s</digVal><cStat>%s</cStat><xMotivo>%s</xMotivo></infProt></protNFe>`,
+		outcome.chave, simulatedNow(), nProt, outcome.digVal, outcome.cStat, simulatedMotivo(outcome.cStat))
+	if outcome.cStat == "100" {
+		s.rememberProtocol(outcome.chave, protNFe)
+	}
+	return protNFe
+}
+
+// retEnviNFe builds the answer of the authorization service: the lote status, the receipt of
+// an asynchronous lote or the protNFe of a synchronous one
+func (s *simulator) retEnviNFe(cUF, cStat, recibo string, protNFes []string) []byte {
+	infRec := ""
+	if recibo != "" {
+		infRec = "<infRec><nRec>" + recibo + "</nRec><tMed>1</tMed></infRec>"
+	}
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope"><soap12:Body><nfeResultMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeAutorizacao4"><retEnviNFe versao="4.00" xmlns="http://www.portalfiscal.inf.br/nfe"><tpAmb>2</tpAmb><verAplic>SIMULADOR</verAplic><cStat>%s</cStat><xMotivo>%s</xMotivo><cUF>%s</cUF><dhRecbto>%s</dhRecbto>%s%s</retEnviNFe></nfeResultMsg></soap12:Body></soap12:Envelope>`,
+		cStat, simulatedMotivo(cStat), cUF, simulatedNow(), infRec, strings.Join(protNFes, "")))
+}
+
+// rememberProtocol keeps the authorization of an NFC-e, forgetting the oldest over the limit
+func (s *simulator) rememberProtocol(chave, protNFe string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.protocols[chave]; found {
+		return
+	}
+	s.protocols[chave] = &simulatedProtocol{protNFe: protNFe}
+	s.chaves = append(s.chaves, chave)
+	if len(s.chaves) > maxSimulatedEntries {
+		delete(s.protocols, s.chaves[0])
+		s.chaves = s.chaves[1:]
+	}
+}
+
+// rememberLote keeps the protNFe of an asynchronous lote under a new receipt
+func (s *simulator) rememberLote(cUF string, protNFes []string, chaves ...string) string {
+	recibo := simulatedNumber(cUF+"9"+time.Now().Format("06"), strings.Join(chaves, ""))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.lotes[recibo]; !found {
+		s.recibos = append(s.recibos, recibo)
+		if len(s.recibos) > maxSimulatedEntries {
+			delete(s.lotes, s.recibos[0])
+			s.recibos = s.recibos[1:]
+		}
+	}
+	s.lotes[recibo] = protNFes
+	return recibo
+}
+
+// simulatedCStat returns the cStat asked by a "SIMULAR CSTAT nnn" text, empty when absent
+func simulatedCStat(text string) string {
+	if match := simulatedCStatPattern.FindStringSubmatch(strings.ToUpper(text)); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// simulatedMotivo returns the xMotivo of a cStat, a generic one for the unlisted
+func simulatedMotivo(cStat string) string {
+	if motivo, found := simulatedMotivos[cStat]; found {
+		return motivo
+	}
+	return "Rejeição: cStat " + cStat + " simulado"
+}
+
+// simulatedNumber derives a 15-digit protocol or receipt number from its prefix, the type of
+// authorizer, cUF and year, and a seed, so the same NFC-e always gets the same number
+func simulatedNumber(prefix, seed string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(seed))
+	return (prefix + fmt.Sprintf("%020d", hash.Sum64()))[:15]
+}
+
+// simulatedNow formats the receipt time of the simulated answers
+func simulatedNow() string {
+	return time.Now().Format("2006-01-02T15:04:05-07:00")
+}
+
+// isChaveAcesso reports whether s has the 44 digits of a chave de acesso
+func isChaveAcesso(s string) bool {
+	if len(s) != 44 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// cUFOf returns the cUF of a chave de acesso, empty when too short
+func cUFOf(chave string) string {
+	if len(chave) < 2 {
+		return ""
+	}
+	return chave[:2]
+}
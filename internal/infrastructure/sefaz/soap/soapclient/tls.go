@@ -0,0 +1,87 @@
+package soapclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/signer"
+)
+
+// ClientCertificate identifies the company A1 certificate presented to SEFAZ (mutual TLS)
+type ClientCertificate struct {
+	CompanyID string
+	Key       signer.KeyMaterial
+}
+
+// cachedClient is an HTTP client bound to a parsed company certificate
+type cachedClient struct {
+	fingerprint string // Detects certificate replacement for the same company
+	httpClient  *http.Client
+}
+
+// certificateCache keeps one mutual TLS client per company so the PFX is parsed once
+type certificateCache struct {
+	mu      sync.RWMutex
+	clients map[string]cachedClient
+}
+
+func newCertificateCache() *certificateCache {
+	return &certificateCache{clients: make(map[string]cachedClient)}
+}
+
+// clientFor returns the HTTP client presenting the company certificate, building it on first use
+func (t *httpTransport) clientFor(cert *ClientCertificate) (*http.Client, error) {
+	if cert == nil {
+		return t.httpClient, nil
+	}
+
+	fingerprint := certificateFingerprint(cert.Key)
+
+	t.certs.mu.RLock()
+	cached, ok := t.certs.clients[cert.CompanyID]
+	t.certs.mu.RUnlock()
+	if ok && cached.fingerprint == fingerprint {
+		return cached.httpClient, nil
+	}
+
+	tlsCert, err := signer.TLSCertificate(cert.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate for company %s: %w", cert.CompanyID, err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		Certificates:  []tls.Certificate{tlsCert},
+		MinVersion:    tls.VersionTLS12,
+		Renegotiation: tls.RenegotiateOnceAsClient, // Some SEFAZ servers request the certificate via renegotiation
+	}
+
+	client := &http.Client{
+		Timeout:   t.timeout,
+		Transport: transport,
+	}
+
+	t.certs.mu.Lock()
+	if previous, ok := t.certs.clients[cert.CompanyID]; ok {
+		previous.httpClient.CloseIdleConnections()
+	}
+	t.certs.clients[cert.CompanyID] = cachedClient{fingerprint: fingerprint, httpClient: client}
+	t.certs.mu.Unlock()
+
+	return client, nil
+}
+
+// certificateFingerprint hashes the PFX bundle and password (or the KMS key reference)
+// without keeping them in the cache key
+func certificateFingerprint(key signer.KeyMaterial) string {
+	material := key.PFXBase64 + "\x00" + key.Password
+	if key.KMS != nil {
+		material = key.KMS.Endpoint + "\x00" + key.KMS.KeyID + "\x00" + key.KMS.Token + "\x00" + string(key.KMS.CertificatePEM)
+	}
+	sum := sha256.Sum256([]byte(material))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,69 @@
+package soapclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Transport carries the SOAP envelopes to SEFAZ
+type Transport interface {
+	// Send posts the envelope to the endpoint, presenting the company certificate when one is
+	// given, and returns the body of the answer
+	Send(ctx context.Context, endpoint string, envelope []byte, cert *ClientCertificate) ([]byte, error)
+}
+
+// httpTransport sends the envelopes over HTTPS, with mutual TLS per company
+type httpTransport struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	certs      *certificateCache // Mutual TLS clients per company
+}
+
+// NewHTTPTransport creates the HTTPS transport to SEFAZ with the timeout of each request
+func NewHTTPTransport(timeout time.Duration) Transport {
+	return &httpTransport{
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+		timeout: timeout,
+		certs:   newCertificateCache(),
+	}
+}
+
+// Send posts the envelope, presenting the company certificate when one is given (SEFAZ
+// production requires mutual TLS)
+func (t *httpTransport) Send(ctx context.Context, endpoint string, envelope []byte, cert *ClientCertificate) ([]byte, error) {
+	httpClient, err := t.clientFor(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(envelope))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", "")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP request failed with status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return body, nil
+}
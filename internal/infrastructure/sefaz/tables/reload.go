@@ -0,0 +1,62 @@
+package tables
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// Reloader loads the tables of SEFAZ_TABLES_FILE and installs them, at startup and again on
+// SIGHUP or the admin endpoint. A file that fails validation keeps the tables in use.
+type Reloader struct {
+	path   string
+	logger logger.Logger
+	mu     sync.Mutex
+}
+
+// NewReloader creates a reloader of the overrides file at path; an empty path reloads the
+// shipped tables
+func NewReloader(path string, l logger.Logger) *Reloader {
+	return &Reloader{path: path, logger: l}
+}
+
+// Reload validates the file and installs the resulting tables
+func (r *Reloader) Reload() (*Tables, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tables, err := Load(r.path)
+	if err != nil {
+		r.logger.Error("Failed to reload SEFAZ tables, keeping the tables in use",
+			logger.Field{Key: "path", Value: r.path},
+			logger.Err(err))
+		return nil, err
+	}
+
+	Install(tables)
+	r.logger.Info("SEFAZ tables loaded",
+		logger.Field{Key: "version", Value: tables.Version},
+		logger.Field{Key: "file_version", Value: tables.FileVersion},
+		logger.Field{Key: "path", Value: tables.Path})
+	return tables, nil
+}
+
+// WatchSignals reloads the tables on every SIGHUP until ctx is done
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.Reload() // Failures are logged and keep the tables in use
+		}
+	}
+}
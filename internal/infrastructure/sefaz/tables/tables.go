@@ -0,0 +1,375 @@
+package tables
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultFile is the table set shipped with the binary; a SEFAZ_TABLES_FILE overrides its
+// entries without a release
+//
+//go:embed tables.json
+var defaultFile []byte
+
+// SEFAZ web services of the NF-e/NFC-e 4.00 layout
+const (
+	ServiceAutorizacao       = "NFeAutorizacao4"
+	ServiceRetAutorizacao    = "NFeRetAutorizacao4"
+	ServiceConsultaProtocolo = "NFeConsultaProtocolo4"
+	ServiceStatusServico     = "NFeStatusServico4"
+	ServiceRecepcaoEvento    = "NFeRecepcaoEvento4"
+	ServiceInutilizacao      = "NFeInutilizacao4"
+	ServiceDistribuicaoDFe   = "NFeDistribuicaoDFe" // Ambiente Nacional only
+)
+
+// Environments of the tables
+const (
+	EnvProducao    = "prod"
+	EnvHomologacao = "hom"
+)
+
+// Authorizers every table set must have, looked up by name rather than by UF
+const (
+	AuthorizerSVCAN            = "SVC-AN"
+	AuthorizerSVCRS            = "SVC-RS"
+	AuthorizerAmbienteNacional = "AN"
+)
+
+// ErrInvalidTables is returned when a table file does not follow the schema
+var ErrInvalidTables = errors.New("invalid SEFAZ tables")
+
+// ProblemsError lists the problems found in a table file
+type ProblemsError struct {
+	Problems []string
+}
+
+// Error implements the error interface
+func (e *ProblemsError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidTables.Error(), strings.Join(e.Problems, "; "))
+}
+
+// Is allows errors.Is(err, ErrInvalidTables)
+func (e *ProblemsError) Is(target error) bool {
+	return target == ErrInvalidTables
+}
+
+// ProblemsOf returns the problems carried by err, if any
+func ProblemsOf(err error) []string {
+	var problemsErr *ProblemsError
+	if errors.As(err, &problemsErr) {
+		return problemsErr.Problems
+	}
+	return nil
+}
+
+// services are the web services an authorizer may publish
+var services = map[string]bool{
+	ServiceAutorizacao:       true,
+	ServiceRetAutorizacao:    true,
+	ServiceConsultaProtocolo: true,
+	ServiceStatusServico:     true,
+	ServiceRecepcaoEvento:    true,
+	ServiceInutilizacao:      true,
+	ServiceDistribuicaoDFe:   true,
+}
+
+// ufs are the states every table must cover
+var ufs = []string{
+	"AC", "AL", "AM", "AP", "BA", "CE", "DF", "ES", "GO", "MA", "MG", "MS", "MT", "PA",
+	"PB", "PE", "PI", "PR", "RJ", "RN", "RO", "RR", "RS", "SC", "SE", "SP", "TO",
+}
+
+// Authorizer maps environment ("prod"/"hom") -> service -> URL
+type Authorizer map[string]map[string]string
+
+// URLs maps environment ("prod"/"hom") -> URL
+type URLs map[string]string
+
+// File is the layout of tables.json and of the SEFAZ_TABLES_FILE overrides, e.g.
+// {"version": "2026-11-01", "autorizadores": {"SVRS-NFCe": {"hom": {"NFeAutorizacao4": "https://..."}}}}.
+// The UFs name the authorizer of their NFC-e and NF-e; the QR Code and consulta URLs are
+// those printed on the DANFE NFC-e.
+type File struct {
+	Version       string                `json:"version"`
+	Autorizadores map[string]Authorizer `json:"autorizadores,omitempty"`
+	NFCe          map[string]string     `json:"nfce,omitempty"`
+	NFe           map[string]string     `json:"nfe,omitempty"`
+	QRCode        map[string]URLs       `json:"qrcode,omitempty"`
+	Consulta      map[string]URLs       `json:"consulta,omitempty"`
+}
+
+// Tables is a validated table set: the shipped file merged with the overrides
+type Tables struct {
+	File
+	Path        string    // Overrides file, empty when only the shipped tables are used
+	FileVersion string    // Version declared by the overrides file
+	LoadedAt    time.Time // When the set was loaded
+
+	endpoints map[string]map[string]map[string]string
+}
+
+// active is the table set in use by this process, swapped whole on reload
+var active atomic.Pointer[Tables]
+
+// defaults parses the shipped tables once
+var defaults = sync.OnceValue(func() *Tables {
+	file, err := parse(defaultFile)
+	if err == nil {
+		err = file.validate()
+	}
+	if err != nil {
+		panic(fmt.Sprintf("shipped SEFAZ tables: %v", err))
+	}
+	return newTables(*file, "", "")
+})
+
+// Active returns the table set in use, the shipped one until Install is called
+func Active() *Tables {
+	if tables := active.Load(); tables != nil {
+		return tables
+	}
+	return defaults()
+}
+
+// Install makes the table set the one in use by the SEFAZ client and the QR Code generator
+func Install(tables *Tables) {
+	active.Store(tables)
+}
+
+// Load returns the shipped tables merged with the overrides of the file at path, validated
+// as a whole. An empty path returns the shipped tables.
+func Load(path string) (*Tables, error) {
+	base := defaults()
+	if path == "" {
+		return newTables(base.File, "", ""), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SEFAZ tables file: %w", err)
+	}
+	overrides, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := base.File.merge(overrides)
+	if err := merged.validate(); err != nil {
+		return nil, err
+	}
+	return newTables(merged, path, overrides.Version), nil
+}
+
+// newTables resolves the endpoint registry of a validated file
+func newTables(file File, path, fileVersion string) *Tables {
+	endpoints := make(map[string]map[string]map[string]string, 2*len(ufs)+3)
+	for uf, name := range file.NFCe {
+		endpoints[uf] = file.Autorizadores[name]
+	}
+	for uf, name := range file.NFe {
+		endpoints[uf+"/55"] = file.Autorizadores[name]
+	}
+	for _, name := range []string{AuthorizerSVCAN, AuthorizerSVCRS, AuthorizerAmbienteNacional} {
+		endpoints[name] = file.Autorizadores[name]
+	}
+	return &Tables{File: file, Path: path, FileVersion: fileVersion, LoadedAt: time.Now(), endpoints: endpoints}
+}
+
+// Endpoints returns the registry of the SEFAZ client: UF (NFC-e), "<UF>/55" (NF-e),
+// SVC-AN, SVC-RS and AN -> environment -> service -> URL. It is shared: callers must not
+// modify it.
+func (t *Tables) Endpoints() map[string]map[string]map[string]string {
+	return t.endpoints
+}
+
+// QRCodeURL returns the base URL of the QR Code of the UF in the environment
+func (t *Tables) QRCodeURL(uf, env string) string {
+	return t.QRCode[uf][env]
+}
+
+// ConsultaURL returns the urlChave of the UF in the environment
+func (t *Tables) ConsultaURL(uf, env string) string {
+	return t.Consulta[uf][env]
+}
+
+// parse decodes a table file, rejecting the fields the schema does not have
+func parse(data []byte) (*File, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file File
+	if err := decoder.Decode(&file); err != nil {
+		return nil, &ProblemsError{Problems: []string{err.Error()}}
+	}
+	if strings.TrimSpace(file.Version) == "" {
+		return nil, &ProblemsError{Problems: []string{"version is required"}}
+	}
+	return &file, nil
+}
+
+// merge returns a copy of the file with the entries of overrides; the services of an
+// authorizer environment are merged one by one, the other entries replaced
+func (f File) merge(overrides *File) File {
+	merged := File{
+		Version:       f.Version,
+		Autorizadores: make(map[string]Authorizer, len(f.Autorizadores)),
+		NFCe:          copyMap(f.NFCe, overrides.NFCe),
+		NFe:           copyMap(f.NFe, overrides.NFe),
+		QRCode:        mergeURLs(f.QRCode, overrides.QRCode),
+		Consulta:      mergeURLs(f.Consulta, overrides.Consulta),
+	}
+	for name, authorizer := range f.Autorizadores {
+		merged.Autorizadores[name] = authorizer
+	}
+	for name, envs := range overrides.Autorizadores {
+		authorizer := Authorizer{}
+		for env, services := range merged.Autorizadores[name] {
+			authorizer[env] = services
+		}
+		for env, services := range envs {
+			authorizer[env] = copyMap(authorizer[env], services)
+		}
+		merged.Autorizadores[name] = authorizer
+	}
+	return merged
+}
+
+// validate checks the environments, services and URLs of the tables and that every UF has
+// its authorizers, QR Code and consulta
+func (f *File) validate() error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for name, envs := range f.Autorizadores {
+		for env, urls := range envs {
+			if env != EnvProducao && env != EnvHomologacao {
+				fail("autorizadores.%s: invalid environment %q (use prod or hom)", name, env)
+				continue
+			}
+			for service, endpoint := range urls {
+				if !services[service] {
+					fail("autorizadores.%s.%s: unknown service %q", name, env, service)
+				} else if !absoluteURL(endpoint, "https") {
+					fail("autorizadores.%s.%s.%s: %q is not an https URL", name, env, service, endpoint)
+				}
+			}
+		}
+	}
+	for _, name := range []string{AuthorizerSVCAN, AuthorizerSVCRS, AuthorizerAmbienteNacional} {
+		if f.Autorizadores[name] == nil {
+			fail("autorizadores.%s is required", name)
+		}
+	}
+
+	for _, uf := range ufs {
+		for section, table := range map[string]map[string]string{"nfce": f.NFCe, "nfe": f.NFe} {
+			if name := table[uf]; name == "" {
+				fail("%s.%s: authorizer is required", section, uf)
+			} else if f.Autorizadores[name] == nil {
+				fail("%s.%s: unknown authorizer %q", section, uf, name)
+			}
+		}
+		for _, env := range []string{EnvProducao, EnvHomologacao} {
+			if !absoluteURL(f.QRCode[uf][env], "http", "https") {
+				fail("qrcode.%s.%s: %q is not an http(s) URL", uf, env, f.QRCode[uf][env])
+			}
+			// The urlChave is printed as published by the UF, often without the scheme
+			if !consultaURL(f.Consulta[uf][env]) {
+				fail("consulta.%s.%s: %q is not a URL", uf, env, f.Consulta[uf][env])
+			}
+		}
+	}
+	for section, table := range map[string]map[string]string{"nfce": f.NFCe, "nfe": f.NFe} {
+		for uf := range table {
+			if !knownUF(uf) {
+				fail("%s: invalid UF %q", section, uf)
+			}
+		}
+	}
+	for section, table := range map[string]map[string]URLs{"qrcode": f.QRCode, "consulta": f.Consulta} {
+		for uf, envs := range table {
+			if !knownUF(uf) {
+				fail("%s: invalid UF %q", section, uf)
+			}
+			for env := range envs {
+				if env != EnvProducao && env != EnvHomologacao {
+					fail("%s.%s: invalid environment %q (use prod or hom)", section, uf, env)
+				}
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return &ProblemsError{Problems: problems}
+}
+
+// absoluteURL reports whether raw is an absolute URL with one of the schemes
+func absoluteURL(raw string, schemes ...string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// consultaURL reports whether raw is an http(s) URL, with or without the scheme
+func consultaURL(raw string) bool {
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	return absoluteURL(raw, "http", "https")
+}
+
+// knownUF reports whether uf is one of the 27 states
+func knownUF(uf string) bool {
+	for _, known := range ufs {
+		if uf == known {
+			return true
+		}
+	}
+	return false
+}
+
+// copyMap returns base with the entries of overrides, leaving both untouched
+func copyMap(base, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
+
+// mergeURLs returns base with the environments of overrides, leaving both untouched
+func mergeURLs(base, overrides map[string]URLs) map[string]URLs {
+	merged := make(map[string]URLs, len(base))
+	for uf, envs := range base {
+		merged[uf] = envs
+	}
+	for uf, envs := range overrides {
+		merged[uf] = copyMap(merged[uf], envs)
+	}
+	return merged
+}
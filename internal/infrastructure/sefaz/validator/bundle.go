@@ -0,0 +1,94 @@
+package validator
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// bundleFS holds the official schema packages shipped with the binary, one directory per
+// package, e.g. bundle/PL_009_V4/nfe_v4.00.xsd
+//
+//go:embed bundle
+var bundleFS embed.FS
+
+// Sources of installed schemas
+const (
+	SchemaSourceBundle   = "bundle"
+	SchemaSourceDownload = "download"
+)
+
+// ErrSchemasUnavailable is returned when the schemas directory is not valid, the package is
+// not bundled and network download was not requested
+var ErrSchemasUnavailable = errors.New("SEFAZ schemas unavailable")
+
+// bundledSchema reads a schema file of a bundled package
+func bundledSchema(pack string, file ManifestFile) ([]byte, error) {
+	return bundleFS.ReadFile("bundle/" + pack + "/" + file.Name)
+}
+
+// bundled reports whether every file of the manifest is embedded with the pinned checksum
+func bundled(manifest *Manifest) bool {
+	for _, file := range manifest.Files {
+		content, err := bundledSchema(manifest.Package, file)
+		if err != nil || checksum(content) != file.SHA256 {
+			return false
+		}
+	}
+	return true
+}
+
+// BundledPackages lists the schema packages embedded in the binary
+func BundledPackages() ([]string, error) {
+	entries, err := fs.ReadDir(bundleFS, "bundle")
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			packages = append(packages, entry.Name())
+		}
+	}
+	return packages, nil
+}
+
+// InstallBundledSchemas installs the embedded schemas of a package into the schemas
+// directory, with the same checksum verification and atomic swap of a download
+func (v *xmlValidator) InstallBundledSchemas(version string) error {
+	manifest, err := LoadManifest(version)
+	if err != nil {
+		return err
+	}
+	if !bundled(manifest) {
+		return fmt.Errorf("%w: schema package %s is not bundled", ErrSchemasUnavailable, manifest.Package)
+	}
+
+	return v.updateSchemas(version, SchemaSourceBundle, func(file ManifestFile) ([]byte, error) {
+		return bundledSchema(manifest.Package, file)
+	})
+}
+
+// EnsureSchemas makes the schemas of a package available offline: valid schemas in the
+// directory are kept, otherwise the bundled package is installed. The SEFAZ portal is only
+// used when allowDownload is set, since it often blocks automated downloads.
+func (v *xmlValidator) EnsureSchemas(ctx context.Context, version string, allowDownload bool) error {
+	status, err := v.SchemaStatus(version)
+	if err != nil {
+		return err
+	}
+	if status.Valid {
+		return nil
+	}
+
+	if status.Bundled {
+		return v.InstallBundledSchemas(version)
+	}
+	if allowDownload {
+		return v.DownloadSEFAZSchemas(ctx, version)
+	}
+	return fmt.Errorf("%w: schemas of %s in %s do not match the manifest and the package is not bundled", ErrSchemasUnavailable, status.Package, status.Dir)
+}
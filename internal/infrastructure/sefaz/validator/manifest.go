@@ -0,0 +1,389 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+	xsdvalidate "github.com/terminalstatic/go-xsd-validate"
+)
+
+//go:embed manifests/*.json
+var manifestsFS embed.FS
+
+// lockFileName records the manifest installed in the schemas directory
+const lockFileName = "schemas.lock.json"
+
+// maxSchemaSize bounds a single XSD download
+const maxSchemaSize = 5 << 20
+
+// reloadCheckInterval throttles the detection of schema swaps made by another process
+const reloadCheckInterval = 30 * time.Second
+
+// xsdNamespace is the namespace of the root xs:schema element of every XSD
+const xsdNamespace = "http://www.w3.org/2001/XMLSchema"
+
+// sha256Pattern matches a hex encoded SHA-256 checksum
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ErrSchemaUpdateInProgress is returned when a schema update is already running
+var ErrSchemaUpdateInProgress = errors.New("schema update already in progress")
+
+// ErrManifestNotPinned is returned when installing a package whose manifest lacks the
+// checksum of some file
+var ErrManifestNotPinned = errors.New("schema manifest is not pinned")
+
+// ErrNotSchema is returned when a schema file is not an XSD, e.g. an HTML error page served
+// in place of the download
+var ErrNotSchema = errors.New("file is not an XSD schema")
+
+// Manifest pins the files and SHA-256 checksums of a SEFAZ schema package version
+type Manifest struct {
+	Version string         `json:"version"`
+	Package string         `json:"package"`
+	Files   []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a pinned schema file
+type ManifestFile struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// InstalledSchemas is the lock file written with each schema swap
+type InstalledSchemas struct {
+	Version     string         `json:"version"`
+	Package     string         `json:"package"`
+	Source      string         `json:"source,omitempty"` // bundle or download
+	InstalledAt time.Time      `json:"installed_at"`
+	Files       []ManifestFile `json:"files"`
+}
+
+// SchemaUpdate is the outcome of the last schema update run
+type SchemaUpdate struct {
+	Version    string     `json:"version"`
+	Source     string     `json:"source"` // bundle or download
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// SchemaFileStatus compares a local schema file with the pinned manifest
+type SchemaFileStatus struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected_sha256"`
+	Actual   string `json:"actual_sha256,omitempty"`
+	Valid    bool   `json:"valid"`
+}
+
+// SchemaStatus describes the schemas directory against the pinned package
+type SchemaStatus struct {
+	Version    string             `json:"version"`
+	Package    string             `json:"package"`
+	Dir        string             `json:"dir"`
+	Valid      bool               `json:"valid"`
+	Pinned     bool               `json:"pinned"` // Every file of the manifest has a checksum
+	Installed  *InstalledSchemas  `json:"installed,omitempty"`
+	Bundled    bool               `json:"bundled"` // The package is embedded in the binary
+	Files      []SchemaFileStatus `json:"files"`
+	Updating   bool               `json:"updating"`
+	LastUpdate *SchemaUpdate      `json:"last_update,omitempty"`
+}
+
+// CheckPinned verifies that every file of the manifest has a SHA-256 checksum
+func (m *Manifest) CheckPinned() error {
+	for _, file := range m.Files {
+		if !sha256Pattern.MatchString(file.SHA256) {
+			return fmt.Errorf("%w: %s has no SHA-256 for %s; import the official package with go run ./scripts/schemas -package %s -from <dir> -pin",
+				ErrManifestNotPinned, m.Package, file.Name, m.Package)
+		}
+	}
+	return nil
+}
+
+// CheckSchema verifies that content is an XSD, i.e. its root element is xs:schema
+func CheckSchema(content []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("%w: no root element (%v)", ErrNotSchema, err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Space != xsdNamespace || start.Name.Local != "schema" {
+				return fmt.Errorf("%w: root element is %s", ErrNotSchema, start.Name.Local)
+			}
+			return nil
+		}
+	}
+}
+
+// defaultPackages is the schema package used for each layout version when no package is pinned
+var defaultPackages = map[string]string{
+	"4.00": "PL_009_V4",
+}
+
+// LoadManifest returns the pinned manifest of a schema package, e.g. PL_009_V4, or of the
+// default package of a layout version, e.g. 4.00
+func LoadManifest(pack string) (*Manifest, error) {
+	name := pack
+	if defaultPackage, ok := defaultPackages[pack]; ok {
+		name = defaultPackage
+	}
+
+	data, err := manifestsFS.ReadFile("manifests/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no pinned schema manifest for %s", pack)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid schema manifest for %s: %w", pack, err)
+	}
+	return &manifest, nil
+}
+
+// DownloadSEFAZSchemas downloads the pinned SEFAZ schemas over HTTPS, verifies
+// their SHA-256 checksums and atomically swaps the schemas directory. The
+// current schemas are kept untouched if any file fails.
+func (v *xmlValidator) DownloadSEFAZSchemas(ctx context.Context, version string) error {
+	return v.updateSchemas(version, SchemaSourceDownload, func(file ManifestFile) ([]byte, error) {
+		return v.downloadSchema(ctx, file)
+	})
+}
+
+// updateSchemas installs the schemas of a package read by fetch, recording the run as the
+// last update
+func (v *xmlValidator) updateSchemas(version, source string, fetch func(ManifestFile) ([]byte, error)) error {
+	if !v.updateMu.TryLock() {
+		return ErrSchemaUpdateInProgress
+	}
+	defer v.updateMu.Unlock()
+
+	update := &SchemaUpdate{Version: version, Source: source, StartedAt: time.Now()}
+	v.setLastUpdate(update)
+
+	err := v.installSchemas(version, source, fetch)
+
+	finished := time.Now()
+	result := *update
+	result.FinishedAt = &finished
+	if err != nil {
+		result.Error = err.Error()
+	}
+	v.setLastUpdate(&result)
+
+	return err
+}
+
+// installSchemas writes the verified manifest files into a staging directory and swaps it in
+func (v *xmlValidator) installSchemas(version, source string, fetch func(ManifestFile) ([]byte, error)) error {
+	manifest, err := LoadManifest(version)
+	if err != nil {
+		return err
+	}
+	if err := manifest.CheckPinned(); err != nil {
+		return err
+	}
+
+	dir := filepath.Clean(v.schemasDir)
+	staging, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+"-staging-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging) // No-op once the staging directory was swapped in
+
+	for _, file := range manifest.Files {
+		content, err := fetch(file)
+		if err != nil {
+			return fmt.Errorf("failed to %s schema %s: %w", source, file.Name, err)
+		}
+		if err := CheckSchema(content); err != nil {
+			return fmt.Errorf("schema %s: %w", file.Name, err)
+		}
+		if sum := checksum(content); sum != file.SHA256 {
+			return fmt.Errorf("checksum mismatch for schema %s: expected %s, got %s", file.Name, file.SHA256, sum)
+		}
+		if err := os.WriteFile(filepath.Join(staging, file.Name), content, 0o644); err != nil {
+			return fmt.Errorf("failed to write schema file: %w", err)
+		}
+	}
+
+	lock, err := json.MarshalIndent(InstalledSchemas{
+		Version:     manifest.Version,
+		Package:     manifest.Package,
+		Source:      source,
+		InstalledAt: time.Now(),
+		Files:       manifest.Files,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema lock file: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, lockFileName), lock, 0o644); err != nil {
+		return fmt.Errorf("failed to write schema lock file: %w", err)
+	}
+	if err := os.Chmod(staging, 0o755); err != nil {
+		return fmt.Errorf("failed to set staging directory permissions: %w", err)
+	}
+
+	// Swap the directories while no schema is being loaded
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	previous := fmt.Sprintf("%s.previous-%d", dir, time.Now().UnixNano())
+	if err := os.Rename(dir, previous); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to move current schemas aside: %w", err)
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		if rollbackErr := os.Rename(previous, dir); rollbackErr != nil {
+			return fmt.Errorf("failed to swap schemas directory: %w (rollback failed: %v)", err, rollbackErr)
+		}
+		return fmt.Errorf("failed to swap schemas directory: %w", err)
+	}
+	if err := os.RemoveAll(previous); err != nil {
+		v.logger.Warn("Failed to remove previous schemas directory",
+			logger.Field{Key: "dir", Value: previous},
+			logger.Err(err))
+	}
+
+	// Clear cache to force reload of updated schemas
+	v.schemas = make(map[string]*xsdvalidate.XsdHandler)
+	v.lockModTime = lockModTime(dir)
+
+	return nil
+}
+
+// downloadSchema downloads a single schema file over HTTPS
+func (v *xmlValidator) downloadSchema(ctx context.Context, file ManifestFile) ([]byte, error) {
+	u, err := url.Parse(file.URL)
+	if err != nil || u.Scheme != "https" {
+		return nil, fmt.Errorf("schema URL must use https: %s", file.URL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", file.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download schema: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error %d downloading schema", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxSchemaSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	if len(content) > maxSchemaSize {
+		return nil, fmt.Errorf("schema exceeds %d bytes", maxSchemaSize)
+	}
+
+	return content, nil
+}
+
+// SchemaStatus verifies the local schema files against the pinned manifest
+func (v *xmlValidator) SchemaStatus(version string) (*SchemaStatus, error) {
+	manifest, err := LoadManifest(version)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	status := &SchemaStatus{
+		Version:    manifest.Version,
+		Package:    manifest.Package,
+		Dir:        v.schemasDir,
+		Valid:      manifest.CheckPinned() == nil,
+		Pinned:     manifest.CheckPinned() == nil,
+		Bundled:    bundled(manifest),
+		Updating:   !v.updateMu.TryLock(),
+		LastUpdate: v.lastUpdate,
+	}
+	if !status.Updating {
+		v.updateMu.Unlock()
+	}
+
+	if data, err := os.ReadFile(filepath.Join(v.schemasDir, lockFileName)); err == nil {
+		var installed InstalledSchemas
+		if err := json.Unmarshal(data, &installed); err == nil {
+			status.Installed = &installed
+		}
+	}
+
+	for _, file := range manifest.Files {
+		fileStatus := SchemaFileStatus{Name: file.Name, Expected: file.SHA256}
+		if content, err := os.ReadFile(filepath.Join(v.schemasDir, file.Name)); err == nil {
+			fileStatus.Actual = checksum(content)
+			fileStatus.Valid = fileStatus.Actual == file.SHA256
+		}
+		if !fileStatus.Valid {
+			status.Valid = false
+		}
+		status.Files = append(status.Files, fileStatus)
+	}
+
+	return status, nil
+}
+
+// reloadIfSwapped clears the schema cache when another process swapped the schemas directory
+func (v *xmlValidator) reloadIfSwapped() {
+	v.mu.RLock()
+	due := time.Since(v.lastReloadCheck) >= reloadCheckInterval
+	v.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.lastReloadCheck = time.Now()
+	if modTime := lockModTime(v.schemasDir); !modTime.Equal(v.lockModTime) {
+		v.schemas = make(map[string]*xsdvalidate.XsdHandler)
+		v.lockModTime = modTime
+	}
+}
+
+// setLastUpdate records the last schema update run
+func (v *xmlValidator) setLastUpdate(update *SchemaUpdate) {
+	v.mu.Lock()
+	v.lastUpdate = update
+	v.mu.Unlock()
+}
+
+// lockModTime returns the modification time of the lock file, zero when missing
+func lockModTime(dir string) time.Time {
+	info, err := os.Stat(filepath.Join(dir, lockFileName))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// checksum returns the hex encoded SHA-256 of content
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,300 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	xsdvalidate "github.com/terminalstatic/go-xsd-validate"
+)
+
+// maxSummaryViolations caps the violations listed in the error message; the full list stays
+// in SchemaError.Violations
+const maxSummaryViolations = 5
+
+// SchemaViolation is one XSD error of a validated document
+type SchemaViolation struct {
+	Path    string `json:"path,omitempty"`  // Element location, e.g. /NFe/infNFe/det[2]/prod/xProd
+	Field   string `json:"field,omitempty"` // Element or attribute the error refers to
+	Line    int    `json:"line,omitempty"`  // Line of the XML reported by libxml2
+	Message string `json:"message"`         // Friendly description in Portuguese
+	Detail  string `json:"detail"`          // Raw libxml2 message
+}
+
+// SchemaError is returned by the validation when the document does not comply with the schema
+type SchemaError struct {
+	Schema     string
+	Violations []SchemaViolation
+}
+
+// Error lists the first violations with their location
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("XML validation failed for schema %s: %s", e.Schema, e.Summary())
+}
+
+// Summary describes the first violations in Portuguese, e.g. for the rejection message
+func (e *SchemaError) Summary() string {
+	parts := make([]string, 0, min(len(e.Violations), maxSummaryViolations)+1)
+	for i, violation := range e.Violations {
+		if i == maxSummaryViolations {
+			parts = append(parts, fmt.Sprintf("e mais %d erro(s)", len(e.Violations)-i))
+			break
+		}
+		parts = append(parts, violation.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// String formats the violation as "path (linha N): message"
+func (v SchemaViolation) String() string {
+	location := v.Path
+	if location == "" {
+		location = v.Field
+	}
+	switch {
+	case location != "" && v.Line > 0:
+		return fmt.Sprintf("%s (linha %d): %s", location, v.Line, v.Message)
+	case location != "":
+		return fmt.Sprintf("%s: %s", location, v.Message)
+	case v.Line > 0:
+		return fmt.Sprintf("linha %d: %s", v.Line, v.Message)
+	}
+	return v.Message
+}
+
+// schemaError converts the structured libxml2 errors of a validation into a SchemaError; it
+// reports false for other errors, e.g. a malformed document
+func schemaError(schema string, xmlData []byte, err error) (*SchemaError, bool) {
+	var validationErr xsdvalidate.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) == 0 {
+		return nil, false
+	}
+
+	paths := elementPaths(xmlData)
+	violations := make([]SchemaViolation, 0, len(validationErr.Errors))
+	for _, structErr := range validationErr.Errors {
+		field, attribute, detail := splitSubject(structErr.Message)
+		if field == "" {
+			field = structErr.NodeName
+		}
+		violation := SchemaViolation{
+			Path:    paths.lookup(structErr.Line, localName(field)),
+			Field:   localName(field),
+			Line:    structErr.Line,
+			Message: friendlyMessage(detail),
+			Detail:  structErr.Message,
+		}
+		if attribute != "" {
+			violation.Field = attribute
+			if violation.Path != "" {
+				violation.Path += "/@" + attribute
+			}
+		}
+		violations = append(violations, violation)
+	}
+	return &SchemaError{Schema: schema, Violations: violations}, true
+}
+
+// subjectRegex splits "Element '{ns}name', attribute 'attr': message" into its parts
+var subjectRegex = regexp.MustCompile(`^Element '([^']*)'(?:, attribute '([^']*)')?: (?s)(.*)$`)
+
+// splitSubject returns the element, the attribute and the message of a libxml2 error
+func splitSubject(message string) (element, attribute, detail string) {
+	match := subjectRegex.FindStringSubmatch(strings.TrimSpace(message))
+	if match == nil {
+		return "", "", strings.TrimSpace(message)
+	}
+	return match[1], match[2], strings.TrimSpace(match[3])
+}
+
+// namespaceRegex matches the "{namespace}" prefix of qualified names in libxml2 messages
+var namespaceRegex = regexp.MustCompile(`\{[^}]*\}`)
+
+// localName drops the namespace of a qualified name
+func localName(name string) string {
+	return namespaceRegex.ReplaceAllString(name, "")
+}
+
+// friendlyRule maps a libxml2 message to a Portuguese description
+type friendlyRule struct {
+	pattern *regexp.Regexp
+	format  func(match []string) string
+}
+
+// friendlyRules are the schema errors most integrators run into, checked in order
+var friendlyRules = []friendlyRule{
+	{
+		regexp.MustCompile(`\[facet 'maxLength'\] The value(?: '(?s:.*)')? has a length of '(\d+)'; this exceeds the allowed maximum length of '(\d+)'`),
+		func(m []string) string {
+			return fmt.Sprintf("tamanho máximo de %s caracteres excedido (informado: %s)", m[2], m[1])
+		},
+	},
+	{
+		regexp.MustCompile(`\[facet 'minLength'\] The value(?: '(?s:.*)')? has a length of '(\d+)'; this underruns the allowed minimum length of '(\d+)'`),
+		func(m []string) string {
+			return fmt.Sprintf("tamanho mínimo de %s caracteres não atingido (informado: %s)", m[2], m[1])
+		},
+	},
+	{
+		regexp.MustCompile(`\[facet 'length'\] The value(?: '(?s:.*)')? has a length of '(\d+)'; this differs from the allowed length of '(\d+)'`),
+		func(m []string) string {
+			return fmt.Sprintf("deve ter exatamente %s caracteres (informado: %s)", m[2], m[1])
+		},
+	},
+	{
+		regexp.MustCompile(`\[facet 'pattern'\] The value '((?s:.*))' is not accepted by the pattern '(.*)'`),
+		func(m []string) string {
+			return fmt.Sprintf("valor '%s' fora do formato esperado (%s)", m[1], m[2])
+		},
+	},
+	{
+		regexp.MustCompile(`\[facet 'enumeration'\] The value '((?s:.*))' is not an element of the set \{(.*)\}`),
+		func(m []string) string {
+			return fmt.Sprintf("valor '%s' não permitido; valores aceitos: %s", m[1], m[2])
+		},
+	},
+	{
+		regexp.MustCompile(`\[facet '(?:maxInclusive|maxExclusive|minInclusive|minExclusive|totalDigits|fractionDigits)'\] The value '((?s:.*))'`),
+		func(m []string) string {
+			return fmt.Sprintf("valor '%s' fora do intervalo ou da precisão permitida", m[1])
+		},
+	},
+	{
+		regexp.MustCompile(`This element is not expected\. Expected is (?:one of )?\( (.*) \)`),
+		func(m []string) string {
+			return fmt.Sprintf("elemento fora de ordem ou não permitido; esperado: %s", localName(m[1]))
+		},
+	},
+	{
+		regexp.MustCompile(`This element is not expected`),
+		func(m []string) string {
+			return "elemento não permitido nesta posição"
+		},
+	},
+	{
+		regexp.MustCompile(`Missing child element\(s\)\. Expected is (?:one of )?\( (.*) \)`),
+		func(m []string) string {
+			return fmt.Sprintf("elemento obrigatório ausente: %s", localName(m[1]))
+		},
+	},
+	{
+		regexp.MustCompile(`The attribute '([^']*)' is required but missing`),
+		func(m []string) string {
+			return fmt.Sprintf("atributo obrigatório ausente: %s", m[1])
+		},
+	},
+	{
+		regexp.MustCompile(`The attribute '([^']*)' is not allowed`),
+		func(m []string) string {
+			return fmt.Sprintf("atributo não permitido: %s", m[1])
+		},
+	},
+	{
+		regexp.MustCompile(`'((?s:.*))' is not a valid value of the (?:local )?(?:atomic|union|list) type`),
+		func(m []string) string {
+			if m[1] == "" {
+				return "campo vazio; informe um valor ou omita o elemento"
+			}
+			return fmt.Sprintf("valor '%s' inválido para o tipo do campo", m[1])
+		},
+	},
+	{
+		regexp.MustCompile(`No matching global (?:element )?declaration available`),
+		func(m []string) string {
+			return "elemento raiz não reconhecido pelo schema"
+		},
+	},
+}
+
+// friendlyMessage describes a libxml2 message in Portuguese, falling back to the raw message
+func friendlyMessage(detail string) string {
+	for _, rule := range friendlyRules {
+		if match := rule.pattern.FindStringSubmatch(detail); match != nil {
+			return rule.format(match)
+		}
+	}
+	return strings.TrimSuffix(localName(detail), ".")
+}
+
+// elementLocation is an element start tag of the validated document
+type elementLocation struct {
+	line int
+	name string
+	path string
+}
+
+// elementLocations lists the elements of the document in order
+type elementLocations []elementLocation
+
+// lookup returns the path of the element named name on the line, or of the last element
+// starting on the line when none matches the name
+func (l elementLocations) lookup(line int, name string) string {
+	fallback := ""
+	for _, location := range l {
+		if location.line != line {
+			continue
+		}
+		if location.name == name {
+			return location.path
+		}
+		fallback = location.path
+	}
+	return fallback
+}
+
+// elementPaths walks the document recording the line and the path of every element. The
+// position of repeated siblings is kept as an index, e.g. det[2], like libxml2 reports them.
+func elementPaths(xmlData []byte) elementLocations {
+	type frame struct {
+		path  string
+		count map[string]int
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(xmlData))
+	stack := []frame{{count: map[string]int{}}}
+	var locations elementLocations
+	var repeated []int // Locations of elements whose siblings share the name
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break // io.EOF, or a syntax error libxml2 already reported
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			line, _ := decoder.InputPos()
+			parent := &stack[len(stack)-1]
+			parent.count[t.Name.Local]++
+			n := parent.count[t.Name.Local]
+
+			path := parent.path + "/" + t.Name.Local
+			if n > 1 {
+				path += "[" + strconv.Itoa(n) + "]"
+				repeated = append(repeated, len(locations))
+			}
+			locations = append(locations, elementLocation{line: line, name: t.Name.Local, path: path})
+			stack = append(stack, frame{path: path, count: map[string]int{}})
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	// The first of repeated siblings only learns it has siblings afterwards
+	for _, i := range repeated {
+		suffix := locations[i].path[strings.LastIndex(locations[i].path, "["):]
+		if suffix != "[2]" {
+			continue
+		}
+		first := strings.TrimSuffix(locations[i].path, suffix)
+		for j := range locations {
+			if locations[j].path == first || strings.HasPrefix(locations[j].path, first+"/") {
+				locations[j].path = first + "[1]" + strings.TrimPrefix(locations[j].path, first)
+			}
+		}
+	}
+	return locations
+}
@@ -2,14 +2,15 @@
 //
 // Example usage:
 //
-//	validator, err := NewXMLValidator("./internal/infrastructure/sefaz/schemas")
+//	validator, err := NewXMLValidator("./internal/infrastructure/sefaz/schemas", logger.NewNopLogger())
 //	if err != nil {
 //		log.Fatal(err)
 //	}
 //
-//	// Download official SEFAZ schemas (one time setup)
+//	// Install the bundled SEFAZ schemas of the package (SHA-256 verified, atomic swap);
+//	// pass true to download them over HTTPS when the package is not bundled
 //	ctx := context.Background()
-//	if err := validator.DownloadSEFAZSchemas(ctx, "4.00"); err != nil {
+//	if err := validator.EnsureSchemas(ctx, "PL_009_V4", false); err != nil {
 //		log.Fatal(err)
 //	}
 //
@@ -23,13 +24,13 @@ package validator
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
 	xsdvalidate "github.com/terminalstatic/go-xsd-validate"
 )
 
@@ -40,6 +41,9 @@ type XMLValidator interface {
 	ValidateWithCustomSchema(ctx context.Context, xml []byte, schemaContent []byte) error
 	ListAvailableSchemas() ([]string, error)
 	DownloadSEFAZSchemas(ctx context.Context, version string) error
+	InstallBundledSchemas(version string) error
+	EnsureSchemas(ctx context.Context, version string, allowDownload bool) error
+	SchemaStatus(version string) (*SchemaStatus, error)
 }
 
 // xmlValidator implements XMLValidator interface
@@ -48,16 +52,37 @@ type xmlValidator struct {
 	schemas    map[string]*xsdvalidate.XsdHandler
 	mu         sync.RWMutex
 	httpClient *http.Client
+	logger     logger.Logger
+
+	// Schema updates
+	updateMu        sync.Mutex
+	lastUpdate      *SchemaUpdate
+	lockModTime     time.Time // schemas.lock.json of the cached schemas
+	lastReloadCheck time.Time
 }
 
+// libxml2Once initializes libxml2 once per process, shared by every validator
+var libxml2Once sync.Once
+
 // NewXMLValidator creates a new XML validator
-func NewXMLValidator(schemasDir string) (XMLValidator, error) {
+func NewXMLValidator(schemasDir string, l logger.Logger) (XMLValidator, error) {
+	// Init only fails when libxml2 was already initialized
+	libxml2Once.Do(func() { _ = xsdvalidate.Init() })
+
 	validator := &xmlValidator{
 		schemasDir: schemasDir,
 		schemas:    make(map[string]*xsdvalidate.XsdHandler),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if req.URL.Scheme != "https" {
+					return fmt.Errorf("refusing redirect to non-https URL %s", req.URL)
+				}
+				return nil
+			},
 		},
+		logger:      l,
+		lockModTime: lockModTime(schemasDir),
 	}
 
 	// Initialize schemas directory if it doesn't exist
@@ -70,14 +95,19 @@ func NewXMLValidator(schemasDir string) (XMLValidator, error) {
 
 // Validate validates XML against XSD schema
 func (v *xmlValidator) Validate(ctx context.Context, xmlData []byte, schemaName string) error {
+	v.reloadIfSwapped()
+
 	// Get or load schema
 	handler, err := v.getSchema(schemaName)
 	if err != nil {
 		return fmt.Errorf("failed to load schema %s: %w", schemaName, err)
 	}
 
-	// Validate XML against schema
+	// Validate XML against schema; schema violations are returned as a *SchemaError
 	if err := handler.ValidateMem(xmlData, xsdvalidate.ValidErrDefault); err != nil {
+		if schemaErr, ok := schemaError(schemaName, xmlData, err); ok {
+			return schemaErr
+		}
 		return fmt.Errorf("XML validation failed for schema %s: %w", schemaName, err)
 	}
 
@@ -134,73 +164,10 @@ func (v *xmlValidator) ValidateWithCustomSchema(ctx context.Context, xmlData []b
 
 	// Validate XML against schema
 	if err := handler.ValidateMem(xmlData, xsdvalidate.ValidErrDefault); err != nil {
-		return fmt.Errorf("XML validation failed against custom schema: %w", err)
-	}
-
-	return nil
-}
-
-// DownloadSEFAZSchemas downloads official SEFAZ schemas for NFC-e
-func (v *xmlValidator) DownloadSEFAZSchemas(ctx context.Context, version string) error {
-	// NFC-e schemas required for version 4.00
-	schemas := map[string]string{
-		"nfe_v4.00.xsd":             "http://www.portalfiscal.inf.br/nfe/xsd/nfe_v4.00.xsd",
-		"infNFe_v4.00.xsd":          "http://www.portalfiscal.inf.br/nfe/xsd/infNFe_v4.00.xsd",
-		"infIntermed_v4.00.xsd":     "http://www.portalfiscal.inf.br/nfe/xsd/infIntermed_v4.00.xsd",
-		"infRespTec_v4.00.xsd":      "http://www.portalfiscal.inf.br/nfe/xsd/infRespTec_v4.00.xsd",
-		"infSolicNFF_v4.00.xsd":     "http://www.portalfiscal.inf.br/nfe/xsd/infSolicNFF_v4.00.xsd",
-		"procNFe_v4.00.xsd":         "http://www.portalfiscal.inf.br/nfe/xsd/procNFe_v4.00.xsd",
-		"retConsSitNFe_v4.00.xsd":   "http://www.portalfiscal.inf.br/nfe/xsd/retConsSitNFe_v4.00.xsd",
-		"retConsStatServ_v4.00.xsd": "http://www.portalfiscal.inf.br/nfe/xsd/retConsStatServ_v4.00.xsd",
-		"retEnviNFe_v4.00.xsd":      "http://www.portalfiscal.inf.br/nfe/xsd/retEnviNFe_v4.00.xsd",
-		"retInutNFe_v4.00.xsd":      "http://www.portalfiscal.inf.br/nfe/xsd/retInutNFe_v4.00.xsd",
-		"tiposBasico_v4.00.xsd":     "http://www.portalfiscal.inf.br/nfe/xsd/tiposBasico_v4.00.xsd",
-	}
-
-	// Download each schema
-	for schemaName, url := range schemas {
-		if err := v.downloadSchema(ctx, schemaName, url); err != nil {
-			return fmt.Errorf("failed to download schema %s: %w", schemaName, err)
+		if schemaErr, ok := schemaError("custom", xmlData, err); ok {
+			return schemaErr
 		}
-	}
-
-	// Clear cache to force reload of updated schemas
-	v.mu.Lock()
-	v.schemas = make(map[string]*xsdvalidate.XsdHandler)
-	v.mu.Unlock()
-
-	return nil
-}
-
-// downloadSchema downloads a single schema file
-func (v *xmlValidator) downloadSchema(ctx context.Context, schemaName, url string) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := v.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to download schema: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP error %d downloading schema", resp.StatusCode)
-	}
-
-	// Create schema file
-	schemaPath := filepath.Join(v.schemasDir, schemaName)
-	file, err := os.Create(schemaPath)
-	if err != nil {
-		return fmt.Errorf("failed to create schema file: %w", err)
-	}
-	defer file.Close()
-
-	// Copy content
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to write schema file: %w", err)
+		return fmt.Errorf("XML validation failed against custom schema: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,148 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Renderer generates the monthly usage statement PDF
+type Renderer interface {
+	RenderTo(w io.Writer, company *entity.Company, statement *entity.UsageStatement) error
+}
+
+// renderer implements Renderer using gofpdf
+type renderer struct{}
+
+// NewRenderer creates a new usage statement renderer
+func NewRenderer() Renderer {
+	return &renderer{}
+}
+
+// RenderTo writes the usage statement PDF to w
+func (r *renderer) RenderTo(w io.Writer, company *entity.Company, statement *entity.UsageStatement) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+
+	summary := statement.Summary
+
+	// Title
+	pdf.SetFont("Arial", "B", 14)
+	pdf.Cell(180, 10, "EXTRATO MENSAL DE USO")
+	pdf.Ln(12)
+
+	pdf.SetFont("Arial", "", 9)
+	pdf.Cell(180, 5, fmt.Sprintf("%s - CNPJ %s", company.RazaoSocial, company.CNPJ))
+	pdf.Ln(5)
+	pdf.Cell(180, 5, fmt.Sprintf("Período: %s a %s",
+		statement.PeriodStart.Format("02/01/2006"), statement.PeriodEnd.AddDate(0, 0, -1).Format("02/01/2006")))
+	pdf.Ln(5)
+	if summary.PlanName != "" {
+		pdf.Cell(180, 5, fmt.Sprintf("Plano: %s", summary.PlanName))
+		pdf.Ln(5)
+	}
+	pdf.Ln(5)
+
+	// NFC-e
+	r.section(pdf, "NFC-E")
+	r.line(pdf, "Total de requisições", fmt.Sprint(summary.NFCe.Total))
+	for _, status := range sortedKeys(summary.NFCe.ByStatus) {
+		r.line(pdf, "  "+status, fmt.Sprint(summary.NFCe.ByStatus[status]))
+	}
+	for _, ambiente := range sortedKeys(summary.NFCe.ByAmbiente) {
+		r.line(pdf, "Ambiente "+ambiente, fmt.Sprint(summary.NFCe.ByAmbiente[ambiente]))
+	}
+	r.line(pdf, "Emitidas em produção", fmt.Sprint(summary.NFCe.Emitted))
+	if summary.NFCe.Quota >= 0 {
+		r.line(pdf, "Cota do plano", fmt.Sprint(summary.NFCe.Quota))
+		r.line(pdf, "Excedente", fmt.Sprint(summary.NFCe.Overage))
+	} else {
+		r.line(pdf, "Cota do plano", "ilimitada")
+	}
+	pdf.Ln(5)
+
+	// Plan changes
+	if len(summary.PlanChanges) > 0 {
+		r.section(pdf, "TROCAS DE PLANO")
+		for _, change := range summary.PlanChanges {
+			r.line(pdf, fmt.Sprintf("%s: %s para %s", change.ChangedAt.Format("02/01/2006"),
+				planLabel(change.FromPlanName, change.FromPlanID), planLabel(change.ToPlanName, change.ToPlanID)),
+				fmt.Sprintf("%.2f", change.Proration))
+		}
+		pdf.Ln(5)
+	}
+
+	// Webhooks
+	r.section(pdf, "WEBHOOKS")
+	r.line(pdf, "Entregas", fmt.Sprint(summary.Webhooks.Total))
+	r.line(pdf, "Com sucesso", fmt.Sprint(summary.Webhooks.Succeeded))
+	r.line(pdf, "Com falha", fmt.Sprint(summary.Webhooks.Failed))
+	pdf.Ln(5)
+
+	// Storage
+	r.section(pdf, "ARMAZENAMENTO")
+	r.line(pdf, "Arquivos", fmt.Sprint(summary.Storage.Objects))
+	r.line(pdf, "Espaço utilizado", formatBytes(summary.Storage.Bytes))
+	pdf.Ln(8)
+
+	pdf.SetFont("Arial", "", 7)
+	pdf.Cell(180, 4, fmt.Sprintf("Gerado em %s", statement.CreatedAt.Format("02/01/2006 15:04:05")))
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to render usage statement: %w", err)
+	}
+
+	return nil
+}
+
+// section draws a section header
+func (r *renderer) section(pdf *gofpdf.Fpdf, title string) {
+	pdf.SetFont("Arial", "B", 10)
+	pdf.Cell(180, 6, title)
+	pdf.Ln(7)
+	pdf.SetFont("Arial", "", 9)
+}
+
+// line draws a label and its value
+func (r *renderer) line(pdf *gofpdf.Fpdf, label, value string) {
+	pdf.Cell(120, 5, label)
+	pdf.CellFormat(60, 5, value, "", 0, "R", false, 0, "")
+	pdf.Ln(5)
+}
+
+// planLabel names a plan, by its ID when it no longer exists
+func planLabel(name, id string) string {
+	if name != "" {
+		return name
+	}
+	return id
+}
+
+// sortedKeys returns the keys of the counts in a stable order
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatBytes formats a size in bytes with a binary unit
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
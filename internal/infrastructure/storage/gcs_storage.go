@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/signer"
+)
+
+const (
+	// gcsEndpoint is the XML API of Google Cloud Storage, compatible with the S3 object API
+	gcsEndpoint = "storage.googleapis.com"
+	// gcsRegion is the SigV4 region GCS accepts for any bucket location
+	gcsRegion = "auto"
+)
+
+// GCSConfig holds the settings of the Google Cloud Storage backend
+type GCSConfig struct {
+	Bucket string
+	// HMAC key of a service account, created in Cloud Storage > Settings > Interoperability
+	AccessKey     string
+	SecretKey     string
+	PresignExpiry time.Duration
+}
+
+// GCSStorage implements StorageService using Google Cloud Storage through its XML API with
+// HMAC credentials. Uploads are encrypted with the default key of the bucket (Google-managed
+// or CMEK).
+type GCSStorage struct {
+	*objectStorage
+	accessKey  string
+	secretKey  string
+	httpClient *http.Client
+}
+
+// NewGCSStorage creates a new Google Cloud Storage service; the bucket must already exist
+func NewGCSStorage(cfg GCSConfig) (*GCSStorage, error) {
+	client, err := minio.New(gcsEndpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: true,
+		Region: gcsRegion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	objects, err := newObjectStorage(client, cfg.Bucket, false, cfg.PresignExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCSStorage{
+		objectStorage: objects,
+		accessKey:     cfg.AccessKey,
+		secretKey:     cfg.SecretKey,
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// gcsLifecycle is the lifecycle configuration of the GCS XML API, which differs from the S3 one
+type gcsLifecycle struct {
+	XMLName xml.Name           `xml:"LifecycleConfiguration"`
+	Rules   []gcsLifecycleRule `xml:"Rule"`
+}
+
+// gcsLifecycleRule keeps the raw XML so rules written elsewhere round-trip untouched
+type gcsLifecycleRule struct {
+	Inner string `xml:",innerxml"`
+}
+
+// gcsRetentionRule is the shape of the delete rules written by ApplyRetention
+type gcsRetentionRule struct {
+	XMLName xml.Name `xml:"Rule"`
+	Action  struct {
+		Delete *struct{} `xml:"Delete"`
+	} `xml:"Action"`
+	Condition struct {
+		Age           int      `xml:"Age,omitempty"`
+		MatchesPrefix []string `xml:"MatchesPrefix"`
+	} `xml:"Condition"`
+}
+
+// retention reports whether the rule deletes objects by age under root only
+func (r gcsLifecycleRule) retention(root string) bool {
+	var rule gcsRetentionRule
+	if err := xml.Unmarshal([]byte("<Rule>"+r.Inner+"</Rule>"), &rule); err != nil {
+		return false
+	}
+	if rule.Action.Delete == nil || rule.Condition.Age == 0 || len(rule.Condition.MatchesPrefix) == 0 {
+		return false
+	}
+	for _, prefix := range rule.Condition.MatchesPrefix {
+		if !strings.HasPrefix(prefix, root) {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyRetention writes one delete rule per retention period, matching the prefixes that share
+// it, and removes the previous delete rules under root
+func (s *GCSStorage) ApplyRetention(ctx context.Context, bucket string, root string, rules []RetentionRule) error {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	current, err := s.lifecycleRequest(ctx, http.MethodGet, bucket, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get bucket lifecycle: %w", err)
+	}
+	var config gcsLifecycle
+	if len(current) > 0 {
+		if err := xml.Unmarshal(current, &config); err != nil {
+			return fmt.Errorf("failed to parse bucket lifecycle: %w", err)
+		}
+	}
+
+	kept := make([]gcsLifecycleRule, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		if !rule.retention(root) {
+			kept = append(kept, rule)
+		}
+	}
+
+	prefixes := make(map[int][]string)
+	for _, rule := range rules {
+		prefixes[rule.Days] = append(prefixes[rule.Days], rule.Prefix)
+	}
+	days := make([]int, 0, len(prefixes))
+	for d := range prefixes {
+		days = append(days, d)
+	}
+	sort.Ints(days)
+
+	for _, d := range days {
+		var rule gcsRetentionRule
+		rule.Action.Delete = &struct{}{}
+		rule.Condition.Age = d
+		rule.Condition.MatchesPrefix = prefixes[d]
+		sort.Strings(rule.Condition.MatchesPrefix)
+
+		inner, err := xml.Marshal(rule)
+		if err != nil {
+			return fmt.Errorf("failed to encode lifecycle rule: %w", err)
+		}
+		inner = bytes.TrimSuffix(bytes.TrimPrefix(inner, []byte("<Rule>")), []byte("</Rule>"))
+		kept = append(kept, gcsLifecycleRule{Inner: string(inner)})
+	}
+
+	config.Rules = kept
+	body, err := xml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode bucket lifecycle: %w", err)
+	}
+	if _, err := s.lifecycleRequest(ctx, http.MethodPut, bucket, body); err != nil {
+		return fmt.Errorf("failed to set bucket lifecycle: %w", err)
+	}
+
+	return nil
+}
+
+// lifecycleRequest calls the ?lifecycle resource of the bucket, signed with the HMAC key
+func (s *GCSStorage) lifecycleRequest(ctx context.Context, method, bucket string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "https://"+gcsEndpoint+"/"+bucket+"?lifecycle", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+
+	resp, err := s.httpClient.Do(signer.SignV4(*req, s.accessKey, s.secretKey, "", gcsRegion))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
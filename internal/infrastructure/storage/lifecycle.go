@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// retentionRuleID prefixes the IDs of the lifecycle rules written by ApplyRetention
+const retentionRuleID = "retention:"
+
+// maxS3LifecycleRules is the number of lifecycle rules AWS S3 accepts per bucket
+const maxS3LifecycleRules = 1000
+
+// ApplyRetention writes one expiration rule per prefix, identified by its ID, and removes
+// the previous retention rules under root
+func (s *objectStorage) ApplyRetention(ctx context.Context, bucket string, root string, rules []RetentionRule) error {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	config, err := s.client.GetBucketLifecycle(ctx, bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf("failed to get bucket lifecycle: %w", err)
+		}
+		config = lifecycle.NewConfiguration()
+	}
+
+	kept := make([]lifecycle.Rule, 0, len(config.Rules)+len(rules))
+	for _, rule := range config.Rules {
+		if !strings.HasPrefix(rule.ID, retentionRuleID+root) {
+			kept = append(kept, rule)
+		}
+	}
+	if len(kept)+len(rules) > maxS3LifecycleRules {
+		return fmt.Errorf("%d retention rules exceed the %d lifecycle rules of a bucket", len(rules), maxS3LifecycleRules-len(kept))
+	}
+
+	sorted := append([]RetentionRule(nil), rules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Prefix < sorted[j].Prefix })
+	for _, rule := range sorted {
+		kept = append(kept, lifecycle.Rule{
+			ID:         retentionRuleID + rule.Prefix,
+			Status:     "Enabled",
+			RuleFilter: lifecycle.Filter{Prefix: rule.Prefix},
+			Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(rule.Days)},
+		})
+	}
+	config.Rules = kept
+	if err := s.client.SetBucketLifecycle(ctx, bucket, config); err != nil {
+		return fmt.Errorf("failed to set bucket lifecycle: %w", err)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -105,6 +106,59 @@ func (s *LocalStorage) FileExists(ctx context.Context, bucket string, key string
 	return true, nil
 }
 
+// Usage walks the directory of the prefix, counting its files and their sizes
+func (s *LocalStorage) Usage(ctx context.Context, bucket string, prefix string) (int, int64, error) {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	var files int
+	var size int64
+	root := filepath.Join(s.basePath, bucket, prefix)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to compute storage usage: %w", err)
+	}
+
+	return files, size, nil
+}
+
+// OpenFile opens a file of the local filesystem for streaming reads
+func (s *LocalStorage) OpenFile(ctx context.Context, bucket string, key string) (io.ReadCloser, error) {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	fullPath := filepath.Join(s.basePath, bucket, key)
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found: %s", fullPath)
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return file, nil
+}
+
 // DownloadFile downloads a file from local filesystem
 func (s *LocalStorage) DownloadFile(ctx context.Context, bucket string, key string) ([]byte, error) {
 	if bucket == "" {
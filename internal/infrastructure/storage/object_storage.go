@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// uploadPartSize is the multipart chunk buffered per upload of unknown size (streamed
+// readers such as the DANFE pipe). minio-go otherwise sizes it for a 5 TiB object,
+// buffering up to 512 MiB per concurrent upload.
+const uploadPartSize = 5 << 20 // 5 MiB, the S3 minimum
+
+// defaultPresignExpiry is the validity of the file URLs, the SigV4 maximum
+const defaultPresignExpiry = 7 * 24 * time.Hour
+
+// objectStorage implements StorageService over the S3 API, shared by the MinIO, AWS S3 and
+// Google Cloud Storage backends
+type objectStorage struct {
+	client        *minio.Client
+	bucketName    string
+	sse           encrypt.ServerSide // Server-side encryption of the uploads, nil for the bucket default
+	presignExpiry time.Duration
+}
+
+// newObjectStorage wraps the client, checking the bucket exists; create makes it when missing
+func newObjectStorage(client *minio.Client, bucketName string, create bool, presignExpiry time.Duration) (*objectStorage, error) {
+	if presignExpiry <= 0 || presignExpiry > defaultPresignExpiry {
+		presignExpiry = defaultPresignExpiry
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket existence: %w", err)
+	}
+
+	if !exists {
+		if !create {
+			return nil, fmt.Errorf("bucket %s does not exist", bucketName)
+		}
+		err = client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bucket: %w", err)
+		}
+	}
+
+	return &objectStorage{
+		client:        client,
+		bucketName:    bucketName,
+		presignExpiry: presignExpiry,
+	}, nil
+}
+
+// UploadFile uploads a file to the bucket
+func (s *objectStorage) UploadFile(ctx context.Context, bucket string, key string, file io.Reader, contentType string) (string, error) {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	// Upload the file
+	_, err := s.client.PutObject(ctx, bucket, key, file, -1, minio.PutObjectOptions{
+		ContentType:          contentType,
+		PartSize:             uploadPartSize,
+		ServerSideEncryption: s.sse,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	// Return the URL
+	return s.GetFileURL(ctx, bucket, key)
+}
+
+// DownloadFile downloads a file from the bucket
+func (s *objectStorage) DownloadFile(ctx context.Context, bucket string, key string) ([]byte, error) {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	// Get object
+	obj, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer obj.Close()
+
+	// Read all data
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object data: %w", err)
+	}
+
+	return data, nil
+}
+
+// OpenFile opens an object of the bucket for streaming reads
+func (s *objectStorage) OpenFile(ctx context.Context, bucket string, key string) (io.ReadCloser, error) {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	obj, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	// GetObject is lazy; stat it so a missing object fails here instead of on the first read
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return obj, nil
+}
+
+// DeleteFile deletes a file from the bucket
+func (s *objectStorage) DeleteFile(ctx context.Context, bucket string, key string) error {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	err := s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return nil
+}
+
+// GetFileURL returns the URL to access a file
+func (s *objectStorage) GetFileURL(ctx context.Context, bucket string, key string) (string, error) {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	// Generate presigned URL
+	url, err := s.client.PresignedGetObject(ctx, bucket, key, s.presignExpiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+
+	return url.String(), nil
+}
+
+// FileExists checks if a file exists in the bucket
+func (s *objectStorage) FileExists(ctx context.Context, bucket string, key string) (bool, error) {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	_, err := s.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check file existence: %w", err)
+	}
+
+	return true, nil
+}
+
+// Usage lists the objects under the prefix, counting them and their sizes
+func (s *objectStorage) Usage(ctx context.Context, bucket string, prefix string) (int, int64, error) {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+
+	var objects int
+	var size int64
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return 0, 0, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		objects++
+		size += object.Size
+	}
+
+	return objects, size, nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// s3Endpoint is the AWS S3 endpoint; the client resolves the regional host from the region
+const s3Endpoint = "s3.amazonaws.com"
+
+// S3Config holds the settings of the AWS S3 backend
+type S3Config struct {
+	Bucket string
+	Region string
+	// KMSKeyID encrypts the uploads with SSE-KMS under this key; empty uses the bucket default
+	KMSKeyID      string
+	PresignExpiry time.Duration
+}
+
+// S3Storage implements StorageService using AWS S3. Credentials come from the standard AWS
+// chain: AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY, the shared credentials file or the IAM role
+// of the instance or task.
+type S3Storage struct {
+	*objectStorage
+	region string
+}
+
+// NewS3Storage creates a new AWS S3 storage service; the bucket must already exist
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	client, err := minio.New(s3Endpoint, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+		}),
+		Secure: true,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	objects, err := newObjectStorage(client, cfg.Bucket, false, cfg.PresignExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.KMSKeyID != "" {
+		sse, err := encrypt.NewSSEKMS(cfg.KMSKeyID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSE-KMS key: %w", err)
+		}
+		objects.sse = sse
+	}
+
+	return &S3Storage{
+		objectStorage: objects,
+		region:        cfg.Region,
+	}, nil
+}
@@ -13,6 +13,9 @@ type StorageService interface {
 	// DownloadFile downloads a file and returns its content
 	DownloadFile(ctx context.Context, bucket string, key string) ([]byte, error)
 
+	// OpenFile opens a file for streaming reads; the caller must close it
+	OpenFile(ctx context.Context, bucket string, key string) (io.ReadCloser, error)
+
 	// DeleteFile deletes a file from storage
 	DeleteFile(ctx context.Context, bucket string, key string) error
 
@@ -21,6 +24,23 @@ type StorageService interface {
 
 	// FileExists checks if a file exists in storage
 	FileExists(ctx context.Context, bucket string, key string) (bool, error)
+
+	// Usage returns the number of files and bytes stored under a key prefix
+	Usage(ctx context.Context, bucket string, prefix string) (int, int64, error)
+}
+
+// RetentionRule expires the objects under a key prefix a number of days after their upload
+type RetentionRule struct {
+	Prefix string
+	Days   int
+}
+
+// LifecycleManager is implemented by the backends that expire objects with bucket lifecycle
+// rules (MinIO, AWS S3 and Google Cloud Storage)
+type LifecycleManager interface {
+	// ApplyRetention replaces the retention rules of the objects under root with rules,
+	// keeping the other lifecycle rules of the bucket
+	ApplyRetention(ctx context.Context, bucket string, root string, rules []RetentionRule) error
 }
 
 // UploadResult contains information about an uploaded file
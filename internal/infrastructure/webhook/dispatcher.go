@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
+)
+
+// deliveryBody is the JSON body posted to webhook receivers
+type deliveryBody struct {
+	Event     entity.WebhookEvent    `json:"event"`
+	CompanyID string                 `json:"company_id"`
+	Data      map[string]interface{} `json:"data"`
+	SentAt    time.Time              `json:"sent_at"`
+}
+
+// dispatcher implements ports.WebhookDispatcher over HTTP
+type dispatcher struct {
+	webhookRepo ports.WebhookRepository
+	httpClient  *http.Client
+}
+
+// NewDispatcher creates a new HTTP webhook dispatcher
+func NewDispatcher(webhookRepo ports.WebhookRepository, timeout time.Duration) ports.WebhookDispatcher {
+	return &dispatcher{
+		webhookRepo: webhookRepo,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Dispatch sends the event to every active webhook of the company listening to it whose filters match.
+// Deliveries run in background so SEFAZ processing is never blocked by slow receivers.
+func (d *dispatcher) Dispatch(ctx context.Context, companyID string, event entity.WebhookEvent, payload map[string]interface{}) error {
+	webhooks, _, err := d.webhookRepo.ListByCompanyID(ctx, companyID, 100, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	body, err := json.Marshal(deliveryBody{
+		Event:     event,
+		CompanyID: companyID,
+		Data:      payload,
+		SentAt:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	for _, wh := range webhooks {
+		if !wh.IsActive() || !wh.ListensToEvent(event) || !wh.Accepts(event, payload) {
+			continue
+		}
+		go d.deliver(wh, event, payload, body)
+	}
+
+	return nil
+}
+
+// deliver posts the payload to a webhook, retrying with exponential backoff, and records the outcome
+func (d *dispatcher) deliver(wh *entity.Webhook, event entity.WebhookEvent, payload map[string]interface{}, body []byte) {
+	interval := wh.RetryConfig.RetryInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	var lastErr error
+	attempts := 0
+	for attempt := 0; attempt <= wh.RetryConfig.MaxRetries; attempt++ {
+		attempts++
+		if attempt > 0 {
+			time.Sleep(interval)
+			interval *= 2
+			if wh.RetryConfig.MaxInterval > 0 && interval > wh.RetryConfig.MaxInterval {
+				interval = wh.RetryConfig.MaxInterval
+			}
+		}
+
+		if lastErr = d.send(wh, event, body); lastErr == nil {
+			break
+		}
+	}
+
+	if lastErr != nil {
+		log.Printf("Webhook %s delivery of %s failed: %v", wh.ID, event, lastErr)
+	}
+
+	wh.RecordDelivery(lastErr == nil)
+	if err := d.webhookRepo.Update(context.Background(), wh); err != nil {
+		log.Printf("Failed to record webhook %s delivery: %v", wh.ID, err)
+	}
+
+	delivery := entity.NewWebhookDelivery(wh.ID, event, payload, attempts, lastErr)
+	if err := d.webhookRepo.CreateDelivery(context.Background(), delivery); err != nil {
+		log.Printf("Failed to store webhook %s delivery: %v", wh.ID, err)
+	}
+}
+
+// send performs a single delivery attempt
+func (d *dispatcher) send(wh *entity.Webhook, event entity.WebhookEvent, body []byte) error {
+	method := string(wh.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequest(method, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", string(event))
+	for key, value := range wh.Headers {
+		req.Header.Set(key, value)
+	}
+	if wh.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", "sha256="+sign(wh.Secret, body))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP request failed with status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// sign computes the HMAC-SHA256 signature of the body with the webhook secret
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
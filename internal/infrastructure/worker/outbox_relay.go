@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+const (
+	// outboxRetention is how long the relayed messages are kept for troubleshooting
+	outboxRetention = 7 * 24 * time.Hour
+	// outboxCleanupBatch caps the relayed messages removed per query
+	outboxCleanupBatch = 1000
+)
+
+// scheduleOutboxRelay periodically publishes the pending outbox messages
+func (w *Worker) scheduleOutboxRelay(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.outboxRelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			if w.underMaintenance(ctx) {
+				continue
+			}
+			if !w.publisher.IsHealthy() {
+				continue
+			}
+			published, err := w.relay.RelayBatch(ctx)
+			if err != nil {
+				w.logger.Error("Failed to relay outbox messages", logger.Field{Key: "error", Value: err.Error()})
+			}
+			if published > 0 {
+				w.logger.Info("Outbox messages relayed", logger.Field{Key: "count", Value: published})
+			}
+		}
+	}
+}
+
+// scheduleOutboxCleanup periodically removes the outbox messages relayed past the retention
+func (w *Worker) scheduleOutboxCleanup(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			if w.underMaintenance(ctx) {
+				continue
+			}
+			purged, err := w.outbox.DeleteSentBefore(ctx, time.Now().Add(-outboxRetention), outboxCleanupBatch)
+			if err != nil {
+				w.logger.Error("Failed to purge relayed outbox messages", logger.Field{Key: "error", Value: err.Error()})
+			}
+			if purged > 0 {
+				w.logger.Info("Relayed outbox messages purged", logger.Field{Key: "count", Value: purged})
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+const (
+	// retryDeadlineMargin keeps the last retry of an NFC-e ahead of the 48h retry cutoff
+	retryDeadlineMargin = 10 * time.Minute
+	// retryEarlyTolerance is how early a delayed retry message may arrive and still be emitted
+	retryEarlyTolerance = time.Second
+	// retryRecoveryGrace is how long past its next_retry_at a retry waits for the broker before
+	// the scheduler recovers it, e.g. when its delayed message could not be published
+	retryRecoveryGrace = 2 * time.Minute
+)
+
+// RetrySchedulerStats reports the activity of the retry scheduler, which recovers the retries
+// the broker did not deliver, used to tune its bounds
+type RetrySchedulerStats struct {
+	Runs      int64         `json:"runs"`
+	Picked    int64         `json:"picked"`    // Retries recovered since start
+	Skipped   int64         `json:"skipped"`   // Due retries left for a later run
+	Throttled int64         `json:"throttled"` // Runs held because RETRY_MAX_IN_FLIGHT was reached
+	Due       int           `json:"due"`       // Retries overdue past the recovery grace at the last run
+	InFlight  int           `json:"in_flight"` // Requests pending or processing at the last run
+	Lag       time.Duration `json:"lag"`       // Wait of the oldest due retry past its next_retry_at
+	Batch     int           `json:"batch"`
+	Interval  time.Duration `json:"interval"`
+	LastRunAt time.Time     `json:"last_run_at"`
+}
+
+// retrySchedulerStats guards the counters shared by the scheduler and the readers
+type retrySchedulerStats struct {
+	mu    sync.Mutex
+	stats RetrySchedulerStats
+}
+
+// RetryStats returns a snapshot of the retry scheduler counters
+func (w *Worker) RetryStats() RetrySchedulerStats {
+	w.retryStats.mu.Lock()
+	defer w.retryStats.mu.Unlock()
+	return w.retryStats.stats
+}
+
+// recordRetryRun updates the counters of a scheduler run and logs it when there was work
+func (w *Worker) recordRetryRun(backlog *entity.RetryBacklog, batch, picked int, interval time.Duration, now time.Time) {
+	var lag time.Duration
+	if backlog.OldestDueAt != nil {
+		lag = now.Sub(*backlog.OldestDueAt)
+	}
+	skipped := max(backlog.Due-picked, 0)
+	throttled := batch == 0 && backlog.Due > 0
+
+	w.retryStats.mu.Lock()
+	stats := &w.retryStats.stats
+	stats.Runs++
+	stats.Picked += int64(picked)
+	stats.Skipped += int64(skipped)
+	if throttled {
+		stats.Throttled++
+	}
+	stats.Due = backlog.Due
+	stats.InFlight = backlog.InFlight
+	stats.Lag = lag
+	stats.Batch = batch
+	stats.Interval = interval
+	stats.LastRunAt = now
+	w.retryStats.mu.Unlock()
+
+	if backlog.Due == 0 {
+		return
+	}
+
+	fields := []logger.Field{
+		{Key: "due", Value: backlog.Due},
+		{Key: "picked", Value: picked},
+		{Key: "skipped", Value: skipped},
+		{Key: "in_flight", Value: backlog.InFlight},
+		{Key: "lag", Value: lag.String()},
+		{Key: "batch", Value: batch},
+		{Key: "next_run_in", Value: interval.String()},
+	}
+	if throttled {
+		w.logger.Warn("Retry scheduler held by back-pressure", fields...)
+		return
+	}
+	w.logger.Info("Retry scheduler run", fields...)
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
@@ -11,15 +12,50 @@ import (
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/ports"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/service"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/cache"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/clock"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/outbox"
 	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
 )
 
+// emailSendTimeout bounds the e-mail of the documents of an authorized NFC-e
+const emailSendTimeout = 2 * time.Minute
+
+// cacheStatsInterval is how often the hit ratio of the cache is logged
+const cacheStatsInterval = 5 * time.Minute
+
 // Worker processes NFC-e emission requests from the message queue
 type Worker struct {
 	repo          ports.NFCeRepository
+	txManager     ports.TxManager
 	publisher     dto.Publisher
 	consumer      dto.Consumer
+	eventBus      ports.EventBus
+	webhooks      ports.WebhookDispatcher
 	workerService *service.NFCeWorkerService
+	exportService *service.ExportService
+	inutService   *service.InutilizacaoService
+	dfeService    *service.DFeService
+	statements    *service.StatementService
+	retention     *service.StorageRetentionService
+	purge         *service.DocumentPurgeService
+	offboarding   *service.CompanyOffboardingService
+	archive       *service.SEFAZArchiveService
+	billing       *service.BillingService
+	lifecycle     *service.SubscriptionLifecycleService
+	emails        *service.EmailService
+	idempotency   *service.IdempotencyService
+	outbox        ports.OutboxRepository
+	relay         *outbox.Relay
+	outboxRelay   time.Duration
+	asyncLote     service.AsyncLoteConfig
+	offline       service.OfflineContingencyConfig
+	retry         service.RetrySchedulerConfig
+	retryStats    retrySchedulerStats
+	monitor       *service.SEFAZMonitor
+	clock         *clock.Monitor
+	maintenance   *service.MaintenanceService
+	cacheMetrics  *cache.Metrics
 	logger        logger.Logger
 	maxRetries    int
 	shutdown      chan struct{}
@@ -29,17 +65,66 @@ type Worker struct {
 // NewWorker creates a new NFC-e worker
 func NewWorker(
 	repo ports.NFCeRepository,
+	txManager ports.TxManager,
 	publisher dto.Publisher,
 	consumer dto.Consumer,
+	eventBus ports.EventBus,
+	webhooks ports.WebhookDispatcher,
 	workerService *service.NFCeWorkerService,
+	exportService *service.ExportService,
+	inutService *service.InutilizacaoService,
+	dfeService *service.DFeService,
+	statements *service.StatementService,
+	retention *service.StorageRetentionService,
+	purge *service.DocumentPurgeService,
+	offboarding *service.CompanyOffboardingService,
+	archive *service.SEFAZArchiveService,
+	billing *service.BillingService,
+	lifecycle *service.SubscriptionLifecycleService,
+	emails *service.EmailService,
+	idempotency *service.IdempotencyService,
+	outboxRepo ports.OutboxRepository,
+	outboxRelay time.Duration,
+	asyncLote service.AsyncLoteConfig,
+	offline service.OfflineContingencyConfig,
+	retry service.RetrySchedulerConfig,
+	monitor *service.SEFAZMonitor,
+	clockMonitor *clock.Monitor,
+	maintenance *service.MaintenanceService,
+	cacheMetrics *cache.Metrics,
 	logger logger.Logger,
 	maxRetries int,
 ) *Worker {
 	return &Worker{
 		repo:          repo,
+		txManager:     txManager,
 		publisher:     publisher,
 		consumer:      consumer,
+		eventBus:      eventBus,
+		webhooks:      webhooks,
 		workerService: workerService,
+		exportService: exportService,
+		inutService:   inutService,
+		dfeService:    dfeService,
+		statements:    statements,
+		retention:     retention,
+		purge:         purge,
+		offboarding:   offboarding,
+		archive:       archive,
+		billing:       billing,
+		lifecycle:     lifecycle,
+		emails:        emails,
+		idempotency:   idempotency,
+		outbox:        outboxRepo,
+		relay:         outbox.NewRelay(outboxRepo, repo, publisher, logger),
+		outboxRelay:   outboxRelay,
+		asyncLote:     asyncLote,
+		offline:       offline,
+		retry:         retry,
+		monitor:       monitor,
+		clock:         clockMonitor,
+		maintenance:   maintenance,
+		cacheMetrics:  cacheMetrics,
 		logger:        logger,
 		maxRetries:    maxRetries,
 		shutdown:      make(chan struct{}),
@@ -70,10 +155,147 @@ func (w *Worker) Start(ctx context.Context) error {
 		}
 	}()
 
+	// Start lote message consumer
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		err := w.consumer.ConsumeLote(ctx, w.handleLoteMessage)
+		if err != nil && err.Error() != "context canceled" {
+			w.logger.Error("Lote consumer error", logger.Field{Key: "error", Value: err.Error()})
+		}
+	}()
+
+	// Start export message consumer
+	if w.exportService != nil {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			err := w.consumer.ConsumeExport(ctx, w.handleExportMessage)
+			if err != nil && err.Error() != "context canceled" {
+				w.logger.Error("Export consumer error", logger.Field{Key: "error", Value: err.Error()})
+			}
+		}()
+
+		// Start expired exports cleanup
+		w.wg.Add(1)
+		go w.scheduleExportCleanup(ctx)
+	}
+
+	// Start inutilização message consumer
+	if w.inutService != nil {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			err := w.consumer.ConsumeInutilizacao(ctx, w.handleInutilizacaoMessage)
+			if err != nil && err.Error() != "context canceled" {
+				w.logger.Error("Inutilizacao consumer error", logger.Field{Key: "error", Value: err.Error()})
+			}
+		}()
+	}
+
+	// Start NFeDistribuicaoDFe sync of the due companies
+	if w.dfeService != nil {
+		w.wg.Add(1)
+		go w.scheduleDFeSync(ctx)
+	}
+
+	// Start generation of the monthly usage statements
+	if w.statements != nil && w.statements.Enabled() {
+		w.wg.Add(1)
+		go w.scheduleStatements(ctx)
+	}
+
+	// Start the sync of the plan retention with the bucket lifecycle rules
+	if w.retention != nil && w.retention.Enabled() {
+		w.wg.Add(1)
+		go w.scheduleStorageRetention(ctx)
+	}
+
+	// Start the purge of the NFC-e documents past the plan retention
+	if w.purge != nil && w.purge.Enabled() {
+		w.wg.Add(1)
+		go w.scheduleDocumentPurge(ctx)
+	}
+
+	// Start the anonymization and storage cleanup of the deleted companies
+	if w.offboarding != nil {
+		w.wg.Add(1)
+		go w.scheduleCompanyOffboarding(ctx)
+	}
+
+	// Start the deletion of the SEFAZ exchanges past the archive retention
+	if w.archive != nil {
+		w.wg.Add(1)
+		go w.scheduleSEFAZArchivePurge(ctx)
+	}
+
+	// Start the charges of the subscriptions through the payment gateway
+	if w.billing != nil && w.billing.Enabled() {
+		w.wg.Add(1)
+		go w.scheduleBilling(ctx)
+	}
+
+	// Start the trial, fixed-period and usage period transitions of the subscriptions
+	if w.lifecycle != nil && w.lifecycle.Enabled() {
+		w.wg.Add(1)
+		go w.scheduleSubscriptionLifecycle(ctx)
+	}
+
+	// Start removal of the expired Idempotency-Key records
+	if w.idempotency != nil {
+		w.wg.Add(1)
+		go w.scheduleIdempotencyCleanup(ctx)
+	}
+
 	// Start retry scheduler
 	w.wg.Add(1)
 	go w.scheduleRetries(ctx)
 
+	// Start relay of the outbox to the queue and removal of the relayed messages
+	if w.outbox != nil {
+		w.wg.Add(1)
+		go w.scheduleOutboxRelay(ctx)
+
+		w.wg.Add(1)
+		go w.scheduleOutboxCleanup(ctx)
+	}
+
+	// Start receipt polling of asynchronous lotes
+	if w.asyncLote.Enabled {
+		w.wg.Add(1)
+		go w.scheduleReceiptPolling(ctx)
+	}
+
+	// Start SEFAZ status monitoring of the authorizers in use
+	if w.monitor.Enabled() {
+		w.wg.Add(1)
+		go w.scheduleSEFAZMonitor(ctx)
+	}
+
+	// Start status service probes that end the contingency periods
+	if w.workerService.ContingencyProbeEnabled() {
+		w.wg.Add(1)
+		go w.scheduleContingencyProbe(ctx)
+	}
+
+	// Start clock skew checks of the host that stamps dhEmi
+	if w.clock.Enabled() {
+		w.wg.Add(1)
+		go w.scheduleClockCheck(ctx)
+	}
+
+	// Start transmission of NFC-e issued in offline contingency
+	if w.offline.Enabled {
+		w.wg.Add(1)
+		go w.scheduleOfflineTransmission(ctx)
+	}
+
+	// Start logging the hit ratio of the company and certificate cache
+	if w.cacheMetrics != nil {
+		w.wg.Add(1)
+		go w.scheduleCacheStats()
+	}
+
 	w.logger.Info("NFC-e worker started successfully")
 	return nil
 }
@@ -104,8 +326,8 @@ func (w *Worker) Stop(ctx context.Context) error {
 
 // handleEmitMessage processes a single emit message from the queue
 func (w *Worker) handleEmitMessage(ctx context.Context, msg dto.EmitMessage) error {
-	w.logger.Info("Processing NFC-e emission request",
-		logger.Field{Key: "request_id", Value: msg.RequestID},
+	log := w.logger.With(logger.RequestID(msg.RequestID))
+	log.Info("Processing NFC-e emission request",
 		logger.Field{Key: "idempotency_key", Value: msg.IdempotencyKey})
 
 	// Get the NFC-e request from database
@@ -114,22 +336,84 @@ func (w *Worker) handleEmitMessage(ctx context.Context, msg dto.EmitMessage) err
 		return fmt.Errorf("failed to get NFC-e request: %w", err)
 	}
 
+	// The domain services log through the request-scoped logger of the context
+	log = log.With(logger.CompanyID(nfceRequest.CompanyID))
+	ctx = logger.NewContext(ctx, log)
+
 	// Check idempotency - if already processed successfully, skip
 	if nfceRequest.Status == entity.RequestStatusAuthorized {
-		w.logger.Info("NFC-e already authorized, skipping")
+		log.Info("NFC-e already authorized, skipping")
+		return nil
+	}
+
+	// Rejected and canceled are final statuses, a redelivered message does not emit them again
+	if nfceRequest.Status.IsTerminal() {
+		log.Info("NFC-e in a final status, skipping",
+			logger.Field{Key: "status", Value: string(nfceRequest.Status)})
+		return nil
+	}
+
+	// The lote was already received by SEFAZ - the receipt polling fetches the result
+	if nfceRequest.IsAwaitingReceipt() {
+		log.Info("NFC-e awaiting SEFAZ receipt, skipping",
+			logger.Field{Key: "recibo", Value: nfceRequest.Recibo})
 		return nil
 	}
 
+	// Issued in offline contingency - the offline transmission sends it to SEFAZ
+	if nfceRequest.IsPendingTransmission() {
+		log.Info("NFC-e pending offline transmission, skipping")
+		return nil
+	}
+
+	// Retry scheduled by the broker: delay it again when it arrived early, then claim it so a
+	// duplicated message is emitted once
+	if nfceRequest.Status == entity.RequestStatusRetrying {
+		if nfceRequest.NextRetryAt != nil && time.Until(*nfceRequest.NextRetryAt) > retryEarlyTolerance {
+			return w.publishRetry(ctx, nfceRequest)
+		}
+
+		claimed, err := w.repo.ClaimRetry(ctx, nfceRequest, time.Now().Add(retryEarlyTolerance))
+		if err != nil {
+			return fmt.Errorf("failed to claim NFC-e retry: %w", err)
+		}
+		if !claimed {
+			log.Info("NFC-e retry already claimed, skipping")
+			return nil
+		}
+	}
+
+	// Reprocess asked by the admin: SEFAZ may already hold the NFC-e under the chave of the request
+	if msg.Resync {
+		if synced, err := w.workerService.SyncWithSEFAZ(ctx, nfceRequest); synced || err != nil {
+			return w.finishEmission(ctx, nfceRequest, err)
+		}
+	}
+
 	// Process the NFC-e emission
-	if err := w.workerService.ProcessNFceEmission(ctx, nfceRequest); err != nil {
-		w.logger.Error("NFC-e emission failed",
-			logger.Field{Key: "error", Value: err.Error()},
-			logger.Field{Key: "request_id", Value: nfceRequest.ID})
+	return w.finishEmission(ctx, nfceRequest, w.workerService.ProcessNFceEmission(ctx, nfceRequest))
+}
+
+// finishEmission schedules the retry of a failed emission and persists the resulting status.
+// ctx carries the logger scoped to the NFC-e.
+func (w *Worker) finishEmission(ctx context.Context, nfceRequest *entity.NFCE, err error) error {
+	log := logger.FromContext(ctx, w.logger)
+	var quota *entity.QuotaContext
+	if err != nil {
+		log.Error("NFC-e emission failed", logger.Err(err))
+
+		if errors.Is(err, service.ErrFeatureNotInPlan) {
+			log.Warn("Contingency blocked by company plan")
+		}
+		quota = service.QuotaContextOf(err)
 
 		// Check if the error indicates the request was already marked as rejected
-		if nfceRequest.Status == entity.RequestStatusRejected {
+		if errors.Is(err, service.ErrSEFAZUnavailable) {
+			// Known outage without contingency - hold the NFC-e without spending a retry
+			w.delayDispatch(nfceRequest, w.monitor.PollInterval())
+		} else if nfceRequest.Status == entity.RequestStatusRejected {
 			// Request was marked as rejected due to non-retryable error
-			w.logger.Info("NFC-e rejected due to non-retryable error",
+			log.Info("NFC-e rejected due to non-retryable error",
 				logger.Field{Key: "cstat", Value: nfceRequest.CStat},
 				logger.Field{Key: "motivo", Value: nfceRequest.XMotivo})
 		} else {
@@ -143,36 +427,78 @@ func (w *Worker) handleEmitMessage(ctx context.Context, msg dto.EmitMessage) err
 		}
 	}
 
-	// Update the request in database
-	if err := w.repo.Update(ctx, nfceRequest); err != nil {
-		return fmt.Errorf("failed to update NFC-e request: %w", err)
-	}
-
 	// Create event for tracking
 	event := &entity.Event{
-		ID:         fmt.Sprintf("%s-%d", nfceRequest.ID, time.Now().Unix()),
-		RequestID:  nfceRequest.ID,
-		StatusFrom: entity.RequestStatusProcessing,
-		StatusTo:   nfceRequest.Status,
-		CStat:      nfceRequest.CStat,
-		Message:    nfceRequest.XMotivo,
-		CreatedAt:  time.Now(),
+		CStat:    nfceRequest.CStat,
+		Message:  nfceRequest.XMotivo,
+		Metadata: sefazExchangeMetadata(nfceRequest),
+	}
+	if quota != nil {
+		// Lets event subscribers tell a plan block apart from a SEFAZ failure
+		if event.Metadata == nil {
+			event.Metadata = map[string]interface{}{}
+		}
+		event.Metadata["quota_context"] = quota
 	}
 
-	if err := w.repo.CreateEvent(ctx, event); err != nil {
-		w.logger.Error("Failed to create event", logger.Field{Key: "error", Value: err.Error()})
+	// Update the request and record the event atomically
+	if err := w.persistStatusChange(ctx, nfceRequest, event, nfceRequest.Status == entity.RequestStatusAuthorized); err != nil {
+		return err
 	}
+	w.publishEvent(ctx, nfceRequest.CompanyID, event)
 
-	w.logger.Info("NFC-e emission completed",
+	w.enqueueRetry(ctx, nfceRequest)
+
+	log.Info("NFC-e emission completed",
 		logger.Field{Key: "status", Value: string(nfceRequest.Status)})
 
 	return nil
 }
 
+// handleLoteMessage processes the emission of the NFC-e of a lote. The NFC-e already
+// processed are skipped, so a redelivered message resumes the lote.
+func (w *Worker) handleLoteMessage(ctx context.Context, msg dto.LoteMessage) error {
+	log := w.logger.With(logger.Field{Key: "lote_id", Value: msg.LoteID})
+	log.Info("Processing NFC-e lote emission")
+
+	requests, err := w.repo.ListByLote(ctx, msg.LoteID)
+	if err != nil {
+		return fmt.Errorf("failed to get lote NFC-e requests: %w", err)
+	}
+
+	var eligible []*entity.NFCE
+	for _, nfceRequest := range requests {
+		switch {
+		case nfceRequest.IsAwaitingReceipt(), nfceRequest.IsPendingTransmission():
+			continue
+		case nfceRequest.Status == entity.RequestStatusPending,
+			nfceRequest.Status == entity.RequestStatusQueuedDeferred,
+			nfceRequest.Status == entity.RequestStatusProcessing:
+			eligible = append(eligible, nfceRequest)
+		}
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+
+	var failed error
+	errs := w.workerService.ProcessLoteEmission(logger.NewContext(ctx, log), eligible)
+	for i, nfceRequest := range eligible {
+		requestCtx := logger.NewContext(ctx, log.With(logger.RequestID(nfceRequest.ID), logger.CompanyID(nfceRequest.CompanyID)))
+		if err := w.finishEmission(requestCtx, nfceRequest, errs[i]); err != nil {
+			failed = err
+		}
+	}
+
+	log.Info("NFC-e lote emission completed", logger.Field{Key: "count", Value: len(eligible)})
+
+	return failed
+}
+
 // handleCancelMessage processes a single cancel message from the queue
 func (w *Worker) handleCancelMessage(ctx context.Context, msg dto.CancelMessage) error {
-	w.logger.Info("Processing NFC-e cancellation request",
-		logger.Field{Key: "request_id", Value: msg.RequestID},
+	log := w.logger.With(logger.RequestID(msg.RequestID))
+	log.Info("Processing NFC-e cancellation request",
 		logger.Field{Key: "idempotency_key", Value: msg.IdempotencyKey},
 		logger.Field{Key: "justificativa", Value: msg.Justificativa})
 
@@ -182,54 +508,503 @@ func (w *Worker) handleCancelMessage(ctx context.Context, msg dto.CancelMessage)
 		return fmt.Errorf("failed to get NFC-e request: %w", err)
 	}
 
+	log = log.With(logger.CompanyID(nfceRequest.CompanyID), logger.Chave(nfceRequest.ChaveAcesso))
+	ctx = logger.NewContext(ctx, log)
+
 	// Check if already canceled
 	if nfceRequest.Status == entity.RequestStatusCanceled {
-		w.logger.Info("NFC-e already canceled, skipping")
+		log.Info("NFC-e already canceled, skipping")
 		return nil
 	}
 
-	// Check if can be canceled (must be authorized)
-	if nfceRequest.Status != entity.RequestStatusAuthorized {
-		w.logger.Warn("Cannot cancel NFC-e that is not authorized",
+	// Check if can be canceled (must be authorized, or locked as processing by the cancel request)
+	if nfceRequest.Status != entity.RequestStatusAuthorized &&
+		!(nfceRequest.Status == entity.RequestStatusProcessing && nfceRequest.Protocolo != "") {
+		log.Warn("Cannot cancel NFC-e that is not authorized",
 			logger.Field{Key: "current_status", Value: string(nfceRequest.Status)})
 		return fmt.Errorf("NFC-e must be authorized to be canceled")
 	}
 
 	// Process the NFC-e cancellation
 	if err := w.workerService.ProcessNFceCancellation(ctx, nfceRequest, msg.Justificativa); err != nil {
-		w.logger.Error("NFC-e cancellation failed",
-			logger.Field{Key: "error", Value: err.Error()},
-			logger.Field{Key: "request_id", Value: nfceRequest.ID})
-
-		// For cancellation, we might want to retry on failure
-		return fmt.Errorf("NFC-e cancellation failed: %w", err)
-	}
+		log.Error("NFC-e cancellation failed", logger.Err(err))
 
-	// Update the request in database
-	if err := w.repo.Update(ctx, nfceRequest); err != nil {
-		return fmt.Errorf("failed to update NFC-e request: %w", err)
+		if !errors.Is(err, service.ErrCancellationRejected) {
+			// For cancellation, we might want to retry on failure
+			return fmt.Errorf("NFC-e cancellation failed: %w", err)
+		}
 	}
 
 	// Create event for tracking
 	event := &entity.Event{
-		ID:         fmt.Sprintf("%s-cancel-%d", nfceRequest.ID, time.Now().Unix()),
-		RequestID:  nfceRequest.ID,
-		StatusFrom: entity.RequestStatusAuthorized,
-		StatusTo:   entity.RequestStatusCanceled,
-		Message:    fmt.Sprintf("Cancelado: %s", msg.Justificativa),
-		CreatedAt:  time.Now(),
+		CStat:   nfceRequest.CStat,
+		Message: nfceRequest.XMotivo,
+	}
+	if nfceRequest.Status == entity.RequestStatusCanceled {
+		event.CStat = ""
+		event.Message = fmt.Sprintf("Cancelado: %s", msg.Justificativa)
+	}
+
+	// Update the request and record the event atomically
+	if err := w.persistStatusChange(ctx, nfceRequest, event, false); err != nil {
+		return err
+	}
+	w.publishEvent(ctx, nfceRequest.CompanyID, event)
+
+	if nfceRequest.Status != entity.RequestStatusCanceled {
+		log.Warn("NFC-e cancellation rejected by SEFAZ",
+			logger.Field{Key: "cstat", Value: nfceRequest.CStat},
+			logger.Field{Key: "motivo", Value: nfceRequest.XMotivo})
+		return nil
+	}
+
+	// Notify company webhooks
+	if w.webhooks != nil {
+		payload := map[string]interface{}{
+			"id":              nfceRequest.ID,
+			"chave_acesso":    nfceRequest.ChaveAcesso,
+			"protocolo":       nfceRequest.CancelProtocolo,
+			"justificativa":   msg.Justificativa,
+			"canceled_at":     nfceRequest.CanceledAt,
+			"cancel_xml_url":  nfceRequest.CancelXMLURL,
+			"idempotency_key": nfceRequest.IdempotencyKey,
+			"ambiente":        nfceRequest.Payload.Ambiente,
+			"modelo":          nfceRequest.Payload.DocumentModel(),
+			"serie":           nfceRequest.Serie,
+			"uf":              nfceRequest.Payload.UF,
+			"valor_total":     nfceRequest.Payload.Total(),
+			"metadata":        nfceRequest.Metadata,
+		}
+		if err := w.webhooks.Dispatch(ctx, nfceRequest.CompanyID, entity.WebhookEventNFCECanceled, payload); err != nil {
+			log.Error("Failed to dispatch cancel webhook", logger.Err(err))
+		}
+	}
+
+	log.Info("NFC-e cancellation completed",
+		logger.Field{Key: "protocolo", Value: nfceRequest.CancelProtocolo})
+
+	return nil
+}
+
+// handleExportMessage processes a bulk export job from the queue
+func (w *Worker) handleExportMessage(ctx context.Context, msg dto.ExportMessage) error {
+	log := w.logger.With(logger.Field{Key: "job_id", Value: msg.JobID})
+	log.Info("Processing export job")
+
+	if err := w.exportService.ProcessExport(logger.NewContext(ctx, log), msg.JobID); err != nil {
+		log.Error("Export job failed", logger.Err(err))
+		return fmt.Errorf("export job failed: %w", err)
+	}
+
+	log.Info("Export job finished")
+	return nil
+}
+
+// handleInutilizacaoMessage processes a number range inutilização from the queue
+func (w *Worker) handleInutilizacaoMessage(ctx context.Context, msg dto.InutilizacaoMessage) error {
+	log := w.logger.With(logger.Field{Key: "inutilizacao_id", Value: msg.InutilizacaoID})
+	log.Info("Processing inutilizacao")
+
+	inutilizacao, err := w.inutService.ProcessInutilizacao(logger.NewContext(ctx, log), msg.InutilizacaoID)
+	if err != nil {
+		log.Error("Inutilizacao failed", logger.Err(err))
+		return fmt.Errorf("inutilizacao failed: %w", err)
+	}
+
+	log.Info("Inutilizacao finished",
+		logger.Field{Key: "status", Value: string(inutilizacao.Status)},
+		logger.Field{Key: "protocolo", Value: inutilizacao.Protocolo})
+	return nil
+}
+
+// scheduleExportCleanup periodically removes the parts of expired export jobs
+func (w *Worker) scheduleExportCleanup(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			if w.underMaintenance(ctx) {
+				continue
+			}
+			cleaned, err := w.exportService.CleanupExpired(ctx, 50)
+			if err != nil {
+				w.logger.Error("Failed to cleanup expired exports", logger.Field{Key: "error", Value: err.Error()})
+			}
+			if cleaned > 0 {
+				w.logger.Info("Expired exports cleaned up", logger.Field{Key: "count", Value: cleaned})
+			}
+		}
+	}
+}
+
+// scheduleIdempotencyCleanup periodically removes the expired Idempotency-Key records
+func (w *Worker) scheduleIdempotencyCleanup(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			if w.underMaintenance(ctx) {
+				continue
+			}
+			purged, err := w.idempotency.PurgeExpired(ctx)
+			if err != nil {
+				w.logger.Error("Failed to purge expired idempotency keys", logger.Field{Key: "error", Value: err.Error()})
+			}
+			if purged > 0 {
+				w.logger.Info("Expired idempotency keys purged", logger.Field{Key: "count", Value: purged})
+			}
+		}
+	}
+}
+
+// scheduleDFeSync periodically syncs the NFeDistribuicaoDFe cursors that are due
+func (w *Worker) scheduleDFeSync(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.dfeService.SyncInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			if w.underMaintenance(ctx) {
+				continue
+			}
+			if err := w.processDueDFeCursors(ctx); err != nil {
+				w.logger.Error("Failed to process due DF-e cursors", logger.Field{Key: "error", Value: err.Error()})
+			}
+		}
+	}
+}
+
+// scheduleStatements periodically generates the usage statements of the month that ended
+func (w *Worker) scheduleStatements(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			if w.underMaintenance(ctx) {
+				continue
+			}
+			generated, err := w.statements.GenerateDue(ctx, time.Now())
+			if err != nil {
+				w.logger.Error("Failed to generate usage statements", logger.Field{Key: "error", Value: err.Error()})
+			}
+			if generated > 0 {
+				w.logger.Info("Usage statements generated", logger.Field{Key: "count", Value: generated})
+			}
+		}
+	}
+}
+
+// scheduleStorageRetention periodically syncs the bucket lifecycle rules with the plan
+// retention of each company, starting right away so a plan change applies on restart
+func (w *Worker) scheduleStorageRetention(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.retention.Interval())
+	defer ticker.Stop()
+
+	for {
+		if !w.underMaintenance(ctx) {
+			rules, err := w.retention.Sync(ctx)
+			if err != nil {
+				w.logger.Error("Failed to sync storage retention", logger.Field{Key: "error", Value: err.Error()})
+			} else {
+				w.logger.Info("Storage retention synced", logger.Field{Key: "rules", Value: rules})
+			}
+		}
+
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleDocumentPurge deletes the documents of the NFC-e past the plan retention right away
+// and then on every interval
+func (w *Worker) scheduleDocumentPurge(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.purge.Interval())
+	defer ticker.Stop()
+
+	for {
+		if !w.underMaintenance(ctx) {
+			purged, err := w.purge.PurgeDue(ctx, time.Now())
+			if err != nil {
+				w.logger.Error("Failed to purge NFC-e documents",
+					logger.Field{Key: "purged", Value: purged},
+					logger.Field{Key: "error", Value: err.Error()})
+			} else if purged > 0 {
+				w.logger.Info("NFC-e documents purged", logger.Field{Key: "purged", Value: purged})
+			}
+		}
+
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleCompanyOffboarding anonymizes the deleted companies and deletes their documents once
+// the grace period ends, right away and then on every interval
+func (w *Worker) scheduleCompanyOffboarding(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.offboarding.Interval())
+	defer ticker.Stop()
+
+	for {
+		if !w.underMaintenance(ctx) {
+			purged, err := w.offboarding.ProcessDeleted(ctx, time.Now())
+			if err != nil {
+				w.logger.Error("Failed to process deleted companies",
+					logger.Field{Key: "purged", Value: purged},
+					logger.Field{Key: "error", Value: err.Error()})
+			} else if purged > 0 {
+				w.logger.Info("Documents of deleted companies purged", logger.Field{Key: "purged", Value: purged})
+			}
+		}
+
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleSEFAZArchivePurge deletes the SEFAZ exchanges past the archive retention right away
+// and then on every interval
+func (w *Worker) scheduleSEFAZArchivePurge(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.archive.Interval())
+	defer ticker.Stop()
+
+	for {
+		if !w.underMaintenance(ctx) {
+			purged, err := w.archive.PurgeExpired(ctx, time.Now())
+			if err != nil {
+				w.logger.Error("Failed to purge SEFAZ exchanges",
+					logger.Field{Key: "purged", Value: purged},
+					logger.Field{Key: "error", Value: err.Error()})
+			} else if purged > 0 {
+				w.logger.Info("SEFAZ exchanges purged", logger.Field{Key: "purged", Value: purged})
+			}
+		}
+
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+		}
 	}
+}
+
+// scheduleBilling charges the subscriptions due and the failed invoices due for a retry right
+// away and then on every interval
+func (w *Worker) scheduleBilling(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.billing.Interval())
+	defer ticker.Stop()
+
+	for {
+		if !w.underMaintenance(ctx) {
+			paid, err := w.billing.BillDue(ctx, time.Now())
+			if err != nil {
+				w.logger.Error("Failed to bill subscriptions",
+					logger.Field{Key: "paid", Value: paid},
+					logger.Field{Key: "error", Value: err.Error()})
+			} else if paid > 0 {
+				w.logger.Info("Subscription invoices paid", logger.Field{Key: "paid", Value: paid})
+			}
+		}
+
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleSubscriptionLifecycle expires the ended trials and fixed-period subscriptions and
+// renews the ended usage periods right away and then on every interval
+func (w *Worker) scheduleSubscriptionLifecycle(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.lifecycle.Interval())
+	defer ticker.Stop()
+
+	for {
+		if !w.underMaintenance(ctx) {
+			run, err := w.lifecycle.RunDue(ctx, time.Now())
+			if err != nil {
+				w.logger.Error("Failed to run subscription lifecycle",
+					logger.Field{Key: "expired", Value: run.Expired},
+					logger.Field{Key: "error", Value: err.Error()})
+			} else if run.Expired > 0 || run.Converted > 0 || run.Renewed > 0 {
+				w.logger.Info("Subscription lifecycle applied",
+					logger.Field{Key: "expired", Value: run.Expired},
+					logger.Field{Key: "converted", Value: run.Converted},
+					logger.Field{Key: "renewed", Value: run.Renewed})
+			}
+		}
+
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processDueDFeCursors syncs the companies whose distribution cursor is due
+func (w *Worker) processDueDFeCursors(ctx context.Context) error {
+	cursors, err := w.dfeService.ListDue(ctx, 20)
+	if err != nil {
+		return fmt.Errorf("failed to list due dfe cursors: %w", err)
+	}
+
+	for _, due := range cursors {
+		cursor, err := w.dfeService.Sync(ctx, due.CompanyID)
+		if err != nil {
+			w.logger.Error("Failed to sync DF-e distribution",
+				logger.Field{Key: "company_id", Value: due.CompanyID},
+				logger.Field{Key: "error", Value: err.Error()})
+			continue
+		}
+
+		w.logger.Info("DF-e distribution synced",
+			logger.Field{Key: "company_id", Value: cursor.CompanyID},
+			logger.Field{Key: "ult_nsu", Value: cursor.UltNSU},
+			logger.Field{Key: "max_nsu", Value: cursor.MaxNSU},
+			logger.Field{Key: "cstat", Value: cursor.CStat})
+	}
+
+	return nil
+}
+
+// sefazExchangeMetadata links the event to the SEFAZ exchanges archived while the request was
+// processed, nil when there are none
+func sefazExchangeMetadata(nfceRequest *entity.NFCE) map[string]interface{} {
+	ids := nfceRequest.TakeSEFAZExchanges()
+	if len(ids) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"sefaz_exchanges": ids}
+}
 
-	if err := w.repo.CreateEvent(ctx, event); err != nil {
-		w.logger.Error("Failed to create cancel event", logger.Field{Key: "error", Value: err.Error()})
+// persistStatusChange saves the status of the request with its transition event in a single
+// transaction; the repository fills the event with the status the request left. With recordUsage the authorized NFC-e is counted against the company quota in the same
+// transaction, and the quota.exceeded webhook and the consumer e-mail go out once it commits.
+func (w *Worker) persistStatusChange(ctx context.Context, nfceRequest *entity.NFCE, event *entity.Event, recordUsage bool) error {
+	var exceeded *entity.QuotaContext
+	persist := func(ctx context.Context) error {
+		if err := w.repo.Transition(ctx, nfceRequest, event); err != nil {
+			return fmt.Errorf("failed to update NFC-e status: %w", err)
+		}
+		if recordUsage {
+			quota, err := w.workerService.RecordUsage(ctx, nfceRequest)
+			if err != nil {
+				return fmt.Errorf("failed to record NFC-e usage: %w", err)
+			}
+			exceeded = quota
+		}
+		return nil
 	}
 
-	w.logger.Info("NFC-e cancellation completed",
-		logger.Field{Key: "request_id", Value: nfceRequest.ID})
+	var err error
+	if w.txManager == nil {
+		err = persist(ctx)
+	} else {
+		err = w.txManager.WithinTx(ctx, persist)
+	}
+	if err != nil {
+		return err
+	}
 
+	if exceeded != nil {
+		w.logger.Warn("NFC-e quota exhausted",
+			logger.Field{Key: "company_id", Value: nfceRequest.CompanyID},
+			logger.Field{Key: "request_id", Value: nfceRequest.ID})
+		w.workerService.NotifyQuotaExceeded(ctx, nfceRequest, exceeded)
+	}
+	if recordUsage {
+		w.emailDocuments(ctx, nfceRequest)
+	}
 	return nil
 }
 
+// emailDocuments e-mails the XML and DANFE of a newly authorized NFC-e to the consumer in the
+// background, so the queue does not wait on the e-mail provider; Stop waits for the send
+func (w *Worker) emailDocuments(ctx context.Context, nfceRequest *entity.NFCE) {
+	if w.emails == nil || !w.emails.Enabled() {
+		return
+	}
+
+	request := *nfceRequest
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), emailSendTimeout)
+		defer cancel()
+
+		delivery, err := w.emails.SendAuthorized(ctx, &request)
+		if err != nil {
+			w.logger.Warn("Failed to e-mail NFC-e documents",
+				logger.Field{Key: "request_id", Value: request.ID},
+				logger.Field{Key: "error", Value: err.Error()})
+			return
+		}
+		if delivery != nil {
+			w.logger.Info("NFC-e documents e-mailed",
+				logger.Field{Key: "request_id", Value: request.ID},
+				logger.Field{Key: "delivery_id", Value: delivery.ID})
+		}
+	}()
+}
+
+// publishEvent broadcasts a persisted status change event to real-time subscribers
+func (w *Worker) publishEvent(ctx context.Context, companyID string, event *entity.Event) {
+	if w.eventBus == nil {
+		return
+	}
+	if err := w.eventBus.Publish(ctx, companyID, event); err != nil {
+		w.logger.Warn("Failed to publish status change event",
+			logger.Field{Key: "request_id", Value: event.RequestID},
+			logger.Field{Key: "error", Value: err.Error()})
+	}
+}
+
 // scheduleRetry schedules a retry for the NFC-e request
 func (w *Worker) scheduleRetry(ctx context.Context, nfceRequest *entity.NFCE) {
 	w.workerService.IncrementRetry(nfceRequest)
@@ -238,6 +1013,11 @@ func (w *Worker) scheduleRetry(ctx context.Context, nfceRequest *entity.NFCE) {
 	delay := w.calculateBackoffDelay(nfceRequest.RetryCount)
 	nextRetryAt := time.Now().Add(delay)
 
+	// Never push the retry past the 48h cutoff: the last attempt runs shortly before it
+	if deadline := nfceRequest.RetryDeadline().Add(-retryDeadlineMargin); nextRetryAt.After(deadline) && deadline.After(time.Now()) {
+		nextRetryAt = deadline
+	}
+
 	nfceRequest.NextRetryAt = &nextRetryAt
 	nfceRequest.Status = entity.RequestStatusRetrying
 
@@ -249,6 +1029,51 @@ func (w *Worker) scheduleRetry(ctx context.Context, nfceRequest *entity.NFCE) {
 		logger.Field{Key: "created_at", Value: nfceRequest.CreatedAt})
 }
 
+// enqueueRetry hands the persisted retry of the NFC-e, if any, to the broker
+func (w *Worker) enqueueRetry(ctx context.Context, nfceRequest *entity.NFCE) {
+	if nfceRequest.Status != entity.RequestStatusRetrying {
+		return
+	}
+	if err := w.publishRetry(ctx, nfceRequest); err != nil {
+		// The retry scheduler recovers it once overdue
+		w.logger.Warn("Failed to schedule retry on the broker",
+			logger.Field{Key: "request_id", Value: nfceRequest.ID},
+			logger.Field{Key: "error", Value: err.Error()})
+	}
+}
+
+// publishRetry publishes the emission message of the retry to the broker, delayed until its
+// next_retry_at
+func (w *Worker) publishRetry(ctx context.Context, nfceRequest *entity.NFCE) error {
+	var delay time.Duration
+	if nfceRequest.NextRetryAt != nil {
+		delay = time.Until(*nfceRequest.NextRetryAt)
+	}
+
+	emitMsg := dto.EmitMessage{
+		RequestID:      nfceRequest.ID,
+		CompanyID:      nfceRequest.CompanyID,
+		IdempotencyKey: nfceRequest.IdempotencyKey,
+		EnqueuedAt:     time.Now(),
+	}
+	if err := w.publisher.PublishEmitAfter(ctx, emitMsg, delay); err != nil {
+		return fmt.Errorf("failed to publish retry message: %w", err)
+	}
+	return nil
+}
+
+// delayDispatch schedules the NFC-e again after the delay without counting a retry
+func (w *Worker) delayDispatch(nfceRequest *entity.NFCE, delay time.Duration) {
+	nextRetryAt := time.Now().Add(delay)
+	nfceRequest.NextRetryAt = &nextRetryAt
+	nfceRequest.Status = entity.RequestStatusRetrying
+
+	w.logger.Info("Dispatch delayed while SEFAZ is unavailable",
+		logger.Field{Key: "request_id", Value: nfceRequest.ID},
+		logger.Field{Key: "uf", Value: nfceRequest.Payload.UF},
+		logger.Field{Key: "next_retry_at", Value: nextRetryAt})
+}
+
 // calculateBackoffDelay calculates exponential backoff delay with jitter
 func (w *Worker) calculateBackoffDelay(retryCount int) time.Duration {
 	// Base delay: 1 minute
@@ -285,49 +1110,62 @@ func (w *Worker) getRandomFloat() float64 {
 	return r.Float64() // Returns [0.0, 1.0)
 }
 
-// scheduleRetries periodically checks for and processes retry requests
+// scheduleRetries periodically recovers the retries the broker did not deliver, e.g. when
+// their delayed message could not be published; the interval between runs adapts to the
+// backlog of overdue retries
 func (w *Worker) scheduleRetries(ctx context.Context) {
 	defer w.wg.Done()
 
-	ticker := time.NewTicker(15 * time.Second) // Check every 15 seconds for faster retries
-	defer ticker.Stop()
+	timer := time.NewTimer(w.retry.MinInterval)
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-w.shutdown:
 			return
-		case <-ticker.C:
-			if err := w.processPendingRetries(ctx); err != nil {
-				w.logger.Error("Failed to process pending retries", logger.Field{Key: "error", Value: err.Error()})
+		case <-timer.C:
+			interval := w.retry.MaxInterval
+			if !w.underMaintenance(ctx) {
+				next, err := w.processPendingRetries(ctx)
+				if err != nil {
+					w.logger.Error("Failed to process pending retries", logger.Field{Key: "error", Value: err.Error()})
+				} else {
+					interval = next
+				}
 			}
+			timer.Reset(interval)
 		}
 	}
 }
 
-// processPendingRetries finds and processes NFC-e requests that are due for retry
-func (w *Worker) processPendingRetries(ctx context.Context) error {
-	// Get requests that are due for retry
-	requests, err := w.repo.GetPendingRetries(ctx, time.Now(), 10) // Process up to 10 at a time
+// processPendingRetries claims the retries overdue past the recovery grace, nearest to the
+// 48h cutoff first, publishes them and returns the wait until the next run
+func (w *Worker) processPendingRetries(ctx context.Context) (time.Duration, error) {
+	now := time.Now()
+	overdue := now.Add(-retryRecoveryGrace)
+	backlog, err := w.repo.GetRetryBacklog(ctx, overdue)
 	if err != nil {
-		return fmt.Errorf("failed to get pending retries: %w", err)
+		return 0, fmt.Errorf("failed to get retry backlog: %w", err)
 	}
 
-	for _, req := range requests {
-		// Reset status to processing and clear next retry time
-		req.Status = entity.RequestStatusProcessing
-		req.NextRetryAt = nil
+	batch, interval := w.retry.Plan(backlog)
+	if backlog.Due == 0 || batch == 0 {
+		w.recordRetryRun(backlog, batch, 0, interval, now)
+		return interval, nil
+	}
 
-		// Update in database
-		if err := w.repo.Update(ctx, req); err != nil {
-			w.logger.Error("Failed to update retry request",
-				logger.Field{Key: "request_id", Value: req.ID},
-				logger.Field{Key: "error", Value: err.Error()})
-			continue
-		}
+	// Claimed rows move to processing, so other workers skip them
+	requests, err := w.repo.ClaimDueRetries(ctx, overdue, batch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim pending retries: %w", err)
+	}
 
+	picked := 0
+	for _, req := range requests {
 		// Publish to queue for immediate processing
 		emitMsg := dto.EmitMessage{
 			RequestID:      req.ID,
+			CompanyID:      req.CompanyID,
 			IdempotencyKey: req.IdempotencyKey,
 			EnqueuedAt:     time.Now(),
 		}
@@ -336,8 +1174,300 @@ func (w *Worker) processPendingRetries(ctx context.Context) error {
 			w.logger.Error("Failed to publish retry message",
 				logger.Field{Key: "request_id", Value: req.ID},
 				logger.Field{Key: "error", Value: err.Error()})
+
+			// Hand the retry back to the next run
+			req.Status = entity.RequestStatusRetrying
+			req.NextRetryAt = &now
+			if err := w.repo.Transition(ctx, req, &entity.Event{Message: "Retentativa devolvida para a próxima execução"}); err != nil {
+				w.logger.Error("Failed to release retry request",
+					logger.Field{Key: "request_id", Value: req.ID},
+					logger.Field{Key: "error", Value: err.Error()})
+			}
+			continue
+		}
+		picked++
+	}
+
+	w.recordRetryRun(backlog, batch, picked, interval, now)
+	return interval, nil
+}
+
+// scheduleReceiptPolling periodically queries SEFAZ for the result of asynchronous lotes
+func (w *Worker) scheduleReceiptPolling(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.asyncLote.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			if err := w.processPendingReceipts(ctx); err != nil {
+				w.logger.Error("Failed to poll SEFAZ receipts", logger.Field{Key: "error", Value: err.Error()})
+			}
 		}
 	}
+}
+
+// processPendingReceipts queries NFeRetAutorizacao4 for the requests whose next poll is due
+func (w *Worker) processPendingReceipts(ctx context.Context) error {
+	requests, err := w.repo.GetAwaitingReceipt(ctx, time.Now(), 10)
+	if err != nil {
+		return fmt.Errorf("failed to get requests awaiting receipt: %w", err)
+	}
+
+	for _, req := range requests {
+		recibo := req.Recibo
+		done, err := w.workerService.PollReceipt(ctx, req)
+		if err != nil {
+			w.logger.Warn("SEFAZ receipt query failed",
+				logger.Field{Key: "request_id", Value: req.ID},
+				logger.Field{Key: "recibo", Value: recibo},
+				logger.Field{Key: "error", Value: err.Error()})
+		}
+
+		if !done {
+			req.ReciboPolls++
+			if req.ReciboPolls < w.asyncLote.MaxPolls {
+				nextPoll := time.Now().Add(w.asyncLote.PollInterval)
+				req.NextRetryAt = &nextPoll
+				if err := w.repo.Update(ctx, req); err != nil {
+					w.logger.Error("Failed to update receipt polling",
+						logger.Field{Key: "request_id", Value: req.ID},
+						logger.Field{Key: "error", Value: err.Error()})
+				}
+				continue
+			}
+
+			// Polls exhausted - submit again; the retry looks the lote NFC-e up through NfeConsultaProtocolo4 first
+			w.logger.Warn("SEFAZ receipt polling exhausted",
+				logger.Field{Key: "request_id", Value: req.ID},
+				logger.Field{Key: "recibo", Value: recibo},
+				logger.Field{Key: "polls", Value: req.ReciboPolls})
+			req.ClearReceipt()
+			if w.workerService.CanRetry(req, w.maxRetries) {
+				w.scheduleRetry(ctx, req)
+			} else {
+				req.MarkAsRejected("999", "Número máximo de tentativas excedido")
+			}
+		}
+
+		event := &entity.Event{
+			CStat:   req.CStat,
+			Message: req.XMotivo,
+		}
+
+		if err := w.persistStatusChange(ctx, req, event, req.Status == entity.RequestStatusAuthorized); err != nil {
+			w.logger.Error("Failed to persist receipt result",
+				logger.Field{Key: "request_id", Value: req.ID},
+				logger.Field{Key: "error", Value: err.Error()})
+			continue
+		}
+		w.publishEvent(ctx, req.CompanyID, event)
+		w.enqueueRetry(ctx, req)
+
+		w.logger.Info("NFC-e receipt processed",
+			logger.Field{Key: "request_id", Value: req.ID},
+			logger.Field{Key: "recibo", Value: recibo},
+			logger.Field{Key: "status", Value: string(req.Status)})
+	}
+
+	return nil
+}
+
+// scheduleOfflineTransmission periodically transmits the NFC-e issued in offline contingency
+func (w *Worker) scheduleOfflineTransmission(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.offline.TransmitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			if w.underMaintenance(ctx) {
+				continue
+			}
+			if err := w.processPendingTransmissions(ctx); err != nil {
+				w.logger.Error("Failed to transmit offline NFC-e", logger.Field{Key: "error", Value: err.Error()})
+			}
+		}
+	}
+}
+
+// processPendingTransmissions sends the NFC-e pending transmission to SEFAZ once
+// NFeStatusServico4 reports the authorizer of their UF back in operation
+func (w *Worker) processPendingTransmissions(ctx context.Context) error {
+	requests, err := w.repo.GetPendingTransmission(ctx, 50)
+	if err != nil {
+		return fmt.Errorf("failed to get requests pending transmission: %w", err)
+	}
+
+	// The service status is queried once per UF and ambiente
+	available := make(map[string]bool)
+	for _, req := range requests {
+		key := req.Payload.UF + "/" + req.Payload.Ambiente
+		up, checked := available[key]
+		if !checked {
+			up = w.workerService.IsSEFAZAvailable(ctx, req.Payload.UF, req.Payload.Ambiente)
+			available[key] = up
+		}
+		if !up {
+			continue
+		}
+
+		done, err := w.workerService.TransmitOffline(ctx, req)
+		if err != nil {
+			w.logger.Warn("Offline NFC-e transmission failed",
+				logger.Field{Key: "request_id", Value: req.ID},
+				logger.Field{Key: "chave_acesso", Value: req.ChaveAcesso},
+				logger.Field{Key: "error", Value: err.Error()})
+		}
+		if !done {
+			continue
+		}
+
+		event := &entity.Event{
+			CStat:    req.CStat,
+			Message:  req.XMotivo,
+			Metadata: sefazExchangeMetadata(req),
+		}
+
+		if err := w.persistStatusChange(ctx, req, event, req.Status == entity.RequestStatusAuthorized); err != nil {
+			w.logger.Error("Failed to persist offline transmission result",
+				logger.Field{Key: "request_id", Value: req.ID},
+				logger.Field{Key: "error", Value: err.Error()})
+			continue
+		}
+		w.publishEvent(ctx, req.CompanyID, event)
+
+		w.logger.Info("Offline NFC-e transmitted",
+			logger.Field{Key: "request_id", Value: req.ID},
+			logger.Field{Key: "chave_acesso", Value: req.ChaveAcesso},
+			logger.Field{Key: "status", Value: string(req.Status)})
+	}
 
 	return nil
 }
+
+// underMaintenance reports whether the schedulers must pause for a maintenance window.
+// Messages already queued are still processed so in-flight emissions finish.
+func (w *Worker) underMaintenance(ctx context.Context) bool {
+	if w.maintenance == nil {
+		return false
+	}
+
+	window, err := w.maintenance.Current(ctx)
+	if err != nil {
+		w.logger.Error("Failed to check maintenance window", logger.Field{Key: "error", Value: err.Error()})
+		return false
+	}
+	return window != nil
+}
+
+// scheduleClockCheck measures the host clock skew right away and then periodically;
+// the monitor warns when it goes beyond the threshold
+func (w *Worker) scheduleClockCheck(ctx context.Context) {
+	defer w.wg.Done()
+
+	w.clock.Check(ctx)
+
+	ticker := time.NewTicker(w.clock.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			w.clock.Check(ctx)
+		}
+	}
+}
+
+// scheduleCacheStats logs the lookups of the company and certificate cache since the worker started
+func (w *Worker) scheduleCacheStats() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(cacheStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			for kind, stats := range w.cacheMetrics.Snapshot() {
+				w.logger.Info("Cache lookups",
+					logger.Field{Key: "kind", Value: kind},
+					logger.Field{Key: "hits", Value: stats.Hits},
+					logger.Field{Key: "misses", Value: stats.Misses},
+					logger.Field{Key: "errors", Value: stats.Errors},
+					logger.Field{Key: "hit_ratio", Value: stats.HitRatio})
+			}
+		}
+	}
+}
+
+// scheduleContingencyProbe periodically queries NfeStatusServico4 for the authorizers of
+// the companies in contingency and ends their contingency once it is in operation again
+func (w *Worker) scheduleContingencyProbe(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.workerService.ContingencyProbeInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			exited, err := w.workerService.ProbeContingencies(ctx)
+			if err != nil {
+				w.logger.Error("Failed to probe contingencies", logger.Field{Key: "error", Value: err.Error()})
+			}
+			for _, state := range exited {
+				w.logger.Info("SEFAZ back in operation, contingency exited",
+					logger.Field{Key: "company_id", Value: state.CompanyID},
+					logger.Field{Key: "uf", Value: state.UF},
+					logger.Field{Key: "ambiente", Value: state.Ambiente},
+					logger.Field{Key: "type", Value: state.Type},
+					logger.Field{Key: "entered_at", Value: state.EnteredAt})
+			}
+		}
+	}
+}
+
+// scheduleSEFAZMonitor periodically queries NfeStatusServico4 for the authorizers in use
+func (w *Worker) scheduleSEFAZMonitor(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.monitor.PollInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			for _, availability := range w.monitor.Refresh(ctx) {
+				if availability.Available {
+					w.logger.Info("SEFAZ back in operation, circuit closed",
+						logger.Field{Key: "uf", Value: availability.UF},
+						logger.Field{Key: "ambiente", Value: availability.Ambiente},
+						logger.Field{Key: "cstat", Value: availability.CStat})
+					continue
+				}
+				w.logger.Warn("SEFAZ unavailable, circuit opened",
+					logger.Field{Key: "uf", Value: availability.UF},
+					logger.Field{Key: "ambiente", Value: availability.Ambiente},
+					logger.Field{Key: "cstat", Value: availability.CStat},
+					logger.Field{Key: "motivo", Value: availability.Motivo})
+			}
+		}
+	}
+}
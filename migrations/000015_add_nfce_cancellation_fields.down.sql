@@ -0,0 +1,4 @@
+-- Remove cancellation event fields from nfce_requests table
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS cancel_xml_url;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS canceled_at;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS cancel_protocolo;
@@ -0,0 +1,8 @@
+-- Add cancellation event (110111) fields to nfce_requests table
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS cancel_protocolo VARCHAR(15);
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS canceled_at TIMESTAMPTZ;
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS cancel_xml_url VARCHAR(500);
+
+-- Add comments
+COMMENT ON COLUMN nfce_requests.cancel_protocolo IS 'Protocolo do evento de cancelamento registrado na SEFAZ';
+COMMENT ON COLUMN nfce_requests.cancel_xml_url IS 'URL do XML procEventoNFe de cancelamento';
@@ -0,0 +1,3 @@
+-- Remove DANFE printing preferences from companies
+ALTER TABLE companies DROP COLUMN IF EXISTS danfe_compacto_estabelecimento;
+ALTER TABLE companies DROP COLUMN IF EXISTS danfe_duas_vias;
@@ -0,0 +1,3 @@
+-- Add DANFE printing preferences to companies
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS danfe_duas_vias BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS danfe_compacto_estabelecimento BOOLEAN NOT NULL DEFAULT FALSE;
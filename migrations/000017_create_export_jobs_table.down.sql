@@ -0,0 +1,3 @@
+-- Drop export_jobs table
+DROP INDEX IF EXISTS idx_nfce_requests_company_authorized_at;
+DROP TABLE IF EXISTS export_jobs;
@@ -0,0 +1,31 @@
+-- Create export_jobs table for chunked bulk exports
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'completed', 'failed', 'expired')),
+
+    -- Export period
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+
+    -- Progress
+    total_items INTEGER DEFAULT 0,
+    processed_items INTEGER DEFAULT 0,
+
+    -- Parts written to storage (stored as JSON array)
+    parts JSONB NOT NULL DEFAULT '[]',
+
+    attempts INTEGER DEFAULT 0,
+    error_message TEXT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for export_jobs
+CREATE INDEX IF NOT EXISTS idx_export_jobs_company_created ON export_jobs(company_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_export_jobs_status_expires ON export_jobs(status, expires_at);
+
+-- Index used to page NFC-e of a company by period
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_company_authorized_at ON nfce_requests(company_id, authorized_at);
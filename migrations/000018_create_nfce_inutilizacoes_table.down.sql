@@ -0,0 +1,2 @@
+-- Drop nfce_inutilizacoes table
+DROP TABLE IF EXISTS nfce_inutilizacoes;
@@ -0,0 +1,28 @@
+-- Create nfce_inutilizacoes table to track inutilized number ranges
+CREATE TABLE IF NOT EXISTS nfce_inutilizacoes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL,
+    uf VARCHAR(2) NOT NULL,
+    ambiente VARCHAR(20) NOT NULL,
+    serie INTEGER NOT NULL CHECK (serie BETWEEN 0 AND 999),
+    numero_inicial BIGINT NOT NULL,
+    numero_final BIGINT NOT NULL,
+    justificativa VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'homologated', 'rejected')),
+
+    -- SEFAZ response
+    protocolo VARCHAR(50),
+    cstat VARCHAR(10),
+    xmotivo TEXT,
+    xml_url TEXT,
+    homologated_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CHECK (numero_final >= numero_inicial)
+);
+
+-- Create indexes for nfce_inutilizacoes
+CREATE INDEX IF NOT EXISTS idx_nfce_inutilizacoes_company_serie ON nfce_inutilizacoes(company_id, serie, numero_inicial, numero_final);
+CREATE INDEX IF NOT EXISTS idx_nfce_inutilizacoes_company_created ON nfce_inutilizacoes(company_id, created_at DESC);
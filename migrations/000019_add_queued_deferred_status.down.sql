@@ -0,0 +1,6 @@
+-- Deferred requests go back to the regular intake status
+DROP INDEX IF EXISTS idx_nfce_requests_queued_deferred;
+UPDATE nfce_requests SET status = 'pending' WHERE status = 'queued_deferred';
+ALTER TABLE nfce_requests DROP CONSTRAINT IF EXISTS nfce_requests_status_check;
+ALTER TABLE nfce_requests ADD CONSTRAINT nfce_requests_status_check
+    CHECK (status IN ('pending', 'processing', 'authorized', 'rejected', 'contingency', 'retrying', 'canceled'));
@@ -0,0 +1,7 @@
+-- Allow requests accepted while the message broker is unavailable
+ALTER TABLE nfce_requests DROP CONSTRAINT IF EXISTS nfce_requests_status_check;
+ALTER TABLE nfce_requests ADD CONSTRAINT nfce_requests_status_check
+    CHECK (status IN ('pending', 'queued_deferred', 'processing', 'authorized', 'rejected', 'contingency', 'retrying', 'canceled'));
+
+-- Catch-up publishing scans deferred requests in arrival order
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_queued_deferred ON nfce_requests(created_at) WHERE status = 'queued_deferred';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_nfce_requests_recibo_polling;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS recibo_polls;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS recibo;
@@ -0,0 +1,6 @@
+-- Receipt (nRec) of asynchronous lotes sent with indSinc=0
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS recibo VARCHAR(15);
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS recibo_polls INTEGER NOT NULL DEFAULT 0;
+
+-- Receipt polling scans processing requests whose next query is due
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_recibo_polling ON nfce_requests(next_retry_at) WHERE status = 'processing' AND recibo IS NOT NULL AND recibo <> '';
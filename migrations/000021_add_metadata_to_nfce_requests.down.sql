@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_nfce_requests_metadata;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS metadata;
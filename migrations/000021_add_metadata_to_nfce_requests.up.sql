@@ -0,0 +1,5 @@
+-- Integrator correlation data (order_id, store_id...) echoed in responses and webhooks
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- Exact key/value filtering in listings (metadata @> '{"order_id":"123"}')
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_metadata ON nfce_requests USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1,2 @@
+-- Registry of marketplaces/delivery apps (infIntermed) selectable by alias on the emit payload
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS intermediadores JSONB NOT NULL DEFAULT '[]'::jsonb;
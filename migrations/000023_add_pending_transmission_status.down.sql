@@ -0,0 +1,6 @@
+-- Offline NFC-e not yet transmitted go back to the generic contingency status
+DROP INDEX IF EXISTS idx_nfce_requests_pending_transmission;
+UPDATE nfce_requests SET status = 'contingency' WHERE status = 'pending_transmission';
+ALTER TABLE nfce_requests DROP CONSTRAINT IF EXISTS nfce_requests_status_check;
+ALTER TABLE nfce_requests ADD CONSTRAINT nfce_requests_status_check
+    CHECK (status IN ('pending', 'queued_deferred', 'processing', 'authorized', 'rejected', 'contingency', 'retrying', 'canceled'));
@@ -0,0 +1,7 @@
+-- NFC-e issued in offline contingency (tpEmis 9) wait for transmission to SEFAZ
+ALTER TABLE nfce_requests DROP CONSTRAINT IF EXISTS nfce_requests_status_check;
+ALTER TABLE nfce_requests ADD CONSTRAINT nfce_requests_status_check
+    CHECK (status IN ('pending', 'queued_deferred', 'processing', 'authorized', 'rejected', 'contingency', 'retrying', 'canceled', 'pending_transmission'));
+
+-- Later transmission scans offline NFC-e in issue order
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_pending_transmission ON nfce_requests(created_at) WHERE status = 'pending_transmission';
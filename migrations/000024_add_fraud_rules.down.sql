@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_nfce_requests_emitente_terminal_created;
+
+UPDATE nfce_requests SET rejection_code = LEFT(rejection_code, 10) WHERE LENGTH(rejection_code) > 10;
+ALTER TABLE nfce_requests ALTER COLUMN rejection_code TYPE VARCHAR(10);
+
+ALTER TABLE companies DROP COLUMN IF EXISTS fraud_rules;
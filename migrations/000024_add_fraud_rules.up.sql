@@ -0,0 +1,9 @@
+-- Pre-emission anti-fraud rules of the company; checks missing here use the default rule set
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS fraud_rules JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- Anti-fraud block codes (e.g. fraud_ticket_value) are longer than a cStat
+ALTER TABLE nfce_requests ALTER COLUMN rejection_code TYPE VARCHAR(50);
+
+-- Velocity per terminal and identical NFC-e lookups
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_emitente_terminal_created
+    ON nfce_requests ((payload->'emitente'->>'cnpj'), (COALESCE(payload->>'terminal', '')), created_at);
@@ -0,0 +1,3 @@
+-- Drop DF-e distribution tables
+DROP TABLE IF EXISTS dfe_documents;
+DROP TABLE IF EXISTS dfe_cursors;
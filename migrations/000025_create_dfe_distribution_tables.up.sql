@@ -0,0 +1,37 @@
+-- Create dfe_cursors table to track the last NSU consumed from NFeDistribuicaoDFe per company
+CREATE TABLE IF NOT EXISTS dfe_cursors (
+    company_id UUID PRIMARY KEY,
+    ult_nsu VARCHAR(15) NOT NULL DEFAULT '0',
+    max_nsu VARCHAR(15) NOT NULL DEFAULT '0',
+
+    -- Last SEFAZ reply
+    cstat VARCHAR(10),
+    motivo TEXT,
+    last_sync_at TIMESTAMPTZ,
+    next_sync_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dfe_cursors_next_sync ON dfe_cursors(next_sync_at);
+
+-- Create dfe_documents table for the documents and events distributed to the company
+CREATE TABLE IF NOT EXISTS dfe_documents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL,
+    nsu VARCHAR(15) NOT NULL,
+    schema VARCHAR(50) NOT NULL,
+    tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('resumo_nfe', 'nfe', 'resumo_evento', 'evento', 'outro')),
+    chave_acesso VARCHAR(44),
+    cnpj_emitente VARCHAR(14),
+    xml_key TEXT NOT NULL,
+    xml_url TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE (company_id, nsu)
+);
+
+-- Create indexes for dfe_documents
+CREATE INDEX IF NOT EXISTS idx_dfe_documents_company_tipo ON dfe_documents(company_id, tipo);
+CREATE INDEX IF NOT EXISTS idx_dfe_documents_chave_acesso ON dfe_documents(chave_acesso);
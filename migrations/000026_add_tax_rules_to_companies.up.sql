@@ -0,0 +1,2 @@
+-- Per-company tax overrides by NCM/CFOP; items without a matching rule use the regime default tables
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS tax_rules JSONB NOT NULL DEFAULT '[]'::jsonb;
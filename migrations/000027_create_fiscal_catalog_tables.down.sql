@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS catalog_cfop;
+DROP TABLE IF EXISTS catalog_cest;
+DROP TABLE IF EXISTS catalog_ncm;
@@ -0,0 +1,42 @@
+-- NCM table (Siscomex); imported with scripts/catalog, validation of NCM existence starts once it has rows
+CREATE TABLE IF NOT EXISTS catalog_ncm (
+    codigo VARCHAR(8) PRIMARY KEY,
+    descricao TEXT NOT NULL,
+    vigencia_fim DATE
+);
+
+-- CEST table (Convênio ICMS 142/2018), one row per NCM or NCM prefix covered by the CEST
+CREATE TABLE IF NOT EXISTS catalog_cest (
+    codigo VARCHAR(7) NOT NULL,
+    ncm VARCHAR(8) NOT NULL,
+    descricao TEXT NOT NULL,
+
+    PRIMARY KEY (codigo, ncm)
+);
+
+-- CFOP table; nfce marks the operations allowed in the NFC-e (modelo 65), st the ones requiring CEST
+CREATE TABLE IF NOT EXISTS catalog_cfop (
+    codigo VARCHAR(4) PRIMARY KEY,
+    descricao TEXT NOT NULL,
+    nfce BOOLEAN NOT NULL DEFAULT FALSE,
+    st BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO catalog_cfop (codigo, descricao, nfce, st) VALUES
+    ('5101', 'Venda de produção do estabelecimento', TRUE, FALSE),
+    ('5102', 'Venda de mercadoria adquirida ou recebida de terceiros', TRUE, FALSE),
+    ('5103', 'Venda de produção do estabelecimento, efetuada fora do estabelecimento', TRUE, FALSE),
+    ('5104', 'Venda de mercadoria adquirida ou recebida de terceiros, efetuada fora do estabelecimento', TRUE, FALSE),
+    ('5115', 'Venda de mercadoria adquirida ou recebida de terceiros, recebida anteriormente em consignação mercantil', TRUE, FALSE),
+    ('5405', 'Venda de mercadoria adquirida ou recebida de terceiros em operação com mercadoria sujeita ao regime de substituição tributária, na condição de contribuinte substituído', TRUE, TRUE),
+    ('5656', 'Venda de combustível ou lubrificante adquirido ou recebido de terceiros destinado a consumidor ou usuário final', TRUE, TRUE),
+    ('5667', 'Venda de combustível ou lubrificante a consumidor ou usuário final estabelecido em outra unidade da Federação', TRUE, TRUE),
+    ('5933', 'Prestação de serviço tributado pelo ISSQN', TRUE, FALSE),
+    ('5152', 'Transferência de mercadoria adquirida ou recebida de terceiros', FALSE, FALSE),
+    ('5202', 'Devolução de compra para comercialização', FALSE, FALSE),
+    ('5403', 'Venda de mercadoria adquirida ou recebida de terceiros em operação com mercadoria sujeita ao regime de substituição tributária, na condição de contribuinte substituto', FALSE, TRUE),
+    ('5910', 'Remessa em bonificação, doação ou brinde', FALSE, FALSE),
+    ('5949', 'Outra saída de mercadoria ou prestação de serviço não especificado', FALSE, FALSE),
+    ('6102', 'Venda de mercadoria adquirida ou recebida de terceiros', FALSE, FALSE),
+    ('6108', 'Venda de mercadoria adquirida ou recebida de terceiros, destinada a não contribuinte', FALSE, FALSE)
+ON CONFLICT (codigo) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Drop maintenance windows table
+DROP TABLE IF EXISTS maintenance_windows;
@@ -0,0 +1,13 @@
+-- Create maintenance_windows table for the admin maintenance mode
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message TEXT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expected_end_at TIMESTAMPTZ,
+    ended_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- At most one window can be active at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_maintenance_windows_active ON maintenance_windows ((ended_at IS NULL)) WHERE ended_at IS NULL;
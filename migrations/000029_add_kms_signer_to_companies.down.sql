@@ -0,0 +1,9 @@
+-- Remove the KMS signer configuration from companies
+ALTER TABLE companies DROP COLUMN IF EXISTS certificado_kms_token;
+ALTER TABLE companies DROP COLUMN IF EXISTS certificado_kms_key_id;
+ALTER TABLE companies DROP COLUMN IF EXISTS certificado_kms_endpoint;
+ALTER TABLE companies DROP COLUMN IF EXISTS certificado_pem;
+
+UPDATE companies SET certificado_type = 'a1' WHERE certificado_type = 'kms';
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_certificado_type_check;
+ALTER TABLE companies ADD CONSTRAINT companies_certificado_type_check CHECK (certificado_type IN ('a1'));
@@ -0,0 +1,8 @@
+-- Companies may sign with a key kept in an HSM or cloud KMS instead of an uploaded PFX
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_certificado_type_check;
+ALTER TABLE companies ADD CONSTRAINT companies_certificado_type_check CHECK (certificado_type IN ('a1', 'kms'));
+
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS certificado_pem BYTEA;
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS certificado_kms_endpoint VARCHAR(500);
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS certificado_kms_key_id VARCHAR(255);
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS certificado_kms_token VARCHAR(500);
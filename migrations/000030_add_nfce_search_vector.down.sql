@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_nfce_requests_search_vector;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS search_vector;
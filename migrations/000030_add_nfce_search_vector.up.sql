@@ -0,0 +1,8 @@
+-- Full-text search over the item descriptions (weight A) and the consumer name (weight B)
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (
+        setweight(jsonb_to_tsvector('portuguese', COALESCE(jsonb_path_query_array(payload, '$.itens[*].descricao'), '[]'::jsonb), '["string"]'), 'A') ||
+        setweight(jsonb_to_tsvector('portuguese', COALESCE(payload->'destinatario'->'nome', '""'::jsonb), '["string"]'), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_search_vector ON nfce_requests USING GIN (search_vector);
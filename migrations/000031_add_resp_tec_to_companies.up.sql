@@ -0,0 +1,2 @@
+-- Company technical responsible (infRespTec) with the UF-issued CSRT; NULL uses the installation one
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS resp_tec JSONB;
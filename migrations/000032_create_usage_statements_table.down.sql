@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook_created;
+DROP TABLE IF EXISTS usage_statements;
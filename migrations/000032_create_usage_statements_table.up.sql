@@ -0,0 +1,18 @@
+-- Monthly usage statements per company; the PDF and JSON live in the company storage area
+CREATE TABLE IF NOT EXISTS usage_statements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    period VARCHAR(7) NOT NULL, -- YYYY-MM
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    summary JSONB NOT NULL DEFAULT '{}',
+    json_key VARCHAR(500) NOT NULL,
+    pdf_key VARCHAR(500) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_usage_statements_company_period UNIQUE (company_id, period)
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_statements_company_period_start ON usage_statements(company_id, period_start DESC);
+
+-- Webhook delivery stats of the statements are counted by company and period
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created ON webhook_deliveries(webhook_id, created_at);
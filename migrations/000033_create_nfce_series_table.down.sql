@@ -0,0 +1,46 @@
+DROP INDEX IF EXISTS idx_nfce_requests_company_serie_numero;
+
+-- Restore the single-series sequence table and its allocation function
+CREATE TABLE IF NOT EXISTS nfce_sequences (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL UNIQUE REFERENCES companies(id) ON DELETE CASCADE,
+    serie VARCHAR(3) NOT NULL DEFAULT '1',
+    ultimo_numero BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_nfce_sequences_company_id ON nfce_sequences(company_id);
+
+-- Keep the highest number used by the default series of each company
+INSERT INTO nfce_sequences (company_id, serie, ultimo_numero)
+SELECT company_id, '1', MAX(next_number) - 1
+FROM nfce_series
+WHERE serie = 1
+GROUP BY company_id
+ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION get_next_nfce_number(company_uuid UUID, nfce_serie VARCHAR(3) DEFAULT '1')
+RETURNS BIGINT AS $$
+DECLARE
+    next_number BIGINT;
+BEGIN
+    UPDATE nfce_sequences
+    SET ultimo_numero = ultimo_numero + 1, updated_at = NOW()
+    WHERE company_id = company_uuid AND serie = nfce_serie;
+
+    IF NOT FOUND THEN
+        INSERT INTO nfce_sequences (company_id, serie, ultimo_numero)
+        VALUES (company_uuid, nfce_serie, 1)
+        RETURNING ultimo_numero INTO next_number;
+    ELSE
+        SELECT ultimo_numero INTO next_number
+        FROM nfce_sequences
+        WHERE company_id = company_uuid AND serie = nfce_serie;
+    END IF;
+
+    RETURN next_number;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS nfce_series;
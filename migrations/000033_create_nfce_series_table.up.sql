@@ -0,0 +1,35 @@
+-- Create nfce_series table: numbering series per company and environment, replacing nfce_sequences
+CREATE TABLE IF NOT EXISTS nfce_series (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    serie INTEGER NOT NULL CHECK (serie BETWEEN 0 AND 999),
+    ambiente VARCHAR(20) NOT NULL CHECK (ambiente IN ('producao', 'homologacao')),
+    next_number BIGINT NOT NULL DEFAULT 1 CHECK (next_number BETWEEN 1 AND 1000000000),
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE (company_id, serie, ambiente)
+);
+
+-- At most one default series per company and environment
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nfce_series_default ON nfce_series(company_id, ambiente) WHERE is_default;
+
+COMMENT ON TABLE nfce_series IS 'Séries de numeração NFC-e por empresa e ambiente';
+COMMENT ON COLUMN nfce_series.next_number IS 'Próximo número (nNF) a ser usado na série';
+COMMENT ON COLUMN nfce_series.is_default IS 'Série usada quando a emissão não informa a série';
+
+-- The old sequence was shared by both environments; carry it to each so no number is reused
+INSERT INTO nfce_series (company_id, serie, ambiente, next_number, is_default)
+SELECT s.company_id, s.serie::integer, a.ambiente, s.ultimo_numero + 1, TRUE
+FROM nfce_sequences s
+CROSS JOIN (VALUES ('producao'), ('homologacao')) AS a(ambiente)
+ON CONFLICT DO NOTHING;
+
+-- Allocation is done by the application, locking the series row
+DROP FUNCTION IF EXISTS get_next_nfce_number(UUID, VARCHAR(3));
+DROP TABLE IF EXISTS nfce_sequences;
+
+-- Lookup of the numbers held by the NFC-e of a series for the gap report
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_company_serie_numero ON nfce_requests(company_id, serie, numero);
@@ -0,0 +1,2 @@
+-- Admin passwords stay hashed: bcrypt cannot be reverted
+DROP TABLE IF EXISTS api_keys;
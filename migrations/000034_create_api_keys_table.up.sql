@@ -0,0 +1,21 @@
+-- Company API keys; only the SHA-256 of the key is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ, -- Set when the key is rotated out
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys(key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_company_id ON api_keys(company_id, created_at DESC);
+
+-- Admin passwords were stored in plain text; hash them with bcrypt as the login expects
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+UPDATE admins SET password = crypt(password, gen_salt('bf', 10)), updated_at = NOW()
+WHERE password !~ '^\$2[aby]\$';
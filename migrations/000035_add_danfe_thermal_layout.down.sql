@@ -0,0 +1,4 @@
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS v_tot_trib;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS qrcode;
+
+ALTER TABLE companies DROP COLUMN IF EXISTS danfe_papel;
@@ -0,0 +1,6 @@
+-- Thermal paper width of the DANFE NFC-e (80mm or 58mm)
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS danfe_papel VARCHAR(10) NOT NULL DEFAULT '80mm';
+
+-- DANFE data fixed at authorization: QR Code content and approximate taxes (Lei 12.741/2012) in centavos
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS qrcode TEXT;
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS v_tot_trib BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS nfce_email_deliveries;
+ALTER TABLE companies DROP COLUMN IF EXISTS email_template;
@@ -0,0 +1,21 @@
+-- Company message e-mailing the XML and DANFE to the consumer; NULL uses the default message
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS email_template JSONB;
+
+-- Send log of the NFC-e documents e-mailed to the consumer
+CREATE TABLE IF NOT EXISTS nfce_email_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    request_id UUID NOT NULL REFERENCES nfce_requests(id) ON DELETE CASCADE,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(500) NOT NULL DEFAULT '',
+    trigger VARCHAR(20) NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    message_id VARCHAR(255),
+    error_message TEXT,
+    succeeded BOOLEAN NOT NULL DEFAULT FALSE,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_nfce_email_deliveries_trigger CHECK (trigger IN ('authorization', 'resend'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_nfce_email_deliveries_request_created ON nfce_email_deliveries(request_id, created_at DESC);
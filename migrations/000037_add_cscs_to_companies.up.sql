@@ -0,0 +1,2 @@
+-- CSCs per environment with activation windows, so companies can rotate them; the csc_* columns stay as the legacy single CSC
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS cscs JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS uq_nfce_requests_company_idempotency_key;
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_idempotency_key ON nfce_requests(idempotency_key);
+ALTER TABLE nfce_requests ADD CONSTRAINT nfce_requests_idempotency_key_key UNIQUE (idempotency_key);
+
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,20 @@
+-- Idempotency-Key of the API requests, unique per company; the response is replayed to retries until expires_at
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    response_status INTEGER NOT NULL DEFAULT 0,
+    response_body BYTEA,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT uq_idempotency_keys_company_key UNIQUE (company_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+-- NFC-e idempotency keys are unique per company instead of across companies
+ALTER TABLE nfce_requests DROP CONSTRAINT IF EXISTS nfce_requests_idempotency_key_key;
+DROP INDEX IF EXISTS idx_nfce_requests_idempotency_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_nfce_requests_company_idempotency_key ON nfce_requests(company_id, idempotency_key);
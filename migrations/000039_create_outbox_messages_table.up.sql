@@ -0,0 +1,24 @@
+-- Transactional outbox: queue messages written with the change they announce and relayed by the worker
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    topic VARCHAR(100) NOT NULL,
+    aggregate_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    available_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_pending ON outbox_messages(available_at) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_sent_at ON outbox_messages(sent_at) WHERE sent_at IS NOT NULL;
+
+-- Requests accepted while the broker was down are now published through the outbox
+INSERT INTO outbox_messages (topic, aggregate_id, payload, created_at)
+SELECT 'nfce.emit',
+       id,
+       json_build_object('request_id', id, 'idempotency_key', idempotency_key, 'enqueued_at', created_at, 'schema_version', 1),
+       created_at
+FROM nfce_requests
+WHERE status = 'queued_deferred';
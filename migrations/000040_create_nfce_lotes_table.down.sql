@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_nfce_requests_lote_id;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS lote_id;
+
+DROP TABLE IF EXISTS nfce_lotes;
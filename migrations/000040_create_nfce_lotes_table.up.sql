@@ -0,0 +1,13 @@
+-- Lotes of NFC-e requested together through POST /nfce/lote
+CREATE TABLE IF NOT EXISTS nfce_lotes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    idempotency_key VARCHAR(255) NOT NULL,
+    size INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_nfce_lotes_company_id ON nfce_lotes(company_id);
+
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS lote_id UUID REFERENCES nfce_lotes(id);
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_lote_id ON nfce_requests(lote_id) WHERE lote_id IS NOT NULL;
@@ -0,0 +1,21 @@
+-- Audit trail of the writes made through the API
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id VARCHAR(255) NOT NULL,
+    company_id UUID,
+    action VARCHAR(255) NOT NULL,
+    resource_type VARCHAR(50),
+    resource_id VARCHAR(255),
+    changes JSONB NOT NULL DEFAULT '{}',
+    status INTEGER NOT NULL,
+    ip VARCHAR(64),
+    user_agent TEXT,
+    request_id VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor ON audit_logs(actor_type, actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_company_id ON audit_logs(company_id, created_at DESC) WHERE company_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_type, resource_id);
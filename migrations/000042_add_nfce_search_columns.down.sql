@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_nfce_requests_company_contingency_created;
+DROP INDEX IF EXISTS idx_nfce_requests_company_cstat_created;
+DROP INDEX IF EXISTS idx_nfce_requests_company_dest_documento;
+DROP INDEX IF EXISTS idx_nfce_requests_company_serie_numero;
+DROP INDEX IF EXISTS idx_nfce_requests_company_v_nf_id;
+DROP INDEX IF EXISTS idx_nfce_requests_company_created_id;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS dest_documento;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS v_nf;
@@ -0,0 +1,41 @@
+-- NFC-e total (vNF) in centavos, set on creation; existing rows are computed from the payload
+-- as EmitPayload.Total does: items less discounts plus other charges, plus the freight
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS v_nf BIGINT NOT NULL DEFAULT 0;
+
+UPDATE nfce_requests SET v_nf = ROUND(COALESCE((payload->>'frete')::numeric, 0) * 100) + COALESCE((
+    SELECT SUM(
+        ROUND((item->>'valor')::numeric * (item->>'quantidade')::numeric * 100)
+        - ROUND(COALESCE((item->>'desconto')::numeric, 0) * 100)
+        + ROUND(COALESCE((item->>'outros')::numeric, 0) * 100)
+    )
+    FROM jsonb_array_elements(payload->'itens') AS item
+), 0)
+WHERE v_nf = 0 AND jsonb_typeof(payload->'itens') = 'array';
+
+-- Consumer CPF or CNPJ, digits only
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS dest_documento TEXT
+    GENERATED ALWAYS AS (
+        regexp_replace(COALESCE(NULLIF(payload->'destinatario'->>'cpf', ''), payload->'destinatario'->>'cnpj', ''), '[^0-9]', '', 'g')
+    ) STORED;
+
+-- Keyset pagination of the listing, by creation (default) and by value
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_company_created_id
+ON nfce_requests(company_id, created_at, id);
+
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_company_v_nf_id
+ON nfce_requests(company_id, v_nf, id);
+
+-- Attribute filters of the listing
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_company_serie_numero
+ON nfce_requests(company_id, serie, numero);
+
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_company_dest_documento
+ON nfce_requests(company_id, dest_documento)
+WHERE dest_documento <> '';
+
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_company_cstat_created
+ON nfce_requests(company_id, cstat, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_company_contingency_created
+ON nfce_requests(company_id, created_at)
+WHERE in_contingency;
@@ -0,0 +1,2 @@
+-- Export jobs may add an index.csv listing the NFC-e of each part
+ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS include_index BOOLEAN NOT NULL DEFAULT FALSE;
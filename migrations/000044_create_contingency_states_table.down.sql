@@ -0,0 +1 @@
+DROP TABLE IF EXISTS contingency_states;
@@ -0,0 +1,24 @@
+-- Create contingency_states table: the contingency periods of each company in a UF and
+-- ambiente, during which new NFC-e go straight to the contingency authorizer
+CREATE TABLE IF NOT EXISTS contingency_states (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL,
+    uf VARCHAR(2) NOT NULL,
+    ambiente VARCHAR(20) NOT NULL,
+    type VARCHAR(20) NOT NULL, -- SVC-AN, SVC-RS or OFFLINE
+    reason VARCHAR(256) NOT NULL, -- xJust of the NFC-e issued in the period
+    cstat VARCHAR(20),
+    entered_at TIMESTAMPTZ NOT NULL, -- dhCont of the NFC-e issued in the period
+    probed_at TIMESTAMPTZ,
+    exited_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- At most one active period per company, UF and ambiente
+CREATE UNIQUE INDEX IF NOT EXISTS idx_contingency_states_active
+ON contingency_states(company_id, uf, ambiente)
+WHERE exited_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_contingency_states_company_entered
+ON contingency_states(company_id, entered_at DESC);
@@ -0,0 +1 @@
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS schema_errors;
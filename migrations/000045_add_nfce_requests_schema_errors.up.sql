@@ -0,0 +1,2 @@
+-- XSD violations of the NFC-e rejected before transmission, with their location in the XML
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS schema_errors JSONB;
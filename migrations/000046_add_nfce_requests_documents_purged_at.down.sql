@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_nfce_requests_company_purgeable;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS documents_purged_at;
@@ -0,0 +1,7 @@
+-- When the plan retention deleted the XML, DANFE and QR Code of the NFC-e; the row is kept
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS documents_purged_at TIMESTAMPTZ;
+
+-- Documents still stored, scanned by the purge job per company from the oldest
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_company_purgeable
+ON nfce_requests(company_id, created_at)
+WHERE documents_purged_at IS NULL AND status IN ('authorized', 'canceled');
@@ -0,0 +1,3 @@
+-- Issuer and CNPJ read from the uploaded certificate, next to its subject and expiry
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS certificado_issuer VARCHAR(500);
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS certificado_cnpj VARCHAR(14);
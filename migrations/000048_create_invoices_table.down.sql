@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS billing_customer_id;
+DROP TABLE IF EXISTS invoices;
@@ -0,0 +1,32 @@
+-- Invoices of the subscription billing periods, charged through the payment gateway
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'BRL',
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'failed', 'void')),
+
+    -- Gateway
+    gateway VARCHAR(20) NOT NULL,
+    charge_id VARCHAR(255),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ,
+    paid_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    -- A period is billed once, even when two workers pick the same subscription
+    CONSTRAINT uq_invoices_subscription_period UNIQUE (subscription_id, period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_company_period_start ON invoices(company_id, period_start DESC);
+CREATE INDEX IF NOT EXISTS idx_invoices_charge_id ON invoices(gateway, charge_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_retry ON invoices(next_attempt_at) WHERE status = 'failed';
+
+-- Customer of the company on the payment gateway, charged with billing_payment_method
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_customer_id VARCHAR(255);
@@ -0,0 +1,2 @@
+ALTER TABLE invoices DROP COLUMN IF EXISTS adjustment;
+DROP TABLE IF EXISTS subscription_events;
@@ -0,0 +1,26 @@
+-- Plan changes of the subscriptions and the prorations billed on their next invoice
+CREATE TABLE IF NOT EXISTS subscription_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL CHECK (type IN ('plan_changed', 'credit_carried')),
+
+    -- Plan change
+    from_plan_id UUID,
+    to_plan_id UUID,
+    quota_before INTEGER NOT NULL DEFAULT 0,
+    quota_after INTEGER NOT NULL DEFAULT 0,
+
+    -- Proration, billed on the next invoice of the subscription
+    amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT 'BRL',
+    invoice_id UUID REFERENCES invoices(id) ON DELETE SET NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_events_company_created_at ON subscription_events(company_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_subscription_events_unbilled ON subscription_events(subscription_id) WHERE invoice_id IS NULL;
+
+-- Prorations included in the amount of the invoice
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS adjustment DECIMAL(10,2) NOT NULL DEFAULT 0;
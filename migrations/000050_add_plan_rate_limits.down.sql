@@ -0,0 +1,2 @@
+ALTER TABLE plans DROP COLUMN IF EXISTS features_emissions_per_minute;
+ALTER TABLE plans DROP COLUMN IF EXISTS features_requests_per_minute;
@@ -0,0 +1,3 @@
+-- Rate limits of the plan per company, 0 = default of the deployment
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS features_requests_per_minute INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS features_emissions_per_minute INTEGER NOT NULL DEFAULT 0;
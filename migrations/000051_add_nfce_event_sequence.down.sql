@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_nfce_events_request_sequence;
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS version;
+ALTER TABLE nfce_events DROP COLUMN IF EXISTS state;
+ALTER TABLE nfce_events DROP COLUMN IF EXISTS sequence;
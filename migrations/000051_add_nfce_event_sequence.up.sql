@@ -0,0 +1,20 @@
+-- The events of a request are numbered and carry the state the request was left in, so the
+-- request can be rebuilt from them; version is the sequence of its last event
+ALTER TABLE nfce_events ADD COLUMN IF NOT EXISTS sequence INTEGER;
+ALTER TABLE nfce_events ADD COLUMN IF NOT EXISTS state JSONB;
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 0;
+
+-- Number the existing events in the order they were recorded
+UPDATE nfce_events e SET sequence = numbered.sequence
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY request_id ORDER BY created_at, id) AS sequence
+    FROM nfce_events
+) numbered
+WHERE e.id = numbered.id AND e.sequence IS NULL;
+
+UPDATE nfce_requests r SET version = events.last
+FROM (SELECT request_id, MAX(sequence) AS last FROM nfce_events GROUP BY request_id) events
+WHERE r.id = events.request_id;
+
+ALTER TABLE nfce_events ALTER COLUMN sequence SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nfce_events_request_sequence ON nfce_events(request_id, sequence);
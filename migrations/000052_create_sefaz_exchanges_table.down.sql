@@ -0,0 +1 @@
+DROP TABLE IF EXISTS sefaz_exchanges;
@@ -0,0 +1,22 @@
+-- SOAP exchanges of the SEFAZ authorization, archived gzip-compressed in the storage for
+-- the disputes with SEFAZ. The NFC-e of a lote share the files of the enviNFe that carried them.
+CREATE TABLE IF NOT EXISTS sefaz_exchanges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    request_id UUID NOT NULL REFERENCES nfce_requests(id) ON DELETE CASCADE,
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    service VARCHAR(30) NOT NULL CHECK (service IN ('autorizacao', 'autorizacao_lote')),
+    uf VARCHAR(2) NOT NULL,
+    ambiente VARCHAR(20) NOT NULL,
+    cstat VARCHAR(10),
+    error TEXT, -- Failure of the call, when SEFAZ did not answer
+
+    request_key VARCHAR(500) NOT NULL,
+    response_key VARCHAR(500), -- Empty when SEFAZ did not answer
+    request_size BIGINT NOT NULL DEFAULT 0, -- Uncompressed bytes
+    response_size BIGINT NOT NULL DEFAULT 0,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sefaz_exchanges_request_id ON sefaz_exchanges(request_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_sefaz_exchanges_created_at ON sefaz_exchanges(created_at);
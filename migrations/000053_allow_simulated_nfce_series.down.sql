@@ -0,0 +1,4 @@
+DELETE FROM nfce_series WHERE ambiente = 'simulado';
+ALTER TABLE nfce_series DROP CONSTRAINT IF EXISTS nfce_series_ambiente_check;
+ALTER TABLE nfce_series ADD CONSTRAINT nfce_series_ambiente_check
+    CHECK (ambiente IN ('producao', 'homologacao'));
@@ -0,0 +1,4 @@
+-- The simulated ambiente, answered by the in-process fake SEFAZ, numbers its NFC-e apart
+ALTER TABLE nfce_series DROP CONSTRAINT IF EXISTS nfce_series_ambiente_check;
+ALTER TABLE nfce_series ADD CONSTRAINT nfce_series_ambiente_check
+    CHECK (ambiente IN ('producao', 'homologacao', 'simulado'));
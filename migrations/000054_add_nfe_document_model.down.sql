@@ -0,0 +1,13 @@
+ALTER TABLE nfce_requests DROP COLUMN IF EXISTS totais;
+
+DELETE FROM nfce_inutilizacoes WHERE modelo = '55';
+ALTER TABLE nfce_inutilizacoes DROP COLUMN IF EXISTS modelo;
+
+DELETE FROM nfce_series WHERE modelo = '55';
+DROP INDEX IF EXISTS idx_nfce_series_default;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nfce_series_default ON nfce_series(company_id, ambiente) WHERE is_default;
+
+ALTER TABLE nfce_series DROP CONSTRAINT IF EXISTS nfce_series_company_id_modelo_serie_ambiente_key;
+ALTER TABLE nfce_series ADD CONSTRAINT nfce_series_company_id_serie_ambiente_key
+    UNIQUE (company_id, serie, ambiente);
+ALTER TABLE nfce_series DROP COLUMN IF EXISTS modelo;
@@ -0,0 +1,19 @@
+-- NF-e (modelo 55) is numbered apart from the NFC-e (modelo 65): the series are per model
+ALTER TABLE nfce_series ADD COLUMN IF NOT EXISTS modelo VARCHAR(2) NOT NULL DEFAULT '65'
+    CHECK (modelo IN ('55', '65'));
+
+ALTER TABLE nfce_series DROP CONSTRAINT IF EXISTS nfce_series_company_id_serie_ambiente_key;
+ALTER TABLE nfce_series ADD CONSTRAINT nfce_series_company_id_modelo_serie_ambiente_key
+    UNIQUE (company_id, modelo, serie, ambiente);
+
+-- At most one default series per company, model and environment
+DROP INDEX IF EXISTS idx_nfce_series_default;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nfce_series_default ON nfce_series(company_id, modelo, ambiente) WHERE is_default;
+
+COMMENT ON COLUMN nfce_series.modelo IS 'Modelo do documento: 65 (NFC-e) ou 55 (NF-e)';
+
+ALTER TABLE nfce_inutilizacoes ADD COLUMN IF NOT EXISTS modelo VARCHAR(2) NOT NULL DEFAULT '65'
+    CHECK (modelo IN ('55', '65'));
+
+-- Tax totals of the ICMSTot printed by the DANFE A4 of the NF-e
+ALTER TABLE nfce_requests ADD COLUMN IF NOT EXISTS totais JSONB;
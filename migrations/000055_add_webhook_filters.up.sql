@@ -0,0 +1,4 @@
+-- Filters on the NFC-e events evaluated by the dispatcher before the delivery
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS filters JSONB NOT NULL DEFAULT '[]';
+
+COMMENT ON COLUMN webhooks.filters IS 'Filtros dos eventos nfce.* ({field, op, value}), todos devem ser atendidos';
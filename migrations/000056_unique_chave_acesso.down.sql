@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_nfce_requests_chave_acesso;
+CREATE INDEX IF NOT EXISTS idx_nfce_requests_chave_acesso ON nfce_requests(chave_acesso);
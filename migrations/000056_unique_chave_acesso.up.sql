@@ -0,0 +1,5 @@
+-- A chave de acesso identifies one NFC-e; the builder draws the cNF again on a collision and
+-- the index keeps a concurrent one from being stored twice
+DROP INDEX IF EXISTS idx_nfce_requests_chave_acesso;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nfce_requests_chave_acesso ON nfce_requests(chave_acesso)
+    WHERE chave_acesso IS NOT NULL AND chave_acesso <> '';
@@ -0,0 +1,2 @@
+-- Company defaults of the ide (natOp, série, tpImp, CFOP by destination UF, verProc); NULL keeps the built-in ones
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS emission_settings JSONB;
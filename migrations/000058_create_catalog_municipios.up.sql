@@ -0,0 +1,11 @@
+-- IBGE municipality table; imported with scripts/catalog, the company addresses are resolved
+-- and checked against it once it has rows
+CREATE TABLE IF NOT EXISTS catalog_municipios (
+    codigo VARCHAR(7) PRIMARY KEY,
+    nome TEXT NOT NULL,
+    uf VARCHAR(2) NOT NULL,
+    -- Upper case name without accents, compared by the lookups by name and UF
+    nome_busca TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_catalog_municipios_uf_nome_busca ON catalog_municipios (uf, nome_busca);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_companies_deleted_at;
+
+ALTER TABLE companies DROP COLUMN IF EXISTS storage_cleaned_at;
+ALTER TABLE companies DROP COLUMN IF EXISTS storage_cleanup_at;
+ALTER TABLE companies DROP COLUMN IF EXISTS deleted_at;
+
+UPDATE companies SET status = 'inactive' WHERE status = 'deleted';
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_status_check;
+ALTER TABLE companies ADD CONSTRAINT companies_status_check CHECK (status IN ('active', 'inactive', 'blocked'));
@@ -0,0 +1,9 @@
+-- LGPD offboarding: deleted companies keep their row, with the date of the deletion and of the storage cleanup
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_status_check;
+ALTER TABLE companies ADD CONSTRAINT companies_status_check CHECK (status IN ('active', 'inactive', 'blocked', 'deleted'));
+
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS storage_cleanup_at TIMESTAMPTZ;
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS storage_cleaned_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_companies_deleted_at ON companies(deleted_at) WHERE deleted_at IS NOT NULL;
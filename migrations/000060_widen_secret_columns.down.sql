@@ -0,0 +1,5 @@
+-- Fails while the columns hold encrypted values longer than the original sizes
+ALTER TABLE webhooks ALTER COLUMN secret TYPE VARCHAR(255);
+ALTER TABLE companies ALTER COLUMN csc_token TYPE VARCHAR(32);
+ALTER TABLE companies ALTER COLUMN certificado_kms_token TYPE VARCHAR(500);
+ALTER TABLE companies ALTER COLUMN certificado_password TYPE VARCHAR(255);
@@ -0,0 +1,6 @@
+-- The secret columns hold the encrypted envelope (key ID, sealed data key and sealed value),
+-- longer than the plaintext they were sized for
+ALTER TABLE companies ALTER COLUMN certificado_password TYPE TEXT;
+ALTER TABLE companies ALTER COLUMN certificado_kms_token TYPE TEXT;
+ALTER TABLE companies ALTER COLUMN csc_token TYPE TEXT;
+ALTER TABLE webhooks ALTER COLUMN secret TYPE TEXT;
@@ -0,0 +1,110 @@
+// Package apierror holds the error envelope returned by every failed HTTP API request and
+// the typed error that carries its HTTP status and machine-readable code to the
+// error-handling middleware.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/i18n"
+)
+
+// Detail describes one cause of an error, e.g. a request field that failed validation
+type Detail struct {
+	Field   string `json:"field,omitempty"` // e.g. "itens[0].ncm"
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// Envelope is the body of every error response:
+//
+//	{"code": "nfce_not_found", "message": "NFC-e não encontrada", "details": [], "trace_id": "..."}
+type Envelope struct {
+	Code    i18n.Code `json:"code"`
+	Message string    `json:"message"`
+	Details []Detail  `json:"details"`
+	TraceID string    `json:"trace_id"`
+}
+
+// Error is an error with the HTTP status and code it is answered with
+type Error struct {
+	Status  int
+	Code    i18n.Code
+	Args    []interface{} // Arguments of the catalog message of Code, e.g. the feature name
+	Details []Detail
+	Extra   map[string]interface{} // Keys answered next to the envelope, e.g. quota_context
+	Err     error
+}
+
+// New wraps err with the status and code of its response
+func New(status int, code i18n.Code, err error) *Error {
+	return &Error{Status: status, Code: code, Err: err}
+}
+
+// BadRequest wraps an error of the request itself, answered with 400
+func BadRequest(code i18n.Code, err error) *Error {
+	return New(http.StatusBadRequest, code, err)
+}
+
+// WithDetails returns the error with the details appended
+func (e *Error) WithDetails(details ...Detail) *Error {
+	e.Details = append(e.Details, details...)
+	return e
+}
+
+// WithArgs returns the error with the arguments of its catalog message
+func (e *Error) WithArgs(args ...interface{}) *Error {
+	e.Args = args
+	return e
+}
+
+// With returns the error with key answered next to the envelope
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Extra == nil {
+		e.Extra = map[string]interface{}{}
+	}
+	e.Extra[key] = value
+	return e
+}
+
+// Error returns the text of the wrapped error
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// As returns the Error in the chain of err, if any
+func As(err error) (*Error, bool) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// Mapping answers a domain sentinel error, and the errors wrapping it, with a status and code
+type Mapping struct {
+	Target error
+	Status int
+	Code   i18n.Code
+}
+
+// Classify wraps err with the status and code of the first mapping it matches. An err that
+// already is an Error keeps its own; one matching no mapping gets fallback and no code, and is
+// answered with the generic message of its status.
+func Classify(err error, mappings []Mapping, fallback int) *Error {
+	if apiErr, ok := As(err); ok {
+		return apiErr
+	}
+	for _, mapping := range mappings {
+		if errors.Is(err, mapping.Target) {
+			return New(mapping.Status, mapping.Code, err)
+		}
+	}
+	return New(fallback, "", err)
+}
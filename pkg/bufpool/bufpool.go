@@ -0,0 +1,37 @@
+package bufpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledSize keeps oversized buffers (e.g. from a huge NFC-e) out of the pool,
+// so a single large document does not pin its memory for the process lifetime
+const maxPooledSize = 1 << 20 // 1 MiB
+
+var pool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Get returns an empty buffer from the pool
+func Get() *bytes.Buffer {
+	buf := pool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// Put returns the buffer to the pool. The buffer content must not be used afterwards;
+// callers that return the bytes copy them first (see Bytes).
+func Put(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledSize {
+		return
+	}
+	pool.Put(buf)
+}
+
+// Bytes copies the buffer content, so the buffer can go back to the pool
+func Bytes(buf *bytes.Buffer) []byte {
+	return append([]byte(nil), buf.Bytes()...)
+}
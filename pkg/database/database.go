@@ -2,38 +2,114 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
 var DB *gorm.DB
 
+// replicaResolver names the resolver of the read replicas; only the queries that opt in with
+// ReadReplica are routed to it, every other statement goes to the primary
+const replicaResolver = "read_replica"
+
+// Config holds the connection settings of the primary and of its read replicas
+type Config struct {
+	DSN         string
+	ReplicaDSNs []string // Read replicas of the reporting queries; none routes them to the primary
+	Env         string
+
+	// Pool of each connection (primary and every replica); zero keeps the database/sql default
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
 // InitDatabase initializes the database connection
 func InitDatabase(ctx context.Context, databaseURL string, env string) error {
+	return InitDatabaseWithConfig(ctx, Config{DSN: databaseURL, Env: env})
+}
+
+// InitDatabaseWithConfig initializes the primary connection with its pool settings and
+// registers the read replicas, if any
+func InitDatabaseWithConfig(ctx context.Context, config Config) error {
 	var err error
 
 	// Configure GORM logger
 	gormLogger := logger.Default.LogMode(logger.Info)
-	if env == "production" {
+	if config.Env == "production" {
 		gormLogger = logger.Default.LogMode(logger.Error)
 	}
 
 	// Connect to database
-	DB, err = gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+	DB, err = gorm.Open(postgres.Open(config.DSN), &gorm.Config{
 		Logger: gormLogger,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database pool: %w", err)
+	}
+	configurePool(config, sqlDB)
+
+	if len(config.ReplicaDSNs) > 0 {
+		replicas := make([]gorm.Dialector, 0, len(config.ReplicaDSNs))
+		for _, dsn := range config.ReplicaDSNs {
+			replicas = append(replicas, postgres.Open(dsn))
+		}
+
+		// Registered under a name rather than globally, so the emission reads keep reading
+		// their own writes on the primary
+		resolver := dbresolver.Register(dbresolver.Config{Replicas: replicas}, replicaResolver)
+		if err := DB.Use(resolver); err != nil {
+			return fmt.Errorf("failed to connect to read replicas: %w", err)
+		}
+		resolver.Call(func(pool gorm.ConnPool) error {
+			if sqlDB, ok := pool.(*sql.DB); ok {
+				configurePool(config, sqlDB)
+			}
+			return nil
+		})
+		log.Printf("Database read replicas registered: %d", len(replicas))
+	}
+
 	log.Println("Database connected successfully")
 	return nil
 }
 
+// configurePool applies the pool settings that were configured
+func configurePool(config Config, pool *sql.DB) {
+	if config.MaxOpenConns > 0 {
+		pool.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		pool.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		pool.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	if config.ConnMaxIdleTime > 0 {
+		pool.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+}
+
+// ReadReplica routes the reads of the query to a read replica, when one is registered. The
+// replicas lag behind the primary: use it for listings, statistics and exports, never to
+// read a row just written.
+func ReadReplica(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Use(replicaResolver))
+}
+
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
 	return DB
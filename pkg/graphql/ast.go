@@ -0,0 +1,140 @@
+package graphql
+
+// Document is a parsed GraphQL request document
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is an operation definition of the document
+type Operation struct {
+	Type         string // query, mutation or subscription
+	Name         string
+	Variables    []*VariableDefinition
+	Directives   []*Directive
+	SelectionSet []Selection
+	Loc          Location
+}
+
+// VariableDefinition declares a variable of an operation
+type VariableDefinition struct {
+	Name    string
+	Type    *TypeRef
+	Default *Value
+	Loc     Location
+}
+
+// TypeRef is a type named in the document, such as [ID!]!
+type TypeRef struct {
+	Name    string   // Named type, empty for a list
+	Elem    *TypeRef // Element type of a list
+	NonNull bool
+}
+
+// String returns the type as written in GraphQL
+func (t *TypeRef) String() string {
+	s := t.Name
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+// Fragment is a named fragment definition
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Loc           Location
+}
+
+// Selection is a *Field, a *FragmentSpread or an *InlineFragment
+type Selection interface {
+	location() Location
+}
+
+// Field is a field selection
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    []*Argument
+	Directives   []*Directive
+	SelectionSet []Selection
+	Loc          Location
+}
+
+// ResponseKey is the name of the field in the result: its alias, or its name
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread is a ...Name selection
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+	Loc        Location
+}
+
+// InlineFragment is a ... on Type { } selection; the type condition is optional
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Loc           Location
+}
+
+func (f *Field) location() Location          { return f.Loc }
+func (f *FragmentSpread) location() Location { return f.Loc }
+func (f *InlineFragment) location() Location { return f.Loc }
+
+// Argument is an argument of a field or directive
+type Argument struct {
+	Name  string
+	Value *Value
+	Loc   Location
+}
+
+// Directive is a directive such as @include(if: $flag)
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+	Loc       Location
+}
+
+// ValueKind is the kind of a value literal
+type ValueKind int
+
+// Kinds of value literals
+const (
+	KindVariable ValueKind = iota
+	KindInt
+	KindFloat
+	KindString
+	KindBoolean
+	KindNull
+	KindEnum
+	KindList
+	KindObject
+)
+
+// Value is a value literal or a variable reference
+type Value struct {
+	Kind   ValueKind
+	Raw    string // Variable name, number, string content, true/false or enum name
+	List   []*Value
+	Fields []*ObjectField
+	Loc    Location
+}
+
+// ObjectField is a field of an input object literal
+type ObjectField struct {
+	Name  string
+	Value *Value
+}
@@ -0,0 +1,364 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Params are the inputs of the execution of a request
+type Params struct {
+	Schema        *Schema
+	Query         string
+	OperationName string
+	Variables     map[string]interface{}
+	Root          interface{} // Source of the query fields
+	MaxDepth      int         // Deepest field nesting allowed, 0 for no limit
+}
+
+// Execute runs a query of the request against the schema. Syntax and validation errors fail
+// the request without data; an error of a field makes it null and is reported with its path.
+// A null in a non-null field is reported the same way, it does not null the parent.
+//
+// The fields are resolved level by level: every field of a level, across all the objects of
+// the level, is resolved before any Thunk is called, so the loads of sibling objects batch.
+// The execution is sequential; resolvers need not be safe for concurrent use.
+func Execute(ctx context.Context, p Params) *Response {
+	doc, err := Parse(p.Query)
+	if err != nil {
+		return errorResponse(asError(err))
+	}
+
+	op, gqlErr := selectOperation(doc, p.OperationName)
+	if gqlErr != nil {
+		return errorResponse(gqlErr)
+	}
+	if op.Type != "query" {
+		return errorResponse(newError(op.Loc, "Only queries are supported, %s operations are not.", op.Type))
+	}
+
+	scope, errs := p.Schema.coerceVariables(op, p.Variables)
+	if len(errs) > 0 {
+		return errorResponse(errs...)
+	}
+
+	e := &executor{
+		ctx:       ctx,
+		schema:    p.Schema,
+		fragments: doc.Fragments,
+		scope:     scope,
+		args:      map[*Field]map[string]interface{}{},
+	}
+	if errs := e.validate(op, p.MaxDepth); len(errs) > 0 {
+		return errorResponse(errs...)
+	}
+
+	data := newOrderedMap(len(op.SelectionSet))
+	e.run(&objectWork{
+		typ:    p.Schema.Query,
+		fields: e.collectFields(p.Schema.Query, op.SelectionSet),
+		items:  []*objectItem{{source: p.Root, out: data}},
+	})
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// selectOperation picks the operation of the document to run
+func selectOperation(doc *Document, name string) (*Operation, *Error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named \"%s\".", name)}
+}
+
+// asError converts an error into a response error
+func asError(err error) *Error {
+	var gqlErr *Error
+	if errors.As(err, &gqlErr) {
+		return gqlErr
+	}
+	return &Error{Message: err.Error()}
+}
+
+// executor holds the state of one execution
+type executor struct {
+	ctx       context.Context
+	schema    *Schema
+	fragments map[string]*Fragment
+	scope     *variableScope
+	args      map[*Field]map[string]interface{} // Coerced by the validation
+	errors    []*Error
+
+	next      []*objectWork
+	nextIndex map[*collectedField]*objectWork
+}
+
+// collectedField is a response key with the field selections merged into it
+type collectedField struct {
+	key    string
+	name   string
+	fields []*Field
+}
+
+// objectWork is the selection of one object type over the objects of a level that share it
+type objectWork struct {
+	typ    *Object
+	fields []*collectedField
+	items  []*objectItem
+}
+
+// objectItem is an object to resolve and the result it fills
+type objectItem struct {
+	source interface{}
+	out    *OrderedMap
+	path   []interface{}
+}
+
+// resolvedField is the value resolved for a field of an object, before completion
+type resolvedField struct {
+	item  *objectItem
+	field *collectedField
+	def   *FieldDefinition
+	path  []interface{}
+	value interface{}
+	err   error
+}
+
+// typenameField is the __typename meta field every object has
+var typenameField = &FieldDefinition{Name: "__typename", Type: &NonNull{Of: String}}
+
+// run executes the levels of the response until no object is left to resolve
+func (e *executor) run(root *objectWork) {
+	level := []*objectWork{root}
+	for len(level) > 0 {
+		e.next, e.nextIndex = nil, map[*collectedField]*objectWork{}
+
+		var resolved []*resolvedField
+		for _, work := range level {
+			for _, item := range work.items {
+				for _, field := range work.fields {
+					resolved = append(resolved, e.resolve(work.typ, field, item))
+				}
+			}
+		}
+
+		// Thunks run after the whole level was resolved, a chained thunk right away
+		for _, r := range resolved {
+			for r.err == nil {
+				thunk, ok := r.value.(Thunk)
+				if !ok {
+					break
+				}
+				r.value, r.err = e.call(thunk)
+			}
+		}
+
+		for _, r := range resolved {
+			key := r.field.key
+			if r.err != nil {
+				e.fieldError(r.err, r.field, r.path)
+				r.item.out.Set(key, nil)
+				continue
+			}
+			r.item.out.Set(key, e.complete(r.def.Type, r.value, r.field, r.path))
+		}
+
+		level = e.next
+	}
+}
+
+// resolve authorizes and resolves a field of the object
+func (e *executor) resolve(typ *Object, field *collectedField, item *objectItem) *resolvedField {
+	r := &resolvedField{item: item, field: field, path: appendPath(item.path, field.key)}
+	if field.name == typenameField.Name {
+		r.def, r.value = typenameField, typ.Name
+		return r
+	}
+
+	r.def = typ.Field(field.name)
+	if r.def.Authorize != nil {
+		if r.err = r.def.Authorize(e.ctx, item.source); r.err != nil {
+			return r
+		}
+	}
+	if r.def.Resolve == nil {
+		if source, ok := item.source.(map[string]interface{}); ok {
+			r.value = source[field.name]
+		}
+		return r
+	}
+
+	params := ResolveParams{
+		Context: e.ctx,
+		Source:  item.source,
+		Args:    e.args[field.fields[0]],
+		Path:    r.path,
+	}
+	r.value, r.err = e.call(func() (interface{}, error) {
+		return r.def.Resolve(params)
+	})
+	return r
+}
+
+// call runs a resolver, reporting a panic as an error of the field
+func (e *executor) call(fn func() (interface{}, error)) (value interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			value, err = nil, fmt.Errorf("internal error: %v", recovered)
+		}
+	}()
+	return fn()
+}
+
+// complete converts the resolved value to the result of the type. Objects are queued for
+// the next level; their result is filled when it runs.
+func (e *executor) complete(t Type, value interface{}, field *collectedField, path []interface{}) interface{} {
+	if nonNull, ok := t.(*NonNull); ok {
+		errorCount := len(e.errors)
+		completed := e.complete(nonNull.Of, value, field, path)
+		if completed == nil && len(e.errors) == errorCount {
+			e.fieldError(fmt.Errorf("Cannot return null for non-nullable field %s.", field.name), field, path)
+		}
+		return completed
+	}
+
+	if list, ok := t.(*List); ok {
+		if value == nil {
+			return nil
+		}
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fieldError(fmt.Errorf("Expected a list for field %s.", field.name), field, path)
+			return nil
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = e.complete(list.Of, rv.Index(i).Interface(), field, appendPath(path, i))
+		}
+		return items
+	}
+
+	if isNil(value) {
+		return nil
+	}
+	switch t := t.(type) {
+	case *Scalar:
+		serialized, err := t.Serialize(value)
+		if err != nil {
+			e.fieldError(err, field, path)
+			return nil
+		}
+		return serialized
+	case *Enum:
+		name, ok := t.nameOf(value)
+		if !ok {
+			e.fieldError(fmt.Errorf("%s cannot represent %v.", t.Name, value), field, path)
+			return nil
+		}
+		return name
+	case *Object:
+		out := newOrderedMap(len(field.fields))
+		e.enqueue(t, field, &objectItem{source: value, out: out, path: path})
+		return out
+	}
+	return nil
+}
+
+// enqueue adds the object to the work of the next level for the selections of the field
+func (e *executor) enqueue(typ *Object, field *collectedField, item *objectItem) {
+	work := e.nextIndex[field]
+	if work == nil {
+		var selections []Selection
+		for _, f := range field.fields {
+			selections = append(selections, f.SelectionSet...)
+		}
+		work = &objectWork{typ: typ, fields: e.collectFields(typ, selections)}
+		e.nextIndex[field] = work
+		e.next = append(e.next, work)
+	}
+	work.items = append(work.items, item)
+}
+
+// fieldError records the error of the field at path
+func (e *executor) fieldError(err error, field *collectedField, path []interface{}) {
+	gqlErr := &Error{Message: err.Error(), Locations: []Location{field.fields[0].Loc}, Path: path}
+	var withExtensions *Error
+	if errors.As(err, &withExtensions) {
+		gqlErr.Extensions = withExtensions.Extensions
+	}
+	e.errors = append(e.errors, gqlErr)
+}
+
+// collectFields merges the selections, fragments included, into the fields of the response
+// in order, leaving out the ones skipped by @skip or @include
+func (e *executor) collectFields(typ *Object, selections []Selection) []*collectedField {
+	var fields []*collectedField
+	index := map[string]*collectedField{}
+	e.collect(typ, selections, &fields, index, map[string]bool{})
+	return fields
+}
+
+func (e *executor) collect(typ *Object, selections []Selection, fields *[]*collectedField, index map[string]*collectedField, visited map[string]bool) {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *Field:
+			if !e.included(s.Directives) {
+				continue
+			}
+			key := s.ResponseKey()
+			if existing, ok := index[key]; ok {
+				existing.fields = append(existing.fields, s)
+				continue
+			}
+			field := &collectedField{key: key, name: s.Name, fields: []*Field{s}}
+			index[key] = field
+			*fields = append(*fields, field)
+		case *InlineFragment:
+			if !e.included(s.Directives) || (s.TypeCondition != "" && s.TypeCondition != typ.Name) {
+				continue
+			}
+			e.collect(typ, s.SelectionSet, fields, index, visited)
+		case *FragmentSpread:
+			if visited[s.Name] || !e.included(s.Directives) {
+				continue
+			}
+			visited[s.Name] = true
+			fragment := e.fragments[s.Name]
+			if fragment.TypeCondition != typ.Name {
+				continue
+			}
+			e.collect(typ, fragment.SelectionSet, fields, index, visited)
+		}
+	}
+}
+
+// included evaluates the @skip and @include directives, validated beforehand
+func (e *executor) included(directives []*Directive) bool {
+	for _, directive := range directives {
+		args, err := coerceArguments(conditionArgs, directive.Arguments, e.scope)
+		if err != nil {
+			continue
+		}
+		condition, _ := args["if"].(bool)
+		if directive.Name == "skip" && condition || directive.Name == "include" && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// appendPath returns a copy of the path with the segment appended
+func appendPath(path []interface{}, segment interface{}) []interface{} {
+	out := make([]interface{}, len(path)+1)
+	copy(out, path)
+	out[len(path)] = segment
+	return out
+}
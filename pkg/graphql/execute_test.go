@@ -0,0 +1,435 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// companies are the sources of the Company objects of the test schema
+var companies = map[string]map[string]interface{}{
+	"1": {"id": "1", "name": "Matriz", "parentId": nil, "tags": []string{"varejo"}},
+	"2": {"id": "2", "name": "Filial A", "parentId": "1", "tags": []string{}},
+	"3": {"id": "3", "name": "Filial B", "parentId": "1", "tags": nil},
+}
+
+type loaderKey struct{}
+
+// newTestSchema builds a schema over the companies; the parent of a company is loaded by the
+// loader of the context
+func newTestSchema(t *testing.T) *Schema {
+	t.Helper()
+
+	status := &Enum{Name: "Status", Values: []*EnumValue{
+		{Name: "AUTHORIZED", Value: "authorized"},
+		{Name: "REJECTED", Value: "rejected"},
+	}}
+	company := &Object{Name: "Company", Fields: []*FieldDefinition{
+		{Name: "id", Type: &NonNull{Of: ID}},
+		{Name: "name", Type: &NonNull{Of: String}},
+		{Name: "tags", Type: &List{Of: &NonNull{Of: String}}},
+	}}
+	company.Fields = append(company.Fields, &FieldDefinition{
+		Name: "parent",
+		Type: company,
+		Resolve: func(p ResolveParams) (interface{}, error) {
+			parentID, _ := p.Source.(map[string]interface{})["parentId"].(string)
+			if parentID == "" {
+				return nil, nil
+			}
+			return p.Context.Value(loaderKey{}).(*Loader[string, map[string]interface{}]).Load(parentID), nil
+		},
+	})
+
+	query := &Object{Name: "Query", Fields: []*FieldDefinition{
+		{
+			Name: "hello",
+			Type: &NonNull{Of: String},
+			Args: []*ArgumentDefinition{{Name: "name", Type: String, Default: "world"}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				return fmt.Sprintf("hello %v", p.Args["name"]), nil
+			},
+		},
+		{
+			Name: "company",
+			Type: company,
+			Args: []*ArgumentDefinition{{Name: "id", Type: &NonNull{Of: ID}}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				if c, ok := companies[p.Args["id"].(string)]; ok {
+					return c, nil
+				}
+				return nil, nil
+			},
+		},
+		{
+			Name: "companies",
+			Type: &NonNull{Of: &List{Of: &NonNull{Of: company}}},
+			Resolve: func(ResolveParams) (interface{}, error) {
+				return []map[string]interface{}{companies["1"], companies["2"], companies["3"]}, nil
+			},
+		},
+		{
+			Name: "status",
+			Type: status,
+			Args: []*ArgumentDefinition{{Name: "is", Type: status}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				return p.Args["is"], nil
+			},
+		},
+		{
+			Name: "sum",
+			Type: Int,
+			Args: []*ArgumentDefinition{{Name: "values", Type: &NonNull{Of: &List{Of: &NonNull{Of: Int}}}}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				total := 0
+				for _, value := range p.Args["values"].([]interface{}) {
+					total += value.(int)
+				}
+				return total, nil
+			},
+		},
+		{
+			Name:      "secret",
+			Type:      String,
+			Authorize: func(context.Context, interface{}) error { return errors.New("not allowed") },
+			Resolve: func(ResolveParams) (interface{}, error) {
+				t.Error("secret resolved despite the failed authorization")
+				return "leaked", nil
+			},
+		},
+		{
+			Name: "coded",
+			Type: String,
+			Resolve: func(ResolveParams) (interface{}, error) {
+				return nil, &Error{Message: "gone", Extensions: map[string]interface{}{"code": "NOT_FOUND"}}
+			},
+		},
+		{Name: "panics", Type: String, Resolve: func(ResolveParams) (interface{}, error) { panic("boom") }},
+		{Name: "missing", Type: &NonNull{Of: String}, Resolve: func(ResolveParams) (interface{}, error) { return nil, nil }},
+		{Name: "big", Type: Int, Resolve: func(ResolveParams) (interface{}, error) { return int64(1) << 40, nil }},
+	}}
+
+	schema, err := NewSchema(query)
+	if err != nil {
+		t.Fatalf("NewSchema: %v", err)
+	}
+	return schema
+}
+
+// execute runs the query against the test schema; fetches are the key batches of the loader
+func execute(t *testing.T, p Params) (resp *Response, fetches [][]string) {
+	t.Helper()
+
+	p.Schema = newTestSchema(t)
+	loader := NewLoader(context.Background(), func(_ context.Context, keys []string) (map[string]map[string]interface{}, error) {
+		fetches = append(fetches, keys)
+		byID := map[string]map[string]interface{}{}
+		for _, key := range keys {
+			byID[key] = companies[key]
+		}
+		return byID, nil
+	})
+	ctx := context.WithValue(context.Background(), loaderKey{}, loader)
+	return Execute(ctx, p), fetches
+}
+
+// marshal returns the response as JSON
+func marshal(t *testing.T, resp *Response) string {
+	t.Helper()
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		operation string
+		variables map[string]interface{}
+		want      string
+	}{
+		{
+			name:  "default argument",
+			query: `{ hello }`,
+			want:  `{"data":{"hello":"hello world"}}`,
+		},
+		{
+			name:      "variables",
+			query:     `query Greet($name: String) { hello(name: $name) }`,
+			variables: map[string]interface{}{"name": "NFC-e"},
+			want:      `{"data":{"hello":"hello NFC-e"}}`,
+		},
+		{
+			name:  "variable not provided leaves the default",
+			query: `query Greet($name: String) { hello(name: $name) }`,
+			want:  `{"data":{"hello":"hello world"}}`,
+		},
+		{
+			name:      "operation name",
+			query:     `query A { a: hello } query B { b: hello(name: "B") }`,
+			operation: "B",
+			want:      `{"data":{"b":"hello B"}}`,
+		},
+		{
+			name:  "aliases keep the selection order",
+			query: `{ z: hello(name: "z") a: hello(name: "a") __typename }`,
+			want:  `{"data":{"z":"hello z","a":"hello a","__typename":"Query"}}`,
+		},
+		{
+			name:  "nested objects and lists",
+			query: `{ company(id: "2") { id name tags parent { name tags } } }`,
+			want:  `{"data":{"company":{"id":"2","name":"Filial A","tags":[],"parent":{"name":"Matriz","tags":["varejo"]}}}}`,
+		},
+		{
+			name:  "null object",
+			query: `{ company(id: "9") { id } }`,
+			want:  `{"data":{"company":null}}`,
+		},
+		{
+			name:  "fragments merge into the fields",
+			query: `{ company(id: "1") { ...Names ... on Company { id } id } } fragment Names on Company { name }`,
+			want:  `{"data":{"company":{"name":"Matriz","id":"1"}}}`,
+		},
+		{
+			name:      "skip and include",
+			query:     `query($yes: Boolean!) { a: hello @include(if: $yes) b: hello @skip(if: $yes) c: hello @include(if: false) }`,
+			variables: map[string]interface{}{"yes": true},
+			want:      `{"data":{"a":"hello world"}}`,
+		},
+		{
+			name:  "enums",
+			query: `{ status(is: REJECTED) }`,
+			want:  `{"data":{"status":"REJECTED"}}`,
+		},
+		{
+			name:      "enum variable",
+			query:     `query($s: Status) { status(is: $s) }`,
+			variables: map[string]interface{}{"s": "AUTHORIZED"},
+			want:      `{"data":{"status":"AUTHORIZED"}}`,
+		},
+		{
+			name:      "list variable from JSON numbers",
+			query:     `query($v: [Int!]!) { sum(values: $v) }`,
+			variables: map[string]interface{}{"v": []interface{}{json.Number("1"), json.Number("2")}},
+			want:      `{"data":{"sum":3}}`,
+		},
+		{
+			name:  "single value coerced to a list",
+			query: `{ sum(values: 4) }`,
+			want:  `{"data":{"sum":4}}`,
+		},
+		{
+			name:  "failed authorization nulls the field",
+			query: `{ hello secret }`,
+			want:  `{"data":{"hello":"hello world","secret":null},"errors":[{"message":"not allowed","locations":[{"line":1,"column":9}],"path":["secret"]}]}`,
+		},
+		{
+			name:  "error extensions",
+			query: `{ coded }`,
+			want:  `{"data":{"coded":null},"errors":[{"message":"gone","locations":[{"line":1,"column":3}],"path":["coded"],"extensions":{"code":"NOT_FOUND"}}]}`,
+		},
+		{
+			name:  "panic",
+			query: `{ panics }`,
+			want:  `{"data":{"panics":null},"errors":[{"message":"internal error: boom","locations":[{"line":1,"column":3}],"path":["panics"]}]}`,
+		},
+		{
+			name:  "null in a non-null field",
+			query: `{ missing }`,
+			want:  `{"data":{"missing":null},"errors":[{"message":"Cannot return null for non-nullable field missing.","locations":[{"line":1,"column":3}],"path":["missing"]}]}`,
+		},
+		{
+			name:  "value out of the scalar range",
+			query: `{ big }`,
+			want:  `{"data":{"big":null},"errors":[{"message":"Int cannot represent 1099511627776","locations":[{"line":1,"column":3}],"path":["big"]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := execute(t, Params{Query: tt.query, OperationName: tt.operation, Variables: tt.variables})
+			if got := marshal(t, resp); got != tt.want {
+				t.Fatalf("response\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteBatchesLoads(t *testing.T) {
+	resp, fetches := execute(t, Params{Query: `{ companies { id parent { id parent { id } } } }`})
+	got := marshal(t, resp)
+
+	want := `{"data":{"companies":[{"id":"1","parent":null},{"id":"2","parent":{"id":"1","parent":null}},{"id":"3","parent":{"id":"1","parent":null}}]}}`
+	if got != want {
+		t.Fatalf("response\n got: %s\nwant: %s", got, want)
+	}
+	// Both filiais load their parent in one batch, cached for the level below
+	if wantFetches := [][]string{{"1"}}; !reflect.DeepEqual(fetches, wantFetches) {
+		t.Fatalf("fetches = %v, want %v", fetches, wantFetches)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		operation string
+		variables map[string]interface{}
+		want      string
+	}{
+		{name: "syntax", query: `{ hello(`, want: "Syntax Error: unexpected end of document"},
+		{name: "mutation", query: `mutation { hello }`, want: "Only queries are supported, mutation operations are not."},
+		{name: "several operations without a name", query: `query A { hello } query B { hello }`, want: "Must provide operation name if query contains multiple operations."},
+		{name: "unknown operation", query: `query A { hello }`, operation: "B", want: `Unknown operation named "B".`},
+		{name: "unknown field", query: `{ nope }`, want: `Cannot query field "nope" on type "Query".`},
+		{name: "unknown nested field", query: `{ company(id: "1") { cnpj } }`, want: `Cannot query field "cnpj" on type "Company".`},
+		{name: "unknown argument", query: `{ hello(nome: "x") }`, want: `Unknown argument "nome".`},
+		{name: "duplicated argument", query: `{ hello(name: "a", name: "b") }`, want: `There can be only one argument named "name".`},
+		{name: "missing required argument", query: `{ company { id } }`, want: `Argument "id" of type "ID!" is required, but it was not provided.`},
+		{name: "null required argument", query: `{ company(id: null) { id } }`, want: `Argument "id" has an invalid value: expected a non-null ID.`},
+		{name: "argument of the wrong type", query: `{ hello(name: 1) }`, want: `Argument "name" has an invalid value: String cannot represent 1.`},
+		{name: "unknown enum value", query: `{ status(is: CANCELED) }`, want: `Argument "is" has an invalid value: Status does not have a value CANCELED.`},
+		{name: "enum as a string", query: `{ status(is: "REJECTED") }`, want: `Argument "is" has an invalid value: Status does not have a value REJECTED.`},
+		{name: "object without selection", query: `{ company(id: "1") }`, want: `Field "company" of type "Company" must have a selection of subfields.`},
+		{name: "leaf with selection", query: `{ hello { length } }`, want: `Field "hello" must not have a selection since type "String!" has no subfields.`},
+		{name: "unknown fragment", query: `{ ...Nope }`, want: `Unknown fragment "Nope".`},
+		{name: "unused fragment", query: `{ hello } fragment F on Query { hello }`, want: `Fragment "F" is never used.`},
+		{name: "fragment cycle", query: `{ ...A } fragment A on Query { ...B } fragment B on Query { ...A }`, want: `Cannot spread fragment "A" within itself.`},
+		{name: "fragment on the wrong type", query: `{ ...F } fragment F on Company { id }`, want: `Fragment cannot be spread here as objects of type "Query" can never be of type "Company".`},
+		{name: "fragment on an unknown type", query: `{ ... on Nope { hello } }`, want: `Unknown type "Nope".`},
+		{name: "unknown directive", query: `{ hello @deprecated }`, want: `Unknown directive "@deprecated".`},
+		{name: "directive without condition", query: `{ hello @skip }`, want: `Directive "@skip": Argument "if" of type "Boolean!" is required, but it was not provided.`},
+		{name: "directive on the operation", query: `query @skip(if: true) { hello }`, want: `Directive "@skip" may not be used on query.`},
+		{name: "undefined variable", query: `{ hello(name: $name) }`, want: `Argument "name" has an invalid value: variable "$name" is not defined.`},
+		{name: "variable of the wrong type", query: `query($id: String!) { company(id: $id) { id } }`, variables: map[string]interface{}{"id": "1"}, want: `Argument "id" has an invalid value: variable "$id" of type "String!" used in position expecting type "ID!".`},
+		{name: "nullable variable in a non-null position", query: `query($id: ID) { company(id: $id) { id } }`, variables: map[string]interface{}{"id": "1"}, want: `Argument "id" has an invalid value: variable "$id" of type "ID" used in position expecting type "ID!".`},
+		{name: "required variable not provided", query: `query($id: ID!) { company(id: $id) { id } }`, want: `Variable "$id" of required type "ID!" was not provided.`},
+		{name: "invalid variable", query: `query($v: [Int!]!) { sum(values: $v) }`, variables: map[string]interface{}{"v": []interface{}{json.Number("1"), "2"}}, want: `Variable "$v" got invalid value: at index 1: Int cannot represent 2.`},
+		{name: "duplicated variable", query: `query($a: Int, $a: Int) { hello }`, want: `There can be only one variable named "$a".`},
+		{name: "variable of an output type", query: `query($c: Company) { hello }`, want: `Variable "$c": Company is not an input type.`},
+		{name: "variable of an unknown type", query: `query($c: Nope) { hello }`, want: `Variable "$c": Unknown type "Nope".`},
+		{name: "invalid default", query: `query($n: Int = "x") { sum(values: [$n]) }`, want: `Variable "$n" has an invalid default value: Int cannot represent x.`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := execute(t, Params{
+				Query:         tt.query,
+				OperationName: tt.operation,
+				Variables:     tt.variables,
+			})
+			if resp.Data != nil {
+				t.Fatalf("data = %v, want none for an invalid request", resp.Data)
+			}
+			if len(resp.Errors) == 0 || resp.Errors[0].Message != tt.want {
+				t.Fatalf("errors = %s, want %q first", messages(resp.Errors), tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		maxDepth int
+		wantErr  bool
+	}{
+		{name: "no limit", query: `{ company(id: "3") { parent { parent { id } } } }`},
+		{name: "at the limit", query: `{ company(id: "3") { parent { id } } }`, maxDepth: 3},
+		{name: "over the limit", query: `{ company(id: "3") { parent { parent { id } } } }`, maxDepth: 3, wantErr: true},
+		{name: "through a fragment", query: `{ company(id: "3") { ...P } } fragment P on Company { parent { parent { id } } }`, maxDepth: 3, wantErr: true},
+		{name: "through an inline fragment", query: `{ company(id: "3") { ... on Company { parent { parent { id } } } } }`, maxDepth: 3, wantErr: true},
+		{name: "fragment reused at a shallower depth", query: `{ a: company(id: "3") { ...I } b: company(id: "3") { parent { ...I } } } fragment I on Company { id }`, maxDepth: 3},
+		{name: "fragment reused deeper", query: `{ a: company(id: "3") { ...I } b: company(id: "3") { parent { ...I } } } fragment I on Company { parent { id } }`, maxDepth: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := execute(t, Params{Query: tt.query, MaxDepth: tt.maxDepth})
+			if !tt.wantErr {
+				if len(resp.Errors) > 0 {
+					t.Fatalf("errors = %s, want none", messages(resp.Errors))
+				}
+				return
+			}
+			want := fmt.Sprintf("Query exceeds the maximum depth of %d.", tt.maxDepth)
+			if resp.Data != nil || len(resp.Errors) != 1 || resp.Errors[0].Message != want {
+				t.Fatalf("errors = %s, want only %q", messages(resp.Errors), want)
+			}
+		})
+	}
+}
+
+func TestNewSchemaErrors(t *testing.T) {
+	object := &Object{Name: "Company", Fields: []*FieldDefinition{{Name: "id", Type: ID}}}
+	tests := []struct {
+		name  string
+		query *Object
+		want  string
+	}{
+		{
+			name:  "field defined twice",
+			query: &Object{Name: "Query", Fields: []*FieldDefinition{{Name: "a", Type: Int}, {Name: "a", Type: String}}},
+			want:  "graphql: field Query.a defined twice",
+		},
+		{
+			name:  "two types with a name",
+			query: &Object{Name: "Query", Fields: []*FieldDefinition{{Name: "a", Type: &Scalar{Name: "Int"}}}},
+			want:  "graphql: two types named Int",
+		},
+		{
+			name:  "non-null of non-null",
+			query: &Object{Name: "Query", Fields: []*FieldDefinition{{Name: "a", Type: &NonNull{Of: &NonNull{Of: Int}}}}},
+			want:  "graphql: Int!! wraps a non-null type",
+		},
+		{
+			name:  "object argument",
+			query: &Object{Name: "Query", Fields: []*FieldDefinition{{Name: "a", Type: Int, Args: []*ArgumentDefinition{{Name: "c", Type: object}}}}},
+			want:  "graphql: argument c of Query.a is not an input type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSchema(tt.query); err == nil || err.Error() != tt.want {
+				t.Fatalf("NewSchema = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderErrorFailsTheBatch(t *testing.T) {
+	calls := 0
+	loader := NewLoader(context.Background(), func(_ context.Context, keys []string) (map[string]int, error) {
+		calls++
+		return nil, errors.New("database down")
+	})
+	first, second := loader.Load("a"), loader.Load("b")
+	if _, err := first(); err == nil || err.Error() != "database down" {
+		t.Fatalf("first = %v, want the batch error", err)
+	}
+	if _, err := second(); err == nil {
+		t.Fatalf("second succeeded, want the batch error")
+	}
+	// The failed keys are not loaded again
+	if _, err := loader.Load("a")(); err == nil || calls != 1 {
+		t.Fatalf("reload: err %v after %d calls, want the cached error after 1", err, calls)
+	}
+}
+
+// messages lists the messages of the errors, for the failure output
+func messages(errs []*Error) []string {
+	out := make([]string, len(errs))
+	for i, err := range errs {
+		out[i] = err.Message
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package graphql
+
+import "context"
+
+// BatchFunc loads the values of the keys in one call. A key missing from the result has the
+// zero value; an error fails every key of the batch.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader batches and caches the loads of a request (DataLoader): the keys requested by
+// the fields of a level are loaded together when the first of their thunks runs. A loader
+// lives for one request and, like the executor, is not safe for concurrent use.
+type Loader[K comparable, V any] struct {
+	ctx     context.Context
+	fetch   BatchFunc[K, V]
+	pending []K
+	queued  map[K]bool
+	values  map[K]V
+	errs    map[K]error
+}
+
+// NewLoader creates a loader over the batch function
+func NewLoader[K comparable, V any](ctx context.Context, fetch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{
+		ctx:    ctx,
+		fetch:  fetch,
+		queued: map[K]bool{},
+		values: map[K]V{},
+		errs:   map[K]error{},
+	}
+}
+
+// Load queues the key and returns a thunk of its value, loaded with the keys queued until
+// the thunk runs
+func (l *Loader[K, V]) Load(key K) Thunk {
+	if _, loaded := l.values[key]; !loaded && !l.queued[key] && l.errs[key] == nil {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	return func() (interface{}, error) {
+		if l.queued[key] {
+			l.dispatch()
+		}
+		if err := l.errs[key]; err != nil {
+			return nil, err
+		}
+		return l.values[key], nil
+	}
+}
+
+// dispatch loads the queued keys
+func (l *Loader[K, V]) dispatch() {
+	keys := l.pending
+	l.pending = nil
+	for _, key := range keys {
+		delete(l.queued, key)
+	}
+
+	values, err := l.fetch(l.ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+			continue
+		}
+		l.values[key] = values[key]
+	}
+}
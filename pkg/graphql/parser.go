@@ -0,0 +1,409 @@
+package graphql
+
+// Parse parses a GraphQL request document: operations and fragments. Type system definitions
+// are not accepted.
+func Parse(query string) (*Document, error) {
+	p := &parser{lexer: newLexer(query)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Fragments: map[string]*Fragment{}}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunctuator, "{"):
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", SelectionSet: selections, Loc: selections[0].location()})
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"), p.peek(tokenName, "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.peek(tokenName, "fragment"):
+			fragment, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.Fragments[fragment.Name]; exists {
+				return nil, newError(fragment.Loc, "There can be only one fragment named %q.", fragment.Name)
+			}
+			doc.Fragments[fragment.Name] = fragment
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, &Error{Message: "The document has no operation."}
+	}
+	return doc, nil
+}
+
+// parser is a recursive descent parser over the tokens of the lexer
+type parser struct {
+	lexer *lexer
+	tok   token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// peek reports whether the current token is of the kind and, when given, the value
+func (p *parser) peek(kind tokenKind, value string) bool {
+	return p.tok.kind == kind && (value == "" || p.tok.value == value)
+}
+
+// skip consumes the current token if it matches and reports whether it did
+func (p *parser) skip(kind tokenKind, value string) (bool, error) {
+	if !p.peek(kind, value) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+// expect consumes the current token, which must match
+func (p *parser) expect(kind tokenKind, value string) (token, error) {
+	tok := p.tok
+	if !p.peek(kind, value) {
+		return tok, p.unexpected()
+	}
+	return tok, p.advance()
+}
+
+func (p *parser) name() (token, error) {
+	return p.expect(tokenName, "")
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return syntaxError(p.tok.loc, "unexpected end of document")
+	}
+	return syntaxError(p.tok.loc, "unexpected %q", p.tok.value)
+}
+
+func (p *parser) operation() (*Operation, error) {
+	tok, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	op := &Operation{Type: tok.value, Loc: tok.loc}
+
+	if p.peek(tokenName, "") {
+		name, _ := p.name()
+		op.Name = name.value
+	}
+	if op.Variables, err = p.variableDefinitions(); err != nil {
+		return nil, err
+	}
+	if op.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if op.SelectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) variableDefinitions() ([]*VariableDefinition, error) {
+	if ok, err := p.skip(tokenPunctuator, "("); !ok || err != nil {
+		return nil, err
+	}
+
+	var definitions []*VariableDefinition
+	for {
+		dollar, err := p.expect(tokenPunctuator, "$")
+		if err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenPunctuator, ":"); err != nil {
+			return nil, err
+		}
+		typ, err := p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		definition := &VariableDefinition{Name: name.value, Type: typ, Loc: dollar.loc}
+		if ok, err := p.skip(tokenPunctuator, "="); err != nil {
+			return nil, err
+		} else if ok {
+			if definition.Default, err = p.value(true); err != nil {
+				return nil, err
+			}
+		}
+		definitions = append(definitions, definition)
+
+		if ok, err := p.skip(tokenPunctuator, ")"); ok || err != nil {
+			return definitions, err
+		}
+	}
+}
+
+func (p *parser) typeRef() (*TypeRef, error) {
+	var typ *TypeRef
+	if ok, err := p.skip(tokenPunctuator, "["); err != nil {
+		return nil, err
+	} else if ok {
+		elem, err := p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenPunctuator, "]"); err != nil {
+			return nil, err
+		}
+		typ = &TypeRef{Elem: elem}
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		typ = &TypeRef{Name: name.value}
+	}
+
+	ok, err := p.skip(tokenPunctuator, "!")
+	typ.NonNull = ok
+	return typ, err
+}
+
+func (p *parser) fragment() (*Fragment, error) {
+	tok, _ := p.name() // fragment
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name.value == "on" {
+		return nil, syntaxError(name.loc, "unexpected %q", name.value)
+	}
+	if _, err := p.expect(tokenName, "on"); err != nil {
+		return nil, err
+	}
+	condition, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	fragment := &Fragment{Name: name.value, TypeCondition: condition.value, Loc: tok.loc}
+	if fragment.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if fragment.SelectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return fragment, nil
+}
+
+func (p *parser) selectionSet() ([]Selection, error) {
+	if _, err := p.expect(tokenPunctuator, "{"); err != nil {
+		return nil, err
+	}
+
+	var selections []Selection
+	for {
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+
+		if ok, err := p.skip(tokenPunctuator, "}"); ok || err != nil {
+			return selections, err
+		}
+	}
+}
+
+func (p *parser) selection() (Selection, error) {
+	if p.peek(tokenPunctuator, "...") {
+		return p.fragmentSelection()
+	}
+
+	tok, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	field := &Field{Name: tok.value, Loc: tok.loc}
+	if ok, err := p.skip(tokenPunctuator, ":"); err != nil {
+		return nil, err
+	} else if ok {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		field.Alias, field.Name = field.Name, name.value
+	}
+
+	if field.Arguments, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if field.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokenPunctuator, "{") {
+		if field.SelectionSet, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *parser) fragmentSelection() (Selection, error) {
+	dots, _ := p.expect(tokenPunctuator, "...")
+
+	if p.peek(tokenName, "") && p.tok.value != "on" {
+		name, _ := p.name()
+		directives, err := p.directives()
+		if err != nil {
+			return nil, err
+		}
+		return &FragmentSpread{Name: name.value, Directives: directives, Loc: dots.loc}, nil
+	}
+
+	fragment := &InlineFragment{Loc: dots.loc}
+	if ok, err := p.skip(tokenName, "on"); err != nil {
+		return nil, err
+	} else if ok {
+		condition, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		fragment.TypeCondition = condition.value
+	}
+
+	var err error
+	if fragment.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if fragment.SelectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return fragment, nil
+}
+
+func (p *parser) arguments() ([]*Argument, error) {
+	if ok, err := p.skip(tokenPunctuator, "("); !ok || err != nil {
+		return nil, err
+	}
+
+	var arguments []*Argument
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenPunctuator, ":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, &Argument{Name: name.value, Value: value, Loc: name.loc})
+
+		if ok, err := p.skip(tokenPunctuator, ")"); ok || err != nil {
+			return arguments, err
+		}
+	}
+}
+
+func (p *parser) directives() ([]*Directive, error) {
+	var directives []*Directive
+	for p.peek(tokenPunctuator, "@") {
+		at, _ := p.expect(tokenPunctuator, "@")
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		arguments, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, &Directive{Name: name.value, Arguments: arguments, Loc: at.loc})
+	}
+	return directives, nil
+}
+
+// value parses a value literal; variables are not allowed in a constant one
+func (p *parser) value(constant bool) (*Value, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenInt:
+		return &Value{Kind: KindInt, Raw: tok.value, Loc: tok.loc}, p.advance()
+	case tokenFloat:
+		return &Value{Kind: KindFloat, Raw: tok.value, Loc: tok.loc}, p.advance()
+	case tokenString:
+		return &Value{Kind: KindString, Raw: tok.value, Loc: tok.loc}, p.advance()
+	case tokenName:
+		value := &Value{Kind: KindEnum, Raw: tok.value, Loc: tok.loc}
+		switch tok.value {
+		case "true", "false":
+			value.Kind = KindBoolean
+		case "null":
+			value.Kind = KindNull
+		}
+		return value, p.advance()
+	}
+
+	switch tok.value {
+	case "$":
+		if constant {
+			return nil, p.unexpected()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Kind: KindVariable, Raw: name.value, Loc: tok.loc}, nil
+	case "[":
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		value := &Value{Kind: KindList, Loc: tok.loc}
+		for {
+			if ok, err := p.skip(tokenPunctuator, "]"); ok || err != nil {
+				return value, err
+			}
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			value.List = append(value.List, item)
+		}
+	case "{":
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		value := &Value{Kind: KindObject, Loc: tok.loc}
+		for {
+			if ok, err := p.skip(tokenPunctuator, "}"); ok || err != nil {
+				return value, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenPunctuator, ":"); err != nil {
+				return nil, err
+			}
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			value.Fields = append(value.Fields, &ObjectField{Name: name.value, Value: item})
+		}
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,212 @@
+package graphql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(`
+		# The NFC-e of a company
+		query List($company: ID!, $first: Int = 20, $status: [NFCeStatus!]) @cached {
+			page: nfces(companyId: $company, first: $first, filter: {status: AUTHORIZED, tags: ["a", "b"]}) {
+				nodes { ...NFCe @include(if: true) }
+				... on NFCeConnection { total }
+				... @skip(if: false) { nextCursor }
+			}
+			echo(text: """
+				multi
+				line""", n: -1.5e3, flag: null)
+		}
+
+		fragment NFCe on NFCe { id, chaveAcesso }
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if len(doc.Operations) != 1 || len(doc.Fragments) != 1 {
+		t.Fatalf("got %d operations and %d fragments, want 1 and 1", len(doc.Operations), len(doc.Fragments))
+	}
+	op := doc.Operations[0]
+	if op.Type != "query" || op.Name != "List" || op.Loc != (Location{Line: 3, Column: 3}) {
+		t.Fatalf("operation = %s %s at %+v", op.Type, op.Name, op.Loc)
+	}
+	if len(op.Directives) != 1 || op.Directives[0].Name != "cached" {
+		t.Fatalf("operation directives = %+v", op.Directives)
+	}
+
+	types := []string{"ID!", "Int", "[NFCeStatus!]"}
+	if len(op.Variables) != len(types) {
+		t.Fatalf("got %d variables, want %d", len(op.Variables), len(types))
+	}
+	for i, want := range types {
+		if got := op.Variables[i].Type.String(); got != want {
+			t.Errorf("variable %s type = %s, want %s", op.Variables[i].Name, got, want)
+		}
+	}
+	if def := op.Variables[1].Default; def == nil || def.Kind != KindInt || def.Raw != "20" {
+		t.Errorf("default of $first = %+v, want 20", def)
+	}
+
+	page := op.SelectionSet[0].(*Field)
+	if page.Alias != "page" || page.Name != "nfces" || page.ResponseKey() != "page" {
+		t.Fatalf("field = %s: %s", page.Alias, page.Name)
+	}
+	if len(page.Arguments) != 3 {
+		t.Fatalf("got %d arguments, want 3", len(page.Arguments))
+	}
+	if company := page.Arguments[0].Value; company.Kind != KindVariable || company.Raw != "company" {
+		t.Errorf("companyId = %+v, want $company", company)
+	}
+	filter := page.Arguments[2].Value
+	if filter.Kind != KindObject || len(filter.Fields) != 2 {
+		t.Fatalf("filter = %+v", filter)
+	}
+	if status := filter.Fields[0].Value; status.Kind != KindEnum || status.Raw != "AUTHORIZED" {
+		t.Errorf("status = %+v, want the AUTHORIZED enum", status)
+	}
+	if tags := filter.Fields[1].Value; tags.Kind != KindList || len(tags.List) != 2 || tags.List[1].Raw != "b" {
+		t.Errorf("tags = %+v", tags)
+	}
+
+	if len(page.SelectionSet) != 3 {
+		t.Fatalf("got %d selections, want 3", len(page.SelectionSet))
+	}
+	nodes := page.SelectionSet[0].(*Field)
+	spread := nodes.SelectionSet[0].(*FragmentSpread)
+	if spread.Name != "NFCe" || len(spread.Directives) != 1 || spread.Directives[0].Name != "include" {
+		t.Errorf("spread = %+v", spread)
+	}
+	if inline := page.SelectionSet[1].(*InlineFragment); inline.TypeCondition != "NFCeConnection" {
+		t.Errorf("inline fragment condition = %q", inline.TypeCondition)
+	}
+	if inline := page.SelectionSet[2].(*InlineFragment); inline.TypeCondition != "" || len(inline.Directives) != 1 {
+		t.Errorf("inline fragment without condition = %+v", inline)
+	}
+
+	echo := op.SelectionSet[1].(*Field)
+	wantArgs := []Value{{Kind: KindString, Raw: "multi\nline"}, {Kind: KindFloat, Raw: "-1.5e3"}, {Kind: KindNull, Raw: "null"}}
+	for i, want := range wantArgs {
+		if got := echo.Arguments[i].Value; got.Kind != want.Kind || got.Raw != want.Raw {
+			t.Errorf("argument %s = %d %q, want %d %q", echo.Arguments[i].Name, got.Kind, got.Raw, want.Kind, want.Raw)
+		}
+	}
+
+	fragment := doc.Fragments["NFCe"]
+	if fragment == nil || fragment.TypeCondition != "NFCe" || len(fragment.SelectionSet) != 2 {
+		t.Fatalf("fragment = %+v", fragment)
+	}
+}
+
+func TestParseShorthand(t *testing.T) {
+	doc, err := Parse(`{ a b: c }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	op := doc.Operations[0]
+	if op.Type != "query" || op.Name != "" || len(op.SelectionSet) != 2 {
+		t.Fatalf("operation = %+v", op)
+	}
+}
+
+func TestParseStrings(t *testing.T) {
+	tests := []struct {
+		literal string
+		want    string
+	}{
+		{literal: `"plain"`, want: "plain"},
+		{literal: `"tab\tquote\"slash\\/"`, want: "tab\tquote\"slash\\/"},
+		{literal: `"ção"`, want: "ção"},
+		{literal: `"ção"`, want: "ção"},
+		{literal: `"""  block "quoted" \n"""`, want: `  block "quoted" \n`},
+		{literal: "\"\"\"\n    first\n      indented\n    last\n\"\"\"", want: "first\n  indented\nlast"},
+		{literal: `"""escaped \""" quotes"""`, want: `escaped """ quotes`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			doc, err := Parse(`{ f(s: ` + tt.literal + `) }`)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := doc.Operations[0].SelectionSet[0].(*Field).Arguments[0].Value.Raw; got != tt.want {
+				t.Fatalf("string = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	tests := []struct {
+		literal string
+		kind    ValueKind
+	}{
+		{literal: "0", kind: KindInt},
+		{literal: "-0", kind: KindInt},
+		{literal: "10", kind: KindInt},
+		{literal: "0.5", kind: KindFloat},
+		{literal: "-10.25", kind: KindFloat},
+		{literal: "1e10", kind: KindFloat},
+		{literal: "1.5E-3", kind: KindFloat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			doc, err := Parse(`{ f(n: ` + tt.literal + `) }`)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			value := doc.Operations[0].SelectionSet[0].(*Field).Arguments[0].Value
+			if value.Kind != tt.kind || value.Raw != tt.literal {
+				t.Fatalf("value = %d %q, want %d %q", value.Kind, value.Raw, tt.kind, tt.literal)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		message string
+		loc     Location
+	}{
+		{name: "empty", query: "", message: "The document has no operation."},
+		{name: "only a fragment", query: "fragment F on Query { a }", message: "The document has no operation."},
+		{name: "unclosed selection", query: "{ a", message: "Syntax Error: unexpected end of document", loc: Location{Line: 1, Column: 4}},
+		{name: "empty selection", query: "{ }", message: `Syntax Error: unexpected "}"`, loc: Location{Line: 1, Column: 3}},
+		{name: "type definition", query: "type Query { a: Int }", message: `Syntax Error: unexpected "type"`, loc: Location{Line: 1, Column: 1}},
+		{name: "missing argument value", query: "{ a(x: ) }", message: `Syntax Error: unexpected ")"`, loc: Location{Line: 1, Column: 8}},
+		{name: "variable in a default", query: "query($a: Int = $b) { a }", message: `Syntax Error: unexpected "$"`, loc: Location{Line: 1, Column: 17}},
+		{name: "fragment named on", query: "fragment on on Query { a } { a }", message: `Syntax Error: unexpected "on"`, loc: Location{Line: 1, Column: 10}},
+		{name: "duplicated fragment", query: "{ ...F } fragment F on Query { a } fragment F on Query { b }", message: `There can be only one fragment named "F".`, loc: Location{Line: 1, Column: 36}},
+		{name: "unterminated string", query: "{ a(s: \"abc) }", message: "Syntax Error: unterminated string", loc: Location{Line: 1, Column: 8}},
+		{name: "newline in a string", query: "{ a(s: \"a\nb\") }", message: "Syntax Error: unterminated string", loc: Location{Line: 1, Column: 8}},
+		{name: "unterminated block string", query: `{ a(s: """abc) }`, message: "Syntax Error: unterminated string", loc: Location{Line: 1, Column: 8}},
+		{name: "invalid escape", query: `{ a(s: "\x") }`, message: `Syntax Error: invalid escape \x`, loc: Location{Line: 1, Column: 8}},
+		{name: "invalid unicode escape", query: `{ a(s: "\u12G4") }`, message: "Syntax Error: invalid unicode escape", loc: Location{Line: 1, Column: 8}},
+		{name: "leading zero", query: "{ a(n: 01) }", message: "Syntax Error: invalid number", loc: Location{Line: 1, Column: 8}},
+		{name: "negative leading zero", query: "{ a(n: -00.5) }", message: "Syntax Error: invalid number", loc: Location{Line: 1, Column: 8}},
+		{name: "number followed by a name", query: "{ a(n: 1x) }", message: "Syntax Error: invalid number", loc: Location{Line: 1, Column: 8}},
+		{name: "two dots", query: "{ ..F }", message: `Syntax Error: unexpected '.'`, loc: Location{Line: 1, Column: 3}},
+		{name: "unknown character", query: "{ a ? }", message: `Syntax Error: unexpected '?'`, loc: Location{Line: 1, Column: 5}},
+		{name: "location on a later line", query: "query {\n  a\n  b(\n}", message: `Syntax Error: unexpected "}"`, loc: Location{Line: 4, Column: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			var gqlErr *Error
+			if !errors.As(err, &gqlErr) {
+				t.Fatalf("Parse(%q) = %v, want an *Error", tt.query, err)
+			}
+			if gqlErr.Message != tt.message {
+				t.Fatalf("message = %q, want %q", gqlErr.Message, tt.message)
+			}
+			if tt.loc != (Location{}) && (len(gqlErr.Locations) != 1 || gqlErr.Locations[0] != tt.loc) {
+				t.Fatalf("locations = %+v, want %+v", gqlErr.Locations, tt.loc)
+			}
+		})
+	}
+}
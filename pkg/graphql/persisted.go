@@ -0,0 +1,101 @@
+package graphql
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// Error codes of the automatic persisted queries, the ones Apollo clients act upon
+const (
+	CodePersistedQueryNotFound     = "PERSISTED_QUERY_NOT_FOUND"
+	CodePersistedQueryNotSupported = "PERSISTED_QUERY_NOT_SUPPORTED"
+)
+
+// PersistedQueries keeps the queries registered by their SHA-256 hash, so clients send the
+// hash instead of the query text (automatic persisted queries). A client whose hash is not
+// known gets PERSISTED_QUERY_NOT_FOUND and sends the query again with the hash, which
+// registers it. The least recently used queries are evicted past the size.
+type PersistedQueries struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// persistedQuery is an entry of the cache
+type persistedQuery struct {
+	hash  string
+	query string
+}
+
+// NewPersistedQueries creates a cache of up to size queries; size 0 disables them
+func NewPersistedQueries(size int) *PersistedQueries {
+	return &PersistedQueries{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// Resolve returns the query of the request given its persistedQuery extension, with the
+// version and sha256Hash of the protocol. Without the extension the query is returned as is.
+func (c *PersistedQueries) Resolve(query string, extensions map[string]interface{}) (string, *Error) {
+	extension, ok := extensions["persistedQuery"].(map[string]interface{})
+	if !ok {
+		return query, nil
+	}
+	if c == nil || c.size <= 0 {
+		return "", persistedQueryError("PersistedQueryNotSupported", CodePersistedQueryNotSupported)
+	}
+	hash, _ := extension["sha256Hash"].(string)
+	hash = strings.ToLower(hash)
+	if len(hash) != sha256.Size*2 {
+		return "", &Error{Message: "persistedQuery.sha256Hash must be a SHA-256 hex digest"}
+	}
+
+	if query == "" {
+		stored, found := c.get(hash)
+		if !found {
+			return "", persistedQueryError("PersistedQueryNotFound", CodePersistedQueryNotFound)
+		}
+		return stored, nil
+	}
+
+	sum := sha256.Sum256([]byte(query))
+	if hex.EncodeToString(sum[:]) != hash {
+		return "", &Error{Message: "provided sha does not match query"}
+	}
+	c.put(hash, query)
+	return query, nil
+}
+
+func (c *PersistedQueries) get(hash string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.entries[hash]
+	if !found {
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*persistedQuery).query, true
+}
+
+func (c *PersistedQueries) put(hash, query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.entries[hash]; found {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[hash] = c.order.PushFront(&persistedQuery{hash: hash, query: query})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*persistedQuery).hash)
+	}
+}
+
+func persistedQueryError(message, code string) *Error {
+	return &Error{Message: message, Extensions: map[string]interface{}{"code": code}}
+}
@@ -0,0 +1,132 @@
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// persisted returns the persistedQuery extension of the query
+func persisted(query string) map[string]interface{} {
+	sum := sha256.Sum256([]byte(query))
+	return map[string]interface{}{
+		"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hex.EncodeToString(sum[:])},
+	}
+}
+
+func TestPersistedQueriesResolve(t *testing.T) {
+	const query = `{ hello }`
+	cache := NewPersistedQueries(2)
+
+	if got, err := cache.Resolve(query, nil); err != nil || got != query {
+		t.Fatalf("without the extension: %q, %v; want the query", got, err)
+	}
+
+	_, err := cache.Resolve("", persisted(query))
+	if err == nil || err.Extensions["code"] != CodePersistedQueryNotFound {
+		t.Fatalf("unknown hash: %v, want %s", err, CodePersistedQueryNotFound)
+	}
+
+	if got, err := cache.Resolve(query, persisted(query)); err != nil || got != query {
+		t.Fatalf("registration: %q, %v; want the query", got, err)
+	}
+	if got, err := cache.Resolve("", persisted(query)); err != nil || got != query {
+		t.Fatalf("known hash: %q, %v; want the query", got, err)
+	}
+
+	upper := persisted(query)
+	extension := upper["persistedQuery"].(map[string]interface{})
+	extension["sha256Hash"] = strings.ToUpper(extension["sha256Hash"].(string))
+	if got, err := cache.Resolve("", upper); err != nil || got != query {
+		t.Fatalf("uppercase hash: %q, %v; want the query", got, err)
+	}
+}
+
+func TestPersistedQueriesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		cache      *PersistedQueries
+		query      string
+		extensions map[string]interface{}
+		message    string
+		code       string
+	}{
+		{
+			name:       "disabled",
+			cache:      NewPersistedQueries(0),
+			extensions: persisted(`{ hello }`),
+			message:    "PersistedQueryNotSupported",
+			code:       CodePersistedQueryNotSupported,
+		},
+		{
+			name:       "nil cache",
+			extensions: persisted(`{ hello }`),
+			message:    "PersistedQueryNotSupported",
+			code:       CodePersistedQueryNotSupported,
+		},
+		{
+			name:       "hash of another query",
+			cache:      NewPersistedQueries(1),
+			query:      `{ secret }`,
+			extensions: persisted(`{ hello }`),
+			message:    "provided sha does not match query",
+		},
+		{
+			name:       "short hash",
+			cache:      NewPersistedQueries(1),
+			extensions: map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": "abc"}},
+			message:    "persistedQuery.sha256Hash must be a SHA-256 hex digest",
+		},
+		{
+			name:       "missing hash",
+			cache:      NewPersistedQueries(1),
+			extensions: map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1}},
+			message:    "persistedQuery.sha256Hash must be a SHA-256 hex digest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cache.Resolve(tt.query, tt.extensions)
+			if err == nil {
+				t.Fatalf("Resolve = %q, want an error", got)
+			}
+			if err.Message != tt.message {
+				t.Fatalf("message = %q, want %q", err.Message, tt.message)
+			}
+			if code, _ := err.Extensions["code"].(string); code != tt.code {
+				t.Fatalf("code = %q, want %q", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestPersistedQueriesEviction(t *testing.T) {
+	queries := []string{`{ a }`, `{ b }`, `{ c }`}
+	cache := NewPersistedQueries(2)
+	register := func(query string) {
+		t.Helper()
+		if _, err := cache.Resolve(query, persisted(query)); err != nil {
+			t.Fatalf("registration of %s: %v", query, err)
+		}
+	}
+	known := func(query string) bool {
+		_, err := cache.Resolve("", persisted(query))
+		return err == nil
+	}
+
+	register(queries[0])
+	register(queries[1])
+	// Reading a makes b the least recently used, evicted by c
+	if !known(queries[0]) {
+		t.Fatalf("%s evicted before the cache was full", queries[0])
+	}
+	register(queries[2])
+
+	for query, want := range map[string]bool{queries[0]: true, queries[1]: false, queries[2]: true} {
+		if got := known(query); got != want {
+			t.Errorf("%s known = %v, want %v", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Type is a GraphQL type: a *Scalar, an *Enum, an *Object, a *List or a *NonNull
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type. Parse coerces an input value, decoded from JSON (numbers as
+// json.Number) or from a literal, and Serialize the resolved value into a JSON value.
+type Scalar struct {
+	Name        string
+	Description string
+	Serialize   func(value interface{}) (interface{}, error)
+	Parse       func(value interface{}) (interface{}, error)
+}
+
+// Enum is a leaf type with a fixed set of values
+type Enum struct {
+	Name        string
+	Description string
+	Values      []*EnumValue
+}
+
+// EnumValue maps the name of an enum value to the Go value resolvers use
+type EnumValue struct {
+	Name        string
+	Description string
+	Value       interface{}
+}
+
+// Object is a type with fields, resolved from a source value
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*FieldDefinition
+
+	fields map[string]*FieldDefinition
+}
+
+// List is a list of values of the element type
+type List struct {
+	Of Type
+}
+
+// NonNull is a type whose value is never null
+type NonNull struct {
+	Of Type
+}
+
+func (s *Scalar) String() string  { return s.Name }
+func (e *Enum) String() string    { return e.Name }
+func (o *Object) String() string  { return o.Name }
+func (l *List) String() string    { return "[" + l.Of.String() + "]" }
+func (n *NonNull) String() string { return n.Of.String() + "!" }
+
+// FieldDefinition is a field of an object type
+type FieldDefinition struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*ArgumentDefinition
+	// Resolve returns the value of the field, or a Thunk to defer a batched load. A nil
+	// Resolve reads the field from a map[string]interface{} source.
+	Resolve func(p ResolveParams) (interface{}, error)
+	// Authorize, when set, runs before Resolve; an error makes the field null
+	Authorize func(ctx context.Context, source interface{}) error
+}
+
+// ArgumentDefinition is an argument of a field
+type ArgumentDefinition struct {
+	Name        string
+	Description string
+	Type        Type
+	Default     interface{} // Coerced value used when the argument is not given
+}
+
+// ResolveParams are the inputs of a resolver
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}            // Value resolved for the parent object
+	Args    map[string]interface{} // Coerced arguments, with their defaults
+	Path    []interface{}
+}
+
+// Thunk is a deferred resolved value. The executor resolves every field of a level before
+// calling the thunks, so the loads they stand for can be batched.
+type Thunk func() (interface{}, error)
+
+// Field returns the field of the object with the name, nil if there is none
+func (o *Object) Field(name string) *FieldDefinition {
+	if o.fields == nil {
+		for _, field := range o.Fields {
+			if field.Name == name {
+				return field
+			}
+		}
+		return nil
+	}
+	return o.fields[name]
+}
+
+// Schema is the type system of a read-only GraphQL API, rooted at the query type
+type Schema struct {
+	Query *Object
+	types map[string]Type
+}
+
+// NewSchema checks the types reachable from the query type and indexes their fields
+func NewSchema(query *Object) (*Schema, error) {
+	s := &Schema{Query: query, types: map[string]Type{}}
+	for _, scalar := range []*Scalar{Int, Float, String, Boolean, ID} {
+		s.types[scalar.Name] = scalar
+	}
+	if err := s.collect(query); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// collect registers the named type and the types it references
+func (s *Schema) collect(t Type) error {
+	switch t := t.(type) {
+	case *List:
+		return s.collect(t.Of)
+	case *NonNull:
+		if _, ok := t.Of.(*NonNull); ok {
+			return fmt.Errorf("graphql: %s wraps a non-null type", t)
+		}
+		return s.collect(t.Of)
+	}
+
+	name := t.String()
+	if existing, ok := s.types[name]; ok {
+		if existing != t {
+			return fmt.Errorf("graphql: two types named %s", name)
+		}
+		return nil
+	}
+	s.types[name] = t
+
+	object, ok := t.(*Object)
+	if !ok {
+		return nil
+	}
+	object.fields = make(map[string]*FieldDefinition, len(object.Fields))
+	for _, field := range object.Fields {
+		if _, exists := object.fields[field.Name]; exists {
+			return fmt.Errorf("graphql: field %s.%s defined twice", object.Name, field.Name)
+		}
+		object.fields[field.Name] = field
+		if err := s.collect(field.Type); err != nil {
+			return err
+		}
+		for _, arg := range field.Args {
+			if !isInputType(arg.Type) {
+				return fmt.Errorf("graphql: argument %s of %s.%s is not an input type", arg.Name, object.Name, field.Name)
+			}
+			if err := s.collect(arg.Type); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Type returns the named type of the schema, nil if there is none
+func (s *Schema) Type(name string) Type {
+	return s.types[name]
+}
+
+// typeNames returns the names of the types of the schema in order
+func (s *Schema) typeNames() []string {
+	names := make([]string, 0, len(s.types))
+	for name := range s.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namedType returns the type unwrapped from its lists and non-null
+func namedType(t Type) Type {
+	for {
+		switch wrapped := t.(type) {
+		case *List:
+			t = wrapped.Of
+		case *NonNull:
+			t = wrapped.Of
+		default:
+			return t
+		}
+	}
+}
+
+// isInputType reports whether the type may be used by arguments and variables
+func isInputType(t Type) bool {
+	switch namedType(t).(type) {
+	case *Scalar, *Enum:
+		return true
+	}
+	return false
+}
+
+// Built-in scalars
+var (
+	Int = &Scalar{
+		Name:        "Int",
+		Description: "A signed 32-bit integer.",
+		Serialize: func(value interface{}) (interface{}, error) {
+			n, ok := toInt64(value)
+			if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent %v", value)
+			}
+			return n, nil
+		},
+		Parse: func(value interface{}) (interface{}, error) {
+			n, ok := toInt64(value)
+			if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent %v", value)
+			}
+			return int(n), nil
+		},
+	}
+	Float = &Scalar{
+		Name:        "Float",
+		Description: "A double-precision floating point number.",
+		Serialize: func(value interface{}) (interface{}, error) {
+			f, ok := toFloat64(value)
+			if !ok {
+				return nil, fmt.Errorf("Float cannot represent %v", value)
+			}
+			return f, nil
+		},
+		Parse: func(value interface{}) (interface{}, error) {
+			f, ok := toFloat64(value)
+			if !ok {
+				return nil, fmt.Errorf("Float cannot represent %v", value)
+			}
+			return f, nil
+		},
+	}
+	String = &Scalar{
+		Name:        "String",
+		Description: "A UTF-8 string.",
+		Serialize:   serializeString,
+		Parse: func(value interface{}) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("String cannot represent %v", value)
+			}
+			return s, nil
+		},
+	}
+	Boolean = &Scalar{
+		Name:        "Boolean",
+		Description: "true or false.",
+		Serialize: func(value interface{}) (interface{}, error) {
+			b, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("Boolean cannot represent %v", value)
+			}
+			return b, nil
+		},
+		Parse: func(value interface{}) (interface{}, error) {
+			b, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("Boolean cannot represent %v", value)
+			}
+			return b, nil
+		},
+	}
+	ID = &Scalar{
+		Name:        "ID",
+		Description: "A unique identifier, serialized as a string.",
+		Serialize:   serializeString,
+		Parse: func(value interface{}) (interface{}, error) {
+			switch v := value.(type) {
+			case string:
+				return v, nil
+			case json.Number:
+				if _, err := v.Int64(); err == nil {
+					return v.String(), nil
+				}
+			}
+			return nil, fmt.Errorf("ID cannot represent %v", value)
+		},
+	}
+)
+
+func serializeString(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	return nil, fmt.Errorf("String cannot represent %v", value)
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case float64:
+		if v == math.Trunc(v) {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return f, err == nil
+	}
+	if n, ok := toInt64(value); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,91 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SDL prints the schema in the GraphQL schema definition language, the types in name order
+// and the built-in scalars left out
+func (s *Schema) SDL() string {
+	var sb strings.Builder
+	for _, name := range s.typeNames() {
+		switch t := s.types[name].(type) {
+		case *Scalar:
+			if t == Int || t == Float || t == String || t == Boolean || t == ID {
+				continue
+			}
+			writeDescription(&sb, t.Description, "")
+			fmt.Fprintf(&sb, "scalar %s\n\n", t.Name)
+		case *Enum:
+			writeDescription(&sb, t.Description, "")
+			fmt.Fprintf(&sb, "enum %s {\n", t.Name)
+			for _, value := range t.Values {
+				writeDescription(&sb, value.Description, "  ")
+				fmt.Fprintf(&sb, "  %s\n", value.Name)
+			}
+			sb.WriteString("}\n\n")
+		case *Object:
+			writeDescription(&sb, t.Description, "")
+			fmt.Fprintf(&sb, "type %s {\n", t.Name)
+			for _, field := range t.Fields {
+				writeDescription(&sb, field.Description, "  ")
+				fmt.Fprintf(&sb, "  %s%s: %s\n", field.Name, printArgs(field.Args), field.Type)
+			}
+			sb.WriteString("}\n\n")
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// printArgs prints the argument definitions of a field, empty when it has none
+func printArgs(args []*ArgumentDefinition) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = fmt.Sprintf("%s: %s", arg.Name, arg.Type)
+		if arg.Default != nil {
+			parts[i] += " = " + printValue(arg.Type, arg.Default)
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// printValue prints a coerced input value as a literal of the type
+func printValue(t Type, value interface{}) string {
+	switch t := namedType(t).(type) {
+	case *Enum:
+		if name, ok := t.nameOf(value); ok {
+			return name
+		}
+	case *Scalar:
+		if serialized, err := t.Serialize(value); err == nil {
+			value = serialized
+		}
+	}
+	literal, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(literal)
+}
+
+// writeDescription writes the description as a string literal before a definition
+func writeDescription(sb *strings.Builder, description, indent string) {
+	if description == "" {
+		return
+	}
+	if strings.Contains(description, "\n") {
+		sb.WriteString(indent + `"""` + "\n")
+		for _, line := range strings.Split(description, "\n") {
+			sb.WriteString(indent + strings.ReplaceAll(line, `"""`, `\"""`) + "\n")
+		}
+		sb.WriteString(indent + `"""` + "\n")
+		return
+	}
+	literal, _ := json.Marshal(description)
+	sb.WriteString(indent + string(literal) + "\n")
+}
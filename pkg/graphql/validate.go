@@ -0,0 +1,147 @@
+package graphql
+
+import "fmt"
+
+// conditionArgs are the arguments of the @skip and @include directives
+var conditionArgs = []*ArgumentDefinition{{Name: "if", Type: &NonNull{Of: Boolean}}}
+
+// validator checks the operation against the schema before it runs
+type validator struct {
+	e         *executor
+	maxDepth  int
+	errors    []*Error
+	tooDeep   bool
+	used      map[string]bool
+	validated map[fragmentDepth]bool
+}
+
+// fragmentDepth is a fragment spread at a depth; its fields validate the same way at it
+type fragmentDepth struct {
+	name  string
+	depth int
+}
+
+// validate checks the fields, arguments, fragments and depth of the operation and coerces
+// the arguments of its fields
+func (e *executor) validate(op *Operation, maxDepth int) []*Error {
+	v := &validator{e: e, maxDepth: maxDepth, used: map[string]bool{}, validated: map[fragmentDepth]bool{}}
+	for _, directive := range op.Directives {
+		v.errors = append(v.errors, newError(directive.Loc, "Directive \"@%s\" may not be used on %s.", directive.Name, op.Type))
+	}
+	v.selectionSet(e.schema.Query, op.SelectionSet, 1, nil)
+
+	for name, fragment := range e.fragments {
+		if !v.used[name] {
+			v.errors = append(v.errors, newError(fragment.Loc, "Fragment \"%s\" is never used.", name))
+		}
+	}
+	return v.errors
+}
+
+func (v *validator) selectionSet(typ *Object, selections []Selection, depth int, spreads []string) {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *Field:
+			v.directives(s.Directives)
+			v.field(typ, s, depth, spreads)
+		case *InlineFragment:
+			v.directives(s.Directives)
+			if s.TypeCondition != "" && !v.typeCondition(typ, s.TypeCondition, s.Loc) {
+				continue
+			}
+			v.selectionSet(typ, s.SelectionSet, depth, spreads)
+		case *FragmentSpread:
+			v.directives(s.Directives)
+			v.fragmentSpread(typ, s, depth, spreads)
+		}
+	}
+}
+
+func (v *validator) field(typ *Object, f *Field, depth int, spreads []string) {
+	if v.maxDepth > 0 && depth > v.maxDepth {
+		if !v.tooDeep {
+			v.tooDeep = true
+			v.errors = append(v.errors, newError(f.Loc, "Query exceeds the maximum depth of %d.", v.maxDepth))
+		}
+		return
+	}
+
+	def := typ.Field(f.Name)
+	if f.Name == typenameField.Name {
+		def = typenameField
+	}
+	if def == nil {
+		v.errors = append(v.errors, newError(f.Loc, "Cannot query field \"%s\" on type \"%s\".", f.Name, typ.Name))
+		return
+	}
+
+	args, err := coerceArguments(def.Args, f.Arguments, v.e.scope)
+	if err != nil {
+		v.errors = append(v.errors, newError(f.Loc, "%s", err.Error()))
+	} else {
+		v.e.args[f] = args
+	}
+
+	object, composite := namedType(def.Type).(*Object)
+	switch {
+	case composite && len(f.SelectionSet) == 0:
+		v.errors = append(v.errors, newError(f.Loc, "Field \"%s\" of type \"%s\" must have a selection of subfields.", f.Name, def.Type))
+	case !composite && len(f.SelectionSet) > 0:
+		v.errors = append(v.errors, newError(f.Loc, "Field \"%s\" must not have a selection since type \"%s\" has no subfields.", f.Name, def.Type))
+	case composite:
+		v.selectionSet(object, f.SelectionSet, depth+1, spreads)
+	}
+}
+
+func (v *validator) fragmentSpread(typ *Object, s *FragmentSpread, depth int, spreads []string) {
+	fragment := v.e.fragments[s.Name]
+	if fragment == nil {
+		v.errors = append(v.errors, newError(s.Loc, "Unknown fragment \"%s\".", s.Name))
+		return
+	}
+	v.used[s.Name] = true
+	for _, spread := range spreads {
+		if spread == s.Name {
+			v.errors = append(v.errors, newError(s.Loc, "Cannot spread fragment \"%s\" within itself.", s.Name))
+			return
+		}
+	}
+	if !v.typeCondition(typ, fragment.TypeCondition, s.Loc) {
+		return
+	}
+
+	key := fragmentDepth{name: s.Name, depth: depth}
+	if v.validated[key] {
+		return
+	}
+	v.validated[key] = true
+	v.directives(fragment.Directives)
+	v.selectionSet(typ, fragment.SelectionSet, depth, append(spreads[:len(spreads):len(spreads)], s.Name))
+}
+
+// typeCondition checks that a fragment on the condition may apply to the type
+func (v *validator) typeCondition(typ *Object, condition string, loc Location) bool {
+	conditionType, ok := v.e.schema.types[condition].(*Object)
+	if !ok {
+		v.errors = append(v.errors, newError(loc, "Unknown type \"%s\".", condition))
+		return false
+	}
+	if conditionType != typ {
+		v.errors = append(v.errors, newError(loc, "Fragment cannot be spread here as objects of type \"%s\" can never be of type \"%s\".", typ.Name, condition))
+		return false
+	}
+	return true
+}
+
+// directives checks that only @skip and @include are used, with a valid condition
+func (v *validator) directives(directives []*Directive) {
+	for _, directive := range directives {
+		if directive.Name != "skip" && directive.Name != "include" {
+			v.errors = append(v.errors, newError(directive.Loc, "Unknown directive \"@%s\".", directive.Name))
+			continue
+		}
+		if _, err := coerceArguments(conditionArgs, directive.Arguments, v.e.scope); err != nil {
+			v.errors = append(v.errors, newError(directive.Loc, "%s", fmt.Sprintf("Directive \"@%s\": %s", directive.Name, err.Error())))
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// typeFromRef returns the schema type named by the document, which must be an input type
+func (s *Schema) typeFromRef(ref *TypeRef) (Type, error) {
+	var t Type
+	if ref.Elem != nil {
+		elem, err := s.typeFromRef(ref.Elem)
+		if err != nil {
+			return nil, err
+		}
+		t = &List{Of: elem}
+	} else {
+		t = s.types[ref.Name]
+		if t == nil {
+			return nil, fmt.Errorf("Unknown type %q.", ref.Name)
+		}
+	}
+	if !isInputType(t) {
+		return nil, fmt.Errorf("%s is not an input type.", ref)
+	}
+	if ref.NonNull {
+		t = &NonNull{Of: t}
+	}
+	return t, nil
+}
+
+// coerceValue coerces a value decoded from the JSON variables to the input type
+func coerceValue(t Type, value interface{}) (interface{}, error) {
+	if nonNull, ok := t.(*NonNull); ok {
+		if value == nil {
+			return nil, fmt.Errorf("expected a non-null %s", nonNull.Of)
+		}
+		return coerceValue(nonNull.Of, value)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items, ok := value.([]interface{})
+		if !ok {
+			item, err := coerceValue(t.Of, value)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		coerced := make([]interface{}, len(items))
+		for i, item := range items {
+			var err error
+			if coerced[i], err = coerceValue(t.Of, item); err != nil {
+				return nil, fmt.Errorf("at index %d: %w", i, err)
+			}
+		}
+		return coerced, nil
+	case *Scalar:
+		return t.Parse(value)
+	case *Enum:
+		if name, ok := value.(string); ok {
+			if enumValue := t.value(name); enumValue != nil {
+				return enumValue.Value, nil
+			}
+		}
+		return nil, fmt.Errorf("%s does not have a value %v", t.Name, value)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// value returns the enum value with the name, nil if there is none
+func (e *Enum) value(name string) *EnumValue {
+	for _, v := range e.Values {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// nameOf returns the name of the enum value equal to value
+func (e *Enum) nameOf(value interface{}) (string, bool) {
+	for _, v := range e.Values {
+		if v.Value == value {
+			return v.Name, true
+		}
+	}
+	return "", false
+}
+
+// variableScope holds the coerced variables of the operation and their declared types
+type variableScope struct {
+	values   map[string]interface{}
+	types    map[string]Type
+	defaults map[string]bool
+}
+
+// coerceVariables coerces the JSON variables of the request to the types the operation declares
+func (s *Schema) coerceVariables(op *Operation, inputs map[string]interface{}) (*variableScope, []*Error) {
+	scope := &variableScope{values: map[string]interface{}{}, types: map[string]Type{}, defaults: map[string]bool{}}
+
+	var errs []*Error
+	for _, def := range op.Variables {
+		if _, duplicated := scope.types[def.Name]; duplicated {
+			errs = append(errs, newError(def.Loc, "There can be only one variable named \"$%s\".", def.Name))
+			continue
+		}
+		t, err := s.typeFromRef(def.Type)
+		if err != nil {
+			errs = append(errs, newError(def.Loc, "Variable \"$%s\": %s", def.Name, err.Error()))
+			continue
+		}
+		scope.types[def.Name] = t
+		scope.defaults[def.Name] = def.Default != nil
+
+		value, provided := inputs[def.Name]
+		switch {
+		case !provided && def.Default != nil:
+			coerced, err := valueFromAST(t, def.Default, nil)
+			if err != nil {
+				errs = append(errs, newError(def.Loc, "Variable \"$%s\" has an invalid default value: %s.", def.Name, err.Error()))
+				continue
+			}
+			scope.values[def.Name] = coerced
+		case !provided:
+			if _, ok := t.(*NonNull); ok {
+				errs = append(errs, newError(def.Loc, "Variable \"$%s\" of required type \"%s\" was not provided.", def.Name, t))
+			}
+		default:
+			coerced, err := coerceValue(t, value)
+			if err != nil {
+				errs = append(errs, newError(def.Loc, "Variable \"$%s\" got invalid value: %s.", def.Name, err.Error()))
+				continue
+			}
+			scope.values[def.Name] = coerced
+		}
+	}
+	return scope, errs
+}
+
+// valueFromAST coerces a literal of the document to the input type; variables are read from
+// the scope, nil for a constant value
+func valueFromAST(t Type, v *Value, scope *variableScope) (interface{}, error) {
+	if v.Kind == KindVariable {
+		if scope == nil {
+			return nil, fmt.Errorf("variables are not allowed here")
+		}
+		varType, defined := scope.types[v.Raw]
+		if !defined {
+			return nil, fmt.Errorf("variable \"$%s\" is not defined", v.Raw)
+		}
+		if !variableFits(varType, scope.defaults[v.Raw], t) {
+			return nil, fmt.Errorf("variable \"$%s\" of type \"%s\" used in position expecting type \"%s\"", v.Raw, varType, t)
+		}
+		value := scope.values[v.Raw]
+		if _, ok := t.(*NonNull); ok && value == nil {
+			return nil, fmt.Errorf("variable \"$%s\" must not be null", v.Raw)
+		}
+		return value, nil
+	}
+
+	if nonNull, ok := t.(*NonNull); ok {
+		if v.Kind == KindNull {
+			return nil, fmt.Errorf("expected a non-null %s", nonNull.Of)
+		}
+		return valueFromAST(nonNull.Of, v, scope)
+	}
+	if v.Kind == KindNull {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		if v.Kind != KindList {
+			item, err := valueFromAST(t.Of, v, scope)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		items := make([]interface{}, len(v.List))
+		for i, item := range v.List {
+			var err error
+			if items[i], err = valueFromAST(t.Of, item, scope); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case *Scalar:
+		var literal interface{}
+		switch v.Kind {
+		case KindInt, KindFloat:
+			literal = json.Number(v.Raw)
+		case KindString:
+			literal = v.Raw
+		case KindBoolean:
+			literal = v.Raw == "true"
+		default:
+			return nil, fmt.Errorf("%s cannot represent a non %s value", t.Name, t.Name)
+		}
+		return t.Parse(literal)
+	case *Enum:
+		if v.Kind == KindEnum {
+			if enumValue := t.value(v.Raw); enumValue != nil {
+				return enumValue.Value, nil
+			}
+		}
+		return nil, fmt.Errorf("%s does not have a value %s", t.Name, v.Raw)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// variableFits reports whether a variable of the type may be used where the position type is
+// expected: a nullable variable fits a non-null position only when it has a default
+func variableFits(varType Type, hasDefault bool, position Type) bool {
+	if nonNull, ok := position.(*NonNull); ok {
+		varNonNull, ok := varType.(*NonNull)
+		if !ok {
+			return hasDefault && variableFits(varType, false, nonNull.Of)
+		}
+		return variableFits(varNonNull.Of, false, nonNull.Of)
+	}
+	if varNonNull, ok := varType.(*NonNull); ok {
+		return variableFits(varNonNull.Of, false, position)
+	}
+	if list, ok := position.(*List); ok {
+		varList, ok := varType.(*List)
+		return ok && variableFits(varList.Of, false, list.Of)
+	}
+	if _, ok := varType.(*List); ok {
+		return false
+	}
+	return varType == position
+}
+
+// coerceArguments coerces the arguments of a field to their definitions, applying the defaults
+func coerceArguments(defs []*ArgumentDefinition, args []*Argument, scope *variableScope) (map[string]interface{}, error) {
+	given := make(map[string]*Argument, len(args))
+	for _, arg := range args {
+		if _, duplicated := given[arg.Name]; duplicated {
+			return nil, fmt.Errorf("There can be only one argument named \"%s\".", arg.Name)
+		}
+		given[arg.Name] = arg
+	}
+
+	coerced := make(map[string]interface{}, len(defs))
+	for _, def := range defs {
+		arg, ok := given[def.Name]
+		delete(given, def.Name)
+		if ok && arg.Value.Kind == KindVariable && scope != nil {
+			varType := scope.types[arg.Value.Raw]
+			if _, set := scope.values[arg.Value.Raw]; !set && varType != nil && variableFits(varType, scope.defaults[arg.Value.Raw], def.Type) {
+				ok = false // A variable that was not provided leaves the argument out
+			}
+		}
+
+		if !ok {
+			switch {
+			case def.Default != nil:
+				coerced[def.Name] = def.Default
+			case isNonNull(def.Type):
+				return nil, fmt.Errorf("Argument \"%s\" of type \"%s\" is required, but it was not provided.", def.Name, def.Type)
+			}
+			continue
+		}
+
+		value, err := valueFromAST(def.Type, arg.Value, scope)
+		if err != nil {
+			return nil, fmt.Errorf("Argument \"%s\" has an invalid value: %s.", def.Name, err.Error())
+		}
+		coerced[def.Name] = value
+	}
+
+	for name := range given {
+		return nil, fmt.Errorf("Unknown argument \"%s\".", name)
+	}
+	return coerced, nil
+}
+
+func isNonNull(t Type) bool {
+	_, ok := t.(*NonNull)
+	return ok
+}
+
+// isNil reports whether the resolved value is nil, including a typed nil pointer
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,702 @@
+// Package i18n holds the error message catalog shared by the HTTP API and the
+// webhook payloads, with pt-BR and en translations keyed by a stable error code.
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lang is a supported message language
+type Lang string
+
+const (
+	PortugueseBR Lang = "pt-BR"
+	English      Lang = "en"
+
+	// DefaultLang is used when Accept-Language is missing or unsupported
+	DefaultLang = PortugueseBR
+)
+
+// Code identifies an error independently of the language it is shown in
+type Code string
+
+const (
+	CodeInvalidRequest         Code = "invalid_request"
+	CodeUnauthorized           Code = "unauthorized"
+	CodeForbidden              Code = "forbidden"
+	CodeNotFound               Code = "not_found"
+	CodeConflict               Code = "conflict"
+	CodeGone                   Code = "gone"
+	CodeUnprocessable          Code = "unprocessable_entity"
+	CodeServiceUnavailable     Code = "service_unavailable"
+	CodeInternal               Code = "internal_error"
+	CodeNotImplemented         Code = "not_implemented"
+	CodeIdempotencyKeyRequired Code = "idempotency_key_required"
+	CodeIdempotencyKeyTooLong  Code = "idempotency_key_too_long"
+	CodeIdempotencyKeyReused   Code = "idempotency_key_reused"
+	CodeIdempotencyInProgress  Code = "idempotency_key_in_progress"
+	CodeRequestTooLarge        Code = "request_too_large"
+	CodeInvalidLimit           Code = "invalid_limit"
+	CodeInvalidOffset          Code = "invalid_offset"
+	CodeInvalidVias            Code = "invalid_vias"
+	CodeInvalidCompacto        Code = "invalid_compacto"
+	CodeInvalidPapel           Code = "invalid_papel"
+	CodeInvalidDANFEFormat     Code = "invalid_danfe_format"
+	CodeInvalidPartNumber      Code = "invalid_part_number"
+	CodeInvalidDateRange       Code = "invalid_date_range"
+	CodeInvalidCursor          Code = "invalid_cursor"
+	CodeInvalidStatsRange      Code = "invalid_stats_range"
+	CodeInvalidSort            Code = "invalid_sort"
+	CodeInvalidValorRange      Code = "invalid_valor_range"
+	CodeInvalidContingency     Code = "invalid_contingency"
+	CodeInvalidDestinatario    Code = "invalid_destinatario"
+	CodeInvalidExportFormat    Code = "invalid_export_format"
+	CodeInvalidExportIndex     Code = "invalid_export_index"
+	CodeInvalidSearchQuery     Code = "invalid_search_query"
+	CodeInvalidGraphQLRequest  Code = "invalid_graphql_request"
+	CodeCompanyIDRequired      Code = "company_id_required"
+	CodeCompanyNotFound        Code = "company_not_found"
+	CodeCompanyAlreadyExists   Code = "company_already_exists"
+	CodeCompanyDeleted         Code = "company_deleted"
+	CodeInvalidCompany         Code = "invalid_company"
+	CodePlanIDRequired         Code = "plan_id_required"
+	CodeSubscriptionIDRequired Code = "subscription_id_required"
+	CodeWebhookIDRequired      Code = "webhook_id_required"
+	CodeNFCeNotFound           Code = "nfce_not_found"
+	CodeNFCeGetFailed          Code = "nfce_get_failed"
+	CodeNFCeListFailed         Code = "nfce_list_failed"
+	CodeNFCeSearchFailed       Code = "nfce_search_failed"
+	CodeNFCeEventsFailed       Code = "nfce_events_failed"
+	CodeNFCeAlreadyRejected    Code = "nfce_already_rejected"
+	CodeNFCeNotAuthorized      Code = "nfce_not_authorized"
+	CodeNFCeNotCancelable      Code = "nfce_not_cancelable"
+	CodeLoteNotFound           Code = "lote_not_found"
+	CodeLoteGetFailed          Code = "lote_get_failed"
+	CodeXMLNotFound            Code = "xml_not_found"
+	CodePDFNotFound            Code = "pdf_not_found"
+	CodeQRCodeNotFound         Code = "qrcode_not_found"
+	CodeDocumentsPurged        Code = "documents_purged"
+	CodeEventStreamUnavailable Code = "event_stream_unavailable"
+	CodeFeatureNotInPlan       Code = "feature_not_in_plan"
+	CodeQuotaExhausted         Code = "quota_exhausted"
+	CodeSubscriptionInactive   Code = "subscription_inactive"
+	CodeBrokerUnavailable      Code = "broker_unavailable"
+	CodeInutilizacaoNotFound   Code = "inutilizacao_not_found"
+	CodeInutilizacaoOverlap    Code = "inutilizacao_overlap"
+	CodeExportNotFound         Code = "export_not_found"
+	CodeExportExpired          Code = "export_expired"
+	CodeExportPartNotFound     Code = "export_part_not_found"
+	CodeCertificateExpired     Code = "certificate_expired"
+	CodeCertificatePassword    Code = "certificate_password_incorrect"
+	CodeCertificateUnsupported Code = "certificate_unsupported"
+	CodeCertificateInvalid     Code = "certificate_invalid"
+	CodeCertificateCNPJ        Code = "certificate_cnpj_mismatch"
+	CodeCSCExpired             Code = "csc_expired"
+	CodeCSCNotFound            Code = "csc_not_found"
+	CodeEmissionBlocked        Code = "emission_blocked"
+	CodeFraudVelocity          Code = "fraud_velocity"
+	CodeFraudTicketValue       Code = "fraud_ticket_value"
+	CodeFraudDuplicate         Code = "fraud_duplicate"
+	CodeDFeDocumentNotFound    Code = "dfe_document_not_found"
+	CodeDFeSyncTooSoon         Code = "dfe_sync_too_soon"
+	CodeInvalidFiscalCodes     Code = "invalid_fiscal_codes"
+	CodeUnderMaintenance       Code = "under_maintenance"
+	CodeMaintenanceActive      Code = "maintenance_active"
+	CodeMaintenanceNotActive   Code = "maintenance_not_active"
+	CodeInvalidCredentials     Code = "invalid_credentials"
+	CodeInvalidAPIKey          Code = "invalid_api_key"
+	CodeInvalidToken           Code = "invalid_token"
+	CodeCompanyInactive        Code = "company_inactive"
+	CodeAPIKeyNotFound         Code = "api_key_not_found"
+	CodeAPIKeyRevoked          Code = "api_key_revoked"
+	CodeEmailDisabled          Code = "email_disabled"
+	CodeEmailRecipientMissing  Code = "email_recipient_missing"
+	CodeEmailSendFailed        Code = "email_send_failed"
+	CodeInvalidSchemaSource    Code = "invalid_schema_source"
+	CodeSchemaNotBundled       Code = "schema_not_bundled"
+	CodeSchemaUpdateInProgress Code = "schema_update_in_progress"
+	CodeInvalidSEFAZTables     Code = "invalid_sefaz_tables"
+	CodePaymentGatewayNotFound Code = "payment_gateway_not_found"
+	CodePaymentSignature       Code = "invalid_payment_signature"
+	CodePaymentWebhookRead     Code = "payment_webhook_unreadable"
+	CodeSubscriptionNotFound   Code = "subscription_not_found"
+	CodePlanNotFound           Code = "plan_not_found"
+	CodeInvalidPlanChange      Code = "invalid_plan_change"
+	CodeRateLimitExceeded      Code = "rate_limit_exceeded"
+	CodeSEFAZExchangesNotFound Code = "sefaz_exchanges_not_found"
+	CodeNFCeAlreadyAuthorized  Code = "nfce_already_authorized"
+	CodeNFCeNotReprocessable   Code = "nfce_not_reprocessable"
+	CodeESCPOSNotAvailable     Code = "escpos_not_available"
+	CodeSeriesNotFound         Code = "series_not_found"
+	CodeSeriesAlreadyExists    Code = "series_already_exists"
+	CodeSeriesInactive         Code = "series_inactive"
+	CodeStatementNotFound      Code = "statement_not_found"
+	CodeInvalidWebhookFilter   Code = "invalid_webhook_filter"
+	CodeInvalidChaveAcesso     Code = "invalid_chave_acesso"
+	CodeWebhookNotFound        Code = "webhook_not_found"
+)
+
+// entry is the message of a code in every supported language
+type entry struct {
+	Messages map[Lang]string
+}
+
+var catalog = map[Code]entry{
+	CodeInvalidRequest: {Messages: map[Lang]string{
+		PortugueseBR: "Requisição inválida",
+		English:      "Invalid request",
+	}},
+	CodeUnauthorized: {Messages: map[Lang]string{
+		PortugueseBR: "Não autorizado",
+		English:      "Unauthorized",
+	}},
+	CodeForbidden: {Messages: map[Lang]string{
+		PortugueseBR: "Acesso negado",
+		English:      "Forbidden",
+	}},
+	CodeNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Recurso não encontrado",
+		English:      "Resource not found",
+	}},
+	CodeConflict: {Messages: map[Lang]string{
+		PortugueseBR: "Conflito com o estado atual do recurso",
+		English:      "Conflict with the current state of the resource",
+	}},
+	CodeGone: {Messages: map[Lang]string{
+		PortugueseBR: "Recurso não está mais disponível",
+		English:      "Resource is no longer available",
+	}},
+	CodeUnprocessable: {Messages: map[Lang]string{
+		PortugueseBR: "Não foi possível processar a requisição",
+		English:      "Unprocessable request",
+	}},
+	CodeServiceUnavailable: {Messages: map[Lang]string{
+		PortugueseBR: "Serviço temporariamente indisponível",
+		English:      "Service temporarily unavailable",
+	}},
+	CodeInternal: {Messages: map[Lang]string{
+		PortugueseBR: "Erro interno",
+		English:      "Internal error",
+	}},
+	CodeNotImplemented: {Messages: map[Lang]string{
+		PortugueseBR: "Funcionalidade ainda não implementada",
+		English:      "Not implemented",
+	}},
+	CodeIdempotencyKeyRequired: {Messages: map[Lang]string{
+		PortugueseBR: "O cabeçalho Idempotency-Key é obrigatório",
+		English:      "Idempotency-Key header is required",
+	}},
+	CodeIdempotencyKeyTooLong: {Messages: map[Lang]string{
+		PortugueseBR: "O cabeçalho Idempotency-Key é longo demais",
+		English:      "Idempotency-Key header is too long",
+	}},
+	CodeIdempotencyKeyReused: {Messages: map[Lang]string{
+		PortugueseBR: "Idempotency-Key já utilizada com outro conteúdo",
+		English:      "Idempotency-Key was already used with a different payload",
+	}},
+	CodeIdempotencyInProgress: {Messages: map[Lang]string{
+		PortugueseBR: "Uma requisição com esta Idempotency-Key ainda está em processamento",
+		English:      "a request with this Idempotency-Key is still in progress",
+	}},
+	CodeRequestTooLarge: {Messages: map[Lang]string{
+		PortugueseBR: "O corpo da requisição excede %d bytes",
+		English:      "request body exceeds %d bytes",
+	}},
+	CodeInvalidLimit: {Messages: map[Lang]string{
+		PortugueseBR: "Parâmetro limit fora do intervalo permitido",
+		English:      "limit is out of the allowed range",
+	}},
+	CodeInvalidOffset: {Messages: map[Lang]string{
+		PortugueseBR: "offset deve ser maior ou igual a 0",
+		English:      "offset must be >= 0",
+	}},
+	CodeInvalidVias: {Messages: map[Lang]string{
+		PortugueseBR: "vias deve ser 1 ou 2",
+		English:      "vias must be 1 or 2",
+	}},
+	CodeInvalidCompacto: {Messages: map[Lang]string{
+		PortugueseBR: "compacto deve ser um booleano",
+		English:      "compacto must be a boolean",
+	}},
+	CodeInvalidPapel: {Messages: map[Lang]string{
+		PortugueseBR: "papel deve ser 80mm ou 58mm",
+		English:      "papel must be 80mm or 58mm",
+	}},
+	CodeInvalidDANFEFormat: {Messages: map[Lang]string{
+		PortugueseBR: "format deve ser pdf ou escpos",
+		English:      "format must be pdf or escpos",
+	}},
+	CodeInvalidPartNumber: {Messages: map[Lang]string{
+		PortugueseBR: "Número da parte inválido",
+		English:      "invalid part number",
+	}},
+	CodeInvalidDateRange: {Messages: map[Lang]string{
+		PortugueseBR: "from e to devem ser datas RFC 3339, ex.: 2026-01-31T00:00:00Z",
+		English:      "from and to must be RFC 3339 date-times, e.g. 2026-01-31T00:00:00Z",
+	}},
+	CodeInvalidStatsRange: {Messages: map[Lang]string{
+		PortugueseBR: "from deve ser anterior a to, com no máximo 366 dias de intervalo",
+		English:      "from must be before to, at most 366 days apart",
+	}},
+	CodeInvalidCursor: {Messages: map[Lang]string{
+		PortugueseBR: "cursor inválido",
+		English:      "invalid cursor",
+	}},
+	CodeInvalidSort: {Messages: map[Lang]string{
+		PortugueseBR: "sort deve ser created_at ou valor, com prefixo - para ordem decrescente",
+		English:      "sort must be created_at or valor, prefixed with - for descending order",
+	}},
+	CodeInvalidValorRange: {Messages: map[Lang]string{
+		PortugueseBR: "valor_min e valor_max devem ser valores em reais, ex.: 10.50",
+		English:      "valor_min and valor_max must be amounts in reais, e.g. 10.50",
+	}},
+	CodeInvalidContingency: {Messages: map[Lang]string{
+		PortugueseBR: "contingency deve ser um booleano",
+		English:      "contingency must be a boolean",
+	}},
+	CodeInvalidDestinatario: {Messages: map[Lang]string{
+		PortugueseBR: "destinatario deve ser um CPF ou CNPJ",
+		English:      "destinatario must be a CPF or CNPJ",
+	}},
+	CodeInvalidExportFormat: {Messages: map[Lang]string{
+		PortugueseBR: "format deve ser zip",
+		English:      "format must be zip",
+	}},
+	CodeInvalidExportIndex: {Messages: map[Lang]string{
+		PortugueseBR: "index deve ser um booleano",
+		English:      "index must be a boolean",
+	}},
+	CodeInvalidSearchQuery: {Messages: map[Lang]string{
+		PortugueseBR: "q deve ter entre 1 e 200 caracteres",
+		English:      "q must have between 1 and 200 characters",
+	}},
+	CodeInvalidGraphQLRequest: {Messages: map[Lang]string{
+		PortugueseBR: "Requisição GraphQL inválida",
+		English:      "invalid GraphQL request",
+	}},
+	CodeCompanyIDRequired: {Messages: map[Lang]string{
+		PortugueseBR: "company ID é obrigatório",
+		English:      "company ID is required",
+	}},
+	CodeCompanyNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Empresa não encontrada",
+		English:      "company not found",
+	}},
+	CodeCompanyAlreadyExists: {Messages: map[Lang]string{
+		PortugueseBR: "Já existe uma empresa com este CNPJ",
+		English:      "company with this CNPJ already exists",
+	}},
+	CodeCompanyDeleted: {Messages: map[Lang]string{
+		PortugueseBR: "A empresa foi excluída",
+		English:      "company was deleted",
+	}},
+	CodeInvalidCompany: {Messages: map[Lang]string{
+		PortugueseBR: "Dados da empresa inválidos",
+		English:      "invalid company",
+	}},
+	CodePlanIDRequired: {Messages: map[Lang]string{
+		PortugueseBR: "plan ID é obrigatório",
+		English:      "plan ID is required",
+	}},
+	CodeSubscriptionIDRequired: {Messages: map[Lang]string{
+		PortugueseBR: "subscription ID é obrigatório",
+		English:      "subscription ID is required",
+	}},
+	CodeWebhookIDRequired: {Messages: map[Lang]string{
+		PortugueseBR: "webhook ID é obrigatório",
+		English:      "webhook ID is required",
+	}},
+	CodeNFCeNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "NFC-e não encontrada",
+		English:      "NFC-e not found",
+	}},
+	CodeNFCeGetFailed: {Messages: map[Lang]string{
+		PortugueseBR: "Falha ao consultar a NFC-e",
+		English:      "failed to get NFC-e",
+	}},
+	CodeNFCeListFailed: {Messages: map[Lang]string{
+		PortugueseBR: "Falha ao listar as NFC-e",
+		English:      "failed to list NFC-es",
+	}},
+	CodeNFCeSearchFailed: {Messages: map[Lang]string{
+		PortugueseBR: "Falha ao buscar as NFC-e",
+		English:      "failed to search NFC-es",
+	}},
+	CodeNFCeEventsFailed: {Messages: map[Lang]string{
+		PortugueseBR: "Falha ao obter os eventos da NFC-e",
+		English:      "failed to get NFC-e events",
+	}},
+	CodeNFCeAlreadyRejected: {Messages: map[Lang]string{
+		PortugueseBR: "NFC-e já rejeitada",
+		English:      "NFC-e already rejected",
+	}},
+	CodeNFCeNotAuthorized: {Messages: map[Lang]string{
+		PortugueseBR: "NFC-e não está autorizada",
+		English:      "NFC-e is not authorized",
+	}},
+	CodeNFCeNotCancelable: {Messages: map[Lang]string{
+		PortugueseBR: "Somente NFC-e autorizadas podem ser canceladas",
+		English:      "only authorized NFC-e can be canceled",
+	}},
+	CodeLoteNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Lote não encontrado",
+		English:      "lote not found",
+	}},
+	CodeLoteGetFailed: {Messages: map[Lang]string{
+		PortugueseBR: "Falha ao consultar o lote",
+		English:      "failed to get lote",
+	}},
+	CodeXMLNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Arquivo XML não encontrado",
+		English:      "XML file not found",
+	}},
+	CodePDFNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Arquivo PDF não encontrado",
+		English:      "PDF file not found",
+	}},
+	CodeQRCodeNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Arquivo do QR Code não encontrado",
+		English:      "QR Code file not found",
+	}},
+	CodeDocumentsPurged: {Messages: map[Lang]string{
+		PortugueseBR: "Os documentos da NFC-e foram removidos pela retenção do plano",
+		English:      "NFC-e documents were purged by the plan retention",
+	}},
+	CodeEventStreamUnavailable: {Messages: map[Lang]string{
+		PortugueseBR: "Stream de eventos indisponível",
+		English:      "event streaming is not available",
+	}},
+	CodeFeatureNotInPlan: {Messages: map[Lang]string{
+		PortugueseBR: "O recurso %s não está incluído no plano atual. Faça upgrade do plano para habilitá-lo.",
+		English:      "The %s feature is not included in the current plan. Upgrade the plan to enable it.",
+	}},
+	CodeQuotaExhausted: {Messages: map[Lang]string{
+		PortugueseBR: "cota de NFC-e esgotada para o período",
+		English:      "NFC-e quota exhausted for the period",
+	}},
+	CodeSubscriptionInactive: {Messages: map[Lang]string{
+		PortugueseBR: "Nenhuma assinatura ativa para emitir NFC-e",
+		English:      "no active subscription",
+	}},
+	CodeBrokerUnavailable: {Messages: map[Lang]string{
+		PortugueseBR: "Fila de mensagens indisponível",
+		English:      "message broker unavailable",
+	}},
+	CodeInutilizacaoNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Inutilização não encontrada",
+		English:      "inutilizacao not found",
+	}},
+	CodeInutilizacaoOverlap: {Messages: map[Lang]string{
+		PortugueseBR: "A faixa de numeração sobrepõe uma inutilização existente",
+		English:      "number range overlaps an existing inutilizacao",
+	}},
+	CodeExportNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Exportação não encontrada",
+		English:      "export not found",
+	}},
+	CodeExportExpired: {Messages: map[Lang]string{
+		PortugueseBR: "A exportação expirou",
+		English:      "export has expired",
+	}},
+	CodeExportPartNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Parte da exportação não encontrada",
+		English:      "export part not found",
+	}},
+	CodeCertificateExpired: {Messages: map[Lang]string{
+		PortugueseBR: "certificado já expirou",
+		English:      "certificate has expired",
+	}},
+	CodeCertificatePassword: {Messages: map[Lang]string{
+		PortugueseBR: "Senha do certificado incorreta",
+		English:      "certificate password is incorrect",
+	}},
+	CodeCertificateUnsupported: {Messages: map[Lang]string{
+		PortugueseBR: "Formato do arquivo do certificado não suportado",
+		English:      "certificate file encoding is not supported",
+	}},
+	CodeCertificateInvalid: {Messages: map[Lang]string{
+		PortugueseBR: "Arquivo do certificado não é um PFX válido",
+		English:      "certificate file is not a valid PFX",
+	}},
+	CodeCertificateCNPJ: {Messages: map[Lang]string{
+		PortugueseBR: "Certificado não foi emitido para a raiz do CNPJ da empresa",
+		English:      "certificate was not issued to the company CNPJ",
+	}},
+	CodeCSCExpired: {Messages: map[Lang]string{
+		PortugueseBR: "CSC já expirou",
+		English:      "CSC has expired",
+	}},
+	CodeCSCNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "CSC não encontrado",
+		English:      "CSC not found",
+	}},
+	CodeEmissionBlocked: {Messages: map[Lang]string{
+		PortugueseBR: "Emissão bloqueada pelas regras antifraude",
+		English:      "emission blocked by anti-fraud rules",
+	}},
+	CodeFraudVelocity: {Messages: map[Lang]string{
+		PortugueseBR: "Limite de NFC-e por terminal excedido",
+		English:      "NFC-e per terminal limit exceeded",
+	}},
+	CodeFraudTicketValue: {Messages: map[Lang]string{
+		PortugueseBR: "Valor da NFC-e acima do limite permitido",
+		English:      "NFC-e total above the allowed limit",
+	}},
+	CodeFraudDuplicate: {Messages: map[Lang]string{
+		PortugueseBR: "NFC-e idêntica emitida recentemente",
+		English:      "identical NFC-e issued recently",
+	}},
+	CodeDFeDocumentNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Documento distribuído não encontrado",
+		English:      "dfe document not found",
+	}},
+	CodeDFeSyncTooSoon: {Messages: map[Lang]string{
+		PortugueseBR: "A SEFAZ exige aguardar uma hora entre consultas sem novos documentos",
+		English:      "dfe sync too soon",
+	}},
+	CodeInvalidFiscalCodes: {Messages: map[Lang]string{
+		PortugueseBR: "NCM, CEST ou CFOP inválidos nos itens",
+		English:      "invalid fiscal codes",
+	}},
+	CodeUnderMaintenance: {Messages: map[Lang]string{
+		PortugueseBR: "Emissão suspensa durante a manutenção da plataforma",
+		English:      "service under maintenance",
+	}},
+	CodeMaintenanceActive: {Messages: map[Lang]string{
+		PortugueseBR: "Já existe uma manutenção em andamento",
+		English:      "maintenance already active",
+	}},
+	CodeMaintenanceNotActive: {Messages: map[Lang]string{
+		PortugueseBR: "Nenhuma manutenção em andamento",
+		English:      "maintenance not active",
+	}},
+	CodeInvalidCredentials: {Messages: map[Lang]string{
+		PortugueseBR: "Usuário ou senha inválidos",
+		English:      "invalid credentials",
+	}},
+	CodeInvalidAPIKey: {Messages: map[Lang]string{
+		PortugueseBR: "Chave de API inválida, revogada ou expirada",
+		English:      "invalid API key",
+	}},
+	CodeInvalidToken: {Messages: map[Lang]string{
+		PortugueseBR: "Token inválido ou expirado",
+		English:      "invalid token",
+	}},
+	CodeCompanyInactive: {Messages: map[Lang]string{
+		PortugueseBR: "Empresa inativa ou bloqueada",
+		English:      "company is not active",
+	}},
+	CodeAPIKeyNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Chave de API não encontrada",
+		English:      "API key not found",
+	}},
+	CodeAPIKeyRevoked: {Messages: map[Lang]string{
+		PortugueseBR: "Chave de API revogada ou expirada",
+		English:      "API key is revoked or expired",
+	}},
+	CodeEmailDisabled: {Messages: map[Lang]string{
+		PortugueseBR: "Envio de e-mail não está configurado",
+		English:      "e-mail delivery is not configured",
+	}},
+	CodeEmailRecipientMissing: {Messages: map[Lang]string{
+		PortugueseBR: "NFC-e não possui e-mail do consumidor",
+		English:      "NFC-e has no consumer e-mail",
+	}},
+	CodeEmailSendFailed: {Messages: map[Lang]string{
+		PortugueseBR: "Falha ao enviar o e-mail",
+		English:      "failed to send e-mail",
+	}},
+	CodeInvalidSchemaSource: {Messages: map[Lang]string{
+		PortugueseBR: "Origem dos schemas deve ser download ou bundle",
+		English:      "source must be download or bundle",
+	}},
+	CodeSchemaNotBundled: {Messages: map[Lang]string{
+		PortugueseBR: "O pacote de schemas não está embutido no binário",
+		English:      "schema package is not bundled",
+	}},
+	CodeSchemaUpdateInProgress: {Messages: map[Lang]string{
+		PortugueseBR: "Já existe uma atualização de schemas em andamento",
+		English:      "schema update already in progress",
+	}},
+	CodeInvalidSEFAZTables: {Messages: map[Lang]string{
+		PortugueseBR: "Tabelas da SEFAZ inválidas",
+		English:      "invalid SEFAZ tables",
+	}},
+	CodePaymentGatewayNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Gateway de pagamento não configurado",
+		English:      "payment gateway not configured",
+	}},
+	CodePaymentSignature: {Messages: map[Lang]string{
+		PortugueseBR: "Assinatura do webhook de pagamento inválida",
+		English:      "invalid payment webhook signature",
+	}},
+	CodePaymentWebhookRead: {Messages: map[Lang]string{
+		PortugueseBR: "Falha ao ler o webhook de pagamento",
+		English:      "failed to read payment webhook",
+	}},
+	CodeSubscriptionNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Assinatura não encontrada",
+		English:      "subscription not found",
+	}},
+	CodePlanNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Plano não encontrado",
+		English:      "plan not found",
+	}},
+	CodeInvalidPlanChange: {Messages: map[Lang]string{
+		PortugueseBR: "Troca de plano inválida",
+		English:      "invalid plan change",
+	}},
+	CodeRateLimitExceeded: {Messages: map[Lang]string{
+		PortugueseBR: "Limite de requisições por minuto excedido, tente novamente após o tempo indicado em Retry-After",
+		English:      "rate limit exceeded",
+	}},
+	CodeSEFAZExchangesNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Nenhuma comunicação com a SEFAZ arquivada para esta NFC-e",
+		English:      "no SEFAZ exchanges archived for this NFC-e",
+	}},
+	CodeNFCeAlreadyAuthorized: {Messages: map[Lang]string{
+		PortugueseBR: "NFC-e já autorizada",
+		English:      "NFC-e already authorized",
+	}},
+	CodeNFCeNotReprocessable: {Messages: map[Lang]string{
+		PortugueseBR: "NFC-e não pode ser reprocessada",
+		English:      "NFC-e can not be reprocessed",
+	}},
+	CodeESCPOSNotAvailable: {Messages: map[Lang]string{
+		PortugueseBR: "O DANFE da NF-e não tem versão ESC/POS",
+		English:      "the DANFE of the NF-e has no ESC/POS version",
+	}},
+	CodeSeriesNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Série de numeração não encontrada",
+		English:      "numbering series not found",
+	}},
+	CodeSeriesAlreadyExists: {Messages: map[Lang]string{
+		PortugueseBR: "Série de numeração já cadastrada",
+		English:      "numbering series already exists",
+	}},
+	CodeSeriesInactive: {Messages: map[Lang]string{
+		PortugueseBR: "Série de numeração inativa",
+		English:      "numbering series is inactive",
+	}},
+	CodeStatementNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Extrato não encontrado",
+		English:      "statement not found",
+	}},
+	CodeInvalidWebhookFilter: {Messages: map[Lang]string{
+		PortugueseBR: "Filtro de webhook inválido",
+		English:      "invalid webhook filter",
+	}},
+	CodeInvalidChaveAcesso: {Messages: map[Lang]string{
+		PortugueseBR: "Chave de acesso inválida: informe os 44 dígitos da chave de uma NFC-e",
+		English:      "invalid chave de acesso: send the 44 digits of the chave of an NFC-e",
+	}},
+	CodeWebhookNotFound: {Messages: map[Lang]string{
+		PortugueseBR: "Webhook não encontrado",
+		English:      "webhook not found",
+	}},
+}
+
+// Message returns the text of code in lang, falling back to DefaultLang
+func Message(code Code, lang Lang, args ...interface{}) string {
+	e, ok := catalog[code]
+	if !ok {
+		return string(code)
+	}
+
+	text, ok := e.Messages[lang]
+	if !ok {
+		text = e.Messages[DefaultLang]
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// Messages returns the text of code in every supported language, for payloads
+// without a negotiated language such as webhooks
+func Messages(code Code, args ...interface{}) map[Lang]string {
+	return map[Lang]string{
+		PortugueseBR: Message(code, PortugueseBR, args...),
+		English:      Message(code, English, args...),
+	}
+}
+
+// ForStatus returns the generic code of an HTTP error status
+func ForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusNotImplemented:
+		return CodeNotImplemented
+	case http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	default:
+		if status >= 500 {
+			return CodeInternal
+		}
+		return CodeInvalidRequest
+	}
+}
+
+// Negotiate picks the supported language with the highest weight in an
+// Accept-Language header, e.g. "en-US,en;q=0.9,pt-BR;q=0.8"
+func Negotiate(acceptLanguage string) Lang {
+	type candidate struct {
+		lang   Lang
+		weight float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+
+		weight := 1.0
+		for _, param := range fields[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+					weight = parsed
+				}
+			}
+		}
+
+		switch {
+		case tag == "pt" || strings.HasPrefix(tag, "pt-"):
+			candidates = append(candidates, candidate{PortugueseBR, weight})
+		case tag == "en" || strings.HasPrefix(tag, "en-"):
+			candidates = append(candidates, candidate{English, weight})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return DefaultLang
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].weight > candidates[j].weight
+	})
+	if candidates[0].weight <= 0 {
+		return DefaultLang
+	}
+	return candidates[0].lang
+}
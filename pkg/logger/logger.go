@@ -1,12 +1,117 @@
 package logger
 
+import (
+	"context"
+	"fmt"
+)
+
+// Logger writes structured entries; With returns a child logger adding its fields to every
+// entry, so the entries of one NFC-e or request carry the same identifiers
 type Logger interface {
+	Debug(msg string, fields ...Field)
 	Info(msg string, fields ...Field)
-	Error(msg string, fields ...Field)
 	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+	With(fields ...Field) Logger
 }
 
 type Field struct {
 	Key   string
 	Value interface{}
 }
+
+// Providers that write the entries
+const (
+	ProviderZap    = "zap"
+	ProviderLogrus = "logrus"
+)
+
+// Minimum levels of the entries written
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Encodings of the entries
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console" // Human-readable lines, for development
+)
+
+// Config selects the provider, the minimum level and the encoding of the logger
+type Config struct {
+	Provider string // zap | logrus
+	Level    string // debug | info | warn | error
+	Encoding string // json | console
+}
+
+// New creates the logger of the configured provider; empty settings default to zap, info and json
+func New(config Config) (Logger, error) {
+	switch config.Provider {
+	case "", ProviderZap:
+		return NewZapLoggerWithConfig(config)
+	case ProviderLogrus:
+		return NewLogrusLoggerWithConfig(config)
+	default:
+		return nil, fmt.Errorf("unknown logger provider %q", config.Provider)
+	}
+}
+
+// RequestID identifies the NFC-e request, or the HTTP request, an entry is about
+func RequestID(id string) Field {
+	return Field{Key: "request_id", Value: id}
+}
+
+// CompanyID identifies the company an entry is about
+func CompanyID(id string) Field {
+	return Field{Key: "company_id", Value: id}
+}
+
+// Chave identifies the NFC-e by its chave de acesso
+func Chave(chave string) Field {
+	return Field{Key: "chave", Value: chave}
+}
+
+// Err carries the text of an error
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// contextKey is the key of the logger carried by a context
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l, usually a child logger with the fields of the
+// NFC-e or request being handled
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or fallback when it carries none. A nil
+// fallback gives a logger that discards the entries.
+func FromContext(ctx context.Context, fallback Logger) Logger {
+	if l, ok := ctx.Value(contextKey{}).(Logger); ok && l != nil {
+		return l
+	}
+	if fallback == nil {
+		return nopLogger{}
+	}
+	return fallback
+}
+
+// NewNopLogger returns a logger that discards the entries
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...Field) {}
+func (nopLogger) Info(string, ...Field)  {}
+func (nopLogger) Warn(string, ...Field)  {}
+func (nopLogger) Error(string, ...Field) {}
+func (n nopLogger) With(...Field) Logger { return n }
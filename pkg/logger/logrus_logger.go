@@ -6,30 +6,56 @@ import (
 )
 
 type LogrusLogger struct {
-	logger *logrus.Logger
+	entry *logrus.Entry
 }
 
 func NewLogrusLogger() Logger {
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.JSONFormatter{})
-	return &LogrusLogger{logger: logger}
+	return &LogrusLogger{entry: logrus.NewEntry(logger)}
+}
+
+// NewLogrusLoggerWithConfig creates a logrus logger at the configured level and encoding
+func NewLogrusLoggerWithConfig(config Config) (Logger, error) {
+	logger := logrus.New()
+	if config.Encoding == EncodingConsole {
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	} else {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	}
+	if config.Level != "" {
+		level, err := logrus.ParseLevel(config.Level)
+		if err != nil {
+			return nil, err
+		}
+		logger.SetLevel(level)
+	}
+	return &LogrusLogger{entry: logrus.NewEntry(logger)}, nil
+}
+
+func (l *LogrusLogger) Debug(msg string, fields ...Field) {
+	l.entry.WithFields(l.convertFields(fields)).Debug(msg)
 }
 
 func (l *LogrusLogger) Info(msg string, fields ...Field) {
-	entry := l.logger.WithFields(l.convertFields(fields))
+	entry := l.entry.WithFields(l.convertFields(fields))
 	entry.Info(msg)
 }
 
 func (l *LogrusLogger) Error(msg string, fields ...Field) {
-	entry := l.logger.WithFields(l.convertFields(fields))
+	entry := l.entry.WithFields(l.convertFields(fields))
 	entry.Error(msg)
 }
 
 func (l *LogrusLogger) Warn(msg string, fields ...Field) {
-	entry := l.logger.WithFields(l.convertFields(fields))
+	entry := l.entry.WithFields(l.convertFields(fields))
 	entry.Warn(msg)
 }
 
+func (l *LogrusLogger) With(fields ...Field) Logger {
+	return &LogrusLogger{entry: l.entry.WithFields(l.convertFields(fields))}
+}
+
 func (l *LogrusLogger) convertFields(fields []Field) logrus.Fields {
 	logrusFields := logrus.Fields{}
 	for _, f := range fields {
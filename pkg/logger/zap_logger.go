@@ -3,6 +3,7 @@ package logger
 
 import (
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type ZapLogger struct {
@@ -17,6 +18,32 @@ func NewZapLogger() Logger {
 	return &ZapLogger{logger: logger}
 }
 
+// NewZapLoggerWithConfig creates a zap logger writing to stderr at the configured level and encoding
+func NewZapLoggerWithConfig(config Config) (Logger, error) {
+	zapConfig := zap.NewProductionConfig()
+	if config.Level != "" {
+		level, err := zapcore.ParseLevel(config.Level)
+		if err != nil {
+			return nil, err
+		}
+		zapConfig.Level = zap.NewAtomicLevelAt(level)
+	}
+	if config.Encoding == EncodingConsole {
+		zapConfig.Encoding = EncodingConsole
+		zapConfig.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	}
+
+	logger, err := zapConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+	return &ZapLogger{logger: logger}, nil
+}
+
+func (z *ZapLogger) Debug(msg string, fields ...Field) {
+	z.logger.Debug(msg, z.convertFields(fields)...)
+}
+
 func (z *ZapLogger) Info(msg string, fields ...Field) {
 	z.logger.Info(msg, z.convertFields(fields)...)
 }
@@ -29,6 +56,10 @@ func (z *ZapLogger) Warn(msg string, fields ...Field) {
 	z.logger.Warn(msg, z.convertFields(fields)...)
 }
 
+func (z *ZapLogger) With(fields ...Field) Logger {
+	return &ZapLogger{logger: z.logger.With(z.convertFields(fields)...)}
+}
+
 func (z *ZapLogger) convertFields(fields []Field) []zap.Field {
 	zapFields := make([]zap.Field, len(fields))
 	for i, f := range fields {
@@ -0,0 +1,258 @@
+// Package money implements the fixed-point values of the NF-e layout: amounts in
+// centavos and quantities with four decimals. Values are parsed from their decimal
+// text, never through float64, and rounded half away from zero, so the item values,
+// their sums in ICMSTot and the DANFE always agree (rejection 629 and friends).
+package money
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a monetary value in centavos
+type Amount int64
+
+// Quantity is a commercial quantity in units of 0.0001
+type Quantity int64
+
+const (
+	amountDecimals   = 2
+	quantityDecimals = 4
+	quantityScale    = 10000
+	// rateScale is the precision of the percentages applied with Percent (0.0001%)
+	rateScale = 10000
+)
+
+// ErrInvalid is returned when a value is not a decimal number
+var ErrInvalid = errors.New("invalid decimal value")
+
+// Parse reads a decimal amount such as "29.90", rounding extra decimals half away from zero
+func Parse(s string) (Amount, error) {
+	value, err := parseFixed(s, amountDecimals)
+	return Amount(value), err
+}
+
+// FromFloat converts a float, rounding to centavos; used at the edges that still
+// carry floats (configured limits, tax rates). NaN, the infinities and values beyond
+// the int64 range of centavos convert to zero, which no limit or rate is meant to be.
+func FromFloat(f float64) Amount {
+	amount, _ := Parse(strconv.FormatFloat(f, 'f', -1, 64))
+	return amount
+}
+
+// Sum adds the amounts
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, amount := range amounts {
+		total += amount
+	}
+	return total
+}
+
+// String formats the amount with the two decimals of the NF-e layout
+func (a Amount) String() string {
+	return formatFixed(int64(a), amountDecimals)
+}
+
+// Float64 returns the amount in reais, for display and metrics only
+func (a Amount) Float64() float64 {
+	return float64(a) / 100
+}
+
+// Percent applies a percentage rate (e.g. 18 for 18%) rounding to centavos
+func (a Amount) Percent(rate float64) Amount {
+	scaled := int64(math.Round(rate * rateScale))
+	return Amount(divRound(int64(a)*scaled, 100*rateScale))
+}
+
+// Allocate splits the amount proportionally to the weights; the rounding
+// difference goes to the last non-zero weight so the shares always add up
+func (a Amount) Allocate(weights []Amount) []Amount {
+	shares := make([]Amount, len(weights))
+	total := Sum(weights...)
+	if a == 0 || total == 0 {
+		return shares
+	}
+
+	last := -1
+	var allocated Amount
+	for i, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+		shares[i] = Amount(divRound(int64(a)*int64(weight), int64(total)))
+		allocated += shares[i]
+		last = i
+	}
+	shares[last] += a - allocated
+	return shares
+}
+
+// MarshalJSON encodes the amount as a JSON number with two decimals
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number (or numeric string) without going through float64
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFixed(data, amountDecimals)
+	if err != nil {
+		return err
+	}
+	*a = Amount(value)
+	return nil
+}
+
+// ParseQuantity reads a decimal quantity such as "1.5", rounding to four decimals
+func ParseQuantity(s string) (Quantity, error) {
+	value, err := parseFixed(s, quantityDecimals)
+	return Quantity(value), err
+}
+
+// QuantityFromFloat converts a float, rounding to four decimals. As with FromFloat,
+// NaN, the infinities and values out of range convert to zero.
+func QuantityFromFloat(f float64) Quantity {
+	quantity, _ := ParseQuantity(strconv.FormatFloat(f, 'f', -1, 64))
+	return quantity
+}
+
+// Units returns a quantity of whole units
+func Units(n int64) Quantity {
+	return Quantity(n * quantityScale)
+}
+
+// String formats the quantity with the four decimals of qCom/qTrib
+func (q Quantity) String() string {
+	return formatFixed(int64(q), quantityDecimals)
+}
+
+// Float64 returns the quantity as a float, for display only
+func (q Quantity) Float64() float64 {
+	return float64(q) / quantityScale
+}
+
+// Times returns the unit price times the quantity rounded to centavos (vProd)
+func (q Quantity) Times(price Amount) Amount {
+	return Amount(divRound(int64(price)*int64(q), quantityScale))
+}
+
+// MarshalJSON encodes the quantity as a JSON number
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(strings.TrimRight(strings.TrimRight(q.String(), "0"), ".")), nil
+}
+
+// UnmarshalJSON decodes a JSON number (or numeric string) without going through float64
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFixed(data, quantityDecimals)
+	if err != nil {
+		return err
+	}
+	*q = Quantity(value)
+	return nil
+}
+
+func unmarshalFixed(data []byte, decimals int) (int64, error) {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return 0, nil
+	}
+	if len(data) > 1 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+	return parseFixed(string(data), decimals)
+}
+
+// parseFixed reads a decimal number into an integer with the given decimals,
+// rounding the remaining digits half away from zero
+func parseFixed(s string, decimals int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("%w: empty", ErrInvalid)
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative, s = true, s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" || !allDigits(intPart) || !allDigits(fracPart) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+
+	roundUp := len(fracPart) > decimals && fracPart[decimals] >= '5'
+	if len(fracPart) > decimals {
+		fracPart = fracPart[:decimals]
+	}
+	fracPart += strings.Repeat("0", decimals-len(fracPart))
+
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	var value int64
+	if digits != "" {
+		var err error
+		if value, err = strconv.ParseInt(digits, 10, 64); err != nil {
+			return 0, fmt.Errorf("%w: %q out of range", ErrInvalid, s)
+		}
+	}
+	if roundUp {
+		if value == math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %q out of range", ErrInvalid, s)
+		}
+		value++
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+func formatFixed(value int64, decimals int) string {
+	sign := ""
+	if value < 0 {
+		sign, value = "-", -value
+	}
+	digits := strconv.FormatInt(value, 10)
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	cut := len(digits) - decimals
+	return sign + digits[:cut] + "." + digits[cut:]
+}
+
+// divRound divides rounding half away from zero
+func divRound(numerator, denominator int64) int64 {
+	quotient, remainder := numerator/denominator, numerator%denominator
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	if 2*remainder >= denominator {
+		if numerator < 0 {
+			return quotient - 1
+		}
+		return quotient + 1
+	}
+	return quotient
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,211 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Amount
+		wantErr bool
+	}{
+		{input: "29.90", want: 2990},
+		{input: "29.9", want: 2990},
+		{input: "29", want: 2900},
+		{input: ".5", want: 50},
+		{input: "+1.00", want: 100},
+		{input: " 1.00 ", want: 100},
+		{input: "0.004", want: 0},
+		{input: "0.005", want: 1},
+		{input: "2.675", want: 268},
+		{input: "-0.005", want: -1},
+		{input: "-2.675", want: -268},
+		{input: "-2.674", want: -267},
+		{input: "1.2e1", want: 1200},
+		{input: "1.005E2", want: 10050},
+		{input: "5e-3", want: 1},
+		{input: "-5e-3", want: -1},
+		{input: "92233720368547758.07", want: math.MaxInt64},
+		{input: "92233720368547758.08", wantErr: true},
+		{input: "92233720368547758.075", wantErr: true},
+		{input: "1e17", wantErr: true},
+		{input: "1e400", wantErr: true},
+		{input: "", wantErr: true},
+		{input: ".", wantErr: true},
+		{input: "-", wantErr: true},
+		{input: "1,50", wantErr: true},
+		{input: "1.2.3", wantErr: true},
+		{input: "NaN", wantErr: true},
+		{input: "Inf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalid) {
+					t.Fatalf("Parse(%q) = %d, %v; want ErrInvalid", tt.input, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Parse(%q) = %d, %v; want %d", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Quantity
+	}{
+		{input: "1.5", want: 15000},
+		{input: "0.00005", want: 1},
+		{input: "0.00004", want: 0},
+		{input: "-0.00005", want: -1},
+		{input: "2.5e-4", want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got, err := ParseQuantity(tt.input); err != nil || got != tt.want {
+				t.Fatalf("ParseQuantity(%q) = %d, %v; want %d", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input float64
+		want  Amount
+	}{
+		{name: "price", input: 29.9, want: 2990},
+		{name: "half", input: 0.125, want: 13},
+		{name: "negative half", input: -0.125, want: -13},
+		{name: "NaN", input: math.NaN()},
+		{name: "infinity", input: math.Inf(1)},
+		{name: "negative infinity", input: math.Inf(-1)},
+		{name: "out of range", input: 1e20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromFloat(tt.input); got != tt.want {
+				t.Fatalf("FromFloat(%v) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	if got := QuantityFromFloat(math.NaN()); got != 0 {
+		t.Fatalf("QuantityFromFloat(NaN) = %d, want 0", got)
+	}
+	if got := QuantityFromFloat(1.25); got != 12500 {
+		t.Fatalf("QuantityFromFloat(1.25) = %d, want 12500", got)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Amount
+		rate   float64
+		want   Amount
+	}{
+		{name: "ICMS", amount: 1000, rate: 18, want: 180},
+		{name: "rounds up", amount: 333, rate: 18, want: 60},
+		{name: "half", amount: 25, rate: 10, want: 3},
+		{name: "negative half", amount: -25, rate: 10, want: -3},
+		{name: "fractional rate", amount: 1000, rate: 7.6, want: 76},
+		{name: "four decimal rate", amount: 1000000, rate: 0.0001, want: 1},
+		{name: "zero rate", amount: 1000, rate: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amount.Percent(tt.rate); got != tt.want {
+				t.Fatalf("%s.Percent(%v) = %s, want %s", tt.amount, tt.rate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  Amount
+		weights []Amount
+		want    []Amount
+	}{
+		{name: "thirds", amount: 100, weights: []Amount{1, 1, 1}, want: []Amount{33, 33, 34}},
+		{name: "negative thirds", amount: -100, weights: []Amount{1, 1, 1}, want: []Amount{-33, -33, -34}},
+		{name: "proportional", amount: 1000, weights: []Amount{2990, 1010}, want: []Amount{748, 252}},
+		{name: "remainder to the last non-zero", amount: 100, weights: []Amount{1, 1, 1, 0}, want: []Amount{33, 33, 34, 0}},
+		{name: "zero weights", amount: 100, weights: []Amount{0, 0}, want: []Amount{0, 0}},
+		{name: "zero amount", amount: 0, weights: []Amount{1, 2}, want: []Amount{0, 0}},
+		{name: "prices", amount: 1, weights: []Amount{199, 299, 399}, want: []Amount{0, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.amount.Allocate(tt.weights)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Allocate = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Allocate = %v, want %v", got, tt.want)
+				}
+			}
+			if Sum(tt.weights...) != 0 && Sum(got...) != tt.amount {
+				t.Fatalf("shares %v add up to %s, want %s", got, Sum(got...), tt.amount)
+			}
+		})
+	}
+}
+
+func TestTimes(t *testing.T) {
+	if got := QuantityFromFloat(1.5).Times(333); got != 500 {
+		t.Fatalf("1.5 x 3.33 = %s, want 5.00", got)
+	}
+	if got := Units(3).Times(1990); got != 5970 {
+		t.Fatalf("3 x 19.90 = %s, want 59.70", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type item struct {
+		Valor      Amount   `json:"valor"`
+		Desconto   Amount   `json:"desconto"`
+		Quantidade Quantity `json:"quantidade"`
+	}
+
+	in := item{Valor: 2990, Desconto: -5, Quantidade: 15000}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if want := `{"valor":29.90,"desconto":-0.05,"quantidade":1.5}`; string(data) != want {
+		t.Fatalf("Marshal = %s, want %s", data, want)
+	}
+	var out item
+	if err := json.Unmarshal(data, &out); err != nil || out != in {
+		t.Fatalf("Unmarshal(%s) = %+v, %v; want %+v", data, out, err, in)
+	}
+
+	if err := json.Unmarshal([]byte(`{"valor":"29.9","desconto":null,"quantidade":2}`), &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if want := (item{Valor: 2990, Quantidade: 20000}); out != want {
+		t.Fatalf("Unmarshal = %+v, want %+v", out, want)
+	}
+	if err := json.Unmarshal([]byte(`{"valor":"abc"}`), &out); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Unmarshal of a non-number = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package validation checks the Brazilian document and fiscal codes of the API payloads
+// (CNPJ, CPF, NCM, CFOP, UF and CEP) as validator tags, and describes the failed rules in
+// Portuguese, so an invalid request is answered field by field before anything is persisted
+// or sent to SEFAZ.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	ncmPattern  = regexp.MustCompile(`^(\d{8}|00)$`) // 00 for services
+	cfopPattern = regexp.MustCompile(`^[1-35-7]\d{3}$`)
+	cepPattern  = regexp.MustCompile(`^\d{5}-?\d{3}$`)
+	// documentPunctuation is the formatting accepted in a CNPJ or CPF, e.g. 12.345.678/0001-95
+	documentPunctuation = strings.NewReplacer(".", "", "/", "", "-", "")
+)
+
+// ufs lists the federal units of the IBGE table
+var ufs = map[string]bool{
+	"AC": true, "AL": true, "AM": true, "AP": true, "BA": true, "CE": true, "DF": true,
+	"ES": true, "GO": true, "MA": true, "MG": true, "MS": true, "MT": true, "PA": true,
+	"PB": true, "PE": true, "PI": true, "PR": true, "RJ": true, "RN": true, "RO": true,
+	"RR": true, "RS": true, "SC": true, "SE": true, "SP": true, "TO": true,
+}
+
+// validators maps each tag to the check of the field value
+var validators = map[string]func(string) bool{
+	"cnpj": CNPJ,
+	"cpf":  CPF,
+	"ncm":  NCM,
+	"cfop": CFOP,
+	"uf":   UF,
+	"cep":  CEP,
+}
+
+// Register adds the cnpj, cpf, ncm, cfop, uf and cep tags to v
+func Register(v *validator.Validate) error {
+	for tag, check := range validators {
+		err := v.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return check(fl.Field().String())
+		})
+		if err != nil {
+			return fmt.Errorf("failed to register the %s validation: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// CNPJ reports whether s is a CNPJ with valid check digits, with or without punctuation
+func CNPJ(s string) bool {
+	digits := documentPunctuation.Replace(s)
+	if len(digits) != 14 || !onlyDigits(digits) || strings.Count(digits, digits[:1]) == 14 {
+		return false
+	}
+	return checkDigits(digits, 12, []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}) &&
+		checkDigits(digits, 13, []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2})
+}
+
+// CPF reports whether s is a CPF with valid check digits, with or without punctuation
+func CPF(s string) bool {
+	digits := documentPunctuation.Replace(s)
+	if len(digits) != 11 || !onlyDigits(digits) || strings.Count(digits, digits[:1]) == 11 {
+		return false
+	}
+	return checkDigits(digits, 9, []int{10, 9, 8, 7, 6, 5, 4, 3, 2}) &&
+		checkDigits(digits, 10, []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2})
+}
+
+// NCM reports whether s has the 8 digits of an NCM, or is 00 for services
+func NCM(s string) bool {
+	return ncmPattern.MatchString(s)
+}
+
+// CFOP reports whether s has the 4 digits of a CFOP, starting with an entry (1 to 3) or
+// exit (5 to 7) group
+func CFOP(s string) bool {
+	return cfopPattern.MatchString(s)
+}
+
+// UF reports whether s is the abbreviation of a federal unit, e.g. SP
+func UF(s string) bool {
+	return ufs[s]
+}
+
+// CEP reports whether s has the 8 digits of a CEP, with or without the hyphen
+func CEP(s string) bool {
+	return cepPattern.MatchString(s)
+}
+
+// Message describes the rule a field failed, in Portuguese
+func Message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "campo obrigatório"
+	case "cnpj":
+		return "CNPJ inválido"
+	case "cpf":
+		return "CPF inválido"
+	case "ncm":
+		return "NCM deve ter 8 dígitos (00 para serviços)"
+	case "cfop":
+		return "CFOP deve ter 4 dígitos, iniciando por 1, 2, 3, 5, 6 ou 7"
+	case "uf":
+		return "UF inválida, informe a sigla do estado (ex.: SP)"
+	case "cep":
+		return "CEP deve ter 8 dígitos"
+	case "oneof":
+		return fmt.Sprintf("valor deve ser um de: %s", strings.Join(strings.Fields(fe.Param()), ", "))
+	case "len":
+		if isCollection(fe) {
+			return fmt.Sprintf("deve ter exatamente %s itens", fe.Param())
+		}
+		return fmt.Sprintf("deve ter exatamente %s caracteres", fe.Param())
+	case "min":
+		if isCollection(fe) {
+			return fmt.Sprintf("deve ter ao menos %s itens", fe.Param())
+		}
+		if isText(fe) {
+			return fmt.Sprintf("deve ter ao menos %s caracteres", fe.Param())
+		}
+		return fmt.Sprintf("deve ser maior ou igual a %s", fe.Param())
+	case "max":
+		if isCollection(fe) {
+			return fmt.Sprintf("deve ter no máximo %s itens", fe.Param())
+		}
+		if isText(fe) {
+			return fmt.Sprintf("deve ter no máximo %s caracteres", fe.Param())
+		}
+		return fmt.Sprintf("deve ser menor ou igual a %s", fe.Param())
+	case "email":
+		return "e-mail inválido"
+	case "url":
+		return "URL inválida"
+	case "numeric":
+		return "deve conter apenas números"
+	default:
+		return fmt.Sprintf("valor inválido (regra %s)", fe.Tag())
+	}
+}
+
+// checkDigits reports whether the digit at position is the modulo 11 check digit of the
+// digits before it, weighted by weights
+func checkDigits(digits string, position int, weights []int) bool {
+	total := 0
+	for i, weight := range weights {
+		total += int(digits[i]-'0') * weight
+	}
+	digit := 11 - total%11
+	if digit >= 10 {
+		digit = 0
+	}
+	return int(digits[position]-'0') == digit
+}
+
+// onlyDigits reports whether s has only decimal digits
+func onlyDigits(s string) bool {
+	for _, char := range s {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isCollection reports whether the field is a slice, array or map
+func isCollection(fe validator.FieldError) bool {
+	switch fe.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// isText reports whether the field is a string
+func isText(fe validator.FieldError) bool {
+	return fe.Kind() == reflect.String
+}
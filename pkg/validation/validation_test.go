@@ -0,0 +1,155 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(string) bool
+		input string
+		want  bool
+	}{
+		{name: "CNPJ", check: CNPJ, input: "11222333000181", want: true},
+		{name: "CNPJ punctuated", check: CNPJ, input: "11.222.333/0001-81", want: true},
+		{name: "CNPJ first check digit", check: CNPJ, input: "11222333000171"},
+		{name: "CNPJ second check digit", check: CNPJ, input: "11222333000182"},
+		{name: "CNPJ repeated digits", check: CNPJ, input: "11111111111111"},
+		{name: "CNPJ repeated zeros", check: CNPJ, input: "00000000000000"},
+		{name: "CNPJ too short", check: CNPJ, input: "1122233300018"},
+		{name: "CNPJ too long", check: CNPJ, input: "112223330001810"},
+		{name: "CNPJ with letters", check: CNPJ, input: "1122233300018A"},
+		{name: "CNPJ empty", check: CNPJ, input: ""},
+		{name: "CPF", check: CPF, input: "52998224725", want: true},
+		{name: "CPF punctuated", check: CPF, input: "529.982.247-25", want: true},
+		{name: "CPF first check digit", check: CPF, input: "52998224735"},
+		{name: "CPF second check digit", check: CPF, input: "52998224724"},
+		{name: "CPF repeated digits", check: CPF, input: "11111111111"},
+		{name: "CPF too short", check: CPF, input: "5299822472"},
+		{name: "CPF as CNPJ", check: CPF, input: "11222333000181"},
+		{name: "CPF empty", check: CPF, input: ""},
+		{name: "NCM", check: NCM, input: "22021000", want: true},
+		{name: "NCM for services", check: NCM, input: "00", want: true},
+		{name: "NCM too short", check: NCM, input: "2202100"},
+		{name: "NCM single zero", check: NCM, input: "0"},
+		{name: "NCM punctuated", check: NCM, input: "2202.10.00"},
+		{name: "CFOP in-state exit", check: CFOP, input: "5102", want: true},
+		{name: "CFOP interstate exit", check: CFOP, input: "6102", want: true},
+		{name: "CFOP entry", check: CFOP, input: "1202", want: true},
+		{name: "CFOP group 4", check: CFOP, input: "4102"},
+		{name: "CFOP group 8", check: CFOP, input: "8102"},
+		{name: "CFOP group 0", check: CFOP, input: "0102"},
+		{name: "CFOP too short", check: CFOP, input: "510"},
+		{name: "UF", check: UF, input: "SP", want: true},
+		{name: "UF lowercase", check: UF, input: "sp"},
+		{name: "UF unknown", check: UF, input: "XX"},
+		{name: "CEP", check: CEP, input: "01310100", want: true},
+		{name: "CEP with hyphen", check: CEP, input: "01310-100", want: true},
+		{name: "CEP too short", check: CEP, input: "0131010"},
+		{name: "CEP with dot", check: CEP, input: "01.310-100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.check(tt.input); got != tt.want {
+				t.Fatalf("check(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// emitente has one field per registered tag
+type emitente struct {
+	CNPJ  string   `validate:"required,cnpj"`
+	CPF   string   `validate:"omitempty,cpf"`
+	NCM   string   `validate:"ncm"`
+	CFOP  string   `validate:"cfop"`
+	UF    string   `validate:"uf"`
+	CEP   string   `validate:"cep"`
+	Nome  string   `validate:"min=2"`
+	Itens []string `validate:"min=1"`
+	Total int      `validate:"max=10"`
+}
+
+func TestRegister(t *testing.T) {
+	v := validator.New()
+	if err := Register(v); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	valid := emitente{
+		CNPJ: "11.222.333/0001-81", NCM: "00", CFOP: "5102", UF: "SP", CEP: "01310-100",
+		Nome: "Loja", Itens: []string{"1"}, Total: 10,
+	}
+	if err := v.Struct(valid); err != nil {
+		t.Fatalf("valid struct: %v", err)
+	}
+
+	invalid := emitente{
+		CNPJ: "11111111111111", CPF: "52998224724", NCM: "123", CFOP: "4102", UF: "XX",
+		CEP: "0131", Nome: "L", Total: 11,
+	}
+	var errs validator.ValidationErrors
+	if !errors.As(v.Struct(invalid), &errs) {
+		t.Fatalf("invalid struct: want validator.ValidationErrors")
+	}
+	want := map[string]string{
+		"CNPJ":  "CNPJ inválido",
+		"CPF":   "CPF inválido",
+		"NCM":   "NCM deve ter 8 dígitos (00 para serviços)",
+		"CFOP":  "CFOP deve ter 4 dígitos, iniciando por 1, 2, 3, 5, 6 ou 7",
+		"UF":    "UF inválida, informe a sigla do estado (ex.: SP)",
+		"CEP":   "CEP deve ter 8 dígitos",
+		"Nome":  "deve ter ao menos 2 caracteres",
+		"Itens": "deve ter ao menos 1 itens",
+		"Total": "deve ser menor ou igual a 10",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for _, fe := range errs {
+		if got := Message(fe); got != want[fe.Field()] {
+			t.Errorf("Message(%s) = %q, want %q", fe.Field(), got, want[fe.Field()])
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	type payload struct {
+		Ambiente string `validate:"oneof=producao homologacao"`
+		Serie    string `validate:"len=3"`
+		Chaves   []int  `validate:"len=2"`
+		Nome     string `validate:"required"`
+		Codigo   string `validate:"numeric"`
+		Site     string `validate:"url"`
+		Contato  string `validate:"email"`
+		Prefixo  string `validate:"startswith=NF"`
+	}
+	v := validator.New()
+	var errs validator.ValidationErrors
+	if !errors.As(v.Struct(payload{Codigo: "12a", Site: "x", Contato: "x"}), &errs) {
+		t.Fatalf("want validator.ValidationErrors")
+	}
+	want := map[string]string{
+		"Ambiente": "valor deve ser um de: producao, homologacao",
+		"Serie":    "deve ter exatamente 3 caracteres",
+		"Chaves":   "deve ter exatamente 2 itens",
+		"Nome":     "campo obrigatório",
+		"Codigo":   "deve conter apenas números",
+		"Site":     "URL inválida",
+		"Contato":  "e-mail inválido",
+		"Prefixo":  "valor inválido (regra startswith)",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for _, fe := range errs {
+		if got := Message(fe); got != want[fe.Field()] {
+			t.Errorf("Message(%s) = %q, want %q", fe.Field(), got, want[fe.Field()])
+		}
+	}
+}
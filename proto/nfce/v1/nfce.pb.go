@@ -0,0 +1,1676 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: nfce/v1/nfce.proto
+
+// gRPC contract of the NFC-e emission for on-premise POS gateways. It mirrors the REST API
+// (POST /api/v1/nfce, GET /api/v1/nfce/{id} and GET /api/v1/nfce/stream) and shares its use
+// cases, so an NFC-e emitted here is listed, canceled and downloaded through REST as usual.
+//
+// Calls are authenticated by the company API key in the x-api-key metadata, or as
+// "authorization: Bearer <key>", and count towards the same rate limits of the plan.
+//
+// Amounts are decimal strings with two decimal places ("10.50") and quantities have up to
+// four ("1.2500"), as in the JSON of the REST API.
+//
+// Regenerate the Go code with `make proto`.
+
+package nfcev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Status int32
+
+const (
+	Status_STATUS_UNSPECIFIED          Status = 0
+	Status_STATUS_PENDING              Status = 1
+	Status_STATUS_QUEUED_DEFERRED      Status = 2
+	Status_STATUS_PROCESSING           Status = 3
+	Status_STATUS_AUTHORIZED           Status = 4
+	Status_STATUS_REJECTED             Status = 5
+	Status_STATUS_CONTINGENCY          Status = 6
+	Status_STATUS_RETRYING             Status = 7
+	Status_STATUS_CANCELED             Status = 8
+	Status_STATUS_PENDING_TRANSMISSION Status = 9
+)
+
+// Enum value maps for Status.
+var (
+	Status_name = map[int32]string{
+		0: "STATUS_UNSPECIFIED",
+		1: "STATUS_PENDING",
+		2: "STATUS_QUEUED_DEFERRED",
+		3: "STATUS_PROCESSING",
+		4: "STATUS_AUTHORIZED",
+		5: "STATUS_REJECTED",
+		6: "STATUS_CONTINGENCY",
+		7: "STATUS_RETRYING",
+		8: "STATUS_CANCELED",
+		9: "STATUS_PENDING_TRANSMISSION",
+	}
+	Status_value = map[string]int32{
+		"STATUS_UNSPECIFIED":          0,
+		"STATUS_PENDING":              1,
+		"STATUS_QUEUED_DEFERRED":      2,
+		"STATUS_PROCESSING":           3,
+		"STATUS_AUTHORIZED":           4,
+		"STATUS_REJECTED":             5,
+		"STATUS_CONTINGENCY":          6,
+		"STATUS_RETRYING":             7,
+		"STATUS_CANCELED":             8,
+		"STATUS_PENDING_TRANSMISSION": 9,
+	}
+)
+
+func (x Status) Enum() *Status {
+	p := new(Status)
+	*p = x
+	return p
+}
+
+func (x Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_nfce_v1_nfce_proto_enumTypes[0].Descriptor()
+}
+
+func (Status) Type() protoreflect.EnumType {
+	return &file_nfce_v1_nfce_proto_enumTypes[0]
+}
+
+func (x Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Status.Descriptor instead.
+func (Status) EnumDescriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{0}
+}
+
+type EmitNFCeRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	IdempotencyKey string                 `protobuf:"bytes,1,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	Uf             string                 `protobuf:"bytes,2,opt,name=uf,proto3" json:"uf,omitempty"`
+	// producao, homologacao or simulado (answered by the in-process fake SEFAZ)
+	Ambiente string `protobuf:"bytes,3,opt,name=ambiente,proto3" json:"ambiente,omitempty"`
+	// The default series of the company when unset
+	Serie      *int32       `protobuf:"varint,4,opt,name=serie,proto3,oneof" json:"serie,omitempty"`
+	Emitente   *Emitente    `protobuf:"bytes,5,opt,name=emitente,proto3" json:"emitente,omitempty"`
+	Itens      []*Item      `protobuf:"bytes,6,rep,name=itens,proto3" json:"itens,omitempty"`
+	Pagamentos []*Payment   `protobuf:"bytes,7,rep,name=pagamentos,proto3" json:"pagamentos,omitempty"`
+	Options    *EmitOptions `protobuf:"bytes,8,opt,name=options,proto3" json:"options,omitempty"`
+	// Echoed back in the responses and webhooks for the integrator correlation
+	Metadata      map[string]string `protobuf:"bytes,9,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Destinatario  *Destinatario     `protobuf:"bytes,10,opt,name=destinatario,proto3" json:"destinatario,omitempty"`
+	Intermediador *Intermediador    `protobuf:"bytes,11,opt,name=intermediador,proto3" json:"intermediador,omitempty"`
+	// Point of sale (PDV), used by the anti-fraud velocity check
+	Terminal string `protobuf:"bytes,12,opt,name=terminal,proto3" json:"terminal,omitempty"`
+	// Freight of the sale (vFrete), apportioned among the items by value
+	Frete         string `protobuf:"bytes,13,opt,name=frete,proto3" json:"frete,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmitNFCeRequest) Reset() {
+	*x = EmitNFCeRequest{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmitNFCeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmitNFCeRequest) ProtoMessage() {}
+
+func (x *EmitNFCeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmitNFCeRequest.ProtoReflect.Descriptor instead.
+func (*EmitNFCeRequest) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *EmitNFCeRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *EmitNFCeRequest) GetUf() string {
+	if x != nil {
+		return x.Uf
+	}
+	return ""
+}
+
+func (x *EmitNFCeRequest) GetAmbiente() string {
+	if x != nil {
+		return x.Ambiente
+	}
+	return ""
+}
+
+func (x *EmitNFCeRequest) GetSerie() int32 {
+	if x != nil && x.Serie != nil {
+		return *x.Serie
+	}
+	return 0
+}
+
+func (x *EmitNFCeRequest) GetEmitente() *Emitente {
+	if x != nil {
+		return x.Emitente
+	}
+	return nil
+}
+
+func (x *EmitNFCeRequest) GetItens() []*Item {
+	if x != nil {
+		return x.Itens
+	}
+	return nil
+}
+
+func (x *EmitNFCeRequest) GetPagamentos() []*Payment {
+	if x != nil {
+		return x.Pagamentos
+	}
+	return nil
+}
+
+func (x *EmitNFCeRequest) GetOptions() *EmitOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *EmitNFCeRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *EmitNFCeRequest) GetDestinatario() *Destinatario {
+	if x != nil {
+		return x.Destinatario
+	}
+	return nil
+}
+
+func (x *EmitNFCeRequest) GetIntermediador() *Intermediador {
+	if x != nil {
+		return x.Intermediador
+	}
+	return nil
+}
+
+func (x *EmitNFCeRequest) GetTerminal() string {
+	if x != nil {
+		return x.Terminal
+	}
+	return ""
+}
+
+func (x *EmitNFCeRequest) GetFrete() string {
+	if x != nil {
+		return x.Frete
+	}
+	return ""
+}
+
+type Emitente struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cnpj          string                 `protobuf:"bytes,1,opt,name=cnpj,proto3" json:"cnpj,omitempty"`
+	Ie            string                 `protobuf:"bytes,2,opt,name=ie,proto3" json:"ie,omitempty"`
+	Regime        string                 `protobuf:"bytes,3,opt,name=regime,proto3" json:"regime,omitempty"`
+	CscId         string                 `protobuf:"bytes,4,opt,name=csc_id,json=cscId,proto3" json:"csc_id,omitempty"`
+	CscToken      string                 `protobuf:"bytes,5,opt,name=csc_token,json=cscToken,proto3" json:"csc_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Emitente) Reset() {
+	*x = Emitente{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Emitente) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Emitente) ProtoMessage() {}
+
+func (x *Emitente) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Emitente.ProtoReflect.Descriptor instead.
+func (*Emitente) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Emitente) GetCnpj() string {
+	if x != nil {
+		return x.Cnpj
+	}
+	return ""
+}
+
+func (x *Emitente) GetIe() string {
+	if x != nil {
+		return x.Ie
+	}
+	return ""
+}
+
+func (x *Emitente) GetRegime() string {
+	if x != nil {
+		return x.Regime
+	}
+	return ""
+}
+
+func (x *Emitente) GetCscId() string {
+	if x != nil {
+		return x.CscId
+	}
+	return ""
+}
+
+func (x *Emitente) GetCscToken() string {
+	if x != nil {
+		return x.CscToken
+	}
+	return ""
+}
+
+type Item struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Descricao     string                 `protobuf:"bytes,1,opt,name=descricao,proto3" json:"descricao,omitempty"`
+	Ncm           string                 `protobuf:"bytes,2,opt,name=ncm,proto3" json:"ncm,omitempty"`
+	Cest          string                 `protobuf:"bytes,3,opt,name=cest,proto3" json:"cest,omitempty"`
+	Cfop          string                 `protobuf:"bytes,4,opt,name=cfop,proto3" json:"cfop,omitempty"`
+	Gtin          string                 `protobuf:"bytes,5,opt,name=gtin,proto3" json:"gtin,omitempty"`
+	Valor         string                 `protobuf:"bytes,6,opt,name=valor,proto3" json:"valor,omitempty"`
+	Quantidade    string                 `protobuf:"bytes,7,opt,name=quantidade,proto3" json:"quantidade,omitempty"`
+	Unidade       string                 `protobuf:"bytes,8,opt,name=unidade,proto3" json:"unidade,omitempty"`
+	Desconto      string                 `protobuf:"bytes,9,opt,name=desconto,proto3" json:"desconto,omitempty"`
+	Outros        string                 `protobuf:"bytes,10,opt,name=outros,proto3" json:"outros,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Item) Reset() {
+	*x = Item{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Item) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Item) ProtoMessage() {}
+
+func (x *Item) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Item.ProtoReflect.Descriptor instead.
+func (*Item) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Item) GetDescricao() string {
+	if x != nil {
+		return x.Descricao
+	}
+	return ""
+}
+
+func (x *Item) GetNcm() string {
+	if x != nil {
+		return x.Ncm
+	}
+	return ""
+}
+
+func (x *Item) GetCest() string {
+	if x != nil {
+		return x.Cest
+	}
+	return ""
+}
+
+func (x *Item) GetCfop() string {
+	if x != nil {
+		return x.Cfop
+	}
+	return ""
+}
+
+func (x *Item) GetGtin() string {
+	if x != nil {
+		return x.Gtin
+	}
+	return ""
+}
+
+func (x *Item) GetValor() string {
+	if x != nil {
+		return x.Valor
+	}
+	return ""
+}
+
+func (x *Item) GetQuantidade() string {
+	if x != nil {
+		return x.Quantidade
+	}
+	return ""
+}
+
+func (x *Item) GetUnidade() string {
+	if x != nil {
+		return x.Unidade
+	}
+	return ""
+}
+
+func (x *Item) GetDesconto() string {
+	if x != nil {
+		return x.Desconto
+	}
+	return ""
+}
+
+func (x *Item) GetOutros() string {
+	if x != nil {
+		return x.Outros
+	}
+	return ""
+}
+
+type Payment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Forma         string                 `protobuf:"bytes,1,opt,name=forma,proto3" json:"forma,omitempty"`
+	Valor         string                 `protobuf:"bytes,2,opt,name=valor,proto3" json:"valor,omitempty"`
+	Troco         string                 `protobuf:"bytes,3,opt,name=troco,proto3" json:"troco,omitempty"`
+	Cartao        *CardPayment           `protobuf:"bytes,4,opt,name=cartao,proto3" json:"cartao,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Payment) Reset() {
+	*x = Payment{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Payment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Payment) ProtoMessage() {}
+
+func (x *Payment) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Payment.ProtoReflect.Descriptor instead.
+func (*Payment) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Payment) GetForma() string {
+	if x != nil {
+		return x.Forma
+	}
+	return ""
+}
+
+func (x *Payment) GetValor() string {
+	if x != nil {
+		return x.Valor
+	}
+	return ""
+}
+
+func (x *Payment) GetTroco() string {
+	if x != nil {
+		return x.Troco
+	}
+	return ""
+}
+
+func (x *Payment) GetCartao() *CardPayment {
+	if x != nil {
+		return x.Cartao
+	}
+	return nil
+}
+
+type CardPayment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 1 integrated with the PDV (TEF), 2 standalone POS
+	TpIntegra     string `protobuf:"bytes,1,opt,name=tp_integra,json=tpIntegra,proto3" json:"tp_integra,omitempty"`
+	Cnpj          string `protobuf:"bytes,2,opt,name=cnpj,proto3" json:"cnpj,omitempty"`
+	Bandeira      string `protobuf:"bytes,3,opt,name=bandeira,proto3" json:"bandeira,omitempty"`
+	Autorizacao   string `protobuf:"bytes,4,opt,name=autorizacao,proto3" json:"autorizacao,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CardPayment) Reset() {
+	*x = CardPayment{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CardPayment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CardPayment) ProtoMessage() {}
+
+func (x *CardPayment) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CardPayment.ProtoReflect.Descriptor instead.
+func (*CardPayment) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CardPayment) GetTpIntegra() string {
+	if x != nil {
+		return x.TpIntegra
+	}
+	return ""
+}
+
+func (x *CardPayment) GetCnpj() string {
+	if x != nil {
+		return x.Cnpj
+	}
+	return ""
+}
+
+func (x *CardPayment) GetBandeira() string {
+	if x != nil {
+		return x.Bandeira
+	}
+	return ""
+}
+
+func (x *CardPayment) GetAutorizacao() string {
+	if x != nil {
+		return x.Autorizacao
+	}
+	return ""
+}
+
+type EmitOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Contingencia  bool                   `protobuf:"varint,1,opt,name=contingencia,proto3" json:"contingencia,omitempty"`
+	Sync          bool                   `protobuf:"varint,2,opt,name=sync,proto3" json:"sync,omitempty"`
+	DanfeDuasVias bool                   `protobuf:"varint,3,opt,name=danfe_duas_vias,json=danfeDuasVias,proto3" json:"danfe_duas_vias,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmitOptions) Reset() {
+	*x = EmitOptions{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmitOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmitOptions) ProtoMessage() {}
+
+func (x *EmitOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmitOptions.ProtoReflect.Descriptor instead.
+func (*EmitOptions) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EmitOptions) GetContingencia() bool {
+	if x != nil {
+		return x.Contingencia
+	}
+	return false
+}
+
+func (x *EmitOptions) GetSync() bool {
+	if x != nil {
+		return x.Sync
+	}
+	return false
+}
+
+func (x *EmitOptions) GetDanfeDuasVias() bool {
+	if x != nil {
+		return x.DanfeDuasVias
+	}
+	return false
+}
+
+type Destinatario struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cpf           string                 `protobuf:"bytes,1,opt,name=cpf,proto3" json:"cpf,omitempty"`
+	Cnpj          string                 `protobuf:"bytes,2,opt,name=cnpj,proto3" json:"cnpj,omitempty"`
+	Nome          string                 `protobuf:"bytes,3,opt,name=nome,proto3" json:"nome,omitempty"`
+	Email         string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Destinatario) Reset() {
+	*x = Destinatario{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Destinatario) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Destinatario) ProtoMessage() {}
+
+func (x *Destinatario) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Destinatario.ProtoReflect.Descriptor instead.
+func (*Destinatario) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Destinatario) GetCpf() string {
+	if x != nil {
+		return x.Cpf
+	}
+	return ""
+}
+
+func (x *Destinatario) GetCnpj() string {
+	if x != nil {
+		return x.Cnpj
+	}
+	return ""
+}
+
+func (x *Destinatario) GetNome() string {
+	if x != nil {
+		return x.Nome
+	}
+	return ""
+}
+
+func (x *Destinatario) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+type Intermediador struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Alias         string                 `protobuf:"bytes,1,opt,name=alias,proto3" json:"alias,omitempty"`
+	Cnpj          string                 `protobuf:"bytes,2,opt,name=cnpj,proto3" json:"cnpj,omitempty"`
+	Nome          string                 `protobuf:"bytes,3,opt,name=nome,proto3" json:"nome,omitempty"`
+	IdCadIntTran  string                 `protobuf:"bytes,4,opt,name=id_cad_int_tran,json=idCadIntTran,proto3" json:"id_cad_int_tran,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Intermediador) Reset() {
+	*x = Intermediador{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Intermediador) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Intermediador) ProtoMessage() {}
+
+func (x *Intermediador) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Intermediador.ProtoReflect.Descriptor instead.
+func (*Intermediador) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Intermediador) GetAlias() string {
+	if x != nil {
+		return x.Alias
+	}
+	return ""
+}
+
+func (x *Intermediador) GetCnpj() string {
+	if x != nil {
+		return x.Cnpj
+	}
+	return ""
+}
+
+func (x *Intermediador) GetNome() string {
+	if x != nil {
+		return x.Nome
+	}
+	return ""
+}
+
+func (x *Intermediador) GetIdCadIntTran() string {
+	if x != nil {
+		return x.IdCadIntTran
+	}
+	return ""
+}
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetStatusRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type NFCe struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Id               string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	IdempotencyKey   string                 `protobuf:"bytes,2,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	Status           Status                 `protobuf:"varint,3,opt,name=status,proto3,enum=plugnfce.nfce.v1.Status" json:"status,omitempty"`
+	ChaveAcesso      string                 `protobuf:"bytes,4,opt,name=chave_acesso,json=chaveAcesso,proto3" json:"chave_acesso,omitempty"`
+	Protocolo        string                 `protobuf:"bytes,5,opt,name=protocolo,proto3" json:"protocolo,omitempty"`
+	Numero           string                 `protobuf:"bytes,6,opt,name=numero,proto3" json:"numero,omitempty"`
+	Serie            string                 `protobuf:"bytes,7,opt,name=serie,proto3" json:"serie,omitempty"`
+	Valor            string                 `protobuf:"bytes,8,opt,name=valor,proto3" json:"valor,omitempty"`
+	RejectionCode    string                 `protobuf:"bytes,9,opt,name=rejection_code,json=rejectionCode,proto3" json:"rejection_code,omitempty"`
+	RejectionMessage string                 `protobuf:"bytes,10,opt,name=rejection_message,json=rejectionMessage,proto3" json:"rejection_message,omitempty"`
+	// XSD errors of a local rejection
+	SchemaErrors []*SchemaError         `protobuf:"bytes,11,rep,name=schema_errors,json=schemaErrors,proto3" json:"schema_errors,omitempty"`
+	RetryCount   int32                  `protobuf:"varint,12,opt,name=retry_count,json=retryCount,proto3" json:"retry_count,omitempty"`
+	NextRetryAt  *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=next_retry_at,json=nextRetryAt,proto3" json:"next_retry_at,omitempty"`
+	CreatedAt    *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt    *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Links        *Links                 `protobuf:"bytes,16,opt,name=links,proto3" json:"links,omitempty"`
+	// When the XML, DANFE and QR Code were deleted by the plan retention
+	DocumentsPurgedAt *timestamppb.Timestamp `protobuf:"bytes,17,opt,name=documents_purged_at,json=documentsPurgedAt,proto3" json:"documents_purged_at,omitempty"`
+	Metadata          map[string]string      `protobuf:"bytes,18,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Accepted while the broker was unavailable, processed once it recovers
+	DelayedProcessing bool   `protobuf:"varint,19,opt,name=delayed_processing,json=delayedProcessing,proto3" json:"delayed_processing,omitempty"`
+	Notice            string `protobuf:"bytes,20,opt,name=notice,proto3" json:"notice,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *NFCe) Reset() {
+	*x = NFCe{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NFCe) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NFCe) ProtoMessage() {}
+
+func (x *NFCe) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NFCe.ProtoReflect.Descriptor instead.
+func (*NFCe) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *NFCe) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *NFCe) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *NFCe) GetStatus() Status {
+	if x != nil {
+		return x.Status
+	}
+	return Status_STATUS_UNSPECIFIED
+}
+
+func (x *NFCe) GetChaveAcesso() string {
+	if x != nil {
+		return x.ChaveAcesso
+	}
+	return ""
+}
+
+func (x *NFCe) GetProtocolo() string {
+	if x != nil {
+		return x.Protocolo
+	}
+	return ""
+}
+
+func (x *NFCe) GetNumero() string {
+	if x != nil {
+		return x.Numero
+	}
+	return ""
+}
+
+func (x *NFCe) GetSerie() string {
+	if x != nil {
+		return x.Serie
+	}
+	return ""
+}
+
+func (x *NFCe) GetValor() string {
+	if x != nil {
+		return x.Valor
+	}
+	return ""
+}
+
+func (x *NFCe) GetRejectionCode() string {
+	if x != nil {
+		return x.RejectionCode
+	}
+	return ""
+}
+
+func (x *NFCe) GetRejectionMessage() string {
+	if x != nil {
+		return x.RejectionMessage
+	}
+	return ""
+}
+
+func (x *NFCe) GetSchemaErrors() []*SchemaError {
+	if x != nil {
+		return x.SchemaErrors
+	}
+	return nil
+}
+
+func (x *NFCe) GetRetryCount() int32 {
+	if x != nil {
+		return x.RetryCount
+	}
+	return 0
+}
+
+func (x *NFCe) GetNextRetryAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NextRetryAt
+	}
+	return nil
+}
+
+func (x *NFCe) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *NFCe) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *NFCe) GetLinks() *Links {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *NFCe) GetDocumentsPurgedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DocumentsPurgedAt
+	}
+	return nil
+}
+
+func (x *NFCe) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *NFCe) GetDelayedProcessing() bool {
+	if x != nil {
+		return x.DelayedProcessing
+	}
+	return false
+}
+
+func (x *NFCe) GetNotice() string {
+	if x != nil {
+		return x.Notice
+	}
+	return ""
+}
+
+type SchemaError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Field         string                 `protobuf:"bytes,2,opt,name=field,proto3" json:"field,omitempty"`
+	Line          int32                  `protobuf:"varint,3,opt,name=line,proto3" json:"line,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Detail        string                 `protobuf:"bytes,5,opt,name=detail,proto3" json:"detail,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SchemaError) Reset() {
+	*x = SchemaError{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SchemaError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SchemaError) ProtoMessage() {}
+
+func (x *SchemaError) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SchemaError.ProtoReflect.Descriptor instead.
+func (*SchemaError) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SchemaError) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *SchemaError) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *SchemaError) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *SchemaError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *SchemaError) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+type Links struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Xml           string                 `protobuf:"bytes,1,opt,name=xml,proto3" json:"xml,omitempty"`
+	Pdf           string                 `protobuf:"bytes,2,opt,name=pdf,proto3" json:"pdf,omitempty"`
+	QrCode        string                 `protobuf:"bytes,3,opt,name=qr_code,json=qrCode,proto3" json:"qr_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Links) Reset() {
+	*x = Links{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Links) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Links) ProtoMessage() {}
+
+func (x *Links) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Links.ProtoReflect.Descriptor instead.
+func (*Links) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Links) GetXml() string {
+	if x != nil {
+		return x.Xml
+	}
+	return ""
+}
+
+func (x *Links) GetPdf() string {
+	if x != nil {
+		return x.Pdf
+	}
+	return ""
+}
+
+func (x *Links) GetQrCode() string {
+	if x != nil {
+		return x.QrCode
+	}
+	return ""
+}
+
+type WatchStatusRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Resumes after this event, the id of the last StatusEvent received
+	LastEventId string `protobuf:"bytes,1,opt,name=last_event_id,json=lastEventId,proto3" json:"last_event_id,omitempty"`
+	// Only the events of these NFC-e; every NFC-e of the company when empty
+	RequestIds    []string `protobuf:"bytes,2,rep,name=request_ids,json=requestIds,proto3" json:"request_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchStatusRequest) Reset() {
+	*x = WatchStatusRequest{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchStatusRequest) ProtoMessage() {}
+
+func (x *WatchStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchStatusRequest.ProtoReflect.Descriptor instead.
+func (*WatchStatusRequest) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *WatchStatusRequest) GetLastEventId() string {
+	if x != nil {
+		return x.LastEventId
+	}
+	return ""
+}
+
+func (x *WatchStatusRequest) GetRequestIds() []string {
+	if x != nil {
+		return x.RequestIds
+	}
+	return nil
+}
+
+type WatchStatusResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*WatchStatusResponse_Status
+	//	*WatchStatusResponse_Resync
+	Event         isWatchStatusResponse_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchStatusResponse) Reset() {
+	*x = WatchStatusResponse{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchStatusResponse) ProtoMessage() {}
+
+func (x *WatchStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchStatusResponse.ProtoReflect.Descriptor instead.
+func (*WatchStatusResponse) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *WatchStatusResponse) GetEvent() isWatchStatusResponse_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *WatchStatusResponse) GetStatus() *StatusEvent {
+	if x != nil {
+		if x, ok := x.Event.(*WatchStatusResponse_Status); ok {
+			return x.Status
+		}
+	}
+	return nil
+}
+
+func (x *WatchStatusResponse) GetResync() *Resync {
+	if x != nil {
+		if x, ok := x.Event.(*WatchStatusResponse_Resync); ok {
+			return x.Resync
+		}
+	}
+	return nil
+}
+
+type isWatchStatusResponse_Event interface {
+	isWatchStatusResponse_Event()
+}
+
+type WatchStatusResponse_Status struct {
+	Status *StatusEvent `protobuf:"bytes,1,opt,name=status,proto3,oneof"`
+}
+
+type WatchStatusResponse_Resync struct {
+	Resync *Resync `protobuf:"bytes,2,opt,name=resync,proto3,oneof"`
+}
+
+func (*WatchStatusResponse_Status) isWatchStatusResponse_Event() {}
+
+func (*WatchStatusResponse_Resync) isWatchStatusResponse_Event() {}
+
+type StatusEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	StatusFrom    Status                 `protobuf:"varint,3,opt,name=status_from,json=statusFrom,proto3,enum=plugnfce.nfce.v1.Status" json:"status_from,omitempty"`
+	StatusTo      Status                 `protobuf:"varint,4,opt,name=status_to,json=statusTo,proto3,enum=plugnfce.nfce.v1.Status" json:"status_to,omitempty"`
+	Cstat         string                 `protobuf:"bytes,5,opt,name=cstat,proto3" json:"cstat,omitempty"`
+	Message       string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusEvent) Reset() {
+	*x = StatusEvent{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusEvent) ProtoMessage() {}
+
+func (x *StatusEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusEvent.ProtoReflect.Descriptor instead.
+func (*StatusEvent) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *StatusEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *StatusEvent) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *StatusEvent) GetStatusFrom() Status {
+	if x != nil {
+		return x.StatusFrom
+	}
+	return Status_STATUS_UNSPECIFIED
+}
+
+func (x *StatusEvent) GetStatusTo() Status {
+	if x != nil {
+		return x.StatusTo
+	}
+	return Status_STATUS_UNSPECIFIED
+}
+
+func (x *StatusEvent) GetCstat() string {
+	if x != nil {
+		return x.Cstat
+	}
+	return ""
+}
+
+func (x *StatusEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *StatusEvent) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+// The last_event_id could not be resumed; the current state must be fetched again
+type Resync struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Resync) Reset() {
+	*x = Resync{}
+	mi := &file_nfce_v1_nfce_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Resync) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Resync) ProtoMessage() {}
+
+func (x *Resync) ProtoReflect() protoreflect.Message {
+	mi := &file_nfce_v1_nfce_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Resync.ProtoReflect.Descriptor instead.
+func (*Resync) Descriptor() ([]byte, []int) {
+	return file_nfce_v1_nfce_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Resync) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_nfce_v1_nfce_proto protoreflect.FileDescriptor
+
+const file_nfce_v1_nfce_proto_rawDesc = "" +
+	"\n" +
+	"\x12nfce/v1/nfce.proto\x12\x10plugnfce.nfce.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xac\x05\n" +
+	"\x0fEmitNFCeRequest\x12'\n" +
+	"\x0fidempotency_key\x18\x01 \x01(\tR\x0eidempotencyKey\x12\x0e\n" +
+	"\x02uf\x18\x02 \x01(\tR\x02uf\x12\x1a\n" +
+	"\bambiente\x18\x03 \x01(\tR\bambiente\x12\x19\n" +
+	"\x05serie\x18\x04 \x01(\x05H\x00R\x05serie\x88\x01\x01\x126\n" +
+	"\bemitente\x18\x05 \x01(\v2\x1a.plugnfce.nfce.v1.EmitenteR\bemitente\x12,\n" +
+	"\x05itens\x18\x06 \x03(\v2\x16.plugnfce.nfce.v1.ItemR\x05itens\x129\n" +
+	"\n" +
+	"pagamentos\x18\a \x03(\v2\x19.plugnfce.nfce.v1.PaymentR\n" +
+	"pagamentos\x127\n" +
+	"\aoptions\x18\b \x01(\v2\x1d.plugnfce.nfce.v1.EmitOptionsR\aoptions\x12K\n" +
+	"\bmetadata\x18\t \x03(\v2/.plugnfce.nfce.v1.EmitNFCeRequest.MetadataEntryR\bmetadata\x12B\n" +
+	"\fdestinatario\x18\n" +
+	" \x01(\v2\x1e.plugnfce.nfce.v1.DestinatarioR\fdestinatario\x12E\n" +
+	"\rintermediador\x18\v \x01(\v2\x1f.plugnfce.nfce.v1.IntermediadorR\rintermediador\x12\x1a\n" +
+	"\bterminal\x18\f \x01(\tR\bterminal\x12\x14\n" +
+	"\x05frete\x18\r \x01(\tR\x05frete\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\b\n" +
+	"\x06_serie\"z\n" +
+	"\bEmitente\x12\x12\n" +
+	"\x04cnpj\x18\x01 \x01(\tR\x04cnpj\x12\x0e\n" +
+	"\x02ie\x18\x02 \x01(\tR\x02ie\x12\x16\n" +
+	"\x06regime\x18\x03 \x01(\tR\x06regime\x12\x15\n" +
+	"\x06csc_id\x18\x04 \x01(\tR\x05cscId\x12\x1b\n" +
+	"\tcsc_token\x18\x05 \x01(\tR\bcscToken\"\xf6\x01\n" +
+	"\x04Item\x12\x1c\n" +
+	"\tdescricao\x18\x01 \x01(\tR\tdescricao\x12\x10\n" +
+	"\x03ncm\x18\x02 \x01(\tR\x03ncm\x12\x12\n" +
+	"\x04cest\x18\x03 \x01(\tR\x04cest\x12\x12\n" +
+	"\x04cfop\x18\x04 \x01(\tR\x04cfop\x12\x12\n" +
+	"\x04gtin\x18\x05 \x01(\tR\x04gtin\x12\x14\n" +
+	"\x05valor\x18\x06 \x01(\tR\x05valor\x12\x1e\n" +
+	"\n" +
+	"quantidade\x18\a \x01(\tR\n" +
+	"quantidade\x12\x18\n" +
+	"\aunidade\x18\b \x01(\tR\aunidade\x12\x1a\n" +
+	"\bdesconto\x18\t \x01(\tR\bdesconto\x12\x16\n" +
+	"\x06outros\x18\n" +
+	" \x01(\tR\x06outros\"\x82\x01\n" +
+	"\aPayment\x12\x14\n" +
+	"\x05forma\x18\x01 \x01(\tR\x05forma\x12\x14\n" +
+	"\x05valor\x18\x02 \x01(\tR\x05valor\x12\x14\n" +
+	"\x05troco\x18\x03 \x01(\tR\x05troco\x125\n" +
+	"\x06cartao\x18\x04 \x01(\v2\x1d.plugnfce.nfce.v1.CardPaymentR\x06cartao\"~\n" +
+	"\vCardPayment\x12\x1d\n" +
+	"\n" +
+	"tp_integra\x18\x01 \x01(\tR\ttpIntegra\x12\x12\n" +
+	"\x04cnpj\x18\x02 \x01(\tR\x04cnpj\x12\x1a\n" +
+	"\bbandeira\x18\x03 \x01(\tR\bbandeira\x12 \n" +
+	"\vautorizacao\x18\x04 \x01(\tR\vautorizacao\"m\n" +
+	"\vEmitOptions\x12\"\n" +
+	"\fcontingencia\x18\x01 \x01(\bR\fcontingencia\x12\x12\n" +
+	"\x04sync\x18\x02 \x01(\bR\x04sync\x12&\n" +
+	"\x0fdanfe_duas_vias\x18\x03 \x01(\bR\rdanfeDuasVias\"^\n" +
+	"\fDestinatario\x12\x10\n" +
+	"\x03cpf\x18\x01 \x01(\tR\x03cpf\x12\x12\n" +
+	"\x04cnpj\x18\x02 \x01(\tR\x04cnpj\x12\x12\n" +
+	"\x04nome\x18\x03 \x01(\tR\x04nome\x12\x14\n" +
+	"\x05email\x18\x04 \x01(\tR\x05email\"t\n" +
+	"\rIntermediador\x12\x14\n" +
+	"\x05alias\x18\x01 \x01(\tR\x05alias\x12\x12\n" +
+	"\x04cnpj\x18\x02 \x01(\tR\x04cnpj\x12\x12\n" +
+	"\x04nome\x18\x03 \x01(\tR\x04nome\x12%\n" +
+	"\x0fid_cad_int_tran\x18\x04 \x01(\tR\fidCadIntTran\"\"\n" +
+	"\x10GetStatusRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xa6\a\n" +
+	"\x04NFCe\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12'\n" +
+	"\x0fidempotency_key\x18\x02 \x01(\tR\x0eidempotencyKey\x120\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x18.plugnfce.nfce.v1.StatusR\x06status\x12!\n" +
+	"\fchave_acesso\x18\x04 \x01(\tR\vchaveAcesso\x12\x1c\n" +
+	"\tprotocolo\x18\x05 \x01(\tR\tprotocolo\x12\x16\n" +
+	"\x06numero\x18\x06 \x01(\tR\x06numero\x12\x14\n" +
+	"\x05serie\x18\a \x01(\tR\x05serie\x12\x14\n" +
+	"\x05valor\x18\b \x01(\tR\x05valor\x12%\n" +
+	"\x0erejection_code\x18\t \x01(\tR\rrejectionCode\x12+\n" +
+	"\x11rejection_message\x18\n" +
+	" \x01(\tR\x10rejectionMessage\x12B\n" +
+	"\rschema_errors\x18\v \x03(\v2\x1d.plugnfce.nfce.v1.SchemaErrorR\fschemaErrors\x12\x1f\n" +
+	"\vretry_count\x18\f \x01(\x05R\n" +
+	"retryCount\x12>\n" +
+	"\rnext_retry_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\vnextRetryAt\x129\n" +
+	"\n" +
+	"created_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12-\n" +
+	"\x05links\x18\x10 \x01(\v2\x17.plugnfce.nfce.v1.LinksR\x05links\x12J\n" +
+	"\x13documents_purged_at\x18\x11 \x01(\v2\x1a.google.protobuf.TimestampR\x11documentsPurgedAt\x12@\n" +
+	"\bmetadata\x18\x12 \x03(\v2$.plugnfce.nfce.v1.NFCe.MetadataEntryR\bmetadata\x12-\n" +
+	"\x12delayed_processing\x18\x13 \x01(\bR\x11delayedProcessing\x12\x16\n" +
+	"\x06notice\x18\x14 \x01(\tR\x06notice\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"}\n" +
+	"\vSchemaError\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x14\n" +
+	"\x05field\x18\x02 \x01(\tR\x05field\x12\x12\n" +
+	"\x04line\x18\x03 \x01(\x05R\x04line\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12\x16\n" +
+	"\x06detail\x18\x05 \x01(\tR\x06detail\"D\n" +
+	"\x05Links\x12\x10\n" +
+	"\x03xml\x18\x01 \x01(\tR\x03xml\x12\x10\n" +
+	"\x03pdf\x18\x02 \x01(\tR\x03pdf\x12\x17\n" +
+	"\aqr_code\x18\x03 \x01(\tR\x06qrCode\"Y\n" +
+	"\x12WatchStatusRequest\x12\"\n" +
+	"\rlast_event_id\x18\x01 \x01(\tR\vlastEventId\x12\x1f\n" +
+	"\vrequest_ids\x18\x02 \x03(\tR\n" +
+	"requestIds\"\x8b\x01\n" +
+	"\x13WatchStatusResponse\x127\n" +
+	"\x06status\x18\x01 \x01(\v2\x1d.plugnfce.nfce.v1.StatusEventH\x00R\x06status\x122\n" +
+	"\x06resync\x18\x02 \x01(\v2\x18.plugnfce.nfce.v1.ResyncH\x00R\x06resyncB\a\n" +
+	"\x05event\"\x99\x02\n" +
+	"\vStatusEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x129\n" +
+	"\vstatus_from\x18\x03 \x01(\x0e2\x18.plugnfce.nfce.v1.StatusR\n" +
+	"statusFrom\x125\n" +
+	"\tstatus_to\x18\x04 \x01(\x0e2\x18.plugnfce.nfce.v1.StatusR\bstatusTo\x12\x14\n" +
+	"\x05cstat\x18\x05 \x01(\tR\x05cstat\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\"\n" +
+	"\x06Resync\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage*\xf6\x01\n" +
+	"\x06Status\x12\x16\n" +
+	"\x12STATUS_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eSTATUS_PENDING\x10\x01\x12\x1a\n" +
+	"\x16STATUS_QUEUED_DEFERRED\x10\x02\x12\x15\n" +
+	"\x11STATUS_PROCESSING\x10\x03\x12\x15\n" +
+	"\x11STATUS_AUTHORIZED\x10\x04\x12\x13\n" +
+	"\x0fSTATUS_REJECTED\x10\x05\x12\x16\n" +
+	"\x12STATUS_CONTINGENCY\x10\x06\x12\x13\n" +
+	"\x0fSTATUS_RETRYING\x10\a\x12\x13\n" +
+	"\x0fSTATUS_CANCELED\x10\b\x12\x1f\n" +
+	"\x1bSTATUS_PENDING_TRANSMISSION\x10\t2\xfb\x01\n" +
+	"\vNFCeService\x12E\n" +
+	"\bEmitNFCe\x12!.plugnfce.nfce.v1.EmitNFCeRequest\x1a\x16.plugnfce.nfce.v1.NFCe\x12G\n" +
+	"\tGetStatus\x12\".plugnfce.nfce.v1.GetStatusRequest\x1a\x16.plugnfce.nfce.v1.NFCe\x12\\\n" +
+	"\vWatchStatus\x12$.plugnfce.nfce.v1.WatchStatusRequest\x1a%.plugnfce.nfce.v1.WatchStatusResponse0\x01BCZAgithub.com/joaopaulo-bertoncini/plugnfce-api/proto/nfce/v1;nfcev1b\x06proto3"
+
+var (
+	file_nfce_v1_nfce_proto_rawDescOnce sync.Once
+	file_nfce_v1_nfce_proto_rawDescData []byte
+)
+
+func file_nfce_v1_nfce_proto_rawDescGZIP() []byte {
+	file_nfce_v1_nfce_proto_rawDescOnce.Do(func() {
+		file_nfce_v1_nfce_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_nfce_v1_nfce_proto_rawDesc), len(file_nfce_v1_nfce_proto_rawDesc)))
+	})
+	return file_nfce_v1_nfce_proto_rawDescData
+}
+
+var file_nfce_v1_nfce_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_nfce_v1_nfce_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_nfce_v1_nfce_proto_goTypes = []any{
+	(Status)(0),                   // 0: plugnfce.nfce.v1.Status
+	(*EmitNFCeRequest)(nil),       // 1: plugnfce.nfce.v1.EmitNFCeRequest
+	(*Emitente)(nil),              // 2: plugnfce.nfce.v1.Emitente
+	(*Item)(nil),                  // 3: plugnfce.nfce.v1.Item
+	(*Payment)(nil),               // 4: plugnfce.nfce.v1.Payment
+	(*CardPayment)(nil),           // 5: plugnfce.nfce.v1.CardPayment
+	(*EmitOptions)(nil),           // 6: plugnfce.nfce.v1.EmitOptions
+	(*Destinatario)(nil),          // 7: plugnfce.nfce.v1.Destinatario
+	(*Intermediador)(nil),         // 8: plugnfce.nfce.v1.Intermediador
+	(*GetStatusRequest)(nil),      // 9: plugnfce.nfce.v1.GetStatusRequest
+	(*NFCe)(nil),                  // 10: plugnfce.nfce.v1.NFCe
+	(*SchemaError)(nil),           // 11: plugnfce.nfce.v1.SchemaError
+	(*Links)(nil),                 // 12: plugnfce.nfce.v1.Links
+	(*WatchStatusRequest)(nil),    // 13: plugnfce.nfce.v1.WatchStatusRequest
+	(*WatchStatusResponse)(nil),   // 14: plugnfce.nfce.v1.WatchStatusResponse
+	(*StatusEvent)(nil),           // 15: plugnfce.nfce.v1.StatusEvent
+	(*Resync)(nil),                // 16: plugnfce.nfce.v1.Resync
+	nil,                           // 17: plugnfce.nfce.v1.EmitNFCeRequest.MetadataEntry
+	nil,                           // 18: plugnfce.nfce.v1.NFCe.MetadataEntry
+	(*timestamppb.Timestamp)(nil), // 19: google.protobuf.Timestamp
+}
+var file_nfce_v1_nfce_proto_depIdxs = []int32{
+	2,  // 0: plugnfce.nfce.v1.EmitNFCeRequest.emitente:type_name -> plugnfce.nfce.v1.Emitente
+	3,  // 1: plugnfce.nfce.v1.EmitNFCeRequest.itens:type_name -> plugnfce.nfce.v1.Item
+	4,  // 2: plugnfce.nfce.v1.EmitNFCeRequest.pagamentos:type_name -> plugnfce.nfce.v1.Payment
+	6,  // 3: plugnfce.nfce.v1.EmitNFCeRequest.options:type_name -> plugnfce.nfce.v1.EmitOptions
+	17, // 4: plugnfce.nfce.v1.EmitNFCeRequest.metadata:type_name -> plugnfce.nfce.v1.EmitNFCeRequest.MetadataEntry
+	7,  // 5: plugnfce.nfce.v1.EmitNFCeRequest.destinatario:type_name -> plugnfce.nfce.v1.Destinatario
+	8,  // 6: plugnfce.nfce.v1.EmitNFCeRequest.intermediador:type_name -> plugnfce.nfce.v1.Intermediador
+	5,  // 7: plugnfce.nfce.v1.Payment.cartao:type_name -> plugnfce.nfce.v1.CardPayment
+	0,  // 8: plugnfce.nfce.v1.NFCe.status:type_name -> plugnfce.nfce.v1.Status
+	11, // 9: plugnfce.nfce.v1.NFCe.schema_errors:type_name -> plugnfce.nfce.v1.SchemaError
+	19, // 10: plugnfce.nfce.v1.NFCe.next_retry_at:type_name -> google.protobuf.Timestamp
+	19, // 11: plugnfce.nfce.v1.NFCe.created_at:type_name -> google.protobuf.Timestamp
+	19, // 12: plugnfce.nfce.v1.NFCe.updated_at:type_name -> google.protobuf.Timestamp
+	12, // 13: plugnfce.nfce.v1.NFCe.links:type_name -> plugnfce.nfce.v1.Links
+	19, // 14: plugnfce.nfce.v1.NFCe.documents_purged_at:type_name -> google.protobuf.Timestamp
+	18, // 15: plugnfce.nfce.v1.NFCe.metadata:type_name -> plugnfce.nfce.v1.NFCe.MetadataEntry
+	15, // 16: plugnfce.nfce.v1.WatchStatusResponse.status:type_name -> plugnfce.nfce.v1.StatusEvent
+	16, // 17: plugnfce.nfce.v1.WatchStatusResponse.resync:type_name -> plugnfce.nfce.v1.Resync
+	0,  // 18: plugnfce.nfce.v1.StatusEvent.status_from:type_name -> plugnfce.nfce.v1.Status
+	0,  // 19: plugnfce.nfce.v1.StatusEvent.status_to:type_name -> plugnfce.nfce.v1.Status
+	19, // 20: plugnfce.nfce.v1.StatusEvent.created_at:type_name -> google.protobuf.Timestamp
+	1,  // 21: plugnfce.nfce.v1.NFCeService.EmitNFCe:input_type -> plugnfce.nfce.v1.EmitNFCeRequest
+	9,  // 22: plugnfce.nfce.v1.NFCeService.GetStatus:input_type -> plugnfce.nfce.v1.GetStatusRequest
+	13, // 23: plugnfce.nfce.v1.NFCeService.WatchStatus:input_type -> plugnfce.nfce.v1.WatchStatusRequest
+	10, // 24: plugnfce.nfce.v1.NFCeService.EmitNFCe:output_type -> plugnfce.nfce.v1.NFCe
+	10, // 25: plugnfce.nfce.v1.NFCeService.GetStatus:output_type -> plugnfce.nfce.v1.NFCe
+	14, // 26: plugnfce.nfce.v1.NFCeService.WatchStatus:output_type -> plugnfce.nfce.v1.WatchStatusResponse
+	24, // [24:27] is the sub-list for method output_type
+	21, // [21:24] is the sub-list for method input_type
+	21, // [21:21] is the sub-list for extension type_name
+	21, // [21:21] is the sub-list for extension extendee
+	0,  // [0:21] is the sub-list for field type_name
+}
+
+func init() { file_nfce_v1_nfce_proto_init() }
+func file_nfce_v1_nfce_proto_init() {
+	if File_nfce_v1_nfce_proto != nil {
+		return
+	}
+	file_nfce_v1_nfce_proto_msgTypes[0].OneofWrappers = []any{}
+	file_nfce_v1_nfce_proto_msgTypes[13].OneofWrappers = []any{
+		(*WatchStatusResponse_Status)(nil),
+		(*WatchStatusResponse_Resync)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_nfce_v1_nfce_proto_rawDesc), len(file_nfce_v1_nfce_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_nfce_v1_nfce_proto_goTypes,
+		DependencyIndexes: file_nfce_v1_nfce_proto_depIdxs,
+		EnumInfos:         file_nfce_v1_nfce_proto_enumTypes,
+		MessageInfos:      file_nfce_v1_nfce_proto_msgTypes,
+	}.Build()
+	File_nfce_v1_nfce_proto = out.File
+	file_nfce_v1_nfce_proto_goTypes = nil
+	file_nfce_v1_nfce_proto_depIdxs = nil
+}
@@ -0,0 +1,227 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: nfce/v1/nfce.proto
+
+// gRPC contract of the NFC-e emission for on-premise POS gateways. It mirrors the REST API
+// (POST /api/v1/nfce, GET /api/v1/nfce/{id} and GET /api/v1/nfce/stream) and shares its use
+// cases, so an NFC-e emitted here is listed, canceled and downloaded through REST as usual.
+//
+// Calls are authenticated by the company API key in the x-api-key metadata, or as
+// "authorization: Bearer <key>", and count towards the same rate limits of the plan.
+//
+// Amounts are decimal strings with two decimal places ("10.50") and quantities have up to
+// four ("1.2500"), as in the JSON of the REST API.
+//
+// Regenerate the Go code with `make proto`.
+
+package nfcev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	NFCeService_EmitNFCe_FullMethodName    = "/plugnfce.nfce.v1.NFCeService/EmitNFCe"
+	NFCeService_GetStatus_FullMethodName   = "/plugnfce.nfce.v1.NFCeService/GetStatus"
+	NFCeService_WatchStatus_FullMethodName = "/plugnfce.nfce.v1.NFCeService/WatchStatus"
+)
+
+// NFCeServiceClient is the client API for NFCeService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NFCeServiceClient interface {
+	// Emits an NFC-e. The idempotency_key is required and unique per company: sending it again
+	// answers with the NFC-e already registered with the key, or with its rejection. The call
+	// returns once the NFC-e is queued; the SEFAZ outcome comes through GetStatus or WatchStatus.
+	EmitNFCe(ctx context.Context, in *EmitNFCeRequest, opts ...grpc.CallOption) (*NFCe, error)
+	// Returns the current status of an NFC-e of the company.
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*NFCe, error)
+	// Streams the status changes of the NFC-e of the company until the client cancels or the
+	// server shuts down. With last_event_id the events missed since it are replayed first; when
+	// it cannot be resumed a resync is sent and the client must fetch the current state again.
+	WatchStatus(ctx context.Context, in *WatchStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchStatusResponse], error)
+}
+
+type nFCeServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNFCeServiceClient(cc grpc.ClientConnInterface) NFCeServiceClient {
+	return &nFCeServiceClient{cc}
+}
+
+func (c *nFCeServiceClient) EmitNFCe(ctx context.Context, in *EmitNFCeRequest, opts ...grpc.CallOption) (*NFCe, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NFCe)
+	err := c.cc.Invoke(ctx, NFCeService_EmitNFCe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nFCeServiceClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*NFCe, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NFCe)
+	err := c.cc.Invoke(ctx, NFCeService_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nFCeServiceClient) WatchStatus(ctx context.Context, in *WatchStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchStatusResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NFCeService_ServiceDesc.Streams[0], NFCeService_WatchStatus_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchStatusRequest, WatchStatusResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NFCeService_WatchStatusClient = grpc.ServerStreamingClient[WatchStatusResponse]
+
+// NFCeServiceServer is the server API for NFCeService service.
+// All implementations must embed UnimplementedNFCeServiceServer
+// for forward compatibility.
+type NFCeServiceServer interface {
+	// Emits an NFC-e. The idempotency_key is required and unique per company: sending it again
+	// answers with the NFC-e already registered with the key, or with its rejection. The call
+	// returns once the NFC-e is queued; the SEFAZ outcome comes through GetStatus or WatchStatus.
+	EmitNFCe(context.Context, *EmitNFCeRequest) (*NFCe, error)
+	// Returns the current status of an NFC-e of the company.
+	GetStatus(context.Context, *GetStatusRequest) (*NFCe, error)
+	// Streams the status changes of the NFC-e of the company until the client cancels or the
+	// server shuts down. With last_event_id the events missed since it are replayed first; when
+	// it cannot be resumed a resync is sent and the client must fetch the current state again.
+	WatchStatus(*WatchStatusRequest, grpc.ServerStreamingServer[WatchStatusResponse]) error
+	mustEmbedUnimplementedNFCeServiceServer()
+}
+
+// UnimplementedNFCeServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNFCeServiceServer struct{}
+
+func (UnimplementedNFCeServiceServer) EmitNFCe(context.Context, *EmitNFCeRequest) (*NFCe, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EmitNFCe not implemented")
+}
+func (UnimplementedNFCeServiceServer) GetStatus(context.Context, *GetStatusRequest) (*NFCe, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedNFCeServiceServer) WatchStatus(*WatchStatusRequest, grpc.ServerStreamingServer[WatchStatusResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchStatus not implemented")
+}
+func (UnimplementedNFCeServiceServer) mustEmbedUnimplementedNFCeServiceServer() {}
+func (UnimplementedNFCeServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeNFCeServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NFCeServiceServer will
+// result in compilation errors.
+type UnsafeNFCeServiceServer interface {
+	mustEmbedUnimplementedNFCeServiceServer()
+}
+
+func RegisterNFCeServiceServer(s grpc.ServiceRegistrar, srv NFCeServiceServer) {
+	// If the following call pancis, it indicates UnimplementedNFCeServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NFCeService_ServiceDesc, srv)
+}
+
+func _NFCeService_EmitNFCe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmitNFCeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NFCeServiceServer).EmitNFCe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NFCeService_EmitNFCe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NFCeServiceServer).EmitNFCe(ctx, req.(*EmitNFCeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NFCeService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NFCeServiceServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NFCeService_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NFCeServiceServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NFCeService_WatchStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NFCeServiceServer).WatchStatus(m, &grpc.GenericServerStream[WatchStatusRequest, WatchStatusResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NFCeService_WatchStatusServer = grpc.ServerStreamingServer[WatchStatusResponse]
+
+// NFCeService_ServiceDesc is the grpc.ServiceDesc for NFCeService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NFCeService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "plugnfce.nfce.v1.NFCeService",
+	HandlerType: (*NFCeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "EmitNFCe",
+			Handler:    _NFCeService_EmitNFCe_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _NFCeService_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStatus",
+			Handler:       _NFCeService_WatchStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "nfce/v1/nfce.proto",
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/database/postgres"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/database"
+	"gorm.io/gorm"
+)
+
+func main() {
+	username := flag.String("username", "", "Admin username used to log in")
+	name := flag.String("name", "", "Admin name")
+	email := flag.String("email", "", "Admin e-mail (required when creating)")
+	password := flag.String("password", "", "Admin password, at least 8 characters")
+	flag.Parse()
+
+	if *username == "" || *password == "" {
+		log.Fatal("Missing credentials: use -username and -password")
+	}
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := database.InitDatabase(ctx, cfg.GetDatabaseDSN(), cfg.Env); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	repo := postgres.NewAdminRepository(database.GetDB())
+
+	// An existing admin has the password reset; otherwise the admin is created
+	admin, err := repo.GetByUsername(ctx, *username)
+	switch {
+	case err == nil:
+		if err := admin.SetPassword(*password); err != nil {
+			log.Fatalf("Invalid password: %v", err)
+		}
+		if err := repo.Update(ctx, admin); err != nil {
+			log.Fatalf("Failed to update admin: %v", err)
+		}
+		fmt.Printf("Password of admin %s reset\n", admin.Username)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		admin, err := entity.NewAdmin(*username, *name, *email, *password)
+		if err != nil {
+			log.Fatalf("Invalid admin: %v", err)
+		}
+		if err := repo.Create(ctx, admin); err != nil {
+			log.Fatalf("Failed to create admin: %v", err)
+		}
+		fmt.Printf("Admin %s created\n", admin.Username)
+	default:
+		log.Fatalf("Failed to get admin: %v", err)
+	}
+}
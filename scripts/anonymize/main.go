@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	nfceInfra "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/database"
+	"gorm.io/gorm"
+)
+
+// batchSize is the number of rows rewritten per query page
+const batchSize = 500
+
+func main() {
+	salt := flag.String("salt", "", "Secret used to derive the pseudonyms; the same salt yields the same dataset on every refresh (random when empty)")
+	adminPassword := flag.String("admin-password", "", "Password set for every admin (random when empty)")
+	confirm := flag.Bool("yes", false, "Confirm the in-place rewrite of the configured database")
+	flag.Parse()
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.Env == "production" {
+		log.Fatal("Refusing to anonymize a database with ENV=production")
+	}
+	if !*confirm {
+		log.Fatalf("This irreversibly rewrites database %s at %s:%s; run again with -yes to confirm", cfg.DBName, cfg.DBHost, cfg.DBPort)
+	}
+
+	key := []byte(*salt)
+	if len(key) == 0 {
+		key = []byte(randomHex(32))
+	}
+	password := *adminPassword
+	if password == "" {
+		password = randomHex(16)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	if err := database.InitDatabase(ctx, cfg.GetDatabaseDSN(), cfg.Env); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	a := &anonymizer{key: key, builder: nfceInfra.NewBuilder(nil, nil, nil, nfceInfra.RespTecConfig{})}
+	err = database.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		steps := []struct {
+			name string
+			run  func(*gorm.DB) (int64, error)
+		}{
+			{"companies", a.companies},
+			{"nfce_requests", a.nfceRequests},
+			{"dfe_documents", a.dfeDocuments},
+			{"nfce_inutilizacoes", a.inutilizacoes},
+			{"sefaz_exchanges", a.sefazExchanges},
+			{"webhooks", a.webhooks},
+			{"webhook_deliveries", a.webhookDeliveries},
+			{"subscriptions", a.subscriptions},
+			{"admins", func(tx *gorm.DB) (int64, error) { return a.admins(tx, password) }},
+		}
+		for _, step := range steps {
+			rows, err := step.run(tx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", step.name, err)
+			}
+			fmt.Printf("Anonymized %d rows of %s\n", rows, step.name)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to anonymize database: %v", err)
+	}
+	if *adminPassword == "" {
+		fmt.Printf("Admin password: %s\n", password)
+	}
+}
+
+// anonymizer derives stable pseudonyms with an HMAC of the original value, so a CNPJ
+// is replaced by the same fake CNPJ in the companies, the NFC-e payloads, the access
+// keys and the DF-e documents, keeping every reference between them intact
+type anonymizer struct {
+	key     []byte
+	builder nfceInfra.Builder
+}
+
+// digits derives n pseudo-random digits from the value
+func (a *anonymizer) digits(kind, value string, n int) string {
+	var out strings.Builder
+	for counter := 0; out.Len() < n; counter++ {
+		mac := hmac.New(sha256.New, a.key)
+		fmt.Fprintf(mac, "%s:%d:%s", kind, counter, value)
+		for _, b := range mac.Sum(nil) {
+			if out.Len() == n {
+				break
+			}
+			out.WriteByte('0' + b%10)
+		}
+	}
+	return out.String()
+}
+
+// cnpj maps a CNPJ to a fake one with valid check digits
+func (a *anonymizer) cnpj(value string) string {
+	if value == "" {
+		return ""
+	}
+	base := a.digits("cnpj", value, 12)
+	base += mod11(base)
+	return base + mod11(base)
+}
+
+// cpf maps a consumer CPF to a fake one with valid check digits
+func (a *anonymizer) cpf(value string) string {
+	if value == "" {
+		return ""
+	}
+	base := a.digits("cpf", value, 9)
+	base += cpfDigit(base)
+	return base + cpfDigit(base)
+}
+
+// ie maps an inscrição estadual to digits of the same length, keeping "ISENTO"
+func (a *anonymizer) ie(value string) string {
+	if value == "" || strings.EqualFold(value, "ISENTO") {
+		return value
+	}
+	return a.digits("ie", value, len(value))
+}
+
+// chave replaces the issuer CNPJ of an access key and recomputes its check digit
+func (a *anonymizer) chave(value string) string {
+	if len(value) != 44 {
+		return value
+	}
+	chave := value[:6] + a.cnpj(value[6:20]) + value[20:43]
+	return chave + a.builder.CalculateDV(chave)
+}
+
+// short returns a stable label for the row, used in fake names and e-mails
+func (a *anonymizer) short(id string) string {
+	return a.digits("id", id, 6)
+}
+
+// companies replaces the identification, contact and street of the companies and
+// strips their certificates and CSC tokens; municipality and UF are kept because
+// they drive the SEFAZ endpoints and taxes
+func (a *anonymizer) companies(tx *gorm.DB) (int64, error) {
+	var rows []struct {
+		ID                string
+		CNPJ              string
+		InscricaoEstadual string
+		Intermediadores   entity.Intermediadores
+	}
+	err := tx.Raw("SELECT id, cnpj, COALESCE(inscricao_estadual, '') AS inscricao_estadual, intermediadores FROM companies").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		for i := range row.Intermediadores {
+			row.Intermediadores[i].CNPJ = a.cnpj(row.Intermediadores[i].CNPJ)
+			row.Intermediadores[i].IdCadIntTran = a.idCadIntTran(row.Intermediadores[i].IdCadIntTran)
+		}
+		label := a.short(row.ID)
+		err := tx.Exec(`UPDATE companies SET
+				cnpj = ?, inscricao_estadual = NULLIF(?, ''),
+				razao_social = ?, nome_fantasia = ?, email = ?,
+				endereco_logradouro = 'Rua Exemplo', endereco_complemento = NULL,
+				certificado_pfx_data = NULL, certificado_password = NULL, certificado_subject = NULL,
+				certificado_issuer = NULL, certificado_cnpj = NULL,
+				csc_token = NULL, intermediadores = ?
+			WHERE id = ?`,
+			a.cnpj(row.CNPJ), a.ie(row.InscricaoEstadual),
+			"Empresa "+label+" LTDA", "Loja "+label, "empresa-"+label+"@example.com",
+			row.Intermediadores, row.ID).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(rows)), nil
+}
+
+// idCadIntTran maps the seller identifier at an intermediary
+func (a *anonymizer) idCadIntTran(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "loja-" + a.digits("intermed", value, 8)
+}
+
+// nfceRequests rewrites the emitente, destinatário and intermediador of the payloads and
+// the access keys, and clears the storage URLs: the stored XML and DANFE carry the real data.
+// The state recorded by the events of the requests gets the same changes.
+func (a *anonymizer) nfceRequests(tx *gorm.DB) (int64, error) {
+	var total int64
+	lastID := ""
+	for {
+		var rows []struct {
+			ID          string
+			Payload     entity.EmitPayload
+			ChaveAcesso string
+		}
+		err := tx.Raw(`SELECT id, payload, COALESCE(chave_acesso, '') AS chave_acesso FROM nfce_requests
+			WHERE id::text > ? ORDER BY id::text LIMIT ?`, lastID, batchSize).Scan(&rows).Error
+		if err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		for _, row := range rows {
+			payload := row.Payload
+			payload.Emitente.CNPJ = a.cnpj(payload.Emitente.CNPJ)
+			payload.Emitente.IE = a.ie(payload.Emitente.IE)
+			payload.Emitente.CSCToken = ""
+			if dest := payload.Destinatario; dest != nil {
+				dest.CPF, dest.CNPJ = a.cpf(dest.CPF), a.cnpj(dest.CNPJ)
+				if dest.Nome != "" {
+					dest.Nome = "Consumidor " + a.digits("nome", dest.Nome, 6)
+				}
+				if dest.Email != "" {
+					dest.Email = "consumidor-" + a.digits("email", dest.Email, 6) + "@example.com"
+				}
+			}
+			if payload.Intermediador != nil {
+				payload.Intermediador.CNPJ = a.cnpj(payload.Intermediador.CNPJ)
+				payload.Intermediador.IdCadIntTran = a.idCadIntTran(payload.Intermediador.IdCadIntTran)
+			}
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return total, err
+			}
+
+			err = tx.Exec(`UPDATE nfce_requests SET payload = ?::jsonb, chave_acesso = NULLIF(?, ''),
+					xml_url = NULL, pdf_url = NULL, qrcode_url = NULL, cancel_xml_url = NULL
+				WHERE id = ?`, string(data), a.chave(row.ChaveAcesso), row.ID).Error
+			if err != nil {
+				return total, err
+			}
+
+			// The events carry the state of the request, rewritten the same way
+			err = tx.Exec(`UPDATE nfce_events
+				SET state = (state - 'xml_url' - 'pdf_url' - 'qrcode_url' - 'cancel_xml_url') || jsonb_build_object('chave_acesso', ?::text)
+				WHERE request_id = ? AND state IS NOT NULL`, a.chave(row.ChaveAcesso), row.ID).Error
+			if err != nil {
+				return total, err
+			}
+		}
+		total += int64(len(rows))
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// dfeDocuments maps the third-party issuers the same way as the companies
+func (a *anonymizer) dfeDocuments(tx *gorm.DB) (int64, error) {
+	var total int64
+	lastID := ""
+	for {
+		var rows []struct {
+			ID           string
+			ChaveAcesso  string
+			CNPJEmitente string `gorm:"column:cnpj_emitente"`
+		}
+		err := tx.Raw(`SELECT id, COALESCE(chave_acesso, '') AS chave_acesso, COALESCE(cnpj_emitente, '') AS cnpj_emitente
+			FROM dfe_documents WHERE id::text > ? ORDER BY id::text LIMIT ?`, lastID, batchSize).Scan(&rows).Error
+		if err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		for _, row := range rows {
+			err := tx.Exec(`UPDATE dfe_documents SET chave_acesso = NULLIF(?, ''), cnpj_emitente = NULLIF(?, ''), xml_url = NULL
+				WHERE id = ?`, a.chave(row.ChaveAcesso), a.cnpj(row.CNPJEmitente), row.ID).Error
+			if err != nil {
+				return total, err
+			}
+		}
+		total += int64(len(rows))
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// inutilizacoes clears the URLs of the homologated XMLs, which carry the real CNPJ
+func (a *anonymizer) inutilizacoes(tx *gorm.DB) (int64, error) {
+	result := tx.Exec("UPDATE nfce_inutilizacoes SET xml_url = NULL WHERE xml_url IS NOT NULL")
+	return result.RowsAffected, result.Error
+}
+
+// sefazExchanges drops the archive of the SEFAZ exchanges, whose XML carries the real CNPJ
+// and consumers
+func (a *anonymizer) sefazExchanges(tx *gorm.DB) (int64, error) {
+	result := tx.Exec("DELETE FROM sefaz_exchanges")
+	return result.RowsAffected, result.Error
+}
+
+// webhooks points the endpoints to example.com and rotates the secrets, so staging
+// never delivers to the customers' systems
+func (a *anonymizer) webhooks(tx *gorm.DB) (int64, error) {
+	result := tx.Exec(`UPDATE webhooks SET url = 'https://example.com/webhooks/' || id::text,
+		secret = md5(random()::text || id::text), headers = '{}'::jsonb`)
+	return result.RowsAffected, result.Error
+}
+
+// webhookDeliveries drops the delivered payloads and the customers' responses
+func (a *anonymizer) webhookDeliveries(tx *gorm.DB) (int64, error) {
+	result := tx.Exec("UPDATE webhook_deliveries SET payload = NULL, response_body = NULL")
+	return result.RowsAffected, result.Error
+}
+
+// subscriptions drops the customers and payment methods on the payment gateway, so staging
+// never charges the customers' cards
+func (a *anonymizer) subscriptions(tx *gorm.DB) (int64, error) {
+	result := tx.Exec(`UPDATE subscriptions SET billing_customer_id = NULL, billing_payment_method = NULL
+		WHERE billing_customer_id IS NOT NULL OR billing_payment_method IS NOT NULL`)
+	return result.RowsAffected, result.Error
+}
+
+// admins replaces the names and e-mails of the admins and resets their passwords
+func (a *anonymizer) admins(tx *gorm.DB, password string) (int64, error) {
+	hash, err := entity.HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []string
+	if err := tx.Raw("SELECT id FROM admins").Scan(&ids).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		label := a.short(id)
+		err := tx.Exec("UPDATE admins SET name = ?, username = ?, email = ?, password = ? WHERE id = ?",
+			"Admin "+label, "admin-"+label, "admin-"+label+"@example.com", hash, id).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(ids)), nil
+}
+
+// mod11 returns the CNPJ check digit of the digits (weights 2 to 9 from the right)
+func mod11(digits string) string {
+	total := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		total += int(digits[i]-'0') * (2 + (len(digits)-1-i)%8)
+	}
+	if remainder := total % 11; remainder >= 2 {
+		return fmt.Sprint(11 - remainder)
+	}
+	return "0"
+}
+
+// cpfDigit returns the CPF check digit of the digits (weights 2 upwards from the right)
+func cpfDigit(digits string) string {
+	total := 0
+	for i := range digits {
+		total += int(digits[i]-'0') * (len(digits) + 1 - i)
+	}
+	return fmt.Sprint(total * 10 % 11 % 10)
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		log.Fatalf("Failed to generate random value: %v", err)
+	}
+	return hex.EncodeToString(buf)
+}
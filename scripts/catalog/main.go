@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/database/postgres"
+	nfceInfra "github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/nfce"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/database"
+)
+
+// siscomexNCM is the NCM table published by Siscomex (Tabela_NCM_Vigente_*.json)
+type siscomexNCM struct {
+	Nomenclaturas []struct {
+		Codigo    string `json:"Codigo"`
+		Descricao string `json:"Descricao"`
+		DataFim   string `json:"Data_Fim"`
+	} `json:"Nomenclaturas"`
+}
+
+// ibgeMunicipio is a municipality of the IBGE localidades API
+// (servicodados.ibge.gov.br/api/v1/localidades/municipios)
+type ibgeMunicipio struct {
+	ID   int64  `json:"id"`
+	Nome string `json:"nome"`
+}
+
+func main() {
+	ncmFile := flag.String("ncm", "", "Siscomex NCM table (JSON)")
+	cestFile := flag.String("cest", "", "CEST table of Convênio ICMS 142/2018 (CSV: cest;ncm;descricao)")
+	municipiosFile := flag.String("municipios", "", "IBGE municipality table (JSON of the localidades API)")
+	flag.Parse()
+
+	if *ncmFile == "" && *cestFile == "" && *municipiosFile == "" {
+		log.Fatal("Nothing to import: use -ncm, -cest and/or -municipios")
+	}
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if err := database.InitDatabase(ctx, cfg.GetDatabaseDSN(), cfg.Env); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	repo := postgres.NewCatalogRepository(database.GetDB())
+
+	if *ncmFile != "" {
+		entries, err := readNCMs(*ncmFile)
+		if err != nil {
+			log.Fatalf("Failed to read NCM table: %v", err)
+		}
+		if err := repo.ReplaceNCMs(ctx, entries); err != nil {
+			log.Fatalf("Failed to import NCM table: %v", err)
+		}
+		fmt.Printf("Imported %d NCM codes\n", len(entries))
+	}
+
+	if *cestFile != "" {
+		entries, err := readCESTs(*cestFile)
+		if err != nil {
+			log.Fatalf("Failed to read CEST table: %v", err)
+		}
+		if err := repo.ReplaceCESTs(ctx, entries); err != nil {
+			log.Fatalf("Failed to import CEST table: %v", err)
+		}
+		fmt.Printf("Imported %d CEST/NCM entries\n", len(entries))
+	}
+
+	if *municipiosFile != "" {
+		entries, err := readMunicipios(*municipiosFile)
+		if err != nil {
+			log.Fatalf("Failed to read municipality table: %v", err)
+		}
+		if err := repo.ReplaceMunicipios(ctx, entries); err != nil {
+			log.Fatalf("Failed to import municipality table: %v", err)
+		}
+		fmt.Printf("Imported %d municipalities\n", len(entries))
+	}
+}
+
+// readNCMs keeps the 8-digit codes of the Siscomex table; chapters, headings and
+// subheadings are only grouping levels
+func readNCMs(path string) ([]entity.NCMCatalogEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var table siscomexNCM
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+
+	var entries []entity.NCMCatalogEntry
+	for _, item := range table.Nomenclaturas {
+		codigo := onlyDigits(item.Codigo)
+		if len(codigo) != 8 {
+			continue
+		}
+
+		entry := entity.NCMCatalogEntry{Codigo: codigo, Descricao: strings.TrimLeft(item.Descricao, "- ")}
+		if fim, err := time.Parse("02/01/2006", item.DataFim); err == nil && fim.Year() < 9999 {
+			entry.VigenciaFim = &fim
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readCESTs reads one line per CEST; the NCM column may list several NCMs or
+// prefixes separated by spaces or commas
+func readCESTs(path string) ([]entity.CESTCatalogEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
+
+	seen := map[string]bool{}
+	var entries []entity.CESTCatalogEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 {
+			continue
+		}
+
+		codigo := onlyDigits(record[0])
+		if len(codigo) != 7 {
+			continue // Header or blank line
+		}
+		for _, ncm := range strings.FieldsFunc(record[1], func(r rune) bool { return r == ' ' || r == ',' }) {
+			ncm = onlyDigits(ncm)
+			if ncm == "" || seen[codigo+ncm] {
+				continue
+			}
+			seen[codigo+ncm] = true
+			entries = append(entries, entity.CESTCatalogEntry{Codigo: codigo, NCM: ncm, Descricao: strings.TrimSpace(record[2])})
+		}
+	}
+	return entries, nil
+}
+
+// readMunicipios reads the municipalities of the IBGE localidades API; the UF is taken from
+// the first two digits of the code, as the region nesting of the API is not always filled
+func readMunicipios(path string) ([]entity.MunicipioCatalogEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var table []ibgeMunicipio
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+
+	entries := make([]entity.MunicipioCatalogEntry, 0, len(table))
+	for _, item := range table {
+		codigo := fmt.Sprintf("%07d", item.ID)
+		uf, ok := nfceInfra.GetUF(codigo[:2])
+		if len(codigo) != 7 || !ok {
+			return nil, fmt.Errorf("invalid municipality code %s (%s)", codigo, item.Nome)
+		}
+		entries = append(entries, entity.MunicipioCatalogEntry{
+			Codigo:    codigo,
+			Nome:      item.Nome,
+			UF:        uf,
+			NomeBusca: entity.MunicipioSearchName(item.Nome),
+		})
+	}
+	return entries, nil
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
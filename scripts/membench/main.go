@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/danfe"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/storage"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/money"
+)
+
+// membench measures the memory the worker spends per emission storing the DANFE,
+// comparing the buffered upload with the streamed (io.Pipe) one. Peak heap under
+// concurrency is what bounds how many emissions an instance can run at once.
+func main() {
+	emissions := flag.Int("n", 200, "emissions per mode")
+	concurrency := flag.Int("concurrency", 8, "concurrent emissions")
+	items := flag.Int("items", 30, "items per NFC-e")
+	flag.Parse()
+
+	dir, err := os.MkdirTemp("", "membench")
+	if err != nil {
+		log.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewLocalStorage(dir, "http://localhost/uploads", "bench")
+	if err != nil {
+		log.Fatalf("Failed to create storage: %v", err)
+	}
+
+	renderer := danfe.NewRenderer()
+	nfceRequest := sampleNFCe(*items)
+	opts := danfe.Options{DuasVias: true}
+
+	modes := []struct {
+		name  string
+		store func(ctx context.Context, key string) error
+	}{
+		{"buffered", func(ctx context.Context, key string) error {
+			pdf := renderer.Render(nil, nfceRequest, "chave", opts)
+			_, err := store.UploadFile(ctx, "", key, bytes.NewReader(pdf), "application/pdf")
+			return err
+		}},
+		{"streamed", func(ctx context.Context, key string) error {
+			reader, writer := io.Pipe()
+			go func() {
+				writer.CloseWithError(renderer.RenderTo(writer, nil, nfceRequest, "chave", opts))
+			}()
+			_, err := store.UploadFile(ctx, "", key, reader, "application/pdf")
+			reader.Close()
+			return err
+		}},
+	}
+
+	fmt.Printf("%-10s %12s %12s %14s %10s\n", "mode", "bytes/op", "allocs/op", "peak heap", "ops/s")
+	for _, mode := range modes {
+		result := run(*emissions, *concurrency, mode.store)
+		fmt.Printf("%-10s %12d %12d %14d %10.1f\n", mode.name, result.bytesPerOp, result.allocsPerOp, result.peakHeap, result.opsPerSecond)
+	}
+}
+
+type result struct {
+	bytesPerOp   uint64
+	allocsPerOp  uint64
+	peakHeap     uint64
+	opsPerSecond float64
+}
+
+// run executes the emissions with the given concurrency, sampling the heap in use
+func run(emissions, concurrency int, store func(ctx context.Context, key string) error) result {
+	ctx := context.Background()
+	runtime.GC()
+
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	var peak uint64
+	done := make(chan struct{})
+	go func() {
+		var stats runtime.MemStats
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				if stats.HeapInuse > atomic.LoadUint64(&peak) {
+					atomic.StoreUint64(&peak, stats.HeapInuse)
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				if err := store(ctx, fmt.Sprintf("nfce/bench/pdf/%06d.pdf", n)); err != nil {
+					log.Fatalf("Failed to store DANFE: %v", err)
+				}
+			}
+		}()
+	}
+	for n := 0; n < emissions; n++ {
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(done)
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	return result{
+		bytesPerOp:   (after.TotalAlloc - before.TotalAlloc) / uint64(emissions),
+		allocsPerOp:  (after.Mallocs - before.Mallocs) / uint64(emissions),
+		peakHeap:     atomic.LoadUint64(&peak),
+		opsPerSecond: float64(emissions) / elapsed.Seconds(),
+	}
+}
+
+// sampleNFCe builds an NFC-e with the given number of items
+func sampleNFCe(items int) *entity.NFCE {
+	payload := entity.EmitPayload{
+		UF:       "SP",
+		Ambiente: "homologacao",
+		Emitente: entity.Emitente{CNPJ: "12345678000195", IE: "123456789012", Regime: "simples"},
+	}
+	for i := 0; i < items; i++ {
+		payload.Itens = append(payload.Itens, entity.Item{
+			Descricao:  fmt.Sprintf("Produto de teste %d", i+1),
+			NCM:        "21069090",
+			CFOP:       "5102",
+			Valor:      990, // R$ 9,90
+			Quantidade: money.Units(2),
+			Unidade:    "UN",
+		})
+	}
+	payload.Pagamentos = []entity.Payment{{Forma: "01", Valor: payload.Total()}}
+
+	return &entity.NFCE{ID: "bench", CompanyID: "bench", Payload: payload}
+}
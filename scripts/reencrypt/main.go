@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/config"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/domain/entity"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/secret"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchSize is the number of rows locked and rewritten per transaction
+const batchSize = 200
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Only count the secrets not sealed under the current key")
+	flag.Parse()
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.CertificateEncryptionKey == "" {
+		log.Fatal("CERTIFICATE_ENCRYPTION_KEY is not set: there is no key to encrypt the secrets with")
+	}
+	// CERTIFICATE_ENCRYPTION_PREVIOUS_KEYS is checked by config.Validate
+	previous, _ := cfg.PreviousEncryptionKeys()
+	cipher, err := secret.NewCipher(cfg.CertificateEncryptionKeyID, cfg.CertificateEncryptionKey, previous)
+	if err != nil {
+		log.Fatalf("Invalid CERTIFICATE_ENCRYPTION_KEY: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	if err := database.InitDatabase(ctx, cfg.GetDatabaseDSN(), cfg.Env); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	r := &reencrypter{cipher: cipher, dryRun: *dryRun}
+	db := database.GetDB().WithContext(ctx)
+	steps := []struct {
+		name string
+		run  func(*gorm.DB) (int, error)
+	}{
+		{"companies", r.companies},
+		{"webhooks", r.webhooks},
+	}
+	for _, step := range steps {
+		count, err := step.run(db)
+		if err != nil {
+			// The batches already committed are sealed under the current key; running again
+			// resumes with the remaining ones
+			log.Fatalf("Failed to re-encrypt %s: %v", step.name, err)
+		}
+		if *dryRun {
+			fmt.Printf("Found %d secrets of %s to re-encrypt\n", count, step.name)
+		} else {
+			fmt.Printf("Re-encrypted %d secrets of %s under key %s\n", count, step.name, cfg.CertificateEncryptionKeyID)
+		}
+	}
+}
+
+// reencrypter seals again under the current key the secrets written before encryption or
+// sealed under a previous key, so the previous keys can be dropped
+type reencrypter struct {
+	cipher *secret.Cipher
+	dryRun bool
+}
+
+// companySecrets are the secret columns of a company as stored
+type companySecrets struct {
+	ID       string
+	PFXData  []byte `gorm:"column:certificado_pfx_data"`
+	Password string `gorm:"column:certificado_password"`
+	KMSToken string `gorm:"column:certificado_kms_token"`
+	CSCToken string `gorm:"column:csc_token"`
+	CSCs     []byte `gorm:"column:cscs"`
+}
+
+// companies re-encrypts the PFX, the certificate password, the KMS token and the CSC tokens
+func (r *reencrypter) companies(db *gorm.DB) (int, error) {
+	total := 0
+	for lastID := ""; ; {
+		var rows []companySecrets
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Table("companies").
+				Select(`id, certificado_pfx_data, COALESCE(certificado_password, '') AS certificado_password,
+					COALESCE(certificado_kms_token, '') AS certificado_kms_token, COALESCE(csc_token, '') AS csc_token, cscs`).
+				Where("id > ?", lastID).Order("id").Limit(batchSize).
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Find(&rows).Error
+			if err != nil {
+				return err
+			}
+
+			for _, row := range rows {
+				updates := make(map[string]interface{})
+				if pfx, ok, err := r.rotate(row.PFXData); err != nil {
+					return fmt.Errorf("company %s PFX: %w", row.ID, err)
+				} else if ok {
+					updates["certificado_pfx_data"] = pfx
+				}
+
+				columns := []struct {
+					name   string
+					stored string
+				}{
+					{"certificado_password", row.Password},
+					{"certificado_kms_token", row.KMSToken},
+					{"csc_token", row.CSCToken},
+				}
+				for _, column := range columns {
+					sealed, ok, err := r.rotateString(column.stored)
+					if err != nil {
+						return fmt.Errorf("company %s %s: %w", row.ID, column.name, err)
+					}
+					if ok {
+						updates[column.name] = sealed
+					}
+				}
+
+				cscs, ok, err := r.rotateCSCs(row.CSCs)
+				if err != nil {
+					return fmt.Errorf("company %s cscs: %w", row.ID, err)
+				}
+				if ok {
+					updates["cscs"] = cscs
+				}
+
+				total += len(updates)
+				if len(updates) == 0 || r.dryRun {
+					continue
+				}
+				if err := tx.Table("companies").Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+					return fmt.Errorf("company %s: %w", row.ID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		if len(rows) < batchSize {
+			return total, nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// webhookSecret is the secret column of a webhook as stored
+type webhookSecret struct {
+	ID     string
+	Secret string
+}
+
+// webhooks re-encrypts the HMAC secrets of the webhooks
+func (r *reencrypter) webhooks(db *gorm.DB) (int, error) {
+	total := 0
+	for lastID := ""; ; {
+		var rows []webhookSecret
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Table("webhooks").
+				Select("id, COALESCE(secret, '') AS secret").
+				Where("id > ?", lastID).Order("id").Limit(batchSize).
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Find(&rows).Error
+			if err != nil {
+				return err
+			}
+
+			for _, row := range rows {
+				sealed, ok, err := r.rotateString(row.Secret)
+				if err != nil {
+					return fmt.Errorf("webhook %s: %w", row.ID, err)
+				}
+				if !ok {
+					continue
+				}
+				total++
+				if r.dryRun {
+					continue
+				}
+				if err := tx.Table("webhooks").Where("id = ?", row.ID).Update("secret", sealed).Error; err != nil {
+					return fmt.Errorf("webhook %s: %w", row.ID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		if len(rows) < batchSize {
+			return total, nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// rotate seals a stored binary secret again under the current key; ok is false when it
+// already is
+func (r *reencrypter) rotate(stored []byte) (sealed []byte, ok bool, err error) {
+	if !r.cipher.NeedsReencryption(stored) {
+		return stored, false, nil
+	}
+	plaintext, err := r.cipher.Open(stored)
+	if err != nil {
+		return nil, false, err
+	}
+	sealed, err = r.cipher.Seal(plaintext)
+	if err != nil {
+		return nil, false, err
+	}
+	return sealed, true, nil
+}
+
+// rotateString seals a stored text secret again under the current key; ok is false when it
+// already is
+func (r *reencrypter) rotateString(stored string) (sealed string, ok bool, err error) {
+	if !r.cipher.NeedsReencryption([]byte(stored)) {
+		return stored, false, nil
+	}
+	plaintext, err := r.cipher.OpenString(stored)
+	if err != nil {
+		return "", false, err
+	}
+	sealed, err = r.cipher.SealString(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return sealed, true, nil
+}
+
+// rotateCSCs seals again the CSC tokens kept in the cscs JSONB; ok is false when all of them
+// already are
+func (r *reencrypter) rotateCSCs(stored []byte) (value interface{}, ok bool, err error) {
+	if len(stored) == 0 {
+		return nil, false, nil
+	}
+	var cscs entity.CSCEntries
+	if err := cscs.Scan(stored); err != nil {
+		return nil, false, err
+	}
+
+	for i := range cscs {
+		token, rotated, err := r.rotateString(cscs[i].CSCToken)
+		if err != nil {
+			return nil, false, fmt.Errorf("CSC %s: %w", cscs[i].ID, err)
+		}
+		if rotated {
+			cscs[i].CSCToken = token
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	value, err = cscs.Value()
+	return value, true, err
+}
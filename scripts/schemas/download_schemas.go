@@ -2,38 +2,144 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/joaopaulo-bertoncini/plugnfce-api/internal/infrastructure/sefaz/validator"
+	"github.com/joaopaulo-bertoncini/plugnfce-api/pkg/logger"
+)
+
+// Where the embedded schema packages and their manifests live in the source tree
+const (
+	bundleDir    = "./internal/infrastructure/sefaz/validator/bundle"
+	manifestsDir = "./internal/infrastructure/sefaz/validator/manifests"
 )
 
 func main() {
-	ctx := context.Background()
+	version := flag.String("version", "4.00", "pinned schema version")
+	pack := flag.String("package", "", "schema package, e.g. PL_009_V4 (default: the package of -version)")
+	dir := flag.String("dir", "./internal/infrastructure/sefaz/schemas", "schemas directory")
+	from := flag.String("from", "", "import an official package extracted in this directory into the bundle")
+	download := flag.Bool("download", false, "download the schemas from the SEFAZ portal instead of using the bundle")
+	pin := flag.Bool("pin", false, "with -from, write the checksums of the imported files into the manifest")
+	flag.Parse()
+
+	name := *version
+	if *pack != "" {
+		name = *pack
+	}
+
+	if *from != "" {
+		if err := importBundle(name, *from, *pin); err != nil {
+			log.Fatalf("Failed to import schemas: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
 
 	// Create validator with schemas directory
-	v, err := validator.NewXMLValidator("./internal/infrastructure/sefaz/schemas")
+	v, err := validator.NewXMLValidator(*dir, logger.NewLogrusLogger())
 	if err != nil {
 		log.Fatalf("Failed to create validator: %v", err)
 	}
 
-	fmt.Println("Downloading SEFAZ schemas for NFC-e v4.00...")
+	if *download {
+		fmt.Printf("Downloading SEFAZ schemas %s...\n", name)
+
+		// Download and verify schemas against the pinned manifest
+		if err := v.DownloadSEFAZSchemas(ctx, name); err != nil {
+			log.Fatalf("Failed to download schemas: %v", err)
+		}
+	} else {
+		fmt.Printf("Installing bundled SEFAZ schemas %s...\n", name)
+
+		if err := v.InstallBundledSchemas(name); err != nil {
+			log.Fatalf("Failed to install schemas: %v (use -download to fetch them from the SEFAZ portal)", err)
+		}
+	}
+
+	fmt.Println("Schemas installed successfully!")
 
-	// Download schemas
-	if err := v.DownloadSEFAZSchemas(ctx, "4.00"); err != nil {
-		log.Fatalf("Failed to download schemas: %v", err)
+	status, err := v.SchemaStatus(name)
+	if err != nil {
+		log.Fatalf("Failed to verify schemas: %v", err)
 	}
 
-	fmt.Println("Schemas downloaded successfully!")
+	fmt.Printf("Schemas %s (%s):\n", status.Version, status.Package)
+	for _, file := range status.Files {
+		fmt.Printf("  - %s %s valid=%t\n", file.Name, file.Actual, file.Valid)
+	}
+}
 
-	// List available schemas
-	schemas, err := v.ListAvailableSchemas()
+// importBundle copies the files of the manifest from an extracted package into the bundle,
+// refusing any file that is not an XSD or whose checksum differs from the manifest. With pin
+// the checksums of the files are written into the manifest instead of being checked, which is
+// how a package downloaded from the SEFAZ portal gets pinned.
+func importBundle(name, from string, pin bool) error {
+	manifest, err := validator.LoadManifest(name)
 	if err != nil {
-		log.Fatalf("Failed to list schemas: %v", err)
+		return err
+	}
+
+	contents := make(map[string][]byte, len(manifest.Files))
+	for i, file := range manifest.Files {
+		content, err := os.ReadFile(filepath.Join(from, file.Name))
+		if err != nil {
+			return fmt.Errorf("missing schema %s: %w", file.Name, err)
+		}
+		if err := validator.CheckSchema(content); err != nil {
+			return fmt.Errorf("schema %s: %w", file.Name, err)
+		}
+		sum := sha256.Sum256(content)
+		actual := hex.EncodeToString(sum[:])
+		if pin {
+			manifest.Files[i].SHA256 = actual
+		} else if actual != file.SHA256 {
+			return fmt.Errorf("checksum mismatch for schema %s: expected %s, got %s", file.Name, file.SHA256, actual)
+		}
+		contents[file.Name] = content
+	}
+	if pin {
+		if err := writeManifest(manifest); err != nil {
+			return err
+		}
+	}
+
+	// Write only once every file matched the manifest
+	target := filepath.Join(bundleDir, manifest.Package)
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	for i, file := range manifest.Files {
+		if err := os.WriteFile(filepath.Join(target, file.Name), contents[file.Name], 0o644); err != nil {
+			return fmt.Errorf("failed to write schema %s: %w", file.Name, err)
+		}
+		fmt.Printf("  - %s %s\n", file.Name, manifest.Files[i].SHA256)
 	}
 
-	fmt.Println("Available schemas:")
-	for _, schema := range schemas {
-		fmt.Printf("  - %s\n", schema)
+	fmt.Printf("Package %s bundled in %s; rebuild the binaries to embed it\n", manifest.Package, target)
+	return nil
+}
+
+// writeManifest rewrites the manifest of a package in the source tree
+func writeManifest(manifest *validator.Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(manifestsDir, manifest.Package+".json")
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
 	}
+	fmt.Printf("Manifest %s pinned\n", path)
+	return nil
 }
@@ -6,6 +6,7 @@
 set -e
 
 API_URL="http://localhost:8080/api/v1"
+API_KEY="${API_KEY:?set API_KEY to a company API key (POST /api/admin/companies/{id}/api-keys)}"
 TEST_DATA_FILE="/tmp/nfce_test_data.json"
 
 echo "🧪 Testing NFC-e API and Worker"
@@ -61,7 +62,7 @@ echo "🔑 Generated idempotency key: $IDEMPOTENCY_KEY"
 # Test 2: Create NFC-e
 echo ""
 echo "📤 Creating NFC-e..."
-CREATE_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" \
+CREATE_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" -H "X-API-Key: $API_KEY" \
   -X POST "$API_URL/api/v1/nfce" \
   -H "Content-Type: application/json" \
   -H "Idempotency-Key: $IDEMPOTENCY_KEY" \
@@ -94,7 +95,7 @@ if [ -n "$NFCE_ID" ] && [ "$NFCE_ID" != "null" ]; then
     ATTEMPT=1
 
     while [ $ATTEMPT -le $MAX_ATTEMPTS ]; do
-        STATUS_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" "$API_URL/api/v1/nfce/$NFCE_ID")
+        STATUS_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" -H "X-API-Key: $API_KEY" "$API_URL/api/v1/nfce/$NFCE_ID")
         HTTP_STATUS=$(echo "$STATUS_RESPONSE" | tail -n1)
 
         if echo "$HTTP_STATUS" | grep -q "200"; then
@@ -148,7 +149,7 @@ if [ -n "$NFCE_ID" ] && [ "$NFCE_ID" != "null" ]; then
         echo "📁 Testing file downloads..."
 
         # Test XML download
-        XML_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" "$API_URL/api/v1/nfce/$NFCE_ID/xml")
+        XML_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" -H "X-API-Key: $API_KEY" "$API_URL/api/v1/nfce/$NFCE_ID/xml")
         if echo "$XML_RESPONSE" | grep -q "HTTP_STATUS:200"; then
             echo "✅ XML download successful"
         else
@@ -156,7 +157,7 @@ if [ -n "$NFCE_ID" ] && [ "$NFCE_ID" != "null" ]; then
         fi
 
         # Test PDF download
-        PDF_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" "$API_URL/api/v1/nfce/$NFCE_ID/pdf")
+        PDF_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" -H "X-API-Key: $API_KEY" "$API_URL/api/v1/nfce/$NFCE_ID/pdf")
         if echo "$PDF_RESPONSE" | grep -q "HTTP_STATUS:200"; then
             echo "✅ PDF download successful"
         else
@@ -164,7 +165,7 @@ if [ -n "$NFCE_ID" ] && [ "$NFCE_ID" != "null" ]; then
         fi
 
         # Test QR Code download
-        QR_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" "$API_URL/api/v1/nfce/$NFCE_ID/qrcode")
+        QR_RESPONSE=$(curl -s -w "\nHTTP_STATUS:%{http_code}" -H "X-API-Key: $API_KEY" "$API_URL/api/v1/nfce/$NFCE_ID/qrcode")
         if echo "$QR_RESPONSE" | grep -q "HTTP_STATUS:200"; then
             echo "✅ QR Code download successful"
         else